@@ -21,8 +21,15 @@ const (
 	publicURL         string = "/public"
 	protectedURL      string = "/protected"
 	protectedClaimURL string = "/protected/{claim}/{value}"
+	protectedAdminURL string = "/admin"
 )
 
+// permissions maps the groups and scopes of the token to the permissions of the API
+var permissions = rs.PermissionMapper{
+	{Claim: "groups", Values: map[string][]rs.Permission{"admins": {"admin"}}},
+	{Claim: "scope", Values: map[string][]rs.Permission{"api.admin": {"admin"}}},
+}
+
 func main() {
 	keyPath := os.Getenv("KEY")
 	port := os.Getenv("PORT")
@@ -85,6 +92,13 @@ func main() {
 		w.Write([]byte("authorized with value " + value))
 	})
 
+	// protected url which requires the admin permission,
+	// granted by the admins group or the api.admin scope
+	router.With(rs.Middleware(provider, permissions, rs.RequireAll("admin"))).
+		HandleFunc(protectedAdminURL, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("authorized as admin " + rs.ClaimsFromContext(r.Context()).Subject))
+		})
+
 	lis := fmt.Sprintf("127.0.0.1:%s", port)
 	log.Printf("listening on http://%s/", lis)
 	log.Fatal(http.ListenAndServe(lis, router))
@@ -43,6 +43,14 @@ func main() {
 			logrus.Fatalf("error parsing PKCE %s", err.Error())
 		}
 	}
+	var par bool
+	if parEnv, ok := os.LookupEnv("PAR"); ok {
+		var err error
+		par, err = strconv.ParseBool(parEnv)
+		if err != nil {
+			logrus.Fatalf("error parsing PAR %s", err.Error())
+		}
+	}
 	redirectURI := fmt.Sprintf("http://localhost:%v%v", port, callbackPath)
 	cookieHandler := httphelper.NewCookieHandler(key, key, httphelper.WithUnsecure())
 
@@ -76,6 +84,9 @@ func main() {
 	if pkce {
 		options = append(options, rp.WithPKCE(cookieHandler))
 	}
+	if par {
+		options = append(options, rp.WithPAR(""))
+	}
 
 	// One can add a logger to the context,
 	// pre-defining log attributes as required.
@@ -12,10 +12,12 @@
 //	KEY_PATH: Path to a private key file, used to for JWT authentication of the App. Only required if the OP expects this type of authentication.
 //	SCOPES: Scopes of the Authentication Request. Optional.
 //
-// Basic usage:
+// Basic usage, against the example server (go run github.com/lmindwarel/oidc/v3/example/server),
+// which registers the device client and serves the user code entry and approval UI at /device,
+// login with user `test-user@localhost` and password `verysecure`:
 //
 //	cd example/client/device
-//	export ISSUER="http://localhost:9000" CLIENT_ID="246048465824634593@demo"
+//	export ISSUER="http://localhost:9998/" CLIENT_ID="device" CLIENT_SECRET="secret"
 //
 // Get an Access Token:
 //
@@ -47,6 +49,7 @@ import (
 
 	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/qrcode"
 )
 
 var (
@@ -85,9 +88,12 @@ func main() {
 	}
 	logrus.Info("resp", resp)
 	fmt.Printf("\nPlease browse to %s and enter code %s\n", resp.VerificationURI, resp.UserCode)
+	if code, err := rp.DeviceAuthorizationQRCode(resp, qrcode.Low); err == nil {
+		fmt.Printf("or scan the QR code:\n\n%s\n", code.ASCII(true))
+	}
 
 	logrus.Info("start polling")
-	token, err := rp.DeviceAccessToken(ctx, resp.DeviceCode, time.Duration(resp.Interval)*time.Second, provider)
+	token, err := rp.DeviceAccessTokenLongPoll(ctx, resp.DeviceCode, time.Duration(resp.Interval)*time.Second, 30*time.Second, provider)
 	if err != nil {
 		logrus.Fatal(err)
 	}
@@ -2,9 +2,11 @@
 Package example contains some example of the various use of this library:
 
 /api        example of an api / resource server implementation using token introspection
+/device     CLI / TV app running the device authorization grant, with the user code entered at the OP
 /app        web app / RP demonstrating authorization code flow using various authentication methods (code, PKCE, JWT profile)
 /github     example of the extended OAuth2 library, providing an HTTP client with a reuse token source
 /service    demonstration of JWT Profile Authorization Grant
+/fullstack  SPA served by a BFF (rp), calling a resource server (rs), with the OP in one docker-compose
 /server		examples of an OpenID Provider implementations (including dynamic) with some very basic
 */
 package example
@@ -0,0 +1,68 @@
+// Command api is the resource server of the fullstack example.
+// It validates the access tokens forwarded by the BFF by introspection.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client/rs"
+)
+
+// permissions maps the scopes of the token to the permissions of the API
+var permissions = rs.PermissionMapper{
+	{Claim: "scope", Values: map[string][]rs.Permission{"profile": {"profile:read"}}},
+}
+
+func main() {
+	issuer := envOr("ISSUER", "http://localhost:9998/")
+	clientID := envOr("CLIENT_ID", "api")
+	clientSecret := envOr("CLIENT_SECRET", "secret")
+	port := envOr("PORT", "9997")
+
+	// the example OP only allows the audience of a token to introspect it,
+	// so the API introspects with the credentials of the client the BFF logs in with.
+	provider, err := rs.NewResourceServerClientCredentials(context.TODO(), issuer, clientID, clientSecret,
+		rs.WithCache(nil, 30*time.Second),
+	)
+	if err != nil {
+		log.Fatalf("error creating resource server: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/me", rs.Middleware(provider, permissions, rs.RequireAll("profile:read"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := rs.ClaimsFromContext(r.Context())
+			writeJSON(w, map[string]any{
+				"subject":  claims.Subject,
+				"username": claims.Username,
+				"email":    claims.Email,
+				"scope":    claims.Scope,
+			})
+		}),
+	))
+	mux.HandleFunc("/api/time", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"time": time.Now()})
+	})
+
+	log.Printf("api listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,219 @@
+// Command bff is the backend for frontend of the fullstack example.
+// It serves the SPA, logs the user in with the authorization code flow
+// and keeps the tokens server side, so the browser only holds a session cookie.
+// Calls of the SPA to /api/ are forwarded to the API with the access token,
+// which is refreshed when it expires.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+const (
+	callbackPath = "/auth/callback"
+	sessionName  = "bff_session"
+)
+
+//go:embed static
+var static embed.FS
+
+var key = []byte("test1234test1234")
+
+// session holds the tokens of a logged in user.
+type session struct {
+	mu     sync.Mutex
+	tokens *oidc.Tokens[*oidc.IDTokenClaims]
+}
+
+// sessions is an in-memory session store, keyed by the session cookie.
+type sessions struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func (s *sessions) create(tokens *oidc.Tokens[*oidc.IDTokenClaims]) string {
+	id := make([]byte, 32)
+	rand.Read(id)
+	sessionID := base64.RawURLEncoding.EncodeToString(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = &session{tokens: tokens}
+	return sessionID
+}
+
+func (s *sessions) get(r *http.Request) (string, *session) {
+	cookie, err := r.Cookie(sessionName)
+	if err != nil {
+		return "", nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cookie.Value, s.sessions[cookie.Value]
+}
+
+func (s *sessions) delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+func main() {
+	issuer := envOr("ISSUER", "http://localhost:9998/")
+	clientID := envOr("CLIENT_ID", "api")
+	clientSecret := envOr("CLIENT_SECRET", "secret")
+	port := envOr("PORT", "9999")
+	apiURL, err := url.Parse(envOr("API_URL", "http://localhost:9997/"))
+	if err != nil {
+		log.Fatalf("invalid API_URL: %v", err)
+	}
+	publicURL := envOr("PUBLIC_URL", "http://localhost:"+port)
+
+	cookieHandler := httphelper.NewCookieHandler(key, key, httphelper.WithUnsecure())
+	provider, err := rp.NewRelyingPartyOIDC(context.TODO(), issuer, clientID, clientSecret, publicURL+callbackPath,
+		[]string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail, oidc.ScopeOfflineAccess},
+		rp.WithCookieHandler(cookieHandler),
+		rp.WithPKCE(cookieHandler),
+		rp.WithVerifierOpts(rp.WithIssuedAtOffset(5*time.Second)),
+	)
+	if err != nil {
+		log.Fatalf("error creating relying party: %v", err)
+	}
+	store := &sessions{sessions: make(map[string]*session)}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServerFS(mustSub(static, "static")))
+	mux.Handle("/login", rp.AuthURLHandler(uuid.NewString, provider))
+	mux.Handle(callbackPath, rp.CodeExchangeHandler(
+		func(w http.ResponseWriter, r *http.Request, tokens *oidc.Tokens[*oidc.IDTokenClaims], state string, _ rp.RelyingParty) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     sessionName,
+				Value:    store.create(tokens),
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			http.Redirect(w, r, "/", http.StatusFound)
+		}, provider))
+
+	// the SPA asks the BFF for the user of the session
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		_, s := store.get(r)
+		if s == nil {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		s.mu.Lock()
+		claims := s.tokens.IDTokenClaims
+		expiry := s.tokens.Expiry
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"subject":              claims.Subject,
+			"access_token_expires": expiry,
+		})
+	})
+
+	// logout revokes the refresh token and ends the session at the OP
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		sessionID, s := store.get(r)
+		if s == nil {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		store.delete(sessionID)
+		http.SetCookie(w, &http.Cookie{Name: sessionName, Path: "/", MaxAge: -1})
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.tokens.RefreshToken != "" {
+			if err := rp.RevokeToken(r.Context(), provider, s.tokens.RefreshToken, "refresh_token"); err != nil {
+				log.Printf("unable to revoke refresh token: %v", err)
+			}
+		}
+		endSession, err := rp.EndSession(r.Context(), provider, s.tokens.IDToken, "", "")
+		if err != nil {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, endSession.String(), http.StatusFound)
+	})
+
+	// calls of the SPA to the API are forwarded with the access token of the session
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(apiURL)
+			pr.Out.Header.Del("Cookie")
+		},
+	}
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		_, s := store.get(r)
+		if s == nil {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		accessToken, err := s.accessToken(r.Context(), provider)
+		if err != nil {
+			http.Error(w, "session expired", http.StatusUnauthorized)
+			return
+		}
+		r.Header.Set("Authorization", oidc.PrefixBearer+accessToken)
+		proxy.ServeHTTP(w, r)
+	})
+
+	log.Printf("bff listening on %s", publicURL)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
+
+// accessToken returns the access token of the session,
+// refreshed with the refresh token if it is about to expire.
+func (s *session) accessToken(ctx context.Context, provider rp.RelyingParty) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Until(s.tokens.Expiry) > 10*time.Second {
+		return s.tokens.AccessToken, nil
+	}
+	tokens, err := rp.RefreshTokens[*oidc.IDTokenClaims](ctx, provider, s.tokens.RefreshToken, "", "")
+	if err != nil {
+		return "", err
+	}
+	if tokens.IDToken == "" {
+		tokens.IDToken, tokens.IDTokenClaims = s.tokens.IDToken, s.tokens.IDTokenClaims
+	}
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = s.tokens.RefreshToken
+	}
+	s.tokens = tokens
+	return tokens.AccessToken, nil
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return sub
+}
+
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
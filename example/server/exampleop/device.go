@@ -15,7 +15,8 @@ import (
 )
 
 type deviceAuthenticate interface {
-	CheckUsernamePasswordSimple(username, password string) error
+	// CheckUsernamePasswordSimple returns the id of the user, if the password is correct.
+	CheckUsernamePasswordSimple(username, password string) (string, error)
 	op.DeviceAuthorizationStorage
 
 	// GetDeviceAuthorizationByUserCode resturns the current state of the device authorization flow,
@@ -121,7 +122,7 @@ const userCodeCookieName = "user_code"
 
 type userCodeCookie struct {
 	UserCode string
-	UserName string
+	Subject  string
 }
 
 func (d *deviceLogin) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -146,7 +147,8 @@ func (d *deviceLogin) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := d.storage.CheckUsernamePasswordSimple(username, password); err != nil {
+	subject, err := d.storage.CheckUsernamePasswordSimple(username, password)
+	if err != nil {
 		redirectBack(w, r, err.Error())
 		return
 	}
@@ -156,7 +158,7 @@ func (d *deviceLogin) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	encoded, err := d.cookie.Encode(userCodeCookieName, userCodeCookie{userCode, username})
+	encoded, err := d.cookie.Encode(userCodeCookieName, userCodeCookie{userCode, subject})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -189,7 +191,7 @@ func (d *deviceLogin) confirmHandler(w http.ResponseWriter, r *http.Request) {
 	action := r.Form.Get("action")
 	switch action {
 	case "allowed":
-		err = d.storage.CompleteDeviceAuthorization(r.Context(), data.UserCode, data.UserName)
+		err = d.storage.CompleteDeviceAuthorization(r.Context(), data.UserCode, data.Subject)
 	case "denied":
 		err = d.storage.DenyDeviceAuthorization(r.Context(), data.UserCode)
 	default:
@@ -115,6 +115,7 @@ func newOP(storage op.Storage, issuer string, key [32]byte, logger *slog.Logger,
 		DeviceAuthorization: op.DeviceAuthorizationConfig{
 			Lifetime:     5 * time.Minute,
 			PollInterval: 5 * time.Second,
+			MaxWait:      30 * time.Second,
 			UserFormPath: "/device",
 			UserCode:     op.UserCodeBase20,
 		},
@@ -125,6 +126,14 @@ func newOP(storage op.Storage, issuer string, key [32]byte, logger *slog.Logger,
 			op.WithAllowInsecure(),
 			// as an example on how to customize an endpoint this will change the authorization_endpoint from /authorize to /auth
 			op.WithCustomAuthEndpoint(op.NewEndpoint("auth")),
+			// clients may push their auth requests to the /par endpoint
+			op.WithPushedAuthorizationRequests(op.PushedAuthorizationConfig{}),
+			// clients may request the authentication of users on their devices at the /bc-authorize endpoint
+			op.WithBackchannelAuthentication(op.BackchannelAuthenticationConfig{}),
+			// clients may register themselves at the /register endpoint (open to anyone, without initial access token)
+			op.WithClientRegistration(op.ClientRegistrationConfig{OpenRegistration: true}),
+			// the web client may transfer the sessions of its users to the native app at the /session_transfer endpoint
+			op.WithSessionTransfer(op.SessionTransferConfig{Clients: []string{"web", "native"}}),
 			// Pass our logger to the OP
 			op.WithLogger(logger.WithGroup("op")),
 		}, extraOptions...)...,
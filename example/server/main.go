@@ -31,9 +31,10 @@ func main() {
 	issuer := fmt.Sprintf("http://localhost:%s/", cfg.Port)
 
 	storage.RegisterClients(
-		storage.NativeClient("native", cfg.RedirectURI...),
+		storage.FirstPartyNativeClient("native", cfg.RedirectURI...),
 		storage.WebClient("web", "secret", cfg.RedirectURI...),
 		storage.WebClient("api", "secret", cfg.RedirectURI...),
+		storage.DeviceClient("device", "secret"),
 	)
 
 	// the OpenIDProvider interface needs a Storage interface handling various checks and state manipulations
@@ -110,7 +110,7 @@ func (c *Client) RestrictAdditionalAccessTokenScopes() func(scopes []string) []s
 // IsScopeAllowed enables Client specific custom scopes validation
 // in this example we allow the CustomScope for all clients
 func (c *Client) IsScopeAllowed(scope string) bool {
-	return scope == CustomScope
+	return scope == CustomScope || scope == oidc.ScopeConsentReceipts
 }
 
 // IDTokenUserinfoClaimsAssertion allows specifying if claims of scope profile, email, phone and address are asserted into the id_token
@@ -167,6 +167,14 @@ func NativeClient(id string, redirectURIs ...string) *Client {
 	}
 }
 
+// FirstPartyNativeClient will create a client like NativeClient, which may also redeem
+// the session transfer tokens of the first-party web client (op.WithSessionTransfer)
+func FirstPartyNativeClient(id string, redirectURIs ...string) *Client {
+	client := NativeClient(id, redirectURIs...)
+	client.grantTypes = append(client.grantTypes, oidc.GrantTypeSessionTransfer)
+	return client
+}
+
 // WebClient will create a client of type web, which will always use Basic Auth and allow the use of refresh tokens
 // user-defined redirectURIs may include:
 // - http://localhost with port specification (e.g. http://localhost:9999/auth/callback)
@@ -211,6 +219,47 @@ func DeviceClient(id, secret string) *Client {
 	}
 }
 
+// BackchannelClient creates a client of the Client-Initiated Backchannel Authentication Flow
+// with Basic authentication, which polls the token endpoint.
+func BackchannelClient(id, secret string) *Client {
+	return &Client{
+		id:                             id,
+		secret:                         secret,
+		redirectURIs:                   nil,
+		applicationType:                op.ApplicationTypeWeb,
+		authMethod:                     oidc.AuthMethodBasic,
+		loginURL:                       defaultLoginURL,
+		responseTypes:                  []oidc.ResponseType{oidc.ResponseTypeCode},
+		grantTypes:                     []oidc.GrantType{oidc.GrantTypeCIBA},
+		accessTokenType:                op.AccessTokenTypeBearer,
+		devMode:                        false,
+		idTokenUserinfoClaimsAssertion: false,
+		clockSkew:                      0,
+	}
+}
+
+// registeredClient creates a client from the metadata of the Dynamic Client Registration.
+func registeredClient(id, secret string, metadata *oidc.ClientMetadata) *Client {
+	applicationType := op.ApplicationTypeWeb
+	if metadata.ApplicationType == oidc.ApplicationTypeNative {
+		applicationType = op.ApplicationTypeNative
+	}
+	return &Client{
+		id:                             id,
+		secret:                         secret,
+		redirectURIs:                   metadata.RedirectURIs,
+		applicationType:                applicationType,
+		authMethod:                     metadata.TokenEndpointAuthMethod,
+		loginURL:                       defaultLoginURL,
+		responseTypes:                  metadata.ResponseTypes,
+		grantTypes:                     metadata.GrantTypes,
+		accessTokenType:                op.AccessTokenTypeBearer,
+		devMode:                        false,
+		idTokenUserinfoClaimsAssertion: false,
+		clockSkew:                      0,
+	}
+}
+
 type hasRedirectGlobs struct {
 	*Client
 }
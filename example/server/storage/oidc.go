@@ -39,8 +39,11 @@ type AuthRequest struct {
 	Nonce         string
 	CodeChallenge *OIDCCodeChallenge
 
-	done     bool
-	authTime time.Time
+	done      bool
+	authTime  time.Time
+	amr       []string
+	acr       string
+	sessionID string
 }
 
 // LogValue allows you to define which fields will be logged.
@@ -61,15 +64,30 @@ func (a *AuthRequest) GetID() string {
 }
 
 func (a *AuthRequest) GetACR() string {
-	return "" // we won't handle acr in this example
+	return a.acr
 }
 
 func (a *AuthRequest) GetAMR() []string {
-	// this example only uses password for authentication
-	if a.done {
-		return []string{"pwd"}
+	return a.amr
+}
+
+// GetSessionID implements the op.SessionIDRequest interface
+func (a *AuthRequest) GetSessionID() string {
+	return a.sessionID
+}
+
+// GetPrompt implements the op.AuthRequestPrompt interface
+func (a *AuthRequest) GetPrompt() []string {
+	return a.Prompt
+}
+
+// GetMaxAge implements the op.AuthRequestPrompt interface
+func (a *AuthRequest) GetMaxAge() *uint {
+	if a.MaxAuthAge == nil {
+		return nil
 	}
-	return nil
+	maxAge := uint(a.MaxAuthAge.Seconds())
+	return &maxAge
 }
 
 func (a *AuthRequest) GetAudience() []string {
@@ -206,6 +224,20 @@ type RefreshTokenRequest struct {
 	*RefreshToken
 }
 
+// GetSessionID implements the op.SessionIDRequest interface
+func (r *RefreshTokenRequest) GetSessionID() string {
+	return r.SessionID
+}
+
+// GetDPoPThumbprint implements the op.DPoPBoundRefreshTokenRequest interface
+func (r *RefreshTokenRequest) GetDPoPThumbprint() string {
+	return r.DPoPThumbprint
+}
+
+func (r *RefreshTokenRequest) GetACR() string {
+	return r.ACR
+}
+
 func (r *RefreshTokenRequest) GetAMR() []string {
 	return r.AMR
 }
@@ -233,3 +265,11 @@ func (r *RefreshTokenRequest) GetSubject() string {
 func (r *RefreshTokenRequest) SetCurrentScopes(scopes []string) {
 	r.Scopes = scopes
 }
+
+func (r *RefreshTokenRequest) GetGrantedScopes() []string {
+	return r.Grant.Scopes
+}
+
+func (r *RefreshTokenRequest) GetGrantedAudience() []string {
+	return r.Grant.Audience
+}
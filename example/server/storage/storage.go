@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -49,6 +50,12 @@ type Storage struct {
 	deviceCodes   map[string]deviceAuthorizationEntry
 	userCodes     map[string]string
 	serviceUsers  map[string]*Client
+
+	pushedAuthRequests map[string]pushedAuthRequestEntry
+	backchannelAuthns  map[string]backchannelAuthnEntry
+	registrations      map[string]*op.RegisteredClient
+
+	deviceNotifier op.DeviceAuthorizationNotifier
 }
 
 type signingKey struct {
@@ -114,8 +121,11 @@ func NewStorageWithClients(userStore UserStore, clients map[string]*Client) *Sto
 			algorithm: jose.RS256,
 			key:       key,
 		},
-		deviceCodes: make(map[string]deviceAuthorizationEntry),
-		userCodes:   make(map[string]string),
+		deviceCodes:        make(map[string]deviceAuthorizationEntry),
+		userCodes:          make(map[string]string),
+		pushedAuthRequests: make(map[string]pushedAuthRequestEntry),
+		backchannelAuthns:  make(map[string]backchannelAuthnEntry),
+		registrations:      make(map[string]*op.RegisteredClient),
 		serviceUsers: map[string]*Client{
 			"sid1": {
 				id:     "sid1",
@@ -132,42 +142,64 @@ func NewStorageWithClients(userStore UserStore, clients map[string]*Client) *Sto
 // CheckUsernamePassword implements the `authenticate` interface of the login
 func (s *Storage) CheckUsernamePassword(username, password, id string) error {
 	s.lock.Lock()
-	defer s.lock.Unlock()
-	request, ok := s.authRequests[id]
-	if !ok {
+	if _, ok := s.authRequests[id]; !ok {
+		s.lock.Unlock()
 		return fmt.Errorf("request not found")
 	}
-
 	// for demonstration purposes we'll check we'll have a simple user store and
 	// a plain text password.  For real world scenarios, be sure to have the password
 	// hashed and salted (e.g. using bcrypt)
 	user := s.userStore.GetUserByUsername(username)
-	if user != nil && user.Password == password {
-		// be sure to set user id into the auth request after the user was checked,
-		// so that you'll be able to get more information about the user after the login
-		request.UserID = user.ID
-
-		// you will have to change some state on the request to guide the user through possible multiple steps of the login process
-		// in this example we'll simply check the username / password and set a boolean to true
-		// therefore we will also just check this boolean if the request / login has been finished
-		request.done = true
+	s.lock.Unlock()
+	if user == nil || user.Password != password {
+		return fmt.Errorf("username or password wrong")
+	}
 
-		request.authTime = time.Now()
+	// report the result of the authentication, so the user id, the methods used
+	// and the time of the authentication end up in the tokens of the auth request.
+	// For multiple steps of the login process, the methods of all steps would be reported
+	return op.CompleteAuthentication(context.Background(), s, id, op.AuthenticationResult{
+		Subject: user.ID,
+		Methods: []string{oidc.AMRPassword},
+	})
+}
 
-		return nil
+// CompleteAuthentication implements the op.AuthenticationStorage interface
+func (s *Storage) CompleteAuthentication(ctx context.Context, id string, result *op.AuthenticationResult) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	request, ok := s.authRequests[id]
+	if !ok {
+		return fmt.Errorf("request not found")
 	}
-	return fmt.Errorf("username or password wrong")
+	// be sure to set user id into the auth request after the user was checked,
+	// so that you'll be able to get more information about the user after the login
+	request.UserID = result.Subject
+	request.authTime = result.Time
+	request.amr = result.Methods
+	request.acr = result.ACR
+	request.sessionID = result.SessionID
+	// the request / login has been finished
+	request.done = true
+	return nil
 }
 
-func (s *Storage) CheckUsernamePasswordSimple(username, password string) error {
+// CheckUsernamePasswordSimple checks the password of the user
+// and returns its id, to be used as the subject of the tokens.
+func (s *Storage) CheckUsernamePasswordSimple(username, password string) (string, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	user := s.userStore.GetUserByUsername(username)
 	if user != nil && user.Password == password {
-		return nil
+		return user.ID, nil
 	}
-	return fmt.Errorf("username or password wrong")
+	return "", fmt.Errorf("username or password wrong")
+}
+
+// CheckCredentials implements the ui.Storage interface
+func (s *Storage) CheckCredentials(_ context.Context, username, password string) (string, error) {
+	return s.CheckUsernamePasswordSimple(username, password)
 }
 
 // CreateAuthRequest implements the op.Storage interface
@@ -234,6 +266,19 @@ func (s *Storage) SaveAuthCode(ctx context.Context, id string, code string) erro
 	return nil
 }
 
+// RedeemAuthCode implements the op.CodeRedeemer interface
+// it will be called before creating the tokens (in an authorization code flow),
+// the code is deleted under the lock, so it can only be redeemed once
+func (s *Storage) RedeemAuthCode(ctx context.Context, code string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.codes[code]; !ok {
+		return op.ErrCodeAlreadyRedeemed
+	}
+	delete(s.codes, code)
+	return nil
+}
+
 // DeleteAuthRequest implements the op.Storage interface
 // it will be called after creating the token response (id and access tokens) for a valid
 // - authentication request (in an implicit flow)
@@ -264,7 +309,7 @@ func (s *Storage) CreateAccessToken(ctx context.Context, request op.TokenRequest
 		applicationID = req.GetClientID()
 	}
 
-	token, err := s.accessToken(applicationID, "", request.GetSubject(), request.GetAudience(), request.GetScopes())
+	token, err := s.accessToken(applicationID, "", request.GetSubject(), request.GetAudience(), request.GetScopes(), sessionID(request))
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -280,16 +325,16 @@ func (s *Storage) CreateAccessAndRefreshTokens(ctx context.Context, request op.T
 	}
 
 	// get the information depending on the request type / implementation
-	applicationID, authTime, amr := getInfoFromRequest(request)
+	applicationID, authTime, amr, acr := getInfoFromRequest(request)
 
 	// if currentRefreshToken is empty (Code Flow) we will have to create a new refresh token
 	if currentRefreshToken == "" {
 		refreshTokenID := uuid.NewString()
-		accessToken, err := s.accessToken(applicationID, refreshTokenID, request.GetSubject(), request.GetAudience(), request.GetScopes())
+		accessToken, err := s.accessToken(applicationID, refreshTokenID, request.GetSubject(), request.GetAudience(), request.GetScopes(), sessionID(request))
 		if err != nil {
 			return "", "", time.Time{}, err
 		}
-		refreshToken, err := s.createRefreshToken(accessToken, amr, authTime)
+		refreshToken, err := s.createRefreshToken(accessToken, amr, acr, authTime, op.NewRefreshTokenGrant(request), op.DPoPThumbprintFromContext(ctx))
 		if err != nil {
 			return "", "", time.Time{}, err
 		}
@@ -301,7 +346,7 @@ func (s *Storage) CreateAccessAndRefreshTokens(ctx context.Context, request op.T
 
 	newRefreshToken = uuid.NewString()
 
-	accessToken, err := s.accessToken(applicationID, newRefreshToken, request.GetSubject(), request.GetAudience(), request.GetScopes())
+	accessToken, err := s.accessToken(applicationID, newRefreshToken, request.GetSubject(), request.GetAudience(), request.GetScopes(), sessionID(request))
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
@@ -318,12 +363,12 @@ func (s *Storage) exchangeRefreshToken(ctx context.Context, request op.TokenExch
 	authTime := request.GetAuthTime()
 
 	refreshTokenID := uuid.NewString()
-	accessToken, err := s.accessToken(applicationID, refreshTokenID, request.GetSubject(), request.GetAudience(), request.GetScopes())
+	accessToken, err := s.accessToken(applicationID, refreshTokenID, request.GetSubject(), request.GetAudience(), request.GetScopes(), sessionID(request))
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
 
-	refreshToken, err := s.createRefreshToken(accessToken, nil, authTime)
+	refreshToken, err := s.createRefreshToken(accessToken, nil, "", authTime, op.NewRefreshTokenGrant(request), op.DPoPThumbprintFromContext(ctx))
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
@@ -435,6 +480,18 @@ func (s *Storage) GetClientByClientID(ctx context.Context, clientID string) (op.
 	return RedirectGlobsClient(client), nil
 }
 
+// ListClients implements the op.ClientLister interface
+// it will be called by op.ReportClients to summarize the configuration of all clients
+func (s *Storage) ListClients(ctx context.Context) ([]op.Client, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	list := make([]op.Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		list = append(list, client)
+	}
+	return list, nil
+}
+
 // AuthorizeClientIDSecret implements the op.Storage interface
 // it will be called for validating the client_id, client_secret on token or introspection requests
 func (s *Storage) AuthorizeClientIDSecret(ctx context.Context, clientID, clientSecret string) error {
@@ -526,12 +583,57 @@ func (s *Storage) SetIntrospectionFromToken(ctx context.Context, introspection *
 			introspection.Scope = token.Scopes
 			//...and the client the token was issued to
 			introspection.ClientID = token.ApplicationID
+			//...and the session of the user, the token was issued for
+			introspection.SessionID = token.SessionID
 			return nil
 		}
 	}
 	return fmt.Errorf("token is not valid for this client")
 }
 
+// ConsentReceiptsToken implements the ConsentReceiptsToken method of op.ConsentReceiptStorage
+// it will be called for the consent receipts endpoint (op.WithConsentReceipts)
+// to validate the access token of the user, like SetUserinfoFromToken
+func (s *Storage) ConsentReceiptsToken(ctx context.Context, tokenID, subject string) ([]string, time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	token, ok := s.tokens[tokenID]
+	if !ok || token.Subject != subject {
+		return nil, time.Time{}, fmt.Errorf("token is invalid or has expired")
+	}
+	return token.Scopes, token.Expiration, nil
+}
+
+// ServiceToken implements the op.ServiceTokenStorage interface
+// it will be called for the introspection endpoint, before SetIntrospectionFromToken,
+// so tokens of service users are introspected with a uniform response
+func (s *Storage) ServiceToken(ctx context.Context, tokenID, clientID string) (*op.ServiceToken, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	token, ok := s.tokens[tokenID]
+	if !ok {
+		return nil, nil
+	}
+	service, ok := s.serviceUsers[token.Subject]
+	if !ok {
+		// the token was issued to a user
+		return nil, nil
+	}
+	if !slices.Contains(token.Audience, clientID) {
+		return nil, fmt.Errorf("token is not valid for this client")
+	}
+	return &op.ServiceToken{
+		ClientID:   service.id,
+		Subject:    service.id,
+		Scopes:     token.Scopes,
+		Audience:   token.Audience,
+		Expiration: token.Expiration,
+		Metadata: map[string]any{
+			"service_account": true,
+		},
+	}, nil
+}
+
 // GetPrivateClaimsFromScopes implements the op.Storage interface
 // it will be called for the creation of a JWT access token to assert claims for custom scopes
 func (s *Storage) GetPrivateClaimsFromScopes(ctx context.Context, userID, clientID string, scopes []string) (claims map[string]any, err error) {
@@ -586,7 +688,7 @@ func (s *Storage) Health(ctx context.Context) error {
 }
 
 // createRefreshToken will store a refresh_token in-memory based on the provided information
-func (s *Storage) createRefreshToken(accessToken *Token, amr []string, authTime time.Time) (string, error) {
+func (s *Storage) createRefreshToken(accessToken *Token, amr []string, acr string, authTime time.Time, grant op.RefreshTokenGrant, dpopThumbprint string) (string, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	token := &RefreshToken{
@@ -594,12 +696,17 @@ func (s *Storage) createRefreshToken(accessToken *Token, amr []string, authTime
 		Token:         accessToken.RefreshTokenID,
 		AuthTime:      authTime,
 		AMR:           amr,
+		ACR:           acr,
 		ApplicationID: accessToken.ApplicationID,
 		UserID:        accessToken.Subject,
 		Audience:      accessToken.Audience,
 		Expiration:    time.Now().Add(5 * time.Hour),
 		Scopes:        accessToken.Scopes,
 		AccessToken:   accessToken.ID,
+		SessionID:     accessToken.SessionID,
+		Grant:         grant,
+		// the op only enforces the binding for public clients
+		DPoPThumbprint: dpopThumbprint,
 	}
 	s.refreshTokens[token.ID] = token
 	return token.Token, nil
@@ -637,7 +744,7 @@ func (s *Storage) renewRefreshToken(currentRefreshToken, newRefreshToken, newAcc
 }
 
 // accessToken will store an access_token in-memory based on the provided information
-func (s *Storage) accessToken(applicationID, refreshTokenID, subject string, audience, scopes []string) (*Token, error) {
+func (s *Storage) accessToken(applicationID, refreshTokenID, subject string, audience, scopes []string, sessionID string) (*Token, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	token := &Token{
@@ -648,6 +755,7 @@ func (s *Storage) accessToken(applicationID, refreshTokenID, subject string, aud
 		Audience:       audience,
 		Expiration:     time.Now().Add(5 * time.Minute),
 		Scopes:         scopes,
+		SessionID:      sessionID,
 	}
 	s.tokens[token.ID] = token
 	return token, nil
@@ -780,17 +888,26 @@ func (s *Storage) getTokenExchangeClaims(ctx context.Context, request op.TokenEx
 	return claims
 }
 
-// getInfoFromRequest returns the clientID, authTime and amr depending on the op.TokenRequest type / implementation
-func getInfoFromRequest(req op.TokenRequest) (clientID string, authTime time.Time, amr []string) {
+// getInfoFromRequest returns the clientID, authTime, amr and acr depending on the op.TokenRequest type / implementation
+func getInfoFromRequest(req op.TokenRequest) (clientID string, authTime time.Time, amr []string, acr string) {
 	authReq, ok := req.(*AuthRequest) // Code Flow (with scope offline_access)
 	if ok {
-		return authReq.ApplicationID, authReq.authTime, authReq.GetAMR()
+		return authReq.ApplicationID, authReq.authTime, authReq.GetAMR(), authReq.GetACR()
 	}
 	refreshReq, ok := req.(*RefreshTokenRequest) // Refresh Token Request
 	if ok {
-		return refreshReq.ApplicationID, refreshReq.AuthTime, refreshReq.AMR
+		return refreshReq.ApplicationID, refreshReq.AuthTime, refreshReq.AMR, refreshReq.ACR
+	}
+	return "", time.Time{}, nil, ""
+}
+
+// sessionID returns the sid of the session the tokens are issued for,
+// kept by the refresh tokens, so it is stable for the session.
+func sessionID(req op.TokenRequest) string {
+	if sidReq, ok := req.(op.SessionIDRequest); ok {
+		return sidReq.GetSessionID()
 	}
-	return "", time.Time{}, nil
+	return ""
 }
 
 // customClaim demonstrates how to return custom claims based on provided information
@@ -809,6 +926,194 @@ func appendClaim(claims map[string]any, claim string, value any) map[string]any
 	return claims
 }
 
+type pushedAuthRequestEntry struct {
+	authReq *oidc.AuthRequest
+	expires time.Time
+}
+
+// StorePushedAuthRequest implements the op.PushedAuthRequestStorage interface
+// it will be called after a client pushed a valid auth request to the PAR endpoint
+func (s *Storage) StorePushedAuthRequest(ctx context.Context, requestURI string, authReq *oidc.AuthRequest, expires time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// purge the expired requests, a real storage would rather do this periodically
+	for uri, entry := range s.pushedAuthRequests {
+		if time.Now().After(entry.expires) {
+			delete(s.pushedAuthRequests, uri)
+		}
+	}
+	s.pushedAuthRequests[requestURI] = pushedAuthRequestEntry{authReq: authReq, expires: expires}
+	return nil
+}
+
+// ConsumePushedAuthRequest implements the op.PushedAuthRequestStorage interface
+// it will be called when the auth request references a pushed request by its request_uri
+func (s *Storage) ConsumePushedAuthRequest(ctx context.Context, requestURI string) (*oidc.AuthRequest, time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry, ok := s.pushedAuthRequests[requestURI]
+	if !ok {
+		return nil, time.Time{}, errors.New("request_uri not found")
+	}
+	// a request_uri can only be used once
+	delete(s.pushedAuthRequests, requestURI)
+	return entry.authReq, entry.expires, nil
+}
+
+// PushedAuthRequest implements the op.PushedAuthRequestStorage interface
+// it will be called when an auth request referencing a pushed request is only validated
+func (s *Storage) PushedAuthRequest(ctx context.Context, requestURI string) (*oidc.AuthRequest, time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry, ok := s.pushedAuthRequests[requestURI]
+	if !ok {
+		return nil, time.Time{}, errors.New("request_uri not found")
+	}
+	return entry.authReq, entry.expires, nil
+}
+
+// CreateRegisteredClient implements the op.ClientRegistrar interface
+// it will be called after a client registered itself at the registration endpoint
+func (s *Storage) CreateRegisteredClient(ctx context.Context, client *op.RegisteredClient) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// for this example we directly store the secret and the registration access token
+	// obviously you would not have them in plain text, but rather hashed and salted (e.g. using bcrypt)
+	registration := *client
+	s.registrations[client.ClientID] = &registration
+	s.clients[client.ClientID] = registeredClient(client.ClientID, client.ClientSecret, &client.Metadata)
+	return nil
+}
+
+// GetRegisteredClient implements the op.ClientRegistrar interface
+// it will be called by the client configuration endpoint to check the registration access token
+func (s *Storage) GetRegisteredClient(ctx context.Context, clientID, registrationAccessToken string) (*op.RegisteredClient, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	registration, ok := s.registrations[clientID]
+	if !ok || registration.RegistrationAccessToken != registrationAccessToken {
+		return nil, errors.New("registration not found")
+	}
+	client := *registration
+	return &client, nil
+}
+
+// UpdateRegisteredClient implements the op.ClientRegistrar interface
+// it will be called after the validation of the new metadata of a registered client
+func (s *Storage) UpdateRegisteredClient(ctx context.Context, clientID string, metadata *oidc.ClientMetadata) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	registration, ok := s.registrations[clientID]
+	if !ok {
+		return errors.New("registration not found")
+	}
+	registration.Metadata = *metadata
+	s.clients[clientID] = registeredClient(clientID, registration.ClientSecret, metadata)
+	return nil
+}
+
+// DeleteRegisteredClient implements the op.ClientRegistrar interface
+// it will be called when a registered client deregisters itself
+func (s *Storage) DeleteRegisteredClient(ctx context.Context, clientID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.registrations, clientID)
+	delete(s.clients, clientID)
+	return nil
+}
+
+type backchannelAuthnEntry struct {
+	userID string
+	state  *op.BackchannelAuthenticationState
+}
+
+// StoreBackchannelAuthentication implements the op.BackchannelAuthenticationStorage interface
+// it will be called after a client requested the authentication of a user at the backchannel authentication endpoint
+func (s *Storage) StoreBackchannelAuthentication(ctx context.Context, authReqID string, req *oidc.BackchannelAuthenticationRequest, idTokenHintSubject string, expires time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var user *User
+	switch {
+	case idTokenHintSubject != "":
+		user = s.userStore.GetUserByID(idTokenHintSubject)
+	case req.LoginHint != "":
+		user = s.userStore.GetUserByUsername(req.LoginHint)
+	default:
+		return oidc.ErrInvalidRequest().WithDescription("login_hint_token not supported")
+	}
+	if user == nil {
+		return oidc.ErrUnknownUserID()
+	}
+	// a real storage would now notify the authentication device of the user,
+	// showing the binding_message
+	s.backchannelAuthns[authReqID] = backchannelAuthnEntry{
+		userID: user.ID,
+		state: &op.BackchannelAuthenticationState{
+			AuthReqID:         authReqID,
+			ClientID:          req.ClientID,
+			Scopes:            req.Scopes,
+			NotificationToken: req.ClientNotificationToken,
+			Expires:           expires,
+		},
+	}
+	return nil
+}
+
+// GetBackchannelAuthenticationState implements the op.BackchannelAuthenticationStorage interface
+// it will be called by the token endpoint, when the client polls with the auth_req_id
+func (s *Storage) GetBackchannelAuthenticationState(ctx context.Context, clientID, authReqID string) (*op.BackchannelAuthenticationState, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry, ok := s.backchannelAuthns[authReqID]
+	if !ok || entry.state.ClientID != clientID {
+		return nil, errors.New("auth_req_id not found for client")
+	}
+	// the tokens can only be issued once
+	if entry.state.Done || entry.state.Denied {
+		delete(s.backchannelAuthns, authReqID)
+	}
+	return entry.state, nil
+}
+
+// CompleteBackchannelAuthentication is called, when the user approved the request
+// on the authentication device, it is not required to implement op.Storage
+func (s *Storage) CompleteBackchannelAuthentication(ctx context.Context, authReqID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry, ok := s.backchannelAuthns[authReqID]
+	if !ok {
+		return errors.New("auth_req_id not found")
+	}
+	entry.state.Subject = entry.userID
+	entry.state.AuthTime = time.Now()
+	entry.state.Done = true
+	return nil
+}
+
+// DenyBackchannelAuthentication is called, when the user denied the request
+// on the authentication device, it is not required to implement op.Storage
+func (s *Storage) DenyBackchannelAuthentication(ctx context.Context, authReqID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry, ok := s.backchannelAuthns[authReqID]
+	if !ok {
+		return errors.New("auth_req_id not found")
+	}
+	entry.state.Denied = true
+	return nil
+}
+
 type deviceAuthorizationEntry struct {
 	deviceCode string
 	userCode   string
@@ -880,6 +1185,7 @@ func (s *Storage) CompleteDeviceAuthorization(ctx context.Context, userCode, sub
 
 	entry.state.Subject = subject
 	entry.state.Done = true
+	s.deviceNotifier.Notify(entry.deviceCode)
 	return nil
 }
 
@@ -888,9 +1194,16 @@ func (s *Storage) DenyDeviceAuthorization(ctx context.Context, userCode string)
 	defer s.lock.Unlock()
 
 	s.deviceCodes[s.userCodes[userCode]].state.Denied = true
+	s.deviceNotifier.Notify(s.userCodes[userCode])
 	return nil
 }
 
+// WatchDeviceAuthorization implements the op.DeviceAuthorizationWatcher interface,
+// so device clients can wait for the completion instead of polling.
+func (s *Storage) WatchDeviceAuthorization(ctx context.Context, clientID, deviceCode string) (<-chan struct{}, error) {
+	return s.deviceNotifier.WatchDeviceAuthorization(ctx, clientID, deviceCode)
+}
+
 // AuthRequestDone is used by testing and is not required to implement op.Storage
 func (s *Storage) AuthRequestDone(id string) error {
 	s.lock.Lock()
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/lmindwarel/oidc/v3/pkg/op/storagetest"
+)
+
+func TestStorage_conformance(t *testing.T) {
+	RegisterClients(
+		WebClient("storagetest-web", "secret", "https://example.com"),
+		DeviceClient("storagetest-device", "secret"),
+	)
+	storage := NewStorage(NewUserStore("http://localhost"))
+	storagetest.TestStorage(t, storagetest.Setup{
+		Storage: func(t *testing.T) op.Storage {
+			return storage
+		},
+		ClientID:    "storagetest-web",
+		RedirectURI: "https://example.com",
+		UserID:      "id1",
+		CompleteAuthRequest: func(t *testing.T, _ op.Storage, id string) {
+			require.NoError(t, storage.AuthRequestDone(id))
+		},
+		DeviceClientID: "storagetest-device",
+		CompleteDeviceAuthorization: func(t *testing.T, _ op.Storage, userCode, subject string) {
+			require.NoError(t, storage.CompleteDeviceAuthorization(context.Background(), userCode, subject))
+		},
+		DenyDeviceAuthorization: func(t *testing.T, _ op.Storage, userCode string) {
+			require.NoError(t, storage.DenyDeviceAuthorization(context.Background(), userCode))
+		},
+	})
+}
@@ -1,6 +1,10 @@
 package storage
 
-import "time"
+import (
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
 
 type Token struct {
 	ID             string
@@ -10,6 +14,7 @@ type Token struct {
 	Audience       []string
 	Expiration     time.Time
 	Scopes         []string
+	SessionID      string
 }
 
 type RefreshToken struct {
@@ -17,10 +22,17 @@ type RefreshToken struct {
 	Token         string
 	AuthTime      time.Time
 	AMR           []string
+	ACR           string
 	Audience      []string
 	UserID        string
 	ApplicationID string
 	Expiration    time.Time
 	Scopes        []string
 	AccessToken   string // Token.ID
+	SessionID     string
+	// Grant are the scopes and audience of the initial issuance,
+	// which limit all refreshes of the token.
+	Grant op.RefreshTokenGrant
+	// DPoPThumbprint of the key the refresh token is bound to, if any.
+	DPoPThumbprint string
 }
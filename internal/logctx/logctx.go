@@ -0,0 +1,25 @@
+//go:build !oidc_nologging
+
+// Package logctx passes the logger of a request through its context.
+// By default it shares the context key of github.com/zitadel/logging,
+// which is used by the OpenID Provider. The oidc_nologging build tag
+// replaces it with a key of its own, to drop the dependency from
+// client binaries.
+package logctx
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/zitadel/logging"
+)
+
+// FromContext returns the logger set with [ToContext].
+func FromContext(ctx context.Context) (logger *slog.Logger, ok bool) {
+	return logging.FromContext(ctx)
+}
+
+// ToContext returns a copy of ctx carrying the logger.
+func ToContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return logging.ToContext(ctx, logger)
+}
@@ -0,0 +1,21 @@
+//go:build oidc_nologging
+
+package logctx
+
+import (
+	"context"
+	"log/slog"
+)
+
+type ctxKey struct{}
+
+// FromContext returns the logger set with [ToContext].
+func FromContext(ctx context.Context) (logger *slog.Logger, ok bool) {
+	logger, ok = ctx.Value(ctxKey{}).(*slog.Logger)
+	return logger, ok
+}
+
+// ToContext returns a copy of ctx carrying the logger.
+func ToContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
@@ -0,0 +1,19 @@
+package logctx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	logger := slog.Default()
+	got, ok := FromContext(ToContext(context.Background(), logger))
+	assert.True(t, ok)
+	assert.Same(t, logger, got)
+}
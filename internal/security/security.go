@@ -0,0 +1,114 @@
+// Package security handles the secrets of the module consistently:
+// client secrets, codes, tokens and key material are compared in constant
+// time, zeroized after use and redacted when formatted or logged.
+package security
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// Redacted replaces secrets in formatted and logged values.
+const Redacted = "[REDACTED]"
+
+// prefixMinLength is the minimal length of secrets, like tokens,
+// of which Redact keeps a prefix.
+const prefixMinLength = 20
+
+// Redact returns the redacted form of a token or secret for logs:
+// a short prefix, for long values only, followed by a truncated SHA-256 hash,
+// which correlates the value across log lines without revealing it.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	var prefix string
+	if len(secret) >= prefixMinLength {
+		prefix = secret[:4]
+	}
+	return prefix + "…sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// Attr returns the attribute of the redacted secret, see [Redact].
+func Attr(key, secret string) slog.Attr {
+	return slog.String(key, Redact(secret))
+}
+
+// String formats the log value of v like %v formats a struct, with its
+// attributes as key=value, for the String methods of types implementing
+// LogValue with redacted secrets. Empty attributes are omitted.
+func String(v slog.LogValuer) string {
+	value := v.LogValue().Resolve()
+	if value.Kind() != slog.KindGroup {
+		return value.String()
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for _, attr := range value.Group() {
+		text := attr.Value.Resolve().String()
+		if text == "" || text == "[]" || text == "0" {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(attr.Key)
+		b.WriteByte('=')
+		b.WriteString(text)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Equal reports if a and b are equal, in constant time.
+// They are compared by their SHA-256 hash, so the time
+// does not reveal the length of the secret either.
+func Equal(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// Zero overwrites the key material in b with zeros,
+// once it is no longer needed.
+func Zero(b []byte) {
+	clear(b)
+	runtime.KeepAlive(b)
+}
+
+// Secret is a string, which is redacted when formatted with the
+// fmt package, like %v, or logged with log/slog.
+// The value is only returned by Reveal.
+type Secret string
+
+// Reveal returns the secret value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String returns [Redacted], or an empty string for an unset secret.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// GoString redacts the secret for %#v.
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+// LogValue implements [slog.LogValuer].
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// Equal reports if the secret equals value, in constant time.
+func (s Secret) Equal(value string) bool {
+	return Equal(string(s), value)
+}
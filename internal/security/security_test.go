@@ -0,0 +1,76 @@
+package security
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal("secret", "secret"))
+	assert.False(t, Equal("secret", "secret2"))
+	assert.False(t, Equal("secret", ""))
+	assert.True(t, Equal("", ""))
+}
+
+func TestZero(t *testing.T) {
+	key := []byte("key material")
+	Zero(key)
+	assert.Equal(t, make([]byte, len(key)), key)
+}
+
+func TestSecret(t *testing.T) {
+	secret := Secret("s3cr3t")
+	value := struct {
+		ClientID string
+		Secret   Secret
+	}{"client", secret}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q"} {
+		assert.NotContains(t, fmt.Sprintf(format, value), "s3cr3t", format)
+		assert.NotContains(t, fmt.Sprintf(format, secret), "s3cr3t", format)
+	}
+	assert.Equal(t, "{client [REDACTED]}", fmt.Sprint(value))
+	assert.Equal(t, "", fmt.Sprint(Secret("")))
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("test", "secret", secret)
+	assert.NotContains(t, buf.String(), "s3cr3t")
+	assert.Contains(t, buf.String(), Redacted)
+
+	assert.Equal(t, "s3cr3t", secret.Reveal())
+	assert.True(t, secret.Equal("s3cr3t"))
+	assert.False(t, secret.Equal("other"))
+}
+
+func TestRedact(t *testing.T) {
+	token := "eyJhbGciOiJSUzI1NiJ9.payload.signature"
+	redacted := Redact(token)
+	assert.Regexp(t, `^eyJh…sha256:[0-9a-f]{8}$`, redacted)
+	assert.Equal(t, redacted, Redact(token), "stable")
+	assert.NotEqual(t, redacted, Redact(token+"x"))
+	assert.Regexp(t, `^…sha256:[0-9a-f]{8}$`, Redact("short"))
+	assert.Empty(t, Redact(""))
+}
+
+type testRequest struct {
+	ClientID string
+	Token    string
+	Count    int
+}
+
+func (r testRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("client_id", r.ClientID),
+		Attr("token", r.Token),
+		slog.Int("count", r.Count),
+	)
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "{client_id=client token="+Redact("secret")+"}", String(testRequest{ClientID: "client", Token: "secret"}))
+	assert.Equal(t, "{count=2}", String(testRequest{Count: 2}))
+}
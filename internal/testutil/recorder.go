@@ -0,0 +1,286 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RecordEnv is the environment variable enabling the record mode of a [Recorder].
+//
+//	OIDC_RECORD=1 go test ./pkg/client/...
+const RecordEnv = "OIDC_RECORD"
+
+// Redacted replaces secret values in recorded interactions.
+const Redacted = "REDACTED"
+
+// Default values redacted by a [Recorder].
+var (
+	RedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "DPoP", "Device-Proof"}
+	RedactParams  = []string{
+		"client_secret", "client_assertion", "code", "code_verifier",
+		"refresh_token", "access_token", "subject_token", "actor_token",
+		"device_code", "password", "token",
+	}
+	RedactFields = []string{
+		"access_token", "refresh_token", "client_secret",
+		"device_code", "registration_access_token",
+	}
+)
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     string      `json:"body,omitempty"`
+	Response Response    `json:"response"`
+}
+
+// Response is a recorded http response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is a http.RoundTripper recording live interactions with a provider
+// (discovery, token, userinfo, ...) into a fixture file, and replaying them
+// deterministically, so tests against real provider response shapes run offline.
+//
+// Interactions are recorded if [RecordEnv] is set, otherwise replayed from the fixture.
+// Headers, form and query parameters and JSON response fields are redacted
+// before recording and before matching, by the Redact* lists of the Recorder.
+// A replayed request matches the first unused interaction with the same
+// method, URL and body.
+type Recorder struct {
+	// Path of the fixture file.
+	Path string
+	// Record enables recording, instead of replaying.
+	Record bool
+	// Transport used for recording, defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+
+	RedactHeaders []string
+	RedactParams  []string
+	RedactFields  []string
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewRecorder returns a Recorder for the fixture testdata/fixtures/<name>.json,
+// with the default redactions. Fixtures are loaded for replay,
+// recordings are saved when the test finishes.
+func NewRecorder(t testing.TB, name string) *Recorder {
+	t.Helper()
+	r := &Recorder{
+		Path:          filepath.Join("testdata", "fixtures", name+".json"),
+		Record:        os.Getenv(RecordEnv) != "",
+		RedactHeaders: RedactHeaders,
+		RedactParams:  RedactParams,
+		RedactFields:  RedactFields,
+	}
+	if r.Record {
+		t.Cleanup(func() {
+			if err := r.Save(); err != nil {
+				t.Errorf("recorder: %v", err)
+			}
+		})
+		return r
+	}
+	if err := r.Load(); err != nil {
+		t.Fatalf("recorder: %v (record with %s=1)", err, RecordEnv)
+	}
+	return r
+}
+
+// Client returns a http.Client using the Recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Load reads the interactions from the fixture file.
+func (r *Recorder) Load() error {
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		return err
+	}
+	var interactions []*Interaction
+	if err = json.Unmarshal(data, &interactions); err != nil {
+		return fmt.Errorf("%s: %w", r.Path, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = interactions
+	r.used = make([]bool, len(interactions))
+	return nil
+}
+
+// Save writes the recorded interactions to the fixture file.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, append(data, '\n'), 0o644)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	interaction := &Interaction{
+		Method: req.Method,
+		URL:    r.redactURL(req.URL),
+		Header: r.redactHeader(req.Header),
+		Body:   r.redactBody(req.Header.Get("Content-Type"), body),
+	}
+	if r.Record {
+		return r.record(req, interaction)
+	}
+	return r.replay(req, interaction)
+}
+
+func (r *Recorder) record(req *http.Request, interaction *Interaction) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	interaction.Response = Response{
+		StatusCode: resp.StatusCode,
+		Header:     r.redactHeader(resp.Header),
+		Body:       r.redactBody(resp.Header.Get("Content-Type"), body),
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.used = append(r.used, true)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// ErrNoInteraction is returned on replay, if no recorded interaction matches the request.
+var ErrNoInteraction = errors.New("recorder: no matching interaction")
+
+func (r *Recorder) replay(req *http.Request, interaction *Interaction) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rec := range r.interactions {
+		if r.used[i] || rec.Method != interaction.Method || rec.URL != interaction.URL || rec.Body != interaction.Body {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rec.Response.StatusCode, http.StatusText(rec.Response.StatusCode)),
+			StatusCode:    rec.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        rec.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(rec.Response.Body)),
+			ContentLength: int64(len(rec.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, interaction.Method, interaction.URL)
+}
+
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	header = header.Clone()
+	for _, key := range r.RedactHeaders {
+		if _, ok := header[http.CanonicalHeaderKey(key)]; ok {
+			header.Set(key, Redacted)
+		}
+	}
+	return header
+}
+
+func (r *Recorder) redactValues(values url.Values) url.Values {
+	for key := range values {
+		if slices.Contains(r.RedactParams, key) {
+			values.Set(key, Redacted)
+		}
+	}
+	return values
+}
+
+func (r *Recorder) redactURL(u *url.URL) string {
+	redacted := *u
+	if u.RawQuery != "" {
+		redacted.RawQuery = r.redactValues(u.Query()).Encode()
+	}
+	return redacted.String()
+}
+
+func (r *Recorder) redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			return r.redactValues(values).Encode()
+		}
+	case strings.Contains(contentType, "json"):
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			break
+		}
+		var redacted bool
+		for _, key := range r.RedactFields {
+			if _, ok := fields[key]; ok {
+				fields[key] = Redacted
+				redacted = true
+			}
+		}
+		if !redacted {
+			break
+		}
+		if data, err := json.Marshal(fields); err == nil {
+			return string(data)
+		}
+	}
+	return string(body)
+}
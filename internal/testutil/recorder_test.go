@@ -0,0 +1,85 @@
+package testutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestRecorder(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc(oidc.DiscoveryEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.DiscoveryConfiguration{
+			Issuer:        server.URL,
+			TokenEndpoint: server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		json.NewEncoder(w).Encode(&oidc.AccessTokenResponse{
+			AccessToken: "secret-at",
+			TokenType:   oidc.BearerToken,
+		})
+	})
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	ctx := context.Background()
+
+	recorder := &tu.Recorder{
+		Path:          path,
+		Record:        true,
+		RedactHeaders: tu.RedactHeaders,
+		RedactParams:  tu.RedactParams,
+		RedactFields:  tu.RedactFields,
+	}
+	recorded, err := client.Discover(ctx, server.URL, recorder.Client())
+	require.NoError(t, err)
+	tokenReq := func(c *http.Client) string {
+		form := url.Values{"grant_type": {"client_credentials"}, "client_secret": {"secret"}}
+		resp, err := c.Post(server.URL+"/token", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var token oidc.AccessTokenResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+		return token.AccessToken
+	}
+	assert.Equal(t, "secret-at", tokenReq(recorder.Client()))
+	require.NoError(t, recorder.Save())
+	server.Close()
+
+	fixture, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"secret-at", "session=secret", "client_secret=secret"} {
+		assert.NotContains(t, string(fixture), secret)
+	}
+
+	replayer := &tu.Recorder{
+		Path:          path,
+		RedactHeaders: tu.RedactHeaders,
+		RedactParams:  tu.RedactParams,
+		RedactFields:  tu.RedactFields,
+	}
+	require.NoError(t, replayer.Load())
+	replayed, err := client.Discover(ctx, server.URL, replayer.Client())
+	require.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, tu.Redacted, tokenReq(replayer.Client()))
+
+	_, err = replayer.Client().Get(server.URL + "/userinfo")
+	assert.ErrorIs(t, err, tu.ErrNoInteraction)
+}
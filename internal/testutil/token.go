@@ -33,6 +33,10 @@ const SignatureAlgorithm = jose.RS256
 var (
 	WebKey jose.JSONWebKey
 	Signer jose.Signer
+	// AccessTokenSigner signs with the at+jwt typ header of RFC 9068.
+	AccessTokenSigner jose.Signer
+	// LogoutTokenSigner signs with the logout+jwt typ header of logout tokens.
+	LogoutTokenSigner jose.Signer
 )
 
 func init() {
@@ -44,6 +48,14 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	AccessTokenSigner, err = jose.NewSigner(jose.SigningKey{Algorithm: SignatureAlgorithm, Key: WebKey}, (&jose.SignerOptions{}).WithType("at+jwt"))
+	if err != nil {
+		panic(err)
+	}
+	LogoutTokenSigner, err = jose.NewSigner(jose.SigningKey{Algorithm: SignatureAlgorithm, Key: WebKey}, (&jose.SignerOptions{}).WithType("logout+jwt"))
+	if err != nil {
+		panic(err)
+	}
 }
 
 type JWTProfileKeyStorage struct{}
@@ -57,11 +69,15 @@ func (JWTProfileKeyStorage) GetKeyByIDAndClientID(ctx context.Context, keyID str
 }
 
 func signEncodeTokenClaims(claims any) string {
+	return signEncodeClaims(Signer, claims)
+}
+
+func signEncodeClaims(signer jose.Signer, claims any) string {
 	payload, err := json.Marshal(claims)
 	if err != nil {
 		panic(err)
 	}
-	object, err := Signer.Sign(payload)
+	object, err := signer.Sign(payload)
 	if err != nil {
 		panic(err)
 	}
@@ -117,6 +133,25 @@ func NewAccessToken(issuer, subject string, audience []string, expiration time.T
 	return NewAccessTokenCustom(issuer, subject, audience, expiration, jwtid, clientID, skew, nil)
 }
 
+// NewJWTAccessTokenCustom is like NewAccessTokenCustom,
+// but signs the token with the at+jwt typ header of RFC 9068.
+func NewJWTAccessTokenCustom(issuer, subject string, audience []string, expiration time.Time, jwtid, clientID string, skew time.Duration, custom map[string]any) (string, *oidc.AccessTokenClaims) {
+	claims := oidc.NewAccessTokenClaims(issuer, subject, audience, expiration, jwtid, clientID, skew)
+	claims.Claims = custom
+	token := signEncodeClaims(AccessTokenSigner, claims)
+
+	// set this so that assertion in tests will work
+	claims.SignatureAlg = SignatureAlgorithm
+	claims.Claims = claimsMap(claims)
+	return token, claims
+}
+
+// NewJWTAccessToken is like NewAccessToken,
+// but signs the token with the at+jwt typ header of RFC 9068.
+func NewJWTAccessToken(issuer, subject string, audience []string, expiration time.Time, jwtid, clientID string, skew time.Duration) (string, *oidc.AccessTokenClaims) {
+	return NewJWTAccessTokenCustom(issuer, subject, audience, expiration, jwtid, clientID, skew, nil)
+}
+
 func NewJWTProfileAssertion(issuer, clientID string, audience []string, issuedAt, expiration time.Time) (string, *oidc.JWTTokenRequest) {
 	req := &oidc.JWTTokenRequest{
 		Issuer:    issuer,
@@ -167,6 +202,10 @@ func ValidAccessToken() (string, *oidc.AccessTokenClaims) {
 	return NewAccessToken(ValidIssuer, ValidSubject, ValidAudience, ValidExpiration, ValidJWTID, ValidClientID, ValidSkew)
 }
 
+func ValidJWTAccessToken() (string, *oidc.AccessTokenClaims) {
+	return NewJWTAccessToken(ValidIssuer, ValidSubject, ValidAudience, ValidExpiration, ValidJWTID, ValidClientID, ValidSkew)
+}
+
 func ValidJWTProfileAssertion() (string, *oidc.JWTTokenRequest) {
 	return NewJWTProfileAssertion(ValidClientID, ValidClientID, []string{ValidIssuer}, time.Now(), ValidExpiration)
 }
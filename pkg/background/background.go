@@ -0,0 +1,82 @@
+// Package background lets services supervise the goroutines the library
+// starts in the background, like the fetches of the keys of a jwks_uri,
+// token refreshes and cache revalidations, instead of leaking them.
+//
+// A [Group], such as an *errgroup.Group of golang.org/x/sync, is passed
+// by the context given to the library:
+//
+//	g, ctx := errgroup.WithContext(ctx)
+//	ctx = background.WithGroup(ctx, g)
+//	keySet := rp.NewRemoteKeySetContext(ctx, httpClient, jwksURL)
+//	...
+//	cancel() // on shutdown
+//	err := g.Wait()
+//
+// The goroutines stop with the context and [Group.Go] is passed their errors,
+// like a failed fetch of the keys, which is also returned to the callers waiting for it.
+// As the errors cancel the context of an errgroup, services which keep running
+// on such failures pass a Group, which handles the errors otherwise, e.g. by logging them.
+// Without a Group, goroutines are started as usual and their errors are dropped.
+package background
+
+import (
+	"context"
+)
+
+// Group runs goroutines and collects their errors,
+// like an *errgroup.Group of golang.org/x/sync.
+type Group interface {
+	Go(f func() error)
+}
+
+type groupKey struct{}
+
+type group struct {
+	Group
+	ctx context.Context
+}
+
+// WithGroup returns a context, which makes the library start
+// its background goroutines in g, until ctx is done.
+func WithGroup(ctx context.Context, g Group) context.Context {
+	return context.WithValue(ctx, groupKey{}, &group{Group: g, ctx: ctx})
+}
+
+func groupFromContext(ctx context.Context) (*group, bool) {
+	g, ok := ctx.Value(groupKey{}).(*group)
+	return g, ok
+}
+
+// Supervised reports if ctx has a [Group], see [WithGroup].
+func Supervised(ctx context.Context) bool {
+	_, ok := groupFromContext(ctx)
+	return ok
+}
+
+// Go runs f in the [Group] of ctx, or in a new goroutine, if it has none.
+// Without a Group, the error of f is dropped.
+func Go(ctx context.Context, f func() error) {
+	if g, ok := groupFromContext(ctx); ok {
+		g.Go(f)
+		return
+	}
+	go func() {
+		_ = f()
+	}()
+}
+
+// Detach returns a context for background work started by a request with ctx,
+// which keeps its values, such as the trace span, but is not canceled with it.
+// It is canceled with the context of the [Group] of ctx, if any, or by cancel.
+func Detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	g, ok := groupFromContext(ctx)
+	if !ok {
+		return detached, cancel
+	}
+	stop := context.AfterFunc(g.ctx, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
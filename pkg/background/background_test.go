@@ -0,0 +1,78 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testGroup is a minimal errgroup.
+type testGroup struct {
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+func (g *testGroup) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+		}
+	}()
+}
+
+func (g *testGroup) Wait() []error {
+	g.wg.Wait()
+	return g.errs
+}
+
+func TestGo(t *testing.T) {
+	errFatal := errors.New("fatal")
+	g := new(testGroup)
+	ctx, cancel := context.WithCancel(WithGroup(context.Background(), g))
+	assert.True(t, Supervised(ctx))
+	assert.False(t, Supervised(context.Background()))
+
+	Go(ctx, func() error {
+		<-ctx.Done()
+		return nil
+	})
+	Go(ctx, func() error { return errFatal })
+	cancel()
+	assert.Equal(t, []error{errFatal}, g.Wait())
+
+	done := make(chan struct{})
+	Go(context.Background(), func() error {
+		close(done)
+		return errFatal
+	})
+	<-done
+}
+
+func TestDetach(t *testing.T) {
+	requestCtx, cancelRequest := context.WithCancel(context.Background())
+	detached, cancel := Detach(requestCtx)
+	cancelRequest()
+	assert.NoError(t, detached.Err())
+	cancel()
+	assert.Error(t, detached.Err())
+
+	groupCtx, cancelGroup := context.WithCancel(context.Background())
+	requestCtx = WithGroup(groupCtx, new(testGroup))
+	detached, cancel = Detach(requestCtx)
+	defer cancel()
+	cancelGroup()
+	select {
+	case <-detached.Done():
+	case <-time.After(time.Second):
+		t.Fatal("detached context not canceled with the group")
+	}
+}
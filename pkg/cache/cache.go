@@ -0,0 +1,92 @@
+// Package cache defines the Cache used by the caches of the library,
+// like the JWKS, discovery documents and userinfo responses of the relying party, the
+// introspection results of the resource server and the replay detection
+// of proof JWTs. Passing a shared implementation, like the client of the
+// redis subpackage, lets multiple instances of a deployment share those
+// caches consistently. [Memory] caches within a single instance.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by Get for missing and expired keys.
+	ErrNotFound = errors.New("cache: key not found")
+	// ErrReplay is returned by [CheckReplay] for an already used ID.
+	ErrReplay = errors.New("cache: replay detected")
+)
+
+// Cache stores values by key with a time to live.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value of the key,
+	// or [ErrNotFound] if it is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores the value of the key for the ttl.
+	// A ttl of zero or less stores the value without expiry.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Adder is an optional interface of a Cache, which sets a value atomically
+// only if the key is missing. It is required for a reliable [CheckReplay]
+// across multiple instances.
+type Adder interface {
+	// Add stores the value for the ttl, if the key is missing,
+	// and reports if it was stored.
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+}
+
+// Add stores the value for the ttl if the key is missing, and reports if it was stored.
+// If c does not implement [Adder], the key is checked with Get before the Set,
+// which is not atomic.
+func Add(ctx context.Context, c Cache, key string, value []byte, ttl time.Duration) (bool, error) {
+	if adder, ok := c.(Adder); ok {
+		return adder.Add(ctx, key, value, ttl)
+	}
+	_, err := c.Get(ctx, key)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	return true, c.Set(ctx, key, value, ttl)
+}
+
+// CheckReplay records the ID, like the jti of a DPoP (RFC 9449, section 11.1)
+// or device proof, for the ttl and returns [ErrReplay] if it was already
+// recorded. The ttl must cover the time in which the proof is accepted.
+func CheckReplay(ctx context.Context, c Cache, id string, ttl time.Duration) error {
+	added, err := Add(ctx, c, "replay:"+id, []byte{1}, ttl)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrReplay
+	}
+	return nil
+}
+
+// GetJSON unmarshals the value of the key into v.
+func GetJSON(ctx context.Context, c Cache, key string, v any) error {
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, v)
+}
+
+// SetJSON stores v marshalled to JSON for the ttl.
+func SetJSON(ctx context.Context, c Cache, key string, v any, ttl time.Duration) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, value, ttl)
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-memory Cache and [Adder] of a single instance.
+// Expired entries are removed when they are read,
+// and all of them when the cache exceeds MaxEntries.
+type Memory struct {
+	// MaxEntries triggers the removal of expired entries, when exceeded.
+	// Defaults to 10000.
+	MaxEntries int
+	// Evict bounds the size to MaxEntries, by removing the entries closest
+	// to expiry if there are no expired ones. It must not be set for
+	// replay detection, see [CheckReplay], as evicted IDs could be replayed.
+	Evict bool
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		MaxEntries: 10000,
+	}
+}
+
+func (m *Memory) time() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if entry.expired(m.time()) {
+		delete(m.entries, key)
+		return nil, ErrNotFound
+	}
+	return entry.value, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *Memory) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.entries[key]; ok && !entry.expired(m.time()) {
+		return false, nil
+	}
+	m.set(key, value, ttl)
+	return true, nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// set must be called with the lock held.
+func (m *Memory) set(key string, value []byte, ttl time.Duration) {
+	if m.entries == nil {
+		m.entries = make(map[string]memoryEntry)
+	}
+	now := m.time()
+	maxEntries := m.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	if len(m.entries) >= maxEntries {
+		for k, entry := range m.entries {
+			if entry.expired(now) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	if _, ok := m.entries[key]; !ok && m.Evict && len(m.entries) >= maxEntries {
+		m.evict()
+	}
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	m.entries[key] = entry
+}
+
+// evict removes the entry closest to expiry, entries without ttl last.
+// It must be called with the lock held.
+func (m *Memory) evict() {
+	var (
+		oldest  string
+		expires time.Time
+		found   bool
+	)
+	for k, entry := range m.entries {
+		if !found || (!entry.expires.IsZero() && (expires.IsZero() || entry.expires.Before(expires))) {
+			oldest, expires, found = k, entry.expires, true
+		}
+	}
+	delete(m.entries, oldest)
+}
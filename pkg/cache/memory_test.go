@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := m.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, m.Set(ctx, "key", []byte("value"), time.Minute))
+	require.NoError(t, m.Set(ctx, "forever", []byte("value"), 0))
+	value, err := m.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	now = now.Add(time.Minute)
+	_, err = m.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Get(ctx, "forever")
+	assert.NoError(t, err)
+
+	require.NoError(t, m.Delete(ctx, "forever"))
+	_, err = m.Get(ctx, "forever")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemory_MaxEntries(t *testing.T) {
+	now := time.Now()
+	m := &Memory{MaxEntries: 2, now: func() time.Time { return now }}
+	ctx := context.Background()
+	require.NoError(t, m.Set(ctx, "a", nil, time.Second))
+	require.NoError(t, m.Set(ctx, "b", nil, time.Minute))
+	now = now.Add(time.Second)
+	require.NoError(t, m.Set(ctx, "c", nil, time.Minute))
+	assert.Len(t, m.entries, 2)
+	assert.NotContains(t, m.entries, "a")
+}
+
+func TestMemory_Evict(t *testing.T) {
+	m := &Memory{MaxEntries: 2, Evict: true}
+	ctx := context.Background()
+	require.NoError(t, m.Set(ctx, "forever", nil, 0))
+	require.NoError(t, m.Set(ctx, "a", nil, time.Minute))
+	require.NoError(t, m.Set(ctx, "b", nil, time.Hour))
+	assert.Len(t, m.entries, 2)
+	assert.NotContains(t, m.entries, "a")
+
+	require.NoError(t, m.Set(ctx, "b", nil, time.Hour))
+	assert.Len(t, m.entries, 2)
+	assert.Contains(t, m.entries, "forever")
+}
+
+// getSetCache hides the Adder of Memory.
+type getSetCache struct {
+	Cache
+}
+
+func TestCheckReplay(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, c := range []Cache{m, getSetCache{m}} {
+		id := "jti"
+		if _, ok := c.(Adder); !ok {
+			id = "other"
+		}
+		require.NoError(t, CheckReplay(ctx, c, id, time.Minute))
+		assert.ErrorIs(t, CheckReplay(ctx, c, id, time.Minute), ErrReplay)
+	}
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, CheckReplay(ctx, m, "jti", time.Minute), "expired")
+}
+
+func TestJSON(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	type value struct {
+		Name string `json:"name"`
+	}
+	require.NoError(t, SetJSON(ctx, m, "key", value{Name: "name"}, time.Minute))
+	var got value
+	require.NoError(t, GetJSON(ctx, m, "key", &got))
+	assert.Equal(t, value{Name: "name"}, got)
+	assert.ErrorIs(t, GetJSON(ctx, m, "missing", &got), ErrNotFound)
+}
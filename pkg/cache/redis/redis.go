@@ -0,0 +1,298 @@
+// Package redis implements a [cache.Cache] backed by Redis (or a compatible
+// server like Valkey), to share the caches of the library between the
+// instances of a deployment. It speaks the RESP2 protocol and only needs
+// the GET, SET and DEL commands.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/internal/security"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+)
+
+// Client is a [cache.Cache] and [cache.Adder] using a Redis server.
+// Connections are pooled and safe for concurrent use.
+type Client struct {
+	addr      string
+	username  string
+	password  security.Secret
+	db        int
+	prefix    string
+	tlsConfig *tls.Config
+	dialer    net.Dialer
+	timeout   time.Duration
+	pool      chan *conn
+}
+
+type Option func(*Client)
+
+// WithAuth authenticates the connections with the AUTH command.
+// The username may be empty for the default user.
+func WithAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = security.Secret(password)
+	}
+}
+
+// WithDB selects the database of the connections.
+func WithDB(db int) Option {
+	return func(c *Client) {
+		c.db = db
+	}
+}
+
+// WithPrefix is prepended to all keys,
+// so the server can be shared with other applications.
+func WithPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.prefix = prefix
+	}
+}
+
+// WithTLS connects to the server with TLS. The config is cloned,
+// its ServerName defaults to the host of the address of the server.
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithTimeout limits the duration of a command, if the context has no earlier deadline.
+// Defaults to 5 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithPoolSize sets the number of idle connections kept open. Defaults to 10.
+func WithPoolSize(size int) Option {
+	return func(c *Client) {
+		c.pool = make(chan *conn, size)
+	}
+}
+
+// New returns a Client for the server at addr (host:port).
+// Connections are opened on first use.
+func New(addr string, opts ...Option) *Client {
+	c := &Client{
+		addr:    addr,
+		timeout: 5 * time.Second,
+		pool:    make(chan *conn, 10),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.tlsConfig != nil {
+		c.tlsConfig = c.tlsConfig.Clone()
+		if c.tlsConfig.ServerName == "" {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				c.tlsConfig.ServerName = host
+			}
+		}
+	}
+	return c
+}
+
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.do(ctx, "GET", c.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, cache.ErrNotFound
+	}
+	return value, nil
+}
+
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := c.do(ctx, setArgs(c.prefix+key, value, ttl)...)
+	return err
+}
+
+func (c *Client) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := c.do(ctx, append(setArgs(c.prefix+key, value, ttl), "NX")...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, "DEL", c.prefix+key)
+	return err
+}
+
+// Close closes the idle connections.
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func setArgs(key string, value []byte, ttl time.Duration) []any {
+	args := []any{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	return args
+}
+
+// Error is an error reply of the server.
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do sends the command on a pooled connection. If the connection fails,
+// as the server may have closed it while idle, the command is retried
+// once on a new connection.
+func (c *Client) do(ctx context.Context, args ...any) ([]byte, error) {
+	var cn *conn
+	select {
+	case cn = <-c.pool:
+	default:
+	}
+	if cn != nil {
+		reply, failed, err := c.doConn(ctx, cn, args...)
+		if !failed || ctx.Err() != nil {
+			return reply, err
+		}
+	}
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, _, err := c.doConn(ctx, cn, args...)
+	return reply, err
+}
+
+// doConn sends the command on the connection, which is returned to the pool
+// unless it failed, after which its state is unknown.
+func (c *Client) doConn(ctx context.Context, cn *conn, args ...any) (reply []byte, failed bool, err error) {
+	reply, err = cn.do(ctx, c.timeout, args...)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		cn.Close()
+		return nil, true, err
+	}
+	c.put(cn)
+	return reply, false, err
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	netConn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if c.tlsConfig != nil {
+		netConn = tls.Client(netConn, c.tlsConfig)
+	}
+	cn := &conn{Conn: netConn, r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)}
+	if c.password != "" {
+		args := []any{"AUTH", c.password.Reveal()}
+		if c.username != "" {
+			args = []any{"AUTH", c.username, c.password.Reveal()}
+		}
+		if _, err = cn.do(ctx, c.timeout, args...); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err = cn.do(ctx, c.timeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.pool <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// do sends the command and reads its reply, which is nil for a nil reply.
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args ...any) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch arg := arg.(type) {
+		case string:
+			b = []byte(arg)
+		case []byte:
+			b = arg
+		}
+		fmt.Fprintf(cn.w, "$%d\r\n", len(b))
+		cn.w.Write(b)
+		cn.w.WriteString("\r\n")
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return cn.readReply()
+}
+
+func (cn *conn) readReply() ([]byte, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: invalid reply")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+', ':':
+		return []byte(line), nil
+	case '-':
+		return nil, Error(line)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply: %w", err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(cn.r, b); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return b[:n], nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+}
@@ -0,0 +1,217 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+)
+
+// fakeServer implements the commands used by the Client.
+type fakeServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    []net.Conn
+	values   map[string]string
+	ttls     map[string]string
+	commands []string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeServer{listener: listener, values: map[string]string{}, ttls: map[string]string{}}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// closeConns closes the open connections, like a server closing idle connections.
+func (s *fakeServer) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		fmt.Fprint(conn, s.handle(args))
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		b := make([]byte, size+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func (s *fakeServer) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, strings.Join(args, " "))
+	switch args[0] {
+	case "AUTH":
+		if args[len(args)-1] != "secret" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		_, exists := s.values[args[1]]
+		if args[len(args)-1] == "NX" && exists {
+			return "$-1\r\n"
+		}
+		s.values[args[1]] = args[2]
+		if len(args) > 4 && args[3] == "PX" {
+			s.ttls[args[1]] = args[4]
+		}
+		return "+OK\r\n"
+	case "DEL":
+		delete(s.values, args[1])
+		return ":1\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeServer(t)
+	client := New(server.listener.Addr().String(), WithAuth("", "secret"), WithDB(2), WithPrefix("oidc:"))
+	defer client.Close()
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, client.Set(ctx, "key", []byte("value\r\n"), 1500*time.Millisecond))
+	value, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value\r\n", string(value))
+	assert.Equal(t, "1500", server.ttls["oidc:key"])
+
+	added, err := client.Add(ctx, "key", []byte("other"), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, added)
+	added, err = client.Add(ctx, "new", []byte("other"), 0)
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	require.NoError(t, client.Delete(ctx, "key"))
+	_, err = client.Get(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	// a single connection is set up and reused
+	assert.Equal(t, []string{"AUTH secret", "SELECT 2"}, server.commands[:2])
+	assert.NotContains(t, server.commands[2:], "AUTH secret")
+}
+
+func TestClient_errors(t *testing.T) {
+	server := newFakeServer(t)
+	ctx := context.Background()
+
+	client := New(server.listener.Addr().String(), WithAuth("user", "wrong"))
+	_, err := client.Get(ctx, "key")
+	var replyErr Error
+	require.ErrorAs(t, err, &replyErr)
+	assert.Contains(t, replyErr.Error(), "WRONGPASS")
+
+	client = New(server.listener.Addr().String())
+	_, err = client.do(ctx, "UNKNOWN")
+	assert.ErrorAs(t, err, &replyErr)
+	// the connection is kept after an error reply
+	assert.Len(t, client.pool, 1)
+
+	server.listener.Close()
+	client.Close()
+	_, err = client.Get(ctx, "key")
+	assert.Error(t, err)
+}
+
+func TestClient_staleConn(t *testing.T) {
+	server := newFakeServer(t)
+	client := New(server.listener.Addr().String())
+	defer client.Close()
+	ctx := context.Background()
+
+	require.NoError(t, client.Set(ctx, "key", []byte("value"), 0))
+	require.Len(t, client.pool, 1)
+	server.closeConns()
+
+	// the command is retried on a new connection
+	value, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(value))
+	assert.Len(t, client.pool, 1)
+}
+
+func TestWithTLS(t *testing.T) {
+	config := &tls.Config{MinVersion: tls.VersionTLS13}
+	client := New("redis.example.com:6380", WithTLS(config))
+	assert.Equal(t, "redis.example.com", client.tlsConfig.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS13), client.tlsConfig.MinVersion)
+	assert.Empty(t, config.ServerName, "the config of the caller is not modified")
+
+	client = New("10.0.0.1:6380", WithTLS(&tls.Config{ServerName: "redis.internal"}))
+	assert.Equal(t, "redis.internal", client.tlsConfig.ServerName)
+}
+
+func TestCheckReplay(t *testing.T) {
+	client := New(newFakeServer(t).listener.Addr().String())
+	ctx := context.Background()
+	require.NoError(t, cache.CheckReplay(ctx, client, "jti", time.Minute))
+	assert.ErrorIs(t, cache.CheckReplay(ctx, client, "jti", time.Minute), cache.ErrReplay)
+}
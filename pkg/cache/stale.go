@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/background"
+)
+
+// StalePolicy serves cached values past their ttl, following the
+// stale-while-revalidate and stale-if-error extensions of RFC 5861,
+// so short outages of the origin, like the OP, don't fail every request.
+// The longer the windows, the longer revoked tokens or removed keys
+// may still be accepted: they are a trade-off of availability against risk.
+//
+// Values are stored with their freshness, so all instances sharing a cache
+// must use a StalePolicy, or none.
+type StalePolicy struct {
+	// WhileRevalidate is the time after the ttl, in which the stale value
+	// is returned, while it is revalidated in the background.
+	WhileRevalidate time.Duration
+	// IfError is the time after the ttl, in which the stale value
+	// is returned, if revalidating it fails.
+	IfError time.Duration
+}
+
+// Staleness describes a value served past its ttl, see [RecordStaleness].
+type Staleness struct {
+	// Age is the time the value is served past its ttl.
+	Age time.Duration
+	// Revalidating is true, if the value is revalidated in the background.
+	Revalidating bool
+	// Err is the error of the origin, if the value is served because of it.
+	Err error
+}
+
+// Fetched is a value fetched from the origin by [StalePolicy.Fetch].
+type Fetched struct {
+	Value []byte
+	// TTL is the freshness of the value. It is not stored, if zero or less.
+	TTL time.Duration
+	// Expires limits the time the value is served at all, if set,
+	// like the expiration of a token.
+	Expires time.Time
+}
+
+type staleEntry struct {
+	Value   []byte    `json:"value"`
+	Fresh   time.Time `json:"fresh"`
+	Expires time.Time `json:"expires"`
+}
+
+type stalenessKey struct{}
+
+type stalenessRecorder struct {
+	mu        sync.Mutex
+	staleness *Staleness
+}
+
+// RecordStaleness returns a context, in which values served stale by a
+// [StalePolicy] record their Staleness, which is returned by the func,
+// nil if none was. If multiple values were stale, the oldest is kept.
+func RecordStaleness(ctx context.Context) (context.Context, func() *Staleness) {
+	recorder := new(stalenessRecorder)
+	return context.WithValue(ctx, stalenessKey{}, recorder), func() *Staleness {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return recorder.staleness
+	}
+}
+
+// ReportStaleness records the staleness of a value served stale, see [RecordStaleness].
+func ReportStaleness(ctx context.Context, staleness Staleness) {
+	recorder, ok := ctx.Value(stalenessKey{}).(*stalenessRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.staleness == nil || recorder.staleness.Age < staleness.Age {
+		recorder.staleness = &staleness
+	}
+}
+
+func (p *StalePolicy) window() time.Duration {
+	return max(p.WhileRevalidate, p.IfError)
+}
+
+// Get returns the value of the key and the time it is past its ttl,
+// zero if it is fresh. Values are kept for the longer of the windows after their ttl.
+// A nil policy gets the value like [Cache.Get].
+func (p *StalePolicy) Get(ctx context.Context, c Cache, key string) ([]byte, time.Duration, error) {
+	value, err := c.Get(ctx, key)
+	if err != nil || p == nil {
+		return value, 0, err
+	}
+	var entry staleEntry
+	if err = json.Unmarshal(value, &entry); err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	if !entry.Expires.IsZero() && !now.Before(entry.Expires) {
+		return nil, 0, ErrNotFound
+	}
+	return entry.Value, max(now.Sub(entry.Fresh), 0), nil
+}
+
+// Set stores the fetched value. A nil policy sets the value like [Cache.Set].
+// A value without TTL replaces a stale one by deleting it.
+func (p *StalePolicy) Set(ctx context.Context, c Cache, key string, fetched Fetched) error {
+	if fetched.TTL <= 0 {
+		if p == nil {
+			return nil
+		}
+		return c.Delete(ctx, key)
+	}
+	if p == nil {
+		return c.Set(ctx, key, fetched.Value, fetched.TTL)
+	}
+	now := time.Now()
+	entry, err := json.Marshal(&staleEntry{
+		Value:   fetched.Value,
+		Fresh:   now.Add(fetched.TTL),
+		Expires: fetched.Expires,
+	})
+	if err != nil {
+		return err
+	}
+	ttl := fetched.TTL + p.window()
+	if !fetched.Expires.IsZero() {
+		ttl = min(ttl, fetched.Expires.Sub(now))
+	}
+	if ttl <= 0 {
+		return c.Delete(ctx, key)
+	}
+	return c.Set(ctx, key, entry, ttl)
+}
+
+// ServeWhileRevalidating reports if a value of the age may be served, while it is revalidated.
+func (p *StalePolicy) ServeWhileRevalidating(age time.Duration) bool {
+	return age == 0 || p != nil && age <= p.WhileRevalidate
+}
+
+// ServeIfError reports if a value of the age may be served, when revalidating it failed.
+func (p *StalePolicy) ServeIfError(age time.Duration) bool {
+	return age == 0 || p != nil && age <= p.IfError
+}
+
+// Fetch returns the value of the key from the cache, or fetches and stores it.
+// Stale values are returned within the windows of the policy, which records their
+// [Staleness] in the context, see [RecordStaleness]. A nil policy doesn't serve stale values.
+func (p *StalePolicy) Fetch(ctx context.Context, c Cache, key string, fetch func(context.Context) (Fetched, error)) ([]byte, error) {
+	value, age, err := p.Get(ctx, c, key)
+	found := err == nil
+	switch {
+	case found && age == 0:
+		return value, nil
+	case found && p.ServeWhileRevalidating(age):
+		p.revalidate(ctx, c, key, fetch)
+		ReportStaleness(ctx, Staleness{Age: age, Revalidating: true})
+		return value, nil
+	}
+	fetched, fetchErr := fetch(ctx)
+	if fetchErr == nil {
+		// the value is fetched again, if storing it fails
+		_ = p.Set(ctx, c, key, fetched)
+		return fetched.Value, nil
+	}
+	if found && p.ServeIfError(age) && !errors.Is(fetchErr, context.Canceled) {
+		ReportStaleness(ctx, Staleness{Age: age, Err: fetchErr})
+		return value, nil
+	}
+	return nil, fetchErr
+}
+
+// revalidate fetches and stores the value in the background,
+// once for all instances sharing the cache, see [background.WithGroup].
+func (p *StalePolicy) revalidate(ctx context.Context, c Cache, key string, fetch func(context.Context) (Fetched, error)) {
+	lock := "revalidate:" + key
+	if added, err := Add(ctx, c, lock, []byte{1}, p.WhileRevalidate); err != nil || !added {
+		return
+	}
+	ctx, cancel := background.Detach(ctx)
+	background.Go(ctx, func() error {
+		defer cancel()
+		defer c.Delete(ctx, lock)
+		fetched, err := fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("revalidate %s: %w", key, err)
+		}
+		return p.Set(ctx, c, key, fetched)
+	})
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStalePolicy_Fetch(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	errOrigin := errors.New("origin down")
+	var (
+		calls   atomic.Int32
+		failing atomic.Bool
+	)
+	fetch := func(context.Context) (Fetched, error) {
+		n := calls.Add(1)
+		if failing.Load() {
+			return Fetched{}, errOrigin
+		}
+		return Fetched{Value: []byte{byte(n)}, TTL: ttl}, nil
+	}
+	policy := &StalePolicy{WhileRevalidate: ttl, IfError: time.Minute}
+	c := NewMemory()
+	ctx := context.Background()
+
+	value, err := policy.Fetch(ctx, c, "key", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, value)
+	value, err = policy.Fetch(ctx, c, "key", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, value)
+	assert.EqualValues(t, 1, calls.Load())
+
+	// stale-while-revalidate returns the stale value and fetches in the background
+	time.Sleep(ttl + 5*time.Millisecond)
+	staleCtx, staleness := RecordStaleness(ctx)
+	value, err = policy.Fetch(staleCtx, c, "key", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, value)
+	require.NotNil(t, staleness())
+	assert.True(t, staleness().Revalidating)
+	assert.Eventually(t, func() bool {
+		value, _ := policy.Fetch(ctx, c, "key", fetch)
+		return len(value) == 1 && value[0] == 2
+	}, time.Second, time.Millisecond)
+
+	// stale-if-error returns the stale value, when the origin fails
+	failing.Store(true)
+	time.Sleep(2*ttl + 5*time.Millisecond)
+	staleCtx, staleness = RecordStaleness(ctx)
+	value, err = policy.Fetch(staleCtx, c, "key", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{2}, value)
+	require.NotNil(t, staleness())
+	assert.ErrorIs(t, staleness().Err, errOrigin)
+
+	// without a policy, the error is returned
+	var noPolicy *StalePolicy
+	_, err = noPolicy.Fetch(ctx, NewMemory(), "key", fetch)
+	assert.ErrorIs(t, err, errOrigin)
+}
+
+func TestStalePolicy_Set(t *testing.T) {
+	policy := &StalePolicy{IfError: time.Hour}
+	c := NewMemory()
+	ctx := context.Background()
+
+	require.NoError(t, policy.Set(ctx, c, "key", Fetched{Value: []byte("value"), TTL: time.Minute}))
+	value, age, err := policy.Get(ctx, c, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(value))
+	assert.Zero(t, age)
+
+	// values without TTL replace stale ones
+	require.NoError(t, policy.Set(ctx, c, "key", Fetched{Value: []byte("inactive")}))
+	_, _, err = policy.Get(ctx, c, "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// values are never served past Expires
+	require.NoError(t, policy.Set(ctx, c, "key", Fetched{Value: []byte("value"), TTL: time.Minute, Expires: time.Now().Add(-time.Second)}))
+	_, _, err = policy.Get(ctx, c, "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type BackchannelAuthenticationCaller interface {
+	GetBackchannelAuthenticationEndpoint() string
+	HttpClient() *http.Client
+}
+
+// BackchannelAuthenticationRequest is the Backchannel Authentication Request
+// of the Client-Initiated Backchannel Authentication Flow (CIBA),
+// authenticated by the client credentials.
+// The scope and client_id are taken from the ClientCredentialsRequest.
+type BackchannelAuthenticationRequest struct {
+	*oidc.ClientCredentialsRequest
+	oidc.BackchannelAuthenticationRequest
+}
+
+// CallBackchannelAuthenticationEndpoint starts the authentication of the user
+// identified by the hint of the request and returns the auth_req_id.
+func CallBackchannelAuthenticationEndpoint(ctx context.Context, request *BackchannelAuthenticationRequest, caller BackchannelAuthenticationCaller) (*oidc.BackchannelAuthenticationResponse, error) {
+	ctx, span := Tracer.Start(ctx, "CallBackchannelAuthenticationEndpoint")
+	defer span.End()
+
+	endpoint := caller.GetBackchannelAuthenticationEndpoint()
+	if endpoint == "" {
+		return nil, fmt.Errorf("backchannel authentication %w", ErrEndpointNotSet)
+	}
+
+	req, err := httphelper.FormRequest(ctx, endpoint, request, Encoder, nil)
+	if err != nil {
+		return nil, err
+	}
+	if request.ClientSecret != "" {
+		req.SetBasicAuth(request.ClientCredentialsRequest.ClientID, request.ClientSecret)
+	}
+
+	resp := new(oidc.BackchannelAuthenticationResponse)
+	if err := httphelper.HttpRequest(caller.HttpClient(), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type BackchannelTokenRequest struct {
+	*oidc.ClientCredentialsRequest
+	oidc.BackchannelTokenRequest
+}
+
+// MarshalForm implements [httphelper.FormMarshaler].
+func (r *BackchannelTokenRequest) MarshalForm(form url.Values) error {
+	if err := errors.Join(
+		oidc.FormOneOf("grant_type", r.BackchannelTokenRequest.GrantType, oidc.GrantTypeCIBA),
+		oidc.FormRequired("auth_req_id", r.AuthReqID),
+	); err != nil {
+		return err
+	}
+	if err := marshalClientCredentials(form, r.ClientCredentialsRequest); err != nil {
+		return err
+	}
+	form.Set("grant_type", string(oidc.GrantTypeCIBA))
+	form.Set("auth_req_id", r.AuthReqID)
+	return nil
+}
+
+func CallBackchannelTokenEndpoint(ctx context.Context, request *BackchannelTokenRequest, caller TokenEndpointCaller) (*oidc.AccessTokenResponse, error) {
+	ctx, span := Tracer.Start(ctx, "CallBackchannelTokenEndpoint")
+	defer span.End()
+
+	req, err := httphelper.FormRequest(ctx, caller.TokenEndpoint(), request, Encoder, nil)
+	if err != nil {
+		return nil, err
+	}
+	if request.ClientSecret != "" {
+		req.SetBasicAuth(request.ClientID, request.ClientSecret)
+	}
+
+	resp := new(oidc.AccessTokenResponse)
+	if err := httphelper.HttpRequest(caller.HttpClient(), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// PollBackchannelTokenEndpoint polls the token endpoint at the interval,
+// until the authentication of the user completes or fails,
+// like [PollDeviceAccessTokenEndpoint].
+func PollBackchannelTokenEndpoint(ctx context.Context, interval time.Duration, request *BackchannelTokenRequest, caller TokenEndpointCaller) (*oidc.AccessTokenResponse, error) {
+	ctx, span := Tracer.Start(ctx, "PollBackchannelTokenEndpoint")
+	defer span.End()
+
+	return pollTokenEndpoint(ctx, interval, 0, func(ctx context.Context) (*oidc.AccessTokenResponse, error) {
+		return CallBackchannelTokenEndpoint(ctx, request, caller)
+	})
+}
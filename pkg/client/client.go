@@ -4,32 +4,42 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
-	"github.com/zitadel/logging"
-	"go.opentelemetry.io/otel"
 	"golang.org/x/oauth2"
 
+	"github.com/lmindwarel/oidc/v3/internal/logctx"
+	"github.com/lmindwarel/oidc/v3/internal/security"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
 	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
 
-var (
-	Encoder = httphelper.Encoder(oidc.NewEncoder())
-	Tracer  = otel.Tracer("github.com/lmindwarel/oidc/pkg/client")
-)
+var Encoder = httphelper.Encoder(oidc.NewEncoder())
 
 // Discover calls the discovery endpoint of the provided issuer and returns its configuration
 // It accepts an optional argument "wellknownUrl" which can be used to overide the dicovery endpoint url
 func Discover(ctx context.Context, issuer string, httpClient *http.Client, wellKnownUrl ...string) (*oidc.DiscoveryConfiguration, error) {
+	return discover(ctx, issuer, httpClient, false, wellKnownUrl...)
+}
+
+// DiscoverInsecure is like [Discover], but matches the issuer of the discovery
+// document with [oidc.MatchIssuer] in insecure mode, for development and tests only.
+// If issuer is a template, the well-known URL must be passed.
+func DiscoverInsecure(ctx context.Context, issuer string, httpClient *http.Client, wellKnownUrl ...string) (*oidc.DiscoveryConfiguration, error) {
+	return discover(ctx, issuer, httpClient, true, wellKnownUrl...)
+}
+
+func discover(ctx context.Context, issuer string, httpClient *http.Client, allowInsecure bool, wellKnownUrl ...string) (*oidc.DiscoveryConfiguration, error) {
 	ctx, span := Tracer.Start(ctx, "Discover")
 	defer span.End()
+	ctx = WithOperation(ctx, OperationDiscovery)
 
 	wellKnown := strings.TrimSuffix(issuer, "/") + oidc.DiscoveryEndpoint
 	if len(wellKnownUrl) == 1 && wellKnownUrl[0] != "" {
@@ -44,16 +54,39 @@ func Discover(ctx context.Context, issuer string, httpClient *http.Client, wellK
 	if err != nil {
 		return nil, errors.Join(oidc.ErrDiscoveryFailed, err)
 	}
-	if logger, ok := logging.FromContext(ctx); ok {
+	if logger, ok := logctx.FromContext(ctx); ok {
 		logger.Debug("discover", "config", discoveryConfig)
 	}
 
-	if discoveryConfig.Issuer != issuer {
+	if !oidc.MatchIssuer(issuer, discoveryConfig.Issuer, allowInsecure) {
 		return nil, oidc.ErrIssuerInvalid
 	}
 	return discoveryConfig, nil
 }
 
+// DiscoverFunc is the signature of [Discover] and [DiscoverInsecure].
+type DiscoverFunc func(ctx context.Context, issuer string, httpClient *http.Client, wellKnownUrl ...string) (*oidc.DiscoveryConfiguration, error)
+
+// CachedDiscover wraps the discover function, so the discovery configurations
+// are shared through the cache for the ttl, per issuer and well-known URL.
+// Configurations are only cached after discover matched their issuer.
+func CachedDiscover(discover DiscoverFunc, c cache.Cache, ttl time.Duration) DiscoverFunc {
+	return func(ctx context.Context, issuer string, httpClient *http.Client, wellKnownUrl ...string) (*oidc.DiscoveryConfiguration, error) {
+		key := "discovery:" + issuer + " " + strings.Join(wellKnownUrl, " ")
+		config := new(oidc.DiscoveryConfiguration)
+		if err := cache.GetJSON(ctx, c, key, config); err == nil {
+			return config, nil
+		}
+		config, err := discover(ctx, issuer, httpClient, wellKnownUrl...)
+		if err != nil {
+			return nil, err
+		}
+		// discovery succeeds regardless, the next call retries storing it
+		_ = cache.SetJSON(ctx, c, key, config, ttl)
+		return config, nil
+	}
+}
+
 type TokenEndpointCaller interface {
 	TokenEndpoint() string
 	HttpClient() *http.Client
@@ -66,6 +99,7 @@ func CallTokenEndpoint(ctx context.Context, request any, caller TokenEndpointCal
 func callTokenEndpoint(ctx context.Context, request any, authFn any, caller TokenEndpointCaller) (newToken *oauth2.Token, err error) {
 	ctx, span := Tracer.Start(ctx, "callTokenEndpoint")
 	defer span.End()
+	ctx = WithOperation(ctx, OperationToken)
 
 	req, err := httphelper.FormRequest(ctx, caller.TokenEndpoint(), request, Encoder, authFn)
 	if err != nil {
@@ -97,6 +131,7 @@ type EndSessionCaller interface {
 func CallEndSessionEndpoint(ctx context.Context, request any, authFn any, caller EndSessionCaller) (*url.URL, error) {
 	ctx, span := Tracer.Start(ctx, "CallEndSessionEndpoint")
 	defer span.End()
+	ctx = WithOperation(ctx, OperationEndSession)
 
 	endpoint := caller.GetEndSessionEndpoint()
 	if endpoint == "" {
@@ -117,11 +152,11 @@ func CallEndSessionEndpoint(ctx context.Context, request any, authFn any, caller
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-		body, err := io.ReadAll(resp.Body)
+		respErr, err := httphelper.NewResponseError(resp)
 		if err != nil {
 			return nil, err
 		}
-		return nil, fmt.Errorf("EndSession failure, %d status code: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("EndSession failure: %w", respErr)
 	}
 	location, err := resp.Location()
 	if err != nil {
@@ -145,9 +180,36 @@ type RevokeRequest struct {
 	ClientSecret  string `schema:"client_secret"`
 }
 
+// LogValue implements [slog.LogValuer] with the token and secret redacted.
+func (r RevokeRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("token", r.Token),
+		slog.String("token_type_hint", r.TokenTypeHint),
+		slog.String("client_id", r.ClientID),
+		security.Attr("client_secret", r.ClientSecret),
+	)
+}
+
+func (r RevokeRequest) String() string {
+	return security.String(r)
+}
+
+// MarshalForm implements [httphelper.FormMarshaler].
+func (r RevokeRequest) MarshalForm(form url.Values) error {
+	if err := oidc.FormRequired("token", r.Token); err != nil {
+		return err
+	}
+	form.Set("token", r.Token)
+	oidc.FormSet(form, "token_type_hint", r.TokenTypeHint)
+	oidc.FormSet(form, "client_id", r.ClientID)
+	oidc.FormSet(form, "client_secret", r.ClientSecret)
+	return nil
+}
+
 func CallRevokeEndpoint(ctx context.Context, request any, authFn any, caller RevokeCaller) error {
 	ctx, span := Tracer.Start(ctx, "CallRevokeEndpoint")
 	defer span.End()
+	ctx = WithOperation(ctx, OperationRevocation)
 
 	endpoint := caller.GetRevokeEndpoint()
 	if endpoint == "" {
@@ -171,12 +233,11 @@ func CallRevokeEndpoint(ctx context.Context, request any, authFn any, caller Rev
 	// "The content of the response body is ignored by the client as all
 	// necessary information is conveyed in the response code."
 	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
-		if err == nil {
-			return fmt.Errorf("revoke returned status %d and text: %s", resp.StatusCode, string(body))
-		} else {
+		respErr, err := httphelper.NewResponseError(resp)
+		if err != nil {
 			return fmt.Errorf("revoke returned status %d", resp.StatusCode)
 		}
+		return fmt.Errorf("revoke failure: %w", respErr)
 	}
 	return nil
 }
@@ -184,6 +245,7 @@ func CallRevokeEndpoint(ctx context.Context, request any, authFn any, caller Rev
 func CallTokenExchangeEndpoint(ctx context.Context, request any, authFn any, caller TokenEndpointCaller) (resp *oidc.TokenExchangeResponse, err error) {
 	ctx, span := Tracer.Start(ctx, "CallTokenExchangeEndpoint")
 	defer span.End()
+	ctx = WithOperation(ctx, OperationTokenExchange)
 
 	req, err := httphelper.FormRequest(ctx, caller.TokenEndpoint(), request, Encoder, authFn)
 	if err != nil {
@@ -228,6 +290,7 @@ type DeviceAuthorizationCaller interface {
 func CallDeviceAuthorizationEndpoint(ctx context.Context, request *oidc.ClientCredentialsRequest, caller DeviceAuthorizationCaller, authFn any) (*oidc.DeviceAuthorizationResponse, error) {
 	ctx, span := Tracer.Start(ctx, "CallDeviceAuthorizationEndpoint")
 	defer span.End()
+	ctx = WithOperation(ctx, OperationDeviceAuthorization)
 
 	endpoint := caller.GetDeviceAuthorizationEndpoint()
 	if endpoint == "" {
@@ -254,14 +317,62 @@ type DeviceAccessTokenRequest struct {
 	oidc.DeviceAccessTokenRequest
 }
 
+// MarshalForm implements [httphelper.FormMarshaler].
+func (r *DeviceAccessTokenRequest) MarshalForm(form url.Values) error {
+	if err := errors.Join(
+		oidc.FormOneOf("grant_type", r.DeviceAccessTokenRequest.GrantType, oidc.GrantTypeDeviceCode),
+		oidc.FormRequired("device_code", r.DeviceCode),
+	); err != nil {
+		return err
+	}
+	if err := marshalClientCredentials(form, r.ClientCredentialsRequest); err != nil {
+		return err
+	}
+	form.Set("grant_type", string(oidc.GrantTypeDeviceCode))
+	form.Set("device_code", r.DeviceCode)
+	return nil
+}
+
+// marshalClientCredentials encodes the client authentication and scope
+// of requests embedding a ClientCredentialsRequest.
+func marshalClientCredentials(form url.Values, r *oidc.ClientCredentialsRequest) error {
+	if r == nil {
+		return nil
+	}
+	if err := oidc.FormOneOf("client_assertion_type", r.ClientAssertionType, oidc.ClientAssertionTypeJWTAssertion, oidc.ClientAssertionTypeJWTSPIFFE); err != nil {
+		return err
+	}
+	oidc.FormSet(form, "scope", r.Scope.String())
+	oidc.FormSet(form, "client_id", r.ClientID)
+	oidc.FormSet(form, "client_secret", r.ClientSecret)
+	oidc.FormSet(form, "client_assertion", r.ClientAssertion)
+	oidc.FormSet(form, "client_assertion_type", r.ClientAssertionType)
+	if len(r.AuthorizationDetails) > 0 {
+		text, err := r.AuthorizationDetails.MarshalText()
+		if err != nil {
+			return err
+		}
+		form.Set("authorization_details", string(text))
+	}
+	return nil
+}
+
 func CallDeviceAccessTokenEndpoint(ctx context.Context, request *DeviceAccessTokenRequest, caller TokenEndpointCaller) (*oidc.AccessTokenResponse, error) {
 	ctx, span := Tracer.Start(ctx, "CallDeviceAccessTokenEndpoint")
 	defer span.End()
 
+	return callDeviceAccessTokenEndpoint(ctx, request, caller, 0)
+}
+
+func callDeviceAccessTokenEndpoint(ctx context.Context, request *DeviceAccessTokenRequest, caller TokenEndpointCaller, wait time.Duration) (*oidc.AccessTokenResponse, error) {
+	ctx = WithOperation(ctx, OperationToken)
 	req, err := httphelper.FormRequest(ctx, caller.TokenEndpoint(), request, Encoder, nil)
 	if err != nil {
 		return nil, err
 	}
+	if wait > 0 {
+		req.Header.Set("Prefer", fmt.Sprintf("wait=%d", int(wait/time.Second)))
+	}
 	if request.ClientSecret != "" {
 		req.SetBasicAuth(request.ClientID, request.ClientSecret)
 	}
@@ -273,10 +384,38 @@ func CallDeviceAccessTokenEndpoint(ctx context.Context, request *DeviceAccessTok
 	return resp, nil
 }
 
+// PollDeviceAccessTokenEndpoint polls the token endpoint at the interval,
+// until the authorization of the device completes or fails.
+// Throttled requests, see [httphelper.ErrThrottled], are handled like slow_down,
+// but wait for the Retry-After of the response, if longer.
 func PollDeviceAccessTokenEndpoint(ctx context.Context, interval time.Duration, request *DeviceAccessTokenRequest, caller TokenEndpointCaller) (*oidc.AccessTokenResponse, error) {
 	ctx, span := Tracer.Start(ctx, "PollDeviceAccessTokenEndpoint")
 	defer span.End()
 
+	return pollDeviceAccessTokenEndpoint(ctx, interval, 0, request, caller)
+}
+
+// LongPollDeviceAccessTokenEndpoint is like PollDeviceAccessTokenEndpoint,
+// but asks the server to hold each request up to wait for the completion
+// of the authorization, by the wait preference of RFC 7240.
+// Servers not supporting it respond immediately, so it falls back to
+// polling at the interval.
+func LongPollDeviceAccessTokenEndpoint(ctx context.Context, interval, wait time.Duration, request *DeviceAccessTokenRequest, caller TokenEndpointCaller) (*oidc.AccessTokenResponse, error) {
+	ctx, span := Tracer.Start(ctx, "LongPollDeviceAccessTokenEndpoint")
+	defer span.End()
+
+	return pollDeviceAccessTokenEndpoint(ctx, interval, wait, request, caller)
+}
+
+func pollDeviceAccessTokenEndpoint(ctx context.Context, interval, wait time.Duration, request *DeviceAccessTokenRequest, caller TokenEndpointCaller) (*oidc.AccessTokenResponse, error) {
+	return pollTokenEndpoint(ctx, interval, wait, func(ctx context.Context) (*oidc.AccessTokenResponse, error) {
+		return callDeviceAccessTokenEndpoint(ctx, request, caller, wait)
+	})
+}
+
+// pollTokenEndpoint calls the token endpoint at the interval, until the
+// authorization completes or fails, handling authorization_pending and slow_down.
+func pollTokenEndpoint(ctx context.Context, interval, wait time.Duration, call func(context.Context) (*oidc.AccessTokenResponse, error)) (*oidc.AccessTokenResponse, error) {
 	for {
 		timer := time.After(interval)
 		select {
@@ -285,16 +424,20 @@ func PollDeviceAccessTokenEndpoint(ctx context.Context, interval time.Duration,
 		case <-timer:
 		}
 
-		ctx, cancel := context.WithTimeout(ctx, interval)
-		defer cancel()
-
-		resp, err := CallDeviceAccessTokenEndpoint(ctx, request, caller)
+		callCtx, cancel := context.WithTimeout(ctx, interval+wait)
+		resp, err := call(callCtx)
+		cancel()
 		if err == nil {
 			return resp, nil
 		}
 		if errors.Is(err, context.DeadlineExceeded) {
 			interval += 5 * time.Second
 		}
+		if retryAfter, throttled := httphelper.RetryAfter(err); throttled {
+			// like slow_down, but waiting for the Retry-After if longer
+			interval = max(interval+5*time.Second, retryAfter)
+			continue
+		}
 		var target *oidc.Error
 		if !errors.As(err, &target) {
 			return nil, err
@@ -3,8 +3,14 @@ package client
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -57,3 +63,112 @@ func TestDiscover(t *testing.T) {
 		})
 	}
 }
+
+func TestCachedDiscover(t *testing.T) {
+	var calls atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		httphelper.MarshalJSON(w, &oidc.DiscoveryConfiguration{Issuer: server.URL, TokenEndpoint: server.URL + "/token"})
+	}))
+	defer server.Close()
+	discover := CachedDiscover(Discover, cache.NewMemory(), time.Hour)
+
+	for i := 0; i < 2; i++ {
+		config, err := discover(context.Background(), server.URL, server.Client())
+		require.NoError(t, err)
+		assert.Equal(t, server.URL+"/token", config.TokenEndpoint)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	// configurations failing the issuer check are not cached
+	for i := 0; i < 2; i++ {
+		_, err := discover(context.Background(), "https://other.example.com", server.Client(), server.URL+oidc.DiscoveryEndpoint)
+		assert.ErrorIs(t, err, oidc.ErrIssuerInvalid)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+type testTokenEndpointCaller struct {
+	tokenEndpoint string
+}
+
+func (c testTokenEndpointCaller) TokenEndpoint() string {
+	return c.tokenEndpoint
+}
+
+func (testTokenEndpointCaller) HttpClient() *http.Client {
+	return http.DefaultClient
+}
+
+func TestLongPollDeviceAccessTokenEndpoint(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "wait=30", r.Header.Get("Prefer"))
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"authorization_pending"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"foo","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	req := &DeviceAccessTokenRequest{
+		DeviceAccessTokenRequest: oidc.DeviceAccessTokenRequest{
+			GrantType:  oidc.GrantTypeDeviceCode,
+			DeviceCode: "device",
+		},
+		ClientCredentialsRequest: &oidc.ClientCredentialsRequest{ClientID: "native"},
+	}
+	resp, err := LongPollDeviceAccessTokenEndpoint(context.Background(), 10*time.Millisecond, 30*time.Second, req, testTokenEndpointCaller{server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, "foo", resp.AccessToken)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+type testRevokeCaller struct {
+	endpoint string
+}
+
+func (c testRevokeCaller) GetRevokeEndpoint() string {
+	return c.endpoint
+}
+
+func (testRevokeCaller) HttpClient() *http.Client {
+	return http.DefaultClient
+}
+
+func TestCallRevokeEndpoint_ResponseError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("<html>maintenance</html>"))
+	}))
+	defer server.Close()
+
+	err := CallRevokeEndpoint(context.Background(), &RevokeRequest{Token: "token"}, nil, testRevokeCaller{server.URL})
+	var respErr *httphelper.ResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, http.StatusServiceUnavailable, respErr.StatusCode)
+	assert.Equal(t, "text/html", respErr.Header.Get("Content-Type"))
+	assert.Equal(t, "<html>maintenance</html>", string(respErr.Body))
+	assert.ErrorIs(t, err, oidc.ErrServerError())
+}
+
+func TestMarshalClientCredentials(t *testing.T) {
+	form := make(url.Values)
+	err := marshalClientCredentials(form, &oidc.ClientCredentialsRequest{
+		ClientAssertion:     "svid",
+		ClientAssertionType: oidc.ClientAssertionTypeJWTSPIFFE,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, oidc.ClientAssertionTypeJWTSPIFFE, form.Get("client_assertion_type"))
+	assert.Equal(t, "svid", form.Get("client_assertion"))
+
+	err = marshalClientCredentials(make(url.Values), &oidc.ClientCredentialsRequest{ClientAssertionType: "urn:unknown"})
+	var formErr *oidc.FormError
+	require.ErrorAs(t, err, &formErr)
+	assert.Equal(t, "client_assertion_type", formErr.Parameter)
+}
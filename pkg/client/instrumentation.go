@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/lmindwarel/oidc/pkg/client"
+
+// Operations of the outbound requests, recorded by the [Instrumentation]
+// as the oidc.operation attribute.
+const (
+	OperationDiscovery           = "discovery"
+	OperationJWKS                = "jwks"
+	OperationToken               = "token"
+	OperationTokenExchange       = "token_exchange"
+	OperationIntrospection       = "introspection"
+	OperationUserinfo            = "userinfo"
+	OperationRevocation          = "revocation"
+	OperationEndSession          = "end_session"
+	OperationDeviceAuthorization = "device_authorization"
+	operationOther               = "other"
+)
+
+type operationKey struct{}
+
+// WithOperation names the operation of the outbound requests made with ctx,
+// see [Instrumentation]. The functions of the client packages set it for the
+// calls they make, like [OperationDiscovery] in [Discover].
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// OperationFromContext returns the operation set by [WithOperation].
+func OperationFromContext(ctx context.Context) string {
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		return operation
+	}
+	return operationOther
+}
+
+// Instrumentation records a span and the request count and duration metrics
+// of every outbound request, by operation (see [WithOperation]), status code
+// and error class. Nil providers default to the global providers of OpenTelemetry.
+// It is used by the WithTracerProvider and WithMeterProvider options of
+// packages rp and rs, and can wrap the http clients of the other packages.
+type Instrumentation struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+}
+
+// Client returns a copy of the http client, instrumenting all requests.
+// A nil httpClient is replaced by [http.DefaultClient].
+func (i Instrumentation) Client(httpClient *http.Client) (*http.Client, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	transport, err := NewInstrumentedTransport(i, httpClient.Transport)
+	if err != nil {
+		return nil, err
+	}
+	wrapped := *httpClient
+	wrapped.Transport = transport
+	return &wrapped, nil
+}
+
+// InstrumentedTransport is a http.RoundTripper recording the requests
+// as configured by the [Instrumentation].
+// It must be created by [NewInstrumentedTransport].
+type InstrumentedTransport struct {
+	base     http.RoundTripper
+	tracer   trace.Tracer
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// NewInstrumentedTransport returns an InstrumentedTransport.
+// A nil base defaults to http.DefaultTransport.
+func NewInstrumentedTransport(i Instrumentation, base http.RoundTripper) (*InstrumentedTransport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tp, mp := i.TracerProvider, i.MeterProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	requests, err := meter.Int64Counter("oidc.client.requests",
+		metric.WithDescription("Number of outbound requests to the provider."),
+	)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("oidc.client.request.duration",
+		metric.WithDescription("Duration of outbound requests to the provider."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &InstrumentedTransport{
+		base:     base,
+		tracer:   tp.Tracer(instrumentationName),
+		requests: requests,
+		duration: duration,
+	}, nil
+}
+
+func (t *InstrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := OperationFromContext(req.Context())
+	ctx, span := t.tracer.Start(req.Context(), "oidc.client."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("oidc.operation", operation),
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	attrs := []attribute.KeyValue{attribute.String("oidc.operation", operation)}
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, attribute.String("error.type", "transport"))
+	case resp.StatusCode >= http.StatusBadRequest:
+		span.SetStatus(codes.Error, resp.Status)
+		attrs = append(attrs, attribute.String("error.type", strconv.Itoa(resp.StatusCode)))
+	}
+	if resp != nil {
+		attrs = append(attrs, attribute.Int("http.response.status_code", resp.StatusCode))
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	set := metric.WithAttributes(attrs...)
+	t.requests.Add(ctx, 1, set)
+	t.duration.Record(ctx, time.Since(start).Seconds(), set)
+	return resp, err
+}
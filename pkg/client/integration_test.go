@@ -14,6 +14,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/lmindwarel/oidc/v3/example/server/exampleop"
 	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/client"
 	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
 	"github.com/lmindwarel/oidc/v3/pkg/client/rs"
 	"github.com/lmindwarel/oidc/v3/pkg/client/tokenexchange"
@@ -197,6 +199,90 @@ func TestRelyingPartyWithSigningAlgsFromDiscovery(t *testing.T) {
 	require.NoError(t, err, "verify id token")
 }
 
+func TestRelyingPartyWithPAR(t *testing.T) {
+	for _, wrapServer := range []bool{false, true} {
+		t.Run(fmt.Sprint("wrapServer ", wrapServer), func(t *testing.T) {
+			testRelyingPartyWithPAR(t, wrapServer)
+		})
+	}
+}
+
+func testRelyingPartyWithPAR(t *testing.T, wrapServer bool) {
+	targetURL := "http://local-site"
+	localURL, err := url.Parse(targetURL + "/login?requestID=1234")
+	require.NoError(t, err, "local url")
+
+	t.Log("------- start example OP ------")
+	seed := rand.New(rand.NewSource(int64(os.Getpid()) + time.Now().UnixNano()))
+	clientID := t.Name() + "-" + strconv.FormatInt(seed.Int63(), 25)
+	clientSecret := "secret"
+	client := storage.WebClient(clientID, clientSecret, targetURL)
+	storage.RegisterClients(client)
+	exampleStorage := storage.NewStorage(storage.NewUserStore(targetURL))
+	var dh deferredHandler
+	opServer := httptest.NewServer(&dh)
+	defer opServer.Close()
+	dh.Handler = exampleop.SetupServer(opServer.URL, exampleStorage, Logger, wrapServer)
+
+	t.Log("------- create RP ------")
+	provider, err := rp.NewRelyingPartyOIDC(
+		CTX,
+		opServer.URL,
+		clientID,
+		clientSecret,
+		targetURL,
+		[]string{"openid"},
+		rp.WithPAR(""),
+	)
+	require.NoError(t, err, "new rp")
+
+	t.Log("------- push auth request ------")
+	state := "state-" + strconv.FormatInt(seed.Int63(), 25)
+	capturedW := httptest.NewRecorder()
+	get := httptest.NewRequest("GET", localURL.String(), nil)
+	rp.AuthURLHandler(func() string { return state }, provider, rp.WithURLParam("custom", "param"))(capturedW, get)
+	defer func() {
+		if t.Failed() {
+			t.Log("response body (redirect from RP to OP)", capturedW.Body.String())
+		}
+	}()
+	resp := capturedW.Result()
+	startAuthURL, err := resp.Location()
+	require.NoError(t, err, "get redirect")
+	assert.Len(t, startAuthURL.Query(), 2, "only client_id and request_uri")
+	assert.Equal(t, clientID, startAuthURL.Query().Get("client_id"))
+	assert.True(t, strings.HasPrefix(startAuthURL.Query().Get("request_uri"), oidc.RequestURIPrefixPushed))
+
+	t.Log("------- run authorization code flow ------")
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err, "create cookie jar")
+	httpClient := &http.Client{
+		Timeout: time.Second * 5,
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Jar: jar,
+	}
+	loginPageURL := getRedirect(t, "get redirect to login page", httpClient, startAuthURL)
+	form := getForm(t, "get login form", httpClient, loginPageURL)
+	postLoginRedirectURL := fillForm(t, "fill login form", httpClient, form, loginPageURL,
+		gosubmit.Set("username", "test-user@local-site"),
+		gosubmit.Set("password", "verysecure"),
+	)
+	codeBearingURL := getRedirect(t, "get redirect with code", httpClient, postLoginRedirectURL)
+	assert.Equal(t, state, codeBearingURL.Query().Get("state"), "state of the pushed request")
+
+	tokens, err := rp.CodeExchange[*oidc.IDTokenClaims](CTX, codeBearingURL.Query().Get("code"), provider)
+	require.NoError(t, err, "code exchange")
+	assert.NotEmpty(t, tokens.IDToken, "id token")
+
+	t.Log("------- reuse request_uri ------")
+	reuse, err := httpClient.Get(startAuthURL.String())
+	require.NoError(t, err, "reuse request_uri")
+	defer reuse.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, reuse.StatusCode, "reused request_uri")
+}
+
 func TestResourceServerTokenExchange(t *testing.T) {
 	for _, wrapServer := range []bool{false, true} {
 		t.Run(fmt.Sprint("wrapServer ", wrapServer), func(t *testing.T) {
@@ -436,6 +522,168 @@ func TestClientCredentials(t *testing.T) {
 	assert.NotEmpty(t, token.AccessToken)
 }
 
+func TestBackchannelAuthentication(t *testing.T) {
+	for _, wrapServer := range []bool{false, true} {
+		t.Run(fmt.Sprint("wrapServer ", wrapServer), func(t *testing.T) {
+			testBackchannelAuthentication(t, wrapServer)
+		})
+	}
+}
+
+func testBackchannelAuthentication(t *testing.T, wrapServer bool) {
+	targetURL := "http://local-site"
+	seed := rand.New(rand.NewSource(int64(os.Getpid()) + time.Now().UnixNano()))
+	clientID := t.Name() + "-" + strconv.FormatInt(seed.Int63(), 25)
+	clientSecret := "secret"
+	storage.RegisterClients(storage.BackchannelClient(clientID, clientSecret))
+	exampleStorage := storage.NewStorage(storage.NewUserStore(targetURL))
+	var dh deferredHandler
+	opServer := httptest.NewServer(&dh)
+	defer opServer.Close()
+	dh.Handler = exampleop.SetupServer(opServer.URL, exampleStorage, Logger, wrapServer)
+
+	provider, err := rp.NewRelyingPartyOIDC(CTX, opServer.URL, clientID, clientSecret, "", []string{oidc.ScopeOpenID})
+	require.NoError(t, err, "new rp")
+
+	_, err = rp.BackchannelAuthentication(CTX, &oidc.BackchannelAuthenticationRequest{LoginHint: "unknown@local-site"}, provider)
+	var oidcErr *oidc.Error
+	require.ErrorAs(t, err, &oidcErr, "unknown user")
+	assert.Equal(t, oidc.UnknownUserID, oidcErr.ErrorType)
+
+	resp, err := rp.BackchannelAuthentication(CTX, &oidc.BackchannelAuthenticationRequest{
+		LoginHint:      "test-user@local-site",
+		BindingMessage: "W4SCT",
+	}, provider)
+	require.NoError(t, err, "backchannel authentication")
+	require.NotEmpty(t, resp.AuthReqID)
+	assert.Equal(t, 5, resp.Interval)
+
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		exampleStorage.CompleteBackchannelAuthentication(CTX, resp.AuthReqID)
+	}()
+	tokens, err := rp.BackchannelAccessToken(CTX, resp.AuthReqID, time.Second, provider)
+	require.NoError(t, err, "backchannel access token")
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.IDToken)
+
+	_, err = rp.BackchannelAccessToken(CTX, resp.AuthReqID, time.Second, provider)
+	require.ErrorAs(t, err, &oidcErr, "used auth_req_id")
+	assert.Equal(t, oidc.InvalidGrant, oidcErr.ErrorType)
+}
+
+func TestSessionTransfer(t *testing.T) {
+	for _, wrapServer := range []bool{false, true} {
+		t.Run(fmt.Sprint("wrapServer ", wrapServer), func(t *testing.T) {
+			testSessionTransfer(t, wrapServer)
+		})
+	}
+}
+
+func testSessionTransfer(t *testing.T, wrapServer bool) {
+	exampleStorage := storage.NewStorage(storage.NewUserStore("http://local-site"))
+	var dh deferredHandler
+	opServer := httptest.NewServer(&dh)
+	defer opServer.Close()
+	dh.Handler = exampleop.SetupServer(opServer.URL, exampleStorage, Logger, wrapServer)
+
+	// the example OP allows the web client to transfer sessions to the native app
+	web, tokens := RunAuthorizationCodeFlow(t, opServer, "web", "secret")
+	storage.RegisterClients(storage.FirstPartyNativeClient("native"))
+	native, err := rp.NewRelyingPartyOIDC(CTX, opServer.URL, "native", "", "", []string{oidc.ScopeOpenID})
+	require.NoError(t, err, "new native rp")
+
+	codeVerifier, codeChallenge, err := rp.NewSessionTransferVerifier(native)
+	require.NoError(t, err)
+	transfer, err := rp.TransferSession(CTX, web, tokens.IDToken, "native", codeChallenge, oidc.ScopeOpenID, oidc.ScopeProfile)
+	require.NoError(t, err, "transfer session")
+	require.NotEmpty(t, transfer.TransferToken)
+
+	var oidcErr *oidc.Error
+	_, err = rp.RedeemSessionTransfer[*oidc.IDTokenClaims](CTX, native, transfer.TransferToken, "wrong-verifier")
+	require.ErrorAs(t, err, &oidcErr, "wrong code_verifier")
+	assert.Equal(t, oidc.InvalidGrant, oidcErr.ErrorType)
+
+	appTokens, err := rp.RedeemSessionTransfer[*oidc.IDTokenClaims](CTX, native, transfer.TransferToken, codeVerifier)
+	require.NoError(t, err, "redeem session transfer")
+	assert.NotEmpty(t, appTokens.AccessToken)
+	assert.Equal(t, tokens.IDTokenClaims.Subject, appTokens.IDTokenClaims.Subject)
+	assert.Equal(t, oidc.Audience{"native"}, appTokens.IDTokenClaims.Audience)
+
+	_, err = rp.RedeemSessionTransfer[*oidc.IDTokenClaims](CTX, native, transfer.TransferToken, codeVerifier)
+	require.ErrorAs(t, err, &oidcErr, "redeemed transfer_token")
+	assert.Equal(t, oidc.InvalidGrant, oidcErr.ErrorType)
+}
+
+func TestClientRegistration(t *testing.T) {
+	for _, wrapServer := range []bool{false, true} {
+		t.Run(fmt.Sprint("wrapServer ", wrapServer), func(t *testing.T) {
+			testClientRegistration(t, wrapServer)
+		})
+	}
+}
+
+func testClientRegistration(t *testing.T, wrapServer bool) {
+	exampleStorage := storage.NewStorage(storage.NewUserStore("http://local-site"))
+	var dh deferredHandler
+	opServer := httptest.NewServer(&dh)
+	defer opServer.Close()
+	dh.Handler = exampleop.SetupServer(opServer.URL, exampleStorage, Logger, wrapServer)
+
+	discovery, err := client.Discover(CTX, opServer.URL, http.DefaultClient)
+	require.NoError(t, err, "discover")
+	require.Equal(t, opServer.URL+"/register", discovery.RegistrationEndpoint)
+
+	var oidcErr *oidc.Error
+	_, err = client.RegisterClient(CTX, discovery.RegistrationEndpoint, "", &oidc.ClientMetadata{}, http.DefaultClient)
+	require.ErrorAs(t, err, &oidcErr, "missing redirect_uris")
+	assert.Equal(t, oidc.InvalidRedirectURI, oidcErr.ErrorType)
+
+	registration, err := client.RegisterClient(CTX, discovery.RegistrationEndpoint, "", &oidc.ClientMetadata{
+		RedirectURIs: []string{"https://registered.example.com/callback"},
+		ClientName:   "registered",
+	}, http.DefaultClient)
+	require.NoError(t, err, "register client")
+	require.NotEmpty(t, registration.ClientID)
+	require.NotEmpty(t, registration.ClientSecret)
+	require.NotEmpty(t, registration.RegistrationAccessToken)
+	assert.Equal(t, oidc.AuthMethodBasic, registration.TokenEndpointAuthMethod)
+	assert.Equal(t, []oidc.GrantType{oidc.GrantTypeCode}, registration.GrantTypes)
+
+	provider, err := rp.NewRelyingPartyOIDC(CTX, opServer.URL, registration.ClientID, registration.ClientSecret, "https://registered.example.com/callback", []string{oidc.ScopeOpenID})
+	require.NoError(t, err, "new rp")
+	_, err = rp.PushedAuthURL(CTX, "state", provider)
+	require.NoError(t, err, "registered client authenticates")
+
+	read, err := client.ReadClientRegistration(CTX, registration.RegistrationClientURI, registration.RegistrationAccessToken, http.DefaultClient)
+	require.NoError(t, err, "read client registration")
+	assert.Equal(t, registration.ClientID, read.ClientID)
+	assert.Equal(t, "registered", read.ClientName)
+
+	_, err = client.ReadClientRegistration(CTX, registration.RegistrationClientURI, "invalid", http.DefaultClient)
+	require.ErrorAs(t, err, &oidcErr, "invalid registration access token")
+	assert.Equal(t, oidc.InvalidToken, oidcErr.ErrorType)
+
+	updated, err := client.UpdateClientRegistration(CTX, registration.RegistrationClientURI, registration.RegistrationAccessToken, &oidc.ClientRegistrationRequest{
+		ClientID: registration.ClientID,
+		ClientMetadata: oidc.ClientMetadata{
+			RedirectURIs: []string{"https://registered.example.com/updated"},
+			ClientName:   "updated",
+		},
+	}, http.DefaultClient)
+	require.NoError(t, err, "update client registration")
+	assert.Equal(t, []string{"https://registered.example.com/updated"}, updated.RedirectURIs)
+
+	_, err = rp.PushedAuthURL(CTX, "state", provider)
+	require.ErrorAs(t, err, &oidcErr, "redirect_uri removed by the update")
+
+	err = client.DeleteClientRegistration(CTX, registration.RegistrationClientURI, registration.RegistrationAccessToken, http.DefaultClient)
+	require.NoError(t, err, "delete client registration")
+	_, err = client.ReadClientRegistration(CTX, registration.RegistrationClientURI, registration.RegistrationAccessToken, http.DefaultClient)
+	require.ErrorAs(t, err, &oidcErr, "deleted client")
+	assert.Equal(t, oidc.InvalidToken, oidcErr.ErrorType)
+}
+
 func TestErrorFromPromptNone(t *testing.T) {
 	jar, err := cookiejar.New(nil)
 	require.NoError(t, err, "create cookie jar")
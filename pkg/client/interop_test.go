@@ -0,0 +1,307 @@
+//go:build interop
+
+package client_test
+
+// The interop tests run the flows of the rp, which need no user interaction,
+// against real providers and report a compatibility matrix. They are excluded
+// from the default test run and need network access:
+//
+//	go test -tags interop -run TestInterop -v ./pkg/client/
+//
+// The providers are configured by environment variables,
+// with NAME the upper case name of the provider:
+//
+//	OIDC_INTEROP_PROVIDERS      comma separated names, defaults to keycloak,auth0,azure,google
+//	OIDC_INTEROP_NAME_ISSUER    issuer URL, required except for google
+//	OIDC_INTEROP_NAME_CLIENT_ID
+//	OIDC_INTEROP_NAME_CLIENT_SECRET  enables the client_credentials and introspection checks
+//	OIDC_INTEROP_NAME_SCOPES    space delimited scopes of the client_credentials grant
+//	OIDC_INTEROP_NAME_AUDIENCE  audience parameter of the client_credentials grant (Auth0)
+//	OIDC_INTEROP_REPORT         file the matrix is written to as markdown table
+//
+// Providers without issuer are skipped.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+	"github.com/lmindwarel/oidc/v3/pkg/client/rs"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+var interopDefaultIssuers = map[string]string{
+	"google": "https://accounts.google.com",
+}
+
+type interopProvider struct {
+	name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	audience     string
+}
+
+func interopProviders() []interopProvider {
+	names := "keycloak,auth0,azure,google"
+	if env := os.Getenv("OIDC_INTEROP_PROVIDERS"); env != "" {
+		names = env
+	}
+	var providers []interopProvider
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		env := func(key string) string {
+			return os.Getenv("OIDC_INTEROP_" + strings.ToUpper(name) + "_" + key)
+		}
+		p := interopProvider{
+			name:         name,
+			issuer:       env("ISSUER"),
+			clientID:     env("CLIENT_ID"),
+			clientSecret: env("CLIENT_SECRET"),
+			scopes:       strings.Fields(env("SCOPES")),
+			audience:     env("AUDIENCE"),
+		}
+		if p.issuer == "" {
+			p.issuer = interopDefaultIssuers[name]
+		}
+		if p.clientID == "" {
+			p.clientID = "interop"
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// interopStatus is the outcome of a check in the matrix.
+type interopStatus string
+
+const (
+	interopPass interopStatus = "pass"
+	interopFail interopStatus = "FAIL"
+	interopSkip interopStatus = "skip"
+)
+
+// errInteropSkip skips a check, which is not applicable to the provider or configuration.
+var errInteropSkip = errors.New("skipped")
+
+// interopEnv is shared by the checks of a provider.
+type interopEnv struct {
+	provider  interopProvider
+	discovery *oidc.DiscoveryConfiguration
+	rp        rp.RelyingParty
+	token     string
+}
+
+var interopChecks = []struct {
+	name  string
+	check func(ctx context.Context, env *interopEnv) error
+}{
+	{"discovery", func(ctx context.Context, env *interopEnv) (err error) {
+		env.discovery, err = client.Discover(ctx, env.provider.issuer, httphelper.DefaultHTTPClient)
+		return err
+	}},
+	{"issuer", func(ctx context.Context, env *interopEnv) error {
+		if env.discovery == nil {
+			return errInteropSkip
+		}
+		if env.discovery.Issuer != env.provider.issuer {
+			return fmt.Errorf("discovered issuer %q", env.discovery.Issuer)
+		}
+		return nil
+	}},
+	{"jwks", func(ctx context.Context, env *interopEnv) error {
+		if env.discovery == nil {
+			return errInteropSkip
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.discovery.JwksURI, nil)
+		if err != nil {
+			return err
+		}
+		keySet := new(jose.JSONWebKeySet)
+		if err := httphelper.HttpRequest(httphelper.DefaultHTTPClient, req, keySet); err != nil {
+			return err
+		}
+		if len(keySet.Keys) == 0 {
+			return errors.New("no keys")
+		}
+		for _, key := range keySet.Keys {
+			if key.Use != "" && key.Use != oidc.KeyUseSignature {
+				continue
+			}
+			if !key.Valid() {
+				return fmt.Errorf("invalid key %q", key.KeyID)
+			}
+		}
+		return nil
+	}},
+	{"relying_party", func(ctx context.Context, env *interopEnv) (err error) {
+		env.rp, err = rp.NewRelyingPartyOIDC(ctx, env.provider.issuer, env.provider.clientID, env.provider.clientSecret,
+			"http://localhost/callback", append([]string{oidc.ScopeOpenID}, env.provider.scopes...))
+		return err
+	}},
+	{"id_token_signing_alg", func(ctx context.Context, env *interopEnv) error {
+		if env.rp == nil {
+			return errInteropSkip
+		}
+		// the defaults of the verifier
+		return rp.Capabilities(env.rp).Require(rp.RequireIDTokenSigningAlg("RS256", "ES256", "PS256"))
+	}},
+	{"code_flow", func(ctx context.Context, env *interopEnv) error {
+		if env.rp == nil {
+			return errInteropSkip
+		}
+		return rp.Capabilities(env.rp).Require(
+			rp.RequireResponseType(oidc.ResponseTypeCode),
+			rp.RequireGrantType(oidc.GrantTypeCode),
+		)
+	}},
+	{"pkce_s256", func(ctx context.Context, env *interopEnv) error {
+		if env.rp == nil {
+			return errInteropSkip
+		}
+		return rp.Capabilities(env.rp).Require(rp.RequirePKCE(oidc.CodeChallengeMethodS256))
+	}},
+	{"client_credentials", func(ctx context.Context, env *interopEnv) error {
+		if env.rp == nil || env.provider.clientSecret == "" {
+			return errInteropSkip
+		}
+		params := url.Values{}
+		if env.provider.audience != "" {
+			params.Set("audience", env.provider.audience)
+		}
+		token, err := rp.ClientCredentials(ctx, env.rp, params)
+		if err != nil {
+			return err
+		}
+		env.token = token.AccessToken
+		return nil
+	}},
+	{"access_token_jwt", func(ctx context.Context, env *interopEnv) error {
+		if env.token == "" || strings.Count(env.token, ".") != 2 {
+			return errInteropSkip
+		}
+		claims := new(oidc.AccessTokenClaims)
+		payload, err := oidc.ParseToken(env.token, claims)
+		if err != nil {
+			return err
+		}
+		if err := oidc.CheckIssuer(claims, env.discovery.Issuer); err != nil {
+			return err
+		}
+		keySet := rp.NewRemoteKeySet(httphelper.DefaultHTTPClient, env.discovery.JwksURI)
+		return oidc.CheckSignature(ctx, env.token, payload, claims, nil, keySet)
+	}},
+	{"introspection", func(ctx context.Context, env *interopEnv) error {
+		if env.token == "" || env.discovery.IntrospectionEndpoint == "" {
+			return errInteropSkip
+		}
+		resourceServer, err := rs.NewResourceServerClientCredentials(ctx, env.provider.issuer, env.provider.clientID, env.provider.clientSecret)
+		if err != nil {
+			return err
+		}
+		resp, err := rs.Introspect[*oidc.IntrospectionResponse](ctx, resourceServer, env.token)
+		if err != nil {
+			return err
+		}
+		if !resp.Active {
+			return errors.New("token of the client_credentials grant is inactive")
+		}
+		return nil
+	}},
+}
+
+type interopResult struct {
+	status interopStatus
+	detail string
+}
+
+func TestInterop(t *testing.T) {
+	providers := interopProviders()
+	matrix := make(map[string]map[string]interopResult)
+	for _, provider := range providers {
+		t.Run(provider.name, func(t *testing.T) {
+			if provider.issuer == "" {
+				t.Skipf("OIDC_INTEROP_%s_ISSUER is not set", strings.ToUpper(provider.name))
+			}
+			results := make(map[string]interopResult)
+			matrix[provider.name] = results
+			env := &interopEnv{provider: provider}
+			for _, c := range interopChecks {
+				err := c.check(CTX, env)
+				switch {
+				case errors.Is(err, errInteropSkip):
+					results[c.name] = interopResult{status: interopSkip}
+				case err != nil:
+					results[c.name] = interopResult{status: interopFail, detail: err.Error()}
+					t.Errorf("%s: %v", c.name, err)
+				default:
+					results[c.name] = interopResult{status: interopPass}
+				}
+			}
+		})
+	}
+	if len(matrix) == 0 {
+		t.Skip("no provider configured")
+	}
+	report := interopReport(providers, matrix)
+	t.Log("\n" + report)
+	if path := os.Getenv("OIDC_INTEROP_REPORT"); path != "" {
+		require.NoError(t, os.WriteFile(path, []byte(report), 0o644))
+	}
+}
+
+// interopReport renders the matrix as markdown table, with the checks
+// as rows and the providers as columns, followed by the failures.
+func interopReport(providers []interopProvider, matrix map[string]map[string]interopResult) string {
+	var names []string
+	for _, provider := range providers {
+		if _, ok := matrix[provider.name]; ok {
+			names = append(names, provider.name)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "| check | %s |\n|---|%s\n", strings.Join(names, " | "), strings.Repeat("---|", len(names)))
+	var failures []string
+	for _, c := range interopChecks {
+		fmt.Fprintf(&b, "| %s |", c.name)
+		for _, name := range names {
+			result := matrix[name][c.name]
+			fmt.Fprintf(&b, " %s |", result.status)
+			if result.status == interopFail {
+				failures = append(failures, fmt.Sprintf("- %s %s: %s", name, c.name, result.detail))
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(failures) > 0 {
+		slices.Sort(failures)
+		b.WriteString("\n" + strings.Join(failures, "\n") + "\n")
+	}
+	return b.String()
+}
+
+// TestInteropReport checks the rendering of the matrix without network access.
+func TestInteropReport(t *testing.T) {
+	report := interopReport(
+		[]interopProvider{{name: "keycloak"}, {name: "auth0"}, {name: "google"}},
+		map[string]map[string]interopResult{
+			"keycloak": {"discovery": {status: interopPass}},
+			"google":   {"discovery": {status: interopFail, detail: "timeout"}},
+		},
+	)
+	require.True(t, strings.HasPrefix(report, "| check | keycloak | google |\n|---|---|---|\n| discovery | pass | FAIL |\n"), report)
+	require.Contains(t, report, "- google discovery: timeout")
+}
@@ -28,3 +28,21 @@ func ClientAssertionFormAuthorization(assertion string) http.FormAuthorization {
 		values.Set("client_assertion_type", oidc.ClientAssertionTypeJWTAssertion)
 	}
 }
+
+// SPIFFEAssertionCodeOptions authenticates the client with its SPIFFE JWT-SVID
+// in the token request of the code exchange (auth method spiffe_jwt).
+func SPIFFEAssertionCodeOptions(svid string) []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("client_assertion", svid),
+		oauth2.SetAuthURLParam("client_assertion_type", oidc.ClientAssertionTypeJWTSPIFFE),
+	}
+}
+
+// SPIFFEAssertionFormAuthorization authenticates the client with its
+// SPIFFE JWT-SVID (auth method spiffe_jwt).
+func SPIFFEAssertionFormAuthorization(svid string) http.FormAuthorization {
+	return func(values url.Values) {
+		values.Set("client_assertion", svid)
+		values.Set("client_assertion_type", oidc.ClientAssertionTypeJWTSPIFFE)
+	}
+}
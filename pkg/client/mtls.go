@@ -0,0 +1,47 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// HasClientCertificate reports whether the transport of the http client
+// is configured with a TLS client certificate, for mutual-TLS.
+func HasClientCertificate(httpClient *http.Client) bool {
+	if httpClient == nil {
+		return false
+	}
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok || transport.TLSClientConfig == nil {
+		return false
+	}
+	return len(transport.TLSClientConfig.Certificates) > 0 || transport.TLSClientConfig.GetClientCertificate != nil
+}
+
+// MTLSEndpoint returns the mTLS alias of an endpoint (RFC 8705, section 5),
+// if the http client has a client certificate and the alias is set.
+// Otherwise the (conventional) endpoint is returned.
+func MTLSEndpoint(httpClient *http.Client, endpoint, alias string) string {
+	if alias != "" && HasClientCertificate(httpClient) {
+		return alias
+	}
+	return endpoint
+}
+
+// MTLSEndpoints returns the discovery configuration with the mTLS aliased
+// endpoints, if the http client has a client certificate.
+// The passed configuration is not modified.
+func MTLSEndpoints(httpClient *http.Client, config *oidc.DiscoveryConfiguration) *oidc.DiscoveryConfiguration {
+	aliases := config.MTLSEndpointAliases
+	if aliases == nil || !HasClientCertificate(httpClient) {
+		return config
+	}
+	c := *config
+	c.TokenEndpoint = MTLSEndpoint(httpClient, c.TokenEndpoint, aliases.TokenEndpoint)
+	c.IntrospectionEndpoint = MTLSEndpoint(httpClient, c.IntrospectionEndpoint, aliases.IntrospectionEndpoint)
+	c.UserinfoEndpoint = MTLSEndpoint(httpClient, c.UserinfoEndpoint, aliases.UserinfoEndpoint)
+	c.RevocationEndpoint = MTLSEndpoint(httpClient, c.RevocationEndpoint, aliases.RevocationEndpoint)
+	c.DeviceAuthorizationEndpoint = MTLSEndpoint(httpClient, c.DeviceAuthorizationEndpoint, aliases.DeviceAuthorizationEndpoint)
+	return &c
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMTLSEndpoints(t *testing.T) {
+	config := &oidc.DiscoveryConfiguration{
+		TokenEndpoint:         "https://example.com/oauth/token",
+		IntrospectionEndpoint: "https://example.com/oauth/introspect",
+		MTLSEndpointAliases: &oidc.MTLSEndpointAliases{
+			TokenEndpoint: "https://mtls.example.com/oauth/token",
+		},
+	}
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		Certificates: []tls.Certificate{{}},
+	}}}
+
+	got := MTLSEndpoints(http.DefaultClient, config)
+	assert.Equal(t, config, got)
+
+	got = MTLSEndpoints(withCert, config)
+	assert.Equal(t, "https://mtls.example.com/oauth/token", got.TokenEndpoint)
+	assert.Equal(t, "https://example.com/oauth/introspect", got.IntrospectionEndpoint)
+	assert.Equal(t, "https://example.com/oauth/token", config.TokenEndpoint, "config must not be modified")
+}
@@ -80,7 +80,7 @@ func NewJWTProfileTokenSource(ctx context.Context, issuer, clientID, keyID strin
 		if err != nil {
 			return nil, err
 		}
-		source.tokenEndpoint = config.TokenEndpoint
+		source.tokenEndpoint = client.MTLSEndpoints(source.httpClient, config).TokenEndpoint
 	}
 	return source, nil
 }
@@ -105,6 +105,8 @@ func (j *jwtProfileTokenSource) HttpClient() *http.Client {
 	return j.httpClient
 }
 
+// Token implements [oauth2.TokenSource], without a context of the caller.
+// TokenCtx is used by [client.TokenTransport] with the context of the request.
 func (j *jwtProfileTokenSource) Token() (*oauth2.Token, error) {
 	return j.TokenCtx(context.Background())
 }
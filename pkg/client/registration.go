@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// RegisterClient registers a new client with the metadata at the registration endpoint
+// of the OpenID Provider (RFC 7591, section 3.1), as discovered in
+// [oidc.DiscoveryConfiguration.RegistrationEndpoint].
+// The initialAccessToken is sent as bearer token, if the provider requires one.
+//
+// The response contains the issued client_id and client_secret and,
+// for the management of the client, the registration_access_token
+// and registration_client_uri.
+func RegisterClient(ctx context.Context, endpoint, initialAccessToken string, metadata *oidc.ClientMetadata, httpClient *http.Client) (*oidc.ClientRegistrationResponse, error) {
+	ctx, span := Tracer.Start(ctx, "RegisterClient")
+	defer span.End()
+
+	if endpoint == "" {
+		return nil, fmt.Errorf("registration %w", ErrEndpointNotSet)
+	}
+	return callRegistrationEndpoint(ctx, http.MethodPost, endpoint, initialAccessToken, &oidc.ClientRegistrationRequest{ClientMetadata: *metadata}, httpClient)
+}
+
+// ReadClientRegistration returns the current registration of the client
+// from its registration_client_uri (RFC 7592, section 2.1).
+func ReadClientRegistration(ctx context.Context, registrationClientURI, registrationAccessToken string, httpClient *http.Client) (*oidc.ClientRegistrationResponse, error) {
+	ctx, span := Tracer.Start(ctx, "ReadClientRegistration")
+	defer span.End()
+
+	return callRegistrationEndpoint(ctx, http.MethodGet, registrationClientURI, registrationAccessToken, nil, httpClient)
+}
+
+// UpdateClientRegistration replaces the metadata of the client at its
+// registration_client_uri (RFC 7592, section 2.2). The request must contain
+// the client_id and all metadata, omitted values are reset by the provider.
+func UpdateClientRegistration(ctx context.Context, registrationClientURI, registrationAccessToken string, request *oidc.ClientRegistrationRequest, httpClient *http.Client) (*oidc.ClientRegistrationResponse, error) {
+	ctx, span := Tracer.Start(ctx, "UpdateClientRegistration")
+	defer span.End()
+
+	return callRegistrationEndpoint(ctx, http.MethodPut, registrationClientURI, registrationAccessToken, request, httpClient)
+}
+
+// DeleteClientRegistration deregisters the client at its registration_client_uri
+// (RFC 7592, section 2.3).
+func DeleteClientRegistration(ctx context.Context, registrationClientURI, registrationAccessToken string, httpClient *http.Client) error {
+	ctx, span := Tracer.Start(ctx, "DeleteClientRegistration")
+	defer span.End()
+
+	req, err := registrationRequest(ctx, http.MethodDelete, registrationClientURI, registrationAccessToken, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		respErr, err := httphelper.NewResponseError(resp)
+		if err != nil {
+			return fmt.Errorf("delete client registration returned status %d", resp.StatusCode)
+		}
+		return fmt.Errorf("delete client registration failure: %w", respErr)
+	}
+	return nil
+}
+
+func callRegistrationEndpoint(ctx context.Context, method, endpoint, token string, request any, httpClient *http.Client) (*oidc.ClientRegistrationResponse, error) {
+	req, err := registrationRequest(ctx, method, endpoint, token, request)
+	if err != nil {
+		return nil, err
+	}
+	resp := new(oidc.ClientRegistrationResponse)
+	if err := httphelper.HttpRequest(httpClient, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func registrationRequest(ctx context.Context, method, endpoint, token string, request any) (*http.Request, error) {
+	var body bytes.Buffer
+	if request != nil {
+		if err := json.NewEncoder(&body).Encode(request); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, &body)
+	if err != nil {
+		return nil, err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", oidc.BearerToken+" "+token)
+	}
+	return req, nil
+}
@@ -0,0 +1,71 @@
+package rp
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// withAuthorizationDetails sets the `authorization_details` param
+// of Rich Authorization Requests (RFC 9396).
+// This is the generalized, unexported, function used by
+// URLParamOpt, AuthURLOpt and CodeExchangeOpt.
+func withAuthorizationDetails(details oidc.AuthorizationDetails) func() []oauth2.AuthCodeOption {
+	return func() []oauth2.AuthCodeOption {
+		if len(details) == 0 {
+			return nil
+		}
+		text, err := details.MarshalText()
+		if err != nil {
+			return nil
+		}
+		return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("authorization_details", string(text))}
+	}
+}
+
+// WithAuthorizationDetailsURLParam sets the `authorization_details` parameter in a URL.
+func WithAuthorizationDetailsURLParam(details ...oidc.AuthorizationDetail) URLParamOpt {
+	return withAuthorizationDetails(details)
+}
+
+// WithAuthorizationDetails requests the authorization details in the auth request (RFC 9396, section 2).
+func WithAuthorizationDetails(details ...oidc.AuthorizationDetail) AuthURLOpt {
+	return withAuthorizationDetails(details)
+}
+
+// WithTokenAuthorizationDetails narrows the authorization details granted by the auth request
+// to the details in the token request (RFC 9396, section 6.1).
+func WithTokenAuthorizationDetails(details ...oidc.AuthorizationDetail) CodeExchangeOpt {
+	return withAuthorizationDetails(details)
+}
+
+// AuthorizationDetailsParams returns the endpoint params requesting the authorization details,
+// e.g. for [ClientCredentials].
+func AuthorizationDetailsParams(details ...oidc.AuthorizationDetail) (url.Values, error) {
+	text, err := oidc.AuthorizationDetails(details).MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return url.Values{"authorization_details": {string(text)}}, nil
+}
+
+// AuthorizationDetailsFromToken returns the authorization details granted to the
+// access token of the token response (RFC 9396, section 7), if any.
+func AuthorizationDetailsFromToken(token *oauth2.Token) (oidc.AuthorizationDetails, error) {
+	extra := token.Extra("authorization_details")
+	if extra == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	var details oidc.AuthorizationDetails
+	if err = json.Unmarshal(data, &details); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
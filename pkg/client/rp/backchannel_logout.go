@@ -0,0 +1,102 @@
+package rp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// VerifyLogoutToken validates the logout token posted by the OP according to
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#Validation
+// with the issuer, client ID, keys and signing algorithms of the ID token verifier.
+// The typ header must be logout+jwt, the events claim must contain the back-channel
+// logout event, jti and sub or sid must be present and a nonce must not be.
+func VerifyLogoutToken(ctx context.Context, token string, v *IDTokenVerifier) (claims *oidc.LogoutTokenClaims, err error) {
+	ctx, span := client.Tracer.Start(ctx, "VerifyLogoutToken")
+	defer span.End()
+
+	header, _, err := oidc.DecodeUnverified[map[string]any](token)
+	if err != nil {
+		return nil, err
+	}
+	if !isLogoutTokenType(header.Type) {
+		return nil, fmt.Errorf("%w: got %q", oidc.ErrLogoutTokenType, header.Type)
+	}
+	claims = new(oidc.LogoutTokenClaims)
+	payload, err := oidc.ParseToken(token, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckIssuerMatch(claims, v.Issuer, v.AllowInsecure); err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckAudience(claims, v.ClientID); err != nil {
+		return nil, err
+	}
+	algs, err := v.signingAlgorithms()
+	if err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckSignature(ctx, token, payload, claims, algs, v.KeySet); err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckExpiration(claims, v.Offset); err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckIssuedAt(claims, v.MaxAgeIAT, v.Offset); err != nil {
+		return nil, err
+	}
+	if _, ok := claims.Events[oidc.BackChannelLogoutEvent].(map[string]any); !ok {
+		return nil, oidc.ErrLogoutEventMissing
+	}
+	if claims.JWTID == "" {
+		return nil, oidc.ErrLogoutJWTIDMissing
+	}
+	if claims.Subject == "" && claims.SessionID == "" {
+		return nil, oidc.ErrLogoutSubjectMissing
+	}
+	if _, ok := claims.Claims["nonce"]; ok {
+		return nil, oidc.ErrLogoutNonce
+	}
+	return claims, nil
+}
+
+// isLogoutTokenType reports if the typ header is logout+jwt,
+// the media type application/logout+jwt may be used as well.
+func isLogoutTokenType(typ string) bool {
+	typ = strings.ToLower(typ)
+	return typ == oidc.LogoutTokenType || typ == "application/"+oidc.LogoutTokenType
+}
+
+// LogoutFunc terminates the sessions of the sid or sub of the verified logout token.
+type LogoutFunc func(ctx context.Context, claims *oidc.LogoutTokenClaims) error
+
+// BackChannelLogoutHandler handles the logout tokens posted by the OP
+// to the backchannel_logout_uri of the client, verified by [VerifyLogoutToken]
+// with the ID token verifier of the RelyingParty.
+// Invalid tokens and failed logouts are answered with 400 Bad Request.
+func BackChannelLogoutHandler(rp RelyingParty, logout LogoutFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		claims, err := VerifyLogoutToken(r.Context(), r.PostFormValue("logout_token"), rp.IDTokenVerifier())
+		if err != nil {
+			httphelper.MarshalJSONWithStatus(w, oidc.ErrInvalidRequest().WithDescription(err.Error()), http.StatusBadRequest)
+			return
+		}
+		if err = logout(r.Context(), claims); err != nil {
+			httphelper.MarshalJSONWithStatus(w, oidc.ErrInvalidRequest().WithDescription("logout failed"), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
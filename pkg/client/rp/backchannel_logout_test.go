@@ -0,0 +1,136 @@
+package rp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func newLogoutToken(t *testing.T, modify func(claims *oidc.LogoutTokenClaims)) string {
+	claims := oidc.NewLogoutTokenClaims(tu.ValidIssuer, tu.ValidSubject, oidc.Audience{tu.ValidClientID}, tu.ValidExpiration, "jti1", "sid1", 0)
+	if modify != nil {
+		modify(claims)
+	}
+	token, err := crypto.Sign(claims, tu.LogoutTokenSigner)
+	require.NoError(t, err)
+	return token
+}
+
+func TestVerifyLogoutToken(t *testing.T) {
+	verifier := NewIDTokenVerifier(tu.ValidIssuer, tu.ValidClientID, tu.KeySet{},
+		WithSupportedSigningAlgorithms(string(tu.SignatureAlgorithm)),
+	)
+	tests := []struct {
+		name    string
+		modify  func(claims *oidc.LogoutTokenClaims)
+		wantErr error
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:   "sid only",
+			modify: func(claims *oidc.LogoutTokenClaims) { claims.Subject = "" },
+		},
+		{
+			name:    "wrong issuer",
+			modify:  func(claims *oidc.LogoutTokenClaims) { claims.Issuer = "https://other.example.com" },
+			wantErr: oidc.ErrIssuerInvalid,
+		},
+		{
+			name:    "wrong audience",
+			modify:  func(claims *oidc.LogoutTokenClaims) { claims.Audience = oidc.Audience{"other"} },
+			wantErr: oidc.ErrAudience,
+		},
+		{
+			name:    "expired",
+			modify:  func(claims *oidc.LogoutTokenClaims) { claims.Expiration = oidc.FromTime(time.Now().Add(-time.Minute)) },
+			wantErr: oidc.ErrExpired,
+		},
+		{
+			name:    "missing event",
+			modify:  func(claims *oidc.LogoutTokenClaims) { claims.Events = map[string]any{"other": struct{}{}} },
+			wantErr: oidc.ErrLogoutEventMissing,
+		},
+		{
+			name:    "missing sub and sid",
+			modify:  func(claims *oidc.LogoutTokenClaims) { claims.Subject, claims.SessionID = "", "" },
+			wantErr: oidc.ErrLogoutSubjectMissing,
+		},
+		{
+			name:    "missing jti",
+			modify:  func(claims *oidc.LogoutTokenClaims) { claims.JWTID = "" },
+			wantErr: oidc.ErrLogoutJWTIDMissing,
+		},
+		{
+			name:    "nonce",
+			modify:  func(claims *oidc.LogoutTokenClaims) { claims.Claims = map[string]any{"nonce": "n"} },
+			wantErr: oidc.ErrLogoutNonce,
+		},
+	}
+	t.Run("missing typ", func(t *testing.T) {
+		token, err := crypto.Sign(oidc.NewLogoutTokenClaims(tu.ValidIssuer, tu.ValidSubject, oidc.Audience{tu.ValidClientID}, tu.ValidExpiration, "jti1", "sid1", 0), tu.Signer)
+		require.NoError(t, err)
+		_, err = VerifyLogoutToken(context.Background(), token, verifier)
+		require.ErrorIs(t, err, oidc.ErrLogoutTokenType)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyLogoutToken(context.Background(), newLogoutToken(t, tt.modify), verifier)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "sid1", got.SessionID)
+		})
+	}
+}
+
+func TestBackChannelLogoutHandler(t *testing.T) {
+	rp := &relyingParty{
+		idTokenVerifier: NewIDTokenVerifier(tu.ValidIssuer, tu.ValidClientID, tu.KeySet{},
+			WithSupportedSigningAlgorithms(string(tu.SignatureAlgorithm)),
+		),
+	}
+	errLogout := errors.New("logout")
+	var sessions []string
+	handler := BackChannelLogoutHandler(rp, func(_ context.Context, claims *oidc.LogoutTokenClaims) error {
+		if claims.SessionID == "fail" {
+			return errLogout
+		}
+		sessions = append(sessions, claims.SessionID)
+		return nil
+	})
+	post := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/backchannel_logout", strings.NewReader(url.Values{"logout_token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := post(newLogoutToken(t, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, []string{"sid1"}, sessions)
+
+	assert.Equal(t, http.StatusBadRequest, post("invalid").Code)
+	assert.Equal(t, http.StatusBadRequest, post(newLogoutToken(t, func(claims *oidc.LogoutTokenClaims) { claims.SessionID = "fail" })).Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/backchannel_logout", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,226 @@
+package rp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ErrCapabilityMissing is returned by [ProviderCapabilities.Require],
+// if the OP does not support a required feature.
+var ErrCapabilityMissing = errors.New("provider capability missing")
+
+// ProviderCapabilities summarizes the features the OP supports,
+// as advertised by its discovery document.
+type ProviderCapabilities struct {
+	// Discovered is false for a RelyingParty created without discovery,
+	// of which only the endpoints are known.
+	Discovered bool
+
+	CodeChallengeMethods []oidc.CodeChallengeMethod
+	ResponseTypes        []oidc.ResponseType
+	// ResponseModes defaults to query and fragment, if not advertised.
+	ResponseModes []oidc.ResponseMode
+	// GrantTypes defaults to authorization_code and implicit, if not advertised.
+	GrantTypes []oidc.GrantType
+	// TokenEndpointAuthMethods defaults to client_secret_basic, if not advertised.
+	TokenEndpointAuthMethods []oidc.AuthMethod
+	IDTokenSigningAlgs       []string
+	DPoPSigningAlgs          []string
+
+	// PushedAuthorization is true, if a Pushed Authorization Request endpoint
+	// is advertised or set with [WithPAR].
+	PushedAuthorization         bool
+	PushedAuthorizationRequired bool
+
+	EndSession          bool
+	Revocation          bool
+	Userinfo            bool
+	DeviceAuthorization bool
+}
+
+type discoverer interface {
+	discoveryConfiguration() *oidc.DiscoveryConfiguration
+}
+
+func (rp *relyingParty) discoveryConfiguration() *oidc.DiscoveryConfiguration {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
+	return rp.discovery
+}
+
+// Capabilities returns the features supported by the OP of the rp,
+// which [ProviderCapabilities.Require] checks at startup.
+func Capabilities(rp RelyingParty) *ProviderCapabilities {
+	c := &ProviderCapabilities{
+		EndSession:          rp.GetEndSessionEndpoint() != "",
+		Revocation:          rp.GetRevokeEndpoint() != "",
+		Userinfo:            rp.UserinfoEndpoint() != "",
+		DeviceAuthorization: rp.GetDeviceAuthorizationEndpoint() != "",
+	}
+	if p, ok := rp.(pushedAuthorizer); ok {
+		endpoint, _ := p.pushedAuthorizationEndpoint()
+		c.PushedAuthorization = endpoint != ""
+	}
+	d, ok := rp.(discoverer)
+	if !ok || d.discoveryConfiguration() == nil {
+		return c
+	}
+	config := d.discoveryConfiguration()
+	c.Discovered = true
+	c.CodeChallengeMethods = slices.Clone(config.CodeChallengeMethodsSupported)
+	c.ResponseTypes = convertStrings[oidc.ResponseType](config.ResponseTypesSupported)
+	c.ResponseModes = convertStrings[oidc.ResponseMode](config.ResponseModesSupported)
+	if len(c.ResponseModes) == 0 {
+		c.ResponseModes = []oidc.ResponseMode{oidc.ResponseModeQuery, oidc.ResponseModeFragment}
+	}
+	c.GrantTypes = slices.Clone(config.GrantTypesSupported)
+	if len(c.GrantTypes) == 0 {
+		c.GrantTypes = []oidc.GrantType{oidc.GrantTypeCode, oidc.GrantTypeImplicit}
+	}
+	c.TokenEndpointAuthMethods = slices.Clone(config.TokenEndpointAuthMethodsSupported)
+	if len(c.TokenEndpointAuthMethods) == 0 {
+		c.TokenEndpointAuthMethods = []oidc.AuthMethod{oidc.AuthMethodBasic}
+	}
+	c.IDTokenSigningAlgs = slices.Clone(config.IDTokenSigningAlgValuesSupported)
+	c.DPoPSigningAlgs = slices.Clone(config.DPoPSigningAlgValuesSupported)
+	c.PushedAuthorizationRequired = config.RequirePushedAuthorizationRequests
+	return c
+}
+
+func convertStrings[T ~string](values []string) []T {
+	if values == nil {
+		return nil
+	}
+	converted := make([]T, len(values))
+	for i, value := range values {
+		converted[i] = T(value)
+	}
+	return converted
+}
+
+// Requirement checks a feature needed by the application, see [ProviderCapabilities.Require].
+type Requirement func(*ProviderCapabilities) error
+
+// Require returns an error wrapping [ErrCapabilityMissing] for each
+// of the requirements the OP does not meet, or nil.
+func (c *ProviderCapabilities) Require(requirements ...Requirement) error {
+	var errs []error
+	for _, requirement := range requirements {
+		if err := requirement(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithRequiredCapabilities fails [NewRelyingPartyOIDC],
+// if the discovered OP does not meet the requirements.
+func WithRequiredCapabilities(requirements ...Requirement) Option {
+	return func(rp *relyingParty) error {
+		rp.requirements = append(rp.requirements, requirements...)
+		return nil
+	}
+}
+
+func capabilityMissing(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrCapabilityMissing}, args...)...)
+}
+
+// RequirePKCE requires the OP to advertise the code challenge method.
+func RequirePKCE(method oidc.CodeChallengeMethod) Requirement {
+	return func(c *ProviderCapabilities) error {
+		if !slices.Contains(c.CodeChallengeMethods, method) {
+			return capabilityMissing("code challenge method %q", method)
+		}
+		return nil
+	}
+}
+
+// RequirePAR requires a Pushed Authorization Request endpoint.
+func RequirePAR() Requirement {
+	return func(c *ProviderCapabilities) error {
+		if !c.PushedAuthorization {
+			return capabilityMissing("pushed authorization request endpoint")
+		}
+		return nil
+	}
+}
+
+// RequireResponseType requires the OP to support the response type.
+func RequireResponseType(responseType oidc.ResponseType) Requirement {
+	return func(c *ProviderCapabilities) error {
+		if !slices.Contains(c.ResponseTypes, responseType) {
+			return capabilityMissing("response type %q", responseType)
+		}
+		return nil
+	}
+}
+
+// RequireResponseMode requires the OP to support the response mode.
+func RequireResponseMode(mode oidc.ResponseMode) Requirement {
+	return func(c *ProviderCapabilities) error {
+		if !slices.Contains(c.ResponseModes, mode) {
+			return capabilityMissing("response mode %q", mode)
+		}
+		return nil
+	}
+}
+
+// RequireGrantType requires the OP to support the grant type.
+func RequireGrantType(grantType oidc.GrantType) Requirement {
+	return func(c *ProviderCapabilities) error {
+		if !slices.Contains(c.GrantTypes, grantType) {
+			return capabilityMissing("grant type %q", grantType)
+		}
+		return nil
+	}
+}
+
+// RequireAuthMethod requires the token endpoint to accept
+// at least one of the client authentication methods.
+func RequireAuthMethod(methods ...oidc.AuthMethod) Requirement {
+	return func(c *ProviderCapabilities) error {
+		for _, method := range methods {
+			if slices.Contains(c.TokenEndpointAuthMethods, method) {
+				return nil
+			}
+		}
+		return capabilityMissing("token endpoint auth method of %q", methods)
+	}
+}
+
+// RequireIDTokenSigningAlg requires the OP to sign ID Tokens
+// with at least one of the algorithms.
+func RequireIDTokenSigningAlg(algs ...string) Requirement {
+	return func(c *ProviderCapabilities) error {
+		for _, alg := range algs {
+			if slices.Contains(c.IDTokenSigningAlgs, alg) {
+				return nil
+			}
+		}
+		return capabilityMissing("id_token signing algorithm of %q", algs)
+	}
+}
+
+// RequireEndSession requires an end_session_endpoint for [EndSession].
+func RequireEndSession() Requirement {
+	return func(c *ProviderCapabilities) error {
+		if !c.EndSession {
+			return capabilityMissing("end session endpoint")
+		}
+		return nil
+	}
+}
+
+// RequireRevocation requires a revocation endpoint for [RevokeToken].
+func RequireRevocation() Requirement {
+	return func(c *ProviderCapabilities) error {
+		if !c.Revocation {
+			return capabilityMissing("revocation endpoint")
+		}
+		return nil
+	}
+}
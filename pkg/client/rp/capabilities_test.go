@@ -0,0 +1,63 @@
+package rp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestCapabilities(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&oidc.DiscoveryConfiguration{
+			Issuer:                             server.URL,
+			TokenEndpoint:                      server.URL + "/oauth/token",
+			PushedAuthorizationRequestEndpoint: server.URL + "/par",
+			CodeChallengeMethodsSupported:      []oidc.CodeChallengeMethod{oidc.CodeChallengeMethodS256},
+			ResponseTypesSupported:             []string{"code"},
+			IDTokenSigningAlgValuesSupported:   []string{"RS256"},
+		})
+	}))
+	defer server.Close()
+
+	relyingParty, err := NewRelyingPartyOIDC(context.Background(), server.URL, "client", "secret", "", nil)
+	require.NoError(t, err)
+	capabilities := Capabilities(relyingParty)
+	assert.True(t, capabilities.Discovered)
+	assert.True(t, capabilities.PushedAuthorization)
+	assert.False(t, capabilities.EndSession)
+	assert.Equal(t, []oidc.ResponseMode{oidc.ResponseModeQuery, oidc.ResponseModeFragment}, capabilities.ResponseModes)
+
+	assert.NoError(t, capabilities.Require(
+		RequirePKCE(oidc.CodeChallengeMethodS256),
+		RequirePAR(),
+		RequireResponseType("code"),
+		RequireResponseMode(oidc.ResponseModeQuery),
+		RequireGrantType(oidc.GrantTypeCode),
+		RequireIDTokenSigningAlg("ES256", "RS256"),
+	))
+	err = capabilities.Require(RequireEndSession(), RequireResponseMode(oidc.ResponseModeFormPost), RequireRevocation())
+	assert.ErrorIs(t, err, ErrCapabilityMissing)
+	assert.ErrorContains(t, err, "end session endpoint")
+	assert.ErrorContains(t, err, `response mode "form_post"`)
+	assert.ErrorContains(t, err, "revocation endpoint")
+
+	_, err = NewRelyingPartyOIDC(context.Background(), server.URL, "client", "secret", "", nil,
+		WithRequiredCapabilities(RequirePKCE(oidc.CodeChallengeMethodS256), RequireEndSession()),
+	)
+	assert.ErrorIs(t, err, ErrCapabilityMissing)
+
+	relyingParty, err = NewRelyingPartyOAuth(&oauth2.Config{ClientID: "client"}, WithPAR("https://op.example.com/par"))
+	require.NoError(t, err)
+	capabilities = Capabilities(relyingParty)
+	assert.False(t, capabilities.Discovered)
+	assert.NoError(t, capabilities.Require(RequirePAR()))
+	assert.ErrorIs(t, capabilities.Require(RequirePKCE(oidc.CodeChallengeMethodS256)), ErrCapabilityMissing)
+}
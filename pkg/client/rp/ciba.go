@@ -0,0 +1,76 @@
+package rp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// GetBackchannelAuthenticationEndpoint returns the endpoint which can
+// be used to start a Client-Initiated Backchannel Authentication flow.
+func (rp *relyingParty) GetBackchannelAuthenticationEndpoint() string {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
+	return rp.endpoints.BackchannelAuthenticationURL
+}
+
+// BackchannelAuthentication starts a new Client-Initiated Backchannel Authentication flow
+// for the user identified by the hint of the request, as defined in CIBA, section 7:
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7
+// The scopes of the RelyingParty are requested, if the request has none.
+func BackchannelAuthentication(ctx context.Context, req *oidc.BackchannelAuthenticationRequest, rp RelyingParty) (*oidc.BackchannelAuthenticationResponse, error) {
+	ctx, span := client.Tracer.Start(ctx, "BackchannelAuthentication")
+	defer span.End()
+
+	ctx = logCtxWithRPData(ctx, rp, "function", "BackchannelAuthentication")
+	caller, ok := rp.(client.BackchannelAuthenticationCaller)
+	if !ok {
+		return nil, fmt.Errorf("backchannel authentication %w", client.ErrEndpointNotSet)
+	}
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = rp.OAuthConfig().Scopes
+	}
+	credentials, err := newDeviceClientCredentialsRequest(scopes, rp)
+	if err != nil {
+		return nil, err
+	}
+	request := &client.BackchannelAuthenticationRequest{
+		ClientCredentialsRequest:         credentials,
+		BackchannelAuthenticationRequest: *req,
+	}
+	// scope and client_id are sent by the client credentials
+	request.BackchannelAuthenticationRequest.Scopes = nil
+	request.BackchannelAuthenticationRequest.ClientID = ""
+	return client.CallBackchannelAuthenticationEndpoint(ctx, request, caller)
+}
+
+// BackchannelAccessToken attempts to obtain tokens from a Client-Initiated Backchannel
+// Authentication, by means of polling as defined in CIBA, section 7.3 and 10.1:
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.1
+// A zero interval, as returned in the ping mode, defaults to 5 seconds.
+// In the ping mode, it can be called once the client was notified.
+func BackchannelAccessToken(ctx context.Context, authReqID string, interval time.Duration, rp RelyingParty) (*oidc.AccessTokenResponse, error) {
+	ctx, span := client.Tracer.Start(ctx, "BackchannelAccessToken")
+	defer span.End()
+
+	ctx = logCtxWithRPData(ctx, rp, "function", "BackchannelAccessToken")
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	credentials, err := newDeviceClientCredentialsRequest(nil, rp)
+	if err != nil {
+		return nil, err
+	}
+	req := &client.BackchannelTokenRequest{
+		ClientCredentialsRequest: credentials,
+		BackchannelTokenRequest: oidc.BackchannelTokenRequest{
+			GrantType: oidc.GrantTypeCIBA,
+			AuthReqID: authReqID,
+		},
+	}
+	return client.PollBackchannelTokenEndpoint(ctx, interval, req, tokenEndpointCaller{rp})
+}
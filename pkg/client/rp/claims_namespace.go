@@ -0,0 +1,36 @@
+package rp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// NamespacedClaim returns the value of the custom claim of the namespace,
+// like the Claims of [oidc.IDTokenClaims] or [oidc.UserInfo], decoded into T.
+// It reports false, if the claim is missing.
+func NamespacedClaim[T any](claims map[string]any, namespace oidc.ClaimNamespace, name string) (value T, ok bool, err error) {
+	ok, err = namespace.Decode(claims, name, &value)
+	return value, ok, err
+}
+
+// StripClaimNamespace renames the custom claims of the namespace to their names
+// without namespace, for applications expecting plain names.
+// Claims which would shadow one of the [oidc.RegisteredClaims] or another claim
+// fail the transformation with [oidc.ErrClaimCollision].
+func StripClaimNamespace(namespace oidc.ClaimNamespace) ClaimsTransformer {
+	return func(_ context.Context, claims map[string]any) error {
+		namespaced := namespace.Claims(claims)
+		for name := range namespaced {
+			if _, ok := claims[name]; ok || oidc.IsRegisteredClaim(name) {
+				return fmt.Errorf("%w: %s", oidc.ErrClaimCollision, namespace.Claim(name))
+			}
+		}
+		for name, value := range namespaced {
+			delete(claims, namespace.Claim(name))
+			claims[name] = value
+		}
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package rp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestNamespacedClaim(t *testing.T) {
+	ns := oidc.ClaimNamespace("https://example.com/")
+	claims := map[string]any{"https://example.com/roles": []any{"admin", "user"}}
+
+	roles, ok, err := NamespacedClaim[[]string](claims, ns, "roles")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"admin", "user"}, roles)
+
+	_, ok, err = NamespacedClaim[string](claims, ns, "tenant")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = NamespacedClaim[int](claims, ns, "roles")
+	assert.Error(t, err)
+}
+
+func TestStripClaimNamespace(t *testing.T) {
+	ns := oidc.ClaimNamespace("https://example.com/")
+	claims := map[string]any{
+		"sub":                        "sub1",
+		"https://example.com/tenant": "acme",
+	}
+	require.NoError(t, StripClaimNamespace(ns)(context.Background(), claims))
+	assert.Equal(t, map[string]any{"sub": "sub1", "tenant": "acme"}, claims)
+
+	for _, claims := range []map[string]any{
+		{"https://example.com/email": "jane@example.com"},
+		{"tenant": "other", "https://example.com/tenant": "acme"},
+	} {
+		err := StripClaimNamespace(ns)(context.Background(), claims)
+		assert.ErrorIs(t, err, oidc.ErrClaimCollision)
+	}
+}
@@ -0,0 +1,102 @@
+package rp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ClaimsTransformer transforms the claims of validated ID tokens and userinfo responses,
+// before they are returned to the application, see [WithClaimsTransformers].
+// The claims are passed in their JSON representation and may be modified in place.
+type ClaimsTransformer func(ctx context.Context, claims map[string]any) error
+
+// WithClaimsTransformers runs the validated claims of the ID tokens of [CodeExchange]
+// and [RefreshTokens] and of the responses of [Userinfo] through the transformers, in order,
+// to centralize the normalization of claims of different OPs.
+// The raw ID token stays unchanged.
+func WithClaimsTransformers(transformers ...ClaimsTransformer) Option {
+	return func(rp *relyingParty) error {
+		rp.claimsTransformers = append(rp.claimsTransformers, transformers...)
+		return nil
+	}
+}
+
+// LowercaseEmail transforms the email claim to lower case.
+func LowercaseEmail() ClaimsTransformer {
+	return func(_ context.Context, claims map[string]any) error {
+		if email, ok := claims["email"].(string); ok {
+			claims["email"] = strings.ToLower(email)
+		}
+		return nil
+	}
+}
+
+// RenameClaims maps OP specific claim names to canonical ones,
+// e.g. {"upn": "preferred_username"}. Canonical claims already present are kept.
+func RenameClaims(names map[string]string) ClaimsTransformer {
+	return func(_ context.Context, claims map[string]any) error {
+		for from, to := range names {
+			value, ok := claims[from]
+			if !ok {
+				continue
+			}
+			delete(claims, from)
+			if _, ok := claims[to]; !ok {
+				claims[to] = value
+			}
+		}
+		return nil
+	}
+}
+
+// DeriveName sets the name claim from the given_name and family_name claims,
+// if the OP did not return it.
+func DeriveName() ClaimsTransformer {
+	return func(_ context.Context, claims map[string]any) error {
+		if name, _ := claims["name"].(string); name != "" {
+			return nil
+		}
+		given, _ := claims["given_name"].(string)
+		family, _ := claims["family_name"].(string)
+		if name := strings.TrimSpace(given + " " + family); name != "" {
+			claims["name"] = name
+		}
+		return nil
+	}
+}
+
+type claimsTransformersGetter interface {
+	ClaimsTransformers() []ClaimsTransformer
+}
+
+// transformClaims runs the claims through the [WithClaimsTransformers] of the rp.
+func transformClaims[C any](ctx context.Context, rp RelyingParty, claims C) (C, error) {
+	getter, ok := rp.(claimsTransformersGetter)
+	if !ok || len(getter.ClaimsTransformers()) == 0 {
+		return claims, nil
+	}
+	var nilClaims C
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nilClaims, err
+	}
+	var members map[string]any
+	if err = json.Unmarshal(data, &members); err != nil {
+		return nilClaims, err
+	}
+	for _, transform := range getter.ClaimsTransformers() {
+		if err = transform(ctx, members); err != nil {
+			return nilClaims, fmt.Errorf("transform claims: %w", err)
+		}
+	}
+	if data, err = json.Marshal(members); err != nil {
+		return nilClaims, err
+	}
+	var transformed C
+	if err = json.Unmarshal(data, &transformed); err != nil {
+		return nilClaims, err
+	}
+	return transformed, nil
+}
@@ -0,0 +1,57 @@
+package rp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func Test_transformClaims(t *testing.T) {
+	rp := &relyingParty{}
+	require.NoError(t, WithClaimsTransformers(
+		LowercaseEmail(),
+		RenameClaims(map[string]string{"upn": "preferred_username", "mail": "email"}),
+		DeriveName(),
+	)(rp))
+
+	idToken := &oidc.IDTokenClaims{
+		TokenClaims: oidc.TokenClaims{Subject: "sub1"},
+		UserInfoProfile: oidc.UserInfoProfile{
+			GivenName:  "Jane",
+			FamilyName: "Doe",
+		},
+		Claims: map[string]any{"upn": "jdoe@example.com"},
+	}
+	gotIDToken, err := transformClaims(context.Background(), rp, idToken)
+	require.NoError(t, err)
+	assert.Equal(t, "sub1", gotIDToken.Subject)
+	assert.Equal(t, "Jane Doe", gotIDToken.Name)
+	assert.Equal(t, "jdoe@example.com", gotIDToken.PreferredUsername)
+	assert.NotContains(t, gotIDToken.Claims, "upn")
+
+	userinfo := &oidc.UserInfo{
+		Subject:       "sub1",
+		UserInfoEmail: oidc.UserInfoEmail{Email: "Jane.Doe@Example.com"},
+		Claims:        map[string]any{"mail": "other@example.com"},
+	}
+	gotUserinfo, err := transformClaims(context.Background(), rp, userinfo)
+	require.NoError(t, err)
+	assert.Equal(t, "jane.doe@example.com", gotUserinfo.Email)
+	assert.NotContains(t, gotUserinfo.Claims, "mail")
+
+	errTransform := errors.New("transform")
+	failing := &relyingParty{claimsTransformers: []ClaimsTransformer{
+		func(context.Context, map[string]any) error { return errTransform },
+	}}
+	_, err = transformClaims(context.Background(), failing, userinfo)
+	require.ErrorIs(t, err, errTransform)
+
+	unchanged, err := transformClaims(context.Background(), &relyingParty{}, userinfo)
+	require.NoError(t, err)
+	assert.Same(t, userinfo, unchanged)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/lmindwarel/oidc/v3/pkg/client"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/qrcode"
 )
 
 func newDeviceClientCredentialsRequest(scopes []string, rp RelyingParty) (*oidc.ClientCredentialsRequest, error) {
@@ -53,7 +54,31 @@ func DeviceAccessToken(ctx context.Context, deviceCode string, interval time.Dur
 	defer span.End()
 
 	ctx = logCtxWithRPData(ctx, rp, "function", "DeviceAccessToken")
-	req := &client.DeviceAccessTokenRequest{
+	req, err := newDeviceAccessTokenRequest(deviceCode, rp)
+	if err != nil {
+		return nil, err
+	}
+	return client.PollDeviceAccessTokenEndpoint(ctx, interval, req, tokenEndpointCaller{rp})
+}
+
+// DeviceAccessTokenLongPoll is like DeviceAccessToken, but asks the OP
+// to hold each token request up to wait for the completion of the authorization,
+// reducing the latency and number of requests. OPs not supporting it
+// respond immediately, so it falls back to polling at the interval.
+func DeviceAccessTokenLongPoll(ctx context.Context, deviceCode string, interval, wait time.Duration, rp RelyingParty) (resp *oidc.AccessTokenResponse, err error) {
+	ctx, span := client.Tracer.Start(ctx, "DeviceAccessTokenLongPoll")
+	defer span.End()
+
+	ctx = logCtxWithRPData(ctx, rp, "function", "DeviceAccessTokenLongPoll")
+	req, err := newDeviceAccessTokenRequest(deviceCode, rp)
+	if err != nil {
+		return nil, err
+	}
+	return client.LongPollDeviceAccessTokenEndpoint(ctx, interval, wait, req, tokenEndpointCaller{rp})
+}
+
+func newDeviceAccessTokenRequest(deviceCode string, rp RelyingParty) (req *client.DeviceAccessTokenRequest, err error) {
+	req = &client.DeviceAccessTokenRequest{
 		DeviceAccessTokenRequest: oidc.DeviceAccessTokenRequest{
 			GrantType:  oidc.GrantTypeDeviceCode,
 			DeviceCode: deviceCode,
@@ -64,6 +89,17 @@ func DeviceAccessToken(ctx context.Context, deviceCode string, interval time.Dur
 	if err != nil {
 		return nil, err
 	}
+	return req, nil
+}
 
-	return client.PollDeviceAccessTokenEndpoint(ctx, interval, req, tokenEndpointCaller{rp})
+// DeviceAuthorizationQRCode encodes the verification_uri_complete of the response
+// as QR code, to be displayed by CLI or TV clients, so the user can scan it
+// instead of typing the user code. It falls back to the verification_uri,
+// if the OP does not return a verification_uri_complete.
+func DeviceAuthorizationQRCode(resp *oidc.DeviceAuthorizationResponse, level qrcode.Level) (*qrcode.QRCode, error) {
+	uri := resp.VerificationURIComplete
+	if uri == "" {
+		uri = resp.VerificationURI
+	}
+	return qrcode.Encode(uri, level)
 }
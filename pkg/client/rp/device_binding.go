@@ -0,0 +1,34 @@
+package rp
+
+import (
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// NewDeviceProofSigner creates a signer for device proofs,
+// embedding the public key of the device key in the JWT header.
+// The key is typically held by a TPM or secure enclave of the device.
+func NewDeviceProofSigner(key any, alg jose.SignatureAlgorithm) (jose.Signer, error) {
+	opts := (&jose.SignerOptions{EmbedJWK: true}).WithType(oidc.DeviceProofType)
+	return jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+}
+
+// NewDeviceProof creates a signed device proof for the issuer, to be sent in the
+// [oidc.DeviceProofHeader] of token requests, binding the refresh token to the device.
+// The refreshToken must be empty on the code exchange and set on refresh.
+func NewDeviceProof(signer jose.Signer, issuer, refreshToken string) (string, error) {
+	claims := &oidc.DeviceProofClaims{
+		JWTID:    uuid.New().String(),
+		IssuedAt: oidc.FromTime(time.Now()),
+		Audience: oidc.Audience{issuer},
+	}
+	if refreshToken != "" {
+		claims.RefreshTokenHash = oidc.RefreshTokenHash(refreshToken)
+	}
+	return crypto.Sign(claims, signer)
+}
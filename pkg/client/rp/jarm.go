@@ -0,0 +1,127 @@
+package rp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ErrJWTResponseRequired is returned for plain authorization responses,
+// if [WithJWTResponseRequired] is set.
+var ErrJWTResponseRequired = errors.New("authorization response is not a JWT")
+
+// jwtResponse is the configuration of the JWT response modes (JARM).
+type jwtResponse struct {
+	required   bool
+	key        any
+	algorithm  jose.KeyAlgorithm
+	encryption jose.ContentEncryption
+}
+
+// WithJWTResponseRequired rejects plain authorization responses in [CodeExchangeHandler],
+// for clients requesting the JWT response modes (JARM) with [WithResponseModeURLParam].
+func WithJWTResponseRequired() Option {
+	return func(rp *relyingParty) error {
+		rp.jwtResponse.required = true
+		return nil
+	}
+}
+
+// WithJWTResponseDecryptionKey decrypts encrypted response JWTs of the JWT response modes (JARM)
+// with the private key, for the registered authorization_encrypted_response_alg and _enc.
+func WithJWTResponseDecryptionKey(key any, algorithm jose.KeyAlgorithm, encryption jose.ContentEncryption) Option {
+	return func(rp *relyingParty) error {
+		rp.jwtResponse.key = key
+		rp.jwtResponse.algorithm = algorithm
+		rp.jwtResponse.encryption = encryption
+		return nil
+	}
+}
+
+type jwtResponseGetter interface {
+	jwtResponseConfig() jwtResponse
+}
+
+func (rp *relyingParty) jwtResponseConfig() jwtResponse {
+	return rp.jwtResponse
+}
+
+// VerifyJWTAuthorizationResponse verifies the response JWT of the JWT response modes (JARM),
+// decrypted with the key of [WithJWTResponseDecryptionKey], if encrypted,
+// and returns its claims with the parameters of the authorization response.
+func VerifyJWTAuthorizationResponse(ctx context.Context, rp RelyingParty, response string) (*oidc.JWTAuthorizationResponseClaims, error) {
+	ctx, span := client.Tracer.Start(ctx, "VerifyJWTAuthorizationResponse")
+	defer span.End()
+
+	if strings.Count(response, ".") == 4 {
+		decrypted, err := decryptJWTResponse(rp, response)
+		if err != nil {
+			return nil, err
+		}
+		response = decrypted
+	}
+	v := rp.IDTokenVerifier()
+	claims := new(oidc.JWTAuthorizationResponseClaims)
+	payload, err := oidc.ParseToken(response, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckIssuerMatch(claims, v.Issuer, v.AllowInsecure); err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckAudience(claims, v.ClientID); err != nil {
+		return nil, err
+	}
+	algs, err := v.signingAlgorithms()
+	if err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckSignature(ctx, response, payload, claims, algs, v.KeySet); err != nil {
+		return nil, err
+	}
+	if err = oidc.CheckExpiration(claims, v.Offset); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decryptJWTResponse(rp RelyingParty, response string) (string, error) {
+	getter, ok := rp.(jwtResponseGetter)
+	if !ok || getter.jwtResponseConfig().key == nil {
+		return "", errors.New("encrypted authorization response: no decryption key set")
+	}
+	config := getter.jwtResponseConfig()
+	encrypted, err := jose.ParseEncrypted(response, []jose.KeyAlgorithm{config.algorithm}, []jose.ContentEncryption{config.encryption})
+	if err != nil {
+		return "", err
+	}
+	decrypted, err := encrypted.Decrypt(config.key)
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+// unwrapJWTResponse replaces the form of the request with the parameters
+// of the verified response JWT, if any.
+func unwrapJWTResponse(r *http.Request, rp RelyingParty) error {
+	response := r.FormValue("response")
+	if response == "" {
+		if getter, ok := rp.(jwtResponseGetter); ok && getter.jwtResponseConfig().required {
+			return ErrJWTResponseRequired
+		}
+		return nil
+	}
+	claims, err := VerifyJWTAuthorizationResponse(r.Context(), rp, response)
+	if err != nil {
+		return err
+	}
+	r.Form = claims.Values()
+	return nil
+}
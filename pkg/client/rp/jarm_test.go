@@ -0,0 +1,145 @@
+package rp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func newJWTResponse(t *testing.T, audience string, expiration time.Time, params url.Values) string {
+	claims := oidc.NewJWTAuthorizationResponseClaims(tu.ValidIssuer, audience, expiration, params)
+	token, err := crypto.Sign(claims, tu.Signer)
+	require.NoError(t, err)
+	return token
+}
+
+func newJARMRelyingParty(options ...Option) *relyingParty {
+	rp := &relyingParty{
+		issuer:      tu.ValidIssuer,
+		oauthConfig: &oauth2.Config{ClientID: tu.ValidClientID},
+		idTokenVerifier: NewIDTokenVerifier(tu.ValidIssuer, tu.ValidClientID, tu.KeySet{},
+			WithSupportedSigningAlgorithms(string(tu.SignatureAlgorithm)),
+		),
+	}
+	for _, opt := range options {
+		opt(rp)
+	}
+	return rp
+}
+
+func TestVerifyJWTAuthorizationResponse(t *testing.T) {
+	encryptionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	encrypt := func(token string) string {
+		encrypter, err := jose.NewEncrypter(jose.A128GCM, jose.Recipient{Algorithm: jose.RSA_OAEP_256, Key: &encryptionKey.PublicKey}, nil)
+		require.NoError(t, err)
+		encrypted, err := encrypter.Encrypt([]byte(token))
+		require.NoError(t, err)
+		serialized, err := encrypted.CompactSerialize()
+		require.NoError(t, err)
+		return serialized
+	}
+	params := url.Values{"code": {"code1"}, "state": {"state1"}}
+	tests := []struct {
+		name     string
+		rp       RelyingParty
+		response string
+		wantErr  bool
+	}{
+		{
+			name:     "signed",
+			rp:       newJARMRelyingParty(),
+			response: newJWTResponse(t, tu.ValidClientID, tu.ValidExpiration, params),
+		},
+		{
+			name:     "other audience",
+			rp:       newJARMRelyingParty(),
+			response: newJWTResponse(t, "other", tu.ValidExpiration, params),
+			wantErr:  true,
+		},
+		{
+			name:     "expired",
+			rp:       newJARMRelyingParty(),
+			response: newJWTResponse(t, tu.ValidClientID, time.Now().Add(-time.Minute), params),
+			wantErr:  true,
+		},
+		{
+			name:     "encrypted",
+			rp:       newJARMRelyingParty(WithJWTResponseDecryptionKey(encryptionKey, jose.RSA_OAEP_256, jose.A128GCM)),
+			response: encrypt(newJWTResponse(t, tu.ValidClientID, tu.ValidExpiration, params)),
+		},
+		{
+			name:     "encrypted without key",
+			rp:       newJARMRelyingParty(),
+			response: encrypt(newJWTResponse(t, tu.ValidClientID, tu.ValidExpiration, params)),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := VerifyJWTAuthorizationResponse(context.Background(), tt.rp, tt.response)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, url.Values{"code": {"code1"}, "state": {"state1"}, "iss": {tu.ValidIssuer}}, claims.Values())
+		})
+	}
+}
+
+func TestCodeExchangeHandler_jwtResponse(t *testing.T) {
+	var gotError, gotDescription, gotState string
+	errorHandler := func(w http.ResponseWriter, r *http.Request, errorType, errorDesc, state string) {
+		gotError, gotDescription, gotState = errorType, errorDesc, state
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	handler := CodeExchangeHandler[*oidc.IDTokenClaims](nil, newJARMRelyingParty(WithErrorHandler(errorHandler), WithJWTResponseRequired()))
+
+	t.Run("unwrapped", func(t *testing.T) {
+		response := newJWTResponse(t, tu.ValidClientID, tu.ValidExpiration, url.Values{
+			"error": {"access_denied"}, "error_description": {"denied"}, "state": {"state1"},
+		})
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/callback?response="+response, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "access_denied", gotError)
+		assert.Equal(t, "denied", gotDescription)
+		assert.Equal(t, "state1", gotState)
+	})
+	t.Run("form post", func(t *testing.T) {
+		gotState = ""
+		response := newJWTResponse(t, tu.ValidClientID, tu.ValidExpiration, url.Values{"error": {"access_denied"}, "state": {"state2"}})
+		req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(url.Values{"response": {response}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler(httptest.NewRecorder(), req)
+		assert.Equal(t, "state2", gotState)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		response := newJWTResponse(t, "other", tu.ValidExpiration, url.Values{"code": {"code1"}})
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/callback?response="+response, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("plain response required", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/callback?code=code1&state=state1", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), ErrJWTResponseRequired.Error())
+	})
+}
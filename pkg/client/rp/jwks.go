@@ -2,13 +2,17 @@ package rp
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	jose "github.com/go-jose/go-jose/v4"
 
+	"github.com/lmindwarel/oidc/v3/pkg/background"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
 	"github.com/lmindwarel/oidc/v3/pkg/client"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
@@ -22,6 +26,25 @@ func NewRemoteKeySet(client *http.Client, jwksURL string, opts ...func(*remoteKe
 	return keyset
 }
 
+// NewRemoteKeySetContext returns a key set like [NewRemoteKeySet],
+// with the background fetches of the keys bound to ctx, see [KeySetContext].
+func NewRemoteKeySetContext(ctx context.Context, client *http.Client, jwksURL string, opts ...func(*remoteKeySet)) oidc.KeySet {
+	return NewRemoteKeySet(client, jwksURL, append(opts, KeySetContext(ctx))...)
+}
+
+// KeySetContext bounds the fetches of the remote keys to the lifetime of ctx,
+// e.g. of the application, so that cancelling it aborts a fetch in progress.
+//
+// A fetch is shared by all concurrent signature verifications and runs in the
+// background: it is not aborted when the context of the verification which
+// started it is cancelled, the verifications only stop waiting for it.
+// It runs in the [background.Group] of ctx, if any.
+func KeySetContext(ctx context.Context) func(set *remoteKeySet) {
+	return func(set *remoteKeySet) {
+		set.ctx = ctx
+	}
+}
+
 // SkipRemoteCheck will suppress checking for new remote keys if signature validation fails with cached keys
 // and no kid header is set in the JWT
 //
@@ -34,11 +57,62 @@ func SkipRemoteCheck() func(set *remoteKeySet) {
 	}
 }
 
+// CertificateRoots only accepts remote keys with an x5c certificate chain,
+// which verifies against the roots, such as those of a trust framework.
+// See [oidc.VerifyKeyCertificates].
+func CertificateRoots(roots *x509.CertPool) func(set *remoteKeySet) {
+	return func(set *remoteKeySet) {
+		set.certificateOpts = &x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+	}
+}
+
+// SharedKeyCache stores the fetched keys in the cache for the ttl,
+// so other instances sharing the cache load them from it,
+// instead of fetching them from the jwks_uri.
+// Keys are still fetched from the jwks_uri, if none of them verifies a signature.
+func SharedKeyCache(c cache.Cache, ttl time.Duration) func(set *remoteKeySet) {
+	return func(set *remoteKeySet) {
+		set.sharedCache = c
+		set.sharedTTL = ttl
+	}
+}
+
+// StaleKeyCache serves the keys of the shared cache of [SharedKeyCache] past
+// its ttl according to the policy, while they are fetched in the background or
+// if fetching them fails, see [cache.StalePolicy]. A nil policy disables it.
+func StaleKeyCache(policy *cache.StalePolicy) func(set *remoteKeySet) {
+	return func(set *remoteKeySet) {
+		set.stale = policy
+	}
+}
+
+// KeysFetcher returns the JSON Web Key Set document of the jwks_uri.
+type KeysFetcher func(ctx context.Context, jwksURL string) ([]byte, error)
+
+// FetchKeys replaces the HTTP request of the key set by the fetcher.
+// It allows key sets in environments without a usable [http.Client],
+// like Go compiled to js/wasm running on hosts with a fetch binding of their own,
+// or keys which are distributed by other means.
+func FetchKeys(fetch KeysFetcher) func(set *remoteKeySet) {
+	return func(set *remoteKeySet) {
+		set.fetch = fetch
+	}
+}
+
 type remoteKeySet struct {
+	ctx             context.Context
 	jwksURL         string
 	httpClient      *http.Client
+	fetch           KeysFetcher
 	defaultAlg      string
 	skipRemoteCheck bool
+	certificateOpts *x509.VerifyOptions
+	sharedCache     cache.Cache
+	sharedTTL       time.Duration
+	stale           *cache.StalePolicy
 
 	// guard all other fields
 	mu sync.Mutex
@@ -91,7 +165,7 @@ func (r *remoteKeySet) VerifySignature(ctx context.Context, jws *jose.JSONWebSig
 	if alg == "" {
 		alg = r.defaultAlg
 	}
-	payload, err := r.verifySignatureCached(jws, keyID, alg)
+	payload, err := r.verifySignatureCached(ctx, jws, keyID, alg)
 	if payload != nil {
 		return payload, nil
 	}
@@ -110,8 +184,8 @@ func (r *remoteKeySet) VerifySignature(ctx context.Context, jws *jose.JSONWebSig
 // - or both (JWT and JWK) kid are equal
 //
 // otherwise it will return no error (so remote keys will be loaded)
-func (r *remoteKeySet) verifySignatureCached(jws *jose.JSONWebSignature, keyID, alg string) ([]byte, error) {
-	keys := r.keysFromCache()
+func (r *remoteKeySet) verifySignatureCached(ctx context.Context, jws *jose.JSONWebSignature, keyID, alg string) ([]byte, error) {
+	keys := r.keysFromCache(ctx)
 	if len(keys) == 0 {
 		return nil, nil
 	}
@@ -120,7 +194,8 @@ func (r *remoteKeySet) verifySignatureCached(jws *jose.JSONWebSignature, keyID,
 		// no key / multiple found, try with remote keys
 		return nil, nil //nolint:nilerr
 	}
-	payload, err := jws.Verify(&key)
+	payload, err := r.verify(jws, &key)
+	oidc.ExplanationFromContext(ctx).Check("key", err, "kid", key.KeyID, "alg", key.Algorithm, "source", "cache")
 	if payload != nil {
 		return payload, nil
 	}
@@ -148,21 +223,92 @@ func (r *remoteKeySet) verifySignatureRemote(ctx context.Context, jws *jose.JSON
 	}
 	key, err := oidc.FindMatchingKey(keyID, oidc.KeyUseSignature, alg, keys...)
 	if err != nil {
+		oidc.ExplanationFromContext(ctx).Check("key", err, "kid", keyID, "alg", alg, "source", "remote")
 		return nil, fmt.Errorf("unable to validate signature: %w", err)
 	}
-	payload, err := jws.Verify(&key)
+	payload, err := r.verify(jws, &key)
+	oidc.ExplanationFromContext(ctx).Check("key", err, "kid", key.KeyID, "alg", key.Algorithm, "source", "remote")
 	if err != nil {
 		return nil, fmt.Errorf("signature verification failed: %w", err)
 	}
 	return payload, nil
 }
 
-func (r *remoteKeySet) keysFromCache() (keys []jose.JSONWebKey) {
+// verify verifies the signature with the key,
+// after its certificate chain, if CertificateRoots are set.
+func (r *remoteKeySet) verify(jws *jose.JSONWebSignature, key *jose.JSONWebKey) ([]byte, error) {
+	if r.certificateOpts != nil {
+		if err := oidc.VerifyKeyCertificates(key, *r.certificateOpts); err != nil {
+			return nil, err
+		}
+	}
+	return jws.Verify(key)
+}
+
+// keysFromCache returns the cached keys,
+// which are loaded from the shared cache, if set and none are cached yet.
+func (r *remoteKeySet) keysFromCache(ctx context.Context) (keys []jose.JSONWebKey) {
+	r.mu.Lock()
+	keys = r.cachedKeys
+	r.mu.Unlock()
+	if len(keys) > 0 || r.sharedCache == nil {
+		return keys
+	}
+	keySet, age, err := r.sharedKeys(ctx)
+	if err != nil || !r.stale.ServeWhileRevalidating(age) {
+		return nil
+	}
+	if age > 0 {
+		cache.ReportStaleness(ctx, cache.Staleness{Age: age, Revalidating: true})
+		r.revalidate(ctx)
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if len(r.cachedKeys) == 0 {
+		r.cachedKeys = keySet.Keys
+	}
 	return r.cachedKeys
 }
 
+// sharedKeys returns the keys of the shared cache and the time they are past its ttl.
+func (r *remoteKeySet) sharedKeys(ctx context.Context) (*jsonWebKeySet, time.Duration, error) {
+	value, age, err := r.stale.Get(ctx, r.sharedCache, r.sharedCacheKey())
+	if err != nil {
+		return nil, 0, err
+	}
+	keySet := new(jsonWebKeySet)
+	if err = json.Unmarshal(value, keySet); err != nil {
+		return nil, 0, err
+	}
+	return keySet, age, nil
+}
+
+// storeSharedKeys stores the fetched keys in the shared cache.
+func (r *remoteKeySet) storeSharedKeys(ctx context.Context, keys []jose.JSONWebKey) error {
+	if r.stale == nil {
+		return cache.SetJSON(ctx, r.sharedCache, r.sharedCacheKey(), jose.JSONWebKeySet{Keys: keys}, r.sharedTTL)
+	}
+	value, err := json.Marshal(jose.JSONWebKeySet{Keys: keys})
+	if err != nil {
+		return err
+	}
+	return r.stale.Set(ctx, r.sharedCache, r.sharedCacheKey(), cache.Fetched{Value: value, TTL: r.sharedTTL})
+}
+
+// revalidate fetches the keys in the background, unless already in flight.
+func (r *remoteKeySet) revalidate(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inflight == nil {
+		r.inflight = newInflight()
+		r.startUpdate(ctx)
+	}
+}
+
+func (r *remoteKeySet) sharedCacheKey() string {
+	return "jwks:" + r.jwksURL
+}
+
 // keysFromRemote syncs the key set from the remote set, records the values in the
 // cache, and returns the key set.
 func (r *remoteKeySet) keysFromRemote(ctx context.Context) ([]jose.JSONWebKey, error) {
@@ -178,7 +324,7 @@ func (r *remoteKeySet) keysFromRemote(ctx context.Context) ([]jose.JSONWebKey, e
 		// This goroutine has exclusive ownership over the current inflight
 		// request. It releases the resource by nil'ing the inflight field
 		// once the goroutine is done.
-		go r.updateKeys(ctx)
+		r.startUpdate(ctx)
 	}
 	inflight := r.inflight
 	r.mu.Unlock()
@@ -191,12 +337,51 @@ func (r *remoteKeySet) keysFromRemote(ctx context.Context) ([]jose.JSONWebKey, e
 	}
 }
 
-func (r *remoteKeySet) updateKeys(ctx context.Context) {
+// startUpdate updates the keys in the background, in the [background.Group]
+// of the context of the key set or else of ctx.
+func (r *remoteKeySet) startUpdate(ctx context.Context) {
+	fetchCtx, cancel := r.fetchContext(ctx)
+	groupCtx := ctx
+	if r.ctx != nil && background.Supervised(r.ctx) {
+		groupCtx = r.ctx
+	}
+	background.Go(groupCtx, func() error {
+		return r.updateKeys(fetchCtx, cancel)
+	})
+}
+
+// fetchContext returns the context of a shared fetch, which keeps the values
+// of ctx, such as the trace span, but is only cancelled with the context of the key set
+// or of the [background.Group] of ctx.
+func (r *remoteKeySet) fetchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := background.Detach(ctx)
+	if r.ctx == nil {
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(r.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (r *remoteKeySet) updateKeys(ctx context.Context, cancel context.CancelFunc) error {
+	defer cancel()
 	ctx, span := client.Tracer.Start(ctx, "updateKeys")
 	defer span.End()
 
 	// Sync keys and finish inflight when that's done.
 	keys, err := r.fetchRemoteKeys(ctx)
+	if err == nil && r.sharedCache != nil {
+		// the keys are fetched again by the instances, if storing them fails
+		_ = r.storeSharedKeys(ctx, keys)
+	}
+	if err != nil && r.stale != nil && r.sharedCache != nil {
+		if keySet, age, sharedErr := r.sharedKeys(ctx); sharedErr == nil && r.stale.ServeIfError(age) {
+			cache.ReportStaleness(ctx, cache.Staleness{Age: age, Err: err})
+			keys, err = keySet.Keys, nil
+		}
+	}
 
 	r.inflight.done(keys, err)
 
@@ -211,12 +396,25 @@ func (r *remoteKeySet) updateKeys(ctx context.Context) {
 
 	// Free inflight so a different request can run.
 	r.inflight = nil
+	return err
 }
 
 func (r *remoteKeySet) fetchRemoteKeys(ctx context.Context) ([]jose.JSONWebKey, error) {
 	ctx, span := client.Tracer.Start(ctx, "fetchRemoteKeys")
 	defer span.End()
+	ctx = client.WithOperation(ctx, client.OperationJWKS)
 
+	if r.fetch != nil {
+		body, err := r.fetch(ctx, r.jwksURL)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to get keys: %w", err)
+		}
+		keySet := new(jsonWebKeySet)
+		if err = json.Unmarshal(body, keySet); err != nil {
+			return nil, fmt.Errorf("oidc: failed to unmarshal keys: %w", err)
+		}
+		return keySet.Keys, nil
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", r.jwksURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: can't create request: %v", err)
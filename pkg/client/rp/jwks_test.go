@@ -0,0 +1,160 @@
+package rp
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteKeySet_CertificateRoots(t *testing.T) {
+	root, leaf, err := tu.NewCertificateChain()
+	require.NoError(t, err)
+	otherRoot, _, err := tu.NewCertificateChain()
+	require.NoError(t, err)
+
+	withCerts := tu.WebKey.Public()
+	withCerts.Certificates = []*x509.Certificate{leaf}
+	idToken, _ := tu.ValidIDToken()
+
+	tests := []struct {
+		name    string
+		key     jose.JSONWebKey
+		roots   *x509.Certificate
+		wantErr bool
+	}{
+		{
+			name:  "trusted",
+			key:   withCerts,
+			roots: root,
+		},
+		{
+			name:    "untrusted",
+			key:     withCerts,
+			roots:   otherRoot,
+			wantErr: true,
+		},
+		{
+			name:    "without certificates",
+			key:     tu.WebKey.Public(),
+			roots:   root,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				httphelper.MarshalJSON(w, &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{tt.key}})
+			}))
+			defer server.Close()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(tt.roots)
+			keySet := NewRemoteKeySet(server.Client(), server.URL, CertificateRoots(roots))
+
+			jws, err := jose.ParseSigned(idToken, []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+			require.NoError(t, err)
+			_, err = keySet.VerifySignature(context.Background(), jws)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRemoteKeySet_SharedKeyCache(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		httphelper.MarshalJSON(w, &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{tu.WebKey.Public()}})
+	}))
+	defer server.Close()
+	idToken, _ := tu.ValidIDToken()
+	shared := cache.NewMemory()
+
+	// instances of a deployment share the keys fetched by the first one
+	for i := 0; i < 3; i++ {
+		keySet := NewRemoteKeySet(server.Client(), server.URL, SharedKeyCache(shared, time.Hour))
+		jws, err := jose.ParseSigned(idToken, []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+		require.NoError(t, err)
+		_, err = keySet.VerifySignature(context.Background(), jws)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestRemoteKeySet_FetchKeys(t *testing.T) {
+	idToken, _ := tu.ValidIDToken()
+	jws, err := jose.ParseSigned(idToken, []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+	require.NoError(t, err)
+
+	var fetchedURL string
+	keySet := NewRemoteKeySet(nil, "https://issuer.example.com/keys", FetchKeys(func(ctx context.Context, jwksURL string) ([]byte, error) {
+		fetchedURL = jwksURL
+		return json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{tu.WebKey.Public()}})
+	}))
+	_, err = keySet.VerifySignature(context.Background(), jws)
+	require.NoError(t, err)
+	assert.Equal(t, "https://issuer.example.com/keys", fetchedURL)
+
+	errFetch := errors.New("fetch failed")
+	keySet = NewRemoteKeySet(nil, "https://issuer.example.com/keys", FetchKeys(func(context.Context, string) ([]byte, error) {
+		return nil, errFetch
+	}))
+	_, err = keySet.VerifySignature(context.Background(), jws)
+	assert.ErrorIs(t, err, errFetch)
+}
+
+func TestRemoteKeySet_KeySetContext(t *testing.T) {
+	idToken, _ := tu.ValidIDToken()
+	jws, err := jose.ParseSigned(idToken, []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	keySet := NewRemoteKeySetContext(context.Background(), nil, "https://issuer.example.com/keys", FetchKeys(func(ctx context.Context, _ string) ([]byte, error) {
+		fetches.Add(1)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-release:
+		}
+		return json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{tu.WebKey.Public()}})
+	}))
+
+	// the cancelled verification stops waiting, but the shared fetch completes
+	verifyCtx, cancelVerify := context.WithCancel(context.Background())
+	cancelVerify()
+	_, err = keySet.VerifySignature(verifyCtx, jws)
+	assert.ErrorIs(t, err, context.Canceled)
+	close(release)
+	require.Eventually(t, func() bool {
+		_, err := keySet.VerifySignature(context.Background(), jws)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// cancelling the context of the key set aborts the fetch
+	setCtx, cancelSet := context.WithCancel(context.Background())
+	keySet = NewRemoteKeySetContext(setCtx, nil, "https://issuer.example.com/keys", FetchKeys(func(ctx context.Context, _ string) ([]byte, error) {
+		cancelSet()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+	_, err = keySet.VerifySignature(context.Background(), jws)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/zitadel/logging"
+	"github.com/lmindwarel/oidc/v3/internal/logctx"
 )
 
 func logCtxWithRPData(ctx context.Context, rp RelyingParty, attrs ...any) context.Context {
@@ -13,5 +13,5 @@ func logCtxWithRPData(ctx context.Context, rp RelyingParty, attrs ...any) contex
 		return ctx
 	}
 	logger = logger.With(slog.Group("rp", attrs...))
-	return logging.ToContext(ctx, logger)
+	return logctx.ToContext(ctx, logger)
 }
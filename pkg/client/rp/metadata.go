@@ -0,0 +1,280 @@
+package rp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/background"
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ErrNotDiscovered is returned by [RefreshDiscovery] for a RelyingParty
+// created without discovery.
+var ErrNotDiscovered = errors.New("relying party was not created with discovery")
+
+// MetadataChange describes how the metadata of the OP changed
+// since the previous discovery, see [RefreshDiscovery].
+type MetadataChange struct {
+	Previous *oidc.DiscoveryConfiguration
+	Current  *oidc.DiscoveryConfiguration
+
+	// Endpoints that were moved, added or removed.
+	Endpoints []EndpointChange
+
+	RemovedIDTokenSigningAlgs   []string
+	RemovedAuthMethods          []oidc.AuthMethod
+	RemovedCodeChallengeMethods []oidc.CodeChallengeMethod
+
+	// Unsupported wraps [ErrCapabilityMissing] for each feature
+	// the configuration of the RelyingParty relies on, which
+	// the OP supported before, but no longer does.
+	// Calls to the OP using them will likely fail.
+	Unsupported error
+}
+
+// EndpointChange is an endpoint of the OP which was moved, added or removed.
+type EndpointChange struct {
+	// Name is the name of the discovery metadata, like token_endpoint.
+	Name     string
+	Previous string
+	Current  string
+}
+
+// MetadataChangeHandler is called with the changes of the metadata
+// of the OP, after they are applied to the RelyingParty.
+type MetadataChangeHandler func(ctx context.Context, change *MetadataChange)
+
+// WithMetadataChangeHandler calls handler for every change of the metadata
+// of the OP found by [RefreshDiscovery] or [WithDiscoveryRefresh].
+func WithMetadataChangeHandler(handler MetadataChangeHandler) Option {
+	return func(rp *relyingParty) error {
+		rp.metadataHandler = handler
+		return nil
+	}
+}
+
+// WithDiscoveryRefresh refreshes the discovery of the OP in the background
+// every interval, see [RefreshDiscovery], until the context passed to
+// [NewRelyingPartyOIDC] is done. The goroutine runs in the [background.Group]
+// of the context, if any. Failed refreshes are logged and retried
+// at the next interval.
+func WithDiscoveryRefresh(interval time.Duration) Option {
+	return func(rp *relyingParty) error {
+		rp.refreshInterval = interval
+		return nil
+	}
+}
+
+type discoveryRefresher interface {
+	refreshDiscovery(ctx context.Context) (*MetadataChange, error)
+}
+
+// RefreshDiscovery fetches the discovery document of the OP again and applies
+// rotated endpoints and algorithms to the RelyingParty.
+// Features the configuration of the RelyingParty relies on, like its client
+// authentication method, the signing algorithms of the ID Token, PKCE,
+// or [WithRequiredCapabilities], are re-validated and reported in
+// [MetadataChange.Unsupported] and logged as warnings, when the OP no longer
+// supports them.
+// The change is passed to the [WithMetadataChangeHandler] and returned;
+// it is nil if the metadata did not change.
+func RefreshDiscovery(ctx context.Context, rp RelyingParty) (*MetadataChange, error) {
+	ctx, span := client.Tracer.Start(ctx, "RefreshDiscovery")
+	defer span.End()
+
+	ctx = logCtxWithRPData(ctx, rp, "function", "RefreshDiscovery")
+	refresher, ok := rp.(discoveryRefresher)
+	if !ok {
+		return nil, ErrNotDiscovered
+	}
+	return refresher.refreshDiscovery(ctx)
+}
+
+func (rp *relyingParty) refreshDiscovery(ctx context.Context) (*MetadataChange, error) {
+	rp.refreshMu.Lock()
+	defer rp.refreshMu.Unlock()
+
+	previous := rp.discoveryConfiguration()
+	if previous == nil {
+		return nil, ErrNotDiscovered
+	}
+	current, err := rp.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	requirements := rp.configuredRequirements()
+	before := Capabilities(rp)
+	rp.applyDiscovery(current)
+	after := Capabilities(rp)
+
+	change := &MetadataChange{
+		Previous:                    previous,
+		Current:                     current,
+		Endpoints:                   changedEndpoints(previous, current),
+		RemovedIDTokenSigningAlgs:   removed(before.IDTokenSigningAlgs, after.IDTokenSigningAlgs),
+		RemovedAuthMethods:          removed(before.TokenEndpointAuthMethods, after.TokenEndpointAuthMethods),
+		RemovedCodeChallengeMethods: removed(before.CodeChallengeMethods, after.CodeChallengeMethods),
+	}
+	var errs []error
+	for _, requirement := range requirements {
+		if requirement(before) == nil {
+			errs = append(errs, requirement(after))
+		}
+	}
+	change.Unsupported = errors.Join(errs...)
+	if len(change.Endpoints) == 0 && len(change.RemovedIDTokenSigningAlgs) == 0 &&
+		len(change.RemovedAuthMethods) == 0 && len(change.RemovedCodeChallengeMethods) == 0 &&
+		change.Unsupported == nil {
+		return nil, nil
+	}
+	if logger, ok := rp.Logger(ctx); ok && change.Unsupported != nil {
+		logger.WarnContext(ctx, "configuration no longer supported by the provider", "error", change.Unsupported)
+	}
+	if rp.metadataHandler != nil {
+		rp.metadataHandler(ctx, change)
+	}
+	return change, nil
+}
+
+// applyDiscovery replaces the endpoints of the rp, including those of
+// the token endpoint of DPoP and the keys of the ID Token verifier.
+func (rp *relyingParty) applyDiscovery(config *oidc.DiscoveryConfiguration) {
+	rp.metadataMu.Lock()
+	defer rp.metadataMu.Unlock()
+
+	endpoints := GetEndpoints(client.MTLSEndpoints(rp.httpClient, config))
+	endpoints.Endpoint.AuthStyle = rp.oauthAuthStyle
+	if transport, ok := rp.httpClient.Transport.(*client.DPoPTransport); ok && endpoints.TokenURL != rp.endpoints.TokenURL {
+		dpopTransport := *transport
+		dpopTransport.Endpoint = endpoints.TokenURL
+		httpClient := *rp.httpClient
+		httpClient.Transport = &dpopTransport
+		rp.httpClient = &httpClient
+	}
+	if rp.idTokenVerifier != nil && (endpoints.JKWsURL != rp.endpoints.JKWsURL || rp.useSigningAlgsFromDiscovery) {
+		verifier := *rp.idTokenVerifier
+		if endpoints.JKWsURL != rp.endpoints.JKWsURL {
+			verifier.KeySet = NewRemoteKeySet(rp.httpClient, endpoints.JKWsURL, rp.keySetOpts...)
+		}
+		if rp.useSigningAlgsFromDiscovery {
+			verifier.SupportedSignAlgs = config.IDTokenSigningAlgValuesSupported
+		}
+		rp.idTokenVerifier = &verifier
+	}
+	oauthConfig := *rp.oauthConfig
+	oauthConfig.Endpoint = endpoints.Endpoint
+	rp.oauthConfig = &oauthConfig
+	rp.endpoints = endpoints
+	rp.discovery = config
+}
+
+// configuredRequirements returns the features of the OP
+// the configuration of the rp relies on.
+func (rp *relyingParty) configuredRequirements() []Requirement {
+	requirements := []Requirement{RequireAuthMethod(rp.authMethods()...)}
+	if !rp.useSigningAlgsFromDiscovery {
+		algs := rp.IDTokenVerifier().SupportedSignAlgs
+		if len(algs) == 0 {
+			// the defaults of the verifier
+			algs = []string{"RS256", "ES256", "PS256"}
+		}
+		requirements = append(requirements, RequireIDTokenSigningAlg(algs...))
+	}
+	if rp.pkce {
+		requirements = append(requirements, RequirePKCE(oidc.CodeChallengeMethodS256))
+	}
+	if !rp.par {
+		requirements = append(requirements, func(c *ProviderCapabilities) error {
+			if c.PushedAuthorizationRequired {
+				return capabilityMissing("auth requests without pushed authorization")
+			}
+			return nil
+		})
+	}
+	return append(requirements, rp.requirements...)
+}
+
+// authMethods returns the client authentication methods the rp may use at the token endpoint.
+func (rp *relyingParty) authMethods() []oidc.AuthMethod {
+	clientSecret := rp.OAuthConfig().ClientSecret
+	switch {
+	case rp.signer != nil:
+		return []oidc.AuthMethod{oidc.AuthMethodPrivateKeyJWT}
+	case clientSecret == "" && client.HasClientCertificate(rp.HttpClient()):
+		return []oidc.AuthMethod{oidc.AuthMethodTLSClientAuth, oidc.AuthMethodSelfSignedTLSClientAuth}
+	case clientSecret == "":
+		return []oidc.AuthMethod{oidc.AuthMethodNone}
+	}
+	switch rp.oauthAuthStyle {
+	case oauth2.AuthStyleInParams:
+		return []oidc.AuthMethod{oidc.AuthMethodPost}
+	case oauth2.AuthStyleInHeader:
+		return []oidc.AuthMethod{oidc.AuthMethodBasic}
+	default:
+		return []oidc.AuthMethod{oidc.AuthMethodBasic, oidc.AuthMethodPost}
+	}
+}
+
+var metadataEndpoints = []struct {
+	name     string
+	endpoint func(*oidc.DiscoveryConfiguration) string
+}{
+	{"authorization_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.AuthorizationEndpoint }},
+	{"token_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.TokenEndpoint }},
+	{"userinfo_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.UserinfoEndpoint }},
+	{"jwks_uri", func(c *oidc.DiscoveryConfiguration) string { return c.JwksURI }},
+	{"end_session_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.EndSessionEndpoint }},
+	{"revocation_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.RevocationEndpoint }},
+	{"introspection_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.IntrospectionEndpoint }},
+	{"device_authorization_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.DeviceAuthorizationEndpoint }},
+	{"pushed_authorization_request_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.PushedAuthorizationRequestEndpoint }},
+	{"backchannel_authentication_endpoint", func(c *oidc.DiscoveryConfiguration) string { return c.BackchannelAuthenticationEndpoint }},
+}
+
+func changedEndpoints(previous, current *oidc.DiscoveryConfiguration) []EndpointChange {
+	var changes []EndpointChange
+	for _, e := range metadataEndpoints {
+		if p, c := e.endpoint(previous), e.endpoint(current); p != c {
+			changes = append(changes, EndpointChange{Name: e.name, Previous: p, Current: c})
+		}
+	}
+	return changes
+}
+
+// removed returns the values of previous, which are not in current.
+func removed[T comparable](previous, current []T) []T {
+	var values []T
+	for _, value := range previous {
+		if !slices.Contains(current, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (rp *relyingParty) startDiscoveryRefresh(ctx context.Context) {
+	if rp.refreshInterval <= 0 {
+		return
+	}
+	background.Go(ctx, func() error {
+		ticker := time.NewTicker(rp.refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			if _, err := rp.refreshDiscovery(ctx); err != nil {
+				if logger, ok := rp.Logger(ctx); ok {
+					logger.WarnContext(ctx, "discovery refresh failed", "error", err)
+				}
+			}
+		}
+	})
+}
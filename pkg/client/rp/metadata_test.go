@@ -0,0 +1,81 @@
+package rp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshDiscovery(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		config oidc.DiscoveryConfiguration
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(&config)
+	}))
+	defer server.Close()
+	config = oidc.DiscoveryConfiguration{
+		Issuer:                            server.URL,
+		TokenEndpoint:                     server.URL + "/oauth/token",
+		JwksURI:                           server.URL + "/keys",
+		TokenEndpointAuthMethodsSupported: []oidc.AuthMethod{oidc.AuthMethodBasic, oidc.AuthMethodPost},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256", "ES256"},
+	}
+
+	var changes []*MetadataChange
+	relyingParty, err := NewRelyingPartyOIDC(context.Background(), server.URL, "client", "secret", "", nil,
+		WithAuthStyle(oauth2.AuthStyleInParams),
+		WithVerifierOpts(WithSupportedSigningAlgorithms("ES256")),
+		WithMetadataChangeHandler(func(_ context.Context, change *MetadataChange) {
+			changes = append(changes, change)
+		}),
+	)
+	require.NoError(t, err)
+
+	change, err := RefreshDiscovery(context.Background(), relyingParty)
+	require.NoError(t, err)
+	assert.Nil(t, change, "unchanged")
+
+	mu.Lock()
+	config.TokenEndpoint = server.URL + "/v2/token"
+	config.JwksURI = server.URL + "/v2/keys"
+	config.TokenEndpointAuthMethodsSupported = []oidc.AuthMethod{oidc.AuthMethodBasic}
+	config.IDTokenSigningAlgValuesSupported = []string{"RS256"}
+	mu.Unlock()
+
+	change, err = RefreshDiscovery(context.Background(), relyingParty)
+	require.NoError(t, err)
+	require.NotNil(t, change)
+	assert.Equal(t, []*MetadataChange{change}, changes)
+	assert.Equal(t, []EndpointChange{
+		{Name: "token_endpoint", Previous: server.URL + "/oauth/token", Current: server.URL + "/v2/token"},
+		{Name: "jwks_uri", Previous: server.URL + "/keys", Current: server.URL + "/v2/keys"},
+	}, change.Endpoints)
+	assert.Equal(t, []oidc.AuthMethod{oidc.AuthMethodPost}, change.RemovedAuthMethods)
+	assert.Equal(t, []string{"ES256"}, change.RemovedIDTokenSigningAlgs)
+	assert.ErrorIs(t, change.Unsupported, ErrCapabilityMissing)
+	assert.ErrorContains(t, change.Unsupported, "client_secret_post")
+	assert.ErrorContains(t, change.Unsupported, "ES256")
+	assert.Equal(t, server.URL+"/v2/token", relyingParty.OAuthConfig().Endpoint.TokenURL)
+	assert.Equal(t, oauth2.AuthStyleInParams, relyingParty.OAuthConfig().Endpoint.AuthStyle)
+
+	_, err = RefreshDiscovery(context.Background(), relyingParty)
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+
+	oauthRP, err := NewRelyingPartyOAuth(&oauth2.Config{ClientID: "client"})
+	require.NoError(t, err)
+	_, err = RefreshDiscovery(context.Background(), oauthRP)
+	assert.ErrorIs(t, err, ErrNotDiscovered)
+}
@@ -0,0 +1,108 @@
+package rp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ErrPAREndpointNotSet is returned by [PushedAuthURL], if the OP
+// does not advertise a Pushed Authorization Request endpoint
+// and none was set with [WithPAR].
+var ErrPAREndpointNotSet = errors.New("pushed authorization request endpoint not set")
+
+// WithPAR pushes the auth requests of [AuthURLHandler] to the Pushed Authorization
+// Request endpoint of the OP (RFC 9126), before redirecting the user agent to the
+// authorization endpoint with the returned request_uri, see [PushedAuthURL].
+// An empty endpoint uses the pushed_authorization_request_endpoint of the discovery.
+func WithPAR(endpoint string) Option {
+	return func(rp *relyingParty) error {
+		rp.par = true
+		rp.parEndpoint = endpoint
+		return nil
+	}
+}
+
+type pushedAuthorizer interface {
+	pushedAuthorizationEndpoint() (endpoint string, enabled bool)
+}
+
+func (rp *relyingParty) pushedAuthorizationEndpoint() (string, bool) {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
+	if rp.parEndpoint != "" {
+		return rp.parEndpoint, rp.par
+	}
+	return rp.endpoints.PushedAuthorizationURL, rp.par
+}
+
+func pushedAuthorizationEnabled(rp RelyingParty) bool {
+	p, ok := rp.(pushedAuthorizer)
+	if !ok {
+		return false
+	}
+	_, enabled := p.pushedAuthorizationEndpoint()
+	return enabled
+}
+
+// PushedAuthURL pushes the parameters of the auth request, which [AuthURL] would encode
+// into the URL, to the Pushed Authorization Request endpoint of the OP
+// and returns the URL of the authorization endpoint referencing them by the request_uri.
+// The client authenticates as at the token endpoint.
+func PushedAuthURL(ctx context.Context, state string, rp RelyingParty, opts ...AuthURLOpt) (string, error) {
+	ctx, span := client.Tracer.Start(ctx, "PushedAuthURL")
+	defer span.End()
+
+	ctx = logCtxWithRPData(ctx, rp, "function", "PushedAuthURL")
+	var endpoint string
+	if p, ok := rp.(pushedAuthorizer); ok {
+		endpoint, _ = p.pushedAuthorizationEndpoint()
+	}
+	if endpoint == "" {
+		return "", ErrPAREndpointNotSet
+	}
+	authURL, err := url.Parse(AuthURL(state, rp, opts...))
+	if err != nil {
+		return "", err
+	}
+	form := authURL.Query()
+	config := rp.OAuthConfig()
+	if signer := rp.Signer(); signer != nil {
+		assertion, err := client.SignedJWTProfileAssertion(config.ClientID, []string{rp.Issuer()}, time.Hour, signer)
+		if err != nil {
+			return "", fmt.Errorf("failed to build assertion: %w", err)
+		}
+		form.Set("client_assertion", assertion)
+		form.Set("client_assertion_type", oidc.ClientAssertionTypeJWTAssertion)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if rp.Signer() == nil && config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+	resp := new(oidc.PushedAuthorizationResponse)
+	if err = httphelper.HttpRequest(rp.HttpClient(), req, resp); err != nil {
+		return "", fmt.Errorf("pushed authorization request: %w", err)
+	}
+	// only client_id and request_uri are sent to the authorization endpoint (RFC 9126, section 4)
+	pushedURL, err := url.Parse(config.Endpoint.AuthURL)
+	if err != nil {
+		return "", err
+	}
+	query := pushedURL.Query()
+	query.Set("client_id", config.ClientID)
+	query.Set("request_uri", resp.RequestURI)
+	pushedURL.RawQuery = query.Encode()
+	return pushedURL.String(), nil
+}
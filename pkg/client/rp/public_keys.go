@@ -0,0 +1,119 @@
+package rp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+)
+
+var ErrNoPublicKey = errors.New("key has no public key to publish")
+
+// PublicKeySet serves the public keys of the relying party as JSON Web Key Set,
+// so the OP can verify private_key_jwt client assertions and request objects
+// with the keys of the jwks_uri registered for the client.
+// Keys can be rotated while the set is served, it is safe for concurrent use.
+type PublicKeySet struct {
+	mu   sync.RWMutex
+	keys []jose.JSONWebKey
+}
+
+// NewPublicKeySet returns a set publishing the public part of the keys.
+// Private keys may be passed, only their public part is ever served.
+func NewPublicKeySet(keys ...jose.JSONWebKey) (*PublicKeySet, error) {
+	set := new(PublicKeySet)
+	for _, key := range keys {
+		public, err := publicWebKey(key)
+		if err != nil {
+			return nil, err
+		}
+		set.keys = append(set.keys, public)
+	}
+	return set, nil
+}
+
+// PublicKeyFromPrivateKeyByte returns the public JSON Web Key of a PEM encoded private key,
+// as used by [client.NewSignerFromPrivateKeyByte] to sign with the keyID.
+func PublicKeyFromPrivateKeyByte(key []byte, keyID string) (jose.JSONWebKey, error) {
+	privateKey, algorithm, err := crypto.BytesToPrivateKey(key)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+	return publicWebKey(jose.JSONWebKey{
+		Key:       privateKey,
+		KeyID:     keyID,
+		Algorithm: string(algorithm),
+		Use:       "sig",
+	})
+}
+
+func publicWebKey(key jose.JSONWebKey) (jose.JSONWebKey, error) {
+	public := key.Public()
+	if !public.Valid() {
+		return jose.JSONWebKey{}, fmt.Errorf("%w: %q", ErrNoPublicKey, key.KeyID)
+	}
+	return public, nil
+}
+
+// Rotate publishes the key in front of the current keys and retains
+// at most retain of the previous ones, so assertions signed with them
+// keep verifying until they are expired.
+// A previous key with the same key ID is replaced.
+func (s *PublicKeySet) Rotate(key jose.JSONWebKey, retain int) error {
+	public, err := publicWebKey(key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []jose.JSONWebKey{public}
+	for _, previous := range s.keys {
+		if len(keys) > retain {
+			break
+		}
+		if previous.KeyID != public.KeyID {
+			keys = append(keys, previous)
+		}
+	}
+	s.keys = keys
+	return nil
+}
+
+// Remove stops publishing the key with the key ID.
+func (s *PublicKeySet) Remove(keyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]jose.JSONWebKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key.KeyID != keyID {
+			keys = append(keys, key)
+		}
+	}
+	s.keys = keys
+}
+
+// Keys returns the currently published keys, the most recent first.
+func (s *PublicKeySet) Keys() []jose.JSONWebKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]jose.JSONWebKey(nil), s.keys...)
+}
+
+// ServeHTTP writes the published keys as JSON Web Key Set,
+// so the set can be mounted as the jwks_uri of the client.
+func (s *PublicKeySet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	httphelper.MarshalJSON(w, &jose.JSONWebKeySet{Keys: s.Keys()})
+}
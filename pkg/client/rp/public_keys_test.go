@@ -0,0 +1,57 @@
+package rp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+)
+
+func TestPublicKeySet(t *testing.T) {
+	set, err := NewPublicKeySet(tu.WebKey)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	set.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got.Keys, 1)
+	assert.True(t, got.Keys[0].IsPublic(), "only the public key is served")
+	assert.Equal(t, tu.WebKey.KeyID, got.Keys[0].KeyID)
+
+	next := tu.WebKey
+	next.KeyID = "next"
+	require.NoError(t, set.Rotate(next, 1))
+	assert.Equal(t, []string{"next", tu.WebKey.KeyID}, keyIDs(set.Keys()))
+
+	last := tu.WebKey
+	last.KeyID = "last"
+	require.NoError(t, set.Rotate(last, 1))
+	assert.Equal(t, []string{"last", "next"}, keyIDs(set.Keys()))
+
+	set.Remove("next")
+	assert.Equal(t, []string{"last"}, keyIDs(set.Keys()))
+
+	err = set.Rotate(jose.JSONWebKey{Key: []byte("secret"), KeyID: "hmac"}, 1)
+	assert.ErrorIs(t, err, ErrNoPublicKey)
+
+	rec = httptest.NewRecorder()
+	set.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/keys", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func keyIDs(keys []jose.JSONWebKey) []string {
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.KeyID
+	}
+	return ids
+}
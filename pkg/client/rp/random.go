@@ -0,0 +1,43 @@
+package rp
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+// 32 bytes result in a 43 character code verifier,
+// the minimum length of RFC 7636, section 4.1.
+const codeVerifierBytes = 32
+
+// 16 bytes gives 128 bit of entropy.
+const stateBytes = 16
+
+// WithRandom sets the random source of the PKCE code verifiers and the state
+// of [SilentAuth]. It must be a cryptographically secure source, like a FIPS 140
+// validated DRBG. [crypto.DeterministicRandom] makes the values reproducible in tests.
+// The state of [AuthURLHandler] is generated by its stateFn.
+func WithRandom(random io.Reader) Option {
+	return func(rp *relyingParty) error {
+		rp.random = random
+		return nil
+	}
+}
+
+func (rp *relyingParty) Random() io.Reader {
+	return rp.random
+}
+
+type randomGetter interface {
+	Random() io.Reader
+}
+
+// randomString returns nBytes of the random source of the rp, base64url encoded.
+func randomString(rp RelyingParty, nBytes int) (string, error) {
+	random := io.Reader(rand.Reader)
+	if getter, ok := rp.(randomGetter); ok && getter.Random() != nil {
+		random = getter.Random()
+	}
+	return crypto.RandomString(random, nBytes)
+}
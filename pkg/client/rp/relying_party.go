@@ -2,22 +2,28 @@ package rp
 
 import (
 	"context"
-	"encoding/base64"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 
+	"github.com/lmindwarel/oidc/v3/internal/logctx"
+	"github.com/lmindwarel/oidc/v3/internal/security"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
 	"github.com/lmindwarel/oidc/v3/pkg/client"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
-	"github.com/zitadel/logging"
 )
 
 const (
@@ -93,10 +99,16 @@ type relyingParty struct {
 	issuer                      string
 	DiscoveryEndpoint           string
 	endpoints                   Endpoints
+	discovery                   *oidc.DiscoveryConfiguration
+	requirements                []Requirement
+	refreshInterval             time.Duration
+	metadataHandler             MetadataChangeHandler
 	oauthConfig                 *oauth2.Config
 	oauth2Only                  bool
 	pkce                        bool
 	useSigningAlgsFromDiscovery bool
+	insecure                    bool
+	signedMetadata              *signedMetadataTrust
 
 	httpClient    *http.Client
 	cookieHandler *httphelper.CookieHandler
@@ -107,11 +119,33 @@ type relyingParty struct {
 	unauthorizedHandler func(http.ResponseWriter, *http.Request, string, string)
 	idTokenVerifier     *IDTokenVerifier
 	verifierOpts        []VerifierOption
+	keySetOpts          []func(*remoteKeySet)
+	sharedCache         cache.Cache
+	sharedCacheTTL      time.Duration
+	userinfoCache       cache.Cache
+	userinfoTTL         time.Duration
+	stale               *cache.StalePolicy
+	retryPolicy         *httphelper.RetryPolicy
+	dpop                client.DPoPProofer
+	telemetry           *client.Telemetry
+	instrumentation     *client.Instrumentation
+	claimsTransformers  []ClaimsTransformer
+	par                 bool
+	parEndpoint         string
+	jwtResponse         jwtResponse
+	statelessState      *StatelessStateConfig
 	signer              jose.Signer
 	logger              *slog.Logger
+	random              io.Reader
+
+	// guards the discovered metadata, see [RefreshDiscovery]
+	metadataMu sync.RWMutex
+	refreshMu  sync.Mutex
 }
 
 func (rp *relyingParty) OAuthConfig() *oauth2.Config {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
 	return rp.oauthConfig
 }
 
@@ -128,6 +162,8 @@ func (rp *relyingParty) CookieHandler() *httphelper.CookieHandler {
 }
 
 func (rp *relyingParty) HttpClient() *http.Client {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
 	return rp.httpClient
 }
 
@@ -140,24 +176,35 @@ func (rp *relyingParty) Signer() jose.Signer {
 }
 
 func (rp *relyingParty) UserinfoEndpoint() string {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
 	return rp.endpoints.UserinfoURL
 }
 
 func (rp *relyingParty) GetDeviceAuthorizationEndpoint() string {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
 	return rp.endpoints.DeviceAuthorizationURL
 }
 
 func (rp *relyingParty) GetEndSessionEndpoint() string {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
 	return rp.endpoints.EndSessionURL
 }
 
 func (rp *relyingParty) GetRevokeEndpoint() string {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
 	return rp.endpoints.RevokeURL
 }
 
 func (rp *relyingParty) IDTokenVerifier() *IDTokenVerifier {
+	rp.metadataMu.Lock()
+	defer rp.metadataMu.Unlock()
 	if rp.idTokenVerifier == nil {
-		rp.idTokenVerifier = NewIDTokenVerifier(rp.issuer, rp.oauthConfig.ClientID, NewRemoteKeySet(rp.httpClient, rp.endpoints.JKWsURL), rp.verifierOpts...)
+		rp.idTokenVerifier = NewIDTokenVerifier(rp.issuer, rp.oauthConfig.ClientID, NewRemoteKeySet(rp.httpClient, rp.endpoints.JKWsURL, rp.keySetOpts...), rp.verifierOpts...)
+		rp.idTokenVerifier.AllowInsecure = rp.insecure
 	}
 	return rp.idTokenVerifier
 }
@@ -176,8 +223,12 @@ func (rp *relyingParty) UnauthorizedHandler() func(http.ResponseWriter, *http.Re
 	return rp.unauthorizedHandler
 }
 
+func (rp *relyingParty) ClaimsTransformers() []ClaimsTransformer {
+	return rp.claimsTransformers
+}
+
 func (rp *relyingParty) Logger(ctx context.Context) (logger *slog.Logger, ok bool) {
-	logger, ok = logging.FromContext(ctx)
+	logger, ok = logctx.FromContext(ctx)
 	if ok {
 		return logger, ok
 	}
@@ -201,8 +252,13 @@ func NewRelyingPartyOAuth(config *oauth2.Config, options ...Option) (RelyingPart
 			return nil, err
 		}
 	}
+	rp.bindTelemetry()
+	if err := rp.bindInstrumentation(); err != nil {
+		return nil, err
+	}
 
 	rp.oauthConfig.Endpoint.AuthStyle = rp.oauthAuthStyle
+	rp.bindDPoP()
 
 	// avoid races by calling these early
 	_ = rp.IDTokenVerifier()     // sets idTokenVerifier
@@ -234,29 +290,59 @@ func NewRelyingPartyOIDC(ctx context.Context, issuer, clientID, clientSecret, re
 			return nil, err
 		}
 	}
+	rp.bindTelemetry()
+	if err := rp.bindInstrumentation(); err != nil {
+		return nil, err
+	}
 	ctx = logCtxWithRPData(ctx, rp, "function", "NewRelyingPartyOIDC")
-	discoveryConfiguration, err := client.Discover(ctx, rp.issuer, rp.httpClient, rp.DiscoveryEndpoint)
+	discoveryConfiguration, err := rp.discover(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if rp.insecure {
+		// a matched template or loopback alias is replaced by the actual issuer
+		rp.issuer = discoveryConfiguration.Issuer
+	}
 	if rp.useSigningAlgsFromDiscovery {
 		rp.verifierOpts = append(rp.verifierOpts, WithSupportedSigningAlgorithms(discoveryConfiguration.IDTokenSigningAlgValuesSupported...))
 	}
-	endpoints := GetEndpoints(discoveryConfiguration)
+	// use the mTLS endpoint aliases, if the http client has a client certificate
+	endpoints := GetEndpoints(client.MTLSEndpoints(rp.httpClient, discoveryConfiguration))
 	rp.oauthConfig.Endpoint = endpoints.Endpoint
 	rp.endpoints = endpoints
+	rp.discovery = discoveryConfiguration
+	if err := Capabilities(rp).Require(rp.requirements...); err != nil {
+		return nil, err
+	}
 
 	rp.oauthConfig.Endpoint.AuthStyle = rp.oauthAuthStyle
 	rp.endpoints.Endpoint.AuthStyle = rp.oauthAuthStyle
+	rp.bindDPoP()
 
 	// avoid races by calling these early
 	_ = rp.IDTokenVerifier()     // sets idTokenVerifier
 	_ = rp.ErrorHandler()        // sets errorHandler
 	_ = rp.UnauthorizedHandler() // sets unauthorizedHandler
 
+	rp.startDiscoveryRefresh(ctx)
 	return rp, nil
 }
 
+func (rp *relyingParty) discover(ctx context.Context) (*oidc.DiscoveryConfiguration, error) {
+	var discover client.DiscoverFunc = client.Discover
+	if rp.insecure {
+		discover = client.DiscoverInsecure
+	}
+	if rp.sharedCache != nil {
+		discover = client.CachedDiscover(discover, rp.sharedCache, rp.sharedCacheTTL)
+	}
+	config, err := discover(ctx, rp.issuer, rp.httpClient, rp.DiscoveryEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	return rp.signedMetadata.verify(ctx, config)
+}
+
 // Option is the type for providing dynamic options to the relyingParty
 type Option func(*relyingParty) error
 
@@ -286,6 +372,17 @@ func WithPKCE(cookieHandler *httphelper.CookieHandler) Option {
 	}
 }
 
+// WithAllowInsecure enables the development mode, which must not be used in production:
+// the issuer may be a template (see [oidc.MatchIssuer]) or a loopback alias
+// (localhost, 127.0.0.1), matched against the issuer of the discovery document and tokens.
+// An issuer template requires [WithCustomDiscoveryUrl].
+func WithAllowInsecure() Option {
+	return func(rp *relyingParty) error {
+		rp.insecure = true
+		return nil
+	}
+}
+
 // WithHTTPClient provides the ability to set an http client to be used for the relaying party and verifier
 func WithHTTPClient(client *http.Client) Option {
 	return func(rp *relyingParty) error {
@@ -361,6 +458,140 @@ func WithSigningAlgsFromDiscovery() Option {
 	}
 }
 
+// WithCertificateRoots only accepts keys of the jwks_uri for the ID token verification,
+// which have an x5c certificate chain verifying against the roots.
+// See [CertificateRoots].
+func WithCertificateRoots(roots *x509.CertPool) Option {
+	return func(rp *relyingParty) error {
+		rp.keySetOpts = append(rp.keySetOpts, CertificateRoots(roots))
+		return nil
+	}
+}
+
+// WithKeySetContext bounds the fetches of the keys of the jwks_uri for the ID token
+// verification to the lifetime of ctx. See [KeySetContext].
+func WithKeySetContext(ctx context.Context) Option {
+	return func(rp *relyingParty) error {
+		rp.keySetOpts = append(rp.keySetOpts, KeySetContext(ctx))
+		return nil
+	}
+}
+
+// WithSharedCache shares the discovery configuration and the keys of the jwks_uri
+// through the cache for the ttl, with other instances using the same cache.
+// See [client.CachedDiscover] and [SharedKeyCache].
+func WithSharedCache(c cache.Cache, ttl time.Duration) Option {
+	return func(rp *relyingParty) error {
+		rp.sharedCache = c
+		rp.sharedCacheTTL = ttl
+		rp.keySetOpts = append(rp.keySetOpts, SharedKeyCache(c, ttl))
+		return nil
+	}
+}
+
+// WithRetryPolicy retries the token requests of [RefreshTokens], when throttled
+// by the OP with status 429 Too Many Requests, honoring its Retry-After header.
+// Without it, throttled requests fail with [httphelper.ErrThrottled].
+// The polling of [DeviceAccessToken] always continues after throttled requests.
+func WithRetryPolicy(policy *httphelper.RetryPolicy) Option {
+	return func(rp *relyingParty) error {
+		rp.retryPolicy = policy
+		return nil
+	}
+}
+
+// WithDPoP adds DPoP proofs (RFC 9449) of the proofer to the token requests,
+// so the OP binds the access tokens to its key. The same proofer must be
+// used to call resource servers with the tokens, see [client.TokenTransport].
+func WithDPoP(proofer client.DPoPProofer) Option {
+	return func(rp *relyingParty) error {
+		rp.dpop = proofer
+		return nil
+	}
+}
+
+// WithTelemetry sets the User-Agent and telemetry headers on all requests
+// of the RelyingParty, including discovery. See [client.Telemetry].
+func WithTelemetry(telemetry client.Telemetry) Option {
+	return func(rp *relyingParty) error {
+		rp.telemetry = &telemetry
+		return nil
+	}
+}
+
+// bindTelemetry wraps the http client to send the headers of [WithTelemetry].
+func (rp *relyingParty) bindTelemetry() {
+	if rp.telemetry == nil {
+		return
+	}
+	rp.httpClient = rp.telemetry.Client(rp.httpClient)
+}
+
+// WithTracerProvider records a span of every request of the RelyingParty,
+// including discovery and the fetches of the keys, see [client.Instrumentation].
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(rp *relyingParty) error {
+		if rp.instrumentation == nil {
+			rp.instrumentation = new(client.Instrumentation)
+		}
+		rp.instrumentation.TracerProvider = tp
+		return nil
+	}
+}
+
+// WithMeterProvider records the count and duration of the requests of the
+// RelyingParty by operation, see [client.Instrumentation].
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(rp *relyingParty) error {
+		if rp.instrumentation == nil {
+			rp.instrumentation = new(client.Instrumentation)
+		}
+		rp.instrumentation.MeterProvider = mp
+		return nil
+	}
+}
+
+// bindInstrumentation wraps the http client to record the requests,
+// if set with [WithTracerProvider] or [WithMeterProvider].
+func (rp *relyingParty) bindInstrumentation() (err error) {
+	if rp.instrumentation == nil {
+		return nil
+	}
+	rp.httpClient, err = rp.instrumentation.Client(rp.httpClient)
+	return err
+}
+
+// bindDPoP wraps the http client to send DPoP proofs to the token endpoint,
+// if set with [WithDPoP].
+func (rp *relyingParty) bindDPoP() {
+	if rp.dpop == nil {
+		return
+	}
+	httpClient := *rp.httpClient
+	httpClient.Transport = &client.DPoPTransport{
+		Proofer:  rp.dpop,
+		Endpoint: rp.oauthConfig.Endpoint.TokenURL,
+		Base:     rp.httpClient.Transport,
+	}
+	rp.httpClient = &httpClient
+}
+
+type retryPolicier interface {
+	throttleRetryPolicy() *httphelper.RetryPolicy
+}
+
+func (rp *relyingParty) throttleRetryPolicy() *httphelper.RetryPolicy {
+	return rp.retryPolicy
+}
+
+// ctxWithRetryPolicy sets the [WithRetryPolicy] of the rp on the context.
+func ctxWithRetryPolicy(ctx context.Context, rp RelyingParty) context.Context {
+	if policier, ok := rp.(retryPolicier); ok && policier.throttleRetryPolicy() != nil {
+		return httphelper.ContextWithRetryPolicy(ctx, policier.throttleRetryPolicy())
+	}
+	return ctx
+}
+
 type SignerFromKey func() (jose.Signer, error)
 
 func SignerFromKeyPath(path string) SignerFromKey {
@@ -402,6 +633,7 @@ func AuthURL(state string, rp RelyingParty, opts ...AuthURLOpt) string {
 // AuthURLHandler extends the `AuthURL` method with a http redirect handler
 // including handling setting cookie for secure `state` transfer.
 // Custom parameters can optionally be set to the redirect URL.
+// With [WithPAR], the auth request is pushed to the OP, see [PushedAuthURL].
 func AuthURLHandler(stateFn func() string, rp RelyingParty, urlParam ...URLParamOpt) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		opts := make([]AuthURLOpt, len(urlParam))
@@ -410,26 +642,53 @@ func AuthURLHandler(stateFn func() string, rp RelyingParty, urlParam ...URLParam
 		}
 
 		state := stateFn()
-		if err := trySetStateCookie(w, state, rp); err != nil {
-			unauthorizedError(w, r, "failed to create state cookie: "+err.Error(), state, rp)
-			return
-		}
-		if rp.IsPKCE() {
-			codeChallenge, err := GenerateAndStoreCodeChallenge(w, rp)
+		if statelessState(rp) != nil {
+			binding, err := SetStatelessStateBinding(w, r, rp)
 			if err != nil {
-				unauthorizedError(w, r, "failed to create code challenge: "+err.Error(), state, rp)
+				unauthorizedError(w, r, "failed to create state binding: "+err.Error(), state, rp)
 				return
 			}
+			signed, codeChallenge, err := NewStatelessState(rp, state, binding)
+			if err != nil {
+				unauthorizedError(w, r, "failed to create state: "+err.Error(), state, rp)
+				return
+			}
+			state = signed
 			opts = append(opts, WithCodeChallenge(codeChallenge))
+		} else {
+			if err := trySetStateCookie(w, state, rp); err != nil {
+				unauthorizedError(w, r, "failed to create state cookie: "+err.Error(), state, rp)
+				return
+			}
+			if rp.IsPKCE() {
+				codeChallenge, err := GenerateAndStoreCodeChallenge(w, rp)
+				if err != nil {
+					unauthorizedError(w, r, "failed to create code challenge: "+err.Error(), state, rp)
+					return
+				}
+				opts = append(opts, WithCodeChallenge(codeChallenge))
+			}
 		}
 
+		if pushedAuthorizationEnabled(rp) {
+			authURL, err := PushedAuthURL(r.Context(), state, rp, opts...)
+			if err != nil {
+				unauthorizedError(w, r, "failed to push auth request: "+err.Error(), state, rp)
+				return
+			}
+			http.Redirect(w, r, authURL, http.StatusFound)
+			return
+		}
 		http.Redirect(w, r, AuthURL(state, rp, opts...), http.StatusFound)
 	}
 }
 
 // GenerateAndStoreCodeChallenge generates a PKCE code challenge and stores its verifier into a secure cookie
 func GenerateAndStoreCodeChallenge(w http.ResponseWriter, rp RelyingParty) (string, error) {
-	codeVerifier := base64.RawURLEncoding.EncodeToString([]byte(uuid.New().String()))
+	codeVerifier, err := randomString(rp, codeVerifierBytes)
+	if err != nil {
+		return "", err
+	}
 	if err := rp.CookieHandler().SetCookie(w, pkceCode, codeVerifier); err != nil {
 		return "", err
 	}
@@ -455,6 +714,9 @@ func verifyTokenResponse[C oidc.IDClaims](ctx context.Context, token *oauth2.Tok
 	if err != nil {
 		return nil, err
 	}
+	if idToken, err = transformClaims(ctx, rp, idToken); err != nil {
+		return nil, err
+	}
 	return &oidc.Tokens[C]{Token: token, IDTokenClaims: idToken, IDToken: idTokenString}, nil
 }
 
@@ -511,13 +773,33 @@ type CodeExchangeCallback[C oidc.IDClaims] func(w http.ResponseWriter, r *http.R
 // including cookie handling for secure `state` transfer
 // and optional PKCE code verifier checking.
 // Custom parameters can optionally be set to the token URL.
+// The ID Token claims are passed to the callback in the request context,
+// see [oidc.ClaimsFromContext].
+// Response JWTs of the JWT response modes (JARM) are verified and unwrapped,
+// see [VerifyJWTAuthorizationResponse].
 func CodeExchangeHandler[C oidc.IDClaims](callback CodeExchangeCallback[C], rp RelyingParty, urlParam ...URLParamOpt) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, span := client.Tracer.Start(r.Context(), "CodeExchangeHandler")
 		r = r.WithContext(ctx)
 		defer span.End()
 
+		if err := unwrapJWTResponse(r, rp); err != nil {
+			unauthorizedError(w, r, "failed to verify response: "+err.Error(), "", rp)
+			return
+		}
+		var codeVerifier string
 		state, err := tryReadStateCookie(w, r, rp)
+		if err == nil && statelessState(rp) != nil {
+			var (
+				s       *StatelessState
+				binding string
+			)
+			if binding, err = StatelessStateBinding(r, rp); err == nil {
+				if s, codeVerifier, err = VerifyStatelessState(r.Context(), rp, state, binding); err == nil {
+					state = s.Target
+				}
+			}
+		}
 		if err != nil {
 			unauthorizedError(w, r, "failed to get state: "+err.Error(), state, rp)
 			return
@@ -531,7 +813,9 @@ func CodeExchangeHandler[C oidc.IDClaims](callback CodeExchangeCallback[C], rp R
 			codeOpts[i] = CodeExchangeOpt(p)
 		}
 
-		if rp.IsPKCE() {
+		if codeVerifier != "" {
+			codeOpts = append(codeOpts, WithCodeVerifier(codeVerifier))
+		} else if rp.IsPKCE() {
 			codeVerifier, err := rp.CookieHandler().CheckCookie(r, pkceCode)
 			if err != nil {
 				unauthorizedError(w, r, "failed to get code verifier: "+err.Error(), state, rp)
@@ -553,6 +837,9 @@ func CodeExchangeHandler[C oidc.IDClaims](callback CodeExchangeCallback[C], rp R
 			unauthorizedError(w, r, "failed to exchange token: "+err.Error(), state, rp)
 			return
 		}
+		if !rp.IsOAuth2Only() {
+			r = r.WithContext(oidc.ContextWithClaims(r.Context(), tokens.IDTokenClaims.GetSubject(), tokens.IDTokenClaims))
+		}
 		callback(w, r, tokens, state, rp)
 	}
 }
@@ -592,19 +879,30 @@ func Userinfo[U SubjectGetter](ctx context.Context, token, tokenType, subject st
 	ctx = logCtxWithRPData(ctx, rp, "function", "Userinfo")
 	ctx, span := client.Tracer.Start(ctx, "Userinfo")
 	defer span.End()
+	ctx = client.WithOperation(ctx, client.OperationUserinfo)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rp.UserinfoEndpoint(), nil)
+	body, err := cachedUserinfo(ctx, rp, token, func(ctx context.Context) (json.RawMessage, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rp.UserinfoEndpoint(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("authorization", tokenType+" "+token)
+		var body json.RawMessage
+		if err := httphelper.HttpRequest(rp.HttpClient(), req, &body); err != nil {
+			return nil, err
+		}
+		return body, nil
+	})
 	if err != nil {
 		return nilU, err
 	}
-	req.Header.Set("authorization", tokenType+" "+token)
-	if err := httphelper.HttpRequest(rp.HttpClient(), req, &userinfo); err != nil {
+	if err = json.Unmarshal(body, &userinfo); err != nil {
 		return nilU, err
 	}
 	if userinfo.GetSubject() != subject {
 		return nilU, ErrUserInfoSubNotMatching
 	}
-	return userinfo, nil
+	return transformClaims(ctx, rp, userinfo)
 }
 
 func trySetStateCookie(w http.ResponseWriter, state string, rp RelyingParty) error {
@@ -617,7 +915,7 @@ func trySetStateCookie(w http.ResponseWriter, state string, rp RelyingParty) err
 }
 
 func tryReadStateCookie(w http.ResponseWriter, r *http.Request, rp RelyingParty) (state string, err error) {
-	if rp.CookieHandler() == nil {
+	if rp.CookieHandler() == nil || statelessState(rp) != nil {
 		return r.FormValue(stateParam), nil
 	}
 	state, err = rp.CookieHandler().CheckQueryCookie(r, stateParam)
@@ -638,6 +936,10 @@ type Endpoints struct {
 	EndSessionURL          string
 	RevokeURL              string
 	DeviceAuthorizationURL string
+	PushedAuthorizationURL string
+
+	BackchannelAuthenticationURL string
+	SessionTransferURL           string
 }
 
 func GetEndpoints(discoveryConfig *oidc.DiscoveryConfiguration) Endpoints {
@@ -652,6 +954,10 @@ func GetEndpoints(discoveryConfig *oidc.DiscoveryConfiguration) Endpoints {
 		EndSessionURL:          discoveryConfig.EndSessionEndpoint,
 		RevokeURL:              discoveryConfig.RevocationEndpoint,
 		DeviceAuthorizationURL: discoveryConfig.DeviceAuthorizationEndpoint,
+		PushedAuthorizationURL: discoveryConfig.PushedAuthorizationRequestEndpoint,
+
+		BackchannelAuthenticationURL: discoveryConfig.BackchannelAuthenticationEndpoint,
+		SessionTransferURL:           discoveryConfig.SessionTransferEndpoint,
 	}
 }
 
@@ -673,6 +979,26 @@ func withPrompt(prompt ...string) func() []oauth2.AuthCodeOption {
 	return withURLParam("prompt", oidc.SpaceDelimitedArray(prompt).String())
 }
 
+// withLoginHints sets the non-empty login hint params in the auth request.
+// This is the generalized, unexported, function used by both
+// URLParamOpt and AuthURLOpt.
+func withLoginHints(hints oidc.LoginHints) func() []oauth2.AuthCodeOption {
+	return func() []oauth2.AuthCodeOption {
+		var opts []oauth2.AuthCodeOption
+		for key, value := range map[string]string{
+			"login_hint":       hints.LoginHint,
+			"login_hint_token": hints.LoginHintToken,
+			"domain_hint":      hints.DomainHint,
+			"idp_hint":         hints.IDPHint,
+		} {
+			if value != "" {
+				opts = append(opts, oauth2.SetAuthURLParam(key, value))
+			}
+		}
+		return opts
+	}
+}
+
 type URLParamOpt func() []oauth2.AuthCodeOption
 
 // WithURLParam allows setting custom key-vale pairs
@@ -691,6 +1017,13 @@ func WithResponseModeURLParam(mode oidc.ResponseMode) URLParamOpt {
 	return withURLParam("response_mode", string(mode))
 }
 
+// WithLoginHintsURLParam sets the `login_hint`, `login_hint_token`,
+// `domain_hint` and `idp_hint` parameters in a URL.
+// Provider-specific hints can be set using [WithURLParam].
+func WithLoginHintsURLParam(hints oidc.LoginHints) URLParamOpt {
+	return withLoginHints(hints)
+}
+
 type AuthURLOpt func() []oauth2.AuthCodeOption
 
 // WithCodeChallenge sets the `code_challenge` params in the auth request
@@ -708,6 +1041,11 @@ func WithPrompt(prompt ...string) AuthURLOpt {
 	return withPrompt(prompt...)
 }
 
+// WithLoginHints sets the login hint params in the auth request
+func WithLoginHints(hints oidc.LoginHints) AuthURLOpt {
+	return withLoginHints(hints)
+}
+
 type CodeExchangeOpt func() []oauth2.AuthCodeOption
 
 // WithCodeVerifier sets the `code_verifier` param in the token request
@@ -724,6 +1062,14 @@ func WithClientAssertionJWT(clientAssertion string) CodeExchangeOpt {
 	}
 }
 
+// WithClientAssertionSPIFFE authenticates the client with its SPIFFE JWT-SVID
+// in the token request (auth method spiffe_jwt).
+func WithClientAssertionSPIFFE(svid string) CodeExchangeOpt {
+	return func() []oauth2.AuthCodeOption {
+		return client.SPIFFEAssertionCodeOptions(svid)
+	}
+}
+
 type tokenEndpointCaller struct {
 	RelyingParty
 }
@@ -742,6 +1088,42 @@ type RefreshTokenRequest struct {
 	GrantType           oidc.GrantType           `schema:"grant_type"`
 }
 
+// LogValue implements [slog.LogValuer] with the token, secret and assertion redacted.
+func (r RefreshTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("refresh_token", r.RefreshToken),
+		slog.String("scope", r.Scopes.String()),
+		slog.String("client_id", r.ClientID),
+		security.Attr("client_secret", r.ClientSecret),
+		security.Attr("client_assertion", r.ClientAssertion),
+		slog.String("client_assertion_type", r.ClientAssertionType),
+		slog.String("grant_type", string(r.GrantType)),
+	)
+}
+
+func (r RefreshTokenRequest) String() string {
+	return security.String(r)
+}
+
+// MarshalForm implements [httphelper.FormMarshaler].
+func (r RefreshTokenRequest) MarshalForm(form url.Values) error {
+	if err := errors.Join(
+		oidc.FormRequired("refresh_token", r.RefreshToken),
+		oidc.FormOneOf("grant_type", r.GrantType, oidc.GrantTypeRefreshToken),
+		oidc.FormOneOf("client_assertion_type", r.ClientAssertionType, oidc.ClientAssertionTypeJWTAssertion, oidc.ClientAssertionTypeJWTSPIFFE),
+	); err != nil {
+		return err
+	}
+	oidc.FormSet(form, "refresh_token", r.RefreshToken)
+	oidc.FormSet(form, "scope", r.Scopes.String())
+	oidc.FormSet(form, "client_id", r.ClientID)
+	oidc.FormSet(form, "client_secret", r.ClientSecret)
+	oidc.FormSet(form, "client_assertion", r.ClientAssertion)
+	oidc.FormSet(form, "client_assertion_type", r.ClientAssertionType)
+	oidc.FormSet(form, "grant_type", oidc.GrantTypeRefreshToken)
+	return nil
+}
+
 // RefreshTokens performs a token refresh. If it doesn't error, it will always
 // provide a new AccessToken. It may provide a new RefreshToken, and if it does, then
 // the old one should be considered invalid.
@@ -754,6 +1136,7 @@ func RefreshTokens[C oidc.IDClaims](ctx context.Context, rp RelyingParty, refres
 	defer span.End()
 
 	ctx = logCtxWithRPData(ctx, rp, "function", "RefreshTokens")
+	ctx = ctxWithRetryPolicy(ctx, rp)
 	request := RefreshTokenRequest{
 		RefreshToken:        refreshToken,
 		Scopes:              rp.OAuthConfig().Scopes,
@@ -2,10 +2,15 @@ package rp
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/client"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -105,3 +110,90 @@ func Test_verifyTokenResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestWithLoginHints(t *testing.T) {
+	rp := &relyingParty{oauthConfig: &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/authorize"},
+	}}
+	got, err := url.Parse(AuthURL("state", rp, WithLoginHints(oidc.LoginHints{
+		LoginHint:  "user@example.com",
+		DomainHint: "example.com",
+	})))
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", got.Query().Get("login_hint"))
+	assert.Equal(t, "example.com", got.Query().Get("domain_hint"))
+	assert.False(t, got.Query().Has("login_hint_token"))
+	assert.False(t, got.Query().Has("idp_hint"))
+}
+
+func TestWithAuthorizationDetails(t *testing.T) {
+	rp := &relyingParty{oauthConfig: &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/authorize"},
+	}}
+	details := oidc.AuthorizationDetail{
+		Type:    "payment_initiation",
+		Actions: []string{"initiate"},
+		Fields:  map[string]any{"creditorName": "Merchant A"},
+	}
+	got, err := url.Parse(AuthURL("state", rp, WithAuthorizationDetails(details)))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"type":"payment_initiation","actions":["initiate"],"creditorName":"Merchant A"}]`, got.Query().Get("authorization_details"))
+
+	got, err = url.Parse(AuthURL("state", rp, WithAuthorizationDetails()))
+	require.NoError(t, err)
+	assert.False(t, got.Query().Has("authorization_details"))
+
+	token := (&oauth2.Token{AccessToken: "token"}).WithExtra(map[string]any{
+		"authorization_details": []any{map[string]any{"type": "payment_initiation", "creditorName": "Merchant A"}},
+	})
+	granted, err := AuthorizationDetailsFromToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, oidc.AuthorizationDetails{{Type: "payment_initiation", Fields: map[string]any{"creditorName": "Merchant A"}}}, granted)
+
+	granted, err = AuthorizationDetailsFromToken(&oauth2.Token{AccessToken: "token"})
+	require.NoError(t, err)
+	assert.Nil(t, granted)
+}
+
+func TestWithTelemetry(t *testing.T) {
+	var userAgents []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		json.NewEncoder(w).Encode(&oidc.DiscoveryConfiguration{
+			Issuer:        server.URL,
+			TokenEndpoint: server.URL + "/oauth/token",
+		})
+	}))
+	defer server.Close()
+
+	relyingParty, err := NewRelyingPartyOIDC(context.Background(), server.URL, "client", "secret", "", nil,
+		WithTelemetry(client.Telemetry{UserAgent: "my-app/1.2"}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-app/1.2"}, userAgents, "discovery")
+
+	resp, err := relyingParty.HttpClient().Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "my-app/1.2", userAgents[1])
+}
+
+func TestRefreshTokenRequest_MarshalForm(t *testing.T) {
+	form := make(url.Values)
+	err := RefreshTokenRequest{
+		RefreshToken:        "refresh",
+		ClientAssertion:     "svid",
+		ClientAssertionType: oidc.ClientAssertionTypeJWTSPIFFE,
+	}.MarshalForm(form)
+	require.NoError(t, err)
+	assert.Equal(t, oidc.ClientAssertionTypeJWTSPIFFE, form.Get("client_assertion_type"))
+	assert.Equal(t, "svid", form.Get("client_assertion"))
+
+	err = RefreshTokenRequest{RefreshToken: "refresh", ClientAssertionType: "urn:unknown"}.MarshalForm(make(url.Values))
+	var formErr *oidc.FormError
+	require.ErrorAs(t, err, &formErr)
+	assert.Equal(t, "client_assertion_type", formErr.Parameter)
+}
@@ -0,0 +1,78 @@
+package rp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ErrSessionEnded is returned by [SessionTracker.Check] for sessions
+// ended at the OP.
+var ErrSessionEnded = errors.New("session ended at the OP")
+
+// SessionTracker tracks the sessions of the end-users at the OP by their sid,
+// which were ended by back-channel logout, see [BackChannelLogoutHandler].
+// Local sessions, like cookies holding the ID token, are checked with Check,
+// even if they are handled by another instance of the application,
+// if the cache is shared.
+// Logouts without sid end all sessions of the subject authenticated until then.
+type SessionTracker struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewSessionTracker returns a SessionTracker stored in c, a [cache.Memory] if nil.
+// Ended sessions are kept for ttl, the maximum lifetime of the local sessions.
+func NewSessionTracker(c cache.Cache, ttl time.Duration) *SessionTracker {
+	if c == nil {
+		c = cache.NewMemory()
+	}
+	return &SessionTracker{cache: c, ttl: ttl}
+}
+
+// Logout ends the session of the logout token, or all sessions of its subject,
+// if it has no sid. It is a [LogoutFunc].
+func (t *SessionTracker) Logout(ctx context.Context, claims *oidc.LogoutTokenClaims) error {
+	key := sessionKey(claims.Issuer, "sub", claims.Subject)
+	if claims.SessionID != "" {
+		key = sessionKey(claims.Issuer, "sid", claims.SessionID)
+	}
+	endedAt := strconv.FormatInt(time.Now().Unix(), 10)
+	return t.cache.Set(ctx, key, []byte(endedAt), t.ttl)
+}
+
+// Check returns [ErrSessionEnded], if the session the ID token was issued for
+// was ended at the OP.
+func (t *SessionTracker) Check(ctx context.Context, claims *oidc.IDTokenClaims) error {
+	keys := []string{sessionKey(claims.Issuer, "sub", claims.Subject)}
+	if claims.SessionID != "" {
+		keys = append(keys, sessionKey(claims.Issuer, "sid", claims.SessionID))
+	}
+	for _, key := range keys {
+		value, err := t.cache.Get(ctx, key)
+		if errors.Is(err, cache.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		endedAt, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("session tracker: invalid logout of %s: %w", key, err)
+		}
+		if claims.IssuedAt.AsTime().Unix() <= endedAt {
+			return ErrSessionEnded
+		}
+	}
+	return nil
+}
+
+// sessionKey is scoped by the issuer, as sid and sub are unique per OP.
+func sessionKey(issuer, kind, id string) string {
+	return "session:" + kind + ":" + issuer + " " + id
+}
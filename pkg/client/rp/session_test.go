@@ -0,0 +1,35 @@
+package rp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestSessionTracker(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewSessionTracker(nil, time.Hour)
+	idToken := func(sid string, issuedAt time.Time) *oidc.IDTokenClaims {
+		claims := oidc.NewIDTokenClaims(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, tu.ValidExpiration, tu.ValidAuthTime, tu.ValidNonce, tu.ValidACR, tu.ValidAMR, tu.ValidClientID, 0)
+		claims.SessionID = sid
+		claims.IssuedAt = oidc.FromTime(issuedAt)
+		return claims
+	}
+	earlier := time.Now().Add(-time.Minute)
+
+	require.NoError(t, tracker.Check(ctx, idToken("sid1", earlier)))
+	require.NoError(t, tracker.Logout(ctx, &oidc.LogoutTokenClaims{Issuer: tu.ValidIssuer, Subject: tu.ValidSubject, SessionID: "sid1"}))
+	assert.ErrorIs(t, tracker.Check(ctx, idToken("sid1", earlier)), ErrSessionEnded)
+	assert.NoError(t, tracker.Check(ctx, idToken("sid2", earlier)), "other session")
+
+	// logouts without sid end all sessions of the subject until then
+	require.NoError(t, tracker.Logout(ctx, &oidc.LogoutTokenClaims{Issuer: tu.ValidIssuer, Subject: tu.ValidSubject}))
+	assert.ErrorIs(t, tracker.Check(ctx, idToken("sid2", earlier)), ErrSessionEnded)
+	assert.NoError(t, tracker.Check(ctx, idToken("sid3", time.Now().Add(time.Minute))))
+}
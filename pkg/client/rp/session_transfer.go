@@ -0,0 +1,100 @@
+package rp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// GetSessionTransferEndpoint returns the endpoint where first-party
+// clients obtain transfer tokens of the sessions of their users.
+func (rp *relyingParty) GetSessionTransferEndpoint() string {
+	rp.metadataMu.RLock()
+	defer rp.metadataMu.RUnlock()
+	return rp.endpoints.SessionTransferURL
+}
+
+// NewSessionTransferVerifier returns a new code_verifier of the native app and
+// its S256 code_challenge, which the app passes to the first-party client
+// requesting the transfer token with [TransferSession].
+func NewSessionTransferVerifier(rp RelyingParty) (codeVerifier, codeChallenge string, err error) {
+	codeVerifier, err = randomString(rp, codeVerifierBytes)
+	if err != nil {
+		return "", "", err
+	}
+	return codeVerifier, oidc.NewSHACodeChallenge(codeVerifier), nil
+}
+
+// TransferSession obtains a one-time transfer token of the session of the user,
+// identified by the ID token issued to the RelyingParty, for the native app
+// with the client_id audience. The transfer token is bound to the S256 code_challenge
+// of the app, which redeems it with [RedeemSessionTransfer].
+// The scopes default to openid at the provider.
+func TransferSession(ctx context.Context, rp RelyingParty, idToken, audience, codeChallenge string, scopes ...string) (*oidc.SessionTransferResponse, error) {
+	ctx, span := client.Tracer.Start(ctx, "TransferSession")
+	defer span.End()
+
+	ctx = logCtxWithRPData(ctx, rp, "function", "TransferSession")
+	caller, ok := rp.(client.SessionTransferCaller)
+	if !ok {
+		return nil, fmt.Errorf("session transfer %w", client.ErrEndpointNotSet)
+	}
+	credentials, err := newDeviceClientCredentialsRequest(nil, rp)
+	if err != nil {
+		return nil, err
+	}
+	request := &client.SessionTransferRequest{
+		ClientCredentialsRequest: credentials,
+		SessionTransferRequest: oidc.SessionTransferRequest{
+			SubjectToken:        idToken,
+			Audience:            audience,
+			Scopes:              scopes,
+			CodeChallenge:       codeChallenge,
+			CodeChallengeMethod: oidc.CodeChallengeMethodS256,
+		},
+	}
+	return client.CallSessionTransferEndpoint(ctx, request, caller)
+}
+
+// RedeemSessionTransfer redeems the transfer token, obtained by the first-party client
+// with [TransferSession], with the code_verifier of [NewSessionTransferVerifier]
+// and returns the tokens of the native app, with the ID token verified.
+func RedeemSessionTransfer[C oidc.IDClaims](ctx context.Context, rp RelyingParty, transferToken, codeVerifier string) (*oidc.Tokens[C], error) {
+	ctx, span := client.Tracer.Start(ctx, "RedeemSessionTransfer")
+	defer span.End()
+
+	ctx = logCtxWithRPData(ctx, rp, "function", "RedeemSessionTransfer")
+	credentials, err := newDeviceClientCredentialsRequest(nil, rp)
+	if err != nil {
+		return nil, err
+	}
+	req := &client.SessionTransferTokenRequest{
+		ClientCredentialsRequest: credentials,
+		SessionTransferTokenRequest: oidc.SessionTransferTokenRequest{
+			GrantType:     oidc.GrantTypeSessionTransfer,
+			TransferToken: transferToken,
+			CodeVerifier:  codeVerifier,
+		},
+	}
+	resp, err := client.CallSessionTransferTokenEndpoint(ctx, req, tokenEndpointCaller{rp})
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		RefreshToken: resp.RefreshToken,
+	}
+	if resp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	if resp.IDToken != "" {
+		token = token.WithExtra(map[string]any{idTokenKey: resp.IDToken})
+	}
+	return verifyTokenResponse[C](ctx, token, rp)
+}
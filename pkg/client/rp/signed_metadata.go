@@ -0,0 +1,37 @@
+package rp
+
+import (
+	"context"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// signedMetadataTrust are the trust anchors of the signed metadata, see [WithSignedMetadata].
+type signedMetadataTrust struct {
+	keySet           oidc.KeySet
+	supportedSigAlgs []string
+}
+
+// WithSignedMetadata requires the discovery configuration of the OP to contain
+// signed_metadata (RFC 8414, section 2.1), verified with the keys of the trust anchors,
+// like [oidc.NewStaticKeySet] for pinned keys or [oidc.NewCertificateKeySet]
+// for keys certified by the roots of a PKI. The signed values take precedence
+// over the plain values, so tampering with the discovery document by a CDN
+// or on the network is detected or without effect.
+// The supported algorithms default to RS256, ES256 and PS256.
+// Discovery fails with [oidc.ErrSignedMetadataMissing] or [oidc.ErrSignedMetadataInvalid].
+func WithSignedMetadata(trustAnchors oidc.KeySet, supportedSigAlgs ...string) Option {
+	return func(rp *relyingParty) error {
+		rp.signedMetadata = &signedMetadataTrust{keySet: trustAnchors, supportedSigAlgs: supportedSigAlgs}
+		return nil
+	}
+}
+
+// verify returns the configuration with the verified signed metadata applied.
+// Without trust anchors, the configuration is returned unmodified.
+func (t *signedMetadataTrust) verify(ctx context.Context, config *oidc.DiscoveryConfiguration) (*oidc.DiscoveryConfiguration, error) {
+	if t == nil {
+		return config, nil
+	}
+	return oidc.VerifySignedMetadata(ctx, config, t.keySet, t.supportedSigAlgs...)
+}
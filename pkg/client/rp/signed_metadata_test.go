@@ -0,0 +1,47 @@
+package rp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestWithSignedMetadata(t *testing.T) {
+	var config oidc.DiscoveryConfiguration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&config)
+	}))
+	defer server.Close()
+
+	payload, err := json.Marshal(map[string]any{"iss": server.URL, "token_endpoint": server.URL + "/token"})
+	require.NoError(t, err)
+	jws, err := tu.Signer.Sign(payload)
+	require.NoError(t, err)
+	signed, err := jws.CompactSerialize()
+	require.NoError(t, err)
+	config = oidc.DiscoveryConfiguration{
+		Issuer:        server.URL,
+		TokenEndpoint: "https://attacker.example.com/token",
+		JwksURI:       server.URL + "/keys",
+	}
+
+	_, err = NewRelyingPartyOIDC(context.Background(), server.URL, "client", "secret", "", nil,
+		WithSignedMetadata(oidc.NewStaticKeySet(tu.WebKey)),
+	)
+	require.ErrorIs(t, err, oidc.ErrSignedMetadataMissing)
+
+	config.SignedMetadata = signed
+	relyingParty, err := NewRelyingPartyOIDC(context.Background(), server.URL, "client", "secret", "", nil,
+		WithSignedMetadata(oidc.NewStaticKeySet(tu.WebKey)),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/token", relyingParty.OAuthConfig().Endpoint.TokenURL)
+}
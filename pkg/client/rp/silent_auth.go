@@ -0,0 +1,148 @@
+package rp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// InteractionRequiredError is returned by [SilentAuth] when the provider
+// can't complete the authentication without interaction of the end-user.
+// Err is one of login_required, consent_required, interaction_required
+// or account_selection_required, as defined in OpenID Connect Core 1.0, section 3.1.2.6.
+// The application should then start a regular (interactive) auth request.
+type InteractionRequiredError struct {
+	Err *oidc.Error
+}
+
+func (e *InteractionRequiredError) Error() string {
+	return "silent authentication: " + e.Err.Error()
+}
+
+func (e *InteractionRequiredError) Unwrap() error {
+	return e.Err
+}
+
+// IsInteractionRequired reports whether err is an [InteractionRequiredError].
+func IsInteractionRequired(err error) bool {
+	target := new(InteractionRequiredError)
+	return errors.As(err, &target)
+}
+
+var (
+	ErrSilentAuthState    = errors.New("silent authentication: state mismatch")
+	ErrSilentAuthRedirect = errors.New("silent authentication: provider did not redirect to the redirect_uri")
+)
+
+// SilentAuth performs an auth request with prompt=none and exchanges the returned code
+// for fresh tokens, if the session of the end-user at the provider is still alive.
+// The optional idTokenHint is a previously issued ID Token of the end-user.
+//
+// The auth request is sent using the HttpClient of the RelyingParty, following
+// the redirects of the provider until the redirect_uri is reached, which is
+// never called. The session of the end-user must therefore be available to the HttpClient,
+// e.g. through its cookie Jar.
+// If the provider requires interaction, an [InteractionRequiredError] is returned.
+func SilentAuth[C oidc.IDClaims](ctx context.Context, rp RelyingParty, idTokenHint string, opts ...AuthURLOpt) (tokens *oidc.Tokens[C], err error) {
+	ctx, span := client.Tracer.Start(ctx, "SilentAuth")
+	defer span.End()
+
+	state, err := randomString(rp, stateBytes)
+	if err != nil {
+		return nil, err
+	}
+	authOpts := append(make([]AuthURLOpt, 0, len(opts)+3), opts...)
+	authOpts = append(authOpts, WithPrompt(oidc.PromptNone))
+	if idTokenHint != "" {
+		authOpts = append(authOpts, withURLParam("id_token_hint", idTokenHint))
+	}
+	var codeOpts []CodeExchangeOpt
+	if rp.IsPKCE() {
+		codeVerifier, err := randomString(rp, codeVerifierBytes)
+		if err != nil {
+			return nil, err
+		}
+		authOpts = append(authOpts, WithCodeChallenge(oidc.NewSHACodeChallenge(codeVerifier)))
+		codeOpts = append(codeOpts, WithCodeVerifier(codeVerifier))
+	}
+
+	callback, err := silentRedirect(ctx, rp.HttpClient(), AuthURL(state, rp, authOpts...), rp.OAuthConfig().RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	params := callback.Query()
+	if params.Get("state") != state {
+		return nil, ErrSilentAuthState
+	}
+	if errType := params.Get("error"); errType != "" {
+		return nil, silentAuthError(errType, params.Get("error_description"))
+	}
+	return CodeExchange[C](ctx, params.Get("code"), rp, codeOpts...)
+}
+
+// silentRedirect calls the authURL and follows the redirects
+// until the redirectURI is reached, which is returned.
+func silentRedirect(ctx context.Context, httpClient *http.Client, authURL, redirectURI string) (*url.URL, error) {
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return nil, fmt.Errorf("silent authentication: invalid redirect_uri: %w", err)
+	}
+	c := *httpClient
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if isRedirectURI(req.URL, redirect) {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("silent authentication: %w", err)
+	}
+	defer resp.Body.Close()
+
+	location, err := resp.Location()
+	if err == nil && isRedirectURI(location, redirect) {
+		return location, nil
+	}
+	if resp.StatusCode == http.StatusOK {
+		// the provider rendered a page instead of redirecting back
+		return nil, &InteractionRequiredError{
+			Err: oidc.ErrInteractionRequired().WithDescription("provider responded with a page").WithParent(ErrSilentAuthRedirect),
+		}
+	}
+	return nil, fmt.Errorf("%w: status %d", ErrSilentAuthRedirect, resp.StatusCode)
+}
+
+func isRedirectURI(u, redirect *url.URL) bool {
+	return u.Scheme == redirect.Scheme && u.Host == redirect.Host && u.Path == redirect.Path
+}
+
+func silentAuthError(errType, description string) error {
+	var err *oidc.Error
+	switch errType {
+	case string(oidc.LoginRequired):
+		err = oidc.ErrLoginRequired()
+	case string(oidc.ConsentRequired):
+		err = oidc.ErrConsentRequired()
+	case string(oidc.InteractionRequired):
+		err = oidc.ErrInteractionRequired()
+	case string(oidc.AccountSelectionRequired):
+		err = oidc.ErrAccountSelectionRequired()
+	default:
+		return fmt.Errorf("silent authentication: %s: %s", errType, description)
+	}
+	err.Description = description
+	return &InteractionRequiredError{Err: err}
+}
@@ -0,0 +1,110 @@
+package rp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestSilentAuth(t *testing.T) {
+	const redirectURI = "https://app.example.com/callback"
+	tests := []struct {
+		name      string
+		authorize func(w http.ResponseWriter, r *http.Request)
+		wantToken string
+		wantErr   error
+	}{
+		{
+			name: "session alive",
+			authorize: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, redirectURI+"?code=code1&state="+r.FormValue("state"), http.StatusFound)
+			},
+			wantToken: "access1",
+		},
+		{
+			name: "login required",
+			authorize: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, redirectURI+"?error=login_required&state="+r.FormValue("state"), http.StatusFound)
+			},
+			wantErr: oidc.ErrLoginRequired(),
+		},
+		{
+			name: "account selection required",
+			authorize: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, redirectURI+"?error=account_selection_required&state="+r.FormValue("state"), http.StatusFound)
+			},
+			wantErr: oidc.ErrAccountSelectionRequired(),
+		},
+		{
+			name: "login page",
+			authorize: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("<html>login</html>"))
+			},
+			wantErr: oidc.ErrInteractionRequired(),
+		},
+		{
+			name: "state mismatch",
+			authorize: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, redirectURI+"?code=code1&state=other", http.StatusFound)
+			},
+			wantErr: ErrSilentAuthState,
+		},
+		{
+			name: "server error",
+			authorize: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantErr: ErrSilentAuthRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, oidc.PromptNone, r.FormValue("prompt"))
+				assert.Equal(t, "hint", r.FormValue("id_token_hint"))
+				assert.NotEmpty(t, r.FormValue("code_challenge"))
+				tt.authorize(w, r)
+			})
+			mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "code1", r.FormValue("code"))
+				assert.NotEmpty(t, r.FormValue("code_verifier"))
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token":"access1","token_type":"Bearer"}`))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			rp, err := NewRelyingPartyOAuth(&oauth2.Config{
+				ClientID:    "client",
+				RedirectURL: redirectURI,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  server.URL + "/authorize",
+					TokenURL: server.URL + "/token",
+				},
+			}, WithPKCE(nil))
+			require.NoError(t, err)
+
+			tokens, err := SilentAuth[*oidc.IDTokenClaims](context.Background(), rp, "hint")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantToken, tokens.AccessToken)
+		})
+	}
+}
+
+func TestIsInteractionRequired(t *testing.T) {
+	assert.True(t, IsInteractionRequired(silentAuthError("consent_required", "")))
+	assert.False(t, IsInteractionRequired(silentAuthError("access_denied", "")))
+	assert.False(t, isRedirectURI(&url.URL{Scheme: "https", Host: "evil.com", Path: "/callback"}, &url.URL{Scheme: "https", Host: "app.example.com", Path: "/callback"}))
+}
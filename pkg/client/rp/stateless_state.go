@@ -0,0 +1,247 @@
+package rp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// DefaultStatelessStateMaxAge is the default maximum age of stateless state values.
+const DefaultStatelessStateMaxAge = 10 * time.Minute
+
+// minStatelessStateKeySize is the minimum size of the HMAC key, the size of the SHA-256 output.
+const minStatelessStateKeySize = 32
+
+// statelessStateCookie holds the per-browser secret binding the stateless state values,
+// see [StatelessStateConfig.BindUserAgent].
+const statelessStateCookie = "state_binding"
+
+// statelessStateBindingBytes gives 256 bit of entropy.
+const statelessStateBindingBytes = 32
+
+// Errors of [WithStatelessState], [NewStatelessState] and [VerifyStatelessState].
+var (
+	ErrStatelessStateKey           = errors.New("stateless state requires a key of at least 32 bytes")
+	ErrStatelessStateDisabled      = errors.New("stateless state requires WithStatelessState")
+	ErrStatelessStateCookieHandler = errors.New("stateless state binding requires a CookieHandler")
+	ErrStatelessStateBinding       = errors.New("missing state binding")
+	ErrStatelessStateInvalid       = errors.New("invalid state")
+	ErrStatelessStateExpired       = errors.New("state expired")
+	ErrStatelessStateReplayed      = errors.New("state already used")
+)
+
+// StatelessStateConfig configures HMAC-signed state values, see [WithStatelessState].
+type StatelessStateConfig struct {
+	// Key of the HMAC, at least 32 bytes. It must be shared by all instances
+	// of the RP and kept secret, as it also derives the PKCE code verifiers.
+	Key []byte
+	// MaxAge is the time from the redirect to the OP to the callback,
+	// defaults to [DefaultStatelessStateMaxAge].
+	MaxAge time.Duration
+	// BindUserAgent binds the state values and code verifiers to the user agent
+	// by a random secret in a session cookie, shared by all its logins and set with
+	// the CookieHandler of the RP, see [SetStatelessStateBinding].
+	// A state value and code leaked from the callback then cannot be redeemed
+	// by another user agent, which protects against login CSRF.
+	// Without it, no cookie is needed at all, but the RP relies on the
+	// single use of the code by the OP and the MaxAge of the state values.
+	BindUserAgent bool
+	// Replay optionally records the used state values until they expire,
+	// so each one is only accepted once. Without it, no server-side storage
+	// is needed, but a state value may be reused within its MaxAge.
+	Replay cache.Cache
+}
+
+// WithStatelessState replaces the state and PKCE cookies of [AuthURLHandler] and
+// [CodeExchangeHandler] by HMAC-signed state values, which embed their creation time,
+// the target returned by the stateFn, such as the page to redirect to after the login,
+// and the code challenge of a PKCE code verifier derived from the key.
+// They are validated on the callback without per-login cookies or server-side storage,
+// e.g. for serverless RPs. The callback receives the target as state.
+//
+// The binding cookie and the Replay cache of the [StatelessStateConfig] are optional hardening.
+func WithStatelessState(config StatelessStateConfig) Option {
+	return func(rp *relyingParty) error {
+		if len(config.Key) < minStatelessStateKeySize {
+			return ErrStatelessStateKey
+		}
+		rp.statelessState = &config
+		return nil
+	}
+}
+
+type statelessStateGetter interface {
+	StatelessState() *StatelessStateConfig
+}
+
+func (rp *relyingParty) StatelessState() *StatelessStateConfig {
+	return rp.statelessState
+}
+
+// statelessState returns the [StatelessStateConfig], nil if not set with [WithStatelessState].
+func statelessState(rp RelyingParty) *StatelessStateConfig {
+	if getter, ok := rp.(statelessStateGetter); ok {
+		return getter.StatelessState()
+	}
+	return nil
+}
+
+// StatelessState is the payload of a stateless state value.
+type StatelessState struct {
+	// ID is random, deriving the PKCE code verifier.
+	ID       string    `json:"jti"`
+	IssuedAt oidc.Time `json:"iat"`
+	// Target is the value returned by the stateFn of [AuthURLHandler].
+	Target string `json:"tgt,omitempty"`
+	// CodeChallenge is the S256 hash of the code verifier.
+	CodeChallenge string `json:"cch"`
+}
+
+func (c *StatelessStateConfig) maxAge() time.Duration {
+	if c.MaxAge > 0 {
+		return c.MaxAge
+	}
+	return DefaultStatelessStateMaxAge
+}
+
+func (c *StatelessStateConfig) mac(data ...string) []byte {
+	mac := hmac.New(sha256.New, c.Key)
+	for _, d := range data {
+		mac.Write([]byte(d))
+		mac.Write([]byte{0})
+	}
+	return mac.Sum(nil)
+}
+
+// codeVerifier derives the PKCE code verifier of the state ID and the binding.
+func (c *StatelessStateConfig) codeVerifier(id, binding string) string {
+	return base64.RawURLEncoding.EncodeToString(c.mac("pkce", id, binding))
+}
+
+// SetStatelessStateBinding returns the per-browser secret of the request,
+// the stateless state values of the user agent are bound to, and sets
+// a new one as session cookie with the CookieHandler of the RP, if the request has none.
+// It returns an empty binding, if [StatelessStateConfig.BindUserAgent] is not set.
+// It is called by [AuthURLHandler] and [CodeExchangeHandler] reads it
+// with [StatelessStateBinding].
+func SetStatelessStateBinding(w http.ResponseWriter, r *http.Request, rp RelyingParty) (string, error) {
+	binding, err := StatelessStateBinding(r, rp)
+	if !errors.Is(err, ErrStatelessStateBinding) {
+		return binding, err
+	}
+	binding, err = randomString(rp, statelessStateBindingBytes)
+	if err != nil {
+		return "", err
+	}
+	if err = rp.CookieHandler().SetCookie(w, statelessStateCookie, binding); err != nil {
+		return "", err
+	}
+	return binding, nil
+}
+
+// StatelessStateBinding returns the per-browser secret of the request,
+// see [SetStatelessStateBinding].
+func StatelessStateBinding(r *http.Request, rp RelyingParty) (string, error) {
+	config := statelessState(rp)
+	if config == nil {
+		return "", ErrStatelessStateDisabled
+	}
+	if !config.BindUserAgent {
+		return "", nil
+	}
+	if rp.CookieHandler() == nil {
+		return "", ErrStatelessStateCookieHandler
+	}
+	binding, err := rp.CookieHandler().CheckCookie(r, statelessStateCookie)
+	if err != nil || binding == "" {
+		return "", ErrStatelessStateBinding
+	}
+	return binding, nil
+}
+
+// NewStatelessState returns a state value signed with the key of [WithStatelessState],
+// embedding the target, and the code challenge to send with the auth request.
+// Both are bound to the binding, a per-browser secret, see [SetStatelessStateBinding],
+// which is empty unless [StatelessStateConfig.BindUserAgent] is set.
+func NewStatelessState(rp RelyingParty, target, binding string) (state, codeChallenge string, err error) {
+	config := statelessState(rp)
+	if config == nil {
+		return "", "", ErrStatelessStateDisabled
+	}
+	if config.BindUserAgent && binding == "" {
+		return "", "", ErrStatelessStateBinding
+	}
+	id, err := randomString(rp, stateBytes)
+	if err != nil {
+		return "", "", err
+	}
+	codeChallenge = oidc.NewSHACodeChallenge(config.codeVerifier(id, binding))
+	payload, err := json.Marshal(&StatelessState{
+		ID:            id,
+		IssuedAt:      oidc.NowTime(),
+		Target:        target,
+		CodeChallenge: codeChallenge,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	signature := base64.RawURLEncoding.EncodeToString(config.mac("state", encoded, binding))
+	return encoded + "." + signature, codeChallenge, nil
+}
+
+// VerifyStatelessState verifies the signature, binding and age of the state value
+// and returns its payload and the PKCE code verifier for the code exchange.
+// With a Replay cache, each state value is only accepted once.
+func VerifyStatelessState(ctx context.Context, rp RelyingParty, state, binding string) (*StatelessState, string, error) {
+	config := statelessState(rp)
+	if config == nil {
+		return nil, "", ErrStatelessStateDisabled
+	}
+	if config.BindUserAgent && binding == "" {
+		return nil, "", ErrStatelessStateBinding
+	}
+	encoded, signature, ok := strings.Cut(state, ".")
+	if !ok {
+		return nil, "", ErrStatelessStateInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, config.mac("state", encoded, binding)) {
+		return nil, "", ErrStatelessStateInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", ErrStatelessStateInvalid
+	}
+	s := new(StatelessState)
+	if err = json.Unmarshal(payload, s); err != nil {
+		return nil, "", ErrStatelessStateInvalid
+	}
+	ttl := time.Until(s.IssuedAt.AsTime().Add(config.maxAge()))
+	if ttl <= 0 {
+		return nil, "", ErrStatelessStateExpired
+	}
+	codeVerifier := config.codeVerifier(s.ID, binding)
+	if oidc.NewSHACodeChallenge(codeVerifier) != s.CodeChallenge {
+		return nil, "", ErrStatelessStateInvalid
+	}
+	if config.Replay == nil {
+		return s, codeVerifier, nil
+	}
+	if err = cache.CheckReplay(ctx, config.Replay, "rp_state:"+s.ID, ttl); err != nil {
+		if errors.Is(err, cache.ErrReplay) {
+			return nil, "", ErrStatelessStateReplayed
+		}
+		return nil, "", err
+	}
+	return s, codeVerifier, nil
+}
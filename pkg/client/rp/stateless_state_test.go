@@ -0,0 +1,211 @@
+package rp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestWithStatelessState(t *testing.T) {
+	const redirectURI = "https://app.example.com/callback"
+	key := []byte(strings.Repeat("k", 32))
+
+	var codeChallenge string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "code1", r.FormValue("code"))
+		assert.Equal(t, codeChallenge, oidc.NewSHACodeChallenge(r.FormValue("code_verifier")))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access1","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+	cookieHandler := httphelper.NewCookieHandler(key, key, httphelper.WithUnsecure(), httphelper.WithSameSite(http.SameSiteNoneMode))
+	newRP := func(t *testing.T, config StatelessStateConfig) RelyingParty {
+		rp, err := NewRelyingPartyOAuth(&oauth2.Config{
+			ClientID:    "client",
+			RedirectURL: redirectURI,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  "https://op.example.com/authorize",
+				TokenURL: server.URL + "/token",
+			},
+		}, WithStatelessState(config), WithCookieHandler(cookieHandler))
+		require.NoError(t, err)
+		return rp
+	}
+
+	_, err := NewRelyingPartyOAuth(&oauth2.Config{}, WithStatelessState(StatelessStateConfig{Key: []byte("short")}))
+	require.ErrorIs(t, err, ErrStatelessStateKey)
+
+	t.Run("code flow", func(t *testing.T) {
+		rp := newRP(t, StatelessStateConfig{Key: key, BindUserAgent: true, Replay: cache.NewMemory()})
+		w := httptest.NewRecorder()
+		AuthURLHandler(func() string { return "/orders" }, rp)(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		binding := cookies[0]
+		assert.Equal(t, statelessStateCookie, binding.Name)
+		assert.True(t, binding.HttpOnly)
+		assert.False(t, binding.Secure, "the attributes are taken from the CookieHandler")
+		assert.Equal(t, http.SameSiteNoneMode, binding.SameSite)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		state := location.Query().Get("state")
+		codeChallenge = location.Query().Get("code_challenge")
+		require.NotEmpty(t, codeChallenge)
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+
+		var gotState string
+		callback := func(w http.ResponseWriter, r *http.Request, tokens *oidc.Tokens[*oidc.IDTokenClaims], state string, rp RelyingParty) {
+			gotState = state
+			assert.Equal(t, "access1", tokens.AccessToken)
+		}
+		callbackRequest := func(state string, binding *http.Cookie) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/callback?code=code1&state="+url.QueryEscape(state), nil)
+			if binding != nil {
+				r.AddCookie(binding)
+			}
+			return r
+		}
+
+		// another user agent cannot redeem the leaked state and code
+		for _, other := range []*http.Cookie{nil, {Name: statelessStateCookie, Value: "other"}} {
+			w = httptest.NewRecorder()
+			CodeExchangeHandler(callback, rp)(w, callbackRequest(state, other))
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		CodeExchangeHandler(callback, rp)(w, callbackRequest(state, binding))
+		assert.Equal(t, "/orders", gotState)
+
+		w = httptest.NewRecorder()
+		CodeExchangeHandler(callback, rp)(w, callbackRequest(state+"x", binding))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// the binding is reused for further logins
+		r := httptest.NewRequest(http.MethodGet, "/login", nil)
+		r.AddCookie(binding)
+		w = httptest.NewRecorder()
+		AuthURLHandler(func() string { return "/orders" }, rp)(w, r)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("without binding", func(t *testing.T) {
+		rp := newRP(t, StatelessStateConfig{Key: key})
+		w := httptest.NewRecorder()
+		AuthURLHandler(func() string { return "/orders" }, rp)(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Empty(t, w.Result().Cookies(), "no cookie is needed")
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		codeChallenge = location.Query().Get("code_challenge")
+
+		var gotState string
+		callback := func(w http.ResponseWriter, r *http.Request, tokens *oidc.Tokens[*oidc.IDTokenClaims], state string, rp RelyingParty) {
+			gotState = state
+		}
+		w = httptest.NewRecorder()
+		CodeExchangeHandler(callback, rp)(w, httptest.NewRequest(http.MethodGet, "/callback?code=code1&state="+url.QueryEscape(location.Query().Get("state")), nil))
+		assert.Equal(t, "/orders", gotState)
+	})
+
+	t.Run("binding without cookie handler", func(t *testing.T) {
+		rp, err := NewRelyingPartyOAuth(&oauth2.Config{ClientID: "client"}, WithStatelessState(StatelessStateConfig{Key: key, BindUserAgent: true}))
+		require.NoError(t, err)
+		_, err = SetStatelessStateBinding(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/login", nil), rp)
+		assert.ErrorIs(t, err, ErrStatelessStateCookieHandler)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			key     []byte
+			maxAge  time.Duration
+			state   func(state string) string
+			binding string
+			wantErr error
+		}{
+			{
+				name: "valid",
+				key:  key,
+			},
+			{
+				name:    "other key",
+				key:     []byte(strings.Repeat("o", 32)),
+				wantErr: ErrStatelessStateInvalid,
+			},
+			{
+				name:    "other binding",
+				key:     key,
+				binding: "other",
+				wantErr: ErrStatelessStateInvalid,
+			},
+			{
+				name:    "expired",
+				key:     key,
+				maxAge:  time.Nanosecond,
+				wantErr: ErrStatelessStateExpired,
+			},
+			{
+				name:    "malformed",
+				key:     key,
+				state:   func(string) string { return "state" },
+				wantErr: ErrStatelessStateInvalid,
+			},
+		}
+		state, _, err := NewStatelessState(newRP(t, StatelessStateConfig{Key: key, Replay: cache.NewMemory()}), "target", "binding1")
+		require.NoError(t, err)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s := state
+				if tt.state != nil {
+					s = tt.state(state)
+				}
+				binding := "binding1"
+				if tt.binding != "" {
+					binding = tt.binding
+				}
+				rp := newRP(t, StatelessStateConfig{Key: tt.key, MaxAge: tt.maxAge, Replay: cache.NewMemory()})
+				got, codeVerifier, err := VerifyStatelessState(context.Background(), rp, s, binding)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, "target", got.Target)
+				assert.Equal(t, got.CodeChallenge, oidc.NewSHACodeChallenge(codeVerifier))
+			})
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		rp := newRP(t, StatelessStateConfig{Key: key, Replay: cache.NewMemory()})
+		state, _, err := NewStatelessState(rp, "", "binding1")
+		require.NoError(t, err)
+		_, _, err = VerifyStatelessState(context.Background(), rp, state, "binding1")
+		require.NoError(t, err)
+		_, _, err = VerifyStatelessState(context.Background(), rp, state, "binding1")
+		assert.ErrorIs(t, err, ErrStatelessStateReplayed)
+
+		rp = newRP(t, StatelessStateConfig{Key: key})
+		state, _, err = NewStatelessState(rp, "", "")
+		require.NoError(t, err)
+		for range 2 {
+			_, _, err = VerifyStatelessState(context.Background(), rp, state, "")
+			require.NoError(t, err, "without a Replay cache, the state may be reused within its MaxAge")
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package rp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+)
+
+// WithUserinfoCache caches the responses of the userinfo endpoint of [Userinfo]
+// by access token for the ttl. A nil cache uses a [cache.Memory].
+func WithUserinfoCache(c cache.Cache, ttl time.Duration) Option {
+	return func(rp *relyingParty) error {
+		if c == nil {
+			c = cache.NewMemory()
+		}
+		rp.userinfoCache = c
+		rp.userinfoTTL = ttl
+		return nil
+	}
+}
+
+// WithStalePolicy serves the responses of the [WithUserinfoCache] and the
+// keys of the [WithSharedCache] past their ttl, during outages of the OP,
+// according to the policy, see [cache.StalePolicy].
+// [cache.RecordStaleness] tells if a response was stale.
+func WithStalePolicy(policy cache.StalePolicy) Option {
+	return func(rp *relyingParty) error {
+		rp.stale = &policy
+		rp.keySetOpts = append(rp.keySetOpts, StaleKeyCache(&policy))
+		return nil
+	}
+}
+
+type userinfoCacher interface {
+	cachedUserinfoConfig() (cache.Cache, time.Duration, *cache.StalePolicy)
+}
+
+func (rp *relyingParty) cachedUserinfoConfig() (cache.Cache, time.Duration, *cache.StalePolicy) {
+	return rp.userinfoCache, rp.userinfoTTL, rp.stale
+}
+
+// userinfoCacheKey does not contain the token itself,
+// so it cannot be read from the cache.
+func userinfoCacheKey(userinfoURL, token string) string {
+	hash := sha256.Sum256([]byte(userinfoURL + " " + token))
+	return "userinfo:" + base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// cachedUserinfo returns the response of fetch from the cache of the rp, if set.
+func cachedUserinfo(ctx context.Context, rp RelyingParty, token string, fetch func(context.Context) (json.RawMessage, error)) (json.RawMessage, error) {
+	cacher, ok := rp.(userinfoCacher)
+	if !ok {
+		return fetch(ctx)
+	}
+	c, ttl, policy := cacher.cachedUserinfoConfig()
+	if c == nil {
+		return fetch(ctx)
+	}
+	return policy.Fetch(ctx, c, userinfoCacheKey(rp.UserinfoEndpoint(), token), func(ctx context.Context) (cache.Fetched, error) {
+		body, err := fetch(ctx)
+		if err != nil {
+			return cache.Fetched{}, err
+		}
+		return cache.Fetched{Value: body, TTL: ttl}, nil
+	})
+}
@@ -7,6 +7,7 @@ import (
 	jose "github.com/go-jose/go-jose/v4"
 
 	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
 
@@ -17,12 +18,15 @@ func VerifyTokens[C oidc.IDClaims](ctx context.Context, accessToken, idToken str
 	defer span.End()
 
 	var nilClaims C
+	explanation := oidc.ExplanationFromContext(ctx)
+	defer func() { explanation.Decide(err) }()
 
 	claims, err = VerifyIDToken[C](ctx, idToken, v)
 	if err != nil {
 		return nilClaims, err
 	}
-	if err := VerifyAccessToken(accessToken, claims.GetAccessTokenHash(), claims.GetSignatureAlgorithm()); err != nil {
+	if err := explanation.Check("at_hash", VerifyAccessToken(accessToken, claims.GetAccessTokenHash(), claims.GetSignatureAlgorithm()),
+		"actual", claims.GetAccessTokenHash()); err != nil {
 		return nilClaims, err
 	}
 	return claims, nil
@@ -35,60 +39,89 @@ func VerifyIDToken[C oidc.Claims](ctx context.Context, token string, v *IDTokenV
 	defer span.End()
 
 	var nilClaims C
+	explanation := oidc.ExplanationFromContext(ctx)
+	defer func() { explanation.Decide(err) }()
 
 	decrypted, err := oidc.DecryptToken(token)
 	if err != nil {
 		return nilClaims, err
 	}
 	payload, err := oidc.ParseToken(decrypted, &claims)
-	if err != nil {
+	if err = explanation.Check("parse", err); err != nil {
 		return nilClaims, err
 	}
 
-	if err := oidc.CheckSubject(claims); err != nil {
+	if err := explanation.Check("sub", oidc.CheckSubject(claims), "actual", claims.GetSubject()); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckIssuer(claims, v.Issuer); err != nil {
+	if err = explanation.Check("iss", oidc.CheckIssuerMatch(claims, v.Issuer, v.AllowInsecure),
+		"expected", v.Issuer, "actual", claims.GetIssuer()); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckAudience(claims, v.ClientID); err != nil {
+	if err = explanation.Check("aud", oidc.CheckAudience(claims, v.ClientID),
+		"expected", v.ClientID, "actual", claims.GetAudience()); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckAuthorizedParty(claims, v.ClientID); err != nil {
+	if err = explanation.Check("azp", oidc.CheckAuthorizedParty(claims, v.ClientID),
+		"expected", v.ClientID, "actual", claims.GetAuthorizedParty()); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckSignature(ctx, decrypted, payload, claims, v.SupportedSignAlgs, v.KeySet); err != nil {
+	algs, err := v.signingAlgorithms()
+	if err != nil {
+		return nilClaims, err
+	}
+	if err = oidc.CheckSignature(ctx, decrypted, payload, claims, algs, v.KeySet); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckExpiration(claims, v.Offset); err != nil {
+	if err = explanation.Check("exp", oidc.CheckExpiration(claims, v.Offset),
+		"actual", claims.GetExpiration(), "offset", v.Offset); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckIssuedAt(claims, v.MaxAgeIAT, v.Offset); err != nil {
+	if err = explanation.Check("iat", oidc.CheckIssuedAt(claims, v.MaxAgeIAT, v.Offset),
+		"actual", claims.GetIssuedAt(), "max_age", v.MaxAgeIAT, "offset", v.Offset); err != nil {
 		return nilClaims, err
 	}
 
 	if v.Nonce != nil {
-		if err = oidc.CheckNonce(claims, v.Nonce(ctx)); err != nil {
+		nonce := v.Nonce(ctx)
+		if err = explanation.Check("nonce", oidc.CheckNonce(claims, nonce),
+			"expected", nonce, "actual", claims.GetNonce()); err != nil {
 			return nilClaims, err
 		}
 	}
 
-	if err = oidc.CheckAuthorizationContextClassReference(claims, v.ACR); err != nil {
+	if err = explanation.Check("acr", oidc.CheckAuthorizationContextClassReference(claims, v.ACR),
+		"actual", claims.GetAuthenticationContextClassReference()); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckAuthTime(claims, v.MaxAge); err != nil {
+	if err = explanation.Check("auth_time", oidc.CheckAuthTime(claims, v.MaxAge),
+		"actual", claims.GetAuthTime(), "max_age", v.MaxAge); err != nil {
 		return nilClaims, err
 	}
+
+	if v.Email != nil {
+		if err = explanation.Check("email", oidc.CheckEmail(payload, v.Email)); err != nil {
+			return nilClaims, err
+		}
+	}
 	return claims, nil
 }
 
+// VerifyIDTokenWithResult is like [VerifyIDToken], but returns the claims
+// with the metadata of the token and the checks applied.
+func VerifyIDTokenWithResult[C oidc.Claims](ctx context.Context, token string, v *IDTokenVerifier) (*oidc.ValidationResult[C], error) {
+	return oidc.ValidateWithResult(ctx, token, func(ctx context.Context) (C, error) {
+		return VerifyIDToken[C](ctx, token, v)
+	})
+}
+
 type IDTokenVerifier oidc.Verifier
 
 // VerifyAccessToken validates the access token according to
@@ -118,6 +151,7 @@ func NewIDTokenVerifier(issuer, clientID string, keySet oidc.KeySet, options ...
 		Nonce: func(_ context.Context) string {
 			return ""
 		},
+		FIPS: crypto.DefaultFIPS(),
 	}
 
 	for _, opts := range options {
@@ -172,3 +206,26 @@ func WithSupportedSigningAlgorithms(algs ...string) VerifierOption {
 		v.SupportedSignAlgs = algs
 	}
 }
+
+// WithFIPS restricts the signing algorithms to the ones approved by FIPS 140-3.
+// It is enabled by default, if the toolchain runs in FIPS mode, see [crypto.DefaultFIPS].
+func WithFIPS(fips crypto.FIPS) VerifierOption {
+	return func(v *IDTokenVerifier) {
+		v.FIPS = &fips
+	}
+}
+
+// signingAlgorithms returns the supported signing algorithms,
+// which are approved, if the FIPS mode is enabled.
+func (v *IDTokenVerifier) signingAlgorithms() ([]string, error) {
+	return v.FIPS.ApprovedAlgorithms(v.SupportedSignAlgs)
+}
+
+// WithVerifiedEmail rejects ID tokens without a verified email address.
+// Optionally the domains of the email address and of the hd claim can be restricted,
+// see [oidc.CheckEmail].
+func WithVerifiedEmail(policy oidc.EmailPolicy) VerifierOption {
+	return func(v *IDTokenVerifier) {
+		v.Email = &policy
+	}
+}
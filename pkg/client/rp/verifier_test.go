@@ -7,6 +7,7 @@ import (
 
 	jose "github.com/go-jose/go-jose/v4"
 	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -357,3 +358,135 @@ func TestNewIDTokenVerifier(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyIDToken_explain(t *testing.T) {
+	verifier := &IDTokenVerifier{
+		Issuer:            tu.ValidIssuer,
+		MaxAgeIAT:         2 * time.Minute,
+		Offset:            time.Second,
+		SupportedSignAlgs: []string{string(tu.SignatureAlgorithm)},
+		KeySet:            tu.KeySet{},
+		MaxAge:            2 * time.Minute,
+		ACR:               tu.ACRVerify,
+		Nonce:             func(context.Context) string { return tu.ValidNonce },
+		ClientID:          tu.ValidClientID,
+	}
+	checkNames := func(explanation *oidc.Explanation) (names []string) {
+		for _, c := range explanation.Checks() {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		ctx, explanation := oidc.WithExplanation(context.Background())
+		token, _ := tu.ValidIDToken()
+		_, err := VerifyIDToken[*oidc.IDTokenClaims](ctx, token, verifier)
+		require.NoError(t, err)
+		assert.True(t, explanation.Valid())
+		assert.Equal(t, []string{"parse", "sub", "iss", "aud", "azp", "signature", "exp", "iat", "nonce", "acr", "auth_time"}, checkNames(explanation))
+	})
+	t.Run("rejected", func(t *testing.T) {
+		ctx, explanation := oidc.WithExplanation(context.Background())
+		token, _ := tu.NewIDToken(
+			"foo", tu.ValidSubject, tu.ValidAudience,
+			tu.ValidExpiration, tu.ValidAuthTime, tu.ValidNonce,
+			tu.ValidACR, tu.ValidAMR, tu.ValidClientID, tu.ValidSkew, "",
+		)
+		_, err := VerifyIDToken[*oidc.IDTokenClaims](ctx, token, verifier)
+		require.ErrorIs(t, err, oidc.ErrIssuerInvalid)
+		assert.False(t, explanation.Valid())
+		assert.ErrorIs(t, explanation.Err(), oidc.ErrIssuerInvalid)
+
+		checks := explanation.Checks()
+		require.Equal(t, []string{"parse", "sub", "iss"}, checkNames(explanation))
+		iss := checks[2]
+		assert.False(t, iss.Passed())
+		assert.Equal(t, map[string]any{"expected": tu.ValidIssuer, "actual": "foo"}, iss.Values)
+	})
+}
+
+func TestVerifyIDToken_fips(t *testing.T) {
+	token, _ := tu.ValidIDToken()
+	verifier := NewIDTokenVerifier(tu.ValidIssuer, tu.ValidClientID, tu.KeySet{},
+		WithSupportedSigningAlgorithms("EdDSA", string(tu.SignatureAlgorithm)),
+		WithNonce(func(context.Context) string { return tu.ValidNonce }),
+		WithFIPS(crypto.FIPS{}),
+	)
+	_, err := VerifyIDToken[*oidc.IDTokenClaims](context.Background(), token, verifier)
+	require.NoError(t, err)
+
+	verifier.SupportedSignAlgs = []string{"EdDSA"}
+	_, err = VerifyIDToken[*oidc.IDTokenClaims](context.Background(), token, verifier)
+	assert.ErrorIs(t, err, crypto.ErrNotFIPSApproved)
+}
+
+func TestVerifyIDToken_email(t *testing.T) {
+	verifier := NewIDTokenVerifier(tu.ValidIssuer, tu.ValidClientID, tu.KeySet{},
+		WithSupportedSigningAlgorithms(string(tu.SignatureAlgorithm)),
+		WithNonce(func(context.Context) string { return tu.ValidNonce }),
+		WithVerifiedEmail(oidc.EmailPolicy{Domains: []string{"example.com"}}),
+	)
+	newToken := func(claims map[string]any) string {
+		token, _ := tu.NewIDTokenCustom(
+			tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience,
+			tu.ValidExpiration, tu.ValidAuthTime, tu.ValidNonce,
+			tu.ValidACR, tu.ValidAMR, tu.ValidClientID, tu.ValidSkew, "",
+			claims,
+		)
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		wantErr error
+	}{
+		{
+			name:    "missing",
+			wantErr: oidc.ErrEmailNotVerified,
+		},
+		{
+			name:    "not verified",
+			claims:  map[string]any{"email": "jdoe@example.com", "email_verified": false},
+			wantErr: oidc.ErrEmailNotVerified,
+		},
+		{
+			name:    "domain not allowed",
+			claims:  map[string]any{"email": "jdoe@example.org", "email_verified": true},
+			wantErr: oidc.ErrEmailDomain,
+		},
+		{
+			name:   "verified",
+			claims: map[string]any{"email": "jdoe@example.com", "email_verified": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyIDToken[*oidc.IDTokenClaims](context.Background(), newToken(tt.claims), verifier)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "jdoe@example.com", got.Email)
+		})
+	}
+}
+
+func TestVerifyIDTokenWithResult(t *testing.T) {
+	verifier := NewIDTokenVerifier(tu.ValidIssuer, tu.ValidClientID, tu.KeySet{},
+		WithSupportedSigningAlgorithms(string(tu.SignatureAlgorithm)),
+		WithNonce(func(context.Context) string { return tu.ValidNonce }),
+	)
+	token, _ := tu.ValidIDToken()
+	got, err := VerifyIDTokenWithResult[*oidc.IDTokenClaims](context.Background(), token, verifier)
+	require.NoError(t, err)
+	assert.Equal(t, tu.ValidSubject, got.Claims.Subject)
+	assert.Equal(t, oidc.ValidationFormatJWT, got.Format)
+	assert.Equal(t, tu.ValidIssuer, got.Issuer)
+	assert.NotEmpty(t, got.Checks)
+
+	_, err = VerifyIDTokenWithResult[*oidc.IDTokenClaims](context.Background(), "~~~~", verifier)
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package rs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+var (
+	ErrAccessTokenType = errors.New("resource server: token is not of type at+jwt")
+	ErrOpaqueToken     = errors.New("resource server: token is not a JWT")
+)
+
+// WithAudience sets the audience, which JWT access tokens must contain
+// to be accepted by [VerifyAccessToken]. Defaults to the client ID of the resource server.
+func WithAudience(audience string) Option {
+	return func(server *resourceServer) {
+		server.audience = audience
+	}
+}
+
+// WithIntrospectionFallback introspects tokens, which are not JWTs,
+// in [VerifyAccessToken], instead of rejecting them with [ErrOpaqueToken].
+func WithIntrospectionFallback() Option {
+	return func(server *resourceServer) {
+		server.introspectionFallback = true
+	}
+}
+
+type accessTokenVerifier interface {
+	tokenValidator
+	Audience() string
+	IntrospectionFallback() bool
+}
+
+func (r *resourceServer) Audience() string {
+	return r.audience
+}
+
+func (r *resourceServer) IntrospectionFallback() bool {
+	return r.introspectionFallback
+}
+
+// VerifyAccessToken validates the JWT access token locally according to
+// [RFC9068], without a request to the introspection endpoint.
+// The keys of the issuer are fetched from its jwks_uri and cached, see [WithKeySet].
+// The typ header must be at+jwt and the iss, aud, nbf, exp claims and signature valid.
+// The claims are returned in an instance of type C, such as [*oidc.AccessTokenClaims].
+// Opaque tokens are only introspected, if enabled by [WithIntrospectionFallback].
+//
+// [RFC9068]: https://www.rfc-editor.org/rfc/rfc9068
+func VerifyAccessToken[C oidc.Claims](ctx context.Context, rs ResourceServer, token string) (claims C, err error) {
+	ctx, span := client.Tracer.Start(ctx, "VerifyAccessToken")
+	defer span.End()
+
+	var nilClaims C
+	v, ok := rs.(accessTokenVerifier)
+	if !ok {
+		return nilClaims, ErrNoKeySet
+	}
+	header, _, err := oidc.DecodeUnverified[map[string]any](token)
+	if err != nil {
+		if v.IntrospectionFallback() {
+			return introspectClaims[C](ctx, rs, token)
+		}
+		return nilClaims, fmt.Errorf("%w: %w", ErrOpaqueToken, err)
+	}
+	keySet := v.KeySet()
+	if keySet == nil {
+		return nilClaims, ErrNoKeySet
+	}
+	payload, err := oidc.ParseToken(token, &claims)
+	if err != nil {
+		return nilClaims, err
+	}
+	if err = checkAccessTokenJWT(ctx, v, keySet, token, header.Type, payload, claims); err != nil {
+		return nilClaims, err
+	}
+	if err = oidc.CheckExpiration(claims, 0); err != nil {
+		return nilClaims, fmt.Errorf("%w: %w", ErrTokenInactive, err)
+	}
+	return claims, nil
+}
+
+// checkAccessTokenJWT checks the typ header, the iss, aud and nbf claims
+// and the signature of the parsed JWT access token (RFC 9068, section 4).
+func checkAccessTokenJWT(ctx context.Context, v accessTokenVerifier, keySet oidc.KeySet, token, typ string, payload []byte, claims oidc.Claims) error {
+	explanation := oidc.ExplanationFromContext(ctx)
+	var err error
+	if !isAccessTokenType(typ) {
+		err = fmt.Errorf("%w: got %q", ErrAccessTokenType, typ)
+	}
+	if err = explanation.Check("typ", err, "actual", typ); err != nil {
+		return err
+	}
+	if err = explanation.Check("iss", oidc.CheckIssuerMatch(claims, v.Issuer(), v.AllowInsecure()),
+		"expected", v.Issuer(), "actual", claims.GetIssuer()); err != nil {
+		return err
+	}
+	if err = explanation.Check("aud", oidc.CheckAudience(claims, v.Audience()),
+		"expected", v.Audience(), "actual", claims.GetAudience()); err != nil {
+		return err
+	}
+	if err = explanation.Check("nbf", oidc.CheckNotBefore(claims, 0)); err != nil {
+		return err
+	}
+	return oidc.CheckSignature(ctx, token, payload, claims, nil, keySet)
+}
+
+// isAccessTokenType reports if the typ header is at+jwt,
+// the media type application/at+jwt may be used as well (RFC 9068, section 4).
+func isAccessTokenType(typ string) bool {
+	typ = strings.ToLower(typ)
+	return typ == "at+jwt" || typ == "application/at+jwt"
+}
+
+// introspectClaims introspects the token, which must be active,
+// and decodes the response in an instance of type C.
+func introspectClaims[C any](ctx context.Context, rs ResourceServer, token string) (claims C, err error) {
+	body, err := Introspect[json.RawMessage](ctx, rs, token)
+	if err != nil {
+		return claims, err
+	}
+	var status struct {
+		Active bool `json:"active"`
+	}
+	if err = json.Unmarshal(body, &status); err != nil {
+		return claims, err
+	}
+	if !status.Active {
+		return claims, ErrTokenInactive
+	}
+	err = json.Unmarshal(body, &claims)
+	return claims, err
+}
@@ -0,0 +1,81 @@
+package rs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func newTypedAccessToken(t *testing.T, typ string, claims *oidc.AccessTokenClaims) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: tu.SignatureAlgorithm, Key: tu.WebKey}, (&jose.SignerOptions{}).WithType(jose.ContentType(typ)))
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	jws, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := jws.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestVerifyAccessToken(t *testing.T) {
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{
+			Active:  r.FormValue("token") == "opaque",
+			Subject: "introspected",
+		})
+	}))
+	defer introspection.Close()
+
+	newRS := func(t *testing.T, options ...Option) ResourceServer {
+		options = append(options, WithKeySet(tu.KeySet{}), WithStaticEndpoints(introspection.URL+"/token", introspection.URL+"/introspect"))
+		rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, "api", "secret", options...)
+		require.NoError(t, err)
+		return rs
+	}
+	newClaims := func(audience []string, expiration time.Time) *oidc.AccessTokenClaims {
+		return oidc.NewAccessTokenClaims(tu.ValidIssuer, tu.ValidSubject, audience, expiration, tu.ValidJWTID, tu.ValidClientID, 0)
+	}
+	valid := newTypedAccessToken(t, "at+jwt", newClaims([]string{"api"}, tu.ValidExpiration))
+	untyped, _ := tu.NewAccessToken(tu.ValidIssuer, tu.ValidSubject, []string{"api"}, tu.ValidExpiration, tu.ValidJWTID, tu.ValidClientID, 0)
+
+	tests := []struct {
+		name        string
+		options     []Option
+		token       string
+		wantSubject string
+		wantErr     error
+	}{
+		{"valid", nil, valid, tu.ValidSubject, nil},
+		{"media type", nil, newTypedAccessToken(t, "application/AT+JWT", newClaims([]string{"api"}, tu.ValidExpiration)), tu.ValidSubject, nil},
+		{"custom audience", []Option{WithAudience("other")}, newTypedAccessToken(t, "at+jwt", newClaims([]string{"other"}, tu.ValidExpiration)), tu.ValidSubject, nil},
+		{"wrong audience", nil, newTypedAccessToken(t, "at+jwt", newClaims([]string{"other"}, tu.ValidExpiration)), "", oidc.ErrAudience},
+		{"expired", nil, newTypedAccessToken(t, "at+jwt", newClaims([]string{"api"}, time.Now().Add(-time.Hour))), "", ErrTokenInactive},
+		{"wrong type", nil, untyped, "", ErrAccessTokenType},
+		{"opaque", nil, "opaque", "", ErrOpaqueToken},
+		{"introspected", []Option{WithIntrospectionFallback()}, "opaque", "introspected", nil},
+		{"introspected inactive", []Option{WithIntrospectionFallback()}, "inactive", "", ErrTokenInactive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyAccessToken[*oidc.AccessTokenClaims](context.Background(), newRS(t, tt.options...), tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSubject, got.Subject)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package rs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// Decision is the result of an [Authorizer].
+type Decision struct {
+	Allow bool `json:"allow"`
+	// Reason of the decision, returned in the 403 response of [Middleware] on deny.
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuthorizationInput is the input of an [Authorizer].
+type AuthorizationInput struct {
+	Claims      *oidc.IntrospectionResponse `json:"claims"`
+	Permissions []Permission                `json:"permissions,omitempty"`
+	Method      string                      `json:"method"`
+	Path        string                      `json:"path"`
+}
+
+// Authorizer evaluates validated token claims against an external policy engine,
+// such as OPA or Casbin, see [WithAuthorizer].
+type Authorizer interface {
+	Authorize(ctx context.Context, input *AuthorizationInput) (Decision, error)
+}
+
+// AuthorizerFunc is a function implementing [Authorizer],
+// e.g. to evaluate an embedded OPA (rego / wasm) policy.
+type AuthorizerFunc func(ctx context.Context, input *AuthorizationInput) (Decision, error)
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, input *AuthorizationInput) (Decision, error) {
+	return f(ctx, input)
+}
+
+// OPAAuthorizer queries the Data API of an Open Policy Agent server:
+// the [AuthorizationInput] is posted as `input` to the URL of a policy
+// decision, e.g. "http://localhost:8181/v1/data/api/authz".
+//
+// The policy result may either be a boolean, or an object
+// with `allow` and an optional `reason`.
+type OPAAuthorizer struct {
+	URL        string
+	HttpClient *http.Client
+}
+
+func (o *OPAAuthorizer) Authorize(ctx context.Context, input *AuthorizationInput) (Decision, error) {
+	body, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return Decision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpClient := o.HttpClient
+	if httpClient == nil {
+		httpClient = httphelper.DefaultHTTPClient
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err = httphelper.HttpRequest(httpClient, req, &resp); err != nil {
+		return Decision{}, fmt.Errorf("opa: %w", err)
+	}
+	var decision Decision
+	if err = json.Unmarshal(resp.Result, &decision.Allow); err == nil {
+		return decision, nil
+	}
+	if err = json.Unmarshal(resp.Result, &decision); err != nil {
+		return Decision{}, fmt.Errorf("opa: unexpected result %s", resp.Result)
+	}
+	return decision, nil
+}
+
+// CasbinEnforcer is implemented by the Enforcer of Casbin
+// (github.com/casbin/casbin/v2).
+type CasbinEnforcer interface {
+	Enforce(rvals ...any) (bool, error)
+}
+
+// CasbinAuthorizer enforces a Casbin model with the request
+// (subject, path, method), e.g. for the RESTful model:
+//
+//	[request_definition]
+//	r = sub, obj, act
+type CasbinAuthorizer struct {
+	Enforcer CasbinEnforcer
+	// Subject returns the Casbin subject of the claims, defaults to the `sub` claim.
+	Subject func(claims *oidc.IntrospectionResponse) string
+}
+
+func (c *CasbinAuthorizer) Authorize(_ context.Context, input *AuthorizationInput) (Decision, error) {
+	subject := input.Claims.Subject
+	if c.Subject != nil {
+		subject = c.Subject(input.Claims)
+	}
+	allow, err := c.Enforcer.Enforce(subject, input.Path, input.Method)
+	if err != nil {
+		return Decision{}, fmt.Errorf("casbin: %w", err)
+	}
+	if !allow {
+		return Decision{Reason: fmt.Sprintf("casbin: %s is not allowed to %s %s", subject, input.Method, input.Path)}, nil
+	}
+	return Decision{Allow: true}, nil
+}
@@ -0,0 +1,130 @@
+package rs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestOPAAuthorizer(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  string
+		want    Decision
+		wantErr bool
+	}{
+		{"bool allow", `true`, Decision{Allow: true}, false},
+		{"bool deny", `false`, Decision{}, false},
+		{"object", `{"allow": false, "reason": "not admin"}`, Decision{Reason: "not admin"}, false},
+		{"undefined", `"foo"`, Decision{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Input AuthorizationInput `json:"input"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				assert.Equal(t, "user", body.Input.Claims.Subject)
+				assert.Equal(t, "/docs", body.Input.Path)
+				w.Write([]byte(`{"result":` + tt.result + `}`))
+			}))
+			defer server.Close()
+
+			o := &OPAAuthorizer{URL: server.URL}
+			got, err := o.Authorize(context.Background(), &AuthorizationInput{
+				Claims: &oidc.IntrospectionResponse{Subject: "user"},
+				Method: http.MethodGet,
+				Path:   "/docs",
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type casbinEnforcer func(rvals ...any) (bool, error)
+
+func (e casbinEnforcer) Enforce(rvals ...any) (bool, error) {
+	return e(rvals...)
+}
+
+func TestCasbinAuthorizer(t *testing.T) {
+	c := &CasbinAuthorizer{
+		Enforcer: casbinEnforcer(func(rvals ...any) (bool, error) {
+			if rvals[0] == "err" {
+				return false, errors.New("fail")
+			}
+			return rvals[0] == "alice" && rvals[1] == "/docs" && rvals[2] == http.MethodGet, nil
+		}),
+	}
+	authorize := func(sub string) (Decision, error) {
+		return c.Authorize(context.Background(), &AuthorizationInput{
+			Claims: &oidc.IntrospectionResponse{Subject: sub},
+			Method: http.MethodGet,
+			Path:   "/docs",
+		})
+	}
+	got, err := authorize("alice")
+	require.NoError(t, err)
+	assert.True(t, got.Allow)
+
+	got, err = authorize("bob")
+	require.NoError(t, err)
+	assert.False(t, got.Allow)
+	assert.Equal(t, "casbin: bob is not allowed to GET /docs", got.Reason)
+
+	_, err = authorize("err")
+	assert.Error(t, err)
+}
+
+func TestMiddleware_authorizer(t *testing.T) {
+	rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+	)
+	require.NoError(t, err)
+	token, _ := tu.ValidJWTAccessToken()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		decision   Decision
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{"allow", Decision{Allow: true}, nil, http.StatusOK, ""},
+		{"deny", Decision{Reason: "outside business hours"}, nil, http.StatusForbidden, "Forbidden: outside business hours\n"},
+		{"error", Decision{}, errors.New("fail"), http.StatusInternalServerError, "Internal Server Error\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizer := AuthorizerFunc(func(_ context.Context, input *AuthorizationInput) (Decision, error) {
+				assert.Equal(t, tu.ValidSubject, input.Claims.Subject)
+				assert.Equal(t, []Permission{"read"}, input.Permissions)
+				return tt.decision, tt.err
+			})
+			mapper := PermissionMapper{{Claim: "sub", Values: map[string][]Permission{tu.ValidSubject: {"read"}}}}
+
+			r := httptest.NewRequest(http.MethodGet, "/docs", nil)
+			r.Header.Set("Authorization", oidc.PrefixBearer+token)
+			w := httptest.NewRecorder()
+			Middleware(rs, mapper, nil, WithAuthorizer(authorizer))(next).ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
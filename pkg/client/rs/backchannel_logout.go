@@ -0,0 +1,34 @@
+package rs
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// RevokeOnLogout returns a [rp.LogoutFunc], which revokes the session or
+// subject of the logout token in the denylist, see [CacheDenylist.Logout],
+// before terminating the local sessions by logout, if not nil.
+func RevokeOnLogout(denylist *CacheDenylist, logout rp.LogoutFunc) rp.LogoutFunc {
+	return func(ctx context.Context, claims *oidc.LogoutTokenClaims) error {
+		if err := denylist.Logout(ctx, claims); err != nil {
+			return err
+		}
+		if logout == nil {
+			return nil
+		}
+		return logout(ctx, claims)
+	}
+}
+
+// BackChannelLogoutHandler is [rp.BackChannelLogoutHandler] with [RevokeOnLogout].
+// Resource servers consulting a [CacheDenylist] with the same shared cache,
+// like the redis subpackage, by [WithDenylist] reject the access tokens
+// of the logged out sessions right away, across services.
+// Sessions are matched by the sid claim of the access tokens,
+// which the OP must include, subjects by the sub claim.
+func BackChannelLogoutHandler(relyingParty rp.RelyingParty, denylist *CacheDenylist, logout rp.LogoutFunc) http.HandlerFunc {
+	return rp.BackChannelLogoutHandler(relyingParty, RevokeOnLogout(denylist, logout))
+}
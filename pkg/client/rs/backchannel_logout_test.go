@@ -0,0 +1,62 @@
+package rs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type logoutRelyingParty struct {
+	rp.RelyingParty
+}
+
+func (logoutRelyingParty) IDTokenVerifier() *rp.IDTokenVerifier {
+	return rp.NewIDTokenVerifier(tu.ValidIssuer, tu.ValidClientID, tu.KeySet{},
+		rp.WithSupportedSigningAlgorithms(string(tu.SignatureAlgorithm)),
+	)
+}
+
+func TestBackChannelLogoutHandler(t *testing.T) {
+	ctx := context.Background()
+	shared := cache.NewMemory()
+	// the denylists of the relying party and of another service share the cache
+	rs, err := NewResourceServerClientCredentials(ctx, tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+		WithDenylist(NewCacheDenylist(shared, time.Hour)),
+	)
+	require.NoError(t, err)
+	token, _ := tu.NewJWTAccessTokenCustom(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, tu.ValidExpiration, tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew,
+		map[string]any{"sid": "sid1"})
+	_, err = ValidateToken(ctx, rs, token)
+	require.NoError(t, err)
+
+	var sessions []string
+	handler := BackChannelLogoutHandler(logoutRelyingParty{}, NewCacheDenylist(shared, time.Hour), func(_ context.Context, claims *oidc.LogoutTokenClaims) error {
+		sessions = append(sessions, claims.SessionID)
+		return nil
+	})
+	logoutToken, err := crypto.Sign(oidc.NewLogoutTokenClaims(tu.ValidIssuer, tu.ValidSubject, oidc.Audience{tu.ValidClientID}, tu.ValidExpiration, "jti1", "sid1", 0), tu.LogoutTokenSigner)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/backchannel_logout", strings.NewReader(url.Values{"logout_token": {logoutToken}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"sid1"}, sessions)
+
+	_, err = ValidateToken(ctx, rs, token)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
@@ -0,0 +1,264 @@
+package rs
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+const (
+	// GitHubActionsIssuer is the issuer of the OIDC tokens of GitHub Actions jobs
+	// on github.com. Enterprises with a custom issuer use
+	// https://token.actions.githubusercontent.com/<enterprise>.
+	GitHubActionsIssuer = "https://token.actions.githubusercontent.com"
+	// GitLabIssuer is the issuer of the ID tokens of GitLab CI/CD jobs on gitlab.com.
+	// Self-managed instances issue tokens with their own URL.
+	GitLabIssuer = "https://gitlab.com"
+	// CircleCIIssuer is the prefix of the issuer of the OIDC tokens of CircleCI jobs,
+	// followed by the ID of the organization.
+	CircleCIIssuer = "https://oidc.circleci.com/org/"
+)
+
+// GitHubActionsClaims are the claims of the OIDC token of a GitHub Actions job,
+// whose sub is repo:<owner>/<repository>:<context>, such as
+// repo:octo-org/octo-repo:environment:prod or repo:octo-org/octo-repo:ref:refs/heads/main.
+type GitHubActionsClaims struct {
+	oidc.TokenClaims
+	Repository           string `json:"repository"`
+	RepositoryID         string `json:"repository_id"`
+	RepositoryOwner      string `json:"repository_owner"`
+	RepositoryOwnerID    string `json:"repository_owner_id"`
+	RepositoryVisibility string `json:"repository_visibility"`
+	Ref                  string `json:"ref"`
+	RefType              string `json:"ref_type"`
+	RefProtected         string `json:"ref_protected"`
+	SHA                  string `json:"sha"`
+	HeadRef              string `json:"head_ref,omitempty"`
+	BaseRef              string `json:"base_ref,omitempty"`
+	Environment          string `json:"environment,omitempty"`
+	Actor                string `json:"actor"`
+	ActorID              string `json:"actor_id"`
+	EventName            string `json:"event_name"`
+	Workflow             string `json:"workflow"`
+	WorkflowRef          string `json:"workflow_ref"`
+	WorkflowSHA          string `json:"workflow_sha"`
+	// JobWorkflowRef differs from WorkflowRef for reusable workflows.
+	JobWorkflowRef    string `json:"job_workflow_ref"`
+	JobWorkflowSHA    string `json:"job_workflow_sha"`
+	RunID             string `json:"run_id"`
+	RunNumber         string `json:"run_number"`
+	RunAttempt        string `json:"run_attempt"`
+	RunnerEnvironment string `json:"runner_environment"`
+}
+
+// GitLabClaims are the claims of the ID token of a GitLab CI/CD job,
+// whose sub is project_path:<group>/<project>:ref_type:<type>:ref:<ref>.
+type GitLabClaims struct {
+	oidc.TokenClaims
+	NamespaceID          string `json:"namespace_id"`
+	NamespacePath        string `json:"namespace_path"`
+	ProjectID            string `json:"project_id"`
+	ProjectPath          string `json:"project_path"`
+	ProjectVisibility    string `json:"project_visibility"`
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserEmail            string `json:"user_email,omitempty"`
+	PipelineID           string `json:"pipeline_id"`
+	PipelineSource       string `json:"pipeline_source"`
+	JobID                string `json:"job_id"`
+	Ref                  string `json:"ref"`
+	RefType              string `json:"ref_type"`
+	RefPath              string `json:"ref_path"`
+	RefProtected         string `json:"ref_protected"`
+	Environment          string `json:"environment,omitempty"`
+	EnvironmentProtected string `json:"environment_protected,omitempty"`
+	DeploymentTier       string `json:"deployment_tier,omitempty"`
+	RunnerID             int64  `json:"runner_id"`
+	RunnerEnvironment    string `json:"runner_environment"`
+	SHA                  string `json:"sha"`
+	CIConfigRefURI       string `json:"ci_config_ref_uri"`
+	CIConfigSHA          string `json:"ci_config_sha"`
+}
+
+// CircleCIClaims are the claims of the OIDC token of a CircleCI job,
+// whose sub is org/<organization>/project/<project>/user/<user>.
+type CircleCIClaims struct {
+	oidc.TokenClaims
+	ProjectID  string   `json:"oidc.circleci.com/project-id"`
+	PipelineID string   `json:"oidc.circleci.com/pipeline-id"`
+	WorkflowID string   `json:"oidc.circleci.com/workflow-id"`
+	JobID      string   `json:"oidc.circleci.com/job-id"`
+	ContextIDs []string `json:"oidc.circleci.com/context-ids,omitempty"`
+	// VCSOrigin is the repository of the project, e.g. github.com/octo-org/octo-repo.
+	VCSOrigin string `json:"oidc.circleci.com/vcs-origin"`
+	VCSRef    string `json:"oidc.circleci.com/vcs-ref"`
+	SSHRerun  bool   `json:"oidc.circleci.com/ssh-rerun"`
+}
+
+type ciConfig struct {
+	httpClient   *http.Client
+	issuer       string
+	jwksURL      string
+	repositories []string
+	refs         []string
+	environments []string
+}
+
+// CIOption configures the verifiers of the OIDC tokens of CI pipelines,
+// see [NewGitHubActionsVerifier], [NewGitLabVerifier] and [NewCircleCIVerifier].
+type CIOption func(*ciConfig)
+
+// WithCIHTTPClient fetches the keys of the issuer with the httpClient.
+// Defaults to the [httphelper.DefaultHTTPClient].
+func WithCIHTTPClient(httpClient *http.Client) CIOption {
+	return func(c *ciConfig) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithCIIssuer sets the issuer and the jwks_uri, such as the ones
+// of a GitHub Enterprise or a self-managed GitLab instance.
+func WithCIIssuer(issuer, jwksURL string) CIOption {
+	return func(c *ciConfig) {
+		c.issuer = issuer
+		c.jwksURL = jwksURL
+	}
+}
+
+// WithCIRepositories only accepts the tokens of pipelines of the repositories:
+// repository of GitHub (<owner>/<repository>), project_path of GitLab
+// and vcs-origin of CircleCI. Values may be patterns of [path.Match],
+// such as octo-org/*.
+func WithCIRepositories(repositories ...string) CIOption {
+	return func(c *ciConfig) {
+		c.repositories = append(c.repositories, repositories...)
+	}
+}
+
+// WithCIRefs only accepts the tokens of pipelines of the git refs:
+// ref of GitHub (refs/heads/main), ref of GitLab (main) and
+// vcs-ref of CircleCI (refs/heads/main). Values may be patterns of [path.Match],
+// such as refs/tags/v*.
+func WithCIRefs(refs ...string) CIOption {
+	return func(c *ciConfig) {
+		c.refs = append(c.refs, refs...)
+	}
+}
+
+// WithCIEnvironments only accepts the tokens of jobs deploying to the environments
+// of GitHub and GitLab. CircleCI has no environments, the values are
+// matched against the IDs of the contexts of the job instead.
+func WithCIEnvironments(environments ...string) CIOption {
+	return func(c *ciConfig) {
+		c.environments = append(c.environments, environments...)
+	}
+}
+
+func newCIConfig(issuer, jwksURL string, options []CIOption) *ciConfig {
+	c := &ciConfig{
+		httpClient: httphelper.DefaultHTTPClient,
+		issuer:     issuer,
+		jwksURL:    jwksURL,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// NewGitHubActionsVerifier returns a WorkloadVerifier of the OIDC tokens
+// of GitHub Actions jobs, requested for the audience.
+//
+// Without [WithCIRepositories], tokens of any repository on GitHub are accepted,
+// so deployment services should always restrict the repositories.
+func NewGitHubActionsVerifier(audience string, options ...CIOption) *WorkloadVerifier[*GitHubActionsClaims] {
+	c := newCIConfig(GitHubActionsIssuer, GitHubActionsIssuer+"/.well-known/jwks", options)
+	return &WorkloadVerifier[*GitHubActionsClaims]{
+		Issuer:   c.issuer,
+		Audience: audience,
+		KeySet:   rp.NewRemoteKeySet(c.httpClient, c.jwksURL),
+		Checks: []func(*GitHubActionsClaims) error{
+			claimMatches("repository", func(claims *GitHubActionsClaims) []string {
+				return []string{claims.Repository}
+			}, c.repositories),
+			claimMatches("ref", func(claims *GitHubActionsClaims) []string {
+				return []string{claims.Ref}
+			}, c.refs),
+			claimMatches("environment", func(claims *GitHubActionsClaims) []string {
+				return []string{claims.Environment}
+			}, c.environments),
+		},
+	}
+}
+
+// NewGitLabVerifier returns a WorkloadVerifier of the ID tokens of GitLab CI/CD jobs
+// with the aud of the id_tokens keyword of the job.
+//
+// Without [WithCIRepositories], tokens of any project on the instance are accepted,
+// so deployment services should always restrict the repositories.
+func NewGitLabVerifier(audience string, options ...CIOption) *WorkloadVerifier[*GitLabClaims] {
+	c := newCIConfig(GitLabIssuer, GitLabIssuer+"/oauth/discovery/keys", options)
+	return &WorkloadVerifier[*GitLabClaims]{
+		Issuer:   c.issuer,
+		Audience: audience,
+		KeySet:   rp.NewRemoteKeySet(c.httpClient, c.jwksURL),
+		Checks: []func(*GitLabClaims) error{
+			claimMatches("project", func(claims *GitLabClaims) []string {
+				return []string{claims.ProjectPath}
+			}, c.repositories),
+			claimMatches("ref", func(claims *GitLabClaims) []string {
+				return []string{claims.Ref}
+			}, c.refs),
+			claimMatches("environment", func(claims *GitLabClaims) []string {
+				return []string{claims.Environment}
+			}, c.environments),
+		},
+	}
+}
+
+// NewCircleCIVerifier returns a WorkloadVerifier of the OIDC tokens of CircleCI jobs
+// of the organization, which is both the audience and part of the issuer.
+func NewCircleCIVerifier(organizationID string, options ...CIOption) *WorkloadVerifier[*CircleCIClaims] {
+	issuer := CircleCIIssuer + organizationID
+	c := newCIConfig(issuer, issuer+"/.well-known/jwks-pub.json", options)
+	return &WorkloadVerifier[*CircleCIClaims]{
+		Issuer:   c.issuer,
+		Audience: organizationID,
+		KeySet:   rp.NewRemoteKeySet(c.httpClient, c.jwksURL),
+		Checks: []func(*CircleCIClaims) error{
+			claimMatches("vcs-origin", func(claims *CircleCIClaims) []string {
+				return []string{claims.VCSOrigin}
+			}, c.repositories),
+			claimMatches("vcs-ref", func(claims *CircleCIClaims) []string {
+				return []string{claims.VCSRef}
+			}, c.refs),
+			claimMatches("context", func(claims *CircleCIClaims) []string {
+				return claims.ContextIDs
+			}, c.environments),
+		},
+	}
+}
+
+// claimMatches returns a check of a claim against the allowed [path.Match] patterns,
+// which passes if any of its values matches any of the patterns.
+// It allows any value if patterns is empty.
+func claimMatches[C any](name string, values func(C) []string, patterns []string) func(C) error {
+	return func(claims C) error {
+		if len(patterns) == 0 {
+			return nil
+		}
+		for _, value := range values(claims) {
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, value); ok {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: %s %q is not allowed", ErrWorkloadClaim, name, strings.Join(values(claims), ","))
+	}
+}
@@ -0,0 +1,93 @@
+package rs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+)
+
+func TestNewGitHubActionsVerifier(t *testing.T) {
+	verifier := NewGitHubActionsVerifier("https://deploy.example.com",
+		WithCIRepositories("octo-org/*"),
+		WithCIRefs("refs/heads/main", "refs/tags/v*"),
+		WithCIEnvironments("prod"),
+	)
+	assert.Equal(t, GitHubActionsIssuer, verifier.Issuer)
+	verifier.KeySet = tu.KeySet{}
+
+	newToken := func(repository, ref, environment string) string {
+		return signWorkloadToken(t, map[string]any{
+			"iss":         GitHubActionsIssuer,
+			"sub":         "repo:" + repository + ":environment:" + environment,
+			"aud":         "https://deploy.example.com",
+			"exp":         time.Now().Add(time.Hour).Unix(),
+			"repository":  repository,
+			"ref":         ref,
+			"environment": environment,
+			"run_id":      "42",
+		})
+	}
+
+	claims, err := verifier.Verify(context.Background(), newToken("octo-org/app", "refs/tags/v1.2.0", "prod"))
+	require.NoError(t, err)
+	assert.Equal(t, "octo-org/app", claims.Repository)
+	assert.Equal(t, "42", claims.RunID)
+
+	for _, token := range []string{
+		newToken("other-org/app", "refs/heads/main", "prod"),
+		newToken("octo-org/app", "refs/heads/feature", "prod"),
+		newToken("octo-org/app", "refs/heads/main", "staging"),
+	} {
+		_, err = verifier.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, ErrWorkloadClaim)
+	}
+}
+
+func TestNewGitLabVerifier(t *testing.T) {
+	verifier := NewGitLabVerifier("https://deploy.example.com",
+		WithCIIssuer("https://gitlab.example.com", "https://gitlab.example.com/oauth/discovery/keys"),
+		WithCIRepositories("group/project"),
+	)
+	verifier.KeySet = tu.KeySet{}
+
+	token := signWorkloadToken(t, map[string]any{
+		"iss":          "https://gitlab.example.com",
+		"sub":          "project_path:group/project:ref_type:branch:ref:main",
+		"aud":          "https://deploy.example.com",
+		"exp":          time.Now().Add(time.Hour).Unix(),
+		"project_path": "group/project",
+		"ref":          "main",
+		"runner_id":    7,
+	})
+	claims, err := verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, claims.RunnerID)
+}
+
+func TestNewCircleCIVerifier(t *testing.T) {
+	verifier := NewCircleCIVerifier("org-1", WithCIEnvironments("context-prod"))
+	assert.Equal(t, CircleCIIssuer+"org-1", verifier.Issuer)
+	verifier.KeySet = tu.KeySet{}
+
+	newToken := func(contexts ...string) string {
+		return signWorkloadToken(t, map[string]any{
+			"iss":                           CircleCIIssuer + "org-1",
+			"sub":                           "org/org-1/project/project-1/user/user-1",
+			"aud":                           "org-1",
+			"exp":                           time.Now().Add(time.Hour).Unix(),
+			"oidc.circleci.com/project-id":  "project-1",
+			"oidc.circleci.com/context-ids": contexts,
+		})
+	}
+	claims, err := verifier.Verify(context.Background(), newToken("context-dev", "context-prod"))
+	require.NoError(t, err)
+	assert.Equal(t, "project-1", claims.ProjectID)
+
+	_, err = verifier.Verify(context.Background(), newToken("context-dev"))
+	assert.ErrorIs(t, err, ErrWorkloadClaim)
+}
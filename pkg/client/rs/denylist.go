@@ -0,0 +1,140 @@
+package rs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ErrTokenRevoked is returned by [ValidateToken] for tokens
+// revoked by the [Denylist] of the resource server.
+var ErrTokenRevoked = errors.New("resource server: token is revoked")
+
+// Denylist is consulted by [ValidateToken] and [Middleware], see [WithDenylist],
+// before accepting an otherwise valid token.
+// It lets applications revoke JWT access tokens, which are validated locally,
+// out-of-band, for example for the subjects or sessions of a back-channel logout.
+type Denylist interface {
+	// Revoked reports if the validated token is revoked.
+	Revoked(ctx context.Context, claims *oidc.IntrospectionResponse) (bool, error)
+}
+
+// WithDenylist rejects tokens revoked by the denylist with [ErrTokenRevoked].
+// Errors of the denylist reject the token as well.
+func WithDenylist(denylist Denylist) Option {
+	return func(server *resourceServer) {
+		server.denylist = denylist
+	}
+}
+
+type denylister interface {
+	Denylist() Denylist
+}
+
+func (r *resourceServer) Denylist() Denylist {
+	return r.denylist
+}
+
+// checkDenylist rejects the validated token, if revoked by the denylist of rs.
+func checkDenylist(ctx context.Context, rs ResourceServer, claims *oidc.IntrospectionResponse) error {
+	d, ok := rs.(denylister)
+	if !ok || d.Denylist() == nil {
+		return nil
+	}
+	revoked, err := d.Denylist().Revoked(ctx, claims)
+	if err == nil && revoked {
+		err = ErrTokenRevoked
+	}
+	return oidc.ExplanationFromContext(ctx).Check("denylist", err, "sub", claims.Subject, "jti", claims.JWTID)
+}
+
+// CacheDenylist is a [Denylist] stored in a [cache.Cache], which can be shared
+// by the instances of a resource server. Revoked subjects and sessions reject
+// the tokens issued until the revocation, tokens issued later are accepted.
+type CacheDenylist struct {
+	cache       cache.Cache
+	maxLifetime time.Duration
+}
+
+// NewCacheDenylist returns a denylist stored in c, a [cache.Memory] if nil.
+// Revocations of subjects and sessions are kept for maxLifetime,
+// the maximum lifetime of the access tokens of the issuer.
+func NewCacheDenylist(c cache.Cache, maxLifetime time.Duration) *CacheDenylist {
+	if c == nil {
+		c = cache.NewMemory()
+	}
+	return &CacheDenylist{cache: c, maxLifetime: maxLifetime}
+}
+
+// RevokeToken revokes the token with the jti until it expires at exp.
+func (d *CacheDenylist) RevokeToken(ctx context.Context, jti string, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.revoke(ctx, "jti:"+jti, ttl)
+}
+
+// RevokeSubject revokes all tokens of the subject issued until now.
+func (d *CacheDenylist) RevokeSubject(ctx context.Context, subject string) error {
+	return d.revoke(ctx, "sub:"+subject, d.maxLifetime)
+}
+
+// RevokeSession revokes all tokens of the session (sid) issued until now.
+func (d *CacheDenylist) RevokeSession(ctx context.Context, sessionID string) error {
+	return d.revoke(ctx, "sid:"+sessionID, d.maxLifetime)
+}
+
+// Logout revokes the session of the logout token, or its subject
+// if it has no sid, see [BackChannelLogoutHandler].
+func (d *CacheDenylist) Logout(ctx context.Context, claims *oidc.LogoutTokenClaims) error {
+	if claims.SessionID != "" {
+		return d.RevokeSession(ctx, claims.SessionID)
+	}
+	return d.RevokeSubject(ctx, claims.Subject)
+}
+
+func (d *CacheDenylist) revoke(ctx context.Context, key string, ttl time.Duration) error {
+	revokedAt := strconv.FormatInt(time.Now().Unix(), 10)
+	return d.cache.Set(ctx, "denylist:"+key, []byte(revokedAt), ttl)
+}
+
+// Revoked implements [Denylist].
+func (d *CacheDenylist) Revoked(ctx context.Context, claims *oidc.IntrospectionResponse) (bool, error) {
+	if claims.JWTID != "" {
+		if _, err := d.cache.Get(ctx, "denylist:jti:"+claims.JWTID); err == nil {
+			return true, nil
+		} else if !errors.Is(err, cache.ErrNotFound) {
+			return false, err
+		}
+	}
+	var keys []string
+	if claims.Subject != "" {
+		keys = append(keys, "sub:"+claims.Subject)
+	}
+	if claims.SessionID != "" {
+		keys = append(keys, "sid:"+claims.SessionID)
+	}
+	for _, key := range keys {
+		value, err := d.cache.Get(ctx, "denylist:"+key)
+		if errors.Is(err, cache.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		revokedAt, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("denylist: invalid revocation of %s: %w", key, err)
+		}
+		if claims.IssuedAt.AsTime().Unix() <= revokedAt {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,55 @@
+package rs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestWithDenylist(t *testing.T) {
+	ctx := context.Background()
+	denylist := NewCacheDenylist(nil, time.Hour)
+	rs, err := NewResourceServerClientCredentials(ctx, tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+		WithDenylist(denylist),
+	)
+	require.NoError(t, err)
+	token, _ := tu.NewJWTAccessTokenCustom(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, tu.ValidExpiration, tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew,
+		map[string]any{"sid": "session"})
+	other, _ := tu.NewJWTAccessToken(tu.ValidIssuer, "other", tu.ValidAudience, tu.ValidExpiration, "other", tu.ValidClientID, tu.ValidSkew)
+
+	_, err = ValidateToken(ctx, rs, token)
+	require.NoError(t, err)
+
+	require.NoError(t, denylist.RevokeToken(ctx, tu.ValidJWTID, tu.ValidExpiration))
+	_, err = ValidateToken(ctx, rs, token)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, err = ValidateToken(ctx, rs, other)
+	assert.NoError(t, err)
+
+	require.NoError(t, denylist.Logout(ctx, &oidc.LogoutTokenClaims{Subject: "other"}))
+	_, err = ValidateToken(ctx, rs, other)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	// tokens issued after the revocation are accepted
+	denylist = NewCacheDenylist(nil, time.Hour)
+	require.NoError(t, denylist.Logout(ctx, &oidc.LogoutTokenClaims{Subject: tu.ValidSubject, SessionID: "session"}))
+	claims := &oidc.IntrospectionResponse{Subject: tu.ValidSubject, SessionID: "session"}
+	claims.IssuedAt = oidc.FromTime(time.Now().Add(-time.Minute))
+	revoked, err := denylist.Revoked(ctx, claims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	claims.IssuedAt = oidc.FromTime(time.Now().Add(time.Minute))
+	revoked, err = denylist.Revoked(ctx, claims)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = denylist.Revoked(ctx, &oidc.IntrospectionResponse{Subject: tu.ValidSubject})
+	require.NoError(t, err)
+	assert.False(t, revoked, "only the session is revoked")
+}
@@ -0,0 +1,66 @@
+package rs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+var (
+	ErrDPoPProofMissing = errors.New("dpop proof missing")
+	ErrDPoPBoundBearer  = errors.New("dpop-bound access token presented as bearer token")
+	ErrDPoPReplay       = errors.New("dpop proof replayed")
+)
+
+// VerifyDPoP verifies the DPoP proof (RFC 9449, section 7) of a resource request
+// to the uri with the DPoP-bound access token, whose validated claims must bind
+// it to the key of the proof. Proofs whose jti is already recorded in the
+// replay cache are rejected, if it is not nil.
+func VerifyDPoP(ctx context.Context, r *http.Request, uri, accessToken string, claims *oidc.IntrospectionResponse, replay cache.Cache) error {
+	proofs := r.Header.Values(oidc.DPoPHeader)
+	if len(proofs) != 1 {
+		return ErrDPoPProofMissing
+	}
+	proof, err := oidc.VerifyDPoPProof(proofs[0], r.Method, uri, accessToken)
+	if err != nil {
+		return err
+	}
+	if err = oidc.VerifyDPoPBinding(proof, claims.Confirmation); err != nil {
+		return err
+	}
+	if replay != nil {
+		// proofs are accepted within DPoPProofMaxAge before and after their iat
+		err = cache.CheckReplay(ctx, replay, "dpop:"+proof.Thumbprint+":"+proof.Claims.JWTID, 2*oidc.DPoPProofMaxAge)
+		if errors.Is(err, cache.ErrReplay) {
+			return ErrDPoPReplay
+		}
+		return err
+	}
+	return nil
+}
+
+// RequestURI returns the uri of the request to compare with the htu of DPoP proofs,
+// from the Host header and the scheme of the connection, as used by [Middleware],
+// unless overridden by [WithRequestURI].
+func RequestURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// accessTokenFromHeader returns the access token of the Authorization header,
+// and if it is presented with the DPoP scheme.
+func accessTokenFromHeader(header http.Header) (token string, dpop, ok bool) {
+	auth := header.Get("Authorization")
+	if token, ok := strings.CutPrefix(auth, oidc.PrefixDPoP); ok {
+		return token, true, token != ""
+	}
+	token, ok = strings.CutPrefix(auth, oidc.PrefixBearer)
+	return token, false, ok && token != ""
+}
@@ -0,0 +1,84 @@
+package rs
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestMiddleware_DPoP(t *testing.T) {
+	rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+	)
+	require.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	proofer, err := client.NewDPoPProofer(key, jose.ES256)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherProofer, err := client.NewDPoPProofer(otherKey, jose.ES256)
+	require.NoError(t, err)
+
+	thumbprint, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+	bound, _ := tu.NewJWTAccessTokenCustom(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, tu.ValidExpiration, tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew,
+		map[string]any{"cnf": map[string]any{"jkt": base64.RawURLEncoding.EncodeToString(thumbprint)}},
+	)
+	unbound, _ := tu.ValidJWTAccessToken()
+	proof := func(proofer client.DPoPProofer, token string) string {
+		p, err := proofer(http.MethodGet, "http://example.com/api", token, "")
+		require.NoError(t, err)
+		return p
+	}
+	replayed := proof(proofer, bound)
+
+	tests := []struct {
+		name       string
+		auth       string
+		proof      string
+		wantStatus int
+	}{
+		{"bound", oidc.PrefixDPoP + bound, proof(proofer, bound), http.StatusOK},
+		{"replayed", oidc.PrefixDPoP + bound, replayed, http.StatusUnauthorized},
+		{"bound as bearer", oidc.PrefixBearer + bound, "", http.StatusUnauthorized},
+		{"missing proof", oidc.PrefixDPoP + bound, "", http.StatusUnauthorized},
+		{"other key", oidc.PrefixDPoP + bound, proof(otherProofer, bound), http.StatusUnauthorized},
+		{"other token", oidc.PrefixDPoP + bound, proof(proofer, unbound), http.StatusUnauthorized},
+		{"unbound", oidc.PrefixDPoP + unbound, proof(proofer, unbound), http.StatusUnauthorized},
+		{"unbound as bearer", oidc.PrefixBearer + unbound, "", http.StatusOK},
+	}
+	replay := cache.NewMemory()
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+	r.Header.Set("Authorization", oidc.PrefixDPoP+bound)
+	r.Header.Set(oidc.DPoPHeader, replayed)
+	Middleware(rs, nil, nil, WithDPoPReplayCache(replay))(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/api?query=1", nil)
+			r.Header.Set("Authorization", tt.auth)
+			if tt.proof != "" {
+				r.Header.Set(oidc.DPoPHeader, tt.proof)
+			}
+			w := httptest.NewRecorder()
+			Middleware(rs, nil, nil, WithDPoPReplayCache(replay))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package rs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+)
+
+// ErrIntrospectionUnavailable is returned by [Introspect], if all introspection
+// endpoints of [WithIntrospectionEndpoints] failed or are skipped.
+var ErrIntrospectionUnavailable = errors.New("resource server: all introspection endpoints are unavailable")
+
+const (
+	DefaultFailureThreshold = 3
+	DefaultOpenDuration     = 30 * time.Second
+)
+
+// FailoverPolicy is the circuit breaking of the introspection endpoints
+// of [WithIntrospectionEndpoints].
+type FailoverPolicy struct {
+	// FailureThreshold is the number of consecutive failures of an endpoint,
+	// after which it is skipped (open circuit), DefaultFailureThreshold if zero.
+	FailureThreshold int
+	// OpenDuration is the time an endpoint is skipped, before a single request
+	// probes if it recovered (half-open circuit), DefaultOpenDuration if zero.
+	OpenDuration time.Duration
+}
+
+// WithIntrospectionEndpoints adds fallback introspection endpoints, like the
+// replicas of the OP, which [Introspect] tries in order, if the introspection
+// endpoint of the discovery or [WithStaticEndpoints] fails.
+// Network errors and responses with a 5xx status or throttled by the OP
+// (after the [WithRetryPolicy]) count as failures, other error responses,
+// like an invalid client, are returned without trying further endpoints.
+// Endpoints failing repeatedly are skipped for a while, according to the policy,
+// a nil policy uses the defaults. If all endpoints fail or are skipped,
+// Introspect fails with [ErrIntrospectionUnavailable], wrapping the last error.
+func WithIntrospectionEndpoints(policy *FailoverPolicy, urls ...string) Option {
+	return func(server *resourceServer) {
+		if policy == nil {
+			policy = new(FailoverPolicy)
+		}
+		server.failover = &introspectionFailover{
+			policy:    *policy,
+			fallbacks: urls,
+			circuits:  make(map[string]*circuit),
+		}
+	}
+}
+
+type introspectionFailover struct {
+	policy    FailoverPolicy
+	fallbacks []string
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+type failoverer interface {
+	introspectionFailover() *introspectionFailover
+}
+
+func (r *resourceServer) introspectionFailover() *introspectionFailover {
+	return r.failover
+}
+
+// endpoints returns the primary and the fallback endpoints, in order.
+func (f *introspectionFailover) endpoints(primary string) []string {
+	return append([]string{primary}, f.fallbacks...)
+}
+
+// allow reports if the endpoint may be requested: its circuit is closed,
+// or open for the OpenDuration and not probed by another request yet.
+func (f *introspectionFailover) allow(url string, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.circuits[url]
+	switch {
+	case c == nil || c.failures < f.threshold():
+		return true
+	case now.After(c.openUntil) && !c.probing:
+		c.probing = true
+		return true
+	}
+	return false
+}
+
+// release ends the probe of the endpoint, without a result,
+// e.g. because the request was canceled.
+func (f *introspectionFailover) release(url string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c := f.circuits[url]; c != nil {
+		c.probing = false
+	}
+}
+
+// report records the result of a request to the endpoint.
+func (f *introspectionFailover) report(url string, err error, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !isEndpointFailure(err) {
+		delete(f.circuits, url)
+		return
+	}
+	c := f.circuits[url]
+	if c == nil {
+		c = new(circuit)
+		f.circuits[url] = c
+	}
+	c.failures++
+	c.probing = false
+	if c.failures >= f.threshold() {
+		c.openUntil = now.Add(f.openDuration())
+	}
+}
+
+func (f *introspectionFailover) threshold() int {
+	if f.policy.FailureThreshold > 0 {
+		return f.policy.FailureThreshold
+	}
+	return DefaultFailureThreshold
+}
+
+func (f *introspectionFailover) openDuration() time.Duration {
+	if f.policy.OpenDuration > 0 {
+		return f.policy.OpenDuration
+	}
+	return DefaultOpenDuration
+}
+
+// isEndpointFailure reports if err indicates an unhealthy endpoint,
+// rather than an error of the request.
+func isEndpointFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var respErr *httphelper.ResponseError
+	if !errors.As(err, &respErr) {
+		return true
+	}
+	return respErr.StatusCode >= http.StatusInternalServerError || respErr.Throttled()
+}
+
+func unavailableError(err error) error {
+	if err == nil {
+		return ErrIntrospectionUnavailable
+	}
+	return fmt.Errorf("%w: %w", ErrIntrospectionUnavailable, err)
+}
@@ -0,0 +1,95 @@
+package rs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type introspectionReplica struct {
+	*httptest.Server
+	status atomic.Int32
+	calls  atomic.Int32
+}
+
+func newIntrospectionReplica(t *testing.T, subject string) *introspectionReplica {
+	replica := new(introspectionReplica)
+	replica.status.Store(http.StatusOK)
+	replica.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replica.calls.Add(1)
+		if status := int(replica.status.Load()); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{Active: true, Subject: subject})
+	}))
+	t.Cleanup(replica.Close)
+	return replica
+}
+
+func TestWithIntrospectionEndpoints(t *testing.T) {
+	primary := newIntrospectionReplica(t, "primary")
+	fallback := newIntrospectionReplica(t, "fallback")
+	rs, err := NewResourceServerClientCredentials(context.Background(), "https://issuer.example.com", "client", "secret",
+		WithStaticEndpoints(primary.URL+"/token", primary.URL+"/introspect"),
+		WithIntrospectionEndpoints(&FailoverPolicy{FailureThreshold: 2, OpenDuration: 50 * time.Millisecond}, fallback.URL+"/introspect"),
+	)
+	require.NoError(t, err)
+	introspect := func() (string, error) {
+		resp, err := Introspect[*oidc.IntrospectionResponse](context.Background(), rs, "token")
+		if err != nil {
+			return "", err
+		}
+		return resp.Subject, nil
+	}
+
+	subject, err := introspect()
+	require.NoError(t, err)
+	assert.Equal(t, "primary", subject)
+
+	primary.status.Store(http.StatusBadGateway)
+	for range 3 {
+		subject, err = introspect()
+		require.NoError(t, err)
+		assert.Equal(t, "fallback", subject)
+	}
+	// the circuit of the primary opened after 2 failures
+	assert.EqualValues(t, 3, primary.calls.Load())
+
+	fallback.status.Store(http.StatusServiceUnavailable)
+	_, err = introspect()
+	assert.ErrorIs(t, err, ErrIntrospectionUnavailable)
+
+	primary.status.Store(http.StatusOK)
+	time.Sleep(60 * time.Millisecond)
+	subject, err = introspect()
+	require.NoError(t, err)
+	assert.Equal(t, "primary", subject)
+	assert.EqualValues(t, 4, primary.calls.Load())
+}
+
+func TestWithIntrospectionEndpoints_requestError(t *testing.T) {
+	primary := newIntrospectionReplica(t, "primary")
+	primary.status.Store(http.StatusUnauthorized)
+	fallback := newIntrospectionReplica(t, "fallback")
+	rs, err := NewResourceServerClientCredentials(context.Background(), "https://issuer.example.com", "client", "secret",
+		WithStaticEndpoints(primary.URL+"/token", primary.URL+"/introspect"),
+		WithIntrospectionEndpoints(nil, fallback.URL+"/introspect"),
+	)
+	require.NoError(t, err)
+
+	_, err = Introspect[*oidc.IntrospectionResponse](context.Background(), rs, "token")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrIntrospectionUnavailable)
+	assert.EqualValues(t, 0, fallback.calls.Load())
+}
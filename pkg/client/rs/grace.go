@@ -0,0 +1,63 @@
+package rs
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// GraceValidation is the result of [ValidateTokenWithGrace].
+type GraceValidation struct {
+	Claims *oidc.IntrospectionResponse
+	// ExpiredBy is the duration the token was already expired by
+	// at the time of validation, zero if it is not expired.
+	ExpiredBy time.Duration
+}
+
+// Expired reports if the token was only accepted within the grace period.
+func (v *GraceValidation) Expired() bool {
+	return v.ExpiredBy > 0
+}
+
+// ValidateTokenWithGrace is like [ValidateToken], but accepts JWT access tokens
+// expired by at most grace, e.g. for requests delayed by queues or batch systems.
+// It must only be used for idempotent read operations, as the token
+// may have been revoked in the meantime.
+// Introspected tokens must still be active, as the introspection endpoint
+// does not report the expiration of inactive tokens.
+func ValidateTokenWithGrace(ctx context.Context, rs ResourceServer, token string, grace time.Duration) (*GraceValidation, error) {
+	ctx, span := client.Tracer.Start(ctx, "ValidateTokenWithGrace")
+	defer span.End()
+
+	claims, expiredBy, err := validateToken(ctx, rs, token, grace)
+	if err != nil {
+		return nil, err
+	}
+	return &GraceValidation{Claims: claims, ExpiredBy: expiredBy}, nil
+}
+
+type expiredByKey struct{}
+
+// ExpiredByFromContext returns the duration the token of the request
+// was expired by, if accepted by the grace period of [WithReadGracePeriod].
+func ExpiredByFromContext(ctx context.Context) time.Duration {
+	expiredBy, _ := ctx.Value(expiredByKey{}).(time.Duration)
+	return expiredBy
+}
+
+// WithReadGracePeriod accepts tokens expired by at most grace
+// for the safe methods GET, HEAD and OPTIONS, see [ValidateTokenWithGrace].
+// Other requests are always validated strictly.
+// The next handler can tell by [ExpiredByFromContext].
+func WithReadGracePeriod(grace time.Duration) MiddlewareOption {
+	return func(m *middleware) {
+		m.readGrace = grace
+	}
+}
+
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,69 @@
+package rs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestValidateTokenWithGrace(t *testing.T) {
+	rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+	)
+	require.NoError(t, err)
+	valid, _ := tu.ValidJWTAccessToken()
+	expired, _ := tu.NewJWTAccessToken(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, time.Now().Add(-time.Minute), tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew)
+
+	got, err := ValidateTokenWithGrace(context.Background(), rs, valid, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, got.Expired())
+
+	got, err = ValidateTokenWithGrace(context.Background(), rs, expired, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, got.Expired())
+	assert.InDelta(t, time.Minute, got.ExpiredBy, float64(time.Second))
+	assert.Equal(t, tu.ValidSubject, got.Claims.Subject)
+
+	_, err = ValidateTokenWithGrace(context.Background(), rs, expired, time.Second)
+	assert.ErrorIs(t, err, ErrTokenInactive)
+	_, err = ValidateToken(context.Background(), rs, expired)
+	assert.ErrorIs(t, err, ErrTokenInactive)
+}
+
+func TestMiddleware_readGracePeriod(t *testing.T) {
+	rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+	)
+	require.NoError(t, err)
+	expired, _ := tu.NewJWTAccessToken(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, time.Now().Add(-time.Minute), tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Greater(t, ExpiredByFromContext(r.Context()), time.Duration(0))
+	})
+
+	tests := []struct {
+		method     string
+		wantStatus int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodHead, http.StatusOK},
+		{http.MethodPost, http.StatusUnauthorized},
+		{http.MethodDelete, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.Header.Set("Authorization", oidc.PrefixBearer+expired)
+			w := httptest.NewRecorder()
+			Middleware(rs, nil, nil, WithReadGracePeriod(time.Hour))(next).ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package rs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+const (
+	// KubernetesAPIServer is the in-cluster address of the API server,
+	// which serves the discovery document of the service account issuer.
+	KubernetesAPIServer = "https://kubernetes.default.svc"
+	// KubernetesServiceAccountDir holds the token and CA certificate
+	// mounted into the pods of Kubernetes.
+	KubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// KubernetesObjectRef references the object a service account token is bound to.
+type KubernetesObjectRef struct {
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+// KubernetesClaims are the kubernetes.io claims of service account tokens.
+type KubernetesClaims struct {
+	Namespace      string               `json:"namespace"`
+	ServiceAccount KubernetesObjectRef  `json:"serviceaccount"`
+	Pod            *KubernetesObjectRef `json:"pod,omitempty"`
+	Node           *KubernetesObjectRef `json:"node,omitempty"`
+	Secret         *KubernetesObjectRef `json:"secret,omitempty"`
+	// WarnAfter is set on legacy tokens, which are accepted beyond their
+	// rotation by the API server and should be refreshed after it.
+	WarnAfter oidc.Time `json:"warnafter,omitempty"`
+}
+
+// KubernetesServiceAccountClaims are the claims of a Kubernetes service
+// account token, whose sub is system:serviceaccount:<namespace>:<name>.
+type KubernetesServiceAccountClaims struct {
+	oidc.TokenClaims
+	Kubernetes KubernetesClaims `json:"kubernetes.io"`
+}
+
+type kubernetesConfig struct {
+	apiServer       string
+	httpClient      *http.Client
+	issuer          string
+	jwksURL         string
+	namespaces      []string
+	serviceAccounts []string
+}
+
+type KubernetesOption func(*kubernetesConfig)
+
+// WithKubernetesAPIServer discovers the issuer at the API server with the
+// httpClient, e.g. from outside of the cluster. Defaults to [KubernetesAPIServer],
+// with the CA certificate and token of the service account of the pod.
+func WithKubernetesAPIServer(apiServer string, httpClient *http.Client) KubernetesOption {
+	return func(c *kubernetesConfig) {
+		c.apiServer = apiServer
+		c.httpClient = httpClient
+	}
+}
+
+// WithKubernetesIssuer sets the issuer and the jwks_uri of the cluster,
+// instead of discovering them at the API server, for clusters publishing
+// their keys elsewhere.
+func WithKubernetesIssuer(issuer, jwksURL string) KubernetesOption {
+	return func(c *kubernetesConfig) {
+		c.issuer = issuer
+		c.jwksURL = jwksURL
+	}
+}
+
+// WithKubernetesNamespaces only accepts the tokens of the namespaces.
+func WithKubernetesNamespaces(namespaces ...string) KubernetesOption {
+	return func(c *kubernetesConfig) {
+		c.namespaces = append(c.namespaces, namespaces...)
+	}
+}
+
+// WithKubernetesServiceAccounts only accepts the tokens of the
+// service accounts, given as <namespace>/<name>.
+func WithKubernetesServiceAccounts(serviceAccounts ...string) KubernetesOption {
+	return func(c *kubernetesConfig) {
+		c.serviceAccounts = append(c.serviceAccounts, serviceAccounts...)
+	}
+}
+
+// NewKubernetesVerifier returns a WorkloadVerifier of the service account tokens
+// of the cluster for the audience, such as projected tokens mounted with a
+// custom audience, so in-cluster services can authenticate each other.
+//
+// The issuer and its keys are discovered at the API server, which requires
+// the service account issuer discovery of the cluster to be enabled.
+func NewKubernetesVerifier(ctx context.Context, audience string, options ...KubernetesOption) (*WorkloadVerifier[*KubernetesServiceAccountClaims], error) {
+	ctx, span := client.Tracer.Start(ctx, "NewKubernetesVerifier")
+	defer span.End()
+
+	c := &kubernetesConfig{apiServer: KubernetesAPIServer}
+	for _, opt := range options {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		var err error
+		if c.httpClient, err = inClusterHTTPClient(KubernetesServiceAccountDir); err != nil {
+			return nil, err
+		}
+	}
+	if c.issuer == "" {
+		req, err := http.NewRequestWithContext(client.WithOperation(ctx, client.OperationDiscovery),
+			http.MethodGet, strings.TrimSuffix(c.apiServer, "/")+oidc.DiscoveryEndpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		config := new(oidc.DiscoveryConfiguration)
+		if err = httphelper.HttpRequest(c.httpClient, req, config); err != nil {
+			return nil, errors.Join(oidc.ErrDiscoveryFailed, err)
+		}
+		c.issuer, c.jwksURL = config.Issuer, config.JwksURI
+	}
+	return &WorkloadVerifier[*KubernetesServiceAccountClaims]{
+		Issuer:   c.issuer,
+		Audience: audience,
+		KeySet:   rp.NewRemoteKeySet(c.httpClient, c.jwksURL),
+		Checks: []func(*KubernetesServiceAccountClaims) error{
+			checkKubernetesSubject,
+			claimIn("namespace", func(claims *KubernetesServiceAccountClaims) string {
+				return claims.Kubernetes.Namespace
+			}, c.namespaces),
+			claimIn("service account", func(claims *KubernetesServiceAccountClaims) string {
+				return claims.Kubernetes.Namespace + "/" + claims.Kubernetes.ServiceAccount.Name
+			}, c.serviceAccounts),
+		},
+	}, nil
+}
+
+// checkKubernetesSubject checks that the sub claim matches the service account
+// of the kubernetes.io claims, so either can be used to identify the workload.
+func checkKubernetesSubject(claims *KubernetesServiceAccountClaims) error {
+	sa := claims.Kubernetes
+	if claims.Subject != "system:serviceaccount:"+sa.Namespace+":"+sa.ServiceAccount.Name {
+		return fmt.Errorf("%w: sub %q does not match the service account", ErrWorkloadClaim, claims.Subject)
+	}
+	return nil
+}
+
+// inClusterHTTPClient trusts the CA certificate of the cluster and authenticates
+// with the token of the service account, which is read on every request,
+// as it is rotated by the kubelet.
+func inClusterHTTPClient(dir string) (*http.Client, error) {
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("resource server: no certificates in the CA of the cluster")
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{
+		Timeout: httphelper.DefaultHTTPClient.Timeout,
+		Transport: &bearerFileTransport{
+			path: filepath.Join(dir, "token"),
+			base: base,
+		},
+	}, nil
+}
+
+type bearerFileTransport struct {
+	path string
+	base http.RoundTripper
+}
+
+func (t *bearerFileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := os.ReadFile(t.path)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", oidc.BearerToken+" "+strings.TrimSpace(string(token)))
+	return t.base.RoundTrip(req)
+}
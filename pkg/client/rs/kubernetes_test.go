@@ -0,0 +1,100 @@
+package rs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func signWorkloadToken(t *testing.T, claims any) string {
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	jws, err := tu.Signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := jws.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestNewKubernetesVerifier(t *testing.T) {
+	const issuer = "https://oidc.cluster.example.com"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, oidc.DiscoveryEndpoint, r.URL.Path)
+		json.NewEncoder(w).Encode(&oidc.DiscoveryConfiguration{Issuer: issuer, JwksURI: issuer + "/openid/v1/jwks"})
+	}))
+	defer server.Close()
+
+	verifier, err := NewKubernetesVerifier(context.Background(), "payments",
+		WithKubernetesAPIServer(server.URL, server.Client()),
+		WithKubernetesServiceAccounts("shop/checkout"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, issuer, verifier.Issuer)
+	verifier.KeySet = tu.KeySet{}
+
+	newToken := func(namespace, name, sub string) string {
+		return signWorkloadToken(t, map[string]any{
+			"iss": issuer,
+			"sub": sub,
+			"aud": []string{"payments"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"iat": time.Now().Unix(),
+			"kubernetes.io": map[string]any{
+				"namespace":      namespace,
+				"serviceaccount": map[string]string{"name": name, "uid": "1"},
+				"pod":            map[string]string{"name": "checkout-1", "uid": "2"},
+			},
+		})
+	}
+
+	claims, err := verifier.Verify(context.Background(), newToken("shop", "checkout", "system:serviceaccount:shop:checkout"))
+	require.NoError(t, err)
+	assert.Equal(t, "shop", claims.Kubernetes.Namespace)
+	assert.Equal(t, "checkout-1", claims.Kubernetes.Pod.Name)
+
+	_, err = verifier.Verify(context.Background(), newToken("shop", "cart", "system:serviceaccount:shop:cart"))
+	assert.ErrorIs(t, err, ErrWorkloadClaim)
+	_, err = verifier.Verify(context.Background(), newToken("shop", "checkout", "system:serviceaccount:other:checkout"))
+	assert.ErrorIs(t, err, ErrWorkloadClaim)
+}
+
+func TestWorkloadMiddleware(t *testing.T) {
+	verifier := &WorkloadVerifier[*KubernetesServiceAccountClaims]{
+		Issuer:   tu.ValidIssuer,
+		Audience: "api",
+		KeySet:   tu.KeySet{},
+	}
+	valid := signWorkloadToken(t, map[string]any{
+		"iss": tu.ValidIssuer, "sub": "workload", "aud": "api", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	notYet := signWorkloadToken(t, map[string]any{
+		"iss": tu.ValidIssuer, "sub": "workload", "aud": "api", "exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(time.Minute).Unix(),
+	})
+	handler := WorkloadMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, _ := oidc.SubjectFromContext(r.Context())
+		w.Write([]byte(subject))
+	}))
+
+	for token, wantStatus := range map[string]int{valid: http.StatusOK, notYet: http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, wantStatus, w.Code)
+		if wantStatus == http.StatusOK {
+			assert.Equal(t, "workload", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package rs
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type permissionsKey struct{}
+
+// ClaimsFromContext returns the validated claims set by [Middleware],
+// see [oidc.ClaimsFromContext].
+func ClaimsFromContext(ctx context.Context) *oidc.IntrospectionResponse {
+	claims, _ := oidc.ClaimsFromContext[*oidc.IntrospectionResponse](ctx)
+	return claims
+}
+
+// PermissionsFromContext returns the permissions set by [Middleware].
+func PermissionsFromContext(ctx context.Context) Permissions {
+	permissions, _ := ctx.Value(permissionsKey{}).(Permissions)
+	return permissions
+}
+
+type stalenessKey struct{}
+
+// StalenessFromContext returns the staleness of the cached introspection
+// response or keys, which validated the token of the request in [Middleware],
+// if served stale by the [WithStalePolicy]. It is nil for fresh validations.
+func StalenessFromContext(ctx context.Context) *cache.Staleness {
+	staleness, _ := ctx.Value(stalenessKey{}).(*cache.Staleness)
+	return staleness
+}
+
+type middleware struct {
+	authorizers       []Authorizer
+	dpopReplay        cache.Cache
+	requestURI        func(*http.Request) string
+	clientCertificate func(*http.Request) (*x509.Certificate, error)
+	readGrace         time.Duration
+}
+
+type MiddlewareOption func(*middleware)
+
+// WithAuthorizer adds an external policy engine to the [Middleware],
+// evaluated after the Policy. A deny is answered with 403 Forbidden
+// and the reason of the Decision, errors with 500 Internal Server Error.
+func WithAuthorizer(authorizer Authorizer) MiddlewareOption {
+	return func(m *middleware) {
+		m.authorizers = append(m.authorizers, authorizer)
+	}
+}
+
+// WithDPoPReplayCache rejects DPoP proofs whose jti is already recorded in the cache.
+func WithDPoPReplayCache(c cache.Cache) MiddlewareOption {
+	return func(m *middleware) {
+		m.dpopReplay = c
+	}
+}
+
+// WithRequestURI overrides the uri of requests compared with the htu of DPoP proofs,
+// e.g. for a resource server behind a reverse proxy. It defaults to [RequestURI].
+func WithRequestURI(requestURI func(*http.Request) string) MiddlewareOption {
+	return func(m *middleware) {
+		m.requestURI = requestURI
+	}
+}
+
+// Middleware validates the bearer token of requests with [ValidateToken],
+// maps the claims to permissions and enforces the policy.
+// Requests without a valid token are answered with 401 Unauthorized,
+// requests denied by the policy with 403 Forbidden.
+// A nil policy allows any valid token.
+//
+// DPoP-bound access tokens (RFC 9449) must be presented with the DPoP scheme
+// and a proof of the key they are bound to, see [VerifyDPoP].
+// Certificate-bound access tokens (RFC 8705) must be presented on a
+// connection with the certificate they are bound to, see [WithClientCertificate].
+//
+// The claims and permissions are available to the next handler
+// by [ClaimsFromContext] and [PermissionsFromContext], the subject
+// by [oidc.SubjectFromContext].
+func Middleware(rs ResourceServer, mapper PermissionMapper, policy Policy, options ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{requestURI: RequestURI, clientCertificate: TLSClientCertificate}
+	for _, opt := range options {
+		opt(m)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, dpop, ok := accessTokenFromHeader(r.Header)
+			if !ok {
+				unauthorized(w, "")
+				return
+			}
+			var grace time.Duration
+			if isReadMethod(r.Method) {
+				grace = m.readGrace
+			}
+			validationCtx, staleness := cache.RecordStaleness(r.Context())
+			claims, expiredBy, err := validateToken(validationCtx, rs, token, grace)
+			if err != nil {
+				unauthorized(w, `, error="invalid_token"`)
+				return
+			}
+			if err = m.verifyDPoP(r, token, dpop, claims); err != nil {
+				unauthorized(w, `, error="invalid_token"`)
+				return
+			}
+			if err = m.verifyCertificateBinding(r, claims); err != nil {
+				unauthorized(w, `, error="invalid_token"`)
+				return
+			}
+			permissions := mapper.Permissions(claims)
+			if policy != nil && !policy(permissions) {
+				forbidden(w, "")
+				return
+			}
+			if len(m.authorizers) > 0 {
+				input := &AuthorizationInput{
+					Claims:      claims,
+					Permissions: permissions.List(),
+					Method:      r.Method,
+					Path:        r.URL.Path,
+				}
+				for _, authorizer := range m.authorizers {
+					decision, err := authorizer.Authorize(r.Context(), input)
+					if err != nil {
+						http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+						return
+					}
+					if !decision.Allow {
+						forbidden(w, decision.Reason)
+						return
+					}
+				}
+			}
+			ctx := oidc.ContextWithClaims(r.Context(), claims.Subject, claims)
+			ctx = context.WithValue(ctx, permissionsKey{}, permissions)
+			if expiredBy > 0 {
+				ctx = context.WithValue(ctx, expiredByKey{}, expiredBy)
+			}
+			if staleness := staleness(); staleness != nil {
+				ctx = context.WithValue(ctx, stalenessKey{}, staleness)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// verifyDPoP verifies the proof of DPoP-bound tokens
+// and rejects them if presented as bearer tokens.
+func (m *middleware) verifyDPoP(r *http.Request, token string, dpop bool, claims *oidc.IntrospectionResponse) error {
+	if !dpop {
+		if claims.Confirmation != nil && claims.Confirmation.JWKThumbprint != "" {
+			return ErrDPoPBoundBearer
+		}
+		return nil
+	}
+	return VerifyDPoP(r.Context(), r, m.requestURI(r), token, claims, m.dpopReplay)
+}
+
+func forbidden(w http.ResponseWriter, reason string) {
+	w.Header().Set("WWW-Authenticate", oidc.BearerToken+` error="insufficient_scope"`)
+	msg := http.StatusText(http.StatusForbidden)
+	if reason != "" {
+		msg += ": " + reason
+	}
+	http.Error(w, msg, http.StatusForbidden)
+}
+
+func unauthorized(w http.ResponseWriter, params string) {
+	w.Header().Set("WWW-Authenticate", oidc.BearerToken+` realm="api"`+params)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
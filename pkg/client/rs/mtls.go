@@ -0,0 +1,54 @@
+package rs
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+var ErrClientCertificateMissing = errors.New("client certificate missing")
+
+// WithClientCertificate overrides how the client certificate of requests is read
+// to verify certificate-bound access tokens, e.g. for a resource server behind
+// a TLS terminating proxy. It defaults to [TLSClientCertificate].
+func WithClientCertificate(clientCertificate func(*http.Request) (*x509.Certificate, error)) MiddlewareOption {
+	return func(m *middleware) {
+		m.clientCertificate = clientCertificate
+	}
+}
+
+// TLSClientCertificate returns the leaf certificate presented by the client
+// on the TLS connection of the request, or nil if none.
+func TLSClientCertificate(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+	return r.TLS.PeerCertificates[0], nil
+}
+
+// VerifyCertificateBinding verifies that the access token, whose validated claims
+// bind it to a client certificate (RFC 8705, section 3), is presented on a
+// connection with the certificate. Tokens without a certificate binding are accepted.
+func VerifyCertificateBinding(cert *x509.Certificate, claims *oidc.IntrospectionResponse) error {
+	if claims.Confirmation == nil || claims.Confirmation.CertificateThumbprint == "" {
+		return nil
+	}
+	if cert == nil {
+		return ErrClientCertificateMissing
+	}
+	return oidc.VerifyCertificateBinding(cert, claims.Confirmation)
+}
+
+// verifyCertificateBinding verifies certificate-bound tokens with the client certificate of the request.
+func (m *middleware) verifyCertificateBinding(r *http.Request, claims *oidc.IntrospectionResponse) error {
+	if claims.Confirmation == nil || claims.Confirmation.CertificateThumbprint == "" {
+		return nil
+	}
+	cert, err := m.clientCertificate(r)
+	if err != nil {
+		return err
+	}
+	return VerifyCertificateBinding(cert, claims)
+}
@@ -0,0 +1,86 @@
+package rs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func testCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestMiddleware_CertificateBinding(t *testing.T) {
+	rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+	)
+	require.NoError(t, err)
+	cert := testCertificate(t)
+	otherCert := testCertificate(t)
+	bound, _ := tu.NewJWTAccessTokenCustom(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, tu.ValidExpiration, tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew,
+		map[string]any{"cnf": map[string]any{"x5t#S256": oidc.CertificateThumbprint(cert)}},
+	)
+	unbound, _ := tu.ValidJWTAccessToken()
+
+	tests := []struct {
+		name       string
+		token      string
+		cert       *x509.Certificate
+		wantStatus int
+	}{
+		{"bound", bound, cert, http.StatusOK},
+		{"other certificate", bound, otherCert, http.StatusUnauthorized},
+		{"missing certificate", bound, nil, http.StatusUnauthorized},
+		{"unbound", unbound, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://example.com/api", nil)
+			r.Header.Set("Authorization", oidc.PrefixBearer+tt.token)
+			if tt.cert != nil {
+				r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert}}
+			}
+			w := httptest.NewRecorder()
+			Middleware(rs, nil, nil)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	t.Run("forwarded certificate", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/api", nil)
+		r.Header.Set("Authorization", oidc.PrefixBearer+bound)
+		w := httptest.NewRecorder()
+		Middleware(rs, nil, nil, WithClientCertificate(func(*http.Request) (*x509.Certificate, error) {
+			return cert, nil
+		}))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,95 @@
+package rs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+var (
+	ErrUnknownIssuer  = errors.New("resource server: unknown issuer")
+	ErrIssuerMismatch = errors.New("resource server: issuer of introspection response does not match the token")
+)
+
+// MultiIssuer accepts tokens from multiple trusted issuers, e.g. for APIs
+// serving customers on different identity providers.
+// JWT tokens are routed by their (unverified) `iss` claim to the
+// ResourceServer configured for the issuer. Tokens of unknown issuers are rejected.
+type MultiIssuer struct {
+	servers map[string]ResourceServer
+	opaque  ResourceServer
+}
+
+type MultiIssuerOption func(*MultiIssuer)
+
+// WithOpaqueTokenServer sets the ResourceServer used for opaque (non JWT) tokens,
+// which carry no issuer. By default, opaque tokens are rejected.
+func WithOpaqueTokenServer(server ResourceServer) MultiIssuerOption {
+	return func(m *MultiIssuer) {
+		m.opaque = server
+	}
+}
+
+// NewMultiIssuer returns a MultiIssuer for the ResourceServers by their issuer.
+func NewMultiIssuer(servers map[string]ResourceServer, options ...MultiIssuerOption) *MultiIssuer {
+	m := &MultiIssuer{
+		servers: make(map[string]ResourceServer, len(servers)),
+	}
+	for issuer, server := range servers {
+		m.servers[issuer] = server
+	}
+	for _, opt := range options {
+		opt(m)
+	}
+	return m
+}
+
+// Route returns the issuer and the ResourceServer responsible for the token.
+// The issuer is empty for opaque tokens.
+//
+// The token is NOT verified: the caller must verify it with the returned
+// ResourceServer, e.g. by [IntrospectMultiIssuer].
+func (m *MultiIssuer) Route(token string) (issuer string, server ResourceServer, err error) {
+	issuer, err = oidc.UnverifiedIssuer(token)
+	if errors.Is(err, oidc.ErrParse) {
+		if m.opaque == nil {
+			return "", nil, fmt.Errorf("%w: opaque token", ErrUnknownIssuer)
+		}
+		return "", m.opaque, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	server, ok := m.servers[issuer]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %q", ErrUnknownIssuer, issuer)
+	}
+	return issuer, server, nil
+}
+
+// IntrospectMultiIssuer calls [Introspect] on the ResourceServer of the token's issuer.
+// If R provides a GetIssuer method, such as [*oidc.IntrospectionResponse],
+// a response issuer different from the routed issuer is rejected.
+func IntrospectMultiIssuer[R any](ctx context.Context, m *MultiIssuer, token string) (resp R, err error) {
+	ctx, span := client.Tracer.Start(ctx, "IntrospectMultiIssuer")
+	defer span.End()
+
+	issuer, server, err := m.Route(token)
+	if err != nil {
+		return resp, err
+	}
+	resp, err = Introspect[R](ctx, server, token)
+	if err != nil {
+		return resp, err
+	}
+	if ig, ok := any(resp).(interface{ GetIssuer() string }); ok && issuer != "" {
+		if got := ig.GetIssuer(); got != "" && got != issuer {
+			var nilResp R
+			return nilResp, fmt.Errorf("%w: expected %q, got %q", ErrIssuerMismatch, issuer, got)
+		}
+	}
+	return resp, nil
+}
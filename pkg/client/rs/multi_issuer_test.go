@@ -0,0 +1,72 @@
+package rs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestIntrospectMultiIssuer(t *testing.T) {
+	newServer := func(t *testing.T, issuer string) ResourceServer {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{
+				Active:  true,
+				Issuer:  issuer,
+				Subject: r.FormValue("token"),
+			})
+		}))
+		t.Cleanup(s.Close)
+		rs, err := NewResourceServerClientCredentials(context.Background(), issuer, "client", "secret",
+			WithStaticEndpoints(s.URL+"/token", s.URL+"/introspect"),
+		)
+		require.NoError(t, err)
+		return rs
+	}
+	jwt := func(issuer string) string {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`))
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"` + issuer + `"}`))
+		return header + "." + claims + ".sig"
+	}
+
+	multi := NewMultiIssuer(map[string]ResourceServer{
+		"https://a.example.com": newServer(t, "https://a.example.com"),
+		"https://b.example.com": newServer(t, "https://b.example.com"),
+		"https://c.example.com": newServer(t, "https://other.example.com"),
+	}, WithOpaqueTokenServer(newServer(t, "https://a.example.com")))
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"issuer a", jwt("https://a.example.com"), nil},
+		{"issuer b", jwt("https://b.example.com"), nil},
+		{"opaque", "opaque-token", nil},
+		{"unknown issuer", jwt("https://evil.example.com"), ErrUnknownIssuer},
+		{"issuer mismatch", jwt("https://c.example.com"), ErrIssuerMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IntrospectMultiIssuer[*oidc.IntrospectionResponse](context.Background(), multi, tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.token, got.Subject)
+		})
+	}
+
+	_, _, err := NewMultiIssuer(nil).Route("opaque-token")
+	assert.ErrorIs(t, err, ErrUnknownIssuer)
+}
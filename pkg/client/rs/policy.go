@@ -0,0 +1,148 @@
+package rs
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// Permission is an application permission, e.g. "documents:write".
+type Permission string
+
+// Permissions is the set of permissions granted by a token.
+type Permissions map[Permission]struct{}
+
+// Has reports whether the permission is granted.
+func (p Permissions) Has(permission Permission) bool {
+	_, ok := p[permission]
+	return ok
+}
+
+// List returns the sorted permissions.
+func (p Permissions) List() []Permission {
+	list := make([]Permission, 0, len(p))
+	for permission := range p {
+		list = append(list, permission)
+	}
+	slices.Sort(list)
+	return list
+}
+
+// ClaimMapping maps the values of a claim, such as `groups`, `roles` or `scp`,
+// to application permissions.
+//
+// A claim value may be a string (space delimited for `scope` and `scp`),
+// a list of strings, or an object keyed by the values (e.g. role claims).
+type ClaimMapping struct {
+	Claim  string
+	Values map[string][]Permission
+}
+
+// PermissionMapper declaratively maps validated claims to permissions.
+//
+//	mapper := rs.PermissionMapper{
+//		{Claim: "groups", Values: map[string][]rs.Permission{"admins": {"users:read", "users:write"}}},
+//		{Claim: "scope", Values: map[string][]rs.Permission{"users.read": {"users:read"}}},
+//	}
+type PermissionMapper []ClaimMapping
+
+// Permissions returns the permissions granted by the claims.
+func (m PermissionMapper) Permissions(claims *oidc.IntrospectionResponse) Permissions {
+	permissions := make(Permissions)
+	for _, mapping := range m {
+		for _, value := range ClaimValues(claims, mapping.Claim) {
+			for _, permission := range mapping.Values[value] {
+				permissions[permission] = struct{}{}
+			}
+		}
+	}
+	return permissions
+}
+
+// ClaimValues returns the values of a claim as list of strings.
+// See [ClaimMapping] for the supported claim formats.
+func ClaimValues(claims *oidc.IntrospectionResponse, claim string) []string {
+	if claims == nil {
+		return nil
+	}
+	switch claim {
+	case "scope":
+		return claims.Scope
+	case "sub":
+		return []string{claims.Subject}
+	case "client_id":
+		return []string{claims.ClientID}
+	}
+	switch v := claims.Claims[claim].(type) {
+	case string:
+		if claim == "scp" {
+			return strings.Fields(v)
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	case map[string]any:
+		values := make([]string, 0, len(v))
+		for key := range v {
+			values = append(values, key)
+		}
+		slices.Sort(values)
+		return values
+	}
+	return nil
+}
+
+// Policy decides on the permissions granted by a token.
+type Policy func(Permissions) bool
+
+// RequireAll allows access if all permissions are granted.
+func RequireAll(permissions ...Permission) Policy {
+	return func(granted Permissions) bool {
+		for _, p := range permissions {
+			if !granted.Has(p) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RequireAny allows access if at least one of the permissions is granted.
+func RequireAny(permissions ...Permission) Policy {
+	return func(granted Permissions) bool {
+		return slices.ContainsFunc(permissions, granted.Has)
+	}
+}
+
+// AllOf allows access if all policies allow it.
+func AllOf(policies ...Policy) Policy {
+	return func(granted Permissions) bool {
+		for _, policy := range policies {
+			if !policy(granted) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf allows access if at least one of the policies allows it.
+func AnyOf(policies ...Policy) Policy {
+	return func(granted Permissions) bool {
+		for _, policy := range policies {
+			if policy(granted) {
+				return true
+			}
+		}
+		return false
+	}
+}
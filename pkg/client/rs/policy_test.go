@@ -0,0 +1,103 @@
+package rs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestPermissionMapper(t *testing.T) {
+	var claims oidc.IntrospectionResponse
+	err := json.Unmarshal([]byte(`{
+		"active": true,
+		"scope": "openid docs.read",
+		"scp": "api.write",
+		"groups": ["admins", 1],
+		"roles": {"editor": {"project": "1"}}
+	}`), &claims)
+	require.NoError(t, err)
+
+	mapper := PermissionMapper{
+		{Claim: "scope", Values: map[string][]Permission{"docs.read": {"docs:read"}}},
+		{Claim: "scp", Values: map[string][]Permission{"api.write": {"api:write"}}},
+		{Claim: "groups", Values: map[string][]Permission{"admins": {"users:read", "users:write"}}},
+		{Claim: "roles", Values: map[string][]Permission{"editor": {"docs:write"}, "viewer": {"docs:list"}}},
+		{Claim: "missing", Values: map[string][]Permission{"x": {"x"}}},
+	}
+	got := mapper.Permissions(&claims)
+	assert.Equal(t, Permissions{
+		"docs:read": {}, "api:write": {}, "users:read": {}, "users:write": {}, "docs:write": {},
+	}, got)
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   bool
+	}{
+		{"all", RequireAll("docs:read", "docs:write"), true},
+		{"all missing", RequireAll("docs:read", "docs:list"), false},
+		{"any", RequireAny("docs:list", "docs:write"), true},
+		{"any missing", RequireAny("docs:list", "x"), false},
+		{"all of", AllOf(RequireAny("x", "docs:read"), RequireAll("users:write")), true},
+		{"all of denied", AllOf(RequireAny("x"), RequireAll("users:write")), false},
+		{"any of", AnyOf(RequireAll("x"), RequireAll("users:write")), true},
+		{"any of denied", AnyOf(RequireAll("x"), RequireAll("y")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy(got))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+	)
+	require.NoError(t, err)
+	token, _ := tu.ValidJWTAccessToken()
+	mapper := PermissionMapper{
+		{Claim: "sub", Values: map[string][]Permission{tu.ValidSubject: {"read"}}},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, tu.ValidSubject, ClaimsFromContext(r.Context()).Subject)
+		subject, _ := oidc.SubjectFromContext(r.Context())
+		assert.Equal(t, tu.ValidSubject, subject)
+		assert.True(t, PermissionsFromContext(r.Context()).Has("read"))
+	})
+
+	tests := []struct {
+		name       string
+		auth       string
+		policy     Policy
+		wantStatus int
+	}{
+		{"allowed", oidc.PrefixBearer + token, RequireAll("read"), http.StatusOK},
+		{"no policy", oidc.PrefixBearer + token, nil, http.StatusOK},
+		{"forbidden", oidc.PrefixBearer + token, RequireAll("write"), http.StatusForbidden},
+		{"missing token", "", nil, http.StatusUnauthorized},
+		{"invalid token", oidc.PrefixBearer + "foo", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			Middleware(rs, mapper, tt.policy)(next).ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), oidc.BearerToken)
+			}
+		})
+	}
+}
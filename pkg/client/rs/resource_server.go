@@ -2,11 +2,19 @@ package rs
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
 
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
 	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
@@ -19,11 +27,28 @@ type ResourceServer interface {
 }
 
 type resourceServer struct {
-	issuer        string
-	tokenURL      string
-	introspectURL string
-	httpClient    *http.Client
-	authFn        func() (any, error)
+	issuer          string
+	tokenURL        string
+	introspectURL   string
+	httpClient      *http.Client
+	authFn          func() (any, error)
+	insecure        bool
+	jwksURL         string
+	keySet          oidc.KeySet
+	tokenFormat     TokenFormat
+	sharedCache     cache.Cache
+	sharedTTL       time.Duration
+	retryPolicy     *httphelper.RetryPolicy
+	telemetry       *client.Telemetry
+	instrumentation *client.Instrumentation
+	audience        string
+
+	introspectionFallback bool
+	introspectCache       cache.Cache
+	introspectTTL         time.Duration
+	failover              *introspectionFailover
+	stale                 *cache.StalePolicy
+	denylist              Denylist
 }
 
 func (r *resourceServer) IntrospectionURL() string {
@@ -46,7 +71,7 @@ func NewResourceServerClientCredentials(ctx context.Context, issuer, clientID, c
 	authorizer := func() (any, error) {
 		return httphelper.AuthorizeBasic(clientID, clientSecret), nil
 	}
-	return newResourceServer(ctx, issuer, authorizer, option...)
+	return newResourceServer(ctx, issuer, authorizer, append([]Option{WithAudience(clientID)}, option...)...)
 }
 
 func NewResourceServerJWTProfile(ctx context.Context, issuer, clientID, keyID string, key []byte, options ...Option) (ResourceServer, error) {
@@ -61,7 +86,7 @@ func NewResourceServerJWTProfile(ctx context.Context, issuer, clientID, keyID st
 		}
 		return client.ClientAssertionFormAuthorization(assertion), nil
 	}
-	return newResourceServer(ctx, issuer, authorizer, options...)
+	return newResourceServer(ctx, issuer, authorizer, append([]Option{WithAudience(clientID)}, options...)...)
 }
 
 func newResourceServer(ctx context.Context, issuer string, authorizer func() (any, error), options ...Option) (*resourceServer, error) {
@@ -72,17 +97,42 @@ func newResourceServer(ctx context.Context, issuer string, authorizer func() (an
 	for _, optFunc := range options {
 		optFunc(rs)
 	}
+	if rs.telemetry != nil {
+		rs.httpClient = rs.telemetry.Client(rs.httpClient)
+	}
+	if rs.instrumentation != nil {
+		var err error
+		if rs.httpClient, err = rs.instrumentation.Client(rs.httpClient); err != nil {
+			return nil, err
+		}
+	}
 	if rs.introspectURL == "" || rs.tokenURL == "" {
-		config, err := client.Discover(ctx, rs.issuer, rs.httpClient)
+		var discover client.DiscoverFunc = client.Discover
+		if rs.insecure {
+			discover = client.DiscoverInsecure
+		}
+		if rs.sharedCache != nil {
+			discover = client.CachedDiscover(discover, rs.sharedCache, rs.sharedTTL)
+		}
+		config, err := discover(ctx, rs.issuer, rs.httpClient)
 		if err != nil {
 			return nil, err
 		}
+		config = client.MTLSEndpoints(rs.httpClient, config)
 		if rs.tokenURL == "" {
 			rs.tokenURL = config.TokenEndpoint
 		}
 		if rs.introspectURL == "" {
 			rs.introspectURL = config.IntrospectionEndpoint
 		}
+		rs.jwksURL = config.JwksURI
+	}
+	if rs.keySet == nil && rs.jwksURL != "" {
+		if rs.sharedCache != nil {
+			rs.keySet = rp.NewRemoteKeySet(rs.httpClient, rs.jwksURL, rp.SharedKeyCache(rs.sharedCache, rs.sharedTTL), rp.StaleKeyCache(rs.stale))
+		} else {
+			rs.keySet = rp.NewRemoteKeySet(rs.httpClient, rs.jwksURL)
+		}
 	}
 	if rs.tokenURL == "" {
 		return nil, errors.New("tokenURL is empty: please provide with either `WithStaticEndpoints` or a discovery url")
@@ -108,6 +158,15 @@ func WithClient(client *http.Client) Option {
 	}
 }
 
+// WithAllowInsecure enables the development mode, matching the issuer
+// of the discovery document with [oidc.MatchIssuer] in insecure mode.
+// It must not be used in production.
+func WithAllowInsecure() Option {
+	return func(server *resourceServer) {
+		server.insecure = true
+	}
+}
+
 // WithStaticEndpoints provides the ability to set static token and introspect URL
 func WithStaticEndpoints(tokenURL, introspectURL string) Option {
 	return func(server *resourceServer) {
@@ -116,6 +175,120 @@ func WithStaticEndpoints(tokenURL, introspectURL string) Option {
 	}
 }
 
+// WithSharedCache shares the discovery configuration, the keys of the jwks_uri
+// and the responses of active tokens from the introspection endpoint through
+// the cache for the ttl, with other instances using the same cache.
+// Introspection responses are cached until the exp of the token at most,
+// so revoked tokens may still be reported active within the ttl.
+func WithSharedCache(c cache.Cache, ttl time.Duration) Option {
+	return func(server *resourceServer) {
+		server.sharedCache = c
+		server.sharedTTL = ttl
+	}
+}
+
+// WithStalePolicy serves cached introspection responses and keys of the jwks_uri
+// past their ttl, during outages of the OP, according to the policy, see
+// [cache.StalePolicy]. Introspection responses are never served past the exp of the token.
+// It requires [WithCache] or [WithSharedCache]. The [Middleware] makes the
+// staleness of the validation available by [StalenessFromContext].
+func WithStalePolicy(policy cache.StalePolicy) Option {
+	return func(server *resourceServer) {
+		server.stale = &policy
+	}
+}
+
+// DefaultIntrospectionCacheSize is the number of introspection responses
+// kept by the in-memory cache of [WithCache].
+const DefaultIntrospectionCacheSize = 10000
+
+// WithCache caches the responses of active tokens from the introspection endpoint
+// for the ttl, but not beyond the exp of the token, so repeated introspections
+// of the same token are served from the cache. Revoked tokens may still be reported
+// active within the ttl. A nil cache uses a [cache.Memory] bounded to
+// DefaultIntrospectionCacheSize entries, a shared implementation, like the
+// redis subpackage, serves multiple instances.
+// It takes precedence over [WithSharedCache] for introspection responses.
+func WithCache(c cache.Cache, ttl time.Duration) Option {
+	return func(server *resourceServer) {
+		if c == nil {
+			c = &cache.Memory{MaxEntries: DefaultIntrospectionCacheSize, Evict: true}
+		}
+		server.introspectCache = c
+		server.introspectTTL = ttl
+	}
+}
+
+// WithRetryPolicy retries the requests of [Introspect], when throttled by the OP
+// with status 429 Too Many Requests, honoring its Retry-After header.
+// Without it, throttled requests fail with [httphelper.ErrThrottled].
+func WithRetryPolicy(policy *httphelper.RetryPolicy) Option {
+	return func(server *resourceServer) {
+		server.retryPolicy = policy
+	}
+}
+
+// WithTelemetry sets the User-Agent and telemetry headers on all requests
+// of the resource server, including discovery. See [client.Telemetry].
+func WithTelemetry(telemetry client.Telemetry) Option {
+	return func(server *resourceServer) {
+		server.telemetry = &telemetry
+	}
+}
+
+// WithTracerProvider records a span of every request of the resource server,
+// including discovery and introspection, see [client.Instrumentation].
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(server *resourceServer) {
+		if server.instrumentation == nil {
+			server.instrumentation = new(client.Instrumentation)
+		}
+		server.instrumentation.TracerProvider = tp
+	}
+}
+
+// WithMeterProvider records the count and duration of the requests of the
+// resource server by operation, see [client.Instrumentation].
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(server *resourceServer) {
+		if server.instrumentation == nil {
+			server.instrumentation = new(client.Instrumentation)
+		}
+		server.instrumentation.MeterProvider = mp
+	}
+}
+
+type retryPolicier interface {
+	throttleRetryPolicy() *httphelper.RetryPolicy
+}
+
+func (r *resourceServer) throttleRetryPolicy() *httphelper.RetryPolicy {
+	return r.retryPolicy
+}
+
+type introspectionCacher interface {
+	introspectionCache() (cache.Cache, time.Duration)
+	stalePolicy() *cache.StalePolicy
+}
+
+func (r *resourceServer) introspectionCache() (cache.Cache, time.Duration) {
+	if r.introspectCache != nil {
+		return r.introspectCache, r.introspectTTL
+	}
+	return r.sharedCache, r.sharedTTL
+}
+
+func (r *resourceServer) stalePolicy() *cache.StalePolicy {
+	return r.stale
+}
+
+// introspectionCacheKey does not contain the token itself,
+// so it cannot be read from the cache.
+func introspectionCacheKey(introspectionURL, token string) string {
+	hash := sha256.Sum256([]byte(introspectionURL + " " + token))
+	return "introspection:" + base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
 // Introspect calls the [RFC7662] Token Introspection
 // endpoint and returns the response in an instance of type R.
 // [*oidc.IntrospectionResponse] can be used as a good example, or use a custom type if type-safe
@@ -125,21 +298,103 @@ func WithStaticEndpoints(tokenURL, introspectURL string) Option {
 func Introspect[R any](ctx context.Context, rp ResourceServer, token string) (resp R, err error) {
 	ctx, span := client.Tracer.Start(ctx, "Introspect")
 	defer span.End()
+	ctx = client.WithOperation(ctx, client.OperationIntrospection)
 
 	if rp.IntrospectionURL() == "" {
 		return resp, errors.New("resource server: introspection URL is empty")
 	}
-	authFn, err := rp.AuthFn()
-	if err != nil {
-		return resp, err
+	if policier, ok := rp.(retryPolicier); ok && policier.throttleRetryPolicy() != nil {
+		ctx = httphelper.ContextWithRetryPolicy(ctx, policier.throttleRetryPolicy())
+	}
+	fetch := func(ctx context.Context) (json.RawMessage, error) {
+		if f, ok := rp.(failoverer); ok && f.introspectionFailover() != nil {
+			return introspectWithFailover(ctx, rp, f.introspectionFailover(), token)
+		}
+		return introspect(ctx, rp, rp.IntrospectionURL(), token)
+	}
+	var (
+		sharedCache cache.Cache
+		ttl         time.Duration
+		policy      *cache.StalePolicy
+		body        json.RawMessage
+	)
+	if cacher, ok := rp.(introspectionCacher); ok {
+		sharedCache, ttl = cacher.introspectionCache()
+		policy = cacher.stalePolicy()
+	}
+	if sharedCache == nil {
+		body, err = fetch(ctx)
+	} else {
+		key := introspectionCacheKey(rp.IntrospectionURL(), token)
+		body, err = policy.Fetch(ctx, sharedCache, key, func(ctx context.Context) (cache.Fetched, error) {
+			body, err := fetch(ctx)
+			if err != nil {
+				return cache.Fetched{}, err
+			}
+			return introspectionFetched(body, ttl), nil
+		})
 	}
-	req, err := httphelper.FormRequest(ctx, rp.IntrospectionURL(), &oidc.IntrospectionRequest{Token: token}, client.Encoder, authFn)
 	if err != nil {
 		return resp, err
 	}
-
-	if err := httphelper.HttpRequest(rp.HttpClient(), req, &resp); err != nil {
+	if err = json.Unmarshal(body, &resp); err != nil {
 		return resp, err
 	}
 	return resp, nil
 }
+
+func introspect(ctx context.Context, rp ResourceServer, introspectionURL, token string) (json.RawMessage, error) {
+	authFn, err := rp.AuthFn()
+	if err != nil {
+		return nil, err
+	}
+	req, err := httphelper.FormRequest(ctx, introspectionURL, &oidc.IntrospectionRequest{Token: token}, client.Encoder, authFn)
+	if err != nil {
+		return nil, err
+	}
+	var body json.RawMessage
+	if err = httphelper.HttpRequest(rp.HttpClient(), req, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// introspectWithFailover tries the endpoints in order, until one of them
+// responds or fails with an error of the request itself.
+func introspectWithFailover(ctx context.Context, rp ResourceServer, failover *introspectionFailover, token string) (json.RawMessage, error) {
+	var lastErr error
+	for _, url := range failover.endpoints(rp.IntrospectionURL()) {
+		if !failover.allow(url, time.Now()) {
+			continue
+		}
+		body, err := introspect(ctx, rp, url, token)
+		if ctx.Err() != nil {
+			failover.release(url)
+			return nil, err
+		}
+		failover.report(url, err, time.Now())
+		if !isEndpointFailure(err) {
+			return body, err
+		}
+		lastErr = err
+	}
+	return nil, unavailableError(lastErr)
+}
+
+// introspectionFetched caches the response of an active token,
+// until its expiration at most.
+func introspectionFetched(body json.RawMessage, ttl time.Duration) cache.Fetched {
+	var status struct {
+		Active     bool      `json:"active"`
+		Expiration oidc.Time `json:"exp"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil || !status.Active {
+		return cache.Fetched{Value: body}
+	}
+	fetched := cache.Fetched{Value: body, TTL: ttl}
+	if expiration := status.Expiration.AsTime(); !expiration.IsZero() {
+		fetched.TTL = min(ttl, time.Until(expiration))
+		fetched.Expires = expiration
+	}
+	return fetched
+}
@@ -2,8 +2,15 @@ package rs
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -219,3 +226,145 @@ func TestIntrospect(t *testing.T) {
 		})
 	}
 }
+
+func TestIntrospect_sharedCache(t *testing.T) {
+	var calls atomic.Int32
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{
+			Active:     r.FormValue("token") != "inactive",
+			Subject:    "introspected",
+			Expiration: oidc.FromTime(time.Now().Add(time.Hour)),
+		})
+	}))
+	defer introspection.Close()
+	shared := cache.NewMemory()
+	newRS := func() ResourceServer {
+		rs, err := NewResourceServerClientCredentials(context.Background(), "https://issuer.example.com", "client", "secret",
+			WithStaticEndpoints(introspection.URL+"/token", introspection.URL+"/introspect"),
+			WithSharedCache(shared, time.Minute),
+		)
+		require.NoError(t, err)
+		return rs
+	}
+
+	for _, rs := range []ResourceServer{newRS(), newRS()} {
+		resp, err := Introspect[*oidc.IntrospectionResponse](context.Background(), rs, "active")
+		require.NoError(t, err)
+		assert.True(t, resp.Active)
+		assert.Equal(t, "introspected", resp.Subject)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	// inactive tokens are introspected again
+	for i := 0; i < 2; i++ {
+		resp, err := Introspect[*oidc.IntrospectionResponse](context.Background(), newRS(), "inactive")
+		require.NoError(t, err)
+		assert.False(t, resp.Active)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestIntrospect_cache(t *testing.T) {
+	var calls atomic.Int32
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{
+			Active:     true,
+			Subject:    r.FormValue("token"),
+			Expiration: oidc.FromTime(time.Now().Add(time.Hour)),
+		})
+	}))
+	defer introspection.Close()
+	rs, err := NewResourceServerClientCredentials(context.Background(), "https://issuer.example.com", "client", "secret",
+		WithStaticEndpoints(introspection.URL+"/token", introspection.URL+"/introspect"),
+		WithCache(nil, time.Minute),
+	)
+	require.NoError(t, err)
+
+	for _, token := range []string{"a", "b", "a", "b"} {
+		resp, err := Introspect[*oidc.IntrospectionResponse](context.Background(), rs, token)
+		require.NoError(t, err)
+		assert.Equal(t, token, resp.Subject)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIntrospect_retryPolicy(t *testing.T) {
+	var calls atomic.Int32
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{Active: r.FormValue("token") == "active"})
+	}))
+	defer introspection.Close()
+	rs, err := NewResourceServerClientCredentials(context.Background(), "https://issuer.example.com", "client", "secret",
+		WithStaticEndpoints(introspection.URL+"/token", introspection.URL+"/introspect"),
+		WithRetryPolicy(&httphelper.RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond}),
+	)
+	require.NoError(t, err)
+
+	resp, err := Introspect[*oidc.IntrospectionResponse](context.Background(), rs, "active")
+	require.NoError(t, err)
+	assert.True(t, resp.Active)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIntrospect_stalePolicy(t *testing.T) {
+	var down atomic.Bool
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{
+			Active:     true,
+			Subject:    "introspected",
+			Expiration: oidc.FromTime(time.Now().Add(time.Hour)),
+		})
+	}))
+	defer introspection.Close()
+	rs, err := NewResourceServerClientCredentials(context.Background(), "https://issuer.example.com", "client", "secret",
+		WithStaticEndpoints(introspection.URL+"/token", introspection.URL+"/introspect"),
+		WithCache(nil, 10*time.Millisecond),
+		WithStalePolicy(cache.StalePolicy{IfError: time.Minute}),
+	)
+	require.NoError(t, err)
+	var staleness *cache.Staleness
+	handler := Middleware(rs, nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		staleness = StalenessFromContext(r.Context())
+	}))
+	serve := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve())
+	assert.Nil(t, staleness)
+
+	down.Store(true)
+	time.Sleep(15 * time.Millisecond)
+	require.Equal(t, http.StatusOK, serve())
+	require.NotNil(t, staleness)
+	assert.Positive(t, staleness.Age)
+	assert.Error(t, staleness.Err)
+
+	// without the stale policy, the outage fails the introspection
+	rs, err = NewResourceServerClientCredentials(context.Background(), "https://issuer.example.com", "client", "secret",
+		WithStaticEndpoints(introspection.URL+"/token", introspection.URL+"/introspect"),
+		WithCache(nil, 10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	_, err = Introspect[*oidc.IntrospectionResponse](context.Background(), rs, "token")
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package rs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// TokenFormat defines how [ValidateToken] validates access tokens.
+type TokenFormat int
+
+const (
+	// TokenFormatAuto detects the format of each token: JWT access tokens,
+	// typed at+jwt (RFC 9068), are validated locally if the keys of the issuer are known,
+	// other tokens are introspected. This includes JWTs of the plain JWT type,
+	// which providers (like the op package by default) issue as access tokens as well.
+	TokenFormatAuto TokenFormat = iota
+	// TokenFormatOpaque introspects all tokens.
+	TokenFormatOpaque
+	// TokenFormatJWT validates all tokens locally as JWT (RFC 9068).
+	TokenFormatJWT
+)
+
+var (
+	ErrTokenInactive = errors.New("resource server: token is not active")
+	ErrNoKeySet      = errors.New("resource server: no key set for local JWT validation")
+)
+
+// WithTokenFormat sets the TokenFormat used by [ValidateToken], defaults to [TokenFormatAuto].
+func WithTokenFormat(format TokenFormat) Option {
+	return func(server *resourceServer) {
+		server.tokenFormat = format
+	}
+}
+
+// WithKeySet sets the key set for local JWT validation.
+// By default, the keys are fetched from the jwks_uri of the discovery document.
+func WithKeySet(keySet oidc.KeySet) Option {
+	return func(server *resourceServer) {
+		server.keySet = keySet
+	}
+}
+
+// WithJWKsURL sets the jwks_uri for local JWT validation,
+// for use with [WithStaticEndpoints].
+func WithJWKsURL(jwksURL string) Option {
+	return func(server *resourceServer) {
+		server.jwksURL = jwksURL
+	}
+}
+
+type tokenValidator interface {
+	Issuer() string
+	KeySet() oidc.KeySet
+	TokenFormat() TokenFormat
+	AllowInsecure() bool
+}
+
+func (r *resourceServer) Issuer() string {
+	return r.issuer
+}
+
+func (r *resourceServer) KeySet() oidc.KeySet {
+	return r.keySet
+}
+
+func (r *resourceServer) TokenFormat() TokenFormat {
+	return r.tokenFormat
+}
+
+func (r *resourceServer) AllowInsecure() bool {
+	return r.insecure
+}
+
+// ValidateToken validates the access token, without requiring the application
+// to know the token format of the provider ahead of time.
+// Depending on the [TokenFormat] of the ResourceServer, the token
+// is validated locally as JWT access token like by [VerifyAccessToken],
+// or introspected and required to be active.
+// Valid tokens revoked by the [WithDenylist] are rejected with [ErrTokenRevoked].
+// Resource servers not created by this package are always introspected.
+func ValidateToken(ctx context.Context, rs ResourceServer, token string) (*oidc.IntrospectionResponse, error) {
+	ctx, span := client.Tracer.Start(ctx, "ValidateToken")
+	defer span.End()
+
+	claims, _, err := validateToken(ctx, rs, token, 0)
+	return claims, err
+}
+
+// ValidateTokenWithResult is like [ValidateToken], but returns the claims
+// with the metadata of the token and the checks applied.
+func ValidateTokenWithResult(ctx context.Context, rs ResourceServer, token string) (*oidc.ValidationResult[*oidc.IntrospectionResponse], error) {
+	return oidc.ValidateWithResult(ctx, token, func(ctx context.Context) (*oidc.IntrospectionResponse, error) {
+		return ValidateToken(ctx, rs, token)
+	})
+}
+
+// validateToken accepts JWTs expired by at most grace
+// and returns the duration they are expired by.
+// Valid tokens are checked against the [Denylist] of rs.
+func validateToken(ctx context.Context, rs ResourceServer, token string, grace time.Duration) (*oidc.IntrospectionResponse, time.Duration, error) {
+	claims, expiredBy, err := validateTokenFormat(ctx, rs, token, grace)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = checkDenylist(ctx, rs, claims); err != nil {
+		return nil, 0, err
+	}
+	return claims, expiredBy, nil
+}
+
+func validateTokenFormat(ctx context.Context, rs ResourceServer, token string, grace time.Duration) (*oidc.IntrospectionResponse, time.Duration, error) {
+	v, ok := rs.(accessTokenVerifier)
+	if !ok {
+		return introspectActive(ctx, rs, token)
+	}
+	switch v.TokenFormat() {
+	case TokenFormatOpaque:
+		return introspectActive(ctx, rs, token)
+	case TokenFormatJWT:
+		return verifyJWT(ctx, v, token, grace)
+	default:
+		if v.KeySet() == nil || !isJWTAccessToken(token) {
+			return introspectActive(ctx, rs, token)
+		}
+		return verifyJWT(ctx, v, token, grace)
+	}
+}
+
+// isJWTAccessToken reports if the token is a JWT typed as access token.
+func isJWTAccessToken(token string) bool {
+	header, _, err := oidc.DecodeUnverified[map[string]any](token)
+	return err == nil && isAccessTokenType(header.Type)
+}
+
+// introspectActive requires the token to be active,
+// expired tokens are never reported active by the introspection endpoint.
+func introspectActive(ctx context.Context, rs ResourceServer, token string) (*oidc.IntrospectionResponse, time.Duration, error) {
+	resp, err := Introspect[*oidc.IntrospectionResponse](ctx, rs, token)
+	if err = oidc.ExplanationFromContext(ctx).Check("introspection", err, "url", rs.IntrospectionURL()); err != nil {
+		return nil, 0, err
+	}
+	if !resp.Active {
+		return nil, 0, oidc.ExplanationFromContext(ctx).Check("active", ErrTokenInactive, "actual", false)
+	}
+	return resp, 0, nil
+}
+
+func verifyJWT(ctx context.Context, v accessTokenVerifier, token string, grace time.Duration) (*oidc.IntrospectionResponse, time.Duration, error) {
+	keySet := v.KeySet()
+	if keySet == nil {
+		return nil, 0, ErrNoKeySet
+	}
+	explanation := oidc.ExplanationFromContext(ctx)
+	claims := new(oidc.AccessTokenClaims)
+	header, _, err := oidc.DecodeUnverified[map[string]any](token)
+	var payload []byte
+	if err == nil {
+		payload, err = oidc.ParseToken(token, claims)
+	}
+	if err = explanation.Check("parse", err); err != nil {
+		return nil, 0, err
+	}
+	if err = checkAccessTokenJWT(ctx, v, keySet, token, header.Type, payload, claims); err != nil {
+		return nil, 0, err
+	}
+	if err = explanation.Check("exp", oidc.CheckExpiration(claims, -grace),
+		"actual", claims.GetExpiration(), "grace", grace); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrTokenInactive, err)
+	}
+	return introspectionFromClaims(claims), max(0, time.Since(claims.GetExpiration())), nil
+}
+
+func introspectionFromClaims(claims *oidc.AccessTokenClaims) *oidc.IntrospectionResponse {
+	tokenType := oidc.BearerToken
+	if claims.Confirmation != nil && claims.Confirmation.JWKThumbprint != "" {
+		tokenType = oidc.DPoPTokenType
+	}
+	return &oidc.IntrospectionResponse{
+		Active:                          true,
+		Scope:                           claims.Scopes,
+		ClientID:                        claims.ClientID,
+		TokenType:                       tokenType,
+		Expiration:                      claims.Expiration,
+		IssuedAt:                        claims.IssuedAt,
+		AuthTime:                        claims.AuthTime,
+		NotBefore:                       claims.NotBefore,
+		Subject:                         claims.Subject,
+		Audience:                        claims.Audience,
+		AuthenticationMethodsReferences: claims.AuthenticationMethodsReferences,
+		Issuer:                          claims.Issuer,
+		JWTID:                           claims.JWTID,
+		Actor:                           claims.Actor,
+		Confirmation:                    claims.Confirmation,
+		SessionID:                       claims.SessionID,
+		AuthorizationDetails:            claims.AuthorizationDetails,
+		Claims:                          claims.Claims,
+	}
+}
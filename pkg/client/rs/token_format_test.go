@@ -0,0 +1,128 @@
+package rs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestValidateToken(t *testing.T) {
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&oidc.IntrospectionResponse{
+			Active:  r.FormValue("token") == "opaque",
+			Subject: "introspected",
+		})
+	}))
+	defer introspection.Close()
+
+	newRS := func(t *testing.T, options ...Option) ResourceServer {
+		options = append(options, WithStaticEndpoints(introspection.URL+"/token", introspection.URL+"/introspect"))
+		rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret", options...)
+		require.NoError(t, err)
+		return rs
+	}
+	jwt, _ := tu.ValidJWTAccessToken()
+	expired, _ := tu.NewJWTAccessToken(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, time.Now().Add(-time.Hour), tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew)
+	idToken, _ := tu.ValidIDToken()
+	otherAudience, _ := tu.NewJWTAccessToken(tu.ValidIssuer, tu.ValidSubject, []string{"other"}, tu.ValidExpiration, tu.ValidJWTID, tu.ValidClientID, tu.ValidSkew)
+	notYetValid, _ := tu.NewJWTAccessToken(tu.ValidIssuer, tu.ValidSubject, tu.ValidAudience, tu.ValidExpiration, tu.ValidJWTID, tu.ValidClientID, -time.Hour)
+
+	tests := []struct {
+		name        string
+		options     []Option
+		token       string
+		wantSubject string
+		wantErr     error
+	}{
+		{"auto jwt", []Option{WithKeySet(tu.KeySet{})}, jwt, tu.ValidSubject, nil},
+		{"auto expired jwt", []Option{WithKeySet(tu.KeySet{})}, expired, "", ErrTokenInactive},
+		{"auto id token", []Option{WithKeySet(tu.KeySet{})}, idToken, "", ErrTokenInactive},
+		{"auto other audience", []Option{WithKeySet(tu.KeySet{})}, otherAudience, "", oidc.ErrAudience},
+		{"auto not yet valid", []Option{WithKeySet(tu.KeySet{})}, notYetValid, "", oidc.ErrNotYetValid},
+		{"auto opaque", []Option{WithKeySet(tu.KeySet{})}, "opaque", "introspected", nil},
+		{"auto inactive", []Option{WithKeySet(tu.KeySet{})}, "inactive", "", ErrTokenInactive},
+		{"auto without keys", nil, jwt, "", ErrTokenInactive},
+		{"opaque", []Option{WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatOpaque)}, jwt, "", ErrTokenInactive},
+		{"jwt", []Option{WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT)}, jwt, tu.ValidSubject, nil},
+		{"jwt without keys", []Option{WithTokenFormat(TokenFormatJWT)}, jwt, "", ErrNoKeySet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateToken(context.Background(), newRS(t, tt.options...), tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, got.Active)
+			assert.Equal(t, tt.wantSubject, got.Subject)
+		})
+	}
+}
+
+func TestValidateTokenWithResult(t *testing.T) {
+	rs, err := NewResourceServerClientCredentials(context.Background(), tu.ValidIssuer, tu.ValidAudience[0], "secret",
+		WithStaticEndpoints("http://localhost/token", "http://localhost/introspect"), WithKeySet(tu.KeySet{}), WithTokenFormat(TokenFormatJWT),
+	)
+	require.NoError(t, err)
+	token, _ := tu.ValidJWTAccessToken()
+
+	got, err := ValidateTokenWithResult(context.Background(), rs, token)
+	require.NoError(t, err)
+	assert.Equal(t, tu.ValidSubject, got.Claims.Subject)
+	assert.Equal(t, oidc.ValidationFormatJWT, got.Format)
+	assert.Equal(t, tu.ValidIssuer, got.Issuer)
+	assert.Equal(t, string(tu.SignatureAlgorithm), got.Algorithm)
+	var checks []string
+	for _, check := range got.Checks {
+		checks = append(checks, check.Name)
+	}
+	assert.Equal(t, []string{"parse", "typ", "iss", "aud", "nbf", "signature", "exp"}, checks)
+}
+
+// TestValidateToken_provider validates a JWT access token of a provider
+// with the default configuration, which types its tokens as JWT.
+func TestValidateToken_provider(t *testing.T) {
+	ctx := context.Background()
+	var handler http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	opStorage := storage.NewStorageWithClients(storage.NewUserStore(server.URL), map[string]*storage.Client{
+		"web": storage.WebClient("web", "secret"),
+	})
+	provider, err := op.NewOpenIDProvider(server.URL, &op.Config{CryptoKey: sha256.Sum256([]byte("test"))}, opStorage, op.WithAllowInsecure())
+	require.NoError(t, err)
+	handler = provider
+
+	webClient, err := opStorage.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	request := &oidc.JWTTokenRequest{Subject: "id1", Audience: []string{"web"}, Scopes: []string{oidc.ScopeOpenID}}
+	token, _, _, err := op.CreateAccessToken(op.ContextWithIssuer(ctx, server.URL), request, op.AccessTokenTypeJWT, provider, webClient, "")
+	require.NoError(t, err)
+	header, _, err := oidc.DecodeUnverified[map[string]any](token)
+	require.NoError(t, err)
+	require.Equal(t, "JWT", header.Type)
+
+	rs, err := NewResourceServerClientCredentials(ctx, server.URL, "web", "secret", WithAllowInsecure())
+	require.NoError(t, err)
+	require.NotNil(t, rs.(tokenValidator).KeySet())
+	got, err := ValidateToken(ctx, rs, token)
+	require.NoError(t, err)
+	assert.True(t, got.Active)
+	assert.Equal(t, "id1", got.Subject)
+}
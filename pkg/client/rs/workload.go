@@ -0,0 +1,128 @@
+package rs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+var (
+	ErrWorkloadNotBefore = errors.New("resource server: token is not valid yet")
+	ErrWorkloadClaim     = errors.New("resource server: workload claim is not allowed")
+)
+
+// WorkloadVerifier verifies the JWTs a platform issues to its workloads,
+// such as Kubernetes service account tokens, with the keys of the issuer.
+// Unlike access tokens (see [VerifyAccessToken]), they have no at+jwt typ header
+// and are validated without an introspection endpoint.
+//
+// The claims are decoded into an instance of type C, such as
+// [*KubernetesServiceAccountClaims], and checked by all Checks.
+type WorkloadVerifier[C oidc.Claims] struct {
+	Issuer string
+	// Audience must be contained in the aud claim.
+	Audience string
+	KeySet   oidc.KeySet
+	// SigningAlgorithms defaults to RS256, ES256 and PS256.
+	SigningAlgorithms []string
+	// FIPS restricts the SigningAlgorithms to the approved ones,
+	// defaults to [crypto.DefaultFIPS].
+	FIPS *crypto.FIPS
+	// Offset is the allowed clock skew for exp and nbf.
+	Offset time.Duration
+	// Checks are applied to the claims after the standard checks.
+	Checks []func(claims C) error
+}
+
+// Verify verifies the iss, aud, exp and nbf claims and the signature of the token,
+// followed by the Checks of the verifier.
+func (v *WorkloadVerifier[C]) Verify(ctx context.Context, token string) (claims C, err error) {
+	ctx, span := client.Tracer.Start(ctx, "WorkloadVerifier.Verify")
+	defer span.End()
+
+	var nilClaims C
+	var notBefore struct {
+		NotBefore oidc.Time `json:"nbf,omitempty"`
+	}
+	if _, err = oidc.ParseToken(token, &notBefore); err != nil {
+		return nilClaims, err
+	}
+	payload, err := oidc.ParseToken(token, &claims)
+	if err != nil {
+		return nilClaims, err
+	}
+	if err = oidc.CheckIssuer(claims, v.Issuer); err != nil {
+		return nilClaims, err
+	}
+	if err = oidc.CheckSubject(claims); err != nil {
+		return nilClaims, err
+	}
+	if err = oidc.CheckAudience(claims, v.Audience); err != nil {
+		return nilClaims, err
+	}
+	if err = oidc.CheckExpiration(claims, v.Offset); err != nil {
+		return nilClaims, fmt.Errorf("%w: %w", ErrTokenInactive, err)
+	}
+	if nbf := notBefore.NotBefore.AsTime(); !nbf.IsZero() && time.Now().Add(v.Offset).Before(nbf) {
+		return nilClaims, ErrWorkloadNotBefore
+	}
+	fips := v.FIPS
+	if fips == nil {
+		fips = crypto.DefaultFIPS()
+	}
+	algs, err := fips.ApprovedAlgorithms(v.SigningAlgorithms)
+	if err != nil {
+		return nilClaims, err
+	}
+	if err = oidc.CheckSignature(ctx, token, payload, claims, algs, v.KeySet); err != nil {
+		return nilClaims, err
+	}
+	for _, check := range v.Checks {
+		if err = check(claims); err != nil {
+			return nilClaims, err
+		}
+	}
+	return claims, nil
+}
+
+// WorkloadMiddleware authenticates requests with the bearer token of the
+// workload, verified by v. Requests without a valid token are answered
+// with 401 Unauthorized.
+//
+// The claims are available to the next handler by [oidc.ClaimsFromContext]
+// with type C, the subject by [oidc.SubjectFromContext].
+func WorkloadMiddleware[C oidc.Claims](v *WorkloadVerifier[C]) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, dpop, ok := accessTokenFromHeader(r.Header)
+			if !ok || dpop {
+				unauthorized(w, "")
+				return
+			}
+			claims, err := v.Verify(r.Context(), token)
+			if err != nil {
+				unauthorized(w, `, error="invalid_token"`)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(oidc.ContextWithClaims(r.Context(), claims.GetSubject(), claims)))
+		})
+	}
+}
+
+// claimIn returns a check of a claim against the allowed values,
+// which allows any value if empty.
+func claimIn[C any](name string, value func(C) string, allowed []string) func(C) error {
+	return func(claims C) error {
+		if len(allowed) == 0 || slices.Contains(allowed, value(claims)) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s %q is not allowed", ErrWorkloadClaim, name, value(claims))
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type SessionTransferCaller interface {
+	GetSessionTransferEndpoint() string
+	HttpClient() *http.Client
+}
+
+// SessionTransferRequest is the request of a first-party client for a transfer token
+// of the session of its user to its native app, authenticated by the client credentials.
+type SessionTransferRequest struct {
+	*oidc.ClientCredentialsRequest
+	oidc.SessionTransferRequest
+}
+
+// MarshalForm implements [httphelper.FormMarshaler].
+func (r *SessionTransferRequest) MarshalForm(form url.Values) error {
+	if err := errors.Join(
+		oidc.FormRequired("subject_token", r.SubjectToken),
+		oidc.FormRequired("audience", r.Audience),
+		oidc.FormRequired("code_challenge", r.CodeChallenge),
+	); err != nil {
+		return err
+	}
+	if err := marshalClientCredentials(form, r.ClientCredentialsRequest); err != nil {
+		return err
+	}
+	form.Set("subject_token", r.SubjectToken)
+	form.Set("audience", r.Audience)
+	oidc.FormSet(form, "scope", r.Scopes.String())
+	form.Set("code_challenge", r.CodeChallenge)
+	oidc.FormSet(form, "code_challenge_method", r.CodeChallengeMethod)
+	return nil
+}
+
+// CallSessionTransferEndpoint requests a transfer token, which the native app
+// redeems with [CallSessionTransferTokenEndpoint].
+func CallSessionTransferEndpoint(ctx context.Context, request *SessionTransferRequest, caller SessionTransferCaller) (*oidc.SessionTransferResponse, error) {
+	ctx, span := Tracer.Start(ctx, "CallSessionTransferEndpoint")
+	defer span.End()
+
+	endpoint := caller.GetSessionTransferEndpoint()
+	if endpoint == "" {
+		return nil, fmt.Errorf("session transfer %w", ErrEndpointNotSet)
+	}
+
+	req, err := httphelper.FormRequest(ctx, endpoint, request, Encoder, nil)
+	if err != nil {
+		return nil, err
+	}
+	if request.ClientSecret != "" {
+		req.SetBasicAuth(request.ClientCredentialsRequest.ClientID, request.ClientSecret)
+	}
+
+	resp := new(oidc.SessionTransferResponse)
+	if err := httphelper.HttpRequest(caller.HttpClient(), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type SessionTransferTokenRequest struct {
+	*oidc.ClientCredentialsRequest
+	oidc.SessionTransferTokenRequest
+}
+
+// MarshalForm implements [httphelper.FormMarshaler].
+func (r *SessionTransferTokenRequest) MarshalForm(form url.Values) error {
+	if err := errors.Join(
+		oidc.FormOneOf("grant_type", r.SessionTransferTokenRequest.GrantType, oidc.GrantTypeSessionTransfer),
+		oidc.FormRequired("transfer_token", r.TransferToken),
+		oidc.FormRequired("code_verifier", r.CodeVerifier),
+	); err != nil {
+		return err
+	}
+	if err := marshalClientCredentials(form, r.ClientCredentialsRequest); err != nil {
+		return err
+	}
+	form.Set("grant_type", string(oidc.GrantTypeSessionTransfer))
+	form.Set("transfer_token", r.TransferToken)
+	form.Set("code_verifier", r.CodeVerifier)
+	return nil
+}
+
+// CallSessionTransferTokenEndpoint redeems the transfer token with the session transfer grant.
+func CallSessionTransferTokenEndpoint(ctx context.Context, request *SessionTransferTokenRequest, caller TokenEndpointCaller) (*oidc.AccessTokenResponse, error) {
+	ctx, span := Tracer.Start(ctx, "CallSessionTransferTokenEndpoint")
+	defer span.End()
+
+	req, err := httphelper.FormRequest(ctx, caller.TokenEndpoint(), request, Encoder, nil)
+	if err != nil {
+		return nil, err
+	}
+	if request.ClientSecret != "" {
+		req.SetBasicAuth(request.ClientID, request.ClientSecret)
+	}
+
+	resp := new(oidc.AccessTokenResponse)
+	if err := httphelper.HttpRequest(caller.HttpClient(), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	// LibraryHeader carries the name and version of this library, see [Telemetry].
+	LibraryHeader = "X-Client-Library"
+	// IntegrationHeader carries the name of the integration, see [Telemetry].
+	IntegrationHeader = "X-Client-Integration"
+
+	modulePath = "github.com/lmindwarel/oidc/v3"
+)
+
+// Telemetry identifies the client to the provider on outbound requests,
+// which some providers require for support and abuse attribution.
+// Headers already set on a request are not overwritten.
+type Telemetry struct {
+	// UserAgent replaces the User-Agent of the Go http client, if not empty,
+	// e.g. "my-app/1.2 (+https://example.com)".
+	UserAgent string
+	// Integration is sent in the [IntegrationHeader], if not empty,
+	// e.g. the name and version of the framework using the library.
+	Integration string
+	// LibraryVersion sends the [LibraryVersion] in the [LibraryHeader].
+	LibraryVersion bool
+}
+
+// Client returns a copy of the http client, sending the headers of the Telemetry
+// on all requests. A nil httpClient is replaced by [http.DefaultClient].
+// It is used by the WithTelemetry options of packages rp and rs, and can wrap
+// the http clients of the other packages, like profile and tokenexchange.
+func (t Telemetry) Client(httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	wrapped := *httpClient
+	wrapped.Transport = &TelemetryTransport{
+		Telemetry: t,
+		Base:      httpClient.Transport,
+	}
+	return &wrapped
+}
+
+// TelemetryTransport is a http.RoundTripper setting the headers of the Telemetry
+// on outbound requests.
+type TelemetryTransport struct {
+	Telemetry
+	// Base is the underlying RoundTripper, defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *TelemetryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *TelemetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	setHeader(req.Header, "User-Agent", t.UserAgent)
+	setHeader(req.Header, IntegrationHeader, t.Integration)
+	if t.LibraryVersion {
+		setHeader(req.Header, LibraryHeader, "lmindwarel-oidc/"+LibraryVersion())
+	}
+	return t.base().RoundTrip(req)
+}
+
+func setHeader(header http.Header, key, value string) {
+	if value != "" && header.Get(key) == "" {
+		header.Set(key, value)
+	}
+}
+
+// LibraryVersion returns the module version of this library from the build
+// information of the binary, or "(devel)" if unknown.
+func LibraryVersion() string {
+	return libraryVersion()
+}
+
+var libraryVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	modules := append([]*debug.Module{&info.Main}, info.Deps...)
+	for _, module := range modules {
+		if module.Path != modulePath {
+			continue
+		}
+		if module.Replace != nil && module.Replace.Version != "" {
+			return module.Replace.Version
+		}
+		if module.Version != "" {
+			return module.Version
+		}
+	}
+	return "(devel)"
+})
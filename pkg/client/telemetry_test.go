@@ -0,0 +1,79 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetry_Client(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		telemetry Telemetry
+		request   http.Header
+		want      map[string]string
+	}{
+		{
+			name:      "user agent only",
+			telemetry: Telemetry{UserAgent: "my-app/1.2"},
+			want: map[string]string{
+				"User-Agent":      "my-app/1.2",
+				IntegrationHeader: "",
+				LibraryHeader:     "",
+			},
+		},
+		{
+			name:      "all headers",
+			telemetry: Telemetry{UserAgent: "my-app/1.2", Integration: "my-framework/3", LibraryVersion: true},
+			want: map[string]string{
+				"User-Agent":      "my-app/1.2",
+				IntegrationHeader: "my-framework/3",
+				LibraryHeader:     "lmindwarel-oidc/" + LibraryVersion(),
+			},
+		},
+		{
+			name:      "request headers take precedence",
+			telemetry: Telemetry{UserAgent: "my-app/1.2", Integration: "my-framework/3"},
+			request:   http.Header{"User-Agent": {"custom/1"}},
+			want: map[string]string{
+				"User-Agent":      "custom/1",
+				IntegrationHeader: "my-framework/3",
+			},
+		},
+		{
+			name: "go default",
+			want: map[string]string{
+				"User-Agent": "Go-http-client/1.1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+			for key, values := range tt.request {
+				req.Header[key] = values
+			}
+			resp, err := tt.telemetry.Client(nil).Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			for key, value := range tt.want {
+				assert.Equal(t, value, header.Get(key), key)
+			}
+			assert.Empty(t, req.Header.Get(IntegrationHeader), "request is not modified")
+		})
+	}
+}
+
+func TestLibraryVersion(t *testing.T) {
+	assert.NotEmpty(t, LibraryVersion())
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// DPoPProofer creates a DPoP proof (RFC 9449) for a request with the access token.
+// The nonce is empty, unless provided by the server.
+type DPoPProofer func(method, uri, accessToken, nonce string) (string, error)
+
+// NewDPoPProofer returns a DPoPProofer signing proofs with the key.
+func NewDPoPProofer(key any, alg jose.SignatureAlgorithm) (DPoPProofer, error) {
+	opts := (&jose.SignerOptions{EmbedJWK: true}).WithType(oidc.DPoPType)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(method, uri, accessToken, nonce string) (string, error) {
+		claims := &oidc.DPoPClaims{
+			JWTID:      uuid.New().String(),
+			HTTPMethod: method,
+			HTTPURI:    uri,
+			IssuedAt:   oidc.FromTime(time.Now()),
+			Nonce:      nonce,
+		}
+		if accessToken != "" {
+			claims.AccessTokenHash = oidc.DPoPAccessTokenHash(accessToken)
+		}
+		return crypto.Sign(claims, signer)
+	}, nil
+}
+
+// TokenTransport is a http.RoundTripper injecting the access token of the Source
+// into outbound requests, as bearer token or DPoP-bound token, if DPoP is set.
+//
+// On a 401 Unauthorized response, the request is retried once with a fresh token,
+// if the Source implements Invalidate() (such as the TokenSource of package tokencache)
+// and returns a different token, or with the DPoP-Nonce provided by the server.
+// Requests with a body are only retried, if the body can be replayed by GetBody.
+//
+// The token is obtained with the context of the request, if the Source implements
+// TokenCtx(context.Context) (such as the TokenSource of package tokencache
+// and the JWT profile token source of package profile).
+type TokenTransport struct {
+	Source oauth2.TokenSource
+	// Base is the underlying RoundTripper, defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// DPoP binds the tokens to the key of the proofer, if set.
+	DPoP DPoPProofer
+}
+
+// NewTokenClient returns a http.Client using a TokenTransport with the source.
+func NewTokenClient(source oauth2.TokenSource) *http.Client {
+	return &http.Client{Transport: &TokenTransport{Source: source}}
+}
+
+func (t *TokenTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// token returns the token of the Source, with the context of the request if supported.
+func (t *TokenTransport) token(ctx context.Context) (*oauth2.Token, error) {
+	if source, ok := t.Source.(interface {
+		TokenCtx(context.Context) (*oauth2.Token, error)
+	}); ok {
+		return source.TokenCtx(ctx)
+	}
+	return t.Source.Token()
+}
+
+func (t *TokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.roundTrip(req, token, "")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	nonce := resp.Header.Get(oidc.DPoPNonceHeader)
+	retry := t.DPoP != nil && nonce != ""
+	if invalidator, ok := t.Source.(interface{ Invalidate() }); ok && !retry {
+		invalidator.Invalidate()
+		fresh, err := t.token(req.Context())
+		if err != nil {
+			return resp, nil
+		}
+		retry = fresh.AccessToken != token.AccessToken
+		token = fresh
+	}
+	if !retry {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.roundTrip(req, token, nonce)
+}
+
+func (t *TokenTransport) roundTrip(req *http.Request, token *oauth2.Token, nonce string) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.DPoP == nil {
+		token.SetAuthHeader(req)
+		return t.base().RoundTrip(req)
+	}
+	uri := *req.URL
+	uri.RawQuery, uri.Fragment = "", ""
+	proof, err := t.DPoP(req.Method, uri.String(), token.AccessToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", oidc.DPoPTokenType+" "+token.AccessToken)
+	req.Header.Set(oidc.DPoPHeader, proof)
+	return t.base().RoundTrip(req)
+}
+
+// DPoPTransport is a http.RoundTripper adding a DPoP proof (RFC 9449) to the
+// requests of the Endpoint, such as the token endpoint of an OP,
+// which then binds the issued access tokens to the key of the proofer.
+// Requests to other URLs are passed unmodified.
+//
+// A request rejected with a DPoP-Nonce header is retried once with the nonce,
+// if the body can be replayed by GetBody.
+type DPoPTransport struct {
+	Proofer DPoPProofer
+	// Endpoint is the URL of the requests to add proofs to, without query.
+	Endpoint string
+	// Base is the underlying RoundTripper, defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *DPoPTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *DPoPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	uri := *req.URL
+	uri.RawQuery, uri.Fragment = "", ""
+	if uri.String() != t.Endpoint {
+		return t.base().RoundTrip(req)
+	}
+	resp, err := t.roundTrip(req, uri.String(), "")
+	if err != nil || (resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized) {
+		return resp, err
+	}
+	nonce := resp.Header.Get(oidc.DPoPNonceHeader)
+	if nonce == "" || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.roundTrip(req, uri.String(), nonce)
+}
+
+func (t *DPoPTransport) roundTrip(req *http.Request, uri, nonce string) (*http.Response, error) {
+	proof, err := t.Proofer(req.Method, uri, "", nonce)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(oidc.DPoPHeader, proof)
+	return t.base().RoundTrip(req)
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type rotatingSource struct {
+	tokens      []string
+	invalidated int
+}
+
+func (s *rotatingSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: s.tokens[s.invalidated], TokenType: oidc.BearerToken}, nil
+}
+
+func (s *rotatingSource) Invalidate() {
+	s.invalidated++
+}
+
+func TestTokenTransport(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "payload", string(body))
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer server.Close()
+
+	t.Run("refresh once", func(t *testing.T) {
+		requests = 0
+		source := &rotatingSource{tokens: []string{"stale", "fresh"}}
+		resp, err := NewTokenClient(source).Post(server.URL, "text/plain", strings.NewReader("payload"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, requests)
+	})
+	t.Run("no retry with same token", func(t *testing.T) {
+		requests = 0
+		source := &rotatingSource{tokens: []string{"stale", "stale"}}
+		resp, err := NewTokenClient(source).Post(server.URL, "text/plain", strings.NewReader("payload"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, 1, requests)
+	})
+	t.Run("no retry without invalidation", func(t *testing.T) {
+		requests = 0
+		source := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "stale"})
+		resp, err := NewTokenClient(source).Post(server.URL, "text/plain", strings.NewReader("payload"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, 1, requests)
+	})
+}
+
+type contextSource struct {
+	ctx context.Context
+}
+
+func (s *contextSource) Token() (*oauth2.Token, error) {
+	return s.TokenCtx(context.Background())
+}
+
+func (s *contextSource) TokenCtx(ctx context.Context) (*oauth2.Token, error) {
+	s.ctx = ctx
+	return &oauth2.Token{AccessToken: "fresh", TokenType: oidc.BearerToken}, nil
+}
+
+func TestTokenTransport_requestContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	source := new(contextSource)
+	resp, err := NewTokenClient(source).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotNil(t, source.ctx)
+	assert.Equal(t, "request", source.ctx.Value(ctxKey{}))
+}
+
+func TestTokenTransport_DPoP(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	proofer, err := NewDPoPProofer(key, jose.ES256)
+	require.NoError(t, err)
+
+	var nonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DPoP token", r.Header.Get("Authorization"))
+		jws, err := jose.ParseSigned(r.Header.Get(oidc.DPoPHeader), []jose.SignatureAlgorithm{jose.ES256})
+		require.NoError(t, err)
+		assert.Equal(t, oidc.DPoPType, jws.Signatures[0].Protected.ExtraHeaders[jose.HeaderType])
+		payload, err := jws.Verify(jws.Signatures[0].Protected.JSONWebKey)
+		require.NoError(t, err)
+		var claims oidc.DPoPClaims
+		require.NoError(t, json.Unmarshal(payload, &claims))
+		assert.Equal(t, http.MethodGet, claims.HTTPMethod)
+		assert.Equal(t, "http://"+r.Host+"/api", claims.HTTPURI)
+		assert.Equal(t, oidc.DPoPAccessTokenHash("token"), claims.AccessTokenHash)
+		nonces = append(nonces, claims.Nonce)
+		if claims.Nonce == "" {
+			w.Header().Set(oidc.DPoPNonceHeader, "server-nonce")
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	c := &http.Client{Transport: &TokenTransport{
+		Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+		DPoP:   proofer,
+	}}
+	resp, err := c.Get(server.URL + "/api?query=1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"", "server-nonce"}, nonces)
+}
+
+func TestDPoPTransport(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	proofer, err := NewDPoPProofer(key, jose.ES256)
+	require.NoError(t, err)
+
+	var nonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "grant_type=client_credentials", string(body))
+		if r.URL.Path != "/token" {
+			assert.Empty(t, r.Header.Get(oidc.DPoPHeader))
+			return
+		}
+		proof, err := oidc.VerifyDPoPProof(r.Header.Get(oidc.DPoPHeader), http.MethodPost, "http://"+r.Host+"/token", "")
+		require.NoError(t, err)
+		nonces = append(nonces, proof.Claims.Nonce)
+		if proof.Claims.Nonce == "" {
+			w.Header().Set(oidc.DPoPNonceHeader, "server-nonce")
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	c := &http.Client{Transport: &DPoPTransport{Proofer: proofer, Endpoint: server.URL + "/token"}}
+	resp, err := c.Post(server.URL+"/token", "application/x-www-form-urlencoded", strings.NewReader("grant_type=client_credentials"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"", "server-nonce"}, nonces)
+
+	resp, err = c.Post(server.URL+"/other", "application/x-www-form-urlencoded", strings.NewReader("grant_type=client_credentials"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,284 @@
+// Package tokencache caches access tokens of service clients per
+// (scopes, audience, resource) tuple, for services calling many downstream APIs.
+package tokencache
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/lmindwarel/oidc/v3/pkg/background"
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+	"github.com/lmindwarel/oidc/v3/pkg/client/tokenexchange"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// Key identifies a cached token.
+// The values are sorted and space delimited, so the order of the requested
+// scopes, audiences and resources does not matter.
+type Key struct {
+	Scopes   string
+	Audience string
+	Resource string
+}
+
+// NewKey returns the Key of the (scopes, audience, resource) tuple.
+func NewKey(scopes, audience, resource []string) Key {
+	return Key{
+		Scopes:   join(scopes),
+		Audience: join(audience),
+		Resource: join(resource),
+	}
+}
+
+func join(values []string) string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return strings.Join(slices.Compact(values), " ")
+}
+
+func split(value string) []string {
+	return strings.Fields(value)
+}
+
+// FetchFunc requests a new token for the Key.
+type FetchFunc func(ctx context.Context, key Key) (*oauth2.Token, error)
+
+// DefaultExpiryDelta is the time before the expiry of a token,
+// when it is refreshed.
+const DefaultExpiryDelta = 10 * time.Second
+
+// Cache maintains a separate token per Key.
+// Concurrent requests for the same Key share a single token request.
+type Cache struct {
+	ctx         context.Context
+	fetch       FetchFunc
+	expiryDelta time.Duration
+
+	mu       sync.Mutex
+	tokens   map[Key]*oauth2.Token
+	inflight map[Key]*inflight
+}
+
+type inflight struct {
+	done  chan struct{}
+	token *oauth2.Token
+	err   error
+}
+
+type Option func(*Cache)
+
+// WithExpiryDelta sets the time before the expiry of a token,
+// when it is refreshed, defaults to [DefaultExpiryDelta].
+func WithExpiryDelta(delta time.Duration) Option {
+	return func(c *Cache) {
+		c.expiryDelta = delta
+	}
+}
+
+// New returns a Cache requesting tokens with fetch.
+func New(fetch FetchFunc, options ...Option) *Cache {
+	c := &Cache{
+		fetch:       fetch,
+		expiryDelta: DefaultExpiryDelta,
+		tokens:      make(map[Key]*oauth2.Token),
+		inflight:    make(map[Key]*inflight),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// NewContext returns a Cache like [New], with the token requests bound to ctx,
+// e.g. of the application, so that cancelling it aborts the requests in progress.
+//
+// A token request is shared by all concurrent callers of the Key and runs in the
+// background: it is not aborted when the context of the caller which started it
+// is cancelled, the callers only stop waiting for it.
+// It runs in the [background.Group] of ctx, if any.
+func NewContext(ctx context.Context, fetch FetchFunc, options ...Option) *Cache {
+	c := New(fetch, options...)
+	c.ctx = ctx
+	return c
+}
+
+// Token returns the cached token for the Key,
+// or requests a new one if none is cached or it is about to expire.
+func (c *Cache) Token(ctx context.Context, key Key) (*oauth2.Token, error) {
+	c.mu.Lock()
+	if token, ok := c.tokens[key]; ok && c.valid(token) {
+		c.mu.Unlock()
+		return token, nil
+	}
+	call, ok := c.inflight[key]
+	if !ok {
+		call = &inflight{done: make(chan struct{})}
+		c.inflight[key] = call
+		refreshCtx, cancel := c.refreshContext(ctx)
+		groupCtx := ctx
+		if c.ctx != nil && background.Supervised(c.ctx) {
+			groupCtx = c.ctx
+		}
+		background.Go(groupCtx, func() error {
+			return c.refresh(refreshCtx, cancel, key, call)
+		})
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refreshContext returns the context of a shared token request, which keeps the values
+// of ctx, such as the trace span, but is only cancelled with the context of the Cache
+// or of the [background.Group] of ctx.
+func (c *Cache) refreshContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := background.Detach(ctx)
+	if c.ctx == nil {
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(c.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (c *Cache) refresh(ctx context.Context, cancel context.CancelFunc, key Key, call *inflight) error {
+	defer cancel()
+	ctx, span := client.Tracer.Start(ctx, "tokencache.refresh")
+	defer span.End()
+
+	call.token, call.err = c.fetch(ctx, key)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.tokens[key] = call.token
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+func (c *Cache) valid(token *oauth2.Token) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+	return token.Expiry.IsZero() || time.Now().Add(c.expiryDelta).Before(token.Expiry)
+}
+
+// Invalidate removes the cached token of the Key,
+// e.g. after it was rejected by the downstream API.
+func (c *Cache) Invalidate(key Key) {
+	c.mu.Lock()
+	delete(c.tokens, key)
+	c.mu.Unlock()
+}
+
+// TokenSource returns a TokenSource for the (scopes, audience, resource) tuple.
+func (c *Cache) TokenSource(scopes, audience, resource []string) *TokenSource {
+	return &TokenSource{cache: c, key: NewKey(scopes, audience, resource)}
+}
+
+// TokenSources returns a TokenSource per audience and resource, keyed by it,
+// each requesting and caching a token restricted to that single target,
+// instead of one token valid at all of them. Services fanning out to several
+// APIs so do not hand each API a token usable at the others,
+// see WithAudienceSplitting of the op package for the OP side.
+func (c *Cache) TokenSources(scopes, audience, resource []string) map[string]*TokenSource {
+	sources := make(map[string]*TokenSource, len(audience)+len(resource))
+	for _, aud := range audience {
+		sources[aud] = c.TokenSource(scopes, []string{aud}, nil)
+	}
+	for _, res := range resource {
+		sources[res] = c.TokenSource(scopes, nil, []string{res})
+	}
+	return sources
+}
+
+// TokenSource is an oauth2.TokenSource of a [Cache] for a single Key.
+type TokenSource struct {
+	cache *Cache
+	key   Key
+}
+
+// Token implements [oauth2.TokenSource], without a context of the caller.
+// TokenCtx is used by [client.TokenTransport] with the context of the request.
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	return s.TokenCtx(context.Background())
+}
+
+func (s *TokenSource) TokenCtx(ctx context.Context) (*oauth2.Token, error) {
+	return s.cache.Token(ctx, s.key)
+}
+
+// Invalidate removes the cached token, see [Cache.Invalidate].
+// It is called by [client.TokenTransport] when the token is rejected.
+func (s *TokenSource) Invalidate() {
+	s.cache.Invalidate(s.key)
+}
+
+// ClientCredentials returns a FetchFunc using the client_credentials grant
+// of the RelyingParty. The audience and resource of the Key are sent
+// as `audience` and `resource` (RFC 8707) parameters.
+func ClientCredentials(relyingParty rp.RelyingParty) FetchFunc {
+	return func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		endpointParams := make(url.Values)
+		if key.Audience != "" {
+			endpointParams["audience"] = split(key.Audience)
+		}
+		if key.Resource != "" {
+			endpointParams["resource"] = split(key.Resource)
+		}
+		oauthConfig := relyingParty.OAuthConfig()
+		config := clientcredentials.Config{
+			ClientID:       oauthConfig.ClientID,
+			ClientSecret:   oauthConfig.ClientSecret,
+			TokenURL:       oauthConfig.Endpoint.TokenURL,
+			Scopes:         split(key.Scopes),
+			EndpointParams: endpointParams,
+			AuthStyle:      oauthConfig.Endpoint.AuthStyle,
+		}
+		return config.Token(context.WithValue(ctx, oauth2.HTTPClient, relyingParty.HttpClient()))
+	}
+}
+
+// TokenExchange returns a FetchFunc exchanging the subject token
+// for an access token of the Key, as defined in RFC 8693.
+// subjectToken is called on every request, e.g. to provide a fresh
+// token of the service itself.
+func TokenExchange(te tokenexchange.TokenExchanger, subjectToken func(ctx context.Context) (string, oidc.TokenType, error)) FetchFunc {
+	return func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		token, tokenType, err := subjectToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := tokenexchange.ExchangeToken(ctx, te, token, tokenType, "", "",
+			split(key.Resource), split(key.Audience), split(key.Scopes), oidc.AccessTokenType)
+		if err != nil {
+			return nil, err
+		}
+		t := &oauth2.Token{
+			AccessToken:  resp.AccessToken,
+			TokenType:    resp.TokenType,
+			RefreshToken: resp.RefreshToken,
+		}
+		if resp.ExpiresIn > 0 {
+			t.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+		}
+		return t, nil
+	}
+}
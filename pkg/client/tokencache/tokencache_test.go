@@ -0,0 +1,210 @@
+package tokencache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/background"
+	"github.com/lmindwarel/oidc/v3/pkg/client/rp"
+)
+
+func TestNewKey(t *testing.T) {
+	assert.Equal(t,
+		NewKey([]string{"b", "a", "a"}, []string{"api"}, nil),
+		NewKey([]string{"a", "b"}, []string{"api"}, []string{}),
+	)
+	assert.NotEqual(t,
+		NewKey([]string{"a"}, []string{"api1"}, nil),
+		NewKey([]string{"a"}, []string{"api2"}, nil),
+	)
+}
+
+func TestCache(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	cache := New(func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		calls.Add(1)
+		<-release
+		return &oauth2.Token{AccessToken: key.Audience, Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	api1 := cache.TokenSource([]string{"read"}, []string{"api1"}, nil)
+	api2 := cache.TokenSource([]string{"read"}, []string{"api2"}, nil)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := api1.Token()
+			assert.NoError(t, err)
+			assert.Equal(t, "api1", token.AccessToken)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, calls.Load(), "single request for concurrent callers")
+
+	_, err := api1.Token()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, calls.Load(), "cached")
+
+	token, err := api2.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "api2", token.AccessToken)
+	assert.EqualValues(t, 2, calls.Load(), "separate token per key")
+
+	cache.Invalidate(NewKey([]string{"read"}, []string{"api1"}, nil))
+	_, err = api1.Token()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, calls.Load(), "refreshed after invalidation")
+}
+
+func TestCache_TokenSources(t *testing.T) {
+	var keys []Key
+	cache := New(func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		keys = append(keys, key)
+		return &oauth2.Token{AccessToken: key.Audience + key.Resource, Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	sources := cache.TokenSources([]string{"read"}, []string{"orders", "billing"}, []string{"https://inventory.example.com"})
+	require.Len(t, sources, 3)
+	for target, source := range sources {
+		token, err := source.Token()
+		require.NoError(t, err)
+		assert.Equal(t, target, token.AccessToken)
+	}
+	assert.ElementsMatch(t, []Key{
+		NewKey([]string{"read"}, []string{"orders"}, nil),
+		NewKey([]string{"read"}, []string{"billing"}, nil),
+		NewKey([]string{"read"}, nil, []string{"https://inventory.example.com"}),
+	}, keys)
+
+	_, err := sources["orders"].Token()
+	require.NoError(t, err)
+	assert.Len(t, keys, 3, "cached per target")
+}
+
+func TestCache_expiry(t *testing.T) {
+	var calls atomic.Int32
+	cache := New(func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		calls.Add(1)
+		return &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(5 * time.Second)}, nil
+	}, WithExpiryDelta(10*time.Second))
+	source := cache.TokenSource(nil, nil, nil)
+	for range 2 {
+		_, err := source.Token()
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 2, calls.Load(), "token within expiry delta is refreshed")
+}
+
+func TestNewContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	cache := NewContext(ctx, func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-release:
+		}
+		return &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+
+	// the cancelled caller stops waiting, but the shared request completes
+	callerCtx, cancelCaller := context.WithCancel(context.Background())
+	cancelCaller()
+	_, err := cache.Token(callerCtx, Key{Audience: "api1"})
+	assert.ErrorIs(t, err, context.Canceled)
+	close(release)
+	require.Eventually(t, func() bool {
+		token, err := cache.Token(context.Background(), Key{Audience: "api1"})
+		return err == nil && token.AccessToken == "token"
+	}, time.Second, 10*time.Millisecond)
+
+	// cancelling the context of the cache aborts the requests
+	cache = NewContext(ctx, func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	_, err = cache.Token(context.Background(), Key{Audience: "api2"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+type countingGroup struct {
+	sync.WaitGroup
+	started atomic.Int32
+	mu      sync.Mutex
+	errs    []error
+}
+
+func (g *countingGroup) Go(f func() error) {
+	g.started.Add(1)
+	g.Add(1)
+	go func() {
+		defer g.Done()
+		if err := f(); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+		}
+	}()
+}
+
+func TestNewContext_group(t *testing.T) {
+	group := new(countingGroup)
+	ctx, cancel := context.WithCancel(background.WithGroup(context.Background(), group))
+	cache := NewContext(ctx, func(ctx context.Context, key Key) (*oauth2.Token, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	callerCtx, cancelCaller := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelCaller()
+	_, err := cache.Token(callerCtx, Key{Audience: "api"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the refresh runs in the group and stops on shutdown, passing its error
+	cancel()
+	group.Wait()
+	assert.EqualValues(t, 1, group.started.Load())
+	require.Len(t, group.errs, 1)
+	assert.ErrorIs(t, group.errs[0], context.Canceled)
+}
+
+func TestClientCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "a b", r.PostForm.Get("scope"))
+		assert.Equal(t, []string{"api1", "api2"}, r.PostForm["audience"])
+		assert.Equal(t, "https://api.example.com", r.PostForm.Get("resource"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "token", "token_type": "Bearer", "expires_in": 3600,
+		})
+	}))
+	defer server.Close()
+
+	relyingParty, err := rp.NewRelyingPartyOAuth(&oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: server.URL},
+	})
+	require.NoError(t, err)
+	cache := New(ClientCredentials(relyingParty))
+	token, err := cache.TokenSource([]string{"b", "a"}, []string{"api2", "api1"}, []string{"https://api.example.com"}).
+		TokenCtx(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token", token.AccessToken)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expiry, time.Minute)
+}
@@ -46,6 +46,20 @@ func NewTokenExchangerJWTProfile(ctx context.Context, issuer, clientID string, s
 	return newOAuthTokenExchange(ctx, issuer, authorizer, options...)
 }
 
+// NewTokenExchangerSPIFFE authenticates the client with the JWT-SVID of the
+// workload, such as [FileSubjectToken] of the file written by the spiffe-helper,
+// which is read for every exchange.
+func NewTokenExchangerSPIFFE(ctx context.Context, issuer string, svid SubjectTokenSource, options ...func(source *OAuthTokenExchange)) (TokenExchanger, error) {
+	authorizer := func() (any, error) {
+		token, _, err := svid(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return client.SPIFFEAssertionFormAuthorization(token), nil
+	}
+	return newOAuthTokenExchange(ctx, issuer, authorizer, options...)
+}
+
 func newOAuthTokenExchange(ctx context.Context, issuer string, authorizer func() (any, error), options ...func(source *OAuthTokenExchange)) (*OAuthTokenExchange, error) {
 	te := &OAuthTokenExchange{
 		httpClient: httphelper.DefaultHTTPClient,
@@ -60,7 +74,7 @@ func newOAuthTokenExchange(ctx context.Context, issuer string, authorizer func()
 			return nil, err
 		}
 
-		te.tokenEndpoint = config.TokenEndpoint
+		te.tokenEndpoint = client.MTLSEndpoints(te.httpClient, config).TokenEndpoint
 	}
 
 	if te.tokenEndpoint == "" {
@@ -0,0 +1,201 @@
+package tokenexchange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// SubjectTokenSource returns the ambient token of a workload, issued by
+// the platform it runs on, to be exchanged as subject_token.
+// It is called on every exchange, so rotated tokens are picked up.
+type SubjectTokenSource func(ctx context.Context) (token string, tokenType oidc.TokenType, err error)
+
+// KubernetesServiceAccountTokenPath is the location of the service account token
+// mounted by Kubernetes. Tokens with a custom audience are mounted with a
+// projected volume at a path of choice.
+const KubernetesServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// FileSubjectToken reads the token from the file on every call, such as a
+// Kubernetes projected service account token, which is rotated by the kubelet,
+// or a SPIFFE JWT-SVID written by the spiffe-helper.
+func FileSubjectToken(path string, tokenType oidc.TokenType) SubjectTokenSource {
+	return func(context.Context) (string, oidc.TokenType, error) {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", err
+		}
+		token = bytes.TrimSpace(token)
+		if len(token) == 0 {
+			return "", "", fmt.Errorf("empty subject token in %s", path)
+		}
+		return string(token), tokenType, nil
+	}
+}
+
+// StaticSubjectToken returns the token of any type, such as an access token,
+// refresh token or ID token obtained by the client.
+func StaticSubjectToken(token string, tokenType oidc.TokenType) SubjectTokenSource {
+	return func(context.Context) (string, oidc.TokenType, error) {
+		return token, tokenType, nil
+	}
+}
+
+// SAMLSubjectToken returns the base64url-encoded XML of the SAML assertion,
+// with the tokenType [oidc.SAML1TokenType] or [oidc.SAML2TokenType].
+func SAMLSubjectToken(assertion []byte, tokenType oidc.TokenType) SubjectTokenSource {
+	return StaticSubjectToken(oidc.EncodeSAMLAssertion(assertion), tokenType)
+}
+
+// Environment variables of GitHub Actions jobs with the id-token: write permission.
+const (
+	GitHubActionsTokenRequestURLEnv   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	GitHubActionsTokenRequestTokenEnv = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+)
+
+var ErrGitHubActionsEnv = errors.New("GitHub Actions OIDC token request environment is not set, the job requires the id-token: write permission")
+
+// GitHubActionsSubjectToken requests the OIDC token of the GitHub Actions job
+// for the audience. A nil httpClient uses the [httphelper.DefaultHTTPClient].
+func GitHubActionsSubjectToken(audience string, httpClient *http.Client) SubjectTokenSource {
+	if httpClient == nil {
+		httpClient = httphelper.DefaultHTTPClient
+	}
+	return func(ctx context.Context) (string, oidc.TokenType, error) {
+		requestURL, bearer := os.Getenv(GitHubActionsTokenRequestURLEnv), os.Getenv(GitHubActionsTokenRequestTokenEnv)
+		if requestURL == "" || bearer == "" {
+			return "", "", ErrGitHubActionsEnv
+		}
+		if audience != "" {
+			u, err := url.Parse(requestURL)
+			if err != nil {
+				return "", "", err
+			}
+			query := u.Query()
+			query.Set("audience", audience)
+			u.RawQuery = query.Encode()
+			requestURL = u.String()
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return "", "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return "", "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", "", fmt.Errorf("GitHub Actions OIDC token request: %s", resp.Status)
+		}
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", "", err
+		}
+		return body.Value, oidc.JWTTokenType, nil
+	}
+}
+
+// DefaultExpiryDelta is the time before the expiry of an exchanged token,
+// when the [WorkloadTokenSource] exchanges the subject token again.
+const DefaultExpiryDelta = time.Minute
+
+// WorkloadTokenSource exchanges the ambient token of a workload for access tokens
+// at the token endpoint of the TokenExchanger (RFC 8693), such as a security token
+// service accepting the tokens of the platform (workload identity federation).
+// The access token is reused until ExpiryDelta before its expiry.
+//
+// It implements [oauth2.TokenSource] and is usable with [client.TokenTransport].
+// It must be created by [NewWorkloadTokenSource].
+type WorkloadTokenSource struct {
+	Scopes   []string
+	Audience []string
+	Resource []string
+	// ExpiryDelta defaults to [DefaultExpiryDelta].
+	ExpiryDelta time.Duration
+
+	te      TokenExchanger
+	subject SubjectTokenSource
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewWorkloadTokenSource returns a WorkloadTokenSource exchanging
+// the token of subject at the token endpoint of te.
+func NewWorkloadTokenSource(te TokenExchanger, subject SubjectTokenSource, scopes, audience, resource []string) *WorkloadTokenSource {
+	return &WorkloadTokenSource{
+		Scopes:      scopes,
+		Audience:    audience,
+		Resource:    resource,
+		ExpiryDelta: DefaultExpiryDelta,
+		te:          te,
+		subject:     subject,
+	}
+}
+
+// Token implements [oauth2.TokenSource], without a context of the caller.
+// TokenCtx is used by [client.TokenTransport] with the context of the request.
+func (s *WorkloadTokenSource) Token() (*oauth2.Token, error) {
+	return s.TokenCtx(context.Background())
+}
+
+func (s *WorkloadTokenSource) TokenCtx(ctx context.Context) (*oauth2.Token, error) {
+	ctx, span := client.Tracer.Start(ctx, "WorkloadTokenSource.TokenCtx")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.valid() {
+		return s.token, nil
+	}
+	subjectToken, tokenType, err := s.subject(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("workload subject token: %w", err)
+	}
+	resp, err := ExchangeToken(ctx, s.te, subjectToken, tokenType, "", "",
+		s.Resource, s.Audience, s.Scopes, oidc.AccessTokenType)
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{
+		AccessToken: resp.AccessToken,
+		TokenType:   resp.TokenType,
+	}
+	if resp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	s.token = token
+	return token, nil
+}
+
+func (s *WorkloadTokenSource) valid() bool {
+	if s.token == nil || s.token.AccessToken == "" {
+		return false
+	}
+	return s.token.Expiry.IsZero() || time.Now().Add(s.ExpiryDelta).Before(s.token.Expiry)
+}
+
+// Invalidate forces a new exchange on the next call.
+// It is called by [client.TokenTransport] when the token is rejected.
+func (s *WorkloadTokenSource) Invalidate() {
+	s.mu.Lock()
+	s.token = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,77 @@
+package tokenexchange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestWorkloadTokenSource(t *testing.T) {
+	var exchanges atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "sa-token", r.PostForm.Get("subject_token"))
+		assert.Equal(t, string(oidc.JWTTokenType), r.PostForm.Get("subject_token_type"))
+		assert.Equal(t, "api", r.PostForm.Get("audience"))
+		exchanges.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(oidc.TokenExchangeResponse{
+			AccessToken:     "access",
+			IssuedTokenType: oidc.AccessTokenType,
+			TokenType:       oidc.BearerToken,
+			ExpiresIn:       3600,
+		})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("sa-token\n"), 0o600))
+
+	te, err := NewTokenExchanger(context.Background(), "https://sts.example.com",
+		WithHTTPClient(server.Client()), WithStaticTokenEndpoint("https://sts.example.com", server.URL))
+	require.NoError(t, err)
+	source := NewWorkloadTokenSource(te, FileSubjectToken(path, oidc.JWTTokenType), nil, []string{"api"}, nil)
+
+	token, err := source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "access", token.AccessToken)
+	_, err = source.Token()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, exchanges.Load(), "reused until expiry")
+
+	source.Invalidate()
+	_, err = source.Token()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, exchanges.Load())
+}
+
+func TestGitHubActionsSubjectToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer request-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "sts.example.com", r.URL.Query().Get("audience"))
+		json.NewEncoder(w).Encode(map[string]string{"value": "gh-token"})
+	}))
+	defer server.Close()
+
+	source := GitHubActionsSubjectToken("sts.example.com", server.Client())
+	t.Setenv(GitHubActionsTokenRequestURLEnv, "")
+	_, _, err := source(context.Background())
+	assert.ErrorIs(t, err, ErrGitHubActionsEnv)
+
+	t.Setenv(GitHubActionsTokenRequestURLEnv, server.URL+"?api-version=2.0")
+	t.Setenv(GitHubActionsTokenRequestTokenEnv, "request-token")
+	token, tokenType, err := source(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "gh-token", token)
+	assert.Equal(t, oidc.JWTTokenType, tokenType)
+}
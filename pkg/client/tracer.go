@@ -0,0 +1,9 @@
+//go:build !oidc_notrace
+
+package client
+
+import "go.opentelemetry.io/otel"
+
+// Tracer of the client packages, using the global TracerProvider of OpenTelemetry.
+// The oidc_notrace build tag replaces it with a no-op Tracer.
+var Tracer = otel.Tracer("github.com/lmindwarel/oidc/pkg/client")
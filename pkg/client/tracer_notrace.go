@@ -0,0 +1,8 @@
+//go:build oidc_notrace
+
+package client
+
+import "go.opentelemetry.io/otel/trace/noop"
+
+// Tracer of the client packages, which does not record spans with the oidc_notrace build tag.
+var Tracer = noop.NewTracerProvider().Tracer("github.com/lmindwarel/oidc/pkg/client")
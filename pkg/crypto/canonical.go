@@ -0,0 +1,48 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// MarshalCanonical returns the canonical JSON encoding of v, a form specific
+// to this module: the encoding of [json.Marshal] with the members of all objects
+// sorted by the bytes of their names, without insignificant whitespace and without
+// escaping of HTML characters. Numbers and strings keep their encoding by [json.Marshal].
+// It is not the JSON Canonicalization Scheme (RFC 8785), which differs in the
+// order of names, numbers and escapes, and must not be used to interoperate with it.
+// Equal values have the same encoding, independent of the field order of structs
+// or custom marshalers, so the payloads of signed artifacts are reproducible
+// across instances of this module.
+func MarshalCanonical(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err = decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// SignCanonical signs the canonical JSON encoding of the object, see [MarshalCanonical].
+// With a deterministic signature algorithm, such as RS256, equal objects
+// result in equal signatures.
+func SignCanonical(object any, signer jose.Signer) (string, error) {
+	payload, err := MarshalCanonical(object)
+	if err != nil {
+		return "", err
+	}
+	return SignPayload(payload, signer)
+}
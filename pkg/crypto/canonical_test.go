@@ -0,0 +1,77 @@
+package crypto_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	zcrypto "github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+type canonicalClaims struct {
+	Subject  string         `json:"sub"`
+	Issuer   string         `json:"iss"`
+	Expiry   int64          `json:"exp"`
+	Scope    float64        `json:"scope_weight"`
+	Nested   map[string]any `json:"nested"`
+	Optional string         `json:"optional,omitempty"`
+}
+
+// reversedClaims marshals its members in reverse order.
+type reversedClaims struct {
+	claims canonicalClaims
+}
+
+func (c reversedClaims) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Nested map[string]any `json:"nested"`
+		Scope  float64        `json:"scope_weight"`
+		Expiry int64          `json:"exp"`
+		Issuer string         `json:"iss"`
+		Sub    string         `json:"sub"`
+	}{c.claims.Nested, c.claims.Scope, c.claims.Expiry, c.claims.Issuer, c.claims.Subject})
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	claims := canonicalClaims{
+		Subject: "<user>&co",
+		Issuer:  "https://issuer.example",
+		Expiry:  1700000000,
+		Scope:   0.5,
+		Nested: map[string]any{
+			"z": []any{2, "b", map[string]any{"y": true, "x": nil}},
+			"a": 1e21,
+		},
+	}
+	want := `{"exp":1700000000,"iss":"https://issuer.example","nested":{"a":1e+21,"z":[2,"b",{"x":null,"y":true}]},"scope_weight":0.5,"sub":"<user>&co"}`
+
+	got, err := zcrypto.MarshalCanonical(claims)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+
+	got, err = zcrypto.MarshalCanonical(reversedClaims{claims})
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+
+	_, err = zcrypto.MarshalCanonical(func() {})
+	assert.Error(t, err)
+}
+
+func TestSignCanonical(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	require.NoError(t, err)
+
+	claims := canonicalClaims{Subject: "sub", Issuer: "iss", Nested: map[string]any{"b": 1, "a": 2}}
+	first, err := zcrypto.SignCanonical(claims, signer)
+	require.NoError(t, err)
+	second, err := zcrypto.SignCanonical(reversedClaims{claims}, signer)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+}
@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"slices"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+var ErrNotFIPSApproved = errors.New("not FIPS 140-3 approved")
+
+// FIPSMinRSABits is the minimum size of RSA keys approved by FIPS 186-5.
+const FIPSMinRSABits = 2048
+
+// fipsMinHMACBytes gives 112 bit of security strength (NIST SP 800-131A).
+const fipsMinHMACBytes = 14
+
+// FIPS restricts algorithms and keys to the ones approved by FIPS 140-3:
+// RSA PKCS #1 v1.5 and PSS, ECDSA on the P-256, P-384 and P-521 curves
+// and HMAC, all with SHA-2, and ML-DSA (FIPS 204).
+type FIPS struct {
+	// AllowEdDSA approves Ed25519 (FIPS 186-5), which is not approved
+	// by all validated modules and deployments.
+	AllowEdDSA bool
+}
+
+// CheckAlgorithm returns an [ErrNotFIPSApproved] error for algorithms which are not approved.
+func (f FIPS) CheckAlgorithm(alg jose.SignatureAlgorithm) error {
+	switch alg {
+	case jose.RS256, jose.RS384, jose.RS512,
+		jose.PS256, jose.PS384, jose.PS512,
+		jose.ES256, jose.ES384, jose.ES512,
+		jose.HS256, jose.HS384, jose.HS512,
+		MLDSA44, MLDSA65, MLDSA87:
+		return nil
+	case jose.EdDSA:
+		if f.AllowEdDSA {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: algorithm %q", ErrNotFIPSApproved, alg)
+}
+
+// CheckAlgorithms checks all algorithms, see [FIPS.CheckAlgorithm].
+func (f FIPS) CheckAlgorithms(algs ...jose.SignatureAlgorithm) error {
+	for _, alg := range algs {
+		if err := f.CheckAlgorithm(alg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultAlgorithms are the algorithms verifiers accept by default.
+var defaultAlgorithms = []string{"RS256", "ES256", "PS256"}
+
+// ApprovedAlgorithms returns the algorithms, without the ones which are not approved.
+// A nil FIPS approves all algorithms. Empty algorithms are the defaults of the
+// verifiers, RS256, ES256 and PS256. It returns an [ErrNotFIPSApproved] error,
+// if none of the algorithms is approved.
+func (f *FIPS) ApprovedAlgorithms(algs []string) ([]string, error) {
+	if f == nil {
+		return algs, nil
+	}
+	if len(algs) == 0 {
+		algs = defaultAlgorithms
+	}
+	approved := slices.DeleteFunc(slices.Clone(algs), func(alg string) bool {
+		return f.CheckAlgorithm(jose.SignatureAlgorithm(alg)) != nil
+	})
+	if len(approved) == 0 {
+		return nil, fmt.Errorf("%w: algorithms %v", ErrNotFIPSApproved, algs)
+	}
+	return approved, nil
+}
+
+// CheckKey returns an [ErrNotFIPSApproved] error for keys, which are not approved
+// or too small: RSA keys below [FIPSMinRSABits], curves other than P-256, P-384
+// and P-521, HMAC keys below 112 bit and Ed25519 keys, unless AllowEdDSA is set.
+// Keys may be private or public and wrapped in a [jose.JSONWebKey].
+func (f FIPS) CheckKey(key any) error {
+	switch k := key.(type) {
+	case jose.JSONWebKey:
+		return f.CheckKey(k.Key)
+	case *jose.JSONWebKey:
+		return f.CheckKey(k.Key)
+	case *rsa.PrivateKey:
+		return f.CheckKey(&k.PublicKey)
+	case *rsa.PublicKey:
+		if k.N.BitLen() < FIPSMinRSABits {
+			return fmt.Errorf("%w: RSA key of %d bits", ErrNotFIPSApproved, k.N.BitLen())
+		}
+		return nil
+	case *ecdsa.PrivateKey:
+		return f.CheckKey(&k.PublicKey)
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return nil
+		}
+		return fmt.Errorf("%w: curve %s", ErrNotFIPSApproved, k.Curve.Params().Name)
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		if f.AllowEdDSA {
+			return nil
+		}
+		return fmt.Errorf("%w: Ed25519 key", ErrNotFIPSApproved)
+	case []byte:
+		if len(k) < fipsMinHMACBytes {
+			return fmt.Errorf("%w: HMAC key of %d bits", ErrNotFIPSApproved, len(k)*8)
+		}
+		return nil
+	case MLDSAKey:
+		return f.CheckAlgorithm(k.Algorithm())
+	}
+	return fmt.Errorf("%w: key type %T", ErrNotFIPSApproved, key)
+}
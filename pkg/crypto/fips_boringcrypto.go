@@ -0,0 +1,8 @@
+//go:build boringcrypto
+
+package crypto
+
+// DefaultFIPS enables the FIPS mode for BoringCrypto builds.
+func DefaultFIPS() *FIPS {
+	return &FIPS{}
+}
@@ -0,0 +1,8 @@
+//go:build !go1.24 && !boringcrypto
+
+package crypto
+
+// DefaultFIPS is nil, as the FIPS mode of the toolchain cannot be detected before Go 1.24.
+func DefaultFIPS() *FIPS {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build go1.24 && !boringcrypto
+
+package crypto
+
+import "crypto/fips140"
+
+// DefaultFIPS enables the FIPS mode, if the Go Cryptographic Module
+// runs in FIPS 140-3 mode, e.g. with GODEBUG=fips140=on.
+func DefaultFIPS() *FIPS {
+	if fips140.Enabled() {
+		return &FIPS{}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	zcrypto "github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+func TestFIPS_CheckAlgorithm(t *testing.T) {
+	fips := zcrypto.FIPS{}
+	for _, alg := range []jose.SignatureAlgorithm{jose.RS256, jose.PS384, jose.ES512, jose.HS256} {
+		assert.NoError(t, fips.CheckAlgorithm(alg), alg)
+	}
+	for _, alg := range []jose.SignatureAlgorithm{jose.EdDSA, "none", "ES256K"} {
+		assert.ErrorIs(t, fips.CheckAlgorithm(alg), zcrypto.ErrNotFIPSApproved, alg)
+	}
+	assert.NoError(t, zcrypto.FIPS{AllowEdDSA: true}.CheckAlgorithm(jose.EdDSA))
+	assert.ErrorIs(t, fips.CheckAlgorithms(jose.RS256, jose.EdDSA), zcrypto.ErrNotFIPSApproved)
+}
+
+func TestFIPS_CheckKey(t *testing.T) {
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		fips    zcrypto.FIPS
+		key     any
+		wantErr bool
+	}{
+		{name: "RSA 2048", key: rsa2048},
+		{name: "RSA public key", key: &rsa2048.PublicKey},
+		{name: "RSA 1024", key: rsa1024, wantErr: true},
+		{name: "P-256", key: p256},
+		{name: "JSON Web Key", key: jose.JSONWebKey{Key: &p256.PublicKey}},
+		{name: "P-224", key: p224, wantErr: true},
+		{name: "Ed25519", key: edPrivate, wantErr: true},
+		{name: "Ed25519 allowed", fips: zcrypto.FIPS{AllowEdDSA: true}, key: edPublic},
+		{name: "HMAC", key: make([]byte, 32)},
+		{name: "short HMAC", key: make([]byte, 8), wantErr: true},
+		{name: "unknown", key: "key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fips.CheckKey(tt.key)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, zcrypto.ErrNotFIPSApproved)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFIPS_ApprovedAlgorithms(t *testing.T) {
+	var disabled *zcrypto.FIPS
+	algs, err := disabled.ApprovedAlgorithms([]string{"EdDSA"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EdDSA"}, algs)
+
+	fips := &zcrypto.FIPS{}
+	algs, err = fips.ApprovedAlgorithms([]string{"EdDSA", "ES256"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ES256"}, algs)
+
+	algs, err = fips.ApprovedAlgorithms(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RS256", "ES256", "PS256"}, algs)
+
+	_, err = fips.ApprovedAlgorithms([]string{"EdDSA"})
+	assert.ErrorIs(t, err, zcrypto.ErrNotFIPSApproved)
+}
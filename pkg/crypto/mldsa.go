@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// ML-DSA (FIPS 204) signature algorithms of draft-ietf-cose-dilithium.
+//
+// EXPERIMENTAL: the draft and therefore the identifiers and the key
+// representation may still change.
+const (
+	MLDSA44 jose.SignatureAlgorithm = "ML-DSA-44"
+	MLDSA65 jose.SignatureAlgorithm = "ML-DSA-65"
+	MLDSA87 jose.SignatureAlgorithm = "ML-DSA-87"
+)
+
+// MLDSAAlgorithms are the ML-DSA algorithms, strongest last.
+var MLDSAAlgorithms = []jose.SignatureAlgorithm{MLDSA44, MLDSA65, MLDSA87}
+
+// KeyTypeAKP is the key type of the Algorithm Key Pair JSON Web Keys of ML-DSA.
+const KeyTypeAKP = "AKP"
+
+var (
+	ErrMLDSAUnsupported = errors.New("ML-DSA is not supported by this Go version, it requires Go 1.27")
+	ErrMLDSAKeyInvalid  = errors.New("invalid ML-DSA key")
+)
+
+// IsMLDSA reports whether alg is one of the [MLDSAAlgorithms].
+func IsMLDSA(alg jose.SignatureAlgorithm) bool {
+	return slices.Contains(MLDSAAlgorithms, alg)
+}
+
+// MLDSAKey is implemented by the ML-DSA signers and public keys of this package.
+// go-jose does not know ML-DSA, they sign and verify as [jose.OpaqueSigner]
+// and [jose.OpaqueVerifier].
+type MLDSAKey interface {
+	Algorithm() jose.SignatureAlgorithm
+	// PublicKeyBytes returns the encoded public key.
+	PublicKeyBytes() []byte
+}
+
+// AKPJSONWebKey is the public JSON Web Key of an ML-DSA key, which can't be
+// represented by a [jose.JSONWebKey].
+type AKPJSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg"`
+	Public    string `json:"pub"`
+}
+
+// NewAKPJSONWebKey returns the public JSON Web Key of the ML-DSA key.
+func NewAKPJSONWebKey(key MLDSAKey, keyID, use string) AKPJSONWebKey {
+	return AKPJSONWebKey{
+		KeyType:   KeyTypeAKP,
+		KeyID:     keyID,
+		Use:       use,
+		Algorithm: string(key.Algorithm()),
+		Public:    base64.RawURLEncoding.EncodeToString(key.PublicKeyBytes()),
+	}
+}
@@ -0,0 +1,121 @@
+//go:build go1.27
+
+package crypto
+
+import (
+	"crypto/mldsa"
+	"encoding/base64"
+	"fmt"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// MLDSASigner signs JWS with an ML-DSA private key.
+// It is used as the Key of an op.SigningKey.
+//
+// EXPERIMENTAL, see [MLDSA44].
+type MLDSASigner struct {
+	key *mldsa.PrivateKey
+	alg jose.SignatureAlgorithm
+}
+
+// NewMLDSASigner returns the signer of the key.
+func NewMLDSASigner(key *mldsa.PrivateKey) *MLDSASigner {
+	return &MLDSASigner{
+		key: key,
+		alg: mldsaAlgorithm(key.PublicKey()),
+	}
+}
+
+// Public returns nil, as a [jose.JSONWebKey] can't hold ML-DSA keys.
+// The kid header must therefore be set by the caller.
+func (s *MLDSASigner) Public() *jose.JSONWebKey {
+	return nil
+}
+
+func (s *MLDSASigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{s.alg}
+}
+
+func (s *MLDSASigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if alg != s.alg {
+		return nil, jose.ErrUnsupportedAlgorithm
+	}
+	return s.key.Sign(nil, payload, nil)
+}
+
+func (s *MLDSASigner) Algorithm() jose.SignatureAlgorithm {
+	return s.alg
+}
+
+func (s *MLDSASigner) PublicKeyBytes() []byte {
+	return s.key.PublicKey().Bytes()
+}
+
+// PublicKey returns the public key to verify the signatures of s.
+func (s *MLDSASigner) PublicKey() *MLDSAPublicKey {
+	return NewMLDSAPublicKey(s.key.PublicKey())
+}
+
+// MLDSAPublicKey verifies JWS signed with ML-DSA.
+// It is used as the Key of an op.Key.
+//
+// EXPERIMENTAL, see [MLDSA44].
+type MLDSAPublicKey struct {
+	key *mldsa.PublicKey
+	alg jose.SignatureAlgorithm
+}
+
+// NewMLDSAPublicKey returns the verifier of the key.
+func NewMLDSAPublicKey(key *mldsa.PublicKey) *MLDSAPublicKey {
+	return &MLDSAPublicKey{
+		key: key,
+		alg: mldsaAlgorithm(key),
+	}
+}
+
+// ParseMLDSAPublicKey parses the public key of an AKP JSON Web Key.
+func ParseMLDSAPublicKey(jwk AKPJSONWebKey) (*MLDSAPublicKey, error) {
+	if jwk.KeyType != KeyTypeAKP {
+		return nil, fmt.Errorf("%w: key type %q", ErrMLDSAKeyInvalid, jwk.KeyType)
+	}
+	var params mldsa.Parameters
+	switch jose.SignatureAlgorithm(jwk.Algorithm) {
+	case MLDSA44:
+		params = mldsa.MLDSA44()
+	case MLDSA65:
+		params = mldsa.MLDSA65()
+	case MLDSA87:
+		params = mldsa.MLDSA87()
+	default:
+		return nil, fmt.Errorf("%w: algorithm %q", ErrMLDSAKeyInvalid, jwk.Algorithm)
+	}
+	encoded, err := base64.RawURLEncoding.DecodeString(jwk.Public)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMLDSAKeyInvalid, err)
+	}
+	key, err := mldsa.NewPublicKey(params, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMLDSAKeyInvalid, err)
+	}
+	return NewMLDSAPublicKey(key), nil
+}
+
+func (k *MLDSAPublicKey) VerifyPayload(payload []byte, signature []byte, alg jose.SignatureAlgorithm) error {
+	if alg != k.alg {
+		return jose.ErrUnsupportedAlgorithm
+	}
+	return mldsa.Verify(k.key, payload, signature, nil)
+}
+
+func (k *MLDSAPublicKey) Algorithm() jose.SignatureAlgorithm {
+	return k.alg
+}
+
+func (k *MLDSAPublicKey) PublicKeyBytes() []byte {
+	return k.key.Bytes()
+}
+
+func mldsaAlgorithm(key *mldsa.PublicKey) jose.SignatureAlgorithm {
+	return jose.SignatureAlgorithm(key.Parameters().String())
+}
@@ -0,0 +1,67 @@
+//go:build go1.27
+
+package crypto_test
+
+import (
+	"crypto/mldsa"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	zcrypto "github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+func TestMLDSASigner(t *testing.T) {
+	key, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	require.NoError(t, err)
+	signer := zcrypto.NewMLDSASigner(key)
+	assert.Equal(t, zcrypto.MLDSA65, signer.Algorithm())
+
+	joseSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: zcrypto.MLDSA65, Key: signer}, nil)
+	require.NoError(t, err)
+	token, err := zcrypto.SignPayload([]byte("payload"), joseSigner)
+	require.NoError(t, err)
+
+	jws, err := jose.ParseSigned(token, []jose.SignatureAlgorithm{zcrypto.MLDSA65})
+	require.NoError(t, err)
+	payload, err := jws.Verify(signer.PublicKey())
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(payload))
+
+	other, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	require.NoError(t, err)
+	_, err = jws.Verify(zcrypto.NewMLDSAPublicKey(other.PublicKey()))
+	assert.Error(t, err)
+
+	_, err = jose.NewSigner(jose.SigningKey{Algorithm: zcrypto.MLDSA44, Key: signer}, nil)
+	assert.ErrorIs(t, err, jose.ErrUnsupportedAlgorithm)
+}
+
+func TestParseMLDSAPublicKey(t *testing.T) {
+	key, err := mldsa.GenerateKey(mldsa.MLDSA44())
+	require.NoError(t, err)
+	jwk := zcrypto.NewAKPJSONWebKey(zcrypto.NewMLDSASigner(key), "key1", "sig")
+	assert.Equal(t, zcrypto.KeyTypeAKP, jwk.KeyType)
+	assert.Equal(t, "ML-DSA-44", jwk.Algorithm)
+
+	public, err := zcrypto.ParseMLDSAPublicKey(jwk)
+	require.NoError(t, err)
+	assert.Equal(t, key.PublicKey().Bytes(), public.PublicKeyBytes())
+	assert.Equal(t, zcrypto.MLDSA44, public.Algorithm())
+
+	jwk.Algorithm = "ML-DSA-65"
+	_, err = zcrypto.ParseMLDSAPublicKey(jwk)
+	assert.ErrorIs(t, err, zcrypto.ErrMLDSAKeyInvalid)
+	jwk.Algorithm = "RS256"
+	_, err = zcrypto.ParseMLDSAPublicKey(jwk)
+	assert.ErrorIs(t, err, zcrypto.ErrMLDSAKeyInvalid)
+}
+
+func TestFIPS_CheckKey_MLDSA(t *testing.T) {
+	key, err := mldsa.GenerateKey(mldsa.MLDSA87())
+	require.NoError(t, err)
+	assert.NoError(t, zcrypto.FIPS{}.CheckKey(zcrypto.NewMLDSASigner(key)))
+	assert.NoError(t, zcrypto.FIPS{}.CheckAlgorithm(zcrypto.MLDSA87))
+}
@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	mrand "math/rand/v2"
+	"sync"
+)
+
+// RandomString reads nBytes of the random source and returns them base64url encoded,
+// without padding. The length of the string is nBytes * 4 / 3.
+func RandomString(random io.Reader, nBytes int) (string, error) {
+	bytes := make([]byte, nBytes)
+	if _, err := io.ReadFull(random, bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// DeterministicRandom returns a random source, which returns the same bytes
+// for the same seed, so tests using it are reproducible.
+// It is a ChaCha8 stream keyed by the SHA-256 of the seed and safe for concurrent use.
+//
+// Its output is predictable to anyone knowing the seed:
+// it must never be used outside of tests.
+func DeterministicRandom(seed string) io.Reader {
+	return &lockedRandom{chacha: mrand.NewChaCha8(sha256.Sum256([]byte(seed)))}
+}
+
+type lockedRandom struct {
+	mu     sync.Mutex
+	chacha *mrand.ChaCha8
+}
+
+func (r *lockedRandom) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.chacha.Read(p)
+}
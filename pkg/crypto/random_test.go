@@ -0,0 +1,40 @@
+package crypto_test
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	zcrypto "github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+func TestRandomString(t *testing.T) {
+	got, err := zcrypto.RandomString(rand.Reader, 32)
+	require.NoError(t, err)
+	assert.Len(t, got, 43)
+
+	_, err = zcrypto.RandomString(io.LimitReader(rand.Reader, 8), 16)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestDeterministicRandom(t *testing.T) {
+	first, err := zcrypto.RandomString(zcrypto.DeterministicRandom("seed"), 32)
+	require.NoError(t, err)
+	second, err := zcrypto.RandomString(zcrypto.DeterministicRandom("seed"), 32)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	other, err := zcrypto.RandomString(zcrypto.DeterministicRandom("other"), 32)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+
+	random := zcrypto.DeterministicRandom("seed")
+	a, _ := zcrypto.RandomString(random, 32)
+	b, _ := zcrypto.RandomString(random, 32)
+	assert.Equal(t, first, a)
+	assert.NotEqual(t, a, b)
+}
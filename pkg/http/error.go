@@ -0,0 +1,142 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// maxErrorBody limits the part of the response body kept in a [ResponseError].
+const maxErrorBody = 1 << 10
+
+// ResponseError is returned for responses with an unexpected status code.
+// It keeps the status, headers and the start of the body of the response,
+// for programmatic handling and logging.
+//
+// Err is the OAuth error of the response (RFC 6749, section 5.2).
+// For non-standard responses, like HTML error pages or proprietary JSON,
+// it is the one returned by the [ErrorTranslator] of the request context,
+// or a server_error. So [errors.As] for an [*oidc.Error] always succeeds.
+type ResponseError struct {
+	StatusCode int
+	Header     http.Header
+	// Body is the start of the response body, up to 1 KiB.
+	Body []byte
+	Err  *oidc.Error
+	// Standard is true if Err was part of the response body.
+	Standard bool
+	// RetryAfter is the delay requested by the Retry-After header
+	// of the response, or 0 if it has none.
+	RetryAfter time.Duration
+}
+
+func (e *ResponseError) Error() string {
+	if e.Standard {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("http status not ok: %d %s %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// Throttled returns true for responses with status 429 Too Many Requests,
+// or 503 Service Unavailable with a Retry-After header.
+func (e *ResponseError) Throttled() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusServiceUnavailable && e.Header.Get("Retry-After") != ""
+}
+
+// Is makes [errors.Is] match [ErrThrottled] for throttled responses.
+func (e *ResponseError) Is(target error) bool {
+	return target == ErrThrottled && e.Throttled()
+}
+
+// ErrThrottled is matched by the errors of requests throttled by the provider,
+// see [ResponseError.Throttled].
+var ErrThrottled = errors.New("throttled by the provider")
+
+// RetryAfter returns the delay requested by the throttled response of err,
+// see [ErrThrottled]. It returns false if err is not a throttled response.
+func RetryAfter(err error) (time.Duration, bool) {
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || !respErr.Throttled() {
+		return 0, false
+	}
+	return respErr.RetryAfter, true
+}
+
+// parseRetryAfter parses the Retry-After header of RFC 9110, section 10.2.3,
+// which is either a number of seconds or a date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// ErrorTranslator translates non-standard error responses of a provider.
+// It returns nil for responses it does not recognize.
+// The body is read completely, but must not be retained.
+type ErrorTranslator func(resp *http.Response, body []byte) *oidc.Error
+
+type errorTranslatorKey struct{}
+
+// ContextWithErrorTranslator returns a context, which makes requests done
+// with it translate non-standard error responses using translate.
+func ContextWithErrorTranslator(ctx context.Context, translate ErrorTranslator) context.Context {
+	return context.WithValue(ctx, errorTranslatorKey{}, translate)
+}
+
+func errorTranslator(ctx context.Context) ErrorTranslator {
+	translate, _ := ctx.Value(errorTranslatorKey{}).(ErrorTranslator)
+	return translate
+}
+
+// NewResponseError reads the body of the response with an unexpected status
+// code and returns the [ResponseError] for it.
+func NewResponseError(resp *http.Response) (*ResponseError, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body: %v", err)
+	}
+	return responseError(resp, body), nil
+}
+
+func responseError(resp *http.Response, body []byte) *ResponseError {
+	e := &ResponseError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       bytes.Clone(body[:min(len(body), maxErrorBody)]),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	var oidcErr oidc.Error
+	if err := json.Unmarshal(body, &oidcErr); err == nil && oidcErr.ErrorType != "" {
+		e.Err, e.Standard = &oidcErr, true
+		return e
+	}
+	if resp.Request != nil {
+		if translate := errorTranslator(resp.Request.Context()); translate != nil {
+			e.Err = translate(resp, body)
+		}
+	}
+	if e.Err == nil {
+		e.Err = oidc.ErrServerError().WithDescription("unexpected response with status %d", resp.StatusCode)
+	}
+	return e
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestHttpRequest_ResponseError(t *testing.T) {
+	translate := ErrorTranslator(func(resp *http.Response, body []byte) *oidc.Error {
+		if resp.Header.Get("Content-Type") != "application/vnd.error+json" {
+			return nil
+		}
+		return oidc.ErrInvalidGrant().WithDescription("%s", body)
+	})
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		wantErr      *oidc.Error
+		wantStandard bool
+		wantMessage  string
+	}{
+		{
+			name:         "standard",
+			contentType:  "application/json",
+			body:         `{"error":"invalid_client","error_description":"unknown client"}`,
+			wantErr:      oidc.ErrInvalidClient(),
+			wantStandard: true,
+			wantMessage:  "ErrorType=invalid_client Description=unknown client",
+		},
+		{
+			name:        "html",
+			contentType: "text/html",
+			body:        "<html>" + strings.Repeat("a", 2*maxErrorBody) + "</html>",
+			wantErr:     oidc.ErrServerError(),
+			wantMessage: "http status not ok: 502 Bad Gateway <html>aaa",
+		},
+		{
+			name:        "translated",
+			contentType: "application/vnd.error+json",
+			body:        `{"code":42}`,
+			wantErr:     oidc.ErrInvalidGrant(),
+			wantMessage: `http status not ok: 502 Bad Gateway {"code":42}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("X-Request-Id", "id")
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			ctx := ContextWithErrorTranslator(context.Background(), translate)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+
+			err = HttpRequest(server.Client(), req, new(map[string]any))
+			var respErr *ResponseError
+			require.ErrorAs(t, err, &respErr)
+			assert.Equal(t, http.StatusBadGateway, respErr.StatusCode)
+			assert.Equal(t, "id", respErr.Header.Get("X-Request-Id"))
+			assert.LessOrEqual(t, len(respErr.Body), maxErrorBody)
+			assert.Equal(t, tt.wantStandard, respErr.Standard)
+			assert.Contains(t, err.Error(), tt.wantMessage)
+
+			var oidcErr *oidc.Error
+			require.ErrorAs(t, err, &oidcErr)
+			assert.Equal(t, tt.wantErr.ErrorType, oidcErr.ErrorType)
+		})
+	}
+}
@@ -3,21 +3,61 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/background"
 )
 
 var DefaultHTTPClient = &http.Client{
 	Timeout: 30 * time.Second,
 }
 
+// ErrNonPublicAddress is returned by the [PublicHTTPClient] for connections
+// to addresses, which are not public.
+var ErrNonPublicAddress = errors.New("connection to non-public address")
+
+// PublicHTTPClient returns an http.Client only connecting to public IP addresses,
+// not to loopback, private, link-local or unspecified ones, checked after name
+// resolution, and without proxy. It fetches the URIs registered by clients, like their jwks_uri,
+// so they cannot make the server request internal services.
+func PublicHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrNonPublicAddress, address)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast())
+}
+
 type Decoder interface {
 	Decode(dst any, src map[string][]string) error
 }
@@ -26,6 +66,14 @@ type Encoder interface {
 	Encode(src any, dst map[string][]string) error
 }
 
+// FormMarshaler is implemented by requests encoding themselves as form,
+// which [FormRequest] prefers over the reflection based Encoder.
+// MarshalForm validates the request and returns an error for missing
+// or invalid parameters, before it is sent.
+type FormMarshaler interface {
+	MarshalForm(form url.Values) error
+}
+
 type FormAuthorization func(url.Values)
 type RequestAuthorization func(*http.Request)
 
@@ -37,7 +85,11 @@ func AuthorizeBasic(user, password string) RequestAuthorization {
 
 func FormRequest(ctx context.Context, endpoint string, request any, encoder Encoder, authFn any) (*http.Request, error) {
 	form := url.Values{}
-	if err := encoder.Encode(request, form); err != nil {
+	if marshaler, ok := request.(FormMarshaler); ok {
+		if err := marshaler.MarshalForm(form); err != nil {
+			return nil, err
+		}
+	} else if err := encoder.Encode(request, form); err != nil {
 		return nil, err
 	}
 	if fn, ok := authFn.(FormAuthorization); ok {
@@ -55,7 +107,22 @@ func FormRequest(ctx context.Context, endpoint string, request any, encoder Enco
 	return req, nil
 }
 
+// HttpRequest does the request and decodes the JSON response body into response.
+// Responses with a status other than 200 or 201 are returned as [*ResponseError].
+// Throttled requests are retried by the [RetryPolicy] of the request context, if any.
 func HttpRequest(client *http.Client, req *http.Request, response any) error {
+	policy := retryPolicy(req.Context())
+	for retry := 0; ; retry++ {
+		err := httpRequest(client, req, response)
+		next, ok := policy.retry(req, retry, err)
+		if !ok {
+			return err
+		}
+		req = next
+	}
+}
+
+func httpRequest(client *http.Client, req *http.Request, response any) error {
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -67,13 +134,8 @@ func HttpRequest(client *http.Client, req *http.Request, response any) error {
 		return fmt.Errorf("unable to read response body: %v", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var oidcErr oidc.Error
-		err = json.Unmarshal(body, &oidcErr)
-		if err != nil || oidcErr.ErrorType == "" {
-			return fmt.Errorf("http status not ok: %s %s", resp.Status, body)
-		}
-		return &oidcErr
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return responseError(resp, body)
 	}
 
 	err = json.Unmarshal(body, response)
@@ -92,21 +154,33 @@ func URLEncodeParams(resp any, encoder Encoder) (url.Values, error) {
 	return values, nil
 }
 
+// StartServer serves on the port until ctx is done. It runs in the
+// [background.Group] of ctx, which is passed the errors of the server,
+// or else terminates the process on them.
 func StartServer(ctx context.Context, port string) {
 	server := &http.Server{Addr: port}
-	go func() {
+	background.Go(ctx, func() error {
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("ListenAndServe(): %v", err)
+			return fatal(ctx, fmt.Errorf("ListenAndServe(): %w", err))
 		}
-	}()
+		return nil
+	})
 
-	go func() {
+	background.Go(ctx, func() error {
 		<-ctx.Done()
 		ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancelShutdown()
-		err := server.Shutdown(ctxShutdown)
-		if err != nil {
-			log.Fatalf("Shutdown(): %v", err)
+		if err := server.Shutdown(ctxShutdown); err != nil {
+			return fatal(ctx, fmt.Errorf("Shutdown(): %w", err))
 		}
-	}()
+		return nil
+	})
+}
+
+// fatal returns err to the [background.Group] of ctx, or terminates the process without one.
+func fatal(ctx context.Context, err error) error {
+	if !background.Supervised(ctx) {
+		log.Fatal(err)
+	}
+	return err
 }
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testFormRequest struct {
+	Token string `schema:"token"`
+}
+
+func (r testFormRequest) MarshalForm(form url.Values) error {
+	if r.Token == "" {
+		return errors.New("token is required")
+	}
+	form.Set("token", r.Token+"-marshaled")
+	return nil
+}
+
+type failingEncoder struct{}
+
+func (failingEncoder) Encode(any, map[string][]string) error {
+	return errors.New("encoder must not be used")
+}
+
+func TestFormRequest_FormMarshaler(t *testing.T) {
+	req, err := FormRequest(context.Background(), "https://op.example.com/token", testFormRequest{Token: "abc"}, failingEncoder{}, FormAuthorization(func(form url.Values) {
+		form.Set("client_id", "client")
+	}))
+	require.NoError(t, err)
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "client_id=client&token=abc-marshaled", string(body))
+
+	_, err = FormRequest(context.Background(), "https://op.example.com/token", testFormRequest{}, failingEncoder{}, nil)
+	assert.EqualError(t, err, "token is required")
+}
+
+func TestPublicHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := PublicHTTPClient().Get(server.URL)
+	assert.ErrorIs(t, err, ErrNonPublicAddress)
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy retries requests throttled by the provider, see [ErrThrottled].
+type RetryPolicy struct {
+	// MaxRetries is the number of retries of a throttled request.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled for each further one.
+	// A longer Retry-After of the response takes precedence.
+	Backoff time.Duration
+	// MaxDelay limits the delay before a retry, if set.
+	// Responses requesting a longer Retry-After are returned without retrying.
+	MaxDelay time.Duration
+}
+
+type retryPolicyKey struct{}
+
+// ContextWithRetryPolicy returns a context, which makes requests done
+// with it honor the policy, when throttled by the provider.
+func ContextWithRetryPolicy(ctx context.Context, policy *RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+func retryPolicy(ctx context.Context) *RetryPolicy {
+	policy, _ := ctx.Value(retryPolicyKey{}).(*RetryPolicy)
+	return policy
+}
+
+// delay returns the delay before the retry, counting from 0,
+// or false if the request must not be retried.
+func (p *RetryPolicy) delay(retry int, retryAfter time.Duration) (time.Duration, bool) {
+	if retry >= p.MaxRetries {
+		return 0, false
+	}
+	delay := max(p.Backoff<<retry, retryAfter)
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		if retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		delay = p.MaxDelay
+	}
+	return delay, true
+}
+
+// retry waits for the delay of the policy, if the request was throttled
+// and can be retried, and returns the request to send again.
+func (p *RetryPolicy) retry(req *http.Request, retry int, err error) (*http.Request, bool) {
+	retryAfter, throttled := RetryAfter(err)
+	if p == nil || !throttled {
+		return nil, false
+	}
+	delay, ok := p.delay(retry, retryAfter)
+	if !ok || req.Body != nil && req.GetBody == nil {
+		return nil, false
+	}
+	next := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		next.Body = body
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return nil, false
+	case <-timer.C:
+	}
+	return next, true
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, time.Minute, parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	policy := &RetryPolicy{MaxRetries: 3, Backoff: time.Second, MaxDelay: 3 * time.Second}
+	tests := []struct {
+		retry      int
+		retryAfter time.Duration
+		want       time.Duration
+		wantOK     bool
+	}{
+		{retry: 0, want: time.Second, wantOK: true},
+		{retry: 1, want: 2 * time.Second, wantOK: true},
+		{retry: 2, want: 3 * time.Second, wantOK: true},
+		{retry: 0, retryAfter: 2 * time.Second, want: 2 * time.Second, wantOK: true},
+		{retry: 0, retryAfter: time.Minute},
+		{retry: 3},
+	}
+	for _, tt := range tests {
+		got, ok := policy.delay(tt.retry, tt.retryAfter)
+		assert.Equal(t, tt.want, got)
+		assert.Equal(t, tt.wantOK, ok)
+	}
+}
+
+func TestHttpRequest_RetryPolicy(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "token=foo", string(body))
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"active":true}`))
+	}))
+	defer server.Close()
+	newRequest := func(ctx context.Context) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader("token=foo"))
+		require.NoError(t, err)
+		return req
+	}
+
+	err := HttpRequest(server.Client(), newRequest(context.Background()), new(map[string]any))
+	assert.ErrorIs(t, err, ErrThrottled)
+	retryAfter, throttled := RetryAfter(err)
+	assert.True(t, throttled)
+	assert.Equal(t, time.Duration(0), retryAfter)
+	assert.EqualValues(t, 1, calls.Load())
+
+	calls.Store(0)
+	ctx := ContextWithRetryPolicy(context.Background(), &RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond})
+	var resp map[string]any
+	require.NoError(t, HttpRequest(server.Client(), newRequest(ctx), &resp))
+	assert.Equal(t, true, resp["active"])
+	assert.EqualValues(t, 3, calls.Load())
+
+	calls.Store(0)
+	ctx = ContextWithRetryPolicy(context.Background(), &RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond})
+	assert.ErrorIs(t, HttpRequest(server.Client(), newRequest(ctx), &resp), ErrThrottled)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestResponseError_Throttled(t *testing.T) {
+	unavailable := &ResponseError{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	assert.False(t, unavailable.Throttled())
+	unavailable.Header.Set("Retry-After", "10")
+	assert.True(t, unavailable.Throttled())
+	assert.False(t, (&ResponseError{StatusCode: http.StatusBadRequest, Header: http.Header{}}).Throttled())
+}
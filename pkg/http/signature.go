@@ -0,0 +1,613 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP Message Signature algorithms, as defined in RFC 9421, section 3.3.
+const (
+	SignatureAlgRSAPSSSHA512    = "rsa-pss-sha512"
+	SignatureAlgRSAV15SHA256    = "rsa-v1_5-sha256"
+	SignatureAlgHMACSHA256      = "hmac-sha256"
+	SignatureAlgECDSAP256SHA256 = "ecdsa-p256-sha256"
+	SignatureAlgEd25519         = "ed25519"
+)
+
+const (
+	headerSignature      = "Signature"
+	headerSignatureInput = "Signature-Input"
+	headerContentDigest  = "Content-Digest"
+
+	componentContentDigest = "content-digest"
+	signatureParams        = "@signature-params"
+)
+
+// DefaultSignatureComponents are the components covered by a [MessageSigner]
+// if none are configured. content-digest is only covered for requests with a body.
+var DefaultSignatureComponents = []string{"@method", "@target-uri", componentContentDigest}
+
+// DefaultRequiredSignatureComponents must be covered by the signatures verified
+// by a [MessageVerifier] without RequiredComponents, together with content-digest
+// for requests with a body.
+var DefaultRequiredSignatureComponents = []string{"@method", "@target-uri"}
+
+// DefaultSignatureMaxAge is the maximum age of the created parameter of the
+// signatures verified by a [MessageVerifier] without MaxAge.
+const DefaultSignatureMaxAge = 5 * time.Minute
+
+// signatureClockSkew is the tolerated skew of created parameters in the future.
+const signatureClockSkew = time.Minute
+
+// DefaultSignatureMaxBodySize is the maximum size of the bodies, whose content digest
+// is verified by a [MessageVerifier] without MaxBodySize.
+const DefaultSignatureMaxBodySize = 1 << 20
+
+var (
+	ErrSignatureMissing   = errors.New("http signature: missing signature")
+	ErrSignatureInvalid   = errors.New("http signature: invalid signature")
+	ErrSignatureExpired   = errors.New("http signature: signature expired")
+	ErrSignatureComponent = errors.New("http signature: required component not covered")
+	ErrContentDigest      = errors.New("http signature: content digest does not match")
+	ErrBodyTooLarge       = errors.New("http signature: body too large")
+)
+
+// MessageSigner creates HTTP Message Signatures (RFC 9421) for requests.
+type MessageSigner struct {
+	// KeyID is set as the keyid parameter of the signature.
+	KeyID string
+	// Alg is one of the SignatureAlg constants, set as the alg parameter.
+	Alg string
+	// Key is the signing key: *rsa.PrivateKey, *ecdsa.PrivateKey,
+	// ed25519.PrivateKey, or the secret []byte for hmac-sha256.
+	Key any
+	// Components covered by the signature, defaults to [DefaultSignatureComponents].
+	// Derived components start with an @, others are (lowercase) header fields.
+	Components []string
+	// Label of the signature, defaults to "sig1".
+	Label string
+	// Tag is the optional tag parameter, identifying the application profile.
+	Tag string
+	// Expiry sets the expires parameter relative to created, if not zero.
+	Expiry time.Duration
+}
+
+// Sign sets the Signature and Signature-Input headers of the request.
+// If content-digest is covered, the Content-Digest header is set from the body.
+func (s *MessageSigner) Sign(r *http.Request) error {
+	components := DefaultSignatureComponents
+	if len(s.Components) > 0 {
+		components = make([]string, len(s.Components))
+		for i, c := range s.Components {
+			components[i] = strings.ToLower(c)
+		}
+	}
+	if slices.Contains(components, componentContentDigest) {
+		body, err := readBody(r, 0)
+		if err != nil {
+			return err
+		}
+		if body == nil && r.Header.Get(headerContentDigest) == "" {
+			components = slices.DeleteFunc(slices.Clone(components), func(c string) bool { return c == componentContentDigest })
+		} else if body != nil {
+			r.Header.Set(headerContentDigest, contentDigest(body))
+		}
+	}
+
+	now := time.Now()
+	params := new(strings.Builder)
+	params.WriteString("(")
+	for i, c := range components {
+		if i > 0 {
+			params.WriteString(" ")
+		}
+		params.WriteString(strconv.Quote(c))
+	}
+	fmt.Fprintf(params, ");created=%d", now.Unix())
+	if s.Expiry > 0 {
+		fmt.Fprintf(params, ";expires=%d", now.Add(s.Expiry).Unix())
+	}
+	if s.KeyID != "" {
+		fmt.Fprintf(params, ";keyid=%s", strconv.Quote(s.KeyID))
+	}
+	if s.Alg != "" {
+		fmt.Fprintf(params, ";alg=%s", strconv.Quote(s.Alg))
+	}
+	if s.Tag != "" {
+		fmt.Fprintf(params, ";tag=%s", strconv.Quote(s.Tag))
+	}
+
+	base, err := signatureBase(r, components, params.String())
+	if err != nil {
+		return err
+	}
+	signature, err := signMessage(s.Alg, s.Key, base)
+	if err != nil {
+		return err
+	}
+	label := s.Label
+	if label == "" {
+		label = "sig1"
+	}
+	r.Header.Set(headerSignatureInput, label+"="+params.String())
+	r.Header.Set(headerSignature, label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// SigningTransport is a http.RoundTripper signing all requests with the Signer.
+type SigningTransport struct {
+	// Base is the underlying RoundTripper, defaults to http.DefaultTransport.
+	Base   http.RoundTripper
+	Signer *MessageSigner
+}
+
+func (t *SigningTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	if err := t.Signer.Sign(r); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
+
+// SigningClient returns a copy of the http client, signing all requests with the signer.
+// It can be passed to the WithHTTPClient options of the client packages.
+func SigningClient(client *http.Client, signer *MessageSigner) *http.Client {
+	c := *client
+	c.Transport = &SigningTransport{Base: client.Transport, Signer: signer}
+	return &c
+}
+
+// MessageVerifier verifies HTTP Message Signatures (RFC 9421) of requests.
+type MessageVerifier struct {
+	// KeyByID returns the verification key for the keyid of the signature:
+	// *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or the secret []byte for hmac-sha256,
+	// together with the signature algorithm allowed for the key.
+	KeyByID func(ctx context.Context, keyID string) (key any, alg string, err error)
+	// RequiredComponents must be covered by the signature, defaults to
+	// [DefaultRequiredSignatureComponents] and content-digest for requests with a body.
+	RequiredComponents []string
+	// MaxAge is the maximum age of the created parameter, which is required,
+	// defaults to [DefaultSignatureMaxAge]. A negative MaxAge disables the check.
+	MaxAge time.Duration
+	// MaxBodySize is the maximum size of the body read to verify its content digest,
+	// defaults to [DefaultSignatureMaxBodySize]. Larger bodies are rejected with [ErrBodyTooLarge].
+	MaxBodySize int64
+}
+
+func (v *MessageVerifier) maxBodySize() int64 {
+	if v.MaxBodySize > 0 {
+		return v.MaxBodySize
+	}
+	return DefaultSignatureMaxBodySize
+}
+
+func (v *MessageVerifier) requiredComponents(r *http.Request) []string {
+	if len(v.RequiredComponents) > 0 {
+		return v.RequiredComponents
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		return append(slices.Clone(DefaultRequiredSignatureComponents), componentContentDigest)
+	}
+	return DefaultRequiredSignatureComponents
+}
+
+func (v *MessageVerifier) checkCreated(created string) error {
+	maxAge := v.MaxAge
+	if maxAge < 0 {
+		return nil
+	}
+	if maxAge == 0 {
+		maxAge = DefaultSignatureMaxAge
+	}
+	unix, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: created parameter missing", ErrSignatureInvalid)
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > maxAge {
+		return ErrSignatureExpired
+	}
+	if age < -signatureClockSkew {
+		return fmt.Errorf("%w: created in the future", ErrSignatureInvalid)
+	}
+	return nil
+}
+
+// Verify verifies the first signature of the request.
+// If content-digest is covered, the digest of the body is verified as well.
+func (v *MessageVerifier) Verify(r *http.Request) error {
+	label, params, ok := strings.Cut(r.Header.Get(headerSignatureInput), "=")
+	if !ok {
+		return ErrSignatureMissing
+	}
+	// only the first signature is verified
+	params = firstMember(params)
+	sigLabel, sigValue, ok := strings.Cut(firstMember(r.Header.Get(headerSignature)), "=")
+	if !ok || sigLabel != label || len(sigValue) < 2 || sigValue[0] != ':' || sigValue[len(sigValue)-1] != ':' {
+		return ErrSignatureMissing
+	}
+	signature, err := base64.StdEncoding.DecodeString(sigValue[1 : len(sigValue)-1])
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSignatureInvalid, err)
+	}
+	components, parameters, err := parseSignatureParams(params)
+	if err != nil {
+		return err
+	}
+	for _, c := range v.requiredComponents(r) {
+		if !slices.Contains(components, c) {
+			return fmt.Errorf("%w: %s", ErrSignatureComponent, c)
+		}
+	}
+	if err = v.checkCreated(parameters["created"]); err != nil {
+		return err
+	}
+	if expires, ok := parameters["expires"]; ok {
+		exp, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().After(time.Unix(exp, 0)) {
+			return ErrSignatureExpired
+		}
+	}
+	key, alg, err := v.KeyByID(r.Context(), parameters["keyid"])
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSignatureInvalid, err)
+	}
+	if signedAlg, ok := parameters["alg"]; ok && signedAlg != alg {
+		return fmt.Errorf("%w: unexpected alg %s", ErrSignatureInvalid, signedAlg)
+	}
+	if slices.Contains(components, componentContentDigest) {
+		body, err := readBody(r, v.maxBodySize())
+		if err != nil {
+			return err
+		}
+		if r.Header.Get(headerContentDigest) != contentDigest(body) {
+			return ErrContentDigest
+		}
+	}
+	base, err := signatureBase(r, components, params)
+	if err != nil {
+		return err
+	}
+	return verifyMessage(alg, key, base, signature)
+}
+
+// Handler is a middleware rejecting requests without a valid signature
+// with 401 Unauthorized. It can be used as interceptor of the OP
+// or in front of resource server endpoints.
+func (v *MessageVerifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readBody reads the body of the request and replaces it,
+// so it can be read again. It returns nil if there is no body.
+// Bodies larger than maxSize are rejected, unless maxSize is 0.
+func readBody(r *http.Request, maxSize int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	reader := io.Reader(r.Body)
+	if maxSize > 0 {
+		reader = io.LimitReader(r.Body, maxSize+1)
+	}
+	body, err := io.ReadAll(reader)
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("http signature: unable to read body: %w", err)
+	}
+	if maxSize > 0 && int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrBodyTooLarge, maxSize)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// contentDigest returns the Content-Digest (RFC 9530) of the body using sha-256.
+func contentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// signatureBase creates the signature base of RFC 9421, section 2.5.
+func signatureBase(r *http.Request, components []string, params string) ([]byte, error) {
+	base := new(bytes.Buffer)
+	for _, c := range components {
+		value, err := componentValue(r, c)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(base, "%s: %s\n", strconv.Quote(c), value)
+	}
+	fmt.Fprintf(base, "%s: %s", strconv.Quote(signatureParams), params)
+	return base.Bytes(), nil
+}
+
+func componentValue(r *http.Request, component string) (string, error) {
+	switch component {
+	case "@method":
+		return strings.ToUpper(r.Method), nil
+	case "@target-uri":
+		return targetScheme(r) + "://" + authority(r) + r.URL.RequestURI(), nil
+	case "@authority":
+		return authority(r), nil
+	case "@scheme":
+		return targetScheme(r), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		if path := r.URL.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+	if strings.HasPrefix(component, "@") {
+		return "", fmt.Errorf("http signature: unsupported component %s", component)
+	}
+	values := r.Header.Values(component)
+	if len(values) == 0 {
+		return "", fmt.Errorf("http signature: header %s missing", component)
+	}
+	// the values of the header are not modified in place
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+func authority(r *http.Request) string {
+	if r.Host != "" {
+		return strings.ToLower(r.Host)
+	}
+	return strings.ToLower(r.URL.Host)
+}
+
+func targetScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// firstMember returns the first member of a structured field dictionary.
+func firstMember(dict string) string {
+	var inString, inList bool
+	for i, c := range dict {
+		switch {
+		case c == '"' && (i == 0 || dict[i-1] != '\\'):
+			inString = !inString
+		case inString:
+		case c == '(':
+			inList = true
+		case c == ')':
+			inList = false
+		case c == ',' && !inList:
+			return strings.TrimSpace(dict[:i])
+		}
+	}
+	return strings.TrimSpace(dict)
+}
+
+// parseSignatureParams parses the inner list of covered components
+// and the parameters of the signature.
+func parseSignatureParams(value string) (components []string, params map[string]string, err error) {
+	if !strings.HasPrefix(value, "(") {
+		return nil, nil, fmt.Errorf("%w: malformed signature input", ErrSignatureInvalid)
+	}
+	list, rest, ok := strings.Cut(value[1:], ")")
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: malformed signature input", ErrSignatureInvalid)
+	}
+	for _, item := range strings.Fields(list) {
+		c, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: malformed component %s", ErrSignatureInvalid, item)
+		}
+		components = append(components, c)
+	}
+	params, err = parseParameters(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return components, params, nil
+}
+
+// parseParameters parses the parameters of a structured field (RFC 8941, section 3.1.2),
+// whose string values may contain ";" and escaped quotes.
+func parseParameters(s string) (map[string]string, error) {
+	malformed := fmt.Errorf("%w: malformed signature parameters", ErrSignatureInvalid)
+	params := make(map[string]string)
+	for s = strings.TrimLeft(s, " "); s != ""; s = strings.TrimLeft(s, " ") {
+		if s[0] != ';' {
+			return nil, malformed
+		}
+		s = strings.TrimLeft(s[1:], " ")
+		end := strings.IndexAny(s, "=;")
+		if end < 0 {
+			end = len(s)
+		}
+		key := s[:end]
+		if key == "" {
+			return nil, malformed
+		}
+		s = s[end:]
+		var value string
+		if strings.HasPrefix(s, "=") {
+			var err error
+			if value, s, err = parseParameterValue(s[1:]); err != nil {
+				return nil, malformed
+			}
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+// parseParameterValue returns the value at the start of s, unquoted if it is a string,
+// and the rest of s.
+func parseParameterValue(s string) (value, rest string, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexByte(s, ';')
+		if end < 0 {
+			end = len(s)
+		}
+		return strings.TrimRight(s[:end], " "), s[end:], nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) || (s[i] != '"' && s[i] != '\\') {
+				return "", "", errors.New("invalid escape")
+			}
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+func signMessage(alg string, key any, base []byte) ([]byte, error) {
+	switch alg {
+	case SignatureAlgRSAPSSSHA512:
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errInvalidSignatureKey(alg)
+		}
+		sum := sha512.Sum512(base)
+		return rsa.SignPSS(rand.Reader, k, crypto.SHA512, sum[:], &rsa.PSSOptions{SaltLength: 64})
+	case SignatureAlgRSAV15SHA256:
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errInvalidSignatureKey(alg)
+		}
+		sum := sha256.Sum256(base)
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+	case SignatureAlgHMACSHA256:
+		k, ok := key.([]byte)
+		if !ok {
+			return nil, errInvalidSignatureKey(alg)
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write(base)
+		return mac.Sum(nil), nil
+	case SignatureAlgECDSAP256SHA256:
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return nil, errInvalidSignatureKey(alg)
+		}
+		sum := sha256.Sum256(base)
+		r, s, err := ecdsa.Sign(rand.Reader, k, sum[:])
+		if err != nil {
+			return nil, err
+		}
+		// the signature is the concatenation of r and s, 32 bytes each
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	case SignatureAlgEd25519:
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errInvalidSignatureKey(alg)
+		}
+		return ed25519.Sign(k, base), nil
+	}
+	return nil, fmt.Errorf("http signature: unsupported alg %q", alg)
+}
+
+func verifyMessage(alg string, key any, base, signature []byte) error {
+	var valid bool
+	switch alg {
+	case SignatureAlgRSAPSSSHA512:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errInvalidSignatureKey(alg)
+		}
+		sum := sha512.Sum512(base)
+		valid = rsa.VerifyPSS(k, crypto.SHA512, sum[:], signature, &rsa.PSSOptions{SaltLength: 64}) == nil
+	case SignatureAlgRSAV15SHA256:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errInvalidSignatureKey(alg)
+		}
+		sum := sha256.Sum256(base)
+		valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], signature) == nil
+	case SignatureAlgHMACSHA256:
+		k, ok := key.([]byte)
+		if !ok {
+			return errInvalidSignatureKey(alg)
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write(base)
+		valid = hmac.Equal(mac.Sum(nil), signature)
+	case SignatureAlgECDSAP256SHA256:
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return errInvalidSignatureKey(alg)
+		}
+		if len(signature) != 64 {
+			return ErrSignatureInvalid
+		}
+		sum := sha256.Sum256(base)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		valid = ecdsa.Verify(k, sum[:], r, s)
+	case SignatureAlgEd25519:
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errInvalidSignatureKey(alg)
+		}
+		valid = ed25519.Verify(k, base, signature)
+	default:
+		return fmt.Errorf("http signature: unsupported alg %q", alg)
+	}
+	if !valid {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+func errInvalidSignatureKey(alg string) error {
+	return fmt.Errorf("http signature: invalid key type for alg %s", alg)
+}
@@ -0,0 +1,192 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageSignature(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("secret")
+
+	tests := []struct {
+		alg     string
+		private any
+		public  any
+	}{
+		{SignatureAlgRSAPSSSHA512, rsaKey, &rsaKey.PublicKey},
+		{SignatureAlgRSAV15SHA256, rsaKey, &rsaKey.PublicKey},
+		{SignatureAlgECDSAP256SHA256, ecKey, &ecKey.PublicKey},
+		{SignatureAlgEd25519, edKey, edPub},
+		{SignatureAlgHMACSHA256, secret, secret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			verifier := &MessageVerifier{
+				KeyByID: func(_ context.Context, keyID string) (any, string, error) {
+					assert.Equal(t, "key1", keyID)
+					return tt.public, tt.alg, nil
+				},
+				RequiredComponents: []string{"@method", "content-digest"},
+			}
+			server := httptest.NewServer(verifier.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				w.Write(body)
+			})))
+			defer server.Close()
+			signer := &MessageSigner{KeyID: "key1", Alg: tt.alg, Key: tt.private}
+
+			resp, err := SigningClient(server.Client(), signer).Post(server.URL+"/token?a=b", "application/x-www-form-urlencoded", strings.NewReader("grant_type=refresh_token"))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+			assert.Equal(t, "grant_type=refresh_token", string(body))
+		})
+	}
+}
+
+func TestMessageVerifier_Verify(t *testing.T) {
+	secret := []byte("secret")
+	signer := &MessageSigner{KeyID: "key1", Alg: SignatureAlgHMACSHA256, Key: secret}
+	verifier := &MessageVerifier{
+		KeyByID: func(context.Context, string) (any, string, error) {
+			return secret, SignatureAlgHMACSHA256, nil
+		},
+	}
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "https://example.com/token", strings.NewReader("body"))
+		require.NoError(t, signer.Sign(r))
+		return r
+	}
+
+	require.NoError(t, verifier.Verify(newRequest()))
+
+	r := httptest.NewRequest(http.MethodPost, "https://example.com/token", nil)
+	assert.ErrorIs(t, verifier.Verify(r), ErrSignatureMissing)
+
+	r = newRequest()
+	r.Body = io.NopCloser(strings.NewReader("other"))
+	assert.ErrorIs(t, verifier.Verify(r), ErrContentDigest)
+
+	r = newRequest()
+	r.Method = http.MethodPut
+	assert.ErrorIs(t, verifier.Verify(r), ErrSignatureInvalid)
+
+	// the header values of the request are not modified
+	r = httptest.NewRequest(http.MethodPost, "https://example.com/token", strings.NewReader("body"))
+	r.Header.Set("Authorization", " Bearer token ")
+	require.NoError(t, (&MessageSigner{Alg: SignatureAlgHMACSHA256, Key: secret, Components: []string{"@method", "@target-uri", "content-digest", "authorization"}}).Sign(r))
+	require.NoError(t, verifier.Verify(r))
+	assert.Equal(t, " Bearer token ", r.Header.Get("Authorization"))
+
+	// defaults require the method, target and body to be covered
+	for _, components := range [][]string{{"@target-uri", "content-digest"}, {"@method", "content-digest"}, {"@method", "@target-uri"}} {
+		r = httptest.NewRequest(http.MethodPost, "https://example.com/token", strings.NewReader("body"))
+		require.NoError(t, (&MessageSigner{Alg: SignatureAlgHMACSHA256, Key: secret, Components: components}).Sign(r))
+		assert.ErrorIs(t, verifier.Verify(r), ErrSignatureComponent, components)
+	}
+
+	// and a recent created parameter
+	r = newRequest()
+	r.Header.Set(headerSignatureInput, strings.Replace(r.Header.Get(headerSignatureInput), ";created=", ";created=1", 1))
+	assert.Error(t, verifier.Verify(r))
+	r = newRequest()
+	r.Header.Set(headerSignatureInput, `sig1=("@method" "@target-uri" "content-digest");created=1618884473`)
+	assert.ErrorIs(t, verifier.Verify(r), ErrSignatureExpired)
+
+	// bodies are only read up to MaxBodySize
+	verifier.MaxBodySize = 3
+	assert.ErrorIs(t, verifier.Verify(newRequest()), ErrBodyTooLarge)
+	verifier.MaxBodySize = 0
+
+	verifier.RequiredComponents = []string{"authorization"}
+	assert.ErrorIs(t, verifier.Verify(newRequest()), ErrSignatureComponent)
+}
+
+func Test_parseSignatureParams(t *testing.T) {
+	components, params, err := parseSignatureParams(`("@method" "@target-uri");created=1618884473;keyid="key;1 \"a\\b\"";alg="hmac-sha256"`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@method", "@target-uri"}, components)
+	assert.Equal(t, map[string]string{
+		"created": "1618884473",
+		"keyid":   `key;1 "a\b"`,
+		"alg":     "hmac-sha256",
+	}, params)
+
+	for _, value := range []string{
+		`("@method");keyid="key1`,
+		`("@method");keyid="key\1"`,
+		`("@method");keyid="key1"x`,
+		`("@method");=key1`,
+	} {
+		_, _, err = parseSignatureParams(value)
+		assert.ErrorIs(t, err, ErrSignatureInvalid, value)
+	}
+
+	// a quoted ";" in the keyid is signed and verified
+	secret := []byte("secret")
+	verifier := &MessageVerifier{
+		KeyByID: func(_ context.Context, keyID string) (any, string, error) {
+			assert.Equal(t, "key;1", keyID)
+			return secret, SignatureAlgHMACSHA256, nil
+		},
+	}
+	r := httptest.NewRequest(http.MethodPost, "https://example.com/token", strings.NewReader("body"))
+	require.NoError(t, (&MessageSigner{KeyID: "key;1", Alg: SignatureAlgHMACSHA256, Key: secret}).Sign(r))
+	require.NoError(t, verifier.Verify(r))
+}
+
+func TestMessageSigner_ECDSACurve(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/userinfo", nil)
+	assert.Error(t, (&MessageSigner{Alg: SignatureAlgECDSAP256SHA256, Key: key}).Sign(r))
+	assert.Error(t, verifyMessage(SignatureAlgECDSAP256SHA256, &key.PublicKey, []byte("base"), make([]byte, 64)))
+}
+
+// Test vector of RFC 9421, appendix B.2.6.
+func TestMessageVerifier_RFC9421Ed25519(t *testing.T) {
+	block, _ := pem.Decode([]byte(`-----BEGIN PUBLIC KEY-----
+MCowBQYDK2VwAyEAJrQLj5P/89iXES9+vFgrIy29clF9CC/oPPsw3c5D0bs=
+-----END PUBLIC KEY-----`))
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/foo?param=Value&Pet=dog", strings.NewReader(`{"hello": "world"}`))
+	r.Host = "example.com"
+	r.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Length", "18")
+	r.Header.Set("Signature-Input", `sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`)
+	r.Header.Set("Signature", `sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:`)
+
+	verifier := &MessageVerifier{
+		KeyByID: func(_ context.Context, keyID string) (any, string, error) {
+			assert.Equal(t, "test-key-ed25519", keyID)
+			return pub, SignatureAlgEd25519, nil
+		},
+		RequiredComponents: []string{"@method", "@path", "@authority"},
+		MaxAge:             -1,
+	}
+	assert.NoError(t, verifier.Verify(r))
+}
@@ -0,0 +1,26 @@
+package oidc
+
+// Authentication Method Reference values of the amr claim,
+// registered by RFC 8176.
+const (
+	AMRFace              = "face"
+	AMRFingerprint       = "fpt"
+	AMRGeolocation       = "geo"
+	AMRHardwareKey       = "hwk"
+	AMRIris              = "iris"
+	AMRKnowledgeBased    = "kba"
+	AMRMultipleChannel   = "mca"
+	AMRMultipleFactor    = "mfa"
+	AMROneTimePassword   = "otp"
+	AMRPin               = "pin"
+	AMRPassword          = "pwd"
+	AMRRiskBased         = "rba"
+	AMRRetina            = "retina"
+	AMRSmartCard         = "sc"
+	AMRSMS               = "sms"
+	AMRSoftwareKey       = "swk"
+	AMRTelephone         = "tel"
+	AMRUserPresence      = "user"
+	AMRVoiceBiometric    = "vbm"
+	AMRWindowsIntegrated = "wia"
+)
@@ -84,11 +84,25 @@ type AuthRequest struct {
 	LoginHint    string              `json:"login_hint" schema:"login_hint"`
 	ACRValues    SpaceDelimitedArray `json:"acr_values" schema:"acr_values"`
 
+	// LoginHintToken, DomainHint and IDPHint are additional hints
+	// for the Login UI, see [LoginHints].
+	LoginHintToken string `json:"login_hint_token,omitempty" schema:"login_hint_token"`
+	DomainHint     string `json:"domain_hint,omitempty" schema:"domain_hint"`
+	IDPHint        string `json:"idp_hint,omitempty" schema:"idp_hint"`
+
 	CodeChallenge       string              `json:"code_challenge" schema:"code_challenge"`
 	CodeChallengeMethod CodeChallengeMethod `json:"code_challenge_method" schema:"code_challenge_method"`
 
 	// RequestParam enables OIDC requests to be passed in a single, self-contained parameter (as JWT, called Request Object)
 	RequestParam string `schema:"request"`
+
+	// RequestURI references an auth request pushed to the
+	// Pushed Authorization Request endpoint before (RFC 9126).
+	RequestURI string `json:"request_uri,omitempty" schema:"request_uri"`
+
+	// AuthorizationDetails are the fine-grained permissions
+	// requested by Rich Authorization Requests (RFC 9396).
+	AuthorizationDetails AuthorizationDetails `json:"authorization_details,omitempty" schema:"authorization_details,omitempty"`
 }
 
 func (a *AuthRequest) LogValue() slog.Value {
@@ -115,6 +129,11 @@ func (a *AuthRequest) GetState() string {
 	return a.State
 }
 
+// GetClientID returns the client_id value
+func (a *AuthRequest) GetClientID() string {
+	return a.ClientID
+}
+
 // GetResponseMode returns the optional ResponseMode
 func (a *AuthRequest) GetResponseMode() ResponseMode {
 	return a.ResponseMode
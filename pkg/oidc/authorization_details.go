@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// AuthorizationDetail is an entry of the authorization_details parameter
+// of Rich Authorization Requests (RFC 9396, section 2), describing
+// the fine-grained permissions requested by the client.
+type AuthorizationDetail struct {
+	Type       string   `json:"type"`
+	Locations  []string `json:"locations,omitempty"`
+	Actions    []string `json:"actions,omitempty"`
+	DataTypes  []string `json:"datatypes,omitempty"`
+	Identifier string   `json:"identifier,omitempty"`
+	Privileges []string `json:"privileges,omitempty"`
+
+	// Fields are the type specific fields of the detail,
+	// which can be decoded into a typed struct by [AuthorizationDetail.Decode].
+	Fields map[string]any `json:"-"`
+}
+
+type adAlias AuthorizationDetail
+
+func (d *AuthorizationDetail) MarshalJSON() ([]byte, error) {
+	return mergeAndMarshalClaims((*adAlias)(d), d.Fields)
+}
+
+func (d *AuthorizationDetail) UnmarshalJSON(data []byte) error {
+	if err := unmarshalJSONMulti(data, (*adAlias)(d), &d.Fields); err != nil {
+		return err
+	}
+	for _, key := range []string{"type", "locations", "actions", "datatypes", "identifier", "privileges"} {
+		delete(d.Fields, key)
+	}
+	if len(d.Fields) == 0 {
+		d.Fields = nil
+	}
+	return nil
+}
+
+// Decode decodes the detail, including its type specific fields, into v,
+// typically a pointer to a struct of the detail type.
+func (d *AuthorizationDetail) Decode(v any) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// AuthorizationDetails is the authorization_details parameter of Rich Authorization Requests (RFC 9396).
+// It is a JSON array, sent form-encoded as string in authorization and token requests.
+type AuthorizationDetails []AuthorizationDetail
+
+func (a *AuthorizationDetails) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*a = nil
+		return nil
+	}
+	var details []AuthorizationDetail
+	if err := json.Unmarshal(text, &details); err != nil {
+		return fmt.Errorf("oidc authorization_details: %w", err)
+	}
+	*a = details
+	return nil
+}
+
+func (a AuthorizationDetails) MarshalText() ([]byte, error) {
+	return json.Marshal([]AuthorizationDetail(a))
+}
+
+func (a AuthorizationDetails) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]AuthorizationDetail(a))
+}
+
+// UnmarshalJSON accepts a JSON array, or a string containing one,
+// as sent by clients copying the form-encoded parameter into request objects.
+func (a *AuthorizationDetails) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		return a.UnmarshalText([]byte(str))
+	}
+	var details []AuthorizationDetail
+	if err := json.Unmarshal(data, &details); err != nil {
+		return fmt.Errorf("oidc authorization_details: %w", err)
+	}
+	*a = details
+	return nil
+}
+
+// OfType returns the details of the type.
+func (a AuthorizationDetails) OfType(typ string) AuthorizationDetails {
+	var details AuthorizationDetails
+	for _, detail := range a {
+		if detail.Type == typ {
+			details = append(details, detail)
+		}
+	}
+	return details
+}
+
+// Contains reports whether one of the details equals the detail,
+// comparing their JSON encoding.
+func (a AuthorizationDetails) Contains(detail AuthorizationDetail) bool {
+	want, err := json.Marshal(&detail)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(a, func(d AuthorizationDetail) bool {
+		got, err := json.Marshal(&d)
+		return err == nil && bytes.Equal(got, want)
+	})
+}
+
+// DecodeAuthorizationDetails decodes the details of the type into T,
+// e.g. for resource servers enforcing the details of introspected tokens.
+func DecodeAuthorizationDetails[T any](details AuthorizationDetails, typ string) ([]T, error) {
+	var decoded []T
+	for _, detail := range details.OfType(typ) {
+		var v T
+		if err := detail.Decode(&v); err != nil {
+			return nil, fmt.Errorf("oidc authorization_details of type %s: %w", typ, err)
+		}
+		decoded = append(decoded, v)
+	}
+	return decoded, nil
+}
+
+// AuthorizationDetailValidator validates the type specific fields of an authorization detail.
+type AuthorizationDetailValidator func(detail *AuthorizationDetail) error
+
+// TypedAuthorizationDetail returns a validator decoding the detail into T,
+// which is then validated by validate, if not nil.
+func TypedAuthorizationDetail[T any](validate func(*T) error) AuthorizationDetailValidator {
+	return func(detail *AuthorizationDetail) error {
+		var v T
+		if err := detail.Decode(&v); err != nil {
+			return err
+		}
+		if validate == nil {
+			return nil
+		}
+		return validate(&v)
+	}
+}
+
+// AuthorizationDetailTypes is a registry of the supported authorization detail types
+// and their validators, as advertised by authorization_details_types_supported.
+type AuthorizationDetailTypes struct {
+	validators map[string]AuthorizationDetailValidator
+}
+
+func NewAuthorizationDetailTypes() *AuthorizationDetailTypes {
+	return &AuthorizationDetailTypes{validators: make(map[string]AuthorizationDetailValidator)}
+}
+
+// Register adds the type to the registry. A nil validator
+// accepts any detail of the type.
+func (t *AuthorizationDetailTypes) Register(typ string, validate AuthorizationDetailValidator) *AuthorizationDetailTypes {
+	t.validators[typ] = validate
+	return t
+}
+
+// Types returns the registered types, sorted.
+func (t *AuthorizationDetailTypes) Types() []string {
+	if t == nil {
+		return nil
+	}
+	types := make([]string, 0, len(t.validators))
+	for typ := range t.validators {
+		types = append(types, typ)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Validate checks that all details are of a registered type
+// and valid according to its validator (RFC 9396, section 5).
+func (t *AuthorizationDetailTypes) Validate(details AuthorizationDetails) error {
+	for i := range details {
+		detail := &details[i]
+		if detail.Type == "" {
+			return ErrInvalidAuthorizationDetails().WithDescription("authorization_details entry %d without type", i)
+		}
+		var (
+			validate AuthorizationDetailValidator
+			ok       bool
+		)
+		if t != nil {
+			validate, ok = t.validators[detail.Type]
+		}
+		if !ok {
+			return ErrInvalidAuthorizationDetails().WithDescription("authorization_details type %s not supported", detail.Type)
+		}
+		if validate == nil {
+			continue
+		}
+		if err := validate(detail); err != nil {
+			return ErrInvalidAuthorizationDetails().WithDescription("authorization_details of type %s invalid: %s", detail.Type, err).WithParent(err)
+		}
+	}
+	return nil
+}
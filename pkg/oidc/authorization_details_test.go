@@ -0,0 +1,125 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zitadel/schema"
+)
+
+const paymentDetails = `[{"type":"payment_initiation","actions":["initiate"],"locations":["https://example.com/payments"],"instructedAmount":{"currency":"EUR","amount":"123.50"},"creditorName":"Merchant A"}]`
+
+type paymentInitiation struct {
+	Type             string `json:"type"`
+	InstructedAmount struct {
+		Currency string `json:"currency"`
+		Amount   string `json:"amount"`
+	} `json:"instructedAmount"`
+	CreditorName string `json:"creditorName"`
+}
+
+func TestAuthorizationDetails_JSON(t *testing.T) {
+	var details AuthorizationDetails
+	require.NoError(t, json.Unmarshal([]byte(paymentDetails), &details))
+	require.Len(t, details, 1)
+	assert.Equal(t, "payment_initiation", details[0].Type)
+	assert.Equal(t, []string{"initiate"}, details[0].Actions)
+	assert.Equal(t, []string{"https://example.com/payments"}, details[0].Locations)
+	assert.Equal(t, map[string]any{
+		"instructedAmount": map[string]any{"currency": "EUR", "amount": "123.50"},
+		"creditorName":     "Merchant A",
+	}, details[0].Fields)
+
+	data, err := json.Marshal(details)
+	require.NoError(t, err)
+	assert.JSONEq(t, paymentDetails, string(data))
+
+	var fromString AuthorizationDetails
+	str, err := json.Marshal(paymentDetails)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(str, &fromString))
+	assert.Equal(t, details, fromString)
+}
+
+func TestAuthorizationDetails_Form(t *testing.T) {
+	values := url.Values{"client_id": {"client"}, "authorization_details": {paymentDetails}}
+	var authReq AuthRequest
+	require.NoError(t, schema.NewDecoder().Decode(&authReq, values))
+	require.Len(t, authReq.AuthorizationDetails, 1)
+	assert.Equal(t, "payment_initiation", authReq.AuthorizationDetails[0].Type)
+
+	encoded := make(url.Values)
+	require.NoError(t, NewEncoder().Encode(&AccessTokenRequest{Code: "code", AuthorizationDetails: authReq.AuthorizationDetails}, encoded))
+	assert.JSONEq(t, paymentDetails, encoded.Get("authorization_details"))
+
+	encoded = make(url.Values)
+	require.NoError(t, NewEncoder().Encode(&AccessTokenRequest{Code: "code"}, encoded))
+	assert.NotContains(t, encoded, "authorization_details")
+}
+
+func TestAuthorizationDetails_Contains(t *testing.T) {
+	var details AuthorizationDetails
+	require.NoError(t, json.Unmarshal([]byte(paymentDetails), &details))
+	var same AuthorizationDetails
+	require.NoError(t, json.Unmarshal([]byte(paymentDetails), &same))
+	assert.True(t, details.Contains(same[0]))
+
+	other := same[0]
+	other.Fields = map[string]any{"creditorName": "Merchant B"}
+	assert.False(t, details.Contains(other))
+	assert.False(t, details.Contains(AuthorizationDetail{Type: "payment_initiation"}))
+}
+
+func TestDecodeAuthorizationDetails(t *testing.T) {
+	var details AuthorizationDetails
+	require.NoError(t, json.Unmarshal([]byte(paymentDetails), &details))
+	details = append(details, AuthorizationDetail{Type: "account_information"})
+
+	payments, err := DecodeAuthorizationDetails[paymentInitiation](details, "payment_initiation")
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, "EUR", payments[0].InstructedAmount.Currency)
+	assert.Equal(t, "Merchant A", payments[0].CreditorName)
+	assert.Len(t, details.OfType("account_information"), 1)
+}
+
+func TestAuthorizationDetailTypes_Validate(t *testing.T) {
+	types := NewAuthorizationDetailTypes().
+		Register("payment_initiation", TypedAuthorizationDetail(func(p *paymentInitiation) error {
+			if p.InstructedAmount.Currency == "" {
+				return errors.New("instructedAmount required")
+			}
+			return nil
+		})).
+		Register("account_information", nil)
+	assert.Equal(t, []string{"account_information", "payment_initiation"}, types.Types())
+
+	var valid AuthorizationDetails
+	require.NoError(t, json.Unmarshal([]byte(paymentDetails), &valid))
+	tests := []struct {
+		name    string
+		types   *AuthorizationDetailTypes
+		details AuthorizationDetails
+		wantErr bool
+	}{
+		{"valid", types, append(valid, AuthorizationDetail{Type: "account_information"}), false},
+		{"missing type", types, AuthorizationDetails{{Actions: []string{"read"}}}, true},
+		{"unknown type", types, AuthorizationDetails{{Type: "unknown"}}, true},
+		{"invalid fields", types, AuthorizationDetails{{Type: "payment_initiation"}}, true},
+		{"no types", nil, valid, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.types.Validate(tt.details)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAuthorizationDetails())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
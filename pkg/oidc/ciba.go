@@ -0,0 +1,68 @@
+package oidc
+
+// BackchannelTokenDeliveryMode is the mode the client is informed of the completed
+// authentication of the Client-Initiated Backchannel Authentication Flow (CIBA, section 5).
+type BackchannelTokenDeliveryMode string
+
+const (
+	// BackchannelTokenDeliveryModePoll lets the client poll the token endpoint.
+	BackchannelTokenDeliveryModePoll BackchannelTokenDeliveryMode = "poll"
+	// BackchannelTokenDeliveryModePing notifies the client at its client_notification_endpoint,
+	// which then calls the token endpoint.
+	BackchannelTokenDeliveryModePing BackchannelTokenDeliveryMode = "ping"
+)
+
+// BackchannelAuthenticationRequest implements
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.1,
+// 7.1. Authentication Request.
+// Exactly one of the hints (LoginHintToken, IDTokenHint or LoginHint) must be set.
+type BackchannelAuthenticationRequest struct {
+	Scopes                  SpaceDelimitedArray `schema:"scope,omitempty"`
+	ClientNotificationToken string              `schema:"client_notification_token,omitempty"`
+	ACRValues               SpaceDelimitedArray `schema:"acr_values,omitempty"`
+	LoginHintToken          string              `schema:"login_hint_token,omitempty"`
+	IDTokenHint             string              `schema:"id_token_hint,omitempty"`
+	LoginHint               string              `schema:"login_hint,omitempty"`
+	BindingMessage          string              `schema:"binding_message,omitempty"`
+	UserCode                string              `schema:"user_code,omitempty"`
+	// RequestedExpiry is the requested lifetime of the auth_req_id in seconds.
+	RequestedExpiry int    `schema:"requested_expiry,omitempty"`
+	ClientID        string `schema:"client_id,omitempty"`
+}
+
+// HasSingleHint reports if exactly one of the hints is set,
+// as required by the specification.
+func (r *BackchannelAuthenticationRequest) HasSingleHint() bool {
+	var hints int
+	for _, hint := range []string{r.LoginHintToken, r.IDTokenHint, r.LoginHint} {
+		if hint != "" {
+			hints++
+		}
+	}
+	return hints == 1
+}
+
+// BackchannelAuthenticationResponse implements
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.3,
+// 7.3. Successful Authentication Request Acknowledgement.
+type BackchannelAuthenticationResponse struct {
+	AuthReqID string `json:"auth_req_id"`
+	ExpiresIn int    `json:"expires_in"`
+	Interval  int    `json:"interval,omitempty"`
+}
+
+// BackchannelTokenRequest implements
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.1,
+// 10.1. Token Request Using CIBA Grant Type.
+type BackchannelTokenRequest struct {
+	GrantType GrantType `json:"grant_type" schema:"grant_type"`
+	AuthReqID string    `json:"auth_req_id" schema:"auth_req_id"`
+}
+
+// BackchannelNotification is the body of the ping callback sent to the
+// client_notification_endpoint of the client, see
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.2,
+// 10.2. Ping Callback.
+type BackchannelNotification struct {
+	AuthReqID string `json:"auth_req_id"`
+}
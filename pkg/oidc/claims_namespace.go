@@ -0,0 +1,133 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// RegisteredClaims are the claim names of the JWT, OpenID Connect and OAuth
+// specifications emitted or interpreted by this package, see the IANA
+// JSON Web Token Claims registry. Custom claims must not use them,
+// as the registered claims of the tokens and responses overwrite
+// custom claims of the same name.
+var RegisteredClaims = []string{
+	// RFC 7519
+	"iss", "sub", "aud", "exp", "nbf", "iat", "jti",
+	// OpenID Connect Core 1.0
+	"azp", "nonce", "auth_time", "at_hash", "c_hash", "acr", "amr", "sub_jwk",
+	"name", "given_name", "family_name", "middle_name", "nickname", "preferred_username",
+	"profile", "picture", "website", "email", "email_verified", "gender", "birthdate",
+	"zoneinfo", "locale", "phone_number", "phone_number_verified", "address", "updated_at",
+	"_claim_names", "_claim_sources",
+	// OpenID Connect Front- and Back-Channel Logout 1.0
+	"sid", "events",
+	// RFC 7800, RFC 8693, RFC 9068, RFC 9396
+	"cnf", "act", "may_act", "scope", "client_id", "groups", "roles", "entitlements",
+	"authorization_details",
+	// RFC 7662
+	"active", "token_type", "username",
+}
+
+// IsRegisteredClaim reports if the claim name is one of the [RegisteredClaims].
+func IsRegisteredClaim(name string) bool {
+	return slices.Contains(RegisteredClaims, name)
+}
+
+var (
+	// ErrClaimCollision is returned for custom claims named like a registered claim.
+	ErrClaimCollision = errors.New("custom claim collides with registered claim")
+	// ErrClaimNamespace is returned for namespaces which are no absolute URI.
+	ErrClaimNamespace = errors.New("invalid claim namespace")
+)
+
+// CheckClaimNames returns an error wrapping [ErrClaimCollision]
+// for each name, which is one of the [RegisteredClaims].
+func CheckClaimNames(names ...string) error {
+	var errs []error
+	for _, name := range names {
+		if IsRegisteredClaim(name) {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrClaimCollision, name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ClaimNamespace is a URI prefixing custom claim names, making them
+// collision-resistant public claim names as of RFC 7519, section 4.2,
+// e.g. https://example.com/ for the https://example.com/roles claim.
+type ClaimNamespace string
+
+// NewClaimNamespace returns the namespace of the absolute URI.
+// URLs not ending with a slash or hash get a slash appended and URNs a colon,
+// so https://example.com/claims prefixes https://example.com/claims/roles
+// and urn:example prefixes urn:example:roles.
+func NewClaimNamespace(uri string) (ClaimNamespace, error) {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() {
+		return "", fmt.Errorf("%w: %q is no absolute URI", ErrClaimNamespace, uri)
+	}
+	switch {
+	case u.Opaque != "":
+		if !strings.HasSuffix(uri, ":") {
+			uri += ":"
+		}
+	case !strings.HasSuffix(uri, "/") && !strings.HasSuffix(uri, "#"):
+		uri += "/"
+	}
+	return ClaimNamespace(uri), nil
+}
+
+// Claim returns the name of the claim in the namespace.
+func (n ClaimNamespace) Claim(name string) string {
+	return string(n) + name
+}
+
+// Set sets the claim of the namespace in the claims map,
+// which is created if nil.
+func (n ClaimNamespace) Set(claims map[string]any, name string, value any) map[string]any {
+	if claims == nil {
+		claims = make(map[string]any, 1)
+	}
+	claims[n.Claim(name)] = value
+	return claims
+}
+
+// Get returns the value of the claim of the namespace.
+func (n ClaimNamespace) Get(claims map[string]any, name string) (any, bool) {
+	value, ok := claims[n.Claim(name)]
+	return value, ok
+}
+
+// Claims returns the claims of the namespace, with the namespace
+// removed from their names.
+func (n ClaimNamespace) Claims(claims map[string]any) map[string]any {
+	namespaced := make(map[string]any)
+	for key, value := range claims {
+		if name, ok := strings.CutPrefix(key, string(n)); ok && name != "" {
+			namespaced[name] = value
+		}
+	}
+	return namespaced
+}
+
+// Decode decodes the value of the claim of the namespace into v,
+// which must be a pointer as per json.Unmarshal rules.
+// It reports false and leaves v unchanged, if the claim is missing.
+func (n ClaimNamespace) Decode(claims map[string]any, name string, v any) (bool, error) {
+	value, ok := n.Get(claims, name)
+	if !ok {
+		return false, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return true, fmt.Errorf("oidc: claim %s: %w", n.Claim(name), err)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("oidc: claim %s: %w", n.Claim(name), err)
+	}
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClaimNamespace(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    ClaimNamespace
+		wantErr bool
+	}{
+		{uri: "https://example.com/", want: "https://example.com/"},
+		{uri: "https://example.com/claims", want: "https://example.com/claims/"},
+		{uri: "https://example.com/claims#", want: "https://example.com/claims#"},
+		{uri: "urn:example", want: "urn:example:"},
+		{uri: "urn:example:", want: "urn:example:"},
+		{uri: "example.com/claims", wantErr: true},
+		{uri: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := NewClaimNamespace(tt.uri)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrClaimNamespace)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckClaimNames(t *testing.T) {
+	require.NoError(t, CheckClaimNames("tenant", "https://example.com/roles"))
+	err := CheckClaimNames("tenant", "email", "sid")
+	require.ErrorIs(t, err, ErrClaimCollision)
+	assert.Contains(t, err.Error(), "email")
+	assert.Contains(t, err.Error(), "sid")
+	assert.NotContains(t, err.Error(), "tenant")
+}
+
+func TestClaimNamespace(t *testing.T) {
+	ns := ClaimNamespace("https://example.com/")
+	claims := ns.Set(nil, "roles", []string{"admin"})
+	claims = ns.Set(claims, "tenant", "acme")
+	claims["email"] = "jane@example.com"
+	assert.Equal(t, map[string]any{
+		"https://example.com/roles":  []string{"admin"},
+		"https://example.com/tenant": "acme",
+		"email":                      "jane@example.com",
+	}, claims)
+
+	value, ok := ns.Get(claims, "tenant")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", value)
+	assert.Equal(t, map[string]any{"roles": []string{"admin"}, "tenant": "acme"}, ns.Claims(claims))
+
+	var roles []string
+	ok, err := ns.Decode(claims, "roles", &roles)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"admin"}, roles)
+
+	ok, err = ns.Decode(claims, "missing", &roles)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	var number int
+	_, err = ns.Decode(claims, "tenant", &number)
+	assert.ErrorContains(t, err, "https://example.com/tenant")
+}
@@ -3,6 +3,7 @@ package oidc
 import (
 	"crypto/sha256"
 
+	"github.com/lmindwarel/oidc/v3/internal/security"
 	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 )
 
@@ -29,5 +30,5 @@ func VerifyCodeChallenge(c *CodeChallenge, codeVerifier string) bool {
 	if c.Method == CodeChallengeMethodS256 {
 		codeVerifier = NewSHACodeChallenge(codeVerifier)
 	}
-	return codeVerifier == c.Challenge
+	return security.Equal(codeVerifier, c.Challenge)
 }
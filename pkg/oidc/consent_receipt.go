@@ -0,0 +1,64 @@
+package oidc
+
+// ConsentReceiptType is the `typ` header of signed consent receipts.
+const ConsentReceiptType = "consent-receipt+jwt"
+
+// ScopeConsentReceipts is the scope required by the access token
+// of the end-user to export the consent receipts of the user.
+const ScopeConsentReceipts = "consent_receipts"
+
+// ConsentReceipt records the authorization granted by an end-user to a client:
+// what was granted, when and to whom. It is exported to the user signed by the
+// OpenID Provider as JWT, which can be verified with the keys of the provider.
+type ConsentReceipt struct {
+	// ID identifies the receipt.
+	ID      string `json:"jti"`
+	Issuer  string `json:"iss"`
+	Subject string `json:"sub"`
+	// ClientID is the client_id of the client the authorization was granted to.
+	ClientID string              `json:"client_id"`
+	Scopes   SpaceDelimitedArray `json:"scope,omitempty"`
+	// Claims are the claims released by the granted scopes, see [ScopeClaims].
+	Claims               []string             `json:"claims,omitempty"`
+	AuthorizationDetails AuthorizationDetails `json:"authorization_details,omitempty"`
+	GrantedAt            Time                 `json:"granted_at"`
+	// IssuedAt is the time the receipt was signed.
+	IssuedAt Time `json:"iat,omitempty"`
+}
+
+// ConsentReceiptsRequest is the request of the end-user to the consent receipts
+// endpoint, authenticated with an access token of the user with the
+// [ScopeConsentReceipts] scope.
+type ConsentReceiptsRequest struct {
+	AccessToken string `schema:"access_token"`
+	// ClientID restricts the receipts to the ones of the client, if set.
+	ClientID string `schema:"client_id"`
+}
+
+// ConsentReceiptsResponse is the response of the consent receipts endpoint
+// with the signed receipts of the user.
+type ConsentReceiptsResponse struct {
+	Receipts []string `json:"receipts"`
+}
+
+// scopeClaims are the claims requested by the scopes of
+// OpenID Connect Core 1.0, section 5.4.
+var scopeClaims = map[string][]string{
+	ScopeProfile: {
+		"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username",
+		"profile", "picture", "website", "gender", "birthdate", "zoneinfo", "locale", "updated_at",
+	},
+	ScopeEmail:   {"email", "email_verified"},
+	ScopeAddress: {"address"},
+	ScopePhone:   {"phone_number", "phone_number_verified"},
+}
+
+// ScopeClaims returns the standard claims requested by the scopes,
+// as defined by OpenID Connect Core 1.0, section 5.4.
+func ScopeClaims(scopes []string) []string {
+	var claims []string
+	for _, scope := range scopes {
+		claims = append(claims, scopeClaims[scope]...)
+	}
+	return claims
+}
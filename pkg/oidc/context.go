@@ -0,0 +1,50 @@
+package oidc
+
+import "context"
+
+type identityKey struct{}
+
+// identity is the authenticated identity set by [ContextWithClaims].
+type identity struct {
+	subject string
+	claims  any
+}
+
+// ContextWithSubject returns a new context carrying the subject
+// of the authenticated identity.
+func ContextWithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, identityKey{}, &identity{subject: subject})
+}
+
+// ContextWithClaims returns a new context carrying the subject and the
+// validated claims of the authenticated identity.
+//
+// It is used by the middleware of the rs and rp packages and by the
+// userinfo endpoint of the op package, before calling the Storage,
+// so the identity flows through the application layers.
+func ContextWithClaims[T any](ctx context.Context, subject string, claims T) context.Context {
+	return context.WithValue(ctx, identityKey{}, &identity{subject: subject, claims: claims})
+}
+
+// SubjectFromContext returns the subject of the authenticated identity,
+// set by [ContextWithSubject] or [ContextWithClaims].
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey{}).(*identity)
+	if !ok {
+		return "", false
+	}
+	return id.subject, true
+}
+
+// ClaimsFromContext returns the claims of the authenticated identity,
+// set by [ContextWithClaims]. It returns false if no claims are set,
+// or they are not of type T.
+func ClaimsFromContext[T any](ctx context.Context) (T, bool) {
+	id, ok := ctx.Value(identityKey{}).(*identity)
+	if !ok {
+		var nilClaims T
+		return nilClaims, false
+	}
+	claims, ok := id.claims.(T)
+	return claims, ok
+}
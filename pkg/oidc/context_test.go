@@ -0,0 +1,34 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimsFromContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := SubjectFromContext(ctx)
+	assert.False(t, ok)
+	_, ok = ClaimsFromContext[*IDTokenClaims](ctx)
+	assert.False(t, ok)
+
+	subjectCtx := ContextWithSubject(ctx, "user")
+	subject, ok := SubjectFromContext(subjectCtx)
+	assert.True(t, ok)
+	assert.Equal(t, "user", subject)
+	_, ok = ClaimsFromContext[*IDTokenClaims](subjectCtx)
+	assert.False(t, ok)
+
+	claims := &IDTokenClaims{TokenClaims: TokenClaims{Subject: "user"}}
+	claimsCtx := ContextWithClaims(ctx, claims.Subject, claims)
+	subject, ok = SubjectFromContext(claimsCtx)
+	assert.True(t, ok)
+	assert.Equal(t, "user", subject)
+	got, ok := ClaimsFromContext[*IDTokenClaims](claimsCtx)
+	assert.True(t, ok)
+	assert.Same(t, claims, got)
+	_, ok = ClaimsFromContext[*AccessTokenClaims](claimsCtx)
+	assert.False(t, ok, "wrong type")
+}
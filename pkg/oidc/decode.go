@@ -0,0 +1,83 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TokenHeader is the JOSE header of a JWT.
+type TokenHeader struct {
+	Algorithm   string `json:"alg,omitempty"`
+	KeyID       string `json:"kid,omitempty"`
+	Type        string `json:"typ,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+
+	// Raw contains all header parameters, including the ones above.
+	Raw map[string]any `json:"-"`
+}
+
+// DecodeUnverified decodes the header and claims of a JWT
+// WITHOUT verifying its signature or any claim.
+//
+// The result must not be trusted. It is meant for logging, debugging
+// and routing decisions before the full verification, such as selecting
+// the verifier of a tenant by the `iss` claim.
+// T may be a claims struct (pointer), such as *IDTokenClaims, or a map[string]any.
+func DecodeUnverified[T any](token string) (*TokenHeader, T, error) {
+	var claims T
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, claims, fmt.Errorf("%w: token contains an invalid number of segments", ErrParse)
+	}
+	header := new(TokenHeader)
+	if err := decodeSegment(parts[0], &header.Raw); err != nil {
+		return nil, claims, fmt.Errorf("%w: malformed jwt header: %v", ErrParse, err)
+	}
+	if err := decodeSegment(parts[0], header); err != nil {
+		return nil, claims, fmt.Errorf("%w: malformed jwt header: %v", ErrParse, err)
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, claims, fmt.Errorf("%w: malformed jwt payload: %v", ErrParse, err)
+	}
+	return header, claims, nil
+}
+
+// UnverifiedIssuer returns the `iss` claim of a JWT, WITHOUT verification.
+// See [DecodeUnverified].
+func UnverifiedIssuer(token string) (string, error) {
+	_, claims, err := DecodeUnverified[struct {
+		Issuer string `json:"iss"`
+	}](token)
+	return claims.Issuer, err
+}
+
+// PrettyToken decodes a JWT WITHOUT verification and returns its header
+// and claims as indented JSON, for logging and debugging.
+// The token signature is omitted, so the output cannot be replayed as token.
+func PrettyToken(token string) (string, error) {
+	header, claims, err := DecodeUnverified[map[string]any](token)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"header": header.Raw,
+		"claims": claims,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,40 @@
+package oidc
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeUnverified(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	token := encode(`{"alg":"RS256","kid":"1","typ":"JWT","x":"y"}`) + "." +
+		encode(`{"iss":"https://issuer.com","sub":"user","aud":"client","exp":1700000000}`) + ".sig"
+
+	header, claims, err := DecodeUnverified[*IDTokenClaims](token)
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", header.Algorithm)
+	assert.Equal(t, "1", header.KeyID)
+	assert.Equal(t, "JWT", header.Type)
+	assert.Equal(t, "y", header.Raw["x"])
+	assert.Equal(t, "https://issuer.com", claims.Issuer)
+	assert.Equal(t, Audience{"client"}, claims.Audience)
+
+	issuer, err := UnverifiedIssuer(token)
+	require.NoError(t, err)
+	assert.Equal(t, "https://issuer.com", issuer)
+
+	pretty, err := PrettyToken(token)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"header": {"alg":"RS256","kid":"1","typ":"JWT","x":"y"},
+		"claims": {"iss":"https://issuer.com","sub":"user","aud":"client","exp":1700000000}
+	}`, pretty)
+
+	for _, invalid := range []string{"foo", "~." + encode("{}") + ".sig", encode("{}") + ".~.sig"} {
+		_, _, err = DecodeUnverified[map[string]any](invalid)
+		assert.ErrorIs(t, err, ErrParse, invalid)
+	}
+}
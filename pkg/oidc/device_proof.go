@@ -0,0 +1,37 @@
+package oidc
+
+import (
+	"crypto/sha256"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+const (
+	// DeviceProofHeader is the http header of token requests
+	// carrying the device proof JWT.
+	DeviceProofHeader = "Device-Proof"
+	// DeviceProofType is the `typ` header of device proof JWTs.
+	DeviceProofType = "device-proof+jwt"
+)
+
+// DeviceProofClaims are the claims of a device proof, a JWT signed by
+// a key held by the device (e.g. in a TPM or secure enclave), used to
+// bind refresh tokens to the device, similar to Device Bound Session Credentials (DBSC).
+//
+// The public key is embedded as `jwk` in the JWT header.
+// On the authorization_code grant, the proof registers the key;
+// on the refresh_token grant, the proof must be signed by the registered key
+// and contain the hash of the refresh token.
+type DeviceProofClaims struct {
+	JWTID    string   `json:"jti"`
+	IssuedAt Time     `json:"iat"`
+	Audience Audience `json:"aud"`
+	// RefreshTokenHash is the base64url encoded SHA-256 hash of the refresh token,
+	// required on the refresh_token grant.
+	RefreshTokenHash string `json:"rth,omitempty"`
+}
+
+// RefreshTokenHash returns the value of the `rth` claim for the refresh token.
+func RefreshTokenHash(refreshToken string) string {
+	return crypto.HashString(sha256.New(), refreshToken, false)
+}
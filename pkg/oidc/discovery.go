@@ -28,6 +28,44 @@ type DiscoveryConfiguration struct {
 
 	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
 
+	// PushedAuthorizationRequestEndpoint is the URL of the Pushed Authorization Request endpoint (RFC 9126, section 5).
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint,omitempty"`
+
+	// RequirePushedAuthorizationRequests specifies whether the OP only accepts auth requests pushed to the
+	// PushedAuthorizationRequestEndpoint. If omitted, the default value is false.
+	RequirePushedAuthorizationRequests bool `json:"require_pushed_authorization_requests,omitempty"`
+
+	// BackchannelAuthenticationEndpoint is the URL of the Backchannel Authentication endpoint
+	// of the Client-Initiated Backchannel Authentication Flow (CIBA, section 4).
+	BackchannelAuthenticationEndpoint string `json:"backchannel_authentication_endpoint,omitempty"`
+
+	// BackchannelTokenDeliveryModesSupported contains a list of the CIBA token delivery modes ("poll", "ping") supported by the OP.
+	BackchannelTokenDeliveryModesSupported []BackchannelTokenDeliveryMode `json:"backchannel_token_delivery_modes_supported,omitempty"`
+
+	// BackchannelUserCodeParameterSupported specifies whether the OP supports the user_code parameter
+	// of the Backchannel Authentication Request. If omitted, the default value is false.
+	BackchannelUserCodeParameterSupported bool `json:"backchannel_user_code_parameter_supported,omitempty"`
+
+	// SessionTransferEndpoint is the URL where first-party clients obtain session transfer tokens,
+	// which their native apps redeem with the session transfer grant.
+	SessionTransferEndpoint string `json:"session_transfer_endpoint,omitempty"`
+
+	// ConsentReceiptsEndpoint is the URL where end-users export the signed receipts
+	// of the authorizations granted by them, authenticated with an access token.
+	ConsentReceiptsEndpoint string `json:"consent_receipts_endpoint,omitempty"`
+
+	// MTLSEndpointAliases contains the endpoints to be used by clients authenticating
+	// with mutual-TLS, instead of the conventional endpoints (RFC 8705, section 5).
+	MTLSEndpointAliases *MTLSEndpointAliases `json:"mtls_endpoint_aliases,omitempty"`
+
+	// TLSClientCertificateBoundAccessTokens indicates support for access tokens
+	// bound to the client certificate of the mutual-TLS connection (RFC 8705, section 3.3).
+	TLSClientCertificateBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+
+	// AuthorizationDetailsTypesSupported contains a list of the authorization details types
+	// supported by the OP for Rich Authorization Requests (RFC 9396, section 10).
+	AuthorizationDetailsTypesSupported []string `json:"authorization_details_types_supported,omitempty"`
+
 	// CheckSessionIframe is a URL where the OP provides an iframe that support cross-origin communications for session state information with the RP Client.
 	CheckSessionIframe string `json:"check_session_iframe,omitempty"`
 
@@ -78,6 +116,13 @@ type DiscoveryConfiguration struct {
 	// These algorithms are used both then the Request Object is passed by value (using the request parameter) and when it is passed by reference (using the request_uri parameter).
 	RequestObjectSigningAlgValuesSupported []string `json:"request_object_signing_alg_values_supported,omitempty"`
 
+	// DPoPSigningAlgValuesSupported contains a list of JWS signing algorithms (alg values) supported by the OP for DPoP proofs (RFC 9449).
+	DPoPSigningAlgValuesSupported []string `json:"dpop_signing_alg_values_supported,omitempty"`
+
+	// AuthorizationSigningAlgValuesSupported contains a list of JWS signing algorithms (alg values) supported by the OP
+	// for the authorization responses of the JWT response modes (JARM).
+	AuthorizationSigningAlgValuesSupported []string `json:"authorization_signing_alg_values_supported,omitempty"`
+
 	// RequestObjectEncryptionAlgValuesSupported contains a list of JWE encryption algorithms (alg values) supported by the OP for Request Objects.
 	// These algorithms are used both when the Request Object is passed by value and by reference.
 	RequestObjectEncryptionAlgValuesSupported []string `json:"request_object_encryption_alg_values_supported,omitempty"`
@@ -153,6 +198,21 @@ type DiscoveryConfiguration struct {
 	// BackChannelLogoutSessionSupported specifies whether the OP can pass a sid (session ID) Claim in the Logout Token to identify the RP session with the OP.
 	// If supported, the sid Claim is also included in ID Tokens issued by the OP. If omitted, the default value is false.
 	BackChannelLogoutSessionSupported bool `json:"backchannel_logout_session_supported,omitempty"`
+
+	// SignedMetadata is a JWT signed by the OP, containing metadata values as claims and the issuer as iss claim.
+	// Values of the signed metadata take precedence over the plain values, see [VerifySignedMetadata].
+	// [RFC 8414, Section 2.1: Signed Authorization Server Metadata](https://www.rfc-editor.org/rfc/rfc8414#section-2.1)
+	SignedMetadata string `json:"signed_metadata,omitempty"`
+}
+
+// MTLSEndpointAliases are the alternative endpoints for mutual-TLS clients,
+// as defined in RFC 8705, section 5. Empty endpoints are not aliased.
+type MTLSEndpointAliases struct {
+	TokenEndpoint               string `json:"token_endpoint,omitempty"`
+	IntrospectionEndpoint       string `json:"introspection_endpoint,omitempty"`
+	UserinfoEndpoint            string `json:"userinfo_endpoint,omitempty"`
+	RevocationEndpoint          string `json:"revocation_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
 }
 
 type AuthMethod string
@@ -162,8 +222,16 @@ const (
 	AuthMethodPost          AuthMethod = "client_secret_post"
 	AuthMethodNone          AuthMethod = "none"
 	AuthMethodPrivateKeyJWT AuthMethod = "private_key_jwt"
+
+	// AuthMethodTLSClientAuth authenticates the client by its PKI certificate
+	// of the mutual-TLS connection (RFC 8705, section 2.1).
+	AuthMethodTLSClientAuth AuthMethod = "tls_client_auth"
+	// AuthMethodSelfSignedTLSClientAuth authenticates the client by its self-signed
+	// certificate of the mutual-TLS connection (RFC 8705, section 2.2).
+	AuthMethodSelfSignedTLSClientAuth AuthMethod = "self_signed_tls_client_auth"
 )
 
 var AllAuthMethods = []AuthMethod{
 	AuthMethodBasic, AuthMethodPost, AuthMethodNone, AuthMethodPrivateKeyJWT,
+	AuthMethodTLSClientAuth, AuthMethodSelfSignedTLSClientAuth,
 }
@@ -0,0 +1,162 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// DPoPHeader is the http header carrying the DPoP proof JWT (RFC 9449).
+	DPoPHeader = "DPoP"
+	// DPoPNonceHeader is the http header of a server provided DPoP nonce.
+	DPoPNonceHeader = "DPoP-Nonce"
+	// DPoPType is the `typ` header of DPoP proof JWTs.
+	DPoPType = "dpop+jwt"
+	// DPoPTokenType is the token type of DPoP-bound access tokens,
+	// used as scheme of the Authorization header.
+	DPoPTokenType = "DPoP"
+	// PrefixDPoP is the prefix of the Authorization header of requests with DPoP-bound access tokens.
+	PrefixDPoP = DPoPTokenType + " "
+)
+
+// DPoPClaims are the claims of a DPoP proof JWT, as defined in RFC 9449, section 4.2.
+// The public key is embedded as `jwk` in the JWT header.
+type DPoPClaims struct {
+	JWTID      string `json:"jti"`
+	HTTPMethod string `json:"htm"`
+	HTTPURI    string `json:"htu"`
+	IssuedAt   Time   `json:"iat"`
+	// AccessTokenHash is the base64url encoded SHA-256 hash of the access token,
+	// required when presenting an access token to a resource server.
+	AccessTokenHash string `json:"ath,omitempty"`
+	Nonce           string `json:"nonce,omitempty"`
+}
+
+// DPoPAccessTokenHash returns the value of the `ath` claim for the access token.
+func DPoPAccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Confirmation is the `cnf` claim of sender-constrained access tokens (RFC 7800).
+type Confirmation struct {
+	// JWKThumbprint is the base64url encoded SHA-256 JWK Thumbprint (RFC 7638)
+	// of the DPoP key the access token is bound to, RFC 9449, section 6.
+	JWKThumbprint string `json:"jkt,omitempty"`
+	// CertificateThumbprint is the base64url encoded SHA-256 thumbprint of the
+	// client certificate the access token is bound to, RFC 8705, section 3.1.
+	CertificateThumbprint string `json:"x5t#S256,omitempty"`
+}
+
+// DPoPProof is the verification result of a DPoP proof JWT.
+type DPoPProof struct {
+	// Key is the public key of the client embedded in the proof.
+	Key *jose.JSONWebKey
+	// Thumbprint is the base64url encoded SHA-256 JWK Thumbprint (RFC 7638) of the Key.
+	Thumbprint string
+	Claims     *DPoPClaims
+}
+
+// DPoPAlgorithms are the signature algorithms accepted for DPoP proofs.
+var DPoPAlgorithms = []jose.SignatureAlgorithm{
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.RS256, jose.PS256, jose.EdDSA,
+}
+
+// DPoPProofMaxAge is the maximum time difference between
+// the `iat` of a DPoP proof and the current time.
+const DPoPProofMaxAge = 5 * time.Minute
+
+var (
+	ErrDPoPProofType     = errors.New("dpop proof: invalid typ header")
+	ErrDPoPProofKey      = errors.New("dpop proof: missing or invalid key")
+	ErrDPoPProofMethod   = errors.New("dpop proof: htm does not match the request")
+	ErrDPoPProofURI      = errors.New("dpop proof: htu does not match the request")
+	ErrDPoPProofIssuedAt = errors.New("dpop proof: iat out of range")
+	ErrDPoPProofHash     = errors.New("dpop proof: access token hash does not match")
+	ErrDPoPBinding       = errors.New("dpop proof: key does not match the access token binding")
+)
+
+// VerifyDPoPProof verifies the DPoP proof JWT of a request with the method and uri,
+// RFC 9449, section 4.3. The query and fragment of the uri are ignored.
+// If accessToken is not empty, the proof must contain its hash.
+func VerifyDPoPProof(proof, method, uri, accessToken string) (*DPoPProof, error) {
+	jws, err := jose.ParseSigned(proof, DPoPAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("dpop proof: %w", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("dpop proof: must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != DPoPType {
+		return nil, ErrDPoPProofType
+	}
+	key := header.JSONWebKey
+	if key == nil || !key.IsPublic() {
+		return nil, ErrDPoPProofKey
+	}
+	payload, err := jws.Verify(key)
+	if err != nil {
+		return nil, fmt.Errorf("dpop proof: %w", err)
+	}
+	claims := new(DPoPClaims)
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("dpop proof: %w", err)
+	}
+	if claims.JWTID == "" {
+		return nil, errors.New("dpop proof: jti missing")
+	}
+	if claims.HTTPMethod != method {
+		return nil, ErrDPoPProofMethod
+	}
+	if !dpopURIMatches(claims.HTTPURI, uri) {
+		return nil, ErrDPoPProofURI
+	}
+	if diff := time.Since(claims.IssuedAt.AsTime()); diff > DPoPProofMaxAge || diff < -DPoPProofMaxAge {
+		return nil, ErrDPoPProofIssuedAt
+	}
+	if accessToken != "" && claims.AccessTokenHash != DPoPAccessTokenHash(accessToken) {
+		return nil, ErrDPoPProofHash
+	}
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("dpop proof: %w", err)
+	}
+	return &DPoPProof{
+		Key:        key,
+		Thumbprint: base64.RawURLEncoding.EncodeToString(thumbprint),
+		Claims:     claims,
+	}, nil
+}
+
+// dpopURIMatches compares the htu of the proof with the uri of the request,
+// without query and fragment, RFC 9449, section 4.3, step 9.
+func dpopURIMatches(htu, uri string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return a.Scheme == b.Scheme && a.Host == b.Host && a.Path == b.Path
+}
+
+// VerifyDPoPBinding checks the proof was signed with the key
+// the access token is bound to by the confirmation.
+func VerifyDPoPBinding(proof *DPoPProof, cnf *Confirmation) error {
+	if cnf == nil || cnf.JWKThumbprint == "" || cnf.JWKThumbprint != proof.Thumbprint {
+		return ErrDPoPBinding
+	}
+	return nil
+}
@@ -10,16 +10,20 @@ import (
 type errorType string
 
 const (
-	InvalidRequest       errorType = "invalid_request"
-	InvalidScope         errorType = "invalid_scope"
-	InvalidClient        errorType = "invalid_client"
-	InvalidGrant         errorType = "invalid_grant"
-	UnauthorizedClient   errorType = "unauthorized_client"
-	UnsupportedGrantType errorType = "unsupported_grant_type"
-	ServerError          errorType = "server_error"
-	InteractionRequired  errorType = "interaction_required"
-	LoginRequired        errorType = "login_required"
-	RequestNotSupported  errorType = "request_not_supported"
+	InvalidRequest           errorType = "invalid_request"
+	InvalidScope             errorType = "invalid_scope"
+	InvalidClient            errorType = "invalid_client"
+	InvalidGrant             errorType = "invalid_grant"
+	UnauthorizedClient       errorType = "unauthorized_client"
+	UnsupportedGrantType     errorType = "unsupported_grant_type"
+	ServerError              errorType = "server_error"
+	InteractionRequired      errorType = "interaction_required"
+	LoginRequired            errorType = "login_required"
+	ConsentRequired          errorType = "consent_required"
+	AccountSelectionRequired errorType = "account_selection_required"
+	RequestNotSupported      errorType = "request_not_supported"
+	RequestURINotSupported   errorType = "request_uri_not_supported"
+	InvalidRequestURI        errorType = "invalid_request_uri"
 
 	// Additional error codes as defined in
 	// https://www.rfc-editor.org/rfc/rfc8628#section-3.5
@@ -29,10 +33,39 @@ const (
 	AccessDenied         errorType = "access_denied"
 	ExpiredToken         errorType = "expired_token"
 
+	// Additional error codes as defined in
+	// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.13
+	// Authentication Error Response
+	ExpiredLoginHintToken errorType = "expired_login_hint_token"
+	UnknownUserID         errorType = "unknown_user_id"
+	MissingUserCode       errorType = "missing_user_code"
+	InvalidUserCode       errorType = "invalid_user_code"
+	InvalidBindingMessage errorType = "invalid_binding_message"
+
+	// Additional error codes as defined in
+	// https://www.rfc-editor.org/rfc/rfc7591#section-3.2.2
+	// Client Registration Error Response
+	InvalidRedirectURI    errorType = "invalid_redirect_uri"
+	InvalidClientMetadata errorType = "invalid_client_metadata"
+
+	// InvalidToken error is returned for invalid registration access tokens
+	// of the client configuration endpoint.
+	// [RFC 7592, Section 2: Client Configuration Endpoint](https://www.rfc-editor.org/rfc/rfc7592#section-2)
+	InvalidToken errorType = "invalid_token"
+
 	// InvalidTarget error is returned by Token Exchange if
 	// the requested target or audience is invalid.
 	// [RFC 8693, Section 2.2.2: Error Response](https://www.rfc-editor.org/rfc/rfc8693#section-2.2.2)
 	InvalidTarget errorType = "invalid_target"
+
+	// InvalidDPoPProof error is returned if the DPoP proof of a request is invalid.
+	// [RFC 9449, Section 5: DPoP Access Token Request](https://www.rfc-editor.org/rfc/rfc9449#section-5)
+	InvalidDPoPProof errorType = "invalid_dpop_proof"
+
+	// InvalidAuthorizationDetails error is returned if the authorization details
+	// of a request are unknown, malformed or not granted.
+	// [RFC 9396, Section 5: Authorization Error Response](https://www.rfc-editor.org/rfc/rfc9396#section-5)
+	InvalidAuthorizationDetails errorType = "invalid_authorization_details"
 )
 
 var (
@@ -87,11 +120,33 @@ var (
 			ErrorType: LoginRequired,
 		}
 	}
+	ErrConsentRequired = func() *Error {
+		return &Error{
+			ErrorType: ConsentRequired,
+		}
+	}
+	ErrAccountSelectionRequired = func() *Error {
+		return &Error{
+			ErrorType: AccountSelectionRequired,
+		}
+	}
 	ErrRequestNotSupported = func() *Error {
 		return &Error{
 			ErrorType: RequestNotSupported,
 		}
 	}
+	ErrRequestURINotSupported = func() *Error {
+		return &Error{
+			ErrorType:        RequestURINotSupported,
+			redirectDisabled: true,
+		}
+	}
+	ErrInvalidRequestURI = func() *Error {
+		return &Error{
+			ErrorType:        InvalidRequestURI,
+			redirectDisabled: true,
+		}
+	}
 
 	// Device Access Token errors:
 	ErrAuthorizationPending = func() *Error {
@@ -119,6 +174,62 @@ var (
 		}
 	}
 
+	// Backchannel Authentication errors:
+	ErrExpiredLoginHintToken = func() *Error {
+		return &Error{
+			ErrorType:   ExpiredLoginHintToken,
+			Description: "The \"login_hint_token\" has expired.",
+		}
+	}
+	ErrUnknownUserID = func() *Error {
+		return &Error{
+			ErrorType:   UnknownUserID,
+			Description: "The end-user could not be identified by the provided hint.",
+		}
+	}
+	ErrMissingUserCode = func() *Error {
+		return &Error{
+			ErrorType:   MissingUserCode,
+			Description: "The \"user_code\" is required, but was not provided.",
+		}
+	}
+	ErrInvalidUserCode = func() *Error {
+		return &Error{
+			ErrorType:   InvalidUserCode,
+			Description: "The \"user_code\" is invalid.",
+		}
+	}
+	ErrInvalidBindingMessage = func() *Error {
+		return &Error{
+			ErrorType:   InvalidBindingMessage,
+			Description: "The \"binding_message\" is invalid or unacceptable.",
+		}
+	}
+	ErrExpiredAuthReqID = func() *Error {
+		return &Error{
+			ErrorType:   ExpiredToken,
+			Description: "The \"auth_req_id\" has expired.",
+		}
+	}
+
+	// Client Registration errors:
+	ErrInvalidRedirectURI = func() *Error {
+		return &Error{
+			ErrorType: InvalidRedirectURI,
+		}
+	}
+	ErrInvalidClientMetadata = func() *Error {
+		return &Error{
+			ErrorType: InvalidClientMetadata,
+		}
+	}
+	ErrInvalidToken = func() *Error {
+		return &Error{
+			ErrorType:   InvalidToken,
+			Description: "The access token is invalid.",
+		}
+	}
+
 	// Token exchange error
 	ErrInvalidTarget = func() *Error {
 		return &Error{
@@ -126,6 +237,20 @@ var (
 			Description: "The requested audience or target is invalid.",
 		}
 	}
+
+	// DPoP error
+	ErrInvalidDPoPProof = func() *Error {
+		return &Error{
+			ErrorType: InvalidDPoPProof,
+		}
+	}
+
+	// Rich Authorization Requests error
+	ErrInvalidAuthorizationDetails = func() *Error {
+		return &Error{
+			ErrorType: InvalidAuthorizationDetails,
+		}
+	}
 )
 
 type Error struct {
@@ -0,0 +1,202 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Explanation is the trace of a token verification, collected in explain mode.
+// It lists every check performed, with the values examined and its outcome,
+// followed by the final decision.
+//
+// Explain mode is enabled per verification by passing the context
+// returned by [WithExplanation] to the verifier:
+//
+//	ctx, explanation := oidc.WithExplanation(ctx)
+//	_, err := rp.VerifyIDToken[*oidc.IDTokenClaims](ctx, token, verifier)
+//	log.Println(explanation)
+type Explanation struct {
+	mu      sync.Mutex
+	checks  []ExplainedCheck
+	err     error
+	decided bool
+}
+
+// ExplainedCheck is a single check of an [Explanation].
+type ExplainedCheck struct {
+	// Name of the check, e.g. the claim name ("iss", "aud", "exp", ...),
+	// "signature" or "key" for each key tried.
+	Name string
+	// Values examined by the check, such as the expected and actual claim values.
+	Values map[string]any
+	// Err is the outcome of the check, nil if passed.
+	Err error
+}
+
+// Passed reports whether the check succeeded.
+func (c ExplainedCheck) Passed() bool {
+	return c.Err == nil
+}
+
+func (c ExplainedCheck) MarshalJSON() ([]byte, error) {
+	check := struct {
+		Name   string         `json:"name"`
+		Values map[string]any `json:"values,omitempty"`
+		Passed bool           `json:"passed"`
+		Error  string         `json:"error,omitempty"`
+	}{
+		Name:   c.Name,
+		Values: c.Values,
+		Passed: c.Passed(),
+	}
+	if c.Err != nil {
+		check.Error = c.Err.Error()
+	}
+	return json.Marshal(check)
+}
+
+type explanationKey struct{}
+
+// WithExplanation enables explain mode for verifications using the returned context.
+// The returned Explanation is filled by the verifier.
+func WithExplanation(ctx context.Context) (context.Context, *Explanation) {
+	e := new(Explanation)
+	return context.WithValue(ctx, explanationKey{}, e), e
+}
+
+// ExplanationFromContext returns the Explanation set by [WithExplanation],
+// or nil if explain mode is not enabled.
+// All methods of Explanation are safe to call on nil.
+func ExplanationFromContext(ctx context.Context) *Explanation {
+	e, _ := ctx.Value(explanationKey{}).(*Explanation)
+	return e
+}
+
+// Check records a check with its outcome err and the examined values,
+// as alternating keys and values. It returns err unchanged.
+func (e *Explanation) Check(name string, err error, keyValues ...any) error {
+	if e == nil {
+		return err
+	}
+	check := ExplainedCheck{Name: name, Err: err}
+	if len(keyValues) > 0 {
+		check.Values = make(map[string]any, len(keyValues)/2)
+		for i := 0; i < len(keyValues); i += 2 {
+			key := fmt.Sprint(keyValues[i])
+			if i+1 < len(keyValues) {
+				check.Values[key] = keyValues[i+1]
+			} else {
+				check.Values[key] = nil
+			}
+		}
+	}
+	e.mu.Lock()
+	e.checks = append(e.checks, check)
+	e.mu.Unlock()
+	return err
+}
+
+// Decide records the final decision of the verification, nil if the token is valid.
+// The last decision wins, so an outer verification (e.g. of an ID token
+// and its access token) overrides the decision of the nested one.
+func (e *Explanation) Decide(err error) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err, e.decided = err, true
+}
+
+// merge appends the checks and decision of a nested explanation.
+func (e *Explanation) merge(other *Explanation) {
+	if e == nil || other == nil {
+		return
+	}
+	checks := other.Checks()
+	other.mu.Lock()
+	err, decided := other.err, other.decided
+	other.mu.Unlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.checks = append(e.checks, checks...)
+	if decided {
+		e.err, e.decided = err, true
+	}
+}
+
+// Checks returns the recorded checks in the order they were performed.
+func (e *Explanation) Checks() []ExplainedCheck {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]ExplainedCheck(nil), e.checks...)
+}
+
+// Err returns the error of the final decision, nil if the token was accepted.
+func (e *Explanation) Err() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Valid reports whether a decision was made and the token was accepted.
+func (e *Explanation) Valid() bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.decided && e.err == nil
+}
+
+func (e *Explanation) MarshalJSON() ([]byte, error) {
+	explanation := struct {
+		Checks   []ExplainedCheck `json:"checks"`
+		Decision string           `json:"decision"`
+		Error    string           `json:"error,omitempty"`
+	}{
+		Checks:   e.Checks(),
+		Decision: e.decision(),
+	}
+	if err := e.Err(); err != nil {
+		explanation.Error = err.Error()
+	}
+	return json.Marshal(explanation)
+}
+
+func (e *Explanation) decision() string {
+	switch {
+	case e.Valid():
+		return "accepted"
+	case e.Err() != nil:
+		return "rejected"
+	default:
+		return "undecided"
+	}
+}
+
+// String returns a human readable trace, one check per line.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	for _, c := range e.Checks() {
+		outcome := "ok"
+		if !c.Passed() {
+			outcome = "failed: " + c.Err.Error()
+		}
+		fmt.Fprintf(&b, "%s %v: %s\n", c.Name, c.Values, outcome)
+	}
+	b.WriteString(e.decision())
+	if err := e.Err(); err != nil {
+		b.WriteString(": " + err.Error())
+	}
+	return b.String()
+}
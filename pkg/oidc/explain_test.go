@@ -0,0 +1,38 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplanation(t *testing.T) {
+	var disabled *Explanation
+	err := errors.New("fail")
+	assert.Equal(t, err, disabled.Check("iss", err))
+	assert.Nil(t, ExplanationFromContext(context.Background()))
+
+	ctx, explanation := WithExplanation(context.Background())
+	require.Same(t, explanation, ExplanationFromContext(ctx))
+	assert.NoError(t, explanation.Check("sub", nil, "actual", "user"))
+	assert.Equal(t, err, explanation.Check("iss", err, "expected", "a", "actual", "b"))
+	explanation.Decide(err)
+
+	assert.False(t, explanation.Valid())
+	assert.Equal(t, "sub map[actual:user]: ok\niss map[actual:b expected:a]: failed: fail\nrejected: fail", explanation.String())
+
+	data, err := json.Marshal(explanation)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"checks": [
+			{"name": "sub", "values": {"actual": "user"}, "passed": true},
+			{"name": "iss", "values": {"expected": "a", "actual": "b"}, "passed": false, "error": "fail"}
+		],
+		"decision": "rejected",
+		"error": "fail"
+	}`, string(data))
+}
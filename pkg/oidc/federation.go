@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Claim names for federated (linked) identities, used when the OP acts as
+// an identity broker in front of upstream identity providers.
+const (
+	// ClaimIdentityProvider is the upstream identity provider
+	// the user authenticated with in the current session.
+	ClaimIdentityProvider = "idp"
+	// ClaimUpstreamSubject is the subject of the user at
+	// the identity provider of [ClaimIdentityProvider].
+	ClaimUpstreamSubject = "upstream_sub"
+	// ClaimIdentities lists all upstream accounts linked to the user.
+	ClaimIdentities = "identities"
+)
+
+// FederatedIdentity is an account at an upstream identity provider,
+// which is linked to the user.
+type FederatedIdentity struct {
+	// Provider is the name or issuer of the upstream identity provider.
+	Provider string `json:"provider"`
+	// Subject is the `sub` of the user at the upstream identity provider.
+	Subject  string `json:"sub"`
+	Issuer   string `json:"iss,omitempty"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+	LinkedAt Time   `json:"linked_at,omitempty"`
+}
+
+// FederationClaims holds the typed federated identity claims.
+type FederationClaims struct {
+	IdentityProvider string              `json:"idp,omitempty"`
+	UpstreamSubject  string              `json:"upstream_sub,omitempty"`
+	Identities       []FederatedIdentity `json:"identities,omitempty"`
+}
+
+// Identity returns the linked identity of the provider,
+// or nil if the user has no such linked account.
+func (f *FederationClaims) Identity(provider string) *FederatedIdentity {
+	for i := range f.Identities {
+		if f.Identities[i].Provider == provider {
+			return &f.Identities[i]
+		}
+	}
+	return nil
+}
+
+// AppendTo sets the non-empty federation claims in the claims map,
+// which is created if nil.
+func (f *FederationClaims) AppendTo(claims map[string]any) map[string]any {
+	if claims == nil {
+		claims = make(map[string]any, 3)
+	}
+	if f.IdentityProvider != "" {
+		claims[ClaimIdentityProvider] = f.IdentityProvider
+	}
+	if f.UpstreamSubject != "" {
+		claims[ClaimUpstreamSubject] = f.UpstreamSubject
+	}
+	if len(f.Identities) > 0 {
+		claims[ClaimIdentities] = f.Identities
+	}
+	return claims
+}
+
+// FederationFromClaims parses the federation claims out of
+// the additional claims of a token or userinfo response.
+func FederationFromClaims(claims map[string]any) (*FederationClaims, error) {
+	subset := make(map[string]any, 3)
+	for _, k := range []string{ClaimIdentityProvider, ClaimUpstreamSubject, ClaimIdentities} {
+		if v, ok := claims[k]; ok {
+			subset[k] = v
+		}
+	}
+	data, err := json.Marshal(subset)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: federation claims: %w", err)
+	}
+	federation := new(FederationClaims)
+	if err = json.Unmarshal(data, federation); err != nil {
+		return nil, fmt.Errorf("oidc: federation claims: %w", err)
+	}
+	return federation, nil
+}
+
+// SetFederation sets the federation claims, which are also
+// copied into the ID Token by [IDTokenClaims.SetUserInfo].
+func (u *UserInfo) SetFederation(f *FederationClaims) {
+	u.Claims = f.AppendTo(u.Claims)
+}
+
+// GetFederation returns the federation claims of the userinfo response.
+func (u *UserInfo) GetFederation() (*FederationClaims, error) {
+	return FederationFromClaims(u.Claims)
+}
+
+// GetFederation returns the federation claims of the ID Token.
+func (t *IDTokenClaims) GetFederation() (*FederationClaims, error) {
+	return FederationFromClaims(t.Claims)
+}
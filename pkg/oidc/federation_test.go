@@ -0,0 +1,53 @@
+package oidc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserInfo_Federation(t *testing.T) {
+	federation := &FederationClaims{
+		IdentityProvider: "github",
+		UpstreamSubject:  "12345",
+		Identities: []FederatedIdentity{
+			{Provider: "github", Subject: "12345", Username: "octocat", LinkedAt: 1700000000},
+			{Provider: "google", Subject: "abc", Issuer: "https://accounts.google.com"},
+		},
+	}
+	u := &UserInfo{Subject: "user1"}
+	u.SetFederation(federation)
+
+	data, err := json.Marshal(u)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"sub": "user1",
+		"idp": "github",
+		"upstream_sub": "12345",
+		"identities": [
+			{"provider": "github", "sub": "12345", "username": "octocat", "linked_at": 1700000000},
+			{"provider": "google", "sub": "abc", "iss": "https://accounts.google.com"}
+		]
+	}`, string(data))
+
+	got := new(UserInfo)
+	require.NoError(t, json.Unmarshal(data, got))
+	gotFederation, err := got.GetFederation()
+	require.NoError(t, err)
+	assert.Equal(t, federation, gotFederation)
+	assert.Equal(t, "abc", gotFederation.Identity("google").Subject)
+	assert.Nil(t, gotFederation.Identity("gitlab"))
+
+	idToken := new(IDTokenClaims)
+	idToken.SetUserInfo(got)
+	gotFederation, err = idToken.GetFederation()
+	require.NoError(t, err)
+	assert.Equal(t, federation, gotFederation)
+}
+
+func TestFederationFromClaims_Error(t *testing.T) {
+	_, err := FederationFromClaims(map[string]any{ClaimIdentities: "invalid"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// ErrInvalidForm is wrapped by the errors of the MarshalForm methods
+// of requests with missing or invalid parameters, see [FormError].
+var ErrInvalidForm = errors.New("invalid request parameters")
+
+// FormError is returned by the MarshalForm methods of requests for a missing
+// or invalid parameter, before the request is sent to the OP.
+type FormError struct {
+	Parameter string
+	// Value is the invalid value, empty if the parameter is missing.
+	Value string
+}
+
+func (e *FormError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %s is required", ErrInvalidForm, e.Parameter)
+	}
+	return fmt.Sprintf("%s: invalid %s %q", ErrInvalidForm, e.Parameter, e.Value)
+}
+
+func (e *FormError) Unwrap() error {
+	return ErrInvalidForm
+}
+
+// FormRequired returns a [FormError], if the value of the parameter is empty.
+func FormRequired[T ~string](parameter string, value T) error {
+	if value == "" {
+		return &FormError{Parameter: parameter}
+	}
+	return nil
+}
+
+// FormOneOf returns a [FormError], if the value of the parameter is set
+// and not one of the allowed values.
+func FormOneOf[T ~string](parameter string, value T, allowed ...T) error {
+	if value != "" && !slices.Contains(allowed, value) {
+		return &FormError{Parameter: parameter, Value: string(value)}
+	}
+	return nil
+}
+
+// FormSet sets the parameter of the form, unless the value is empty.
+func FormSet[T ~string](form url.Values, parameter string, value T) {
+	if value != "" {
+		form.Set(parameter, string(value))
+	}
+}
+
+// FormAdd adds the values of the parameter to the form, in order.
+func FormAdd[T ~string](form url.Values, parameter string, values []T) {
+	for _, value := range values {
+		form.Add(parameter, string(value))
+	}
+}
+
+// MarshalForm implements the FormMarshaler of the http package.
+func (r TokenExchangeRequest) MarshalForm(form url.Values) error {
+	if err := errors.Join(
+		FormOneOf("grant_type", r.GrantType, GrantTypeTokenExchange),
+		FormRequired("subject_token", r.SubjectToken),
+		FormRequired("subject_token_type", r.SubjectTokenType),
+		FormOneOf("requested_token_type", r.RequestedTokenType, AllTokenTypes...),
+	); err != nil {
+		return err
+	}
+	if r.ActorToken != "" {
+		if err := FormRequired("actor_token_type", r.ActorTokenType); err != nil {
+			return err
+		}
+	}
+	FormSet(form, "grant_type", GrantTypeTokenExchange)
+	FormSet(form, "subject_token", r.SubjectToken)
+	FormSet(form, "subject_token_type", r.SubjectTokenType)
+	FormSet(form, "actor_token", r.ActorToken)
+	FormSet(form, "actor_token_type", r.ActorTokenType)
+	FormAdd(form, "resource", r.Resource)
+	FormAdd(form, "audience", r.Audience)
+	FormSet(form, "scope", r.Scopes.String())
+	FormSet(form, "requested_token_type", r.RequestedTokenType)
+	return nil
+}
+
+// MarshalForm implements the FormMarshaler of the http package.
+func (r JWTProfileGrantRequest) MarshalForm(form url.Values) error {
+	if err := errors.Join(
+		FormOneOf("grant_type", r.GrantType, GrantTypeBearer),
+		FormRequired("assertion", r.Assertion),
+	); err != nil {
+		return err
+	}
+	FormSet(form, "grant_type", GrantTypeBearer)
+	FormSet(form, "assertion", r.Assertion)
+	FormSet(form, "scope", r.Scope.String())
+	return nil
+}
+
+// MarshalForm implements the FormMarshaler of the http package.
+func (r IntrospectionRequest) MarshalForm(form url.Values) error {
+	if err := FormRequired("token", r.Token); err != nil {
+		return err
+	}
+	form.Set("token", r.Token)
+	return nil
+}
@@ -0,0 +1,114 @@
+package oidc
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenExchangeRequest_MarshalForm(t *testing.T) {
+	tests := []struct {
+		name    string
+		request TokenExchangeRequest
+		want    url.Values
+		wantErr *FormError
+	}{
+		{
+			name: "valid",
+			request: TokenExchangeRequest{
+				SubjectToken:       "subject",
+				SubjectTokenType:   AccessTokenType,
+				Audience:           Audience{"api1", "api2"},
+				Scopes:             SpaceDelimitedArray{"read", "write"},
+				RequestedTokenType: AccessTokenType,
+			},
+			want: url.Values{
+				"grant_type":           {string(GrantTypeTokenExchange)},
+				"subject_token":        {"subject"},
+				"subject_token_type":   {string(AccessTokenType)},
+				"audience":             {"api1", "api2"},
+				"scope":                {"read write"},
+				"requested_token_type": {string(AccessTokenType)},
+			},
+		},
+		{
+			name:    "missing subject token",
+			request: TokenExchangeRequest{SubjectTokenType: AccessTokenType},
+			wantErr: &FormError{Parameter: "subject_token"},
+		},
+		{
+			name: "invalid grant type",
+			request: TokenExchangeRequest{
+				GrantType:        GrantTypeCode,
+				SubjectToken:     "subject",
+				SubjectTokenType: AccessTokenType,
+			},
+			wantErr: &FormError{Parameter: "grant_type", Value: string(GrantTypeCode)},
+		},
+		{
+			name: "invalid requested token type",
+			request: TokenExchangeRequest{
+				SubjectToken:       "subject",
+				SubjectTokenType:   AccessTokenType,
+				RequestedTokenType: "urn:example:unknown",
+			},
+			wantErr: &FormError{Parameter: "requested_token_type", Value: "urn:example:unknown"},
+		},
+		{
+			name: "actor token without type",
+			request: TokenExchangeRequest{
+				SubjectToken:     "subject",
+				SubjectTokenType: AccessTokenType,
+				ActorToken:       "actor",
+			},
+			wantErr: &FormError{Parameter: "actor_token_type"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			err := tt.request.MarshalForm(form)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, ErrInvalidForm)
+				var formErr *FormError
+				require.ErrorAs(t, err, &formErr)
+				assert.Equal(t, tt.wantErr, formErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, form)
+		})
+	}
+}
+
+func TestFormError_Error(t *testing.T) {
+	assert.Equal(t, "invalid request parameters: token is required", (&FormError{Parameter: "token"}).Error())
+	assert.Equal(t, `invalid request parameters: invalid grant_type "implicit"`, (&FormError{Parameter: "grant_type", Value: "implicit"}).Error())
+}
+
+func BenchmarkTokenExchangeRequest_MarshalForm(b *testing.B) {
+	request := TokenExchangeRequest{
+		GrantType:        GrantTypeTokenExchange,
+		SubjectToken:     "subject",
+		SubjectTokenType: AccessTokenType,
+		Audience:         Audience{"api"},
+		Scopes:           SpaceDelimitedArray{"read", "write"},
+	}
+	b.Run("MarshalForm", func(b *testing.B) {
+		for range b.N {
+			if err := request.MarshalForm(url.Values{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Encoder", func(b *testing.B) {
+		encoder := NewEncoder()
+		for range b.N {
+			if err := encoder.Encode(request, url.Values{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -18,6 +18,7 @@ type ClientAssertionParams struct {
 type IntrospectionResponse struct {
 	Active                          bool                `json:"active"`
 	Scope                           SpaceDelimitedArray `json:"scope,omitempty"`
+	ScopeArray                      ScopeArray          `json:"scp,omitempty"`
 	ClientID                        string              `json:"client_id,omitempty"`
 	TokenType                       string              `json:"token_type,omitempty"`
 	Expiration                      Time                `json:"exp,omitempty"`
@@ -31,14 +32,25 @@ type IntrospectionResponse struct {
 	JWTID                           string              `json:"jti,omitempty"`
 	Username                        string              `json:"username,omitempty"`
 	Actor                           *ActorClaims        `json:"act,omitempty"`
+	Confirmation                    *Confirmation       `json:"cnf,omitempty"`
+	// SessionID is the sid of the session of the end-user at the OP, if any.
+	SessionID string `json:"sid,omitempty"`
 	UserInfoProfile
 	UserInfoEmail
 	UserInfoPhone
 
+	// AuthorizationDetails are the authorization details granted to the token (RFC 9396, section 9.2).
+	AuthorizationDetails AuthorizationDetails `json:"authorization_details,omitempty"`
+
 	Address *UserInfoAddress `json:"address,omitempty"`
 	Claims  map[string]any   `json:"-"`
 }
 
+// SetActive sets the active member of the response.
+func (i *IntrospectionResponse) SetActive(active bool) {
+	i.Active = active
+}
+
 // SetUserInfo copies all relevant fields from UserInfo
 // into the IntroSpectionResponse.
 func (i *IntrospectionResponse) SetUserInfo(u *UserInfo) {
@@ -55,6 +67,11 @@ func (i *IntrospectionResponse) SetUserInfo(u *UserInfo) {
 	}
 }
 
+// GetIssuer returns the issuer of the introspected token.
+func (i *IntrospectionResponse) GetIssuer() string {
+	return i.Issuer
+}
+
 // GetAddress is a safe getter that takes
 // care of a possible nil value.
 func (i *IntrospectionResponse) GetAddress() *UserInfoAddress {
@@ -74,6 +91,11 @@ func (i *IntrospectionResponse) MarshalJSON() ([]byte, error) {
 	return mergeAndMarshalClaims((*introspectionResponseAlias)(i), i.Claims)
 }
 
+// UnmarshalJSON accepts the scopes from the scope or the scp claim.
 func (i *IntrospectionResponse) UnmarshalJSON(data []byte) error {
-	return unmarshalJSONMulti(data, (*introspectionResponseAlias)(i), &i.Claims)
+	if err := unmarshalJSONMulti(data, (*introspectionResponseAlias)(i), &i.Claims); err != nil {
+		return err
+	}
+	i.Scope = scopesOrArray(i.Scope, i.ScopeArray)
+	return nil
 }
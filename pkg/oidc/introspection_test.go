@@ -77,3 +77,39 @@ func TestIntrospectionResponse_MarshalJSON(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, string(got), `{"active":false,"username":"muhlemmer","preferred_username":"muhlemmer"}`)
 }
+
+func TestIntrospectionResponse_UnmarshalJSON_scopes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want SpaceDelimitedArray
+	}{
+		{
+			name: "scope",
+			data: `{"active":true,"scope":"openid api"}`,
+			want: SpaceDelimitedArray{"openid", "api"},
+		},
+		{
+			name: "scp array",
+			data: `{"active":true,"scp":["openid","api"]}`,
+			want: SpaceDelimitedArray{"openid", "api"},
+		},
+		{
+			name: "scp string",
+			data: `{"active":true,"scp":"openid api"}`,
+			want: SpaceDelimitedArray{"openid", "api"},
+		},
+		{
+			name: "scope precedes scp",
+			data: `{"active":true,"scope":"openid","scp":["api"]}`,
+			want: SpaceDelimitedArray{"openid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got IntrospectionResponse
+			require.NoError(t, json.Unmarshal([]byte(tt.data), &got))
+			assert.Equal(t, tt.want, got.Scope)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package oidc
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Placeholders of issuer templates, see [MatchIssuer].
+const (
+	IssuerTemplateHost = "{host}"
+	IssuerTemplatePort = "{port}"
+)
+
+// IsIssuerTemplate reports whether the issuer contains placeholders.
+func IsIssuerTemplate(issuer string) bool {
+	return strings.Contains(issuer, IssuerTemplateHost) || strings.Contains(issuer, IssuerTemplatePort)
+}
+
+// MatchIssuer reports whether the issuer matches the expected issuer.
+// Unless allowInsecure is set, both must be equal.
+//
+// allowInsecure is meant for development and tests only. Issuers on a loopback
+// host (localhost, 127.0.0.1 or ::1) then match, regardless of the loopback
+// host name used. The expected issuer may further be a template with
+// {host} and {port} placeholders, e.g. "http://localhost:{port}/oidc",
+// to match dynamically allocated addresses.
+func MatchIssuer(expected, issuer string, allowInsecure bool) bool {
+	if expected == issuer {
+		return true
+	}
+	if !allowInsecure {
+		return false
+	}
+	actual, err := url.Parse(issuer)
+	if err != nil || !isLoopback(actual.Hostname()) {
+		return false
+	}
+	expected = strings.NewReplacer(
+		IssuerTemplateHost, actual.Hostname(),
+		IssuerTemplatePort, actual.Port(),
+	).Replace(expected)
+	want, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+	return want.Scheme == actual.Scheme &&
+		isLoopback(want.Hostname()) &&
+		want.Port() == actual.Port() &&
+		strings.TrimSuffix(want.Path, "/") == strings.TrimSuffix(actual.Path, "/")
+}
+
+// ContainsIssuer reports whether the audience contains the issuer,
+// matched by [MatchIssuer].
+func ContainsIssuer(audience []string, issuer string, allowInsecure bool) bool {
+	return slices.ContainsFunc(audience, func(aud string) bool {
+		return MatchIssuer(issuer, aud, allowInsecure)
+	})
+}
+
+// CheckIssuerMatch is like [CheckIssuer], matching the issuer with [MatchIssuer].
+func CheckIssuerMatch(claims Claims, issuer string, allowInsecure bool) error {
+	if !MatchIssuer(issuer, claims.GetIssuer(), allowInsecure) {
+		return fmt.Errorf("%w: Expected: %s, got: %s", ErrIssuerInvalid, issuer, claims.GetIssuer())
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || net.ParseIP(host).IsLoopback()
+}
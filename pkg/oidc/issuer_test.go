@@ -0,0 +1,34 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchIssuer(t *testing.T) {
+	tests := []struct {
+		name          string
+		expected      string
+		issuer        string
+		allowInsecure bool
+		want          bool
+	}{
+		{"equal", "https://issuer.com", "https://issuer.com", false, true},
+		{"different", "https://issuer.com", "https://other.com", true, false},
+		{"loopback alias", "http://localhost:8080", "http://127.0.0.1:8080", true, true},
+		{"loopback alias secure", "http://localhost:8080", "http://127.0.0.1:8080", false, false},
+		{"loopback other port", "http://localhost:8080", "http://127.0.0.1:9090", true, false},
+		{"loopback other scheme", "https://localhost:8080", "http://localhost:8080", true, false},
+		{"port template", "http://localhost:{port}/oidc", "http://127.0.0.1:54321/oidc", true, true},
+		{"port template secure", "http://localhost:{port}/oidc", "http://localhost:54321/oidc", false, false},
+		{"port template other path", "http://localhost:{port}/oidc", "http://localhost:54321/other", true, false},
+		{"host template", "http://{host}:{port}", "http://[::1]:54321", true, true},
+		{"host template not loopback", "http://{host}:{port}", "http://example.com:54321", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MatchIssuer(tt.expected, tt.issuer, tt.allowInsecure))
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package oidc
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// Response modes of the JWT Secured Authorization Response Mode for OAuth 2.0 (JARM).
+// The authorization response is sent as JWT in the response parameter.
+const (
+	// ResponseModeJWT uses the default response mode of the response type,
+	// query.jwt for the code flow and fragment.jwt otherwise.
+	ResponseModeJWT         ResponseMode = "jwt"
+	ResponseModeQueryJWT    ResponseMode = "query.jwt"
+	ResponseModeFragmentJWT ResponseMode = "fragment.jwt"
+	ResponseModeFormPostJWT ResponseMode = "form_post.jwt"
+)
+
+// IsJWT reports whether the response mode is one of the JWT response modes (JARM).
+func (m ResponseMode) IsJWT() bool {
+	switch m {
+	case ResponseModeJWT, ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT:
+		return true
+	}
+	return false
+}
+
+// Base returns the response mode the JWT of a JWT response mode is sent with,
+// e.g. query for query.jwt, which is empty for jwt, defaulting to the response type.
+// Other response modes are returned unchanged.
+func (m ResponseMode) Base() ResponseMode {
+	switch m {
+	case ResponseModeJWT:
+		return ""
+	case ResponseModeQueryJWT:
+		return ResponseModeQuery
+	case ResponseModeFragmentJWT:
+		return ResponseModeFragment
+	case ResponseModeFormPostJWT:
+		return ResponseModeFormPost
+	}
+	return m
+}
+
+// JWTAuthorizationResponse is the authorization response of the JWT response modes.
+type JWTAuthorizationResponse struct {
+	Response string `schema:"response"`
+}
+
+// JWTAuthorizationResponseClaims are the claims of the response JWT of the JWT response modes.
+// Params holds the parameters of the authorization response, such as code and state,
+// or error and error_description.
+type JWTAuthorizationResponseClaims struct {
+	Issuer     string         `json:"iss,omitempty"`
+	Audience   Audience       `json:"aud,omitempty"`
+	Expiration Time           `json:"exp,omitempty"`
+	Params     map[string]any `json:"-"`
+
+	SignatureAlg jose.SignatureAlgorithm `json:"-"`
+}
+
+func NewJWTAuthorizationResponseClaims(issuer, clientID string, expiration time.Time, params url.Values) *JWTAuthorizationResponseClaims {
+	claims := &JWTAuthorizationResponseClaims{
+		Issuer:     issuer,
+		Audience:   Audience{clientID},
+		Expiration: FromTime(expiration),
+		Params:     make(map[string]any, len(params)),
+	}
+	for key := range params {
+		claims.Params[key] = params.Get(key)
+	}
+	return claims
+}
+
+// Values returns the parameters of the authorization response,
+// including the iss parameter (RFC 9207).
+func (c *JWTAuthorizationResponseClaims) Values() url.Values {
+	values := make(url.Values, len(c.Params))
+	for key, value := range c.Params {
+		if key == "aud" || key == "exp" {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			values.Set(key, s)
+			continue
+		}
+		values.Set(key, fmt.Sprint(value))
+	}
+	return values
+}
+
+func (c *JWTAuthorizationResponseClaims) GetIssuer() string {
+	return c.Issuer
+}
+
+func (c *JWTAuthorizationResponseClaims) GetSubject() string {
+	return ""
+}
+
+func (c *JWTAuthorizationResponseClaims) GetAudience() []string {
+	return c.Audience
+}
+
+func (c *JWTAuthorizationResponseClaims) GetExpiration() time.Time {
+	return c.Expiration.AsTime()
+}
+
+func (c *JWTAuthorizationResponseClaims) GetIssuedAt() time.Time {
+	return time.Time{}
+}
+
+func (c *JWTAuthorizationResponseClaims) GetNonce() string {
+	return ""
+}
+
+func (c *JWTAuthorizationResponseClaims) GetAuthenticationContextClassReference() string {
+	return ""
+}
+
+func (c *JWTAuthorizationResponseClaims) GetAuthTime() time.Time {
+	return time.Time{}
+}
+
+func (c *JWTAuthorizationResponseClaims) GetAuthorizedParty() string {
+	return ""
+}
+
+func (c *JWTAuthorizationResponseClaims) SetSignatureAlgorithm(algorithm jose.SignatureAlgorithm) {
+	c.SignatureAlg = algorithm
+}
+
+type jarcAlias JWTAuthorizationResponseClaims
+
+func (c *JWTAuthorizationResponseClaims) MarshalJSON() ([]byte, error) {
+	return mergeAndMarshalClaims((*jarcAlias)(c), c.Params)
+}
+
+func (c *JWTAuthorizationResponseClaims) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONMulti(data, (*jarcAlias)(c), &c.Params)
+}
@@ -0,0 +1,45 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseMode_Base(t *testing.T) {
+	tests := []struct {
+		mode  ResponseMode
+		isJWT bool
+		base  ResponseMode
+	}{
+		{ResponseModeQuery, false, ResponseModeQuery},
+		{ResponseModeFormPost, false, ResponseModeFormPost},
+		{ResponseModeJWT, true, ""},
+		{ResponseModeQueryJWT, true, ResponseModeQuery},
+		{ResponseModeFragmentJWT, true, ResponseModeFragment},
+		{ResponseModeFormPostJWT, true, ResponseModeFormPost},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			assert.Equal(t, tt.isJWT, tt.mode.IsJWT())
+			assert.Equal(t, tt.base, tt.mode.Base())
+		})
+	}
+}
+
+func TestJWTAuthorizationResponseClaims(t *testing.T) {
+	exp := time.Unix(1700000000, 0)
+	claims := NewJWTAuthorizationResponseClaims("https://issuer.com", "client1", exp, url.Values{"code": {"code1"}, "state": {"state1"}})
+	data, err := json.Marshal(claims)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"iss":"https://issuer.com","aud":["client1"],"exp":1700000000,"code":"code1","state":"state1"}`, string(data))
+
+	got := new(JWTAuthorizationResponseClaims)
+	require.NoError(t, json.Unmarshal(data, got))
+	assert.Equal(t, "client1", got.GetAudience()[0])
+	assert.Equal(t, url.Values{"iss": {"https://issuer.com"}, "code": {"code1"}, "state": {"state1"}}, got.Values())
+}
@@ -2,13 +2,18 @@ package oidc
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"strings"
 
 	jose "github.com/go-jose/go-jose/v4"
+
+	zcrypto "github.com/lmindwarel/oidc/v3/pkg/crypto"
 )
 
 const (
@@ -18,12 +23,16 @@ const (
 var (
 	ErrKeyMultiple = errors.New("multiple possible keys match")
 	ErrKeyNone     = errors.New("no possible keys matches")
+
+	ErrKeyCertificatesMissing  = errors.New("key has no x5c certificate chain")
+	ErrKeyCertificatesMismatch = errors.New("x5c certificate does not match the key")
 )
 
 // KeySet represents a set of JSON Web Keys
 // - remotely fetch via discovery and jwks_uri -> `remoteKeySet`
 // - held by the OP itself in storage -> `openIDKeySet`
 // - dynamically aggregated by request for OAuth JWT Profile Assertion -> `jwtProfileKeySet`
+// - from the x5c certificate chain of the JWS header -> `certificateKeySet`
 type KeySet interface {
 	// VerifySignature verifies the signature with the given keyset and returns the raw payload
 	VerifySignature(ctx context.Context, jws *jose.JSONWebSignature) (payload []byte, err error)
@@ -93,6 +102,9 @@ func FindMatchingKey(keyID, use, expectedAlg string, keys ...jose.JSONWebKey) (k
 }
 
 func algToKeyType(key any, alg string) bool {
+	if k, ok := key.(zcrypto.MLDSAKey); ok {
+		return string(k.Algorithm()) == alg
+	}
 	if strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS") {
 		_, ok := key.(*rsa.PublicKey)
 		return ok
@@ -107,3 +119,81 @@ func algToKeyType(key any, alg string) bool {
 	}
 	return false
 }
+
+// VerifyKeyCertificates verifies the x5c certificate chain of the key
+// with the options, e.g. against the Roots of a trust framework,
+// and that the leaf certificate belongs to the key.
+// Signing certificates often lack the server authentication extended key usage,
+// which is the default of [x509.VerifyOptions], so the KeyUsages might need to be set.
+func VerifyKeyCertificates(key *jose.JSONWebKey, opts x509.VerifyOptions) error {
+	if len(key.Certificates) == 0 {
+		return ErrKeyCertificatesMissing
+	}
+	if !publicKeyEqual(key.Certificates[0].PublicKey, key.Public().Key) {
+		return ErrKeyCertificatesMismatch
+	}
+	if opts.Intermediates == nil {
+		opts.Intermediates = x509.NewCertPool()
+		for _, cert := range key.Certificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+	if _, err := key.Certificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("x5c certificate chain: %w", err)
+	}
+	return nil
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && b != nil && k.Equal(b)
+}
+
+// NewStaticKeySet returns a KeySet verifying signatures with the public part
+// of the keys, like keys pinned as trust anchors of an OP.
+func NewStaticKeySet(keys ...jose.JSONWebKey) KeySet {
+	set := make(staticKeySet, len(keys))
+	for i, key := range keys {
+		set[i] = key.Public()
+	}
+	return set
+}
+
+type staticKeySet []jose.JSONWebKey
+
+func (k staticKeySet) VerifySignature(_ context.Context, jws *jose.JSONWebSignature) ([]byte, error) {
+	keyID, alg := GetKeyIDAndAlg(jws)
+	key, err := FindMatchingKey(keyID, KeyUseSignature, alg, k...)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(&key)
+}
+
+// NewCertificateKeySet returns a KeySet verifying signatures with the key
+// of the x5c certificate chain in the JWS header, after verifying the chain
+// with the options. See [VerifyKeyCertificates] about the KeyUsages.
+func NewCertificateKeySet(opts x509.VerifyOptions) KeySet {
+	return &certificateKeySet{opts: opts}
+}
+
+type certificateKeySet struct {
+	opts x509.VerifyOptions
+}
+
+func (k *certificateKeySet) VerifySignature(ctx context.Context, jws *jose.JSONWebSignature) ([]byte, error) {
+	if len(jws.Signatures) != 1 {
+		return nil, ErrKeyNone
+	}
+	chains, err := jws.Signatures[0].Protected.Certificates(k.opts)
+	ExplanationFromContext(ctx).Check("x5c", err)
+	if err != nil {
+		return nil, fmt.Errorf("x5c certificate chain: %w", err)
+	}
+	payload, err := jws.Verify(chains[0][0].PublicKey)
+	ExplanationFromContext(ctx).Check("key", err, "source", "x5c")
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+	return payload, nil
+}
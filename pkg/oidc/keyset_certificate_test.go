@@ -0,0 +1,117 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func certPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+func TestVerifyKeyCertificates(t *testing.T) {
+	root, leaf, err := tu.NewCertificateChain()
+	require.NoError(t, err)
+	otherRoot, otherLeaf, err := tu.NewCertificateChain()
+	require.NoError(t, err)
+	opts := x509.VerifyOptions{
+		Roots:     certPool(root),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	key := tu.WebKey.Public()
+
+	tests := []struct {
+		name          string
+		certs         []*x509.Certificate
+		wantErr       error
+		wantUntrusted bool
+	}{
+		{
+			name:  "valid",
+			certs: []*x509.Certificate{leaf},
+		},
+		{
+			name:  "with root",
+			certs: []*x509.Certificate{leaf, root},
+		},
+		{
+			name:    "missing",
+			wantErr: oidc.ErrKeyCertificatesMissing,
+		},
+		{
+			name:    "other key",
+			certs:   []*x509.Certificate{otherRoot},
+			wantErr: oidc.ErrKeyCertificatesMismatch,
+		},
+		{
+			name:          "untrusted",
+			certs:         []*x509.Certificate{otherLeaf, otherRoot},
+			wantUntrusted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := key
+			key.Certificates = tt.certs
+			err := oidc.VerifyKeyCertificates(&key, opts)
+			switch {
+			case tt.wantUntrusted:
+				assert.ErrorAs(t, err, &x509.UnknownAuthorityError{})
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCertificateKeySet(t *testing.T) {
+	root, leaf, err := tu.NewCertificateChain()
+	require.NoError(t, err)
+	otherRoot, _, err := tu.NewCertificateChain()
+	require.NoError(t, err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: tu.SignatureAlgorithm, Key: tu.WebKey},
+		(&jose.SignerOptions{}).WithHeader("x5c", []string{base64.StdEncoding.EncodeToString(leaf.Raw)}))
+	require.NoError(t, err)
+	signed, err := signer.Sign([]byte("payload"))
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+	jws, err := jose.ParseSigned(token, []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+	require.NoError(t, err)
+
+	keySet := oidc.NewCertificateKeySet(x509.VerifyOptions{
+		Roots:     certPool(root),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	payload, err := keySet.VerifySignature(context.Background(), jws)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), payload)
+
+	keySet = oidc.NewCertificateKeySet(x509.VerifyOptions{
+		Roots:     certPool(otherRoot),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	_, err = keySet.VerifySignature(context.Background(), jws)
+	assert.ErrorAs(t, err, &x509.UnknownAuthorityError{})
+
+	idToken, _ := tu.ValidIDToken()
+	jws, err = jose.ParseSigned(idToken, []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+	require.NoError(t, err)
+	_, err = keySet.VerifySignature(context.Background(), jws)
+	assert.ErrorIs(t, err, jose.ErrMissingX5cHeader)
+}
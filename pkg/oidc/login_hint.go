@@ -0,0 +1,43 @@
+package oidc
+
+// LoginHints are the hints of an auth request about the end-user
+// and the (upstream) identity provider to authenticate with.
+// They allow the Login UI to pre-select an account or to skip the account
+// or identity provider picker, e.g. for enterprise SSO deep-links.
+type LoginHints struct {
+	// LoginHint is the `login_hint` of OpenID Connect Core 1.0,
+	// typically the email address or username of the end-user.
+	LoginHint string
+	// LoginHintToken is the `login_hint_token` of OpenID Connect
+	// Client-Initiated Backchannel Authentication (CIBA),
+	// a token containing information identifying the end-user.
+	LoginHintToken string
+	// DomainHint is the (email) domain of the end-user's organization,
+	// used to select the tenant or identity provider.
+	DomainHint string
+	// IDPHint is the name of the identity provider to authenticate with.
+	IDPHint string
+}
+
+// IsEmpty reports whether no hint is set.
+func (h LoginHints) IsEmpty() bool {
+	return h == LoginHints{}
+}
+
+// GetLoginHints returns the login hints of the auth request.
+func (a *AuthRequest) GetLoginHints() LoginHints {
+	return LoginHints{
+		LoginHint:      a.LoginHint,
+		LoginHintToken: a.LoginHintToken,
+		DomainHint:     a.DomainHint,
+		IDPHint:        a.IDPHint,
+	}
+}
+
+// SetLoginHints overwrites the login hints of the auth request.
+func (a *AuthRequest) SetLoginHints(h LoginHints) {
+	a.LoginHint = h.LoginHint
+	a.LoginHintToken = h.LoginHintToken
+	a.DomainHint = h.DomainHint
+	a.IDPHint = h.IDPHint
+}
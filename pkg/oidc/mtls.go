@@ -0,0 +1,27 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+)
+
+var ErrCertificateBinding = errors.New("mtls: client certificate does not match the access token binding")
+
+// CertificateThumbprint returns the base64url encoded SHA-256 thumbprint
+// of the DER encoding of the certificate, the `x5t#S256` confirmation
+// of certificate-bound access tokens (RFC 8705, section 3.1).
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyCertificateBinding checks the client certificate is the one
+// the access token is bound to by the confirmation.
+func VerifyCertificateBinding(cert *x509.Certificate, cnf *Confirmation) error {
+	if cert == nil || cnf == nil || cnf.CertificateThumbprint == "" || cnf.CertificateThumbprint != CertificateThumbprint(cert) {
+		return ErrCertificateBinding
+	}
+	return nil
+}
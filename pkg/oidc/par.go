@@ -0,0 +1,13 @@
+package oidc
+
+// RequestURIPrefixPushed is the prefix of the request_uri values
+// issued by Pushed Authorization Request endpoints (RFC 9126, section 2.2).
+const RequestURIPrefixPushed = "urn:ietf:params:oauth:request_uri:"
+
+// PushedAuthorizationResponse implements
+// https://www.rfc-editor.org/rfc/rfc9126#section-2.2,
+// 2.2. Successful Response.
+type PushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
@@ -0,0 +1,211 @@
+package oidc
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/zitadel/schema"
+)
+
+// Names of the request and response parameters of the OAuth and OpenID Connect
+// specifications, see the IANA OAuth Parameters registry.
+const (
+	// RFC 6749
+	ParamClientID         = "client_id"
+	ParamClientSecret     = "client_secret"
+	ParamResponseType     = "response_type"
+	ParamRedirectURI      = "redirect_uri"
+	ParamScope            = "scope"
+	ParamState            = "state"
+	ParamCode             = "code"
+	ParamError            = "error"
+	ParamErrorDescription = "error_description"
+	ParamErrorURI         = "error_uri"
+	ParamGrantType        = "grant_type"
+	ParamAccessToken      = "access_token"
+	ParamTokenType        = "token_type"
+	ParamExpiresIn        = "expires_in"
+	ParamUsername         = "username"
+	ParamPassword         = "password"
+	ParamRefreshToken     = "refresh_token"
+
+	// OpenID Connect Core 1.0
+	ParamNonce         = "nonce"
+	ParamDisplay       = "display"
+	ParamPrompt        = "prompt"
+	ParamMaxAge        = "max_age"
+	ParamUILocales     = "ui_locales"
+	ParamClaimsLocales = "claims_locales"
+	ParamIDTokenHint   = "id_token_hint"
+	ParamLoginHint     = "login_hint"
+	ParamACRValues     = "acr_values"
+	ParamClaims        = "claims"
+	ParamRegistration  = "registration"
+	ParamRequest       = "request"
+	ParamRequestURI    = "request_uri"
+	ParamIDToken       = "id_token"
+
+	// OAuth 2.0 Multiple Response Type Encoding Practices, OpenID Connect Session Management 1.0
+	ParamResponseMode = "response_mode"
+	ParamSessionState = "session_state"
+
+	// OpenID Connect RP-Initiated Logout 1.0
+	ParamPostLogoutRedirectURI = "post_logout_redirect_uri"
+	ParamLogoutHint            = "logout_hint"
+
+	// RFC 7521, RFC 7523
+	ParamAssertion           = "assertion"
+	ParamClientAssertion     = "client_assertion"
+	ParamClientAssertionType = "client_assertion_type"
+
+	// RFC 7636
+	ParamCodeVerifier        = "code_verifier"
+	ParamCodeChallenge       = "code_challenge"
+	ParamCodeChallengeMethod = "code_challenge_method"
+
+	// RFC 7009, RFC 7662
+	ParamToken         = "token"
+	ParamTokenTypeHint = "token_type_hint"
+
+	// RFC 8628
+	ParamDeviceCode              = "device_code"
+	ParamUserCode                = "user_code"
+	ParamVerificationURI         = "verification_uri"
+	ParamVerificationURIComplete = "verification_uri_complete"
+	ParamInterval                = "interval"
+
+	// RFC 8693, RFC 8707
+	ParamResource           = "resource"
+	ParamAudience           = "audience"
+	ParamRequestedTokenType = "requested_token_type"
+	ParamSubjectToken       = "subject_token"
+	ParamSubjectTokenType   = "subject_token_type"
+	ParamActorToken         = "actor_token"
+	ParamActorTokenType     = "actor_token_type"
+	ParamIssuedTokenType    = "issued_token_type"
+
+	// RFC 9207, RFC 9396, RFC 9449
+	ParamIssuer               = "iss"
+	ParamAuthorizationDetails = "authorization_details"
+	ParamDPoPJKT              = "dpop_jkt"
+
+	// OpenID Connect Client-Initiated Backchannel Authentication Flow - Core 1.0
+	ParamClientNotificationToken = "client_notification_token"
+	ParamLoginHintToken          = "login_hint_token"
+	ParamBindingMessage          = "binding_message"
+	ParamRequestedExpiry         = "requested_expiry"
+	ParamAuthReqID               = "auth_req_id"
+
+	// OpenID Federation 1.0 and common provider extensions
+	ParamTrustChain = "trust_chain"
+	ParamDomainHint = "domain_hint"
+	ParamIDPHint    = "idp_hint"
+)
+
+// ClientAuthenticationParams are the parameters of the client authentication
+// at the token endpoint, which are not fields of all token requests.
+var ClientAuthenticationParams = []string{
+	ParamClientID, ParamClientSecret, ParamClientAssertion, ParamClientAssertionType,
+}
+
+// ParseParams decodes the parameters into the request, a pointer to a struct with
+// schema tags like *AuthRequest, and returns the parameters, which are no fields
+// of the request, e.g. provider-specific parameters. Those are not lost,
+// unlike with the decoders ignoring unknown parameters.
+func ParseParams(params url.Values, request any) (extra url.Values, err error) {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	if err = decoder.Decode(request, params); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidForm, err)
+	}
+	known := schemaNames(reflect.TypeOf(request))
+	extra = make(url.Values)
+	for name, values := range params {
+		if !slices.Contains(known, name) {
+			extra[name] = values
+		}
+	}
+	return extra, nil
+}
+
+// schemaNames returns the parameter names of the fields of the struct type t,
+// including the fields of embedded structs.
+func schemaNames(t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("schema"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ParseAuthRequest decodes the parameters of an auth request
+// and returns the parameters, which are no fields of the [AuthRequest].
+func ParseAuthRequest(params url.Values) (*AuthRequest, url.Values, error) {
+	authReq := new(AuthRequest)
+	extra, err := ParseParams(params, authReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	return authReq, extra, nil
+}
+
+// ParseTokenRequest decodes the parameters of a token request into the request
+// of its grant_type and returns the parameters, which are no fields of the request,
+// except for grant_type and the [ClientAuthenticationParams].
+// The request is one of *[AccessTokenRequest], *[RefreshTokenRequest],
+// *[ClientCredentialsRequest], *[TokenExchangeRequest], *[JWTProfileGrantRequest],
+// *[DeviceAccessTokenRequest], *[BackchannelTokenRequest] and *[SessionTransferTokenRequest].
+func ParseTokenRequest(params url.Values) (request any, extra url.Values, err error) {
+	switch grantType := GrantType(params.Get(ParamGrantType)); grantType {
+	case GrantTypeCode:
+		request = new(AccessTokenRequest)
+	case GrantTypeRefreshToken:
+		request = new(RefreshTokenRequest)
+	case GrantTypeClientCredentials:
+		request = new(ClientCredentialsRequest)
+	case GrantTypeTokenExchange:
+		request = new(TokenExchangeRequest)
+	case GrantTypeBearer:
+		request = new(JWTProfileGrantRequest)
+	case GrantTypeDeviceCode:
+		request = new(DeviceAccessTokenRequest)
+	case GrantTypeCIBA:
+		request = new(BackchannelTokenRequest)
+	case GrantTypeSessionTransfer:
+		request = new(SessionTransferTokenRequest)
+	case "":
+		return nil, nil, &FormError{Parameter: ParamGrantType}
+	default:
+		return nil, nil, &FormError{Parameter: ParamGrantType, Value: string(grantType)}
+	}
+	if extra, err = ParseParams(params, request); err != nil {
+		return nil, nil, err
+	}
+	extra.Del(ParamGrantType)
+	for _, param := range ClientAuthenticationParams {
+		extra.Del(param)
+	}
+	return request, extra, nil
+}
@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuthRequest(t *testing.T) {
+	authReq, extra, err := ParseAuthRequest(url.Values{
+		ParamClientID:     {"web"},
+		ParamResponseType: {"code"},
+		ParamScope:        {"openid profile"},
+		ParamMaxAge:       {"60"},
+		ParamRequest:      {"jwt"},
+		"tenant":          {"acme"},
+		"resource":        {"https://api.example.com", "https://other.example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "web", authReq.ClientID)
+	assert.Equal(t, ResponseTypeCode, authReq.ResponseType)
+	assert.Equal(t, SpaceDelimitedArray{"openid", "profile"}, authReq.Scopes)
+	assert.Equal(t, uint(60), *authReq.MaxAge)
+	assert.Equal(t, "jwt", authReq.RequestParam)
+	assert.Equal(t, url.Values{
+		"tenant":   {"acme"},
+		"resource": {"https://api.example.com", "https://other.example.com"},
+	}, extra)
+
+	_, _, err = ParseAuthRequest(url.Values{ParamMaxAge: {"never"}})
+	assert.ErrorIs(t, err, ErrInvalidForm)
+}
+
+func TestParseTokenRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    url.Values
+		want      any
+		wantExtra url.Values
+		wantErr   bool
+	}{
+		{
+			name: "authorization code",
+			params: url.Values{
+				ParamGrantType:    {string(GrantTypeCode)},
+				ParamCode:         {"code"},
+				ParamRedirectURI:  {"https://example.com"},
+				ParamClientID:     {"web"},
+				ParamCodeVerifier: {"verifier"},
+				"tenant":          {"acme"},
+			},
+			want: &AccessTokenRequest{
+				Code:         "code",
+				RedirectURI:  "https://example.com",
+				ClientID:     "web",
+				CodeVerifier: "verifier",
+			},
+			wantExtra: url.Values{"tenant": {"acme"}},
+		},
+		{
+			name: "device code with client authentication",
+			params: url.Values{
+				ParamGrantType:    {string(GrantTypeDeviceCode)},
+				ParamDeviceCode:   {"device"},
+				ParamClientID:     {"device"},
+				ParamClientSecret: {"secret"},
+			},
+			want: &DeviceAccessTokenRequest{
+				GrantType:  GrantTypeDeviceCode,
+				DeviceCode: "device",
+			},
+			wantExtra: url.Values{},
+		},
+		{
+			name:    "missing grant type",
+			params:  url.Values{ParamCode: {"code"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown grant type",
+			params:  url.Values{ParamGrantType: {"password"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, extra, err := ParseTokenRequest(tt.params)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidForm)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantExtra, extra)
+		})
+	}
+}
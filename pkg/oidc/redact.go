@@ -0,0 +1,276 @@
+package oidc
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/lmindwarel/oidc/v3/internal/security"
+)
+
+// The token requests and responses implement [slog.LogValuer] and
+// [fmt.Stringer] with their tokens, codes, secrets and assertions redacted
+// to a short prefix and a truncated hash, so logging them, also by accident
+// with %v, does not leak credentials. The hash correlates the values across
+// log lines.
+
+func (a AccessTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("code", a.Code),
+		slog.String("redirect_uri", a.RedirectURI),
+		slog.String("client_id", a.ClientID),
+		security.Attr("client_secret", a.ClientSecret),
+		security.Attr("code_verifier", a.CodeVerifier),
+		security.Attr("client_assertion", a.ClientAssertion),
+		slog.String("client_assertion_type", a.ClientAssertionType),
+	)
+}
+
+func (a AccessTokenRequest) String() string {
+	return security.String(a)
+}
+
+func (r RefreshTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("refresh_token", r.RefreshToken),
+		slog.String("scope", r.Scopes.String()),
+		slog.String("client_id", r.ClientID),
+		security.Attr("client_secret", r.ClientSecret),
+		security.Attr("client_assertion", r.ClientAssertion),
+		slog.String("client_assertion_type", r.ClientAssertionType),
+	)
+}
+
+func (r RefreshTokenRequest) String() string {
+	return security.String(r)
+}
+
+func (r ClientCredentialsRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("grant_type", string(r.GrantType)),
+		slog.String("scope", r.Scope.String()),
+		slog.String("client_id", r.ClientID),
+		security.Attr("client_secret", r.ClientSecret),
+		security.Attr("client_assertion", r.ClientAssertion),
+		slog.String("client_assertion_type", r.ClientAssertionType),
+	)
+}
+
+func (r ClientCredentialsRequest) String() string {
+	return security.String(r)
+}
+
+func (r TokenExchangeRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("grant_type", string(r.GrantType)),
+		security.Attr("subject_token", r.SubjectToken),
+		slog.String("subject_token_type", string(r.SubjectTokenType)),
+		security.Attr("actor_token", r.ActorToken),
+		slog.String("actor_token_type", string(r.ActorTokenType)),
+		slog.Any("resource", r.Resource),
+		slog.Any("audience", []string(r.Audience)),
+		slog.String("scope", r.Scopes.String()),
+		slog.String("requested_token_type", string(r.RequestedTokenType)),
+	)
+}
+
+func (r TokenExchangeRequest) String() string {
+	return security.String(r)
+}
+
+func (r JWTProfileGrantRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("grant_type", string(r.GrantType)),
+		security.Attr("assertion", r.Assertion),
+		slog.String("scope", r.Scope.String()),
+	)
+}
+
+func (r JWTProfileGrantRequest) String() string {
+	return security.String(r)
+}
+
+func (r IntrospectionRequest) LogValue() slog.Value {
+	return slog.GroupValue(security.Attr("token", r.Token))
+}
+
+func (r IntrospectionRequest) String() string {
+	return security.String(r)
+}
+
+func (r RevocationRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("token", r.Token),
+		slog.String("token_type_hint", r.TokenTypeHint),
+	)
+}
+
+func (r RevocationRequest) String() string {
+	return security.String(r)
+}
+
+func (r DeviceAccessTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("grant_type", string(r.GrantType)),
+		security.Attr("device_code", r.DeviceCode),
+	)
+}
+
+func (r DeviceAccessTokenRequest) String() string {
+	return security.String(r)
+}
+
+func (r BackchannelTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("grant_type", string(r.GrantType)),
+		security.Attr("auth_req_id", r.AuthReqID),
+	)
+}
+
+func (r BackchannelTokenRequest) String() string {
+	return security.String(r)
+}
+
+func (r BackchannelAuthenticationRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("scope", r.Scopes.String()),
+		security.Attr("client_notification_token", r.ClientNotificationToken),
+		slog.String("acr_values", r.ACRValues.String()),
+		security.Attr("login_hint_token", r.LoginHintToken),
+		security.Attr("id_token_hint", r.IDTokenHint),
+		slog.String("login_hint", r.LoginHint),
+		slog.String("binding_message", r.BindingMessage),
+		security.Attr("user_code", r.UserCode),
+		slog.Int("requested_expiry", r.RequestedExpiry),
+		slog.String("client_id", r.ClientID),
+	)
+}
+
+func (r BackchannelAuthenticationRequest) String() string {
+	return security.String(r)
+}
+
+func (r SessionTransferRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("subject_token", r.SubjectToken),
+		slog.String("audience", r.Audience),
+		slog.String("scope", r.Scopes.String()),
+		slog.String("code_challenge", r.CodeChallenge),
+		slog.String("code_challenge_method", string(r.CodeChallengeMethod)),
+		slog.String("client_id", r.ClientID),
+	)
+}
+
+func (r SessionTransferRequest) String() string {
+	return security.String(r)
+}
+
+func (r SessionTransferResponse) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("transfer_token", r.TransferToken),
+		slog.Uint64("expires_in", r.ExpiresIn),
+	)
+}
+
+func (r SessionTransferResponse) String() string {
+	return security.String(r)
+}
+
+func (r SessionTransferTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("grant_type", string(r.GrantType)),
+		security.Attr("transfer_token", r.TransferToken),
+		security.Attr("code_verifier", r.CodeVerifier),
+	)
+}
+
+func (r SessionTransferTokenRequest) String() string {
+	return security.String(r)
+}
+
+func (r AccessTokenResponse) LogValue() slog.Value {
+	return slog.GroupValue(
+		security.Attr("access_token", r.AccessToken),
+		slog.String("token_type", r.TokenType),
+		security.Attr("refresh_token", r.RefreshToken),
+		slog.Uint64("expires_in", r.ExpiresIn),
+		security.Attr("id_token", r.IDToken),
+		slog.String("state", r.State),
+		slog.String("scope", r.Scope.String()),
+	)
+}
+
+func (r AccessTokenResponse) String() string {
+	return security.String(r)
+}
+
+func (r TokenExchangeResponse) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		security.Attr("access_token", r.AccessToken),
+		slog.String("issued_token_type", string(r.IssuedTokenType)),
+		slog.String("token_type", r.TokenType),
+		slog.Uint64("expires_in", r.ExpiresIn),
+		slog.String("scope", r.Scopes.String()),
+		security.Attr("refresh_token", r.RefreshToken),
+		security.Attr("id_token", r.IDToken),
+	}
+	if len(r.IssuedTokens) > 0 {
+		issued := make([]slog.Attr, len(r.IssuedTokens))
+		for i, token := range r.IssuedTokens {
+			issued[i] = slog.Any(strconv.Itoa(i), token)
+		}
+		attrs = append(attrs, slog.Attr{Key: "issued_tokens", Value: slog.GroupValue(issued...)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (r TokenExchangeResponse) String() string {
+	return security.String(r)
+}
+
+func (t IssuedToken) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("audience", t.Audience),
+		slog.String("resource", t.Resource),
+		security.Attr("access_token", t.AccessToken),
+		slog.String("issued_token_type", string(t.IssuedTokenType)),
+		slog.String("token_type", t.TokenType),
+		slog.Uint64("expires_in", t.ExpiresIn),
+		security.Attr("refresh_token", t.RefreshToken),
+	)
+}
+
+func (t IssuedToken) String() string {
+	return security.String(t)
+}
+
+func (t Tokens[C]) LogValue() slog.Value {
+	attrs := []slog.Attr{security.Attr("id_token", t.IDToken)}
+	if t.Token != nil {
+		attrs = append(attrs,
+			security.Attr("access_token", t.AccessToken),
+			slog.String("token_type", t.TokenType),
+			security.Attr("refresh_token", t.RefreshToken),
+		)
+		if !t.Expiry.IsZero() {
+			attrs = append(attrs, slog.Time("expiry", t.Expiry))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (t Tokens[C]) String() string {
+	return security.String(t)
+}
+
+func (j JWTProfileAssertionClaims) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("kid", j.PrivateKeyID),
+		slog.String("iss", j.Issuer),
+		slog.String("sub", j.Subject),
+		slog.Any("aud", []string(j.Audience)),
+	)
+}
+
+func (j JWTProfileAssertionClaims) String() string {
+	return security.String(j)
+}
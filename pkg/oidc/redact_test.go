@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestRedaction(t *testing.T) {
+	const secret = "s3cr3t-value-which-must-not-leak"
+	values := []any{
+		AccessTokenRequest{Code: secret, ClientID: "client", ClientSecret: secret, CodeVerifier: secret},
+		RefreshTokenRequest{RefreshToken: secret, ClientSecret: secret},
+		ClientCredentialsRequest{ClientSecret: secret, ClientAssertion: secret},
+		TokenExchangeRequest{SubjectToken: secret, ActorToken: secret},
+		JWTProfileGrantRequest{Assertion: secret},
+		IntrospectionRequest{Token: secret},
+		RevocationRequest{Token: secret},
+		DeviceAccessTokenRequest{DeviceCode: secret},
+		BackchannelTokenRequest{AuthReqID: secret},
+		BackchannelAuthenticationRequest{LoginHintToken: secret, ClientNotificationToken: secret},
+		SessionTransferRequest{SubjectToken: secret, Audience: "app"},
+		SessionTransferResponse{TransferToken: secret},
+		SessionTransferTokenRequest{TransferToken: secret, CodeVerifier: secret},
+		AccessTokenResponse{AccessToken: secret, RefreshToken: secret, IDToken: secret},
+		TokenExchangeResponse{AccessToken: secret, IssuedTokens: []IssuedToken{{AccessToken: secret}}},
+		Tokens[*IDTokenClaims]{Token: &oauth2.Token{AccessToken: secret, RefreshToken: secret}, IDToken: secret},
+		JWTProfileAssertionClaims{PrivateKey: []byte(secret), Issuer: "client"},
+	}
+	for _, value := range values {
+		t.Run(fmt.Sprintf("%T", value), func(t *testing.T) {
+			for _, format := range []string{"%v", "%+v", "%s"} {
+				assert.NotContains(t, fmt.Sprintf(format, value), secret, format)
+			}
+			var buf bytes.Buffer
+			slog.New(slog.NewJSONHandler(&buf, nil)).Info("test", "value", value)
+			assert.NotContains(t, buf.String(), secret)
+			assert.NotContains(t, buf.String(), "BADKEY")
+		})
+	}
+}
+
+func TestAccessTokenRequest_String(t *testing.T) {
+	request := AccessTokenRequest{Code: "code", ClientID: "client", RedirectURI: "https://example.com/cb"}
+	assert.Regexp(t, `^\{code=…sha256:[0-9a-f]{8} redirect_uri=https://example.com/cb client_id=client\}$`, request.String())
+}
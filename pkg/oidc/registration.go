@@ -0,0 +1,74 @@
+package oidc
+
+import (
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+const (
+	ApplicationTypeWeb    = "web"
+	ApplicationTypeNative = "native"
+)
+
+// ClientMetadata implements
+// https://www.rfc-editor.org/rfc/rfc7591#section-2,
+// 2. Client Metadata, with the additional metadata of
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata.
+type ClientMetadata struct {
+	RedirectURIs            []string            `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod AuthMethod          `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []GrantType         `json:"grant_types,omitempty"`
+	ResponseTypes           []ResponseType      `json:"response_types,omitempty"`
+	ApplicationType         string              `json:"application_type,omitempty"`
+	ClientName              string              `json:"client_name,omitempty"`
+	ClientURI               string              `json:"client_uri,omitempty"`
+	LogoURI                 string              `json:"logo_uri,omitempty"`
+	Scope                   SpaceDelimitedArray `json:"scope,omitempty"`
+	Contacts                []string            `json:"contacts,omitempty"`
+	TOSURI                  string              `json:"tos_uri,omitempty"`
+	PolicyURI               string              `json:"policy_uri,omitempty"`
+	JWKSURI                 string              `json:"jwks_uri,omitempty"`
+	JWKS                    *jose.JSONWebKeySet `json:"jwks,omitempty"`
+	SoftwareID              string              `json:"software_id,omitempty"`
+	SoftwareVersion         string              `json:"software_version,omitempty"`
+	PostLogoutRedirectURIs  []string            `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI    string              `json:"backchannel_logout_uri,omitempty"`
+
+	// The expected subject of the certificate of tls_client_auth clients,
+	// exactly one must be set (RFC 8705, section 2.1.2).
+	TLSClientAuthSubjectDN string `json:"tls_client_auth_subject_dn,omitempty"`
+	TLSClientAuthSANDNS    string `json:"tls_client_auth_san_dns,omitempty"`
+	TLSClientAuthSANURI    string `json:"tls_client_auth_san_uri,omitempty"`
+	TLSClientAuthSANIP     string `json:"tls_client_auth_san_ip,omitempty"`
+	TLSClientAuthSANEmail  string `json:"tls_client_auth_san_email,omitempty"`
+	// TLSClientCertificateBoundAccessTokens requests access tokens bound
+	// to the client certificate (RFC 8705, section 3.4).
+	TLSClientCertificateBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+}
+
+// ClientRegistrationRequest implements
+// https://www.rfc-editor.org/rfc/rfc7591#section-3.1,
+// 3.1. Client Registration Request and
+// https://www.rfc-editor.org/rfc/rfc7592#section-2.2,
+// 2.2. Client Update Request, which must contain the ClientID.
+type ClientRegistrationRequest struct {
+	ClientMetadata
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// ClientRegistrationResponse implements
+// https://www.rfc-editor.org/rfc/rfc7591#section-3.2.1,
+// 3.2.1. Client Information Response, with the
+// registration_access_token and registration_client_uri of
+// https://www.rfc-editor.org/rfc/rfc7592#section-3,
+// 3. Client Information Response.
+type ClientRegistrationResponse struct {
+	ClientMetadata
+	ClientID         string `json:"client_id"`
+	ClientSecret     string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt int64  `json:"client_id_issued_at,omitempty"`
+	// ClientSecretExpiresAt is 0 if the client_secret does not expire.
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+}
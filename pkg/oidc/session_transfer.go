@@ -0,0 +1,31 @@
+package oidc
+
+// SessionTransferRequest is the request of a first-party client to the session
+// transfer endpoint, for a transfer token of the session of the user to its native app.
+// The user is identified by the SubjectToken, an ID token issued to the client.
+type SessionTransferRequest struct {
+	SubjectToken string `schema:"subject_token"`
+	// Audience is the client_id of the native app redeeming the transfer token.
+	Audience string              `schema:"audience"`
+	Scopes   SpaceDelimitedArray `schema:"scope,omitempty"`
+	// CodeChallenge binds the transfer token to the code_verifier of the native app,
+	// which must be sent with the session transfer grant.
+	CodeChallenge       string              `schema:"code_challenge"`
+	CodeChallengeMethod CodeChallengeMethod `schema:"code_challenge_method,omitempty"`
+	ClientID            string              `schema:"client_id,omitempty"`
+}
+
+// SessionTransferResponse is the response of the session transfer endpoint.
+// The transfer token can be redeemed once, within ExpiresIn seconds.
+type SessionTransferResponse struct {
+	TransferToken string `json:"transfer_token"`
+	ExpiresIn     uint64 `json:"expires_in"`
+}
+
+// SessionTransferTokenRequest is the token request of the native app,
+// redeeming the transfer token with the session transfer grant.
+type SessionTransferTokenRequest struct {
+	GrantType     GrantType `json:"grant_type" schema:"grant_type"`
+	TransferToken string    `json:"transfer_token" schema:"transfer_token"`
+	CodeVerifier  string    `json:"code_verifier" schema:"code_verifier"`
+}
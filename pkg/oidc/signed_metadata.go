@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+var (
+	ErrSignedMetadataMissing = errors.New("discovery configuration has no signed_metadata")
+	ErrSignedMetadataInvalid = errors.New("signed_metadata of the discovery configuration is invalid")
+)
+
+// signedMetadataJWTClaims are not metadata values and not applied to the configuration.
+var signedMetadataJWTClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+// VerifySignedMetadata verifies the signed_metadata of the discovery configuration with
+// the keys of the trust anchors and returns a copy of the configuration with the signed
+// values applied, as they take precedence over the plain values.
+// The iss claim of the signed metadata must match the issuer of the configuration,
+// expired signed metadata is rejected. The supported algorithms default to RS256, ES256 and PS256.
+// See RFC 8414, section 2.1.
+func VerifySignedMetadata(ctx context.Context, config *DiscoveryConfiguration, keySet KeySet, supportedSigAlgs ...string) (*DiscoveryConfiguration, error) {
+	if config.SignedMetadata == "" {
+		return nil, ErrSignedMetadataMissing
+	}
+	jws, err := jose.ParseSigned(config.SignedMetadata, toJoseSignatureAlgorithms(supportedSigAlgs))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSignedMetadataInvalid, err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, fmt.Errorf("%w: %d signatures", ErrSignedMetadataInvalid, len(jws.Signatures))
+	}
+	payload, err := keySet.VerifySignature(ctx, jws)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: %w", ErrSignedMetadataInvalid, ErrSignatureInvalid, err)
+	}
+	signed := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(payload, &signed); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSignedMetadataInvalid, err)
+	}
+	var claims struct {
+		Issuer     string `json:"iss"`
+		Expiration Time   `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSignedMetadataInvalid, err)
+	}
+	if claims.Issuer != config.Issuer {
+		return nil, fmt.Errorf("%w: %w: iss %q", ErrSignedMetadataInvalid, ErrIssuerInvalid, claims.Issuer)
+	}
+	if claims.Expiration != 0 && time.Now().After(claims.Expiration.AsTime()) {
+		return nil, fmt.Errorf("%w: %w", ErrSignedMetadataInvalid, ErrExpired)
+	}
+	for _, claim := range signedMetadataJWTClaims {
+		delete(signed, claim)
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range signed {
+		merged[key] = value
+	}
+	if data, err = json.Marshal(merged); err != nil {
+		return nil, err
+	}
+	verified := new(DiscoveryConfiguration)
+	if err = json.Unmarshal(data, verified); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSignedMetadataInvalid, err)
+	}
+	if verified.Issuer != config.Issuer {
+		return nil, fmt.Errorf("%w: %w: issuer %q", ErrSignedMetadataInvalid, ErrIssuerInvalid, verified.Issuer)
+	}
+	return verified, nil
+}
@@ -0,0 +1,118 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func signMetadata(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	jws, err := tu.Signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := jws.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestVerifySignedMetadata(t *testing.T) {
+	const issuer = "https://op.example.com"
+	trustAnchors := oidc.NewStaticKeySet(tu.WebKey)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		config  oidc.DiscoveryConfiguration
+		keySet  oidc.KeySet
+		want    *oidc.DiscoveryConfiguration
+		wantErr error
+	}{
+		{
+			name: "signed values take precedence",
+			config: oidc.DiscoveryConfiguration{
+				Issuer:          issuer,
+				TokenEndpoint:   "https://attacker.example.com/token",
+				ScopesSupported: []string{"openid"},
+				SignedMetadata: signMetadata(t, map[string]any{
+					"iss":            issuer,
+					"iat":            time.Now().Unix(),
+					"token_endpoint": issuer + "/token",
+				}),
+			},
+			keySet: trustAnchors,
+			want: &oidc.DiscoveryConfiguration{
+				Issuer:          issuer,
+				TokenEndpoint:   issuer + "/token",
+				ScopesSupported: []string{"openid"},
+			},
+		},
+		{
+			name:    "missing",
+			config:  oidc.DiscoveryConfiguration{Issuer: issuer},
+			keySet:  trustAnchors,
+			wantErr: oidc.ErrSignedMetadataMissing,
+		},
+		{
+			name: "untrusted key",
+			config: oidc.DiscoveryConfiguration{
+				Issuer:         issuer,
+				SignedMetadata: signMetadata(t, map[string]any{"iss": issuer}),
+			},
+			keySet:  oidc.NewStaticKeySet(jose.JSONWebKey{Key: &otherKey.PublicKey, KeyID: "1"}),
+			wantErr: oidc.ErrSignatureInvalid,
+		},
+		{
+			name: "other issuer",
+			config: oidc.DiscoveryConfiguration{
+				Issuer:         issuer,
+				SignedMetadata: signMetadata(t, map[string]any{"iss": "https://other.example.com"}),
+			},
+			keySet:  trustAnchors,
+			wantErr: oidc.ErrIssuerInvalid,
+		},
+		{
+			name: "issuer changed by signed value",
+			config: oidc.DiscoveryConfiguration{
+				Issuer:         issuer,
+				SignedMetadata: signMetadata(t, map[string]any{"iss": issuer, "issuer": "https://other.example.com"}),
+			},
+			keySet:  trustAnchors,
+			wantErr: oidc.ErrIssuerInvalid,
+		},
+		{
+			name: "expired",
+			config: oidc.DiscoveryConfiguration{
+				Issuer:         issuer,
+				SignedMetadata: signMetadata(t, map[string]any{"iss": issuer, "exp": time.Now().Add(-time.Minute).Unix()}),
+			},
+			keySet:  trustAnchors,
+			wantErr: oidc.ErrExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := oidc.VerifySignedMetadata(context.Background(), &tt.config, tt.keySet)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.want.SignedMetadata = tt.config.SignedMetadata
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ClientAssertionTypeJWTSPIFFE defines the client_assertion_type
+	// `urn:ietf:params:oauth:client-assertion-type:jwt-spiffe` used for the
+	// authentication of clients with a SPIFFE JWT-SVID.
+	ClientAssertionTypeJWTSPIFFE = "urn:ietf:params:oauth:client-assertion-type:jwt-spiffe"
+
+	// AuthMethodSPIFFEJWT authenticates the client by a SPIFFE JWT-SVID
+	// sent as client_assertion of type [ClientAssertionTypeJWTSPIFFE].
+	AuthMethodSPIFFEJWT AuthMethod = "spiffe_jwt"
+
+	// KeyUseJWTSVID is the use of the keys of a SPIFFE trust bundle,
+	// which sign JWT-SVIDs.
+	KeyUseJWTSVID = "jwt-svid"
+)
+
+var ErrInvalidSPIFFEID = errors.New("invalid SPIFFE ID")
+
+// SPIFFEID is the identity of a workload, spiffe://<trust domain>/<path>,
+// the sub claim of its JWT-SVIDs.
+type SPIFFEID struct {
+	TrustDomain string
+	// Path starts with a slash or is empty for the trust domain itself.
+	Path string
+}
+
+// ParseSPIFFEID parses id according to the SPIFFE ID specification:
+// the trust domain is lowercase and the path has no empty, . or .. segments,
+// query or fragment.
+func ParseSPIFFEID(id string) (SPIFFEID, error) {
+	rest, ok := strings.CutPrefix(id, "spiffe://")
+	if !ok {
+		return SPIFFEID{}, fmt.Errorf("%w %q: scheme must be spiffe", ErrInvalidSPIFFEID, id)
+	}
+	trustDomain, path, hasPath := strings.Cut(rest, "/")
+	if trustDomain == "" || strings.Trim(trustDomain, "abcdefghijklmnopqrstuvwxyz0123456789-._") != "" {
+		return SPIFFEID{}, fmt.Errorf("%w %q: trust domain must consist of lowercase letters, digits, -, . and _", ErrInvalidSPIFFEID, id)
+	}
+	if strings.ContainsAny(path, "?#") {
+		return SPIFFEID{}, fmt.Errorf("%w %q: must not contain a query or fragment", ErrInvalidSPIFFEID, id)
+	}
+	if !hasPath {
+		return SPIFFEID{TrustDomain: trustDomain}, nil
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return SPIFFEID{}, fmt.Errorf("%w %q: path segments must not be empty, . or ..", ErrInvalidSPIFFEID, id)
+		}
+		if unescaped, err := url.PathUnescape(segment); err != nil || unescaped != segment {
+			return SPIFFEID{}, fmt.Errorf("%w %q: path must not be percent-encoded", ErrInvalidSPIFFEID, id)
+		}
+	}
+	return SPIFFEID{TrustDomain: trustDomain, Path: "/" + path}, nil
+}
+
+func (id SPIFFEID) String() string {
+	return "spiffe://" + id.TrustDomain + id.Path
+}
+
+// MemberOf reports if id belongs to the trust domain.
+func (id SPIFFEID) MemberOf(trustDomain string) bool {
+	return id.TrustDomain == trustDomain
+}
@@ -0,0 +1,39 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSPIFFEID(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    SPIFFEID
+		wantErr bool
+	}{
+		{id: "spiffe://example.org/ns/shop/sa/checkout", want: SPIFFEID{TrustDomain: "example.org", Path: "/ns/shop/sa/checkout"}},
+		{id: "spiffe://example.org", want: SPIFFEID{TrustDomain: "example.org"}},
+		{id: "https://example.org/workload", wantErr: true},
+		{id: "spiffe://Example.org/workload", wantErr: true},
+		{id: "spiffe:///workload", wantErr: true},
+		{id: "spiffe://example.org/", wantErr: true},
+		{id: "spiffe://example.org/a//b", wantErr: true},
+		{id: "spiffe://example.org/a/../b", wantErr: true},
+		{id: "spiffe://example.org/a?b=c", wantErr: true},
+		{id: "spiffe://example.org/a%2Fb", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := ParseSPIFFEID(tt.id)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSPIFFEID)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.id, got.String())
+		})
+	}
+}
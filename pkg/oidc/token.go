@@ -74,6 +74,10 @@ func (c *TokenClaims) GetIssuedAt() time.Time {
 	return c.IssuedAt.AsTime()
 }
 
+func (c *TokenClaims) GetNotBefore() time.Time {
+	return c.NotBefore.AsTime()
+}
+
 func (c *TokenClaims) GetNonce() string {
 	return c.Nonce
 }
@@ -100,8 +104,16 @@ func (c *TokenClaims) SetSignatureAlgorithm(algorithm jose.SignatureAlgorithm) {
 
 type AccessTokenClaims struct {
 	TokenClaims
-	Scopes SpaceDelimitedArray `json:"scope,omitempty"`
-	Claims map[string]any      `json:"-"`
+	Scopes     SpaceDelimitedArray `json:"scope,omitempty"`
+	ScopeArray ScopeArray          `json:"scp,omitempty"`
+	// Confirmation binds the access token to a DPoP key.
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+	// SessionID is the sid of the session of the end-user at the OP, if any.
+	SessionID string         `json:"sid,omitempty"`
+	Claims    map[string]any `json:"-"`
+
+	// AuthorizationDetails are the authorization details granted to the access token (RFC 9396, section 9.1).
+	AuthorizationDetails AuthorizationDetails `json:"authorization_details,omitempty"`
 }
 
 func NewAccessTokenClaims(issuer, subject string, audience []string, expiration time.Time, jwtid, clientID string, skew time.Duration) *AccessTokenClaims {
@@ -129,8 +141,13 @@ func (a *AccessTokenClaims) MarshalJSON() ([]byte, error) {
 	return mergeAndMarshalClaims((*atcAlias)(a), a.Claims)
 }
 
+// UnmarshalJSON accepts the scopes from the scope or the scp claim.
 func (a *AccessTokenClaims) UnmarshalJSON(data []byte) error {
-	return unmarshalJSONMulti(data, (*atcAlias)(a), &a.Claims)
+	if err := unmarshalJSONMulti(data, (*atcAlias)(a), &a.Claims); err != nil {
+		return err
+	}
+	a.Scopes = scopesOrArray(a.Scopes, a.ScopeArray)
+	return nil
 }
 
 // IDTokenClaims extends TokenClaims by further implementing
@@ -237,6 +254,9 @@ type AccessTokenResponse struct {
 	IDToken      string              `json:"id_token,omitempty" schema:"id_token,omitempty"`
 	State        string              `json:"state,omitempty" schema:"state,omitempty"`
 	Scope        SpaceDelimitedArray `json:"scope,omitempty" schema:"scope,omitempty"`
+
+	// AuthorizationDetails are the authorization details granted to the access token (RFC 9396, section 7).
+	AuthorizationDetails AuthorizationDetails `json:"authorization_details,omitempty" schema:"authorization_details,omitempty"`
 }
 
 type JWTProfileAssertionClaims struct {
@@ -382,8 +402,31 @@ type TokenExchangeResponse struct {
 	// IDToken field allows returning an additional ID token
 	// if the requested_token_type was Access Token and scope contained openid.
 	IDToken string `json:"id_token,omitempty"`
+
+	// IssuedTokens holds a token per audience and resource of the request,
+	// restricted to it, if the OP splits multi-audience requests.
+	IssuedTokens []IssuedToken `json:"issued_tokens,omitempty"`
+}
+
+// IssuedToken is a token of a [TokenExchangeResponse] restricted
+// to a single audience or resource of the request.
+type IssuedToken struct {
+	Audience        string    `json:"audience,omitempty"`
+	Resource        string    `json:"resource,omitempty"`
+	AccessToken     string    `json:"access_token"`
+	IssuedTokenType TokenType `json:"issued_token_type"`
+	TokenType       string    `json:"token_type"`
+	ExpiresIn       uint64    `json:"expires_in,omitempty"`
+	RefreshToken    string    `json:"refresh_token,omitempty"`
 }
 
+const (
+	// BackChannelLogoutEvent is the member of the events claim of logout tokens.
+	BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	// LogoutTokenType is the `typ` header of logout tokens.
+	LogoutTokenType = "logout+jwt"
+)
+
 type LogoutTokenClaims struct {
 	Issuer     string         `json:"iss,omitempty"`
 	Subject    string         `json:"sub,omitempty"`
@@ -394,6 +437,50 @@ type LogoutTokenClaims struct {
 	Events     map[string]any `json:"events,omitempty"`
 	SessionID  string         `json:"sid,omitempty"`
 	Claims     map[string]any `json:"-"`
+
+	SignatureAlg jose.SignatureAlgorithm `json:"-"`
+}
+
+func (i *LogoutTokenClaims) GetIssuer() string {
+	return i.Issuer
+}
+
+func (i *LogoutTokenClaims) GetSubject() string {
+	return i.Subject
+}
+
+func (i *LogoutTokenClaims) GetAudience() []string {
+	return i.Audience
+}
+
+func (i *LogoutTokenClaims) GetExpiration() time.Time {
+	return i.Expiration.AsTime()
+}
+
+func (i *LogoutTokenClaims) GetIssuedAt() time.Time {
+	return i.IssuedAt.AsTime()
+}
+
+// GetNonce returns the nonce claim, which logout tokens must not contain.
+func (i *LogoutTokenClaims) GetNonce() string {
+	nonce, _ := i.Claims["nonce"].(string)
+	return nonce
+}
+
+func (i *LogoutTokenClaims) GetAuthenticationContextClassReference() string {
+	return ""
+}
+
+func (i *LogoutTokenClaims) GetAuthTime() time.Time {
+	return time.Time{}
+}
+
+func (i *LogoutTokenClaims) GetAuthorizedParty() string {
+	return ""
+}
+
+func (i *LogoutTokenClaims) SetSignatureAlgorithm(algorithm jose.SignatureAlgorithm) {
+	i.SignatureAlg = algorithm
 }
 
 type ltcAlias LogoutTokenClaims
@@ -406,6 +493,11 @@ func (i *LogoutTokenClaims) UnmarshalJSON(data []byte) error {
 	return unmarshalJSONMulti(data, (*ltcAlias)(i), &i.Claims)
 }
 
+// BackChannelLogoutRequest is posted by the OP to the backchannel_logout_uri of the client.
+type BackChannelLogoutRequest struct {
+	LogoutToken string `schema:"logout_token"`
+}
+
 func NewLogoutTokenClaims(issuer, subject string, audience Audience, expiration time.Time, jwtID, sessionID string, skew time.Duration) *LogoutTokenClaims {
 	return &LogoutTokenClaims{
 		Issuer:     issuer,
@@ -415,7 +507,7 @@ func NewLogoutTokenClaims(issuer, subject string, audience Audience, expiration
 		Expiration: FromTime(expiration),
 		JWTID:      jwtID,
 		Events: map[string]any{
-			"http://schemas.openid.net/event/backchannel-logout": struct{}{},
+			BackChannelLogoutEvent: struct{}{},
 		},
 		SessionID: sessionID,
 	}
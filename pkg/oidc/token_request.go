@@ -1,9 +1,11 @@
 package oidc
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	jose "github.com/go-jose/go-jose/v4"
@@ -31,6 +33,14 @@ const (
 	// GrantTypeDeviceCode
 	GrantTypeDeviceCode GrantType = "urn:ietf:params:oauth:grant-type:device_code"
 
+	// GrantTypeCIBA defines the grant_type `urn:openid:params:grant-type:ciba` used for the Token Request
+	// of the Client-Initiated Backchannel Authentication Flow
+	GrantTypeCIBA GrantType = "urn:openid:params:grant-type:ciba"
+
+	// GrantTypeSessionTransfer defines the grant_type used by first-party native apps
+	// to redeem a session transfer token for their own tokens
+	GrantTypeSessionTransfer GrantType = "urn:lmindwarel:params:oauth:grant-type:session_transfer"
+
 	// ClientAssertionTypeJWTAssertion defines the client_assertion_type `urn:ietf:params:oauth:client-assertion-type:jwt-bearer`
 	// used for the OAuth JWT Profile Client Authentication
 	ClientAssertionTypeJWTAssertion = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
@@ -39,7 +49,8 @@ const (
 var AllGrantTypes = []GrantType{
 	GrantTypeCode, GrantTypeRefreshToken, GrantTypeClientCredentials,
 	GrantTypeBearer, GrantTypeTokenExchange, GrantTypeImplicit,
-	GrantTypeDeviceCode, ClientAssertionTypeJWTAssertion,
+	GrantTypeDeviceCode, ClientAssertionTypeJWTAssertion, GrantTypeCIBA,
+	GrantTypeSessionTransfer,
 }
 
 type GrantType string
@@ -49,6 +60,11 @@ const (
 	RefreshTokenType TokenType = "urn:ietf:params:oauth:token-type:refresh_token"
 	IDTokenType      TokenType = "urn:ietf:params:oauth:token-type:id_token"
 	JWTTokenType     TokenType = "urn:ietf:params:oauth:token-type:jwt"
+
+	// SAML1TokenType and SAML2TokenType are base64url-encoded SAML assertions,
+	// which are accepted as subject and actor tokens, but never issued.
+	SAML1TokenType TokenType = "urn:ietf:params:oauth:token-type:saml1"
+	SAML2TokenType TokenType = "urn:ietf:params:oauth:token-type:saml2"
 )
 
 var AllTokenTypes = []TokenType{
@@ -61,6 +77,18 @@ func (t TokenType) IsSupported() bool {
 	return slices.Contains(AllTokenTypes, t)
 }
 
+// EncodeSAMLAssertion encodes the XML of a SAML assertion to be sent
+// as subject or actor token (RFC 8693, section 3).
+func EncodeSAMLAssertion(assertion []byte) string {
+	return base64.RawURLEncoding.EncodeToString(assertion)
+}
+
+// DecodeSAMLAssertion decodes a SAML assertion sent as subject or actor token,
+// accepting padding, which some clients add.
+func DecodeSAMLAssertion(token string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(token, "="))
+}
+
 type TokenRequest interface {
 	// GrantType GrantType `schema:"grant_type"`
 	GrantType() GrantType
@@ -76,6 +104,10 @@ type AccessTokenRequest struct {
 	CodeVerifier        string `schema:"code_verifier,omitempty"`
 	ClientAssertion     string `schema:"client_assertion,omitempty"`
 	ClientAssertionType string `schema:"client_assertion_type,omitempty"`
+
+	// AuthorizationDetails narrow the authorization details granted
+	// by the authorization request (RFC 9396, section 6.1).
+	AuthorizationDetails AuthorizationDetails `schema:"authorization_details,omitempty"`
 }
 
 func (a *AccessTokenRequest) GrantType() GrantType {
@@ -101,6 +133,10 @@ type RefreshTokenRequest struct {
 	ClientSecret        string              `schema:"client_secret"`
 	ClientAssertion     string              `schema:"client_assertion"`
 	ClientAssertionType string              `schema:"client_assertion_type"`
+
+	// AuthorizationDetails narrow the authorization details
+	// granted to the refresh token (RFC 9396, section 6.2).
+	AuthorizationDetails AuthorizationDetails `schema:"authorization_details,omitempty"`
 }
 
 func (a *RefreshTokenRequest) GrantType() GrantType {
@@ -242,4 +278,7 @@ type ClientCredentialsRequest struct {
 	ClientSecret        string              `schema:"client_secret"`
 	ClientAssertion     string              `schema:"client_assertion"`
 	ClientAssertionType string              `schema:"client_assertion_type"`
+
+	// AuthorizationDetails are requested by the client (RFC 9396, section 6).
+	AuthorizationDetails AuthorizationDetails `schema:"authorization_details,omitempty"`
 }
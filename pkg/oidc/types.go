@@ -224,13 +224,44 @@ func (s SpaceDelimitedArray) Value() (driver.Value, error) {
 	return strings.Join(s, " "), nil
 }
 
+// ScopeArray is the scp claim, which API gateways expect as array of scopes.
+// It marshals as JSON array, but also unmarshals from a space delimited string,
+// as issued by some providers.
+type ScopeArray []string
+
+func (s *ScopeArray) UnmarshalJSON(data []byte) error {
+	var scopes []string
+	if err := json.Unmarshal(data, &scopes); err == nil {
+		*s = scopes
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*s = strings.Fields(str)
+	return nil
+}
+
+// scopesOrArray returns the scopes, or the scp claim if the scope claim is missing.
+func scopesOrArray(scopes SpaceDelimitedArray, array ScopeArray) SpaceDelimitedArray {
+	if len(scopes) == 0 && len(array) > 0 {
+		return SpaceDelimitedArray(array)
+	}
+	return scopes
+}
+
 // NewEncoder returns a schema Encoder with
-// a registered encoder for SpaceDelimitedArray.
+// registered encoders for SpaceDelimitedArray and AuthorizationDetails.
 func NewEncoder() *schema.Encoder {
 	e := schema.NewEncoder()
 	e.RegisterEncoder(SpaceDelimitedArray{}, func(value reflect.Value) string {
 		return value.Interface().(SpaceDelimitedArray).String()
 	})
+	e.RegisterEncoder(AuthorizationDetails{}, func(value reflect.Value) string {
+		text, _ := value.Interface().(AuthorizationDetails).MarshalText()
+		return string(text)
+	})
 	return e
 }
 
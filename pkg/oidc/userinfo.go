@@ -97,3 +97,19 @@ type UserInfoAddress struct {
 type UserInfoRequest struct {
 	AccessToken string `schema:"access_token"`
 }
+
+const (
+	// ClaimNames maps the names of distributed claims to their source,
+	// OpenID Connect Core 1.0, section 5.6.2.
+	ClaimNames = "_claim_names"
+	// ClaimSources holds the sources of distributed claims by their name.
+	ClaimSources = "_claim_sources"
+)
+
+// ClaimSource is the source of a distributed claim, which the client
+// obtains from the endpoint with the access token.
+// https://openid.net/specs/openid-connect-core-1_0.html#DistributedExample
+type ClaimSource struct {
+	Endpoint    string `json:"endpoint"`
+	AccessToken string `json:"access_token,omitempty"`
+}
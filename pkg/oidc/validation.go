@@ -0,0 +1,63 @@
+package oidc
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ValidationFormatJWT    = "jwt"
+	ValidationFormatOpaque = "opaque"
+)
+
+// ValidationResult is the outcome of a successful token validation.
+// Besides the claims it describes the token and the checks applied,
+// for consistent logging and authorization decisions downstream
+// without parsing the token again.
+type ValidationResult[C any] struct {
+	Claims C
+	// Format is [ValidationFormatJWT] or [ValidationFormatOpaque].
+	Format string
+	// Issuer of the claims, if they provide one.
+	Issuer string
+	// KeyID and Algorithm of the JWS header of JWTs.
+	KeyID     string
+	Algorithm string
+	// ValidatedAt is the start of the validation,
+	// which took Duration, including requests to the OP.
+	ValidatedAt time.Time
+	Duration    time.Duration
+	// Checks are the checks and policy decisions applied, see [Explanation].
+	Checks []ExplainedCheck
+}
+
+// ValidateWithResult runs the verification of the token in explain mode
+// and returns its claims as [ValidationResult].
+// The checks are added to the Explanation of ctx as well, if any.
+func ValidateWithResult[C any](ctx context.Context, token string, verify func(ctx context.Context) (C, error)) (*ValidationResult[C], error) {
+	outer := ExplanationFromContext(ctx)
+	ctx, explanation := WithExplanation(ctx)
+	start := time.Now()
+	claims, err := verify(ctx)
+	duration := time.Since(start)
+	outer.merge(explanation)
+	if err != nil {
+		return nil, err
+	}
+	result := &ValidationResult[C]{
+		Claims:      claims,
+		Format:      ValidationFormatOpaque,
+		ValidatedAt: start,
+		Duration:    duration,
+		Checks:      explanation.Checks(),
+	}
+	if header, _, err := DecodeUnverified[map[string]any](token); err == nil {
+		result.Format = ValidationFormatJWT
+		result.KeyID = header.KeyID
+		result.Algorithm = header.Algorithm
+	}
+	if issuer, ok := any(claims).(interface{ GetIssuer() string }); ok {
+		result.Issuer = issuer.GetIssuer()
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWithResult(t *testing.T) {
+	const jwt = "eyJhbGciOiJSUzI1NiIsImtpZCI6ImtleTEifQ.eyJpc3MiOiJodHRwczovL2lzc3Vlci5leGFtcGxlLmNvbSJ9.c2ln"
+	verify := func(err error) func(ctx context.Context) (*TokenClaims, error) {
+		return func(ctx context.Context) (*TokenClaims, error) {
+			ExplanationFromContext(ctx).Check("iss", err)
+			if err != nil {
+				return nil, err
+			}
+			return &TokenClaims{Issuer: "https://issuer.example.com"}, nil
+		}
+	}
+
+	ctx, outer := WithExplanation(context.Background())
+	got, err := ValidateWithResult(ctx, jwt, verify(nil))
+	require.NoError(t, err)
+	assert.Equal(t, ValidationFormatJWT, got.Format)
+	assert.Equal(t, "key1", got.KeyID)
+	assert.Equal(t, "RS256", got.Algorithm)
+	assert.Equal(t, "https://issuer.example.com", got.Issuer)
+	assert.False(t, got.ValidatedAt.IsZero())
+	require.Len(t, got.Checks, 1)
+	assert.Equal(t, "iss", got.Checks[0].Name)
+	assert.Len(t, outer.Checks(), 1)
+
+	got, err = ValidateWithResult(context.Background(), "opaque", verify(nil))
+	require.NoError(t, err)
+	assert.Equal(t, ValidationFormatOpaque, got.Format)
+	assert.Empty(t, got.KeyID)
+
+	errInvalid := errors.New("invalid")
+	_, err = ValidateWithResult(context.Background(), jwt, verify(errInvalid))
+	assert.ErrorIs(t, err, errInvalid)
+}
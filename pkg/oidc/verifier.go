@@ -12,6 +12,8 @@ import (
 	"time"
 
 	jose "github.com/go-jose/go-jose/v4"
+
+	zcrypto "github.com/lmindwarel/oidc/v3/pkg/crypto"
 )
 
 type Claims interface {
@@ -54,11 +56,20 @@ var (
 	ErrIatMissing              = errors.New("issuedAt of token is missing")
 	ErrIatInFuture             = errors.New("issuedAt of token is in the future")
 	ErrIatToOld                = errors.New("issuedAt of token is to old")
+	ErrNotYetValid             = errors.New("token is not valid yet (nbf)")
 	ErrNonceInvalid            = errors.New("nonce does not match")
 	ErrAcrInvalid              = errors.New("acr is invalid")
 	ErrAuthTimeNotPresent      = errors.New("claim `auth_time` of token is missing")
 	ErrAuthTimeToOld           = errors.New("auth time of token is too old")
 	ErrAtHash                  = errors.New("at_hash does not correspond to access token")
+	ErrEmailNotVerified        = errors.New("email is not verified")
+	ErrEmailDomain             = errors.New("email domain is not allowed")
+	ErrHostedDomain            = errors.New("hosted domain is not allowed")
+	ErrLogoutEventMissing      = errors.New("events of logout token do not contain the back-channel logout event")
+	ErrLogoutSubjectMissing    = errors.New("logout token contains neither sub nor sid")
+	ErrLogoutNonce             = errors.New("logout token must not contain a nonce")
+	ErrLogoutTokenType         = errors.New("typ header of logout token must be logout+jwt")
+	ErrLogoutJWTIDMissing      = errors.New("jti of logout token is missing")
 )
 
 // Verifier caries configuration for the various token verification
@@ -74,6 +85,23 @@ type Verifier struct {
 	ACR               ACRVerifier
 	KeySet            KeySet
 	Nonce             func(ctx context.Context) string
+	// AllowInsecure matches the issuer with [MatchIssuer] in insecure mode,
+	// for development and tests only.
+	AllowInsecure bool
+	// Email requires a verified email address, if set, see [CheckEmail].
+	Email *EmailPolicy
+	// FIPS restricts the SupportedSignAlgs to the approved ones, if set,
+	// see [zcrypto.FIPS.ApprovedAlgorithms].
+	FIPS *zcrypto.FIPS
+}
+
+// EmailPolicy requires the email_verified claim to be true
+// and optionally restricts the domains of the user, see [CheckEmail].
+type EmailPolicy struct {
+	// Domains allowed for the email address, any domain if empty.
+	Domains []string
+	// HostedDomains allowed for the hd claim (e.g. Google Workspace), any if empty.
+	HostedDomains []string
 }
 
 // ACRVerifier specifies the function to be used by the `DefaultVerifier` for validating the acr claim
@@ -147,7 +175,12 @@ func CheckAuthorizedParty(claims Claims, clientID string) error {
 	return nil
 }
 
-func CheckSignature(ctx context.Context, token string, payload []byte, claims ClaimsSignature, supportedSigAlgs []string, set KeySet) error {
+func CheckSignature(ctx context.Context, token string, payload []byte, claims ClaimsSignature, supportedSigAlgs []string, set KeySet) (err error) {
+	var keyID, alg string
+	defer func() {
+		ExplanationFromContext(ctx).Check("signature", err, "kid", keyID, "alg", alg, "supported_algs", supportedSigAlgs)
+	}()
+
 	jws, err := jose.ParseSigned(token, toJoseSignatureAlgorithms(supportedSigAlgs))
 	if err != nil {
 		if strings.HasPrefix(err.Error(), "go-jose/go-jose: unexpected signature algorithm") {
@@ -164,6 +197,7 @@ func CheckSignature(ctx context.Context, token string, payload []byte, claims Cl
 		return ErrSignatureMultiple
 	}
 	sig := jws.Signatures[0]
+	keyID, alg = GetKeyIDAndAlg(jws)
 
 	signedPayload, err := set.VerifySignature(ctx, jws)
 	if err != nil {
@@ -199,6 +233,19 @@ func CheckExpiration(claims Claims, offset time.Duration) error {
 	return nil
 }
 
+// CheckNotBefore checks that the token is already valid by its nbf claim,
+// if the claims implement GetNotBefore, such as [TokenClaims], and have one.
+func CheckNotBefore(claims Claims, offset time.Duration) error {
+	nbf, ok := claims.(interface{ GetNotBefore() time.Time })
+	if !ok || nbf.GetNotBefore().IsZero() {
+		return nil
+	}
+	if notBefore := nbf.GetNotBefore(); time.Now().Add(offset).Before(notBefore) {
+		return fmt.Errorf("%w: (nbf: %v)", ErrNotYetValid, notBefore)
+	}
+	return nil
+}
+
 func CheckIssuedAt(claims Claims, maxAgeIAT, offset time.Duration) error {
 	issuedAt := claims.GetIssuedAt()
 	if issuedAt.IsZero() {
@@ -248,3 +295,41 @@ func CheckAuthTime(claims Claims, maxAge time.Duration) error {
 	}
 	return nil
 }
+
+type emailClaims struct {
+	Email         string `json:"email"`
+	EmailVerified Bool   `json:"email_verified"`
+	HostedDomain  string `json:"hd"`
+}
+
+// CheckEmail checks the email claims of the token payload against the policy.
+// The email must be present and verified, its domain and the hd claim
+// must be one of the allowed, if any are set. Domains are compared case-insensitive.
+func CheckEmail(payload []byte, policy *EmailPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	var claims emailClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return fmt.Errorf("%w: %q", ErrEmailNotVerified, claims.Email)
+	}
+	if len(policy.Domains) > 0 {
+		_, domain, _ := strings.Cut(claims.Email, "@")
+		if !containsFold(policy.Domains, domain) {
+			return fmt.Errorf("%w: expected one of: %v, got: %q", ErrEmailDomain, policy.Domains, domain)
+		}
+	}
+	if len(policy.HostedDomains) > 0 && !containsFold(policy.HostedDomains, claims.HostedDomain) {
+		return fmt.Errorf("%w: expected one of: %v, got: %q", ErrHostedDomain, policy.HostedDomains, claims.HostedDomain)
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	return value != "" && slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
@@ -372,3 +372,62 @@ func TestCheckAuthTime(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		policy  *EmailPolicy
+		wantErr error
+	}{
+		{
+			name:    "no policy",
+			payload: `{}`,
+		},
+		{
+			name:    "missing",
+			payload: `{}`,
+			policy:  &EmailPolicy{},
+			wantErr: ErrEmailNotVerified,
+		},
+		{
+			name:    "not verified",
+			payload: `{"email":"jdoe@example.com","email_verified":false}`,
+			policy:  &EmailPolicy{},
+			wantErr: ErrEmailNotVerified,
+		},
+		{
+			name:    "verified",
+			payload: `{"email":"jdoe@example.com","email_verified":"true"}`,
+			policy:  &EmailPolicy{},
+		},
+		{
+			name:    "domain not allowed",
+			payload: `{"email":"jdoe@example.org","email_verified":true}`,
+			policy:  &EmailPolicy{Domains: []string{"example.com"}},
+			wantErr: ErrEmailDomain,
+		},
+		{
+			name:    "domain allowed",
+			payload: `{"email":"jdoe@Example.com","email_verified":true}`,
+			policy:  &EmailPolicy{Domains: []string{"example.org", "example.com"}},
+		},
+		{
+			name:    "hosted domain missing",
+			payload: `{"email":"jdoe@example.com","email_verified":true}`,
+			policy:  &EmailPolicy{HostedDomains: []string{"example.com"}},
+			wantErr: ErrHostedDomain,
+		},
+		{
+			name:    "hosted domain allowed",
+			payload: `{"email":"jdoe@example.com","email_verified":true,"hd":"example.com"}`,
+			policy:  &EmailPolicy{HostedDomains: []string{"example.com"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckEmail([]byte(tt.payload), tt.policy)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
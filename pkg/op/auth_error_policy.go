@@ -0,0 +1,90 @@
+package op
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// AuthErrorAction is the outcome of the [AuthErrorPolicy]
+// for an error of an auth request.
+type AuthErrorAction int
+
+const (
+	// AuthErrorRedirect returns the error to the redirect_uri of the client
+	// (RFC 6749, section 4.1.2.1).
+	AuthErrorRedirect AuthErrorAction = iota
+	// AuthErrorRender responds with the error to the user agent,
+	// without redirecting it to the client.
+	AuthErrorRender
+)
+
+func (a AuthErrorAction) String() string {
+	switch a {
+	case AuthErrorRedirect:
+		return "redirect"
+	case AuthErrorRender:
+		return "render"
+	}
+	return "unknown"
+}
+
+// AuthErrorPolicy decides whether the error of an auth request is redirected
+// to the client or rendered, see [WithAuthErrorPolicy].
+// It is passed the default action, which renders the error if the redirect_uri
+// is missing or was not validated, like for an invalid redirect_uri or an unknown
+// client, and redirects all others.
+//
+// Errors without a validated redirect_uri are always rendered, as redirecting
+// them would make the Provider an open redirector. Returning [AuthErrorRedirect]
+// for them has no effect.
+type AuthErrorPolicy func(ctx context.Context, authReq ErrAuthRequest, err *oidc.Error, action AuthErrorAction) AuthErrorAction
+
+type authErrorPolicyGetter interface {
+	AuthErrorPolicy() AuthErrorPolicy
+}
+
+// authErrorAction returns the action for the error of the auth request,
+// decided by the AuthErrorPolicy of v, if it has one.
+func authErrorAction(ctx context.Context, v any, authReq ErrAuthRequest, e *oidc.Error) AuthErrorAction {
+	redirectable := authReq.GetRedirectURI() != "" && !e.IsRedirectDisabled()
+	action := AuthErrorRender
+	if redirectable {
+		action = AuthErrorRedirect
+	}
+	if getter, ok := v.(authErrorPolicyGetter); ok {
+		if policy := getter.AuthErrorPolicy(); policy != nil {
+			action = policy(ctx, authReq, e, action)
+		}
+	}
+	if !redirectable {
+		return AuthErrorRender
+	}
+	return action
+}
+
+// AuthErrorRenderer writes the response of an auth request error, which is not
+// redirected to the client, with the status code, see [WithAuthErrorRenderer].
+// Without, the error is written as plain text, or as HTML if preferred by the user agent.
+type AuthErrorRenderer func(w http.ResponseWriter, r *http.Request, err *oidc.Error, statusCode int)
+
+type authErrorRendererGetter interface {
+	AuthErrorRenderer() AuthErrorRenderer
+}
+
+// renderAuthError writes the error with the AuthErrorRenderer of v, if it has one,
+// or as plain text with the message, or HTML if preferred by the Accept header.
+func renderAuthError(w http.ResponseWriter, r *http.Request, v any, e *oidc.Error, message string) {
+	if getter, ok := v.(authErrorRendererGetter); ok {
+		if renderer := getter.AuthErrorRenderer(); renderer != nil {
+			renderer(w, r, e, http.StatusBadRequest)
+			return
+		}
+	}
+	if Negotiate(r, ContentTypeText, ContentTypeHTML) == ContentTypeHTML {
+		writeHTMLError(w, e, http.StatusBadRequest)
+		return
+	}
+	WriteText(w, message, http.StatusBadRequest)
+}
@@ -0,0 +1,106 @@
+package op_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthRequestError_AuthErrorPolicy(t *testing.T) {
+	var decided []op.AuthErrorAction
+	policy := func(_ context.Context, _ op.ErrAuthRequest, err *oidc.Error, action op.AuthErrorAction) op.AuthErrorAction {
+		decided = append(decided, action)
+		switch err.ErrorType {
+		case oidc.LoginRequired:
+			return op.AuthErrorRender
+		case oidc.InvalidRequest:
+			return op.AuthErrorRedirect
+		}
+		return action
+	}
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig,
+		storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(), op.WithAuthErrorPolicy(policy),
+	)
+	require.NoError(t, err)
+	authReq := &oidc.AuthRequest{
+		ClientID:     "web",
+		RedirectURI:  "https://client.example.com/callback",
+		ResponseType: oidc.ResponseTypeCode,
+		State:        "state",
+	}
+
+	tests := []struct {
+		name         string
+		err          error
+		wantStatus   int
+		wantDecided  op.AuthErrorAction
+		wantLocation string
+	}{
+		{
+			name:         "redirected",
+			err:          oidc.ErrAccessDenied(),
+			wantStatus:   http.StatusFound,
+			wantDecided:  op.AuthErrorRedirect,
+			wantLocation: "https://client.example.com/callback?error=access_denied&error_description=The+authorization+request+was+denied.&state=state",
+		},
+		{
+			name:        "rendered by policy",
+			err:         oidc.ErrLoginRequired(),
+			wantStatus:  http.StatusBadRequest,
+			wantDecided: op.AuthErrorRedirect,
+		},
+		{
+			name:        "unvalidated redirect_uri",
+			err:         oidc.ErrInvalidRequestRedirectURI(),
+			wantStatus:  http.StatusBadRequest,
+			wantDecided: op.AuthErrorRender,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decided = nil
+			w := httptest.NewRecorder()
+			op.AuthRequestError(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), authReq, tt.err, provider)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, []op.AuthErrorAction{tt.wantDecided}, decided)
+			if tt.wantLocation != "" {
+				assert.Equal(t, tt.wantLocation, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestAuthErrorAction_String(t *testing.T) {
+	assert.Equal(t, "redirect", op.AuthErrorRedirect.String())
+	assert.Equal(t, "render", op.AuthErrorRender.String())
+}
+
+func TestAuthRequestError_AuthErrorRenderer(t *testing.T) {
+	renderer := func(w http.ResponseWriter, _ *http.Request, err *oidc.Error, statusCode int) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(statusCode)
+		w.Write([]byte("<p>" + string(err.ErrorType) + "</p>"))
+	}
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig,
+		storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(), op.WithAuthErrorRenderer(renderer),
+	)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	op.AuthRequestError(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), &oidc.AuthRequest{ClientID: "web"}, oidc.ErrInvalidRequestRedirectURI(), provider)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "<p>invalid_request</p>", w.Body.String())
+
+	w = httptest.NewRecorder()
+	op.AuthRequestError(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), nil, oidc.ErrInvalidRequest(), provider)
+	assert.Equal(t, "<p>invalid_request</p>", w.Body.String())
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"html/template"
 	"log/slog"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
@@ -92,67 +93,154 @@ func Authorize(w http.ResponseWriter, r *http.Request, authorizer Authorizer) {
 		AuthRequestError(w, r, nil, err, authorizer)
 		return
 	}
-	if authReq.RequestParam != "" && authorizer.RequestObjectSupported() {
-		err = ParseRequestObject(ctx, authReq, authorizer.Storage(), IssuerFromContext(ctx))
+	authReq, err = resolveAuthRequest(ctx, authorizer, authReq, true)
+	if err != nil {
+		AuthRequestError(w, r, nil, err, authorizer)
+		return
+	}
+	client, userID, err := validateAuthorizeRequest(ctx, authorizer, authReq)
+	if err != nil {
+		AuthRequestError(w, r, authReq, err, authorizer)
+		return
+	}
+	req, err := createAuthRequest(ctx, authorizer, authorizer.Storage(), authReq, userID, client)
+	if err != nil {
+		AuthRequestError(w, r, authReq, oidc.DefaultToServerError(err, "unable to save auth request"), authorizer)
+		return
+	}
+	silent, err := authorizeWithoutLogin(ctx, authorizer, authorizer.Storage(), req, authReq, userID, r.Header)
+	if err != nil {
+		AuthRequestError(w, r, authReq, err, authorizer)
+		return
+	}
+	if silent {
+		// reload as the request was completed by the storage
+		req, err = authorizer.Storage().AuthRequestByID(ctx, req.GetID())
 		if err != nil {
-			AuthRequestError(w, r, nil, err, authorizer)
+			AuthRequestError(w, r, authReq, oidc.DefaultToServerError(err, "unable to load auth request"), authorizer)
 			return
 		}
+		AuthResponse(req, authorizer, w, r)
+		return
+	}
+	RedirectToLogin(req.GetID(), client, w, r)
+}
+
+// resolveAuthRequest returns the auth request with the parameters of its pushed
+// auth request or request object and checks its client_id and redirect_uri.
+// Its errors must not be redirected, as the redirect_uri is not validated yet.
+// A pushed auth request is consumed, if consume is set.
+func resolveAuthRequest(ctx context.Context, authorizer Authorizer, authReq *oidc.AuthRequest, consume bool) (*oidc.AuthRequest, error) {
+	authReq, err := resolvePushedAuthRequest(ctx, authorizer, authorizer.Storage(), authReq, consume)
+	if err != nil {
+		return nil, err
+	}
+	if authReq.RequestParam != "" && authorizer.RequestObjectSupported() {
+		err = parseRequestObject(ctx, authReq, authorizer.Storage(), IssuerFromContext(ctx), allowInsecure(authorizer), clientJWKSCache(authorizer))
+		if err != nil {
+			return nil, err
+		}
 	}
 	if authReq.ClientID == "" {
-		AuthRequestError(w, r, nil, fmt.Errorf("auth request is missing client_id"), authorizer)
-		return
+		return nil, fmt.Errorf("auth request is missing client_id")
 	}
 	if authReq.RedirectURI == "" {
-		AuthRequestError(w, r, nil, fmt.Errorf("auth request is missing redirect_uri"), authorizer)
-		return
+		return nil, fmt.Errorf("auth request is missing redirect_uri")
 	}
+	return authReq, nil
+}
 
-	var client Client
+// validateAuthorizeRequest validates the resolved auth request for its client,
+// or by the [AuthorizeValidator] of the authorizer, in which case the returned client is nil.
+// It returns the internal subject of the id_token_hint, if any.
+func validateAuthorizeRequest(ctx context.Context, authorizer Authorizer, authReq *oidc.AuthRequest) (client Client, userID string, err error) {
 	validation := func(ctx context.Context, authReq *oidc.AuthRequest, storage Storage, verifier *IDTokenHintVerifier) (sub string, err error) {
 		client, err = authorizer.Storage().GetClientByClientID(ctx, authReq.ClientID)
 		if err != nil {
 			return "", oidc.ErrInvalidRequestRedirectURI().WithDescription("unable to retrieve client by id").WithParent(err)
 		}
-		return ValidateAuthRequestClient(ctx, authReq, client, verifier)
+		sub, err = ValidateAuthRequestClient(ctx, authReq, client, verifier)
+		if err != nil {
+			return "", err
+		}
+		if err = ValidateAuthReqPublicClient(client, authReq, publicClientPolicy(authorizer)); err != nil {
+			return "", err
+		}
+		if err = ValidateAuthReqDeprecations(ctx, authorizer, authReq); err != nil {
+			return "", err
+		}
+		if err = ValidateAuthReqResponseMode(authorizer, client, authReq); err != nil {
+			return "", err
+		}
+		if err = ValidateAuthReqAuthorizationDetails(authorizer, authReq); err != nil {
+			return "", err
+		}
+		sub, err = internalSubject(ctx, storage, sub, client.GetID())
+		if err != nil {
+			return "", oidc.ErrLoginRequired().WithDescription("The id_token_hint is invalid.").WithParent(err)
+		}
+		if err = validateLoginHints(ctx, storage, authReq, client); err != nil {
+			return "", err
+		}
+		return sub, nil
 	}
 	if validator, ok := authorizer.(AuthorizeValidator); ok {
 		validation = validator.ValidateAuthRequest
 	}
-	userID, err := validation(ctx, authReq, authorizer.Storage(), authorizer.IDTokenHintVerifier(ctx))
+	userID, err = validation(ctx, authReq, authorizer.Storage(), authorizer.IDTokenHintVerifier(ctx))
 	if err != nil {
-		AuthRequestError(w, r, authReq, err, authorizer)
-		return
+		return nil, "", err
 	}
 	if authReq.RequestParam != "" {
-		AuthRequestError(w, r, authReq, oidc.ErrRequestNotSupported(), authorizer)
-		return
+		return nil, "", oidc.ErrRequestNotSupported()
 	}
-	req, err := authorizer.Storage().CreateAuthRequest(ctx, authReq, userID)
-	if err != nil {
-		AuthRequestError(w, r, authReq, oidc.DefaultToServerError(err, "unable to save auth request"), authorizer)
-		return
-	}
-	RedirectToLogin(req.GetID(), client, w, r)
+	return client, userID, nil
 }
 
 // ParseAuthorizeRequest parsed the http request into an oidc.AuthRequest
+//
+// Authorization requests may be sent by GET or POST (OpenID Connect Core 1.0, section 3.1.2.1),
+// the latter for requests exceeding practical URL lengths, e.g. because of request objects.
+// A POST request must send its parameters form-encoded in the body and is validated the same way.
+// Parameters sent in both the query and the body are rejected, so neither overrides the other.
 func ParseAuthorizeRequest(r *http.Request, decoder httphelper.Decoder) (*oidc.AuthRequest, error) {
-	err := r.ParseForm()
+	form, err := authorizeForm(r)
 	if err != nil {
-		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse form").WithParent(err)
+		return nil, err
 	}
 	authReq := new(oidc.AuthRequest)
-	err = decoder.Decode(authReq, r.Form)
+	err = decoder.Decode(authReq, form)
 	if err != nil {
 		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse auth request").WithParent(err)
 	}
 	return authReq, nil
 }
 
+func authorizeForm(r *http.Request) (url.Values, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse form").WithParent(err)
+	}
+	if r.Method != http.MethodPost {
+		return r.Form, nil
+	}
+	if contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType != "application/x-www-form-urlencoded" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("a posted auth request must be application/x-www-form-urlencoded")
+	}
+	for key := range r.URL.Query() {
+		if r.PostForm.Has(key) {
+			return nil, oidc.ErrInvalidRequest().WithDescription("parameter %s is included in the query and the body", key)
+		}
+	}
+	return r.Form, nil
+}
+
 // ParseRequestObject parse the `request` parameter, validates the token including the signature
 // and copies the token claims into the auth request
 func ParseRequestObject(ctx context.Context, authReq *oidc.AuthRequest, storage Storage, issuer string) error {
+	return parseRequestObject(ctx, authReq, storage, issuer, false, nil)
+}
+
+func parseRequestObject(ctx context.Context, authReq *oidc.AuthRequest, storage Storage, issuer string, allowInsecure bool, clientJWKS *ClientJWKSCache) error {
 	requestObject := new(oidc.RequestObject)
 	payload, err := oidc.ParseToken(authReq.RequestParam, requestObject)
 	if err != nil {
@@ -168,10 +256,10 @@ func ParseRequestObject(ctx context.Context, authReq *oidc.AuthRequest, storage
 	if requestObject.Issuer != requestObject.ClientID {
 		return oidc.ErrInvalidRequest().WithDescription("missing or wrong issuer in request")
 	}
-	if !slices.Contains(requestObject.Audience, issuer) {
+	if !oidc.ContainsIssuer(requestObject.Audience, issuer, allowInsecure) {
 		return oidc.ErrInvalidRequest().WithDescription("issuer missing in audience")
 	}
-	keySet := &jwtProfileKeySet{storage: storage, clientID: requestObject.Issuer}
+	keySet := &jwtProfileKeySet{storage: storage, clientID: requestObject.Issuer, clientJWKS: clientJWKS}
 	if err = oidc.CheckSignature(ctx, authReq.RequestParam, payload, requestObject, nil, keySet); err != nil {
 		return oidc.ErrInvalidRequest().WithParent(err).WithDescription(err.Error())
 	}
@@ -215,6 +303,15 @@ func CopyRequestObjectToAuthRequest(authReq *oidc.AuthRequest, requestObject *oi
 	if requestObject.LoginHint != "" {
 		authReq.LoginHint = requestObject.LoginHint
 	}
+	if requestObject.LoginHintToken != "" {
+		authReq.LoginHintToken = requestObject.LoginHintToken
+	}
+	if requestObject.DomainHint != "" {
+		authReq.DomainHint = requestObject.DomainHint
+	}
+	if requestObject.IDPHint != "" {
+		authReq.IDPHint = requestObject.IDPHint
+	}
 	if len(requestObject.ACRValues) > 0 {
 		authReq.ACRValues = requestObject.ACRValues
 	}
@@ -445,6 +542,10 @@ func AuthorizeCallback(w http.ResponseWriter, r *http.Request, authorizer Author
 		AuthRequestError(w, r, nil, err, authorizer)
 		return
 	}
+	if err = checkAuthRequestExpiry(r.Context(), authorizer, authorizer.Storage(), authReq); err != nil {
+		AuthRequestError(w, r, authReq, err, authorizer)
+		return
+	}
 	if !authReq.Done() {
 		AuthRequestError(w, r, authReq,
 			oidc.ErrInteractionRequired().WithDescription("Unfortunately, the user may be not logged in and/or additional interaction is required."),
@@ -476,6 +577,10 @@ func AuthResponse(authReq AuthRequest, authorizer Authorizer, w http.ResponseWri
 		AuthRequestError(w, r, authReq, err, authorizer)
 		return
 	}
+	if err = recordConsentReceipt(r.Context(), authorizer, authReq); err != nil {
+		AuthRequestError(w, r, authReq, err, authorizer)
+		return
+	}
 	if authReq.GetResponseType() == oidc.ResponseTypeCode {
 		AuthResponseCode(w, r, authReq, authorizer)
 		return
@@ -490,7 +595,7 @@ func AuthResponseCode(w http.ResponseWriter, r *http.Request, authReq AuthReques
 	r = r.WithContext(ctx)
 
 	var err error
-	if authReq.GetResponseMode() == oidc.ResponseModeFormPost {
+	if authReq.GetResponseMode().Base() == oidc.ResponseModeFormPost {
 		err = handleFormPostResponse(w, r, authReq, authorizer)
 	} else {
 		err = handleRedirectResponse(w, r, authReq, authorizer)
@@ -507,7 +612,11 @@ func handleFormPostResponse(w http.ResponseWriter, r *http.Request, authReq Auth
 	if err != nil {
 		return err
 	}
-	return AuthResponseFormPost(w, authReq.GetRedirectURI(), codeResponse, authorizer.Encoder())
+	response, _, err := jwtAuthResponse(r.Context(), authorizer, authReq.GetClientID(), authReq.GetResponseMode(), codeResponse)
+	if err != nil {
+		return err
+	}
+	return AuthResponseFormPost(w, authReq.GetRedirectURI(), response, authorizer.Encoder())
 }
 
 // handleRedirectResponse processes the authentication response using the redirect method
@@ -522,7 +631,7 @@ func handleRedirectResponse(w http.ResponseWriter, r *http.Request, authReq Auth
 
 // BuildAuthResponseCodeResponsePayload generates the authorization code response payload for the authentication request
 func BuildAuthResponseCodeResponsePayload(ctx context.Context, authReq AuthRequest, authorizer Authorizer) (*CodeResponseType, error) {
-	code, err := CreateAuthRequestCode(ctx, authReq, authorizer.Storage(), authorizer.Crypto())
+	code, err := createAuthRequestCode(ctx, authorizer, authReq, authorizer.Storage(), authorizer.Crypto())
 	if err != nil {
 		return nil, err
 	}
@@ -545,8 +654,12 @@ func BuildAuthResponseCallbackURL(ctx context.Context, authReq AuthRequest, auth
 	if err != nil {
 		return "", err
 	}
+	response, responseMode, err := jwtAuthResponse(ctx, authorizer, authReq.GetClientID(), authReq.GetResponseMode(), codeResponse)
+	if err != nil {
+		return "", err
+	}
 
-	return AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), authReq.GetResponseMode(), codeResponse, authorizer.Encoder())
+	return AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), responseMode, response, authorizer.Encoder())
 }
 
 // AuthResponseToken creates the successful token(s) authentication response
@@ -562,8 +675,14 @@ func AuthResponseToken(w http.ResponseWriter, r *http.Request, authReq AuthReque
 		return
 	}
 
-	if authReq.GetResponseMode() == oidc.ResponseModeFormPost {
-		err := AuthResponseFormPost(w, authReq.GetRedirectURI(), resp, authorizer.Encoder())
+	response, responseMode, err := jwtAuthResponse(r.Context(), authorizer, authReq.GetClientID(), authReq.GetResponseMode(), resp)
+	if err != nil {
+		AuthRequestError(w, r, authReq, err, authorizer)
+		return
+	}
+
+	if responseMode == oidc.ResponseModeFormPost {
+		err := AuthResponseFormPost(w, authReq.GetRedirectURI(), response, authorizer.Encoder())
 		if err != nil {
 			AuthRequestError(w, r, authReq, err, authorizer)
 			return
@@ -572,7 +691,7 @@ func AuthResponseToken(w http.ResponseWriter, r *http.Request, authReq AuthReque
 		return
 	}
 
-	callback, err := AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), authReq.GetResponseMode(), resp, authorizer.Encoder())
+	callback, err := AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), responseMode, response, authorizer.Encoder())
 	if err != nil {
 		AuthRequestError(w, r, authReq, err, authorizer)
 		return
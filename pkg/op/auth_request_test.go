@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -121,6 +122,69 @@ func TestParseAuthorizeRequest(t *testing.T) {
 	}
 }
 
+func TestParseAuthorizeRequest_post(t *testing.T) {
+	post := func(query, body, contentType string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/authorize?"+query, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+	const form = "application/x-www-form-urlencoded; charset=utf-8"
+	requestObject := strings.Repeat("a", 16*1024)
+
+	got, err := op.ParseAuthorizeRequest(post("", "client_id=web&scope=openid+profile&request="+requestObject, form), schema.NewDecoder())
+	require.NoError(t, err)
+	assert.Equal(t, &oidc.AuthRequest{
+		ClientID:     "web",
+		Scopes:       oidc.SpaceDelimitedArray{"openid", "profile"},
+		RequestParam: requestObject,
+	}, got)
+
+	got, err = op.ParseAuthorizeRequest(post("state=state", "client_id=web", form), schema.NewDecoder())
+	require.NoError(t, err)
+	assert.Equal(t, "state", got.State)
+
+	_, err = op.ParseAuthorizeRequest(post("client_id=other", "client_id=web", form), schema.NewDecoder())
+	assert.ErrorIs(t, err, oidc.ErrInvalidRequest())
+
+	_, err = op.ParseAuthorizeRequest(post("", `{"client_id":"web"}`, "application/json"), schema.NewDecoder())
+	assert.ErrorIs(t, err, oidc.ErrInvalidRequest())
+}
+
+func TestAuthorize_postParity(t *testing.T) {
+	provider, _ := newReloadTestProvider(t)
+	params := url.Values{
+		"client_id":     {"web"},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+		"state":         {"state"},
+	}
+	authorize := func(method string, params url.Values) *httptest.ResponseRecorder {
+		var r *http.Request
+		if method == http.MethodGet {
+			r = httptest.NewRequest(method, testIssuer+"authorize?"+params.Encode(), nil)
+		} else {
+			r = httptest.NewRequest(method, testIssuer+"authorize", strings.NewReader(params.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, r)
+		return w
+	}
+
+	// unregistered redirect_uri
+	params.Set("redirect_uri", "https://evil.example.com/callback")
+	get, post := authorize(http.MethodGet, params), authorize(http.MethodPost, params)
+	assert.Equal(t, http.StatusBadRequest, get.Code)
+	assert.Equal(t, get.Code, post.Code)
+	assert.Equal(t, get.Body.String(), post.Body.String())
+
+	params.Set("redirect_uri", "https://example.com")
+	get, post = authorize(http.MethodGet, params), authorize(http.MethodPost, params)
+	require.Equal(t, http.StatusFound, get.Code)
+	assert.Equal(t, get.Code, post.Code)
+	assert.Contains(t, post.Header().Get("Location"), "authRequestID=")
+}
+
 func TestValidateAuthRequest(t *testing.T) {
 	type args struct {
 		authRequest *oidc.AuthRequest
@@ -1610,3 +1674,91 @@ func TestBuildAuthResponseCallbackURL(t *testing.T) {
 		})
 	}
 }
+
+// promptNoneStorage completes auth requests with prompt=none
+// for the user agents with a session cookie.
+type promptNoneStorage struct {
+	*storage.Storage
+}
+
+func (s promptNoneStorage) AuthorizeSilently(_ context.Context, authReq op.AuthRequest, header http.Header) (op.PromptNoneState, error) {
+	if header.Get("Cookie") == "" {
+		return op.PromptNoneLoginRequired, nil
+	}
+	return op.PromptNoneAuthenticated, s.CheckUsernamePassword("test-user@localhost", "verysecure", authReq.GetID())
+}
+
+// CreateAuthRequest stores the auth request without its prompt,
+// as the example storage rejects prompt=none.
+func (s promptNoneStorage) CreateAuthRequest(ctx context.Context, authReq *oidc.AuthRequest, userID string) (op.AuthRequest, error) {
+	withoutPrompt := *authReq
+	withoutPrompt.Prompt = nil
+	return s.Storage.CreateAuthRequest(ctx, &withoutPrompt, userID)
+}
+
+func TestAuthorize_promptNone(t *testing.T) {
+	provider, err := op.NewProvider(testConfig, promptNoneStorage{storage.NewStorage(storage.NewUserStore(testIssuer))},
+		op.StaticIssuer(testIssuer), op.WithAllowInsecure())
+	require.NoError(t, err)
+	authReq := func() *oidc.AuthRequest {
+		return &oidc.AuthRequest{
+			ClientID:     "web",
+			RedirectURI:  "https://example.com",
+			ResponseType: oidc.ResponseTypeCode,
+			Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID},
+			State:        "state1",
+			Prompt:       oidc.SpaceDelimitedArray{oidc.PromptNone},
+		}
+	}
+	withCookie := http.Header{"Cookie": {"session=1"}}
+
+	t.Run("authorize handler", func(t *testing.T) {
+		authorize := func(header http.Header) *url.URL {
+			values := url.Values{
+				"client_id":     {"web"},
+				"redirect_uri":  {"https://example.com"},
+				"response_type": {string(oidc.ResponseTypeCode)},
+				"scope":         {oidc.ScopeOpenID},
+				"state":         {"state1"},
+				"prompt":        {oidc.PromptNone},
+			}
+			r := httptest.NewRequest(http.MethodGet, "/authorize?"+values.Encode(), nil)
+			for k, v := range header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			provider.ServeHTTP(w, r)
+			require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+			location, err := url.Parse(w.Header().Get("Location"))
+			require.NoError(t, err)
+			return location
+		}
+
+		location := authorize(nil)
+		assert.Equal(t, "example.com", location.Host)
+		assert.Equal(t, "login_required", location.Query().Get("error"))
+
+		location = authorize(withCookie)
+		assert.Equal(t, "example.com", location.Host)
+		assert.NotEmpty(t, location.Query().Get("code"))
+		assert.Equal(t, "state1", location.Query().Get("state"))
+	})
+	t.Run("legacy server", func(t *testing.T) {
+		ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+		server := op.NewLegacyServer(provider, *op.DefaultEndpoints)
+		client, err := provider.Storage().GetClientByClientID(ctx, "web")
+		require.NoError(t, err)
+		authorize := func(header http.Header) string {
+			redirect, err := server.Authorize(ctx, &op.ClientRequest[oidc.AuthRequest]{
+				Request: &op.Request[oidc.AuthRequest]{Header: header, Data: authReq()},
+				Client:  client,
+			})
+			require.NoError(t, err)
+			return redirect.URL
+		}
+
+		assert.Contains(t, authorize(http.Header{}), "error=login_required")
+		redirect := authorize(withCookie)
+		assert.True(t, strings.HasPrefix(redirect, testIssuer+"authorize/callback?id="), redirect)
+	})
+}
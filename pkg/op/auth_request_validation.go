@@ -0,0 +1,92 @@
+package op
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ValidatedAuthRequest is the result of [ValidateAuthRequestParams].
+type ValidatedAuthRequest struct {
+	// AuthRequest is the normalized auth request, including the parameters
+	// of its pushed auth request or request object.
+	AuthRequest *oidc.AuthRequest
+	Client      Client
+	// UserID is the internal subject of the id_token_hint, if any.
+	UserID string
+}
+
+// AuthRequestValidationError is returned by [ValidateAuthRequestParams]
+// for an invalid auth request.
+type AuthRequestValidationError struct {
+	Err *oidc.Error
+	// Redirect reports whether the error may be returned to the client
+	// at RedirectURL, as the authorization endpoint would, instead of
+	// being rendered to the user, e.g. for an unvalidated redirect_uri.
+	Redirect bool
+	// RedirectURL is the redirect_uri of the auth request with the error
+	// in the query or fragment, set if Redirect.
+	RedirectURL string
+}
+
+func (e *AuthRequestValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthRequestValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateAuthRequestParams validates and normalizes the parameters of an auth request
+// the way the authorization endpoint does, without creating the auth request,
+// so custom login UIs and BFFs can check a request before rendering anything.
+// A pushed auth request is looked up without consuming its request_uri.
+// The context must carry the issuer, see [ContextWithIssuer].
+// Errors are of type [*AuthRequestValidationError].
+func ValidateAuthRequestParams(ctx context.Context, authorizer Authorizer, params url.Values) (*ValidatedAuthRequest, error) {
+	ctx, span := tracer.Start(ctx, "ValidateAuthRequestParams")
+	defer span.End()
+
+	authReq := new(oidc.AuthRequest)
+	if err := authorizer.Decoder().Decode(authReq, params); err != nil {
+		return nil, authRequestValidationError(ctx, authorizer, nil, oidc.ErrInvalidRequest().WithDescription("cannot parse auth request").WithParent(err))
+	}
+	authReq, err := resolveAuthRequest(ctx, authorizer, authReq, false)
+	if err != nil {
+		return nil, authRequestValidationError(ctx, authorizer, nil, err)
+	}
+	client, userID, err := validateAuthorizeRequest(ctx, authorizer, authReq)
+	if err != nil {
+		return nil, authRequestValidationError(ctx, authorizer, authReq, err)
+	}
+	if client == nil {
+		client, err = authorizer.Storage().GetClientByClientID(ctx, authReq.ClientID)
+		if err != nil {
+			return nil, authRequestValidationError(ctx, authorizer, authReq, oidc.ErrInvalidRequestRedirectURI().WithDescription("unable to retrieve client by id").WithParent(err))
+		}
+	}
+	return &ValidatedAuthRequest{
+		AuthRequest: authReq,
+		Client:      client,
+		UserID:      userID,
+	}, nil
+}
+
+// authRequestValidationError wraps the error, with the redirect the authorization
+// endpoint would send for it, if the error may be redirected.
+func authRequestValidationError(ctx context.Context, authorizer Authorizer, authReq *oidc.AuthRequest, err error) error {
+	e := oidc.DefaultToServerError(err, err.Error())
+	if authReq == nil {
+		return &AuthRequestValidationError{Err: e}
+	}
+	redirect, err := tryErrorRedirect(ctx, authorizer, authReq, e, authorizer.Encoder(), authorizer.Logger())
+	if err != nil {
+		return &AuthRequestValidationError{Err: e}
+	}
+	return &AuthRequestValidationError{
+		Err:         e,
+		Redirect:    true,
+		RedirectURL: redirect.URL,
+	}
+}
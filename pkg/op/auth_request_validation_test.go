@@ -0,0 +1,82 @@
+package op_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestValidateAuthRequestParams(t *testing.T) {
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	authorizer := testProvider.(op.Authorizer)
+	params := func(kv ...string) url.Values {
+		values := url.Values{
+			"client_id":     {"web"},
+			"redirect_uri":  {"https://example.com"},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"state":         {"state1"},
+		}
+		for i := 0; i < len(kv); i += 2 {
+			values.Set(kv[i], kv[i+1])
+		}
+		return values
+	}
+	tests := []struct {
+		name         string
+		params       url.Values
+		wantErr      error
+		wantRedirect bool
+	}{
+		{
+			name:   "valid",
+			params: params(),
+		},
+		{
+			name:    "unknown client",
+			params:  params("client_id", "unknown"),
+			wantErr: oidc.ErrInvalidRequest(),
+		},
+		{
+			name:    "unregistered redirect_uri",
+			params:  params("redirect_uri", "https://evil.example.com"),
+			wantErr: oidc.ErrInvalidRequest(),
+		},
+		{
+			name:         "invalid prompt",
+			params:       params("prompt", "none login"),
+			wantErr:      oidc.ErrInvalidRequest(),
+			wantRedirect: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := op.ValidateAuthRequestParams(ctx, authorizer, tt.params)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				assert.Equal(t, "web", got.Client.GetID())
+				assert.Equal(t, "state1", got.AuthRequest.State)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			var validationErr *op.AuthRequestValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.wantRedirect, validationErr.Redirect)
+			if !tt.wantRedirect {
+				assert.Empty(t, validationErr.RedirectURL)
+				return
+			}
+			redirect, err := url.Parse(validationErr.RedirectURL)
+			require.NoError(t, err)
+			assert.Equal(t, "example.com", redirect.Host)
+			assert.Equal(t, "invalid_request", redirect.Query().Get("error"))
+			assert.Equal(t, "state1", redirect.Query().Get("state"))
+		})
+	}
+}
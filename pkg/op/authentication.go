@@ -0,0 +1,102 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrAuthenticationSubject = errors.New("authentication result without subject")
+	ErrAuthenticationTime    = errors.New("authentication time is in the future")
+	ErrAuthenticationStorage = errors.New("storage does not implement AuthenticationStorage")
+)
+
+// AuthenticationResult is reported by the Login UI, after it authenticated the end-user,
+// with [CompleteAuthentication]. It becomes the sub, amr, auth_time and acr claims
+// of the ID tokens of the auth request and might be kept in the session of the end-user,
+// e.g. to check the max_age of later auth requests with [AuthenticationResult.SatisfiesMaxAge].
+type AuthenticationResult struct {
+	// Subject is the id of the authenticated end-user.
+	Subject string
+	// Methods used for the authentication, like [oidc.AMRPassword] and [oidc.AMROneTimePassword].
+	Methods []string
+	// Time of the authentication, which defaults to now.
+	Time time.Time
+	// ACR is the Authentication Context Class Reference achieved, if any.
+	ACR string
+	// SessionID identifies the session of the end-user, issued as sid claim, if any.
+	SessionID string
+}
+
+// AuthenticationStorage is an optional interface of the [Storage],
+// which completes auth requests with the result of the authentication.
+type AuthenticationStorage interface {
+	// CompleteAuthentication marks the auth request as done and stores the result,
+	// which must be returned by GetSubject, GetAMR, GetAuthTime and GetACR
+	// of the [AuthRequest], as well as of the refresh token requests issued for it.
+	// The SessionID is returned by GetSessionID, see [SessionIDRequest].
+	CompleteAuthentication(ctx context.Context, authReqID string, result *AuthenticationResult) error
+}
+
+// SessionIDRequest is an optional interface of the [AuthRequest] and the refresh token
+// requests, which returns the SessionID of the [AuthenticationResult],
+// issued as sid claim of the ID tokens and JWT access tokens.
+// Refresh token requests must return the SessionID of the auth request,
+// the refresh token was issued for, so the sid is stable for the session.
+// Storages should set the sid of access tokens in the introspection response as well.
+type SessionIDRequest interface {
+	GetSessionID() string
+}
+
+// CompleteAuthentication validates the result of the authentication by the Login UI
+// and lets the [AuthenticationStorage] complete the auth request with it.
+// The time defaults to now and the time and methods are normalized.
+func CompleteAuthentication(ctx context.Context, storage Storage, authReqID string, result AuthenticationResult) error {
+	ctx, span := tracer.Start(ctx, "CompleteAuthentication")
+	defer span.End()
+
+	authStorage, ok := storage.(AuthenticationStorage)
+	if !ok {
+		return ErrAuthenticationStorage
+	}
+	if result.Subject == "" {
+		return ErrAuthenticationSubject
+	}
+	now := time.Now()
+	if result.Time.IsZero() {
+		result.Time = now
+	}
+	if result.Time.After(now.Add(time.Minute)) {
+		return ErrAuthenticationTime
+	}
+	// auth_time has a precision of seconds
+	result.Time = result.Time.UTC().Truncate(time.Second)
+	result.Methods = normalizeMethods(result.Methods)
+	return authStorage.CompleteAuthentication(ctx, authReqID, &result)
+}
+
+// normalizeMethods removes empty and duplicate methods, keeping the order.
+func normalizeMethods(methods []string) []string {
+	normalized := make([]string, 0, len(methods))
+	for _, method := range methods {
+		if method != "" && !slices.Contains(normalized, method) {
+			normalized = append(normalized, method)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// SatisfiesMaxAge reports if the authentication is recent enough for
+// the max_age (in seconds) of an auth request, see [oidc.AuthRequest].
+// Without max_age, any authentication is sufficient.
+func (r *AuthenticationResult) SatisfiesMaxAge(maxAge *uint) bool {
+	if maxAge == nil {
+		return true
+	}
+	return time.Since(r.Time) <= time.Duration(*maxAge)*time.Second
+}
@@ -0,0 +1,114 @@
+package op_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/muhlemmer/gu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestCompleteAuthentication(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	authReq, err := s.CreateAuthRequest(ctx, &oidc.AuthRequest{
+		ClientID:     "web",
+		RedirectURI:  "https://example.com",
+		Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID},
+		ResponseType: oidc.ResponseTypeCode,
+	}, "")
+	require.NoError(t, err)
+
+	authTime := time.Now().Add(-time.Minute)
+	err = op.CompleteAuthentication(ctx, s, authReq.GetID(), op.AuthenticationResult{
+		Subject:   "id1",
+		Methods:   []string{oidc.AMRPassword, oidc.AMROneTimePassword, "", oidc.AMRPassword},
+		Time:      authTime,
+		ACR:       "urn:example:loa:2",
+		SessionID: "session1",
+	})
+	require.NoError(t, err)
+
+	got, err := s.AuthRequestByID(ctx, authReq.GetID())
+	require.NoError(t, err)
+	assert.True(t, got.Done())
+	assert.Equal(t, "id1", got.GetSubject())
+	assert.Equal(t, []string{oidc.AMRPassword, oidc.AMROneTimePassword}, got.GetAMR())
+	assert.Equal(t, "urn:example:loa:2", got.GetACR())
+	assert.Equal(t, authTime.UTC().Truncate(time.Second), got.GetAuthTime())
+
+	client, err := s.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	idToken, err := op.CreateIDToken(ctx, testIssuer, got, time.Hour, "", "", s, client)
+	require.NoError(t, err)
+	claims := new(oidc.IDTokenClaims)
+	_, err = oidc.ParseToken(idToken, claims)
+	require.NoError(t, err)
+	assert.Equal(t, []string{oidc.AMRPassword, oidc.AMROneTimePassword}, claims.AuthenticationMethodsReferences)
+	assert.Equal(t, "urn:example:loa:2", claims.AuthenticationContextClassReference)
+	assert.Equal(t, "session1", claims.SessionID)
+	assert.Equal(t, authTime.Add(-client.ClockSkew()).Unix(), claims.GetAuthTime().Unix())
+}
+
+type storageWithoutAuthentication struct {
+	op.Storage
+}
+
+func TestCompleteAuthentication_error(t *testing.T) {
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	tests := []struct {
+		name    string
+		storage op.Storage
+		result  op.AuthenticationResult
+		wantErr error
+	}{
+		{
+			name:    "storage",
+			storage: storageWithoutAuthentication{s},
+			result:  op.AuthenticationResult{Subject: "id1"},
+			wantErr: op.ErrAuthenticationStorage,
+		},
+		{
+			name:    "subject",
+			storage: s,
+			wantErr: op.ErrAuthenticationSubject,
+		},
+		{
+			name:    "time",
+			storage: s,
+			result:  op.AuthenticationResult{Subject: "id1", Time: time.Now().Add(time.Hour)},
+			wantErr: op.ErrAuthenticationTime,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := op.CompleteAuthentication(context.Background(), tt.storage, "id", tt.result)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestAuthenticationResult_SatisfiesMaxAge(t *testing.T) {
+	result := &op.AuthenticationResult{Time: time.Now().Add(-time.Minute)}
+	tests := []struct {
+		name   string
+		maxAge *uint
+		want   bool
+	}{
+		{"none", nil, true},
+		{"larger", gu.Ptr[uint](300), true},
+		{"smaller", gu.Ptr[uint](30), false},
+		{"zero", gu.Ptr[uint](0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, result.SatisfiesMaxAge(tt.maxAge))
+		})
+	}
+}
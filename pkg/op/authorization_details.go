@@ -0,0 +1,99 @@
+package op
+
+import (
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// AuthorizationDetailsRequest is an optional interface that can be implemented by the
+// AuthRequest, RefreshTokenRequest and TokenRequest implementations of the Storage,
+// carrying the authorization details of Rich Authorization Requests (RFC 9396),
+// see [WithAuthorizationDetailTypes].
+//
+// The details are returned in the token response and set as claim of JWT access tokens.
+// Storages issuing opaque access tokens should record them in CreateAccessToken and
+// return them in the introspection response, so resource servers can enforce them.
+type AuthorizationDetailsRequest interface {
+	// GetAuthorizationDetails returns the authorization details granted to the request,
+	// typically the details of the auth request as approved by the end-user.
+	GetAuthorizationDetails() oidc.AuthorizationDetails
+	// SetCurrentAuthorizationDetails narrows the granted details of the request
+	// to the details requested at the token endpoint, like SetCurrentScopes.
+	SetCurrentAuthorizationDetails(details oidc.AuthorizationDetails)
+}
+
+type authorizationDetailTypesGetter interface {
+	AuthorizationDetailTypes() *oidc.AuthorizationDetailTypes
+}
+
+func authorizationDetailTypes(v any) *oidc.AuthorizationDetailTypes {
+	if getter, ok := v.(authorizationDetailTypesGetter); ok {
+		return getter.AuthorizationDetailTypes()
+	}
+	return nil
+}
+
+// ValidateAuthReqAuthorizationDetails validates the authorization_details of the auth request
+// with the types registered by [WithAuthorizationDetailTypes].
+func ValidateAuthReqAuthorizationDetails(provider any, authReq *oidc.AuthRequest) error {
+	if len(authReq.AuthorizationDetails) == 0 {
+		return nil
+	}
+	return authorizationDetailTypes(provider).Validate(authReq.AuthorizationDetails)
+}
+
+// ValidateTokenAuthorizationDetails validates the authorization_details of a token request,
+// which must be granted to the request (RFC 9396, section 6.1), and sets them as
+// current authorization details of the request.
+// If empty, the granted details of the request are used.
+func ValidateTokenAuthorizationDetails(provider any, requested oidc.AuthorizationDetails, request any) error {
+	if len(requested) == 0 {
+		return nil
+	}
+	detailsRequest, err := validateRequestedAuthorizationDetails(provider, requested, request)
+	if err != nil {
+		return err
+	}
+	granted := detailsRequest.GetAuthorizationDetails()
+	for _, detail := range requested {
+		if !granted.Contains(detail) {
+			return oidc.ErrInvalidAuthorizationDetails().WithDescription("authorization_details of type %s not granted", detail.Type)
+		}
+	}
+	detailsRequest.SetCurrentAuthorizationDetails(requested)
+	return nil
+}
+
+// validateClientCredentialsAuthorizationDetails sets the authorization_details of a
+// client_credentials request on the token request of the storage, which authorizes
+// them on the creation of the access token, as there is no prior grant.
+func validateClientCredentialsAuthorizationDetails(provider any, requested oidc.AuthorizationDetails, request TokenRequest) error {
+	if len(requested) == 0 {
+		return nil
+	}
+	detailsRequest, err := validateRequestedAuthorizationDetails(provider, requested, request)
+	if err != nil {
+		return err
+	}
+	detailsRequest.SetCurrentAuthorizationDetails(requested)
+	return nil
+}
+
+func validateRequestedAuthorizationDetails(provider any, requested oidc.AuthorizationDetails, request any) (AuthorizationDetailsRequest, error) {
+	if err := authorizationDetailTypes(provider).Validate(requested); err != nil {
+		return nil, err
+	}
+	detailsRequest, ok := request.(AuthorizationDetailsRequest)
+	if !ok {
+		return nil, oidc.ErrInvalidAuthorizationDetails().WithDescription("authorization_details not supported for this grant")
+	}
+	return detailsRequest, nil
+}
+
+// grantedAuthorizationDetails returns the authorization details of the request,
+// if it implements [AuthorizationDetailsRequest].
+func grantedAuthorizationDetails(request any) oidc.AuthorizationDetails {
+	if detailsRequest, ok := request.(AuthorizationDetailsRequest); ok {
+		return detailsRequest.GetAuthorizationDetails()
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package op
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type authorizationDetailsProvider struct {
+	types *oidc.AuthorizationDetailTypes
+}
+
+func (p authorizationDetailsProvider) AuthorizationDetailTypes() *oidc.AuthorizationDetailTypes {
+	return p.types
+}
+
+type authorizationDetailsRequest struct {
+	TokenRequest
+	granted oidc.AuthorizationDetails
+	current oidc.AuthorizationDetails
+}
+
+func (r *authorizationDetailsRequest) GetAuthorizationDetails() oidc.AuthorizationDetails {
+	if r.current != nil {
+		return r.current
+	}
+	return r.granted
+}
+
+func (r *authorizationDetailsRequest) SetCurrentAuthorizationDetails(details oidc.AuthorizationDetails) {
+	r.current = details
+}
+
+func TestValidateAuthReqAuthorizationDetails(t *testing.T) {
+	provider := authorizationDetailsProvider{types: oidc.NewAuthorizationDetailTypes().Register("payment_initiation", nil)}
+	tests := []struct {
+		name     string
+		provider any
+		details  oidc.AuthorizationDetails
+		wantErr  bool
+	}{
+		{"none", nil, nil, false},
+		{"supported", provider, oidc.AuthorizationDetails{{Type: "payment_initiation"}}, false},
+		{"unsupported", provider, oidc.AuthorizationDetails{{Type: "account_information"}}, true},
+		{"not enabled", nil, oidc.AuthorizationDetails{{Type: "payment_initiation"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAuthReqAuthorizationDetails(tt.provider, &oidc.AuthRequest{AuthorizationDetails: tt.details})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidAuthorizationDetails())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateTokenAuthorizationDetails(t *testing.T) {
+	provider := authorizationDetailsProvider{types: oidc.NewAuthorizationDetailTypes().
+		Register("payment_initiation", nil).
+		Register("account_information", nil)}
+	payment := oidc.AuthorizationDetail{Type: "payment_initiation", Actions: []string{"initiate"}, Fields: map[string]any{"creditorName": "Merchant A"}}
+	accounts := oidc.AuthorizationDetail{Type: "account_information", Actions: []string{"read"}}
+	granted := oidc.AuthorizationDetails{payment, accounts}
+
+	tests := []struct {
+		name        string
+		requested   oidc.AuthorizationDetails
+		request     any
+		wantErr     bool
+		wantCurrent oidc.AuthorizationDetails
+	}{
+		{"none", nil, &authorizationDetailsRequest{granted: granted}, false, granted},
+		{"subset", oidc.AuthorizationDetails{accounts}, &authorizationDetailsRequest{granted: granted}, false, oidc.AuthorizationDetails{accounts}},
+		{"not granted", oidc.AuthorizationDetails{{Type: "account_information", Actions: []string{"write"}}}, &authorizationDetailsRequest{granted: granted}, true, nil},
+		{"unsupported type", oidc.AuthorizationDetails{{Type: "unknown"}}, &authorizationDetailsRequest{granted: granted}, true, nil},
+		{"request without details", oidc.AuthorizationDetails{accounts}, struct{ TokenRequest }{}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTokenAuthorizationDetails(provider, tt.requested, tt.request)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidAuthorizationDetails())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCurrent, grantedAuthorizationDetails(tt.request))
+		})
+	}
+}
+
+func Test_validateClientCredentialsAuthorizationDetails(t *testing.T) {
+	provider := authorizationDetailsProvider{types: oidc.NewAuthorizationDetailTypes().Register("account_information", nil)}
+	requested := oidc.AuthorizationDetails{{Type: "account_information", Actions: []string{"read"}}}
+
+	request := &authorizationDetailsRequest{}
+	assert.NoError(t, validateClientCredentialsAuthorizationDetails(provider, requested, request))
+	assert.Equal(t, requested, grantedAuthorizationDetails(request))
+
+	err := validateClientCredentialsAuthorizationDetails(provider, requested, struct{ TokenRequest }{})
+	assert.ErrorIs(t, err, oidc.ErrInvalidAuthorizationDetails())
+}
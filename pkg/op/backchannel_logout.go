@@ -0,0 +1,205 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// HasBackChannelLogout is an optional interface that can be implemented by implementors
+// of Client, which registered a backchannel_logout_uri
+// (OpenID Connect Back-Channel Logout 1.0, section 2.2).
+type HasBackChannelLogout interface {
+	Client
+	BackChannelLogoutURI() string
+	// BackChannelLogoutSessionRequired reports if the client requires the sid claim.
+	BackChannelLogoutSessionRequired() bool
+}
+
+// BackChannelLogoutSession is the session of a client,
+// which is terminated together with the session of the user.
+type BackChannelLogoutSession struct {
+	ClientID string
+	// UserID is mapped to the subject of the client, as in the ID tokens.
+	UserID string
+	// SessionID is sent as sid claim, if not empty.
+	SessionID string
+}
+
+// BackChannelLogoutStorage is an optional interface that can be implemented by implementors
+// of Storage. If Back-Channel Logout is supported by the [Configuration], the sessions
+// are notified by a logout token posted to the backchannel_logout_uri of their client,
+// after the session of the [EndSessionRequest] was terminated.
+// Clients not implementing [HasBackChannelLogout] are skipped.
+type BackChannelLogoutStorage interface {
+	BackChannelLogoutSessions(ctx context.Context, session *EndSessionRequest) ([]BackChannelLogoutSession, error)
+}
+
+// DefaultLogoutTokenLifetime is the lifetime of logout tokens,
+// which are meant to be used immediately.
+const DefaultLogoutTokenLifetime = 2 * time.Minute
+
+// DefaultBackChannelLogoutTimeout limits each logout token posted without
+// [WithBackChannelLogoutQueue], as the logout of the user waits for the clients.
+const DefaultBackChannelLogoutTimeout = 5 * time.Second
+
+var ErrBackChannelLogoutSessionRequired = errors.New("client requires the sid of the session for back-channel logout")
+
+// CreateLogoutToken creates the logout token of the session for the client,
+// signed by the key of the storage.
+func CreateLogoutToken(ctx context.Context, issuer string, session BackChannelLogoutSession, client Client, storage Storage) (string, error) {
+	return createLogoutToken(ctx, issuer, session, client, storage, storage)
+}
+
+func createLogoutToken(ctx context.Context, issuer string, session BackChannelLogoutSession, client Client, storage Storage, keys signingKeyGetter) (string, error) {
+	ctx, span := tracer.Start(ctx, "CreateLogoutToken")
+	defer span.End()
+
+	if required, ok := client.(HasBackChannelLogout); ok && required.BackChannelLogoutSessionRequired() && session.SessionID == "" {
+		return "", ErrBackChannelLogoutSessionRequired
+	}
+	subject, err := externalSubject(ctx, storage, session.UserID, client.GetID())
+	if err != nil {
+		return "", err
+	}
+	tokenID, err := randomString(ctx, logoutTokenIDBytes)
+	if err != nil {
+		return "", err
+	}
+	exp := time.Now().UTC().Add(client.ClockSkew()).Add(DefaultLogoutTokenLifetime)
+	claims := oidc.NewLogoutTokenClaims(issuer, subject, oidc.Audience{client.GetID()}, exp, tokenID, session.SessionID, client.ClockSkew())
+	signingKey, err := keys.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	signer, err := SignerFromKeyWithHeader(signingKey, &TokenHeader{Type: oidc.LogoutTokenType})
+	if err != nil {
+		return "", err
+	}
+	return crypto.SignCanonical(claims, signer)
+}
+
+// 16 bytes gives 128 bit of entropy.
+const logoutTokenIDBytes = 16
+
+// SendBackChannelLogout posts the logout token to the backchannel_logout_uri
+// and expects the client to respond with 200 OK or 204 No Content.
+func SendBackChannelLogout(ctx context.Context, httpClient *http.Client, logoutURI, logoutToken string) error {
+	form := url.Values{"logout_token": {logoutToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, logoutURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("back-channel logout: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type backChannelLogoutGetter interface {
+	BackChannelLogoutSupported() bool
+}
+
+type backChannelLogoutClientGetter interface {
+	BackChannelLogoutClient() *http.Client
+}
+
+// defaultBackChannelLogoutClient only posts to public addresses,
+// as the backchannel_logout_uri is part of the client metadata.
+var defaultBackChannelLogoutClient = sync.OnceValue(httphelper.PublicHTTPClient)
+
+func backChannelLogoutClient(v any) *http.Client {
+	if getter, ok := v.(backChannelLogoutClientGetter); ok && getter.BackChannelLogoutClient() != nil {
+		return getter.BackChannelLogoutClient()
+	}
+	return defaultBackChannelLogoutClient()
+}
+
+type backChannelLogoutQueueGetter interface {
+	BackChannelLogoutQueue() *SoftFailQueue
+}
+
+func backChannelLogoutQueue(v any) *SoftFailQueue {
+	if getter, ok := v.(backChannelLogoutQueueGetter); ok {
+		return getter.BackChannelLogoutQueue()
+	}
+	return nil
+}
+
+// backChannelLogout notifies the clients of the sessions terminated with the session,
+// if supported. Failures are logged and do not fail the logout of the user.
+// The logout tokens are posted by the [SoftFailQueue] of [WithBackChannelLogoutQueue], if set,
+// or else one after the other, each limited by [DefaultBackChannelLogoutTimeout].
+func backChannelLogout(ctx context.Context, ender SessionEnder, session *EndSessionRequest) {
+	if supported, ok := ender.(backChannelLogoutGetter); !ok || !supported.BackChannelLogoutSupported() {
+		return
+	}
+	storage, ok := ender.Storage().(BackChannelLogoutStorage)
+	if !ok {
+		return
+	}
+	ctx, span := tracer.Start(ctx, "backChannelLogout")
+	defer span.End()
+
+	logger := ender.Logger()
+	sessions, err := storage.BackChannelLogoutSessions(ctx, session)
+	if err != nil {
+		logger.WarnContext(ctx, "back-channel logout: unable to get sessions", "error", err)
+		return
+	}
+	httpClient := backChannelLogoutClient(ender)
+	queue := backChannelLogoutQueue(ender)
+	keys := signingKeys(ender, ender.Storage())
+	for _, s := range sessions {
+		clientLogger := logger.With(slog.String("client_id", s.ClientID))
+		client, err := ender.Storage().GetClientByClientID(ctx, s.ClientID)
+		if err != nil {
+			clientLogger.WarnContext(ctx, "back-channel logout: unable to get client", "error", err)
+			continue
+		}
+		logoutClient, ok := client.(HasBackChannelLogout)
+		if !ok || logoutClient.BackChannelLogoutURI() == "" {
+			continue
+		}
+		token, err := createLogoutToken(ctx, IssuerFromContext(ctx), s, client, ender.Storage(), keys)
+		if err != nil {
+			clientLogger.WarnContext(ctx, "back-channel logout: unable to create logout token", "error", err)
+			continue
+		}
+		if queue != nil {
+			logoutURI, clientID := logoutClient.BackChannelLogoutURI(), s.ClientID
+			queue.Enqueue(ctx, func(ctx context.Context) error {
+				if err := SendBackChannelLogout(ctx, httpClient, logoutURI, token); err != nil {
+					return fmt.Errorf("client %s: %w", clientID, err)
+				}
+				return nil
+			})
+			continue
+		}
+		if err = sendBackChannelLogoutTimeout(ctx, httpClient, logoutClient.BackChannelLogoutURI(), token); err != nil {
+			clientLogger.WarnContext(ctx, "back-channel logout failed", "error", err)
+		}
+	}
+}
+
+func sendBackChannelLogoutTimeout(ctx context.Context, httpClient *http.Client, logoutURI, logoutToken string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultBackChannelLogoutTimeout)
+	defer cancel()
+	return SendBackChannelLogout(ctx, httpClient, logoutURI, logoutToken)
+}
@@ -0,0 +1,120 @@
+package op
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type logoutSigningKey struct{}
+
+func (logoutSigningKey) SignatureAlgorithm() jose.SignatureAlgorithm { return tu.SignatureAlgorithm }
+func (logoutSigningKey) Key() any                                    { return tu.WebKey.Key }
+func (logoutSigningKey) ID() string                                  { return tu.WebKey.KeyID }
+
+type logoutClient struct {
+	Client
+	id              string
+	uri             string
+	sessionRequired bool
+}
+
+func (c *logoutClient) GetID() string                          { return c.id }
+func (c *logoutClient) ClockSkew() time.Duration               { return 0 }
+func (c *logoutClient) BackChannelLogoutURI() string           { return c.uri }
+func (c *logoutClient) BackChannelLogoutSessionRequired() bool { return c.sessionRequired }
+
+type logoutStorage struct {
+	Storage
+	clients  map[string]Client
+	sessions []BackChannelLogoutSession
+}
+
+func (s *logoutStorage) GetClientByClientID(_ context.Context, id string) (Client, error) {
+	return s.clients[id], nil
+}
+
+func (s *logoutStorage) SigningKey(context.Context) (SigningKey, error) {
+	return logoutSigningKey{}, nil
+}
+
+func (s *logoutStorage) BackChannelLogoutSessions(context.Context, *EndSessionRequest) ([]BackChannelLogoutSession, error) {
+	return s.sessions, nil
+}
+
+type logoutEnder struct {
+	SessionEnder
+	storage    Storage
+	supported  bool
+	queue      *SoftFailQueue
+	httpClient *http.Client
+}
+
+func (e *logoutEnder) Storage() Storage                 { return e.storage }
+func (e *logoutEnder) Logger() *slog.Logger             { return slog.Default() }
+func (e *logoutEnder) BackChannelLogoutSupported() bool { return e.supported }
+func (e *logoutEnder) BackChannelLogoutQueue() *SoftFailQueue {
+	return e.queue
+}
+func (e *logoutEnder) BackChannelLogoutClient() *http.Client {
+	return e.httpClient
+}
+
+func Test_backChannelLogout(t *testing.T) {
+	var tokens []string
+	rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.PostFormValue("logout_token"))
+	}))
+	defer rp.Close()
+	storage := &logoutStorage{
+		clients: map[string]Client{
+			"client1":  &logoutClient{id: "client1", uri: rp.URL},
+			"client2":  &logoutClient{id: "client2", uri: rp.URL, sessionRequired: true},
+			"frontend": &logoutClient{id: "frontend"},
+		},
+		sessions: []BackChannelLogoutSession{
+			{ClientID: "client1", UserID: "user1", SessionID: "sid1"},
+			{ClientID: "client2", UserID: "user1"},
+			{ClientID: "frontend", UserID: "user1", SessionID: "sid1"},
+		},
+	}
+	ctx := ContextWithIssuer(context.Background(), "https://op.example.com")
+
+	backChannelLogout(ctx, &logoutEnder{storage: storage}, &EndSessionRequest{UserID: "user1"})
+	assert.Empty(t, tokens)
+
+	backChannelLogout(ctx, &logoutEnder{storage: storage, supported: true}, &EndSessionRequest{UserID: "user1"})
+	assert.Empty(t, tokens, "the default client does not post to loopback addresses")
+
+	backChannelLogout(ctx, &logoutEnder{storage: storage, supported: true, httpClient: rp.Client()}, &EndSessionRequest{UserID: "user1"})
+	require.Len(t, tokens, 1)
+	jws, err := jose.ParseSigned(tokens[0], []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+	require.NoError(t, err)
+	assert.Equal(t, oidc.LogoutTokenType, jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType])
+	claims := new(oidc.LogoutTokenClaims)
+	_, err = oidc.ParseToken(tokens[0], claims)
+	require.NoError(t, err)
+	assert.Equal(t, "https://op.example.com", claims.Issuer)
+	assert.Equal(t, "user1", claims.Subject)
+	assert.Equal(t, oidc.Audience{"client1"}, claims.Audience)
+	assert.Equal(t, "sid1", claims.SessionID)
+	assert.Contains(t, claims.Events, oidc.BackChannelLogoutEvent)
+	assert.NotEmpty(t, claims.JWTID)
+
+	queue, err := NewSoftFailQueue("back-channel logout", SoftFailConfig{})
+	require.NoError(t, err)
+	backChannelLogout(ctx, &logoutEnder{storage: storage, supported: true, queue: queue, httpClient: rp.Client()}, &EndSessionRequest{UserID: "user1"})
+	require.NoError(t, queue.Close(context.Background()))
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, SoftFailStats{Enqueued: 1, Completed: 1}, queue.Stats())
+}
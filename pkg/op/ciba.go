@@ -0,0 +1,432 @@
+package op
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+const (
+	// DefaultBackchannelAuthenticationLifetime is the default lifetime of the auth_req_id.
+	DefaultBackchannelAuthenticationLifetime = 2 * time.Minute
+	// DefaultBackchannelPollInterval is the default minimum interval between
+	// token requests of clients polling with an auth_req_id.
+	DefaultBackchannelPollInterval = 5 * time.Second
+)
+
+// BackchannelAuthenticationConfig configures the Client-Initiated Backchannel Authentication Flow
+// (CIBA), see [WithBackchannelAuthentication].
+type BackchannelAuthenticationConfig struct {
+	// Lifetime of the auth_req_id, defaults to [DefaultBackchannelAuthenticationLifetime].
+	// Clients can request a shorter lifetime by requested_expiry.
+	Lifetime time.Duration
+	// PollInterval returned to clients in the poll mode,
+	// defaults to [DefaultBackchannelPollInterval].
+	PollInterval time.Duration
+	// UserCodeSupported advertises the support of the user_code parameter,
+	// which must be validated by the [BackchannelAuthenticationStorage].
+	UserCodeSupported bool
+}
+
+func (c *BackchannelAuthenticationConfig) lifetime(requestedExpiry int) time.Duration {
+	lifetime := c.Lifetime
+	if lifetime <= 0 {
+		lifetime = DefaultBackchannelAuthenticationLifetime
+	}
+	if requested := time.Duration(requestedExpiry) * time.Second; requested > 0 && requested < lifetime {
+		return requested
+	}
+	return lifetime
+}
+
+func (c *BackchannelAuthenticationConfig) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return DefaultBackchannelPollInterval
+}
+
+// HasBackchannelTokenDelivery is an optional interface that can be implemented by implementors
+// of Client, which registered a backchannel_token_delivery_mode (CIBA, section 4).
+// Clients not implementing it use the poll mode.
+type HasBackchannelTokenDelivery interface {
+	Client
+	BackchannelTokenDeliveryMode() oidc.BackchannelTokenDeliveryMode
+	// BackchannelClientNotificationEndpoint is the endpoint notified in the ping mode.
+	BackchannelClientNotificationEndpoint() string
+}
+
+func backchannelTokenDeliveryMode(client Client) oidc.BackchannelTokenDeliveryMode {
+	if delivery, ok := client.(HasBackchannelTokenDelivery); ok && delivery.BackchannelTokenDeliveryMode() != "" {
+		return delivery.BackchannelTokenDeliveryMode()
+	}
+	return oidc.BackchannelTokenDeliveryModePoll
+}
+
+type backchannelAuthenticationGetter interface {
+	BackchannelAuthentication() *BackchannelAuthenticationConfig
+}
+
+// backchannelAuthentication returns the [BackchannelAuthenticationConfig],
+// nil if the Client-Initiated Backchannel Authentication Flow is disabled.
+func backchannelAuthentication(v any) *BackchannelAuthenticationConfig {
+	if getter, ok := v.(backchannelAuthenticationGetter); ok {
+		return getter.BackchannelAuthentication()
+	}
+	return nil
+}
+
+type backchannelAuthenticationEndpointGetter interface {
+	BackchannelAuthenticationEndpoint() *Endpoint
+}
+
+// backchannelAuthenticationEndpointOf returns the endpoint of the provider, if any.
+func backchannelAuthenticationEndpointOf(config any) *Endpoint {
+	if getter, ok := config.(backchannelAuthenticationEndpointGetter); ok {
+		return getter.BackchannelAuthenticationEndpoint()
+	}
+	return nil
+}
+
+// backchannelAuthenticationEndpoint returns the discovered URL of the endpoint,
+// empty if the Client-Initiated Backchannel Authentication Flow is disabled.
+func backchannelAuthenticationEndpoint(config any, endpoint *Endpoint, issuer string) string {
+	if backchannelAuthentication(config) == nil {
+		return ""
+	}
+	return endpoint.Absolute(issuer)
+}
+
+// BackchannelTokenDeliveryModes returns the supported token delivery modes,
+// nil if the Client-Initiated Backchannel Authentication Flow is disabled.
+func BackchannelTokenDeliveryModes(c Configuration) []oidc.BackchannelTokenDeliveryMode {
+	if backchannelAuthentication(c) == nil {
+		return nil
+	}
+	return []oidc.BackchannelTokenDeliveryMode{
+		oidc.BackchannelTokenDeliveryModePoll,
+		oidc.BackchannelTokenDeliveryModePing,
+	}
+}
+
+func backchannelUserCodeSupported(c Configuration) bool {
+	config := backchannelAuthentication(c)
+	return config != nil && config.UserCodeSupported
+}
+
+// 32 bytes gives 256 bit of entropy.
+const authReqIDBytes = 32
+
+func BackchannelAuthenticationHandler(o OpenIDProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := BackchannelAuthentication(w, r, o); err != nil {
+			RequestError(w, r, err, o.Logger())
+		}
+	}
+}
+
+// BackchannelAuthentication handles the Backchannel Authentication Request of an authenticated client,
+// storing the validated request for the authentication of the user and responding
+// with its auth_req_id (CIBA, section 7).
+func BackchannelAuthentication(w http.ResponseWriter, r *http.Request, o OpenIDProvider) error {
+	ctx, span := tracer.Start(r.Context(), "BackchannelAuthentication")
+	r = r.WithContext(ctx)
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		return oidc.ErrInvalidRequest().WithDescription("backchannel authentication requests must be posted")
+	}
+	client, err := authenticateBackchannelClient(r, o)
+	if err != nil {
+		return err
+	}
+	req, err := ParseBackchannelAuthenticationRequest(r, o.Decoder())
+	if err != nil {
+		return err
+	}
+	response, err := createBackchannelAuthentication(ctx, o, req, client)
+	if err != nil {
+		return err
+	}
+	WriteJSON(w, response, http.StatusOK)
+	return nil
+}
+
+func ParseBackchannelAuthenticationRequest(r *http.Request, decoder httphelper.Decoder) (*oidc.BackchannelAuthenticationRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse form").WithParent(err)
+	}
+	req := new(oidc.BackchannelAuthenticationRequest)
+	if err := decoder.Decode(req, r.Form); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse backchannel authentication request").WithParent(err)
+	}
+	return req, nil
+}
+
+// backchannelClientProvider is implemented by both [OpenIDProvider] and [Exchanger].
+type backchannelClientProvider interface {
+	ClientProvider
+	AuthMethodPostSupported() bool
+}
+
+// authenticateBackchannelClient authenticates the client with the methods of the token endpoint.
+// The Client-Initiated Backchannel Authentication Flow is only available to confidential clients.
+func authenticateBackchannelClient(r *http.Request, p backchannelClientProvider) (Client, error) {
+	clientID, authenticated, err := ClientIDFromRequest(r, p)
+	if err != nil {
+		return nil, err
+	}
+	client, err := p.Storage().GetClientByClientID(r.Context(), clientID)
+	if err != nil {
+		return nil, oidc.ErrInvalidClient().WithParent(err)
+	}
+	if authenticated {
+		return client, nil
+	}
+	if isTLSAuthMethod(client.AuthMethod()) {
+		if err = AuthorizeTLSClient(r.Context(), client, mtlsConfig(p)); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	if client.AuthMethod() != oidc.AuthMethodPost || !p.AuthMethodPostSupported() {
+		return nil, oidc.ErrInvalidClient().WithDescription("client authentication required")
+	}
+	if err = AuthorizeClientIDSecret(r.Context(), clientID, r.Form.Get("client_secret"), p.Storage()); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// createBackchannelAuthentication validates the Backchannel Authentication Request
+// of the client and stores it under a new auth_req_id.
+func createBackchannelAuthentication(ctx context.Context, o OpenIDProvider, req *oidc.BackchannelAuthenticationRequest, client Client) (*oidc.BackchannelAuthenticationResponse, error) {
+	ctx, span := tracer.Start(ctx, "createBackchannelAuthentication")
+	defer span.End()
+
+	config := backchannelAuthentication(o)
+	storage, ok := o.Storage().(BackchannelAuthenticationStorage)
+	if config == nil || !ok {
+		return nil, oidc.ErrInvalidRequest().WithDescription("backchannel authentication not supported")
+	}
+	if client.AuthMethod() == oidc.AuthMethodNone {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("backchannel authentication requires a confidential client")
+	}
+	if !ValidateGrantType(client, oidc.GrantTypeCIBA) {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("client missing grant type " + string(oidc.GrantTypeCIBA))
+	}
+	if req.ClientID == "" {
+		req.ClientID = client.GetID()
+	}
+	if req.ClientID != client.GetID() {
+		return nil, oidc.ErrInvalidRequest().WithDescription("client_id does not match the authenticated client")
+	}
+	if !slices.Contains(req.Scopes, oidc.ScopeOpenID) {
+		return nil, oidc.ErrInvalidScope().WithDescription("the openid scope is required")
+	}
+	if !req.HasSingleHint() {
+		return nil, oidc.ErrInvalidRequest().WithDescription("exactly one of login_hint_token, id_token_hint or login_hint is required")
+	}
+	mode := backchannelTokenDeliveryMode(client)
+	if mode == oidc.BackchannelTokenDeliveryModePing && req.ClientNotificationToken == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("client_notification_token is required in the ping mode")
+	}
+	var subject string
+	if req.IDTokenHint != "" {
+		claims, err := VerifyIDTokenHint[*oidc.TokenClaims](ctx, req.IDTokenHint, o.IDTokenHintVerifier(ctx))
+		if err != nil && !errors.As(err, &IDTokenHintExpiredError{}) {
+			return nil, oidc.ErrInvalidRequest().WithDescription("the id_token_hint is invalid").WithParent(err)
+		}
+		subject = claims.GetSubject()
+	}
+
+	authReqID, err := randomString(ctx, authReqIDBytes)
+	if err != nil {
+		return nil, oidc.ErrServerError().WithDescription("unable to generate auth_req_id").WithParent(err)
+	}
+	lifetime := config.lifetime(req.RequestedExpiry)
+	if err := storage.StoreBackchannelAuthentication(ctx, authReqID, req, subject, time.Now().Add(lifetime)); err != nil {
+		var oidcErr *oidc.Error
+		if errors.As(err, &oidcErr) {
+			return nil, err
+		}
+		return nil, oidc.ErrServerError().WithDescription("unable to save backchannel authentication request").WithParent(err)
+	}
+	response := &oidc.BackchannelAuthenticationResponse{
+		AuthReqID: authReqID,
+		ExpiresIn: int(lifetime / time.Second),
+	}
+	if mode == oidc.BackchannelTokenDeliveryModePoll {
+		response.Interval = int(config.pollInterval() / time.Second)
+	}
+	return response, nil
+}
+
+// BackchannelAuthenticationState describes the current state of
+// the Client-Initiated Backchannel Authentication Flow.
+// It implements the [IDTokenRequest] interface.
+type BackchannelAuthenticationState struct {
+	AuthReqID string
+	ClientID  string
+	Audience  []string
+	Scopes    []string
+	// NotificationToken is the client_notification_token of the request,
+	// sent to the client in the ping mode.
+	NotificationToken string
+	Expires           time.Time // The time after we consider the authentication request timed-out
+	Done              bool      // The user authenticated and approved the authentication request
+	Denied            bool      // The user authenticated and denied the authentication request
+
+	// The following fields are populated after Done == true
+	Subject  string
+	AMR      []string
+	AuthTime time.Time
+}
+
+func (r *BackchannelAuthenticationState) GetAMR() []string {
+	return r.AMR
+}
+
+func (r *BackchannelAuthenticationState) GetAudience() []string {
+	if !slices.Contains(r.Audience, r.ClientID) {
+		r.Audience = append(r.Audience, r.ClientID)
+	}
+	return r.Audience
+}
+
+func (r *BackchannelAuthenticationState) GetAuthTime() time.Time {
+	return r.AuthTime
+}
+
+func (r *BackchannelAuthenticationState) GetClientID() string {
+	return r.ClientID
+}
+
+func (r *BackchannelAuthenticationState) GetScopes() []string {
+	return r.Scopes
+}
+
+func (r *BackchannelAuthenticationState) GetSubject() string {
+	return r.Subject
+}
+
+// CheckBackchannelAuthenticationState returns the state of the auth_req_id, if Done,
+// or the error to be returned to the polling client otherwise (CIBA, section 11).
+func CheckBackchannelAuthenticationState(ctx context.Context, clientID, authReqID string, exchanger Exchanger) (*BackchannelAuthenticationState, error) {
+	ctx, span := tracer.Start(ctx, "CheckBackchannelAuthenticationState")
+	defer span.End()
+
+	storage, ok := exchanger.Storage().(BackchannelAuthenticationStorage)
+	if !ok {
+		return nil, oidc.ErrUnsupportedGrantType().WithDescription("ciba grant not supported")
+	}
+	state, err := storage.GetBackchannelAuthenticationState(ctx, clientID, authReqID)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, oidc.ErrSlowDown().WithParent(err)
+	}
+	var oidcErr *oidc.Error
+	if errors.As(err, &oidcErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, oidc.ErrInvalidGrant().WithDescription("unknown auth_req_id").WithParent(err)
+	}
+	if state.Denied {
+		return state, oidc.ErrAccessDenied()
+	}
+	if state.Done {
+		return state, nil
+	}
+	if time.Now().After(state.Expires) {
+		return state, oidc.ErrExpiredAuthReqID()
+	}
+	return state, oidc.ErrAuthorizationPending()
+}
+
+func BackchannelAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchanger) {
+	ctx, span := tracer.Start(r.Context(), "BackchannelAccessToken")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	if err := backchannelAccessToken(w, r, exchanger); err != nil {
+		RequestError(w, r, err, exchanger.Logger())
+	}
+}
+
+func backchannelAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchanger) error {
+	client, err := authenticateBackchannelClient(r, exchanger)
+	if err != nil {
+		return err
+	}
+	req := new(oidc.BackchannelTokenRequest)
+	if err := exchanger.Decoder().Decode(req, r.PostForm); err != nil {
+		return oidc.ErrInvalidRequest().WithDescription("error decoding form").WithParent(err)
+	}
+	resp, err := createBackchannelTokenResponse(r.Context(), exchanger, client, req.AuthReqID)
+	if err != nil {
+		return err
+	}
+	WriteJSON(w, resp, http.StatusOK)
+	return nil
+}
+
+// createBackchannelTokenResponse issues the tokens of the auth_req_id,
+// once the user authenticated.
+func createBackchannelTokenResponse(ctx context.Context, exchanger Exchanger, client Client, authReqID string) (*oidc.AccessTokenResponse, error) {
+	if authReqID == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("auth_req_id missing")
+	}
+	if !ValidateGrantType(client, oidc.GrantTypeCIBA) {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("client missing grant type " + string(oidc.GrantTypeCIBA))
+	}
+	state, err := CheckBackchannelAuthenticationState(ctx, client.GetID(), authReqID, exchanger)
+	if err != nil {
+		return nil, err
+	}
+	return CreateDeviceTokenResponse(ctx, state, exchanger, client)
+}
+
+// NotifyBackchannelClient sends the ping callback of the completed authentication
+// to the client_notification_endpoint of the client, if it uses the ping mode.
+// It is meant to be called by implementors after the user authenticated or denied
+// the request.
+func NotifyBackchannelClient(ctx context.Context, httpClient *http.Client, client Client, state *BackchannelAuthenticationState) error {
+	if backchannelTokenDeliveryMode(client) != oidc.BackchannelTokenDeliveryModePing {
+		return nil
+	}
+	endpoint := client.(HasBackchannelTokenDelivery).BackchannelClientNotificationEndpoint()
+	return SendBackchannelNotification(ctx, httpClient, endpoint, state.NotificationToken, state.AuthReqID)
+}
+
+// SendBackchannelNotification posts the auth_req_id to the client_notification_endpoint,
+// authenticated by the client_notification_token as bearer token (CIBA, section 10.2),
+// and expects the client to respond with 200 OK or 204 No Content.
+func SendBackchannelNotification(ctx context.Context, httpClient *http.Client, endpoint, notificationToken, authReqID string) error {
+	body, err := json.Marshal(oidc.BackchannelNotification{AuthReqID: authReqID})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", oidc.BearerToken+" "+notificationToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("backchannel notification: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
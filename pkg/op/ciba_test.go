@@ -0,0 +1,256 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type cibaClient struct {
+	Client
+	authMethod oidc.AuthMethod
+	grantTypes []oidc.GrantType
+	mode       oidc.BackchannelTokenDeliveryMode
+	endpoint   string
+}
+
+func (c *cibaClient) GetID() string                { return "client1" }
+func (c *cibaClient) AuthMethod() oidc.AuthMethod  { return c.authMethod }
+func (c *cibaClient) GrantTypes() []oidc.GrantType { return c.grantTypes }
+func (c *cibaClient) BackchannelTokenDeliveryMode() oidc.BackchannelTokenDeliveryMode {
+	return c.mode
+}
+func (c *cibaClient) BackchannelClientNotificationEndpoint() string { return c.endpoint }
+
+type cibaStorage struct {
+	Storage
+	requests map[string]*oidc.BackchannelAuthenticationRequest
+	states   map[string]*BackchannelAuthenticationState
+	err      error
+}
+
+func (s *cibaStorage) StoreBackchannelAuthentication(_ context.Context, authReqID string, req *oidc.BackchannelAuthenticationRequest, _ string, _ time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.requests[authReqID] = req
+	return nil
+}
+
+func (s *cibaStorage) GetBackchannelAuthenticationState(_ context.Context, clientID, authReqID string) (*BackchannelAuthenticationState, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	state, ok := s.states[authReqID]
+	if !ok || state.ClientID != clientID {
+		return nil, errors.New("not found")
+	}
+	return state, nil
+}
+
+type cibaProvider struct {
+	OpenIDProvider
+	storage Storage
+	config  *BackchannelAuthenticationConfig
+}
+
+func (p cibaProvider) Storage() Storage { return p.storage }
+
+func (p cibaProvider) BackchannelAuthentication() *BackchannelAuthenticationConfig {
+	return p.config
+}
+
+type cibaExchanger struct {
+	Exchanger
+	storage Storage
+}
+
+func (e cibaExchanger) Storage() Storage { return e.storage }
+
+func Test_createBackchannelAuthentication(t *testing.T) {
+	pollClient := &cibaClient{authMethod: oidc.AuthMethodBasic, grantTypes: []oidc.GrantType{oidc.GrantTypeCIBA}}
+	pingClient := &cibaClient{authMethod: oidc.AuthMethodBasic, grantTypes: []oidc.GrantType{oidc.GrantTypeCIBA}, mode: oidc.BackchannelTokenDeliveryModePing}
+	valid := func() *oidc.BackchannelAuthenticationRequest {
+		return &oidc.BackchannelAuthenticationRequest{Scopes: []string{oidc.ScopeOpenID}, LoginHint: "user1"}
+	}
+	tests := []struct {
+		name         string
+		config       *BackchannelAuthenticationConfig
+		client       Client
+		req          func() *oidc.BackchannelAuthenticationRequest
+		storageErr   error
+		wantErr      func() *oidc.Error
+		wantInterval int
+		wantExpires  int
+	}{
+		{
+			name:    "disabled",
+			client:  pollClient,
+			req:     valid,
+			wantErr: oidc.ErrInvalidRequest,
+		},
+		{
+			name:    "public client",
+			config:  &BackchannelAuthenticationConfig{},
+			client:  &cibaClient{authMethod: oidc.AuthMethodNone, grantTypes: []oidc.GrantType{oidc.GrantTypeCIBA}},
+			req:     valid,
+			wantErr: oidc.ErrUnauthorizedClient,
+		},
+		{
+			name:    "missing grant type",
+			config:  &BackchannelAuthenticationConfig{},
+			client:  &cibaClient{authMethod: oidc.AuthMethodBasic},
+			req:     valid,
+			wantErr: oidc.ErrUnauthorizedClient,
+		},
+		{
+			name:   "missing openid scope",
+			config: &BackchannelAuthenticationConfig{},
+			client: pollClient,
+			req: func() *oidc.BackchannelAuthenticationRequest {
+				return &oidc.BackchannelAuthenticationRequest{Scopes: []string{"profile"}, LoginHint: "user1"}
+			},
+			wantErr: oidc.ErrInvalidScope,
+		},
+		{
+			name:   "multiple hints",
+			config: &BackchannelAuthenticationConfig{},
+			client: pollClient,
+			req: func() *oidc.BackchannelAuthenticationRequest {
+				req := valid()
+				req.LoginHintToken = "token"
+				return req
+			},
+			wantErr: oidc.ErrInvalidRequest,
+		},
+		{
+			name:    "ping without notification token",
+			config:  &BackchannelAuthenticationConfig{},
+			client:  pingClient,
+			req:     valid,
+			wantErr: oidc.ErrInvalidRequest,
+		},
+		{
+			name:       "unknown user",
+			config:     &BackchannelAuthenticationConfig{},
+			client:     pollClient,
+			req:        valid,
+			storageErr: oidc.ErrUnknownUserID(),
+			wantErr:    oidc.ErrUnknownUserID,
+		},
+		{
+			name:       "storage error",
+			config:     &BackchannelAuthenticationConfig{},
+			client:     pollClient,
+			req:        valid,
+			storageErr: errors.New("storage error"),
+			wantErr:    oidc.ErrServerError,
+		},
+		{
+			name:         "poll",
+			config:       &BackchannelAuthenticationConfig{},
+			client:       pollClient,
+			req:          valid,
+			wantInterval: 5,
+			wantExpires:  120,
+		},
+		{
+			name:   "ping with requested expiry",
+			config: &BackchannelAuthenticationConfig{},
+			client: pingClient,
+			req: func() *oidc.BackchannelAuthenticationRequest {
+				req := valid()
+				req.ClientNotificationToken = "notification"
+				req.RequestedExpiry = 60
+				return req
+			},
+			wantExpires: 60,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &cibaStorage{requests: make(map[string]*oidc.BackchannelAuthenticationRequest), err: tt.storageErr}
+			provider := cibaProvider{storage: storage, config: tt.config}
+			got, err := createBackchannelAuthentication(context.Background(), provider, tt.req(), tt.client)
+			if tt.wantErr != nil {
+				var oidcErr *oidc.Error
+				require.ErrorAs(t, err, &oidcErr)
+				assert.Equal(t, tt.wantErr().ErrorType, oidcErr.ErrorType)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantInterval, got.Interval)
+			assert.Equal(t, tt.wantExpires, got.ExpiresIn)
+			require.Contains(t, storage.requests, got.AuthReqID)
+			assert.Equal(t, "client1", storage.requests[got.AuthReqID].ClientID)
+		})
+	}
+}
+
+func TestCheckBackchannelAuthenticationState(t *testing.T) {
+	now := time.Now()
+	storage := &cibaStorage{states: map[string]*BackchannelAuthenticationState{
+		"pending": {ClientID: "client1", Expires: now.Add(time.Minute)},
+		"expired": {ClientID: "client1", Expires: now.Add(-time.Second)},
+		"denied":  {ClientID: "client1", Expires: now.Add(time.Minute), Denied: true},
+		"done":    {ClientID: "client1", Expires: now.Add(time.Minute), Done: true, Subject: "user1"},
+	}}
+	tests := []struct {
+		name      string
+		authReqID string
+		err       error
+		wantErr   func() *oidc.Error
+	}{
+		{name: "unknown", authReqID: "unknown", wantErr: oidc.ErrInvalidGrant},
+		{name: "slow down", authReqID: "pending", err: oidc.ErrSlowDown(), wantErr: oidc.ErrSlowDown},
+		{name: "pending", authReqID: "pending", wantErr: oidc.ErrAuthorizationPending},
+		{name: "expired", authReqID: "expired", wantErr: oidc.ErrExpiredAuthReqID},
+		{name: "denied", authReqID: "denied", wantErr: oidc.ErrAccessDenied},
+		{name: "done", authReqID: "done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage.err = tt.err
+			state, err := CheckBackchannelAuthenticationState(context.Background(), "client1", tt.authReqID, cibaExchanger{storage: storage})
+			if tt.wantErr != nil {
+				var oidcErr *oidc.Error
+				require.ErrorAs(t, err, &oidcErr)
+				assert.Equal(t, tt.wantErr().ErrorType, oidcErr.ErrorType)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user1", state.GetSubject())
+			assert.Equal(t, []string{"client1"}, state.GetAudience())
+		})
+	}
+}
+
+func TestNotifyBackchannelClient(t *testing.T) {
+	var notification oidc.BackchannelNotification
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&notification)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	state := &BackchannelAuthenticationState{AuthReqID: "req1", NotificationToken: "notification"}
+
+	err := NotifyBackchannelClient(context.Background(), server.Client(), &cibaClient{endpoint: server.URL}, state)
+	require.NoError(t, err)
+	assert.Empty(t, authorization, "poll mode is not notified")
+
+	err = NotifyBackchannelClient(context.Background(), server.Client(), &cibaClient{mode: oidc.BackchannelTokenDeliveryModePing, endpoint: server.URL}, state)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer notification", authorization)
+	assert.Equal(t, "req1", notification.AuthReqID)
+}
@@ -3,10 +3,12 @@ package op
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/lmindwarel/oidc/v3/internal/security"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
@@ -167,6 +169,12 @@ func ClientIDFromRequest(r *http.Request, p ClientProvider) (clientID string, au
 		return "", false, err
 	}
 
+	if client, ok, err := authorizeSPIFFEClientAssertion(r.Context(), p, p.Storage(), data.ClientAssertionType, data.ClientAssertion); ok {
+		if err != nil {
+			return "", false, err
+		}
+		return client.GetID(), true, nil
+	}
 	JWTProfile, ok := p.(ClientJWTProfile)
 	if ok && data.ClientAssertion != "" {
 		// if JWTProfile is supported and client sent an assertion, check it and use it as response
@@ -199,3 +207,17 @@ type ClientCredentials struct {
 	ClientAssertion     string `schema:"client_assertion"` // JWT
 	ClientAssertionType string `schema:"client_assertion_type"`
 }
+
+// LogValue implements [slog.LogValuer] with the secret and assertion redacted.
+func (c ClientCredentials) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("client_id", c.ClientID),
+		security.Attr("client_secret", c.ClientSecret),
+		security.Attr("client_assertion", c.ClientAssertion),
+		slog.String("client_assertion_type", c.ClientAssertionType),
+	)
+}
+
+func (c ClientCredentials) String() string {
+	return security.String(c)
+}
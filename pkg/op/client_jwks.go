@@ -0,0 +1,214 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// HasJWKSURI is an optional interface that can be implemented by implementors
+// of Client, which publish their keys at a jwks_uri (RFC 7591, section 2),
+// like most dynamically registered clients.
+//
+// The keys of the jwks_uri verify the private_key_jwt assertions and the
+// request objects of the client, instead of the keys returned by
+// GetKeyByIDAndClientID of the Storage. See [ClientJWKSCache].
+type HasJWKSURI interface {
+	Client
+	JWKSURI() string
+}
+
+const (
+	defaultClientJWKSEntries = 1000
+	defaultClientJWKSSize    = 1 << 20
+)
+
+// ClientJWKSCache fetches and caches the keys of the jwks_uri of clients,
+// see [HasJWKSURI] and [WithClientJWKSCache].
+//
+// Keys are cached for the max-age of the Cache-Control header of the response,
+// or the TTL. Signatures with an unknown kid refresh the keys, at most
+// once per MinRefresh, so clients can rotate their keys at any time.
+//
+// The jwks_uri is part of the client metadata, so the http.Client should
+// restrict the hosts it connects to, if clients register themselves,
+// like the [httphelper.PublicHTTPClient].
+// It must be created by [NewClientJWKSCache].
+type ClientJWKSCache struct {
+	// TTL is how long keys are cached without a max-age.
+	TTL time.Duration
+	// MinRefresh is the minimum interval between fetches of a jwks_uri.
+	MinRefresh time.Duration
+	// MaxEntries is the maximum number of cached jwks_uris,
+	// beyond which the oldest ones are evicted.
+	MaxEntries int
+	// MaxResponseSize is the maximum size in bytes of a fetched key set.
+	MaxResponseSize int64
+
+	httpClient *http.Client
+	mu         sync.Mutex
+	sets       map[string]*clientKeySet
+	order      []string
+	now        func() time.Time
+}
+
+type clientKeySet struct {
+	mu      sync.Mutex
+	keys    []jose.JSONWebKey
+	fetched time.Time
+	expires time.Time
+}
+
+// NewClientJWKSCache returns a ClientJWKSCache, caching the keys of up to
+// 1000 jwks_uris for an hour, refreshing them at most once per minute,
+// and accepting key sets of up to 1 MiB.
+// A nil httpClient uses the [httphelper.PublicHTTPClient].
+func NewClientJWKSCache(httpClient *http.Client) *ClientJWKSCache {
+	if httpClient == nil {
+		httpClient = httphelper.PublicHTTPClient()
+	}
+	return &ClientJWKSCache{
+		TTL:             time.Hour,
+		MinRefresh:      time.Minute,
+		MaxEntries:      defaultClientJWKSEntries,
+		MaxResponseSize: defaultClientJWKSSize,
+		httpClient:      httpClient,
+		sets:            make(map[string]*clientKeySet),
+		now:             time.Now,
+	}
+}
+
+// VerifySignature verifies the signature with the keys of the jwks_uri.
+func (c *ClientJWKSCache) VerifySignature(ctx context.Context, jwksURI string, jws *jose.JSONWebSignature) ([]byte, error) {
+	keyID, alg := oidc.GetKeyIDAndAlg(jws)
+	key, err := c.key(ctx, jwksURI, keyID, alg)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(&key)
+}
+
+func (c *ClientJWKSCache) key(ctx context.Context, jwksURI, keyID, alg string) (jose.JSONWebKey, error) {
+	c.mu.Lock()
+	set, ok := c.sets[jwksURI]
+	if !ok {
+		for len(c.order) > 0 && len(c.order) >= c.MaxEntries {
+			delete(c.sets, c.order[0])
+			c.order = c.order[1:]
+		}
+		set = new(clientKeySet)
+		c.sets[jwksURI] = set
+		c.order = append(c.order, jwksURI)
+	}
+	c.mu.Unlock()
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	now := c.now()
+	key, err := oidc.FindMatchingKey(keyID, oidc.KeyUseSignature, alg, set.keys...)
+	if err == nil && now.Before(set.expires) {
+		return key, nil
+	}
+	if !set.fetched.IsZero() && now.Sub(set.fetched) < c.MinRefresh {
+		return key, err
+	}
+	// failed fetches count as well, not to flood the client with requests
+	set.fetched = now
+	keys, ttl, err := c.fetch(ctx, jwksURI)
+	if err != nil {
+		return jose.JSONWebKey{}, fmt.Errorf("error fetching keys of %s: %w", jwksURI, err)
+	}
+	set.keys, set.expires = keys, now.Add(ttl)
+	return oidc.FindMatchingKey(keyID, oidc.KeyUseSignature, alg, set.keys...)
+}
+
+func (c *ClientJWKSCache) fetch(ctx context.Context, jwksURI string) ([]jose.JSONWebKey, time.Duration, error) {
+	ctx, span := tracer.Start(ctx, "fetchClientJWKS")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("http status not ok: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxResponseSize+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(body)) > c.MaxResponseSize {
+		return nil, 0, fmt.Errorf("key set exceeds %d bytes", c.MaxResponseSize)
+	}
+	var keySet jose.JSONWebKeySet
+	if err = json.Unmarshal(body, &keySet); err != nil {
+		return nil, 0, err
+	}
+	// SPIFFE trust bundles are JWKS as well, see [SPIFFETrustDomain]
+	return spiffeBundleKeys(keySet.Keys), maxAge(resp.Header.Get("Cache-Control"), c.TTL), nil
+}
+
+// maxAge returns the max-age of the Cache-Control header, or ttl if it has none.
+func maxAge(cacheControl string, ttl time.Duration) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return 0
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return ttl
+}
+
+type clientJWKSCacheGetter interface {
+	ClientJWKSCache() *ClientJWKSCache
+}
+
+func clientJWKSCache(v any) *ClientJWKSCache {
+	if getter, ok := v.(clientJWKSCacheGetter); ok {
+		return getter.ClientJWKSCache()
+	}
+	return nil
+}
+
+type clientGetter interface {
+	GetClientByClientID(ctx context.Context, clientID string) (Client, error)
+}
+
+// clientJWKSURI returns the jwks_uri of the client, if the storage
+// returns a client implementing [HasJWKSURI].
+func clientJWKSURI(ctx context.Context, storage any, clientID string) string {
+	getter, ok := storage.(clientGetter)
+	if !ok {
+		return ""
+	}
+	client, err := getter.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		return ""
+	}
+	if client, ok := client.(HasJWKSURI); ok {
+		return client.JWKSURI()
+	}
+	return ""
+}
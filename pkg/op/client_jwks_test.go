@@ -0,0 +1,110 @@
+package op
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestClientJWKSCache_key(t *testing.T) {
+	newKey := func(kid string) jose.JSONWebKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		return jose.JSONWebKey{Key: key.Public(), KeyID: kid, Algorithm: "ES256", Use: oidc.KeyUseSignature}
+	}
+	var (
+		calls atomic.Int32
+		keys  atomic.Pointer[jose.JSONWebKeySet]
+	)
+	keys.Store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{newKey("1")}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		json.NewEncoder(w).Encode(keys.Load())
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	c := NewClientJWKSCache(server.Client())
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	key, err := c.key(ctx, server.URL, "1", "ES256")
+	require.NoError(t, err)
+	assert.Equal(t, "1", key.KeyID)
+	_, err = c.key(ctx, server.URL, "1", "ES256")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, calls.Load(), "cached")
+
+	// rotated keys are fetched for an unknown kid
+	now = now.Add(c.MinRefresh)
+	keys.Store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{newKey("2")}})
+	key, err = c.key(ctx, server.URL, "2", "ES256")
+	require.NoError(t, err)
+	assert.Equal(t, "2", key.KeyID)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// but at most once per MinRefresh
+	_, err = c.key(ctx, server.URL, "3", "ES256")
+	assert.ErrorIs(t, err, oidc.ErrKeyNone)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// and when expired
+	now = now.Add(c.TTL)
+	_, err = c.key(ctx, server.URL, "2", "ES256")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestMaxAge(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{cacheControl: "", want: time.Hour},
+		{cacheControl: "public, max-age=300", want: 5 * time.Minute},
+		{cacheControl: "max-age=invalid", want: time.Hour},
+		{cacheControl: "no-store", want: 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, maxAge(tt.cacheControl, time.Hour), tt.cacheControl)
+	}
+}
+
+func TestClientJWKSCache_limits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Write([]byte(`{"keys":[]}` + strings.Repeat(" ", 100)))
+			return
+		}
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer server.Close()
+
+	c := NewClientJWKSCache(server.Client())
+	c.MaxEntries = 2
+	c.MaxResponseSize = 100
+	ctx := context.Background()
+
+	_, err := c.key(ctx, server.URL+"/large", "1", "ES256")
+	assert.ErrorContains(t, err, "exceeds 100 bytes")
+	for _, path := range []string{"/1", "/2", "/3"} {
+		_, err = c.key(ctx, server.URL+path, "1", "ES256")
+		assert.ErrorIs(t, err, oidc.ErrKeyNone)
+	}
+	assert.Len(t, c.sets, 2)
+	assert.Equal(t, []string{server.URL + "/2", server.URL + "/3"}, c.order)
+}
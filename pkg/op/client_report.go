@@ -0,0 +1,151 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ClientLister is an optional interface of the [Storage],
+// which lets [ReportClients] summarize all registered clients.
+type ClientLister interface {
+	ListClients(ctx context.Context) ([]Client, error)
+}
+
+// ClientReport summarizes what a client is configured for
+// and which legacy behaviors it depends on.
+type ClientReport struct {
+	ClientID        string              `json:"client_id"`
+	ApplicationType string              `json:"application_type"`
+	AuthMethod      oidc.AuthMethod     `json:"auth_method"`
+	GrantTypes      []oidc.GrantType    `json:"grant_types"`
+	ResponseTypes   []oidc.ResponseType `json:"response_types"`
+	AccessTokenType string              `json:"access_token_type"`
+	// IDTokenSigningAlgorithms are used to sign the ID tokens
+	// and JWT access tokens of the client.
+	IDTokenSigningAlgorithms []string `json:"id_token_signing_algs,omitempty"`
+	// AuthSigningAlgorithms are accepted for client assertions,
+	// if the client authenticates with private_key_jwt.
+	AuthSigningAlgorithms []string `json:"auth_signing_algs,omitempty"`
+	// PKCE reports if a code_challenge and the S256 method are required
+	// for the client by the [PublicClientPolicy] and [DeprecationPolicy].
+	PKCE    PKCERequirement        `json:"pkce"`
+	DevMode bool                   `json:"dev_mode,omitempty"`
+	Legacy  []ClientLegacyBehavior `json:"legacy,omitempty"`
+}
+
+// PKCERequirement reports the code_challenge requirement for a client.
+type PKCERequirement struct {
+	Required    bool `json:"required"`
+	RequireS256 bool `json:"require_s256"`
+}
+
+// ClientLegacyBehavior is a legacy behavior, which the client
+// is configured for or has used since the start of the Provider.
+type ClientLegacyBehavior struct {
+	Behavior LegacyBehavior `json:"behavior"`
+	Used     bool           `json:"used"`
+	// Disabled by the [DeprecationPolicy], so requests depending on it fail.
+	Disabled bool `json:"disabled"`
+}
+
+// ReportClient summarizes the client, built from the [Client] interface,
+// the policies of the provider and the legacy behaviors recorded by it.
+func ReportClient(ctx context.Context, provider OpenIDProvider, client Client) *ClientReport {
+	report := &ClientReport{
+		ClientID:                 client.GetID(),
+		ApplicationType:          client.ApplicationType().String(),
+		AuthMethod:               client.AuthMethod(),
+		GrantTypes:               client.GrantTypes(),
+		ResponseTypes:            client.ResponseTypes(),
+		AccessTokenType:          client.AccessTokenType().String(),
+		IDTokenSigningAlgorithms: SigAlgorithms(ctx, discoverStorage(provider)),
+		DevMode:                  client.DevMode(),
+	}
+	if client.AuthMethod() == oidc.AuthMethodPrivateKeyJWT {
+		report.AuthSigningAlgorithms = provider.TokenEndpointSigningAlgorithmsSupported()
+	}
+	publicPolicy := publicClientPolicy(provider)
+	deprecation := deprecationPolicy(provider)
+	public := isPublicClient(client)
+	report.PKCE = PKCERequirement{
+		Required:    public && publicPolicy.RequirePKCE,
+		RequireS256: (public && publicPolicy.RequireS256) || deprecation.DisablePlainPKCE,
+	}
+
+	var usage *LegacyUsage
+	if getter, ok := provider.(legacyUsageGetter); ok {
+		usage = getter.LegacyUsage()
+	}
+	implicit := slices.ContainsFunc(client.ResponseTypes(), isImplicitFlow)
+	for _, behavior := range []LegacyBehavior{LegacyImplicitFlow, LegacyPlainPKCE, LegacyRefreshTokenReuse} {
+		used := usage != nil && usage.used(behavior, client.GetID())
+		if !used && (behavior != LegacyImplicitFlow || !implicit) {
+			continue
+		}
+		report.Legacy = append(report.Legacy, ClientLegacyBehavior{
+			Behavior: behavior,
+			Used:     used,
+			Disabled: deprecation.disabled(behavior),
+		})
+	}
+	return report
+}
+
+// ReportClients summarizes all clients of the Storage,
+// which must implement [ClientLister].
+func ReportClients(ctx context.Context, provider OpenIDProvider) ([]*ClientReport, error) {
+	lister, ok := provider.Storage().(ClientLister)
+	if !ok {
+		return nil, oidc.ErrRequestNotSupported().WithDescription("storage does not list clients")
+	}
+	clients, err := lister.ListClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*ClientReport, len(clients))
+	for i, client := range clients {
+		reports[i] = ReportClient(ctx, provider, client)
+	}
+	slices.SortFunc(reports, func(a, b *ClientReport) int {
+		return strings.Compare(a.ClientID, b.ClientID)
+	})
+	return reports, nil
+}
+
+// ClientReportHandler serves the [ClientReport] of the client passed as client_id
+// query parameter, or of all clients without it.
+// It is not registered on the router of the Provider, as it discloses the client
+// configurations and must be mounted behind the authentication of an admin or
+// debug interface.
+func ClientReportHandler(provider OpenIDProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "ClientReport")
+		defer span.End()
+
+		clientID := r.URL.Query().Get("client_id")
+		if clientID == "" {
+			reports, err := ReportClients(ctx, provider)
+			if errors.Is(err, oidc.ErrRequestNotSupported()) {
+				WriteJSON(w, err, http.StatusNotImplemented)
+				return
+			}
+			if err != nil {
+				WriteJSON(w, oidc.DefaultToServerError(err, err.Error()), http.StatusInternalServerError)
+				return
+			}
+			WriteJSON(w, reports, http.StatusOK)
+			return
+		}
+		client, err := provider.Storage().GetClientByClientID(ctx, clientID)
+		if err != nil {
+			WriteJSON(w, oidc.ErrInvalidClient().WithParent(err), http.StatusNotFound)
+			return
+		}
+		WriteJSON(w, ReportClient(ctx, provider, client), http.StatusOK)
+	}
+}
@@ -0,0 +1,96 @@
+package op_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportClient(t *testing.T) {
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithAllowInsecure(),
+		op.WithPublicClientPolicy(op.PublicClientPolicy{RequirePKCE: true}),
+		op.WithDeprecationPolicy(op.DeprecationPolicy{DisableImplicitFlow: true}),
+	)
+	require.NoError(t, err)
+
+	// the native client still sends plain code challenges
+	require.NoError(t, op.ValidateAuthReqDeprecations(ctx, provider, &oidc.AuthRequest{
+		ClientID:      "native",
+		ResponseType:  oidc.ResponseTypeCode,
+		CodeChallenge: "challenge",
+	}))
+
+	native, err := s.GetClientByClientID(ctx, "native")
+	require.NoError(t, err)
+	algorithms := op.SigAlgorithms(ctx, s)
+	assert.Equal(t, &op.ClientReport{
+		ClientID:                 "native",
+		ApplicationType:          "native",
+		AuthMethod:               oidc.AuthMethodNone,
+		GrantTypes:               []oidc.GrantType{oidc.GrantTypeCode, oidc.GrantTypeRefreshToken},
+		ResponseTypes:            []oidc.ResponseType{oidc.ResponseTypeCode},
+		AccessTokenType:          "bearer",
+		IDTokenSigningAlgorithms: algorithms,
+		PKCE:                     op.PKCERequirement{Required: true},
+		DevMode:                  native.DevMode(),
+		Legacy: []op.ClientLegacyBehavior{
+			{Behavior: op.LegacyPlainPKCE, Used: true},
+		},
+	}, op.ReportClient(ctx, provider, native))
+
+	web, err := s.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	report := op.ReportClient(ctx, provider, web)
+	assert.False(t, report.PKCE.Required)
+	// configured for the implicit flow, which is disabled
+	assert.Equal(t, []op.ClientLegacyBehavior{
+		{Behavior: op.LegacyImplicitFlow, Disabled: true},
+	}, report.Legacy)
+
+	reports, err := op.ReportClients(ctx, provider)
+	require.NoError(t, err)
+	var ids []string
+	for _, report := range reports {
+		ids = append(ids, report.ClientID)
+	}
+	assert.IsIncreasing(t, ids)
+	assert.Contains(t, ids, "native")
+	assert.Contains(t, ids, "web")
+}
+
+func TestClientReportHandler(t *testing.T) {
+	provider, _ := newReloadTestProvider(t)
+	handler := op.ClientReportHandler(provider)
+	serve := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/clients"+query, nil)
+		handler.ServeHTTP(w, r.WithContext(op.ContextWithIssuer(r.Context(), testIssuer)))
+		return w
+	}
+
+	w := serve("?client_id=web")
+	require.Equal(t, http.StatusOK, w.Code)
+	var report op.ClientReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "web", report.ClientID)
+	assert.Equal(t, oidc.AuthMethodBasic, report.AuthMethod)
+
+	w = serve("")
+	require.Equal(t, http.StatusOK, w.Code)
+	var reports []op.ClientReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reports))
+	assert.NotEmpty(t, reports)
+
+	assert.Equal(t, http.StatusNotFound, serve("?client_id=unknown").Code)
+}
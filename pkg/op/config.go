@@ -3,12 +3,15 @@ package op
 import (
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/muhlemmer/httpforwarded"
 	"golang.org/x/text/language"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
 
 var (
@@ -17,6 +20,7 @@ var (
 	ErrInvalidIssuerURL         = errors.New("invalid url for issuer")
 	ErrInvalidIssuerMissingHost = errors.New("host for issuer missing")
 	ErrInvalidIssuerHTTPS       = errors.New("scheme for issuer must be `https`")
+	ErrInvalidIssuerTemplate    = errors.New("issuer templates require insecure mode")
 )
 
 type Configuration interface {
@@ -139,6 +143,40 @@ func StaticIssuer(issuer string) func(bool) (IssuerFromRequest, error) {
 	}
 }
 
+// IssuerTemplate resolves the issuer from a template with {host} and {port}
+// placeholders ([oidc.IssuerTemplateHost], [oidc.IssuerTemplatePort]),
+// which are replaced by the host name and port of each request,
+// e.g. "http://localhost:{port}/oidc" for servers on dynamically allocated ports.
+//
+// Templates are meant for development and tests and require [WithAllowInsecure].
+func IssuerTemplate(template string) func(bool) (IssuerFromRequest, error) {
+	return func(allowInsecure bool) (IssuerFromRequest, error) {
+		if !allowInsecure {
+			return nil, ErrInvalidIssuerTemplate
+		}
+		resolve := func(host, port string) string {
+			t := template
+			if port == "" {
+				t = strings.ReplaceAll(t, ":"+oidc.IssuerTemplatePort, "")
+			}
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+			return strings.NewReplacer(oidc.IssuerTemplateHost, host, oidc.IssuerTemplatePort, port).Replace(t)
+		}
+		if err := ValidateIssuer(resolve("localhost", "1"), allowInsecure); err != nil {
+			return nil, err
+		}
+		return func(r *http.Request) string {
+			host, port, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host, port = strings.Trim(r.Host, "[]"), ""
+			}
+			return resolve(host, port)
+		}, nil
+	}
+}
+
 func ValidateIssuer(issuer string, allowInsecure bool) error {
 	if issuer == "" {
 		return ErrInvalidIssuerNoIssuer
@@ -165,6 +203,12 @@ func ValidateIssuerPath(issuer *url.URL) error {
 	return nil
 }
 
+// allowInsecure reports whether v is a [Configuration] in insecure mode.
+func allowInsecure(v any) bool {
+	c, ok := v.(interface{ Insecure() bool })
+	return ok && c.Insecure()
+}
+
 func devLocalAllowed(url *url.URL, allowInsecure bool) bool {
 	if !allowInsecure {
 		return false
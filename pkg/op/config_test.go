@@ -461,3 +461,29 @@ func TestStaticIssuer(t *testing.T) {
 		})
 	}
 }
+
+func TestIssuerTemplate(t *testing.T) {
+	_, err := IssuerTemplate("http://localhost:{port}/oidc")(false)
+	require.ErrorIs(t, err, ErrInvalidIssuerTemplate)
+
+	_, err = IssuerTemplate("http://localhost:{port}/oidc?query")(true)
+	require.ErrorIs(t, err, ErrInvalidIssuerPath)
+
+	issuer, err := IssuerTemplate("http://{host}:{port}/oidc")(true)
+	require.NoError(t, err)
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"127.0.0.1:54321", "http://127.0.0.1:54321/oidc"},
+		{"localhost", "http://localhost/oidc"},
+		{"[::1]:8080", "http://[::1]:8080/oidc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tt.host
+			assert.Equal(t, tt.want, issuer(r))
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ConsentReceiptStorage persists the consent receipts of the users, see [WithConsentReceipts].
+type ConsentReceiptStorage interface {
+	// SaveConsentReceipt stores the receipt of an authorization granted by the user.
+	// The Subject of the receipt is the internal subject of the user.
+	SaveConsentReceipt(ctx context.Context, receipt *oidc.ConsentReceipt) error
+	// ConsentReceipts returns the stored receipts of the user of the internal subject.
+	ConsentReceipts(ctx context.Context, subject string) ([]*oidc.ConsentReceipt, error)
+	// ConsentReceiptsToken validates the access token of an export request, identified
+	// by its ID, like SetUserinfoFromToken of the [Storage] does: it must have been issued
+	// to the user of the internal subject and not be revoked.
+	// It returns the scopes and the expiration of the token.
+	ConsentReceiptsToken(ctx context.Context, tokenID, subject string) (scopes []string, expiration time.Time, err error)
+}
+
+// ConsentReceiptConfig configures the consent receipts, see [WithConsentReceipts].
+type ConsentReceiptConfig struct {
+	// Storage persists the receipts. Required.
+	Storage ConsentReceiptStorage
+}
+
+// ErrConsentReceiptStorage is returned by [WithConsentReceipts] without storage.
+var ErrConsentReceiptStorage = errors.New("consent receipts require a storage")
+
+type consentReceiptsGetter interface {
+	ConsentReceipts() *ConsentReceiptConfig
+}
+
+// consentReceipts returns the [ConsentReceiptConfig],
+// nil if consent receipts are disabled.
+func consentReceipts(v any) *ConsentReceiptConfig {
+	if getter, ok := v.(consentReceiptsGetter); ok {
+		return getter.ConsentReceipts()
+	}
+	return nil
+}
+
+type consentReceiptsEndpointGetter interface {
+	ConsentReceiptsEndpoint() *Endpoint
+}
+
+// consentReceiptsEndpointOf returns the endpoint of the provider, if any.
+func consentReceiptsEndpointOf(config any) *Endpoint {
+	if getter, ok := config.(consentReceiptsEndpointGetter); ok {
+		return getter.ConsentReceiptsEndpoint()
+	}
+	return nil
+}
+
+// consentReceiptsEndpoint returns the discovered URL of the endpoint,
+// empty if consent receipts are disabled.
+func consentReceiptsEndpoint(config any, endpoint *Endpoint, issuer string) string {
+	if consentReceipts(config) == nil {
+		return ""
+	}
+	return endpoint.Absolute(issuer)
+}
+
+// 16 bytes gives 128 bit of entropy.
+const consentReceiptIDBytes = 16
+
+// NewConsentReceipt returns the receipt of the authorization granted by the user
+// with the auth request: its scopes, the claims released by them and its
+// authorization details, if the auth request implements [AuthorizationDetailsRequest].
+func NewConsentReceipt(ctx context.Context, issuer string, authReq AuthRequest) (*oidc.ConsentReceipt, error) {
+	id, err := randomString(ctx, consentReceiptIDBytes)
+	if err != nil {
+		return nil, err
+	}
+	receipt := &oidc.ConsentReceipt{
+		ID:        id,
+		Issuer:    issuer,
+		Subject:   authReq.GetSubject(),
+		ClientID:  authReq.GetClientID(),
+		Scopes:    authReq.GetScopes(),
+		Claims:    oidc.ScopeClaims(authReq.GetScopes()),
+		GrantedAt: oidc.NowTime(),
+	}
+	if details, ok := authReq.(AuthorizationDetailsRequest); ok {
+		receipt.AuthorizationDetails = details.GetAuthorizationDetails()
+	}
+	return receipt, nil
+}
+
+// recordConsentReceipt saves the receipt of the auth request, if consent receipts are enabled
+// and the user granted or changed consent with it: auth requests completed with the consent
+// already recorded, like silent SSO or prompt=none, don't add a receipt.
+func recordConsentReceipt(ctx context.Context, authorizer Authorizer, authReq AuthRequest) error {
+	ctx, span := tracer.Start(ctx, "recordConsentReceipt")
+	defer span.End()
+
+	config := consentReceipts(authorizer)
+	if config == nil {
+		return nil
+	}
+	receipts, err := config.Storage.ConsentReceipts(ctx, authReq.GetSubject())
+	if err != nil {
+		return oidc.ErrServerError().WithDescription("unable to get consent receipts").WithParent(err)
+	}
+	if consentRecorded(receipts, authReq) {
+		return nil
+	}
+	receipt, err := NewConsentReceipt(ctx, IssuerFromContext(ctx), authReq)
+	if err != nil {
+		return oidc.ErrServerError().WithDescription("unable to create consent receipt").WithParent(err)
+	}
+	if err = config.Storage.SaveConsentReceipt(ctx, receipt); err != nil {
+		return oidc.ErrServerError().WithDescription("unable to save consent receipt").WithParent(err)
+	}
+	return nil
+}
+
+// consentRecorded reports whether the latest receipt of the client
+// already covers the scopes and authorization details of the auth request.
+func consentRecorded(receipts []*oidc.ConsentReceipt, authReq AuthRequest) bool {
+	var latest *oidc.ConsentReceipt
+	for _, receipt := range receipts {
+		if receipt.ClientID != authReq.GetClientID() {
+			continue
+		}
+		if latest == nil || !receipt.GrantedAt.AsTime().Before(latest.GrantedAt.AsTime()) {
+			latest = receipt
+		}
+	}
+	if latest == nil {
+		return false
+	}
+	for _, scope := range authReq.GetScopes() {
+		if !slices.Contains(latest.Scopes, scope) {
+			return false
+		}
+	}
+	if details, ok := authReq.(AuthorizationDetailsRequest); ok {
+		for _, detail := range details.GetAuthorizationDetails() {
+			if !latest.AuthorizationDetails.Contains(detail) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SignConsentReceipt signs the receipt as JWT with the signing key of the provider,
+// translating the internal subject of the user for the client of the receipt.
+func SignConsentReceipt(ctx context.Context, o OpenIDProvider, receipt *oidc.ConsentReceipt) (string, error) {
+	ctx, span := tracer.Start(ctx, "SignConsentReceipt")
+	defer span.End()
+
+	claims := *receipt
+	subject, err := externalSubject(ctx, o.Storage(), receipt.Subject, receipt.ClientID)
+	if err != nil {
+		return "", err
+	}
+	claims.Subject = subject
+	claims.IssuedAt = oidc.FromTime(time.Now())
+	signingKey, err := signingKeys(o, o.Storage()).SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	signer, err := SignerFromKeyWithHeader(signingKey, &TokenHeader{Type: oidc.ConsentReceiptType})
+	if err != nil {
+		return "", err
+	}
+	return crypto.SignCanonical(&claims, signer)
+}
+
+func ConsentReceiptsHandler(o OpenIDProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ExportConsentReceipts(w, r, o); err != nil {
+			WriteError(w, r, err, o.Logger())
+		}
+	}
+}
+
+// ExportConsentReceipts handles the request of the user for the signed receipts
+// of the authorizations granted by the user, authenticated by an access token of the user
+// with the [oidc.ScopeConsentReceipts] scope, validated by the [ConsentReceiptStorage].
+func ExportConsentReceipts(w http.ResponseWriter, r *http.Request, o OpenIDProvider) error {
+	ctx, span := tracer.Start(r.Context(), "ExportConsentReceipts")
+	r = r.WithContext(ctx)
+	defer span.End()
+
+	req, err := ParseConsentReceiptsRequest(r, o)
+	if err != nil {
+		return err
+	}
+	response, err := exportConsentReceipts(ctx, o, req)
+	if err != nil {
+		return err
+	}
+	WriteJSON(w, response, http.StatusOK)
+	return nil
+}
+
+// ParseConsentReceiptsRequest parses the request, taking the access token from
+// the Authorization header, if sent as bearer token.
+func ParseConsentReceiptsRequest(r *http.Request, o OpenIDProvider) (*oidc.ConsentReceiptsRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse form").WithParent(err)
+	}
+	req := new(oidc.ConsentReceiptsRequest)
+	if err := o.Decoder().Decode(req, r.Form); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse consent receipts request").WithParent(err)
+	}
+	if token, err := getAccessToken(r); err == nil {
+		req.AccessToken = token
+	}
+	return req, nil
+}
+
+// exportConsentReceipts verifies the access token of the user
+// and returns the signed receipts of the user.
+func exportConsentReceipts(ctx context.Context, o OpenIDProvider, req *oidc.ConsentReceiptsRequest) (*oidc.ConsentReceiptsResponse, error) {
+	ctx, span := tracer.Start(ctx, "exportConsentReceipts")
+	defer span.End()
+
+	config := consentReceipts(o)
+	if config == nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("consent receipts not supported")
+	}
+	if req.AccessToken == "" {
+		return nil, NewStatusError(oidc.ErrInvalidRequest().WithDescription("access token missing"), http.StatusUnauthorized)
+	}
+	tokenID, subject, _, ok := getTokenIDAndSubject(ctx, o, req.AccessToken)
+	if !ok {
+		return nil, NewStatusError(oidc.ErrAccessDenied().WithDescription("access token invalid"), http.StatusUnauthorized)
+	}
+	scopes, expiration, err := config.Storage.ConsentReceiptsToken(ctx, tokenID, subject)
+	if err != nil {
+		return nil, NewStatusError(oidc.ErrAccessDenied().WithDescription("access token invalid").WithParent(err), http.StatusUnauthorized)
+	}
+	if !time.Now().Before(expiration) {
+		return nil, NewStatusError(oidc.ErrAccessDenied().WithDescription("access token expired"), http.StatusUnauthorized)
+	}
+	if !slices.Contains(scopes, oidc.ScopeConsentReceipts) {
+		return nil, NewStatusError(oidc.ErrAccessDenied().WithDescription("access token requires the scope %s", oidc.ScopeConsentReceipts), http.StatusForbidden)
+	}
+	receipts, err := config.Storage.ConsentReceipts(ctx, subject)
+	if err != nil {
+		return nil, oidc.ErrServerError().WithDescription("unable to get consent receipts").WithParent(err)
+	}
+	response := &oidc.ConsentReceiptsResponse{Receipts: make([]string, 0, len(receipts))}
+	for _, receipt := range receipts {
+		if req.ClientID != "" && receipt.ClientID != req.ClientID {
+			continue
+		}
+		signed, err := SignConsentReceipt(ctx, o, receipt)
+		if err != nil {
+			return nil, oidc.ErrServerError().WithDescription("unable to sign consent receipt").WithParent(err)
+		}
+		response.Receipts = append(response.Receipts, signed)
+	}
+	return response, nil
+}
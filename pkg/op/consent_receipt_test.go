@@ -0,0 +1,165 @@
+package op_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+type consentReceiptStore struct {
+	mu       sync.Mutex
+	receipts []*oidc.ConsentReceipt
+	tokens   *storage.Storage
+}
+
+func (s *consentReceiptStore) SaveConsentReceipt(_ context.Context, receipt *oidc.ConsentReceipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receipts = append(s.receipts, receipt)
+	return nil
+}
+
+func (s *consentReceiptStore) ConsentReceipts(_ context.Context, subject string) ([]*oidc.ConsentReceipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var receipts []*oidc.ConsentReceipt
+	for _, receipt := range s.receipts {
+		if receipt.Subject == subject {
+			receipts = append(receipts, receipt)
+		}
+	}
+	return receipts, nil
+}
+
+func (s *consentReceiptStore) ConsentReceiptsToken(ctx context.Context, tokenID, subject string) ([]string, time.Time, error) {
+	return s.tokens.ConsentReceiptsToken(ctx, tokenID, subject)
+}
+
+func TestWithConsentReceipts(t *testing.T) {
+	_, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithConsentReceipts(op.ConsentReceiptConfig{}),
+	)
+	require.ErrorIs(t, err, op.ErrConsentReceiptStorage)
+
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	receipts := &consentReceiptStore{tokens: s}
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithAllowInsecure(),
+		op.WithConsentReceipts(op.ConsentReceiptConfig{Storage: receipts}),
+	)
+	require.NoError(t, err)
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, r)
+		return w
+	}
+
+	login := func(t *testing.T, scope string) *oidc.AccessTokenResponse {
+		params := url.Values{
+			"client_id":     {"web"},
+			"redirect_uri":  {"https://example.com"},
+			"response_type": {"code"},
+			"scope":         {scope},
+		}
+		w := serve(httptest.NewRequest(http.MethodGet, testIssuer+"authorize?"+params.Encode(), nil))
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		id := location.Query().Get("authRequestID")
+		require.NoError(t, s.CheckUsernamePassword("test-user@localhost", "verysecure", id))
+
+		w = serve(httptest.NewRequest(http.MethodGet, testIssuer+"authorize/callback?id="+id, nil))
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		location, err = url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+
+		form := url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {location.Query().Get("code")},
+			"redirect_uri": {"https://example.com"},
+		}
+		r := httptest.NewRequest(http.MethodPost, testIssuer+"oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("web", "secret")
+		w = serve(r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		tokens := new(oidc.AccessTokenResponse)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), tokens))
+		return tokens
+	}
+	tokens := login(t, "openid email consent_receipts")
+	require.Len(t, receipts.receipts, 1)
+
+	export := func(query, accessToken string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, testIssuer+"consent_receipts"+query, nil)
+		if accessToken != "" {
+			r.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		return serve(r)
+	}
+
+	t.Run("receipts", func(t *testing.T) {
+		w := export("", tokens.AccessToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response oidc.ConsentReceiptsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Receipts, 1)
+
+		receipt := new(oidc.ConsentReceipt)
+		_, err := oidc.ParseToken(response.Receipts[0], receipt)
+		require.NoError(t, err)
+		assert.Equal(t, receipts.receipts[0].ID, receipt.ID)
+		assert.Equal(t, testIssuer, receipt.Issuer)
+		assert.Equal(t, "web", receipt.ClientID)
+		assert.Equal(t, oidc.SpaceDelimitedArray{"openid", "email", "consent_receipts"}, receipt.Scopes)
+		assert.Equal(t, []string{"email", "email_verified"}, receipt.Claims)
+		assert.NotZero(t, receipt.GrantedAt)
+		assert.NotZero(t, receipt.IssuedAt)
+	})
+	t.Run("other client", func(t *testing.T) {
+		w := export("?client_id=native", tokens.AccessToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"receipts":[]}`, w.Body.String())
+	})
+	t.Run("missing access token", func(t *testing.T) {
+		w := export("", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("invalid access token", func(t *testing.T) {
+		w := export("", "invalid")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("missing scope", func(t *testing.T) {
+		w := export("", login(t, "openid email").AccessToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+	t.Run("consent unchanged", func(t *testing.T) {
+		login(t, "openid email consent_receipts")
+		login(t, "openid email")
+		assert.Len(t, receipts.receipts, 1, "no receipt without new consent")
+		login(t, "openid profile")
+		require.Len(t, receipts.receipts, 2)
+		assert.Equal(t, oidc.SpaceDelimitedArray{"openid", "profile"}, receipts.receipts[1].Scopes)
+	})
+	t.Run("revoked access token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, testIssuer+"revoke", strings.NewReader(url.Values{"token": {tokens.AccessToken}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("web", "secret")
+		require.Equal(t, http.StatusOK, serve(r).Code)
+		w := export("", tokens.AccessToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,191 @@
+package op
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// DefaultCorrelationHeader is the default header of the correlation ID
+// in requests and responses, see [CorrelationPolicy].
+const DefaultCorrelationHeader = "X-Correlation-ID"
+
+// CorrelationPolicy assigns a correlation ID to every request of the Provider,
+// so a client-reported error can be correlated with the server logs.
+// The ID is returned in the response header, added as correlation_id to the
+// logs of the Provider and available to the Storage by [CorrelationIDFromContext],
+// e.g. for audit events. See [WithCorrelation] and [WithServerCorrelation].
+type CorrelationPolicy struct {
+	// Header of the correlation ID in requests and responses,
+	// defaults to [DefaultCorrelationHeader].
+	Header string
+	// TrustInbound propagates the correlation ID of the request:
+	// the Header, the X-Request-ID header or the trace ID of a W3C traceparent header.
+	// Without, a new ID is generated for every request.
+	TrustInbound bool
+	// Generate returns a new ID, defaults to 16 bytes of the random source
+	// of the request in hex, see [RandomFromContext].
+	Generate func() string
+	// ErrorDescription appends the correlation ID to the error_description
+	// of error responses.
+	ErrorDescription bool
+}
+
+type correlationKey struct{}
+
+type correlation struct {
+	id       string
+	describe bool
+}
+
+// CorrelationIDFromContext returns the correlation ID of the request, if any.
+func CorrelationIDFromContext(ctx context.Context) string {
+	if c, ok := ctx.Value(correlationKey{}).(*correlation); ok {
+		return c.id
+	}
+	return ""
+}
+
+// Handler is the middleware, which assigns the correlation ID to the requests.
+func (p *CorrelationPolicy) Handler(next http.Handler) http.Handler {
+	header := p.Header
+	if header == "" {
+		header = DefaultCorrelationHeader
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id string
+		if p.TrustInbound {
+			id = inboundCorrelationID(r.Header, header)
+		}
+		if id == "" {
+			id = p.generate(r.Context())
+		}
+		w.Header().Set(header, id)
+		ctx := context.WithValue(r.Context(), correlationKey{}, &correlation{id: id, describe: p.ErrorDescription})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (p *CorrelationPolicy) generate(ctx context.Context) string {
+	if p.Generate != nil {
+		return p.Generate()
+	}
+	id := make([]byte, 16)
+	_, _ = io.ReadFull(RandomFromContext(ctx), id)
+	return hex.EncodeToString(id)
+}
+
+// inboundCorrelationID returns the first valid ID of the header,
+// the X-Request-ID header and the trace ID of the traceparent header.
+func inboundCorrelationID(h http.Header, header string) string {
+	for _, id := range []string{h.Get(header), h.Get("X-Request-ID"), traceIDOf(h.Get("traceparent"))} {
+		if validCorrelationID(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// traceIDOf returns the trace ID of a traceparent header: version-traceid-parentid-flags.
+func traceIDOf(traceparent string) string {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return ""
+	}
+	return parts[1]
+}
+
+// validCorrelationID accepts IDs of up to 128 letters, digits and ._:- characters,
+// which are safe to return in headers and logs.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.ContainsRune("._:-", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// correlateError returns the error with the correlation ID appended to the description,
+// if enabled by the [CorrelationPolicy] of the request.
+func correlateError(ctx context.Context, e *oidc.Error) *oidc.Error {
+	c, ok := ctx.Value(correlationKey{}).(*correlation)
+	if !ok || !c.describe {
+		return e
+	}
+	suffix := "(correlation ID: " + c.id + ")"
+	if strings.HasSuffix(e.Description, suffix) {
+		return e
+	}
+	correlated := *e
+	correlated.Description = strings.TrimSpace(e.Description + " " + suffix)
+	return &correlated
+}
+
+// correlationLogHandler adds the correlation ID of the context to the records.
+type correlationLogHandler struct {
+	slog.Handler
+}
+
+func (h correlationLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := CorrelationIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("correlation_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h correlationLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return correlationLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h correlationLogHandler) WithGroup(name string) slog.Handler {
+	return correlationLogHandler{h.Handler.WithGroup(name)}
+}
+
+// correlationLogger returns the logger, which logs the correlation ID of the requests.
+func correlationLogger(logger *slog.Logger) *slog.Logger {
+	if _, ok := logger.Handler().(correlationLogHandler); ok {
+		return logger
+	}
+	return slog.New(correlationLogHandler{logger.Handler()})
+}
+
+type correlationPolicyGetter interface {
+	CorrelationPolicy() *CorrelationPolicy
+}
+
+func correlationPolicy(v any) *CorrelationPolicy {
+	if g, ok := v.(correlationPolicyGetter); ok {
+		return g.CorrelationPolicy()
+	}
+	return nil
+}
+
+// WithCorrelation assigns a correlation ID to every request, see [CorrelationPolicy].
+func WithCorrelation(policy CorrelationPolicy) Option {
+	return func(o *Provider) error {
+		o.correlation = &policy
+		return nil
+	}
+}
+
+// WithServerCorrelation assigns a correlation ID to every request of the Server,
+// see [CorrelationPolicy]. The fallback logger logs the correlation ID.
+func WithServerCorrelation(policy CorrelationPolicy) ServerOption {
+	return func(s *webServer) {
+		s.correlation = &policy
+	}
+}
@@ -0,0 +1,156 @@
+package op
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestCorrelationPolicy_Handler(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name    string
+		policy  CorrelationPolicy
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:   "generated",
+			policy: CorrelationPolicy{Generate: func() string { return "generated" }},
+			want:   "generated",
+		},
+		{
+			name:    "inbound not trusted",
+			policy:  CorrelationPolicy{Generate: func() string { return "generated" }},
+			headers: map[string]string{DefaultCorrelationHeader: "inbound"},
+			want:    "generated",
+		},
+		{
+			name:    "correlation header",
+			policy:  CorrelationPolicy{TrustInbound: true},
+			headers: map[string]string{DefaultCorrelationHeader: "inbound", "X-Request-ID": "request"},
+			want:    "inbound",
+		},
+		{
+			name:    "custom header",
+			policy:  CorrelationPolicy{Header: "X-Trace", TrustInbound: true},
+			headers: map[string]string{"X-Trace": "trace"},
+			want:    "trace",
+		},
+		{
+			name:    "request id",
+			policy:  CorrelationPolicy{TrustInbound: true},
+			headers: map[string]string{"X-Request-ID": "request", "traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"},
+			want:    "request",
+		},
+		{
+			name:    "traceparent",
+			policy:  CorrelationPolicy{TrustInbound: true},
+			headers: map[string]string{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"},
+			want:    traceID,
+		},
+		{
+			name:   "invalid inbound",
+			policy: CorrelationPolicy{TrustInbound: true, Generate: func() string { return "generated" }},
+			headers: map[string]string{
+				DefaultCorrelationHeader: "in\nbound",
+				"X-Request-ID":           strings.Repeat("a", 129),
+				"traceparent":            "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			},
+			want: "generated",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := tt.policy.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = CorrelationIDFromContext(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.want, got)
+			header := tt.policy.Header
+			if header == "" {
+				header = DefaultCorrelationHeader
+			}
+			assert.Equal(t, tt.want, w.Header().Get(header))
+		})
+	}
+}
+
+func TestCorrelationPolicy_generate(t *testing.T) {
+	policy := CorrelationPolicy{}
+	ctx := context.Background()
+	id := policy.generate(ctx)
+	assert.Len(t, id, 32)
+	assert.True(t, validCorrelationID(id))
+	assert.NotEqual(t, id, policy.generate(ctx))
+
+	ctx = ContextWithRandom(ctx, crypto.DeterministicRandom("correlation"))
+	id = policy.generate(ctx)
+	assert.Equal(t, id, policy.generate(ContextWithRandom(context.Background(), crypto.DeterministicRandom("correlation"))))
+}
+
+func TestCorrelation_errors(t *testing.T) {
+	var logs bytes.Buffer
+	logger := correlationLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+	serve := func(policy CorrelationPolicy) *httptest.ResponseRecorder {
+		handler := policy.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			RequestError(w, r, oidc.ErrInvalidRequest().WithDescription("bad request"), logger)
+		}))
+		r := httptest.NewRequest(http.MethodPost, "/oauth/token", nil)
+		r.Header.Set(DefaultCorrelationHeader, "abc-123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(CorrelationPolicy{TrustInbound: true, ErrorDescription: true})
+	var resp oidc.Error
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "bad request (correlation ID: abc-123)", resp.Description)
+	assert.Equal(t, "abc-123", w.Header().Get(DefaultCorrelationHeader))
+	assert.Contains(t, logs.String(), `"correlation_id":"abc-123"`)
+
+	w = serve(CorrelationPolicy{TrustInbound: true})
+	resp = oidc.Error{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "bad request", resp.Description)
+	assert.Equal(t, "abc-123", w.Header().Get(DefaultCorrelationHeader))
+}
+
+func TestCorrelateError_once(t *testing.T) {
+	policy := CorrelationPolicy{TrustInbound: true, ErrorDescription: true}
+	var got *oidc.Error
+	handler := policy.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = correlateError(r.Context(), correlateError(r.Context(), oidc.ErrServerError()))
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(DefaultCorrelationHeader, "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "(correlation ID: abc)", got.Description)
+}
+
+func TestCorrelationLogger(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	wrapped := correlationLogger(logger)
+	assert.IsType(t, correlationLogHandler{}, wrapped.Handler())
+	assert.Same(t, wrapped, correlationLogger(wrapped))
+	assert.IsType(t, correlationLogHandler{}, wrapped.With("key", "value").Handler())
+	assert.IsType(t, correlationLogHandler{}, wrapped.WithGroup("group").Handler())
+}
@@ -1,6 +1,7 @@
 package op
 
 import (
+	"github.com/lmindwarel/oidc/v3/internal/security"
 	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 )
 
@@ -10,17 +11,17 @@ type Crypto interface {
 }
 
 type aesCrypto struct {
-	key string
+	key security.Secret
 }
 
 func NewAESCrypto(key [32]byte) Crypto {
-	return &aesCrypto{key: string(key[:32])}
+	return &aesCrypto{key: security.Secret(key[:32])}
 }
 
 func (c *aesCrypto) Encrypt(s string) (string, error) {
-	return crypto.EncryptAES(s, c.key)
+	return crypto.EncryptAES(s, c.key.Reveal())
 }
 
 func (c *aesCrypto) Decrypt(s string) (string, error) {
-	return crypto.DecryptAES(s, c.key)
+	return crypto.DecryptAES(s, c.key.Reveal())
 }
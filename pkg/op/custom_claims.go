@@ -0,0 +1,47 @@
+package op
+
+import (
+	"slices"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// addCustomClaims adds the names of the claims of the namespace
+// to the custom claims, see [WithCustomClaims].
+func (o *Provider) addCustomClaims(namespace oidc.ClaimNamespace, names []string) error {
+	if namespace == "" {
+		if err := oidc.CheckClaimNames(names...); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if namespace, err = oidc.NewClaimNamespace(string(namespace)); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if claim := namespace.Claim(name); !slices.Contains(o.customClaims, claim) {
+			o.customClaims = append(o.customClaims, claim)
+		}
+	}
+	return nil
+}
+
+type customClaimsGetter interface {
+	CustomClaims() []string
+}
+
+// withCustomClaims returns the supported claims with the custom claims of c appended.
+func withCustomClaims(c Configuration, supported []string) []string {
+	getter, ok := c.(customClaimsGetter)
+	if !ok || len(getter.CustomClaims()) == 0 {
+		return supported
+	}
+	claims := slices.Clone(supported)
+	for _, claim := range getter.CustomClaims() {
+		if !slices.Contains(claims, claim) {
+			claims = append(claims, claim)
+		}
+	}
+	return claims
+}
@@ -0,0 +1,42 @@
+package op_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestWithCustomClaims(t *testing.T) {
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithCustomClaims("https://example.com/claims", "roles", "tenant"),
+		op.WithCustomClaims("", "department"),
+		op.WithCustomClaims("https://example.com/claims/", "roles"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://example.com/claims/roles",
+		"https://example.com/claims/tenant",
+		"department",
+	}, provider.CustomClaims())
+
+	supported := op.SupportedClaims(provider)
+	assert.Subset(t, supported, provider.CustomClaims())
+	assert.Subset(t, supported, op.DefaultSupportedClaims)
+	assert.Len(t, op.DefaultSupportedClaims, 24, "default claims must not be modified")
+
+	_, err = op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithCustomClaims("", "department", "email"),
+	)
+	assert.ErrorIs(t, err, oidc.ErrClaimCollision)
+
+	_, err = op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithCustomClaims("example.com", "roles"),
+	)
+	assert.ErrorIs(t, err, oidc.ErrClaimNamespace)
+}
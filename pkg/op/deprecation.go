@@ -0,0 +1,157 @@
+package op
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// LegacyBehavior is a behavior of the Provider, which is kept for
+// compatibility with existing clients and can be disabled by the
+// [DeprecationPolicy].
+type LegacyBehavior string
+
+const (
+	// LegacyImplicitFlow is the use of the id_token
+	// and `id_token token` response types.
+	LegacyImplicitFlow LegacyBehavior = "implicit_flow"
+	// LegacyPlainPKCE is a code_challenge with the plain
+	// or without code_challenge_method.
+	LegacyPlainPKCE LegacyBehavior = "plain_pkce"
+	// LegacyRefreshTokenReuse is a refresh_token grant, for which the
+	// Storage returns the same or no new refresh token.
+	LegacyRefreshTokenReuse LegacyBehavior = "refresh_token_reuse"
+)
+
+// DeprecationPolicy disables legacy behaviors individually, see [WithDeprecationPolicy].
+// Legacy behaviors which are not disabled are recorded in the [LegacyUsage] of the Provider,
+// and logged with the client on their first use by it, so migrations can be planned
+// on the clients which still depend on them.
+//
+// Introspection always requires client authentication,
+// so there is no switch for unauthenticated introspection.
+type DeprecationPolicy struct {
+	// DisableImplicitFlow rejects authorization requests of the implicit flow
+	// and omits its response types from the discovery configuration.
+	DisableImplicitFlow bool
+	// DisablePlainPKCE rejects authorization requests with a plain code_challenge.
+	DisablePlainPKCE bool
+	// DisableRefreshTokenReuse fails refresh_token grants,
+	// for which the Storage does not rotate the refresh token.
+	DisableRefreshTokenReuse bool
+}
+
+// DefaultDeprecationPolicy is used when no other policy
+// was set with [WithDeprecationPolicy]. It keeps all legacy behaviors.
+var DefaultDeprecationPolicy = DeprecationPolicy{}
+
+func (p *DeprecationPolicy) disabled(behavior LegacyBehavior) bool {
+	switch behavior {
+	case LegacyImplicitFlow:
+		return p.DisableImplicitFlow
+	case LegacyPlainPKCE:
+		return p.DisablePlainPKCE
+	case LegacyRefreshTokenReuse:
+		return p.DisableRefreshTokenReuse
+	}
+	return false
+}
+
+type deprecationPolicyGetter interface {
+	DeprecationPolicy() *DeprecationPolicy
+}
+
+func deprecationPolicy(v any) *DeprecationPolicy {
+	if p, ok := v.(deprecationPolicyGetter); ok {
+		if policy := p.DeprecationPolicy(); policy != nil {
+			return policy
+		}
+	}
+	return &DefaultDeprecationPolicy
+}
+
+// LegacyUsage records the clients using legacy behaviors.
+// The zero value is ready to use.
+type LegacyUsage struct {
+	mu      sync.Mutex
+	clients map[LegacyBehavior]map[string]struct{}
+}
+
+// Clients returns the sorted IDs of the clients,
+// which used the legacy behavior since the start of the Provider.
+func (u *LegacyUsage) Clients(behavior LegacyBehavior) []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	clients := make([]string, 0, len(u.clients[behavior]))
+	for clientID := range u.clients[behavior] {
+		clients = append(clients, clientID)
+	}
+	slices.Sort(clients)
+	return clients
+}
+
+func (u *LegacyUsage) used(behavior LegacyBehavior, clientID string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	_, ok := u.clients[behavior][clientID]
+	return ok
+}
+
+// record returns true on the first use of the behavior by the client.
+func (u *LegacyUsage) record(behavior LegacyBehavior, clientID string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.clients == nil {
+		u.clients = make(map[LegacyBehavior]map[string]struct{})
+	}
+	if u.clients[behavior] == nil {
+		u.clients[behavior] = make(map[string]struct{})
+	}
+	if _, ok := u.clients[behavior][clientID]; ok {
+		return false
+	}
+	u.clients[behavior][clientID] = struct{}{}
+	return true
+}
+
+type legacyUsageGetter interface {
+	LegacyUsage() *LegacyUsage
+}
+
+// useLegacy returns false if the behavior is disabled by the policy of v.
+// Otherwise its use by the client is recorded and logged, if v provides them.
+func useLegacy(ctx context.Context, v any, behavior LegacyBehavior, clientID string) bool {
+	if deprecationPolicy(v).disabled(behavior) {
+		return false
+	}
+	usage, ok := v.(legacyUsageGetter)
+	if !ok || !usage.LegacyUsage().record(behavior, clientID) {
+		return true
+	}
+	logger := slog.Default()
+	if l, ok := v.(interface{ Logger() *slog.Logger }); ok {
+		logger = l.Logger()
+	}
+	logger.WarnContext(ctx, "deprecated behavior used by client", "behavior", behavior, "client_id", clientID)
+	return true
+}
+
+func isImplicitFlow(responseType oidc.ResponseType) bool {
+	return responseType == oidc.ResponseTypeIDToken || responseType == oidc.ResponseTypeIDTokenOnly
+}
+
+// ValidateAuthReqDeprecations rejects the auth request, if it uses a legacy
+// behavior disabled by the [DeprecationPolicy] of the provider.
+func ValidateAuthReqDeprecations(ctx context.Context, provider any, authReq *oidc.AuthRequest) error {
+	if isImplicitFlow(authReq.ResponseType) && !useLegacy(ctx, provider, LegacyImplicitFlow, authReq.ClientID) {
+		return oidc.ErrUnauthorizedClient().WithDescription("the implicit flow is disabled")
+	}
+	if authReq.CodeChallenge != "" && authReq.CodeChallengeMethod != oidc.CodeChallengeMethodS256 &&
+		!useLegacy(ctx, provider, LegacyPlainPKCE, authReq.ClientID) {
+		return oidc.ErrInvalidRequest().WithDescription("code_challenge_method S256 required")
+	}
+	return nil
+}
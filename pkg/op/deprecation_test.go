@@ -0,0 +1,177 @@
+package op
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDeprecationTestProvider(policy DeprecationPolicy, storage Storage) (*Provider, *bytes.Buffer) {
+	logs := new(bytes.Buffer)
+	return &Provider{
+		deprecationPolicy: &policy,
+		storage:           storage,
+		logger:            slog.New(slog.NewTextHandler(logs, nil)),
+	}, logs
+}
+
+func TestValidateAuthReqDeprecations(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   DeprecationPolicy
+		authReq  *oidc.AuthRequest
+		wantErr  error
+		wantUsed LegacyBehavior
+	}{
+		{
+			name:    "code flow",
+			policy:  DeprecationPolicy{DisableImplicitFlow: true, DisablePlainPKCE: true},
+			authReq: &oidc.AuthRequest{ClientID: "client", ResponseType: oidc.ResponseTypeCode, CodeChallenge: "challenge", CodeChallengeMethod: oidc.CodeChallengeMethodS256},
+		},
+		{
+			name:     "implicit flow allowed",
+			authReq:  &oidc.AuthRequest{ClientID: "client", ResponseType: oidc.ResponseTypeIDToken},
+			wantUsed: LegacyImplicitFlow,
+		},
+		{
+			name:    "implicit flow disabled",
+			policy:  DeprecationPolicy{DisableImplicitFlow: true},
+			authReq: &oidc.AuthRequest{ClientID: "client", ResponseType: oidc.ResponseTypeIDTokenOnly},
+			wantErr: oidc.ErrUnauthorizedClient(),
+		},
+		{
+			name:     "plain PKCE allowed",
+			authReq:  &oidc.AuthRequest{ClientID: "client", ResponseType: oidc.ResponseTypeCode, CodeChallenge: "challenge"},
+			wantUsed: LegacyPlainPKCE,
+		},
+		{
+			name:    "plain PKCE disabled",
+			policy:  DeprecationPolicy{DisablePlainPKCE: true},
+			authReq: &oidc.AuthRequest{ClientID: "client", ResponseType: oidc.ResponseTypeCode, CodeChallenge: "challenge", CodeChallengeMethod: oidc.CodeChallengeMethodPlain},
+			wantErr: oidc.ErrInvalidRequest(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, logs := newDeprecationTestProvider(tt.policy, nil)
+			err := ValidateAuthReqDeprecations(context.Background(), provider, tt.authReq)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, logs.String())
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantUsed == "" {
+				assert.Empty(t, logs.String())
+				return
+			}
+			assert.Equal(t, []string{"client"}, provider.LegacyUsage().Clients(tt.wantUsed))
+			assert.Contains(t, logs.String(), "behavior="+string(tt.wantUsed)+" client_id=client")
+
+			// logged once per client
+			require.NoError(t, ValidateAuthReqDeprecations(context.Background(), provider, tt.authReq))
+			assert.Equal(t, 1, strings.Count(logs.String(), "deprecated behavior"))
+		})
+	}
+}
+
+type testRefreshTokenRequest struct {
+	RefreshTokenRequest
+}
+
+func (testRefreshTokenRequest) GetSubject() string     { return "sub" }
+func (testRefreshTokenRequest) GetAudience() []string  { return []string{"native"} }
+func (testRefreshTokenRequest) GetScopes() []string    { return []string{oidc.ScopeOfflineAccess} }
+func (testRefreshTokenRequest) GetClientID() string    { return "native" }
+func (testRefreshTokenRequest) GetAuthTime() time.Time { return time.Time{} }
+
+type testRefreshTokenStorage struct {
+	Storage
+	newRefreshToken string
+	revoked         *[]string
+}
+
+func (s testRefreshTokenStorage) CreateAccessAndRefreshTokens(context.Context, TokenRequest, string) (string, string, time.Time, error) {
+	return "accessTokenID", s.newRefreshToken, time.Now().Add(time.Hour), nil
+}
+
+func (s testRefreshTokenStorage) RevokeToken(_ context.Context, tokenID, _, _ string) *oidc.Error {
+	*s.revoked = append(*s.revoked, tokenID)
+	return nil
+}
+
+func Test_createTokens_refreshTokenReuse(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          DeprecationPolicy
+		publicClient    *PublicClientPolicy
+		newRefreshToken string
+		wantErr         bool
+		wantUsed        bool
+	}{
+		{
+			name:            "rotated",
+			policy:          DeprecationPolicy{DisableRefreshTokenReuse: true},
+			newRefreshToken: "new",
+		},
+		{
+			name:            "reused allowed",
+			newRefreshToken: "old",
+			wantUsed:        true,
+		},
+		{
+			name:     "none returned allowed",
+			wantUsed: true,
+		},
+		{
+			name:            "reused disabled",
+			policy:          DeprecationPolicy{DisableRefreshTokenReuse: true},
+			newRefreshToken: "old",
+			wantErr:         true,
+		},
+		{
+			name:            "reused by public client with rotate policy",
+			publicClient:    &PublicClientPolicy{RefreshTokens: PublicClientRefreshTokensRotate},
+			newRefreshToken: "old",
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var revoked []string
+			provider, _ := newDeprecationTestProvider(tt.policy, testRefreshTokenStorage{newRefreshToken: tt.newRefreshToken, revoked: &revoked})
+			provider.publicClientPolicy = tt.publicClient
+			_, newRefreshToken, _, err := createTokens(context.Background(), testRefreshTokenRequest{}, provider, "old", newClient(clientTypeNative))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrServerError())
+				assert.Equal(t, []string{"accessTokenID"}, revoked, "the persisted access token is revoked")
+				assert.Empty(t, provider.LegacyUsage().Clients(LegacyRefreshTokenReuse))
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, revoked)
+			assert.Equal(t, tt.newRefreshToken, newRefreshToken)
+			if tt.wantUsed {
+				assert.Equal(t, []string{"native"}, provider.LegacyUsage().Clients(LegacyRefreshTokenReuse))
+			} else {
+				assert.Empty(t, provider.LegacyUsage().Clients(LegacyRefreshTokenReuse))
+			}
+		})
+	}
+}
+
+func TestDiscovery_disabledImplicitFlow(t *testing.T) {
+	provider, _ := newDeprecationTestProvider(DeprecationPolicy{DisableImplicitFlow: true}, nil)
+	provider.state.Store(&providerState{config: &Config{}, endpoints: DefaultEndpoints})
+	assert.Equal(t, []string{string(oidc.ResponseTypeCode)}, ResponseTypes(provider))
+	assert.NotContains(t, GrantTypes(provider), oidc.GrantTypeImplicit)
+
+	assert.Contains(t, ResponseTypes(&Provider{}), string(oidc.ResponseTypeIDToken))
+}
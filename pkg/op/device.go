@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
 	"net/url"
@@ -13,7 +14,7 @@ import (
 	"strings"
 	"time"
 
-	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
 
@@ -21,6 +22,14 @@ type DeviceAuthorizationConfig struct {
 	Lifetime     time.Duration
 	PollInterval time.Duration
 
+	// MaxWait is the maximum duration a device access token request is held,
+	// waiting for the completion of the authorization, if the client asks for it
+	// by the wait preference of RFC 7240 (`Prefer: wait=30`) or by accepting
+	// Server-Sent Events (`Accept: text/event-stream`).
+	// It requires the Storage to implement [DeviceAuthorizationWatcher].
+	// Zero disables waiting and clients fall back to polling.
+	MaxWait time.Duration
+
 	// UserFormURL is the complete URL where the user must go to authorize the device.
 	// Deprecated: use UserFormPath instead.
 	UserFormURL string
@@ -29,8 +38,21 @@ type DeviceAuthorizationConfig struct {
 	// The hostname for the URL is taken from the request by IssuerFromContext.
 	UserFormPath string
 	UserCode     UserCodeConfig
+
+	// VerificationURICompleteTemplate builds the verification_uri_complete,
+	// which might be rendered as QR code by the device.
+	// The placeholders {verification_uri} and {user_code} (query escaped)
+	// are replaced, e.g. "https://example.com/activate/{user_code}".
+	// Defaults to the verification_uri with the user_code query parameter.
+	VerificationURICompleteTemplate string
 }
 
+// Placeholders of [DeviceAuthorizationConfig.VerificationURICompleteTemplate].
+const (
+	VerificationURIPlaceholder = "{verification_uri}"
+	UserCodePlaceholder        = "{user_code}"
+)
+
 type UserCodeConfig struct {
 	CharSet      string
 	CharAmount   int
@@ -77,7 +99,7 @@ func DeviceAuthorization(w http.ResponseWriter, r *http.Request, o OpenIDProvide
 		return err
 	}
 
-	httphelper.MarshalJSON(w, response)
+	WriteJSON(w, response, http.StatusOK)
 	return nil
 }
 
@@ -91,13 +113,25 @@ func createDeviceAuthorization(ctx context.Context, req *oidc.DeviceAuthorizatio
 	}
 	config := o.DeviceAuthorization()
 
-	deviceCode, _ := NewDeviceCode(RecommendedDeviceCodeBytes)
-	userCode, err := NewUserCode([]rune(config.UserCode.CharSet), config.UserCode.CharAmount, config.UserCode.DashInterval)
+	random := RandomFromContext(ctx)
+	deviceCode, err := crypto.RandomString(random, RecommendedDeviceCodeBytes)
+	if err != nil {
+		return nil, NewStatusError(err, http.StatusInternalServerError)
+	}
+	userCode, err := newUserCode(random, []rune(config.UserCode.CharSet), config.UserCode.CharAmount, config.UserCode.DashInterval)
 	if err != nil {
 		return nil, NewStatusError(err, http.StatusInternalServerError)
 	}
 
-	expires := time.Now().Add(config.Lifetime)
+	lifetime := config.Lifetime
+	lifetimes, err := clientFlowLifetimesByID(ctx, o, o.Storage(), clientID)
+	if err != nil {
+		return nil, oidc.ErrInvalidClient().WithParent(err)
+	}
+	if lifetimes != nil && lifetimes.DeviceCode > 0 {
+		lifetime = lifetimes.DeviceCode
+	}
+	expires := time.Now().Add(lifetime)
 	err = storage.StoreDeviceAuthorization(ctx, clientID, deviceCode, userCode, expires, req.Scopes)
 	if err != nil {
 		return nil, NewStatusError(err, http.StatusInternalServerError)
@@ -121,10 +155,17 @@ func createDeviceAuthorization(ctx context.Context, req *oidc.DeviceAuthorizatio
 		DeviceCode:      deviceCode,
 		UserCode:        userCode,
 		VerificationURI: verification.String(),
-		ExpiresIn:       int(config.Lifetime / time.Second),
+		ExpiresIn:       int(lifetime / time.Second),
 		Interval:        int(config.PollInterval / time.Second),
 	}
 
+	if config.VerificationURICompleteTemplate != "" {
+		response.VerificationURIComplete = strings.NewReplacer(
+			VerificationURIPlaceholder, response.VerificationURI,
+			UserCodePlaceholder, url.QueryEscape(userCode),
+		).Replace(config.VerificationURICompleteTemplate)
+		return response, nil
+	}
 	verification.RawQuery = "user_code=" + userCode
 	response.VerificationURIComplete = verification.String()
 	return response, nil
@@ -172,6 +213,10 @@ func NewDeviceCode(nBytes int) (string, error) {
 }
 
 func NewUserCode(charSet []rune, charAmount, dashInterval int) (string, error) {
+	return newUserCode(rand.Reader, charSet, charAmount, dashInterval)
+}
+
+func newUserCode(random io.Reader, charSet []rune, charAmount, dashInterval int) (string, error) {
 	var buf strings.Builder
 	if dashInterval > 0 {
 		buf.Grow(charAmount + charAmount/dashInterval - 1)
@@ -186,7 +231,7 @@ func NewUserCode(charSet []rune, charAmount, dashInterval int) (string, error) {
 			buf.WriteByte('-')
 		}
 
-		bi, err := rand.Int(rand.Reader, max)
+		bi, err := rand.Int(random, max)
 		if err != nil {
 			return "", fmt.Errorf("%w getting entropy for user code", err)
 		}
@@ -208,9 +253,11 @@ func DeviceAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchang
 }
 
 func deviceAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchanger) error {
+	wait, stream := deviceAuthorizationWait(r.Header, exchanger)
+
 	// use a limited context timeout shorter as the default
-	// poll interval of 5 seconds.
-	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+	// poll interval of 5 seconds, extended by the wait.
+	ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second+wait)
 	defer cancel()
 	r = r.WithContext(ctx)
 
@@ -224,7 +271,8 @@ func deviceAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchang
 		return err
 	}
 	tokenRequest, err := CheckDeviceAuthorizationState(ctx, clientID, req.DeviceCode, exchanger)
-	if err != nil {
+	pending := wait > 0 && errors.Is(err, oidc.ErrAuthorizationPending())
+	if err != nil && !pending {
 		return err
 	}
 
@@ -237,12 +285,24 @@ func deviceAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchang
 			WithDescription("confidential client requires authentication")
 	}
 
+	if pending {
+		if flusher, ok := w.(http.Flusher); stream && ok {
+			streamDeviceAccessToken(w, r, flusher, exchanger, client, req.DeviceCode, wait)
+			return nil
+		}
+		w.Header().Set("Preference-Applied", fmt.Sprintf("wait=%d", int(wait/time.Second)))
+		tokenRequest, err = WaitDeviceAuthorizationState(ctx, clientID, req.DeviceCode, wait, exchanger)
+		if err != nil {
+			return err
+		}
+	}
+
 	resp, err := CreateDeviceTokenResponse(r.Context(), tokenRequest, exchanger, client)
 	if err != nil {
 		return err
 	}
 
-	httphelper.MarshalJSON(w, resp)
+	WriteJSON(w, resp, http.StatusOK)
 	return nil
 }
 
@@ -342,14 +402,14 @@ func CreateDeviceTokenResponse(ctx context.Context, tokenRequest TokenRequest, c
 	response := &oidc.AccessTokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		TokenType:    oidc.BearerToken,
+		TokenType:    issuedTokenType(ctx),
 		ExpiresIn:    uint64(validity.Seconds()),
 		Scope:        tokenRequest.GetScopes(),
 	}
 
 	// TODO(v4): remove type assertion
 	if idTokenRequest, ok := tokenRequest.(IDTokenRequest); ok && slices.Contains(tokenRequest.GetScopes(), oidc.ScopeOpenID) {
-		response.IDToken, err = CreateIDToken(ctx, IssuerFromContext(ctx), idTokenRequest, client.IDTokenLifetime(), accessToken, "", creator.Storage(), client)
+		response.IDToken, err = createIDToken(ctx, IssuerFromContext(ctx), idTokenRequest, client.IDTokenLifetime(), accessToken, "", creator.Storage(), client, *newIDTokenOptions(creator))
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,179 @@
+package op
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// DeviceProof is the verification result of a device proof ([oidc.DeviceProofClaims]).
+type DeviceProof struct {
+	// GrantType is the grant of the token request, authorization_code or refresh_token.
+	GrantType oidc.GrantType
+	// Key is the public key of the device.
+	Key *jose.JSONWebKey
+	// Thumbprint is the base64url encoded SHA-256 JWK Thumbprint (RFC 7638) of the Key.
+	Thumbprint string
+	Claims     *oidc.DeviceProofClaims
+}
+
+// DeviceBindingStorage is an optional additional interface that may be implemented by
+// implementors of Storage. It enables sender-constrained refresh tokens,
+// bound to a key of the device, similar to Device Bound Session Credentials (DBSC).
+//
+// A client registers the device key by sending a device proof in the
+// [oidc.DeviceProofHeader] of the authorization_code (or refresh_token) grant.
+// Refresh tokens bound to a device key may only be used with a
+// device proof signed by that key.
+type DeviceBindingStorage interface {
+	// DeviceKeyByRefreshToken returns the device key bound to the refresh token,
+	// or nil if the refresh token is not bound.
+	DeviceKeyByRefreshToken(ctx context.Context, refreshToken string) (*jose.JSONWebKey, error)
+	// DeviceProofVerified is called with the verified device proof of a token request,
+	// before the tokens are created. If the request was not bound yet, the Storage
+	// must bind the Key to the refresh token created for the request.
+	// The JWTID of the Claims may be checked to prevent replays,
+	// unless a cache is set with [WithReplayCache].
+	// Returning an error aborts the token request.
+	DeviceProofVerified(ctx context.Context, request TokenRequest, proof *DeviceProof) error
+}
+
+// DeviceProofAlgorithms are the signature algorithms accepted for device proofs.
+var DeviceProofAlgorithms = []jose.SignatureAlgorithm{
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.RS256, jose.PS256, jose.EdDSA,
+}
+
+// DeviceProofMaxAge is the maximum time difference between
+// the `iat` of a device proof and the current time.
+const DeviceProofMaxAge = 5 * time.Minute
+
+var (
+	ErrDeviceProofType     = errors.New("device proof: invalid typ header")
+	ErrDeviceProofKey      = errors.New("device proof: missing or invalid key")
+	ErrDeviceProofAudience = errors.New("device proof: issuer missing in audience")
+	ErrDeviceProofIssuedAt = errors.New("device proof: iat out of range")
+	ErrDeviceProofHash     = errors.New("device proof: refresh token hash does not match")
+)
+
+// VerifyDeviceProof verifies the device proof JWT.
+// If key is nil, the proof is verified by the `jwk` embedded in its header.
+// If refreshToken is not empty, the proof must contain its hash.
+func VerifyDeviceProof(proof string, key *jose.JSONWebKey, issuer, refreshToken string) (*DeviceProof, error) {
+	jws, err := jose.ParseSigned(proof, DeviceProofAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("device proof: %w", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("device proof: must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != oidc.DeviceProofType {
+		return nil, ErrDeviceProofType
+	}
+	if key == nil {
+		key = header.JSONWebKey
+	}
+	if key == nil || !key.IsPublic() {
+		return nil, ErrDeviceProofKey
+	}
+	payload, err := jws.Verify(key)
+	if err != nil {
+		return nil, fmt.Errorf("device proof: %w", err)
+	}
+	claims := new(oidc.DeviceProofClaims)
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("device proof: %w", err)
+	}
+	if claims.JWTID == "" {
+		return nil, errors.New("device proof: jti missing")
+	}
+	if !slices.Contains(claims.Audience, issuer) {
+		return nil, ErrDeviceProofAudience
+	}
+	if diff := time.Since(claims.IssuedAt.AsTime()); diff > DeviceProofMaxAge || diff < -DeviceProofMaxAge {
+		return nil, ErrDeviceProofIssuedAt
+	}
+	if refreshToken != "" && claims.RefreshTokenHash != oidc.RefreshTokenHash(refreshToken) {
+		return nil, ErrDeviceProofHash
+	}
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("device proof: %w", err)
+	}
+	return &DeviceProof{
+		Key:        key,
+		Thumbprint: base64.RawURLEncoding.EncodeToString(thumbprint),
+		Claims:     claims,
+	}, nil
+}
+
+type replayCacheGetter interface {
+	ReplayCache() cache.Cache
+}
+
+func replayCache(v any) cache.Cache {
+	if getter, ok := v.(replayCacheGetter); ok {
+		return getter.ReplayCache()
+	}
+	return nil
+}
+
+// verifyDeviceBinding verifies the device proof of the token request,
+// if the storage implements [DeviceBindingStorage].
+// A proof is required if the refresh token is bound to a device key.
+// Proofs whose jti is already recorded in the replay cache are rejected.
+func verifyDeviceBinding(ctx context.Context, storage Storage, replay cache.Cache, header http.Header, request TokenRequest, grantType oidc.GrantType, refreshToken string) error {
+	binding, ok := storage.(DeviceBindingStorage)
+	if !ok {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "verifyDeviceBinding")
+	defer span.End()
+
+	proof := header.Get(oidc.DeviceProofHeader)
+	var key *jose.JSONWebKey
+	if grantType == oidc.GrantTypeRefreshToken {
+		var err error
+		key, err = binding.DeviceKeyByRefreshToken(ctx, refreshToken)
+		if err != nil {
+			return oidc.ErrInvalidGrant().WithParent(err)
+		}
+		if key != nil && proof == "" {
+			return oidc.ErrInvalidGrant().WithDescription("device proof required")
+		}
+	}
+	if proof == "" {
+		return nil
+	}
+	result, err := VerifyDeviceProof(proof, key, IssuerFromContext(ctx), refreshToken)
+	if err != nil {
+		return oidc.ErrInvalidGrant().WithDescription("invalid device proof").WithParent(err)
+	}
+	if replay != nil {
+		// proofs are accepted within DeviceProofMaxAge before and after their iat
+		err = cache.CheckReplay(ctx, replay, "device_proof:"+result.Thumbprint+":"+result.Claims.JWTID, 2*DeviceProofMaxAge)
+		if errors.Is(err, cache.ErrReplay) {
+			return oidc.ErrInvalidGrant().WithDescription("device proof replayed")
+		}
+		if err != nil {
+			return oidc.ErrServerError().WithParent(err)
+		}
+	}
+	result.GrantType = grantType
+	if err = binding.DeviceProofVerified(ctx, request, result); err != nil {
+		return oidc.DefaultToServerError(err, "unable to verify device proof")
+	}
+	return nil
+}
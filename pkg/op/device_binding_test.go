@@ -0,0 +1,160 @@
+package op
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deviceTestIssuer = "https://issuer.example.com"
+
+type deviceBindingStorage struct {
+	Storage
+	keys map[string]*jose.JSONWebKey
+}
+
+func (s *deviceBindingStorage) DeviceKeyByRefreshToken(_ context.Context, refreshToken string) (*jose.JSONWebKey, error) {
+	return s.keys[refreshToken], nil
+}
+
+func (s *deviceBindingStorage) DeviceProofVerified(_ context.Context, _ TokenRequest, proof *DeviceProof) error {
+	if proof.GrantType == oidc.GrantTypeCode {
+		s.keys["rt1"] = proof.Key
+	}
+	return nil
+}
+
+func newTestDeviceProof(t *testing.T, key *ecdsa.PrivateKey, claims *oidc.DeviceProofClaims) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{EmbedJWK: true}).WithType(oidc.DeviceProofType))
+	require.NoError(t, err)
+	proof, err := crypto.Sign(claims, signer)
+	require.NoError(t, err)
+	return proof
+}
+
+func Test_verifyDeviceBinding(t *testing.T) {
+	ctx := ContextWithIssuer(context.Background(), deviceTestIssuer)
+	deviceKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	storage := &deviceBindingStorage{keys: make(map[string]*jose.JSONWebKey)}
+	header := func(proof string) http.Header {
+		h := make(http.Header)
+		if proof != "" {
+			h.Set(oidc.DeviceProofHeader, proof)
+		}
+		return h
+	}
+	claims := func(refreshToken string) *oidc.DeviceProofClaims {
+		c := &oidc.DeviceProofClaims{JWTID: "1", IssuedAt: oidc.NowTime(), Audience: oidc.Audience{deviceTestIssuer}}
+		if refreshToken != "" {
+			c.RefreshTokenHash = oidc.RefreshTokenHash(refreshToken)
+		}
+		return c
+	}
+
+	// without implementing the storage, the proof is ignored
+	err = verifyDeviceBinding(ctx, struct{ Storage }{}, nil, header("invalid"), nil, oidc.GrantTypeCode, "")
+	require.NoError(t, err)
+
+	// code exchange registers the key
+	err = verifyDeviceBinding(ctx, storage, nil, header(newTestDeviceProof(t, deviceKey, claims(""))), nil, oidc.GrantTypeCode, "")
+	require.NoError(t, err)
+	require.Contains(t, storage.keys, "rt1")
+
+	tests := []struct {
+		name         string
+		proof        string
+		refreshToken string
+		wantErr      bool
+	}{
+		{
+			name:         "unbound without proof",
+			refreshToken: "rt2",
+		},
+		{
+			name:         "bound without proof",
+			refreshToken: "rt1",
+			wantErr:      true,
+		},
+		{
+			name:         "bound with proof",
+			proof:        newTestDeviceProof(t, deviceKey, claims("rt1")),
+			refreshToken: "rt1",
+		},
+		{
+			name:         "signed by other key",
+			proof:        newTestDeviceProof(t, otherKey, claims("rt1")),
+			refreshToken: "rt1",
+			wantErr:      true,
+		},
+		{
+			name:         "wrong refresh token hash",
+			proof:        newTestDeviceProof(t, deviceKey, claims("rt2")),
+			refreshToken: "rt1",
+			wantErr:      true,
+		},
+		{
+			name: "expired",
+			proof: newTestDeviceProof(t, deviceKey, &oidc.DeviceProofClaims{
+				JWTID:            "1",
+				IssuedAt:         oidc.FromTime(time.Now().Add(-time.Hour)),
+				Audience:         oidc.Audience{deviceTestIssuer},
+				RefreshTokenHash: oidc.RefreshTokenHash("rt1"),
+			}),
+			refreshToken: "rt1",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyDeviceBinding(ctx, storage, nil, header(tt.proof), nil, oidc.GrantTypeRefreshToken, tt.refreshToken)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidGrant())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_verifyDeviceBinding_replay(t *testing.T) {
+	ctx := ContextWithIssuer(context.Background(), deviceTestIssuer)
+	deviceKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	storage := &deviceBindingStorage{keys: make(map[string]*jose.JSONWebKey)}
+	replay := cache.NewMemory()
+	header := make(http.Header)
+	header.Set(oidc.DeviceProofHeader, newTestDeviceProof(t, deviceKey, &oidc.DeviceProofClaims{
+		JWTID: "1", IssuedAt: oidc.NowTime(), Audience: oidc.Audience{deviceTestIssuer},
+	}))
+
+	require.NoError(t, verifyDeviceBinding(ctx, storage, replay, header, nil, oidc.GrantTypeCode, ""))
+	err = verifyDeviceBinding(ctx, storage, replay, header, nil, oidc.GrantTypeCode, "")
+	assert.ErrorIs(t, err, oidc.ErrInvalidGrant())
+
+	assert.Nil(t, replayCache(&Provider{}))
+	assert.Equal(t, replay, replayCache(&Provider{replayCache: replay}))
+}
+
+func TestVerifyDeviceProof_Audience(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	proof := newTestDeviceProof(t, key, &oidc.DeviceProofClaims{JWTID: "1", IssuedAt: oidc.NowTime(), Audience: oidc.Audience{"other"}})
+	_, err = VerifyDeviceProof(proof, nil, deviceTestIssuer, "")
+	assert.ErrorIs(t, err, ErrDeviceProofAudience)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
 	"io"
 	mr "math/rand"
 	"net/http"
@@ -23,24 +24,36 @@ import (
 
 func Test_deviceAuthorizationHandler(t *testing.T) {
 	type conf struct {
-		UserFormURL  string
-		UserFormPath string
+		UserFormURL                     string
+		UserFormPath                    string
+		VerificationURICompleteTemplate string
 	}
 	tests := []struct {
-		name string
-		conf conf
+		name         string
+		conf         conf
+		wantComplete string
 	}{
 		{
 			name: "UserFormURL",
 			conf: conf{
 				UserFormURL: "https://localhost:9998/device",
 			},
+			wantComplete: "https://localhost:9998/device?user_code=JKRV-FRGK",
 		},
 		{
 			name: "UserFormPath",
 			conf: conf{
 				UserFormPath: "/device",
 			},
+			wantComplete: "https://localhost:9998/device?user_code=JKRV-FRGK",
+		},
+		{
+			name: "VerificationURICompleteTemplate",
+			conf: conf{
+				UserFormPath:                    "/device",
+				VerificationURICompleteTemplate: "{verification_uri}/{user_code}",
+			},
+			wantComplete: "https://localhost:9998/device/JKRV-FRGK",
 		},
 	}
 	for _, tt := range tests {
@@ -48,6 +61,7 @@ func Test_deviceAuthorizationHandler(t *testing.T) {
 			conf := gu.PtrCopy(testConfig)
 			conf.DeviceAuthorization.UserFormURL = tt.conf.UserFormURL
 			conf.DeviceAuthorization.UserFormPath = tt.conf.UserFormPath
+			conf.DeviceAuthorization.VerificationURICompleteTemplate = tt.conf.VerificationURICompleteTemplate
 			provider := newTestProvider(conf)
 
 			req := &oidc.DeviceAuthorizationRequest{
@@ -73,7 +87,7 @@ func Test_deviceAuthorizationHandler(t *testing.T) {
 			assert.Less(t, result.StatusCode, 300)
 
 			got, _ := io.ReadAll(result.Body)
-			assert.JSONEq(t, `{"device_code":"Uv38ByGCZU8WP18PmmIdcg", "expires_in":300, "interval":5, "user_code":"JKRV-FRGK", "verification_uri":"https://localhost:9998/device", "verification_uri_complete":"https://localhost:9998/device?user_code=JKRV-FRGK"}`, string(got))
+			assert.JSONEq(t, `{"device_code":"Uv38ByGCZU8WP18PmmIdcg", "expires_in":300, "interval":5, "user_code":"JKRV-FRGK", "verification_uri":"https://localhost:9998/device", "verification_uri_complete":"`+tt.wantComplete+`"}`, string(got))
 		})
 	}
 }
@@ -332,6 +346,117 @@ func TestDeviceAccessToken(t *testing.T) {
 	assert.NotEmpty(t, string(got))
 }
 
+func TestDeviceAccessToken_wait(t *testing.T) {
+	conf := gu.PtrCopy(testConfig)
+	conf.DeviceAuthorization.MaxWait = 2 * time.Second
+	provider := newTestProvider(conf)
+	storage := provider.Storage().(*storage.Storage)
+
+	tests := []struct {
+		name          string
+		header        http.Header
+		complete      func(userCode string) error
+		wantStatus    int
+		wantHeader    http.Header
+		wantBody      string
+		wantMinDelay  time.Duration
+		wantMaxDelay  time.Duration
+		wantEventType string
+	}{
+		{
+			name:         "no wait",
+			complete:     func(string) error { return nil },
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     `"error":"authorization_pending"`,
+			wantMaxDelay: time.Second,
+		},
+		{
+			name:   "long poll completed",
+			header: http.Header{"Prefer": {"wait=10"}},
+			complete: func(userCode string) error {
+				return storage.CompleteDeviceAuthorization(context.Background(), userCode, "tim")
+			},
+			wantStatus:   http.StatusOK,
+			wantHeader:   http.Header{"Preference-Applied": {"wait=2"}},
+			wantBody:     `"access_token":`,
+			wantMaxDelay: time.Second,
+		},
+		{
+			name:   "long poll denied",
+			header: http.Header{"Prefer": {"respond-async, wait=1"}},
+			complete: func(userCode string) error {
+				return storage.DenyDeviceAuthorization(context.Background(), userCode)
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantHeader:   http.Header{"Preference-Applied": {"wait=1"}},
+			wantBody:     `"error":"access_denied"`,
+			wantMaxDelay: 900 * time.Millisecond,
+		},
+		{
+			name:         "long poll elapsed",
+			header:       http.Header{"Prefer": {"wait=1"}},
+			complete:     func(string) error { return nil },
+			wantStatus:   http.StatusBadRequest,
+			wantHeader:   http.Header{"Preference-Applied": {"wait=1"}},
+			wantBody:     `"error":"authorization_pending"`,
+			wantMinDelay: time.Second,
+			wantMaxDelay: 2 * time.Second,
+		},
+		{
+			name:   "event stream",
+			header: http.Header{"Accept": {"text/event-stream"}},
+			complete: func(userCode string) error {
+				return storage.CompleteDeviceAuthorization(context.Background(), userCode, "tim")
+			},
+			wantStatus:   http.StatusOK,
+			wantHeader:   http.Header{"Content-Type": {"text/event-stream"}},
+			wantBody:     "event: token\ndata: {\"access_token\":",
+			wantMaxDelay: time.Second,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceCode, userCode := fmt.Sprintf("wait_device_%d", i), fmt.Sprintf("wait_user_%d", i)
+			err := storage.StoreDeviceAuthorization(context.Background(), "native", deviceCode, userCode, time.Now().Add(time.Minute), []string{"foo"})
+			require.NoError(t, err)
+
+			values := make(url.Values)
+			values.Set("client_id", "native")
+			values.Set("grant_type", string(oidc.GrantTypeDeviceCode))
+			values.Set("device_code", deviceCode)
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(values.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			r = r.WithContext(op.ContextWithIssuer(r.Context(), testIssuer))
+			w := httptest.NewRecorder()
+
+			timer := time.AfterFunc(100*time.Millisecond, func() {
+				assert.NoError(t, tt.complete(userCode))
+			})
+			defer timer.Stop()
+
+			start := time.Now()
+			op.DeviceAccessToken(w, r, provider)
+			delay := time.Since(start)
+
+			result := w.Result()
+			got, _ := io.ReadAll(result.Body)
+			assert.Equal(t, tt.wantStatus, result.StatusCode)
+			for k := range tt.wantHeader {
+				assert.Equal(t, tt.wantHeader.Get(k), result.Header.Get(k), k)
+			}
+			if tt.wantHeader.Get("Preference-Applied") == "" {
+				assert.Empty(t, result.Header.Get("Preference-Applied"))
+			}
+			assert.Contains(t, string(got), tt.wantBody)
+			assert.GreaterOrEqual(t, delay, tt.wantMinDelay)
+			assert.Less(t, delay, tt.wantMaxDelay)
+		})
+	}
+}
+
 func TestCheckDeviceAuthorizationState(t *testing.T) {
 	now := time.Now()
 
@@ -0,0 +1,212 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// Events of the Server-Sent Events stream of a device access token request,
+// carrying the JSON encoded token response or error.
+const (
+	DeviceEventToken      = "token"
+	DeviceEventTokenError = "token_error"
+)
+
+const eventStreamMediaType = "text/event-stream"
+
+// DeviceAuthorizationNotifier implements the [DeviceAuthorizationWatcher] in memory,
+// for storages running on a single instance. The storage calls Notify,
+// when it completes or denies a device authorization.
+// The zero value is ready to use.
+type DeviceAuthorizationNotifier struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func (n *DeviceAuthorizationNotifier) WatchDeviceAuthorization(ctx context.Context, clientID, deviceCode string) (<-chan struct{}, error) {
+	changed := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	if n.watchers == nil {
+		n.watchers = make(map[string]map[chan struct{}]struct{})
+	}
+	if n.watchers[deviceCode] == nil {
+		n.watchers[deviceCode] = make(map[chan struct{}]struct{})
+	}
+	n.watchers[deviceCode][changed] = struct{}{}
+	n.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.watchers[deviceCode], changed)
+		if len(n.watchers[deviceCode]) == 0 {
+			delete(n.watchers, deviceCode)
+		}
+	})
+	return changed, nil
+}
+
+// Notify wakes up the requests waiting for the device authorization.
+func (n *DeviceAuthorizationNotifier) Notify(deviceCode string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for changed := range n.watchers[deviceCode] {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+type deviceAuthorizationConfigGetter interface {
+	DeviceAuthorization() DeviceAuthorizationConfig
+}
+
+func deviceAuthorizationConfig(exchanger Exchanger) DeviceAuthorizationConfig {
+	if getter, ok := exchanger.(deviceAuthorizationConfigGetter); ok {
+		return getter.DeviceAuthorization()
+	}
+	return DeviceAuthorizationConfig{}
+}
+
+// deviceAuthorizationWait returns how long a device access token request
+// waits for the completion of the authorization and if the client accepts
+// Server-Sent Events. The wait is zero, if unsupported by the provider.
+func deviceAuthorizationWait(header http.Header, exchanger Exchanger) (wait time.Duration, stream bool) {
+	if _, ok := exchanger.Storage().(DeviceAuthorizationWatcher); !ok {
+		return 0, false
+	}
+	maxWait := deviceAuthorizationConfig(exchanger).MaxWait
+	if maxWait <= 0 {
+		return 0, false
+	}
+	if acceptsEventStream(header) {
+		return maxWait, true
+	}
+	return min(preferredWait(header), maxWait), false
+}
+
+// preferredWait returns the wait preference of RFC 7240, section 4.3.
+func preferredWait(header http.Header) time.Duration {
+	for _, value := range header.Values("Prefer") {
+		for _, preference := range strings.Split(value, ",") {
+			preference, _, _ = strings.Cut(preference, ";")
+			name, value, _ := strings.Cut(preference, "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "wait") {
+				continue
+			}
+			seconds, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+			if err != nil || seconds < 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+func acceptsEventStream(header http.Header) bool {
+	for _, value := range header.Values("Accept") {
+		for _, mediaType := range strings.Split(value, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), eventStreamMediaType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WaitDeviceAuthorizationState is like [CheckDeviceAuthorizationState], but waits up to wait
+// for a pending authorization to be completed or denied, if the Storage implements
+// [DeviceAuthorizationWatcher]. The state is checked on every change and at the poll interval.
+// If the wait elapses, the authorization_pending error is returned, so the client continues polling.
+func WaitDeviceAuthorizationState(ctx context.Context, clientID, deviceCode string, wait time.Duration, exchanger Exchanger) (*DeviceAuthorizationState, error) {
+	return waitDeviceAuthorizationState(ctx, clientID, deviceCode, wait, nil, exchanger)
+}
+
+// waitDeviceAuthorizationState calls pending at the poll interval, while the authorization is pending.
+func waitDeviceAuthorizationState(ctx context.Context, clientID, deviceCode string, wait time.Duration, pending func(), exchanger Exchanger) (*DeviceAuthorizationState, error) {
+	ctx, span := tracer.Start(ctx, "WaitDeviceAuthorizationState")
+	defer span.End()
+
+	watcher, ok := exchanger.Storage().(DeviceAuthorizationWatcher)
+	if !ok || wait <= 0 {
+		return CheckDeviceAuthorizationState(ctx, clientID, deviceCode, exchanger)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	changed, err := watcher.WatchDeviceAuthorization(waitCtx, clientID, deviceCode)
+	if err != nil {
+		return CheckDeviceAuthorizationState(ctx, clientID, deviceCode, exchanger)
+	}
+
+	interval := deviceAuthorizationConfig(exchanger).PollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		state, err := CheckDeviceAuthorizationState(ctx, clientID, deviceCode, exchanger)
+		if !errors.Is(err, oidc.ErrAuthorizationPending()) {
+			return state, err
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+			if pending != nil {
+				pending()
+			}
+		case <-waitCtx.Done():
+			return state, err
+		}
+	}
+}
+
+// streamDeviceAccessToken answers a pending device access token request by Server-Sent Events.
+// A comment is sent at the poll interval, until the DeviceEventToken or DeviceEventTokenError
+// event, after which the stream is closed.
+func streamDeviceAccessToken(w http.ResponseWriter, r *http.Request, flusher http.Flusher, exchanger Exchanger, client Client, deviceCode string, wait time.Duration) {
+	w.Header().Set("Content-Type", eventStreamMediaType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	state, err := waitDeviceAuthorizationState(r.Context(), client.GetID(), deviceCode, wait, func() {
+		io.WriteString(w, ": "+string(oidc.AuthorizationPending)+"\n\n")
+		flusher.Flush()
+	}, exchanger)
+	var resp *oidc.AccessTokenResponse
+	if err == nil {
+		resp, err = CreateDeviceTokenResponse(r.Context(), state, exchanger, client)
+	}
+	if err != nil {
+		e := oidc.DefaultToServerError(err, err.Error())
+		exchanger.Logger().Log(r.Context(), e.LogLevel(), "request error", "oidc_error", e)
+		writeEvent(w, DeviceEventTokenError, e)
+	} else {
+		writeEvent(w, DeviceEventToken, resp)
+	}
+	flusher.Flush()
+}
+
+func writeEvent(w io.Writer, event string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
@@ -6,7 +6,6 @@ import (
 
 	jose "github.com/go-jose/go-jose/v4"
 
-	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
 
@@ -30,12 +29,12 @@ func discoveryHandler(c Configuration, s DiscoverStorage) func(http.ResponseWrit
 }
 
 func Discover(w http.ResponseWriter, config *oidc.DiscoveryConfiguration) {
-	httphelper.MarshalJSON(w, config)
+	WriteJSON(w, config, http.StatusOK)
 }
 
 func CreateDiscoveryConfig(ctx context.Context, config Configuration, storage DiscoverStorage) *oidc.DiscoveryConfiguration {
 	issuer := IssuerFromContext(ctx)
-	return &oidc.DiscoveryConfiguration{
+	return signMetadata(ctx, config, storage, &oidc.DiscoveryConfiguration{
 		Issuer:                                     issuer,
 		AuthorizationEndpoint:                      config.AuthorizationEndpoint().Absolute(issuer),
 		TokenEndpoint:                              config.TokenEndpoint().Absolute(issuer),
@@ -45,6 +44,7 @@ func CreateDiscoveryConfig(ctx context.Context, config Configuration, storage Di
 		EndSessionEndpoint:                         config.EndSessionEndpoint().Absolute(issuer),
 		JwksURI:                                    config.KeysEndpoint().Absolute(issuer),
 		DeviceAuthorizationEndpoint:                config.DeviceAuthorizationEndpoint().Absolute(issuer),
+		MTLSEndpointAliases:                        discoveryMTLSAliases(config, issuer, configEndpoints(config)),
 		CheckSessionIframe:                         config.CheckSessionIframe().Absolute(issuer),
 		ScopesSupported:                            Scopes(config),
 		ResponseTypesSupported:                     ResponseTypes(config),
@@ -52,6 +52,7 @@ func CreateDiscoveryConfig(ctx context.Context, config Configuration, storage Di
 		SubjectTypesSupported:                      SubjectTypes(config),
 		IDTokenSigningAlgValuesSupported:           SigAlgorithms(ctx, storage),
 		RequestObjectSigningAlgValuesSupported:     RequestObjectSigAlgorithms(config),
+		DPoPSigningAlgValuesSupported:              DPoPSigAlgorithms(config),
 		TokenEndpointAuthMethodsSupported:          AuthMethodsTokenEndpoint(config),
 		TokenEndpointAuthSigningAlgValuesSupported: TokenSigAlgorithms(config),
 		IntrospectionEndpointAuthSigningAlgValuesSupported: IntrospectionSigAlgorithms(config),
@@ -64,12 +65,24 @@ func CreateDiscoveryConfig(ctx context.Context, config Configuration, storage Di
 		RequestParameterSupported:                          config.RequestObjectSupported(),
 		BackChannelLogoutSupported:                         config.BackChannelLogoutSupported(),
 		BackChannelLogoutSessionSupported:                  config.BackChannelLogoutSessionSupported(),
-	}
+		PushedAuthorizationRequestEndpoint:                 pushedAuthorizationEndpoint(config, pushedAuthorizationEndpointOf(config), issuer),
+		RequirePushedAuthorizationRequests:                 pushedAuthorizationRequired(config),
+		ResponseModesSupported:                             ResponseModes(config),
+		AuthorizationSigningAlgValuesSupported:             AuthorizationSigAlgorithms(ctx, config, storage),
+		BackchannelAuthenticationEndpoint:                  backchannelAuthenticationEndpoint(config, backchannelAuthenticationEndpointOf(config), issuer),
+		BackchannelTokenDeliveryModesSupported:             BackchannelTokenDeliveryModes(config),
+		BackchannelUserCodeParameterSupported:              backchannelUserCodeSupported(config),
+		RegistrationEndpoint:                               clientRegistrationEndpoint(config, clientRegistrationEndpointOf(config), issuer),
+		SessionTransferEndpoint:                            sessionTransferEndpoint(config, sessionTransferEndpointOf(config), issuer),
+		ConsentReceiptsEndpoint:                            consentReceiptsEndpoint(config, consentReceiptsEndpointOf(config), issuer),
+		TLSClientCertificateBoundAccessTokens:              boundAccessTokens(config),
+		AuthorizationDetailsTypesSupported:                 authorizationDetailTypes(config).Types(),
+	})
 }
 
 func createDiscoveryConfigV2(ctx context.Context, config Configuration, storage DiscoverStorage, endpoints *Endpoints) *oidc.DiscoveryConfiguration {
 	issuer := IssuerFromContext(ctx)
-	return &oidc.DiscoveryConfiguration{
+	return signMetadata(ctx, config, storage, &oidc.DiscoveryConfiguration{
 		Issuer:                                     issuer,
 		AuthorizationEndpoint:                      endpoints.Authorization.Absolute(issuer),
 		TokenEndpoint:                              endpoints.Token.Absolute(issuer),
@@ -79,12 +92,14 @@ func createDiscoveryConfigV2(ctx context.Context, config Configuration, storage
 		EndSessionEndpoint:                         endpoints.EndSession.Absolute(issuer),
 		JwksURI:                                    endpoints.JwksURI.Absolute(issuer),
 		DeviceAuthorizationEndpoint:                endpoints.DeviceAuthorization.Absolute(issuer),
+		MTLSEndpointAliases:                        discoveryMTLSAliases(config, issuer, endpoints),
 		ScopesSupported:                            Scopes(config),
 		ResponseTypesSupported:                     ResponseTypes(config),
 		GrantTypesSupported:                        GrantTypes(config),
 		SubjectTypesSupported:                      SubjectTypes(config),
 		IDTokenSigningAlgValuesSupported:           SigAlgorithms(ctx, storage),
 		RequestObjectSigningAlgValuesSupported:     RequestObjectSigAlgorithms(config),
+		DPoPSigningAlgValuesSupported:              DPoPSigAlgorithms(config),
 		TokenEndpointAuthMethodsSupported:          AuthMethodsTokenEndpoint(config),
 		TokenEndpointAuthSigningAlgValuesSupported: TokenSigAlgorithms(config),
 		IntrospectionEndpointAuthSigningAlgValuesSupported: IntrospectionSigAlgorithms(config),
@@ -97,18 +112,33 @@ func createDiscoveryConfigV2(ctx context.Context, config Configuration, storage
 		RequestParameterSupported:                          config.RequestObjectSupported(),
 		BackChannelLogoutSupported:                         config.BackChannelLogoutSupported(),
 		BackChannelLogoutSessionSupported:                  config.BackChannelLogoutSessionSupported(),
-	}
+		PushedAuthorizationRequestEndpoint:                 pushedAuthorizationEndpoint(config, endpoints.PushedAuthorization, issuer),
+		RequirePushedAuthorizationRequests:                 pushedAuthorizationRequired(config),
+		ResponseModesSupported:                             ResponseModes(config),
+		AuthorizationSigningAlgValuesSupported:             AuthorizationSigAlgorithms(ctx, config, storage),
+		BackchannelAuthenticationEndpoint:                  backchannelAuthenticationEndpoint(config, endpoints.BackchannelAuthentication, issuer),
+		BackchannelTokenDeliveryModesSupported:             BackchannelTokenDeliveryModes(config),
+		BackchannelUserCodeParameterSupported:              backchannelUserCodeSupported(config),
+		RegistrationEndpoint:                               clientRegistrationEndpoint(config, endpoints.Registration, issuer),
+		SessionTransferEndpoint:                            sessionTransferEndpoint(config, endpoints.SessionTransfer, issuer),
+		ConsentReceiptsEndpoint:                            consentReceiptsEndpoint(config, endpoints.ConsentReceipts, issuer),
+		TLSClientCertificateBoundAccessTokens:              boundAccessTokens(config),
+		AuthorizationDetailsTypesSupported:                 authorizationDetailTypes(config).Types(),
+	})
 }
 
 func Scopes(c Configuration) []string {
 	provider, ok := c.(*Provider)
-	if ok && provider.config.SupportedScopes != nil {
-		return provider.config.SupportedScopes
+	if ok && provider.currentConfig().SupportedScopes != nil {
+		return provider.currentConfig().SupportedScopes
 	}
 	return DefaultSupportedScopes
 }
 
 func ResponseTypes(c Configuration) []string {
+	if deprecationPolicy(c).DisableImplicitFlow {
+		return []string{string(oidc.ResponseTypeCode)}
+	}
 	return []string{
 		string(oidc.ResponseTypeCode),
 		string(oidc.ResponseTypeIDTokenOnly),
@@ -119,7 +149,9 @@ func ResponseTypes(c Configuration) []string {
 func GrantTypes(c Configuration) []oidc.GrantType {
 	grantTypes := []oidc.GrantType{
 		oidc.GrantTypeCode,
-		oidc.GrantTypeImplicit,
+	}
+	if !deprecationPolicy(c).DisableImplicitFlow {
+		grantTypes = append(grantTypes, oidc.GrantTypeImplicit)
 	}
 	if c.GrantTypeRefreshTokenSupported() {
 		grantTypes = append(grantTypes, oidc.GrantTypeRefreshToken)
@@ -136,6 +168,12 @@ func GrantTypes(c Configuration) []oidc.GrantType {
 	if c.GrantTypeDeviceCodeSupported() {
 		grantTypes = append(grantTypes, oidc.GrantTypeDeviceCode)
 	}
+	if backchannelAuthentication(c) != nil {
+		grantTypes = append(grantTypes, oidc.GrantTypeCIBA)
+	}
+	if sessionTransfer(c) != nil {
+		grantTypes = append(grantTypes, oidc.GrantTypeSessionTransfer)
+	}
 	return grantTypes
 }
 
@@ -165,6 +203,18 @@ func RequestObjectSigAlgorithms(c Configuration) []string {
 	return c.RequestObjectSigningAlgorithmsSupported()
 }
 
+// DPoPSigAlgorithms returns the algorithms of DPoP proofs, if enabled with [WithDPoP].
+func DPoPSigAlgorithms(c Configuration) []string {
+	if !dpopEnabled(c) {
+		return nil
+	}
+	algs := make([]string, len(oidc.DPoPAlgorithms))
+	for i, alg := range oidc.DPoPAlgorithms {
+		algs[i] = string(alg)
+	}
+	return algs
+}
+
 func AuthMethodsTokenEndpoint(c Configuration) []oidc.AuthMethod {
 	authMethods := []oidc.AuthMethod{
 		oidc.AuthMethodNone,
@@ -176,6 +226,12 @@ func AuthMethodsTokenEndpoint(c Configuration) []oidc.AuthMethod {
 	if c.AuthMethodPrivateKeyJWTSupported() {
 		authMethods = append(authMethods, oidc.AuthMethodPrivateKeyJWT)
 	}
+	if config := mtlsConfig(c); config != nil {
+		authMethods = append(authMethods, config.authMethods()...)
+	}
+	if spiffeConfig(c) != nil {
+		authMethods = append(authMethods, oidc.AuthMethodSPIFFEJWT)
+	}
 	return authMethods
 }
 
@@ -226,11 +282,11 @@ func AuthMethodsRevocationEndpoint(c Configuration) []oidc.AuthMethod {
 
 func SupportedClaims(c Configuration) []string {
 	provider, ok := c.(*Provider)
-	if ok && provider.config.SupportedClaims != nil {
-		return provider.config.SupportedClaims
+	if ok && provider.currentConfig().SupportedClaims != nil {
+		return withCustomClaims(c, provider.currentConfig().SupportedClaims)
 	}
 
-	return DefaultSupportedClaims
+	return withCustomClaims(c, DefaultSupportedClaims)
 }
 
 func CodeChallengeMethods(c Configuration) []oidc.CodeChallengeMethod {
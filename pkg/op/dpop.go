@@ -0,0 +1,130 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type dpopThumbprintKey struct{}
+
+// DPoPThumbprintFromContext returns the JWK Thumbprint of the DPoP key (RFC 9449)
+// of the token request, if enabled with [WithDPoP] and the client sent a valid proof.
+//
+// JWT access tokens are bound to the key by their cnf claim.
+// Storages issuing opaque access tokens should record the thumbprint
+// in CreateAccessToken and return it as [oidc.Confirmation] of the
+// introspection response, so resource servers can verify the binding.
+func DPoPThumbprintFromContext(ctx context.Context) string {
+	thumbprint, _ := ctx.Value(dpopThumbprintKey{}).(string)
+	return thumbprint
+}
+
+func contextWithDPoPThumbprint(ctx context.Context, thumbprint string) context.Context {
+	return context.WithValue(ctx, dpopThumbprintKey{}, thumbprint)
+}
+
+// tokenConfirmation returns the cnf claim of the access tokens issued for the request,
+// binding them to the DPoP key and the client certificate, if any.
+func tokenConfirmation(ctx context.Context) *oidc.Confirmation {
+	cnf := &oidc.Confirmation{
+		JWKThumbprint:         DPoPThumbprintFromContext(ctx),
+		CertificateThumbprint: CertificateThumbprintFromContext(ctx),
+	}
+	if cnf.JWKThumbprint == "" && cnf.CertificateThumbprint == "" {
+		return nil
+	}
+	return cnf
+}
+
+// DPoPBoundRefreshTokenRequest is a RefreshTokenRequest with the JWK Thumbprint
+// of the DPoP key the refresh token is bound to, if any.
+// Refresh tokens issued to public clients with a DPoP proof are bound to its key
+// (RFC 9449, section 5): the Storage records [DPoPThumbprintFromContext] in
+// CreateAccessAndRefreshTokens and keeps it on rotation. Refresh token requests
+// of public clients must then present a DPoP proof of the same key.
+type DPoPBoundRefreshTokenRequest interface {
+	RefreshTokenRequest
+	GetDPoPThumbprint() string
+}
+
+// validateRefreshTokenDPoP requires the DPoP proof of refresh token requests
+// of public clients to use the key the refresh token is bound to.
+func validateRefreshTokenDPoP(ctx context.Context, client Client, request RefreshTokenRequest) error {
+	bound, ok := request.(DPoPBoundRefreshTokenRequest)
+	if !ok || client.AuthMethod() != oidc.AuthMethodNone || bound.GetDPoPThumbprint() == "" {
+		return nil
+	}
+	thumbprint := DPoPThumbprintFromContext(ctx)
+	if thumbprint == "" {
+		return oidc.ErrInvalidDPoPProof().WithDescription("DPoP proof required for the refresh token")
+	}
+	if thumbprint != bound.GetDPoPThumbprint() {
+		return oidc.ErrInvalidGrant().WithDescription("refresh token is bound to another DPoP key")
+	}
+	return nil
+}
+
+type dpopGetter interface {
+	DPoP() bool
+}
+
+func dpopEnabled(v any) bool {
+	getter, ok := v.(dpopGetter)
+	return ok && getter.DPoP()
+}
+
+// issuedTokenType returns the token type of the access tokens issued for the request,
+// DPoP if they are bound to a DPoP key.
+func issuedTokenType(ctx context.Context) string {
+	if DPoPThumbprintFromContext(ctx) != "" {
+		return oidc.DPoPTokenType
+	}
+	return oidc.BearerToken
+}
+
+// dpopHandler verifies the DPoP proof of token requests, if present,
+// and passes the thumbprint of its key to the handler in the context,
+// see [DPoPThumbprintFromContext].
+// Proofs whose jti is already recorded in the replay cache are rejected.
+func dpopHandler(tokenEndpoint *Endpoint, replay cache.Cache, writeError func(http.ResponseWriter, *http.Request, error), handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proofs := r.Header.Values(oidc.DPoPHeader)
+		if len(proofs) == 0 {
+			handler(w, r)
+			return
+		}
+		ctx, span := tracer.Start(r.Context(), "verifyDPoP")
+		thumbprint, err := verifyDPoP(ctx, proofs, r.Method, tokenEndpoint.Absolute(IssuerFromContext(ctx)), replay)
+		span.End()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		handler(w, r.WithContext(contextWithDPoPThumbprint(r.Context(), thumbprint)))
+	}
+}
+
+func verifyDPoP(ctx context.Context, proofs []string, method, uri string, replay cache.Cache) (string, error) {
+	if len(proofs) != 1 {
+		return "", oidc.ErrInvalidDPoPProof().WithDescription("exactly one DPoP proof required")
+	}
+	proof, err := oidc.VerifyDPoPProof(proofs[0], method, uri, "")
+	if err != nil {
+		return "", oidc.ErrInvalidDPoPProof().WithDescription("invalid DPoP proof").WithParent(err)
+	}
+	if replay != nil {
+		// proofs are accepted within DPoPProofMaxAge before and after their iat
+		err = cache.CheckReplay(ctx, replay, "dpop:"+proof.Thumbprint+":"+proof.Claims.JWTID, 2*oidc.DPoPProofMaxAge)
+		if errors.Is(err, cache.ErrReplay) {
+			return "", oidc.ErrInvalidDPoPProof().WithDescription("DPoP proof replayed")
+		}
+		if err != nil {
+			return "", oidc.ErrServerError().WithParent(err)
+		}
+	}
+	return proof.Thumbprint, nil
+}
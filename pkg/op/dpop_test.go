@@ -0,0 +1,119 @@
+package op
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/client"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func Test_dpopHandler(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	proofer, err := client.NewDPoPProofer(key, jose.ES256)
+	require.NoError(t, err)
+	proof := func(method, uri string) string {
+		p, err := proofer(method, uri, "", "")
+		require.NoError(t, err)
+		return p
+	}
+	replayed := proof(http.MethodPost, "https://op.example.com/oauth/token")
+
+	handler := dpopHandler(NewEndpoint("/oauth/token"), cache.NewMemory(),
+		func(w http.ResponseWriter, r *http.Request, err error) { RequestError(w, r, err, slog.Default()) },
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(issuedTokenType(r.Context()) + " " + DPoPThumbprintFromContext(r.Context())))
+		},
+	)
+	tests := []struct {
+		name       string
+		proofs     []string
+		wantStatus int
+		wantType   string
+	}{
+		{"no proof", nil, http.StatusOK, oidc.BearerToken},
+		{"valid", []string{proof(http.MethodPost, "https://op.example.com/oauth/token")}, http.StatusOK, oidc.DPoPTokenType},
+		{"first use", []string{replayed}, http.StatusOK, oidc.DPoPTokenType},
+		{"replayed", []string{replayed}, http.StatusBadRequest, ""},
+		{"other uri", []string{proof(http.MethodPost, "https://op.example.com/userinfo")}, http.StatusBadRequest, ""},
+		{"other method", []string{proof(http.MethodGet, "https://op.example.com/oauth/token")}, http.StatusBadRequest, ""},
+		{"multiple", []string{proof(http.MethodPost, "https://op.example.com/oauth/token"), proof(http.MethodPost, "https://op.example.com/oauth/token")}, http.StatusBadRequest, ""},
+		{"invalid", []string{"foo"}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/oauth/token", nil)
+			r = r.WithContext(ContextWithIssuer(r.Context(), "https://op.example.com"))
+			for _, p := range tt.proofs {
+				r.Header.Add(oidc.DPoPHeader, p)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				var resp oidc.Error
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, oidc.InvalidDPoPProof, resp.ErrorType)
+				return
+			}
+			tokenType, thumbprint, _ := strings.Cut(w.Body.String(), " ")
+			assert.Equal(t, tt.wantType, tokenType)
+			assert.Equal(t, tt.wantType == oidc.DPoPTokenType, thumbprint != "")
+		})
+	}
+}
+
+type dpopRefreshTokenRequest struct {
+	RefreshTokenRequest
+	thumbprint string
+}
+
+func (r dpopRefreshTokenRequest) GetDPoPThumbprint() string {
+	return r.thumbprint
+}
+
+func Test_validateRefreshTokenDPoP(t *testing.T) {
+	public := &tlsClient{authMethod: oidc.AuthMethodNone}
+	bound := dpopRefreshTokenRequest{thumbprint: "jkt1"}
+	tests := []struct {
+		name       string
+		client     Client
+		request    RefreshTokenRequest
+		thumbprint string
+		wantErr    func() *oidc.Error
+	}{
+		{name: "same key", client: public, request: bound, thumbprint: "jkt1"},
+		{name: "other key", client: public, request: bound, thumbprint: "jkt2", wantErr: oidc.ErrInvalidGrant},
+		{name: "missing proof", client: public, request: bound, wantErr: oidc.ErrInvalidDPoPProof},
+		{name: "unbound", client: public, request: dpopRefreshTokenRequest{}, thumbprint: "jkt2"},
+		{name: "confidential client", client: &tlsClient{authMethod: oidc.AuthMethodBasic}, request: bound, thumbprint: "jkt2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.thumbprint != "" {
+				ctx = contextWithDPoPThumbprint(ctx, tt.thumbprint)
+			}
+			err := validateRefreshTokenDPoP(ctx, tt.client, tt.request)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
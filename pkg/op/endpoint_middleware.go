@@ -0,0 +1,95 @@
+package op
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// ErrUnknownEndpoint is returned by [WithEndpointMiddleware] for names
+// which are not one of the EndpointName constants.
+var ErrUnknownEndpoint = errors.New("unknown endpoint name")
+
+var endpointNames = []string{
+	EndpointNameDiscovery,
+	EndpointNameAuthorization,
+	EndpointNameAuthorizationCallback,
+	EndpointNameToken,
+	EndpointNameIntrospection,
+	EndpointNameUserinfo,
+	EndpointNameRevocation,
+	EndpointNameEndSession,
+	EndpointNameKeys,
+	EndpointNameDeviceAuthorization,
+	EndpointNamePushedAuthorization,
+	EndpointNameBackchannelAuthentication,
+	EndpointNameRegistration,
+	EndpointNameSessionTransfer,
+	EndpointNameConsentReceipts,
+}
+
+// EndpointMiddleware are the middleware chains of the endpoints,
+// by the EndpointName constants, like [EndpointNameToken].
+// The middleware of an endpoint run in order, after the interceptors
+// of the Provider and within the [Instrumentation] of the endpoint,
+// so requests rejected by a middleware, e.g. by a rate limit, are recorded.
+type EndpointMiddleware map[string][]HttpInterceptor
+
+// handler wraps the handler of the endpoint with its middleware.
+func (m EndpointMiddleware) handler(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	chain := m[endpoint]
+	if len(chain) == 0 {
+		return handler
+	}
+	var h http.Handler = handler
+	for _, middleware := range slices.Backward(chain) {
+		h = middleware(h)
+	}
+	return h.ServeHTTP
+}
+
+type endpointMiddlewareGetter interface {
+	EndpointMiddleware() EndpointMiddleware
+}
+
+func endpointMiddleware(v any) EndpointMiddleware {
+	if g, ok := v.(endpointMiddlewareGetter); ok {
+		return g.EndpointMiddleware()
+	}
+	return nil
+}
+
+// endpointHandler wraps the handler of the endpoint with its middleware and the instrumentation.
+func endpointHandler(inst *Instrumentation, middleware EndpointMiddleware, endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return inst.Handler(endpoint, middleware.handler(endpoint, handler))
+}
+
+// WithEndpointMiddleware adds the middleware to the endpoint of the name,
+// one of the EndpointName constants, like [EndpointNameToken].
+// It allows rate limits, request logging or tenant extraction per endpoint,
+// without wrapping the Provider and matching the paths of the endpoints.
+// See [EndpointMiddleware] for the order of execution.
+func WithEndpointMiddleware(endpoint string, middleware ...HttpInterceptor) Option {
+	return func(o *Provider) error {
+		if !slices.Contains(endpointNames, endpoint) {
+			return fmt.Errorf("%w: %q", ErrUnknownEndpoint, endpoint)
+		}
+		if o.endpointMiddleware == nil {
+			o.endpointMiddleware = make(EndpointMiddleware)
+		}
+		o.endpointMiddleware[endpoint] = append(o.endpointMiddleware[endpoint], middleware...)
+		return nil
+	}
+}
+
+// WithServerEndpointMiddleware adds the middleware to the endpoint of the name
+// of a Server, see [WithEndpointMiddleware]. Unknown names are ignored.
+func WithServerEndpointMiddleware(endpoint string, middleware ...HttpInterceptor) ServerOption {
+	return func(s *webServer) {
+		if s.endpointMiddleware == nil {
+			s.endpointMiddleware = make(EndpointMiddleware)
+		}
+		s.endpointMiddleware[endpoint] = append(s.endpointMiddleware[endpoint], middleware...)
+	}
+}
@@ -0,0 +1,78 @@
+package op_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// tagMiddleware appends the tag to the X-Middleware header of the response.
+func tagMiddleware(tag string) op.HttpInterceptor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", tag)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rejectMiddleware(http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	})
+}
+
+func TestWithEndpointMiddleware(t *testing.T) {
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig,
+		storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(),
+		op.WithEndpointMiddleware(op.EndpointNameKeys, tagMiddleware("first"), tagMiddleware("second")),
+		op.WithEndpointMiddleware(op.EndpointNameKeys, tagMiddleware("third")),
+		op.WithEndpointMiddleware(op.EndpointNameToken, rejectMiddleware),
+	)
+	require.NoError(t, err)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	w := serve(http.MethodGet, "/keys")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"first", "second", "third"}, w.Header().Values("X-Middleware"))
+
+	w = serve(http.MethodPost, "/oauth/token")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = serve(http.MethodGet, "/.well-known/openid-configuration")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Values("X-Middleware"))
+}
+
+func TestWithEndpointMiddleware_unknown(t *testing.T) {
+	_, err := op.NewOpenIDProvider(testIssuer, testConfig,
+		storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithEndpointMiddleware("tokens", rejectMiddleware),
+	)
+	assert.ErrorIs(t, err, op.ErrUnknownEndpoint)
+}
+
+func TestWithServerEndpointMiddleware(t *testing.T) {
+	handler := op.RegisterServer(op.UnimplementedServer{}, *op.DefaultEndpoints,
+		op.WithServerEndpointMiddleware(op.EndpointNameToken, rejectMiddleware),
+		op.WithServerEndpointMiddleware(op.EndpointNameDiscovery, tagMiddleware("discovery")),
+	)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/oauth/token", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	assert.Equal(t, []string{"discovery"}, w.Header().Values("X-Middleware"))
+}
@@ -27,12 +27,13 @@ type LogAuthRequest interface {
 }
 
 func AuthRequestError(w http.ResponseWriter, r *http.Request, authReq ErrAuthRequest, err error, authorizer Authorizer) {
-	e := oidc.DefaultToServerError(err, err.Error())
+	e := correlateError(r.Context(), oidc.DefaultToServerError(err, err.Error()))
 	logger := authorizer.Logger().With("oidc_error", e)
+	recordRequestError(r.Context(), e)
 
 	if authReq == nil {
 		logger.Log(r.Context(), e.LogLevel(), "auth request")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		renderAuthError(w, r, authorizer, e, err.Error())
 		return
 	}
 
@@ -40,9 +41,9 @@ func AuthRequestError(w http.ResponseWriter, r *http.Request, authReq ErrAuthReq
 		logger = logger.With("auth_request", logAuthReq)
 	}
 
-	if authReq.GetRedirectURI() == "" || e.IsRedirectDisabled() {
+	if authErrorAction(r.Context(), authorizer, authReq, e) == AuthErrorRender {
 		logger.Log(r.Context(), e.LogLevel(), "auth request: not redirecting")
-		http.Error(w, e.Description, http.StatusBadRequest)
+		renderAuthError(w, r, authorizer, e, e.Description)
 		return
 	}
 	e.State = authReq.GetState()
@@ -56,10 +57,16 @@ func AuthRequestError(w http.ResponseWriter, r *http.Request, authReq ErrAuthReq
 	if rm, ok := authReq.(interface{ GetResponseMode() oidc.ResponseMode }); ok {
 		responseMode = rm.GetResponseMode()
 	}
-	url, err := AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), responseMode, e, authorizer.Encoder())
+	response, responseMode, err := jwtAuthErrorResponse(r.Context(), authorizer, authReq, responseMode, e)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "auth response JWT", "error", err)
+		WriteText(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	url, err := AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), responseMode, response, authorizer.Encoder())
 	if err != nil {
 		logger.ErrorContext(r.Context(), "auth response URL", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		WriteText(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	logger.Log(r.Context(), e.LogLevel(), "auth request")
@@ -67,21 +74,28 @@ func AuthRequestError(w http.ResponseWriter, r *http.Request, authReq ErrAuthReq
 }
 
 func RequestError(w http.ResponseWriter, r *http.Request, err error, logger *slog.Logger) {
-	e := oidc.DefaultToServerError(err, err.Error())
+	e := correlateError(r.Context(), oidc.DefaultToServerError(err, err.Error()))
+	recordRequestError(r.Context(), e)
 	status := http.StatusBadRequest
 	if e.ErrorType == oidc.InvalidClient {
 		status = http.StatusUnauthorized
 	}
 	logger.Log(r.Context(), e.LogLevel(), "request error", "oidc_error", e)
-	httphelper.MarshalJSONWithStatus(w, e, status)
+	writeNegotiatedError(w, r, e, status)
 }
 
 // TryErrorRedirect tries to handle an error by redirecting a client.
 // If this attempt fails, an error is returned that must be returned
 // to the client instead.
 func TryErrorRedirect(ctx context.Context, authReq ErrAuthRequest, parent error, encoder httphelper.Encoder, logger *slog.Logger) (*Redirect, error) {
-	e := oidc.DefaultToServerError(parent, parent.Error())
+	return tryErrorRedirect(ctx, nil, authReq, parent, encoder, logger)
+}
+
+// tryErrorRedirect is [TryErrorRedirect] with the [AuthErrorPolicy] of the provider.
+func tryErrorRedirect(ctx context.Context, provider any, authReq ErrAuthRequest, parent error, encoder httphelper.Encoder, logger *slog.Logger) (*Redirect, error) {
+	e := correlateError(ctx, oidc.DefaultToServerError(parent, parent.Error()))
 	logger = logger.With("oidc_error", e)
+	recordRequestError(ctx, e)
 
 	if authReq == nil {
 		logger.Log(ctx, e.LogLevel(), "auth request")
@@ -92,7 +106,7 @@ func TryErrorRedirect(ctx context.Context, authReq ErrAuthRequest, parent error,
 		logger = logger.With("auth_request", logAuthReq)
 	}
 
-	if authReq.GetRedirectURI() == "" || e.IsRedirectDisabled() {
+	if authErrorAction(ctx, provider, authReq, e) == AuthErrorRender {
 		logger.Log(ctx, e.LogLevel(), "auth request: not redirecting")
 		return nil, AsStatusError(e, http.StatusBadRequest)
 	}
@@ -108,7 +122,12 @@ func TryErrorRedirect(ctx context.Context, authReq ErrAuthRequest, parent error,
 	if rm, ok := authReq.(interface{ GetResponseMode() oidc.ResponseMode }); ok {
 		responseMode = rm.GetResponseMode()
 	}
-	url, err := AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), responseMode, e, encoder)
+	response, responseMode, err := jwtAuthErrorResponse(ctx, provider, authReq, responseMode, e)
+	if err != nil {
+		logger.ErrorContext(ctx, "auth response JWT", "error", err)
+		return nil, AsStatusError(err, http.StatusBadRequest)
+	}
+	url, err := AuthResponseURL(authReq.GetRedirectURI(), authReq.GetResponseType(), responseMode, response, encoder)
 	if err != nil {
 		logger.ErrorContext(ctx, "auth response URL", "error", err)
 		return nil, AsStatusError(err, http.StatusBadRequest)
@@ -192,6 +211,8 @@ func WriteError(w http.ResponseWriter, r *http.Request, err error, logger *slog.
 }
 
 func writeError(w http.ResponseWriter, r *http.Request, err *oidc.Error, statusCode int, logger *slog.Logger) {
+	err = correlateError(r.Context(), err)
+	recordRequestError(r.Context(), err)
 	logger.Log(r.Context(), err.LogLevel(), "request error", "oidc_error", err, "status_code", statusCode)
-	httphelper.MarshalJSONWithStatus(w, err, statusCode)
+	writeNegotiatedError(w, r, err, statusCode)
 }
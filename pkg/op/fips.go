@@ -0,0 +1,111 @@
+package op
+
+import (
+	"fmt"
+	"slices"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type fipsGetter interface {
+	FIPS() *crypto.FIPS
+}
+
+func providerFIPS(v any) *crypto.FIPS {
+	if g, ok := v.(fipsGetter); ok {
+		return g.FIPS()
+	}
+	return nil
+}
+
+// WithFIPS restricts the Provider to FIPS 140-3 approved algorithms and keys,
+// for deployments built with a FIPS validated toolchain, like BoringCrypto.
+// It is enabled by default for builds with the boringcrypto tag and with Go 1.24
+// or later, if the Go Cryptographic Module runs in FIPS 140-3 mode, see [crypto.DefaultFIPS].
+//
+// NewProvider fails if the ClientSigningAlgorithms of the [Config], or the
+// [oidc.DPoPAlgorithms] and [DeviceProofAlgorithms] if enabled, are not approved.
+// ClientSigningAlgorithms set by [Provider.UpdateConfig], which are not approved,
+// are ignored. [Provider.SetSigningKeys] fails and tokens are not signed for keys
+// which are not approved, see [crypto.FIPS.CheckKey].
+// Certificates are identified by their SHA-256 thumbprint only (x5t#S256),
+// the SHA-1 thumbprint (x5t) is omitted.
+func WithFIPS(fips crypto.FIPS) Option {
+	return func(o *Provider) error {
+		o.fips = &fips
+		return nil
+	}
+}
+
+// checkFIPS fails fast for configured algorithms, which are not approved.
+func (o *Provider) checkFIPS(config *Config) error {
+	if o.fips == nil {
+		return nil
+	}
+	algs := make([]jose.SignatureAlgorithm, 0, len(config.ClientSigningAlgorithms))
+	for _, alg := range config.ClientSigningAlgorithms {
+		algs = append(algs, jose.SignatureAlgorithm(alg))
+	}
+	if err := o.fips.CheckAlgorithms(algs...); err != nil {
+		return fmt.Errorf("client signing algorithms: %w", err)
+	}
+	if o.dpop {
+		if err := o.fips.CheckAlgorithms(oidc.DPoPAlgorithms...); err != nil {
+			return fmt.Errorf("DPoP algorithms: %w", err)
+		}
+	}
+	if _, ok := o.storage.(DeviceBindingStorage); ok {
+		if err := o.fips.CheckAlgorithms(DeviceProofAlgorithms...); err != nil {
+			return fmt.Errorf("device proof algorithms: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkFIPSKey returns an error for a signing key, which is not approved.
+func checkFIPSKey(fips *crypto.FIPS, key SigningKey) error {
+	if fips == nil {
+		return nil
+	}
+	if err := fips.CheckAlgorithm(key.SignatureAlgorithm()); err != nil {
+		return err
+	}
+	return fips.CheckKey(key.Key())
+}
+
+// fipsApprovedAlgorithms filters the algorithms, which are not approved,
+// e.g. set by [Provider.UpdateConfig].
+func fipsApprovedAlgorithms(fips *crypto.FIPS, algs []string) []string {
+	if fips == nil {
+		return algs
+	}
+	return slices.DeleteFunc(slices.Clone(algs), func(alg string) bool {
+		return fips.CheckAlgorithm(jose.SignatureAlgorithm(alg)) != nil
+	})
+}
+
+// fipsTokenHeader returns the header without the SHA-1 thumbprint, if the FIPS mode is enabled.
+func fipsTokenHeader(v any, header *TokenHeader) *TokenHeader {
+	if header == nil || !header.X509 || providerFIPS(v) == nil {
+		return header
+	}
+	fipsHeader := *header
+	fipsHeader.omitSHA1 = true
+	return &fipsHeader
+}
+
+// fipsKeySet returns a copy of the set without the SHA-1 thumbprints of the keys,
+// if the FIPS mode is enabled.
+func fipsKeySet(v any, set *jose.JSONWebKeySet) *jose.JSONWebKeySet {
+	if providerFIPS(v) == nil {
+		return set
+	}
+	keys := slices.Clone(set.Keys)
+	for i := range keys {
+		keys[i].CertificateThumbprintSHA1 = nil
+	}
+	return &jose.JSONWebKeySet{Keys: keys}
+}
@@ -0,0 +1,81 @@
+package op_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func newFIPSProvider(t *testing.T, config op.Config, opts ...op.Option) (*op.Provider, error) {
+	t.Helper()
+	opts = append([]op.Option{op.WithAllowInsecure()}, opts...)
+	return op.NewOpenIDProvider(testIssuer, &config, storage.NewStorage(storage.NewUserStore(testIssuer)), opts...)
+}
+
+func TestWithFIPS_algorithms(t *testing.T) {
+	config := *testConfig
+	config.ClientSigningAlgorithms = []string{"RS256", "EdDSA"}
+	_, err := newFIPSProvider(t, config, op.WithFIPS(crypto.FIPS{}))
+	assert.ErrorIs(t, err, crypto.ErrNotFIPSApproved)
+
+	_, err = newFIPSProvider(t, config, op.WithFIPS(crypto.FIPS{AllowEdDSA: true}))
+	assert.NoError(t, err)
+
+	config.ClientSigningAlgorithms = []string{"ES256"}
+	_, err = newFIPSProvider(t, config, op.WithFIPS(crypto.FIPS{}))
+	assert.NoError(t, err)
+
+	// the default DPoP algorithms include EdDSA
+	_, err = newFIPSProvider(t, config, op.WithFIPS(crypto.FIPS{}), op.WithDPoP())
+	assert.ErrorIs(t, err, crypto.ErrNotFIPSApproved)
+
+	provider, err := newFIPSProvider(t, config, op.WithFIPS(crypto.FIPS{}))
+	require.NoError(t, err)
+	provider.UpdateConfig(func(config *op.Config) {
+		config.ClientSigningAlgorithms = []string{"EdDSA", "PS256"}
+	})
+	assert.Equal(t, []string{"PS256"}, provider.RequestObjectSigningAlgorithmsSupported())
+}
+
+func TestWithFIPS_keys(t *testing.T) {
+	provider, err := newFIPSProvider(t, *testConfig, op.WithFIPS(crypto.FIPS{}))
+	require.NoError(t, err)
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	weakKey := certSigningKey{key: weak}
+	err = provider.SetSigningKeys(weakKey, certKey{weakKey})
+	assert.ErrorIs(t, err, crypto.ErrNotFIPSApproved)
+
+	key := newCertSigningKey(t)
+	require.NoError(t, provider.SetSigningKeys(key, certKey{key}))
+
+	w := httptest.NewRecorder()
+	provider.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var keySet jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keySet))
+	require.Len(t, keySet.Keys, 1)
+	assert.NotEmpty(t, keySet.Keys[0].CertificateThumbprintSHA256)
+	assert.Empty(t, keySet.Keys[0].CertificateThumbprintSHA1)
+}
+
+func TestWithFIPS_storageKey(t *testing.T) {
+	provider, err := newFIPSProvider(t, *testConfig, op.WithFIPS(crypto.FIPS{}))
+	require.NoError(t, err)
+	key, err := provider.SigningKey(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, jose.RS256, key.SignatureAlgorithm())
+}
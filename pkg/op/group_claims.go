@@ -0,0 +1,125 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// GroupClaimsOverflow is what happens to a group claim,
+// which exceeds the limits of the [GroupClaimsPolicy].
+type GroupClaimsOverflow int
+
+const (
+	// GroupClaimsOverflowOmit removes the claim from the token
+	// and indicates it with the <claim>_overflow claim set to true,
+	// so the client can obtain the claim from the userinfo endpoint.
+	GroupClaimsOverflowOmit GroupClaimsOverflow = iota
+	// GroupClaimsOverflowDistributed replaces the claim by a distributed claim,
+	// OpenID Connect Core 1.0, section 5.6.2, with the source of the policy.
+	GroupClaimsOverflowDistributed
+)
+
+// DefaultGroupClaims are guarded, if the [GroupClaimsPolicy] names no claims.
+var DefaultGroupClaims = []string{"groups", "roles"}
+
+var ErrGroupClaimsSource = errors.New("group claims policy without source for distributed claims")
+
+// GroupClaimsPolicy guards the size of list claims like groups and roles
+// in the ID and JWT access tokens, so end-users which are members of
+// hundreds of groups don't get tokens of many kilobytes. See [WithGroupClaimsPolicy].
+type GroupClaimsPolicy struct {
+	// Claims are the names of the guarded claims,
+	// [DefaultGroupClaims] if empty.
+	Claims []string
+	// MaxValues is the maximum number of values of a claim.
+	// Zero means no limit.
+	MaxValues int
+	// MaxBytes is the maximum size of the JSON encoded value of a claim.
+	// Zero means no limit.
+	MaxBytes int
+	// Overflow of a claim exceeding a limit.
+	Overflow GroupClaimsOverflow
+	// Source returns the source of an overflowing claim of the subject,
+	// required for [GroupClaimsOverflowDistributed].
+	Source func(ctx context.Context, claim, subject, clientID string) (oidc.ClaimSource, error)
+}
+
+type groupClaimsPolicyGetter interface {
+	GroupClaimsPolicy() *GroupClaimsPolicy
+}
+
+func groupClaimsPolicy(v any) *GroupClaimsPolicy {
+	if p, ok := v.(groupClaimsPolicyGetter); ok {
+		return p.GroupClaimsPolicy()
+	}
+	return nil
+}
+
+// apply returns the claims with the overflowing group claims
+// omitted or distributed. A nil policy returns the claims unmodified.
+func (p *GroupClaimsPolicy) apply(ctx context.Context, claims map[string]any, subject, clientID string) (map[string]any, error) {
+	if p == nil || len(claims) == 0 {
+		return claims, nil
+	}
+	names := p.Claims
+	if len(names) == 0 {
+		names = DefaultGroupClaims
+	}
+	for _, name := range names {
+		value, ok := claims[name]
+		if !ok || !p.overflows(value) {
+			continue
+		}
+		delete(claims, name)
+		switch p.Overflow {
+		case GroupClaimsOverflowDistributed:
+			if p.Source == nil {
+				return nil, ErrGroupClaimsSource
+			}
+			source, err := p.Source(ctx, name, subject, clientID)
+			if err != nil {
+				return nil, err
+			}
+			setDistributedClaim(claims, name, source)
+		default:
+			claims[name+"_overflow"] = true
+		}
+	}
+	return claims, nil
+}
+
+func (p *GroupClaimsPolicy) overflows(value any) bool {
+	if p.MaxValues > 0 {
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Slice && v.Len() > p.MaxValues {
+			return true
+		}
+	}
+	if p.MaxBytes > 0 {
+		data, err := json.Marshal(value)
+		if err != nil || len(data) > p.MaxBytes {
+			return true
+		}
+	}
+	return false
+}
+
+// setDistributedClaim references the claim to the source of the same name
+// in the _claim_names and _claim_sources members of the claims.
+func setDistributedClaim(claims map[string]any, name string, source oidc.ClaimSource) {
+	claimNames, _ := claims[oidc.ClaimNames].(map[string]string)
+	if claimNames == nil {
+		claimNames = make(map[string]string)
+	}
+	claimSources, _ := claims[oidc.ClaimSources].(map[string]oidc.ClaimSource)
+	if claimSources == nil {
+		claimSources = make(map[string]oidc.ClaimSource)
+	}
+	claimNames[name] = name
+	claimSources[name] = source
+	claims[oidc.ClaimNames] = claimNames
+	claims[oidc.ClaimSources] = claimSources
+}
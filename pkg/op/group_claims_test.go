@@ -0,0 +1,74 @@
+package op
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestGroupClaimsPolicy_apply(t *testing.T) {
+	source := func(_ context.Context, claim, subject, clientID string) (oidc.ClaimSource, error) {
+		return oidc.ClaimSource{Endpoint: "https://groups.example.com/" + subject + "/" + claim}, nil
+	}
+	tests := []struct {
+		name    string
+		policy  *GroupClaimsPolicy
+		claims  map[string]any
+		want    map[string]any
+		wantErr error
+	}{
+		{
+			name:   "no policy",
+			claims: map[string]any{"groups": []string{"a", "b", "c"}},
+			want:   map[string]any{"groups": []string{"a", "b", "c"}},
+		},
+		{
+			name:   "within limits",
+			policy: &GroupClaimsPolicy{MaxValues: 3, MaxBytes: 100},
+			claims: map[string]any{"groups": []string{"a", "b", "c"}},
+			want:   map[string]any{"groups": []string{"a", "b", "c"}},
+		},
+		{
+			name:   "omit values",
+			policy: &GroupClaimsPolicy{MaxValues: 2},
+			claims: map[string]any{"groups": []string{"a", "b", "c"}, "roles": []any{"admin"}},
+			want:   map[string]any{"groups_overflow": true, "roles": []any{"admin"}},
+		},
+		{
+			name:   "omit bytes",
+			policy: &GroupClaimsPolicy{MaxBytes: 8, Claims: []string{"teams"}},
+			claims: map[string]any{"teams": []string{"engineering"}, "groups": []string{"engineering"}},
+			want:   map[string]any{"teams_overflow": true, "groups": []string{"engineering"}},
+		},
+		{
+			name:   "distributed",
+			policy: &GroupClaimsPolicy{MaxValues: 1, Overflow: GroupClaimsOverflowDistributed, Source: source},
+			claims: map[string]any{"groups": []string{"a", "b"}},
+			want: map[string]any{
+				oidc.ClaimNames:   map[string]string{"groups": "groups"},
+				oidc.ClaimSources: map[string]oidc.ClaimSource{"groups": {Endpoint: "https://groups.example.com/sub1/groups"}},
+			},
+		},
+		{
+			name:    "distributed without source",
+			policy:  &GroupClaimsPolicy{MaxValues: 1, Overflow: GroupClaimsOverflowDistributed},
+			claims:  map[string]any{"groups": []string{"a", "b"}},
+			wantErr: ErrGroupClaimsSource,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.apply(context.Background(), tt.claims, "sub1", "client1")
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
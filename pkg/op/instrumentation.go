@@ -0,0 +1,173 @@
+package op
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+const instrumentationName = "github.com/lmindwarel/oidc/pkg/op"
+
+// Names of the endpoints, recorded by the [Instrumentation]
+// as the oidc.endpoint attribute.
+const (
+	EndpointNameDiscovery                 = "discovery"
+	EndpointNameAuthorization             = "authorize"
+	EndpointNameAuthorizationCallback     = "authorize_callback"
+	EndpointNameToken                     = "token"
+	EndpointNameIntrospection             = "introspection"
+	EndpointNameUserinfo                  = "userinfo"
+	EndpointNameRevocation                = "revocation"
+	EndpointNameEndSession                = "end_session"
+	EndpointNameKeys                      = "keys"
+	EndpointNameDeviceAuthorization       = "device_authorization"
+	EndpointNamePushedAuthorization       = "par"
+	EndpointNameBackchannelAuthentication = "backchannel_authentication"
+	EndpointNameRegistration              = "registration"
+	EndpointNameSessionTransfer           = "session_transfer"
+	EndpointNameConsentReceipts           = "consent_receipts"
+)
+
+// Instrumentation records a span and the request count and duration metrics
+// of the endpoint handlers, by endpoint, grant type (of the token endpoint),
+// status code and the OAuth error type of failed requests.
+// It must be created by [NewInstrumentation], see [WithTracerProvider],
+// [WithMeterProvider] and [WithServerInstrumentation].
+type Instrumentation struct {
+	tracer   trace.Tracer
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// NewInstrumentation returns an Instrumentation. Nil providers default
+// to the global providers of OpenTelemetry.
+func NewInstrumentation(tp trace.TracerProvider, mp metric.MeterProvider) (*Instrumentation, error) {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	requests, err := meter.Int64Counter("oidc.server.requests",
+		metric.WithDescription("Number of requests to the endpoints of the provider."),
+	)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("oidc.server.request.duration",
+		metric.WithDescription("Duration of requests to the endpoints of the provider."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Instrumentation{
+		tracer:   tp.Tracer(instrumentationName),
+		requests: requests,
+		duration: duration,
+	}, nil
+}
+
+// Handler records the requests of the endpoint handler.
+// A nil Instrumentation returns the handler unchanged.
+func (i *Instrumentation) Handler(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	if i == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		attrs := []attribute.KeyValue{attribute.String("oidc.endpoint", endpoint)}
+		if endpoint == EndpointNameToken {
+			if grantType := formGrantType(r); grantType != "" {
+				attrs = append(attrs, attribute.String("oidc.grant_type", grantType))
+			}
+		}
+		ctx, span := i.tracer.Start(r.Context(), "oidc.server."+endpoint,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		record := new(requestRecord)
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		handler(rw, r.WithContext(context.WithValue(ctx, requestRecordKey{}, record)))
+
+		attrs = append(attrs, attribute.Int("http.response.status_code", rw.status))
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.status))
+		if record.errorType != "" {
+			attrs = append(attrs, attribute.String("error.type", record.errorType))
+			span.SetAttributes(attribute.String("error.type", record.errorType))
+		} else if rw.status >= http.StatusBadRequest {
+			attrs = append(attrs, attribute.String("error.type", strconv.Itoa(rw.status)))
+		}
+		if rw.status >= http.StatusInternalServerError || record.errorType == string(oidc.ServerError) {
+			span.SetStatus(codes.Error, record.errorType)
+		}
+		set := metric.WithAttributes(attrs...)
+		i.requests.Add(ctx, 1, set)
+		i.duration.Record(ctx, time.Since(start).Seconds(), set)
+	}
+}
+
+// formGrantType returns the grant_type of form encoded requests,
+// other requests are left to the handler.
+func formGrantType(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
+		return ""
+	}
+	return r.PostFormValue(oidc.ParamGrantType)
+}
+
+type requestRecordKey struct{}
+
+// requestRecord collects the outcome of an instrumented request.
+type requestRecord struct {
+	errorType string
+}
+
+// recordRequestError records the error type of a request, if instrumented.
+func recordRequestError(ctx context.Context, err *oidc.Error) {
+	if record, ok := ctx.Value(requestRecordKey{}).(*requestRecord); ok {
+		record.errorType = string(err.ErrorType)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type instrumentationGetter interface {
+	Instrumentation() *Instrumentation
+}
+
+func providerInstrumentation(v any) *Instrumentation {
+	if getter, ok := v.(instrumentationGetter); ok {
+		return getter.Instrumentation()
+	}
+	return nil
+}
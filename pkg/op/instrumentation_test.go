@@ -0,0 +1,83 @@
+package op
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type recordingMeterProvider struct {
+	noop.MeterProvider
+	adds []attribute.Set
+}
+
+func (p *recordingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return recordingMeter{provider: p}
+}
+
+type recordingMeter struct {
+	noop.Meter
+	provider *recordingMeterProvider
+}
+
+func (m recordingMeter) Int64Counter(string, ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return recordingCounter{provider: m.provider}, nil
+}
+
+type recordingCounter struct {
+	noop.Int64Counter
+	provider *recordingMeterProvider
+}
+
+func (c recordingCounter) Add(_ context.Context, _ int64, opts ...metric.AddOption) {
+	c.provider.adds = append(c.provider.adds, metric.NewAddConfig(opts).Attributes())
+}
+
+func TestInstrumentation_Handler(t *testing.T) {
+	mp := new(recordingMeterProvider)
+	inst, err := NewInstrumentation(nil, mp)
+	require.NoError(t, err)
+
+	handler := inst.Handler(EndpointNameToken, func(w http.ResponseWriter, r *http.Request) {
+		RequestError(w, r, oidc.ErrInvalidGrant(), slog.Default())
+	})
+	form := url.Values{"grant_type": {string(oidc.GrantTypeRefreshToken)}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.Len(t, mp.adds, 1)
+	attrs := mp.adds[0]
+	for key, want := range map[attribute.Key]attribute.Value{
+		"oidc.endpoint":             attribute.StringValue(EndpointNameToken),
+		"oidc.grant_type":           attribute.StringValue(string(oidc.GrantTypeRefreshToken)),
+		"error.type":                attribute.StringValue(string(oidc.InvalidGrant)),
+		"http.response.status_code": attribute.IntValue(http.StatusBadRequest),
+	} {
+		got, ok := attrs.Value(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, got, key)
+	}
+}
+
+func TestInstrumentation_Handler_nil(t *testing.T) {
+	var inst *Instrumentation
+	called := false
+	handler := inst.Handler(EndpointNameKeys, func(http.ResponseWriter, *http.Request) { called = true })
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/keys", nil))
+	assert.True(t, called)
+}
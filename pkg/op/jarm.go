@@ -0,0 +1,183 @@
+package op
+
+import (
+	"context"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// HasJWTAuthorizationResponse is an optional interface that can be implemented by implementors
+// of Client, which registered the JWT Secured Authorization Response Mode (JARM).
+// Only these clients may request the JWT response modes, if they are supported
+// by the provider, see [Config.JWTAuthorizationResponseSupported].
+type HasJWTAuthorizationResponse interface {
+	Client
+	// AuthorizationResponseEncryption returns the registered encryption
+	// of the authorization responses, nil if they are only signed.
+	AuthorizationResponseEncryption() *JWTResponseEncryption
+}
+
+// JWTResponseEncryption is the encryption of the authorization responses
+// registered by a client (authorization_encrypted_response_alg and _enc).
+type JWTResponseEncryption struct {
+	// Key is the public key of the client.
+	Key        jose.JSONWebKey
+	Algorithm  jose.KeyAlgorithm
+	Encryption jose.ContentEncryption
+}
+
+// DefaultJWTResponseLifetime is the lifetime of the response JWTs,
+// which are meant to be used immediately.
+const DefaultJWTResponseLifetime = 10 * time.Minute
+
+var JWTResponseModes = []oidc.ResponseMode{
+	oidc.ResponseModeQueryJWT,
+	oidc.ResponseModeFragmentJWT,
+	oidc.ResponseModeFormPostJWT,
+	oidc.ResponseModeJWT,
+}
+
+type jwtAuthorizationResponseGetter interface {
+	JWTAuthorizationResponseSupported() bool
+}
+
+func jwtAuthorizationResponseSupported(v any) bool {
+	getter, ok := v.(jwtAuthorizationResponseGetter)
+	return ok && getter.JWTAuthorizationResponseSupported()
+}
+
+// ValidateAuthReqResponseMode rejects the JWT response modes, if not supported by the provider
+// or not registered by the client.
+func ValidateAuthReqResponseMode(provider any, client Client, authReq *oidc.AuthRequest) error {
+	if !authReq.ResponseMode.IsJWT() {
+		return nil
+	}
+	if !jwtAuthorizationResponseSupported(provider) {
+		return oidc.ErrInvalidRequest().WithDescription("response_mode %s is not supported", authReq.ResponseMode)
+	}
+	if _, ok := client.(HasJWTAuthorizationResponse); !ok {
+		return oidc.ErrUnauthorizedClient().WithDescription("response_mode %s is not registered for the client", authReq.ResponseMode)
+	}
+	return nil
+}
+
+// ResponseModes returns the response modes of the discovery,
+// only set if the JWT response modes are supported.
+func ResponseModes(c Configuration) []string {
+	if !jwtAuthorizationResponseSupported(c) {
+		return nil
+	}
+	modes := []string{
+		string(oidc.ResponseModeQuery),
+		string(oidc.ResponseModeFragment),
+		string(oidc.ResponseModeFormPost),
+	}
+	for _, mode := range JWTResponseModes {
+		modes = append(modes, string(mode))
+	}
+	return modes
+}
+
+// AuthorizationSigAlgorithms returns the algorithms of the response JWTs
+// of the discovery, only set if the JWT response modes are supported.
+func AuthorizationSigAlgorithms(ctx context.Context, c Configuration, storage DiscoverStorage) []string {
+	if !jwtAuthorizationResponseSupported(c) {
+		return nil
+	}
+	return SigAlgorithms(ctx, storage)
+}
+
+// CreateJWTAuthorizationResponse signs the parameters of the authorization response
+// for the client as response JWT, encrypted if registered by the client.
+func CreateJWTAuthorizationResponse(ctx context.Context, issuer string, client Client, response any, encoder httphelper.Encoder, storage Storage) (string, error) {
+	return createJWTAuthorizationResponse(ctx, issuer, client, response, encoder, storage)
+}
+
+func createJWTAuthorizationResponse(ctx context.Context, issuer string, client Client, response any, encoder httphelper.Encoder, keys signingKeyGetter) (string, error) {
+	ctx, span := tracer.Start(ctx, "CreateJWTAuthorizationResponse")
+	defer span.End()
+
+	params, err := httphelper.URLEncodeParams(response, encoder)
+	if err != nil {
+		return "", err
+	}
+	exp := time.Now().UTC().Add(client.ClockSkew()).Add(DefaultJWTResponseLifetime)
+	claims := oidc.NewJWTAuthorizationResponseClaims(issuer, client.GetID(), exp, params)
+	signingKey, err := keys.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	signer, err := SignerFromKey(signingKey)
+	if err != nil {
+		return "", err
+	}
+	token, err := crypto.SignCanonical(claims, signer)
+	if err != nil {
+		return "", err
+	}
+	jarmClient, ok := client.(HasJWTAuthorizationResponse)
+	if !ok || jarmClient.AuthorizationResponseEncryption() == nil {
+		return token, nil
+	}
+	encryption := jarmClient.AuthorizationResponseEncryption()
+	encrypter, err := jose.NewEncrypter(encryption.Encryption,
+		jose.Recipient{Algorithm: encryption.Algorithm, Key: encryption.Key.Key, KeyID: encryption.Key.KeyID},
+		(&jose.EncrypterOptions{}).WithContentType("JWT"),
+	)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := encrypter.Encrypt([]byte(token))
+	if err != nil {
+		return "", err
+	}
+	return encrypted.CompactSerialize()
+}
+
+// jwtAuthResponse wraps the response in a response JWT for the JWT response modes
+// and returns the response mode it is sent with.
+// Other response modes are returned unchanged with the response.
+func jwtAuthResponse(ctx context.Context, provider jwtResponseProvider, clientID string, responseMode oidc.ResponseMode, response any) (any, oidc.ResponseMode, error) {
+	if !responseMode.IsJWT() {
+		return response, responseMode, nil
+	}
+	storage := provider.Storage()
+	client, err := storage.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		return nil, "", oidc.ErrServerError().WithParent(err)
+	}
+	// errors of rejected JWT response modes are sent unsigned
+	if _, ok := client.(HasJWTAuthorizationResponse); !ok || !jwtAuthorizationResponseSupported(provider) {
+		return response, responseMode.Base(), nil
+	}
+	token, err := createJWTAuthorizationResponse(ctx, IssuerFromContext(ctx), client, response, provider.Encoder(), signingKeys(provider, storage))
+	if err != nil {
+		return nil, "", oidc.ErrServerError().WithParent(err)
+	}
+	return &oidc.JWTAuthorizationResponse{Response: token}, responseMode.Base(), nil
+}
+
+type clientIDGetter interface {
+	GetClientID() string
+}
+
+type jwtResponseProvider interface {
+	Storage() Storage
+	Encoder() httphelper.Encoder
+}
+
+// jwtAuthErrorResponse is [jwtAuthResponse] for the errors of auth requests,
+// which are sent unsigned, if the client or provider are unknown.
+func jwtAuthErrorResponse(ctx context.Context, provider any, authReq ErrAuthRequest, responseMode oidc.ResponseMode, e *oidc.Error) (any, oidc.ResponseMode, error) {
+	clientID, ok := authReq.(clientIDGetter)
+	responder, hasProvider := provider.(jwtResponseProvider)
+	if !ok || !hasProvider {
+		return e, responseMode.Base(), nil
+	}
+	return jwtAuthResponse(ctx, responder, clientID.GetClientID(), responseMode, e)
+}
@@ -0,0 +1,124 @@
+package op
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zitadel/schema"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type jarmClient struct {
+	logoutClient
+	encryption *JWTResponseEncryption
+}
+
+func (c *jarmClient) AuthorizationResponseEncryption() *JWTResponseEncryption {
+	return c.encryption
+}
+
+type jarmProvider struct {
+	supported bool
+	storage   Storage
+}
+
+func (p jarmProvider) JWTAuthorizationResponseSupported() bool { return p.supported }
+func (p jarmProvider) Storage() Storage                        { return p.storage }
+func (p jarmProvider) Encoder() httphelper.Encoder             { return schema.NewEncoder() }
+
+func TestValidateAuthReqResponseMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		supported bool
+		client    Client
+		mode      oidc.ResponseMode
+		wantErr   bool
+	}{
+		{"plain", false, &logoutClient{}, oidc.ResponseModeQuery, false},
+		{"not supported", false, &jarmClient{}, oidc.ResponseModeQueryJWT, true},
+		{"not registered", true, &logoutClient{}, oidc.ResponseModeJWT, true},
+		{"registered", true, &jarmClient{}, oidc.ResponseModeFormPostJWT, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAuthReqResponseMode(jarmProvider{supported: tt.supported}, tt.client, &oidc.AuthRequest{ResponseMode: tt.mode})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_jwtAuthResponse(t *testing.T) {
+	encryptionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	storage := &logoutStorage{clients: map[string]Client{
+		"plain":  &logoutClient{id: "plain"},
+		"signed": &jarmClient{logoutClient: logoutClient{id: "signed"}},
+		"encrypted": &jarmClient{logoutClient: logoutClient{id: "encrypted"}, encryption: &JWTResponseEncryption{
+			Key:        jose.JSONWebKey{Key: &encryptionKey.PublicKey, KeyID: "enc1"},
+			Algorithm:  jose.RSA_OAEP_256,
+			Encryption: jose.A128GCM,
+		}},
+	}}
+	provider := jarmProvider{supported: true, storage: storage}
+	ctx := ContextWithIssuer(context.Background(), "https://issuer.com")
+	code := &CodeResponseType{Code: "code1", State: "state1"}
+
+	t.Run("plain response mode", func(t *testing.T) {
+		response, mode, err := jwtAuthResponse(ctx, provider, "signed", oidc.ResponseModeQuery, code)
+		require.NoError(t, err)
+		assert.Equal(t, code, response)
+		assert.Equal(t, oidc.ResponseModeQuery, mode)
+	})
+	t.Run("not registered", func(t *testing.T) {
+		response, mode, err := jwtAuthResponse(ctx, provider, "plain", oidc.ResponseModeQueryJWT, code)
+		require.NoError(t, err)
+		assert.Equal(t, code, response)
+		assert.Equal(t, oidc.ResponseModeQuery, mode)
+	})
+	t.Run("signed", func(t *testing.T) {
+		response, mode, err := jwtAuthResponse(ctx, provider, "signed", oidc.ResponseModeFormPostJWT, code)
+		require.NoError(t, err)
+		assert.Equal(t, oidc.ResponseModeFormPost, mode)
+		claims := verifyJWTResponse(t, response.(*oidc.JWTAuthorizationResponse).Response)
+		assert.Equal(t, "https://issuer.com", claims.Issuer)
+		assert.Equal(t, oidc.Audience{"signed"}, claims.Audience)
+		assert.Equal(t, url.Values{"code": {"code1"}, "state": {"state1"}, "iss": {"https://issuer.com"}}, claims.Values())
+	})
+	t.Run("encrypted", func(t *testing.T) {
+		response, mode, err := jwtAuthResponse(ctx, provider, "encrypted", oidc.ResponseModeJWT, code)
+		require.NoError(t, err)
+		assert.Equal(t, oidc.ResponseMode(""), mode)
+		encrypted, err := jose.ParseEncrypted(response.(*oidc.JWTAuthorizationResponse).Response, []jose.KeyAlgorithm{jose.RSA_OAEP_256}, []jose.ContentEncryption{jose.A128GCM})
+		require.NoError(t, err)
+		assert.Equal(t, "enc1", encrypted.Header.KeyID)
+		decrypted, err := encrypted.Decrypt(encryptionKey)
+		require.NoError(t, err)
+		claims := verifyJWTResponse(t, string(decrypted))
+		assert.Equal(t, oidc.Audience{"encrypted"}, claims.Audience)
+	})
+}
+
+func verifyJWTResponse(t *testing.T, token string) *oidc.JWTAuthorizationResponseClaims {
+	t.Helper()
+	signed, err := jose.ParseSigned(token, []jose.SignatureAlgorithm{tu.SignatureAlgorithm})
+	require.NoError(t, err)
+	payload, err := signed.Verify(tu.WebKey.Public())
+	require.NoError(t, err)
+	claims := new(oidc.JWTAuthorizationResponseClaims)
+	require.NoError(t, json.Unmarshal(payload, claims))
+	return claims
+}
@@ -0,0 +1,107 @@
+package op
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// maxJSONRequestBody limits the size of translated JSON request bodies.
+const maxJSONRequestBody = 1 << 20
+
+type jsonRequestBodiesGetter interface {
+	JSONRequestBodies() bool
+}
+
+func jsonRequestBodies(v any) bool {
+	getter, ok := v.(jsonRequestBodiesGetter)
+	return ok && getter.JSONRequestBodies()
+}
+
+// translateJSONBody translates an application/json body of the request to the
+// form-encoded parameters required by RFC 6749, so the endpoints handle both alike.
+// Other requests are left unmodified.
+//
+// String values are taken as they are, numbers and booleans are formatted, and
+// arrays of strings become repeated parameters, except for the space delimited scope.
+// Objects are rejected. Every translation is returned as a warning, so clients
+// which are not compliant can be found in the logs.
+func translateJSONBody(w http.ResponseWriter, r *http.Request) (warnings []string, err error) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Method != http.MethodPost || contentType != "application/json" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONRequestBody))
+	decoder.UseNumber()
+	var params map[string]any
+	if err = decoder.Decode(&params); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("invalid JSON request body").WithParent(err)
+	}
+	warnings = []string{"application/json body instead of application/x-www-form-urlencoded"}
+	form := make(url.Values, len(params))
+	for key, value := range params {
+		switch value := value.(type) {
+		case nil:
+		case string:
+			form.Set(key, value)
+		case json.Number:
+			form.Set(key, value.String())
+			warnings = append(warnings, key+": number instead of string")
+		case bool:
+			form.Set(key, strconv.FormatBool(value))
+			warnings = append(warnings, key+": boolean instead of string")
+		case []any:
+			values := make([]string, len(value))
+			for i, v := range value {
+				s, ok := v.(string)
+				if !ok {
+					return nil, oidc.ErrInvalidRequest().WithDescription("parameter %s must be an array of strings", key)
+				}
+				values[i] = s
+			}
+			if key == "scope" {
+				form.Set(key, strings.Join(values, " "))
+				warnings = append(warnings, key+": array instead of space delimited string")
+				continue
+			}
+			form[key] = values
+			warnings = append(warnings, key+": array instead of repeated parameter")
+		default:
+			return nil, oidc.ErrInvalidRequest().WithDescription("parameter %s must not be an object", key)
+		}
+	}
+	// sorted for stable logs, as the map is not ordered
+	slices.Sort(warnings[1:])
+	body := form.Encode()
+	r.Body = io.NopCloser(bytes.NewBufferString(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Form, r.PostForm = nil, nil
+	return warnings, nil
+}
+
+// jsonBodyHandler translates JSON request bodies, see [WithJSONRequestBodies],
+// before calling the handler.
+func jsonBodyHandler(logger func(*http.Request) *slog.Logger, writeError func(http.ResponseWriter, *http.Request, error), handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		warnings, err := translateJSONBody(w, r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(warnings) > 0 {
+			logger(r).WarnContext(r.Context(), "non-standard request body translated",
+				"path", r.URL.Path, "client_id", r.PostFormValue("client_id"), "warnings", warnings)
+		}
+		handler(w, r)
+	}
+}
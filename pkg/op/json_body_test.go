@@ -0,0 +1,83 @@
+package op
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_translateJSONBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		wantForm     url.Values
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "grant_type=client_credentials",
+			wantForm:    url.Values{"grant_type": {"client_credentials"}},
+		},
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"grant_type":"client_credentials","scope":["openid","profile"],"audience":["a","b"],"expires_in":3600,"pkce":true,"resource":null}`,
+			wantForm: url.Values{
+				"grant_type": {"client_credentials"},
+				"scope":      {"openid profile"},
+				"audience":   {"a", "b"},
+				"expires_in": {"3600"},
+				"pkce":       {"true"},
+			},
+			wantWarnings: []string{
+				"application/json body instead of application/x-www-form-urlencoded",
+				"audience: array instead of repeated parameter",
+				"expires_in: number instead of string",
+				"pkce: boolean instead of string",
+				"scope: array instead of space delimited string",
+			},
+		},
+		{
+			name:        "object",
+			contentType: "application/json",
+			body:        `{"claims":{"userinfo":{}}}`,
+			wantErr:     true,
+		},
+		{
+			name:        "array of objects",
+			contentType: "application/json",
+			body:        `{"audience":[{}]}`,
+			wantErr:     true,
+		},
+		{
+			name:        "invalid",
+			contentType: "application/json",
+			body:        `grant_type=client_credentials`,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			warnings, err := translateJSONBody(httptest.NewRecorder(), r)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidRequest())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWarnings, warnings)
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, tt.wantForm, r.PostForm)
+		})
+	}
+}
@@ -2,11 +2,11 @@ package op
 
 import (
 	"context"
+	"crypto/sha1"
+	"crypto/sha256"
 	"net/http"
 
 	jose "github.com/go-jose/go-jose/v4"
-
-	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 )
 
 type KeyProvider interface {
@@ -26,10 +26,10 @@ func Keys(w http.ResponseWriter, r *http.Request, k KeyProvider) {
 
 	keySet, err := k.KeySet(r.Context())
 	if err != nil {
-		httphelper.MarshalJSONWithStatus(w, err, http.StatusInternalServerError)
+		WriteJSON(w, err, http.StatusInternalServerError)
 		return
 	}
-	httphelper.MarshalJSON(w, jsonWebKeySet(keySet))
+	WriteJSON(w, publicKeySet(k, keySet), http.StatusOK)
 }
 
 func jsonWebKeySet(keys []Key) *jose.JSONWebKeySet {
@@ -41,6 +41,14 @@ func jsonWebKeySet(keys []Key) *jose.JSONWebKeySet {
 			Use:       key.Use(),
 			Key:       key.Key(),
 		}
+		if certs, ok := key.(KeyCertificates); ok && len(certs.Certificates()) > 0 {
+			chain := certs.Certificates()
+			sha1Sum := sha1.Sum(chain[0].Raw)
+			sha256Sum := sha256.Sum256(chain[0].Raw)
+			webKeys[i].Certificates = chain
+			webKeys[i].CertificateThumbprintSHA1 = sha1Sum[:]
+			webKeys[i].CertificateThumbprintSHA256 = sha256Sum[:]
+		}
 	}
 	return &jose.JSONWebKeySet{Keys: webKeys}
 }
@@ -2,6 +2,9 @@ package op_test
 
 import (
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +13,7 @@ import (
 	jose "github.com/go-jose/go-jose/v4"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 	"github.com/lmindwarel/oidc/v3/pkg/op"
@@ -98,3 +102,43 @@ func TestKeys(t *testing.T) {
 		})
 	}
 }
+
+type certKey struct {
+	certSigningKey
+}
+
+func (k certKey) Algorithm() jose.SignatureAlgorithm { return jose.RS256 }
+func (k certKey) Use() string                        { return oidc.KeyUseSignature }
+func (k certKey) Key() any                           { return &k.key.PublicKey }
+
+func TestKeys_certificates(t *testing.T) {
+	key := certKey{newCertSigningKey(t)}
+	m := mock.NewMockKeyProvider(gomock.NewController(t))
+	m.EXPECT().KeySet(gomock.Any()).Return([]op.Key{key}, nil)
+
+	w := httptest.NewRecorder()
+	op.Keys(w, httptest.NewRequest("GET", "/keys", nil), m)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var keySet jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keySet))
+	require.Len(t, keySet.Keys, 1)
+	got := keySet.Keys[0]
+	require.Len(t, got.Certificates, 1)
+	assert.Equal(t, key.certs[0].Raw, got.Certificates[0].Raw)
+	thumbprint := sha256.Sum256(key.certs[0].Raw)
+	assert.Equal(t, thumbprint[:], got.CertificateThumbprintSHA256)
+	assert.NotEmpty(t, got.CertificateThumbprintSHA1)
+	assert.NoError(t, oidc.VerifyKeyCertificates(&got, x509.VerifyOptions{
+		Roots:     certPool(key.certs[0]),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}))
+}
+
+func certPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
@@ -0,0 +1,223 @@
+package op
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// FlowLifetimes are the lifetimes of the auth requests, authorization codes,
+// pushed auth requests and device codes, see [WithFlowLifetimes] and [HasFlowLifetimes].
+//
+// The lifetimes of auth requests and codes are enforced by the Provider,
+// independent of the expiry of the [Storage]: auth requests must be completed
+// by the callback after the login of the user and codes must be exchanged
+// within their lifetime. Zero values are not enforced.
+// The lifetimes of pushed auth requests and device codes take precedence
+// over the ones of the [PushedAuthorizationConfig] and the [DeviceAuthorizationConfig].
+//
+// The expiry is passed to the Storage in the context of CreateAuthRequest and
+// SaveAuthCode, see [ExpiryFromContext], and can be read with [AuthRequestExpiry],
+// e.g. for a countdown in the login UI.
+type FlowLifetimes struct {
+	AuthRequest       time.Duration
+	AuthCode          time.Duration
+	PushedAuthRequest time.Duration
+	DeviceCode        time.Duration
+
+	cache cache.Cache
+}
+
+// HasFlowLifetimes is an optional interface of a [Client] with its own
+// [FlowLifetimes], whose non-zero values take precedence over the ones
+// of [WithFlowLifetimes]. It requires [WithFlowLifetimes].
+type HasFlowLifetimes interface {
+	FlowLifetimes() FlowLifetimes
+}
+
+type flowLifetimesGetter interface {
+	FlowLifetimes() *FlowLifetimes
+}
+
+// flowLifetimes returns the [FlowLifetimes], nil if not set with [WithFlowLifetimes].
+func flowLifetimes(v any) *FlowLifetimes {
+	if getter, ok := v.(flowLifetimesGetter); ok {
+		return getter.FlowLifetimes()
+	}
+	return nil
+}
+
+// forClient returns the lifetimes, with the non-zero values of the client taking precedence.
+func (l FlowLifetimes) forClient(client any) FlowLifetimes {
+	c, ok := client.(HasFlowLifetimes)
+	if !ok {
+		return l
+	}
+	own := c.FlowLifetimes()
+	if own.AuthRequest > 0 {
+		l.AuthRequest = own.AuthRequest
+	}
+	if own.AuthCode > 0 {
+		l.AuthCode = own.AuthCode
+	}
+	if own.PushedAuthRequest > 0 {
+		l.PushedAuthRequest = own.PushedAuthRequest
+	}
+	if own.DeviceCode > 0 {
+		l.DeviceCode = own.DeviceCode
+	}
+	return l
+}
+
+// clientFlowLifetimes returns the lifetimes of the client, nil if not set with [WithFlowLifetimes].
+func clientFlowLifetimes(v any, client any) *FlowLifetimes {
+	config := flowLifetimes(v)
+	if config == nil {
+		return nil
+	}
+	lifetimes := config.forClient(client)
+	return &lifetimes
+}
+
+// clientFlowLifetimesByID is [clientFlowLifetimes] for the client of the ID.
+func clientFlowLifetimesByID(ctx context.Context, v any, storage Storage, clientID string) (*FlowLifetimes, error) {
+	if flowLifetimes(v) == nil {
+		return nil, nil
+	}
+	client, err := storage.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return clientFlowLifetimes(v, client), nil
+}
+
+type expiryKey struct{}
+
+// ExpiryFromContext returns the expiry of the auth request or code, which is being stored
+// by CreateAuthRequest or SaveAuthCode of the [Storage], if enforced by [FlowLifetimes].
+func ExpiryFromContext(ctx context.Context) (time.Time, bool) {
+	expiry, ok := ctx.Value(expiryKey{}).(time.Time)
+	return expiry, ok
+}
+
+func contextWithExpiry(ctx context.Context, lifetime time.Duration) context.Context {
+	if lifetime <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, expiryKey{}, time.Now().Add(lifetime))
+}
+
+// recordExpiry records the expiry of the context for the key, if any.
+func (l *FlowLifetimes) recordExpiry(ctx context.Context, key string) error {
+	expiry, ok := ExpiryFromContext(ctx)
+	if !ok || !time.Now().Before(expiry) {
+		return nil
+	}
+	return cache.SetJSON(ctx, l.cache, key, expiry, time.Until(expiry))
+}
+
+// checkExpiry returns [ErrExpired], if the expiry recorded for the key passed or is missing.
+func (l *FlowLifetimes) checkExpiry(ctx context.Context, key string) error {
+	expiry, err := l.expiry(ctx, key)
+	if errors.Is(err, cache.ErrNotFound) || (err == nil && time.Now().After(expiry)) {
+		return oidc.ErrExpired
+	}
+	return err
+}
+
+func (l *FlowLifetimes) expiry(ctx context.Context, key string) (time.Time, error) {
+	var expiry time.Time
+	err := cache.GetJSON(ctx, l.cache, key, &expiry)
+	return expiry, err
+}
+
+func authRequestExpiryKey(id string) string {
+	return "flow:auth_request:" + id
+}
+
+func authCodeExpiryKey(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return "flow:auth_code:" + base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// AuthRequestExpiry returns the expiry of the auth request of the ID,
+// if enforced by [FlowLifetimes], e.g. for a countdown in the login UI.
+func AuthRequestExpiry(ctx context.Context, o OpenIDProvider, id string) (time.Time, bool) {
+	config := flowLifetimes(o)
+	if config == nil {
+		return time.Time{}, false
+	}
+	expiry, err := config.expiry(ctx, authRequestExpiryKey(id))
+	return expiry, err == nil
+}
+
+// createAuthRequest creates the auth request in the Storage,
+// passing and recording its expiry, if enforced by [FlowLifetimes].
+func createAuthRequest(ctx context.Context, v any, storage Storage, authReq *oidc.AuthRequest, userID string, client Client) (AuthRequest, error) {
+	lifetimes := clientFlowLifetimes(v, client)
+	if lifetimes == nil {
+		return storage.CreateAuthRequest(ctx, authReq, userID)
+	}
+	ctx = contextWithExpiry(ctx, lifetimes.AuthRequest)
+	req, err := storage.CreateAuthRequest(ctx, authReq, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = lifetimes.recordExpiry(ctx, authRequestExpiryKey(req.GetID())); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// createAuthRequestCode creates the code of the auth request,
+// passing and recording its expiry, if enforced by [FlowLifetimes].
+func createAuthRequestCode(ctx context.Context, v any, authReq AuthRequest, storage Storage, crypto Crypto) (string, error) {
+	lifetimes, err := clientFlowLifetimesByID(ctx, v, storage, authReq.GetClientID())
+	if err != nil {
+		return "", err
+	}
+	if lifetimes == nil {
+		return CreateAuthRequestCode(ctx, authReq, storage, crypto)
+	}
+	ctx = contextWithExpiry(ctx, lifetimes.AuthCode)
+	code, err := CreateAuthRequestCode(ctx, authReq, storage, crypto)
+	if err != nil {
+		return "", err
+	}
+	if err = lifetimes.recordExpiry(ctx, authCodeExpiryKey(code)); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// checkAuthRequestExpiry rejects auth requests, whose lifetime passed.
+func checkAuthRequestExpiry(ctx context.Context, v any, storage Storage, authReq AuthRequest) error {
+	lifetimes, err := clientFlowLifetimesByID(ctx, v, storage, authReq.GetClientID())
+	if err != nil {
+		return oidc.DefaultToServerError(err, "unable to get client")
+	}
+	if lifetimes == nil || lifetimes.AuthRequest <= 0 {
+		return nil
+	}
+	if err = lifetimes.checkExpiry(ctx, authRequestExpiryKey(authReq.GetID())); err != nil {
+		return oidc.ErrInvalidRequest().WithDescription("auth request expired").WithParent(err)
+	}
+	return nil
+}
+
+// checkAuthCodeExpiry rejects codes of the client, whose lifetime passed.
+func checkAuthCodeExpiry(ctx context.Context, v any, client Client, code string) error {
+	lifetimes := clientFlowLifetimes(v, client)
+	if lifetimes == nil || lifetimes.AuthCode <= 0 {
+		return nil
+	}
+	if err := lifetimes.checkExpiry(ctx, authCodeExpiryKey(code)); err != nil {
+		return oidc.ErrInvalidGrant().WithDescription("code expired").WithParent(err)
+	}
+	return nil
+}
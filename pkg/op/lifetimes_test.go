@@ -0,0 +1,109 @@
+package op_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// expiringCache loses all entries, when expired is set.
+type expiringCache struct {
+	*cache.Memory
+	expired bool
+}
+
+func (c *expiringCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if c.expired {
+		return nil, cache.ErrNotFound
+	}
+	return c.Memory.Get(ctx, key)
+}
+
+func TestWithFlowLifetimes(t *testing.T) {
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	c := &expiringCache{Memory: cache.NewMemory()}
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithAllowInsecure(),
+		op.WithFlowLifetimes(op.FlowLifetimes{AuthRequest: 5 * time.Minute, AuthCode: time.Minute}, c),
+	)
+	require.NoError(t, err)
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, r)
+		return w
+	}
+
+	// authorize returns the ID of a new auth request, completed by the login of the user.
+	authorize := func(t *testing.T) string {
+		c.expired = false
+		params := url.Values{
+			"client_id":     {"web"},
+			"redirect_uri":  {"https://example.com"},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+		}
+		w := serve(httptest.NewRequest(http.MethodGet, testIssuer+"authorize?"+params.Encode(), nil))
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		id := location.Query().Get("authRequestID")
+		require.NotEmpty(t, id)
+		require.NoError(t, s.CheckUsernamePassword("test-user@localhost", "verysecure", id))
+
+		expiry, ok := op.AuthRequestExpiry(context.Background(), provider, id)
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(5*time.Minute), expiry, time.Minute)
+		return id
+	}
+	callback := func(id string) *url.URL {
+		w := serve(httptest.NewRequest(http.MethodGet, testIssuer+"authorize/callback?id="+id, nil))
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location
+	}
+	exchange := func(code string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {code},
+			"redirect_uri": {"https://example.com"},
+		}
+		r := httptest.NewRequest(http.MethodPost, testIssuer+"oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("web", "secret")
+		return serve(r)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		code := callback(authorize(t)).Query().Get("code")
+		require.NotEmpty(t, code)
+		w := exchange(code)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+	t.Run("expired auth request", func(t *testing.T) {
+		id := authorize(t)
+		c.expired = true
+		location := callback(id)
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+		assert.Equal(t, "auth request expired", location.Query().Get("error_description"))
+	})
+	t.Run("expired code", func(t *testing.T) {
+		code := callback(authorize(t)).Query().Get("code")
+		require.NotEmpty(t, code)
+		c.expired = true
+		w := exchange(code)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "code expired")
+	})
+}
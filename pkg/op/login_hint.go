@@ -0,0 +1,47 @@
+package op
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// LoginHintValidator is an optional additional interface that may be implemented by
+// implementors of Storage. It is called during the auth request, before it is
+// created, to validate the login hints ([oidc.LoginHints]) of the request.
+//
+// The hints may be modified, e.g. to resolve a login_hint_token into a login_hint
+// or to drop an idp_hint the client is not allowed to use. The (modified) hints
+// are passed to CreateAuthRequest as part of the [oidc.AuthRequest],
+// so the Login UI is able to pre-select an account or skip the picker.
+//
+// If not implemented, the hints are passed through as received.
+type LoginHintValidator interface {
+	ValidateLoginHints(ctx context.Context, hints *oidc.LoginHints, client Client) error
+}
+
+// validateLoginHints calls the [LoginHintValidator] if implemented by the storage.
+// Errors which are not an [oidc.Error] are returned as invalid_request.
+func validateLoginHints(ctx context.Context, storage Storage, authReq *oidc.AuthRequest, client Client) error {
+	validator, ok := storage.(LoginHintValidator)
+	if !ok {
+		return nil
+	}
+	hints := authReq.GetLoginHints()
+	if hints.IsEmpty() {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "validateLoginHints")
+	defer span.End()
+
+	if err := validator.ValidateLoginHints(ctx, &hints, client); err != nil {
+		oidcErr := new(oidc.Error)
+		if errors.As(err, &oidcErr) {
+			return oidcErr
+		}
+		return oidc.ErrInvalidRequest().WithDescription("The login hint is invalid.").WithParent(err)
+	}
+	authReq.SetLoginHints(hints)
+	return nil
+}
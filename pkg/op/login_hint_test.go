@@ -0,0 +1,83 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loginHintStorage struct {
+	Storage
+}
+
+func (loginHintStorage) ValidateLoginHints(_ context.Context, hints *oidc.LoginHints, _ Client) error {
+	switch hints.LoginHintToken {
+	case "":
+	case "valid":
+		hints.LoginHint = "user@example.com"
+		hints.LoginHintToken = ""
+	case "expired":
+		return oidc.ErrInvalidGrant().WithDescription("expired")
+	default:
+		return errors.New("unknown token")
+	}
+	if hints.IDPHint == "forbidden" {
+		hints.IDPHint = ""
+	}
+	return nil
+}
+
+func Test_validateLoginHints(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage Storage
+		authReq *oidc.AuthRequest
+		want    oidc.LoginHints
+		wantErr error
+	}{
+		{
+			name:    "not implemented",
+			storage: struct{ Storage }{},
+			authReq: &oidc.AuthRequest{LoginHintToken: "valid"},
+			want:    oidc.LoginHints{LoginHintToken: "valid"},
+		},
+		{
+			name:    "no hints",
+			storage: loginHintStorage{},
+			authReq: &oidc.AuthRequest{},
+		},
+		{
+			name:    "resolved",
+			storage: loginHintStorage{},
+			authReq: &oidc.AuthRequest{LoginHintToken: "valid", IDPHint: "forbidden", DomainHint: "example.com"},
+			want:    oidc.LoginHints{LoginHint: "user@example.com", DomainHint: "example.com"},
+		},
+		{
+			name:    "oidc error",
+			storage: loginHintStorage{},
+			authReq: &oidc.AuthRequest{LoginHintToken: "expired"},
+			wantErr: oidc.ErrInvalidGrant(),
+		},
+		{
+			name:    "other error",
+			storage: loginHintStorage{},
+			authReq: &oidc.AuthRequest{LoginHintToken: "other"},
+			wantErr: oidc.ErrInvalidRequest(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLoginHints(context.Background(), tt.storage, tt.authReq, nil)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tt.authReq.GetLoginHints())
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package op
+
+import (
+	"errors"
+	"slices"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+var ErrMLDSADisabled = errors.New("ML-DSA signing keys require WithExperimentalMLDSA")
+
+type mldsaGetter interface {
+	ExperimentalMLDSA() bool
+}
+
+func providerMLDSA(v any) bool {
+	if g, ok := v.(mldsaGetter); ok {
+		return g.ExperimentalMLDSA()
+	}
+	return false
+}
+
+// WithExperimentalMLDSA opts in to the EXPERIMENTAL post-quantum ML-DSA (FIPS 204)
+// signing of ID and access tokens, see [crypto.MLDSA44]. Signing keys are created
+// with [crypto.NewMLDSASigner] and public keys with [crypto.NewMLDSAPublicKey],
+// which require Go 1.27.
+//
+// Without it, ML-DSA signing keys are rejected with [ErrMLDSADisabled] and
+// ML-DSA keys and algorithms are neither served nor advertised.
+// With it, ML-DSA public keys are served as AKP JSON Web Keys and discovery
+// advertises their algorithms after the classical ones. Publishing a classical and
+// an ML-DSA key (hybrid) lets relying parties without ML-DSA support keep working,
+// while [Provider.SetSigningKeys] switches the signing key for the pilot.
+// The access token and ID token hint verifiers of the Provider accept ML-DSA
+// signatures in addition to their default algorithms.
+func WithExperimentalMLDSA() Option {
+	return func(o *Provider) error {
+		o.mldsa = true
+		return nil
+	}
+}
+
+// checkMLDSAKey returns an error for an ML-DSA signing key, unless enabled.
+func checkMLDSAKey(enabled bool, key SigningKey) error {
+	if !enabled && crypto.IsMLDSA(key.SignatureAlgorithm()) {
+		return ErrMLDSADisabled
+	}
+	return nil
+}
+
+// mldsaAlgorithms removes the ML-DSA algorithms, unless enabled,
+// or moves them after the classical algorithms.
+func mldsaAlgorithms(enabled bool, algs []jose.SignatureAlgorithm) []jose.SignatureAlgorithm {
+	classical := slices.DeleteFunc(slices.Clone(algs), crypto.IsMLDSA)
+	if !enabled {
+		return classical
+	}
+	for _, alg := range algs {
+		if crypto.IsMLDSA(alg) {
+			classical = append(classical, alg)
+		}
+	}
+	return classical
+}
+
+// mldsaVerifierAlgorithms adds the ML-DSA algorithms to the defaults of
+// [oidc.CheckSignature], if enabled. Algorithms set explicitly are kept.
+func mldsaVerifierAlgorithms(enabled bool, algs []string) []string {
+	if !enabled || len(algs) > 0 {
+		return algs
+	}
+	algs = []string{string(jose.RS256), string(jose.ES256), string(jose.PS256)}
+	for _, alg := range crypto.MLDSAAlgorithms {
+		algs = append(algs, string(alg))
+	}
+	return algs
+}
+
+// publicKeySet returns the key set of the keys endpoint. ML-DSA keys are served
+// as AKP JSON Web Keys, which [jose.JSONWebKeySet] can't marshal, if enabled,
+// and removed otherwise.
+func publicKeySet(v any, keys []Key) any {
+	set := fipsKeySet(v, jsonWebKeySet(keys))
+	if !slices.ContainsFunc(set.Keys, isMLDSAKey) {
+		return set
+	}
+	if !providerMLDSA(v) {
+		set.Keys = slices.DeleteFunc(set.Keys, isMLDSAKey)
+		return set
+	}
+	webKeys := make([]any, len(set.Keys))
+	for i, key := range set.Keys {
+		if k, ok := key.Key.(crypto.MLDSAKey); ok {
+			webKeys[i] = crypto.NewAKPJSONWebKey(k, key.KeyID, key.Use)
+			continue
+		}
+		webKeys[i] = key
+	}
+	return struct {
+		Keys []any `json:"keys"`
+	}{Keys: webKeys}
+}
+
+func isMLDSAKey(key jose.JSONWebKey) bool {
+	_, ok := key.Key.(crypto.MLDSAKey)
+	return ok
+}
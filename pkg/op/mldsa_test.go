@@ -0,0 +1,101 @@
+//go:build go1.27
+
+package op_test
+
+import (
+	"context"
+	"crypto/mldsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+type mldsaSigningKey struct {
+	signer *crypto.MLDSASigner
+}
+
+func (k mldsaSigningKey) SignatureAlgorithm() jose.SignatureAlgorithm { return k.signer.Algorithm() }
+func (k mldsaSigningKey) Key() any                                    { return k.signer }
+func (k mldsaSigningKey) ID() string                                  { return "pq1" }
+
+type mldsaKey struct {
+	mldsaSigningKey
+}
+
+func (k mldsaKey) Algorithm() jose.SignatureAlgorithm { return k.signer.Algorithm() }
+func (k mldsaKey) Use() string                        { return oidc.KeyUseSignature }
+func (k mldsaKey) Key() any                           { return k.signer.PublicKey() }
+
+func newMLDSASigningKey(t *testing.T) mldsaSigningKey {
+	key, err := mldsa.GenerateKey(mldsa.MLDSA44())
+	require.NoError(t, err)
+	return mldsaSigningKey{signer: crypto.NewMLDSASigner(key)}
+}
+
+func newMLDSAProvider(t *testing.T, opts ...op.Option) *op.Provider {
+	t.Helper()
+	opts = append([]op.Option{op.WithAllowInsecure()}, opts...)
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)), opts...)
+	require.NoError(t, err)
+	return provider
+}
+
+func TestWithExperimentalMLDSA_disabled(t *testing.T) {
+	provider := newMLDSAProvider(t)
+	key := newMLDSASigningKey(t)
+	err := provider.SetSigningKeys(key, mldsaKey{key})
+	assert.ErrorIs(t, err, op.ErrMLDSADisabled)
+}
+
+func TestWithExperimentalMLDSA(t *testing.T) {
+	provider := newMLDSAProvider(t, op.WithExperimentalMLDSA())
+	key := newMLDSASigningKey(t)
+	classical := newCertSigningKey(t)
+	require.NoError(t, provider.SetSigningKeys(key, certKey{classical}, mldsaKey{key}))
+
+	w := httptest.NewRecorder()
+	provider.ServeHTTP(w, httptest.NewRequest(http.MethodGet, oidc.DiscoveryEndpoint, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var config oidc.DiscoveryConfiguration
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &config))
+	assert.Equal(t, []string{"RS256", "ML-DSA-44"}, config.IDTokenSigningAlgValuesSupported)
+
+	w = httptest.NewRecorder()
+	provider.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var keySet struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keySet))
+	require.Len(t, keySet.Keys, 2)
+	var rsaKey jose.JSONWebKey
+	require.NoError(t, rsaKey.UnmarshalJSON(keySet.Keys[0]))
+	var akp crypto.AKPJSONWebKey
+	require.NoError(t, json.Unmarshal(keySet.Keys[1], &akp))
+	assert.Equal(t, "pq1", akp.KeyID)
+	public, err := crypto.ParseMLDSAPublicKey(akp)
+	require.NoError(t, err)
+	assert.Equal(t, key.signer.PublicKeyBytes(), public.PublicKeyBytes())
+
+	signer, err := op.SignerFromKey(key)
+	require.NoError(t, err)
+	claims := oidc.NewAccessTokenClaims(testIssuer, "sub", []string{"client"}, time.Now().Add(time.Hour), "id", "client", 0)
+	token, err := crypto.Sign(claims, signer)
+	require.NoError(t, err)
+
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	verified, err := op.VerifyAccessToken[*oidc.AccessTokenClaims](ctx, token, provider.AccessTokenVerifier(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, "sub", verified.Subject)
+}
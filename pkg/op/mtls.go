@@ -0,0 +1,297 @@
+package op
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// MTLSConfig enables the mutual-TLS client authentication and the
+// certificate-bound access tokens of RFC 8705, see [WithMTLS].
+//
+// The client certificate is read at the token, introspection, revocation,
+// pushed authorization and backchannel authentication endpoints, where the
+// TLS termination must request client certificates. The certificates of
+// tls_client_auth clients must be verified, either against the ClientCAs,
+// or by the TLS termination of the [http.Server] (like [crypto/tls.VerifyClientCertIfGiven]),
+// which rejects the self-signed certificates of self_signed_tls_client_auth.
+// Accepting both requires the ClientCAs, with a TLS termination that does not
+// verify the certificates (like [crypto/tls.RequestClientCert]).
+// See [MTLSAliases] to serve the endpoints on a separate host.
+type MTLSConfig struct {
+	// ClientCertificate returns the client certificate of the request, or nil if none.
+	// Defaults to [TLSClientCertificate]. Behind a TLS terminating proxy, it must
+	// return the certificate forwarded by the proxy, see [ForwardedClientCertificate].
+	ClientCertificate func(*http.Request) (*x509.Certificate, error)
+	// TLSClientAuth enables the tls_client_auth method,
+	// for clients implementing [TLSClientAuthClient].
+	TLSClientAuth bool
+	// ClientCAs verifies the certificates of tls_client_auth clients.
+	// If nil, the certificate must have been verified by the TLS handshake
+	// of the request (VerifiedChains of [crypto/tls.ConnectionState]), so it
+	// is required with a ClientCertificate function, e.g. behind a proxy.
+	ClientCAs *x509.CertPool
+	// SelfSignedTLSClientAuth enables the self_signed_tls_client_auth method,
+	// for clients implementing [SelfSignedTLSClient].
+	SelfSignedTLSClientAuth bool
+	// BoundAccessTokens binds the access tokens issued to requests with a client
+	// certificate to the certificate, see [CertificateThumbprintFromContext].
+	BoundAccessTokens bool
+}
+
+func (c *MTLSConfig) clientCertificate(r *http.Request) (*x509.Certificate, error) {
+	if c.ClientCertificate != nil {
+		return c.ClientCertificate(r)
+	}
+	return TLSClientCertificate(r)
+}
+
+func (c *MTLSConfig) validate() error {
+	if c.TLSClientAuth && c.ClientCAs == nil && c.ClientCertificate != nil {
+		return errors.New("mtls: tls_client_auth with a ClientCertificate function requires ClientCAs")
+	}
+	return nil
+}
+
+func (c *MTLSConfig) authMethods() []oidc.AuthMethod {
+	var methods []oidc.AuthMethod
+	if c.TLSClientAuth {
+		methods = append(methods, oidc.AuthMethodTLSClientAuth)
+	}
+	if c.SelfSignedTLSClientAuth {
+		methods = append(methods, oidc.AuthMethodSelfSignedTLSClientAuth)
+	}
+	return methods
+}
+
+type mtlsGetter interface {
+	MTLS() *MTLSConfig
+}
+
+func mtlsConfig(v any) *MTLSConfig {
+	if getter, ok := v.(mtlsGetter); ok {
+		return getter.MTLS()
+	}
+	return nil
+}
+
+// boundAccessTokens reports whether access tokens are bound to client certificates.
+func boundAccessTokens(v any) bool {
+	config := mtlsConfig(v)
+	return config != nil && config.BoundAccessTokens
+}
+
+// TLSClientCertificate returns the leaf certificate presented by the client
+// on the TLS connection of the request, or nil if none.
+func TLSClientCertificate(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+	return r.TLS.PeerCertificates[0], nil
+}
+
+// ForwardedClientCertificate returns a ClientCertificate function of the [MTLSConfig],
+// reading the client certificate from the header set by a TLS terminating proxy,
+// either as byte sequence of RFC 9440 (`:base64 DER:`, as in the Client-Cert header)
+// or as URL encoded PEM (like nginx $ssl_client_escaped_cert).
+//
+// The proxy must remove the header from the requests of clients,
+// otherwise they can present any certificate.
+func ForwardedClientCertificate(header string) func(*http.Request) (*x509.Certificate, error) {
+	return func(r *http.Request) (*x509.Certificate, error) {
+		value := r.Header.Get(header)
+		if value == "" {
+			return nil, nil
+		}
+		if der, ok := strings.CutPrefix(value, ":"); ok {
+			der, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(der, ":"))
+			if err != nil {
+				return nil, err
+			}
+			return x509.ParseCertificate(der)
+		}
+		value, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode([]byte(value))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, errors.New("no PEM encoded certificate")
+		}
+		return x509.ParseCertificate(block.Bytes)
+	}
+}
+
+type clientCertificateKey struct{}
+
+type certificateThumbprintKey struct{}
+
+type verifiedCertificateKey struct{}
+
+// ClientCertificateFromContext returns the client certificate of the request,
+// if mutual-TLS is enabled with [WithMTLS] and the client presented one.
+func ClientCertificateFromContext(ctx context.Context) *x509.Certificate {
+	cert, _ := ctx.Value(clientCertificateKey{}).(*x509.Certificate)
+	return cert
+}
+
+// CertificateThumbprintFromContext returns the thumbprint of the client certificate
+// (RFC 8705, section 3.1) the access tokens of the token request are bound to,
+// if enabled with BoundAccessTokens of the [MTLSConfig].
+//
+// JWT access tokens are bound to the certificate by their cnf claim.
+// Storages issuing opaque access tokens should record the thumbprint
+// in CreateAccessToken and return it as [oidc.Confirmation] of the
+// introspection response, so resource servers can verify the binding.
+func CertificateThumbprintFromContext(ctx context.Context) string {
+	thumbprint, _ := ctx.Value(certificateThumbprintKey{}).(string)
+	return thumbprint
+}
+
+// mtlsHandler passes the client certificate of the request to the handler in the context,
+// see [ClientCertificateFromContext] and [CertificateThumbprintFromContext].
+func mtlsHandler(config *MTLSConfig, writeError func(http.ResponseWriter, *http.Request, error), handler http.HandlerFunc) http.HandlerFunc {
+	if config == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		cert, err := config.clientCertificate(r)
+		if err != nil {
+			writeError(w, r, oidc.ErrInvalidRequest().WithDescription("invalid client certificate").WithParent(err))
+			return
+		}
+		if cert == nil {
+			handler(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), clientCertificateKey{}, cert)
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && cert.Equal(r.TLS.VerifiedChains[0][0]) {
+			ctx = context.WithValue(ctx, verifiedCertificateKey{}, true)
+		}
+		if config.BoundAccessTokens {
+			ctx = context.WithValue(ctx, certificateThumbprintKey{}, oidc.CertificateThumbprint(cert))
+		}
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// TLSClientAuthSubject is the expected subject of the certificate of a
+// tls_client_auth client, of which exactly one value is set (RFC 8705, section 2.1.2).
+type TLSClientAuthSubject struct {
+	// SubjectDN is compared to the subject of the certificate,
+	// as formatted by [crypto/x509/pkix.Name.String], ignoring case.
+	SubjectDN string
+	SANDNS    string
+	SANURI    string
+	SANIP     string
+	SANEmail  string
+}
+
+func (s TLSClientAuthSubject) matches(cert *x509.Certificate) bool {
+	switch {
+	case s.SubjectDN != "":
+		return strings.EqualFold(s.SubjectDN, cert.Subject.String())
+	case s.SANDNS != "":
+		return slices.ContainsFunc(cert.DNSNames, func(name string) bool { return strings.EqualFold(name, s.SANDNS) })
+	case s.SANURI != "":
+		return slices.ContainsFunc(cert.URIs, func(uri *url.URL) bool { return uri.String() == s.SANURI })
+	case s.SANIP != "":
+		ip := net.ParseIP(s.SANIP)
+		return ip != nil && slices.ContainsFunc(cert.IPAddresses, ip.Equal)
+	case s.SANEmail != "":
+		return slices.Contains(cert.EmailAddresses, s.SANEmail)
+	}
+	return false
+}
+
+// TLSClientAuthClient is an optional interface that can be implemented by implementors
+// of Client, which authenticate with [oidc.AuthMethodTLSClientAuth],
+// by a certificate with the subject, issued by one of the ClientCAs of the [MTLSConfig].
+type TLSClientAuthClient interface {
+	Client
+	TLSClientAuthSubject() TLSClientAuthSubject
+}
+
+// SelfSignedTLSClient is an optional interface that can be implemented by implementors
+// of Client, which authenticate with [oidc.AuthMethodSelfSignedTLSClientAuth].
+// The public key of the certificate must be one of the registered keys of the client.
+type SelfSignedTLSClient interface {
+	Client
+	TLSClientKeys(ctx context.Context) ([]jose.JSONWebKey, error)
+}
+
+func isTLSAuthMethod(method oidc.AuthMethod) bool {
+	return method == oidc.AuthMethodTLSClientAuth || method == oidc.AuthMethodSelfSignedTLSClientAuth
+}
+
+// AuthorizeTLSClient authenticates a client using tls_client_auth or self_signed_tls_client_auth
+// by the client certificate of the request (RFC 8705, section 2), see [ClientCertificateFromContext].
+// The certificate of a tls_client_auth client must be verified against the ClientCAs
+// of the config, or have been verified by the TLS handshake of the request.
+func AuthorizeTLSClient(ctx context.Context, client Client, config *MTLSConfig) error {
+	ctx, span := tracer.Start(ctx, "AuthorizeTLSClient")
+	defer span.End()
+
+	method := client.AuthMethod()
+	if config == nil || !slices.Contains(config.authMethods(), method) {
+		return oidc.ErrInvalidClient().WithDescription("auth_method %s not supported", method)
+	}
+	cert := ClientCertificateFromContext(ctx)
+	if cert == nil {
+		return oidc.ErrInvalidClient().WithDescription("client certificate required")
+	}
+	if method == oidc.AuthMethodTLSClientAuth {
+		if config.ClientCAs != nil {
+			_, err := cert.Verify(x509.VerifyOptions{Roots: config.ClientCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+			if err != nil {
+				return oidc.ErrInvalidClient().WithDescription("client certificate not trusted").WithParent(err)
+			}
+		} else if verified, _ := ctx.Value(verifiedCertificateKey{}).(bool); !verified {
+			return oidc.ErrInvalidClient().WithDescription("client certificate not verified")
+		}
+		tlsClient, ok := client.(TLSClientAuthClient)
+		if !ok || !tlsClient.TLSClientAuthSubject().matches(cert) {
+			return oidc.ErrInvalidClient().WithDescription("client certificate does not match")
+		}
+		return nil
+	}
+	selfSigned, ok := client.(SelfSignedTLSClient)
+	if !ok {
+		return oidc.ErrInvalidClient().WithDescription("client certificate does not match")
+	}
+	keys, err := selfSigned.TLSClientKeys(ctx)
+	if err != nil {
+		return oidc.ErrInvalidClient().WithDescription("client keys unavailable").WithParent(err)
+	}
+	publicKey, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !slices.ContainsFunc(keys, func(key jose.JSONWebKey) bool { return publicKey.Equal(key.Public().Key) }) {
+		return oidc.ErrInvalidClient().WithDescription("client certificate does not match")
+	}
+	return nil
+}
+
+// authorizeTLSClientByID authenticates the client by its certificate, if it uses
+// one of the mutual-TLS auth methods and a certificate was presented.
+// For other clients, ok is false and they must be authenticated otherwise.
+func authorizeTLSClientByID(ctx context.Context, storage Storage, clientID string, config *MTLSConfig) (_ Client, ok bool, err error) {
+	if config == nil || clientID == "" || ClientCertificateFromContext(ctx) == nil {
+		return nil, false, nil
+	}
+	client, err := storage.GetClientByClientID(ctx, clientID)
+	if err != nil || !isTLSAuthMethod(client.AuthMethod()) {
+		return nil, false, nil
+	}
+	return client, true, AuthorizeTLSClient(ctx, client, config)
+}
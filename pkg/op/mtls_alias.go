@@ -0,0 +1,125 @@
+package op
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// MTLSAliasMode defines on which host an endpoint is served,
+// when [MTLSAliases] are configured.
+type MTLSAliasMode int
+
+const (
+	// MTLSAliasNone serves the endpoint on the public host only.
+	MTLSAliasNone MTLSAliasMode = iota
+	// MTLSAliasShared serves the endpoint on the public and the mTLS alias host.
+	MTLSAliasShared
+	// MTLSAliasExclusive serves the endpoint on the mTLS alias host only,
+	// as required by some FAPI deployments.
+	MTLSAliasExclusive
+)
+
+// MTLSAliases configures the mTLS endpoint aliases of RFC 8705, section 5.
+// The aliased endpoints are served with the same paths on a separate host,
+// where the TLS termination requests client certificates.
+// They are advertised as `mtls_endpoint_aliases` in the discovery document.
+//
+// Requests on the alias host to endpoints that are not aliased,
+// and requests on the public host to exclusive endpoints, result in 404 Not Found.
+type MTLSAliases struct {
+	// Host of the mTLS alias (and optional port), e.g. "mtls.example.com".
+	Host string
+
+	Token               MTLSAliasMode
+	Introspection       MTLSAliasMode
+	Userinfo            MTLSAliasMode
+	Revocation          MTLSAliasMode
+	DeviceAuthorization MTLSAliasMode
+}
+
+type mtlsAliasesGetter interface {
+	MTLSAliases() *MTLSAliases
+}
+
+func (m *MTLSAliases) modes(endpoints *Endpoints) map[string]MTLSAliasMode {
+	modes := make(map[string]MTLSAliasMode, 5)
+	for e, mode := range map[*Endpoint]MTLSAliasMode{
+		endpoints.Token:               m.Token,
+		endpoints.Introspection:       m.Introspection,
+		endpoints.Userinfo:            m.Userinfo,
+		endpoints.Revocation:          m.Revocation,
+		endpoints.DeviceAuthorization: m.DeviceAuthorization,
+	} {
+		if e != nil && mode != MTLSAliasNone {
+			modes[e.Relative()] = mode
+		}
+	}
+	return modes
+}
+
+// Handler returns a middleware restricting the endpoints to the hosts
+// they are configured for.
+func (m *MTLSAliases) Handler(endpoints *Endpoints) func(http.Handler) http.Handler {
+	modes := m.modes(endpoints)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mode := modes[r.URL.Path]
+			onAlias := strings.EqualFold(r.Host, m.Host)
+			if (onAlias && mode == MTLSAliasNone) || (!onAlias && mode == MTLSAliasExclusive) {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// aliasIssuer returns the issuer with the host replaced by the alias host.
+func (m *MTLSAliases) aliasIssuer(issuer string) string {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return issuer
+	}
+	u.Host = m.Host
+	return u.String()
+}
+
+func (m *MTLSAliases) discovery(issuer string, endpoints *Endpoints) *oidc.MTLSEndpointAliases {
+	issuer = m.aliasIssuer(issuer)
+	alias := func(e *Endpoint, mode MTLSAliasMode) string {
+		if mode == MTLSAliasNone {
+			return ""
+		}
+		return e.Absolute(issuer)
+	}
+	return &oidc.MTLSEndpointAliases{
+		TokenEndpoint:               alias(endpoints.Token, m.Token),
+		IntrospectionEndpoint:       alias(endpoints.Introspection, m.Introspection),
+		UserinfoEndpoint:            alias(endpoints.Userinfo, m.Userinfo),
+		RevocationEndpoint:          alias(endpoints.Revocation, m.Revocation),
+		DeviceAuthorizationEndpoint: alias(endpoints.DeviceAuthorization, m.DeviceAuthorization),
+	}
+}
+
+// discoveryMTLSAliases returns the mtls_endpoint_aliases,
+// if the config implements mtlsAliasesGetter.
+func discoveryMTLSAliases(config any, issuer string, endpoints *Endpoints) *oidc.MTLSEndpointAliases {
+	mo, ok := config.(mtlsAliasesGetter)
+	if !ok || mo.MTLSAliases() == nil {
+		return nil
+	}
+	return mo.MTLSAliases().discovery(issuer, endpoints)
+}
+
+func configEndpoints(c Configuration) *Endpoints {
+	return &Endpoints{
+		Token:               c.TokenEndpoint(),
+		Introspection:       c.IntrospectionEndpoint(),
+		Userinfo:            c.UserinfoEndpoint(),
+		Revocation:          c.RevocationEndpoint(),
+		DeviceAuthorization: c.DeviceAuthorizationEndpoint(),
+	}
+}
@@ -0,0 +1,55 @@
+package op
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMTLSAliases_Handler(t *testing.T) {
+	aliases := &MTLSAliases{
+		Host:          "mtls.example.com",
+		Token:         MTLSAliasShared,
+		Introspection: MTLSAliasExclusive,
+	}
+	handler := aliases.Handler(DefaultEndpoints)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	tests := []struct {
+		host string
+		path string
+		want int
+	}{
+		{"example.com", "/oauth/token", http.StatusOK},
+		{"mtls.example.com", "/oauth/token", http.StatusOK},
+		{"example.com", "/oauth/introspect", http.StatusNotFound},
+		{"mtls.example.com", "/oauth/introspect", http.StatusOK},
+		{"example.com", "/authorize", http.StatusOK},
+		{"mtls.example.com", "/authorize", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "https://"+tt.host+tt.path, nil)
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestMTLSAliases_discovery(t *testing.T) {
+	aliases := &MTLSAliases{
+		Host:       "mtls.example.com",
+		Token:      MTLSAliasShared,
+		Revocation: MTLSAliasExclusive,
+	}
+	got := aliases.discovery("https://example.com/oidc", DefaultEndpoints)
+	assert.Equal(t, &oidc.MTLSEndpointAliases{
+		TokenEndpoint:      "https://mtls.example.com/oidc/oauth/token",
+		RevocationEndpoint: "https://mtls.example.com/oidc/revoke",
+	}, got)
+	assert.Nil(t, discoveryMTLSAliases(struct{}{}, "https://example.com", DefaultEndpoints))
+}
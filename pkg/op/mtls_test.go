@@ -0,0 +1,231 @@
+package op
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type tlsClient struct {
+	Client
+	authMethod oidc.AuthMethod
+	subject    TLSClientAuthSubject
+	keys       []jose.JSONWebKey
+}
+
+func (c *tlsClient) GetID() string                              { return "client1" }
+func (c *tlsClient) AuthMethod() oidc.AuthMethod                { return c.authMethod }
+func (c *tlsClient) TLSClientAuthSubject() TLSClientAuthSubject { return c.subject }
+func (c *tlsClient) TLSClientKeys(context.Context) ([]jose.JSONWebKey, error) {
+	return c.keys, nil
+}
+
+func testCertificate(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client1", Organization: []string{"Example"}},
+		DNSNames:     []string{"client1.example.com"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestAuthorizeTLSClient(t *testing.T) {
+	cert, key := testCertificate(t)
+	_, otherKey := testCertificate(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	config := &MTLSConfig{TLSClientAuth: true, SelfSignedTLSClientAuth: true}
+
+	tests := []struct {
+		name     string
+		client   *tlsClient
+		config   *MTLSConfig
+		cert     *x509.Certificate
+		verified bool
+		wantErr  bool
+	}{
+		{
+			name:     "subject dn",
+			client:   &tlsClient{authMethod: oidc.AuthMethodTLSClientAuth, subject: TLSClientAuthSubject{SubjectDN: "cn=client1,o=example"}},
+			config:   config,
+			cert:     cert,
+			verified: true,
+		},
+		{
+			name:    "not verified",
+			client:  &tlsClient{authMethod: oidc.AuthMethodTLSClientAuth, subject: TLSClientAuthSubject{SubjectDN: "cn=client1,o=example"}},
+			config:  config,
+			cert:    cert,
+			wantErr: true,
+		},
+		{
+			name:   "san dns",
+			client: &tlsClient{authMethod: oidc.AuthMethodTLSClientAuth, subject: TLSClientAuthSubject{SANDNS: "client1.example.com"}},
+			config: &MTLSConfig{TLSClientAuth: true, ClientCAs: roots},
+			cert:   cert,
+		},
+		{
+			name:     "other subject",
+			client:   &tlsClient{authMethod: oidc.AuthMethodTLSClientAuth, subject: TLSClientAuthSubject{SANDNS: "client2.example.com"}},
+			config:   config,
+			cert:     cert,
+			verified: true,
+			wantErr:  true,
+		},
+		{
+			name:    "untrusted",
+			client:  &tlsClient{authMethod: oidc.AuthMethodTLSClientAuth, subject: TLSClientAuthSubject{SANDNS: "client1.example.com"}},
+			config:  &MTLSConfig{TLSClientAuth: true, ClientCAs: x509.NewCertPool()},
+			cert:    cert,
+			wantErr: true,
+		},
+		{
+			name:   "self signed",
+			client: &tlsClient{authMethod: oidc.AuthMethodSelfSignedTLSClientAuth, keys: []jose.JSONWebKey{{Key: otherKey.Public()}, {Key: key.Public()}}},
+			config: config,
+			cert:   cert,
+		},
+		{
+			name:    "self signed other key",
+			client:  &tlsClient{authMethod: oidc.AuthMethodSelfSignedTLSClientAuth, keys: []jose.JSONWebKey{{Key: otherKey.Public()}}},
+			config:  config,
+			cert:    cert,
+			wantErr: true,
+		},
+		{
+			name:    "missing certificate",
+			client:  &tlsClient{authMethod: oidc.AuthMethodSelfSignedTLSClientAuth, keys: []jose.JSONWebKey{{Key: key.Public()}}},
+			config:  config,
+			wantErr: true,
+		},
+		{
+			name:    "method disabled",
+			client:  &tlsClient{authMethod: oidc.AuthMethodSelfSignedTLSClientAuth, keys: []jose.JSONWebKey{{Key: key.Public()}}},
+			config:  &MTLSConfig{TLSClientAuth: true},
+			cert:    cert,
+			wantErr: true,
+		},
+		{
+			name:    "not enabled",
+			client:  &tlsClient{authMethod: oidc.AuthMethodTLSClientAuth, subject: TLSClientAuthSubject{SANDNS: "client1.example.com"}},
+			cert:    cert,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.cert != nil {
+				ctx = context.WithValue(ctx, clientCertificateKey{}, tt.cert)
+			}
+			if tt.verified {
+				ctx = context.WithValue(ctx, verifiedCertificateKey{}, true)
+			}
+			err := AuthorizeTLSClient(ctx, tt.client, tt.config)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidClient())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMTLSConfig_validate(t *testing.T) {
+	assert.NoError(t, (&MTLSConfig{TLSClientAuth: true}).validate())
+	assert.NoError(t, (&MTLSConfig{TLSClientAuth: true, ClientCAs: x509.NewCertPool(), ClientCertificate: ForwardedClientCertificate("Client-Cert")}).validate())
+	assert.NoError(t, (&MTLSConfig{SelfSignedTLSClientAuth: true, ClientCertificate: ForwardedClientCertificate("Client-Cert")}).validate())
+	assert.Error(t, (&MTLSConfig{TLSClientAuth: true, ClientCertificate: ForwardedClientCertificate("Client-Cert")}).validate())
+}
+
+func TestForwardedClientCertificate(t *testing.T) {
+	cert, _ := testCertificate(t)
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	tests := []struct {
+		name    string
+		value   string
+		want    *x509.Certificate
+		wantErr bool
+	}{
+		{"none", "", nil, false},
+		{"byte sequence", ":" + base64.StdEncoding.EncodeToString(cert.Raw) + ":", cert, false},
+		{"escaped pem", url.QueryEscape(string(pemCert)), cert, false},
+		{"invalid", "foo", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/oauth/token", nil)
+			if tt.value != "" {
+				r.Header.Set("Client-Cert", tt.value)
+			}
+			got, err := ForwardedClientCertificate("Client-Cert")(r)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_mtlsHandler(t *testing.T) {
+	cert, _ := testCertificate(t)
+	writeError := func(w http.ResponseWriter, r *http.Request, err error) { RequestError(w, r, err, slog.Default()) }
+	var (
+		cnf      *oidc.Confirmation
+		verified bool
+	)
+	handler := mtlsHandler(&MTLSConfig{BoundAccessTokens: true}, writeError, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, cert, ClientCertificateFromContext(r.Context()))
+		cnf = tokenConfirmation(r.Context())
+		verified, _ = r.Context().Value(verifiedCertificateKey{}).(bool)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	handler(httptest.NewRecorder(), r)
+	assert.False(t, verified)
+	r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	handler(httptest.NewRecorder(), r)
+	assert.True(t, verified)
+	require.NotNil(t, cnf)
+	assert.Equal(t, oidc.CertificateThumbprint(cert), cnf.CertificateThumbprint)
+	assert.Empty(t, cnf.JWKThumbprint)
+
+	r = httptest.NewRequest(http.MethodPost, "/oauth/token", nil)
+	handler = mtlsHandler(&MTLSConfig{BoundAccessTokens: true}, writeError, func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, ClientCertificateFromContext(r.Context()))
+		assert.Nil(t, tokenConfirmation(r.Context()))
+	})
+	handler(httptest.NewRecorder(), r)
+}
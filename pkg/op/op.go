@@ -3,33 +3,44 @@ package op
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	jose "github.com/go-jose/go-jose/v4"
 	"github.com/rs/cors"
 	"github.com/zitadel/schema"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/text/language"
 
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
 
 const (
-	healthEndpoint               = "/healthz"
-	readinessEndpoint            = "/ready"
-	authCallbackPathSuffix       = "/callback"
-	defaultAuthorizationEndpoint = "authorize"
-	defaultTokenEndpoint         = "oauth/token"
-	defaultIntrospectEndpoint    = "oauth/introspect"
-	defaultUserinfoEndpoint      = "userinfo"
-	defaultRevocationEndpoint    = "revoke"
-	defaultEndSessionEndpoint    = "end_session"
-	defaultKeysEndpoint          = "keys"
-	defaultDeviceAuthzEndpoint   = "/device_authorization"
+	healthEndpoint                 = "/healthz"
+	readinessEndpoint              = "/ready"
+	authCallbackPathSuffix         = "/callback"
+	defaultAuthorizationEndpoint   = "authorize"
+	defaultTokenEndpoint           = "oauth/token"
+	defaultIntrospectEndpoint      = "oauth/introspect"
+	defaultUserinfoEndpoint        = "userinfo"
+	defaultRevocationEndpoint      = "revoke"
+	defaultEndSessionEndpoint      = "end_session"
+	defaultKeysEndpoint            = "keys"
+	defaultDeviceAuthzEndpoint     = "/device_authorization"
+	defaultPushedAuthzEndpoint     = "/par"
+	defaultCIBAEndpoint            = "/bc-authorize"
+	defaultRegistrationEndpoint    = "/register"
+	defaultSessionTransferEndpoint = "/session_transfer"
+	defaultConsentReceiptsEndpoint = "/consent_receipts"
 )
 
 var (
@@ -42,6 +53,12 @@ var (
 		EndSession:          NewEndpoint(defaultEndSessionEndpoint),
 		JwksURI:             NewEndpoint(defaultKeysEndpoint),
 		DeviceAuthorization: NewEndpoint(defaultDeviceAuthzEndpoint),
+		PushedAuthorization: NewEndpoint(defaultPushedAuthzEndpoint),
+		Registration:        NewEndpoint(defaultRegistrationEndpoint),
+
+		BackchannelAuthentication: NewEndpoint(defaultCIBAEndpoint),
+		SessionTransfer:           NewEndpoint(defaultSessionTransferEndpoint),
+		ConsentReceipts:           NewEndpoint(defaultConsentReceiptsEndpoint),
 	}
 
 	DefaultSupportedClaims = []string{
@@ -96,8 +113,6 @@ var (
 	}
 )
 
-var tracer = otel.Tracer("github.com/lmindwarel/oidc/pkg/op")
-
 type OpenIDProvider interface {
 	http.Handler
 	Configuration
@@ -123,6 +138,15 @@ type corsOptioner interface {
 
 func CreateRouter(o OpenIDProvider, interceptors ...HttpInterceptor) chi.Router {
 	router := chi.NewRouter()
+	if random := providerRandom(o); random != nil {
+		router.Use(randomHandler(random))
+	}
+	if policy := correlationPolicy(o); policy != nil {
+		router.Use(policy.Handler)
+	}
+	if hooks := providerResponseHooks(o); len(hooks) > 0 {
+		router.Use(hooks.Handler)
+	}
 	if co, ok := o.(corsOptioner); ok {
 		if opts := co.CORSOptions(); opts != nil {
 			router.Use(cors.New(*opts).Handler)
@@ -130,22 +154,92 @@ func CreateRouter(o OpenIDProvider, interceptors ...HttpInterceptor) chi.Router
 	} else {
 		router.Use(cors.New(defaultCORSOptions).Handler)
 	}
+	if so, ok := o.(securityHeadersOptioner); ok {
+		if headers := so.SecurityHeaders(); headers != nil {
+			router.Use(headers.Handler)
+		}
+	} else {
+		router.Use(DefaultSecurityHeaders.Handler)
+	}
+	if mo, ok := o.(mtlsAliasesGetter); ok && mo.MTLSAliases() != nil {
+		router.Use(mo.MTLSAliases().Handler(configEndpoints(o)))
+	}
 	router.Use(intercept(o.IssuerFromRequest, interceptors...))
 	router.HandleFunc(healthEndpoint, healthHandler)
 	router.HandleFunc(readinessEndpoint, readyHandler(o.Probes()))
-	router.HandleFunc(oidc.DiscoveryEndpoint, discoveryHandler(o, o.Storage()))
-	router.HandleFunc(o.AuthorizationEndpoint().Relative(), authorizeHandler(o))
-	router.HandleFunc(authCallbackPath(o), AuthorizeCallbackHandler(o))
-	router.HandleFunc(o.TokenEndpoint().Relative(), tokenHandler(o))
-	router.HandleFunc(o.IntrospectionEndpoint().Relative(), introspectionHandler(o))
-	router.HandleFunc(o.UserinfoEndpoint().Relative(), userinfoHandler(o))
-	router.HandleFunc(o.RevocationEndpoint().Relative(), revocationHandler(o))
-	router.HandleFunc(o.EndSessionEndpoint().Relative(), endSessionHandler(o))
-	router.HandleFunc(o.KeysEndpoint().Relative(), keysHandler(o.Storage()))
-	router.HandleFunc(o.DeviceAuthorizationEndpoint().Relative(), DeviceAuthorizationHandler(o))
+	inst, middleware := providerInstrumentation(o), endpointMiddleware(o)
+	router.HandleFunc(oidc.DiscoveryEndpoint, endpointHandler(inst, middleware, EndpointNameDiscovery, discoveryHandler(o, discoverStorage(o))))
+	router.HandleFunc(o.AuthorizationEndpoint().Relative(), endpointHandler(inst, middleware, EndpointNameAuthorization, authorizeHandler(o)))
+	router.HandleFunc(authCallbackPath(o), endpointHandler(inst, middleware, EndpointNameAuthorizationCallback, AuthorizeCallbackHandler(o)))
+	router.HandleFunc(o.TokenEndpoint().Relative(), endpointHandler(inst, middleware, EndpointNameToken, clientRequestHandler(o, providerDPoPHandler(o, providerTokenHooksHandler(o, tokenHandler(o))))))
+	handleEndpoint(router, o.IntrospectionEndpoint(), endpointHandler(inst, middleware, EndpointNameIntrospection, clientRequestHandler(o, introspectionHandler(o))))
+	handleEndpoint(router, o.UserinfoEndpoint(), endpointHandler(inst, middleware, EndpointNameUserinfo, userinfoHandler(o)))
+	handleEndpoint(router, o.RevocationEndpoint(), endpointHandler(inst, middleware, EndpointNameRevocation, clientRequestHandler(o, revocationHandler(o))))
+	handleEndpoint(router, o.EndSessionEndpoint(), endpointHandler(inst, middleware, EndpointNameEndSession, endSessionHandler(o)))
+	router.HandleFunc(o.KeysEndpoint().Relative(), endpointHandler(inst, middleware, EndpointNameKeys, keysHandler(keyProvider(o))))
+	handleEndpoint(router, o.DeviceAuthorizationEndpoint(), endpointHandler(inst, middleware, EndpointNameDeviceAuthorization, DeviceAuthorizationHandler(o)))
+	if pushedAuthorization(o) != nil {
+		handleEndpoint(router, pushedAuthorizationEndpointOf(o), endpointHandler(inst, middleware, EndpointNamePushedAuthorization, clientRequestHandler(o, PushedAuthorizationHandler(o))))
+	}
+	if backchannelAuthentication(o) != nil {
+		handleEndpoint(router, backchannelAuthenticationEndpointOf(o), endpointHandler(inst, middleware, EndpointNameBackchannelAuthentication, clientRequestHandler(o, BackchannelAuthenticationHandler(o))))
+	}
+	if clientRegistration(o) != nil {
+		handleEndpoint(router, clientRegistrationEndpointOf(o), endpointHandler(inst, middleware, EndpointNameRegistration, ClientRegistrationHandler(o)))
+	}
+	if sessionTransfer(o) != nil {
+		handleEndpoint(router, sessionTransferEndpointOf(o), endpointHandler(inst, middleware, EndpointNameSessionTransfer, clientRequestHandler(o, SessionTransferHandler(o))))
+	}
+	if consentReceipts(o) != nil {
+		handleEndpoint(router, consentReceiptsEndpointOf(o), endpointHandler(inst, middleware, EndpointNameConsentReceipts, ConsentReceiptsHandler(o)))
+	}
 	return router
 }
 
+// clientRequestHandler wraps the handler of an endpoint called by clients,
+// to read the client certificate if enabled with [WithMTLS]
+// and to accept JSON request bodies if enabled with [WithJSONRequestBodies].
+func clientRequestHandler(o OpenIDProvider, handler http.HandlerFunc) http.HandlerFunc {
+	writeError := func(w http.ResponseWriter, r *http.Request, err error) { RequestError(w, r, err, o.Logger()) }
+	handler = mtlsHandler(mtlsConfig(o), writeError, handler)
+	if !jsonRequestBodies(o) {
+		return handler
+	}
+	return jsonBodyHandler(
+		func(*http.Request) *slog.Logger { return o.Logger() },
+		writeError,
+		handler,
+	)
+}
+
+// providerDPoPHandler wraps the token handler to verify DPoP proofs,
+// if enabled with [WithDPoP].
+func providerDPoPHandler(o OpenIDProvider, handler http.HandlerFunc) http.HandlerFunc {
+	if !dpopEnabled(o) {
+		return handler
+	}
+	return dpopHandler(o.TokenEndpoint(), replayCache(o),
+		func(w http.ResponseWriter, r *http.Request, err error) { RequestError(w, r, err, o.Logger()) },
+		handler,
+	)
+}
+
+// providerTokenHooksHandler wraps the token handler to call the hooks
+// added with [WithTokenHooks].
+func providerTokenHooksHandler(o OpenIDProvider, handler http.HandlerFunc) http.HandlerFunc {
+	return tokenHooksHandler(providerTokenHooks(o), o.Decoder,
+		func(w http.ResponseWriter, r *http.Request, err error) { RequestError(w, r, err, o.Logger()) },
+		handler,
+	)
+}
+
+// handleEndpoint registers the handler, unless the endpoint is nil and therefore disabled.
+func handleEndpoint(router chi.Router, endpoint *Endpoint, handler http.HandlerFunc) {
+	if endpoint != nil {
+		router.HandleFunc(endpoint.Relative(), handler)
+	}
+}
+
 // AuthCallbackURL builds the url for the redirect (with the requestID) after a successful login
 func AuthCallbackURL(o OpenIDProvider) func(context.Context, string) string {
 	return func(ctx context.Context, requestID string) string {
@@ -171,6 +265,12 @@ type Config struct {
 	DeviceAuthorization               DeviceAuthorizationConfig
 	BackChannelLogoutSupported        bool
 	BackChannelLogoutSessionSupported bool
+	// JWTAuthorizationResponseSupported enables the JWT response modes (JARM)
+	// for clients implementing [HasJWTAuthorizationResponse].
+	JWTAuthorizationResponseSupported bool
+	// ClientSigningAlgorithms are the JWS algorithms supported
+	// for client assertions and request objects. Defaults to RS256.
+	ClientSigningAlgorithms []string
 }
 
 // Endpoints defines endpoint routes.
@@ -184,6 +284,21 @@ type Endpoints struct {
 	CheckSessionIframe  *Endpoint
 	JwksURI             *Endpoint
 	DeviceAuthorization *Endpoint
+	// PushedAuthorization is only served if enabled
+	// with [WithPushedAuthorizationRequests].
+	PushedAuthorization *Endpoint
+	// BackchannelAuthentication is only served if enabled
+	// with [WithBackchannelAuthentication].
+	BackchannelAuthentication *Endpoint
+	// Registration is only served if enabled
+	// with [WithClientRegistration].
+	Registration *Endpoint
+	// SessionTransfer is only served if enabled
+	// with [WithSessionTransfer].
+	SessionTransfer *Endpoint
+	// ConsentReceipts is only served if enabled
+	// with [WithConsentReceipts].
+	ConsentReceipts *Endpoint
 }
 
 // NewOpenIDProvider creates a provider. The provider provides (with HttpHandler())
@@ -201,6 +316,11 @@ type Endpoints struct {
 //	/end_session
 //	/keys
 //	/device_authorization
+//	/par (with WithPushedAuthorizationRequests)
+//	/bc-authorize (with WithBackchannelAuthentication)
+//	/register (with WithClientRegistration)
+//	/session_transfer (with WithSessionTransfer)
+//	/consent_receipts (with WithConsentReceipts)
 //
 // This does not include login. Login is handled with a redirect that includes the
 // request ID. The redirect for logins is specified per-client by Client.LoginURL().
@@ -246,6 +366,11 @@ func NewForwardedOpenIDProvider(path string, config *Config, storage Storage, op
 //	/end_session
 //	/keys
 //	/device_authorization
+//	/par (with WithPushedAuthorizationRequests)
+//	/bc-authorize (with WithBackchannelAuthentication)
+//	/register (with WithClientRegistration)
+//	/session_transfer (with WithSessionTransfer)
+//	/consent_receipts (with WithConsentReceipts)
 //
 // This does not include login. Login is handled with a redirect that includes the
 // request ID. The redirect for logins is specified per-client by Client.LoginURL().
@@ -253,17 +378,18 @@ func NewForwardedOpenIDProvider(path string, config *Config, storage Storage, op
 // op.AuthCallbackURL(provider) which is probably /callback. On the redirect back
 // to the AuthCallbackURL, the request id should be passed as the "id" parameter.
 func NewProvider(config *Config, storage Storage, issuer func(insecure bool) (IssuerFromRequest, error), opOpts ...Option) (_ *Provider, err error) {
-	keySet := &OpenIDKeySet{storage}
 	o := &Provider{
-		config:            config,
-		storage:           storage,
-		accessTokenKeySet: keySet,
-		idTokenHinKeySet:  keySet,
-		endpoints:         DefaultEndpoints,
-		timer:             make(<-chan time.Time),
-		corsOpts:          &defaultCORSOptions,
-		logger:            slog.Default(),
+		storage:         storage,
+		endpoints:       DefaultEndpoints,
+		timer:           make(<-chan time.Time),
+		corsOpts:        &defaultCORSOptions,
+		securityHeaders: &DefaultSecurityHeaders,
+		logger:          slog.Default(),
+		fips:            crypto.DefaultFIPS(),
 	}
+	keySet := &providerKeySet{o}
+	o.accessTokenKeySet = keySet
+	o.idTokenHinKeySet = keySet
 
 	for _, optFunc := range opOpts {
 		if err := optFunc(o); err != nil {
@@ -275,7 +401,24 @@ func NewProvider(config *Config, storage Storage, issuer func(insecure bool) (Is
 	if err != nil {
 		return nil, err
 	}
-	o.Handler = CreateRouter(o, o.interceptors...)
+	if err = o.checkFIPS(config); err != nil {
+		return nil, err
+	}
+	if o.federatedTokenExchange != nil && o.clientJWKS == nil {
+		return nil, ErrFederatedClientJWKSCache
+	}
+	if o.correlation != nil {
+		o.logger = correlationLogger(o.logger)
+	}
+	if o.tracerProvider != nil || o.meterProvider != nil {
+		o.instrumentation, err = NewInstrumentation(o.tracerProvider, o.meterProvider)
+		if err != nil {
+			return nil, err
+		}
+	}
+	o.state.Store(&providerState{config: config, endpoints: o.endpoints})
+	o.router.Store(CreateRouter(o, o.interceptors...))
+	o.Handler = http.HandlerFunc(o.serveRouter)
 	o.decoder = schema.NewDecoder()
 	o.decoder.IgnoreUnknownKeys(true)
 	o.encoder = oidc.NewEncoder()
@@ -285,7 +428,6 @@ func NewProvider(config *Config, storage Storage, issuer func(insecure bool) (Is
 
 type Provider struct {
 	http.Handler
-	config                  *Config
 	issuer                  IssuerFromRequest
 	insecure                bool
 	endpoints               *Endpoints
@@ -300,7 +442,64 @@ type Provider struct {
 	accessTokenVerifierOpts []AccessTokenVerifierOpt
 	idTokenHintVerifierOpts []IDTokenHintVerifierOpt
 	corsOpts                *cors.Options
+	securityHeaders         *SecurityHeaders
+	publicClientPolicy      *PublicClientPolicy
+	sessionPolicy           *SessionPolicy
+	sessionSelector         SessionSelector
+	endpointMiddleware      EndpointMiddleware
+	correlation             *CorrelationPolicy
+	responseHooks           []ResponseHook
+	tokenHooks              *TokenEndpointHooks
+	random                  io.Reader
+	fips                    *crypto.FIPS
+	mldsa                   bool
+	refreshIDTokenPolicy    *RefreshIDTokenPolicy
+	refreshTokenGrant       bool
+	deprecationPolicy       *DeprecationPolicy
+	legacyUsage             LegacyUsage
+	mtlsAliases             *MTLSAliases
+	userinfoFromToken       userinfoFunc
+	introspectionFromToken  introspectionFunc
+	serviceTokenSubject     ServiceTokenSubject
+	replayCache             cache.Cache
+	jsonRequestBodies       bool
+	scopeArrayClaim         bool
+	audienceSplitting       bool
+	customClaims            []string
+	dpop                    bool
+	groupClaimsPolicy       *GroupClaimsPolicy
+	pushedAuthorization     *PushedAuthorizationConfig
+	backchannelAuthn        *BackchannelAuthenticationConfig
+	clientRegistration      *ClientRegistrationConfig
+	sessionTransfer         *SessionTransferConfig
+	consentReceipts         *ConsentReceiptConfig
+	tenantKeys              *TenantKeysConfig
+	mtls                    *MTLSConfig
+	authorizationDetails    *oidc.AuthorizationDetailTypes
+	federatedTokenExchange  *FederatedTokenExchangeConfig
+	tokenExchangePolicy     *TokenExchangePolicy
+	signedMetadata          *SignedMetadataConfig
+	spiffe                  *SPIFFEConfig
+	tokenExchangeVerifiers  map[oidc.TokenType]TokenExchangeTokenVerifier
+	tracerProvider          trace.TracerProvider
+	meterProvider           metric.MeterProvider
+	instrumentation         *Instrumentation
+	tokenSizePolicy         *TokenSizePolicy
+	authErrorPolicy         AuthErrorPolicy
+	authErrorRenderer       AuthErrorRenderer
+	clientJWKS              *ClientJWKSCache
+	idTokenHeader           *TokenHeader
+	accessTokenHeader       *TokenHeader
+	backChannelLogoutClient *http.Client
+	backChannelLogoutQueue  *SoftFailQueue
+	flowLifetimes           *FlowLifetimes
 	logger                  *slog.Logger
+
+	// endpoints are set by the options, state and router
+	// are swapped at runtime, see reload.go.
+	reloadMu sync.Mutex
+	state    atomic.Pointer[providerState]
+	router   atomic.Value
 }
 
 func (o *Provider) IssuerFromRequest(r *http.Request) string {
@@ -312,59 +511,59 @@ func (o *Provider) Insecure() bool {
 }
 
 func (o *Provider) AuthorizationEndpoint() *Endpoint {
-	return o.endpoints.Authorization
+	return o.currentEndpoints().Authorization
 }
 
 func (o *Provider) TokenEndpoint() *Endpoint {
-	return o.endpoints.Token
+	return o.currentEndpoints().Token
 }
 
 func (o *Provider) IntrospectionEndpoint() *Endpoint {
-	return o.endpoints.Introspection
+	return o.currentEndpoints().Introspection
 }
 
 func (o *Provider) UserinfoEndpoint() *Endpoint {
-	return o.endpoints.Userinfo
+	return o.currentEndpoints().Userinfo
 }
 
 func (o *Provider) RevocationEndpoint() *Endpoint {
-	return o.endpoints.Revocation
+	return o.currentEndpoints().Revocation
 }
 
 func (o *Provider) EndSessionEndpoint() *Endpoint {
-	return o.endpoints.EndSession
+	return o.currentEndpoints().EndSession
 }
 
 func (o *Provider) DeviceAuthorizationEndpoint() *Endpoint {
-	return o.endpoints.DeviceAuthorization
+	return o.currentEndpoints().DeviceAuthorization
 }
 
 func (o *Provider) CheckSessionIframe() *Endpoint {
-	return o.endpoints.CheckSessionIframe
+	return o.currentEndpoints().CheckSessionIframe
 }
 
 func (o *Provider) KeysEndpoint() *Endpoint {
-	return o.endpoints.JwksURI
+	return o.currentEndpoints().JwksURI
 }
 
 func (o *Provider) AuthMethodPostSupported() bool {
-	return o.config.AuthMethodPost
+	return o.currentConfig().AuthMethodPost
 }
 
 func (o *Provider) CodeMethodS256Supported() bool {
-	return o.config.CodeMethodS256
+	return o.currentConfig().CodeMethodS256
 }
 
 func (o *Provider) AuthMethodPrivateKeyJWTSupported() bool {
-	return o.config.AuthMethodPrivateKeyJWT
+	return o.currentConfig().AuthMethodPrivateKeyJWT
 }
 
 func (o *Provider) TokenEndpointSigningAlgorithmsSupported() []string {
-	return []string{"RS256"}
+	return o.clientSigningAlgorithms()
 }
 
 func (o *Provider) GrantTypeRefreshTokenSupported() bool {
-	return o.config.GrantTypeRefreshToken
+	return o.currentConfig().GrantTypeRefreshToken
 }
 
 func (o *Provider) GrantTypeTokenExchangeSupported() bool {
@@ -386,7 +585,7 @@ func (o *Provider) IntrospectionAuthMethodPrivateKeyJWTSupported() bool {
 }
 
 func (o *Provider) IntrospectionEndpointSigningAlgorithmsSupported() []string {
-	return []string{"RS256"}
+	return o.clientSigningAlgorithms()
 }
 
 func (o *Provider) GrantTypeClientCredentialsSupported() bool {
@@ -399,31 +598,35 @@ func (o *Provider) RevocationAuthMethodPrivateKeyJWTSupported() bool {
 }
 
 func (o *Provider) RevocationEndpointSigningAlgorithmsSupported() []string {
-	return []string{"RS256"}
+	return o.clientSigningAlgorithms()
 }
 
 func (o *Provider) RequestObjectSupported() bool {
-	return o.config.RequestObjectSupported
+	return o.currentConfig().RequestObjectSupported
 }
 
 func (o *Provider) RequestObjectSigningAlgorithmsSupported() []string {
-	return []string{"RS256"}
+	return o.clientSigningAlgorithms()
 }
 
 func (o *Provider) SupportedUILocales() []language.Tag {
-	return o.config.SupportedUILocales
+	return o.currentConfig().SupportedUILocales
 }
 
 func (o *Provider) DeviceAuthorization() DeviceAuthorizationConfig {
-	return o.config.DeviceAuthorization
+	return o.currentConfig().DeviceAuthorization
 }
 
 func (o *Provider) BackChannelLogoutSupported() bool {
-	return o.config.BackChannelLogoutSupported
+	return o.currentConfig().BackChannelLogoutSupported
+}
+
+func (o *Provider) JWTAuthorizationResponseSupported() bool {
+	return o.currentConfig().JWTAuthorizationResponseSupported
 }
 
 func (o *Provider) BackChannelLogoutSessionSupported() bool {
-	return o.config.BackChannelLogoutSessionSupported
+	return o.currentConfig().BackChannelLogoutSessionSupported
 }
 
 func (o *Provider) Storage() Storage {
@@ -439,15 +642,23 @@ func (o *Provider) Encoder() httphelper.Encoder {
 }
 
 func (o *Provider) IDTokenHintVerifier(ctx context.Context) *IDTokenHintVerifier {
-	return NewIDTokenHintVerifier(IssuerFromContext(ctx), o.idTokenHinKeySet, o.idTokenHintVerifierOpts...)
+	verifier := NewIDTokenHintVerifier(IssuerFromContext(ctx), o.idTokenHinKeySet, o.idTokenHintVerifierOpts...)
+	verifier.AllowInsecure = o.insecure
+	verifier.SupportedSignAlgs = mldsaVerifierAlgorithms(o.mldsa, verifier.SupportedSignAlgs)
+	return verifier
 }
 
 func (o *Provider) JWTProfileVerifier(ctx context.Context) *JWTProfileVerifier {
-	return NewJWTProfileVerifier(o.Storage(), IssuerFromContext(ctx), 1*time.Hour, time.Second)
+	verifier := NewJWTProfileVerifier(o.Storage(), IssuerFromContext(ctx), 1*time.Hour, time.Second, ClientJWKS(o.clientJWKS))
+	verifier.AllowInsecure = o.insecure
+	return verifier
 }
 
 func (o *Provider) AccessTokenVerifier(ctx context.Context) *AccessTokenVerifier {
-	return NewAccessTokenVerifier(IssuerFromContext(ctx), o.accessTokenKeySet, o.accessTokenVerifierOpts...)
+	verifier := NewAccessTokenVerifier(IssuerFromContext(ctx), o.accessTokenKeySet, o.accessTokenVerifierOpts...)
+	verifier.AllowInsecure = o.insecure
+	verifier.SupportedSignAlgs = mldsaVerifierAlgorithms(o.mldsa, verifier.SupportedSignAlgs)
+	return verifier
 }
 
 func (o *Provider) Crypto() Crypto {
@@ -455,7 +666,7 @@ func (o *Provider) Crypto() Crypto {
 }
 
 func (o *Provider) DefaultLogoutRedirectURI() string {
-	return o.config.DefaultLogoutRedirectURI
+	return o.currentConfig().DefaultLogoutRedirectURI
 }
 
 func (o *Provider) Probes() []ProbesFn {
@@ -468,6 +679,224 @@ func (o *Provider) CORSOptions() *cors.Options {
 	return o.corsOpts
 }
 
+func (o *Provider) SecurityHeaders() *SecurityHeaders {
+	return o.securityHeaders
+}
+
+func (o *Provider) PublicClientPolicy() *PublicClientPolicy {
+	return o.publicClientPolicy
+}
+
+func (o *Provider) SessionPolicy() *SessionPolicy {
+	return o.sessionPolicy
+}
+
+func (o *Provider) SessionSelector() SessionSelector {
+	return o.sessionSelector
+}
+
+func (o *Provider) EndpointMiddleware() EndpointMiddleware {
+	return o.endpointMiddleware
+}
+
+func (o *Provider) CorrelationPolicy() *CorrelationPolicy {
+	return o.correlation
+}
+
+func (o *Provider) ResponseHooks() []ResponseHook {
+	return o.responseHooks
+}
+
+func (o *Provider) TokenHooks() *TokenEndpointHooks {
+	return o.tokenHooks
+}
+
+func (o *Provider) Random() io.Reader {
+	return o.random
+}
+
+func (o *Provider) FIPS() *crypto.FIPS {
+	return o.fips
+}
+
+func (o *Provider) ExperimentalMLDSA() bool {
+	return o.mldsa
+}
+
+func (o *Provider) RefreshIDTokenPolicy() *RefreshIDTokenPolicy {
+	return o.refreshIDTokenPolicy
+}
+
+func (o *Provider) RefreshTokenGrantRequired() bool {
+	return o.refreshTokenGrant
+}
+
+func (o *Provider) DeprecationPolicy() *DeprecationPolicy {
+	return o.deprecationPolicy
+}
+
+// LegacyUsage records the clients using
+// legacy behaviors, see [DeprecationPolicy].
+func (o *Provider) LegacyUsage() *LegacyUsage {
+	return &o.legacyUsage
+}
+
+func (o *Provider) MTLSAliases() *MTLSAliases {
+	return o.mtlsAliases
+}
+
+func (o *Provider) userinfoFunc() userinfoFunc {
+	return o.userinfoFromToken
+}
+
+func (o *Provider) introspectionFunc() introspectionFunc {
+	return o.introspectionFromToken
+}
+
+func (o *Provider) ServiceTokenSubject() ServiceTokenSubject {
+	return o.serviceTokenSubject
+}
+
+func (o *Provider) AuthErrorPolicy() AuthErrorPolicy {
+	return o.authErrorPolicy
+}
+
+func (o *Provider) AuthErrorRenderer() AuthErrorRenderer {
+	return o.authErrorRenderer
+}
+
+func (o *Provider) JSONRequestBodies() bool {
+	return o.jsonRequestBodies
+}
+
+func (o *Provider) MTLS() *MTLSConfig {
+	return o.mtls
+}
+
+func (o *Provider) AuthorizationDetailTypes() *oidc.AuthorizationDetailTypes {
+	return o.authorizationDetails
+}
+
+func (o *Provider) DPoP() bool {
+	return o.dpop
+}
+
+func (o *Provider) ScopeArrayClaim() bool {
+	return o.scopeArrayClaim
+}
+
+func (o *Provider) AudienceSplitting() bool {
+	return o.audienceSplitting
+}
+
+func (o *Provider) CustomClaims() []string {
+	return o.customClaims
+}
+
+func (o *Provider) PushedAuthorizationEndpoint() *Endpoint {
+	return o.currentEndpoints().PushedAuthorization
+}
+
+func (o *Provider) PushedAuthorization() *PushedAuthorizationConfig {
+	return o.pushedAuthorization
+}
+
+func (o *Provider) BackchannelAuthenticationEndpoint() *Endpoint {
+	return o.currentEndpoints().BackchannelAuthentication
+}
+
+func (o *Provider) BackchannelAuthentication() *BackchannelAuthenticationConfig {
+	return o.backchannelAuthn
+}
+
+func (o *Provider) ClientRegistrationEndpoint() *Endpoint {
+	return o.currentEndpoints().Registration
+}
+
+func (o *Provider) ClientRegistration() *ClientRegistrationConfig {
+	return o.clientRegistration
+}
+
+func (o *Provider) SessionTransferEndpoint() *Endpoint {
+	return o.currentEndpoints().SessionTransfer
+}
+
+func (o *Provider) SessionTransfer() *SessionTransferConfig {
+	return o.sessionTransfer
+}
+
+func (o *Provider) ConsentReceiptsEndpoint() *Endpoint {
+	return o.currentEndpoints().ConsentReceipts
+}
+
+func (o *Provider) ConsentReceipts() *ConsentReceiptConfig {
+	return o.consentReceipts
+}
+
+func (o *Provider) TenantKeys() *TenantKeysConfig {
+	return o.tenantKeys
+}
+
+func (o *Provider) GroupClaimsPolicy() *GroupClaimsPolicy {
+	return o.groupClaimsPolicy
+}
+
+func (o *Provider) TokenSizePolicy() *TokenSizePolicy {
+	return o.tokenSizePolicy
+}
+
+func (o *Provider) ReplayCache() cache.Cache {
+	return o.replayCache
+}
+
+func (o *Provider) Instrumentation() *Instrumentation {
+	return o.instrumentation
+}
+
+func (o *Provider) FederatedTokenExchange() *FederatedTokenExchangeConfig {
+	return o.federatedTokenExchange
+}
+
+func (o *Provider) SignedMetadata() *SignedMetadataConfig {
+	return o.signedMetadata
+}
+
+func (o *Provider) TokenExchangePolicy() *TokenExchangePolicy {
+	return o.tokenExchangePolicy
+}
+
+func (o *Provider) TokenExchangeTokenVerifiers() map[oidc.TokenType]TokenExchangeTokenVerifier {
+	return o.tokenExchangeVerifiers
+}
+
+func (o *Provider) SPIFFE() *SPIFFEConfig {
+	return o.spiffe
+}
+
+func (o *Provider) ClientJWKSCache() *ClientJWKSCache {
+	return o.clientJWKS
+}
+
+func (o *Provider) IDTokenHeader() *TokenHeader {
+	return o.idTokenHeader
+}
+
+func (o *Provider) AccessTokenHeader() *TokenHeader {
+	return o.accessTokenHeader
+}
+
+func (o *Provider) BackChannelLogoutClient() *http.Client {
+	return o.backChannelLogoutClient
+}
+
+func (o *Provider) BackChannelLogoutQueue() *SoftFailQueue {
+	return o.backChannelLogoutQueue
+}
+
+func (o *Provider) FlowLifetimes() *FlowLifetimes {
+	return o.flowLifetimes
+}
+
 func (o *Provider) Logger() *slog.Logger {
 	return o.logger
 }
@@ -484,22 +913,34 @@ type OpenIDKeySet struct {
 // VerifySignature implements the oidc.KeySet interface
 // providing an implementation for the keys stored in the OP Storage interface
 func (o *OpenIDKeySet) VerifySignature(ctx context.Context, jws *jose.JSONWebSignature) ([]byte, error) {
-	keySet, err := o.Storage.KeySet(ctx)
+	return verifySignature(ctx, o.Storage, jws)
+}
+
+func verifySignature(ctx context.Context, keys KeyProvider, jws *jose.JSONWebSignature) ([]byte, error) {
+	keySet, err := keys.KeySet(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching keys: %w", err)
 	}
 	keyID, alg := oidc.GetKeyIDAndAlg(jws)
+	explanation := oidc.ExplanationFromContext(ctx)
 	key, err := oidc.FindMatchingKey(keyID, oidc.KeyUseSignature, alg, jsonWebKeySet(keySet).Keys...)
 	if err != nil {
+		explanation.Check("key", err, "kid", keyID, "alg", alg)
 		return nil, fmt.Errorf("invalid signature: %w", err)
 	}
-	return jws.Verify(&key)
+	payload, err := jws.Verify(&key)
+	explanation.Check("key", err, "kid", key.KeyID, "alg", key.Algorithm)
+	return payload, err
 }
 
 type Option func(o *Provider) error
 
 // WithAllowInsecure allows the use of http (instead of https) for issuers
-// this is not recommended for production use and violates the OIDC specification
+// this is not recommended for production use and violates the OIDC specification.
+//
+// It is the single switch of the development mode: it also enables
+// [IssuerTemplate] and lets the verifiers and the request object validation
+// match loopback issuers with [oidc.MatchIssuer].
 func WithAllowInsecure() Option {
 	return func(o *Provider) error {
 		o.insecure = true
@@ -587,6 +1028,143 @@ func WithCustomDeviceAuthorizationEndpoint(endpoint *Endpoint) Option {
 	}
 }
 
+func WithCustomPushedAuthorizationEndpoint(endpoint *Endpoint) Option {
+	return func(o *Provider) error {
+		if err := endpoint.Validate(); err != nil {
+			return err
+		}
+		o.endpoints.PushedAuthorization = endpoint
+		return nil
+	}
+}
+
+// WithPushedAuthorizationRequests serves the Pushed Authorization Request endpoint (RFC 9126),
+// where clients push their auth requests before redirecting to the authorization endpoint
+// with the returned request_uri. The Storage must implement [PushedAuthRequestStorage].
+func WithPushedAuthorizationRequests(config PushedAuthorizationConfig) Option {
+	return func(o *Provider) error {
+		o.pushedAuthorization = &config
+		return nil
+	}
+}
+
+func WithCustomBackchannelAuthenticationEndpoint(endpoint *Endpoint) Option {
+	return func(o *Provider) error {
+		if err := endpoint.Validate(); err != nil {
+			return err
+		}
+		o.endpoints.BackchannelAuthentication = endpoint
+		return nil
+	}
+}
+
+// WithBackchannelAuthentication serves the Backchannel Authentication endpoint of the
+// Client-Initiated Backchannel Authentication Flow (CIBA) and enables the
+// urn:openid:params:grant-type:ciba grant at the token endpoint, in the poll and ping modes.
+// The Storage must implement [BackchannelAuthenticationStorage].
+func WithBackchannelAuthentication(config BackchannelAuthenticationConfig) Option {
+	return func(o *Provider) error {
+		o.backchannelAuthn = &config
+		return nil
+	}
+}
+
+func WithCustomClientRegistrationEndpoint(endpoint *Endpoint) Option {
+	return func(o *Provider) error {
+		if err := endpoint.Validate(); err != nil {
+			return err
+		}
+		o.endpoints.Registration = endpoint
+		return nil
+	}
+}
+
+// WithClientRegistration serves the Dynamic Client Registration endpoint (RFC 7591),
+// where clients register themselves, and manage their registration with the issued
+// registration access token (RFC 7592). The Storage must implement [ClientRegistrar].
+// Registration requires an initial access token, unless OpenRegistration is set.
+func WithClientRegistration(config ClientRegistrationConfig) Option {
+	return func(o *Provider) error {
+		if err := config.validate(); err != nil {
+			return err
+		}
+		o.clientRegistration = &config
+		return nil
+	}
+}
+
+func WithCustomSessionTransferEndpoint(endpoint *Endpoint) Option {
+	return func(o *Provider) error {
+		if err := endpoint.Validate(); err != nil {
+			return err
+		}
+		o.endpoints.SessionTransfer = endpoint
+		return nil
+	}
+}
+
+// WithSessionTransfer serves the session transfer endpoint, where the first-party clients
+// of the config obtain one-time transfer tokens of the sessions of their users, and enables
+// the session transfer grant at the token endpoint, where their native apps redeem them
+// with PKCE for tokens of their own, so users continue in the app without logging in again.
+// Redeemed transfer tokens are recorded in the cache of [WithReplayCache], if set,
+// which must be shared by all instances of the Provider.
+func WithSessionTransfer(config SessionTransferConfig) Option {
+	return func(o *Provider) error {
+		if len(config.Clients) == 0 {
+			return ErrSessionTransferClients
+		}
+		config.redeemed = cache.NewMemory()
+		o.sessionTransfer = &config
+		return nil
+	}
+}
+
+func WithCustomConsentReceiptsEndpoint(endpoint *Endpoint) Option {
+	return func(o *Provider) error {
+		if err := endpoint.Validate(); err != nil {
+			return err
+		}
+		o.endpoints.ConsentReceipts = endpoint
+		return nil
+	}
+}
+
+// WithConsentReceipts records a receipt of each authorization granted by a user
+// at the authorization endpoint in the storage of the config, and serves the
+// consent receipts endpoint, where users export their receipts, signed by the
+// Provider, with one of their access tokens, for transparency about what they
+// granted, when and to which client. The access token requires the
+// [oidc.ScopeConsentReceipts] scope, which the clients must allow.
+func WithConsentReceipts(config ConsentReceiptConfig) Option {
+	return func(o *Provider) error {
+		if config.Storage == nil {
+			return ErrConsentReceiptStorage
+		}
+		o.consentReceipts = &config
+		return nil
+	}
+}
+
+// WithTenantKeys isolates the keys of the tenants of a multi-issuer Provider,
+// such as with [IssuerFromHost], identified by the issuer of the request:
+// the tokens of each tenant are signed by its own key and its keys endpoint
+// only serves its own public keys, which are cached per tenant. The keys of a
+// tenant are rotated with [Provider.SetTenantSigningKeys] or in the storage,
+// followed by [Provider.RefreshTenantKeys], without affecting the other tenants.
+// The keys of a single tenant are exposed by [Provider.TenantKeyProvider].
+// It takes precedence over [Provider.SetSigningKeys].
+func WithTenantKeys(config TenantKeysConfig) Option {
+	return func(o *Provider) error {
+		if config.Storage == nil {
+			config.Storage = storageTenantKeys{o.storage}
+		}
+		config.tenants = newTenantKeyCache(config.MaxTenants)
+		o.tenantKeys = &config
+		return nil
+	}
+}
+
 // WithCustomEndpoints sets multiple endpoints at once.
 // Non of the endpoints may be nil, or an error will
 // be returned when the Option used by the Provider.
@@ -653,6 +1231,385 @@ func WithCORSOptions(opts *cors.Options) Option {
 	}
 }
 
+// WithSecurityHeaders sets the security headers policy for all
+// responses of the Provider. Defaults to [DefaultSecurityHeaders].
+// Passing nil disables the security headers.
+func WithSecurityHeaders(headers *SecurityHeaders) Option {
+	return func(o *Provider) error {
+		o.securityHeaders = headers
+		return nil
+	}
+}
+
+// WithPublicClientPolicy sets the policy enforced for public clients.
+// Defaults to [DefaultPublicClientPolicy].
+func WithPublicClientPolicy(policy PublicClientPolicy) Option {
+	return func(o *Provider) error {
+		o.publicClientPolicy = &policy
+		return nil
+	}
+}
+
+// WithSessionPolicy sets the policy for reusing the sessions of a [SessionStorage].
+// Defaults to [DefaultSessionPolicy].
+func WithSessionPolicy(policy SessionPolicy) Option {
+	return func(o *Provider) error {
+		o.sessionPolicy = &policy
+		return nil
+	}
+}
+
+// WithSessionSelector sets the selector, which chooses the session of auth requests
+// with prompt=select_account from the active sessions of a [MultiSessionStorage]
+// or [SessionStorage]. Without, these requests are redirected to the Login UI.
+func WithSessionSelector(selector SessionSelector) Option {
+	return func(o *Provider) error {
+		o.sessionSelector = selector
+		return nil
+	}
+}
+
+// WithDeprecationPolicy disables legacy behaviors of the Provider.
+// Defaults to [DefaultDeprecationPolicy], which keeps all of them.
+func WithDeprecationPolicy(policy DeprecationPolicy) Option {
+	return func(o *Provider) error {
+		o.deprecationPolicy = &policy
+		return nil
+	}
+}
+
+// WithRefreshIDTokenPolicy sets the policy for ID tokens issued on refresh_token grants.
+// Defaults to [DefaultRefreshIDTokenPolicy].
+func WithRefreshIDTokenPolicy(policy RefreshIDTokenPolicy) Option {
+	return func(o *Provider) error {
+		o.refreshIDTokenPolicy = &policy
+		return nil
+	}
+}
+
+// WithRefreshTokenGrantRequired rejects refresh token requests, whose
+// RefreshTokenRequest does not implement [GrantedRefreshTokenRequest],
+// with [ErrRefreshTokenGrantNotRecorded], instead of validating the scopes
+// against the current scopes reported by the Storage.
+func WithRefreshTokenGrantRequired() Option {
+	return func(o *Provider) error {
+		o.refreshTokenGrant = true
+		return nil
+	}
+}
+
+// WithMTLSAliases serves the configured endpoints on the mTLS alias host
+// and advertises them as mtls_endpoint_aliases in discovery.
+func WithMTLSAliases(aliases *MTLSAliases) Option {
+	return func(o *Provider) error {
+		o.mtlsAliases = aliases
+		return nil
+	}
+}
+
+// WithMTLS enables the mutual-TLS client authentication methods and the
+// certificate-bound access tokens of RFC 8705, see [MTLSConfig].
+// It fails for tls_client_auth with a ClientCertificate function but no ClientCAs,
+// as the certificates could not be verified.
+func WithMTLS(config MTLSConfig) Option {
+	return func(o *Provider) error {
+		if err := config.validate(); err != nil {
+			return err
+		}
+		o.mtls = &config
+		return nil
+	}
+}
+
+// WithAuthorizationDetailTypes enables the authorization_details of Rich Authorization
+// Requests (RFC 9396) of the registered types, see [AuthorizationDetailsRequest].
+func WithAuthorizationDetailTypes(types *oidc.AuthorizationDetailTypes) Option {
+	return func(o *Provider) error {
+		o.authorizationDetails = types
+		return nil
+	}
+}
+
+// WithFederatedTokenExchange accepts the tokens of trusted external issuers
+// as subject_token of the token exchange grant, see [FederatedTokenExchangeConfig].
+// The keys of the issuers are fetched by the cache of [WithClientJWKSCache], which is required:
+// [NewProvider] fails with [ErrFederatedClientJWKSCache] without it.
+func WithFederatedTokenExchange(config FederatedTokenExchangeConfig) Option {
+	return func(o *Provider) error {
+		if config.MapSubject == nil {
+			return ErrFederatedSubjectMapper
+		}
+		o.federatedTokenExchange = &config
+		return nil
+	}
+}
+
+// WithSignedMetadata publishes the discovery configuration additionally
+// as signed JWT in its signed_metadata member, see [SignedMetadataConfig].
+func WithSignedMetadata(config SignedMetadataConfig) Option {
+	return func(o *Provider) error {
+		o.signedMetadata = &config
+		return nil
+	}
+}
+
+// WithTokenExchangePolicy restricts the audiences, resources and scopes
+// the clients may request with the token exchange grant, see [TokenExchangePolicy].
+// Without, all requests are passed to the [TokenExchangeStorage].
+func WithTokenExchangePolicy(policy TokenExchangePolicy) Option {
+	return func(o *Provider) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		o.tokenExchangePolicy = &policy
+		return nil
+	}
+}
+
+// WithTokenExchangeTokenVerifier verifies the subject and actor tokens of the
+// token exchange grant of the tokenType with verifier, instead of the built-in
+// verification of access, refresh and ID tokens issued by the Provider.
+// Token types without built-in verification, such as [oidc.SAML2TokenType],
+// are accepted once a verifier is set.
+func WithTokenExchangeTokenVerifier(tokenType oidc.TokenType, verifier TokenExchangeTokenVerifier) Option {
+	return func(o *Provider) error {
+		if o.tokenExchangeVerifiers == nil {
+			o.tokenExchangeVerifiers = make(map[oidc.TokenType]TokenExchangeTokenVerifier)
+		}
+		o.tokenExchangeVerifiers[tokenType] = verifier
+		return nil
+	}
+}
+
+// WithSPIFFE accepts the JWT-SVIDs of workloads of the trusted SPIFFE
+// trust domains as client assertions and subject tokens, see [SPIFFEConfig].
+func WithSPIFFE(config SPIFFEConfig) Option {
+	return func(o *Provider) error {
+		o.spiffe = &config
+		return nil
+	}
+}
+
+// WithTracerProvider records a span of every request to the endpoints
+// of the Provider, see [Instrumentation].
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *Provider) error {
+		o.tracerProvider = tp
+		return nil
+	}
+}
+
+// WithMeterProvider records the count and duration of the requests to the
+// endpoints of the Provider, by endpoint and grant type, see [Instrumentation].
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(o *Provider) error {
+		o.meterProvider = mp
+		return nil
+	}
+}
+
+// WithUserinfoStorage lets the userinfo endpoint obtain the claims of type C
+// from storage, instead of using SetUserinfoFromToken of the [Storage].
+func WithUserinfoStorage[C any](storage UserinfoStorage[C]) Option {
+	return func(o *Provider) error {
+		o.userinfoFromToken = newUserinfoFunc(storage)
+		return nil
+	}
+}
+
+// WithIntrospectionStorage lets the introspection endpoint obtain the response of type C
+// from storage, instead of using SetIntrospectionFromToken of the [Storage].
+func WithIntrospectionStorage[C IntrospectionClaims](storage IntrospectionStorage[C]) Option {
+	return func(o *Provider) error {
+		o.introspectionFromToken = newIntrospectionFunc(storage)
+		return nil
+	}
+}
+
+// WithServiceTokenSubject sets how the sub claim of the introspection
+// response of a service token is derived, see [ServiceTokenStorage].
+// Defaults to [ServiceTokenSubjectClientID].
+func WithServiceTokenSubject(subject ServiceTokenSubject) Option {
+	return func(o *Provider) error {
+		o.serviceTokenSubject = subject
+		return nil
+	}
+}
+
+// WithAuthErrorPolicy decides whether errors of auth requests are redirected
+// to the client or rendered, see [AuthErrorPolicy].
+func WithAuthErrorPolicy(policy AuthErrorPolicy) Option {
+	return func(o *Provider) error {
+		o.authErrorPolicy = policy
+		return nil
+	}
+}
+
+// WithAuthErrorRenderer renders the errors of auth requests, which are not
+// redirected to the client, for example as HTML error page, see [AuthErrorRenderer].
+func WithAuthErrorRenderer(renderer AuthErrorRenderer) Option {
+	return func(o *Provider) error {
+		o.authErrorRenderer = renderer
+		return nil
+	}
+}
+
+// WithJSONRequestBodies accepts application/json request bodies at the token,
+// introspection and revocation endpoints, for clients and gateways which do
+// not send them form-encoded as required by RFC 6749. The bodies are translated
+// to the standard parameters and each translation is logged as a warning.
+// It is disabled by default.
+func WithJSONRequestBodies() Option {
+	return func(o *Provider) error {
+		o.jsonRequestBodies = true
+		return nil
+	}
+}
+
+// WithDPoP accepts DPoP proofs (RFC 9449) at the token endpoint and binds
+// the access tokens to the key of the proof, see [DPoPThumbprintFromContext].
+// Proofs are checked for replays, if a cache is set with [WithReplayCache].
+// It is disabled by default.
+func WithDPoP() Option {
+	return func(o *Provider) error {
+		o.dpop = true
+		return nil
+	}
+}
+
+// WithScopeArrayClaim emits the scopes of JWT access tokens and introspection
+// responses additionally as scp array, for API gateways which do not accept
+// the space delimited scope claim of RFC 9068 and RFC 7662.
+// It is disabled by default.
+func WithScopeArrayClaim() Option {
+	return func(o *Provider) error {
+		o.scopeArrayClaim = true
+		return nil
+	}
+}
+
+// WithAudienceSplitting mints a separate access token for each audience and
+// resource of a token exchange request asking for several, restricted to it,
+// instead of a single token accepted by all of them. Services fanning out to
+// many APIs this way do not hand each API a token valid at the others.
+// The tokens are returned in the issued_tokens member of the response,
+// the standard members hold the token of the first audience or resource.
+// It is disabled by default.
+func WithAudienceSplitting() Option {
+	return func(o *Provider) error {
+		o.audienceSplitting = true
+		return nil
+	}
+}
+
+// WithCustomClaims declares the custom claims emitted by the Storage,
+// see [oidc.ClaimNamespace], which are added to the claims_supported
+// of the discovery. Claims without namespace named like one of the
+// [oidc.RegisteredClaims] would be shadowed by the registered claim,
+// they fail the creation of the Provider with [oidc.ErrClaimCollision].
+// It can be used multiple times, for each namespace.
+func WithCustomClaims(namespace oidc.ClaimNamespace, names ...string) Option {
+	return func(o *Provider) error {
+		return o.addCustomClaims(namespace, names)
+	}
+}
+
+// WithGroupClaimsPolicy guards the size of the group claims
+// of the ID and JWT access tokens issued by the Provider.
+// Without, the claims are issued as returned by the Storage.
+func WithGroupClaimsPolicy(policy GroupClaimsPolicy) Option {
+	return func(o *Provider) error {
+		o.groupClaimsPolicy = &policy
+		return nil
+	}
+}
+
+// WithTokenSizePolicy limits the size of the serialized ID and JWT access tokens
+// issued by the Provider. Oversized tokens are refused with a [TokenSizeError]
+// naming the largest claims, unless the policy moves them to userinfo.
+func WithTokenSizePolicy(policy TokenSizePolicy) Option {
+	return func(o *Provider) error {
+		o.tokenSizePolicy = &policy
+		return nil
+	}
+}
+
+// WithReplayCache records the jti of the device proofs in the cache,
+// rejecting proofs which are used more than once.
+// A cache shared between the instances of the Provider, which implements
+// [cache.Adder], detects replays on all of them.
+func WithReplayCache(c cache.Cache) Option {
+	return func(o *Provider) error {
+		o.replayCache = c
+		return nil
+	}
+}
+
+// WithClientJWKSCache enables the cache for the keys of the jwks_uri of clients
+// implementing [HasJWKSURI], and of the issuers of [WithFederatedTokenExchange].
+// Without it, all signatures are verified with the keys of the Storage.
+func WithClientJWKSCache(c *ClientJWKSCache) Option {
+	return func(o *Provider) error {
+		o.clientJWKS = c
+		return nil
+	}
+}
+
+// WithIDTokenHeader sets or overrides parameters of the JWS header
+// of the ID tokens issued by the Provider.
+func WithIDTokenHeader(header TokenHeader) Option {
+	return func(o *Provider) error {
+		o.idTokenHeader = &header
+		return nil
+	}
+}
+
+// WithAccessTokenHeader sets or overrides parameters of the JWS header
+// of the JWT access tokens issued by the Provider,
+// e.g. the at+jwt type required by RFC 9068.
+func WithAccessTokenHeader(header TokenHeader) Option {
+	return func(o *Provider) error {
+		o.accessTokenHeader = &header
+		return nil
+	}
+}
+
+// WithBackChannelLogoutClient sets the http.Client posting the logout tokens
+// to the backchannel_logout_uri of clients, see [BackChannelLogoutStorage].
+// As the URIs are part of the client metadata, it defaults to the [httphelper.PublicHTTPClient],
+// so clients cannot make the provider post to internal services.
+func WithBackChannelLogoutClient(client *http.Client) Option {
+	return func(o *Provider) error {
+		o.backChannelLogoutClient = client
+		return nil
+	}
+}
+
+// WithBackChannelLogoutQueue posts the logout tokens in the background,
+// rather than during the end_session request, see [SoftFailQueue].
+// Notifications are dropped, when the queue is full.
+func WithBackChannelLogoutQueue(queue *SoftFailQueue) Option {
+	return func(o *Provider) error {
+		o.backChannelLogoutQueue = queue
+		return nil
+	}
+}
+
+// WithFlowLifetimes enforces the lifetimes of auth requests and codes and
+// sets the lifetimes of pushed auth requests and device codes, see [FlowLifetimes].
+// The expiry of auth requests and codes is recorded in the cache, which must be
+// shared between the instances of the Provider. It defaults to a [cache.Memory].
+func WithFlowLifetimes(lifetimes FlowLifetimes, c cache.Cache) Option {
+	return func(o *Provider) error {
+		if c == nil {
+			c = cache.NewMemory()
+		}
+		lifetimes.cache = c
+		o.flowLifetimes = &lifetimes
+		return nil
+	}
+}
+
 // WithLogger lets a logger other than slog.Default().
 func WithLogger(logger *slog.Logger) Option {
 	return func(o *Provider) error {
@@ -3,6 +3,7 @@ package op_test
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -204,7 +205,7 @@ func TestRoutes(t *testing.T) {
 				"assertion":  jwtToken,
 			},
 			wantCode: http.StatusBadRequest,
-			json:     "{\"error\":\"server_error\",\"error_description\":\"audience is not valid: Audience must contain client_id \\\"https://localhost:9998/\\\"\"}",
+			json:     "{\"error\":\"server_error\",\"error_description\":\"audience is not valid: Audience must contain the issuer \\\"https://localhost:9998/\\\"\"}",
 		},
 		{
 			name:      "Token exchange",
@@ -453,3 +454,26 @@ func TestWithCustomEndpoints(t *testing.T) {
 		})
 	}
 }
+
+func TestWithJSONRequestBodies(t *testing.T) {
+	token := func(t *testing.T, opts ...op.Option) string {
+		opts = append(opts, op.WithAllowInsecure())
+		provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)), opts...)
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodPost, testIssuer+"oauth/token",
+			strings.NewReader(`{"grant_type":"refresh_token","refresh_token":"unknown","scope":["openid"]}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.SetBasicAuth("web", "secret")
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, r)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		var resp oidc.Error
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return string(resp.ErrorType)
+	}
+
+	// the grant_type is missing, as the body is not form-encoded
+	assert.Equal(t, string(oidc.InvalidRequest), token(t))
+	// the refresh token is unknown
+	assert.Equal(t, string(oidc.InvalidGrant), token(t, op.WithJSONRequestBodies()))
+}
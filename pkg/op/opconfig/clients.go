@@ -0,0 +1,378 @@
+package opconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/lmindwarel/oidc/v3/internal/security"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrInvalidSecret  = errors.New("invalid client secret")
+)
+
+// AuthRequestIDPlaceholder is replaced by the ID of the auth request in the
+// [ClientConfig.LoginURL].
+const AuthRequestIDPlaceholder = "{auth_request_id}"
+
+// ClientsConfig is the content of a client registry file.
+type ClientsConfig struct {
+	Clients []ClientConfig `json:"clients" yaml:"clients"`
+}
+
+// ClientConfig is the declarative configuration of a static client.
+type ClientConfig struct {
+	ID string `json:"id" yaml:"id"`
+	// Secret is the client secret. Prefer SecretHash,
+	// so the registry file does not hold the secret.
+	Secret *SecretRef `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// SecretHash is the bcrypt hash of the client secret.
+	SecretHash string `json:"secret_hash,omitempty" yaml:"secret_hash,omitempty"`
+
+	RedirectURIs           []string            `json:"redirect_uris,omitempty" yaml:"redirect_uris,omitempty"`
+	PostLogoutRedirectURIs []string            `json:"post_logout_redirect_uris,omitempty" yaml:"post_logout_redirect_uris,omitempty"`
+	ApplicationType        op.ApplicationType  `json:"application_type,omitempty" yaml:"application_type,omitempty"`
+	AuthMethod             oidc.AuthMethod     `json:"auth_method,omitempty" yaml:"auth_method,omitempty"`
+	ResponseTypes          []oidc.ResponseType `json:"response_types,omitempty" yaml:"response_types,omitempty"`
+	GrantTypes             []oidc.GrantType    `json:"grant_types,omitempty" yaml:"grant_types,omitempty"`
+	AccessTokenType        op.AccessTokenType  `json:"access_token_type,omitempty" yaml:"access_token_type,omitempty"`
+	// LoginURL is the URL of the login UI, with the [AuthRequestIDPlaceholder].
+	LoginURL        string   `json:"login_url" yaml:"login_url"`
+	IDTokenLifetime Duration `json:"id_token_lifetime,omitempty" yaml:"id_token_lifetime,omitempty"`
+	ClockSkew       Duration `json:"clock_skew,omitempty" yaml:"clock_skew,omitempty"`
+	// AllowedScopes restricts the scopes the client may request
+	// beyond openid. All scopes are allowed, if empty.
+	AllowedScopes                  []string `json:"allowed_scopes,omitempty" yaml:"allowed_scopes,omitempty"`
+	IDTokenUserinfoClaimsAssertion bool     `json:"id_token_userinfo_claims_assertion,omitempty" yaml:"id_token_userinfo_claims_assertion,omitempty"`
+	DevMode                        bool     `json:"dev_mode,omitempty" yaml:"dev_mode,omitempty"`
+	// JWKSURI publishes the keys of a client authenticating with private_key_jwt,
+	// see [op.HasJWKSURI]. They are only fetched with [op.WithClientJWKSCache].
+	JWKSURI string `json:"jwks_uri,omitempty" yaml:"jwks_uri,omitempty"`
+}
+
+func (c *ClientConfig) validate() error {
+	var errs []error
+	if c.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+	if c.Secret != nil && c.SecretHash != "" {
+		errs = append(errs, errors.New("secret and secret_hash are mutually exclusive"))
+	}
+	if c.SecretHash != "" {
+		if _, err := bcrypt.Cost([]byte(c.SecretHash)); err != nil {
+			errs = append(errs, fmt.Errorf("secret_hash: %w", err))
+		}
+	}
+	if c.Secret != nil {
+		if _, err := c.Secret.Resolve(); err != nil {
+			errs = append(errs, fmt.Errorf("secret: %w", err))
+		}
+	}
+	hasSecret := c.Secret != nil || c.SecretHash != ""
+	switch c.authMethod() {
+	case oidc.AuthMethodBasic, oidc.AuthMethodPost:
+		if !hasSecret {
+			errs = append(errs, fmt.Errorf("auth_method %s requires a secret", c.authMethod()))
+		}
+	case oidc.AuthMethodNone, oidc.AuthMethodPrivateKeyJWT, oidc.AuthMethodTLSClientAuth,
+		oidc.AuthMethodSelfSignedTLSClientAuth, oidc.AuthMethodSPIFFEJWT:
+		if hasSecret {
+			errs = append(errs, fmt.Errorf("auth_method %s does not use a secret", c.authMethod()))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown auth_method %q", c.AuthMethod))
+	}
+	if slices.Contains(c.grantTypes(), oidc.GrantTypeCode) || slices.Contains(c.grantTypes(), oidc.GrantTypeImplicit) {
+		if len(c.RedirectURIs) == 0 {
+			errs = append(errs, errors.New("redirect_uris are required for the authorization_code and implicit grants"))
+		}
+		if !strings.Contains(c.LoginURL, AuthRequestIDPlaceholder) {
+			errs = append(errs, fmt.Errorf("login_url must contain %s", AuthRequestIDPlaceholder))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ClientConfig) authMethod() oidc.AuthMethod {
+	if c.AuthMethod == "" {
+		return oidc.AuthMethodBasic
+	}
+	return c.AuthMethod
+}
+
+func (c *ClientConfig) grantTypes() []oidc.GrantType {
+	if len(c.GrantTypes) == 0 {
+		return []oidc.GrantType{oidc.GrantTypeCode}
+	}
+	return c.GrantTypes
+}
+
+// staticClient implements [op.Client] for a [ClientConfig].
+type staticClient struct {
+	config *ClientConfig
+	secret security.Secret
+}
+
+func (c *staticClient) GetID() string {
+	return c.config.ID
+}
+
+func (c *staticClient) RedirectURIs() []string {
+	return c.config.RedirectURIs
+}
+
+func (c *staticClient) PostLogoutRedirectURIs() []string {
+	return c.config.PostLogoutRedirectURIs
+}
+
+func (c *staticClient) ApplicationType() op.ApplicationType {
+	return c.config.ApplicationType
+}
+
+func (c *staticClient) AuthMethod() oidc.AuthMethod {
+	return c.config.authMethod()
+}
+
+func (c *staticClient) GrantTypes() []oidc.GrantType {
+	return c.config.grantTypes()
+}
+
+func (c *staticClient) AccessTokenType() op.AccessTokenType {
+	return c.config.AccessTokenType
+}
+
+func (c *staticClient) IDTokenLifetime() time.Duration {
+	return durationOr(c.config.IDTokenLifetime, time.Hour)
+}
+
+func (c *staticClient) DevMode() bool {
+	return c.config.DevMode
+}
+
+func (c *staticClient) ClockSkew() time.Duration {
+	return time.Duration(c.config.ClockSkew)
+}
+
+func (c *staticClient) IDTokenUserinfoClaimsAssertion() bool {
+	return c.config.IDTokenUserinfoClaimsAssertion
+}
+
+func (c *staticClient) ResponseTypes() []oidc.ResponseType {
+	if len(c.config.ResponseTypes) == 0 {
+		return []oidc.ResponseType{oidc.ResponseTypeCode}
+	}
+	return c.config.ResponseTypes
+}
+
+func (c *staticClient) LoginURL(id string) string {
+	return strings.ReplaceAll(c.config.LoginURL, AuthRequestIDPlaceholder, id)
+}
+
+func (c *staticClient) RestrictAdditionalIdTokenScopes() func(scopes []string) []string {
+	return func(scopes []string) []string { return scopes }
+}
+
+func (c *staticClient) RestrictAdditionalAccessTokenScopes() func(scopes []string) []string {
+	return func(scopes []string) []string { return scopes }
+}
+
+func (c *staticClient) IsScopeAllowed(scope string) bool {
+	return len(c.config.AllowedScopes) == 0 || slices.Contains(c.config.AllowedScopes, scope)
+}
+
+func (c *staticClient) authorizeSecret(secret string) error {
+	switch {
+	case c.config.SecretHash != "":
+		if bcrypt.CompareHashAndPassword([]byte(c.config.SecretHash), []byte(secret)) != nil {
+			return ErrInvalidSecret
+		}
+	case c.secret != "":
+		if !c.secret.Equal(secret) {
+			return ErrInvalidSecret
+		}
+	default:
+		return ErrInvalidSecret
+	}
+	return nil
+}
+
+// jwksURIClient is a staticClient with a jwks_uri.
+type jwksURIClient struct {
+	*staticClient
+}
+
+func (c jwksURIClient) JWKSURI() string {
+	return c.config.JWKSURI
+}
+
+// ClientRegistry is a read-only registry of static clients, loaded from a file.
+// It implements the client methods of the [op.Storage] and [op.ClientLister],
+// to be embedded by Storages of simple self-hosted Providers.
+//
+// The file is YAML for the .yaml and .yml extensions and JSON otherwise,
+// see [ClientsConfig]. It can be reloaded at runtime with [ClientRegistry.Reload]
+// or [ClientRegistry.Watch]. Requests observe either the previous or the new
+// clients, never a partial update, and invalid files keep the previous clients.
+type ClientRegistry struct {
+	path    string
+	clients atomic.Pointer[map[string]*staticClient]
+	modTime atomic.Pointer[time.Time]
+}
+
+// LoadClients loads the clients of the file.
+func LoadClients(path string) (*ClientRegistry, error) {
+	registry := &ClientRegistry{path: path}
+	if err := registry.Reload(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Reload reads and validates the file and replaces the clients.
+func (r *ClientRegistry) Reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	clients, err := parseClients(data, formatOf(r.path))
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.path, err)
+	}
+	modTime := info.ModTime()
+	r.clients.Store(&clients)
+	r.modTime.Store(&modTime)
+	return nil
+}
+
+// Watch checks the modification time of the file every interval and reloads it
+// on changes, until the context is done. Errors of reloading are passed to onError,
+// if not nil, once per modification, and the previous clients are kept.
+func (r *ClientRegistry) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	seen := *r.modTime.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(r.path)
+		if err == nil {
+			if info.ModTime().Equal(seen) {
+				continue
+			}
+			seen = info.ModTime()
+			err = r.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func parseClients(data []byte, format Format) (map[string]*staticClient, error) {
+	var config ClientsConfig
+	if err := decode(data, format, &config); err != nil {
+		return nil, err
+	}
+	clients := make(map[string]*staticClient, len(config.Clients))
+	var errs []error
+	for i := range config.Clients {
+		c := &config.Clients[i]
+		if err := c.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("clients[%d] %s: %w", i, c.ID, err))
+			continue
+		}
+		if _, ok := clients[c.ID]; ok {
+			errs = append(errs, fmt.Errorf("clients[%d]: duplicate id %s", i, c.ID))
+			continue
+		}
+		client := &staticClient{config: c}
+		if c.Secret != nil {
+			secret, _ := c.Secret.Resolve()
+			client.secret = security.Secret(secret)
+			security.Zero(secret)
+		}
+		clients[c.ID] = client
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, errors.Join(errs...))
+	}
+	return clients, nil
+}
+
+func (r *ClientRegistry) client(clientID string) (*staticClient, error) {
+	client, ok := (*r.clients.Load())[clientID]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return client, nil
+}
+
+// GetClientByClientID implements the [op.Storage] interface.
+func (r *ClientRegistry) GetClientByClientID(_ context.Context, clientID string) (op.Client, error) {
+	client, err := r.client(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.config.JWKSURI != "" {
+		return jwksURIClient{client}, nil
+	}
+	return client, nil
+}
+
+// AuthorizeClientIDSecret implements the [op.Storage] interface.
+func (r *ClientRegistry) AuthorizeClientIDSecret(_ context.Context, clientID, clientSecret string) error {
+	client, err := r.client(clientID)
+	if err != nil {
+		return err
+	}
+	return client.authorizeSecret(clientSecret)
+}
+
+// ClientCredentials authenticates a client of the client_credentials grant,
+// for Storages implementing the [op.ClientCredentialsStorage].
+func (r *ClientRegistry) ClientCredentials(ctx context.Context, clientID, clientSecret string) (op.Client, error) {
+	if err := r.AuthorizeClientIDSecret(ctx, clientID, clientSecret); err != nil {
+		return nil, err
+	}
+	client, err := r.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(client.GrantTypes(), oidc.GrantTypeClientCredentials) {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("client_credentials grant not allowed")
+	}
+	return client, nil
+}
+
+// ListClients implements the [op.ClientLister] interface.
+func (r *ClientRegistry) ListClients(ctx context.Context) ([]op.Client, error) {
+	clients := *r.clients.Load()
+	ids := make([]string, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	list := make([]op.Client, len(ids))
+	for i, id := range ids {
+		list[i], _ = r.GetClientByClientID(ctx, id)
+	}
+	return list, nil
+}
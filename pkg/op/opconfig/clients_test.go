@@ -0,0 +1,161 @@
+package opconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func writeClients(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestClientRegistry(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("web-secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "clients.yaml")
+	writeClients(t, path, `
+clients:
+  - id: web
+    secret_hash: `+string(hash)+`
+    redirect_uris: [https://example.com/callback]
+    login_url: /login?authRequestID={auth_request_id}
+    grant_types: [authorization_code, refresh_token]
+    access_token_type: jwt
+  - id: native
+    auth_method: none
+    application_type: native
+    redirect_uris: [http://127.0.0.1/callback]
+    login_url: /login/{auth_request_id}
+    allowed_scopes: [email]
+  - id: service
+    secret:
+      value: service-secret
+    grant_types: [client_credentials]
+  - id: keys
+    auth_method: private_key_jwt
+    grant_types: [client_credentials]
+    jwks_uri: https://keys.example.com/jwks.json
+`)
+	registry, err := LoadClients(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	web, err := registry.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	assert.Equal(t, oidc.AuthMethodBasic, web.AuthMethod())
+	assert.Equal(t, op.AccessTokenTypeJWT, web.AccessTokenType())
+	assert.Equal(t, "/login?authRequestID=123", web.LoginURL("123"))
+	assert.Equal(t, []oidc.ResponseType{oidc.ResponseTypeCode}, web.ResponseTypes())
+	assert.NoError(t, registry.AuthorizeClientIDSecret(ctx, "web", "web-secret"))
+	assert.ErrorIs(t, registry.AuthorizeClientIDSecret(ctx, "web", "wrong"), ErrInvalidSecret)
+
+	native, err := registry.GetClientByClientID(ctx, "native")
+	require.NoError(t, err)
+	assert.Equal(t, op.ApplicationTypeNative, native.ApplicationType())
+	assert.True(t, native.IsScopeAllowed("email"))
+	assert.False(t, native.IsScopeAllowed("admin"))
+	assert.ErrorIs(t, registry.AuthorizeClientIDSecret(ctx, "native", ""), ErrInvalidSecret)
+
+	service, err := registry.ClientCredentials(ctx, "service", "service-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "service", service.GetID())
+	_, err = registry.ClientCredentials(ctx, "web", "web-secret")
+	assert.ErrorIs(t, err, oidc.ErrUnauthorizedClient())
+
+	keys, err := registry.GetClientByClientID(ctx, "keys")
+	require.NoError(t, err)
+	require.Implements(t, (*op.HasJWKSURI)(nil), keys)
+	assert.Equal(t, "https://keys.example.com/jwks.json", keys.(op.HasJWKSURI).JWKSURI())
+
+	_, err = registry.GetClientByClientID(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrClientNotFound)
+
+	list, err := registry.ListClients(ctx)
+	require.NoError(t, err)
+	ids := make([]string, len(list))
+	for i, client := range list {
+		ids[i] = client.GetID()
+	}
+	assert.Equal(t, []string{"keys", "native", "service", "web"}, ids)
+}
+
+func TestParseClients_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients string
+	}{
+		{
+			name:    "missing secret",
+			clients: `{"clients":[{"id":"web","redirect_uris":["https://example.com"],"login_url":"/login/{auth_request_id}"}]}`,
+		},
+		{
+			name:    "secret of public client",
+			clients: `{"clients":[{"id":"app","auth_method":"none","secret":{"value":"s"},"grant_types":["client_credentials"]}]}`,
+		},
+		{
+			name:    "invalid secret hash",
+			clients: `{"clients":[{"id":"svc","secret_hash":"plain","grant_types":["client_credentials"]}]}`,
+		},
+		{
+			name:    "missing redirect uris",
+			clients: `{"clients":[{"id":"web","secret":{"value":"s"},"login_url":"/login/{auth_request_id}"}]}`,
+		},
+		{
+			name:    "missing login url placeholder",
+			clients: `{"clients":[{"id":"web","secret":{"value":"s"},"redirect_uris":["https://example.com"],"login_url":"/login"}]}`,
+		},
+		{
+			name:    "duplicate id",
+			clients: `{"clients":[{"id":"svc","secret":{"value":"s"},"grant_types":["client_credentials"]},{"id":"svc","secret":{"value":"s"},"grant_types":["client_credentials"]}]}`,
+		},
+		{
+			name:    "unknown application type",
+			clients: `{"clients":[{"id":"svc","secret":{"value":"s"},"application_type":"desktop","grant_types":["client_credentials"]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseClients([]byte(tt.clients), FormatJSON)
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+		})
+	}
+}
+
+func TestClientRegistry_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	writeClients(t, path, `{"clients":[{"id":"svc","secret":{"value":"old"},"grant_types":["client_credentials"]}]}`)
+	registry, err := LoadClients(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	go registry.Watch(ctx, 10*time.Millisecond, func(err error) { errs <- err })
+
+	writeClients(t, path, `{"clients":[{"id":"svc","secret":{"value":"new"},"grant_types":["client_credentials"]}]}`)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool {
+		return registry.AuthorizeClientIDSecret(ctx, "svc", "new") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	writeClients(t, path, `{"clients":[{"id":"svc"`)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	case <-time.After(time.Second):
+		t.Fatal("invalid file not reported")
+	}
+	assert.NoError(t, registry.AuthorizeClientIDSecret(ctx, "svc", "new"), "previous clients are kept")
+}
@@ -0,0 +1,202 @@
+package opconfig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/internal/security"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// SecretRef references a secret, either inline by Value,
+// read from a File or from the environment variable Env.
+// Exactly one of them must be set.
+type SecretRef struct {
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	File  string `json:"file,omitempty" yaml:"file,omitempty"`
+	Env   string `json:"env,omitempty" yaml:"env,omitempty"`
+}
+
+// String describes the reference, with an inline Value redacted,
+// so configurations can be printed and logged.
+func (s SecretRef) String() string {
+	switch {
+	case s.File != "":
+		return "file:" + s.File
+	case s.Env != "":
+		return "env:" + s.Env
+	case s.Value != "":
+		return "value:" + security.Redacted
+	}
+	return ""
+}
+
+// GoString redacts an inline Value for %#v.
+func (s SecretRef) GoString() string {
+	return "opconfig.SecretRef(" + s.String() + ")"
+}
+
+// LogValue implements [slog.LogValuer].
+func (s SecretRef) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// Resolve returns the referenced secret.
+func (s SecretRef) Resolve() ([]byte, error) {
+	var set int
+	for _, v := range []string{s.Value, s.File, s.Env} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of value, file and env is required")
+	}
+	switch {
+	case s.File != "":
+		return os.ReadFile(s.File)
+	case s.Env != "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return []byte(value), nil
+	default:
+		return []byte(s.Value), nil
+	}
+}
+
+func (c *ProviderConfig) cryptoKey() ([32]byte, error) {
+	var key [32]byte
+	resolved, err := c.CryptoKey.Resolve()
+	if err != nil {
+		return key, err
+	}
+	defer security.Zero(resolved)
+	secret := bytes.TrimSpace(resolved)
+	for _, decode := range []func([]byte) ([]byte, error){
+		func(b []byte) ([]byte, error) { return b, nil },
+		func(b []byte) ([]byte, error) { return hex.DecodeString(string(b)) },
+		func(b []byte) ([]byte, error) { return base64.StdEncoding.DecodeString(string(b)) },
+		func(b []byte) ([]byte, error) { return base64.RawURLEncoding.DecodeString(string(b)) },
+	} {
+		decoded, err := decode(secret)
+		if err == nil && len(decoded) == len(key) {
+			copy(key[:], decoded)
+			security.Zero(decoded)
+			return key, nil
+		}
+	}
+	return key, errors.New("must be 32 bytes, raw, hex or base64 encoded")
+}
+
+// SigningKeyRef references a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1)
+// of a signing key. The ID defaults to the JWK thumbprint of the key
+// and the Algorithm to RS256, ES256, ES384, ES512 or EdDSA, by the key type.
+type SigningKeyRef struct {
+	ID         string    `json:"id,omitempty" yaml:"id,omitempty"`
+	Algorithm  string    `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	PrivateKey SecretRef `json:"private_key" yaml:"private_key"`
+}
+
+type signingKey struct {
+	id        string
+	algorithm jose.SignatureAlgorithm
+	key       crypto.Signer
+}
+
+func (k *signingKey) SignatureAlgorithm() jose.SignatureAlgorithm { return k.algorithm }
+func (k *signingKey) Key() any                                    { return k.key }
+func (k *signingKey) ID() string                                  { return k.id }
+
+func (k *signingKey) public() op.Key {
+	return &publicKey{signingKey: k}
+}
+
+type publicKey struct {
+	*signingKey
+}
+
+func (k *publicKey) Algorithm() jose.SignatureAlgorithm { return k.algorithm }
+func (k *publicKey) Use() string                        { return "sig" }
+func (k *publicKey) Key() any                           { return k.key.Public() }
+
+func (r SigningKeyRef) load() (*signingKey, error) {
+	data, err := r.PrivateKey.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key")
+	}
+	signer, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	algorithm := jose.SignatureAlgorithm(r.Algorithm)
+	if algorithm == "" {
+		if algorithm, err = defaultAlgorithm(signer); err != nil {
+			return nil, err
+		}
+	}
+	id := r.ID
+	if id == "" {
+		thumbprint, err := (&jose.JSONWebKey{Key: signer.Public()}).Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		id = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+	return &signingKey{id: id, algorithm: algorithm, key: signer}, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key, PKCS#8, PKCS#1 or SEC 1 expected")
+}
+
+func defaultAlgorithm(key crypto.Signer) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+	case ed25519.PrivateKey:
+		return jose.EdDSA, nil
+	}
+	return "", fmt.Errorf("no default algorithm for private key %T", key)
+}
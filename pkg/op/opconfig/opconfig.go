@@ -0,0 +1,452 @@
+// Package opconfig loads the configuration of an [op.Provider] from YAML or JSON
+// files and environment variables, so deployments can manage the settings of
+// the Provider with configuration management instead of code changes.
+//
+// The Storage and the features requiring code, such as claims or hooks,
+// are still passed as Go values to [ProviderConfig.NewProvider].
+// Simple self-hosted Providers can embed a [ClientRegistry] into their
+// Storage, to serve a static set of clients from a file.
+package opconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables applied by [Load].
+const DefaultEnvPrefix = "OIDC"
+
+var ErrInvalidConfig = errors.New("invalid provider configuration")
+
+// ProviderConfig is the declarative configuration of an [op.Provider].
+// Zero values keep the defaults of the op package.
+type ProviderConfig struct {
+	// Issuer is the static issuer of the Provider.
+	Issuer string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	// IssuerPath derives the issuer from the Forwarded or Host header of the
+	// requests with the path, see [op.IssuerFromForwardedOrHost].
+	// Exactly one of Issuer and IssuerPath is required.
+	IssuerPath string `json:"issuer_path,omitempty" yaml:"issuer_path,omitempty"`
+	// Insecure allows http issuers, for development only.
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+
+	// CryptoKey encrypts opaque tokens and codes. It must be 32 bytes,
+	// either raw, hex or base64 encoded.
+	CryptoKey SecretRef `json:"crypto_key" yaml:"crypto_key"`
+	// SigningKeys are PEM encoded private keys. The first one signs the tokens,
+	// all are published. Without, the keys of the Storage are used.
+	SigningKeys []SigningKeyRef `json:"signing_keys,omitempty" yaml:"signing_keys,omitempty"`
+
+	DefaultLogoutRedirectURI string   `json:"default_logout_redirect_uri,omitempty" yaml:"default_logout_redirect_uri,omitempty"`
+	SupportedUILocales       []string `json:"supported_ui_locales,omitempty" yaml:"supported_ui_locales,omitempty"`
+	SupportedClaims          []string `json:"supported_claims,omitempty" yaml:"supported_claims,omitempty"`
+	SupportedScopes          []string `json:"supported_scopes,omitempty" yaml:"supported_scopes,omitempty"`
+	ClientSigningAlgorithms  []string `json:"client_signing_algorithms,omitempty" yaml:"client_signing_algorithms,omitempty"`
+
+	// TokenExchangeRules are the trust matrix of the token exchange grant,
+	// see [op.TokenExchangePolicy]. Without, all requests are passed to the Storage.
+	TokenExchangeRules []TokenExchangeRule `json:"token_exchange_rules,omitempty" yaml:"token_exchange_rules,omitempty"`
+
+	Features  Features  `json:"features" yaml:"features"`
+	Endpoints Endpoints `json:"endpoints" yaml:"endpoints"`
+	Device    Device    `json:"device" yaml:"device"`
+	Lifetimes Lifetimes `json:"lifetimes" yaml:"lifetimes"`
+}
+
+// Features toggles the features of the Provider.
+type Features struct {
+	CodeMethodS256              bool `json:"code_method_s256,omitempty" yaml:"code_method_s256,omitempty"`
+	AuthMethodPost              bool `json:"auth_method_post,omitempty" yaml:"auth_method_post,omitempty"`
+	AuthMethodPrivateKeyJWT     bool `json:"auth_method_private_key_jwt,omitempty" yaml:"auth_method_private_key_jwt,omitempty"`
+	GrantTypeRefreshToken       bool `json:"grant_type_refresh_token,omitempty" yaml:"grant_type_refresh_token,omitempty"`
+	RequestObject               bool `json:"request_object,omitempty" yaml:"request_object,omitempty"`
+	BackChannelLogout           bool `json:"backchannel_logout,omitempty" yaml:"backchannel_logout,omitempty"`
+	BackChannelLogoutSession    bool `json:"backchannel_logout_session,omitempty" yaml:"backchannel_logout_session,omitempty"`
+	JWTAuthorizationResponse    bool `json:"jwt_authorization_response,omitempty" yaml:"jwt_authorization_response,omitempty"`
+	JSONRequestBodies           bool `json:"json_request_bodies,omitempty" yaml:"json_request_bodies,omitempty"`
+	DPoP                        bool `json:"dpop,omitempty" yaml:"dpop,omitempty"`
+	ScopeArrayClaim             bool `json:"scope_array_claim,omitempty" yaml:"scope_array_claim,omitempty"`
+	AudienceSplitting           bool `json:"audience_splitting,omitempty" yaml:"audience_splitting,omitempty"`
+	SignedMetadata              bool `json:"signed_metadata,omitempty" yaml:"signed_metadata,omitempty"`
+	PushedAuthorization         bool `json:"pushed_authorization,omitempty" yaml:"pushed_authorization,omitempty"`
+	PushedAuthorizationRequired bool `json:"pushed_authorization_required,omitempty" yaml:"pushed_authorization_required,omitempty"`
+	BackchannelAuthentication   bool `json:"backchannel_authentication,omitempty" yaml:"backchannel_authentication,omitempty"`
+	RefreshTokenGrantRequired   bool `json:"refresh_token_grant_required,omitempty" yaml:"refresh_token_grant_required,omitempty"`
+}
+
+// TokenExchangeRule is an [op.TokenExchangeRule].
+type TokenExchangeRule struct {
+	Clients           []string `json:"clients" yaml:"clients"`
+	Audiences         []string `json:"audiences" yaml:"audiences"`
+	Scopes            []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	SubjectTokenTypes []string `json:"subject_token_types,omitempty" yaml:"subject_token_types,omitempty"`
+}
+
+// Endpoints overrides the paths of the endpoints of [op.DefaultEndpoints].
+// Unset endpoints keep the default, endpoints set to an empty string are
+// disabled, except for the required authorization, token and jwks endpoints.
+type Endpoints struct {
+	Authorization             *string `json:"authorization,omitempty" yaml:"authorization,omitempty"`
+	Token                     *string `json:"token,omitempty" yaml:"token,omitempty"`
+	Introspection             *string `json:"introspection,omitempty" yaml:"introspection,omitempty"`
+	Userinfo                  *string `json:"userinfo,omitempty" yaml:"userinfo,omitempty"`
+	Revocation                *string `json:"revocation,omitempty" yaml:"revocation,omitempty"`
+	EndSession                *string `json:"end_session,omitempty" yaml:"end_session,omitempty"`
+	CheckSessionIframe        *string `json:"check_session_iframe,omitempty" yaml:"check_session_iframe,omitempty"`
+	JwksURI                   *string `json:"jwks_uri,omitempty" yaml:"jwks_uri,omitempty"`
+	DeviceAuthorization       *string `json:"device_authorization,omitempty" yaml:"device_authorization,omitempty"`
+	PushedAuthorization       *string `json:"pushed_authorization,omitempty" yaml:"pushed_authorization,omitempty"`
+	BackchannelAuthentication *string `json:"backchannel_authentication,omitempty" yaml:"backchannel_authentication,omitempty"`
+	Registration              *string `json:"registration,omitempty" yaml:"registration,omitempty"`
+}
+
+// Device configures the device authorization grant,
+// which is enabled by a Storage implementing [op.DeviceAuthorizationStorage].
+type Device struct {
+	UserFormPath                    string `json:"user_form_path,omitempty" yaml:"user_form_path,omitempty"`
+	VerificationURICompleteTemplate string `json:"verification_uri_complete_template,omitempty" yaml:"verification_uri_complete_template,omitempty"`
+	// UserCode is either base20 (default) or digits.
+	UserCode string `json:"user_code,omitempty" yaml:"user_code,omitempty"`
+}
+
+// Lifetimes of the artifacts of the Provider. Zero values keep the defaults.
+type Lifetimes struct {
+	DeviceCode                 Duration `json:"device_code,omitempty" yaml:"device_code,omitempty"`
+	DevicePollInterval         Duration `json:"device_poll_interval,omitempty" yaml:"device_poll_interval,omitempty"`
+	DeviceMaxWait              Duration `json:"device_max_wait,omitempty" yaml:"device_max_wait,omitempty"`
+	PushedAuthorizationRequest Duration `json:"pushed_authorization_request,omitempty" yaml:"pushed_authorization_request,omitempty"`
+	BackchannelAuthentication  Duration `json:"backchannel_authentication,omitempty" yaml:"backchannel_authentication,omitempty"`
+	BackchannelPollInterval    Duration `json:"backchannel_poll_interval,omitempty" yaml:"backchannel_poll_interval,omitempty"`
+	SessionAuthentication      Duration `json:"session_authentication,omitempty" yaml:"session_authentication,omitempty"`
+	SessionRememberMe          Duration `json:"session_remember_me,omitempty" yaml:"session_remember_me,omitempty"`
+	SessionReauthenticationAge Duration `json:"session_reauthentication_age,omitempty" yaml:"session_reauthentication_age,omitempty"`
+}
+
+// Duration is a [time.Duration] written as string, such as 5m or 1h30m.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load reads the configuration from the file, YAML for the .yaml and .yml
+// extensions and JSON otherwise, applies the environment variables
+// with the [DefaultEnvPrefix] and validates it.
+func Load(path string) (*ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(data, formatOf(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err = config.ApplyEnv(DefaultEnvPrefix); err != nil {
+		return nil, err
+	}
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatYAML
+)
+
+func formatOf(path string) Format {
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// Parse decodes the configuration, rejecting unknown fields.
+func Parse(data []byte, format Format) (*ProviderConfig, error) {
+	config := new(ProviderConfig)
+	if err := decode(data, format, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func decode(data []byte, format Format, v any) error {
+	var err error
+	switch format {
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(v)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(v)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// Validate checks the configuration and returns all problems at once,
+// wrapped in [ErrInvalidConfig].
+func (c *ProviderConfig) Validate() error {
+	var errs []error
+	switch {
+	case c.Issuer == "" && c.IssuerPath == "":
+		errs = append(errs, errors.New("issuer or issuer_path is required"))
+	case c.Issuer != "" && c.IssuerPath != "":
+		errs = append(errs, errors.New("issuer and issuer_path are mutually exclusive"))
+	case c.Issuer != "":
+		if err := op.ValidateIssuer(c.Issuer, c.Insecure); err != nil {
+			errs = append(errs, fmt.Errorf("issuer: %w", err))
+		}
+	}
+	if _, err := c.cryptoKey(); err != nil {
+		errs = append(errs, fmt.Errorf("crypto_key: %w", err))
+	}
+	for i, key := range c.SigningKeys {
+		if _, err := key.load(); err != nil {
+			errs = append(errs, fmt.Errorf("signing_keys[%d]: %w", i, err))
+		}
+	}
+	for _, locale := range c.SupportedUILocales {
+		if _, err := language.Parse(locale); err != nil {
+			errs = append(errs, fmt.Errorf("supported_ui_locales: %w", err))
+		}
+	}
+	for name, path := range map[string]*string{
+		"authorization": c.Endpoints.Authorization,
+		"token":         c.Endpoints.Token,
+		"jwks_uri":      c.Endpoints.JwksURI,
+	} {
+		if path != nil && *path == "" {
+			errs = append(errs, fmt.Errorf("endpoints: %s is required", name))
+		}
+	}
+	switch c.Device.UserCode {
+	case "", "base20", "digits":
+	default:
+		errs = append(errs, fmt.Errorf("device: unknown user_code %q", c.Device.UserCode))
+	}
+	if c.Features.PushedAuthorizationRequired && !c.Features.PushedAuthorization {
+		errs = append(errs, errors.New("features: pushed_authorization_required requires pushed_authorization"))
+	}
+	if c.Features.BackChannelLogoutSession && !c.Features.BackChannelLogout {
+		errs = append(errs, errors.New("features: backchannel_logout_session requires backchannel_logout"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Config returns the [op.Config] of the Provider.
+func (c *ProviderConfig) Config() (*op.Config, error) {
+	cryptoKey, err := c.cryptoKey()
+	if err != nil {
+		return nil, err
+	}
+	locales := make([]language.Tag, 0, len(c.SupportedUILocales))
+	for _, locale := range c.SupportedUILocales {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return nil, err
+		}
+		locales = append(locales, tag)
+	}
+	userCode := op.UserCodeBase20
+	if c.Device.UserCode == "digits" {
+		userCode = op.UserCodeDigits
+	}
+	return &op.Config{
+		CryptoKey:                         cryptoKey,
+		DefaultLogoutRedirectURI:          c.DefaultLogoutRedirectURI,
+		CodeMethodS256:                    c.Features.CodeMethodS256,
+		AuthMethodPost:                    c.Features.AuthMethodPost,
+		AuthMethodPrivateKeyJWT:           c.Features.AuthMethodPrivateKeyJWT,
+		GrantTypeRefreshToken:             c.Features.GrantTypeRefreshToken,
+		RequestObjectSupported:            c.Features.RequestObject,
+		SupportedUILocales:                locales,
+		SupportedClaims:                   c.SupportedClaims,
+		SupportedScopes:                   c.SupportedScopes,
+		BackChannelLogoutSupported:        c.Features.BackChannelLogout,
+		BackChannelLogoutSessionSupported: c.Features.BackChannelLogoutSession,
+		JWTAuthorizationResponseSupported: c.Features.JWTAuthorizationResponse,
+		ClientSigningAlgorithms:           c.ClientSigningAlgorithms,
+		DeviceAuthorization: op.DeviceAuthorizationConfig{
+			Lifetime:                        durationOr(c.Lifetimes.DeviceCode, 5*time.Minute),
+			PollInterval:                    durationOr(c.Lifetimes.DevicePollInterval, 5*time.Second),
+			MaxWait:                         time.Duration(c.Lifetimes.DeviceMaxWait),
+			UserFormPath:                    c.Device.UserFormPath,
+			UserCode:                        userCode,
+			VerificationURICompleteTemplate: c.Device.VerificationURICompleteTemplate,
+		},
+	}, nil
+}
+
+// IssuerFunc returns the issuer function for [op.NewProvider].
+func (c *ProviderConfig) IssuerFunc() func(insecure bool) (op.IssuerFromRequest, error) {
+	if c.IssuerPath != "" {
+		return op.IssuerFromForwardedOrHost(c.IssuerPath)
+	}
+	return op.StaticIssuer(c.Issuer)
+}
+
+// Options returns the [op.Option]s of the features and lifetimes.
+func (c *ProviderConfig) Options() []op.Option {
+	var options []op.Option
+	if c.Insecure {
+		options = append(options, op.WithAllowInsecure())
+	}
+	if c.Features.JSONRequestBodies {
+		options = append(options, op.WithJSONRequestBodies())
+	}
+	if c.Features.DPoP {
+		options = append(options, op.WithDPoP())
+	}
+	if c.Features.ScopeArrayClaim {
+		options = append(options, op.WithScopeArrayClaim())
+	}
+	if c.Features.AudienceSplitting {
+		options = append(options, op.WithAudienceSplitting())
+	}
+	if c.Features.SignedMetadata {
+		options = append(options, op.WithSignedMetadata(op.SignedMetadataConfig{}))
+	}
+	if len(c.TokenExchangeRules) > 0 {
+		rules := make([]op.TokenExchangeRule, len(c.TokenExchangeRules))
+		for i, rule := range c.TokenExchangeRules {
+			rules[i] = op.TokenExchangeRule{
+				Clients:   rule.Clients,
+				Audiences: rule.Audiences,
+				Scopes:    rule.Scopes,
+			}
+			for _, tokenType := range rule.SubjectTokenTypes {
+				rules[i].SubjectTokenTypes = append(rules[i].SubjectTokenTypes, oidc.TokenType(tokenType))
+			}
+		}
+		options = append(options, op.WithTokenExchangePolicy(op.TokenExchangePolicy{Rules: rules}))
+	}
+	if c.Features.RefreshTokenGrantRequired {
+		options = append(options, op.WithRefreshTokenGrantRequired())
+	}
+	if c.Features.PushedAuthorization {
+		options = append(options, op.WithPushedAuthorizationRequests(op.PushedAuthorizationConfig{
+			Lifetime: time.Duration(c.Lifetimes.PushedAuthorizationRequest),
+			Required: c.Features.PushedAuthorizationRequired,
+		}))
+	}
+	if c.Features.BackchannelAuthentication {
+		options = append(options, op.WithBackchannelAuthentication(op.BackchannelAuthenticationConfig{
+			Lifetime:     time.Duration(c.Lifetimes.BackchannelAuthentication),
+			PollInterval: time.Duration(c.Lifetimes.BackchannelPollInterval),
+		}))
+	}
+	if l := c.Lifetimes; l.SessionAuthentication > 0 || l.SessionRememberMe > 0 || l.SessionReauthenticationAge > 0 {
+		options = append(options, op.WithSessionPolicy(op.SessionPolicy{
+			AuthenticationLifetime: time.Duration(l.SessionAuthentication),
+			RememberMeLifetime:     time.Duration(l.SessionRememberMe),
+			ReauthenticationAge:    time.Duration(l.SessionReauthenticationAge),
+		}))
+	}
+	return options
+}
+
+// EndpointsConfig returns [op.DefaultEndpoints] with the overrides of Endpoints.
+func (c *ProviderConfig) EndpointsConfig() op.Endpoints {
+	endpoints := *op.DefaultEndpoints
+	for _, e := range []struct {
+		path     *string
+		endpoint **op.Endpoint
+	}{
+		{c.Endpoints.Authorization, &endpoints.Authorization},
+		{c.Endpoints.Token, &endpoints.Token},
+		{c.Endpoints.Introspection, &endpoints.Introspection},
+		{c.Endpoints.Userinfo, &endpoints.Userinfo},
+		{c.Endpoints.Revocation, &endpoints.Revocation},
+		{c.Endpoints.EndSession, &endpoints.EndSession},
+		{c.Endpoints.CheckSessionIframe, &endpoints.CheckSessionIframe},
+		{c.Endpoints.JwksURI, &endpoints.JwksURI},
+		{c.Endpoints.DeviceAuthorization, &endpoints.DeviceAuthorization},
+		{c.Endpoints.PushedAuthorization, &endpoints.PushedAuthorization},
+		{c.Endpoints.BackchannelAuthentication, &endpoints.BackchannelAuthentication},
+		{c.Endpoints.Registration, &endpoints.Registration},
+	} {
+		switch {
+		case e.path == nil:
+		case *e.path == "":
+			*e.endpoint = nil
+		default:
+			*e.endpoint = op.NewEndpoint(*e.path)
+		}
+	}
+	return endpoints
+}
+
+// NewProvider creates the Provider with the storage and the options of the configuration,
+// followed by opts. The configured endpoints and signing keys are set on the Provider.
+func (c *ProviderConfig) NewProvider(storage op.Storage, opts ...op.Option) (*op.Provider, error) {
+	config, err := c.Config()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := op.NewProvider(config, storage, c.IssuerFunc(), append(c.Options(), opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	if c.Endpoints != (Endpoints{}) {
+		if err = provider.SetEndpoints(c.EndpointsConfig()); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.SigningKeys) > 0 {
+		keys := make([]op.Key, len(c.SigningKeys))
+		var signing op.SigningKey
+		for i, ref := range c.SigningKeys {
+			key, err := ref.load()
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				signing = key
+			}
+			keys[i] = key.public()
+		}
+		if err = provider.SetSigningKeys(signing, keys...); err != nil {
+			return nil, err
+		}
+	}
+	return provider, nil
+}
+
+func durationOr(d Duration, fallback time.Duration) time.Duration {
+	if d > 0 {
+		return time.Duration(d)
+	}
+	return fallback
+}
@@ -0,0 +1,199 @@
+package opconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+const testIssuer = "https://localhost:9998/"
+
+func writePrivateKey(t *testing.T, dir string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "signing.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := writePrivateKey(t, dir)
+	configPath := filepath.Join(dir, "provider.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+issuer: `+testIssuer+`
+crypto_key:
+  value: 000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f
+signing_keys:
+  - id: key1
+    private_key:
+      file: `+keyPath+`
+supported_ui_locales: [en, de]
+token_exchange_rules:
+  - clients: [gateway]
+    audiences: ["https://api.example.com/*"]
+    subject_token_types: ["urn:ietf:params:oauth:token-type:access_token"]
+features:
+  code_method_s256: true
+  dpop: true
+  signed_metadata: true
+endpoints:
+  token: /token
+  end_session: ""
+lifetimes:
+  device_code: 10m
+  session_authentication: 1h
+`), 0o600))
+	t.Setenv("OIDC_FEATURES_SCOPE_ARRAY_CLAIM", "true")
+	t.Setenv("OIDC_LIFETIMES_DEVICE_POLL_INTERVAL", "2s")
+
+	config, err := Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, Duration(10*time.Minute), config.Lifetimes.DeviceCode)
+	assert.True(t, config.Features.ScopeArrayClaim)
+
+	provider, err := config.NewProvider(storage.NewStorage(storage.NewUserStore(testIssuer)))
+	require.NoError(t, err)
+	assert.True(t, provider.CodeMethodS256Supported())
+	assert.True(t, provider.DPoP())
+	assert.NotNil(t, provider.SignedMetadata())
+	assert.True(t, provider.ScopeArrayClaim())
+	assert.Equal(t, 10*time.Minute, provider.DeviceAuthorization().Lifetime)
+	assert.Equal(t, 2*time.Second, provider.DeviceAuthorization().PollInterval)
+	assert.Equal(t, time.Hour, provider.SessionPolicy().AuthenticationLifetime)
+	assert.Equal(t, "/token", provider.TokenEndpoint().Relative())
+	assert.Nil(t, provider.EndSessionEndpoint())
+	require.Len(t, provider.TokenExchangePolicy().Rules, 1)
+	assert.Equal(t, []oidc.TokenType{oidc.AccessTokenType}, provider.TokenExchangePolicy().Rules[0].SubjectTokenTypes)
+	assert.Equal(t, op.DefaultEndpoints.Userinfo, provider.UserinfoEndpoint())
+
+	signing, err := provider.SigningKey(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "key1", signing.ID())
+	assert.Equal(t, jose.ES256, signing.SignatureAlgorithm())
+	keys, err := provider.KeySet(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "sig", keys[0].Use())
+}
+
+func TestParse_JSON(t *testing.T) {
+	config, err := Parse([]byte(`{"issuer_path":"/oidc","crypto_key":{"env":"TEST_CRYPTO_KEY"},"lifetimes":{"device_code":"1m"}}`), FormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, "/oidc", config.IssuerPath)
+	assert.Equal(t, Duration(time.Minute), config.Lifetimes.DeviceCode)
+
+	_, err = Parse([]byte(`{"issuer":"https://example.com","unknown":true}`), FormatJSON)
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestSecretRef_String(t *testing.T) {
+	secret := SecretRef{Value: "s3cr3t"}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		assert.NotContains(t, fmt.Sprintf(format, secret), "s3cr3t", format)
+		assert.NotContains(t, fmt.Sprintf(format, ProviderConfig{CryptoKey: secret}), "s3cr3t", format)
+	}
+	assert.Equal(t, "value:[REDACTED]", secret.String())
+	assert.Equal(t, "env:CRYPTO_KEY", SecretRef{Env: "CRYPTO_KEY"}.String())
+	assert.Equal(t, "file:/run/secrets/key", SecretRef{File: "/run/secrets/key"}.String())
+}
+
+func TestProviderConfig_Validate(t *testing.T) {
+	t.Setenv("TEST_CRYPTO_KEY", "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+	tests := []struct {
+		name    string
+		config  ProviderConfig
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			config: ProviderConfig{
+				Issuer:    testIssuer,
+				CryptoKey: SecretRef{Env: "TEST_CRYPTO_KEY"},
+			},
+		},
+		{
+			name:    "missing issuer",
+			config:  ProviderConfig{CryptoKey: SecretRef{Env: "TEST_CRYPTO_KEY"}},
+			wantErr: true,
+		},
+		{
+			name: "insecure issuer",
+			config: ProviderConfig{
+				Issuer:    "http://example.com",
+				CryptoKey: SecretRef{Env: "TEST_CRYPTO_KEY"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "short crypto key",
+			config:  ProviderConfig{Issuer: testIssuer, CryptoKey: SecretRef{Value: "short"}},
+			wantErr: true,
+		},
+		{
+			name: "required endpoint disabled",
+			config: ProviderConfig{
+				Issuer:    testIssuer,
+				CryptoKey: SecretRef{Env: "TEST_CRYPTO_KEY"},
+				Endpoints: Endpoints{Token: new(string)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "required pushed authorization without the feature",
+			config: ProviderConfig{
+				Issuer:    testIssuer,
+				CryptoKey: SecretRef{Env: "TEST_CRYPTO_KEY"},
+				Features:  Features{PushedAuthorizationRequired: true},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestProviderConfig_ApplyEnv(t *testing.T) {
+	t.Setenv("TEST_ISSUER", "https://example.com")
+	t.Setenv("TEST_SUPPORTED_SCOPES", "openid, email")
+	t.Setenv("TEST_ENDPOINTS_REVOCATION", "")
+	t.Setenv("TEST_CRYPTO_KEY_FILE", "/run/secrets/crypto_key")
+
+	config := new(ProviderConfig)
+	require.NoError(t, config.ApplyEnv("test"))
+	assert.Equal(t, "https://example.com", config.Issuer)
+	assert.Equal(t, []string{"openid", "email"}, config.SupportedScopes)
+	require.NotNil(t, config.Endpoints.Revocation)
+	assert.Empty(t, *config.Endpoints.Revocation)
+	assert.Equal(t, "/run/secrets/crypto_key", config.CryptoKey.File)
+
+	t.Setenv("TEST_FEATURES_DPOP", "maybe")
+	assert.ErrorIs(t, config.ApplyEnv("test"), ErrInvalidConfig)
+}
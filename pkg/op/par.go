@@ -0,0 +1,236 @@
+package op
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// DefaultPushedAuthRequestLifetime is the default lifetime of the request_uri
+// of pushed auth requests, as recommended by RFC 9126, section 2.2.
+const DefaultPushedAuthRequestLifetime = 60 * time.Second
+
+// PushedAuthorizationConfig configures the Pushed Authorization Requests (RFC 9126),
+// see [WithPushedAuthorizationRequests].
+type PushedAuthorizationConfig struct {
+	// Lifetime of the request_uri, defaults to [DefaultPushedAuthRequestLifetime].
+	Lifetime time.Duration
+	// Required rejects auth requests which were not pushed before.
+	Required bool
+}
+
+func (c *PushedAuthorizationConfig) lifetime() time.Duration {
+	if c.Lifetime > 0 {
+		return c.Lifetime
+	}
+	return DefaultPushedAuthRequestLifetime
+}
+
+type pushedAuthorizationGetter interface {
+	PushedAuthorization() *PushedAuthorizationConfig
+}
+
+// pushedAuthorization returns the [PushedAuthorizationConfig],
+// nil if Pushed Authorization Requests are disabled.
+func pushedAuthorization(v any) *PushedAuthorizationConfig {
+	if getter, ok := v.(pushedAuthorizationGetter); ok {
+		return getter.PushedAuthorization()
+	}
+	return nil
+}
+
+type pushedAuthorizationEndpointGetter interface {
+	PushedAuthorizationEndpoint() *Endpoint
+}
+
+// pushedAuthorizationEndpoint returns the discovered URL of the endpoint,
+// empty if Pushed Authorization Requests are disabled.
+func pushedAuthorizationEndpoint(config any, endpoint *Endpoint, issuer string) string {
+	if pushedAuthorization(config) == nil {
+		return ""
+	}
+	return endpoint.Absolute(issuer)
+}
+
+// pushedAuthorizationEndpointOf returns the endpoint of the provider, if any.
+func pushedAuthorizationEndpointOf(config any) *Endpoint {
+	if getter, ok := config.(pushedAuthorizationEndpointGetter); ok {
+		return getter.PushedAuthorizationEndpoint()
+	}
+	return nil
+}
+
+func pushedAuthorizationRequired(config any) bool {
+	par := pushedAuthorization(config)
+	return par != nil && par.Required
+}
+
+// 32 bytes gives 256 bit of entropy.
+const pushedRequestURIBytes = 32
+
+func newPushedRequestURI(ctx context.Context) (string, error) {
+	value, err := randomString(ctx, pushedRequestURIBytes)
+	if err != nil {
+		return "", err
+	}
+	return oidc.RequestURIPrefixPushed + value, nil
+}
+
+func PushedAuthorizationHandler(o OpenIDProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := PushedAuthorization(w, r, o); err != nil {
+			RequestError(w, r, err, o.Logger())
+		}
+	}
+}
+
+// PushedAuthorization handles the Pushed Authorization Request of an authenticated client,
+// storing the validated auth request for the authorization endpoint
+// and responding with its request_uri (RFC 9126, section 2).
+func PushedAuthorization(w http.ResponseWriter, r *http.Request, o OpenIDProvider) error {
+	ctx, span := tracer.Start(r.Context(), "PushedAuthorization")
+	r = r.WithContext(ctx)
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		return oidc.ErrInvalidRequest().WithDescription("pushed authorization requests must be posted")
+	}
+	client, err := authenticatePushingClient(r, o)
+	if err != nil {
+		return err
+	}
+	authReq, err := ParseAuthorizeRequest(r, o.Decoder())
+	if err != nil {
+		return err
+	}
+	response, err := pushAuthRequest(ctx, o, authReq, client)
+	if err != nil {
+		return err
+	}
+	WriteJSON(w, response, http.StatusCreated)
+	return nil
+}
+
+// authenticatePushingClient authenticates the client of the Pushed Authorization Request
+// with the methods of the token endpoint. Public clients only send their client_id.
+func authenticatePushingClient(r *http.Request, o OpenIDProvider) (Client, error) {
+	clientID, authenticated, err := ClientIDFromRequest(r, o)
+	if err != nil {
+		return nil, err
+	}
+	client, err := o.Storage().GetClientByClientID(r.Context(), clientID)
+	if err != nil {
+		return nil, oidc.ErrInvalidClient().WithParent(err)
+	}
+	if authenticated || client.AuthMethod() == oidc.AuthMethodNone {
+		return client, nil
+	}
+	if isTLSAuthMethod(client.AuthMethod()) {
+		if err = AuthorizeTLSClient(r.Context(), client, mtlsConfig(o)); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	if client.AuthMethod() != oidc.AuthMethodPost || !o.AuthMethodPostSupported() {
+		return nil, oidc.ErrInvalidClient().WithDescription("client authentication required")
+	}
+	if err = AuthorizeClientIDSecret(r.Context(), clientID, r.Form.Get("client_secret"), o.Storage()); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// pushAuthRequest validates the auth request pushed by the client
+// and stores it under a new request_uri.
+func pushAuthRequest(ctx context.Context, o OpenIDProvider, authReq *oidc.AuthRequest, client Client) (*oidc.PushedAuthorizationResponse, error) {
+	ctx, span := tracer.Start(ctx, "pushAuthRequest")
+	defer span.End()
+
+	config := pushedAuthorization(o)
+	storage, ok := o.Storage().(PushedAuthRequestStorage)
+	if config == nil || !ok {
+		return nil, oidc.ErrInvalidRequest().WithDescription("pushed authorization requests not supported")
+	}
+	if authReq.RequestURI != "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("request_uri must not be pushed")
+	}
+	if authReq.RequestParam != "" {
+		if !o.RequestObjectSupported() {
+			return nil, oidc.ErrRequestNotSupported()
+		}
+		if err := parseRequestObject(ctx, authReq, o.Storage(), IssuerFromContext(ctx), allowInsecure(o), clientJWKSCache(o)); err != nil {
+			return nil, err
+		}
+	}
+	if authReq.ClientID == "" {
+		authReq.ClientID = client.GetID()
+	}
+	if authReq.ClientID != client.GetID() {
+		return nil, oidc.ErrInvalidRequest().WithDescription("client_id does not match the authenticated client")
+	}
+	if authReq.RedirectURI == "" {
+		return nil, oidc.ErrInvalidRequest().WithParent(ErrAuthReqMissingRedirectURI).WithDescription(ErrAuthReqMissingRedirectURI.Error())
+	}
+	if _, err := ValidateAuthRequestClient(ctx, authReq, client, o.IDTokenHintVerifier(ctx)); err != nil {
+		return nil, err
+	}
+	if err := ValidateAuthReqPublicClient(client, authReq, publicClientPolicy(o)); err != nil {
+		return nil, err
+	}
+	if err := ValidateAuthReqResponseMode(o, client, authReq); err != nil {
+		return nil, err
+	}
+	if err := ValidateAuthReqAuthorizationDetails(o, authReq); err != nil {
+		return nil, err
+	}
+	requestURI, err := newPushedRequestURI(ctx)
+	if err != nil {
+		return nil, oidc.ErrServerError().WithDescription("unable to generate request_uri").WithParent(err)
+	}
+	lifetime := config.lifetime()
+	if lifetimes := clientFlowLifetimes(o, client); lifetimes != nil && lifetimes.PushedAuthRequest > 0 {
+		lifetime = lifetimes.PushedAuthRequest
+	}
+	if err := storage.StorePushedAuthRequest(ctx, requestURI, authReq, time.Now().Add(lifetime)); err != nil {
+		return nil, oidc.ErrServerError().WithDescription("unable to save pushed auth request").WithParent(err)
+	}
+	return &oidc.PushedAuthorizationResponse{
+		RequestURI: requestURI,
+		ExpiresIn:  int(lifetime / time.Second),
+	}, nil
+}
+
+// resolvePushedAuthRequest returns the auth request pushed before
+// under the request_uri of the auth request, if any, which can only be used once,
+// so it is consumed, unless the auth request is only validated.
+// Auth requests which were not pushed are rejected, if [PushedAuthorizationConfig.Required].
+func resolvePushedAuthRequest(ctx context.Context, v any, storage Storage, authReq *oidc.AuthRequest, consume bool) (*oidc.AuthRequest, error) {
+	config := pushedAuthorization(v)
+	if authReq.RequestURI == "" {
+		if config != nil && config.Required {
+			return nil, oidc.ErrInvalidRequest().WithDescription("auth requests must be pushed to the pushed authorization request endpoint")
+		}
+		return authReq, nil
+	}
+	parStorage, ok := storage.(PushedAuthRequestStorage)
+	if config == nil || !ok {
+		return nil, oidc.ErrRequestURINotSupported()
+	}
+	lookup := parStorage.PushedAuthRequest
+	if consume {
+		lookup = parStorage.ConsumePushedAuthRequest
+	}
+	pushed, expires, err := lookup(ctx, authReq.RequestURI)
+	if err != nil {
+		return nil, oidc.ErrInvalidRequestURI().WithDescription("unknown or used request_uri").WithParent(err)
+	}
+	if time.Now().After(expires) {
+		return nil, oidc.ErrInvalidRequestURI().WithDescription("request_uri expired")
+	}
+	if pushed.ClientID != authReq.ClientID {
+		return nil, oidc.ErrInvalidRequestURI().WithDescription("request_uri was pushed by another client")
+	}
+	return pushed, nil
+}
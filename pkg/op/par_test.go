@@ -0,0 +1,127 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type parStorage struct {
+	Storage
+	requests map[string]pushedEntry
+}
+
+type pushedEntry struct {
+	authReq *oidc.AuthRequest
+	expires time.Time
+}
+
+func (s *parStorage) StorePushedAuthRequest(_ context.Context, requestURI string, authReq *oidc.AuthRequest, expires time.Time) error {
+	s.requests[requestURI] = pushedEntry{authReq, expires}
+	return nil
+}
+
+func (s *parStorage) ConsumePushedAuthRequest(_ context.Context, requestURI string) (*oidc.AuthRequest, time.Time, error) {
+	entry, ok := s.requests[requestURI]
+	if !ok {
+		return nil, time.Time{}, errors.New("not found")
+	}
+	delete(s.requests, requestURI)
+	return entry.authReq, entry.expires, nil
+}
+
+func (s *parStorage) PushedAuthRequest(_ context.Context, requestURI string) (*oidc.AuthRequest, time.Time, error) {
+	entry, ok := s.requests[requestURI]
+	if !ok {
+		return nil, time.Time{}, errors.New("not found")
+	}
+	return entry.authReq, entry.expires, nil
+}
+
+type parConfig struct {
+	config *PushedAuthorizationConfig
+}
+
+func (c parConfig) PushedAuthorization() *PushedAuthorizationConfig {
+	return c.config
+}
+
+func Test_resolvePushedAuthRequest(t *testing.T) {
+	pushed := &oidc.AuthRequest{ClientID: "client1", RedirectURI: "https://example.com/callback", State: "state1"}
+	storage := &parStorage{requests: map[string]pushedEntry{
+		"urn:valid":   {pushed, time.Now().Add(time.Minute)},
+		"urn:expired": {pushed, time.Now().Add(-time.Second)},
+	}}
+	tests := []struct {
+		name    string
+		config  *PushedAuthorizationConfig
+		authReq *oidc.AuthRequest
+		want    *oidc.AuthRequest
+		wantErr func() *oidc.Error
+	}{
+		{
+			name:    "not pushed",
+			config:  &PushedAuthorizationConfig{},
+			authReq: &oidc.AuthRequest{ClientID: "client1"},
+			want:    &oidc.AuthRequest{ClientID: "client1"},
+		},
+		{
+			name:    "required",
+			config:  &PushedAuthorizationConfig{Required: true},
+			authReq: &oidc.AuthRequest{ClientID: "client1"},
+			wantErr: oidc.ErrInvalidRequest,
+		},
+		{
+			name:    "disabled",
+			authReq: &oidc.AuthRequest{ClientID: "client1", RequestURI: "urn:valid"},
+			wantErr: oidc.ErrRequestURINotSupported,
+		},
+		{
+			name:    "other client",
+			config:  &PushedAuthorizationConfig{},
+			authReq: &oidc.AuthRequest{ClientID: "client2", RequestURI: "urn:valid"},
+			wantErr: oidc.ErrInvalidRequestURI,
+		},
+		{
+			name:    "used",
+			config:  &PushedAuthorizationConfig{},
+			authReq: &oidc.AuthRequest{ClientID: "client1", RequestURI: "urn:valid"},
+			wantErr: oidc.ErrInvalidRequestURI,
+		},
+		{
+			name:    "expired",
+			config:  &PushedAuthorizationConfig{},
+			authReq: &oidc.AuthRequest{ClientID: "client1", RequestURI: "urn:expired"},
+			wantErr: oidc.ErrInvalidRequestURI,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePushedAuthRequest(context.Background(), parConfig{tt.config}, storage, tt.authReq, true)
+			if tt.wantErr != nil {
+				var oidcErr *oidc.Error
+				require.ErrorAs(t, err, &oidcErr)
+				assert.Equal(t, tt.wantErr().ErrorType, oidcErr.ErrorType)
+				assert.Equal(t, tt.wantErr().IsRedirectDisabled(), oidcErr.IsRedirectDisabled())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// validation does not consume the request_uri
+	storage.requests["urn:valid"] = pushedEntry{pushed, time.Now().Add(time.Minute)}
+	for _, consume := range []bool{false, false, true} {
+		got, err := resolvePushedAuthRequest(context.Background(), parConfig{&PushedAuthorizationConfig{}}, storage, &oidc.AuthRequest{ClientID: "client1", RequestURI: "urn:valid"}, consume)
+		require.NoError(t, err)
+		assert.Equal(t, pushed, got)
+	}
+	assert.NotContains(t, storage.requests, "urn:valid")
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"errors"
 	"net/http"
-
-	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 )
 
 type ProbesFn func(context.Context) error
@@ -24,7 +22,7 @@ func Readiness(w http.ResponseWriter, r *http.Request, probes ...ProbesFn) {
 	ctx := r.Context()
 	for _, probe := range probes {
 		if err := probe(ctx); err != nil {
-			http.Error(w, "not ready", http.StatusInternalServerError)
+			WriteText(w, "not ready", http.StatusInternalServerError)
 			return
 		}
 	}
@@ -41,7 +39,7 @@ func ReadyStorage(s Storage) ProbesFn {
 }
 
 func ok(w http.ResponseWriter) {
-	httphelper.MarshalJSON(w, Status{"ok"})
+	WriteJSON(w, Status{"ok"}, http.StatusOK)
 }
 
 type Status struct {
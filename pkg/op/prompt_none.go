@@ -0,0 +1,86 @@
+package op
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// PromptNoneState is the state of the end-user's session, as reported by [PromptNoneStorage].
+type PromptNoneState int
+
+const (
+	// PromptNoneAuthenticated means the end-user has an active session and
+	// has granted consent. The auth request must be completed (done) by the Storage.
+	PromptNoneAuthenticated PromptNoneState = iota
+	PromptNoneLoginRequired
+	PromptNoneConsentRequired
+	PromptNoneInteractionRequired
+)
+
+// PromptNoneStorage is an optional additional interface that may be implemented by
+// implementors of Storage. It enables the prompt=none semantics of
+// OpenID Connect Core 1.0, section 3.1.2.1, where the OP must not display
+// any authentication or consent UI, for example for silent authentication in iframes.
+//
+// If not implemented, auth requests with prompt=none are redirected
+// to the Login UI, like any other request.
+type PromptNoneStorage interface {
+	// AuthorizeSilently checks the end-user's session, typically
+	// from the session cookie in the header of the auth request.
+	// When [PromptNoneAuthenticated] is returned, the Storage must
+	// have completed the auth request, as the Login UI would.
+	AuthorizeSilently(ctx context.Context, authReq AuthRequest, header http.Header) (PromptNoneState, error)
+}
+
+func (s PromptNoneState) toError() error {
+	switch s {
+	case PromptNoneAuthenticated:
+		return nil
+	case PromptNoneLoginRequired:
+		return oidc.ErrLoginRequired().WithDescription("The end-user is not logged in.")
+	case PromptNoneConsentRequired:
+		return oidc.ErrConsentRequired().WithDescription("The end-user did not grant consent.")
+	default:
+		return oidc.ErrInteractionRequired().WithDescription("The end-user must interact with the authorization server.")
+	}
+}
+
+// authorizeSilently handles auth requests with prompt=none,
+// if the storage implements [PromptNoneStorage].
+// It returns false if the request must be redirected to the Login UI instead.
+// On error, the auth request is deleted.
+func authorizeSilently(ctx context.Context, storage Storage, authReq AuthRequest, prompts []string, header http.Header) (bool, error) {
+	promptNone, ok := storage.(PromptNoneStorage)
+	if !ok || !slices.Contains(prompts, oidc.PromptNone) {
+		return false, nil
+	}
+	ctx, span := tracer.Start(ctx, "authorizeSilently")
+	defer span.End()
+
+	state, err := promptNone.AuthorizeSilently(ctx, authReq, header)
+	if err == nil {
+		err = state.toError()
+	}
+	if err != nil {
+		// the auth request can't be completed anymore
+		_ = storage.DeleteAuthRequest(ctx, authReq.GetID())
+		return true, oidc.DefaultToServerError(err, "unable to check session")
+	}
+	return true, nil
+}
+
+// authorizeWithoutLogin decides if the auth request is completed without the Login UI:
+// with prompt=none, if the storage implements [PromptNoneStorage],
+// or from the session of the end-user, see [SessionPolicy].
+// It returns false if the request must be redirected to the Login UI instead.
+// Both the Authorize handler and the [LegacyServer] rely on it.
+func authorizeWithoutLogin(ctx context.Context, provider any, storage Storage, req AuthRequest, authReq *oidc.AuthRequest, userID string, header http.Header) (bool, error) {
+	silent, err := authorizeSilently(ctx, storage, req, authReq.Prompt, header)
+	if err != nil || silent {
+		return silent, err
+	}
+	return authorizeFromSession(ctx, storage, sessionPolicy(provider), sessionSelector(provider), req, authReq, userID, header)
+}
@@ -0,0 +1,111 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+type promptNoneAuthRequest struct {
+	AuthRequest
+}
+
+func (promptNoneAuthRequest) GetID() string { return "id1" }
+
+type promptNoneStorage struct {
+	Storage
+	state   PromptNoneState
+	err     error
+	deleted []string
+}
+
+func (s *promptNoneStorage) AuthorizeSilently(_ context.Context, _ AuthRequest, header http.Header) (PromptNoneState, error) {
+	if header.Get("Cookie") == "" {
+		return PromptNoneLoginRequired, nil
+	}
+	return s.state, s.err
+}
+
+func (s *promptNoneStorage) DeleteAuthRequest(_ context.Context, id string) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func Test_authorizeSilently(t *testing.T) {
+	withCookie := http.Header{"Cookie": {"session=1"}}
+	tests := []struct {
+		name       string
+		storage    Storage
+		prompts    []string
+		header     http.Header
+		wantSilent bool
+		wantErr    error
+	}{
+		{
+			name:    "storage not implemented",
+			storage: struct{ Storage }{},
+			prompts: []string{oidc.PromptNone},
+		},
+		{
+			name:    "no prompt none",
+			storage: &promptNoneStorage{},
+			prompts: []string{oidc.PromptLogin},
+			header:  withCookie,
+		},
+		{
+			name:       "authenticated",
+			storage:    &promptNoneStorage{state: PromptNoneAuthenticated},
+			prompts:    []string{oidc.PromptNone},
+			header:     withCookie,
+			wantSilent: true,
+		},
+		{
+			name:       "login required",
+			storage:    &promptNoneStorage{state: PromptNoneAuthenticated},
+			prompts:    []string{oidc.PromptNone},
+			header:     http.Header{},
+			wantSilent: true,
+			wantErr:    oidc.ErrLoginRequired(),
+		},
+		{
+			name:       "consent required",
+			storage:    &promptNoneStorage{state: PromptNoneConsentRequired},
+			prompts:    []string{oidc.PromptNone},
+			header:     withCookie,
+			wantSilent: true,
+			wantErr:    oidc.ErrConsentRequired(),
+		},
+		{
+			name:       "interaction required",
+			storage:    &promptNoneStorage{state: PromptNoneInteractionRequired},
+			prompts:    []string{oidc.PromptNone},
+			header:     withCookie,
+			wantSilent: true,
+			wantErr:    oidc.ErrInteractionRequired(),
+		},
+		{
+			name:       "storage error",
+			storage:    &promptNoneStorage{err: errors.New("db down")},
+			prompts:    []string{oidc.PromptNone},
+			header:     withCookie,
+			wantSilent: true,
+			wantErr:    oidc.ErrServerError(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			silent, err := authorizeSilently(context.Background(), tt.storage, promptNoneAuthRequest{}, tt.prompts, tt.header)
+			assert.Equal(t, tt.wantSilent, silent)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, []string{"id1"}, tt.storage.(*promptNoneStorage).deleted)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package op
+
+import (
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// PublicClientRefreshTokens defines if and how refresh tokens
+// are issued to public clients.
+type PublicClientRefreshTokens int
+
+const (
+	// PublicClientRefreshTokensAllow leaves the issuance
+	// and rotation of refresh tokens to the [Storage] implementation.
+	PublicClientRefreshTokensAllow PublicClientRefreshTokens = iota
+	// PublicClientRefreshTokensRotate only allows refresh tokens
+	// which are rotated on every use.
+	// A refresh_token grant fails if the Storage returns the same refresh token,
+	// the access token created together with it is revoked.
+	PublicClientRefreshTokensRotate
+	// PublicClientRefreshTokensDeny prevents issuance of refresh tokens
+	// to public clients and rejects the refresh_token grant for them.
+	PublicClientRefreshTokensDeny
+)
+
+// PublicClientPolicy is enforced for public clients,
+// which are clients with the [oidc.AuthMethodNone] authentication method.
+// See [WithPublicClientPolicy].
+type PublicClientPolicy struct {
+	RefreshTokens PublicClientRefreshTokens
+	// RequirePKCE rejects authorization requests of public clients
+	// without a code_challenge, instead of failing at the token endpoint.
+	RequirePKCE bool
+	// RequireS256 rejects the `plain` code_challenge_method for public clients.
+	RequireS256 bool
+}
+
+// DefaultPublicClientPolicy is used when no other policy
+// was set with [WithPublicClientPolicy].
+var DefaultPublicClientPolicy = PublicClientPolicy{
+	RefreshTokens: PublicClientRefreshTokensAllow,
+}
+
+type publicClientPolicyGetter interface {
+	PublicClientPolicy() *PublicClientPolicy
+}
+
+func publicClientPolicy(v any) *PublicClientPolicy {
+	if p, ok := v.(publicClientPolicyGetter); ok {
+		if policy := p.PublicClientPolicy(); policy != nil {
+			return policy
+		}
+	}
+	return &DefaultPublicClientPolicy
+}
+
+func isPublicClient(client any) bool {
+	c, ok := client.(interface{ AuthMethod() oidc.AuthMethod })
+	return ok && c.AuthMethod() == oidc.AuthMethodNone
+}
+
+// ValidateAuthReqPublicClient validates the code_challenge of the auth request
+// against the policy, when the client is a public client.
+func ValidateAuthReqPublicClient(client Client, authReq *oidc.AuthRequest, policy *PublicClientPolicy) error {
+	if policy == nil || !isPublicClient(client) {
+		return nil
+	}
+	if policy.RequirePKCE && authReq.CodeChallenge == "" {
+		return oidc.ErrInvalidRequest().WithDescription("code_challenge required for public clients")
+	}
+	if policy.RequireS256 && authReq.CodeChallenge != "" && authReq.CodeChallengeMethod != oidc.CodeChallengeMethodS256 {
+		return oidc.ErrInvalidRequest().WithDescription("code_challenge_method S256 required for public clients")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package op
+
+import (
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAuthReqPublicClient(t *testing.T) {
+	strict := &PublicClientPolicy{
+		RequirePKCE: true,
+		RequireS256: true,
+	}
+	tests := []struct {
+		name    string
+		client  Client
+		authReq *oidc.AuthRequest
+		policy  *PublicClientPolicy
+		wantErr bool
+	}{
+		{
+			name:    "nil policy",
+			client:  newClient(clientTypeNative),
+			authReq: &oidc.AuthRequest{},
+			policy:  nil,
+		},
+		{
+			name:    "default policy",
+			client:  newClient(clientTypeNative),
+			authReq: &oidc.AuthRequest{},
+			policy:  &DefaultPublicClientPolicy,
+		},
+		{
+			name:    "confidential client",
+			client:  newClient(clientTypeWeb),
+			authReq: &oidc.AuthRequest{},
+			policy:  strict,
+		},
+		{
+			name:    "missing code_challenge",
+			client:  newClient(clientTypeNative),
+			authReq: &oidc.AuthRequest{},
+			policy:  strict,
+			wantErr: true,
+		},
+		{
+			name:   "plain method",
+			client: newClient(clientTypeNative),
+			authReq: &oidc.AuthRequest{
+				CodeChallenge:       "challenge",
+				CodeChallengeMethod: oidc.CodeChallengeMethodPlain,
+			},
+			policy:  strict,
+			wantErr: true,
+		},
+		{
+			name:   "S256 method",
+			client: newClient(clientTypeNative),
+			authReq: &oidc.AuthRequest{
+				CodeChallenge:       "challenge",
+				CodeChallengeMethod: oidc.CodeChallengeMethodS256,
+			},
+			policy: strict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAuthReqPublicClient(tt.client, tt.authReq, tt.policy)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidRequest())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_publicClientPolicy(t *testing.T) {
+	assert.Equal(t, &DefaultPublicClientPolicy, publicClientPolicy(struct{}{}))
+	policy := PublicClientPolicy{RefreshTokens: PublicClientRefreshTokensDeny}
+	assert.Equal(t, &policy, publicClientPolicy(&Provider{publicClientPolicy: &policy}))
+}
@@ -0,0 +1,74 @@
+package op
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+)
+
+type randomKey struct{}
+
+// ContextWithRandom returns a context with the random source, see [RandomFromContext].
+func ContextWithRandom(ctx context.Context, random io.Reader) context.Context {
+	return context.WithValue(ctx, randomKey{}, random)
+}
+
+// RandomFromContext returns the random source of the request, set by [WithRandom],
+// or [rand.Reader] without.
+// The Provider generates the request_uri of pushed auth requests, the auth_req_id,
+// device and user codes, registered clients, logout token IDs and correlation IDs
+// with it. Storage implementations should use it for the codes, tokens and IDs
+// they generate, so the random source can be verified and replaced in tests.
+//
+// Signatures and keys are created by the crypto packages with [rand.Reader].
+func RandomFromContext(ctx context.Context) io.Reader {
+	if random, ok := ctx.Value(randomKey{}).(io.Reader); ok {
+		return random
+	}
+	return rand.Reader
+}
+
+// randomString returns nBytes of the random source of the context, base64url encoded.
+func randomString(ctx context.Context, nBytes int) (string, error) {
+	return crypto.RandomString(RandomFromContext(ctx), nBytes)
+}
+
+// randomHandler returns the middleware, which sets the random source to the requests.
+func randomHandler(random io.Reader) HttpInterceptor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ContextWithRandom(r.Context(), random)))
+		})
+	}
+}
+
+type randomGetter interface {
+	Random() io.Reader
+}
+
+func providerRandom(v any) io.Reader {
+	if g, ok := v.(randomGetter); ok {
+		return g.Random()
+	}
+	return nil
+}
+
+// WithRandom sets the random source of the Provider, see [RandomFromContext].
+// It must be a cryptographically secure source, like a FIPS 140 validated DRBG.
+// [crypto.DeterministicRandom] makes the generated values reproducible in tests.
+func WithRandom(random io.Reader) Option {
+	return func(o *Provider) error {
+		o.random = random
+		return nil
+	}
+}
+
+// WithServerRandom sets the random source of the Server, see [WithRandom].
+func WithServerRandom(random io.Reader) ServerOption {
+	return func(s *webServer) {
+		s.random = random
+	}
+}
@@ -0,0 +1,53 @@
+package op_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestRandomFromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, rand.Reader, op.RandomFromContext(ctx))
+	random := crypto.DeterministicRandom("seed")
+	assert.Equal(t, random, op.RandomFromContext(op.ContextWithRandom(ctx, random)))
+}
+
+func TestWithRandom(t *testing.T) {
+	deviceAuthorization := func(seed string) *oidc.DeviceAuthorizationResponse {
+		provider, err := op.NewOpenIDProvider(testIssuer, testConfig,
+			storage.NewStorage(storage.NewUserStore(testIssuer)),
+			op.WithAllowInsecure(),
+			op.WithRandom(crypto.DeterministicRandom(seed)),
+		)
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodGet, "/device_authorization?"+url.Values{"scope": {oidc.ScopeOpenID}}.Encode(), nil)
+		r.SetBasicAuth("device", "secret")
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp oidc.DeviceAuthorizationResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return &resp
+	}
+	first := deviceAuthorization("seed")
+	second := deviceAuthorization("seed")
+	assert.Equal(t, first.DeviceCode, second.DeviceCode)
+	assert.Equal(t, first.UserCode, second.UserCode)
+
+	other := deviceAuthorization("other")
+	assert.NotEqual(t, first.DeviceCode, other.DeviceCode)
+	assert.NotEqual(t, first.UserCode, other.UserCode)
+}
@@ -0,0 +1,487 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/internal/security"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ClientRegistrationConfig configures the Dynamic Client Registration (RFC 7591)
+// and Management (RFC 7592), see [WithClientRegistration].
+type ClientRegistrationConfig struct {
+	// InitialAccessToken authorizes the registration requests by the initial access token,
+	// sent as bearer token (RFC 7591, section 3). Returning an error rejects the request.
+	// It is required, unless OpenRegistration is set.
+	InitialAccessToken func(ctx context.Context, token string) error
+	// OpenRegistration allows anyone to register clients without an initial access token,
+	// if InitialAccessToken is nil.
+	OpenRegistration bool
+	// SecretLifetime of the issued client secrets, zero never expires.
+	SecretLifetime time.Duration
+}
+
+func (c *ClientRegistrationConfig) validate() error {
+	if c.InitialAccessToken == nil && !c.OpenRegistration {
+		return errors.New("client registration requires InitialAccessToken or OpenRegistration")
+	}
+	return nil
+}
+
+func (c *ClientRegistrationConfig) secretExpiresAt(issuedAt time.Time) time.Time {
+	if c.SecretLifetime > 0 {
+		return issuedAt.Add(c.SecretLifetime)
+	}
+	return time.Time{}
+}
+
+// RegisteredClient is a client registered at the registration endpoint,
+// stored by the [ClientRegistrar].
+type RegisteredClient struct {
+	ClientID string
+	// ClientSecret is only issued to confidential clients.
+	ClientSecret     string
+	ClientIDIssuedAt time.Time
+	// ClientSecretExpiresAt is zero, if the secret does not expire.
+	ClientSecretExpiresAt time.Time
+	// RegistrationAccessToken authorizes the requests
+	// of the client to its client configuration endpoint.
+	RegistrationAccessToken string
+	Metadata                oidc.ClientMetadata
+}
+
+type clientRegistrationGetter interface {
+	ClientRegistration() *ClientRegistrationConfig
+}
+
+// clientRegistration returns the [ClientRegistrationConfig],
+// nil if Dynamic Client Registration is disabled.
+func clientRegistration(v any) *ClientRegistrationConfig {
+	if getter, ok := v.(clientRegistrationGetter); ok {
+		return getter.ClientRegistration()
+	}
+	return nil
+}
+
+type clientRegistrationEndpointGetter interface {
+	ClientRegistrationEndpoint() *Endpoint
+}
+
+// clientRegistrationEndpointOf returns the endpoint of the provider, if any.
+func clientRegistrationEndpointOf(config any) *Endpoint {
+	if getter, ok := config.(clientRegistrationEndpointGetter); ok {
+		return getter.ClientRegistrationEndpoint()
+	}
+	return nil
+}
+
+// clientRegistrationEndpoint returns the discovered URL of the endpoint,
+// empty if Dynamic Client Registration is disabled.
+func clientRegistrationEndpoint(config any, endpoint *Endpoint, issuer string) string {
+	if clientRegistration(config) == nil {
+		return ""
+	}
+	return endpoint.Absolute(issuer)
+}
+
+const (
+	// 16 bytes for the client_id, which is no secret.
+	registeredClientIDBytes = 16
+	// 32 bytes gives 256 bit of entropy for the client_secret
+	// and the registration_access_token.
+	registeredSecretBytes = 32
+)
+
+func newRegistrationValue(ctx context.Context, size int) (string, error) {
+	value, err := randomString(ctx, size)
+	if err != nil {
+		return "", oidc.ErrServerError().WithDescription("unable to generate client credentials").WithParent(err)
+	}
+	return value, nil
+}
+
+// registrationToken returns the initial or registration access token
+// sent as bearer token, empty if there is none.
+func registrationToken(header http.Header) string {
+	token, _ := strings.CutPrefix(header.Get("Authorization"), oidc.BearerToken+" ")
+	return token
+}
+
+// registrationClientURI returns the client configuration endpoint of the client,
+// which is the registration endpoint with the client_id as query parameter.
+func registrationClientURI(endpoint, clientID string) string {
+	return endpoint + "?" + url.Values{"client_id": {clientID}}.Encode()
+}
+
+func ClientRegistrationHandler(o OpenIDProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ClientRegistration(w, r, o); err != nil {
+			WriteError(w, r, err, o.Logger())
+		}
+	}
+}
+
+// ClientRegistration handles the registration of new clients (RFC 7591, section 3) and,
+// called with the client_id of the registration_client_uri, the read, update and delete
+// requests of registered clients (RFC 7592, section 2).
+func ClientRegistration(w http.ResponseWriter, r *http.Request, o OpenIDProvider) error {
+	ctx, span := tracer.Start(r.Context(), "ClientRegistration")
+	r = r.WithContext(ctx)
+	defer span.End()
+
+	endpoint := clientRegistrationEndpointOf(o).Absolute(IssuerFromContext(ctx))
+	token := registrationToken(r.Header)
+	clientID := r.URL.Query().Get("client_id")
+	if clientID == "" {
+		if r.Method != http.MethodPost {
+			return oidc.ErrInvalidRequest().WithDescription("client registration requests must be posted")
+		}
+		req, err := ParseClientRegistrationRequest(w, r)
+		if err != nil {
+			return err
+		}
+		response, err := registerClient(ctx, o, token, req, endpoint)
+		if err != nil {
+			return err
+		}
+		WriteJSON(w, response, http.StatusCreated)
+		return nil
+	}
+	switch r.Method {
+	case http.MethodGet:
+		response, err := readClientRegistration(ctx, o, clientID, token, endpoint)
+		if err != nil {
+			return err
+		}
+		WriteJSON(w, response, http.StatusOK)
+	case http.MethodPut:
+		req, err := ParseClientRegistrationRequest(w, r)
+		if err != nil {
+			return err
+		}
+		response, err := updateClientRegistration(ctx, o, clientID, token, req, endpoint)
+		if err != nil {
+			return err
+		}
+		WriteJSON(w, response, http.StatusOK)
+	case http.MethodDelete:
+		if err := deleteClientRegistration(ctx, o, clientID, token); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return oidc.ErrInvalidRequest().WithDescription("method %s not supported by the client configuration endpoint", r.Method)
+	}
+	return nil
+}
+
+// ParseClientRegistrationRequest decodes the JSON body of the
+// registration and client update requests.
+func ParseClientRegistrationRequest(w http.ResponseWriter, r *http.Request) (*oidc.ClientRegistrationRequest, error) {
+	req := new(oidc.ClientRegistrationRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONRequestBody)).Decode(req); err != nil {
+		return nil, oidc.ErrInvalidClientMetadata().WithDescription("invalid JSON request body").WithParent(err)
+	}
+	return req, nil
+}
+
+func clientRegistrar(o OpenIDProvider) (*ClientRegistrationConfig, ClientRegistrar, error) {
+	config := clientRegistration(o)
+	storage, ok := o.Storage().(ClientRegistrar)
+	if config == nil || !ok {
+		return nil, nil, oidc.ErrInvalidRequest().WithDescription("client registration not supported")
+	}
+	return config, storage, nil
+}
+
+// registrarError passes errors of the [ClientRegistrar], which are an [oidc.Error],
+// for example to reject the metadata by a policy, and returns a server_error otherwise.
+func registrarError(err error, description string) error {
+	var oidcErr *oidc.Error
+	if errors.As(err, &oidcErr) {
+		return oidcErr
+	}
+	return oidc.ErrServerError().WithDescription("%s", description).WithParent(err)
+}
+
+// registerClient validates the metadata and stores the new client,
+// with the issued client_id, client_secret and registration_access_token.
+func registerClient(ctx context.Context, o OpenIDProvider, initialAccessToken string, req *oidc.ClientRegistrationRequest, endpoint string) (*oidc.ClientRegistrationResponse, error) {
+	ctx, span := tracer.Start(ctx, "registerClient")
+	defer span.End()
+
+	config, storage, err := clientRegistrar(o)
+	if err != nil {
+		return nil, err
+	}
+	if config.InitialAccessToken != nil {
+		if err := config.InitialAccessToken(ctx, initialAccessToken); err != nil {
+			return nil, NewStatusError(oidc.ErrInvalidToken().WithDescription("invalid initial access token").WithParent(err), http.StatusUnauthorized)
+		}
+	} else if !config.OpenRegistration {
+		return nil, NewStatusError(oidc.ErrInvalidToken().WithDescription("initial access token required"), http.StatusUnauthorized)
+	}
+	metadata := req.ClientMetadata
+	if err := ValidateClientMetadata(o, &metadata); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	client := &RegisteredClient{
+		ClientIDIssuedAt: now,
+		Metadata:         metadata,
+	}
+	if client.ClientID, err = newRegistrationValue(ctx, registeredClientIDBytes); err != nil {
+		return nil, err
+	}
+	if client.RegistrationAccessToken, err = newRegistrationValue(ctx, registeredSecretBytes); err != nil {
+		return nil, err
+	}
+	if metadata.TokenEndpointAuthMethod == oidc.AuthMethodBasic || metadata.TokenEndpointAuthMethod == oidc.AuthMethodPost {
+		if client.ClientSecret, err = newRegistrationValue(ctx, registeredSecretBytes); err != nil {
+			return nil, err
+		}
+		client.ClientSecretExpiresAt = config.secretExpiresAt(now)
+	}
+	if err := storage.CreateRegisteredClient(ctx, client); err != nil {
+		return nil, registrarError(err, "unable to save client")
+	}
+	return clientRegistrationResponse(client, endpoint), nil
+}
+
+// authorizeRegisteredClient returns the registered client,
+// if the registration access token was issued for it.
+func authorizeRegisteredClient(ctx context.Context, storage ClientRegistrar, clientID, token string) (*RegisteredClient, error) {
+	if token == "" {
+		return nil, NewStatusError(oidc.ErrInvalidToken().WithDescription("registration access token missing"), http.StatusUnauthorized)
+	}
+	client, err := storage.GetRegisteredClient(ctx, clientID, token)
+	if err != nil {
+		return nil, NewStatusError(oidc.ErrInvalidToken().WithParent(err), http.StatusUnauthorized)
+	}
+	return client, nil
+}
+
+func readClientRegistration(ctx context.Context, o OpenIDProvider, clientID, token, endpoint string) (*oidc.ClientRegistrationResponse, error) {
+	ctx, span := tracer.Start(ctx, "readClientRegistration")
+	defer span.End()
+
+	_, storage, err := clientRegistrar(o)
+	if err != nil {
+		return nil, err
+	}
+	client, err := authorizeRegisteredClient(ctx, storage, clientID, token)
+	if err != nil {
+		return nil, err
+	}
+	return clientRegistrationResponse(client, endpoint), nil
+}
+
+// updateClientRegistration replaces the metadata of the client with the validated metadata
+// of the request (RFC 7592, section 2.2). Omitted metadata is reset to the defaults.
+func updateClientRegistration(ctx context.Context, o OpenIDProvider, clientID, token string, req *oidc.ClientRegistrationRequest, endpoint string) (*oidc.ClientRegistrationResponse, error) {
+	ctx, span := tracer.Start(ctx, "updateClientRegistration")
+	defer span.End()
+
+	_, storage, err := clientRegistrar(o)
+	if err != nil {
+		return nil, err
+	}
+	client, err := authorizeRegisteredClient(ctx, storage, clientID, token)
+	if err != nil {
+		return nil, err
+	}
+	if req.ClientID != clientID {
+		return nil, oidc.ErrInvalidRequest().WithDescription("client_id does not match the registered client")
+	}
+	if req.ClientSecret != "" && client.ClientSecret != "" && !security.Equal(req.ClientSecret, client.ClientSecret) {
+		return nil, oidc.ErrInvalidRequest().WithDescription("client_secret does not match the registered client")
+	}
+	metadata := req.ClientMetadata
+	if err := ValidateClientMetadata(o, &metadata); err != nil {
+		return nil, err
+	}
+	if (metadata.TokenEndpointAuthMethod == oidc.AuthMethodNone) != (client.Metadata.TokenEndpointAuthMethod == oidc.AuthMethodNone) {
+		return nil, oidc.ErrInvalidClientMetadata().WithDescription("public clients cannot become confidential and vice versa")
+	}
+	if err := storage.UpdateRegisteredClient(ctx, clientID, &metadata); err != nil {
+		return nil, registrarError(err, "unable to update client")
+	}
+	client.Metadata = metadata
+	return clientRegistrationResponse(client, endpoint), nil
+}
+
+func deleteClientRegistration(ctx context.Context, o OpenIDProvider, clientID, token string) error {
+	ctx, span := tracer.Start(ctx, "deleteClientRegistration")
+	defer span.End()
+
+	_, storage, err := clientRegistrar(o)
+	if err != nil {
+		return err
+	}
+	if _, err = authorizeRegisteredClient(ctx, storage, clientID, token); err != nil {
+		return err
+	}
+	if err = storage.DeleteRegisteredClient(ctx, clientID); err != nil {
+		return registrarError(err, "unable to delete client")
+	}
+	return nil
+}
+
+func clientRegistrationResponse(client *RegisteredClient, endpoint string) *oidc.ClientRegistrationResponse {
+	response := &oidc.ClientRegistrationResponse{
+		ClientMetadata:          client.Metadata,
+		ClientID:                client.ClientID,
+		ClientSecret:            client.ClientSecret,
+		RegistrationAccessToken: client.RegistrationAccessToken,
+		RegistrationClientURI:   registrationClientURI(endpoint, client.ClientID),
+	}
+	if !client.ClientIDIssuedAt.IsZero() {
+		response.ClientIDIssuedAt = client.ClientIDIssuedAt.Unix()
+	}
+	if !client.ClientSecretExpiresAt.IsZero() {
+		response.ClientSecretExpiresAt = client.ClientSecretExpiresAt.Unix()
+	}
+	return response
+}
+
+// ValidateClientMetadata validates the metadata of a registration or client update request
+// against the configuration of the provider and sets the defaults of RFC 7591, section 2:
+// the authorization_code grant and code response type of a web application,
+// authenticated with client_secret_basic.
+func ValidateClientMetadata(c Configuration, metadata *oidc.ClientMetadata) error {
+	if metadata.ApplicationType == "" {
+		metadata.ApplicationType = oidc.ApplicationTypeWeb
+	}
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = oidc.AuthMethodBasic
+	}
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []oidc.GrantType{oidc.GrantTypeCode}
+	}
+	if len(metadata.ResponseTypes) == 0 {
+		metadata.ResponseTypes = []oidc.ResponseType{oidc.ResponseTypeCode}
+	}
+	if metadata.ApplicationType != oidc.ApplicationTypeWeb && metadata.ApplicationType != oidc.ApplicationTypeNative {
+		return oidc.ErrInvalidClientMetadata().WithDescription("application_type %q not supported", metadata.ApplicationType)
+	}
+	if !slices.Contains(AuthMethodsTokenEndpoint(c), metadata.TokenEndpointAuthMethod) {
+		return oidc.ErrInvalidClientMetadata().WithDescription("token_endpoint_auth_method %q not supported", metadata.TokenEndpointAuthMethod)
+	}
+	if metadata.JWKS != nil && metadata.JWKSURI != "" {
+		return oidc.ErrInvalidClientMetadata().WithDescription("jwks and jwks_uri must not both be set")
+	}
+	if err := validateFetchedURI("jwks_uri", metadata.JWKSURI); err != nil {
+		return err
+	}
+	if err := validateFetchedURI("backchannel_logout_uri", metadata.BackchannelLogoutURI); err != nil {
+		return err
+	}
+	if metadata.TokenEndpointAuthMethod == oidc.AuthMethodPrivateKeyJWT && metadata.JWKS == nil && metadata.JWKSURI == "" {
+		return oidc.ErrInvalidClientMetadata().WithDescription("private_key_jwt requires jwks or jwks_uri")
+	}
+	if metadata.TokenEndpointAuthMethod == oidc.AuthMethodSelfSignedTLSClientAuth && metadata.JWKS == nil && metadata.JWKSURI == "" {
+		return oidc.ErrInvalidClientMetadata().WithDescription("self_signed_tls_client_auth requires jwks or jwks_uri")
+	}
+	if metadata.TokenEndpointAuthMethod == oidc.AuthMethodTLSClientAuth && tlsClientAuthSubjects(metadata) != 1 {
+		return oidc.ErrInvalidClientMetadata().WithDescription("tls_client_auth requires exactly one tls_client_auth subject")
+	}
+	grantTypes := GrantTypes(c)
+	for _, grantType := range metadata.GrantTypes {
+		if !slices.Contains(grantTypes, grantType) {
+			return oidc.ErrInvalidClientMetadata().WithDescription("grant_type %q not supported", grantType)
+		}
+	}
+	responseTypes := ResponseTypes(c)
+	for _, responseType := range metadata.ResponseTypes {
+		if !slices.Contains(responseTypes, string(responseType)) {
+			return oidc.ErrInvalidClientMetadata().WithDescription("response_type %q not supported", responseType)
+		}
+		grantType := oidc.GrantTypeImplicit
+		if responseType == oidc.ResponseTypeCode {
+			grantType = oidc.GrantTypeCode
+		}
+		if !slices.Contains(metadata.GrantTypes, grantType) {
+			return oidc.ErrInvalidClientMetadata().WithDescription("response_type %q requires the grant_type %q", responseType, grantType)
+		}
+	}
+	return validateRegisteredRedirectURIs(metadata)
+}
+
+// validateRegisteredRedirectURIs requires redirect_uris for the grants using them.
+// Web applications using the implicit grant must only register https URIs,
+// which are not localhost (OpenID Connect Dynamic Client Registration, section 2).
+func validateRegisteredRedirectURIs(metadata *oidc.ClientMetadata) error {
+	implicit := slices.Contains(metadata.GrantTypes, oidc.GrantTypeImplicit)
+	if len(metadata.RedirectURIs) == 0 && (implicit || slices.Contains(metadata.GrantTypes, oidc.GrantTypeCode)) {
+		return oidc.ErrInvalidRedirectURI().WithDescription("redirect_uris are required")
+	}
+	for _, uri := range metadata.RedirectURIs {
+		u, err := parseRegisteredURI(uri)
+		if err != nil {
+			return oidc.ErrInvalidRedirectURI().WithDescription("%s", err.Error())
+		}
+		if implicit && metadata.ApplicationType == oidc.ApplicationTypeWeb && (u.Scheme != "https" || u.Hostname() == "localhost") {
+			return oidc.ErrInvalidRedirectURI().WithDescription("web applications using the implicit grant must use https redirect_uris, which are not localhost")
+		}
+	}
+	for _, uri := range metadata.PostLogoutRedirectURIs {
+		if _, err := parseRegisteredURI(uri); err != nil {
+			return oidc.ErrInvalidClientMetadata().WithDescription("%s", err.Error())
+		}
+	}
+	return nil
+}
+
+func parseRegisteredURI(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return nil, fmt.Errorf("%q must be an absolute URI without fragment", uri)
+	}
+	return u, nil
+}
+
+// validateFetchedURI requires the URIs the provider sends requests to, if set,
+// to be https URIs, which are not localhost or a loopback, private or link-local IP address,
+// so clients cannot make the provider request internal services.
+// Host names resolving to internal addresses are blocked by the [httphelper.PublicHTTPClient].
+func validateFetchedURI(name, uri string) error {
+	if uri == "" {
+		return nil
+	}
+	u, err := parseRegisteredURI(uri)
+	if err != nil {
+		return oidc.ErrInvalidClientMetadata().WithDescription("%s: %s", name, err.Error())
+	}
+	if u.Scheme != "https" {
+		return oidc.ErrInvalidClientMetadata().WithDescription("%s must be an https URI", name)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return oidc.ErrInvalidClientMetadata().WithDescription("%s must not be a local URI", name)
+	}
+	if ip := net.ParseIP(host); ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()) {
+		return oidc.ErrInvalidClientMetadata().WithDescription("%s must not be an internal address", name)
+	}
+	return nil
+}
+
+// tlsClientAuthSubjects counts the tls_client_auth subject metadata of the client (RFC 8705, section 2.1.2).
+func tlsClientAuthSubjects(metadata *oidc.ClientMetadata) int {
+	var n int
+	for _, subject := range []string{metadata.TLSClientAuthSubjectDN, metadata.TLSClientAuthSANDNS, metadata.TLSClientAuthSANURI, metadata.TLSClientAuthSANIP, metadata.TLSClientAuthSANEmail} {
+		if subject != "" {
+			n++
+		}
+	}
+	return n
+}
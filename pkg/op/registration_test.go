@@ -0,0 +1,254 @@
+package op_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestValidateClientMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata oidc.ClientMetadata
+		want     *oidc.ClientMetadata
+		wantErr  func() *oidc.Error
+	}{
+		{
+			name:     "defaults",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}},
+			want: &oidc.ClientMetadata{
+				RedirectURIs:            []string{"https://example.com/callback"},
+				TokenEndpointAuthMethod: oidc.AuthMethodBasic,
+				GrantTypes:              []oidc.GrantType{oidc.GrantTypeCode},
+				ResponseTypes:           []oidc.ResponseType{oidc.ResponseTypeCode},
+				ApplicationType:         oidc.ApplicationTypeWeb,
+			},
+		},
+		{
+			name: "native custom scheme",
+			metadata: oidc.ClientMetadata{
+				RedirectURIs:            []string{"custom://auth/callback"},
+				TokenEndpointAuthMethod: oidc.AuthMethodNone,
+				ApplicationType:         oidc.ApplicationTypeNative,
+			},
+			want: &oidc.ClientMetadata{
+				RedirectURIs:            []string{"custom://auth/callback"},
+				TokenEndpointAuthMethod: oidc.AuthMethodNone,
+				GrantTypes:              []oidc.GrantType{oidc.GrantTypeCode},
+				ResponseTypes:           []oidc.ResponseType{oidc.ResponseTypeCode},
+				ApplicationType:         oidc.ApplicationTypeNative,
+			},
+		},
+		{
+			name:     "unsupported application type",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, ApplicationType: "tv"},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "unsupported auth method",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, TokenEndpointAuthMethod: "tls_client_auth"},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "private_key_jwt without keys",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, TokenEndpointAuthMethod: oidc.AuthMethodPrivateKeyJWT},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name: "jwks and jwks_uri",
+			metadata: oidc.ClientMetadata{
+				RedirectURIs: []string{"https://example.com/callback"},
+				JWKS:         &jose.JSONWebKeySet{},
+				JWKSURI:      "https://example.com/keys",
+			},
+			wantErr: oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "http jwks_uri",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, JWKSURI: "http://example.com/keys"},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "loopback jwks_uri",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, JWKSURI: "https://127.0.0.1/keys"},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "localhost backchannel_logout_uri",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, BackchannelLogoutURI: "https://localhost/logout"},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "private backchannel_logout_uri",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, BackchannelLogoutURI: "https://10.0.0.1/logout"},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "link-local jwks_uri",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, JWKSURI: "https://169.254.169.254/keys"},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "unsupported grant type",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, GrantTypes: []oidc.GrantType{oidc.GrantTypeCode, "urn:unknown"}},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "response type without grant type",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback"}, ResponseTypes: []oidc.ResponseType{oidc.ResponseTypeIDTokenOnly}},
+			wantErr:  oidc.ErrInvalidClientMetadata,
+		},
+		{
+			name:     "missing redirect uris",
+			metadata: oidc.ClientMetadata{},
+			wantErr:  oidc.ErrInvalidRedirectURI,
+		},
+		{
+			name:     "redirect uri with fragment",
+			metadata: oidc.ClientMetadata{RedirectURIs: []string{"https://example.com/callback#fragment"}},
+			wantErr:  oidc.ErrInvalidRedirectURI,
+		},
+		{
+			name: "implicit web client on http",
+			metadata: oidc.ClientMetadata{
+				RedirectURIs:  []string{"http://example.com/callback"},
+				GrantTypes:    []oidc.GrantType{oidc.GrantTypeImplicit},
+				ResponseTypes: []oidc.ResponseType{oidc.ResponseTypeIDTokenOnly},
+			},
+			wantErr: oidc.ErrInvalidRedirectURI,
+		},
+		{
+			name: "relative post logout redirect uri",
+			metadata: oidc.ClientMetadata{
+				RedirectURIs:           []string{"https://example.com/callback"},
+				PostLogoutRedirectURIs: []string{"/logged-out"},
+			},
+			wantErr: oidc.ErrInvalidClientMetadata,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := tt.metadata
+			err := op.ValidateClientMetadata(testProvider, &metadata)
+			if tt.wantErr != nil {
+				var oidcErr *oidc.Error
+				require.ErrorAs(t, err, &oidcErr)
+				assert.Equal(t, tt.wantErr().ErrorType, oidcErr.ErrorType)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, &metadata)
+		})
+	}
+}
+
+func TestClientRegistration(t *testing.T) {
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(),
+		op.WithClientRegistration(op.ClientRegistrationConfig{
+			InitialAccessToken: func(_ context.Context, token string) error {
+				if token != "initial" {
+					return errors.New("unknown initial access token")
+				}
+				return nil
+			},
+			SecretLifetime: time.Hour,
+		}),
+	)
+	require.NoError(t, err)
+
+	register := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"redirect_uris":["https://example.com/callback"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		provider.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, token := range []string{"", "invalid"} {
+		rec := register(token)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "token %q", token)
+		assert.Contains(t, rec.Body.String(), string(oidc.InvalidToken))
+	}
+
+	rec := register("initial")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var registration oidc.ClientRegistrationResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &registration))
+	assert.NotEmpty(t, registration.ClientSecret)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), registration.ClientSecretExpiresAt, 5)
+	assert.Equal(t, testIssuer+"register?client_id="+registration.ClientID, registration.RegistrationClientURI)
+
+	req := httptest.NewRequest(http.MethodDelete, "/register?client_id="+registration.ClientID, nil)
+	req.Header.Set("Authorization", "Bearer "+registration.RegistrationAccessToken)
+	rec = httptest.NewRecorder()
+	provider.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestWithClientRegistration(t *testing.T) {
+	_, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(),
+		op.WithClientRegistration(op.ClientRegistrationConfig{}),
+	)
+	assert.Error(t, err)
+
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(),
+		op.WithClientRegistration(op.ClientRegistrationConfig{OpenRegistration: true}),
+	)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"redirect_uris":["https://example.com/callback"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	provider.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+}
+
+func TestValidateClientMetadata_MTLS(t *testing.T) {
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(),
+		op.WithMTLS(op.MTLSConfig{TLSClientAuth: true, SelfSignedTLSClientAuth: true}),
+	)
+	require.NoError(t, err)
+	redirectURIs := []string{"https://example.com/callback"}
+	tests := []struct {
+		name     string
+		metadata oidc.ClientMetadata
+		wantErr  bool
+	}{
+		{"tls_client_auth", oidc.ClientMetadata{RedirectURIs: redirectURIs, TokenEndpointAuthMethod: oidc.AuthMethodTLSClientAuth, TLSClientAuthSANDNS: "client.example.com"}, false},
+		{"tls_client_auth without subject", oidc.ClientMetadata{RedirectURIs: redirectURIs, TokenEndpointAuthMethod: oidc.AuthMethodTLSClientAuth}, true},
+		{"tls_client_auth with two subjects", oidc.ClientMetadata{RedirectURIs: redirectURIs, TokenEndpointAuthMethod: oidc.AuthMethodTLSClientAuth, TLSClientAuthSANDNS: "client.example.com", TLSClientAuthSANEmail: "client@example.com"}, true},
+		{"self_signed_tls_client_auth", oidc.ClientMetadata{RedirectURIs: redirectURIs, TokenEndpointAuthMethod: oidc.AuthMethodSelfSignedTLSClientAuth, JWKSURI: "https://example.com/keys"}, false},
+		{"self_signed_tls_client_auth without keys", oidc.ClientMetadata{RedirectURIs: redirectURIs, TokenEndpointAuthMethod: oidc.AuthMethodSelfSignedTLSClientAuth}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := tt.metadata
+			err := op.ValidateClientMetadata(provider, &metadata)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidClientMetadata())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
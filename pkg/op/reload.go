@@ -0,0 +1,248 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+var ErrSigningKeyUnpublished = errors.New("signing key is not part of the public keys")
+
+// providerState is the part of the [Provider] which can be changed at runtime.
+// It is swapped as a whole, so a request observes a consistent state.
+type providerState struct {
+	config    *Config
+	endpoints *Endpoints
+	keys      *providerKeys
+}
+
+// providerKeys are set by [Provider.SetSigningKeys] and override the keys of the Storage.
+type providerKeys struct {
+	signing SigningKey
+	public  []Key
+}
+
+func (o *Provider) currentConfig() *Config {
+	return o.state.Load().config
+}
+
+func (o *Provider) currentEndpoints() *Endpoints {
+	return o.state.Load().endpoints
+}
+
+func (o *Provider) serveRouter(w http.ResponseWriter, r *http.Request) {
+	o.router.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+func (o *Provider) clientSigningAlgorithms() []string {
+	if algorithms := fipsApprovedAlgorithms(o.fips, o.currentConfig().ClientSigningAlgorithms); len(algorithms) > 0 {
+		return algorithms
+	}
+	return []string{"RS256"}
+}
+
+// UpdateConfig calls update with a copy of the current [Config] and swaps it in,
+// so feature toggles, such as GrantTypeRefreshToken or RequestObjectSupported,
+// and the ClientSigningAlgorithms can be changed without restarting the Provider.
+// The CryptoKey is kept, as changing it would invalidate the opaque tokens and
+// codes of flows in progress.
+// It is safe to call concurrently with requests being served.
+func (o *Provider) UpdateConfig(update func(config *Config)) {
+	o.reloadMu.Lock()
+	defer o.reloadMu.Unlock()
+
+	state := *o.state.Load()
+	config := *state.config
+	config.SupportedUILocales = slices.Clone(config.SupportedUILocales)
+	config.SupportedClaims = slices.Clone(config.SupportedClaims)
+	config.SupportedScopes = slices.Clone(config.SupportedScopes)
+	config.ClientSigningAlgorithms = slices.Clone(config.ClientSigningAlgorithms)
+	update(&config)
+	config.CryptoKey = state.config.CryptoKey
+	state.config = &config
+	o.state.Store(&state)
+}
+
+// SetEndpoints swaps the endpoints and rebuilds the router of the Provider.
+// The Authorization, Token and JwksURI endpoints are required, other nil
+// endpoints are disabled and omitted from the discovery configuration.
+// Requests in flight are completed by the previous router.
+// The endpoints of a [LegacyServer] are not affected.
+// It is safe to call concurrently with requests being served.
+func (o *Provider) SetEndpoints(endpoints Endpoints) error {
+	for _, e := range []*Endpoint{endpoints.Authorization, endpoints.Token, endpoints.JwksURI} {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	o.reloadMu.Lock()
+	defer o.reloadMu.Unlock()
+
+	state := *o.state.Load()
+	state.endpoints = &endpoints
+	o.state.Store(&state)
+	o.router.Store(CreateRouter(o, o.interceptors...))
+	return nil
+}
+
+// SetSigningKeys swaps the key used to sign the issued tokens and the public keys,
+// served on the keys endpoint and used for the verification of access tokens and
+// ID token hints. They override the SigningKey, KeySet and SignatureAlgorithms of the
+// Storage, until SetSigningKeys is called with a nil signing key.
+// The public keys must include the signing key and should keep the previous keys,
+// until the tokens signed by them have expired.
+// It is safe to call concurrently with requests being served.
+func (o *Provider) SetSigningKeys(signing SigningKey, public ...Key) error {
+	keys, err := o.newProviderKeys(signing, public)
+	if err != nil {
+		return err
+	}
+	o.reloadMu.Lock()
+	defer o.reloadMu.Unlock()
+
+	state := *o.state.Load()
+	state.keys = keys
+	o.state.Store(&state)
+	return nil
+}
+
+// newProviderKeys validates the keys, nil without signing key.
+func (o *Provider) newProviderKeys(signing SigningKey, public []Key) (*providerKeys, error) {
+	if signing == nil {
+		return nil, nil
+	}
+	if err := checkFIPSKey(o.fips, signing); err != nil {
+		return nil, err
+	}
+	if err := checkMLDSAKey(o.mldsa, signing); err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(public, func(key Key) bool { return key.ID() == signing.ID() }) {
+		return nil, ErrSigningKeyUnpublished
+	}
+	return &providerKeys{
+		signing: signing,
+		public:  slices.Clone(public),
+	}, nil
+}
+
+// currentKeys returns the keys of the tenant of the issuer of the context,
+// if isolated with [WithTenantKeys], or the keys set by [Provider.SetSigningKeys],
+// nil if the keys of the Storage are used.
+func (o *Provider) currentKeys(ctx context.Context) (*providerKeys, error) {
+	if o.tenantKeys != nil {
+		return o.tenantKeys.keys(ctx, IssuerFromContext(ctx))
+	}
+	return o.state.Load().keys, nil
+}
+
+// SigningKey returns the key of the tenant with [WithTenantKeys],
+// the key set by [Provider.SetSigningKeys], or the signing key of the Storage.
+func (o *Provider) SigningKey(ctx context.Context) (SigningKey, error) {
+	if o.tenantKeys != nil {
+		keys, err := o.tenantKeys.keys(ctx, IssuerFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return o.checkSigningKey(keys.signing)
+	}
+	if keys := o.state.Load().keys; keys != nil {
+		return keys.signing, nil
+	}
+	key, err := o.storage.SigningKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return o.checkSigningKey(key)
+}
+
+func (o *Provider) checkSigningKey(key SigningKey) (SigningKey, error) {
+	if err := checkFIPSKey(o.fips, key); err != nil {
+		return nil, err
+	}
+	if err := checkMLDSAKey(o.mldsa, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// KeySet returns the public keys of the tenant with [WithTenantKeys],
+// the keys set by [Provider.SetSigningKeys], or the keys of the Storage.
+func (o *Provider) KeySet(ctx context.Context) ([]Key, error) {
+	keys, err := o.currentKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if keys != nil {
+		return keys.public, nil
+	}
+	return o.storage.KeySet(ctx)
+}
+
+// SignatureAlgorithms returns the algorithms of the keys of the tenant with
+// [WithTenantKeys], of the keys set by [Provider.SetSigningKeys], or the
+// algorithms of the Storage.
+func (o *Provider) SignatureAlgorithms(ctx context.Context) ([]jose.SignatureAlgorithm, error) {
+	keys, err := o.currentKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		algorithms, err := o.storage.SignatureAlgorithms(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return mldsaAlgorithms(o.mldsa, algorithms), nil
+	}
+	algorithms := []jose.SignatureAlgorithm{keys.signing.SignatureAlgorithm()}
+	for _, key := range keys.public {
+		if !slices.Contains(algorithms, key.Algorithm()) {
+			algorithms = append(algorithms, key.Algorithm())
+		}
+	}
+	return mldsaAlgorithms(o.mldsa, algorithms), nil
+}
+
+// providerKeySet is the default key set of the Provider
+// for the verification of access tokens and ID token hints.
+type providerKeySet struct {
+	provider *Provider
+}
+
+func (k *providerKeySet) VerifySignature(ctx context.Context, jws *jose.JSONWebSignature) ([]byte, error) {
+	return verifySignature(ctx, k.provider, jws)
+}
+
+type signingKeyGetter interface {
+	SigningKey(context.Context) (SigningKey, error)
+}
+
+// signingKeys returns v, if it provides the signing key,
+// like the [Provider], or defaults to the storage.
+func signingKeys(v any, storage Storage) signingKeyGetter {
+	if getter, ok := v.(signingKeyGetter); ok {
+		return getter
+	}
+	return storage
+}
+
+// keyProvider returns the provider, if it provides the public keys,
+// or defaults to its storage.
+func keyProvider(o OpenIDProvider) KeyProvider {
+	if provider, ok := o.(KeyProvider); ok {
+		return provider
+	}
+	return o.Storage()
+}
+
+// discoverStorage returns the provider, if it provides the signature
+// algorithms, or defaults to its storage.
+func discoverStorage(o OpenIDProvider) DiscoverStorage {
+	if storage, ok := o.(DiscoverStorage); ok {
+		return storage
+	}
+	return o.Storage()
+}
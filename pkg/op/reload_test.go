@@ -0,0 +1,153 @@
+package op_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReloadTestProvider(t *testing.T) (*op.Provider, op.Storage) {
+	storage := storage.NewStorage(storage.NewUserStore(testIssuer))
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage, op.WithAllowInsecure())
+	require.NoError(t, err)
+	return provider, storage
+}
+
+func discoveryConfig(t *testing.T, handler http.Handler) *oidc.DiscoveryConfiguration {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testIssuer+strings.TrimPrefix(oidc.DiscoveryEndpoint, "/"), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	config := new(oidc.DiscoveryConfiguration)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), config))
+	return config
+}
+
+func TestProvider_UpdateConfig(t *testing.T) {
+	provider, _ := newReloadTestProvider(t)
+	require.True(t, provider.RequestObjectSupported())
+	assert.Equal(t, []string{"RS256"}, provider.TokenEndpointSigningAlgorithmsSupported())
+
+	provider.UpdateConfig(func(config *op.Config) {
+		config.RequestObjectSupported = false
+		config.ClientSigningAlgorithms = []string{"ES256", "RS256"}
+		config.SupportedClaims = append(config.SupportedClaims[:0], "sub")
+	})
+	assert.False(t, provider.RequestObjectSupported())
+	assert.Equal(t, []string{"ES256", "RS256"}, provider.TokenEndpointSigningAlgorithmsSupported())
+	assert.Equal(t, []string{"ES256", "RS256"}, provider.RequestObjectSigningAlgorithmsSupported())
+
+	config := discoveryConfig(t, provider)
+	assert.False(t, config.RequestParameterSupported)
+	assert.Equal(t, []string{"ES256", "RS256"}, config.TokenEndpointAuthSigningAlgValuesSupported)
+
+	// the config passed to the provider is not modified
+	assert.True(t, testConfig.RequestObjectSupported)
+	assert.Equal(t, op.DefaultSupportedClaims[0], testConfig.SupportedClaims[0])
+	assert.Empty(t, testConfig.ClientSigningAlgorithms)
+}
+
+func TestProvider_SetEndpoints(t *testing.T) {
+	provider, _ := newReloadTestProvider(t)
+	introspect := func() int {
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, httptest.NewRequest(http.MethodPost, testIssuer+"oauth/introspect", nil))
+		return w.Code
+	}
+	require.Equal(t, http.StatusUnauthorized, introspect())
+
+	endpoints := *op.DefaultEndpoints
+	endpoints.Introspection = nil
+	endpoints.Token = op.NewEndpoint("token")
+	require.NoError(t, provider.SetEndpoints(endpoints))
+
+	assert.Equal(t, http.StatusNotFound, introspect())
+	config := discoveryConfig(t, provider)
+	assert.Empty(t, config.IntrospectionEndpoint)
+	assert.Equal(t, testIssuer+"token", config.TokenEndpoint)
+	assert.Equal(t, "/token", provider.TokenEndpoint().Relative())
+
+	endpoints.JwksURI = nil
+	assert.ErrorIs(t, provider.SetEndpoints(endpoints), op.ErrNilEndpoint)
+	assert.Equal(t, "/token", provider.TokenEndpoint().Relative())
+}
+
+func TestProvider_SetSigningKeys(t *testing.T) {
+	provider, storage := newReloadTestProvider(t)
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	key := newCertSigningKey(t)
+
+	client, err := storage.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	createToken := func() string {
+		token, _, _, err := op.CreateAccessToken(ctx, &oidc.JWTTokenRequest{
+			Subject:  "sub1",
+			Audience: []string{"web"},
+		}, op.AccessTokenTypeJWT, provider, client, "")
+		require.NoError(t, err)
+		return token
+	}
+	keyIDs := func() []string {
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testIssuer+"keys", nil))
+		var keySet jose.JSONWebKeySet
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keySet))
+		ids := make([]string, len(keySet.Keys))
+		for i, key := range keySet.Keys {
+			ids[i] = key.KeyID
+		}
+		return ids
+	}
+	storageToken := createToken()
+	storageKeyIDs := keyIDs()
+
+	assert.ErrorIs(t, provider.SetSigningKeys(key), op.ErrSigningKeyUnpublished)
+	require.NoError(t, provider.SetSigningKeys(key, certKey{key}))
+
+	token := createToken()
+	assert.Equal(t, "key1", signedHeader(t, token)["kid"])
+	assert.Equal(t, []string{"key1"}, keyIDs())
+	_, err = op.VerifyAccessToken[*oidc.AccessTokenClaims](ctx, token, provider.AccessTokenVerifier(ctx))
+	assert.NoError(t, err)
+	_, err = op.VerifyAccessToken[*oidc.AccessTokenClaims](ctx, storageToken, provider.AccessTokenVerifier(ctx))
+	assert.Error(t, err, "key of the storage is no longer published")
+
+	algorithms, err := provider.SignatureAlgorithms(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []jose.SignatureAlgorithm{jose.RS256}, algorithms)
+
+	require.NoError(t, provider.SetSigningKeys(nil))
+	assert.Equal(t, storageKeyIDs, keyIDs())
+	_, err = op.VerifyAccessToken[*oidc.AccessTokenClaims](ctx, createToken(), provider.AccessTokenVerifier(ctx))
+	assert.NoError(t, err)
+}
+
+func TestProvider_reloadConcurrent(t *testing.T) {
+	provider, _ := newReloadTestProvider(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			provider.UpdateConfig(func(config *op.Config) {
+				config.GrantTypeRefreshToken = !config.GrantTypeRefreshToken
+			})
+			assert.NoError(t, provider.SetEndpoints(*op.DefaultEndpoints))
+		}()
+		go func() {
+			defer wg.Done()
+			discoveryConfig(t, provider)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,238 @@
+package op
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// Media types of the responses of the Provider.
+// JSON is always UTF-8 (RFC 8259), so it has no charset parameter.
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeJWT  = "application/jwt"
+	ContentTypeHTML = "text/html; charset=utf-8"
+	ContentTypeText = "text/plain; charset=utf-8"
+)
+
+// WriteJSON writes v as JSON with the status code.
+// v is encoded before the header is written, so an encoding error
+// results in a [http.StatusInternalServerError] rather than a partial body.
+// A nil v writes no body.
+func WriteJSON(w http.ResponseWriter, v any, statusCode int) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		w.WriteHeader(statusCode)
+		return
+	}
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		WriteText(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, ContentTypeJSON, body.Bytes(), statusCode)
+}
+
+// WriteJWT writes the signed or encrypted JWT with the status code,
+// e.g. for signed userinfo responses (OpenID Connect Core 1.0, section 5.3.2).
+func WriteJWT(w http.ResponseWriter, token string, statusCode int) {
+	writeBody(w, ContentTypeJWT, []byte(token), statusCode)
+}
+
+// WriteText writes the message as plain text with the status code, like [http.Error].
+func WriteText(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeBody(w, ContentTypeText, []byte(message+"\n"), statusCode)
+}
+
+func writeBody(w http.ResponseWriter, contentType string, body []byte, statusCode int) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.ErrorType}}</title></head>
+<body>
+<h1>{{.ErrorType}}</h1>
+{{with .Description}}<p>{{.}}</p>{{end}}
+</body>
+</html>
+`))
+
+// writeNegotiatedError writes the error as JSON, or as HTML if preferred by the
+// Accept header of the request, e.g. for a user agent navigated to the endpoint.
+func writeNegotiatedError(w http.ResponseWriter, r *http.Request, e *oidc.Error, statusCode int) {
+	if Negotiate(r, ContentTypeJSON, ContentTypeHTML) == ContentTypeHTML {
+		writeHTMLError(w, e, statusCode)
+		return
+	}
+	WriteJSON(w, e, statusCode)
+}
+
+func writeHTMLError(w http.ResponseWriter, e *oidc.Error, statusCode int) {
+	var body bytes.Buffer
+	if err := errorPage.Execute(&body, e); err != nil {
+		WriteText(w, e.Description, statusCode)
+		return
+	}
+	writeBody(w, ContentTypeHTML, body.Bytes(), statusCode)
+}
+
+// Negotiate returns the offered content type preferred by the Accept header
+// of the request (RFC 9110, section 12.5.1). Parameters of the offers, like
+// the charset, are ignored for matching. Without an Accept header, or if none
+// of the offers is acceptable, the first offer is returned.
+func Negotiate(r *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return offers[0]
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		mediaType, _, err := mime.ParseMediaType(offer)
+		if err != nil {
+			continue
+		}
+		if q := acceptQuality(ranges, mediaType); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching the media type.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, rng := range ranges {
+		s := -1
+		switch rng.mediaType {
+		case mediaType:
+			s = 2
+		case mainType + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = rng.q, s
+		}
+	}
+	return q
+}
+
+// ResponseHook mutates the header and the status code of a response of the
+// Provider, before they are written, and returns the status code to write.
+// It is called for every response, including errors and redirects,
+// e.g. to add headers or to rewrite the status code for a gateway.
+type ResponseHook func(r *http.Request, header http.Header, statusCode int) int
+
+type responseHooks []ResponseHook
+
+// Handler calls the hooks for the responses of next.
+func (hooks responseHooks) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&hookedResponseWriter{ResponseWriter: w, r: r, hooks: hooks}, r)
+	})
+}
+
+type hookedResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	hooks       responseHooks
+	wroteHeader bool
+}
+
+func (w *hookedResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, hook := range w.hooks {
+			statusCode = hook(w.r, w.Header(), statusCode)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *hookedResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps streamed responses, like the device access token stream, working.
+func (w *hookedResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *hookedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type responseHooksGetter interface {
+	ResponseHooks() []ResponseHook
+}
+
+func providerResponseHooks(v any) responseHooks {
+	if g, ok := v.(responseHooksGetter); ok {
+		return g.ResponseHooks()
+	}
+	return nil
+}
+
+// WithResponseHooks adds hooks, which mutate the responses of the Provider,
+// see [ResponseHook]. They are called in order.
+func WithResponseHooks(hooks ...ResponseHook) Option {
+	return func(o *Provider) error {
+		o.responseHooks = append(o.responseHooks, hooks...)
+		return nil
+	}
+}
+
+// WithServerResponseHooks adds hooks, which mutate the responses of the Server,
+// see [ResponseHook]. They are called in order.
+func WithServerResponseHooks(hooks ...ResponseHook) ServerOption {
+	return func(s *webServer) {
+		s.responseHooks = append(s.responseHooks, hooks...)
+	}
+}
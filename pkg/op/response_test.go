@@ -0,0 +1,178 @@
+package op
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept []string
+		offers []string
+		want   string
+	}{
+		{
+			name:   "no accept",
+			offers: []string{ContentTypeJSON, ContentTypeHTML},
+			want:   ContentTypeJSON,
+		},
+		{
+			name:   "any",
+			accept: []string{"*/*"},
+			offers: []string{ContentTypeJSON, ContentTypeHTML},
+			want:   ContentTypeJSON,
+		},
+		{
+			name:   "browser",
+			accept: []string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			offers: []string{ContentTypeJSON, ContentTypeHTML},
+			want:   ContentTypeHTML,
+		},
+		{
+			name:   "quality",
+			accept: []string{"text/html;q=0.5, application/json"},
+			offers: []string{ContentTypeHTML, ContentTypeJSON},
+			want:   ContentTypeJSON,
+		},
+		{
+			name:   "multiple headers",
+			accept: []string{"application/jwt;q=0.1", "text/*"},
+			offers: []string{ContentTypeJWT, ContentTypeText},
+			want:   ContentTypeText,
+		},
+		{
+			name:   "specific range excludes",
+			accept: []string{"text/html;q=0, */*"},
+			offers: []string{ContentTypeHTML, ContentTypeJSON},
+			want:   ContentTypeJSON,
+		},
+		{
+			name:   "none acceptable",
+			accept: []string{"image/png"},
+			offers: []string{ContentTypeJSON, ContentTypeHTML},
+			want:   ContentTypeJSON,
+		},
+		{
+			name:   "invalid ranges",
+			accept: []string{"text/html;q=2, ;;, application/json;q=0.4"},
+			offers: []string{ContentTypeHTML, ContentTypeJSON},
+			want:   ContentTypeJSON,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, accept := range tt.accept {
+				r.Header.Add("Accept", accept)
+			}
+			assert.Equal(t, tt.want, Negotiate(r, tt.offers...))
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteJSON(w, map[string]string{"foo": "bar"}, http.StatusCreated)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ContentTypeJSON, w.Header().Get("Content-Type"))
+	assert.Equal(t, "14", w.Header().Get("Content-Length"))
+	assert.Equal(t, "{\"foo\":\"bar\"}\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteJSON(w, (*oidc.Error)(nil), http.StatusOK)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteJSON(w, math.Inf(1), http.StatusOK)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, ContentTypeText, w.Header().Get("Content-Type"))
+}
+
+func TestWriteJWT(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteJWT(w, "header.payload.signature", http.StatusOK)
+	assert.Equal(t, ContentTypeJWT, w.Header().Get("Content-Type"))
+	assert.Equal(t, "header.payload.signature", w.Body.String())
+}
+
+func TestWriteText(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteText(w, "not ready", http.StatusInternalServerError)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, ContentTypeText, w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "not ready\n", w.Body.String())
+}
+
+func TestWriteNegotiatedError(t *testing.T) {
+	e := oidc.ErrInvalidRequest().WithDescription("<script>")
+
+	w := httptest.NewRecorder()
+	writeNegotiatedError(w, httptest.NewRequest(http.MethodGet, "/", nil), e, http.StatusBadRequest)
+	assert.Equal(t, ContentTypeJSON, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"invalid_request","error_description":"<script>"}`, w.Body.String())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	writeNegotiatedError(w, r, e, http.StatusBadRequest)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ContentTypeHTML, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<h1>invalid_request</h1>")
+	assert.Contains(t, w.Body.String(), "&lt;script&gt;")
+	assert.NotContains(t, w.Body.String(), "<script>")
+}
+
+func TestResponseHooks(t *testing.T) {
+	var calls int
+	hooks := responseHooks{
+		func(r *http.Request, header http.Header, statusCode int) int {
+			calls++
+			header.Set("X-Path", r.URL.Path)
+			return statusCode
+		},
+		func(r *http.Request, header http.Header, statusCode int) int {
+			if statusCode == http.StatusUnauthorized {
+				return http.StatusForbidden
+			}
+			return statusCode
+		},
+	}
+	serve := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		hooks.Handler(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/userinfo", nil))
+		return w
+	}
+
+	w := serve(func(w http.ResponseWriter, r *http.Request) {
+		WriteText(w, "access token invalid", http.StatusUnauthorized)
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "/userinfo", w.Header().Get("X-Path"))
+	assert.Equal(t, 1, calls)
+
+	w = serve(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+		_, _ = w.Write([]byte("ok"))
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/userinfo", w.Header().Get("X-Path"))
+	assert.Equal(t, 2, calls)
+
+	w = serve(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if assert.True(t, ok) {
+			flusher.Flush()
+		}
+	})
+	assert.True(t, w.Flushed)
+	assert.Equal(t, 3, calls)
+}
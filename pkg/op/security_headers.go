@@ -0,0 +1,77 @@
+package op
+
+import (
+	"net/http"
+)
+
+// SecurityHeaders defines the HTTP security headers which are set
+// on every response served by the OP.
+// Empty values are not set.
+//
+// Handlers may still overwrite any of the headers, for example
+// to allow caching of the discovery document.
+type SecurityHeaders struct {
+	// CacheControl prevents caching of responses containing tokens
+	// or other sensitive information (RFC 6749, section 5.1).
+	CacheControl string
+	// Pragma is set for compatibility with HTTP/1.0 caches.
+	Pragma string
+	// ReferrerPolicy prevents leaking of codes and tokens
+	// in the Referer header of subsequent requests.
+	ReferrerPolicy string
+	// ContentTypeOptions disables MIME sniffing by user agents.
+	ContentTypeOptions string
+	// FrameOptions prevents the rendered pages, such as the
+	// form_post response, from being embedded into frames (clickjacking).
+	FrameOptions string
+	// ContentSecurityPolicy is the CSP applied to all responses.
+	// The default only restricts framing, as the form_post
+	// page relies on an inline script for auto submission.
+	ContentSecurityPolicy string
+
+	// Override is called after the above headers are set,
+	// and allows implementation of a custom policy per request.
+	Override func(header http.Header, r *http.Request)
+}
+
+// DefaultSecurityHeaders is the policy used when no
+// other policy was set by [WithSecurityHeaders] or [WithServerSecurityHeaders].
+var DefaultSecurityHeaders = SecurityHeaders{
+	CacheControl:          "no-store",
+	Pragma:                "no-cache",
+	ReferrerPolicy:        "no-referrer",
+	ContentTypeOptions:    "nosniff",
+	FrameOptions:          "DENY",
+	ContentSecurityPolicy: "frame-ancestors 'none'",
+}
+
+// Handler returns a middleware which sets the headers
+// on the response, before calling next.
+func (s *SecurityHeaders) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.setHeaders(w.Header(), r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *SecurityHeaders) setHeaders(header http.Header, r *http.Request) {
+	for _, h := range []struct{ key, value string }{
+		{"Cache-Control", s.CacheControl},
+		{"Pragma", s.Pragma},
+		{"Referrer-Policy", s.ReferrerPolicy},
+		{"X-Content-Type-Options", s.ContentTypeOptions},
+		{"X-Frame-Options", s.FrameOptions},
+		{"Content-Security-Policy", s.ContentSecurityPolicy},
+	} {
+		if h.value != "" {
+			header.Set(h.key, h.value)
+		}
+	}
+	if s.Override != nil {
+		s.Override(header, r)
+	}
+}
+
+type securityHeadersOptioner interface {
+	SecurityHeaders() *SecurityHeaders
+}
@@ -0,0 +1,63 @@
+package op_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders_Handler(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers op.SecurityHeaders
+		want    http.Header
+	}{
+		{
+			name:    "default",
+			headers: op.DefaultSecurityHeaders,
+			want: http.Header{
+				"Cache-Control":           {"no-store"},
+				"Pragma":                  {"no-cache"},
+				"Referrer-Policy":         {"no-referrer"},
+				"X-Content-Type-Options":  {"nosniff"},
+				"X-Frame-Options":         {"DENY"},
+				"Content-Security-Policy": {"frame-ancestors 'none'"},
+			},
+		},
+		{
+			name: "empty values omitted",
+			headers: op.SecurityHeaders{
+				CacheControl: "no-store",
+			},
+			want: http.Header{
+				"Cache-Control": {"no-store"},
+			},
+		},
+		{
+			name: "override",
+			headers: op.SecurityHeaders{
+				CacheControl: "no-store",
+				Pragma:       "no-cache",
+				Override: func(header http.Header, r *http.Request) {
+					header.Del("Pragma")
+					header.Set("Content-Security-Policy", "default-src 'self'")
+				},
+			},
+			want: http.Header{
+				"Cache-Control":           {"no-store"},
+				"Content-Security-Policy": {"default-src 'self'"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.headers.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tt.want, w.Header())
+		})
+	}
+}
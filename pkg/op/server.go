@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"net/url"
 
-	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 	"github.com/muhlemmer/gu"
 )
@@ -71,6 +70,48 @@ type Server interface {
 	// The recommended Response Data type is [oidc.DeviceAuthorizationResponse].
 	DeviceAuthorization(context.Context, *ClientRequest[oidc.DeviceAuthorizationRequest]) (*Response, error)
 
+	// PushedAuthorization validates and stores the Auth Request pushed by the Client,
+	// to be referenced by the returned `request_uri` in the Auth Request sent to
+	// the authorization endpoint, where VerifyAuthRequest must resolve it.
+	// The Response is sent with status 201 Created.
+	// https://www.rfc-editor.org/rfc/rfc9126#section-2
+	// The recommended Response Data type is [oidc.PushedAuthorizationResponse].
+	PushedAuthorization(context.Context, *ClientRequest[oidc.AuthRequest]) (*Response, error)
+
+	// BackchannelAuthentication validates and stores the Backchannel Authentication Request
+	// of the Client-Initiated Backchannel Authentication Flow and starts the
+	// authentication of the user on its authentication device.
+	// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7
+	// The recommended Response Data type is [oidc.BackchannelAuthenticationResponse].
+	BackchannelAuthentication(context.Context, *ClientRequest[oidc.BackchannelAuthenticationRequest]) (*Response, error)
+
+	// SessionTransfer issues a one-time transfer token of the session of the user,
+	// identified by the subject_token of the first-party client, to its native app,
+	// which redeems it with the session transfer grant.
+	// The recommended Response Data type is [oidc.SessionTransferResponse].
+	SessionTransfer(context.Context, *ClientRequest[oidc.SessionTransferRequest]) (*Response, error)
+
+	// ConsentReceipts returns the signed receipts of the authorizations granted
+	// by the user, authenticated with an access token of the user.
+	// The recommended Response Data type is [oidc.ConsentReceiptsResponse].
+	ConsentReceipts(context.Context, *Request[oidc.ConsentReceiptsRequest]) (*Response, error)
+
+	// RegisterClient validates the metadata and registers a new Client.
+	// An initial access token may be sent as bearer token in the Authorization header.
+	// The Response is sent with status 201 Created.
+	// https://www.rfc-editor.org/rfc/rfc7591#section-3
+	// The recommended Response Data type is [oidc.ClientRegistrationResponse].
+	RegisterClient(context.Context, *Request[oidc.ClientRegistrationRequest]) (*Response, error)
+
+	// ClientConfiguration reads (GET), updates (PUT) or deletes (DELETE) the Client
+	// identified by the client_id query parameter of the registration endpoint,
+	// authorized by the registration access token in the Authorization header.
+	// Data is only set for updates. Deletions are sent with status 204 No Content
+	// and an empty body.
+	// https://www.rfc-editor.org/rfc/rfc7592#section-2
+	// The recommended Response Data type is [oidc.ClientRegistrationResponse].
+	ClientConfiguration(context.Context, *Request[oidc.ClientRegistrationRequest]) (*Response, error)
+
 	// VerifyClient is called on most oauth/token handlers to authenticate,
 	// using either a secret (POST, Basic) or assertion (JWT).
 	// If no secrets are provided, the client must be public.
@@ -124,6 +165,22 @@ type Server interface {
 	// The recommended Response Data type is [oidc.AccessTokenResponse].
 	DeviceToken(context.Context, *ClientRequest[oidc.DeviceAccessTokenRequest]) (*Response, error)
 
+	// BackchannelToken handles the Client-Initiated Backchannel Authentication Grant.
+	// It is called by the Token endpoint handler when
+	// grant_type has the value urn:openid:params:grant-type:ciba.
+	// Like DeviceToken, it is typically polled and appropriate errors
+	// should be returned to signal authorization_pending or slow_down etc.
+	// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.1
+	// The recommended Response Data type is [oidc.AccessTokenResponse].
+	BackchannelToken(context.Context, *ClientRequest[oidc.BackchannelTokenRequest]) (*Response, error)
+
+	// SessionTransferToken handles the session transfer grant,
+	// redeeming the transfer token of a native app with its code_verifier.
+	// It is called by the Token endpoint handler when
+	// grant_type has the value of [oidc.GrantTypeSessionTransfer].
+	// The recommended Response Data type is [oidc.AccessTokenResponse].
+	SessionTransferToken(context.Context, *ClientRequest[oidc.SessionTransferTokenRequest]) (*Response, error)
+
 	// Introspect handles the OAuth 2.0 Token Introspection endpoint.
 	// https://datatracker.ietf.org/doc/html/rfc7662
 	// The recommended Response Data type is [oidc.IntrospectionResponse].
@@ -224,8 +281,12 @@ func NewResponse(data any) *Response {
 }
 
 func (resp *Response) writeOut(w http.ResponseWriter) {
+	resp.writeOutWithStatus(w, http.StatusOK)
+}
+
+func (resp *Response) writeOutWithStatus(w http.ResponseWriter, status int) {
 	gu.MapMerge(resp.Header, w.Header())
-	httphelper.MarshalJSON(w, resp.Data)
+	WriteJSON(w, resp.Data, status)
 }
 
 // Redirect is a special response type which will
@@ -305,6 +366,30 @@ func (UnimplementedServer) DeviceAuthorization(ctx context.Context, r *ClientReq
 	return nil, unimplementedError(r)
 }
 
+func (UnimplementedServer) PushedAuthorization(ctx context.Context, r *ClientRequest[oidc.AuthRequest]) (*Response, error) {
+	return nil, unimplementedError(r)
+}
+
+func (UnimplementedServer) BackchannelAuthentication(ctx context.Context, r *ClientRequest[oidc.BackchannelAuthenticationRequest]) (*Response, error) {
+	return nil, unimplementedError(r)
+}
+
+func (UnimplementedServer) SessionTransfer(ctx context.Context, r *ClientRequest[oidc.SessionTransferRequest]) (*Response, error) {
+	return nil, unimplementedError(r)
+}
+
+func (UnimplementedServer) ConsentReceipts(ctx context.Context, r *Request[oidc.ConsentReceiptsRequest]) (*Response, error) {
+	return nil, unimplementedError(r)
+}
+
+func (UnimplementedServer) RegisterClient(ctx context.Context, r *Request[oidc.ClientRegistrationRequest]) (*Response, error) {
+	return nil, unimplementedError(r)
+}
+
+func (UnimplementedServer) ClientConfiguration(ctx context.Context, r *Request[oidc.ClientRegistrationRequest]) (*Response, error) {
+	return nil, unimplementedError(r)
+}
+
 func (UnimplementedServer) VerifyClient(ctx context.Context, r *Request[ClientCredentials]) (Client, error) {
 	return nil, unimplementedError(r)
 }
@@ -333,6 +418,14 @@ func (UnimplementedServer) DeviceToken(ctx context.Context, r *ClientRequest[oid
 	return nil, unimplementedGrantError(oidc.GrantTypeDeviceCode)
 }
 
+func (UnimplementedServer) BackchannelToken(ctx context.Context, r *ClientRequest[oidc.BackchannelTokenRequest]) (*Response, error) {
+	return nil, unimplementedGrantError(oidc.GrantTypeCIBA)
+}
+
+func (UnimplementedServer) SessionTransferToken(ctx context.Context, r *ClientRequest[oidc.SessionTransferTokenRequest]) (*Response, error) {
+	return nil, unimplementedGrantError(oidc.GrantTypeSessionTransfer)
+}
+
 func (UnimplementedServer) Introspect(ctx context.Context, r *Request[IntrospectionRequest]) (*Response, error) {
 	return nil, unimplementedError(r)
 }
@@ -0,0 +1,231 @@
+package op
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/url"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// EmbeddedServer runs the protocol operations of a [Server], such as a [LegacyServer],
+// without an HTTP server, so the provider can be embedded behind gRPC, message queues
+// or other custom transports.
+//
+// Its methods take the typed requests of the operations, as decoded by the transport,
+// and perform the same client authentication and validation as the handlers registered
+// by [RegisterServer], before calling the Server. The Header of the requests may carry
+// metadata of the transport, such as the cookies of the user agent.
+// Errors are returned as by the Server, usually as [*oidc.Error] or [StatusError],
+// for the transport to map to its own error responses.
+//
+// Unlike the handlers of [RegisterServer], no [ServerOption] applies. As DPoP proofs
+// and mTLS client certificates cannot be verified, requests with a DPoP header and
+// clients authenticating by mTLS are rejected, rather than issued unbound tokens.
+//
+// EXPERIMENTAL: may change until v4
+type EmbeddedServer struct {
+	server Server
+}
+
+// NewEmbeddedServer returns an [EmbeddedServer] of the server.
+//
+// EXPERIMENTAL: may change until v4
+func NewEmbeddedServer(server Server) *EmbeddedServer {
+	return &EmbeddedServer{server: server}
+}
+
+// Authorize validates the auth request and initiates the authorization flow,
+// returning the redirect to the login page, or to the client if the
+// request was completed silently.
+func (e *EmbeddedServer) Authorize(ctx context.Context, r *Request[oidc.AuthRequest]) (*Redirect, error) {
+	ctx, span := tracer.Start(ctx, "EmbeddedServer.Authorize")
+	defer span.End()
+
+	return authorizeServer(ctx, e.server, embeddedRequest(r))
+}
+
+// CodeExchange authenticates the client and exchanges the authorization code for tokens.
+// The recommended Response Data type is [oidc.AccessTokenResponse].
+func (e *EmbeddedServer) CodeExchange(ctx context.Context, cc *ClientCredentials, r *Request[oidc.AccessTokenRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "EmbeddedServer.CodeExchange")
+	defer span.End()
+
+	r = embeddedRequest(r)
+	client, err := verifyEmbeddedClient(ctx, e.server, cc, r, oidc.GrantTypeCode)
+	if err != nil {
+		return nil, err
+	}
+	if r.Data.Code == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("code missing")
+	}
+	if r.Data.RedirectURI == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("redirect_uri missing")
+	}
+	return e.server.CodeExchange(ctx, &ClientRequest[oidc.AccessTokenRequest]{Request: r, Client: client})
+}
+
+// RefreshToken authenticates the client and issues new tokens for the refresh token.
+// The recommended Response Data type is [oidc.AccessTokenResponse].
+func (e *EmbeddedServer) RefreshToken(ctx context.Context, cc *ClientCredentials, r *Request[oidc.RefreshTokenRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "EmbeddedServer.RefreshToken")
+	defer span.End()
+
+	r = embeddedRequest(r)
+	client, err := verifyEmbeddedClient(ctx, e.server, cc, r, oidc.GrantTypeRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if r.Data.RefreshToken == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("refresh_token missing")
+	}
+	return e.server.RefreshToken(ctx, &ClientRequest[oidc.RefreshTokenRequest]{Request: r, Client: client})
+}
+
+// ClientCredentialsExchange authenticates the client and issues its tokens
+// with the client credentials grant.
+// The recommended Response Data type is [oidc.AccessTokenResponse].
+func (e *EmbeddedServer) ClientCredentialsExchange(ctx context.Context, cc *ClientCredentials, r *Request[oidc.ClientCredentialsRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "EmbeddedServer.ClientCredentialsExchange")
+	defer span.End()
+
+	r = embeddedRequest(r)
+	client, err := verifyEmbeddedClient(ctx, e.server, cc, r, oidc.GrantTypeClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+	if client.AuthMethod() == oidc.AuthMethodNone {
+		return nil, oidc.ErrInvalidClient().WithDescription("client must be authenticated")
+	}
+	return e.server.ClientCredentialsExchange(ctx, &ClientRequest[oidc.ClientCredentialsRequest]{Request: r, Client: client})
+}
+
+// DeviceToken authenticates the client and issues the tokens of the device code,
+// once authorized by the user.
+// The recommended Response Data type is [oidc.AccessTokenResponse].
+func (e *EmbeddedServer) DeviceToken(ctx context.Context, cc *ClientCredentials, r *Request[oidc.DeviceAccessTokenRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "EmbeddedServer.DeviceToken")
+	defer span.End()
+
+	r = embeddedRequest(r)
+	client, err := verifyEmbeddedClient(ctx, e.server, cc, r, oidc.GrantTypeDeviceCode)
+	if err != nil {
+		return nil, err
+	}
+	if r.Data.DeviceCode == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("device_code missing")
+	}
+	return e.server.DeviceToken(ctx, &ClientRequest[oidc.DeviceAccessTokenRequest]{Request: r, Client: client})
+}
+
+// Introspect returns the introspection response of the token
+// to the authenticated client, usually a resource server.
+// The recommended Response Data type is [oidc.IntrospectionResponse].
+func (e *EmbeddedServer) Introspect(ctx context.Context, cc *ClientCredentials, r *Request[oidc.IntrospectionRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "EmbeddedServer.Introspect")
+	defer span.End()
+
+	r = embeddedRequest(r)
+	if cc == nil || (cc.ClientSecret == "" && cc.ClientAssertion == "") {
+		return nil, oidc.ErrInvalidClient().WithDescription("client must be authenticated")
+	}
+	if err := checkEmbeddedDPoP(r); err != nil {
+		return nil, err
+	}
+	if r.Data.Token == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("token missing")
+	}
+	return e.server.Introspect(ctx, &Request[IntrospectionRequest]{
+		Method:   r.Method,
+		URL:      r.URL,
+		Header:   r.Header,
+		Form:     r.Form,
+		PostForm: r.PostForm,
+		Data:     &IntrospectionRequest{cc, r.Data},
+	})
+}
+
+// Revoke authenticates the client and revokes its token.
+func (e *EmbeddedServer) Revoke(ctx context.Context, cc *ClientCredentials, r *Request[oidc.RevocationRequest]) error {
+	ctx, span := tracer.Start(ctx, "EmbeddedServer.Revoke")
+	defer span.End()
+
+	r = embeddedRequest(r)
+	client, err := verifyEmbeddedClient(ctx, e.server, cc, r, "")
+	if err != nil {
+		return err
+	}
+	if r.Data.Token == "" {
+		return oidc.ErrInvalidRequest().WithDescription("token missing")
+	}
+	_, err = e.server.Revocation(ctx, &ClientRequest[oidc.RevocationRequest]{Request: r, Client: client})
+	return err
+}
+
+// verifyEmbeddedClient authenticates the client of the request by its credentials
+// and checks that the grant type, if any, is allowed for the client.
+func verifyEmbeddedClient[T any](ctx context.Context, server Server, cc *ClientCredentials, r *Request[T], grantType oidc.GrantType) (Client, error) {
+	if cc == nil || (cc.ClientID == "" && cc.ClientAssertion == "") {
+		return nil, oidc.ErrInvalidRequest().WithDescription("client_id or client_assertion must be provided")
+	}
+	if cc.ClientAssertion != "" && cc.ClientAssertionType != oidc.ClientAssertionTypeJWTAssertion && cc.ClientAssertionType != oidc.ClientAssertionTypeJWTSPIFFE {
+		return nil, oidc.ErrInvalidRequest().WithDescription("invalid client_assertion_type %s", cc.ClientAssertionType)
+	}
+	if err := checkEmbeddedDPoP(r); err != nil {
+		return nil, err
+	}
+	if grantType != "" {
+		r.Form.Set(oidc.ParamGrantType, string(grantType))
+	}
+	client, err := server.VerifyClient(ctx, &Request[ClientCredentials]{
+		Method: r.Method,
+		URL:    r.URL,
+		Header: r.Header,
+		Form:   r.Form,
+		Data:   cc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch client.AuthMethod() {
+	case oidc.AuthMethodTLSClientAuth, oidc.AuthMethodSelfSignedTLSClientAuth:
+		return nil, oidc.ErrInvalidClient().WithDescription("mTLS client authentication is not supported without the HTTP handlers")
+	}
+	if grantType != "" && !ValidateGrantType(client, grantType) {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("grant_type %q not allowed", grantType)
+	}
+	return client, nil
+}
+
+// checkEmbeddedDPoP rejects requests with a DPoP proof, which cannot be verified
+// without the HTTP handlers, so the tokens are not issued unbound.
+func checkEmbeddedDPoP[T any](r *Request[T]) error {
+	if len(r.Header.Values(oidc.DPoPHeader)) > 0 {
+		return oidc.ErrInvalidDPoPProof().WithDescription("DPoP proofs are not supported without the HTTP handlers")
+	}
+	return nil
+}
+
+// embeddedRequest returns a copy of the request with the informational fields,
+// which the transport may leave empty, set as for a posted HTTP request.
+func embeddedRequest[T any](r *Request[T]) *Request[T] {
+	req := *r
+	if req.Data == nil {
+		req.Data = new(T)
+	}
+	if req.Method == "" {
+		req.Method = http.MethodPost
+	}
+	if req.URL == nil {
+		req.URL = new(url.URL)
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Form = maps.Clone(req.Form)
+	if req.Form == nil {
+		req.Form = make(url.Values)
+	}
+	return &req
+}
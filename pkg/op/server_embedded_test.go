@@ -0,0 +1,80 @@
+package op_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestEmbeddedServer(t *testing.T) {
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	server := op.NewEmbeddedServer(op.NewLegacyServer(testProvider, *op.DefaultEndpoints))
+
+	t.Run("authorize", func(t *testing.T) {
+		redirect, err := server.Authorize(ctx, &op.Request[oidc.AuthRequest]{Data: &oidc.AuthRequest{
+			ClientID:     "web",
+			RedirectURI:  "https://example.com",
+			ResponseType: oidc.ResponseTypeCode,
+			Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID},
+			State:        "state1",
+		}})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(redirect.URL, "/login/username?authRequestID="), redirect.URL)
+
+		_, err = server.Authorize(ctx, &op.Request[oidc.AuthRequest]{Data: &oidc.AuthRequest{
+			ClientID:     "web",
+			RedirectURI:  "https://evil.example.com",
+			ResponseType: oidc.ResponseTypeCode,
+			Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID},
+		}})
+		assert.ErrorIs(t, err, oidc.ErrInvalidRequestRedirectURI())
+	})
+	t.Run("token, introspect and revoke", func(t *testing.T) {
+		resp, err := server.ClientCredentialsExchange(ctx,
+			&op.ClientCredentials{ClientID: "sid1", ClientSecret: "verysecret"},
+			&op.Request[oidc.ClientCredentialsRequest]{Data: &oidc.ClientCredentialsRequest{Scope: oidc.SpaceDelimitedArray{oidc.ScopeOpenID}}},
+		)
+		require.NoError(t, err)
+		tokens, ok := resp.Data.(*oidc.AccessTokenResponse)
+		require.True(t, ok)
+		require.NotEmpty(t, tokens.AccessToken)
+
+		webClient := &op.ClientCredentials{ClientID: "web", ClientSecret: "secret"}
+		resp, err = server.Introspect(ctx, webClient, &op.Request[oidc.IntrospectionRequest]{Data: &oidc.IntrospectionRequest{Token: tokens.AccessToken}})
+		require.NoError(t, err)
+		introspection, ok := resp.Data.(*oidc.IntrospectionResponse)
+		require.True(t, ok)
+		assert.False(t, introspection.Active, "web is not an audience of the token")
+
+		err = server.Revoke(ctx, webClient, &op.Request[oidc.RevocationRequest]{Data: &oidc.RevocationRequest{}})
+		assert.ErrorIs(t, err, oidc.ErrInvalidRequest())
+	})
+	t.Run("client authentication", func(t *testing.T) {
+		request := &op.Request[oidc.AccessTokenRequest]{Data: &oidc.AccessTokenRequest{Code: "code", RedirectURI: "https://example.com"}}
+		_, err := server.CodeExchange(ctx, nil, request)
+		assert.ErrorIs(t, err, oidc.ErrInvalidRequest())
+		_, err = server.CodeExchange(ctx, &op.ClientCredentials{ClientID: "web", ClientSecret: "wrong"}, request)
+		assert.Error(t, err)
+		_, err = server.CodeExchange(ctx, &op.ClientCredentials{ClientID: "device", ClientSecret: "secret"}, request)
+		assert.ErrorIs(t, err, oidc.ErrUnauthorizedClient(), "grant type not allowed")
+		_, err = server.Introspect(ctx, &op.ClientCredentials{ClientID: "web"}, &op.Request[oidc.IntrospectionRequest]{Data: &oidc.IntrospectionRequest{Token: "token"}})
+		assert.ErrorIs(t, err, oidc.ErrInvalidClient())
+	})
+	t.Run("DPoP proof", func(t *testing.T) {
+		header := make(http.Header)
+		header.Set(oidc.DPoPHeader, "proof")
+		request := &op.Request[oidc.ClientCredentialsRequest]{
+			Header: header,
+			Data:   &oidc.ClientCredentialsRequest{Scope: oidc.SpaceDelimitedArray{oidc.ScopeOpenID}},
+		}
+		_, err := server.ClientCredentialsExchange(ctx, &op.ClientCredentials{ClientID: "sid1", ClientSecret: "verysecret"}, request)
+		assert.ErrorIs(t, err, oidc.ErrInvalidDPoPProof(), "tokens are not issued unbound")
+	})
+}
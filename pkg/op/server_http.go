@@ -2,13 +2,17 @@ package op
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/muhlemmer/gu"
 	"github.com/rs/cors"
 	"github.com/zitadel/logging"
 	"github.com/zitadel/schema"
@@ -30,17 +34,36 @@ func RegisterServer(server Server, endpoints Endpoints, options ...ServerOption)
 		endpoints: endpoints,
 		decoder:   decoder,
 		corsOpts:  &defaultCORSOptions,
+		headers:   &DefaultSecurityHeaders,
 		logger:    slog.Default(),
 	}
 
 	for _, option := range options {
 		option(ws)
 	}
+	if ws.correlation != nil {
+		ws.logger = correlationLogger(ws.logger)
+	}
 
 	ws.createRouter()
 	ws.handler = ws.router
+	if ws.mtlsAliases != nil {
+		ws.handler = ws.mtlsAliases.Handler(&ws.endpoints)(ws.handler)
+	}
+	if ws.headers != nil {
+		ws.handler = ws.headers.Handler(ws.handler)
+	}
 	if ws.corsOpts != nil {
-		ws.handler = cors.New(*ws.corsOpts).Handler(ws.router)
+		ws.handler = cors.New(*ws.corsOpts).Handler(ws.handler)
+	}
+	if len(ws.responseHooks) > 0 {
+		ws.handler = ws.responseHooks.Handler(ws.handler)
+	}
+	if ws.correlation != nil {
+		ws.handler = ws.correlation.Handler(ws.handler)
+	}
+	if ws.random != nil {
+		ws.handler = randomHandler(ws.random)(ws.handler)
 	}
 	return ws
 }
@@ -77,6 +100,76 @@ func WithServerCORSOptions(opts *cors.Options) ServerOption {
 	}
 }
 
+// WithServerSecurityHeaders sets the security headers policy
+// for all responses of the Server.
+// Defaults to [DefaultSecurityHeaders].
+// Passing nil disables the security headers.
+func WithServerSecurityHeaders(headers *SecurityHeaders) ServerOption {
+	return func(s *webServer) {
+		s.headers = headers
+	}
+}
+
+// WithServerMTLSAliases serves the configured endpoints on the mTLS alias host.
+// The Server is responsible to advertise them in discovery.
+func WithServerMTLSAliases(aliases *MTLSAliases) ServerOption {
+	return func(s *webServer) {
+		s.mtlsAliases = aliases
+	}
+}
+
+// WithServerJSONRequestBodies accepts application/json request bodies at the
+// token, introspection and revocation endpoints, see [WithJSONRequestBodies].
+func WithServerJSONRequestBodies() ServerOption {
+	return func(s *webServer) {
+		s.jsonBodies = true
+	}
+}
+
+// WithServerMTLS reads the client certificates at the endpoints called by clients,
+// for the mutual-TLS client authentication and certificate-bound access tokens,
+// see [WithMTLS].
+func WithServerMTLS(config *MTLSConfig) ServerOption {
+	return func(s *webServer) {
+		s.mtls = config
+	}
+}
+
+// WithServerDPoP verifies DPoP proofs at the token endpoint, see [WithDPoP].
+// Proofs are checked for replays, if the cache is not nil.
+func WithServerDPoP(replay cache.Cache) ServerOption {
+	return func(s *webServer) {
+		s.dpop = true
+		s.dpopReplay = replay
+	}
+}
+
+// WithServerTokenExchangeTokenTypes accepts subject and actor tokens of the types
+// in token exchange requests, such as [oidc.SAML2TokenType], which the Server
+// verifies, see [WithTokenExchangeTokenVerifier].
+func WithServerTokenExchangeTokenTypes(types ...oidc.TokenType) ServerOption {
+	return func(s *webServer) {
+		s.tokenExchangeTokenTypes = append(s.tokenExchangeTokenTypes, types...)
+	}
+}
+
+// WithServerTokenHooks calls the hooks per grant type at the token endpoint,
+// see [TokenHooks]. The Issue hooks are called by the [LegacyServer] only,
+// other implementations of the Server create tokens themselves.
+func WithServerTokenHooks(hooks *TokenEndpointHooks) ServerOption {
+	return func(s *webServer) {
+		s.tokenHooks = hooks
+	}
+}
+
+// WithServerInstrumentation records the requests to the endpoints
+// of the Server, see [Instrumentation].
+func WithServerInstrumentation(i *Instrumentation) ServerOption {
+	return func(s *webServer) {
+		s.instrumentation = i
+	}
+}
+
 // WithFallbackLogger overrides the fallback logger, which
 // is used when no logger was found in the context.
 // Defaults to [slog.Default].
@@ -87,13 +180,31 @@ func WithFallbackLogger(logger *slog.Logger) ServerOption {
 }
 
 type webServer struct {
-	server    Server
-	router    *chi.Mux
-	handler   http.Handler
-	endpoints Endpoints
-	decoder   httphelper.Decoder
-	corsOpts  *cors.Options
-	logger    *slog.Logger
+	server      Server
+	router      *chi.Mux
+	handler     http.Handler
+	endpoints   Endpoints
+	decoder     httphelper.Decoder
+	corsOpts    *cors.Options
+	headers     *SecurityHeaders
+	mtlsAliases *MTLSAliases
+	jsonBodies  bool
+	mtls        *MTLSConfig
+	dpop        bool
+	dpopReplay  cache.Cache
+	logger      *slog.Logger
+
+	// tokenExchangeTokenTypes are accepted as subject_token_type
+	// and actor_token_type in addition to [oidc.AllTokenTypes].
+	tokenExchangeTokenTypes []oidc.TokenType
+
+	instrumentation *Instrumentation
+	// endpointMiddleware are set by [WithServerEndpointMiddleware].
+	endpointMiddleware EndpointMiddleware
+	correlation        *CorrelationPolicy
+	responseHooks      responseHooks
+	tokenHooks         *TokenEndpointHooks
+	random             io.Reader
 }
 
 func (s *webServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -110,20 +221,67 @@ func (s *webServer) getLogger(ctx context.Context) *slog.Logger {
 func (s *webServer) createRouter() {
 	s.router.HandleFunc(healthEndpoint, simpleHandler(s, s.server.Health))
 	s.router.HandleFunc(readinessEndpoint, simpleHandler(s, s.server.Ready))
-	s.router.HandleFunc(oidc.DiscoveryEndpoint, simpleHandler(s, s.server.Discovery))
+	s.router.HandleFunc(oidc.DiscoveryEndpoint, endpointHandler(s.instrumentation, s.endpointMiddleware, EndpointNameDiscovery, simpleHandler(s, s.server.Discovery)))
+
+	s.endpointRoute(s.endpoints.Authorization, EndpointNameAuthorization, s.authorizeHandler)
+	s.endpointRoute(s.endpoints.DeviceAuthorization, EndpointNameDeviceAuthorization, s.withClient(s.deviceAuthorizationHandler))
+	s.endpointRoute(s.endpoints.PushedAuthorization, EndpointNamePushedAuthorization, s.clientRequestHandler(s.withClient(s.pushedAuthorizationHandler)))
+	s.endpointRoute(s.endpoints.BackchannelAuthentication, EndpointNameBackchannelAuthentication, s.clientRequestHandler(s.withClient(s.backchannelAuthenticationHandler)))
+	s.endpointRoute(s.endpoints.Registration, EndpointNameRegistration, s.registrationHandler)
+	s.endpointRoute(s.endpoints.SessionTransfer, EndpointNameSessionTransfer, s.clientRequestHandler(s.withClient(s.sessionTransferHandler)))
+	s.endpointRoute(s.endpoints.ConsentReceipts, EndpointNameConsentReceipts, s.consentReceiptsHandler)
+	s.endpointRoute(s.endpoints.Token, EndpointNameToken, s.clientRequestHandler(s.dpopHandler(s.tokenHooksHandler(s.tokensHandler))))
+	s.endpointRoute(s.endpoints.Introspection, EndpointNameIntrospection, s.clientRequestHandler(s.introspectionHandler))
+	s.endpointRoute(s.endpoints.Userinfo, EndpointNameUserinfo, s.userInfoHandler)
+	s.endpointRoute(s.endpoints.Revocation, EndpointNameRevocation, s.clientRequestHandler(s.withClient(s.revocationHandler)))
+	s.endpointRoute(s.endpoints.EndSession, EndpointNameEndSession, s.endSessionHandler)
+	s.endpointRoute(s.endpoints.JwksURI, EndpointNameKeys, simpleHandler(s, s.server.Keys))
+}
+
+// dpopHandler verifies DPoP proofs, if enabled with [WithServerDPoP].
+func (s *webServer) dpopHandler(handler http.HandlerFunc) http.HandlerFunc {
+	if !s.dpop {
+		return handler
+	}
+	return dpopHandler(s.endpoints.Token, s.dpopReplay,
+		func(w http.ResponseWriter, r *http.Request, err error) {
+			WriteError(w, r, err, s.getLogger(r.Context()))
+		},
+		handler,
+	)
+}
+
+// tokenHooksHandler calls the hooks set with [WithServerTokenHooks].
+func (s *webServer) tokenHooksHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return tokenHooksHandler(s.tokenHooks,
+		func() httphelper.Decoder { return s.decoder },
+		func(w http.ResponseWriter, r *http.Request, err error) {
+			WriteError(w, r, err, s.getLogger(r.Context()))
+		},
+		handler,
+	)
+}
 
-	s.endpointRoute(s.endpoints.Authorization, s.authorizeHandler)
-	s.endpointRoute(s.endpoints.DeviceAuthorization, s.withClient(s.deviceAuthorizationHandler))
-	s.endpointRoute(s.endpoints.Token, s.tokensHandler)
-	s.endpointRoute(s.endpoints.Introspection, s.introspectionHandler)
-	s.endpointRoute(s.endpoints.Userinfo, s.userInfoHandler)
-	s.endpointRoute(s.endpoints.Revocation, s.withClient(s.revocationHandler))
-	s.endpointRoute(s.endpoints.EndSession, s.endSessionHandler)
-	s.endpointRoute(s.endpoints.JwksURI, simpleHandler(s, s.server.Keys))
+// clientRequestHandler reads the client certificate, if enabled with [WithServerMTLS],
+// and accepts JSON request bodies, if enabled with [WithServerJSONRequestBodies].
+func (s *webServer) clientRequestHandler(handler http.HandlerFunc) http.HandlerFunc {
+	writeError := func(w http.ResponseWriter, r *http.Request, err error) {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+	}
+	handler = mtlsHandler(s.mtls, writeError, handler)
+	if !s.jsonBodies {
+		return handler
+	}
+	return jsonBodyHandler(
+		func(r *http.Request) *slog.Logger { return s.getLogger(r.Context()) },
+		writeError,
+		handler,
+	)
 }
 
-func (s *webServer) endpointRoute(e *Endpoint, hf http.HandlerFunc) {
+func (s *webServer) endpointRoute(e *Endpoint, name string, hf http.HandlerFunc) {
 	if e != nil {
+		hf = endpointHandler(s.instrumentation, s.endpointMiddleware, name, hf)
 		traceHandler := func(w http.ResponseWriter, r *http.Request) {
 			ctx, span := tracer.Start(r.Context(), e.Relative())
 			r = r.WithContext(ctx)
@@ -148,7 +306,7 @@ func (s *webServer) withClient(handler clientHandler) http.HandlerFunc {
 			WriteError(w, r, err, s.getLogger(r.Context()))
 			return
 		}
-		if grantType := oidc.GrantType(r.Form.Get("grant_type")); grantType != "" {
+		if grantType := oidc.GrantType(r.Form.Get(oidc.ParamGrantType)); grantType != "" {
 			if !ValidateGrantType(client, grantType) {
 				WriteError(w, r, oidc.ErrUnauthorizedClient().WithDescription("grant_type %q not allowed", grantType), s.getLogger(r.Context()))
 				return
@@ -194,18 +352,23 @@ func (s *webServer) parseClientCredentials(r *http.Request) (_ *ClientCredential
 	if cc.ClientID == "" && cc.ClientAssertion == "" {
 		return nil, oidc.ErrInvalidRequest().WithDescription("client_id or client_assertion must be provided")
 	}
-	if cc.ClientAssertion != "" && cc.ClientAssertionType != oidc.ClientAssertionTypeJWTAssertion {
+	if cc.ClientAssertion != "" && cc.ClientAssertionType != oidc.ClientAssertionTypeJWTAssertion && cc.ClientAssertionType != oidc.ClientAssertionTypeJWTSPIFFE {
 		return nil, oidc.ErrInvalidRequest().WithDescription("invalid client_assertion_type %s", cc.ClientAssertionType)
 	}
 	return cc, nil
 }
 
 func (s *webServer) authorizeHandler(w http.ResponseWriter, r *http.Request) {
-	request, err := decodeRequest[oidc.AuthRequest](s.decoder, r, false)
+	form, err := authorizeForm(r)
 	if err != nil {
 		WriteError(w, r, err, s.getLogger(r.Context()))
 		return
 	}
+	request := new(oidc.AuthRequest)
+	if err = s.decoder.Decode(request, form); err != nil {
+		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("error decoding form").WithParent(err), s.getLogger(r.Context()))
+		return
+	}
 	redirect, err := s.authorize(r.Context(), newRequest(r, request))
 	if err != nil {
 		WriteError(w, r, err, s.getLogger(r.Context()))
@@ -215,7 +378,12 @@ func (s *webServer) authorizeHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *webServer) authorize(ctx context.Context, r *Request[oidc.AuthRequest]) (_ *Redirect, err error) {
-	cr, err := s.server.VerifyAuthRequest(ctx, r)
+	return authorizeServer(ctx, s.server, r)
+}
+
+// authorizeServer verifies and validates the auth request before passing it to the server.
+func authorizeServer(ctx context.Context, server Server, r *Request[oidc.AuthRequest]) (_ *Redirect, err error) {
+	cr, err := server.VerifyAuthRequest(ctx, r)
 	if err != nil {
 		return nil, err
 	}
@@ -237,7 +405,7 @@ func (s *webServer) authorize(ctx context.Context, r *Request[oidc.AuthRequest])
 	if err := ValidateAuthReqResponseType(cr.Client, authReq.ResponseType); err != nil {
 		return nil, err
 	}
-	return s.server.Authorize(ctx, cr)
+	return server.Authorize(ctx, cr)
 }
 
 func (s *webServer) deviceAuthorizationHandler(w http.ResponseWriter, r *http.Request, client Client) {
@@ -254,13 +422,129 @@ func (s *webServer) deviceAuthorizationHandler(w http.ResponseWriter, r *http.Re
 	resp.writeOut(w)
 }
 
+func (s *webServer) pushedAuthorizationHandler(w http.ResponseWriter, r *http.Request, client Client) {
+	if r.Method != http.MethodPost {
+		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("pushed authorization requests must be posted"), s.getLogger(r.Context()))
+		return
+	}
+	request, err := decodeRequest[oidc.AuthRequest](s.decoder, r, true)
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp, err := s.server.PushedAuthorization(r.Context(), newClientRequest(r, request, client))
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp.writeOutWithStatus(w, http.StatusCreated)
+}
+
+func (s *webServer) backchannelAuthenticationHandler(w http.ResponseWriter, r *http.Request, client Client) {
+	if r.Method != http.MethodPost {
+		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("backchannel authentication requests must be posted"), s.getLogger(r.Context()))
+		return
+	}
+	request, err := decodeRequest[oidc.BackchannelAuthenticationRequest](s.decoder, r, false)
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp, err := s.server.BackchannelAuthentication(r.Context(), newClientRequest(r, request, client))
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp.writeOut(w)
+}
+
+func (s *webServer) sessionTransferHandler(w http.ResponseWriter, r *http.Request, client Client) {
+	if r.Method != http.MethodPost {
+		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("session transfer requests must be posted"), s.getLogger(r.Context()))
+		return
+	}
+	request, err := decodeRequest[oidc.SessionTransferRequest](s.decoder, r, false)
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp, err := s.server.SessionTransfer(r.Context(), newClientRequest(r, request, client))
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp.writeOut(w)
+}
+
+func (s *webServer) consentReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	request, err := decodeRequest[oidc.ConsentReceiptsRequest](s.decoder, r, false)
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	if token, err := getAccessToken(r); err == nil {
+		request.AccessToken = token
+	}
+	if request.AccessToken == "" {
+		err = NewStatusError(
+			oidc.ErrInvalidRequest().WithDescription("access token missing"),
+			http.StatusUnauthorized,
+		)
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp, err := s.server.ConsentReceipts(r.Context(), newRequest(r, request))
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp.writeOut(w)
+}
+
+// registrationHandler serves the registration endpoint and,
+// with the client_id query parameter, the client configuration endpoint.
+func (s *webServer) registrationHandler(w http.ResponseWriter, r *http.Request) {
+	request := new(oidc.ClientRegistrationRequest)
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		var err error
+		if request, err = ParseClientRegistrationRequest(w, r); err != nil {
+			WriteError(w, r, err, s.getLogger(r.Context()))
+			return
+		}
+	}
+	if r.URL.Query().Get("client_id") == "" {
+		if r.Method != http.MethodPost {
+			WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("client registration requests must be posted"), s.getLogger(r.Context()))
+			return
+		}
+		resp, err := s.server.RegisterClient(r.Context(), newRequest(r, request))
+		if err != nil {
+			WriteError(w, r, err, s.getLogger(r.Context()))
+			return
+		}
+		resp.writeOutWithStatus(w, http.StatusCreated)
+		return
+	}
+	resp, err := s.server.ClientConfiguration(r.Context(), newRequest(r, request))
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	if r.Method == http.MethodDelete {
+		gu.MapMerge(resp.Header, w.Header())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp.writeOut(w)
+}
+
 func (s *webServer) tokensHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("error parsing form").WithParent(err), s.getLogger(r.Context()))
 		return
 	}
 
-	switch grantType := oidc.GrantType(r.Form.Get("grant_type")); grantType {
+	switch grantType := oidc.GrantType(r.Form.Get(oidc.ParamGrantType)); grantType {
 	case oidc.GrantTypeCode:
 		s.withClient(s.codeExchangeHandler)(w, r)
 	case oidc.GrantTypeRefreshToken:
@@ -273,6 +557,10 @@ func (s *webServer) tokensHandler(w http.ResponseWriter, r *http.Request) {
 		s.withClient(s.tokenExchangeHandler)(w, r)
 	case oidc.GrantTypeDeviceCode:
 		s.withClient(s.deviceTokenHandler)(w, r)
+	case oidc.GrantTypeCIBA:
+		s.withClient(s.backchannelTokenHandler)(w, r)
+	case oidc.GrantTypeSessionTransfer:
+		s.withClient(s.sessionTransferTokenHandler)(w, r)
 	case "":
 		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("grant_type missing"), s.getLogger(r.Context()))
 	default:
@@ -352,7 +640,7 @@ func (s *webServer) tokenExchangeHandler(w http.ResponseWriter, r *http.Request,
 		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("subject_token_type missing"), s.getLogger(r.Context()))
 		return
 	}
-	if !request.SubjectTokenType.IsSupported() {
+	if !request.SubjectTokenType.IsSupported() && !slices.Contains(s.tokenExchangeTokenTypes, request.SubjectTokenType) {
 		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("subject_token_type is not supported"), s.getLogger(r.Context()))
 		return
 	}
@@ -360,7 +648,7 @@ func (s *webServer) tokenExchangeHandler(w http.ResponseWriter, r *http.Request,
 		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("requested_token_type is not supported"), s.getLogger(r.Context()))
 		return
 	}
-	if request.ActorTokenType != "" && !request.ActorTokenType.IsSupported() {
+	if request.ActorTokenType != "" && !request.ActorTokenType.IsSupported() && !slices.Contains(s.tokenExchangeTokenTypes, request.ActorTokenType) {
 		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("actor_token_type is not supported"), s.getLogger(r.Context()))
 		return
 	}
@@ -409,6 +697,42 @@ func (s *webServer) deviceTokenHandler(w http.ResponseWriter, r *http.Request, c
 	resp.writeOut(w)
 }
 
+func (s *webServer) backchannelTokenHandler(w http.ResponseWriter, r *http.Request, client Client) {
+	request, err := decodeRequest[oidc.BackchannelTokenRequest](s.decoder, r, false)
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	if request.AuthReqID == "" {
+		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("auth_req_id missing"), s.getLogger(r.Context()))
+		return
+	}
+	resp, err := s.server.BackchannelToken(r.Context(), newClientRequest(r, request, client))
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp.writeOut(w)
+}
+
+func (s *webServer) sessionTransferTokenHandler(w http.ResponseWriter, r *http.Request, client Client) {
+	request, err := decodeRequest[oidc.SessionTransferTokenRequest](s.decoder, r, false)
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	if request.TransferToken == "" {
+		WriteError(w, r, oidc.ErrInvalidRequest().WithDescription("transfer_token missing"), s.getLogger(r.Context()))
+		return
+	}
+	resp, err := s.server.SessionTransferToken(r.Context(), newClientRequest(r, request, client))
+	if err != nil {
+		WriteError(w, r, err, s.getLogger(r.Context()))
+		return
+	}
+	resp.writeOut(w)
+}
+
 func (s *webServer) introspectionHandler(w http.ResponseWriter, r *http.Request) {
 	cc, err := s.parseClientCredentials(r)
 	if err != nil {
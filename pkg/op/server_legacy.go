@@ -34,6 +34,27 @@ func RegisterLegacyServer(s ExtendedLegacyServer, authorizeCallbackHandler http.
 			r.HandleFunc(s.Endpoints().Authorization.Relative()+authCallbackPathSuffix, authorizeCallbackHandler)
 		}),
 	)
+	if mo, ok := s.Provider().(mtlsAliasesGetter); ok && mo.MTLSAliases() != nil {
+		options = append(options, WithServerMTLSAliases(mo.MTLSAliases()))
+	}
+	if config := mtlsConfig(s.Provider()); config != nil {
+		options = append(options, WithServerMTLS(config))
+	}
+	if dpopEnabled(s.Provider()) {
+		options = append(options, WithServerDPoP(replayCache(s.Provider())))
+	}
+	if getter, ok := s.Provider().(tokenExchangeTokenVerifiersGetter); ok && len(getter.TokenExchangeTokenVerifiers()) > 0 {
+		options = append(options, WithServerTokenExchangeTokenTypes(tokenExchangeTokenTypes(getter.TokenExchangeTokenVerifiers())...))
+	}
+	if policy := correlationPolicy(s.Provider()); policy != nil {
+		options = append(options, WithServerCorrelation(*policy))
+	}
+	if hooks := providerResponseHooks(s.Provider()); len(hooks) > 0 {
+		options = append(options, WithServerResponseHooks(hooks...))
+	}
+	if random := providerRandom(s.Provider()); random != nil {
+		options = append(options, WithServerRandom(random))
+	}
 	return RegisterServer(s, s.Endpoints(), options...)
 }
 
@@ -104,7 +125,7 @@ func (s *LegacyServer) Discovery(ctx context.Context, r *Request[struct{}]) (*Re
 	defer span.End()
 
 	return NewResponse(
-		createDiscoveryConfigV2(ctx, s.provider, s.provider.Storage(), &s.endpoints),
+		createDiscoveryConfigV2(ctx, s.provider, discoverStorage(s.provider), &s.endpoints),
 	), nil
 }
 
@@ -112,11 +133,11 @@ func (s *LegacyServer) Keys(ctx context.Context, r *Request[struct{}]) (*Respons
 	ctx, span := tracer.Start(ctx, "LegacyServer.Keys")
 	defer span.End()
 
-	keys, err := s.provider.Storage().KeySet(ctx)
+	keys, err := keyProvider(s.provider).KeySet(ctx)
 	if err != nil {
 		return nil, AsStatusError(err, http.StatusInternalServerError)
 	}
-	return NewResponse(jsonWebKeySet(keys)), nil
+	return NewResponse(publicKeySet(s.provider, keys)), nil
 }
 
 var (
@@ -128,11 +149,16 @@ func (s *LegacyServer) VerifyAuthRequest(ctx context.Context, r *Request[oidc.Au
 	ctx, span := tracer.Start(ctx, "LegacyServer.VerifyAuthRequest")
 	defer span.End()
 
+	authReq, err := resolvePushedAuthRequest(ctx, s.provider, s.provider.Storage(), r.Data, true)
+	if err != nil {
+		return nil, err
+	}
+	r.Data = authReq
 	if r.Data.RequestParam != "" {
 		if !s.provider.RequestObjectSupported() {
 			return nil, oidc.ErrRequestNotSupported()
 		}
-		err := ParseRequestObject(ctx, r.Data, s.provider.Storage(), IssuerFromContext(ctx))
+		err := parseRequestObject(ctx, r.Data, s.provider.Storage(), IssuerFromContext(ctx), s.provider.Insecure(), clientJWKSCache(s.provider))
 		if err != nil {
 			return nil, err
 		}
@@ -155,13 +181,39 @@ func (s *LegacyServer) Authorize(ctx context.Context, r *ClientRequest[oidc.Auth
 	ctx, span := tracer.Start(ctx, "LegacyServer.Authorize")
 	defer span.End()
 
+	if err = ValidateAuthReqPublicClient(r.Client, r.Data, publicClientPolicy(s.provider)); err != nil {
+		return nil, err
+	}
+	if err = ValidateAuthReqDeprecations(ctx, s.provider, r.Data); err != nil {
+		return nil, err
+	}
+	if err = ValidateAuthReqResponseMode(s.provider, r.Client, r.Data); err != nil {
+		return nil, err
+	}
+	if err = ValidateAuthReqAuthorizationDetails(s.provider, r.Data); err != nil {
+		return nil, err
+	}
 	userID, err := ValidateAuthReqIDTokenHint(ctx, r.Data.IDTokenHint, s.provider.IDTokenHintVerifier(ctx))
 	if err != nil {
 		return nil, err
 	}
-	req, err := s.provider.Storage().CreateAuthRequest(ctx, r.Data, userID)
+	userID, err = internalSubject(ctx, s.provider.Storage(), userID, r.Client.GetID())
+	if err != nil {
+		return nil, oidc.ErrLoginRequired().WithDescription("The id_token_hint is invalid.").WithParent(err)
+	}
+	if err = validateLoginHints(ctx, s.provider.Storage(), r.Data, r.Client); err != nil {
+		return nil, err
+	}
+	req, err := createAuthRequest(ctx, s.provider, s.provider.Storage(), r.Data, userID, r.Client)
+	if err != nil {
+		return tryErrorRedirect(ctx, s.provider, r.Data, oidc.DefaultToServerError(err, "unable to save auth request"), s.provider.Encoder(), s.provider.Logger())
+	}
+	silent, err := authorizeWithoutLogin(ctx, s.provider, s.provider.Storage(), req, r.Data, userID, r.Header)
 	if err != nil {
-		return TryErrorRedirect(ctx, r.Data, oidc.DefaultToServerError(err, "unable to save auth request"), s.provider.Encoder(), s.provider.Logger())
+		return tryErrorRedirect(ctx, s.provider, r.Data, err, s.provider.Encoder(), s.provider.Logger())
+	}
+	if silent {
+		return NewRedirect(s.AuthCallbackURL()(ctx, req.GetID())), nil
 	}
 	return NewRedirect(r.Client.LoginURL(req.GetID())), nil
 }
@@ -177,15 +229,103 @@ func (s *LegacyServer) DeviceAuthorization(ctx context.Context, r *ClientRequest
 	return NewResponse(response), nil
 }
 
+func (s *LegacyServer) PushedAuthorization(ctx context.Context, r *ClientRequest[oidc.AuthRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.PushedAuthorization")
+	defer span.End()
+
+	response, err := pushAuthRequest(ctx, s.provider, r.Data, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(response), nil
+}
+
+func (s *LegacyServer) BackchannelAuthentication(ctx context.Context, r *ClientRequest[oidc.BackchannelAuthenticationRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.BackchannelAuthentication")
+	defer span.End()
+
+	response, err := createBackchannelAuthentication(ctx, s.provider, r.Data, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(response), nil
+}
+
+func (s *LegacyServer) SessionTransfer(ctx context.Context, r *ClientRequest[oidc.SessionTransferRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.SessionTransfer")
+	defer span.End()
+
+	response, err := createSessionTransfer(ctx, s.provider, r.Data, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(response), nil
+}
+
+func (s *LegacyServer) ConsentReceipts(ctx context.Context, r *Request[oidc.ConsentReceiptsRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.ConsentReceipts")
+	defer span.End()
+
+	response, err := exportConsentReceipts(ctx, s.provider, r.Data)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(response), nil
+}
+
+func (s *LegacyServer) RegisterClient(ctx context.Context, r *Request[oidc.ClientRegistrationRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.RegisterClient")
+	defer span.End()
+
+	endpoint := s.endpoints.Registration.Absolute(IssuerFromContext(ctx))
+	response, err := registerClient(ctx, s.provider, registrationToken(r.Header), r.Data, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(response), nil
+}
+
+func (s *LegacyServer) ClientConfiguration(ctx context.Context, r *Request[oidc.ClientRegistrationRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.ClientConfiguration")
+	defer span.End()
+
+	endpoint := s.endpoints.Registration.Absolute(IssuerFromContext(ctx))
+	clientID, token := r.URL.Query().Get("client_id"), registrationToken(r.Header)
+	var (
+		response *oidc.ClientRegistrationResponse
+		err      error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		response, err = readClientRegistration(ctx, s.provider, clientID, token, endpoint)
+	case http.MethodPut:
+		response, err = updateClientRegistration(ctx, s.provider, clientID, token, r.Data, endpoint)
+	case http.MethodDelete:
+		err = deleteClientRegistration(ctx, s.provider, clientID, token)
+	default:
+		err = oidc.ErrInvalidRequest().WithDescription("method %s not supported by the client configuration endpoint", r.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(response), nil
+}
+
 func (s *LegacyServer) VerifyClient(ctx context.Context, r *Request[ClientCredentials]) (Client, error) {
 	ctx, span := tracer.Start(ctx, "LegacyServer.VerifyClient")
 	defer span.End()
 
-	if oidc.GrantType(r.Form.Get("grant_type")) == oidc.GrantTypeClientCredentials {
+	if client, ok, err := authorizeSPIFFEClientAssertion(ctx, s.provider, s.provider.Storage(), r.Data.ClientAssertionType, r.Data.ClientAssertion); ok {
+		return client, err
+	}
+	if oidc.GrantType(r.Form.Get(oidc.ParamGrantType)) == oidc.GrantTypeClientCredentials {
 		storage, ok := s.provider.Storage().(ClientCredentialsStorage)
 		if !ok {
 			return nil, oidc.ErrUnsupportedGrantType().WithDescription("client_credentials grant not supported")
 		}
+		if client, ok, err := authorizeTLSClientByID(ctx, s.provider.Storage(), r.Data.ClientID, mtlsConfig(s.provider)); ok {
+			return client, err
+		}
 		return storage.ClientCredentials(ctx, r.Data.ClientID, r.Data.ClientSecret)
 	}
 
@@ -206,6 +346,11 @@ func (s *LegacyServer) VerifyClient(ctx context.Context, r *Request[ClientCreden
 		return client, nil
 	case oidc.AuthMethodPrivateKeyJWT:
 		return nil, oidc.ErrInvalidClient().WithDescription("private_key_jwt not allowed for this client")
+	case oidc.AuthMethodTLSClientAuth, oidc.AuthMethodSelfSignedTLSClientAuth:
+		if err = AuthorizeTLSClient(ctx, client, mtlsConfig(s.provider)); err != nil {
+			return nil, err
+		}
+		return client, nil
 	case oidc.AuthMethodPost:
 		if !s.provider.AuthMethodPostSupported() {
 			return nil, oidc.ErrInvalidClient().WithDescription("auth_method post not supported")
@@ -228,6 +373,9 @@ func (s *LegacyServer) CodeExchange(ctx context.Context, r *ClientRequest[oidc.A
 	if err != nil {
 		return nil, err
 	}
+	if err = checkAuthCodeExpiry(ctx, s.provider, r.Client, r.Data.Code); err != nil {
+		return nil, err
+	}
 	if r.Client.AuthMethod() == oidc.AuthMethodNone || r.Data.CodeVerifier != "" {
 		if err = AuthorizeCodeChallenge(r.Data.CodeVerifier, authReq.GetCodeChallenge()); err != nil {
 			return nil, err
@@ -236,6 +384,15 @@ func (s *LegacyServer) CodeExchange(ctx context.Context, r *ClientRequest[oidc.A
 	if r.Data.RedirectURI != authReq.GetRedirectURI() {
 		return nil, oidc.ErrInvalidGrant().WithDescription("redirect_uri does not correspond")
 	}
+	if err = ValidateTokenAuthorizationDetails(s.provider, r.Data.AuthorizationDetails, authReq); err != nil {
+		return nil, err
+	}
+	if err = verifyDeviceBinding(ctx, s.provider.Storage(), replayCache(s.provider), r.Header, authReq, oidc.GrantTypeCode, ""); err != nil {
+		return nil, err
+	}
+	if err = RedeemAuthCode(ctx, s.provider.Storage(), r.Data.Code); err != nil {
+		return nil, err
+	}
 	resp, err := CreateTokenResponse(ctx, authReq, r.Client, s.provider, true, r.Data.Code, "")
 	if err != nil {
 		return nil, err
@@ -257,10 +414,19 @@ func (s *LegacyServer) RefreshToken(ctx context.Context, r *ClientRequest[oidc.R
 	if r.Client.GetID() != request.GetClientID() {
 		return nil, oidc.ErrInvalidGrant()
 	}
-	if err = ValidateRefreshTokenScopes(r.Data.Scopes, request); err != nil {
+	if err = validateRefreshTokenDPoP(ctx, r.Client, request); err != nil {
+		return nil, err
+	}
+	if err = validateRefreshTokenScopes(s.provider, r.Data.Scopes, request); err != nil {
+		return nil, err
+	}
+	if err = ValidateTokenAuthorizationDetails(s.provider, r.Data.AuthorizationDetails, request); err != nil {
+		return nil, err
+	}
+	if err = verifyDeviceBinding(ctx, s.provider.Storage(), replayCache(s.provider), r.Header, request, oidc.GrantTypeRefreshToken, r.Data.RefreshToken); err != nil {
 		return nil, err
 	}
-	resp, err := CreateTokenResponse(ctx, request, r.Client, s.provider, true, "", r.Data.RefreshToken)
+	resp, err := CreateRefreshTokenResponse(ctx, request, r.Client, s.provider, r.Data.RefreshToken)
 	if err != nil {
 		return nil, err
 	}
@@ -321,6 +487,9 @@ func (s *LegacyServer) ClientCredentialsExchange(ctx context.Context, r *ClientR
 	if err != nil {
 		return nil, err
 	}
+	if err = validateClientCredentialsAuthorizationDetails(s.provider, r.Data.AuthorizationDetails, tokenRequest); err != nil {
+		return nil, err
+	}
 	resp, err := CreateClientCredentialsTokenResponse(ctx, tokenRequest, s.provider, r.Client)
 	if err != nil {
 		return nil, err
@@ -335,12 +504,16 @@ func (s *LegacyServer) DeviceToken(ctx context.Context, r *ClientRequest[oidc.De
 	if !s.provider.GrantTypeDeviceCodeSupported() {
 		return nil, unimplementedGrantError(oidc.GrantTypeDeviceCode)
 	}
+	// Server-Sent Events are not supported by the Server interface,
+	// such requests are answered by long polling.
+	wait, _ := deviceAuthorizationWait(r.Header, s.provider)
+
 	// use a limited context timeout shorter as the default
-	// poll interval of 5 seconds.
-	ctx, cancel := context.WithTimeout(ctx, 4*time.Second)
+	// poll interval of 5 seconds, extended by the wait.
+	ctx, cancel := context.WithTimeout(ctx, 4*time.Second+wait)
 	defer cancel()
 
-	tokenRequest, err := CheckDeviceAuthorizationState(ctx, r.Client.GetID(), r.Data.DeviceCode, s.provider)
+	tokenRequest, err := WaitDeviceAuthorizationState(ctx, r.Client.GetID(), r.Data.DeviceCode, wait, s.provider)
 	if err != nil {
 		return nil, err
 	}
@@ -351,6 +524,37 @@ func (s *LegacyServer) DeviceToken(ctx context.Context, r *ClientRequest[oidc.De
 	return NewResponse(resp), nil
 }
 
+func (s *LegacyServer) BackchannelToken(ctx context.Context, r *ClientRequest[oidc.BackchannelTokenRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.BackchannelToken")
+	defer span.End()
+
+	if backchannelAuthentication(s.provider) == nil {
+		return nil, unimplementedGrantError(oidc.GrantTypeCIBA)
+	}
+	if r.Client.AuthMethod() == oidc.AuthMethodNone {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("backchannel authentication requires a confidential client")
+	}
+	resp, err := createBackchannelTokenResponse(ctx, s.provider, r.Client, r.Data.AuthReqID)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(resp), nil
+}
+
+func (s *LegacyServer) SessionTransferToken(ctx context.Context, r *ClientRequest[oidc.SessionTransferTokenRequest]) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "LegacyServer.SessionTransferToken")
+	defer span.End()
+
+	if sessionTransfer(s.provider) == nil {
+		return nil, unimplementedGrantError(oidc.GrantTypeSessionTransfer)
+	}
+	resp, err := createSessionTransferTokenResponse(ctx, s.provider, r.Client, r.Data)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(resp), nil
+}
+
 func (s *LegacyServer) authenticateResourceClient(ctx context.Context, cc *ClientCredentials) (string, error) {
 	ctx, span := tracer.Start(ctx, "LegacyServer.authenticateResourceClient")
 	defer span.End()
@@ -375,16 +579,10 @@ func (s *LegacyServer) Introspect(ctx context.Context, r *Request[IntrospectionR
 	if err != nil {
 		return nil, err
 	}
-	response := new(oidc.IntrospectionResponse)
-	tokenID, subject, ok := getTokenIDAndSubject(ctx, s.provider, r.Data.Token)
+	response, ok := introspectToken(ctx, s.provider, getIntrospectionFunc(s.provider), r.Data.Token, clientID)
 	if !ok {
-		return NewResponse(response), nil
-	}
-	err = s.provider.Storage().SetIntrospectionFromToken(ctx, response, tokenID, subject, clientID)
-	if err != nil {
-		return NewResponse(response), nil
+		return NewResponse(new(oidc.IntrospectionResponse)), nil
 	}
-	response.Active = true
 	return NewResponse(response), nil
 }
 
@@ -392,15 +590,18 @@ func (s *LegacyServer) UserInfo(ctx context.Context, r *Request[oidc.UserInfoReq
 	ctx, span := tracer.Start(ctx, "LegacyServer.UserInfo")
 	defer span.End()
 
-	tokenID, subject, ok := getTokenIDAndSubject(ctx, s.provider, r.Data.AccessToken)
+	tokenID, subject, clientID, ok := getTokenIDAndSubject(ctx, s.provider, r.Data.AccessToken)
 	if !ok {
 		return nil, NewStatusError(oidc.ErrAccessDenied().WithDescription("access token invalid"), http.StatusUnauthorized)
 	}
-	info := new(oidc.UserInfo)
-	err := s.provider.Storage().SetUserinfoFromToken(ctx, info, tokenID, subject, r.Header.Get("origin"))
+	ctx = oidc.ContextWithSubject(ctx, subject)
+	info, err := getUserinfoFunc(s.provider)(ctx, tokenID, subject, r.Header.Get("origin"))
 	if err != nil {
 		return nil, NewStatusError(err, http.StatusForbidden)
 	}
+	if err = setUserinfoSubject(ctx, s.provider.Storage(), info, tokenID, clientID); err != nil {
+		return nil, NewStatusError(err, http.StatusForbidden)
+	}
 	return NewResponse(info), nil
 }
 
@@ -424,10 +625,13 @@ func (s *LegacyServer) Revocation(ctx context.Context, r *ClientRequest[oidc.Rev
 		}
 	}
 	if doDecrypt {
-		tokenID, userID, ok := getTokenIDAndSubjectForRevocation(ctx, s.provider, r.Data.Token)
+		tokenID, userID, tokenClientID, ok := getTokenIDAndSubject(ctx, s.provider, r.Data.Token)
 		if ok {
 			r.Data.Token = tokenID
 			subject = userID
+			if err := validateRevocationClient(ctx, s.provider.Storage(), tokenID, userID, tokenClientID, r.Client.GetID()); err != nil {
+				return nil, RevocationError(err)
+			}
 		}
 	}
 	if err := s.provider.Storage().RevokeToken(ctx, r.Data.Token, subject, r.Client.GetID()); err != nil {
@@ -453,5 +657,6 @@ func (s *LegacyServer) EndSession(ctx context.Context, r *Request[oidc.EndSessio
 	if err != nil {
 		return nil, err
 	}
+	backChannelLogout(ctx, s.provider, session)
 	return NewRedirect(redirect), nil
 }
@@ -0,0 +1,107 @@
+package op
+
+import (
+	"context"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ServiceToken describes an access token issued to a client itself,
+// e.g. by the client_credentials or jwt-bearer grant, instead of a user.
+type ServiceToken struct {
+	// ClientID is the client the token was issued to.
+	ClientID string
+	// Subject is the service account of the client, if any.
+	Subject    string
+	Scopes     []string
+	Audience   []string
+	Expiration time.Time
+	IssuedAt   time.Time
+	// Metadata of the service account, returned as additional claims
+	// of the introspection response. Registered claims, such as
+	// client_id or sub, cannot be overwritten.
+	Metadata map[string]any
+}
+
+// ServiceTokenStorage is an optional interface of the [Storage],
+// which lets the introspection endpoint return a uniform response
+// for service tokens, with the client_id, scope, aud and the subject
+// set by [WithServiceTokenSubject].
+// ServiceToken returns nil and no error for tokens issued to a user,
+// which are then introspected as before. clientID is the client
+// calling the introspection endpoint, the implementation returns an
+// error if it is not part of the audience of the token.
+type ServiceTokenStorage interface {
+	ServiceToken(ctx context.Context, tokenID, clientID string) (*ServiceToken, error)
+}
+
+// ServiceTokenSubject returns the sub claim of the
+// introspection response of a service token.
+type ServiceTokenSubject func(ctx context.Context, token *ServiceToken) string
+
+// ServiceTokenSubjectClientID uses the client_id as sub,
+// as recommended by RFC 9068, section 2.2. It is the default.
+func ServiceTokenSubjectClientID(_ context.Context, token *ServiceToken) string {
+	return token.ClientID
+}
+
+// ServiceTokenSubjectAccount uses the service account as sub,
+// falling back to the client_id.
+func ServiceTokenSubjectAccount(_ context.Context, token *ServiceToken) string {
+	if token.Subject != "" {
+		return token.Subject
+	}
+	return token.ClientID
+}
+
+type serviceTokenSubjectGetter interface {
+	ServiceTokenSubject() ServiceTokenSubject
+}
+
+func serviceTokenSubject(v any) ServiceTokenSubject {
+	if g, ok := v.(serviceTokenSubjectGetter); ok {
+		if subject := g.ServiceTokenSubject(); subject != nil {
+			return subject
+		}
+	}
+	return ServiceTokenSubjectClientID
+}
+
+// introspectServiceToken returns the introspection response of a service token,
+// or false if the Storage does not implement [ServiceTokenStorage] or the
+// token was issued to a user. An inactive token is returned as nil response.
+func introspectServiceToken(ctx context.Context, introspector Introspector, tokenID, clientID string) (*oidc.IntrospectionResponse, bool) {
+	storage, ok := introspector.Storage().(ServiceTokenStorage)
+	if !ok {
+		return nil, false
+	}
+	token, err := storage.ServiceToken(ctx, tokenID, clientID)
+	if err != nil {
+		return nil, true
+	}
+	if token == nil {
+		return nil, false
+	}
+	if !token.Expiration.IsZero() && time.Now().After(token.Expiration) {
+		return nil, true
+	}
+	response := &oidc.IntrospectionResponse{
+		Active:     true,
+		Scope:      token.Scopes,
+		ClientID:   token.ClientID,
+		TokenType:  oidc.BearerToken,
+		Expiration: oidc.FromTime(token.Expiration),
+		IssuedAt:   oidc.FromTime(token.IssuedAt),
+		Subject:    serviceTokenSubject(introspector)(ctx, token),
+		Audience:   token.Audience,
+		Issuer:     IssuerFromContext(ctx),
+	}
+	if len(token.Metadata) > 0 {
+		response.Claims = make(map[string]any, len(token.Metadata))
+		for k, v := range token.Metadata {
+			response.Claims[k] = v
+		}
+	}
+	return response, true
+}
@@ -0,0 +1,150 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type testServiceTokenStorage struct {
+	Storage
+	expiration time.Time
+}
+
+func (s testServiceTokenStorage) ServiceToken(_ context.Context, tokenID, clientID string) (*ServiceToken, error) {
+	switch {
+	case tokenID != "serviceTokenID":
+		return nil, nil
+	case clientID != "gateway":
+		return nil, errors.New("not in audience")
+	}
+	return &ServiceToken{
+		ClientID:   "service",
+		Subject:    "account1",
+		Scopes:     []string{"read", "write"},
+		Audience:   []string{"gateway"},
+		Expiration: s.expiration,
+		Metadata:   map[string]any{"tenant": "acme", "client_id": "override"},
+	}, nil
+}
+
+type testServiceTokenProvider struct {
+	testClaimsProvider
+	storage Storage
+	subject ServiceTokenSubject
+}
+
+func (p testServiceTokenProvider) Storage() Storage                         { return p.storage }
+func (p testServiceTokenProvider) ServiceTokenSubject() ServiceTokenSubject { return p.subject }
+
+func Test_introspectToken_serviceToken(t *testing.T) {
+	crypto := NewAESCrypto([32]byte{1})
+	serviceToken, err := CreateBearerToken("serviceTokenID", "account1", crypto)
+	require.NoError(t, err)
+	userToken, err := CreateBearerToken("tokenID", "sub1", crypto)
+	require.NoError(t, err)
+	fromToken := newIntrospectionFunc[*testIntrospectionClaims](testClaimsStorage{})
+	expiration := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		expiration time.Time
+		subject    ServiceTokenSubject
+		token      string
+		clientID   string
+		want       any
+		wantActive bool
+	}{
+		{
+			name:       "default subject",
+			expiration: expiration,
+			token:      serviceToken,
+			clientID:   "gateway",
+			want: &oidc.IntrospectionResponse{
+				Active:     true,
+				Scope:      oidc.SpaceDelimitedArray{"read", "write"},
+				ClientID:   "service",
+				TokenType:  oidc.BearerToken,
+				Expiration: oidc.FromTime(expiration),
+				Subject:    "service",
+				Audience:   oidc.Audience{"gateway"},
+				Issuer:     "https://issuer.example.com",
+				Claims:     map[string]any{"tenant": "acme", "client_id": "override"},
+			},
+			wantActive: true,
+		},
+		{
+			name:       "account subject",
+			expiration: expiration,
+			subject:    ServiceTokenSubjectAccount,
+			token:      serviceToken,
+			clientID:   "gateway",
+			want: &oidc.IntrospectionResponse{
+				Active:     true,
+				Scope:      oidc.SpaceDelimitedArray{"read", "write"},
+				ClientID:   "service",
+				TokenType:  oidc.BearerToken,
+				Expiration: oidc.FromTime(expiration),
+				Subject:    "account1",
+				Audience:   oidc.Audience{"gateway"},
+				Issuer:     "https://issuer.example.com",
+				Claims:     map[string]any{"tenant": "acme", "client_id": "override"},
+			},
+			wantActive: true,
+		},
+		{
+			name:       "not in audience",
+			expiration: expiration,
+			token:      serviceToken,
+			clientID:   "other",
+		},
+		{
+			name:       "expired",
+			expiration: time.Now().Add(-time.Minute),
+			token:      serviceToken,
+			clientID:   "gateway",
+		},
+		{
+			name:       "user token",
+			token:      userToken,
+			clientID:   "gateway",
+			want:       &testIntrospectionClaims{Active: true, Subject: "sub1", Department: "engineering"},
+			wantActive: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := testServiceTokenProvider{
+				testClaimsProvider: testClaimsProvider{crypto: crypto},
+				storage:            testServiceTokenStorage{expiration: tt.expiration},
+				subject:            tt.subject,
+			}
+			ctx := ContextWithIssuer(context.Background(), "https://issuer.example.com")
+			got, ok := introspectToken(ctx, provider, fromToken, tt.token, tt.clientID)
+			require.Equal(t, tt.wantActive, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIntrospectionResponse_serviceTokenMetadata(t *testing.T) {
+	provider := testServiceTokenProvider{
+		testClaimsProvider: testClaimsProvider{crypto: NewAESCrypto([32]byte{1})},
+		storage:            testServiceTokenStorage{},
+	}
+	resp, ok := introspectServiceToken(context.Background(), provider, "serviceTokenID", "gateway")
+	require.True(t, ok)
+	require.NotNil(t, resp)
+	data, err := resp.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"active":true,"scope":"read write","client_id":"service","token_type":"Bearer","sub":"service","aud":["gateway"],"tenant":"acme"}`, string(data))
+}
@@ -33,7 +33,7 @@ func EndSession(w http.ResponseWriter, r *http.Request, ender SessionEnder) {
 
 	req, err := ParseEndSessionRequest(r, ender.Decoder())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		WriteText(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	session, err := ValidateEndSessionRequest(r.Context(), req, ender)
@@ -51,6 +51,7 @@ func EndSession(w http.ResponseWriter, r *http.Request, ender SessionEnder) {
 		RequestError(w, r, oidc.DefaultToServerError(err, "error terminating session"), ender.Logger())
 		return
 	}
+	backChannelLogout(r.Context(), ender, session)
 	http.Redirect(w, r, redirect, http.StatusFound)
 }
 
@@ -79,12 +80,15 @@ func ValidateEndSessionRequest(ctx context.Context, req *oidc.EndSessionRequest,
 		if err != nil && !errors.As(err, &IDTokenHintExpiredError{}) {
 			return nil, oidc.ErrInvalidRequest().WithDescription("id_token_hint invalid").WithParent(err)
 		}
-		session.UserID = claims.GetSubject()
 		session.IDTokenHintClaims = claims
 		if req.ClientID != "" && req.ClientID != claims.GetAuthorizedParty() {
 			return nil, oidc.ErrInvalidRequest().WithDescription("client_id does not match azp of id_token_hint")
 		}
 		req.ClientID = claims.GetAuthorizedParty()
+		session.UserID, err = internalSubject(ctx, ender.Storage(), claims.GetSubject(), req.ClientID)
+		if err != nil {
+			return nil, oidc.ErrInvalidRequest().WithDescription("id_token_hint invalid").WithParent(err)
+		}
 	}
 	if req.ClientID != "" {
 		client, err := ender.Storage().GetClientByClientID(ctx, req.ClientID)
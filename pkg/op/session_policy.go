@@ -0,0 +1,266 @@
+package op
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// Session is the authentication of an end-user, kept by the [SessionStorage]
+// for single sign-on, e.g. referenced by a session cookie.
+type Session struct {
+	AuthenticationResult
+	// RememberMe was chosen by the end-user at the login,
+	// extending the lifetime of the session.
+	RememberMe bool
+	// Name of the end-user, displayed by account choosers.
+	Name string
+}
+
+// SessionPolicy defines how long sessions are reused for single sign-on
+// and when the end-user must authenticate again. See [WithSessionPolicy].
+type SessionPolicy struct {
+	// AuthenticationLifetime is how long after the authentication
+	// a session is reused, if the end-user did not choose remember-me.
+	AuthenticationLifetime time.Duration
+	// RememberMeLifetime is how long after the authentication
+	// a session is reused, if the end-user chose remember-me.
+	// Without, the AuthenticationLifetime applies.
+	RememberMeLifetime time.Duration
+	// ReauthenticationAge requires a new authentication, if the session is older,
+	// like the max_age of an auth request, regardless of the lifetime. Zero disables it.
+	ReauthenticationAge time.Duration
+}
+
+// DefaultSessionPolicy is used when no other policy
+// was set with [WithSessionPolicy].
+var DefaultSessionPolicy = SessionPolicy{
+	AuthenticationLifetime: 12 * time.Hour,
+	RememberMeLifetime:     30 * 24 * time.Hour,
+}
+
+// SessionStorage is an optional interface of the [Storage], which lets the Provider
+// complete auth requests from the session of the end-user according to the [SessionPolicy],
+// without redirecting to the Login UI. The storage must implement [AuthenticationStorage] as well.
+//
+// Auth requests with prompt=none are rejected with login_required, if the session
+// can't be reused, unless the storage implements [PromptNoneStorage].
+type SessionStorage interface {
+	// SessionFromRequest returns the session of the end-user, typically
+	// from the session cookie in the header of the auth request,
+	// or nil if there is none.
+	SessionFromRequest(ctx context.Context, header http.Header) (*Session, error)
+}
+
+// MultiSessionStorage is an optional interface of the [SessionStorage] for browsers
+// with multiple active sessions of different end-users, like account switchers.
+//
+// Auth requests bound to an end-user, e.g. by an id_token_hint, are completed
+// from the session of the end-user. Auth requests with prompt=select_account are
+// completed from the session chosen by the [SessionSelector], if any, or the Login UI
+// lets the end-user choose from the [ActiveSessions].
+// The sessions must have distinct SessionIDs, which are issued as sid claim.
+type MultiSessionStorage interface {
+	SessionStorage
+	// SessionsFromRequest returns all sessions of the browser,
+	// typically from the session cookies in the header of the auth request.
+	SessionsFromRequest(ctx context.Context, header http.Header) ([]*Session, error)
+}
+
+// SessionSelector chooses the session of an auth request with prompt=select_account
+// from the active sessions of the browser, see [WithSessionSelector].
+// It returns nil to let the end-user choose in the Login UI.
+type SessionSelector func(ctx context.Context, authReq *oidc.AuthRequest, sessions []*Session) (*Session, error)
+
+// AuthRequestPrompt is an optional interface of the [AuthRequest], which lets
+// [ActiveSessions] honor the prompt and max_age of the request.
+type AuthRequestPrompt interface {
+	GetPrompt() []string
+	GetMaxAge() *uint
+}
+
+// Expiry returns when the session can no longer be reused.
+func (p *SessionPolicy) Expiry(session *Session) time.Time {
+	lifetime := p.AuthenticationLifetime
+	if session.RememberMe && p.RememberMeLifetime > 0 {
+		lifetime = p.RememberMeLifetime
+	}
+	return session.Time.Add(lifetime)
+}
+
+// CookieMaxAge returns the Max-Age of the session cookie in seconds: until the expiry
+// of the session, if the end-user chose remember-me, or 0 for a cookie
+// which ends with the browser session.
+func (p *SessionPolicy) CookieMaxAge(session *Session) int {
+	if !session.RememberMe {
+		return 0
+	}
+	return max(int(time.Until(p.Expiry(session)).Seconds()), 0)
+}
+
+// Reusable reports if the session may complete the auth request without a new authentication:
+// it is not expired and satisfies the max_age and re-authentication age,
+// the auth request is not bound to another end-user (subject, e.g. by an id_token_hint)
+// and none of the login, consent or select_account prompts require the Login UI.
+func (p *SessionPolicy) Reusable(session *Session, authReq *oidc.AuthRequest, subject string) bool {
+	if slices.Contains(authReq.Prompt, oidc.PromptSelectAccount) {
+		return false
+	}
+	return p.Selectable(session, authReq, subject)
+}
+
+// Selectable reports if the end-user may continue with the session in an account chooser
+// of an auth request with prompt=select_account. It is like Reusable, without the select_account prompt.
+func (p *SessionPolicy) Selectable(session *Session, authReq *oidc.AuthRequest, subject string) bool {
+	if session == nil || session.Subject == "" || !time.Now().Before(p.Expiry(session)) {
+		return false
+	}
+	for _, prompt := range authReq.Prompt {
+		if prompt == oidc.PromptLogin || prompt == oidc.PromptConsent {
+			return false
+		}
+	}
+	if !session.SatisfiesMaxAge(authReq.MaxAge) {
+		return false
+	}
+	if p.ReauthenticationAge > 0 && time.Since(session.Time) > p.ReauthenticationAge {
+		return false
+	}
+	return subject == "" || subject == session.Subject
+}
+
+type sessionPolicyGetter interface {
+	SessionPolicy() *SessionPolicy
+}
+
+func sessionPolicy(v any) *SessionPolicy {
+	if p, ok := v.(sessionPolicyGetter); ok {
+		if policy := p.SessionPolicy(); policy != nil {
+			return policy
+		}
+	}
+	return &DefaultSessionPolicy
+}
+
+type sessionSelectorGetter interface {
+	SessionSelector() SessionSelector
+}
+
+func sessionSelector(v any) SessionSelector {
+	if s, ok := v.(sessionSelectorGetter); ok {
+		return s.SessionSelector()
+	}
+	return nil
+}
+
+// ActiveSessions returns the sessions of the browser, which may complete the auth request
+// in an account chooser, see [SessionPolicy.Selectable]. The prompt and max_age of the
+// request are honored if it implements [AuthRequestPrompt]. Without [SessionStorage],
+// there are no sessions.
+func ActiveSessions(ctx context.Context, o OpenIDProvider, authReq AuthRequest, header http.Header) ([]*Session, error) {
+	ctx, span := tracer.Start(ctx, "ActiveSessions")
+	defer span.End()
+
+	constraints := new(oidc.AuthRequest)
+	if prompt, ok := authReq.(AuthRequestPrompt); ok {
+		constraints.Prompt = prompt.GetPrompt()
+		constraints.MaxAge = prompt.GetMaxAge()
+	}
+	sessions, ok := o.Storage().(SessionStorage)
+	if !ok {
+		return nil, nil
+	}
+	return activeSessions(ctx, sessions, sessionPolicy(o), constraints, authReq.GetSubject(), header)
+}
+
+func activeSessions(ctx context.Context, storage SessionStorage, policy *SessionPolicy, authReq *oidc.AuthRequest, subject string, header http.Header) ([]*Session, error) {
+	var sessions []*Session
+	if multi, ok := storage.(MultiSessionStorage); ok {
+		var err error
+		if sessions, err = multi.SessionsFromRequest(ctx, header); err != nil {
+			return nil, err
+		}
+	} else {
+		session, err := storage.SessionFromRequest(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		sessions = []*Session{session}
+	}
+	return slices.DeleteFunc(sessions, func(session *Session) bool {
+		return !policy.Selectable(session, authReq, subject)
+	}), nil
+}
+
+// sessionOfRequest returns the session which may complete the auth request, if any:
+// the session chosen by the selector for prompt=select_account, the session of the
+// subject of a [MultiSessionStorage] or the session of the request.
+func sessionOfRequest(ctx context.Context, sessions SessionStorage, policy *SessionPolicy, selector SessionSelector, authReq *oidc.AuthRequest, subject string, header http.Header) (*Session, error) {
+	if slices.Contains(authReq.Prompt, oidc.PromptSelectAccount) {
+		if selector == nil {
+			return nil, nil
+		}
+		active, err := activeSessions(ctx, sessions, policy, authReq, subject, header)
+		if err != nil || len(active) == 0 {
+			return nil, err
+		}
+		session, err := selector(ctx, authReq, active)
+		if err != nil || !slices.Contains(active, session) {
+			return nil, err
+		}
+		return session, nil
+	}
+	if multi, ok := sessions.(MultiSessionStorage); ok && subject != "" {
+		all, err := multi.SessionsFromRequest(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range all {
+			if policy.Reusable(session, authReq, subject) {
+				return session, nil
+			}
+		}
+		return nil, nil
+	}
+	session, err := sessions.SessionFromRequest(ctx, header)
+	if err != nil || !policy.Reusable(session, authReq, subject) {
+		return nil, err
+	}
+	return session, nil
+}
+
+// authorizeFromSession completes the auth request with the session of the end-user,
+// if the storage implements [SessionStorage] and a session is reusable or selected.
+// It returns false if the request must be redirected to the Login UI instead.
+// Auth requests with prompt=none fail with login_required and are deleted, if not completed.
+func authorizeFromSession(ctx context.Context, storage Storage, policy *SessionPolicy, selector SessionSelector, req AuthRequest, authReq *oidc.AuthRequest, subject string, header http.Header) (bool, error) {
+	sessions, ok := storage.(SessionStorage)
+	if !ok {
+		return false, nil
+	}
+	if _, ok := storage.(AuthenticationStorage); !ok {
+		return false, nil
+	}
+	ctx, span := tracer.Start(ctx, "authorizeFromSession")
+	defer span.End()
+
+	session, err := sessionOfRequest(ctx, sessions, policy, selector, authReq, subject, header)
+	if err == nil && session != nil {
+		err = CompleteAuthentication(ctx, storage, req.GetID(), session.AuthenticationResult)
+		if err == nil {
+			return true, nil
+		}
+	}
+	if err == nil && !slices.Contains(authReq.Prompt, oidc.PromptNone) {
+		return false, nil
+	}
+	if err == nil {
+		err = oidc.ErrLoginRequired().WithDescription("The end-user must authenticate again.")
+	}
+	// the auth request can't be completed anymore
+	_ = storage.DeleteAuthRequest(ctx, req.GetID())
+	return true, oidc.DefaultToServerError(err, "unable to complete the auth request from the session")
+}
@@ -0,0 +1,264 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/muhlemmer/gu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestSessionPolicy_Reusable(t *testing.T) {
+	policy := &SessionPolicy{
+		AuthenticationLifetime: time.Hour,
+		RememberMeLifetime:     24 * time.Hour,
+		ReauthenticationAge:    12 * time.Hour,
+	}
+	session := func(age time.Duration, rememberMe bool) *Session {
+		return &Session{
+			AuthenticationResult: AuthenticationResult{Subject: "id1", Time: time.Now().Add(-age)},
+			RememberMe:           rememberMe,
+		}
+	}
+	tests := []struct {
+		name    string
+		session *Session
+		authReq *oidc.AuthRequest
+		subject string
+		want    bool
+	}{
+		{"no session", nil, &oidc.AuthRequest{}, "", false},
+		{"valid", session(time.Minute, false), &oidc.AuthRequest{}, "", true},
+		{"expired", session(2*time.Hour, false), &oidc.AuthRequest{}, "", false},
+		{"remember me", session(2*time.Hour, true), &oidc.AuthRequest{}, "", true},
+		{"reauthentication age", session(13*time.Hour, true), &oidc.AuthRequest{}, "", false},
+		{"max age", session(10*time.Minute, false), &oidc.AuthRequest{MaxAge: gu.Ptr[uint](300)}, "", false},
+		{"within max age", session(time.Minute, false), &oidc.AuthRequest{MaxAge: gu.Ptr[uint](300)}, "", true},
+		{"prompt login", session(time.Minute, false), &oidc.AuthRequest{Prompt: []string{oidc.PromptLogin}}, "", false},
+		{"prompt consent", session(time.Minute, false), &oidc.AuthRequest{Prompt: []string{oidc.PromptConsent}}, "", false},
+		{"prompt none", session(time.Minute, false), &oidc.AuthRequest{Prompt: []string{oidc.PromptNone}}, "", true},
+		{"same subject", session(time.Minute, false), &oidc.AuthRequest{}, "id1", true},
+		{"other subject", session(time.Minute, false), &oidc.AuthRequest{}, "id2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.Reusable(tt.session, tt.authReq, tt.subject))
+		})
+	}
+}
+
+func TestSessionPolicy_CookieMaxAge(t *testing.T) {
+	policy := &DefaultSessionPolicy
+	session := &Session{AuthenticationResult: AuthenticationResult{Time: time.Now()}}
+	assert.Equal(t, 0, policy.CookieMaxAge(session))
+	assert.Equal(t, session.Time.Add(12*time.Hour), policy.Expiry(session))
+
+	session.RememberMe = true
+	assert.InDelta(t, (30 * 24 * time.Hour).Seconds(), policy.CookieMaxAge(session), 2)
+	session.Time = time.Now().Add(-31 * 24 * time.Hour)
+	assert.Equal(t, 0, policy.CookieMaxAge(session))
+}
+
+type sessionStorage struct {
+	Storage
+	session   *Session
+	err       error
+	completed *AuthenticationResult
+	deleted   []string
+}
+
+func (s *sessionStorage) SessionFromRequest(context.Context, http.Header) (*Session, error) {
+	return s.session, s.err
+}
+
+func (s *sessionStorage) CompleteAuthentication(_ context.Context, _ string, result *AuthenticationResult) error {
+	s.completed = result
+	return nil
+}
+
+func (s *sessionStorage) DeleteAuthRequest(_ context.Context, id string) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func Test_authorizeFromSession(t *testing.T) {
+	valid := &Session{AuthenticationResult: AuthenticationResult{
+		Subject: "id1",
+		Methods: []string{oidc.AMRPassword},
+		Time:    time.Now().Add(-time.Minute),
+	}}
+	tests := []struct {
+		name          string
+		storage       *sessionStorage
+		prompts       []string
+		wantSilent    bool
+		wantCompleted bool
+		wantErr       error
+	}{
+		{
+			name:    "no session",
+			storage: &sessionStorage{},
+		},
+		{
+			name:          "session",
+			storage:       &sessionStorage{session: valid},
+			wantSilent:    true,
+			wantCompleted: true,
+		},
+		{
+			name:          "prompt none",
+			storage:       &sessionStorage{session: valid},
+			prompts:       []string{oidc.PromptNone},
+			wantSilent:    true,
+			wantCompleted: true,
+		},
+		{
+			name:    "prompt login",
+			storage: &sessionStorage{session: valid},
+			prompts: []string{oidc.PromptLogin},
+		},
+		{
+			name:       "prompt none without session",
+			storage:    &sessionStorage{},
+			prompts:    []string{oidc.PromptNone},
+			wantSilent: true,
+			wantErr:    oidc.ErrLoginRequired(),
+		},
+		{
+			name:       "storage error",
+			storage:    &sessionStorage{err: errors.New("db down")},
+			wantSilent: true,
+			wantErr:    oidc.ErrServerError(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authReq := &oidc.AuthRequest{Prompt: tt.prompts}
+			silent, err := authorizeFromSession(context.Background(), tt.storage, &DefaultSessionPolicy, nil, promptNoneAuthRequest{}, authReq, "", nil)
+			assert.Equal(t, tt.wantSilent, silent)
+			if tt.wantCompleted {
+				assert.Equal(t, "id1", tt.storage.completed.Subject)
+				assert.Equal(t, []string{oidc.AMRPassword}, tt.storage.completed.Methods)
+			} else {
+				assert.Nil(t, tt.storage.completed)
+			}
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				assert.Empty(t, tt.storage.deleted)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, []string{"id1"}, tt.storage.deleted)
+		})
+	}
+}
+
+func Test_authorizeFromSession_notImplemented(t *testing.T) {
+	silent, err := authorizeFromSession(context.Background(), struct{ Storage }{}, &DefaultSessionPolicy, nil, promptNoneAuthRequest{}, &oidc.AuthRequest{Prompt: []string{oidc.PromptNone}}, "", nil)
+	assert.False(t, silent)
+	assert.NoError(t, err)
+}
+
+type multiSessionStorage struct {
+	sessionStorage
+	sessions []*Session
+}
+
+func (s *multiSessionStorage) SessionsFromRequest(context.Context, http.Header) ([]*Session, error) {
+	return s.sessions, s.err
+}
+
+func Test_authorizeFromSession_multiSession(t *testing.T) {
+	session := func(subject string, age time.Duration) *Session {
+		return &Session{AuthenticationResult: AuthenticationResult{
+			Subject:   subject,
+			Time:      time.Now().Add(-age),
+			SessionID: "sid-" + subject,
+		}}
+	}
+	sessions := []*Session{session("id1", time.Minute), session("id2", time.Minute), session("id3", 24*time.Hour)}
+	selectSecond := func(_ context.Context, _ *oidc.AuthRequest, sessions []*Session) (*Session, error) {
+		return sessions[1], nil
+	}
+	tests := []struct {
+		name        string
+		prompts     []string
+		subject     string
+		selector    SessionSelector
+		wantSession string
+	}{
+		{name: "current session", wantSession: "sid-id1"},
+		{name: "session of subject", subject: "id2", wantSession: "sid-id2"},
+		{name: "expired session of subject", subject: "id3"},
+		{name: "select account without selector", prompts: []string{oidc.PromptSelectAccount}},
+		{name: "select account", prompts: []string{oidc.PromptSelectAccount}, selector: selectSecond, wantSession: "sid-id2"},
+		{
+			name:    "selector without choice",
+			prompts: []string{oidc.PromptSelectAccount},
+			selector: func(context.Context, *oidc.AuthRequest, []*Session) (*Session, error) {
+				return nil, nil
+			},
+		},
+		{
+			name:    "selector with other session",
+			prompts: []string{oidc.PromptSelectAccount},
+			selector: func(context.Context, *oidc.AuthRequest, []*Session) (*Session, error) {
+				return sessions[2], nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &multiSessionStorage{sessionStorage: sessionStorage{session: sessions[0]}, sessions: sessions}
+			authReq := &oidc.AuthRequest{Prompt: tt.prompts}
+			silent, err := authorizeFromSession(context.Background(), storage, &DefaultSessionPolicy, tt.selector, promptNoneAuthRequest{}, authReq, tt.subject, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSession != "", silent)
+			if tt.wantSession == "" {
+				assert.Nil(t, storage.completed)
+				return
+			}
+			assert.Equal(t, tt.wantSession, storage.completed.SessionID)
+		})
+	}
+}
+
+type selectAccountAuthRequest struct {
+	AuthRequest
+	prompt []string
+}
+
+func (selectAccountAuthRequest) GetSubject() string    { return "" }
+func (r selectAccountAuthRequest) GetPrompt() []string { return r.prompt }
+func (selectAccountAuthRequest) GetMaxAge() *uint      { return nil }
+
+func TestActiveSessions(t *testing.T) {
+	active := &Session{AuthenticationResult: AuthenticationResult{Subject: "id1", Time: time.Now()}}
+	expired := &Session{AuthenticationResult: AuthenticationResult{Subject: "id2", Time: time.Now().Add(-24 * time.Hour)}}
+	provider := &Provider{storage: &multiSessionStorage{sessions: []*Session{active, expired}}}
+	authReq := selectAccountAuthRequest{prompt: []string{oidc.PromptSelectAccount}}
+
+	sessions, err := ActiveSessions(context.Background(), provider, authReq, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*Session{active}, sessions)
+
+	sessions, err = ActiveSessions(context.Background(), provider, selectAccountAuthRequest{prompt: []string{oidc.PromptLogin}}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, sessions, "prompt login")
+
+	provider.storage = &sessionStorage{session: active}
+	sessions, err = ActiveSessions(context.Background(), provider, authReq, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*Session{active}, sessions)
+
+	provider.storage = struct{ Storage }{}
+	sessions, err = ActiveSessions(context.Background(), provider, authReq, nil)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,408 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/cache"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// DefaultSessionTransferLifetime is the default lifetime of the transfer tokens.
+const DefaultSessionTransferLifetime = time.Minute
+
+// SessionTransferConfig configures the transfer of user sessions from first-party
+// clients, like a web app, to their native apps, see [WithSessionTransfer].
+type SessionTransferConfig struct {
+	// Clients are the client IDs of the first-party clients, which may obtain
+	// transfer tokens or redeem them. Required.
+	Clients []string
+	// Lifetime of the transfer tokens, defaults to [DefaultSessionTransferLifetime].
+	Lifetime time.Duration
+	// AllowRequestedScopes transfers the requested scopes, restricted by the
+	// [SessionTransferStorage] only. By default, they are restricted to the scopes of
+	// the subject_token: the openid scope and the ones of its scope claim, if any.
+	AllowRequestedScopes bool
+
+	// redeemed records the redeemed transfer tokens,
+	// if the Provider has no cache set with [WithReplayCache].
+	redeemed cache.Cache
+}
+
+// ErrSessionTransferClients is returned by [WithSessionTransfer] without first-party clients.
+var ErrSessionTransferClients = errors.New("session transfer requires first-party clients")
+
+func (c *SessionTransferConfig) lifetime() time.Duration {
+	if c.Lifetime > 0 {
+		return c.Lifetime
+	}
+	return DefaultSessionTransferLifetime
+}
+
+func (c *SessionTransferConfig) firstParty(clientID string) bool {
+	return slices.Contains(c.Clients, clientID)
+}
+
+type sessionTransferGetter interface {
+	SessionTransfer() *SessionTransferConfig
+}
+
+// sessionTransfer returns the [SessionTransferConfig],
+// nil if the transfer of sessions is disabled.
+func sessionTransfer(v any) *SessionTransferConfig {
+	if getter, ok := v.(sessionTransferGetter); ok {
+		return getter.SessionTransfer()
+	}
+	return nil
+}
+
+type sessionTransferEndpointGetter interface {
+	SessionTransferEndpoint() *Endpoint
+}
+
+// sessionTransferEndpointOf returns the endpoint of the provider, if any.
+func sessionTransferEndpointOf(config any) *Endpoint {
+	if getter, ok := config.(sessionTransferEndpointGetter); ok {
+		return getter.SessionTransferEndpoint()
+	}
+	return nil
+}
+
+// sessionTransferEndpoint returns the discovered URL of the endpoint,
+// empty if the transfer of sessions is disabled.
+func sessionTransferEndpoint(config any, endpoint *Endpoint, issuer string) string {
+	if sessionTransfer(config) == nil {
+		return ""
+	}
+	return endpoint.Absolute(issuer)
+}
+
+// SessionTransfer is the session of a user transferred to a native app,
+// carried encrypted by the transfer token.
+// It implements the [IDTokenRequest] and [SessionIDRequest] interfaces
+// for the tokens issued to the native app.
+type SessionTransfer struct {
+	// ID identifies the transfer token, which can only be redeemed once.
+	ID string `json:"jti"`
+	// Subject is the internal subject of the user.
+	Subject string `json:"sub"`
+	// ClientID is the client_id of the native app.
+	ClientID string `json:"client_id"`
+	// FromClientID is the client_id of the client the session is transferred from, if any.
+	FromClientID string    `json:"from_client_id,omitempty"`
+	Scopes       []string  `json:"scope,omitempty"`
+	AMR          []string  `json:"amr,omitempty"`
+	AuthTime     time.Time `json:"auth_time"`
+	// SessionID is the sid of the transferred session, so a logout of
+	// the session includes the native app.
+	SessionID  string    `json:"sid,omitempty"`
+	Expiration time.Time `json:"exp"`
+	// CodeChallenge must be met by the code_verifier of the native app,
+	// only the S256 method is accepted.
+	CodeChallenge *oidc.CodeChallenge `json:"code_challenge"`
+}
+
+func (t *SessionTransfer) GetAMR() []string {
+	return t.AMR
+}
+
+func (t *SessionTransfer) GetAudience() []string {
+	return []string{t.ClientID}
+}
+
+func (t *SessionTransfer) GetAuthTime() time.Time {
+	return t.AuthTime
+}
+
+func (t *SessionTransfer) GetClientID() string {
+	return t.ClientID
+}
+
+func (t *SessionTransfer) GetScopes() []string {
+	return t.Scopes
+}
+
+func (t *SessionTransfer) GetSubject() string {
+	return t.Subject
+}
+
+func (t *SessionTransfer) GetSessionID() string {
+	return t.SessionID
+}
+
+// 16 bytes gives 128 bit of entropy.
+const sessionTransferIDBytes = 16
+
+// CreateSessionTransfer issues a transfer token of the session to the native app
+// of the transfer, which redeems it with the session transfer grant and the
+// code_verifier of the CodeChallenge. It can be used by implementors transferring
+// sessions of their own login UI, the session transfer endpoint calls it for the
+// sessions of first-party clients.
+// The ID and Expiration of the transfer are set.
+func CreateSessionTransfer(ctx context.Context, o OpenIDProvider, transfer *SessionTransfer) (*oidc.SessionTransferResponse, error) {
+	ctx, span := tracer.Start(ctx, "CreateSessionTransfer")
+	defer span.End()
+
+	config := sessionTransfer(o)
+	if config == nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("session transfer not supported")
+	}
+	if !config.firstParty(transfer.ClientID) {
+		return nil, oidc.ErrInvalidTarget().WithDescription("client %s may not redeem session transfers", transfer.ClientID)
+	}
+	if transfer.Subject == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("session transfer without subject")
+	}
+	if transfer.CodeChallenge == nil || transfer.CodeChallenge.Challenge == "" ||
+		transfer.CodeChallenge.Method != oidc.CodeChallengeMethodS256 {
+		return nil, oidc.ErrInvalidRequest().WithDescription("code_challenge with code_challenge_method S256 required")
+	}
+	if validator, ok := o.Storage().(SessionTransferStorage); ok {
+		if err := validator.ValidateSessionTransfer(ctx, transfer); err != nil {
+			var oidcErr *oidc.Error
+			if errors.As(err, &oidcErr) {
+				return nil, err
+			}
+			return nil, oidc.ErrServerError().WithDescription("unable to validate session transfer").WithParent(err)
+		}
+	}
+
+	id, err := randomString(ctx, sessionTransferIDBytes)
+	if err != nil {
+		return nil, oidc.ErrServerError().WithDescription("unable to generate transfer token").WithParent(err)
+	}
+	lifetime := config.lifetime()
+	transfer.ID = id
+	transfer.Expiration = time.Now().Add(lifetime)
+	payload, err := json.Marshal(transfer)
+	if err != nil {
+		return nil, oidc.ErrServerError().WithParent(err)
+	}
+	token, err := o.Crypto().Encrypt(string(payload))
+	if err != nil {
+		return nil, oidc.ErrServerError().WithDescription("unable to encrypt transfer token").WithParent(err)
+	}
+	return &oidc.SessionTransferResponse{
+		TransferToken: token,
+		ExpiresIn:     uint64(lifetime / time.Second),
+	}, nil
+}
+
+func SessionTransferHandler(o OpenIDProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := TransferSession(w, r, o); err != nil {
+			RequestError(w, r, err, o.Logger())
+		}
+	}
+}
+
+// TransferSession handles the session transfer request of an authenticated first-party client,
+// responding with a transfer token of the session of the user to the native app of the request.
+func TransferSession(w http.ResponseWriter, r *http.Request, o OpenIDProvider) error {
+	ctx, span := tracer.Start(r.Context(), "TransferSession")
+	r = r.WithContext(ctx)
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		return oidc.ErrInvalidRequest().WithDescription("session transfer requests must be posted")
+	}
+	client, err := authenticateBackchannelClient(r, o)
+	if err != nil {
+		return err
+	}
+	req, err := ParseSessionTransferRequest(r, o.Decoder())
+	if err != nil {
+		return err
+	}
+	response, err := createSessionTransfer(ctx, o, req, client)
+	if err != nil {
+		return err
+	}
+	WriteJSON(w, response, http.StatusOK)
+	return nil
+}
+
+func ParseSessionTransferRequest(r *http.Request, decoder httphelper.Decoder) (*oidc.SessionTransferRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse form").WithParent(err)
+	}
+	req := new(oidc.SessionTransferRequest)
+	if err := decoder.Decode(req, r.Form); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("cannot parse session transfer request").WithParent(err)
+	}
+	return req, nil
+}
+
+// createSessionTransfer verifies the subject_token of the client
+// and issues a transfer token of its session to the native app.
+func createSessionTransfer(ctx context.Context, o OpenIDProvider, req *oidc.SessionTransferRequest, client Client) (*oidc.SessionTransferResponse, error) {
+	ctx, span := tracer.Start(ctx, "createSessionTransfer")
+	defer span.End()
+
+	config := sessionTransfer(o)
+	if config == nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("session transfer not supported")
+	}
+	if client.AuthMethod() == oidc.AuthMethodNone {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("session transfer requires a confidential client")
+	}
+	if !config.firstParty(client.GetID()) {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("client %s may not transfer sessions", client.GetID())
+	}
+	if req.ClientID != "" && req.ClientID != client.GetID() {
+		return nil, oidc.ErrInvalidRequest().WithDescription("client_id does not match the authenticated client")
+	}
+	if req.SubjectToken == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("subject_token missing")
+	}
+	if req.Audience == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("audience missing")
+	}
+	claims, err := VerifyIDTokenHint[*oidc.IDTokenClaims](ctx, req.SubjectToken, o.IDTokenHintVerifier(ctx))
+	if err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("the subject_token is invalid").WithParent(err)
+	}
+	if !slices.Contains(claims.Audience, client.GetID()) {
+		return nil, oidc.ErrInvalidRequest().WithDescription("the subject_token was not issued to the client")
+	}
+	subject, err := internalSubject(ctx, o.Storage(), claims.Subject, client.GetID())
+	if err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("the subject_token is invalid").WithParent(err)
+	}
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID}
+	}
+	if !config.AllowRequestedScopes {
+		granted := subjectTokenScopes(claims)
+		scopes = slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+			return !slices.Contains(granted, scope)
+		})
+	}
+	return CreateSessionTransfer(ctx, o, &SessionTransfer{
+		Subject:      subject,
+		ClientID:     req.Audience,
+		FromClientID: client.GetID(),
+		Scopes:       scopes,
+		AMR:          claims.AuthenticationMethodsReferences,
+		AuthTime:     claims.GetAuthTime(),
+		SessionID:    claims.SessionID,
+		CodeChallenge: &oidc.CodeChallenge{
+			Challenge: req.CodeChallenge,
+			Method:    req.CodeChallengeMethod,
+		},
+	})
+}
+
+// subjectTokenScopes returns the scopes of the ID token: the openid scope
+// and the ones of its scope claim, if any.
+func subjectTokenScopes(claims *oidc.IDTokenClaims) []string {
+	scopes := []string{oidc.ScopeOpenID}
+	if scope, ok := claims.Claims["scope"].(string); ok {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+	return scopes
+}
+
+func SessionTransferAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchanger) {
+	ctx, span := tracer.Start(r.Context(), "SessionTransferAccessToken")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	if err := sessionTransferAccessToken(w, r, exchanger); err != nil {
+		RequestError(w, r, err, exchanger.Logger())
+	}
+}
+
+func sessionTransferAccessToken(w http.ResponseWriter, r *http.Request, exchanger Exchanger) error {
+	ctx := r.Context()
+	clientID, clientAuthenticated, err := ClientIDFromRequest(r, exchanger)
+	if err != nil {
+		return err
+	}
+	client, err := exchanger.Storage().GetClientByClientID(ctx, clientID)
+	if err != nil {
+		return oidc.ErrInvalidClient().WithParent(err)
+	}
+	if clientAuthenticated != IsConfidentialType(client) {
+		return oidc.ErrInvalidClient().WithParent(ErrNoClientCredentials).
+			WithDescription("confidential client requires authentication")
+	}
+	req := new(oidc.SessionTransferTokenRequest)
+	if err := exchanger.Decoder().Decode(req, r.PostForm); err != nil {
+		return oidc.ErrInvalidRequest().WithDescription("error decoding form").WithParent(err)
+	}
+	resp, err := createSessionTransferTokenResponse(ctx, exchanger, client, req)
+	if err != nil {
+		return err
+	}
+	WriteJSON(w, resp, http.StatusOK)
+	return nil
+}
+
+// createSessionTransferTokenResponse redeems the transfer token of the native app,
+// if the code_verifier meets its code challenge, and issues the tokens of the session.
+func createSessionTransferTokenResponse(ctx context.Context, exchanger Exchanger, client Client, req *oidc.SessionTransferTokenRequest) (*oidc.AccessTokenResponse, error) {
+	ctx, span := tracer.Start(ctx, "createSessionTransferTokenResponse")
+	defer span.End()
+
+	config := sessionTransfer(exchanger)
+	if config == nil {
+		return nil, oidc.ErrUnsupportedGrantType().WithDescription("session transfer grant not supported")
+	}
+	if !ValidateGrantType(client, oidc.GrantTypeSessionTransfer) {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("client missing grant type " + string(oidc.GrantTypeSessionTransfer))
+	}
+	if !config.firstParty(client.GetID()) {
+		return nil, oidc.ErrUnauthorizedClient().WithDescription("client %s may not redeem session transfers", client.GetID())
+	}
+	if req.TransferToken == "" {
+		return nil, oidc.ErrInvalidRequest().WithDescription("transfer_token missing")
+	}
+	transfer, err := decryptSessionTransfer(exchanger.Crypto(), req.TransferToken)
+	if err != nil {
+		return nil, oidc.ErrInvalidGrant().WithDescription("invalid transfer_token").WithParent(err)
+	}
+	if transfer.ClientID != client.GetID() {
+		return nil, oidc.ErrInvalidGrant().WithDescription("transfer_token was issued to another client")
+	}
+	ttl := time.Until(transfer.Expiration)
+	if ttl <= 0 {
+		return nil, oidc.ErrInvalidGrant().WithDescription("transfer_token expired")
+	}
+	if err = AuthorizeCodeChallenge(req.CodeVerifier, transfer.CodeChallenge); err != nil {
+		return nil, err
+	}
+	redeemed := replayCache(exchanger)
+	if redeemed == nil {
+		redeemed = config.redeemed
+	}
+	if err = cache.CheckReplay(ctx, redeemed, "session_transfer:"+transfer.ID, ttl); err != nil {
+		if errors.Is(err, cache.ErrReplay) {
+			return nil, oidc.ErrInvalidGrant().WithDescription("transfer_token already redeemed")
+		}
+		return nil, oidc.ErrServerError().WithParent(err)
+	}
+	return CreateDeviceTokenResponse(ctx, transfer, exchanger, client)
+}
+
+func decryptSessionTransfer(crypto Crypto, token string) (*SessionTransfer, error) {
+	payload, err := crypto.Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+	transfer := new(SessionTransfer)
+	if err = json.Unmarshal([]byte(payload), transfer); err != nil {
+		return nil, err
+	}
+	if transfer.ID == "" || transfer.CodeChallenge == nil {
+		return nil, errors.New("not a transfer token")
+	}
+	return transfer, nil
+}
@@ -0,0 +1,158 @@
+package op_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func newSessionTransferProvider(t *testing.T) (op.OpenIDProvider, string) {
+	t.Helper()
+	storage.RegisterClients(storage.FirstPartyNativeClient("app"))
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	keySet := &op.OpenIDKeySet{s}
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithAllowInsecure(),
+		op.WithAccessTokenKeySet(keySet),
+		op.WithIDTokenHintKeySet(keySet),
+		op.WithSessionTransfer(op.SessionTransferConfig{Clients: []string{"web", "app"}}),
+	)
+	require.NoError(t, err)
+
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	client, err := s.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	authReq, err := s.CreateAuthRequest(ctx, &oidc.AuthRequest{
+		ClientID:     "web",
+		RedirectURI:  "https://example.com",
+		Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID, oidc.ScopeProfile},
+		ResponseType: oidc.ResponseTypeCode,
+	}, "id1")
+	require.NoError(t, err)
+	require.NoError(t, s.AuthRequestDone(authReq.GetID()))
+	idToken, err := op.CreateIDToken(ctx, testIssuer, authReq, time.Hour, "", "", s, client)
+	require.NoError(t, err)
+	return provider, idToken
+}
+
+func postSessionTransfer(provider op.OpenIDProvider, path string, form url.Values, basicAuth bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, testIssuer+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicAuth {
+		req.SetBasicAuth("web", "secret")
+	}
+	rec := httptest.NewRecorder()
+	provider.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSessionTransfer(t *testing.T) {
+	provider, idToken := newSessionTransferProvider(t)
+	verifier := "session-transfer-code-verifier-of-the-native-app"
+
+	rec := postSessionTransfer(provider, "session_transfer", url.Values{
+		"subject_token":         {idToken},
+		"audience":              {"app"},
+		"scope":                 {"openid profile"},
+		"code_challenge":        {oidc.NewSHACodeChallenge(verifier)},
+		"code_challenge_method": {string(oidc.CodeChallengeMethodS256)},
+	}, true)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var transfer oidc.SessionTransferResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &transfer))
+	assert.Equal(t, uint64(op.DefaultSessionTransferLifetime/time.Second), transfer.ExpiresIn)
+	payload, err := provider.Crypto().Decrypt(transfer.TransferToken)
+	require.NoError(t, err)
+	var session op.SessionTransfer
+	require.NoError(t, json.Unmarshal([]byte(payload), &session))
+	assert.Equal(t, []string{oidc.ScopeOpenID}, session.Scopes, "scopes are restricted to the ones of the subject_token")
+
+	redeem := func(verifier string) *httptest.ResponseRecorder {
+		return postSessionTransfer(provider, "oauth/token", url.Values{
+			"grant_type":     {string(oidc.GrantTypeSessionTransfer)},
+			"client_id":      {"app"},
+			"transfer_token": {transfer.TransferToken},
+			"code_verifier":  {verifier},
+		}, false)
+	}
+
+	rec = redeem("wrong-verifier")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid_grant")
+
+	rec = redeem(verifier)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var tokens oidc.AccessTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+	assert.NotEmpty(t, tokens.AccessToken)
+	claims := new(oidc.IDTokenClaims)
+	_, err = oidc.ParseToken(tokens.IDToken, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "id1", claims.Subject)
+	assert.Equal(t, oidc.Audience{"app"}, claims.Audience)
+
+	rec = redeem(verifier)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "already redeemed")
+}
+
+func TestSessionTransfer_errors(t *testing.T) {
+	provider, idToken := newSessionTransferProvider(t)
+	challenge := oidc.NewSHACodeChallenge("verifier")
+	tests := []struct {
+		name      string
+		form      url.Values
+		basicAuth bool
+		wantErr   string
+	}{
+		{
+			name:    "unauthenticated",
+			form:    url.Values{"client_id": {"web"}, "subject_token": {idToken}, "audience": {"app"}, "code_challenge": {challenge}, "code_challenge_method": {"S256"}},
+			wantErr: "invalid_client",
+		},
+		{
+			name:      "invalid subject token",
+			form:      url.Values{"subject_token": {"foo"}, "audience": {"app"}, "code_challenge": {challenge}, "code_challenge_method": {"S256"}},
+			basicAuth: true,
+			wantErr:   "the subject_token is invalid",
+		},
+		{
+			name:      "third-party audience",
+			form:      url.Values{"subject_token": {idToken}, "audience": {"device"}, "code_challenge": {challenge}, "code_challenge_method": {"S256"}},
+			basicAuth: true,
+			wantErr:   "invalid_target",
+		},
+		{
+			name:      "plain code challenge",
+			form:      url.Values{"subject_token": {idToken}, "audience": {"app"}, "code_challenge": {challenge}},
+			basicAuth: true,
+			wantErr:   "code_challenge_method S256 required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postSessionTransfer(provider, "session_transfer", tt.form, tt.basicAuth)
+			assert.NotEqual(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantErr)
+		})
+	}
+}
+
+func TestWithSessionTransfer_clients(t *testing.T) {
+	_, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithSessionTransfer(op.SessionTransferConfig{}),
+	)
+	assert.ErrorIs(t, err, op.ErrSessionTransferClients)
+}
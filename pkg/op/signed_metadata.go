@@ -0,0 +1,92 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// SignedMetadataConfig publishes the discovery configuration additionally
+// as signed_metadata (RFC 8414, section 2.1), see [WithSignedMetadata].
+// Relying parties verifying it against their trust anchors detect
+// discovery documents tampered with by a CDN or on the network.
+type SignedMetadataConfig struct {
+	// Key signs the metadata, defaults to the signing key of the tokens.
+	// A dedicated key, which is not rotated with the token keys, can be
+	// pinned by the relying parties, or certified with an x5c chain.
+	Key SigningKey
+	// Header of the signed metadata, see [TokenHeader].
+	Header *TokenHeader
+	// Lifetime sets the exp claim of the signed metadata, if not zero.
+	Lifetime time.Duration
+}
+
+var errSignedMetadataKey = errors.New("no signing key for the signed metadata")
+
+type signedMetadataGetter interface {
+	SignedMetadata() *SignedMetadataConfig
+}
+
+func signedMetadata(v any) *SignedMetadataConfig {
+	if getter, ok := v.(signedMetadataGetter); ok {
+		return getter.SignedMetadata()
+	}
+	return nil
+}
+
+// signMetadata sets the signed_metadata of the discovery configuration,
+// if enabled for the config. Failures are logged and omit the signed_metadata,
+// which relying parties requiring it reject.
+func signMetadata(ctx context.Context, config Configuration, storage DiscoverStorage, discovery *oidc.DiscoveryConfiguration) *oidc.DiscoveryConfiguration {
+	signed := signedMetadata(config)
+	if signed == nil {
+		return discovery
+	}
+	token, err := signed.sign(ctx, config, storage, discovery)
+	if err != nil {
+		providerLogger(config).ErrorContext(ctx, "sign discovery metadata", "err", err)
+		return discovery
+	}
+	discovery.SignedMetadata = token
+	return discovery
+}
+
+func (c *SignedMetadataConfig) sign(ctx context.Context, config Configuration, storage DiscoverStorage, discovery *oidc.DiscoveryConfiguration) (string, error) {
+	key := c.Key
+	if key == nil {
+		keys, ok := config.(signingKeyGetter)
+		if !ok {
+			keys, ok = storage.(signingKeyGetter)
+		}
+		if !ok {
+			return "", errSignedMetadataKey
+		}
+		var err error
+		if key, err = keys.SigningKey(ctx); err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(discovery)
+	if err != nil {
+		return "", err
+	}
+	claims := make(map[string]any)
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims["iss"] = discovery.Issuer
+	claims["iat"] = now.Unix()
+	if c.Lifetime > 0 {
+		claims["exp"] = now.Add(c.Lifetime).Unix()
+	}
+	signer, err := SignerFromKeyWithHeader(key, fipsTokenHeader(config, c.Header))
+	if err != nil {
+		return "", err
+	}
+	return crypto.SignCanonical(claims, signer)
+}
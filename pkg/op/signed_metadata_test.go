@@ -0,0 +1,57 @@
+package op_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+type metadataSigningKey struct{}
+
+func (metadataSigningKey) SignatureAlgorithm() jose.SignatureAlgorithm { return tu.SignatureAlgorithm }
+func (metadataSigningKey) Key() any                                    { return tu.WebKey.Key }
+func (metadataSigningKey) ID() string                                  { return tu.WebKey.KeyID }
+
+func TestWithSignedMetadata(t *testing.T) {
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s)
+	require.NoError(t, err)
+	assert.Empty(t, op.CreateDiscoveryConfig(ctx, provider, s).SignedMetadata)
+
+	provider, err = op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithSignedMetadata(op.SignedMetadataConfig{Key: metadataSigningKey{}, Lifetime: time.Hour}),
+	)
+	require.NoError(t, err)
+	config := op.CreateDiscoveryConfig(ctx, provider, s)
+	require.NotEmpty(t, config.SignedMetadata)
+
+	tampered := *config
+	tampered.TokenEndpoint = "https://attacker.example.com/token"
+	verified, err := oidc.VerifySignedMetadata(ctx, &tampered, oidc.NewStaticKeySet(tu.WebKey))
+	require.NoError(t, err)
+	assert.Equal(t, config, verified)
+
+	// the signing key of the tokens by default
+	provider, err = op.NewOpenIDProvider(testIssuer, testConfig, s, op.WithSignedMetadata(op.SignedMetadataConfig{}))
+	require.NoError(t, err)
+	config = op.CreateDiscoveryConfig(ctx, provider, s)
+	keys, err := s.KeySet(ctx)
+	require.NoError(t, err)
+	_, err = oidc.VerifySignedMetadata(ctx, config, oidc.NewStaticKeySet(jose.JSONWebKey{
+		Key:   keys[0].Key(),
+		KeyID: keys[0].ID(),
+		Use:   keys[0].Use(),
+	}))
+	require.NoError(t, err)
+}
@@ -1,6 +1,10 @@
 package op
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"errors"
 
 	jose "github.com/go-jose/go-jose/v4"
@@ -14,20 +18,106 @@ type SigningKey interface {
 	ID() string
 }
 
+// KeyCertificates can be implemented by a [SigningKey] or a [Key]
+// to provide its X.509 certificate chain, leaf first.
+// It is added as x5c to the JSON Web Key Set and, if enabled
+// by [TokenHeader], to the header of issued tokens.
+type KeyCertificates interface {
+	Certificates() []*x509.Certificate
+}
+
+// TokenHeader sets or overrides parameters of the JWS header of issued tokens.
+// See [WithIDTokenHeader] and [WithAccessTokenHeader].
+type TokenHeader struct {
+	// Type is the typ header parameter, which defaults to JWT.
+	// RFC 9068 requires at+jwt for access tokens.
+	Type string
+	// KeyID returns the kid header parameter for the signing key,
+	// which defaults to the ID of the key.
+	KeyID func(key SigningKey) string
+	// X509 adds the x5c chain and the x5t and x5t#S256 thumbprints of the leaf,
+	// if the signing key implements [KeyCertificates].
+	X509 bool
+	// Extra parameters are added to the header and take precedence over all others.
+	Extra map[string]any
+
+	// omitSHA1 omits the x5t thumbprint in the FIPS mode, see [WithFIPS].
+	omitSHA1 bool
+}
+
 func SignerFromKey(key SigningKey) (jose.Signer, error) {
+	return SignerFromKeyWithHeader(key, nil)
+}
+
+// SignerFromKeyWithHeader is like [SignerFromKey], with the header parameters applied.
+// A nil header results in the same signer as [SignerFromKey].
+func SignerFromKeyWithHeader(key SigningKey, header *TokenHeader) (jose.Signer, error) {
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	keyID := key.ID()
+	if header != nil {
+		if header.Type != "" {
+			opts = opts.WithType(jose.ContentType(header.Type))
+		}
+		if header.KeyID != nil {
+			keyID = header.KeyID(key)
+		}
+		if certs, ok := key.(KeyCertificates); ok && header.X509 && len(certs.Certificates()) > 0 {
+			chain := certs.Certificates()
+			x5c := make([]string, len(chain))
+			for i, cert := range chain {
+				x5c[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+			}
+			sha256Sum := sha256.Sum256(chain[0].Raw)
+			opts = opts.WithHeader("x5c", x5c).
+				WithHeader("x5t#S256", base64.RawURLEncoding.EncodeToString(sha256Sum[:]))
+			if !header.omitSHA1 {
+				sha1Sum := sha1.Sum(chain[0].Raw)
+				opts = opts.WithHeader("x5t", base64.RawURLEncoding.EncodeToString(sha1Sum[:]))
+			}
+		}
+	}
+	// go-jose sets the kid of the public key, which opaque signers
+	// of keys it doesn't know, like ML-DSA, can't provide.
+	if opaque, ok := key.Key().(jose.OpaqueSigner); ok && opaque.Public() == nil && keyID != "" {
+		opts = opts.WithHeader("kid", keyID)
+	}
+	if header != nil {
+		for k, v := range header.Extra {
+			opts = opts.WithHeader(jose.HeaderKey(k), v)
+		}
+	}
 	signer, err := jose.NewSigner(jose.SigningKey{
 		Algorithm: key.SignatureAlgorithm(),
 		Key: &jose.JSONWebKey{
 			Key:   key.Key(),
-			KeyID: key.ID(),
+			KeyID: keyID,
 		},
-	}, (&jose.SignerOptions{}).WithType("JWT"))
+	}, opts)
 	if err != nil {
 		return nil, ErrSignerCreationFailed // TODO: log / wrap error?
 	}
 	return signer, nil
 }
 
+type tokenHeaderGetter interface {
+	IDTokenHeader() *TokenHeader
+	AccessTokenHeader() *TokenHeader
+}
+
+func idTokenHeader(v any) *TokenHeader {
+	if g, ok := v.(tokenHeaderGetter); ok {
+		return g.IDTokenHeader()
+	}
+	return nil
+}
+
+func accessTokenHeader(v any) *TokenHeader {
+	if g, ok := v.(tokenHeaderGetter); ok {
+		return g.AccessTokenHeader()
+	}
+	return nil
+}
+
 type Key interface {
 	ID() string
 	Algorithm() jose.SignatureAlgorithm
@@ -0,0 +1,171 @@
+package op_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type certSigningKey struct {
+	key   *rsa.PrivateKey
+	certs []*x509.Certificate
+}
+
+func (k certSigningKey) SignatureAlgorithm() jose.SignatureAlgorithm { return jose.RS256 }
+func (k certSigningKey) Key() any                                    { return k.key }
+func (k certSigningKey) ID() string                                  { return "key1" }
+func (k certSigningKey) Certificates() []*x509.Certificate           { return k.certs }
+
+func newCertSigningKey(t *testing.T) certSigningKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return certSigningKey{key: key, certs: []*x509.Certificate{cert}}
+}
+
+// signedHeader returns the protected header parameters of the token.
+func signedHeader(t *testing.T, token string) map[string]any {
+	_, err := jose.ParseSigned(token, []jose.SignatureAlgorithm{jose.RS256})
+	require.NoError(t, err)
+	encoded, _, _ := strings.Cut(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	var header map[string]any
+	require.NoError(t, json.Unmarshal(data, &header))
+	return header
+}
+
+func TestSignerFromKeyWithHeader(t *testing.T) {
+	key := newCertSigningKey(t)
+	thumbprint := sha256.Sum256(key.certs[0].Raw)
+
+	tests := []struct {
+		name     string
+		header   *op.TokenHeader
+		wantType string
+		wantKID  string
+		wantX509 bool
+		wantCty  any
+	}{
+		{
+			name:     "default",
+			wantType: "JWT",
+			wantKID:  "key1",
+		},
+		{
+			name:     "type",
+			header:   &op.TokenHeader{Type: "at+jwt"},
+			wantType: "at+jwt",
+			wantKID:  "key1",
+		},
+		{
+			name: "key id",
+			header: &op.TokenHeader{KeyID: func(key op.SigningKey) string {
+				return "urn:example:" + key.ID()
+			}},
+			wantType: "JWT",
+			wantKID:  "urn:example:key1",
+		},
+		{
+			name:     "x509",
+			header:   &op.TokenHeader{X509: true},
+			wantType: "JWT",
+			wantKID:  "key1",
+			wantX509: true,
+		},
+		{
+			name: "extra",
+			header: &op.TokenHeader{
+				Type:  "at+jwt",
+				Extra: map[string]any{"cty": "secevent+jwt", "typ": "custom"},
+			},
+			wantType: "custom",
+			wantKID:  "key1",
+			wantCty:  "secevent+jwt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := op.SignerFromKeyWithHeader(key, tt.header)
+			require.NoError(t, err)
+			token, err := crypto.Sign(map[string]string{"sub": "id1"}, signer)
+			require.NoError(t, err)
+
+			header := signedHeader(t, token)
+			assert.Equal(t, tt.wantType, header["typ"])
+			assert.Equal(t, tt.wantKID, header["kid"])
+			assert.Equal(t, tt.wantCty, header["cty"])
+			if tt.wantX509 {
+				assert.Equal(t, []any{base64.StdEncoding.EncodeToString(key.certs[0].Raw)}, header["x5c"])
+				assert.Equal(t, base64.RawURLEncoding.EncodeToString(thumbprint[:]), header["x5t#S256"])
+				assert.NotEmpty(t, header["x5t"])
+			} else {
+				assert.NotContains(t, header, "x5c")
+				assert.NotContains(t, header, "x5t#S256")
+			}
+		})
+	}
+}
+
+type jwtAccessTokenClient struct {
+	op.Client
+}
+
+func (jwtAccessTokenClient) AccessTokenType() op.AccessTokenType {
+	return op.AccessTokenTypeJWT
+}
+
+func TestCreateTokenResponse_tokenHeaders(t *testing.T) {
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	storage := storage.NewStorage(storage.NewUserStore(testIssuer))
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage,
+		op.WithAllowInsecure(),
+		op.WithIDTokenHeader(op.TokenHeader{Extra: map[string]any{"x-id": "true"}}),
+		op.WithAccessTokenHeader(op.TokenHeader{Type: "at+jwt"}),
+	)
+	require.NoError(t, err)
+
+	client, err := storage.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	authReq, err := storage.CreateAuthRequest(ctx, &oidc.AuthRequest{
+		ClientID:     client.GetID(),
+		RedirectURI:  "https://example.com",
+		Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID},
+		ResponseType: oidc.ResponseTypeCode,
+	}, "id1")
+	require.NoError(t, err)
+
+	resp, err := op.CreateTokenResponse(ctx, authReq, jwtAccessTokenClient{client}, provider, true, "code", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "at+jwt", signedHeader(t, resp.AccessToken)["typ"])
+	idTokenHeader := signedHeader(t, resp.IDToken)
+	assert.Equal(t, "JWT", idTokenHeader["typ"])
+	assert.Equal(t, "true", idTokenHeader["x-id"])
+}
@@ -0,0 +1,255 @@
+package op
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/lmindwarel/oidc/v3/pkg/background"
+)
+
+// SoftFailDropPolicy decides which task is dropped, when the queue of a [SoftFailQueue] is full.
+type SoftFailDropPolicy int
+
+const (
+	// SoftFailDropNewest drops the task to be enqueued.
+	SoftFailDropNewest SoftFailDropPolicy = iota
+	// SoftFailDropOldest drops the oldest queued task in favor of the task to be enqueued.
+	SoftFailDropOldest
+)
+
+// Defaults of the [SoftFailConfig].
+const (
+	DefaultSoftFailQueueSize = 1000
+	DefaultSoftFailWorkers   = 1
+	DefaultSoftFailTimeout   = 10 * time.Second
+)
+
+// SoftFailConfig configures a [SoftFailQueue].
+type SoftFailConfig struct {
+	// QueueSize is the number of tasks queued before tasks are dropped,
+	// defaults to [DefaultSoftFailQueueSize].
+	QueueSize int
+	// Workers is the number of tasks run concurrently, defaults to [DefaultSoftFailWorkers].
+	Workers int
+	// Timeout of each task, defaults to [DefaultSoftFailTimeout].
+	Timeout time.Duration
+	// DropPolicy applies when the queue is full, defaults to [SoftFailDropNewest].
+	DropPolicy SoftFailDropPolicy
+	// Logger logs dropped and failed tasks, defaults to slog.Default().
+	Logger *slog.Logger
+	// MeterProvider records the oidc.server.soft_fail.dropped and oidc.server.soft_fail.failed
+	// counters with the oidc.subsystem attribute, defaults to the global provider of OpenTelemetry.
+	MeterProvider metric.MeterProvider
+	// Context runs the workers in its [background.Group], if any, see [background.WithGroup].
+	// The workers stop when it is done, leaving the queued tasks. Defaults to context.Background().
+	Context context.Context
+}
+
+// SoftFailStats are the counters of a [SoftFailQueue].
+type SoftFailStats struct {
+	// Enqueued tasks, including tasks dropped by [SoftFailDropOldest] later on.
+	Enqueued uint64
+	// Dropped tasks, because the queue was full or closed.
+	Dropped uint64
+	// Failed tasks, which returned an error or panicked.
+	Failed uint64
+	// Completed tasks, including failed tasks.
+	Completed uint64
+}
+
+// SoftFailQueue runs the tasks of an optional subsystem, like an audit sink,
+// webhook events or notifications of clients, in the background with a bounded queue.
+// Tasks are dropped rather than blocking the caller, when the queue is full,
+// so a failing or slow backend never blocks the requests of the provider,
+// like the issuance of tokens. Dropped and failed tasks are logged and counted,
+// see [SoftFailQueue.Stats].
+//
+// The queue is used by [WithBackChannelLogoutQueue] and can be used by
+// extensions, e.g. from [TokenHooks]. It must be created by [NewSoftFailQueue]
+// and should be closed on shutdown, see [SoftFailQueue.Close].
+type SoftFailQueue struct {
+	name   string
+	config SoftFailConfig
+	tasks  chan softFailTask
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	enqueued, dropped, failed, completed atomic.Uint64
+	droppedCounter, failedCounter        metric.Int64Counter
+	attrs                                metric.MeasurementOption
+}
+
+type softFailTask struct {
+	ctx context.Context
+	run func(context.Context) error
+}
+
+// NewSoftFailQueue starts the workers of the queue of the named subsystem.
+func NewSoftFailQueue(name string, config SoftFailConfig) (*SoftFailQueue, error) {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultSoftFailQueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = DefaultSoftFailWorkers
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultSoftFailTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if config.MeterProvider == nil {
+		config.MeterProvider = otel.GetMeterProvider()
+	}
+	if config.Context == nil {
+		config.Context = context.Background()
+	}
+	meter := config.MeterProvider.Meter(instrumentationName)
+	dropped, err := meter.Int64Counter("oidc.server.soft_fail.dropped",
+		metric.WithDescription("Number of tasks of optional subsystems dropped by their full queue."),
+	)
+	if err != nil {
+		return nil, err
+	}
+	failed, err := meter.Int64Counter("oidc.server.soft_fail.failed",
+		metric.WithDescription("Number of failed tasks of optional subsystems."),
+	)
+	if err != nil {
+		return nil, err
+	}
+	q := &SoftFailQueue{
+		name:           name,
+		config:         config,
+		tasks:          make(chan softFailTask, config.QueueSize),
+		droppedCounter: dropped,
+		failedCounter:  failed,
+		attrs:          metric.WithAttributes(attribute.String("oidc.subsystem", name)),
+	}
+	q.wg.Add(config.Workers)
+	for range config.Workers {
+		background.Go(config.Context, q.work)
+	}
+	return q, nil
+}
+
+// Enqueue queues the task and reports if it was queued, rather than dropped.
+// The task is run with a context without the cancellation of ctx, but with its values,
+// limited by the Timeout of the [SoftFailConfig].
+func (q *SoftFailQueue) Enqueue(ctx context.Context, task func(ctx context.Context) error) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		q.drop(ctx, "queue closed")
+		return false
+	}
+	t := softFailTask{ctx: context.WithoutCancel(ctx), run: task}
+	select {
+	case q.tasks <- t:
+		q.enqueued.Add(1)
+		return true
+	default:
+	}
+	if q.config.DropPolicy == SoftFailDropOldest {
+		select {
+		case oldest := <-q.tasks:
+			q.drop(oldest.ctx, "queue full")
+		default:
+		}
+		select {
+		case q.tasks <- t:
+			q.enqueued.Add(1)
+			return true
+		default:
+		}
+	}
+	q.drop(ctx, "queue full")
+	return false
+}
+
+func (q *SoftFailQueue) drop(ctx context.Context, reason string) {
+	q.dropped.Add(1)
+	q.droppedCounter.Add(ctx, 1, q.attrs)
+	q.config.Logger.WarnContext(ctx, "soft-fail task dropped", "subsystem", q.name, "reason", reason)
+}
+
+// work runs the tasks until the queue is closed or the Context of the config is done.
+// Failed tasks are soft failures, which are logged rather than returned.
+func (q *SoftFailQueue) work() error {
+	defer q.wg.Done()
+	for {
+		select {
+		case t, ok := <-q.tasks:
+			if !ok {
+				return nil
+			}
+			q.run(t)
+		case <-q.config.Context.Done():
+			return nil
+		}
+	}
+}
+
+func (q *SoftFailQueue) run(t softFailTask) {
+	ctx, cancel := context.WithTimeout(t.ctx, q.config.Timeout)
+	defer cancel()
+	defer q.completed.Add(1)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("soft-fail task panicked: %v", r)
+				q.config.Logger.ErrorContext(ctx, "soft-fail task panicked", "subsystem", q.name,
+					"panic", r, "stack", string(debug.Stack()))
+			}
+		}()
+		return t.run(ctx)
+	}()
+	if err != nil {
+		q.failed.Add(1)
+		q.failedCounter.Add(ctx, 1, q.attrs)
+		q.config.Logger.WarnContext(ctx, "soft-fail task failed", "subsystem", q.name, "error", err)
+	}
+}
+
+// Stats returns the counters of the queue.
+func (q *SoftFailQueue) Stats() SoftFailStats {
+	return SoftFailStats{
+		Enqueued:  q.enqueued.Load(),
+		Dropped:   q.dropped.Load(),
+		Failed:    q.failed.Load(),
+		Completed: q.completed.Load(),
+	}
+}
+
+// Close stops accepting tasks and waits for the queued tasks to complete,
+// or returns the error of ctx when it is done first.
+func (q *SoftFailQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,126 @@
+package op_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/background"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestSoftFailQueue(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    op.SoftFailDropPolicy
+		wantTasks []int
+	}{
+		{
+			name:      "drop newest",
+			policy:    op.SoftFailDropNewest,
+			wantTasks: []int{0, 1, 2},
+		},
+		{
+			name:      "drop oldest",
+			policy:    op.SoftFailDropOldest,
+			wantTasks: []int{0, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue, err := op.NewSoftFailQueue("test", op.SoftFailConfig{QueueSize: 2, DropPolicy: tt.policy})
+			require.NoError(t, err)
+
+			var (
+				mu    sync.Mutex
+				tasks []int
+			)
+			started, release := make(chan struct{}), make(chan struct{})
+			task := func(i int) func(context.Context) error {
+				return func(context.Context) error {
+					if i == 0 {
+						close(started)
+						<-release
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					tasks = append(tasks, i)
+					if i == 2 {
+						return errors.New("backend unavailable")
+					}
+					return nil
+				}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			assert.True(t, queue.Enqueue(ctx, task(0)))
+			<-started
+			assert.True(t, queue.Enqueue(ctx, task(1)))
+			assert.True(t, queue.Enqueue(ctx, task(2)))
+			assert.Equal(t, tt.policy == op.SoftFailDropOldest, queue.Enqueue(ctx, task(3)))
+			cancel()
+			close(release)
+
+			require.NoError(t, queue.Close(context.Background()))
+			assert.False(t, queue.Enqueue(context.Background(), task(4)))
+			assert.Equal(t, tt.wantTasks, tasks)
+
+			stats := queue.Stats()
+			assert.Equal(t, uint64(2), stats.Dropped)
+			assert.Equal(t, uint64(1), stats.Failed)
+			assert.Equal(t, uint64(3), stats.Completed)
+		})
+	}
+}
+
+type softFailGroup struct {
+	sync.WaitGroup
+	started int
+}
+
+func (g *softFailGroup) Go(f func() error) {
+	g.started++
+	g.Add(1)
+	go func() {
+		defer g.Done()
+		_ = f()
+	}()
+}
+
+func TestSoftFailQueue_group(t *testing.T) {
+	group := new(softFailGroup)
+	ctx, cancel := context.WithCancel(background.WithGroup(context.Background(), group))
+	queue, err := op.NewSoftFailQueue("test", op.SoftFailConfig{Workers: 2, Context: ctx})
+	require.NoError(t, err)
+	assert.Equal(t, 2, group.started)
+
+	done := make(chan struct{})
+	assert.True(t, queue.Enqueue(context.Background(), func(context.Context) error {
+		close(done)
+		return nil
+	}))
+	<-done
+
+	// the workers stop with the context of the group
+	cancel()
+	group.Wait()
+	require.NoError(t, queue.Close(context.Background()))
+}
+
+func TestSoftFailQueue_panic(t *testing.T) {
+	var logs bytes.Buffer
+	queue, err := op.NewSoftFailQueue("test", op.SoftFailConfig{Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+	require.NoError(t, err)
+	queue.Enqueue(context.Background(), func(context.Context) error {
+		panic("boom")
+	})
+	require.NoError(t, queue.Close(context.Background()))
+	assert.Equal(t, uint64(1), queue.Stats().Failed)
+	assert.Contains(t, logs.String(), "panic=boom")
+	assert.Contains(t, logs.String(), "TestSoftFailQueue_panic")
+}
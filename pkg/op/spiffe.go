@@ -0,0 +1,184 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// SPIFFETrustDomain is a SPIFFE trust domain, whose JWT-SVIDs are accepted
+// by the Provider, see [SPIFFEConfig].
+type SPIFFETrustDomain struct {
+	// Name of the trust domain, e.g. example.org.
+	Name string
+	// BundleEndpoint is the location of the trust bundle of the domain
+	// in JWKS format, fetched and cached by the [ClientJWKSCache] of the Provider.
+	BundleEndpoint string
+}
+
+// SPIFFEConfig accepts JWT-SVIDs of workloads of trusted SPIFFE trust domains,
+// so service mesh identities can take part in OAuth flows, see [WithSPIFFE]:
+//
+//   - as client_assertion of type [oidc.ClientAssertionTypeJWTSPIFFE]
+//     by clients registered with the auth method [oidc.AuthMethodSPIFFEJWT]
+//   - as subject_token of the token exchange grant, if MapSubject is set.
+type SPIFFEConfig struct {
+	TrustDomains []SPIFFETrustDomain
+	// Audience are the accepted aud values of JWT-SVIDs.
+	// Defaults to the issuer of the Provider.
+	Audience []string
+	// SigningAlgorithms are the accepted algorithms of JWT-SVIDs.
+	// Defaults to RS256, ES256 and PS256.
+	SigningAlgorithms []string
+	// Offset is the allowed clock skew for exp.
+	Offset time.Duration
+	// ClientID maps the SPIFFE ID of a client assertion to the client_id
+	// of the client. Defaults to the SPIFFE ID itself.
+	ClientID func(ctx context.Context, id oidc.SPIFFEID) (string, error)
+	// MapSubject maps the SPIFFE ID of a subject_token to the local subject.
+	// JWT-SVIDs are only accepted as subject_token, if set.
+	MapSubject func(ctx context.Context, id oidc.SPIFFEID, claims map[string]any) (string, error)
+}
+
+var ErrSPIFFETrustDomain = errors.New("trust domain of the SPIFFE ID is not trusted")
+
+type spiffeGetter interface {
+	SPIFFE() *SPIFFEConfig
+}
+
+func spiffeConfig(v any) *SPIFFEConfig {
+	if getter, ok := v.(spiffeGetter); ok {
+		return getter.SPIFFE()
+	}
+	return nil
+}
+
+func (c *SPIFFEConfig) trustDomain(name string) *SPIFFETrustDomain {
+	for i := range c.TrustDomains {
+		if c.TrustDomains[i].Name == name {
+			return &c.TrustDomains[i]
+		}
+	}
+	return nil
+}
+
+// VerifyJWTSVID verifies a JWT-SVID of a workload of one of the trusted domains
+// with the keys of the trust bundle and returns its SPIFFE ID and claims.
+// The audience defaults to issuer.
+func VerifyJWTSVID(ctx context.Context, config *SPIFFEConfig, jwks *ClientJWKSCache, issuer, token string) (id oidc.SPIFFEID, claims map[string]any, err error) {
+	ctx, span := tracer.Start(ctx, "VerifyJWTSVID")
+	defer span.End()
+
+	tokenClaims := new(oidc.TokenClaims)
+	payload, err := oidc.ParseToken(token, tokenClaims)
+	if err != nil {
+		return id, nil, err
+	}
+	if id, err = oidc.ParseSPIFFEID(tokenClaims.Subject); err != nil {
+		return id, nil, err
+	}
+	trustDomain := config.trustDomain(id.TrustDomain)
+	if trustDomain == nil {
+		return id, nil, fmt.Errorf("%w: %q", ErrSPIFFETrustDomain, id.TrustDomain)
+	}
+	audience := config.Audience
+	if len(audience) == 0 {
+		audience = []string{issuer}
+	}
+	if !slices.ContainsFunc(tokenClaims.Audience, func(aud string) bool { return slices.Contains(audience, aud) }) {
+		return id, nil, fmt.Errorf("%w: audience must contain one of %q", oidc.ErrAudience, audience)
+	}
+	if err = oidc.CheckExpiration(tokenClaims, config.Offset); err != nil {
+		return id, nil, err
+	}
+	keySet := &federatedKeySet{cache: jwks, jwksURI: trustDomain.BundleEndpoint}
+	if err = oidc.CheckSignature(ctx, token, payload, tokenClaims, config.SigningAlgorithms, keySet); err != nil {
+		return id, nil, err
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return id, nil, err
+	}
+	return id, claims, nil
+}
+
+// AuthorizeSPIFFEClient authenticates the client by the JWT-SVID of the assertion.
+// The client must be registered with the auth method [oidc.AuthMethodSPIFFEJWT].
+func AuthorizeSPIFFEClient(ctx context.Context, assertion string, config *SPIFFEConfig, jwks *ClientJWKSCache, storage Storage) (Client, error) {
+	ctx, span := tracer.Start(ctx, "AuthorizeSPIFFEClient")
+	defer span.End()
+
+	if config == nil || jwks == nil {
+		return nil, oidc.ErrInvalidClient().WithDescription("auth_method spiffe_jwt not supported")
+	}
+	id, _, err := VerifyJWTSVID(ctx, config, jwks, IssuerFromContext(ctx), assertion)
+	if err != nil {
+		return nil, oidc.ErrInvalidClient().WithDescription("invalid JWT-SVID").WithParent(err)
+	}
+	clientID := id.String()
+	if config.ClientID != nil {
+		if clientID, err = config.ClientID(ctx, id); err != nil {
+			return nil, oidc.ErrInvalidClient().WithParent(err)
+		}
+	}
+	client, err := storage.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		return nil, oidc.ErrInvalidClient().WithParent(err)
+	}
+	if client.AuthMethod() != oidc.AuthMethodSPIFFEJWT {
+		return nil, oidc.ErrInvalidClient().WithDescription("spiffe_jwt not allowed for this client")
+	}
+	return client, nil
+}
+
+// authorizeSPIFFEClientAssertion authenticates the client by its JWT-SVID,
+// if the client_assertion_type is [oidc.ClientAssertionTypeJWTSPIFFE].
+func authorizeSPIFFEClientAssertion(ctx context.Context, provider any, storage Storage, assertionType, assertion string) (_ Client, ok bool, err error) {
+	if assertionType != oidc.ClientAssertionTypeJWTSPIFFE {
+		return nil, false, nil
+	}
+	client, err := AuthorizeSPIFFEClient(ctx, assertion, spiffeConfig(provider), clientJWKSCache(provider), storage)
+	return client, true, err
+}
+
+// verifySPIFFESubjectToken verifies the subject_token as JWT-SVID,
+// if the [SPIFFEConfig] of the exchanger maps subjects.
+func verifySPIFFESubjectToken(ctx context.Context, exchanger Exchanger, token string, tokenType oidc.TokenType) (tokenIDOrToken, subject string, claims map[string]any, ok bool) {
+	config := spiffeConfig(exchanger)
+	jwks := clientJWKSCache(exchanger)
+	if config == nil || config.MapSubject == nil || jwks == nil || tokenType != oidc.JWTTokenType {
+		return "", "", nil, false
+	}
+	id, claims, err := VerifyJWTSVID(ctx, config, jwks, IssuerFromContext(ctx), token)
+	if err == nil {
+		subject, err = config.MapSubject(ctx, id, claims)
+	}
+	if err != nil {
+		oidc.ExplanationFromContext(ctx).Check("spiffe", err)
+		return "", "", nil, false
+	}
+	return token, subject, claims, true
+}
+
+// spiffeBundleKeys returns the keys of a SPIFFE trust bundle for the verification
+// of JWT-SVIDs, which have the use jwt-svid instead of sig. X.509 authorities
+// of the bundle are ignored.
+func spiffeBundleKeys(keys []jose.JSONWebKey) []jose.JSONWebKey {
+	bundle := keys[:0]
+	for _, key := range keys {
+		switch key.Use {
+		case "x509-svid":
+			continue
+		case oidc.KeyUseJWTSVID:
+			key.Use = oidc.KeyUseSignature
+		}
+		bundle = append(bundle, key)
+	}
+	return bundle
+}
@@ -0,0 +1,122 @@
+package op
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type spiffeClient struct {
+	Client
+	id         string
+	authMethod oidc.AuthMethod
+}
+
+func (c *spiffeClient) GetID() string               { return c.id }
+func (c *spiffeClient) AuthMethod() oidc.AuthMethod { return c.authMethod }
+
+type spiffeStorage struct {
+	Storage
+	clients map[string]Client
+}
+
+func (s *spiffeStorage) GetClientByClientID(_ context.Context, id string) (Client, error) {
+	if client, ok := s.clients[id]; ok {
+		return client, nil
+	}
+	return nil, oidc.ErrInvalidClient()
+}
+
+func TestAuthorizeSPIFFEClient(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: key.Public(), KeyID: "1", Algorithm: "ES256", Use: oidc.KeyUseJWTSVID},
+		}})
+	}))
+	defer server.Close()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: key, KeyID: "1"}}, nil)
+	require.NoError(t, err)
+	const issuer = "https://op.example.com"
+	svid := func(sub, aud string, exp time.Time) string {
+		payload, err := json.Marshal(map[string]any{"sub": sub, "aud": aud, "exp": exp.Unix()})
+		require.NoError(t, err)
+		jws, err := signer.Sign(payload)
+		require.NoError(t, err)
+		token, err := jws.CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	config := &SPIFFEConfig{TrustDomains: []SPIFFETrustDomain{{Name: "example.org", BundleEndpoint: server.URL}}}
+	jwks := NewClientJWKSCache(server.Client())
+	storage := &spiffeStorage{clients: map[string]Client{
+		"spiffe://example.org/ns/shop/sa/checkout": &spiffeClient{id: "spiffe://example.org/ns/shop/sa/checkout", authMethod: oidc.AuthMethodSPIFFEJWT},
+		"spiffe://example.org/ns/shop/sa/cart":     &spiffeClient{id: "spiffe://example.org/ns/shop/sa/cart", authMethod: oidc.AuthMethodBasic},
+	}}
+	ctx := ContextWithIssuer(context.Background(), issuer)
+	exp := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name      string
+		assertion string
+		wantErr   bool
+	}{
+		{
+			name:      "authenticated",
+			assertion: svid("spiffe://example.org/ns/shop/sa/checkout", issuer, exp),
+		},
+		{
+			name:      "untrusted domain",
+			assertion: svid("spiffe://other.org/ns/shop/sa/checkout", issuer, exp),
+			wantErr:   true,
+		},
+		{
+			name:      "wrong audience",
+			assertion: svid("spiffe://example.org/ns/shop/sa/checkout", "https://api.example.com", exp),
+			wantErr:   true,
+		},
+		{
+			name:      "expired",
+			assertion: svid("spiffe://example.org/ns/shop/sa/checkout", issuer, time.Now().Add(-time.Minute)),
+			wantErr:   true,
+		},
+		{
+			name:      "auth method not allowed",
+			assertion: svid("spiffe://example.org/ns/shop/sa/cart", issuer, exp),
+			wantErr:   true,
+		},
+		{
+			name:      "not a SPIFFE ID",
+			assertion: svid("checkout", issuer, exp),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := AuthorizeSPIFFEClient(ctx, tt.assertion, config, jwks, storage)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrInvalidClient())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "spiffe://example.org/ns/shop/sa/checkout", client.GetID())
+		})
+	}
+
+	_, err = AuthorizeSPIFFEClient(ctx, tests[0].assertion, nil, jwks, storage)
+	assert.ErrorIs(t, err, oidc.ErrInvalidClient())
+}
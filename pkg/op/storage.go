@@ -62,6 +62,30 @@ type AuthStorage interface {
 	KeySet(context.Context) ([]Key, error)
 }
 
+// ErrCodeAlreadyRedeemed is returned by [CodeRedeemer.RedeemAuthCode]
+// if the code was redeemed before.
+var ErrCodeAlreadyRedeemed = errors.New("authorization code already redeemed")
+
+// CodeRedeemer is an optional additional interface that may be implemented by
+// implementors of Storage, to guarantee that an authorization code is redeemed
+// at most once, as required by RFC 6749, section 4.1.2, even if concurrent
+// token requests with the same code reach multiple instances of the OP.
+//
+// Without it, the code is only invalidated by DeleteAuthRequest
+// after the tokens were created, leaving a window in which a replayed
+// code is exchanged a second time.
+//
+// The package storagetest provides a conformance test for implementations.
+type CodeRedeemer interface {
+	// RedeemAuthCode is called after the validation of the token request
+	// and before creating the tokens. It must atomically mark the code as redeemed,
+	// for example by a compare-and-swap or in a transaction, so that of concurrent
+	// calls with the same code exactly one succeeds. All others must fail,
+	// preferably with ErrCodeAlreadyRedeemed. Implementations may revoke
+	// the tokens previously issued for the code on such a replay.
+	RedeemAuthCode(ctx context.Context, code string) error
+}
+
 // CanTerminateSessionFromRequest is an optional additional interface that may be implemented by
 // implementors of Storage as an alternative to TerminateSession of the AuthStorage.
 // It passes the complete parsed EndSessionRequest to the implementation, which allows access to additional data.
@@ -150,6 +174,30 @@ type CanGetPrivateClaimsFromRequest interface {
 	GetPrivateClaimsFromRequest(ctx context.Context, request TokenRequest, restrictedScopes []string) (map[string]any, error)
 }
 
+// CanSetAccessTokenClaims is an optional additional interface that may be implemented by
+// implementors of Storage. It is called after all default and private claims of a JWT access token are set,
+// right before signing. It allows customization of the access token independent of the ID token,
+// including `sub`, `scope`, `aud` and any custom (namespaced) claims.
+type CanSetAccessTokenClaims interface {
+	SetAccessTokenClaims(ctx context.Context, claims *oidc.AccessTokenClaims, request TokenRequest, clientID string) error
+}
+
+// CanValidateRevocationClient is an optional additional interface that may be implemented by
+// implementors of Storage. It verifies that the opaque access token to be revoked was issued
+// to the requesting client (RFC 7009, section 2.1), which matters especially for public clients
+// that revoke tokens without client secret. A JWT access token is verified by its client_id claim instead.
+type CanValidateRevocationClient interface {
+	ValidateRevocationClient(ctx context.Context, tokenID, subject, clientID string) error
+}
+
+// CanGetTokenClientID is an optional additional interface that may be implemented by
+// implementors of Storage with a [SubjectMapper]. It returns the ID of the client the opaque
+// access token was issued to, so the subject of the userinfo response is translated for the same
+// client as the one of the ID token. A JWT access token has its client_id claim instead.
+type CanGetTokenClientID interface {
+	GetTokenClientID(ctx context.Context, tokenID string) (string, error)
+}
+
 // Storage is a required parameter for NewOpenIDProvider(). In addition to the
 // embedded interfaces below, if the passed Storage implements ClientCredentialsStorage
 // then the grant type "client_credentials" will be supported. In that case, the access
@@ -190,6 +238,21 @@ type DeviceAuthorizationStorage interface {
 	GetDeviceAuthorizatonState(ctx context.Context, clientID, deviceCode string) (*DeviceAuthorizationState, error)
 }
 
+// DeviceAuthorizationWatcher is an optional extension of the DeviceAuthorizationStorage,
+// allowing device clients to wait for the completion of the authorization by long polling
+// or Server-Sent Events, instead of polling GetDeviceAuthorizatonState at a fixed interval.
+// See [DeviceAuthorizationConfig.MaxWait].
+//
+// [DeviceAuthorizationNotifier] implements it for storages running on a single instance.
+// Storages running on multiple instances must notify the watchers of all instances,
+// for example by a publish / subscribe mechanism of the database.
+type DeviceAuthorizationWatcher interface {
+	// WatchDeviceAuthorization returns a channel receiving a value, when the state of the
+	// device authorization changed, e.g. it was completed or denied.
+	// The watch ends, when the context is done.
+	WatchDeviceAuthorization(ctx context.Context, clientID, deviceCode string) (<-chan struct{}, error)
+}
+
 func assertDeviceStorage(s Storage) (DeviceAuthorizationStorage, error) {
 	storage, ok := s.(DeviceAuthorizationStorage)
 	if !ok {
@@ -197,3 +260,71 @@ func assertDeviceStorage(s Storage) (DeviceAuthorizationStorage, error) {
 	}
 	return storage, nil
 }
+
+// PushedAuthRequestStorage is an optional extension of the Storage,
+// storing the auth requests pushed to the Pushed Authorization Request endpoint
+// (RFC 9126), see [WithPushedAuthorizationRequests].
+type PushedAuthRequestStorage interface {
+	// StorePushedAuthRequest stores the validated auth request under the request_uri,
+	// until it expires. Implementers must purge expired auth requests after some time.
+	StorePushedAuthRequest(ctx context.Context, requestURI string, authReq *oidc.AuthRequest, expires time.Time) error
+
+	// ConsumePushedAuthRequest returns the auth request stored under the request_uri,
+	// with its expiry, and deletes it, so that a request_uri can only be used once.
+	ConsumePushedAuthRequest(ctx context.Context, requestURI string) (authReq *oidc.AuthRequest, expires time.Time, err error)
+
+	// PushedAuthRequest returns the auth request stored under the request_uri,
+	// with its expiry, without deleting it, see [ValidateAuthRequestParams].
+	PushedAuthRequest(ctx context.Context, requestURI string) (authReq *oidc.AuthRequest, expires time.Time, err error)
+}
+
+// BackchannelAuthenticationStorage is an optional extension of the Storage,
+// storing the requests of the Client-Initiated Backchannel Authentication Flow
+// (CIBA), see [WithBackchannelAuthentication].
+type BackchannelAuthenticationStorage interface {
+	// StoreBackchannelAuthentication stores the validated request of the client under the auth_req_id,
+	// until it expires, and starts the authentication of the user on its authentication device.
+	// The user is identified by the login_hint or login_hint_token of the request, or by the subject
+	// of the verified id_token_hint, if not empty. Unknown users should be reported by
+	// [oidc.ErrUnknownUserID], other returned [oidc.Error] are passed to the client as well.
+	// Implementers must purge expired requests after some time.
+	StoreBackchannelAuthentication(ctx context.Context, authReqID string, req *oidc.BackchannelAuthenticationRequest, idTokenHintSubject string, expires time.Time) error
+
+	// GetBackchannelAuthenticationState returns the current state of the authentication of the auth_req_id.
+	// The method is polled until the authentication is either Done, Expired or Denied.
+	// As the tokens may only be issued once, the state should be deleted after it was returned as Done.
+	// An [oidc.ErrSlowDown] can be returned to clients polling too fast.
+	GetBackchannelAuthenticationState(ctx context.Context, clientID, authReqID string) (*BackchannelAuthenticationState, error)
+}
+
+// SessionTransferStorage is an optional extension of the Storage,
+// validating the session transfers of [WithSessionTransfer].
+type SessionTransferStorage interface {
+	// ValidateSessionTransfer is called before a transfer token is issued, e.g. to check
+	// the session of the user is still active or to restrict the Scopes of the transfer.
+	// Returned [oidc.Error] are passed to the client.
+	ValidateSessionTransfer(ctx context.Context, transfer *SessionTransfer) error
+}
+
+// ClientRegistrar is an optional extension of the Storage, storing the clients of the
+// Dynamic Client Registration (RFC 7591) and Management (RFC 7592), see [WithClientRegistration].
+// The registered clients must be returned by GetClientByClientID of the Storage
+// and their client_secret verified by AuthorizeClientIDSecret.
+type ClientRegistrar interface {
+	// CreateRegisteredClient stores the client registered with the validated metadata.
+	// The client_id, client_secret and registration_access_token are issued by the provider,
+	// implementers should only store hashes of the secrets.
+	// Returned [oidc.Error], e.g. of a policy rejecting the metadata, are passed to the client.
+	CreateRegisteredClient(ctx context.Context, client *RegisteredClient) error
+
+	// GetRegisteredClient returns the registered client, if the registration access token
+	// was issued for it. The ClientSecret and RegistrationAccessToken may be left empty.
+	GetRegisteredClient(ctx context.Context, clientID, registrationAccessToken string) (*RegisteredClient, error)
+
+	// UpdateRegisteredClient replaces the metadata of the registered client.
+	UpdateRegisteredClient(ctx context.Context, clientID string, metadata *oidc.ClientMetadata) error
+
+	// DeleteRegisteredClient deletes the registered client,
+	// which invalidates its client_secret and registration access token.
+	DeleteRegisteredClient(ctx context.Context, clientID string) error
+}
@@ -0,0 +1,162 @@
+package storagetest
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// ClaimsSource is the response of the provider, which returned the claims.
+type ClaimsSource string
+
+const (
+	SourceIDToken       ClaimsSource = "id_token"
+	SourceUserinfo      ClaimsSource = "userinfo"
+	SourceIntrospection ClaimsSource = "introspection"
+)
+
+// Claims returned by the provider for the same token. Nil responses are not compared.
+type Claims struct {
+	IDToken       *oidc.IDTokenClaims
+	Userinfo      *oidc.UserInfo
+	Introspection *oidc.IntrospectionResponse
+}
+
+// ClaimDiff is a claim with different values in two responses.
+// A nil value means the claim is missing.
+type ClaimDiff struct {
+	Claim       string
+	Source      ClaimsSource
+	Value       any
+	OtherSource ClaimsSource
+	OtherValue  any
+}
+
+func (d ClaimDiff) String() string {
+	return fmt.Sprintf("%s: %s has %v, %s has %v", d.Claim, d.Source, d.Value, d.OtherSource, d.OtherValue)
+}
+
+// protocolClaims describe the token or the response rather than the end-user,
+// they are expected to differ between the responses.
+var protocolClaims = []string{
+	"iss", "aud", "exp", "iat", "nbf", "jti", "nonce", "at_hash", "c_hash",
+	"auth_time", "acr", "amr", "azp", "sid", "act", "may_act", "cnf",
+	"active", "client_id", "scope", "token_type", "username",
+}
+
+// DiffClaims compares the sub and the user claims returned by multiple responses.
+// The sub must be returned by all responses, other claims are compared if returned
+// by more than one. Claims describing the token, like iss, aud or exp, are ignored.
+// Inactive introspection responses have no claims. The differences are sorted by claim.
+func DiffClaims(claims Claims) ([]ClaimDiff, error) {
+	type source struct {
+		name   ClaimsSource
+		claims map[string]any
+	}
+	var sources []source
+	for _, response := range []struct {
+		name  ClaimsSource
+		value any
+		ok    bool
+	}{
+		{SourceIDToken, claims.IDToken, claims.IDToken != nil},
+		{SourceUserinfo, claims.Userinfo, claims.Userinfo != nil},
+		{SourceIntrospection, claims.Introspection, claims.Introspection != nil},
+	} {
+		if !response.ok {
+			continue
+		}
+		values := make(map[string]any)
+		if response.name != SourceIntrospection || claims.Introspection.Active {
+			data, err := json.Marshal(response.value)
+			if err != nil {
+				return nil, fmt.Errorf("storagetest: %s: %w", response.name, err)
+			}
+			if err = json.Unmarshal(data, &values); err != nil {
+				return nil, fmt.Errorf("storagetest: %s: %w", response.name, err)
+			}
+		}
+		for _, claim := range protocolClaims {
+			delete(values, claim)
+		}
+		sources = append(sources, source{name: response.name, claims: values})
+	}
+
+	var diffs []ClaimDiff
+	for i, a := range sources {
+		for _, b := range sources[i+1:] {
+			for claim, value := range a.claims {
+				other, ok := b.claims[claim]
+				if ok && !reflect.DeepEqual(value, other) {
+					diffs = append(diffs, ClaimDiff{Claim: claim, Source: a.name, Value: value, OtherSource: b.name, OtherValue: other})
+				}
+			}
+			if a.claims["sub"] == nil || b.claims["sub"] == nil {
+				if a.claims["sub"] != b.claims["sub"] {
+					diffs = append(diffs, ClaimDiff{Claim: "sub", Source: a.name, Value: a.claims["sub"], OtherSource: b.name, OtherValue: b.claims["sub"]})
+				}
+			}
+		}
+	}
+	slices.SortFunc(diffs, func(a, b ClaimDiff) int {
+		return cmp.Or(cmp.Compare(a.Claim, b.Claim), cmp.Compare(a.Source, b.Source), cmp.Compare(a.OtherSource, b.OtherSource))
+	})
+	return diffs, nil
+}
+
+// AssertConsistentClaims asserts the claims have no differences, see [DiffClaims].
+func AssertConsistentClaims(t *testing.T, claims Claims) bool {
+	t.Helper()
+	diffs, err := DiffClaims(claims)
+	if !assert.NoError(t, err) {
+		return false
+	}
+	for _, diff := range diffs {
+		assert.Fail(t, "inconsistent claim", diff.String())
+	}
+	return len(diffs) == 0
+}
+
+// testClaimsConsistency compares the claims of the ID token, built from
+// SetUserinfoFromScopes and SetUserinfoFromRequest, with the claims of
+// SetUserinfoFromToken and SetIntrospectionFromToken for the same authorization.
+func testClaimsConsistency(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	created := setup.authRequest(t, storage, oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail, oidc.ScopePhone, oidc.ScopeAddress)
+	authReq, err := storage.AuthRequestByID(ctx, created.GetID())
+	require.NoError(t, err, "AuthRequestByID")
+	client, err := storage.GetClientByClientID(ctx, setup.ClientID)
+	require.NoError(t, err, "GetClientByClientID")
+
+	idToken, err := op.CreateIDToken(ctx, "https://storagetest.example.com", authReq, time.Hour, "", "", storage, client)
+	require.NoError(t, err, "CreateIDToken")
+	claims := Claims{
+		IDToken:       new(oidc.IDTokenClaims),
+		Userinfo:      new(oidc.UserInfo),
+		Introspection: new(oidc.IntrospectionResponse),
+	}
+	_, err = oidc.ParseToken(idToken, claims.IDToken)
+	require.NoError(t, err, "ParseToken")
+
+	tokenID, _, err := storage.CreateAccessToken(ctx, authReq)
+	require.NoError(t, err, "CreateAccessToken")
+	require.NoError(t, storage.SetUserinfoFromToken(ctx, claims.Userinfo, tokenID, setup.UserID, ""), "SetUserinfoFromToken")
+	require.NoError(t, storage.SetIntrospectionFromToken(ctx, claims.Introspection, tokenID, setup.UserID, setup.ClientID), "SetIntrospectionFromToken")
+	// the provider sets active for introspection responses without error
+	claims.Introspection.SetActive(true)
+
+	assert.Equal(t, setup.UserID, claims.IDToken.Subject, "sub of the ID token")
+	AssertConsistentClaims(t, claims)
+}
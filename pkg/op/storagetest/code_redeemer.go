@@ -0,0 +1,125 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// CodeRedeemerSetup provides the storages under test to [TestCodeRedeemer].
+type CodeRedeemerSetup struct {
+	// Instance returns the storage of an OP instance.
+	// All instances must share the same backend, e.g. the same database,
+	// to simulate concurrent token requests against multiple OP instances.
+	Instance func(t *testing.T) op.CodeRedeemer
+	// NewCode creates an authorization request with a saved, not yet redeemed code.
+	NewCode func(t *testing.T) string
+	// Instances is the number of OP instances, defaults to 3.
+	Instances int
+	// Concurrency is the number of concurrent redemptions per code, defaults to 32.
+	Concurrency int
+	// Rounds is the number of codes redeemed concurrently, defaults to 10.
+	Rounds int
+}
+
+func (s CodeRedeemerSetup) instances(t *testing.T) []op.CodeRedeemer {
+	n := s.Instances
+	if n <= 0 {
+		n = 3
+	}
+	instances := make([]op.CodeRedeemer, n)
+	for i := range instances {
+		instances[i] = s.Instance(t)
+	}
+	return instances
+}
+
+// TestCodeRedeemer verifies the contract of [op.CodeRedeemer]:
+// a code is redeemed exactly once, also under concurrent redemptions
+// against multiple instances, and all other redemptions fail.
+func TestCodeRedeemer(t *testing.T, setup CodeRedeemerSetup) {
+	ctx := context.Background()
+
+	t.Run("redeem once", func(t *testing.T) {
+		storage := setup.Instance(t)
+		code := setup.NewCode(t)
+		require.NoError(t, storage.RedeemAuthCode(ctx, code))
+
+		err := storage.RedeemAuthCode(ctx, code)
+		require.Error(t, err, "second redemption")
+	})
+
+	t.Run("redeem on other instance", func(t *testing.T) {
+		instances := setup.instances(t)
+		code := setup.NewCode(t)
+		require.NoError(t, instances[0].RedeemAuthCode(ctx, code))
+		for i, storage := range instances {
+			assert.Error(t, storage.RedeemAuthCode(ctx, code), "instance %d", i)
+		}
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		storage := setup.Instance(t)
+		assert.Error(t, storage.RedeemAuthCode(ctx, "unknown"))
+	})
+
+	t.Run("concurrent redemption", func(t *testing.T) {
+		instances := setup.instances(t)
+		concurrency, rounds := setup.Concurrency, setup.Rounds
+		if concurrency <= 0 {
+			concurrency = 32
+		}
+		if rounds <= 0 {
+			rounds = 10
+		}
+		for round := 0; round < rounds; round++ {
+			code := setup.NewCode(t)
+			errs := redeemConcurrently(ctx, instances, code, concurrency)
+
+			var succeeded int
+			for _, err := range errs {
+				if err == nil {
+					succeeded++
+				}
+			}
+			require.Equal(t, 1, succeeded, "round %d: successful redemptions of code %q", round, code)
+		}
+	})
+
+	t.Run("already redeemed error", func(t *testing.T) {
+		storage := setup.Instance(t)
+		code := setup.NewCode(t)
+		require.NoError(t, storage.RedeemAuthCode(ctx, code))
+		if err := storage.RedeemAuthCode(ctx, code); !errors.Is(err, op.ErrCodeAlreadyRedeemed) {
+			t.Logf("recommended: return op.ErrCodeAlreadyRedeemed on replay, got %v", err)
+		}
+	})
+}
+
+// redeemConcurrently releases all redemptions of the code at once
+// and returns their errors.
+func redeemConcurrently(ctx context.Context, instances []op.CodeRedeemer, code string, concurrency int) []error {
+	var (
+		start sync.WaitGroup
+		done  sync.WaitGroup
+		errs  = make([]error, concurrency)
+	)
+	start.Add(1)
+	done.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func(i int) {
+			defer done.Done()
+			start.Wait()
+			errs[i] = instances[i%len(instances)].RedeemAuthCode(ctx, code)
+		}(i)
+	}
+	start.Done()
+	done.Wait()
+	return errs
+}
@@ -0,0 +1,441 @@
+// Package storagetest provides conformance tests for implementations
+// of the Storage interface of package op and its optional extensions,
+// so custom backends can verify they meet the expectations of the provider.
+//
+// The tests are run from the tests of the implementation:
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.TestStorage(t, storagetest.Setup{
+//			Storage:     func(t *testing.T) op.Storage { return newStorage(t, db) },
+//			ClientID:    "web",
+//			RedirectURI: "https://example.com/callback",
+//			UserID:      "user",
+//		})
+//	}
+//
+// [DiffClaims] compares the claims returned by a deployment for the same token,
+// e.g. by the token, userinfo and introspection endpoints, to diagnose inconsistencies.
+package storagetest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// Setup provides the storage under test and its fixtures to [TestStorage].
+type Setup struct {
+	// Storage returns the storage under test. It may be called multiple times
+	// and must return storages sharing the same backend.
+	Storage func(t *testing.T) op.Storage
+
+	// ClientID of a registered client, allowed to use the
+	// authorization_code and refresh_token grants.
+	ClientID string
+	// RedirectURI registered for the client.
+	RedirectURI string
+	// UserID of a registered user.
+	UserID string
+
+	// CompleteAuthRequest marks the auth request as authenticated by the user,
+	// as done by the login UI of the provider. Optional.
+	CompleteAuthRequest func(t *testing.T, storage op.Storage, id string)
+
+	// DeviceClientID of a client allowed to use the device_code grant.
+	// The device flow is tested, if set and the storage implements
+	// [op.DeviceAuthorizationStorage].
+	DeviceClientID string
+	// CompleteDeviceAuthorization approves the device authorization
+	// as the user, as done by the user form of the provider. Optional.
+	CompleteDeviceAuthorization func(t *testing.T, storage op.Storage, userCode, subject string)
+	// DenyDeviceAuthorization denies the device authorization
+	// as the user, as done by the user form of the provider. Optional.
+	DenyDeviceAuthorization func(t *testing.T, storage op.Storage, userCode string)
+}
+
+// TestStorage runs the conformance tests for the Storage:
+//   - auth requests are retrievable by id and code, and codes are single-use
+//   - access and refresh tokens are created with a future expiration
+//   - revoked tokens and terminated sessions are no longer accepted
+//   - the signing key is part of the key set
+//   - the ID token, userinfo and introspection return consistent claims, see [DiffClaims]
+//   - the states of the device flow, if supported
+//   - the results of authentications, kept for refresh tokens, if supported
+//
+// If the storage implements [op.CodeRedeemer], [TestCodeRedeemer] is run as well.
+func TestStorage(t *testing.T, setup Setup) {
+	t.Run("client", func(t *testing.T) { testClient(t, setup) })
+	t.Run("auth request", func(t *testing.T) { testAuthRequest(t, setup) })
+	t.Run("code single-use", func(t *testing.T) { testCodeSingleUse(t, setup) })
+	t.Run("access token revocation", func(t *testing.T) { testAccessTokenRevocation(t, setup) })
+	t.Run("refresh token", func(t *testing.T) { testRefreshToken(t, setup) })
+	t.Run("refresh token revocation", func(t *testing.T) { testRefreshTokenRevocation(t, setup) })
+	t.Run("terminate session", func(t *testing.T) { testTerminateSession(t, setup) })
+	t.Run("keys", func(t *testing.T) { testKeys(t, setup) })
+	t.Run("claims consistency", func(t *testing.T) { testClaimsConsistency(t, setup) })
+
+	if _, ok := setup.Storage(t).(op.CodeRedeemer); ok {
+		t.Run("code redeemer", func(t *testing.T) {
+			TestCodeRedeemer(t, CodeRedeemerSetup{
+				Instance: func(t *testing.T) op.CodeRedeemer {
+					return setup.Storage(t).(op.CodeRedeemer)
+				},
+				NewCode: func(t *testing.T) string {
+					storage := setup.Storage(t)
+					code := uuid.NewString()
+					authReq := setup.authRequest(t, storage)
+					require.NoError(t, storage.SaveAuthCode(context.Background(), authReq.GetID(), code))
+					return code
+				},
+			})
+		})
+	}
+	if _, ok := setup.Storage(t).(op.AuthenticationStorage); ok {
+		t.Run("authentication", func(t *testing.T) { testAuthentication(t, setup) })
+	}
+	if _, ok := setup.Storage(t).(op.DeviceAuthorizationStorage); ok && setup.DeviceClientID != "" {
+		t.Run("device authorization", func(t *testing.T) { testDeviceAuthorization(t, setup) })
+	}
+}
+
+// authRequest creates a new auth request for the client and user,
+// completed by CompleteAuthRequest. The scopes default to openid and offline_access.
+func (s Setup) authRequest(t *testing.T, storage op.Storage, scopes ...string) op.AuthRequest {
+	t.Helper()
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, oidc.ScopeOfflineAccess}
+	}
+	authReq, err := storage.CreateAuthRequest(context.Background(), &oidc.AuthRequest{
+		Scopes:       scopes,
+		ResponseType: oidc.ResponseTypeCode,
+		ClientID:     s.ClientID,
+		RedirectURI:  s.RedirectURI,
+		State:        "state",
+		Nonce:        "nonce",
+	}, s.UserID)
+	require.NoError(t, err, "CreateAuthRequest")
+	require.NotEmpty(t, authReq.GetID(), "auth request id")
+	if s.CompleteAuthRequest != nil {
+		s.CompleteAuthRequest(t, storage, authReq.GetID())
+	}
+	return authReq
+}
+
+func testClient(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+
+	client, err := storage.GetClientByClientID(ctx, setup.ClientID)
+	require.NoError(t, err)
+	assert.Equal(t, setup.ClientID, client.GetID())
+	assert.Contains(t, client.RedirectURIs(), setup.RedirectURI)
+
+	_, err = storage.GetClientByClientID(ctx, uuid.NewString())
+	assert.Error(t, err, "unknown client")
+}
+
+func testAuthRequest(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	authReq := setup.authRequest(t, storage)
+
+	got, err := setup.Storage(t).AuthRequestByID(ctx, authReq.GetID())
+	require.NoError(t, err, "AuthRequestByID")
+	assert.Equal(t, authReq.GetID(), got.GetID())
+	assert.Equal(t, setup.ClientID, got.GetClientID())
+	assert.Equal(t, setup.RedirectURI, got.GetRedirectURI())
+	assert.Equal(t, "state", got.GetState())
+	assert.Equal(t, "nonce", got.GetNonce())
+	assert.ElementsMatch(t, []string{oidc.ScopeOpenID, oidc.ScopeOfflineAccess}, got.GetScopes())
+
+	_, err = storage.AuthRequestByID(ctx, uuid.NewString())
+	assert.Error(t, err, "unknown auth request")
+}
+
+func testCodeSingleUse(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	authReq := setup.authRequest(t, storage)
+	code := uuid.NewString()
+	require.NoError(t, storage.SaveAuthCode(ctx, authReq.GetID(), code))
+
+	got, err := setup.Storage(t).AuthRequestByCode(ctx, code)
+	require.NoError(t, err, "AuthRequestByCode")
+	assert.Equal(t, authReq.GetID(), got.GetID())
+
+	// called by the provider after creating the token response
+	require.NoError(t, storage.DeleteAuthRequest(ctx, authReq.GetID()))
+	_, err = setup.Storage(t).AuthRequestByCode(ctx, code)
+	assert.Error(t, err, "AuthRequestByCode after DeleteAuthRequest")
+	_, err = setup.Storage(t).AuthRequestByID(ctx, authReq.GetID())
+	assert.Error(t, err, "AuthRequestByID after DeleteAuthRequest")
+
+	_, err = storage.AuthRequestByCode(ctx, uuid.NewString())
+	assert.Error(t, err, "unknown code")
+}
+
+// accessToken creates an access token for a new auth request
+// and asserts it is accepted by the userinfo and introspection.
+func (s Setup) accessToken(t *testing.T, storage op.Storage) (tokenID string) {
+	t.Helper()
+	ctx := context.Background()
+	authReq := s.authRequest(t, storage)
+	tokenID, expiration, err := storage.CreateAccessToken(ctx, authReq)
+	require.NoError(t, err, "CreateAccessToken")
+	require.NotEmpty(t, tokenID)
+	assert.True(t, expiration.After(time.Now()), "expiration %v is not in the future", expiration)
+	require.NoError(t, storage.SetUserinfoFromToken(ctx, new(oidc.UserInfo), tokenID, s.UserID, ""), "SetUserinfoFromToken")
+	require.NoError(t, storage.SetIntrospectionFromToken(ctx, new(oidc.IntrospectionResponse), tokenID, s.UserID, s.ClientID), "SetIntrospectionFromToken")
+	return tokenID
+}
+
+func testAccessTokenRevocation(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	tokenID := setup.accessToken(t, storage)
+
+	if err := storage.RevokeToken(ctx, tokenID, setup.UserID, setup.ClientID); err != nil {
+		require.NoError(t, err, "RevokeToken")
+	}
+	other := setup.Storage(t)
+	assert.Error(t, other.SetUserinfoFromToken(ctx, new(oidc.UserInfo), tokenID, setup.UserID, ""), "SetUserinfoFromToken after RevokeToken")
+	assert.Error(t, other.SetIntrospectionFromToken(ctx, new(oidc.IntrospectionResponse), tokenID, setup.UserID, setup.ClientID), "SetIntrospectionFromToken after RevokeToken")
+}
+
+// refreshToken creates an access and refresh token for a new auth request.
+func (s Setup) refreshToken(t *testing.T, storage op.Storage) (tokenID, refreshToken string) {
+	t.Helper()
+	authReq := s.authRequest(t, storage)
+	tokenID, refreshToken, expiration, err := storage.CreateAccessAndRefreshTokens(context.Background(), authReq, "")
+	require.NoError(t, err, "CreateAccessAndRefreshTokens")
+	require.NotEmpty(t, tokenID)
+	require.NotEmpty(t, refreshToken)
+	assert.True(t, expiration.After(time.Now()), "expiration %v is not in the future", expiration)
+	return tokenID, refreshToken
+}
+
+func testRefreshToken(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	_, refreshToken := setup.refreshToken(t, storage)
+
+	request, err := setup.Storage(t).TokenRequestByRefreshToken(ctx, refreshToken)
+	require.NoError(t, err, "TokenRequestByRefreshToken")
+	assert.Equal(t, setup.UserID, request.GetSubject())
+	assert.Equal(t, setup.ClientID, request.GetClientID())
+	assert.Contains(t, request.GetScopes(), oidc.ScopeOfflineAccess)
+
+	userID, _, err := storage.GetRefreshTokenInfo(ctx, setup.ClientID, refreshToken)
+	require.NoError(t, err, "GetRefreshTokenInfo")
+	assert.Equal(t, setup.UserID, userID)
+	_, _, err = storage.GetRefreshTokenInfo(ctx, setup.ClientID, uuid.NewString())
+	assert.ErrorIs(t, err, op.ErrInvalidRefreshToken, "GetRefreshTokenInfo of unknown token")
+
+	tokenID, newRefreshToken, expiration, err := storage.CreateAccessAndRefreshTokens(ctx, request, refreshToken)
+	require.NoError(t, err, "CreateAccessAndRefreshTokens with refresh token")
+	assert.NotEmpty(t, tokenID)
+	assert.NotEmpty(t, newRefreshToken)
+	assert.True(t, expiration.After(time.Now()), "expiration %v is not in the future", expiration)
+	_, err = setup.Storage(t).TokenRequestByRefreshToken(ctx, newRefreshToken)
+	assert.NoError(t, err, "TokenRequestByRefreshToken of the new refresh token")
+
+	_, err = storage.TokenRequestByRefreshToken(ctx, uuid.NewString())
+	assert.Error(t, err, "unknown refresh token")
+}
+
+func testRefreshTokenRevocation(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	_, refreshToken := setup.refreshToken(t, storage)
+
+	if err := storage.RevokeToken(ctx, refreshToken, "", setup.ClientID); err != nil {
+		require.NoError(t, err, "RevokeToken")
+	}
+	_, err := setup.Storage(t).TokenRequestByRefreshToken(ctx, refreshToken)
+	assert.Error(t, err, "TokenRequestByRefreshToken after RevokeToken")
+}
+
+func testTerminateSession(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	tokenID, refreshToken := setup.refreshToken(t, storage)
+
+	require.NoError(t, storage.TerminateSession(ctx, setup.UserID, setup.ClientID))
+	other := setup.Storage(t)
+	assert.Error(t, other.SetUserinfoFromToken(ctx, new(oidc.UserInfo), tokenID, setup.UserID, ""), "SetUserinfoFromToken after TerminateSession")
+	_, err := other.TokenRequestByRefreshToken(ctx, refreshToken)
+	assert.Error(t, err, "TokenRequestByRefreshToken after TerminateSession")
+}
+
+func testKeys(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+
+	signingKey, err := storage.SigningKey(ctx)
+	require.NoError(t, err, "SigningKey")
+	require.NotNil(t, signingKey.Key())
+
+	algorithms, err := storage.SignatureAlgorithms(ctx)
+	require.NoError(t, err, "SignatureAlgorithms")
+	assert.Contains(t, algorithms, signingKey.SignatureAlgorithm())
+
+	signer, err := op.SignerFromKey(signingKey)
+	require.NoError(t, err, "SignerFromKey")
+	jws, err := signer.Sign([]byte("payload"))
+	require.NoError(t, err)
+
+	keys, err := storage.KeySet(ctx)
+	require.NoError(t, err, "KeySet")
+	i := slices.IndexFunc(keys, func(key op.Key) bool {
+		return key.ID() == signingKey.ID()
+	})
+	require.GreaterOrEqual(t, i, 0, "signing key %q is not part of the key set", signingKey.ID())
+	key := keys[i]
+	assert.Equal(t, signingKey.SignatureAlgorithm(), key.Algorithm())
+	assert.Contains(t, []string{"", "sig"}, key.Use())
+	assert.True(t, (&jose.JSONWebKey{Key: key.Key()}).IsPublic(), "key set must only contain public keys")
+	_, err = jws.Verify(key.Key())
+	assert.NoError(t, err, "signature of the signing key is not verifiable by the key set")
+}
+
+func testAuthentication(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t)
+	authReq, err := storage.CreateAuthRequest(ctx, &oidc.AuthRequest{
+		Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID, oidc.ScopeOfflineAccess},
+		ResponseType: oidc.ResponseTypeCode,
+		ClientID:     setup.ClientID,
+		RedirectURI:  setup.RedirectURI,
+	}, "")
+	require.NoError(t, err, "CreateAuthRequest")
+
+	authTime := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	methods := []string{oidc.AMRPassword, oidc.AMROneTimePassword}
+	require.NoError(t, op.CompleteAuthentication(ctx, storage, authReq.GetID(), op.AuthenticationResult{
+		Subject: setup.UserID,
+		Methods: methods,
+		Time:    authTime,
+		ACR:     "urn:example:loa:2",
+	}), "CompleteAuthentication")
+
+	got, err := setup.Storage(t).AuthRequestByID(ctx, authReq.GetID())
+	require.NoError(t, err, "AuthRequestByID")
+	assert.True(t, got.Done(), "auth request done")
+	assert.Equal(t, setup.UserID, got.GetSubject())
+	assert.Equal(t, methods, got.GetAMR())
+	assert.True(t, authTime.Equal(got.GetAuthTime()), "auth time %v, expected %v", got.GetAuthTime(), authTime)
+	assert.Equal(t, "urn:example:loa:2", got.GetACR())
+
+	_, refreshToken, _, err := storage.CreateAccessAndRefreshTokens(ctx, got, "")
+	require.NoError(t, err, "CreateAccessAndRefreshTokens")
+	request, err := setup.Storage(t).TokenRequestByRefreshToken(ctx, refreshToken)
+	require.NoError(t, err, "TokenRequestByRefreshToken")
+	assert.Equal(t, methods, request.GetAMR(), "amr of the refresh token")
+	assert.True(t, authTime.Equal(request.GetAuthTime()), "auth time of the refresh token %v, expected %v", request.GetAuthTime(), authTime)
+	if acrRequest, ok := request.(interface{ GetACR() string }); ok {
+		assert.Equal(t, "urn:example:loa:2", acrRequest.GetACR(), "acr of the refresh token")
+	}
+}
+
+func testDeviceAuthorization(t *testing.T, setup Setup) {
+	ctx := context.Background()
+	storage := setup.Storage(t).(op.DeviceAuthorizationStorage)
+	scopes := []string{oidc.ScopeOpenID}
+
+	store := func(t *testing.T, expires time.Time) (deviceCode, userCode string) {
+		deviceCode, userCode = uuid.NewString(), uuid.NewString()
+		err := storage.StoreDeviceAuthorization(ctx, setup.DeviceClientID, deviceCode, userCode, expires, scopes)
+		require.NoError(t, err, "StoreDeviceAuthorization")
+		return deviceCode, userCode
+	}
+	state := func(t *testing.T, deviceCode string) *op.DeviceAuthorizationState {
+		state, err := setup.Storage(t).(op.DeviceAuthorizationStorage).GetDeviceAuthorizatonState(ctx, setup.DeviceClientID, deviceCode)
+		require.NoError(t, err, "GetDeviceAuthorizatonState")
+		return state
+	}
+
+	t.Run("pending", func(t *testing.T) {
+		expires := time.Now().Add(time.Minute)
+		deviceCode, _ := store(t, expires)
+		got := state(t, deviceCode)
+		assert.Equal(t, setup.DeviceClientID, got.ClientID)
+		assert.Equal(t, scopes, got.Scopes)
+		assert.WithinDuration(t, expires, got.Expires, time.Second)
+		assert.False(t, got.Done)
+		assert.False(t, got.Denied)
+
+		_, err := storage.GetDeviceAuthorizatonState(ctx, uuid.NewString(), deviceCode)
+		assert.Error(t, err, "device code of other client")
+		_, err = storage.GetDeviceAuthorizatonState(ctx, setup.DeviceClientID, uuid.NewString())
+		assert.Error(t, err, "unknown device code")
+	})
+	t.Run("duplicate user code", func(t *testing.T) {
+		_, userCode := store(t, time.Now().Add(time.Minute))
+		err := storage.StoreDeviceAuthorization(ctx, setup.DeviceClientID, uuid.NewString(), userCode, time.Now().Add(time.Minute), scopes)
+		assert.ErrorIs(t, err, op.ErrDuplicateUserCode)
+	})
+	t.Run("expired", func(t *testing.T) {
+		deviceCode, _ := store(t, time.Now().Add(-time.Minute))
+		got := state(t, deviceCode)
+		assert.True(t, got.Expires.Before(time.Now()), "expires %v is not in the past", got.Expires)
+		assert.False(t, got.Done)
+	})
+	if setup.CompleteDeviceAuthorization != nil {
+		t.Run("completed", func(t *testing.T) {
+			deviceCode, userCode := store(t, time.Now().Add(time.Minute))
+			changed := watchDeviceAuthorization(t, setup.Storage(t), setup.DeviceClientID, deviceCode)
+			setup.CompleteDeviceAuthorization(t, setup.Storage(t), userCode, setup.UserID)
+			got := state(t, deviceCode)
+			assert.True(t, got.Done)
+			assert.False(t, got.Denied)
+			assert.Equal(t, setup.UserID, got.Subject)
+			assertChanged(t, changed)
+		})
+	}
+	if setup.DenyDeviceAuthorization != nil {
+		t.Run("denied", func(t *testing.T) {
+			deviceCode, userCode := store(t, time.Now().Add(time.Minute))
+			changed := watchDeviceAuthorization(t, setup.Storage(t), setup.DeviceClientID, deviceCode)
+			setup.DenyDeviceAuthorization(t, setup.Storage(t), userCode)
+			got := state(t, deviceCode)
+			assert.True(t, got.Denied)
+			assert.False(t, got.Done)
+			assertChanged(t, changed)
+		})
+	}
+}
+
+// watchDeviceAuthorization watches the device authorization,
+// if the storage implements [op.DeviceAuthorizationWatcher].
+func watchDeviceAuthorization(t *testing.T, storage op.Storage, clientID, deviceCode string) <-chan struct{} {
+	watcher, ok := storage.(op.DeviceAuthorizationWatcher)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	changed, err := watcher.WatchDeviceAuthorization(ctx, clientID, deviceCode)
+	require.NoError(t, err, "WatchDeviceAuthorization")
+	return changed
+}
+
+func assertChanged(t *testing.T, changed <-chan struct{}) {
+	t.Helper()
+	if changed == nil {
+		return
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "watcher not notified about the change of the device authorization")
+	}
+}
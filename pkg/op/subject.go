@@ -0,0 +1,275 @@
+package op
+
+import (
+	"container/list"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/lmindwarel/oidc/v3/internal/security"
+)
+
+// SubjectMapper is an optional additional interface that may be implemented by
+// implementors of Storage. It translates between the internal user ID,
+// as used throughout the Storage interfaces, and the `sub` exposed to clients.
+//
+// When implemented, the subject is translated consistently in ID tokens,
+// JWT access tokens, userinfo and introspection responses.
+// The subject of an id_token_hint and of JWT access tokens is translated back to the user ID.
+//
+// The clientID may be empty if it is unknown, for example on introspection
+// of opaque access tokens. On userinfo requests with opaque access tokens,
+// it is resolved with [CanGetTokenClientID].
+type SubjectMapper interface {
+	ExternalSubject(ctx context.Context, userID, clientID string) (string, error)
+	InternalSubject(ctx context.Context, subject, clientID string) (string, error)
+}
+
+// ErrUnknownSubject is returned by a SubjectMapper if
+// the subject can't be translated into a user ID.
+var ErrUnknownSubject = errors.New("unknown subject")
+
+// ErrUnknownTokenClient is returned on userinfo requests with opaque access tokens,
+// if the Storage implements [SubjectMapper] but not [CanGetTokenClientID].
+var ErrUnknownTokenClient = errors.New("unknown client of the access token")
+
+func externalSubject(ctx context.Context, storage any, userID, clientID string) (string, error) {
+	if mapper, ok := storage.(SubjectMapper); ok && userID != "" {
+		return mapper.ExternalSubject(ctx, userID, clientID)
+	}
+	return userID, nil
+}
+
+// tokenClientID returns the client ID of the opaque access token
+// for the translation of its subject, if the storage implements [SubjectMapper].
+func tokenClientID(ctx context.Context, storage any, tokenID string) (string, error) {
+	if _, ok := storage.(SubjectMapper); !ok {
+		return "", nil
+	}
+	getter, ok := storage.(CanGetTokenClientID)
+	if !ok {
+		return "", ErrUnknownTokenClient
+	}
+	return getter.GetTokenClientID(ctx, tokenID)
+}
+
+func internalSubject(ctx context.Context, storage any, subject, clientID string) (string, error) {
+	if mapper, ok := storage.(SubjectMapper); ok && subject != "" {
+		return mapper.InternalSubject(ctx, subject, clientID)
+	}
+	return subject, nil
+}
+
+// PairwiseSubjectMapper calculates a pairwise subject identifier
+// (OpenID Connect Core 1.0, section 8.1) as the HMAC-SHA256 of
+// the sector identifier and the user ID.
+// As the calculation is not reversible, it should be wrapped
+// into a [CachedSubjectMapper] or have Resolve set.
+type PairwiseSubjectMapper struct {
+	Key []byte
+	// SectorIdentifier returns the sector of the client.
+	// Defaults to the client ID itself.
+	SectorIdentifier func(clientID string) string
+	// Resolve returns the user ID for a pairwise subject,
+	// for example from a lookup table in the database.
+	// Optional, [ErrUnknownSubject] is returned when nil.
+	Resolve func(ctx context.Context, subject, clientID string) (string, error)
+}
+
+func (m *PairwiseSubjectMapper) ExternalSubject(_ context.Context, userID, clientID string) (string, error) {
+	sector := clientID
+	if m.SectorIdentifier != nil {
+		sector = m.SectorIdentifier(clientID)
+	}
+	mac := hmac.New(sha256.New, m.Key)
+	mac.Write([]byte(sector))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (m *PairwiseSubjectMapper) InternalSubject(ctx context.Context, subject, clientID string) (string, error) {
+	if m.Resolve == nil {
+		return "", ErrUnknownSubject
+	}
+	return m.Resolve(ctx, subject, clientID)
+}
+
+// EncryptedSubjectMapper encrypts the user ID into the subject using AES-GCM.
+// The encryption is deterministic, so a user always has the same subject:
+// the nonce is a synthetic IV, the HMAC-SHA256 of the user ID, which is
+// checked on decryption, as is the authentication tag, so subjects cannot be forged.
+// The encryption and HMAC keys are derived from the key with HKDF.
+// It's neither pairwise nor does it rely on storage to resolve the user ID.
+type EncryptedSubjectMapper struct {
+	aead   cipher.AEAD
+	macKey security.Secret
+}
+
+func NewEncryptedSubjectMapper(key [32]byte) *EncryptedSubjectMapper {
+	encKey := deriveSubjectKey(key, "oidc subject encryption")
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		// a 32 byte key is always valid
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &EncryptedSubjectMapper{
+		aead:   aead,
+		macKey: security.Secret(deriveSubjectKey(key, "oidc subject nonce")),
+	}
+}
+
+// deriveSubjectKey derives a 32 byte key for the purpose from the key with HKDF-SHA256.
+func deriveSubjectKey(key [32]byte, info string) []byte {
+	derived := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key[:], nil, []byte(info)), derived); err != nil {
+		panic(err)
+	}
+	return derived
+}
+
+// nonce returns the synthetic nonce of the user ID.
+func (m *EncryptedSubjectMapper) nonce(userID []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(m.macKey.Reveal()))
+	mac.Write(userID)
+	return mac.Sum(nil)[:m.aead.NonceSize()]
+}
+
+func (m *EncryptedSubjectMapper) ExternalSubject(_ context.Context, userID, _ string) (string, error) {
+	nonce := m.nonce([]byte(userID))
+	return base64.RawURLEncoding.EncodeToString(m.aead.Seal(nonce, nonce, []byte(userID), nil)), nil
+}
+
+func (m *EncryptedSubjectMapper) InternalSubject(_ context.Context, subject, _ string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(subject)
+	if err != nil {
+		return "", errors.Join(ErrUnknownSubject, err)
+	}
+	nonceSize := m.aead.NonceSize()
+	if len(data) < nonceSize+m.aead.Overhead() {
+		return "", ErrUnknownSubject
+	}
+	userID, err := m.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return "", errors.Join(ErrUnknownSubject, err)
+	}
+	if !hmac.Equal(data[:nonceSize], m.nonce(userID)) {
+		return "", ErrUnknownSubject
+	}
+	return string(userID), nil
+}
+
+// CachedSubjectMapper caches the translations of the wrapped SubjectMapper
+// in memory, in both directions.
+// Subjects returned by ExternalSubject can therefore be translated back,
+// even if the wrapped mapper is not reversible.
+// When the cache exceeds MaxEntries (defaults to 10000),
+// the least recently used translation is evicted.
+type CachedSubjectMapper struct {
+	SubjectMapper
+	MaxEntries int
+
+	mu       sync.Mutex
+	lru      *list.List
+	external map[string]*list.Element
+	internal map[string]*list.Element
+}
+
+// subjectCacheEntry is the translation between userID and subject for the client.
+type subjectCacheEntry struct {
+	clientID, userID, subject string
+}
+
+func NewCachedSubjectMapper(mapper SubjectMapper) *CachedSubjectMapper {
+	return &CachedSubjectMapper{
+		SubjectMapper: mapper,
+		MaxEntries:    10000,
+	}
+}
+
+func subjectCacheKey(clientID, value string) string {
+	return strings.Join([]string{clientID, value}, "\x00")
+}
+
+func (m *CachedSubjectMapper) ExternalSubject(ctx context.Context, userID, clientID string) (string, error) {
+	if entry, ok := m.lookup(subjectCacheKey(clientID, userID), true); ok {
+		return entry.subject, nil
+	}
+	subject, err := m.SubjectMapper.ExternalSubject(ctx, userID, clientID)
+	if err != nil {
+		return "", err
+	}
+	m.store(clientID, userID, subject)
+	return subject, nil
+}
+
+func (m *CachedSubjectMapper) InternalSubject(ctx context.Context, subject, clientID string) (string, error) {
+	if entry, ok := m.lookup(subjectCacheKey(clientID, subject), false); ok {
+		return entry.userID, nil
+	}
+	userID, err := m.SubjectMapper.InternalSubject(ctx, subject, clientID)
+	if err != nil {
+		return "", err
+	}
+	m.store(clientID, userID, subject)
+	return userID, nil
+}
+
+// lookup returns the entry of the key, of the user ID if external is set,
+// of the subject otherwise, and marks it as recently used.
+func (m *CachedSubjectMapper) lookup(key string, external bool) (subjectCacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	index := m.internal
+	if external {
+		index = m.external
+	}
+	elem, ok := index[key]
+	if !ok {
+		return subjectCacheEntry{}, false
+	}
+	m.lru.MoveToFront(elem)
+	return elem.Value.(subjectCacheEntry), true
+}
+
+func (m *CachedSubjectMapper) store(clientID, userID, subject string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lru == nil {
+		m.lru = list.New()
+		m.external = make(map[string]*list.Element)
+		m.internal = make(map[string]*list.Element)
+	}
+	externalKey, internalKey := subjectCacheKey(clientID, userID), subjectCacheKey(clientID, subject)
+	if elem, ok := m.external[externalKey]; ok {
+		m.remove(elem)
+	}
+	if elem, ok := m.internal[internalKey]; ok {
+		m.remove(elem)
+	}
+	for m.MaxEntries > 0 && m.lru.Len() >= m.MaxEntries {
+		m.remove(m.lru.Back())
+	}
+	elem := m.lru.PushFront(subjectCacheEntry{clientID: clientID, userID: userID, subject: subject})
+	m.external[externalKey] = elem
+	m.internal[internalKey] = elem
+}
+
+func (m *CachedSubjectMapper) remove(elem *list.Element) {
+	entry := m.lru.Remove(elem).(subjectCacheEntry)
+	delete(m.external, subjectCacheKey(entry.clientID, entry.userID))
+	delete(m.internal, subjectCacheKey(entry.clientID, entry.subject))
+}
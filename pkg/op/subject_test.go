@@ -0,0 +1,105 @@
+package op
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairwiseSubjectMapper(t *testing.T) {
+	ctx := context.Background()
+	m := &PairwiseSubjectMapper{Key: []byte("secret")}
+
+	sub1, err := m.ExternalSubject(ctx, "user1", "client1")
+	require.NoError(t, err)
+	again, err := m.ExternalSubject(ctx, "user1", "client1")
+	require.NoError(t, err)
+	assert.Equal(t, sub1, again)
+
+	sub2, err := m.ExternalSubject(ctx, "user1", "client2")
+	require.NoError(t, err)
+	assert.NotEqual(t, sub1, sub2)
+
+	_, err = m.InternalSubject(ctx, sub1, "client1")
+	assert.ErrorIs(t, err, ErrUnknownSubject)
+
+	m.SectorIdentifier = func(string) string { return "sector" }
+	sub1, err = m.ExternalSubject(ctx, "user1", "client1")
+	require.NoError(t, err)
+	sub2, err = m.ExternalSubject(ctx, "user1", "client2")
+	require.NoError(t, err)
+	assert.Equal(t, sub1, sub2)
+}
+
+func TestEncryptedSubjectMapper(t *testing.T) {
+	ctx := context.Background()
+	m := NewEncryptedSubjectMapper([32]byte{1, 2, 3})
+
+	sub, err := m.ExternalSubject(ctx, "user1", "client1")
+	require.NoError(t, err)
+	assert.NotEqual(t, "user1", sub)
+	again, err := m.ExternalSubject(ctx, "user1", "client2")
+	require.NoError(t, err)
+	assert.Equal(t, sub, again)
+
+	userID, err := m.InternalSubject(ctx, sub, "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+
+	_, err = m.InternalSubject(ctx, "!", "client1")
+	assert.ErrorIs(t, err, ErrUnknownSubject)
+
+	// tampered subjects fail the authentication
+	data, err := base64.RawURLEncoding.DecodeString(sub)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 1
+	_, err = m.InternalSubject(ctx, base64.RawURLEncoding.EncodeToString(data), "client1")
+	assert.ErrorIs(t, err, ErrUnknownSubject)
+
+	_, err = NewEncryptedSubjectMapper([32]byte{4, 5, 6}).InternalSubject(ctx, sub, "client1")
+	assert.ErrorIs(t, err, ErrUnknownSubject)
+}
+
+func TestCachedSubjectMapper(t *testing.T) {
+	ctx := context.Background()
+	m := NewCachedSubjectMapper(&PairwiseSubjectMapper{Key: []byte("secret")})
+	m.MaxEntries = 2
+
+	sub1, err := m.ExternalSubject(ctx, "user1", "client1")
+	require.NoError(t, err)
+	userID, err := m.InternalSubject(ctx, sub1, "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+
+	_, err = m.InternalSubject(ctx, sub1, "client2")
+	assert.ErrorIs(t, err, ErrUnknownSubject)
+
+	sub2, err := m.ExternalSubject(ctx, "user2", "client1")
+	require.NoError(t, err)
+	_, err = m.InternalSubject(ctx, sub1, "client1")
+	require.NoError(t, err)
+
+	// exceeding MaxEntries evicts the least recently used translation
+	_, err = m.ExternalSubject(ctx, "user3", "client1")
+	require.NoError(t, err)
+	_, err = m.InternalSubject(ctx, sub2, "client1")
+	assert.ErrorIs(t, err, ErrUnknownSubject)
+	userID, err = m.InternalSubject(ctx, sub1, "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+	assert.Equal(t, 2, m.lru.Len())
+}
+
+func Test_externalSubject(t *testing.T) {
+	ctx := context.Background()
+	got, err := externalSubject(ctx, struct{}{}, "user1", "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", got)
+
+	got, err = internalSubject(ctx, struct{}{}, "user1", "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", got)
+}
@@ -0,0 +1,256 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultTenantKeysCacheTTL is the default time the keys of a tenant are cached.
+const DefaultTenantKeysCacheTTL = 5 * time.Minute
+
+// DefaultTenantKeysMaxTenants is the default maximum number of cached tenants.
+const DefaultTenantKeysMaxTenants = 1000
+
+// ErrTenantIssuerMissing is returned for the keys of a multi-issuer Provider
+// with [WithTenantKeys], if the context has no issuer identifying the tenant.
+var ErrTenantIssuerMissing = errors.New("tenant keys require the issuer in the context")
+
+// ErrTenantKeysDisabled is returned by [Provider.SetTenantSigningKeys] without [WithTenantKeys].
+var ErrTenantKeysDisabled = errors.New("tenant keys require WithTenantKeys")
+
+// TenantKeyStorage provides the keys of each tenant of a multi-issuer Provider,
+// such as with [IssuerFromHost], identified by its issuer, see [WithTenantKeys].
+type TenantKeyStorage interface {
+	// TenantSigningKey returns the key signing the tokens of the tenant.
+	TenantSigningKey(ctx context.Context, issuer string) (SigningKey, error)
+	// TenantKeySet returns the public keys of the tenant,
+	// which must include its signing key.
+	TenantKeySet(ctx context.Context, issuer string) ([]Key, error)
+}
+
+// TenantKeysConfig isolates the keys of the tenants of a multi-issuer Provider,
+// see [WithTenantKeys].
+type TenantKeysConfig struct {
+	// Storage provides the keys of the tenants. It defaults to the SigningKey and
+	// KeySet methods of the [Storage], called with the issuer of the tenant in the context.
+	Storage TenantKeyStorage
+	// CacheTTL is the time the keys of a tenant are cached,
+	// defaults to [DefaultTenantKeysCacheTTL]. A negative value disables the cache.
+	CacheTTL time.Duration
+	// MaxTenants is the maximum number of tenants whose keys are cached,
+	// defaults to [DefaultTenantKeysMaxTenants]. Beyond it, the oldest tenants
+	// are evicted, except the ones with keys set by [Provider.SetTenantSigningKeys].
+	// As the issuer is taken from the request, issuers whose keys cannot be
+	// fetched from the Storage are not cached at all.
+	MaxTenants int
+
+	tenants *tenantKeyCache
+}
+
+// tenantKeyCache holds the keys of each tenant, guarded by a mutex of its own,
+// so fetching or rotating the keys of one tenant does not block the others.
+type tenantKeyCache struct {
+	mu      sync.Mutex
+	max     int
+	tenants map[string]*tenantKeys
+	// order of insertion of the tenants, the oldest first
+	order []string
+}
+
+func newTenantKeyCache(max int) *tenantKeyCache {
+	if max <= 0 {
+		max = DefaultTenantKeysMaxTenants
+	}
+	return &tenantKeyCache{max: max, tenants: make(map[string]*tenantKeys)}
+}
+
+type tenantKeys struct {
+	mu sync.Mutex
+	// override is set by [Provider.SetTenantSigningKeys].
+	override *providerKeys
+	cached   *providerKeys
+	expires  time.Time
+	// pinned is set while the keys are overridden, so the tenant is not evicted.
+	// It is guarded by the mutex of the cache.
+	pinned bool
+}
+
+func (c *tenantKeyCache) get(issuer string) *tenantKeys {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getLocked(issuer)
+}
+
+func (c *tenantKeyCache) getLocked(issuer string) *tenantKeys {
+	keys, ok := c.tenants[issuer]
+	if !ok {
+		c.evict()
+		keys = new(tenantKeys)
+		c.tenants[issuer] = keys
+		c.order = append(c.order, issuer)
+	}
+	return keys
+}
+
+// pin returns the tenant of the issuer, protected from eviction if pinned.
+func (c *tenantKeyCache) pin(issuer string, pinned bool) *tenantKeys {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := c.getLocked(issuer)
+	keys.pinned = pinned
+	return keys
+}
+
+// lookup returns the tenant of the issuer, if cached.
+func (c *tenantKeyCache) lookup(issuer string) *tenantKeys {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tenants[issuer]
+}
+
+// evict removes the oldest tenants, which are not pinned, while the cache is full.
+func (c *tenantKeyCache) evict() {
+	for len(c.order) >= c.max {
+		i := slices.IndexFunc(c.order, func(issuer string) bool { return !c.tenants[issuer].pinned })
+		if i < 0 {
+			return
+		}
+		delete(c.tenants, c.order[i])
+		c.order = slices.Delete(c.order, i, i+1)
+	}
+}
+
+// drop removes the tenant of the issuer, unless it is pinned or was replaced.
+func (c *tenantKeyCache) drop(issuer string, keys *tenantKeys) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tenants[issuer] != keys || keys.pinned {
+		return
+	}
+	delete(c.tenants, issuer)
+	c.order = slices.DeleteFunc(c.order, func(i string) bool { return i == issuer })
+}
+
+func (c *TenantKeysConfig) cacheTTL() time.Duration {
+	if c.CacheTTL != 0 {
+		return c.CacheTTL
+	}
+	return DefaultTenantKeysCacheTTL
+}
+
+// keys returns the keys of the tenant of the issuer,
+// fetched from the Storage, if not overridden or cached.
+func (c *TenantKeysConfig) keys(ctx context.Context, issuer string) (*providerKeys, error) {
+	if issuer == "" {
+		return nil, ErrTenantIssuerMissing
+	}
+	tenant := c.tenants.get(issuer)
+	keys, err := c.tenantKeys(ctx, issuer, tenant)
+	if err != nil {
+		// unknown issuers must not fill the cache
+		c.tenants.drop(issuer, tenant)
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (c *TenantKeysConfig) tenantKeys(ctx context.Context, issuer string, tenant *tenantKeys) (*providerKeys, error) {
+	tenant.mu.Lock()
+	defer tenant.mu.Unlock()
+	if tenant.override != nil {
+		return tenant.override, nil
+	}
+	if tenant.cached != nil && time.Now().Before(tenant.expires) {
+		return tenant.cached, nil
+	}
+	signing, err := c.Storage.TenantSigningKey(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	public, err := c.Storage.TenantKeySet(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	keys := &providerKeys{signing: signing, public: public}
+	if ttl := c.cacheTTL(); ttl > 0 {
+		tenant.cached = keys
+		tenant.expires = time.Now().Add(ttl)
+	}
+	return keys, nil
+}
+
+// storageTenantKeys provides the keys of the tenants by the [Storage].
+type storageTenantKeys struct {
+	storage Storage
+}
+
+func (s storageTenantKeys) TenantSigningKey(ctx context.Context, issuer string) (SigningKey, error) {
+	return s.storage.SigningKey(ContextWithIssuer(ctx, issuer))
+}
+
+func (s storageTenantKeys) TenantKeySet(ctx context.Context, issuer string) ([]Key, error) {
+	return s.storage.KeySet(ContextWithIssuer(ctx, issuer))
+}
+
+// SetTenantSigningKeys swaps the keys of the tenant of the issuer, like
+// [Provider.SetSigningKeys] does for a single tenant, overriding the keys of the
+// [TenantKeyStorage] until called with a nil signing key. The keys of the other
+// tenants are not affected. It requires [WithTenantKeys].
+// It is safe to call concurrently with requests being served.
+func (o *Provider) SetTenantSigningKeys(issuer string, signing SigningKey, public ...Key) error {
+	if o.tenantKeys == nil {
+		return ErrTenantKeysDisabled
+	}
+	if issuer == "" {
+		return ErrTenantIssuerMissing
+	}
+	keys, err := o.newProviderKeys(signing, public)
+	if err != nil {
+		return err
+	}
+	tenant := o.tenantKeys.tenants.pin(issuer, keys != nil)
+	tenant.mu.Lock()
+	defer tenant.mu.Unlock()
+	tenant.override = keys
+	tenant.cached = nil
+	return nil
+}
+
+// RefreshTenantKeys drops the cached keys of the tenant of the issuer,
+// so they are fetched from the [TenantKeyStorage] again, e.g. after a
+// rotation of the keys of the tenant in the storage.
+func (o *Provider) RefreshTenantKeys(issuer string) {
+	if o.tenantKeys == nil {
+		return
+	}
+	tenant := o.tenantKeys.tenants.lookup(issuer)
+	if tenant == nil {
+		return
+	}
+	tenant.mu.Lock()
+	defer tenant.mu.Unlock()
+	tenant.cached = nil
+}
+
+// TenantKeyProvider returns the public keys of the tenant of the issuer,
+// independent of the issuer of the context, e.g. for the verification of the
+// tokens of the tenant outside of its requests.
+func (o *Provider) TenantKeyProvider(issuer string) KeyProvider {
+	return &tenantKeyProvider{provider: o, issuer: issuer}
+}
+
+type tenantKeyProvider struct {
+	provider *Provider
+	issuer   string
+}
+
+func (k *tenantKeyProvider) KeySet(ctx context.Context) ([]Key, error) {
+	return k.provider.KeySet(ContextWithIssuer(ctx, k.issuer))
+}
+
+func (k *tenantKeyProvider) SigningKey(ctx context.Context) (SigningKey, error) {
+	return k.provider.SigningKey(ContextWithIssuer(ctx, k.issuer))
+}
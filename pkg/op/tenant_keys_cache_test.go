@@ -0,0 +1,51 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errUnknownTenant = errors.New("unknown tenant")
+
+// knownTenants fails for the issuers it does not know.
+type knownTenants map[string]bool
+
+func (k knownTenants) TenantSigningKey(_ context.Context, issuer string) (SigningKey, error) {
+	if !k[issuer] {
+		return nil, errUnknownTenant
+	}
+	return nil, nil
+}
+
+func (k knownTenants) TenantKeySet(context.Context, string) ([]Key, error) {
+	return nil, nil
+}
+
+func TestTenantKeyCache(t *testing.T) {
+	ctx := context.Background()
+	config := &TenantKeysConfig{
+		Storage: knownTenants{"a": true, "b": true, "c": true},
+		tenants: newTenantKeyCache(2),
+	}
+
+	_, err := config.keys(ctx, "unknown")
+	require.ErrorIs(t, err, errUnknownTenant)
+	assert.Empty(t, config.tenants.tenants, "unknown issuers are not cached")
+
+	config.tenants.pin("a", true)
+	for _, issuer := range []string{"b", "c"} {
+		_, err := config.keys(ctx, issuer)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"a", "c"}, config.tenants.order, "the oldest unpinned tenant is evicted")
+
+	config.tenants.pin("a", false)
+	_, err = config.keys(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, config.tenants.order)
+	assert.Len(t, config.tenants.tenants, 2)
+}
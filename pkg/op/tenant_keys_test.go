@@ -0,0 +1,146 @@
+package op_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+type tenantSigningKey struct {
+	certSigningKey
+	id string
+}
+
+func (k tenantSigningKey) ID() string { return k.id }
+
+type tenantPublicKey struct {
+	certKey
+	id string
+}
+
+func (k tenantPublicKey) ID() string { return k.id }
+
+// tenantKeyStore has a key per tenant and counts the fetches of their keys.
+type tenantKeyStore struct {
+	mu      sync.Mutex
+	keys    map[string]tenantSigningKey
+	fetches map[string]int
+}
+
+func (s *tenantKeyStore) TenantSigningKey(_ context.Context, issuer string) (op.SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches[issuer]++
+	return s.keys[issuer], nil
+}
+
+func (s *tenantKeyStore) TenantKeySet(_ context.Context, issuer string) ([]op.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := s.keys[issuer]
+	return []op.Key{tenantPublicKey{certKey{key.certSigningKey}, key.id}}, nil
+}
+
+func TestWithTenantKeys(t *testing.T) {
+	const (
+		issuerA = "http://a.example"
+		issuerB = "http://b.example"
+	)
+	keys := &tenantKeyStore{
+		keys: map[string]tenantSigningKey{
+			issuerA: {newCertSigningKey(t), "a1"},
+			issuerB: {newCertSigningKey(t), "b1"},
+		},
+		fetches: make(map[string]int),
+	}
+	provider, err := op.NewProvider(testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)), op.IssuerFromHost(""),
+		op.WithAllowInsecure(),
+		op.WithTenantKeys(op.TenantKeysConfig{Storage: keys}),
+	)
+	require.NoError(t, err)
+
+	keyIDs := func(issuer string) []string {
+		w := httptest.NewRecorder()
+		provider.ServeHTTP(w, httptest.NewRequest(http.MethodGet, issuer+"/keys", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var keySet jose.JSONWebKeySet
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keySet))
+		ids := make([]string, len(keySet.Keys))
+		for i, key := range keySet.Keys {
+			ids[i] = key.KeyID
+		}
+		return ids
+	}
+	signingKeyID := func(issuer string) string {
+		key, err := provider.SigningKey(op.ContextWithIssuer(context.Background(), issuer))
+		require.NoError(t, err)
+		return key.ID()
+	}
+
+	assert.Equal(t, []string{"a1"}, keyIDs(issuerA))
+	assert.Equal(t, []string{"b1"}, keyIDs(issuerB))
+	assert.Equal(t, "a1", signingKeyID(issuerA))
+	assert.Equal(t, "b1", signingKeyID(issuerB))
+	assert.Equal(t, map[string]int{issuerA: 1, issuerB: 1}, keys.fetches, "keys are cached per tenant")
+
+	_, err = provider.SigningKey(context.Background())
+	assert.ErrorIs(t, err, op.ErrTenantIssuerMissing)
+
+	t.Run("rotation of a tenant", func(t *testing.T) {
+		rotated := tenantSigningKey{newCertSigningKey(t), "a2"}
+		require.NoError(t, provider.SetTenantSigningKeys(issuerA, rotated,
+			tenantPublicKey{certKey{rotated.certSigningKey}, "a2"},
+			tenantPublicKey{certKey{keys.keys[issuerA].certSigningKey}, "a1"},
+		))
+		assert.Equal(t, []string{"a2", "a1"}, keyIDs(issuerA))
+		assert.Equal(t, "a2", signingKeyID(issuerA))
+		assert.Equal(t, []string{"b1"}, keyIDs(issuerB))
+		assert.Equal(t, "b1", signingKeyID(issuerB))
+
+		require.NoError(t, provider.SetTenantSigningKeys(issuerA, nil))
+		assert.Equal(t, []string{"a1"}, keyIDs(issuerA))
+		assert.Equal(t, map[string]int{issuerA: 2, issuerB: 1}, keys.fetches)
+	})
+	t.Run("refresh of a tenant", func(t *testing.T) {
+		provider.RefreshTenantKeys(issuerB)
+		assert.Equal(t, "b1", signingKeyID(issuerB))
+		assert.Equal(t, "a1", signingKeyID(issuerA))
+		assert.Equal(t, map[string]int{issuerA: 2, issuerB: 2}, keys.fetches)
+	})
+	t.Run("tenant key provider", func(t *testing.T) {
+		keySet, err := provider.TenantKeyProvider(issuerB).KeySet(op.ContextWithIssuer(context.Background(), issuerA))
+		require.NoError(t, err)
+		require.Len(t, keySet, 1)
+		assert.Equal(t, "b1", keySet[0].ID())
+	})
+	t.Run("tokens of a tenant", func(t *testing.T) {
+		ctxA := op.ContextWithIssuer(context.Background(), issuerA)
+		ctxB := op.ContextWithIssuer(context.Background(), issuerB)
+		client, err := provider.Storage().GetClientByClientID(ctxA, "web")
+		require.NoError(t, err)
+		token, _, _, err := op.CreateAccessToken(ctxA, &oidc.JWTTokenRequest{
+			Subject:  "sub1",
+			Audience: []string{"web"},
+		}, op.AccessTokenTypeJWT, provider, client, "")
+		require.NoError(t, err)
+		assert.Equal(t, "a1", signedHeader(t, token)["kid"])
+
+		_, err = op.VerifyAccessToken[*oidc.AccessTokenClaims](ctxA, token, provider.AccessTokenVerifier(ctxA))
+		assert.NoError(t, err)
+		_, err = op.VerifyAccessToken[*oidc.AccessTokenClaims](ctxB, token, provider.AccessTokenVerifier(ctxB))
+		assert.Error(t, err, "token of tenant a is rejected by tenant b")
+	})
+
+	assert.ErrorIs(t, new(op.Provider).SetTenantSigningKeys(issuerA, nil), op.ErrTenantKeysDisabled)
+}
@@ -2,10 +2,10 @@ package op
 
 import (
 	"context"
+	"log/slog"
 	"slices"
 	"time"
 
-	"github.com/lmindwarel/oidc/v3/pkg/crypto"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
 
@@ -28,6 +28,12 @@ type AccessTokenClient interface {
 }
 
 func CreateTokenResponse(ctx context.Context, request IDTokenRequest, client Client, creator TokenCreator, createAccessToken bool, code, refreshToken string) (*oidc.AccessTokenResponse, error) {
+	return createTokenResponse(ctx, request, client, creator, createAccessToken, code, refreshToken, newIDTokenOptions(creator))
+}
+
+// createTokenResponse is like [CreateTokenResponse],
+// without ID token if idTokenOpts is nil.
+func createTokenResponse(ctx context.Context, request IDTokenRequest, client Client, creator TokenCreator, createAccessToken bool, code, refreshToken string, idTokenOpts *idTokenOptions) (*oidc.AccessTokenResponse, error) {
 	ctx, span := tracer.Start(ctx, "CreateTokenResponse")
 	defer span.End()
 
@@ -40,14 +46,18 @@ func CreateTokenResponse(ctx context.Context, request IDTokenRequest, client Cli
 			return nil, err
 		}
 	}
-	idToken, err := CreateIDToken(ctx, IssuerFromContext(ctx), request, client.IDTokenLifetime(), accessToken, code, creator.Storage(), client)
-	if err != nil {
-		return nil, err
+	var idToken string
+	if idTokenOpts != nil {
+		var err error
+		idToken, err = createIDToken(ctx, IssuerFromContext(ctx), request, client.IDTokenLifetime(), accessToken, code, creator.Storage(), client, *idTokenOpts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var state string
 	if authRequest, ok := request.(AuthRequest); ok {
-		err = creator.Storage().DeleteAuthRequest(ctx, authRequest.GetID())
+		err := creator.Storage().DeleteAuthRequest(ctx, authRequest.GetID())
 		if err != nil {
 			return nil, err
 		}
@@ -59,27 +69,58 @@ func CreateTokenResponse(ctx context.Context, request IDTokenRequest, client Cli
 
 	exp := uint64(validity.Seconds())
 	return &oidc.AccessTokenResponse{
-		AccessToken:  accessToken,
-		IDToken:      idToken,
-		RefreshToken: newRefreshToken,
-		TokenType:    oidc.BearerToken,
-		ExpiresIn:    exp,
-		State:        state,
-		Scope:        request.GetScopes(),
+		AccessToken:          accessToken,
+		IDToken:              idToken,
+		RefreshToken:         newRefreshToken,
+		TokenType:            issuedTokenType(ctx),
+		ExpiresIn:            exp,
+		State:                state,
+		Scope:                request.GetScopes(),
+		AuthorizationDetails: grantedAuthorizationDetails(request),
 	}, nil
 }
 
-func createTokens(ctx context.Context, tokenRequest TokenRequest, storage Storage, refreshToken string, client AccessTokenClient) (id, newRefreshToken string, exp time.Time, err error) {
+func createTokens(ctx context.Context, tokenRequest TokenRequest, creator TokenCreator, refreshToken string, client AccessTokenClient) (id, newRefreshToken string, exp time.Time, err error) {
 	ctx, span := tracer.Start(ctx, "createTokens")
 	defer span.End()
 
+	if err = issueTokenHooks(ctx, tokenRequest); err != nil {
+		return "", "", time.Time{}, err
+	}
+	storage := creator.Storage()
+	policy := publicClientPolicy(creator)
+	public := isPublicClient(client)
+	if public && policy.RefreshTokens == PublicClientRefreshTokensDeny {
+		if _, ok := tokenRequest.(RefreshTokenRequest); ok {
+			return "", "", time.Time{}, oidc.ErrUnauthorizedClient().WithDescription("refresh_token grant not allowed for public clients")
+		}
+		id, exp, err = storage.CreateAccessToken(ctx, tokenRequest)
+		return
+	}
 	if needsRefreshToken(tokenRequest, client) {
-		return storage.CreateAccessAndRefreshTokens(ctx, tokenRequest, refreshToken)
+		id, newRefreshToken, exp, err = storage.CreateAccessAndRefreshTokens(ctx, tokenRequest, refreshToken)
+		if err != nil || refreshToken == "" {
+			return
+		}
+		if public && policy.RefreshTokens == PublicClientRefreshTokensRotate && newRefreshToken == refreshToken ||
+			(newRefreshToken == "" || newRefreshToken == refreshToken) && !useLegacy(ctx, creator, LegacyRefreshTokenReuse, client.GetID()) {
+			revokeUnissuedToken(ctx, creator, id, tokenRequest.GetSubject(), client.GetID())
+			return "", "", time.Time{}, oidc.ErrServerError().WithDescription("refresh token was not rotated")
+		}
+		return
 	}
 	id, exp, err = storage.CreateAccessToken(ctx, tokenRequest)
 	return
 }
 
+// revokeUnissuedToken revokes the access token, which the Storage already persisted,
+// but which is not issued as the request failed.
+func revokeUnissuedToken(ctx context.Context, creator TokenCreator, tokenID, subject, clientID string) {
+	if err := creator.Storage().RevokeToken(ctx, tokenID, subject, clientID); err != nil {
+		providerLogger(creator).WarnContext(ctx, "unable to revoke unissued access token", "client_id", clientID, "error", err)
+	}
+}
+
 func needsRefreshToken(tokenRequest TokenRequest, client AccessTokenClient) bool {
 	switch req := tokenRequest.(type) {
 	case AuthRequest:
@@ -99,7 +140,7 @@ func CreateAccessToken(ctx context.Context, tokenRequest TokenRequest, accessTok
 	ctx, span := tracer.Start(ctx, "CreateAccessToken")
 	defer span.End()
 
-	id, newRefreshToken, exp, err := createTokens(ctx, tokenRequest, creator.Storage(), refreshToken, client)
+	id, newRefreshToken, exp, err := createTokens(ctx, tokenRequest, creator, refreshToken, client)
 	if err != nil {
 		return "", "", 0, err
 	}
@@ -109,7 +150,7 @@ func CreateAccessToken(ctx context.Context, tokenRequest TokenRequest, accessTok
 	}
 	validity = exp.Add(clockSkew).Sub(time.Now().UTC())
 	if accessTokenType == AccessTokenTypeJWT {
-		accessToken, err = CreateJWT(ctx, IssuerFromContext(ctx), tokenRequest, exp, id, client, creator.Storage())
+		accessToken, err = createJWT(ctx, IssuerFromContext(ctx), tokenRequest, exp, id, client, creator.Storage(), newAccessTokenOptions(creator))
 		return
 	}
 	_, span = tracer.Start(ctx, "CreateBearerToken")
@@ -127,6 +168,46 @@ type TokenActorRequest interface {
 }
 
 func CreateJWT(ctx context.Context, issuer string, tokenRequest TokenRequest, exp time.Time, id string, client AccessTokenClient, storage Storage) (string, error) {
+	return createJWT(ctx, issuer, tokenRequest, exp, id, client, storage, accessTokenOptions{})
+}
+
+// accessTokenOptions of the Provider for the creation of JWT access tokens.
+type accessTokenOptions struct {
+	header *TokenHeader
+	// keys provide the signing key, defaults to the storage.
+	keys signingKeyGetter
+	// scopeArray emits the scopes as scp array as well.
+	scopeArray bool
+	// groupClaims guards the size of the group claims, if set.
+	groupClaims *GroupClaimsPolicy
+	// tokenSize limits the size of the token, if set.
+	tokenSize *TokenSizePolicy
+	logger    *slog.Logger
+}
+
+// newAccessTokenOptions returns the options of the creator.
+func newAccessTokenOptions(creator TokenCreator) accessTokenOptions {
+	return accessTokenOptions{
+		header:      fipsTokenHeader(creator, accessTokenHeader(creator)),
+		keys:        signingKeys(creator, creator.Storage()),
+		scopeArray:  scopeArrayClaim(creator),
+		groupClaims: groupClaimsPolicy(creator),
+		tokenSize:   tokenSizePolicy(creator),
+		logger:      providerLogger(creator),
+	}
+}
+
+type scopeArrayClaimGetter interface {
+	ScopeArrayClaim() bool
+}
+
+// scopeArrayClaim reports if the scopes are emitted as scp array, see [WithScopeArrayClaim].
+func scopeArrayClaim(v any) bool {
+	getter, ok := v.(scopeArrayClaimGetter)
+	return ok && getter.ScopeArrayClaim()
+}
+
+func createJWT(ctx context.Context, issuer string, tokenRequest TokenRequest, exp time.Time, id string, client AccessTokenClient, storage Storage, opts accessTokenOptions) (string, error) {
 	ctx, span := tracer.Start(ctx, "CreateJWT")
 	defer span.End()
 
@@ -162,15 +243,42 @@ func CreateJWT(ctx context.Context, issuer string, tokenRequest TokenRequest, ex
 	if actorReq, ok := tokenRequest.(TokenActorRequest); ok {
 		claims.Actor = actorReq.GetActor()
 	}
-	signingKey, err := storage.SigningKey(ctx)
+	if sidRequest, ok := tokenRequest.(SessionIDRequest); ok {
+		claims.SessionID = sidRequest.GetSessionID()
+	}
+	claims.AuthorizationDetails = grantedAuthorizationDetails(tokenRequest)
+	subject, err := externalSubject(ctx, storage, claims.Subject, client.GetID())
 	if err != nil {
 		return "", err
 	}
-	signer, err := SignerFromKey(signingKey)
+	claims.Subject = subject
+	if setter, ok := storage.(CanSetAccessTokenClaims); ok {
+		if err := setter.SetAccessTokenClaims(ctx, claims, tokenRequest, client.GetID()); err != nil {
+			return "", err
+		}
+	}
+	if cnf := tokenConfirmation(ctx); cnf != nil {
+		claims.Confirmation = cnf
+	}
+	if opts.scopeArray && len(claims.ScopeArray) == 0 {
+		claims.ScopeArray = oidc.ScopeArray(claims.Scopes)
+	}
+	if claims.Claims, err = opts.groupClaims.apply(ctx, claims.Claims, claims.Subject, client.GetID()); err != nil {
+		return "", err
+	}
+	keys := opts.keys
+	if keys == nil {
+		keys = storage
+	}
+	signingKey, err := keys.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	signer, err := SignerFromKeyWithHeader(signingKey, opts.header)
 	if err != nil {
 		return "", err
 	}
-	return crypto.Sign(claims, signer)
+	return opts.tokenSize.sign(ctx, opts.logger, claims, claims.Claims, signer)
 }
 
 type IDTokenRequest interface {
@@ -183,22 +291,70 @@ type IDTokenRequest interface {
 }
 
 func CreateIDToken(ctx context.Context, issuer string, request IDTokenRequest, validity time.Duration, accessToken, code string, storage Storage, client Client) (string, error) {
+	return createIDToken(ctx, issuer, request, validity, accessToken, code, storage, client, idTokenOptions{})
+}
+
+// idTokenOptions of the Provider for the creation of ID tokens.
+type idTokenOptions struct {
+	header *TokenHeader
+	// keys provide the signing key, defaults to the storage.
+	keys signingKeyGetter
+	// authTime overrides the auth_time of the request, if not zero.
+	authTime time.Time
+	// omitUserinfo omits the claims of the userinfo scopes.
+	omitUserinfo bool
+	// groupClaims guards the size of the group claims, if set.
+	groupClaims *GroupClaimsPolicy
+	// tokenSize limits the size of the token, if set.
+	tokenSize *TokenSizePolicy
+	logger    *slog.Logger
+}
+
+// newIDTokenOptions returns the options of the creator.
+func newIDTokenOptions(creator TokenCreator) *idTokenOptions {
+	return &idTokenOptions{
+		header:      fipsTokenHeader(creator, idTokenHeader(creator)),
+		keys:        signingKeys(creator, creator.Storage()),
+		groupClaims: groupClaimsPolicy(creator),
+		tokenSize:   tokenSizePolicy(creator),
+		logger:      providerLogger(creator),
+	}
+}
+
+func createIDToken(ctx context.Context, issuer string, request IDTokenRequest, validity time.Duration, accessToken, code string, storage Storage, client Client, opts idTokenOptions) (string, error) {
 	ctx, span := tracer.Start(ctx, "CreateIDToken")
 	defer span.End()
 
 	exp := time.Now().UTC().Add(client.ClockSkew()).Add(validity)
 	var acr, nonce string
 	if authRequest, ok := request.(AuthRequest); ok {
-		acr = authRequest.GetACR()
 		nonce = authRequest.GetNonce()
 	}
-	claims := oidc.NewIDTokenClaims(issuer, request.GetSubject(), request.GetAudience(), exp, request.GetAuthTime(), nonce, acr, request.GetAMR(), request.GetClientID(), client.ClockSkew())
+	// refresh token requests may keep the acr of the authentication as well
+	if acrRequest, ok := request.(interface{ GetACR() string }); ok {
+		acr = acrRequest.GetACR()
+	}
+	authTime := request.GetAuthTime()
+	if !opts.authTime.IsZero() {
+		authTime = opts.authTime
+	}
+	claims := oidc.NewIDTokenClaims(issuer, request.GetSubject(), request.GetAudience(), exp, authTime, nonce, acr, request.GetAMR(), request.GetClientID(), client.ClockSkew())
+	if sidRequest, ok := request.(SessionIDRequest); ok {
+		claims.SessionID = sidRequest.GetSessionID()
+	}
 	if actorReq, ok := request.(TokenActorRequest); ok {
 		claims.Actor = actorReq.GetActor()
 	}
 
 	scopes := client.RestrictAdditionalIdTokenScopes()(request.GetScopes())
-	signingKey, err := storage.SigningKey(ctx)
+	if opts.omitUserinfo {
+		scopes = removeUserinfoScopes(scopes)
+	}
+	keys := opts.keys
+	if keys == nil {
+		keys = storage
+	}
+	signingKey, err := keys.SigningKey(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -243,11 +399,18 @@ func CreateIDToken(ctx context.Context, issuer string, request IDTokenRequest, v
 		}
 		claims.CodeHash = codeHash
 	}
-	signer, err := SignerFromKey(signingKey)
+	claims.Subject, err = externalSubject(ctx, storage, claims.Subject, request.GetClientID())
+	if err != nil {
+		return "", err
+	}
+	if claims.Claims, err = opts.groupClaims.apply(ctx, claims.Claims, claims.Subject, request.GetClientID()); err != nil {
+		return "", err
+	}
+	signer, err := SignerFromKeyWithHeader(signingKey, opts.header)
 	if err != nil {
 		return "", err
 	}
-	return crypto.Sign(claims, signer)
+	return opts.tokenSize.sign(ctx, opts.logger, claims, claims.Claims, signer)
 }
 
 func removeUserinfoScopes(scopes []string) []string {
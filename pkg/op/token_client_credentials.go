@@ -33,7 +33,7 @@ func ClientCredentialsExchange(w http.ResponseWriter, r *http.Request, exchanger
 		return
 	}
 
-	httphelper.MarshalJSON(w, resp)
+	WriteJSON(w, resp, http.StatusOK)
 }
 
 // ParseClientCredentialsRequest parsed the http request into a oidc.ClientCredentialsRequest
@@ -78,7 +78,17 @@ func ValidateClientCredentialsRequest(ctx context.Context, request *oidc.ClientC
 		return nil, nil, oidc.ErrUnsupportedGrantType().WithDescription("client_credentials grant not supported")
 	}
 
-	client, err := AuthorizeClientCredentialsClient(ctx, request, storage)
+	client, ok, err := authorizeSPIFFEClientAssertion(ctx, exchanger, exchanger.Storage(), request.ClientAssertionType, request.ClientAssertion)
+	if ok && err == nil {
+		request.ClientID = client.GetID()
+	} else if !ok {
+		client, ok, err = authorizeTLSClientByID(ctx, exchanger.Storage(), request.ClientID, mtlsConfig(exchanger))
+	}
+	if !ok {
+		client, err = AuthorizeClientCredentialsClient(ctx, request, storage)
+	} else if err == nil && !ValidateGrantType(client, oidc.GrantTypeClientCredentials) {
+		err = oidc.ErrUnauthorizedClient()
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -87,6 +97,9 @@ func ValidateClientCredentialsRequest(ctx context.Context, request *oidc.ClientC
 	if err != nil {
 		return nil, nil, err
 	}
+	if err = validateClientCredentialsAuthorizationDetails(exchanger, request.AuthorizationDetails, tokenRequest); err != nil {
+		return nil, nil, err
+	}
 
 	return tokenRequest, client, nil
 }
@@ -117,9 +130,10 @@ func CreateClientCredentialsTokenResponse(ctx context.Context, tokenRequest Toke
 	}
 
 	return &oidc.AccessTokenResponse{
-		AccessToken: accessToken,
-		TokenType:   oidc.BearerToken,
-		ExpiresIn:   uint64(validity.Seconds()),
-		Scope:       tokenRequest.GetScopes(),
+		AccessToken:          accessToken,
+		TokenType:            issuedTokenType(ctx),
+		ExpiresIn:            uint64(validity.Seconds()),
+		Scope:                tokenRequest.GetScopes(),
+		AuthorizationDetails: grantedAuthorizationDetails(tokenRequest),
 	}, nil
 }
@@ -28,12 +28,20 @@ func CodeExchange(w http.ResponseWriter, r *http.Request, exchanger Exchanger) {
 		RequestError(w, r, err, exchanger.Logger())
 		return
 	}
+	if err = verifyDeviceBinding(r.Context(), exchanger.Storage(), replayCache(exchanger), r.Header, authReq, oidc.GrantTypeCode, ""); err != nil {
+		RequestError(w, r, err, exchanger.Logger())
+		return
+	}
+	if err = RedeemAuthCode(r.Context(), exchanger.Storage(), tokenReq.Code); err != nil {
+		RequestError(w, r, err, exchanger.Logger())
+		return
+	}
 	resp, err := CreateTokenResponse(r.Context(), authReq, client, exchanger, true, tokenReq.Code, "")
 	if err != nil {
 		RequestError(w, r, err, exchanger.Logger())
 		return
 	}
-	httphelper.MarshalJSON(w, resp)
+	WriteJSON(w, resp, http.StatusOK)
 }
 
 // ParseAccessTokenRequest parsed the http request into a oidc.AccessTokenRequest
@@ -59,12 +67,18 @@ func ValidateAccessTokenRequest(ctx context.Context, tokenReq *oidc.AccessTokenR
 	if client.GetID() != authReq.GetClientID() {
 		return nil, nil, oidc.ErrInvalidGrant()
 	}
+	if err = checkAuthCodeExpiry(ctx, exchanger, client, tokenReq.Code); err != nil {
+		return nil, nil, err
+	}
 	if !ValidateGrantType(client, oidc.GrantTypeCode) {
 		return nil, nil, oidc.ErrUnauthorizedClient().WithDescription("client missing grant type " + string(oidc.GrantTypeCode))
 	}
 	if tokenReq.RedirectURI != authReq.GetRedirectURI() {
 		return nil, nil, oidc.ErrInvalidGrant().WithDescription("redirect_uri does not correspond")
 	}
+	if err = ValidateTokenAuthorizationDetails(exchanger, tokenReq.AuthorizationDetails, authReq); err != nil {
+		return nil, nil, err
+	}
 	return authReq, client, nil
 }
 
@@ -85,6 +99,12 @@ func AuthorizeCodeClient(ctx context.Context, tokenReq *oidc.AccessTokenRequest,
 		return nil, nil, err
 	}
 
+	if client, ok, err := authorizeSPIFFEClientAssertion(ctx, exchanger, exchanger.Storage(), tokenReq.ClientAssertionType, tokenReq.ClientAssertion); ok {
+		if err != nil {
+			return nil, nil, err
+		}
+		return request, client, nil
+	}
 	if tokenReq.ClientAssertionType == oidc.ClientAssertionTypeJWTAssertion {
 		jwtExchanger, ok := exchanger.(JWTAuthorizationGrantExchanger)
 		if !ok || !exchanger.AuthMethodPrivateKeyJWTSupported() {
@@ -101,6 +121,12 @@ func AuthorizeCodeClient(ctx context.Context, tokenReq *oidc.AccessTokenRequest,
 	if err != nil {
 		return nil, nil, oidc.ErrInvalidClient().WithParent(err)
 	}
+	if isTLSAuthMethod(client.AuthMethod()) {
+		if err = AuthorizeTLSClient(ctx, client, mtlsConfig(exchanger)); err != nil {
+			return nil, nil, err
+		}
+		return request, client, nil
+	}
 	if client.AuthMethod() == oidc.AuthMethodPrivateKeyJWT {
 		return nil, nil, oidc.ErrInvalidClient().WithDescription("private_key_jwt not allowed for this client")
 	}
@@ -132,3 +158,19 @@ func AuthRequestByCode(ctx context.Context, storage Storage, code string) (AuthR
 	}
 	return authReq, nil
 }
+
+// RedeemAuthCode marks the code as redeemed, if the Storage implements [CodeRedeemer].
+// It must be called after the validation of the token request and before creating the tokens.
+func RedeemAuthCode(ctx context.Context, storage Storage, code string) error {
+	redeemer, ok := storage.(CodeRedeemer)
+	if !ok {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "RedeemAuthCode")
+	defer span.End()
+
+	if err := redeemer.RedeemAuthCode(ctx, code); err != nil {
+		return oidc.ErrInvalidGrant().WithDescription("invalid code").WithParent(err)
+	}
+	return nil
+}
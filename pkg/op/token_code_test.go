@@ -0,0 +1,94 @@
+package op_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// slowCodeStorage widens the window between reading the auth request
+// by code and deleting it after the token creation.
+type slowCodeStorage struct {
+	*storage.Storage
+}
+
+func (s slowCodeStorage) AuthRequestByCode(ctx context.Context, code string) (op.AuthRequest, error) {
+	authReq, err := s.Storage.AuthRequestByCode(ctx, code)
+	time.Sleep(20 * time.Millisecond)
+	return authReq, err
+}
+
+func TestCodeExchange_concurrent(t *testing.T) {
+	// two OP instances sharing the storage
+	shared := slowCodeStorage{storage.NewStorage(storage.NewUserStore(testIssuer))}
+	var instances []http.Handler
+	for i := 0; i < 2; i++ {
+		provider, err := op.NewOpenIDProvider(testIssuer, testConfig, shared, op.WithAllowInsecure())
+		require.NoError(t, err)
+		instances = append(instances, provider)
+	}
+
+	ctx := context.Background()
+	authReq, err := shared.CreateAuthRequest(ctx, &oidc.AuthRequest{
+		ClientID:     "web",
+		RedirectURI:  "https://example.com",
+		Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID},
+		ResponseType: oidc.ResponseTypeCode,
+	}, "id1")
+	require.NoError(t, err)
+	require.NoError(t, shared.AuthRequestDone(authReq.GetID()))
+	require.NoError(t, shared.SaveAuthCode(ctx, authReq.GetID(), "concurrent"))
+
+	values := url.Values{
+		"grant_type":   {string(oidc.GrantTypeCode)},
+		"code":         {"concurrent"},
+		"redirect_uri": {"https://example.com"},
+	}.Encode()
+
+	const concurrency = 20
+	var (
+		start  sync.WaitGroup
+		done   sync.WaitGroup
+		status = make([]int, concurrency)
+		bodies = make([]string, concurrency)
+	)
+	start.Add(1)
+	done.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func(i int) {
+			defer done.Done()
+			r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(values))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.SetBasicAuth("web", "secret")
+			w := httptest.NewRecorder()
+			start.Wait()
+			instances[i%len(instances)].ServeHTTP(w, r)
+			status[i], bodies[i] = w.Code, w.Body.String()
+		}(i)
+	}
+	start.Done()
+	done.Wait()
+
+	var succeeded int
+	for i := range status {
+		if status[i] == http.StatusOK {
+			succeeded++
+			continue
+		}
+		assert.Equal(t, http.StatusBadRequest, status[i])
+		assert.Contains(t, bodies[i], `"error":"invalid_grant"`)
+	}
+	assert.Equal(t, 1, succeeded)
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lmindwarel/oidc/v3/internal/security"
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 )
@@ -38,12 +39,12 @@ type TokenExchangeRequest interface {
 }
 
 type tokenExchangeRequest struct {
-	exchangeSubjectTokenIDOrToken string
+	exchangeSubjectTokenIDOrToken security.Secret
 	exchangeSubjectTokenType      oidc.TokenType
 	exchangeSubject               string
 	exchangeSubjectTokenClaims    map[string]any
 
-	exchangeActorTokenIDOrToken string
+	exchangeActorTokenIDOrToken security.Secret
 	exchangeActorTokenType      oidc.TokenType
 	exchangeActor               string
 	exchangeActorTokenClaims    map[string]any
@@ -94,7 +95,7 @@ func (r *tokenExchangeRequest) GetExchangeSubjectTokenType() oidc.TokenType {
 }
 
 func (r *tokenExchangeRequest) GetExchangeSubjectTokenIDOrToken() string {
-	return r.exchangeSubjectTokenIDOrToken
+	return r.exchangeSubjectTokenIDOrToken.Reveal()
 }
 
 func (r *tokenExchangeRequest) GetExchangeSubjectTokenClaims() map[string]any {
@@ -110,7 +111,7 @@ func (r *tokenExchangeRequest) GetExchangeActorTokenType() oidc.TokenType {
 }
 
 func (r *tokenExchangeRequest) GetExchangeActorTokenIDOrToken() string {
-	return r.exchangeActorTokenIDOrToken
+	return r.exchangeActorTokenIDOrToken.Reveal()
 }
 
 func (r *tokenExchangeRequest) GetExchangeActorTokenClaims() map[string]any {
@@ -154,7 +155,7 @@ func TokenExchange(w http.ResponseWriter, r *http.Request, exchanger Exchanger)
 		RequestError(w, r, err, exchanger.Logger())
 		return
 	}
-	httphelper.MarshalJSON(w, resp)
+	WriteJSON(w, resp, http.StatusOK)
 }
 
 // ParseTokenExchangeRequest parses the http request into oidc.TokenExchangeRequest
@@ -214,11 +215,11 @@ func ValidateTokenExchangeRequest(
 		return nil, nil, oidc.ErrInvalidRequest().WithDescription("requested_token_type is not supported")
 	}
 
-	if !oidcTokenExchangeRequest.SubjectTokenType.IsSupported() {
+	if !tokenExchangeTokenTypeSupported(exchanger, oidcTokenExchangeRequest.SubjectTokenType) {
 		return nil, nil, oidc.ErrInvalidRequest().WithDescription("subject_token_type is not supported")
 	}
 
-	if oidcTokenExchangeRequest.ActorTokenType != "" && !oidcTokenExchangeRequest.ActorTokenType.IsSupported() {
+	if oidcTokenExchangeRequest.ActorTokenType != "" && !tokenExchangeTokenTypeSupported(exchanger, oidcTokenExchangeRequest.ActorTokenType) {
 		return nil, nil, oidc.ErrInvalidRequest().WithDescription("actor_token_type is not supported")
 	}
 
@@ -243,6 +244,10 @@ func CreateTokenExchangeRequest(
 		return nil, unimplementedGrantError(oidc.GrantTypeTokenExchange)
 	}
 
+	if err := tokenExchangePolicy(exchanger).Check(client.GetID(), oidcTokenExchangeRequest); err != nil {
+		return nil, err
+	}
+
 	exchangeSubjectTokenIDOrToken, exchangeSubject, exchangeSubjectTokenClaims, ok := GetTokenIDAndSubjectFromToken(ctx, exchanger,
 		oidcTokenExchangeRequest.SubjectToken, oidcTokenExchangeRequest.SubjectTokenType, false)
 	if !ok {
@@ -262,12 +267,12 @@ func CreateTokenExchangeRequest(
 	}
 
 	req := &tokenExchangeRequest{
-		exchangeSubjectTokenIDOrToken: exchangeSubjectTokenIDOrToken,
+		exchangeSubjectTokenIDOrToken: security.Secret(exchangeSubjectTokenIDOrToken),
 		exchangeSubjectTokenType:      oidcTokenExchangeRequest.SubjectTokenType,
 		exchangeSubject:               exchangeSubject,
 		exchangeSubjectTokenClaims:    exchangeSubjectTokenClaims,
 
-		exchangeActorTokenIDOrToken: exchangeActorTokenIDOrToken,
+		exchangeActorTokenIDOrToken: security.Secret(exchangeActorTokenIDOrToken),
 		exchangeActorTokenType:      oidcTokenExchangeRequest.ActorTokenType,
 		exchangeActor:               exchangeActor,
 		exchangeActorTokenClaims:    exchangeActorTokenClaims,
@@ -304,6 +309,10 @@ func GetTokenIDAndSubjectFromToken(
 	ctx, span := tracer.Start(ctx, "GetTokenIDAndSubjectFromToken")
 	defer span.End()
 
+	if tokenIDOrToken, subject, claims, handled, ok := verifyTokenExchangeToken(ctx, exchanger, token, tokenType, isActor); handled {
+		return tokenIDOrToken, subject, claims, ok
+	}
+
 	switch tokenType {
 	case oidc.AccessTokenType:
 		var accessTokenClaims *oidc.AccessTokenClaims
@@ -328,6 +337,13 @@ func GetTokenIDAndSubjectFromToken(
 		tokenIDOrToken, subject, claims, ok = token, idTokenClaims.Subject, idTokenClaims.Claims, true
 	}
 
+	if !ok && !isActor {
+		tokenIDOrToken, subject, claims, ok = verifyFederatedSubjectToken(ctx, exchanger, token, tokenType)
+	}
+	if !ok && !isActor {
+		tokenIDOrToken, subject, claims, ok = verifySPIFFESubjectToken(ctx, exchanger, token, tokenType)
+	}
+
 	if !ok {
 		if verifier, ok := exchanger.Storage().(TokenExchangeTokensVerifierStorage); ok {
 			var err error
@@ -382,21 +398,24 @@ func CreateTokenExchangeResponse(
 
 	switch tokenExchangeRequest.GetRequestedTokenType() {
 	case oidc.AccessTokenType, oidc.RefreshTokenType:
+		if targets := exchangeTargets(tokenExchangeRequest); audienceSplitting(creator) && len(targets) > 1 {
+			return createSplitTokenExchangeResponse(ctx, tokenExchangeRequest, targets, client, creator)
+		}
 		token, refreshToken, validity, err = CreateAccessToken(ctx, tokenExchangeRequest, client.AccessTokenType(), creator, client, "")
 		if err != nil {
 			return nil, err
 		}
 
 		if slices.Contains(tokenExchangeRequest.GetScopes(), oidc.ScopeOpenID) {
-			tokenID, err = CreateIDToken(ctx, IssuerFromContext(ctx), tokenExchangeRequest, client.IDTokenLifetime(), "", "", creator.Storage(), client)
+			tokenID, err = createIDToken(ctx, IssuerFromContext(ctx), tokenExchangeRequest, client.IDTokenLifetime(), "", "", creator.Storage(), client, *newIDTokenOptions(creator))
 			if err != nil {
 				return nil, err
 			}
 		}
 
-		tokenType = oidc.BearerToken
+		tokenType = issuedTokenType(ctx)
 	case oidc.IDTokenType:
-		token, err = CreateIDToken(ctx, IssuerFromContext(ctx), tokenExchangeRequest, client.IDTokenLifetime(), "", "", creator.Storage(), client)
+		token, err = createIDToken(ctx, IssuerFromContext(ctx), tokenExchangeRequest, client.IDTokenLifetime(), "", "", creator.Storage(), client, *newIDTokenOptions(creator))
 		if err != nil {
 			return nil, err
 		}
@@ -422,6 +441,107 @@ func CreateTokenExchangeResponse(
 	}, nil
 }
 
+type audienceSplittingGetter interface {
+	AudienceSplitting() bool
+}
+
+// audienceSplitting reports if multi-audience token exchange requests
+// are split, see [WithAudienceSplitting].
+func audienceSplitting(v any) bool {
+	getter, ok := v.(audienceSplittingGetter)
+	return ok && getter.AudienceSplitting()
+}
+
+// exchangeTarget is a single audience or resource of a token exchange request.
+type exchangeTarget struct {
+	audience string
+	resource string
+}
+
+// exchangeTargets returns the audiences, followed by the resources of the request.
+func exchangeTargets(req TokenExchangeRequest) []exchangeTarget {
+	var targets []exchangeTarget
+	for _, audience := range req.GetAudience() {
+		targets = append(targets, exchangeTarget{audience: audience})
+	}
+	for _, resource := range req.GetResourses() {
+		targets = append(targets, exchangeTarget{resource: resource})
+	}
+	return targets
+}
+
+// splitTokenExchangeRequest restricts a TokenExchangeRequest to a single target.
+// A resource is the audience of its token as well.
+type splitTokenExchangeRequest struct {
+	TokenExchangeRequest
+	target exchangeTarget
+}
+
+func (r *splitTokenExchangeRequest) GetAudience() []string {
+	if r.target.resource != "" {
+		return []string{r.target.resource}
+	}
+	return []string{r.target.audience}
+}
+
+func (r *splitTokenExchangeRequest) GetResourses() []string {
+	if r.target.resource != "" {
+		return []string{r.target.resource}
+	}
+	return nil
+}
+
+// createSplitTokenExchangeResponse issues an access token per target,
+// see [WithAudienceSplitting].
+func createSplitTokenExchangeResponse(
+	ctx context.Context,
+	tokenExchangeRequest TokenExchangeRequest,
+	targets []exchangeTarget,
+	client Client,
+	creator TokenCreator,
+) (*oidc.TokenExchangeResponse, error) {
+	ctx, span := tracer.Start(ctx, "createSplitTokenExchangeResponse")
+	defer span.End()
+
+	tokenType := issuedTokenType(ctx)
+	issued := make([]oidc.IssuedToken, len(targets))
+	for i, target := range targets {
+		req := &splitTokenExchangeRequest{TokenExchangeRequest: tokenExchangeRequest, target: target}
+		token, refreshToken, validity, err := CreateAccessToken(ctx, req, client.AccessTokenType(), creator, client, "")
+		if err != nil {
+			return nil, err
+		}
+		issued[i] = oidc.IssuedToken{
+			Audience:        target.audience,
+			Resource:        target.resource,
+			AccessToken:     token,
+			IssuedTokenType: tokenExchangeRequest.GetRequestedTokenType(),
+			TokenType:       tokenType,
+			ExpiresIn:       uint64(validity.Seconds()),
+			RefreshToken:    refreshToken,
+		}
+	}
+
+	var tokenID string
+	if slices.Contains(tokenExchangeRequest.GetScopes(), oidc.ScopeOpenID) {
+		var err error
+		tokenID, err = createIDToken(ctx, IssuerFromContext(ctx), tokenExchangeRequest, client.IDTokenLifetime(), "", "", creator.Storage(), client, *newIDTokenOptions(creator))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &oidc.TokenExchangeResponse{
+		AccessToken:     issued[0].AccessToken,
+		IssuedTokenType: issued[0].IssuedTokenType,
+		TokenType:       tokenType,
+		ExpiresIn:       issued[0].ExpiresIn,
+		RefreshToken:    issued[0].RefreshToken,
+		IDToken:         tokenID,
+		Scopes:          tokenExchangeRequest.GetScopes(),
+		IssuedTokens:    issued,
+	}, nil
+}
+
 func getTokenIDAndClaims(ctx context.Context, userinfoProvider UserinfoProvider, accessToken string) (string, string, *oidc.AccessTokenClaims, bool) {
 	tokenIDSubject, err := userinfoProvider.Crypto().Decrypt(accessToken)
 	if err == nil {
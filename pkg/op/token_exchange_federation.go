@@ -0,0 +1,170 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// FederatedIssuer is an external issuer, whose JWTs are accepted as subject_token
+// of the token exchange grant, see [FederatedTokenExchangeConfig].
+type FederatedIssuer struct {
+	// Issuer is the iss claim of the tokens of the issuer.
+	Issuer string
+	// JWKSURI is the location of the keys of the issuer,
+	// fetched and cached by the [ClientJWKSCache] of the Provider, see [WithClientJWKSCache].
+	JWKSURI string
+	// Audience are the accepted aud values of the tokens.
+	// Defaults to the issuer of the Provider.
+	Audience []string
+	// SigningAlgorithms are the accepted algorithms of the tokens.
+	// Defaults to RS256, ES256 and PS256.
+	SigningAlgorithms []string
+}
+
+// FederatedSubjectMapper maps the verified claims of a token of an external issuer
+// to the local subject of the exchanged tokens, for example by looking up a
+// workload or user linked to the external subject.
+// Returning an error rejects the subject_token.
+type FederatedSubjectMapper func(ctx context.Context, issuer *FederatedIssuer, claims *oidc.TokenClaims, payload map[string]any) (subject string, err error)
+
+// FederatedTokenExchangeConfig accepts the JWTs of trusted external issuers as
+// subject_token of the token exchange grant (RFC 8693), see [WithFederatedTokenExchange].
+//
+// Tokens of the types JWT, ID token and access token, which are not issued
+// by the Provider, are verified with the keys of their issuer and mapped to
+// a local subject by MapSubject, before ValidateTokenExchangeRequest
+// of the [TokenExchangeStorage] is called. The claims of the external token
+// are available with GetExchangeSubjectTokenClaims of the request.
+type FederatedTokenExchangeConfig struct {
+	Issuers []FederatedIssuer
+	// MapSubject is required. Returning the sub claim unchanged
+	// trusts the external issuer with the local subjects.
+	MapSubject FederatedSubjectMapper
+	// Offset is the allowed clock skew for exp, nbf and iat.
+	Offset time.Duration
+	// MaxAgeIAT rejects tokens issued longer ago, if set.
+	MaxAgeIAT time.Duration
+}
+
+var (
+	// ErrFederatedSubjectMapper is returned by [WithFederatedTokenExchange] without MapSubject.
+	ErrFederatedSubjectMapper = errors.New("federated token exchange requires MapSubject")
+	// ErrFederatedClientJWKSCache is returned by [NewProvider] with [WithFederatedTokenExchange],
+	// but without [WithClientJWKSCache].
+	ErrFederatedClientJWKSCache = errors.New("federated token exchange requires WithClientJWKSCache")
+)
+
+var (
+	errFederatedIssuerUnknown = errors.New("issuer of the token is not trusted")
+	errFederatedTokenType     = errors.New("token type is not accepted from external issuers")
+	errFederatedNotBefore     = errors.New("token is not valid yet")
+)
+
+type federatedTokenExchangeGetter interface {
+	FederatedTokenExchange() *FederatedTokenExchangeConfig
+}
+
+func federatedTokenExchange(v any) *FederatedTokenExchangeConfig {
+	if getter, ok := v.(federatedTokenExchangeGetter); ok {
+		return getter.FederatedTokenExchange()
+	}
+	return nil
+}
+
+func (c *FederatedTokenExchangeConfig) issuer(iss string) *FederatedIssuer {
+	for i := range c.Issuers {
+		if c.Issuers[i].Issuer == iss {
+			return &c.Issuers[i]
+		}
+	}
+	return nil
+}
+
+// VerifyFederatedToken verifies a token of one of the trusted external issuers
+// and maps its subject to a local one. The audience defaults to issuer.
+func VerifyFederatedToken(ctx context.Context, config *FederatedTokenExchangeConfig, jwks *ClientJWKSCache, issuer, token string, tokenType oidc.TokenType) (subject string, claims map[string]any, err error) {
+	ctx, span := tracer.Start(ctx, "VerifyFederatedToken")
+	defer span.End()
+
+	switch tokenType {
+	case oidc.JWTTokenType, oidc.IDTokenType, oidc.AccessTokenType:
+	default:
+		return "", nil, errFederatedTokenType
+	}
+	tokenClaims := new(oidc.TokenClaims)
+	payload, err := oidc.ParseToken(token, tokenClaims)
+	if err != nil {
+		return "", nil, err
+	}
+	trusted := config.issuer(tokenClaims.Issuer)
+	if trusted == nil {
+		return "", nil, fmt.Errorf("%w: %q", errFederatedIssuerUnknown, tokenClaims.Issuer)
+	}
+	if err = oidc.CheckSubject(tokenClaims); err != nil {
+		return "", nil, err
+	}
+	audience := trusted.Audience
+	if len(audience) == 0 {
+		audience = []string{issuer}
+	}
+	if !slices.ContainsFunc(tokenClaims.Audience, func(aud string) bool { return slices.Contains(audience, aud) }) {
+		return "", nil, fmt.Errorf("%w: audience must contain one of %q", oidc.ErrAudience, audience)
+	}
+	if err = oidc.CheckExpiration(tokenClaims, config.Offset); err != nil {
+		return "", nil, err
+	}
+	if nbf := tokenClaims.NotBefore.AsTime(); !nbf.IsZero() && time.Now().Add(config.Offset).Before(nbf) {
+		return "", nil, errFederatedNotBefore
+	}
+	if config.MaxAgeIAT > 0 {
+		if err = oidc.CheckIssuedAt(tokenClaims, config.MaxAgeIAT, config.Offset); err != nil {
+			return "", nil, err
+		}
+	}
+	keySet := &federatedKeySet{cache: jwks, jwksURI: trusted.JWKSURI}
+	if err = oidc.CheckSignature(ctx, token, payload, tokenClaims, trusted.SigningAlgorithms, keySet); err != nil {
+		return "", nil, err
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return "", nil, err
+	}
+	subject, err = config.MapSubject(ctx, trusted, tokenClaims, claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return subject, claims, nil
+}
+
+// federatedKeySet implements oidc.KeySet with the keys of the jwks_uri of a [FederatedIssuer].
+type federatedKeySet struct {
+	cache   *ClientJWKSCache
+	jwksURI string
+}
+
+func (k *federatedKeySet) VerifySignature(ctx context.Context, jws *jose.JSONWebSignature) ([]byte, error) {
+	return k.cache.VerifySignature(ctx, k.jwksURI, jws)
+}
+
+// verifyFederatedSubjectToken verifies the subject_token with the [FederatedTokenExchangeConfig]
+// of the exchanger, if set.
+func verifyFederatedSubjectToken(ctx context.Context, exchanger Exchanger, token string, tokenType oidc.TokenType) (tokenIDOrToken, subject string, claims map[string]any, ok bool) {
+	config := federatedTokenExchange(exchanger)
+	jwks := clientJWKSCache(exchanger)
+	if config == nil || jwks == nil {
+		return "", "", nil, false
+	}
+	subject, claims, err := VerifyFederatedToken(ctx, config, jwks, IssuerFromContext(ctx), token, tokenType)
+	if err != nil {
+		oidc.ExplanationFromContext(ctx).Check("federated", err)
+		return "", "", nil, false
+	}
+	return token, subject, claims, true
+}
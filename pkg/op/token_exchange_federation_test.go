@@ -0,0 +1,125 @@
+package op
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestVerifyFederatedToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: key.Public(), KeyID: "1", Algorithm: "ES256", Use: oidc.KeyUseSignature},
+		}})
+	}))
+	defer server.Close()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: key, KeyID: "1"}}, nil)
+	require.NoError(t, err)
+	sign := func(claims map[string]any) string {
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+		jws, err := signer.Sign(payload)
+		require.NoError(t, err)
+		token, err := jws.CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	now := time.Now()
+	claims := func(iss, aud string, exp time.Time) map[string]any {
+		return map[string]any{"iss": iss, "sub": "repo:octo/app", "aud": aud, "iat": now.Unix(), "exp": exp.Unix()}
+	}
+
+	config := &FederatedTokenExchangeConfig{
+		Issuers: []FederatedIssuer{{Issuer: "https://ci.example.com", JWKSURI: server.URL}},
+		MapSubject: func(ctx context.Context, issuer *FederatedIssuer, claims *oidc.TokenClaims, payload map[string]any) (string, error) {
+			if claims.Subject != "repo:octo/app" {
+				return "", errors.New("unknown workload")
+			}
+			return "workload-1", nil
+		},
+	}
+	jwks := NewClientJWKSCache(server.Client())
+	const issuer = "https://op.example.com"
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType oidc.TokenType
+		wantSub   string
+		wantErr   bool
+	}{
+		{
+			name:      "mapped",
+			token:     sign(claims("https://ci.example.com", issuer, now.Add(time.Minute))),
+			tokenType: oidc.JWTTokenType,
+			wantSub:   "workload-1",
+		},
+		{
+			name:      "untrusted issuer",
+			token:     sign(claims("https://other.example.com", issuer, now.Add(time.Minute))),
+			tokenType: oidc.JWTTokenType,
+			wantErr:   true,
+		},
+		{
+			name:      "wrong audience",
+			token:     sign(claims("https://ci.example.com", "https://api.example.com", now.Add(time.Minute))),
+			tokenType: oidc.JWTTokenType,
+			wantErr:   true,
+		},
+		{
+			name:      "expired",
+			token:     sign(claims("https://ci.example.com", issuer, now.Add(-time.Minute))),
+			tokenType: oidc.JWTTokenType,
+			wantErr:   true,
+		},
+		{
+			name:      "refresh token type",
+			token:     sign(claims("https://ci.example.com", issuer, now.Add(time.Minute))),
+			tokenType: oidc.RefreshTokenType,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, got, err := VerifyFederatedToken(context.Background(), config, jwks, issuer, tt.token, tt.tokenType)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSub, sub)
+			assert.Equal(t, "repo:octo/app", got["sub"])
+		})
+	}
+}
+
+func TestWithFederatedTokenExchange(t *testing.T) {
+	o := new(Provider)
+	err := WithFederatedTokenExchange(FederatedTokenExchangeConfig{})(o)
+	assert.ErrorIs(t, err, ErrFederatedSubjectMapper)
+
+	config := FederatedTokenExchangeConfig{
+		MapSubject: func(context.Context, *FederatedIssuer, *oidc.TokenClaims, map[string]any) (string, error) {
+			return "", nil
+		},
+	}
+	_, err = NewProvider(&Config{}, nil, StaticIssuer("https://op.example.com"), WithFederatedTokenExchange(config))
+	assert.ErrorIs(t, err, ErrFederatedClientJWKSCache)
+	_, err = NewProvider(&Config{}, nil, StaticIssuer("https://op.example.com"), WithFederatedTokenExchange(config), WithClientJWKSCache(NewClientJWKSCache(nil)))
+	assert.NoError(t, err)
+}
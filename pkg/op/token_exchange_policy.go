@@ -0,0 +1,137 @@
+package op
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// TokenExchangeRule allows clients to exchange tokens for the audiences and scopes
+// of the rule, see [TokenExchangePolicy].
+// The values of Clients and Audiences may be "*" for any value,
+// or end with "*" for any value with the prefix, like https://api.example.com/*.
+type TokenExchangeRule struct {
+	// Clients are the client IDs the rule applies to. Required.
+	Clients []string
+	// Audiences are the audience and resource values the clients may request. Required.
+	Audiences []string
+	// Scopes are the scopes the clients may request for the audiences, any if empty.
+	Scopes []string
+	// SubjectTokenTypes are the accepted types of the subject_token, any if empty.
+	SubjectTokenTypes []oidc.TokenType
+}
+
+// TokenExchangePolicy is the trust matrix of the token exchange grant (RFC 8693),
+// see [WithTokenExchangePolicy]. It is evaluated before the subject and actor
+// tokens are verified and ValidateTokenExchangeRequest of the [TokenExchangeStorage]
+// is called, which can still apply further checks.
+//
+// Each requested audience and resource must be allowed for the client by a rule,
+// else the request is rejected with invalid_target. The requested scopes must be
+// allowed by the rules of every requested audience and resource, else the request
+// is rejected with invalid_scope. Requests without audience and resource are
+// allowed for clients with any rule, restricted to the scopes of its rules.
+// Clients without rule may not exchange tokens at all.
+type TokenExchangePolicy struct {
+	Rules []TokenExchangeRule
+}
+
+// ErrTokenExchangeRule is returned by [WithTokenExchangePolicy] for rules without clients or audiences.
+var ErrTokenExchangeRule = errors.New("token exchange rule requires clients and audiences")
+
+func (p *TokenExchangePolicy) validate() error {
+	for i, rule := range p.Rules {
+		if len(rule.Clients) == 0 || len(rule.Audiences) == 0 {
+			return fmt.Errorf("%w: rule %d", ErrTokenExchangeRule, i)
+		}
+	}
+	return nil
+}
+
+type tokenExchangePolicyGetter interface {
+	TokenExchangePolicy() *TokenExchangePolicy
+}
+
+func tokenExchangePolicy(v any) *TokenExchangePolicy {
+	if getter, ok := v.(tokenExchangePolicyGetter); ok {
+		return getter.TokenExchangePolicy()
+	}
+	return nil
+}
+
+// Check returns an [oidc.Error], if the policy does not allow the client
+// to exchange the subject token type for the audiences, resources and scopes
+// of the request. A nil policy allows all requests.
+func (p *TokenExchangePolicy) Check(clientID string, request *oidc.TokenExchangeRequest) error {
+	if p == nil {
+		return nil
+	}
+	var rules []*TokenExchangeRule
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if policyMatch(rule.Clients, clientID) &&
+			(len(rule.SubjectTokenTypes) == 0 || slices.Contains(rule.SubjectTokenTypes, request.SubjectTokenType)) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return oidc.ErrUnauthorizedClient().WithDescription(
+			"client %s may not exchange tokens of type %s", clientID, request.SubjectTokenType)
+	}
+	targets := slices.Concat(request.Audience, request.Resource)
+	if len(targets) == 0 {
+		return checkTokenExchangeScopes(rules, request.Scopes, "")
+	}
+	for _, target := range targets {
+		var targetRules []*TokenExchangeRule
+		for _, rule := range rules {
+			if policyMatch(rule.Audiences, target) {
+				targetRules = append(targetRules, rule)
+			}
+		}
+		if len(targetRules) == 0 {
+			return oidc.ErrInvalidTarget().WithDescription(
+				"client %s may not exchange tokens for the target %s", clientID, target)
+		}
+		if err := checkTokenExchangeScopes(targetRules, request.Scopes, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTokenExchangeScopes returns an invalid_scope error for the first scope,
+// which none of the rules allows.
+func checkTokenExchangeScopes(rules []*TokenExchangeRule, scopes []string, target string) error {
+	for _, scope := range scopes {
+		allowed := slices.ContainsFunc(rules, func(rule *TokenExchangeRule) bool {
+			return len(rule.Scopes) == 0 || slices.Contains(rule.Scopes, scope)
+		})
+		if allowed {
+			continue
+		}
+		if target == "" {
+			return oidc.ErrInvalidScope().WithDescription("scope %s may not be requested by token exchange", scope)
+		}
+		return oidc.ErrInvalidScope().WithDescription("scope %s may not be requested for the target %s", scope, target)
+	}
+	return nil
+}
+
+// policyMatch reports if the value matches one of the patterns,
+// which are exact values, "*" or prefixes ending with "*".
+func policyMatch(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(value, prefix) {
+				return true
+			}
+		} else if pattern == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package op_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestTokenExchangePolicy_Check(t *testing.T) {
+	policy := &op.TokenExchangePolicy{Rules: []op.TokenExchangeRule{
+		{
+			Clients:   []string{"gateway"},
+			Audiences: []string{"orders", "https://api.example.com/*"},
+			Scopes:    []string{"read", "write"},
+		},
+		{
+			Clients:   []string{"gateway"},
+			Audiences: []string{"billing"},
+			Scopes:    []string{"read"},
+		},
+		{
+			Clients:           []string{"*"},
+			Audiences:         []string{"directory"},
+			SubjectTokenTypes: []oidc.TokenType{oidc.AccessTokenType},
+		},
+	}}
+	tests := []struct {
+		name     string
+		clientID string
+		request  oidc.TokenExchangeRequest
+		wantErr  func() *oidc.Error
+		wantDesc string
+	}{
+		{
+			name:     "audience and resource",
+			clientID: "gateway",
+			request: oidc.TokenExchangeRequest{
+				Audience: []string{"orders"},
+				Resource: []string{"https://api.example.com/inventory"},
+				Scopes:   []string{"write"},
+			},
+		},
+		{
+			name:     "scope of other rule of the client",
+			clientID: "gateway",
+			request:  oidc.TokenExchangeRequest{Scopes: []string{"write"}},
+		},
+		{
+			name:     "any client and scope",
+			clientID: "batch",
+			request: oidc.TokenExchangeRequest{
+				SubjectTokenType: oidc.AccessTokenType,
+				Audience:         []string{"directory"},
+				Scopes:           []string{"admin"},
+			},
+		},
+		{
+			name:     "unknown target",
+			clientID: "gateway",
+			request:  oidc.TokenExchangeRequest{Audience: []string{"orders", "payroll"}},
+			wantErr:  oidc.ErrInvalidTarget,
+			wantDesc: "client gateway may not exchange tokens for the target payroll",
+		},
+		{
+			name:     "scope not allowed for target",
+			clientID: "gateway",
+			request: oidc.TokenExchangeRequest{
+				Audience: []string{"orders", "billing"},
+				Scopes:   []string{"read", "write"},
+			},
+			wantErr:  oidc.ErrInvalidScope,
+			wantDesc: "scope write may not be requested for the target billing",
+		},
+		{
+			name:     "subject token type",
+			clientID: "batch",
+			request: oidc.TokenExchangeRequest{
+				SubjectTokenType: oidc.IDTokenType,
+				Audience:         []string{"directory"},
+			},
+			wantErr: oidc.ErrUnauthorizedClient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.clientID, &tt.request)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			var oidcErr *oidc.Error
+			require.ErrorAs(t, err, &oidcErr)
+			assert.Equal(t, tt.wantErr().ErrorType, oidcErr.ErrorType)
+			if tt.wantDesc != "" {
+				assert.Equal(t, tt.wantDesc, oidcErr.Description)
+			}
+		})
+	}
+
+	var nilPolicy *op.TokenExchangePolicy
+	assert.NoError(t, nilPolicy.Check("gateway", &oidc.TokenExchangeRequest{Audience: []string{"payroll"}}))
+}
+
+func TestWithTokenExchangePolicy(t *testing.T) {
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+
+	_, err := op.NewOpenIDProvider(testIssuer, testConfig, s,
+		op.WithTokenExchangePolicy(op.TokenExchangePolicy{Rules: []op.TokenExchangeRule{{Clients: []string{"web"}}}}),
+	)
+	require.ErrorIs(t, err, op.ErrTokenExchangeRule)
+
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s, op.WithAllowInsecure(),
+		op.WithTokenExchangePolicy(op.TokenExchangePolicy{Rules: []op.TokenExchangeRule{
+			{Clients: []string{"web"}, Audiences: []string{"orders"}},
+		}}),
+	)
+	require.NoError(t, err)
+	client, err := s.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+
+	// the policy is evaluated before the subject token is verified
+	_, err = op.CreateTokenExchangeRequest(ctx, &oidc.TokenExchangeRequest{
+		SubjectToken:     "invalid",
+		SubjectTokenType: oidc.AccessTokenType,
+		Audience:         []string{"billing"},
+	}, client, provider)
+	var oidcErr *oidc.Error
+	require.ErrorAs(t, err, &oidcErr)
+	assert.Equal(t, oidc.InvalidTarget, oidcErr.ErrorType)
+
+	_, err = op.CreateTokenExchangeRequest(ctx, &oidc.TokenExchangeRequest{
+		SubjectToken:     "invalid",
+		SubjectTokenType: oidc.AccessTokenType,
+		Audience:         []string{"orders"},
+	}, client, provider)
+	require.ErrorAs(t, err, &oidcErr)
+	assert.Equal(t, "subject_token is invalid", oidcErr.Description)
+}
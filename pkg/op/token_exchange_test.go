@@ -0,0 +1,102 @@
+package op_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+type jwtClient struct {
+	op.Client
+}
+
+func (jwtClient) AccessTokenType() op.AccessTokenType { return op.AccessTokenTypeJWT }
+
+type testTokenExchangeRequest struct {
+	op.TokenExchangeRequest
+	audience []string
+	resource []string
+}
+
+func (r *testTokenExchangeRequest) GetAMR() []string       { return nil }
+func (r *testTokenExchangeRequest) GetAudience() []string  { return r.audience }
+func (r *testTokenExchangeRequest) GetResourses() []string { return r.resource }
+func (r *testTokenExchangeRequest) GetAuthTime() time.Time { return time.Time{} }
+func (r *testTokenExchangeRequest) GetClientID() string    { return "web" }
+func (r *testTokenExchangeRequest) GetScopes() []string    { return []string{"api"} }
+func (r *testTokenExchangeRequest) GetSubject() string     { return "id1" }
+func (r *testTokenExchangeRequest) GetRequestedTokenType() oidc.TokenType {
+	return oidc.AccessTokenType
+}
+
+func TestCreateTokenExchangeResponse_audienceSplitting(t *testing.T) {
+	ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	webClient, err := s.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+	client := jwtClient{webClient}
+	request := &testTokenExchangeRequest{
+		audience: []string{"orders", "billing"},
+		resource: []string{"https://inventory.example.com"},
+	}
+
+	tests := []struct {
+		name      string
+		options   []op.Option
+		wantAuds  []oidc.Audience
+		wantSplit bool
+	}{
+		{
+			name:     "single token",
+			wantAuds: []oidc.Audience{{"orders", "billing"}},
+		},
+		{
+			name:    "split",
+			options: []op.Option{op.WithAudienceSplitting()},
+			wantAuds: []oidc.Audience{
+				{"orders"},
+				{"billing"},
+				{"https://inventory.example.com"},
+			},
+			wantSplit: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s, append(tt.options, op.WithAllowInsecure())...)
+			require.NoError(t, err)
+
+			resp, err := op.CreateTokenExchangeResponse(ctx, request, client, provider)
+			require.NoError(t, err)
+
+			tokens := []string{resp.AccessToken}
+			if tt.wantSplit {
+				require.Len(t, resp.IssuedTokens, len(tt.wantAuds))
+				assert.Equal(t, resp.IssuedTokens[0].AccessToken, resp.AccessToken)
+				assert.Equal(t, "billing", resp.IssuedTokens[1].Audience)
+				assert.Equal(t, "https://inventory.example.com", resp.IssuedTokens[2].Resource)
+				tokens = tokens[:0]
+				for _, issued := range resp.IssuedTokens {
+					assert.Equal(t, oidc.AccessTokenType, issued.IssuedTokenType)
+					assert.NotZero(t, issued.ExpiresIn)
+					tokens = append(tokens, issued.AccessToken)
+				}
+			} else {
+				assert.Empty(t, resp.IssuedTokens)
+			}
+			for i, token := range tokens {
+				claims := new(oidc.AccessTokenClaims)
+				_, err = oidc.ParseToken(token, claims)
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantAuds[i], claims.Audience)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package op
+
+import (
+	"context"
+	"slices"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// TokenExchangeTokenVerifier verifies a subject_token or actor_token of the token
+// exchange grant of one token type, see [WithTokenExchangeTokenVerifier].
+// It returns the token ID or the token itself, the subject of the token and its claims.
+//
+// SAML assertions (RFC 8693, section 3) are passed base64url-encoded,
+// as sent by the client, and can be decoded by [oidc.DecodeSAMLAssertion].
+type TokenExchangeTokenVerifier func(ctx context.Context, token string, isActor bool) (tokenIDOrToken, subject string, claims map[string]any, err error)
+
+type tokenExchangeTokenVerifiersGetter interface {
+	TokenExchangeTokenVerifiers() map[oidc.TokenType]TokenExchangeTokenVerifier
+}
+
+func tokenExchangeTokenVerifier(v any, tokenType oidc.TokenType) TokenExchangeTokenVerifier {
+	if getter, ok := v.(tokenExchangeTokenVerifiersGetter); ok {
+		return getter.TokenExchangeTokenVerifiers()[tokenType]
+	}
+	return nil
+}
+
+// tokenExchangeTokenTypeSupported reports if tokens of the type are accepted
+// as subject_token or actor_token, either by the built-in verification
+// or a [TokenExchangeTokenVerifier].
+func tokenExchangeTokenTypeSupported(v any, tokenType oidc.TokenType) bool {
+	return tokenType.IsSupported() || tokenExchangeTokenVerifier(v, tokenType) != nil
+}
+
+// verifyTokenExchangeToken verifies the token with the [TokenExchangeTokenVerifier]
+// registered for its type, if any.
+func verifyTokenExchangeToken(ctx context.Context, exchanger Exchanger, token string, tokenType oidc.TokenType, isActor bool) (tokenIDOrToken, subject string, claims map[string]any, handled, ok bool) {
+	verifier := tokenExchangeTokenVerifier(exchanger, tokenType)
+	if verifier == nil {
+		return "", "", nil, false, false
+	}
+	tokenIDOrToken, subject, claims, err := verifier(ctx, token, isActor)
+	if err != nil {
+		oidc.ExplanationFromContext(ctx).Check("token_type", err)
+		return "", "", nil, true, false
+	}
+	return tokenIDOrToken, subject, claims, true, true
+}
+
+// tokenExchangeTokenTypes returns the token types of the registered verifiers,
+// to be passed to [WithServerTokenExchangeTokenTypes].
+func tokenExchangeTokenTypes(verifiers map[oidc.TokenType]TokenExchangeTokenVerifier) []oidc.TokenType {
+	types := make([]oidc.TokenType, 0, len(verifiers))
+	for tokenType := range verifiers {
+		types = append(types, tokenType)
+	}
+	slices.Sort(types)
+	return types
+}
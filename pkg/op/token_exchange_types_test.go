@@ -0,0 +1,51 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type tokenTypeExchanger struct {
+	Exchanger
+	verifiers map[oidc.TokenType]TokenExchangeTokenVerifier
+}
+
+func (e *tokenTypeExchanger) TokenExchangeTokenVerifiers() map[oidc.TokenType]TokenExchangeTokenVerifier {
+	return e.verifiers
+}
+
+func TestGetTokenIDAndSubjectFromToken_verifier(t *testing.T) {
+	assertion := oidc.EncodeSAMLAssertion([]byte(`<saml:Assertion ID="a1"><saml:Subject>alice</saml:Subject></saml:Assertion>`))
+	exchanger := &tokenTypeExchanger{verifiers: map[oidc.TokenType]TokenExchangeTokenVerifier{
+		oidc.SAML2TokenType: func(ctx context.Context, token string, isActor bool) (string, string, map[string]any, error) {
+			xml, err := oidc.DecodeSAMLAssertion(token)
+			if err != nil {
+				return "", "", nil, err
+			}
+			if isActor {
+				return "", "", nil, errors.New("no actor assertions")
+			}
+			return "a1", "alice", map[string]any{"assertion_length": len(xml)}, nil
+		},
+	}}
+
+	assert.True(t, tokenExchangeTokenTypeSupported(exchanger, oidc.SAML2TokenType))
+	assert.True(t, tokenExchangeTokenTypeSupported(exchanger, oidc.AccessTokenType))
+	assert.False(t, tokenExchangeTokenTypeSupported(exchanger, oidc.SAML1TokenType))
+
+	tokenID, subject, claims, ok := GetTokenIDAndSubjectFromToken(context.Background(), exchanger, assertion, oidc.SAML2TokenType, false)
+	assert.True(t, ok)
+	assert.Equal(t, "a1", tokenID)
+	assert.Equal(t, "alice", subject)
+	assert.NotZero(t, claims["assertion_length"])
+
+	_, _, _, ok = GetTokenIDAndSubjectFromToken(context.Background(), exchanger, assertion, oidc.SAML2TokenType, true)
+	assert.False(t, ok)
+	_, _, _, ok = GetTokenIDAndSubjectFromToken(context.Background(), exchanger, "%%%", oidc.SAML2TokenType, false)
+	assert.False(t, ok)
+}
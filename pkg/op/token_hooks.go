@@ -0,0 +1,278 @@
+package op
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// TokenHookRequest are the token requests of the grant types, which can be hooked
+// with [TokenHooks]. The grant type of the hooks is the grant type of the request:
+//   - [oidc.AccessTokenRequest]: authorization_code
+//   - [oidc.RefreshTokenRequest]: refresh_token
+//   - [oidc.JWTProfileGrantRequest]: urn:ietf:params:oauth:grant-type:jwt-bearer
+//   - [oidc.TokenExchangeRequest]: urn:ietf:params:oauth:grant-type:token-exchange
+//   - [oidc.ClientCredentialsRequest]: client_credentials
+//   - [oidc.DeviceAccessTokenRequest]: urn:ietf:params:oauth:grant-type:device_code
+//   - [oidc.BackchannelTokenRequest]: urn:openid:params:grant-type:ciba
+//   - [oidc.SessionTransferTokenRequest]: the session transfer grant
+type TokenHookRequest interface {
+	oidc.AccessTokenRequest | oidc.RefreshTokenRequest | oidc.JWTProfileGrantRequest |
+		oidc.TokenExchangeRequest | oidc.ClientCredentialsRequest | oidc.DeviceAccessTokenRequest |
+		oidc.BackchannelTokenRequest | oidc.SessionTransferTokenRequest
+}
+
+// TokenHookContext is passed to the [TokenHooks] of a token request.
+type TokenHookContext[R TokenHookRequest] struct {
+	GrantType oidc.GrantType
+	// ClientID is the client_id of the form or the basic authorization, if any.
+	// The client is not authenticated yet, when the Validate hook is called.
+	ClientID string
+	// Request is the token request decoded from the form.
+	// Changes are not passed to the grant handler.
+	Request *R
+	// HTTPRequest is the request to the token endpoint.
+	HTTPRequest *http.Request
+}
+
+// TokenHooks extend the handling of a grant type at the token endpoint,
+// see [WithTokenHooks], e.g. for policy checks, analytics or additional response members,
+// without reimplementing the grant handler. All hooks are optional.
+//
+// Errors returned by the hooks are returned to the client, an [oidc.Error]
+// as is, other errors as server_error.
+type TokenHooks[R TokenHookRequest] struct {
+	// Validate is called before the grant handler, a returned error rejects the request.
+	Validate func(ctx context.Context, hc *TokenHookContext[R]) error
+	// Issue is called with the validated request of the grant handler, after the client
+	// was authenticated and before the access and refresh tokens are created by the [Storage].
+	// A returned error vetoes the issuance of the tokens.
+	Issue func(ctx context.Context, hc *TokenHookContext[R], tokenRequest TokenRequest) error
+	// Decorate is called with the members of the successful JSON response,
+	// which it may change, add or delete. A returned error replaces the response,
+	// the tokens have been issued already though.
+	// Decorate is not called for streamed device access token responses.
+	Decorate func(ctx context.Context, hc *TokenHookContext[R], response map[string]any) error
+}
+
+// TokenEndpointHooks are the [TokenHooks] of the token endpoint for all grant types,
+// see [AddTokenHooks] and [WithServerTokenHooks].
+type TokenEndpointHooks struct {
+	hooks map[oidc.GrantType][]tokenHook
+}
+
+// NewTokenEndpointHooks returns empty [TokenEndpointHooks].
+func NewTokenEndpointHooks() *TokenEndpointHooks {
+	return &TokenEndpointHooks{hooks: make(map[oidc.GrantType][]tokenHook)}
+}
+
+// AddTokenHooks adds the hooks for the grant type of R.
+// Hooks of the same grant type are called in the order they were added.
+func AddTokenHooks[R TokenHookRequest](h *TokenEndpointHooks, hooks TokenHooks[R]) {
+	grantType := tokenHookGrantType[R]()
+	h.hooks[grantType] = append(h.hooks[grantType], hooks)
+}
+
+// WithTokenHooks adds the hooks for the grant type of R to the token endpoint,
+// see [TokenHooks]. It may be passed multiple times, the hooks are called in order.
+func WithTokenHooks[R TokenHookRequest](hooks TokenHooks[R]) Option {
+	return func(o *Provider) error {
+		if o.tokenHooks == nil {
+			o.tokenHooks = NewTokenEndpointHooks()
+		}
+		AddTokenHooks(o.tokenHooks, hooks)
+		return nil
+	}
+}
+
+func tokenHookGrantType[R TokenHookRequest]() oidc.GrantType {
+	switch any(new(R)).(type) {
+	case *oidc.AccessTokenRequest:
+		return oidc.GrantTypeCode
+	case *oidc.RefreshTokenRequest:
+		return oidc.GrantTypeRefreshToken
+	case *oidc.JWTProfileGrantRequest:
+		return oidc.GrantTypeBearer
+	case *oidc.TokenExchangeRequest:
+		return oidc.GrantTypeTokenExchange
+	case *oidc.ClientCredentialsRequest:
+		return oidc.GrantTypeClientCredentials
+	case *oidc.DeviceAccessTokenRequest:
+		return oidc.GrantTypeDeviceCode
+	case *oidc.BackchannelTokenRequest:
+		return oidc.GrantTypeCIBA
+	default:
+		return oidc.GrantTypeSessionTransfer
+	}
+}
+
+// tokenHook starts the hooks of a token request.
+type tokenHook interface {
+	begin(ctx context.Context, r *http.Request, clientID string, decoder httphelper.Decoder) (*tokenHookCall, error)
+}
+
+// tokenHookCall are the Issue and Decorate hooks bound to the context of a token request.
+type tokenHookCall struct {
+	issue    func(ctx context.Context, tokenRequest TokenRequest) error
+	decorate func(ctx context.Context, response map[string]any) error
+}
+
+func (hooks TokenHooks[R]) begin(ctx context.Context, r *http.Request, clientID string, decoder httphelper.Decoder) (*tokenHookCall, error) {
+	request := new(R)
+	if err := decoder.Decode(request, r.Form); err != nil {
+		return nil, oidc.ErrInvalidRequest().WithDescription("error decoding form").WithParent(err)
+	}
+	hc := &TokenHookContext[R]{
+		GrantType:   tokenHookGrantType[R](),
+		ClientID:    clientID,
+		Request:     request,
+		HTTPRequest: r,
+	}
+	if hooks.Validate != nil {
+		if err := hooks.Validate(ctx, hc); err != nil {
+			return nil, err
+		}
+	}
+	call := new(tokenHookCall)
+	if hooks.Issue != nil {
+		call.issue = func(ctx context.Context, tokenRequest TokenRequest) error {
+			return hooks.Issue(ctx, hc, tokenRequest)
+		}
+	}
+	if hooks.Decorate != nil {
+		call.decorate = func(ctx context.Context, response map[string]any) error {
+			return hooks.Decorate(ctx, hc, response)
+		}
+	}
+	return call, nil
+}
+
+type tokenHooksGetter interface {
+	TokenHooks() *TokenEndpointHooks
+}
+
+func providerTokenHooks(v any) *TokenEndpointHooks {
+	if g, ok := v.(tokenHooksGetter); ok {
+		return g.TokenHooks()
+	}
+	return nil
+}
+
+type tokenHookCallsKey struct{}
+
+// issueTokenHooks calls the Issue hooks of the token request of the context.
+func issueTokenHooks(ctx context.Context, tokenRequest TokenRequest) error {
+	calls, _ := ctx.Value(tokenHookCallsKey{}).([]*tokenHookCall)
+	for _, call := range calls {
+		if call.issue == nil {
+			continue
+		}
+		if err := call.issue(ctx, tokenRequest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenHooksHandler calls the hooks of the grant type of the token request around the handler.
+func tokenHooksHandler(hooks *TokenEndpointHooks, decoder func() httphelper.Decoder, writeError func(http.ResponseWriter, *http.Request, error), handler http.HandlerFunc) http.HandlerFunc {
+	if hooks == nil || len(hooks.hooks) == 0 {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeError(w, r, oidc.ErrInvalidRequest().WithDescription("error parsing form").WithParent(err))
+			return
+		}
+		grantHooks := hooks.hooks[oidc.GrantType(r.FormValue(oidc.ParamGrantType))]
+		if len(grantHooks) == 0 {
+			handler(w, r)
+			return
+		}
+		ctx, span := tracer.Start(r.Context(), "tokenHooks")
+		clientID, _, ok := r.BasicAuth()
+		if ok {
+			clientID, _ = url.QueryUnescape(clientID)
+		} else {
+			clientID = r.FormValue(oidc.ParamClientID)
+		}
+		calls := make([]*tokenHookCall, 0, len(grantHooks))
+		decorate := false
+		for _, hook := range grantHooks {
+			call, err := hook.begin(ctx, r, clientID, decoder())
+			if err != nil {
+				span.End()
+				writeError(w, r, err)
+				return
+			}
+			calls = append(calls, call)
+			decorate = decorate || call.decorate != nil
+		}
+		span.End()
+		r = r.WithContext(context.WithValue(r.Context(), tokenHookCallsKey{}, calls))
+		if !decorate || acceptsEventStream(r.Header) {
+			handler(w, r)
+			return
+		}
+
+		buffered := &bufferedResponseWriter{header: w.Header()}
+		handler(buffered, r)
+		mediaType, _, _ := mime.ParseMediaType(buffered.header.Get("Content-Type"))
+		if buffered.statusCode != http.StatusOK || mediaType != ContentTypeJSON {
+			buffered.writeTo(w)
+			return
+		}
+		response := make(map[string]any)
+		jsonDecoder := json.NewDecoder(&buffered.body)
+		jsonDecoder.UseNumber()
+		if err := jsonDecoder.Decode(&response); err != nil {
+			writeError(w, r, oidc.ErrServerError().WithParent(err))
+			return
+		}
+		for _, call := range calls {
+			if call.decorate == nil {
+				continue
+			}
+			if err := call.decorate(r.Context(), response); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		WriteJSON(w, response, http.StatusOK)
+	}
+}
+
+// bufferedResponseWriter buffers the status code and body of a response,
+// writing the header to the header of the underlying writer.
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) writeTo(rw http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+	rw.WriteHeader(w.statusCode)
+	_, _ = w.body.WriteTo(rw)
+}
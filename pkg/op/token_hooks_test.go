@@ -0,0 +1,112 @@
+package op_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func testTokenHooks(calls *[]string) op.TokenHooks[oidc.ClientCredentialsRequest] {
+	return op.TokenHooks[oidc.ClientCredentialsRequest]{
+		Validate: func(_ context.Context, hc *op.TokenHookContext[oidc.ClientCredentialsRequest]) error {
+			*calls = append(*calls, "validate "+hc.ClientID)
+			if slices.Contains(hc.Request.Scope, "forbidden") {
+				return oidc.ErrInvalidScope().WithDescription("scope forbidden denied by policy")
+			}
+			return nil
+		},
+		Issue: func(_ context.Context, hc *op.TokenHookContext[oidc.ClientCredentialsRequest], tokenRequest op.TokenRequest) error {
+			*calls = append(*calls, "issue "+tokenRequest.GetSubject())
+			if slices.Contains(hc.Request.Scope, "veto") {
+				return oidc.ErrAccessDenied().WithDescription("issuance vetoed")
+			}
+			return nil
+		},
+		Decorate: func(_ context.Context, hc *op.TokenHookContext[oidc.ClientCredentialsRequest], response map[string]any) error {
+			*calls = append(*calls, "decorate "+string(hc.GrantType))
+			response["tenant"] = "example"
+			return nil
+		},
+	}
+}
+
+func TestWithTokenHooks(t *testing.T) {
+	var calls []string
+	refreshCalled := false
+	provider, err := op.NewOpenIDProvider(testIssuer, testConfig, storage.NewStorage(storage.NewUserStore(testIssuer)),
+		op.WithAllowInsecure(),
+		op.WithTokenHooks(testTokenHooks(&calls)),
+		op.WithTokenHooks(op.TokenHooks[oidc.RefreshTokenRequest]{
+			Validate: func(context.Context, *op.TokenHookContext[oidc.RefreshTokenRequest]) error {
+				refreshCalled = true
+				return nil
+			},
+		}),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{
+			name:    "provider",
+			handler: provider,
+		},
+		{
+			name: "server",
+			handler: func() http.Handler {
+				hooks := op.NewTokenEndpointHooks()
+				op.AddTokenHooks(hooks, testTokenHooks(&calls))
+				return op.RegisterServer(op.NewLegacyServer(provider, *op.DefaultEndpoints), *op.DefaultEndpoints,
+					op.WithServerTokenHooks(hooks),
+				)
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := func(scope string) *httptest.ResponseRecorder {
+				calls = nil
+				form := url.Values{"grant_type": {string(oidc.GrantTypeClientCredentials)}, "scope": {scope}}
+				req := httptest.NewRequest(http.MethodPost, testIssuer+"oauth/token", strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				req.SetBasicAuth("sid1", "verysecret")
+				rec := httptest.NewRecorder()
+				tt.handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			rec := post("openid")
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var response map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, "example", response["tenant"])
+			assert.NotEmpty(t, response["access_token"])
+			assert.Equal(t, []string{"validate sid1", "issue sid1", "decorate client_credentials"}, calls)
+
+			rec = post("openid forbidden")
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "denied by policy")
+			assert.Equal(t, []string{"validate sid1"}, calls)
+
+			rec = post("openid veto")
+			assert.NotEqual(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), "issuance vetoed")
+			assert.Equal(t, []string{"validate sid1", "issue sid1"}, calls)
+		})
+	}
+	assert.False(t, refreshCalled)
+}
@@ -27,29 +27,114 @@ func introspectionHandler(introspector Introspector) func(http.ResponseWriter, *
 	}
 }
 
+// IntrospectionClaims is the constraint for custom introspection response types.
+// SetActive(true) is called after the claims were successfully returned by the storage.
+type IntrospectionClaims interface {
+	SetActive(active bool)
+}
+
+// IntrospectionStorage is an optional alternative to the SetIntrospectionFromToken method of [Storage],
+// which returns the introspection response as custom type C.
+// It can be set to a [Provider] using [WithIntrospectionStorage], or used directly with [IntrospectClaims].
+type IntrospectionStorage[C IntrospectionClaims] interface {
+	IntrospectionFromToken(ctx context.Context, tokenID, subject, clientID string) (C, error)
+}
+
+type introspectionFunc func(ctx context.Context, tokenID, subject, clientID string) (any, error)
+
+func newIntrospectionFunc[C IntrospectionClaims](storage IntrospectionStorage[C]) introspectionFunc {
+	return func(ctx context.Context, tokenID, subject, clientID string) (any, error) {
+		response, err := storage.IntrospectionFromToken(ctx, tokenID, subject, clientID)
+		if err != nil {
+			return nil, err
+		}
+		response.SetActive(true)
+		return response, nil
+	}
+}
+
+type introspectionFuncGetter interface {
+	introspectionFunc() introspectionFunc
+}
+
+// getIntrospectionFunc returns the function set by [WithIntrospectionStorage],
+// or defaults to the SetIntrospectionFromToken method of the Storage.
+func getIntrospectionFunc(introspector Introspector) introspectionFunc {
+	if g, ok := introspector.(introspectionFuncGetter); ok {
+		if f := g.introspectionFunc(); f != nil {
+			return f
+		}
+	}
+	return func(ctx context.Context, tokenID, subject, clientID string) (any, error) {
+		response := new(oidc.IntrospectionResponse)
+		if err := introspector.Storage().SetIntrospectionFromToken(ctx, response, tokenID, subject, clientID); err != nil {
+			return nil, err
+		}
+		response.Active = true
+		return response, nil
+	}
+}
+
 func Introspect(w http.ResponseWriter, r *http.Request, introspector Introspector) {
 	ctx, span := tracer.Start(r.Context(), "Introspect")
 	defer span.End()
 	r = r.WithContext(ctx)
 
-	response := new(oidc.IntrospectionResponse)
+	introspect(w, r, introspector, getIntrospectionFunc(introspector))
+}
+
+// IntrospectClaims handles the introspection request like [Introspect],
+// but obtains the response of type C from the passed storage.
+func IntrospectClaims[C IntrospectionClaims](w http.ResponseWriter, r *http.Request, introspector Introspector, storage IntrospectionStorage[C]) {
+	ctx, span := tracer.Start(r.Context(), "IntrospectClaims")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	introspect(w, r, introspector, newIntrospectionFunc(storage))
+}
+
+func introspect(w http.ResponseWriter, r *http.Request, introspector Introspector, fromToken introspectionFunc) {
 	token, clientID, err := ParseTokenIntrospectionRequest(r, introspector)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		WriteText(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	tokenID, subject, ok := getTokenIDAndSubject(r.Context(), introspector, token)
+	response, ok := introspectToken(r.Context(), introspector, fromToken, token, clientID)
 	if !ok {
-		httphelper.MarshalJSON(w, response)
+		WriteJSON(w, new(oidc.IntrospectionResponse), http.StatusOK)
 		return
 	}
-	err = introspector.Storage().SetIntrospectionFromToken(r.Context(), response, tokenID, subject, clientID)
+	WriteJSON(w, response, http.StatusOK)
+}
+
+// introspectToken returns the active introspection response for the token,
+// or false if the token is inactive. Service tokens are introspected
+// uniformly, if the Storage implements [ServiceTokenStorage].
+func introspectToken(ctx context.Context, introspector Introspector, fromToken introspectionFunc, token, clientID string) (any, bool) {
+	tokenID, subject, tokenClientID, ok := getTokenIDAndSubject(ctx, introspector, token)
+	if !ok {
+		return nil, false
+	}
+	if response, ok := introspectServiceToken(ctx, introspector, tokenID, clientID); ok {
+		return response, response != nil
+	}
+	response, err := fromToken(ctx, tokenID, subject, clientID)
 	if err != nil {
-		httphelper.MarshalJSON(w, response)
-		return
+		return nil, false
+	}
+	if introspection, ok := response.(*oidc.IntrospectionResponse); ok {
+		if introspection.ClientID != "" {
+			tokenClientID = introspection.ClientID
+		}
+		introspection.Subject, err = externalSubject(ctx, introspector.Storage(), introspection.Subject, tokenClientID)
+		if err != nil {
+			return nil, false
+		}
+		if scopeArrayClaim(introspector) && len(introspection.ScopeArray) == 0 {
+			introspection.ScopeArray = oidc.ScopeArray(introspection.Scope)
+		}
 	}
-	response.Active = true
-	httphelper.MarshalJSON(w, response)
+	return response, true
 }
 
 func ParseTokenIntrospectionRequest(r *http.Request, introspector Introspector) (token, clientID string, err error) {
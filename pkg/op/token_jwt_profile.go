@@ -41,7 +41,7 @@ func JWTProfile(w http.ResponseWriter, r *http.Request, exchanger JWTAuthorizati
 		RequestError(w, r, err, exchanger.Logger())
 		return
 	}
-	httphelper.MarshalJSON(w, resp)
+	WriteJSON(w, resp, http.StatusOK)
 }
 
 func ParseJWTProfileGrantRequest(r *http.Request, decoder httphelper.Decoder) (*oidc.JWTProfileGrantRequest, error) {
@@ -87,7 +87,7 @@ func CreateJWTTokenResponse(ctx context.Context, tokenRequest TokenRequest, crea
 	}
 	return &oidc.AccessTokenResponse{
 		AccessToken: accessToken,
-		TokenType:   oidc.BearerToken,
+		TokenType:   issuedTokenType(ctx),
 		ExpiresIn:   uint64(validity.Seconds()),
 		Scope:       tokenRequest.GetScopes(),
 	}, nil
@@ -21,6 +21,51 @@ type RefreshTokenRequest interface {
 	SetCurrentScopes(scopes []string)
 }
 
+// RefreshIDToken defines if an ID token is issued on refresh_token grants.
+type RefreshIDToken int
+
+const (
+	// RefreshIDTokenAlways issues a new ID token on every refresh_token grant.
+	RefreshIDTokenAlways RefreshIDToken = iota
+	// RefreshIDTokenOpenIDScope only issues a new ID token,
+	// if the openid scope is part of the (current) scopes of the refresh token request.
+	RefreshIDTokenOpenIDScope
+	// RefreshIDTokenNever issues access and refresh tokens only.
+	RefreshIDTokenNever
+)
+
+// RefreshIDTokenPolicy defines the ID token issued on refresh_token grants,
+// as described by OpenID Connect Core 1.0, section 12.2. See [WithRefreshIDTokenPolicy].
+type RefreshIDTokenPolicy struct {
+	IDToken RefreshIDToken
+	// OmitUserinfoClaims omits the claims of the profile, email, phone and address scopes
+	// from the new ID token, instead of re-evaluating them with the Storage.
+	OmitUserinfoClaims bool
+	// RefreshAuthTime sets auth_time to the time of the refresh, instead of
+	// preserving the time of the original authentication, as required by section 12.2.
+	// It is meant for relying parties, which use auth_time as the time of issuance.
+	RefreshAuthTime bool
+}
+
+// DefaultRefreshIDTokenPolicy is used when no other policy
+// was set with [WithRefreshIDTokenPolicy].
+var DefaultRefreshIDTokenPolicy = RefreshIDTokenPolicy{
+	IDToken: RefreshIDTokenAlways,
+}
+
+type refreshIDTokenPolicyGetter interface {
+	RefreshIDTokenPolicy() *RefreshIDTokenPolicy
+}
+
+func refreshIDTokenPolicy(v any) *RefreshIDTokenPolicy {
+	if p, ok := v.(refreshIDTokenPolicyGetter); ok {
+		if policy := p.RefreshIDTokenPolicy(); policy != nil {
+			return policy
+		}
+	}
+	return &DefaultRefreshIDTokenPolicy
+}
+
 // RefreshTokenExchange handles the OAuth 2.0 refresh_token grant, including
 // parsing, validating, authorizing the client and finally exchanging the refresh_token for new tokens
 func RefreshTokenExchange(w http.ResponseWriter, r *http.Request, exchanger Exchanger) {
@@ -37,12 +82,35 @@ func RefreshTokenExchange(w http.ResponseWriter, r *http.Request, exchanger Exch
 		RequestError(w, r, err, exchanger.Logger())
 		return
 	}
-	resp, err := CreateTokenResponse(r.Context(), validatedRequest, client, exchanger, true, "", tokenReq.RefreshToken)
+	if err = verifyDeviceBinding(r.Context(), exchanger.Storage(), replayCache(exchanger), r.Header, validatedRequest, oidc.GrantTypeRefreshToken, tokenReq.RefreshToken); err != nil {
+		RequestError(w, r, err, exchanger.Logger())
+		return
+	}
+	resp, err := CreateRefreshTokenResponse(r.Context(), validatedRequest, client, exchanger, tokenReq.RefreshToken)
 	if err != nil {
 		RequestError(w, r, err, exchanger.Logger())
 		return
 	}
-	httphelper.MarshalJSON(w, resp)
+	WriteJSON(w, resp, http.StatusOK)
+}
+
+// CreateRefreshTokenResponse creates the response of a refresh_token grant,
+// with an ID token according to the [RefreshIDTokenPolicy] of the creator.
+func CreateRefreshTokenResponse(ctx context.Context, request RefreshTokenRequest, client Client, creator TokenCreator, refreshToken string) (*oidc.AccessTokenResponse, error) {
+	policy := refreshIDTokenPolicy(creator)
+	issueIDToken := policy.IDToken == RefreshIDTokenAlways
+	if policy.IDToken == RefreshIDTokenOpenIDScope {
+		issueIDToken = slices.Contains(request.GetScopes(), oidc.ScopeOpenID)
+	}
+	var idTokenOpts *idTokenOptions
+	if issueIDToken {
+		idTokenOpts = newIDTokenOptions(creator)
+		idTokenOpts.omitUserinfo = policy.OmitUserinfoClaims
+		if policy.RefreshAuthTime {
+			idTokenOpts.authTime = time.Now()
+		}
+	}
+	return createTokenResponse(ctx, request, client, creator, true, "", refreshToken, idTokenOpts)
 }
 
 // ParseRefreshTokenRequest parsed the http request into a oidc.RefreshTokenRequest
@@ -71,7 +139,13 @@ func ValidateRefreshTokenRequest(ctx context.Context, tokenReq *oidc.RefreshToke
 	if client.GetID() != request.GetClientID() {
 		return nil, nil, oidc.ErrInvalidGrant()
 	}
-	if err = ValidateRefreshTokenScopes(tokenReq.Scopes, request); err != nil {
+	if err = validateRefreshTokenDPoP(ctx, client, request); err != nil {
+		return nil, nil, err
+	}
+	if err = validateRefreshTokenScopes(exchanger, tokenReq.Scopes, request); err != nil {
+		return nil, nil, err
+	}
+	if err = ValidateTokenAuthorizationDetails(exchanger, tokenReq.AuthorizationDetails, request); err != nil {
 		return nil, nil, err
 	}
 	return request, client, nil
@@ -99,6 +173,17 @@ func AuthorizeRefreshClient(ctx context.Context, tokenReq *oidc.RefreshTokenRequ
 	ctx, span := tracer.Start(ctx, "AuthorizeRefreshClient")
 	defer span.End()
 
+	client, ok, err := authorizeSPIFFEClientAssertion(ctx, exchanger, exchanger.Storage(), tokenReq.ClientAssertionType, tokenReq.ClientAssertion)
+	if ok {
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ValidateGrantType(client, oidc.GrantTypeRefreshToken) {
+			return nil, nil, oidc.ErrUnauthorizedClient()
+		}
+		request, err = RefreshTokenRequestByRefreshToken(ctx, exchanger.Storage(), tokenReq.RefreshToken)
+		return request, client, err
+	}
 	if tokenReq.ClientAssertionType == oidc.ClientAssertionTypeJWTAssertion {
 		jwtExchanger, ok := exchanger.(JWTAuthorizationGrantExchanger)
 		if !ok || !exchanger.AuthMethodPrivateKeyJWTSupported() {
@@ -121,6 +206,13 @@ func AuthorizeRefreshClient(ctx context.Context, tokenReq *oidc.RefreshTokenRequ
 	if !ValidateGrantType(client, oidc.GrantTypeRefreshToken) {
 		return nil, nil, oidc.ErrUnauthorizedClient()
 	}
+	if isTLSAuthMethod(client.AuthMethod()) {
+		if err = AuthorizeTLSClient(ctx, client, mtlsConfig(exchanger)); err != nil {
+			return nil, nil, err
+		}
+		request, err = RefreshTokenRequestByRefreshToken(ctx, exchanger.Storage(), tokenReq.RefreshToken)
+		return request, client, err
+	}
 	if client.AuthMethod() == oidc.AuthMethodPrivateKeyJWT {
 		return nil, nil, oidc.ErrInvalidClient()
 	}
@@ -0,0 +1,119 @@
+package op
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// ErrRefreshTokenGrantNotRecorded is the parent of the invalid_grant error of refresh
+// token requests, whose original grant the Storage cannot supply, see [GrantedRefreshTokenRequest].
+var ErrRefreshTokenGrantNotRecorded = errors.New("original grant of the refresh token is not recorded")
+
+// GrantedRefreshTokenRequest is a RefreshTokenRequest with the scopes and audience
+// originally granted to the refresh token, recorded by the Storage at issuance.
+// Unlike GetScopes and GetAudience, which reflect the last refresh,
+// they never change during the lifetime of the refresh token and its rotations.
+//
+// If implemented, the scopes of refresh token requests are validated against the
+// granted scopes (RFC 6749, section 6), omitted scopes are granted the original
+// scopes and the current scopes and audience must not exceed the grant.
+// Nil granted scopes mark refresh tokens issued before the grant was recorded,
+// which are rejected with [ErrRefreshTokenGrantNotRecorded]. Once the grant is
+// recorded, a nil granted audience is an empty one.
+type GrantedRefreshTokenRequest interface {
+	RefreshTokenRequest
+	GetGrantedScopes() []string
+	GetGrantedAudience() []string
+}
+
+// RefreshTokenGrant records the scopes and audience granted to a refresh token.
+// Storages embed it into their refresh tokens to implement [GrantedRefreshTokenRequest].
+type RefreshTokenGrant struct {
+	Scopes   []string `json:"granted_scopes"`
+	Audience []string `json:"granted_audience"`
+}
+
+// NewRefreshTokenGrant returns the grant of a refresh token issued for the request,
+// to be called in CreateAccessAndRefreshTokens of the Storage.
+// On refresh token rotation, the grant of the current refresh token is carried over.
+func NewRefreshTokenGrant(request TokenRequest) RefreshTokenGrant {
+	if granted, ok := request.(GrantedRefreshTokenRequest); ok && granted.GetGrantedScopes() != nil {
+		return RefreshTokenGrant{
+			Scopes:   slices.Clone(granted.GetGrantedScopes()),
+			Audience: slices.Clone(granted.GetGrantedAudience()),
+		}
+	}
+	scopes := slices.Clone(request.GetScopes())
+	if scopes == nil {
+		scopes = []string{}
+	}
+	audience := slices.Clone(request.GetAudience())
+	if audience == nil {
+		audience = []string{}
+	}
+	return RefreshTokenGrant{
+		Scopes:   scopes,
+		Audience: audience,
+	}
+}
+
+func (g RefreshTokenGrant) GetGrantedScopes() []string {
+	return g.Scopes
+}
+
+func (g RefreshTokenGrant) GetGrantedAudience() []string {
+	return g.Audience
+}
+
+// ValidateRefreshTokenGrant validates the requested scopes against the scopes
+// originally granted to the refresh token and sets them as current scopes,
+// or the granted scopes, if none are requested. It rejects requests
+// whose current scopes or audience exceed the grant.
+func ValidateRefreshTokenGrant(requestedScopes []string, request GrantedRefreshTokenRequest) error {
+	granted := request.GetGrantedScopes()
+	if granted == nil {
+		return oidc.ErrInvalidGrant().WithParent(ErrRefreshTokenGrantNotRecorded)
+	}
+	if !isSubset(request.GetScopes(), granted) {
+		return oidc.ErrInvalidGrant().WithDescription("scopes of the refresh token exceed the original grant")
+	}
+	if !isSubset(request.GetAudience(), request.GetGrantedAudience()) {
+		return oidc.ErrInvalidGrant().WithDescription("audience of the refresh token exceeds the original grant")
+	}
+	if len(requestedScopes) == 0 {
+		request.SetCurrentScopes(slices.Clone(granted))
+		return nil
+	}
+	if !isSubset(requestedScopes, granted) {
+		return oidc.ErrInvalidScope()
+	}
+	request.SetCurrentScopes(requestedScopes)
+	return nil
+}
+
+type refreshTokenGrantRequiredGetter interface {
+	RefreshTokenGrantRequired() bool
+}
+
+// validateRefreshTokenScopes validates the scopes with the recorded grant of the request,
+// if any, see [WithRefreshTokenGrantRequired].
+func validateRefreshTokenScopes(v any, requestedScopes []string, request RefreshTokenRequest) error {
+	if granted, ok := request.(GrantedRefreshTokenRequest); ok {
+		return ValidateRefreshTokenGrant(requestedScopes, granted)
+	}
+	if getter, ok := v.(refreshTokenGrantRequiredGetter); ok && getter.RefreshTokenGrantRequired() {
+		return oidc.ErrInvalidGrant().WithParent(ErrRefreshTokenGrantNotRecorded)
+	}
+	return ValidateRefreshTokenScopes(requestedScopes, request)
+}
+
+func isSubset(values, of []string) bool {
+	for _, value := range values {
+		if !slices.Contains(of, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package op
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+type grantedRefreshRequest struct {
+	RefreshTokenGrant
+	scopes   []string
+	audience []string
+}
+
+func (r *grantedRefreshRequest) GetAMR() []string                 { return nil }
+func (r *grantedRefreshRequest) GetAudience() []string            { return r.audience }
+func (r *grantedRefreshRequest) GetAuthTime() time.Time           { return time.Time{} }
+func (r *grantedRefreshRequest) GetClientID() string              { return "client" }
+func (r *grantedRefreshRequest) GetScopes() []string              { return r.scopes }
+func (r *grantedRefreshRequest) GetSubject() string               { return "subject" }
+func (r *grantedRefreshRequest) SetCurrentScopes(scopes []string) { r.scopes = scopes }
+
+func TestValidateRefreshTokenGrant(t *testing.T) {
+	grant := RefreshTokenGrant{Scopes: []string{"openid", "email", "offline_access"}, Audience: []string{"client"}}
+
+	tests := []struct {
+		name       string
+		request    *grantedRefreshRequest
+		requested  []string
+		wantScopes []string
+		wantErr    error
+	}{
+		{
+			name:       "omitted scopes are the original grant",
+			request:    &grantedRefreshRequest{RefreshTokenGrant: grant, scopes: []string{"openid"}, audience: []string{"client"}},
+			wantScopes: []string{"openid", "email", "offline_access"},
+		},
+		{
+			name:       "narrowed scopes can be requested again",
+			request:    &grantedRefreshRequest{RefreshTokenGrant: grant, scopes: []string{"openid"}},
+			requested:  []string{"openid", "email"},
+			wantScopes: []string{"openid", "email"},
+		},
+		{
+			name:      "scope not granted",
+			request:   &grantedRefreshRequest{RefreshTokenGrant: grant, scopes: []string{"openid"}},
+			requested: []string{"openid", "profile"},
+			wantErr:   oidc.ErrInvalidScope(),
+		},
+		{
+			name:    "current scopes exceed the grant",
+			request: &grantedRefreshRequest{RefreshTokenGrant: grant, scopes: []string{"openid", "admin"}},
+			wantErr: oidc.ErrInvalidGrant(),
+		},
+		{
+			name:    "audience exceeds the grant",
+			request: &grantedRefreshRequest{RefreshTokenGrant: grant, audience: []string{"client", "other"}},
+			wantErr: oidc.ErrInvalidGrant(),
+		},
+		{
+			name:    "audience without granted audience",
+			request: &grantedRefreshRequest{RefreshTokenGrant: RefreshTokenGrant{Scopes: []string{"openid"}}, audience: []string{"other"}},
+			wantErr: oidc.ErrInvalidGrant(),
+		},
+		{
+			name:    "grant not recorded",
+			request: &grantedRefreshRequest{scopes: []string{"openid"}},
+			wantErr: ErrRefreshTokenGrantNotRecorded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRefreshTokenGrant(tt.requested, tt.request)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantScopes, tt.request.scopes)
+		})
+	}
+}
+
+func TestNewRefreshTokenGrant(t *testing.T) {
+	rotated := &grantedRefreshRequest{
+		RefreshTokenGrant: RefreshTokenGrant{Scopes: []string{"openid", "email"}},
+		scopes:            []string{"openid"},
+	}
+	assert.Equal(t, []string{"openid", "email"}, NewRefreshTokenGrant(rotated).Scopes, "grant is carried over on rotation")
+
+	issued := &grantedRefreshRequest{scopes: []string{"openid"}, audience: []string{"client"}}
+	assert.Equal(t, RefreshTokenGrant{Scopes: []string{"openid"}, Audience: []string{"client"}}, NewRefreshTokenGrant(issued))
+
+	assert.Equal(t, RefreshTokenGrant{Scopes: []string{}, Audience: []string{}}, NewRefreshTokenGrant(&grantedRefreshRequest{}), "empty grant is recorded")
+}
+
+func TestValidateRefreshTokenScopes_grantRequired(t *testing.T) {
+	provider := &Provider{refreshTokenGrant: true}
+	err := validateRefreshTokenScopes(provider, nil, &refreshRequestWithoutGrant{})
+	assert.ErrorIs(t, err, ErrRefreshTokenGrantNotRecorded)
+	assert.NoError(t, validateRefreshTokenScopes(&Provider{}, nil, &refreshRequestWithoutGrant{}))
+}
+
+type refreshRequestWithoutGrant struct {
+	RefreshTokenRequest
+}
@@ -0,0 +1,127 @@
+package op_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// userinfoAssertionClient asserts the userinfo claims in the ID token,
+// even if an access token is issued.
+type userinfoAssertionClient struct {
+	op.Client
+}
+
+func (userinfoAssertionClient) IDTokenUserinfoClaimsAssertion() bool {
+	return true
+}
+
+func TestCreateRefreshTokenResponse(t *testing.T) {
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name          string
+		policy        *op.RefreshIDTokenPolicy
+		scopes        []string
+		wantIDToken   bool
+		wantEmail     bool
+		wantRefreshed bool
+	}{
+		{
+			name:        "default",
+			scopes:      []string{oidc.ScopeOpenID, oidc.ScopeEmail, oidc.ScopeOfflineAccess},
+			wantIDToken: true,
+			wantEmail:   true,
+		},
+		{
+			name:   "never",
+			policy: &op.RefreshIDTokenPolicy{IDToken: op.RefreshIDTokenNever},
+			scopes: []string{oidc.ScopeOpenID, oidc.ScopeEmail, oidc.ScopeOfflineAccess},
+		},
+		{
+			name:        "openid scope",
+			policy:      &op.RefreshIDTokenPolicy{IDToken: op.RefreshIDTokenOpenIDScope},
+			scopes:      []string{oidc.ScopeOpenID, oidc.ScopeEmail, oidc.ScopeOfflineAccess},
+			wantIDToken: true,
+			wantEmail:   true,
+		},
+		{
+			name:   "without openid scope",
+			policy: &op.RefreshIDTokenPolicy{IDToken: op.RefreshIDTokenOpenIDScope},
+			scopes: []string{oidc.ScopeOfflineAccess},
+		},
+		{
+			name:        "omit userinfo claims",
+			policy:      &op.RefreshIDTokenPolicy{OmitUserinfoClaims: true},
+			scopes:      []string{oidc.ScopeOpenID, oidc.ScopeEmail, oidc.ScopeOfflineAccess},
+			wantIDToken: true,
+		},
+		{
+			name:          "refresh auth time",
+			policy:        &op.RefreshIDTokenPolicy{RefreshAuthTime: true},
+			scopes:        []string{oidc.ScopeOpenID, oidc.ScopeOfflineAccess},
+			wantIDToken:   true,
+			wantRefreshed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := op.ContextWithIssuer(context.Background(), testIssuer)
+			s := storage.NewStorage(storage.NewUserStore(testIssuer))
+			opts := []op.Option{op.WithAllowInsecure()}
+			if tt.policy != nil {
+				opts = append(opts, op.WithRefreshIDTokenPolicy(*tt.policy))
+			}
+			provider, err := op.NewOpenIDProvider(testIssuer, testConfig, s, opts...)
+			require.NoError(t, err)
+
+			authReq, err := s.CreateAuthRequest(ctx, &oidc.AuthRequest{
+				ClientID:     "web",
+				RedirectURI:  "https://example.com",
+				Scopes:       tt.scopes,
+				ResponseType: oidc.ResponseTypeCode,
+			}, "")
+			require.NoError(t, err)
+			require.NoError(t, op.CompleteAuthentication(ctx, s, authReq.GetID(), op.AuthenticationResult{
+				Subject: "id1",
+				Methods: []string{oidc.AMRPassword},
+				Time:    authTime,
+			}))
+			authReq, err = s.AuthRequestByID(ctx, authReq.GetID())
+			require.NoError(t, err)
+			_, refreshToken, _, err := s.CreateAccessAndRefreshTokens(ctx, authReq, "")
+			require.NoError(t, err)
+
+			client, err := s.GetClientByClientID(ctx, "web")
+			require.NoError(t, err)
+			request, err := op.RefreshTokenRequestByRefreshToken(ctx, s, refreshToken)
+			require.NoError(t, err)
+			resp, err := op.CreateRefreshTokenResponse(ctx, request, userinfoAssertionClient{client}, provider, refreshToken)
+			require.NoError(t, err)
+			assert.NotEmpty(t, resp.AccessToken)
+			assert.NotEmpty(t, resp.RefreshToken)
+			if !tt.wantIDToken {
+				assert.Empty(t, resp.IDToken)
+				return
+			}
+
+			claims := new(oidc.IDTokenClaims)
+			_, err = oidc.ParseToken(resp.IDToken, claims)
+			require.NoError(t, err)
+			assert.Equal(t, "id1", claims.Subject)
+			assert.Equal(t, []string{oidc.AMRPassword}, claims.AuthenticationMethodsReferences)
+			assert.Equal(t, tt.wantEmail, claims.Email != "", "email claim")
+			if tt.wantRefreshed {
+				assert.WithinDuration(t, time.Now(), claims.GetAuthTime(), time.Minute)
+			} else {
+				assert.Equal(t, authTime.Add(-client.ClockSkew()).Unix(), claims.GetAuthTime().Unix())
+			}
+		})
+	}
+}
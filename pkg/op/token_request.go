@@ -41,7 +41,7 @@ func Exchange(w http.ResponseWriter, r *http.Request, exchanger Exchanger) {
 	r = r.WithContext(ctx)
 	defer span.End()
 
-	grantType := r.FormValue("grant_type")
+	grantType := r.FormValue(oidc.ParamGrantType)
 	switch grantType {
 	case string(oidc.GrantTypeCode):
 		CodeExchange(w, r, exchanger)
@@ -71,6 +71,16 @@ func Exchange(w http.ResponseWriter, r *http.Request, exchanger Exchanger) {
 			DeviceAccessToken(w, r, exchanger)
 			return
 		}
+	case string(oidc.GrantTypeCIBA):
+		if backchannelAuthentication(exchanger) != nil {
+			BackchannelAccessToken(w, r, exchanger)
+			return
+		}
+	case string(oidc.GrantTypeSessionTransfer):
+		if sessionTransfer(exchanger) != nil {
+			SessionTransferAccessToken(w, r, exchanger)
+			return
+		}
 	case "":
 		RequestError(w, r, oidc.ErrInvalidRequest().WithDescription("grant_type missing"), exchanger.Logger())
 		return
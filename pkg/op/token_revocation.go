@@ -5,7 +5,6 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
-	"strings"
 
 	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
@@ -58,17 +57,21 @@ func Revoke(w http.ResponseWriter, r *http.Request, revoker Revoker) {
 		}
 	}
 	if doDecrypt {
-		tokenID, userID, ok := getTokenIDAndSubjectForRevocation(r.Context(), revoker, token)
+		tokenID, userID, tokenClientID, ok := getTokenIDAndSubject(r.Context(), revoker, token)
 		if ok {
 			token = tokenID
 			subject = userID
+			if err := validateRevocationClient(r.Context(), revoker.Storage(), tokenID, userID, tokenClientID, clientID); err != nil {
+				RevocationRequestError(w, r, err)
+				return
+			}
 		}
 	}
 	if err := revoker.Storage().RevokeToken(r.Context(), token, subject, clientID); err != nil {
 		RevocationRequestError(w, r, err)
 		return
 	}
-	httphelper.MarshalJSON(w, nil)
+	WriteJSON(w, nil, http.StatusOK)
 }
 
 func ParseTokenRevocationRequest(r *http.Request, revoker Revoker) (token, tokenTypeHint, clientID string, err error) {
@@ -90,6 +93,12 @@ func ParseTokenRevocationRequest(r *http.Request, revoker Revoker) (token, token
 	if err != nil {
 		return "", "", "", oidc.ErrInvalidRequest().WithDescription("error decoding form").WithParent(err)
 	}
+	if client, ok, err := authorizeSPIFFEClientAssertion(r.Context(), revoker, revoker.Storage(), req.ClientAssertionType, req.ClientAssertion); ok {
+		if err != nil {
+			return "", "", "", err
+		}
+		return req.Token, req.TokenTypeHint, client.GetID(), nil
+	}
 	if req.ClientAssertionType == oidc.ClientAssertionTypeJWTAssertion {
 		revokerJWTProfile, ok := revoker.(RevokerJWTProfile)
 		if !ok || !revoker.AuthMethodPrivateKeyJWTSupported() {
@@ -111,10 +120,14 @@ func ParseTokenRevocationRequest(r *http.Request, revoker Revoker) (token, token
 		if err != nil {
 			return "", "", "", oidc.ErrInvalidClient().WithDescription("invalid basic auth header").WithParent(err)
 		}
-		if err = AuthorizeClientIDSecret(r.Context(), clientID, clientSecret, revoker.Storage()); err != nil {
-			return "", "", "", err
+		// public clients might send their client_id without secret in the basic auth header
+		if clientSecret != "" {
+			if err = AuthorizeClientIDSecret(r.Context(), clientID, clientSecret, revoker.Storage()); err != nil {
+				return "", "", "", err
+			}
+			return req.Token, req.TokenTypeHint, clientID, nil
 		}
-		return req.Token, req.TokenTypeHint, clientID, nil
+		req.ClientID = clientID
 	}
 	if req.ClientID == "" {
 		return "", "", "", oidc.ErrInvalidClient().WithDescription("invalid authorization")
@@ -140,7 +153,7 @@ func ParseTokenRevocationRequest(r *http.Request, revoker Revoker) (token, token
 
 func RevocationRequestError(w http.ResponseWriter, r *http.Request, err error) {
 	statusErr := RevocationError(err)
-	httphelper.MarshalJSONWithStatus(w, statusErr.parent, statusErr.statusCode)
+	WriteJSON(w, statusErr.parent, statusErr.statusCode)
 }
 
 func RevocationError(err error) StatusError {
@@ -155,21 +168,20 @@ func RevocationError(err error) StatusError {
 	return NewStatusError(e, status)
 }
 
-func getTokenIDAndSubjectForRevocation(ctx context.Context, userinfoProvider UserinfoProvider, accessToken string) (string, string, bool) {
-	ctx, span := tracer.Start(ctx, "getTokenIDAndSubjectForRevocation")
-	defer span.End()
-
-	tokenIDSubject, err := userinfoProvider.Crypto().Decrypt(accessToken)
-	if err == nil {
-		splitToken := strings.Split(tokenIDSubject, ":")
-		if len(splitToken) != 2 {
-			return "", "", false
+// validateRevocationClient verifies that the access token was issued to the requesting client.
+// tokenClientID is the client_id claim of a JWT access token.
+// For opaque tokens, the check is delegated to the Storage, if it implements [CanValidateRevocationClient].
+func validateRevocationClient(ctx context.Context, storage Storage, tokenID, subject, tokenClientID, clientID string) error {
+	if tokenClientID != "" {
+		if tokenClientID != clientID {
+			return oidc.ErrUnauthorizedClient().WithDescription("token was not issued to the client")
 		}
-		return splitToken[0], splitToken[1], true
+		return nil
 	}
-	accessTokenClaims, err := VerifyAccessToken[*oidc.AccessTokenClaims](ctx, accessToken, userinfoProvider.AccessTokenVerifier(ctx))
-	if err != nil {
-		return "", "", false
+	if validator, ok := storage.(CanValidateRevocationClient); ok {
+		if err := validator.ValidateRevocationClient(ctx, tokenID, subject, clientID); err != nil {
+			return oidc.ErrUnauthorizedClient().WithDescription("token was not issued to the client").WithParent(err)
+		}
 	}
-	return accessTokenClaims.JWTID, accessTokenClaims.Subject, true
+	return nil
 }
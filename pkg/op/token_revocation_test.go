@@ -0,0 +1,69 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+type revocationClientStorage struct {
+	Storage
+	clientID string
+}
+
+func (s revocationClientStorage) ValidateRevocationClient(_ context.Context, _, _, clientID string) error {
+	if clientID != s.clientID {
+		return errors.New("wrong client")
+	}
+	return nil
+}
+
+func Test_validateRevocationClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		storage       Storage
+		tokenClientID string
+		clientID      string
+		wantErr       bool
+	}{
+		{
+			name:          "jwt same client",
+			tokenClientID: "client",
+			clientID:      "client",
+		},
+		{
+			name:          "jwt other client",
+			tokenClientID: "other",
+			clientID:      "client",
+			wantErr:       true,
+		},
+		{
+			name:     "opaque without validator",
+			clientID: "client",
+		},
+		{
+			name:     "opaque validator ok",
+			storage:  revocationClientStorage{clientID: "client"},
+			clientID: "client",
+		},
+		{
+			name:     "opaque validator error",
+			storage:  revocationClientStorage{clientID: "other"},
+			clientID: "client",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRevocationClient(context.Background(), tt.storage, "tokenID", "subject", tt.tokenClientID, tt.clientID)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, oidc.ErrUnauthorizedClient())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
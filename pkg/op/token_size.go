@@ -0,0 +1,134 @@
+package op
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+// TokenSizePolicy limits the size of the serialized ID and JWT access tokens,
+// as oversized tokens break cookies and headers of real deployments.
+// See [WithTokenSizePolicy].
+type TokenSizePolicy struct {
+	// MaxBytes is the maximum length of the serialized token.
+	// Zero means no limit.
+	MaxBytes int
+	// MoveToUserinfo removes the largest additional claims (the Claims map)
+	// of an oversized token, until it is within MaxBytes,
+	// so they are only available from the userinfo endpoint.
+	// Without, oversized tokens are not issued.
+	MoveToUserinfo bool
+}
+
+// ClaimSize is the size of the JSON encoded name and value of a claim.
+type ClaimSize struct {
+	Name  string
+	Bytes int
+}
+
+// TokenSizeError is returned for tokens exceeding the [TokenSizePolicy],
+// with the sizes of the largest claims for diagnostics.
+type TokenSizeError struct {
+	Size     int
+	MaxBytes int
+	// Claims are the largest claims of the token, largest first.
+	Claims []ClaimSize
+}
+
+// maxDiagnosticClaims limits the claims reported by a [TokenSizeError].
+const maxDiagnosticClaims = 5
+
+func (e *TokenSizeError) Error() string {
+	claims := make([]string, len(e.Claims))
+	for i, claim := range e.Claims {
+		claims[i] = fmt.Sprintf("%s=%d", claim.Name, claim.Bytes)
+	}
+	return fmt.Sprintf("token of %d bytes exceeds the maximum of %d bytes, largest claims: %s", e.Size, e.MaxBytes, strings.Join(claims, ", "))
+}
+
+type tokenSizePolicyGetter interface {
+	TokenSizePolicy() *TokenSizePolicy
+}
+
+func tokenSizePolicy(v any) *TokenSizePolicy {
+	if p, ok := v.(tokenSizePolicyGetter); ok {
+		return p.TokenSizePolicy()
+	}
+	return nil
+}
+
+func providerLogger(v any) *slog.Logger {
+	if l, ok := v.(interface{ Logger() *slog.Logger }); ok {
+		return l.Logger()
+	}
+	return slog.Default()
+}
+
+// sign signs the claims and enforces the size of the token.
+// Additional claims moved out of the token are deleted from the private claims.
+// A nil policy signs the claims without limit.
+func (p *TokenSizePolicy) sign(ctx context.Context, logger *slog.Logger, claims any, private map[string]any, signer jose.Signer) (string, error) {
+	token, err := crypto.Sign(claims, signer)
+	if err != nil || p == nil || p.MaxBytes <= 0 || len(token) <= p.MaxBytes {
+		return token, err
+	}
+	sizes, err := claimSizes(claims)
+	if err != nil {
+		return "", err
+	}
+	if p.MoveToUserinfo {
+		var moved []string
+		for _, size := range sizes {
+			if _, ok := private[size.Name]; !ok {
+				continue
+			}
+			delete(private, size.Name)
+			moved = append(moved, size.Name)
+			if token, err = crypto.Sign(claims, signer); err != nil {
+				return "", err
+			}
+			if len(token) <= p.MaxBytes {
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.WarnContext(ctx, "oversized token, claims moved to userinfo", "max_bytes", p.MaxBytes, "claims", moved)
+				return token, nil
+			}
+		}
+	}
+	err = &TokenSizeError{
+		Size:     len(token),
+		MaxBytes: p.MaxBytes,
+		Claims:   sizes[:min(len(sizes), maxDiagnosticClaims)],
+	}
+	return "", oidc.ErrServerError().WithDescription("token too large").WithParent(err)
+}
+
+// claimSizes returns the sizes of the JSON encoded claims, largest first.
+func claimSizes(claims any) ([]ClaimSize, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err = json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	sizes := make([]ClaimSize, 0, len(members))
+	for name, value := range members {
+		sizes = append(sizes, ClaimSize{Name: name, Bytes: len(name) + len(value)})
+	}
+	slices.SortFunc(sizes, func(a, b ClaimSize) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), strings.Compare(a.Name, b.Name))
+	})
+	return sizes, nil
+}
@@ -0,0 +1,91 @@
+package op
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
+	"github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+)
+
+func TestTokenSizePolicy_sign(t *testing.T) {
+	newClaims := func() *oidc.AccessTokenClaims {
+		claims := oidc.NewAccessTokenClaims("https://op.example.com", "sub1", []string{"client1"}, time.Now().Add(time.Hour), "id1", "client1", 0)
+		claims.Claims = map[string]any{
+			"small":  "a",
+			"large":  strings.Repeat("b", 500),
+			"larger": strings.Repeat("c", 1000),
+		}
+		return claims
+	}
+	unlimited, err := crypto.Sign(newClaims(), tu.Signer)
+	require.NoError(t, err)
+	withoutLarge := newClaims()
+	delete(withoutLarge.Claims, "larger")
+	withoutLarger, err := crypto.Sign(withoutLarge, tu.Signer)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		policy     *TokenSizePolicy
+		wantClaims []string
+		wantErr    bool
+	}{
+		{
+			name:       "no policy",
+			wantClaims: []string{"small", "large", "larger"},
+		},
+		{
+			name:       "within limit",
+			policy:     &TokenSizePolicy{MaxBytes: len(unlimited)},
+			wantClaims: []string{"small", "large", "larger"},
+		},
+		{
+			name:    "exceeded",
+			policy:  &TokenSizePolicy{MaxBytes: len(withoutLarger)},
+			wantErr: true,
+		},
+		{
+			name:       "moved to userinfo",
+			policy:     &TokenSizePolicy{MaxBytes: len(withoutLarger), MoveToUserinfo: true},
+			wantClaims: []string{"small", "large"},
+		},
+		{
+			name:    "standard claims exceed",
+			policy:  &TokenSizePolicy{MaxBytes: 100, MoveToUserinfo: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := newClaims()
+			token, err := tt.policy.sign(context.Background(), nil, claims, claims.Claims, tu.Signer)
+			if tt.wantErr {
+				var sizeErr *TokenSizeError
+				require.ErrorAs(t, err, &sizeErr)
+				assert.Equal(t, tt.policy.MaxBytes, sizeErr.MaxBytes)
+				assert.Greater(t, sizeErr.Size, sizeErr.MaxBytes)
+				assert.Equal(t, "larger", sizeErr.Claims[0].Name)
+				assert.Contains(t, sizeErr.Error(), "larger=")
+				return
+			}
+			require.NoError(t, err)
+			if tt.policy != nil {
+				assert.LessOrEqual(t, len(token), tt.policy.MaxBytes)
+			}
+			got := new(oidc.AccessTokenClaims)
+			_, err = oidc.ParseToken(token, got)
+			require.NoError(t, err)
+			for _, claim := range []string{"small", "large", "larger"} {
+				assert.Equal(t, slices.Contains(tt.wantClaims, claim), got.Claims[claim] != nil, claim)
+			}
+		})
+	}
+}
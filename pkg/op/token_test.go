@@ -0,0 +1,87 @@
+package op_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type accessTokenClaimsStorage struct {
+	*storage.Storage
+}
+
+func (s accessTokenClaimsStorage) SetAccessTokenClaims(_ context.Context, claims *oidc.AccessTokenClaims, request op.TokenRequest, clientID string) error {
+	claims.Subject = "external-" + request.GetSubject()
+	claims.Audience = oidc.Audience{"https://api.example.com"}
+	claims.Scopes = request.GetScopes()
+	claims.Claims = map[string]any{
+		"https://example.com/client": clientID,
+	}
+	return nil
+}
+
+type testTokenRequest struct {
+	subject  string
+	audience []string
+	scopes   []string
+}
+
+func (r testTokenRequest) GetSubject() string    { return r.subject }
+func (r testTokenRequest) GetAudience() []string { return r.audience }
+func (r testTokenRequest) GetScopes() []string   { return r.scopes }
+
+func TestCreateJWT_SetAccessTokenClaims(t *testing.T) {
+	ctx := context.Background()
+	s := accessTokenClaimsStorage{storage.NewStorage(storage.NewUserStore(testIssuer))}
+	client, err := s.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+
+	request := testTokenRequest{
+		subject:  "id1",
+		audience: []string{"web"},
+		scopes:   []string{oidc.ScopeOpenID, "api"},
+	}
+	token, err := op.CreateJWT(ctx, testIssuer, request, time.Now().Add(time.Hour), "tokenID", client, s)
+	require.NoError(t, err)
+
+	claims := new(oidc.AccessTokenClaims)
+	_, err = oidc.ParseToken(token, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "external-id1", claims.Subject)
+	assert.Equal(t, oidc.Audience{"https://api.example.com"}, claims.Audience)
+	assert.Equal(t, oidc.SpaceDelimitedArray{oidc.ScopeOpenID, "api"}, claims.Scopes)
+	assert.Equal(t, "web", claims.Claims["https://example.com/client"])
+	assert.Equal(t, "tokenID", claims.JWTID)
+}
+
+type sessionTokenRequest struct {
+	testTokenRequest
+	sessionID string
+}
+
+func (r sessionTokenRequest) GetSessionID() string { return r.sessionID }
+
+func TestCreateJWT_sessionID(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	client, err := s.GetClientByClientID(ctx, "web")
+	require.NoError(t, err)
+
+	request := sessionTokenRequest{
+		testTokenRequest: testTokenRequest{subject: "id1", audience: []string{"web"}, scopes: []string{oidc.ScopeOpenID}},
+		sessionID:        "sid1",
+	}
+	token, err := op.CreateJWT(ctx, testIssuer, request, time.Now().Add(time.Hour), "tokenID", client, s)
+	require.NoError(t, err)
+
+	claims := new(oidc.AccessTokenClaims)
+	_, err = oidc.ParseToken(token, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "sid1", claims.SessionID)
+}
@@ -0,0 +1,7 @@
+//go:build oidc_notrace
+
+package op
+
+import "go.opentelemetry.io/otel/trace/noop"
+
+var tracer = noop.NewTracerProvider().Tracer("github.com/lmindwarel/oidc/pkg/op")
@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+var (
+	// ErrAuthenticationFailed is returned by an [Authenticator],
+	// if the end-user could not be authenticated.
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// ErrPending is returned by an [Authenticator], if the authentication continues
+	// out of band, like by a magic link sent by email. The login page is rendered
+	// with the login.pending message and the state of the Authenticator is kept.
+	ErrPending = errors.New("authentication pending")
+)
+
+// Authenticator is a method of the login page to authenticate the end-user,
+// such as a password, WebAuthn, a magic link or a one-time password.
+//
+// The login page renders the template authenticator_<name> of each Authenticator,
+// executed with a [Page] with a [LoginMethod] as Data. Its form must post the name
+// as method parameter to the Action, along with the CSRF token and the ID of the
+// login. Links sent out of band, like magic links, must point to login/verify with
+// the method, authRequestID or user_code and their own parameters. They must be opened
+// in the browser of the login, which keeps the state of the Authenticator in a cookie.
+type Authenticator interface {
+	// Name of the method, unique among the Authenticators.
+	Name() string
+	// Challenge is called when the login page is rendered. It returns the Challenge
+	// of the [LoginMethod] and the state passed to Verify, like a nonce.
+	Challenge(ctx context.Context, login *Login) (challenge any, state string, err error)
+	// Verify verifies the parameters of the request with the state of the Challenge.
+	// It returns the authenticated end-user, [ErrAuthenticationFailed] or [ErrPending].
+	// Step-up Authenticators must authenticate the Subject of the Login.
+	Verify(ctx context.Context, r *http.Request, login *Login, state string) (*op.AuthenticationResult, error)
+}
+
+// StepUp returns the Authenticator of the next step of the login of an end-user,
+// authenticated by the Methods of the Login, or nil if the login is complete.
+// It must return the same Authenticator for the same Login.
+// For example, it returns a one-time password Authenticator for end-users
+// who enrolled a second factor, unless the Methods contain [oidc.AMROneTimePassword].
+type StepUp func(ctx context.Context, login *Login) (Authenticator, error)
+
+// Login is the progress of the login of an end-user.
+type Login struct {
+	// AuthRequestID or UserCode is set, for the authorization or the device flow.
+	AuthRequestID string
+	UserCode      string
+	// Subject authenticated by the previous steps, if any.
+	Subject string
+	// Methods used by the previous steps.
+	Methods []string
+}
+
+// PasswordAuthenticator authenticates the end-user by username and password,
+// checked by the Storage. It is the default of [Config.Authenticators].
+func PasswordAuthenticator(storage Storage) Authenticator {
+	return passwordAuthenticator{storage: storage}
+}
+
+type passwordAuthenticator struct {
+	storage Storage
+}
+
+func (passwordAuthenticator) Name() string {
+	return "password"
+}
+
+func (passwordAuthenticator) Challenge(context.Context, *Login) (any, string, error) {
+	return nil, "", nil
+}
+
+func (a passwordAuthenticator) Verify(ctx context.Context, r *http.Request, login *Login, _ string) (*op.AuthenticationResult, error) {
+	subject, err := a.storage.CheckCredentials(ctx, r.PostFormValue("username"), r.PostFormValue("password"))
+	if err != nil {
+		return nil, errors.Join(ErrAuthenticationFailed, err)
+	}
+	if login.Subject != "" && login.Subject != subject {
+		return nil, ErrAuthenticationFailed
+	}
+	return &op.AuthenticationResult{
+		Subject: subject,
+		Methods: []string{oidc.AMRPassword},
+	}, nil
+}
+
+// mergeMethods adds the methods of a step to the methods of the previous steps
+// and the mfa method, if more than one step was completed.
+func mergeMethods(previous, step []string) []string {
+	methods := slices.Clone(previous)
+	for _, method := range step {
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	if len(previous) > 0 && !slices.Contains(methods, oidc.AMRMultipleFactor) {
+		methods = append(methods, oidc.AMRMultipleFactor)
+	}
+	return methods
+}
@@ -0,0 +1,121 @@
+package ui_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/lmindwarel/oidc/v3/pkg/op/ui"
+)
+
+// codeAuthenticator verifies the code parameter against the state of its challenge.
+// Without code, it is pending, like a code or link sent by email.
+type codeAuthenticator struct {
+	name string
+}
+
+func (a codeAuthenticator) Name() string {
+	return a.name
+}
+
+func (a codeAuthenticator) Challenge(_ context.Context, login *ui.Login) (any, string, error) {
+	return nil, "code-of-" + login.Subject, nil
+}
+
+func (a codeAuthenticator) Verify(_ context.Context, r *http.Request, login *ui.Login, state string) (*op.AuthenticationResult, error) {
+	code := r.FormValue("code")
+	if code == "" {
+		return nil, ui.ErrPending
+	}
+	if code != state {
+		return nil, ui.ErrAuthenticationFailed
+	}
+	return &op.AuthenticationResult{Subject: login.Subject, Methods: []string{oidc.AMROneTimePassword}, ACR: "urn:test:mfa"}, nil
+}
+
+var codeTemplates = fstest.MapFS{
+	"otp.html": {Data: []byte(`{{ define "authenticator_otp" }}<form data-method="otp">` +
+		`<input type="hidden" name="csrf_token" value="{{ .CSRFToken }}">{{ if .Data.Pending }}pending{{ end }}</form>{{ end }}`)},
+}
+
+func TestHandler_stepUp(t *testing.T) {
+	u := newTestUI(t, false, ui.Config{
+		Templates: codeTemplates,
+		StepUp: func(_ context.Context, login *ui.Login) (ui.Authenticator, error) {
+			for _, method := range login.Methods {
+				if method == oidc.AMROneTimePassword {
+					return nil, nil
+				}
+			}
+			return codeAuthenticator{name: "otp"}, nil
+		},
+	})
+	id := u.createAuthRequest(t)
+
+	_, body := u.get(t, "/login?authRequestID="+id)
+	resp, body := u.post(t, "/login", url.Values{
+		"csrf_token":    {csrfToken(t, body)},
+		"authRequestID": {id},
+		"username":      {testUsername},
+		"password":      {testPassword},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `data-method="otp"`)
+	assert.NotContains(t, body, `name="password"`)
+	token := csrfToken(t, body)
+
+	resp, body = u.post(t, "/login", url.Values{"csrf_token": {token}, "authRequestID": {id}, "method": {"password"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "method of the previous step")
+
+	resp, body = u.post(t, "/login", url.Values{"csrf_token": {csrfToken(t, body)}, "authRequestID": {id}, "method": {"otp"}, "code": {"wrong"}})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = u.post(t, "/login", url.Values{"csrf_token": {csrfToken(t, body)}, "authRequestID": {id}, "method": {"otp"}, "code": {"code-of-id2"}})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	authReq, err := u.storage.AuthRequestByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.True(t, authReq.Done())
+	assert.Equal(t, []string{oidc.AMRPassword, oidc.AMROneTimePassword, oidc.AMRMultipleFactor}, authReq.GetAMR())
+	assert.Equal(t, "urn:test:mfa", authReq.GetACR())
+}
+
+func TestHandler_pending(t *testing.T) {
+	u := newTestUI(t, false, ui.Config{
+		Templates: codeTemplates,
+		Authenticators: []ui.Authenticator{
+			linkAuthenticator{codeAuthenticator{name: "otp"}},
+		},
+	})
+	id := u.createAuthRequest(t)
+
+	_, body := u.get(t, "/login?authRequestID="+id)
+	resp, body := u.post(t, "/login", url.Values{"csrf_token": {csrfToken(t, body)}, "authRequestID": {id}, "method": {"otp"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "Follow the link we sent you")
+	assert.Contains(t, body, "pending</form>")
+
+	resp, _ = u.get(t, "/login/verify?method=otp&authRequestID="+id+"&code=code-of-")
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, testIssuer+"authorize/callback?id="+id, resp.Header.Get("Location"))
+}
+
+// linkAuthenticator authenticates the test user by the link of a codeAuthenticator.
+type linkAuthenticator struct {
+	codeAuthenticator
+}
+
+func (a linkAuthenticator) Verify(ctx context.Context, r *http.Request, login *ui.Login, state string) (*op.AuthenticationResult, error) {
+	result, err := a.codeAuthenticator.Verify(ctx, r, login, state)
+	if err != nil {
+		return nil, err
+	}
+	result.Subject = "id2"
+	return result, nil
+}
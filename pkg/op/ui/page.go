@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	//go:embed templates
+	templateFS       embed.FS
+	defaultTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+)
+
+// Theme customizes the look of the embedded templates.
+type Theme struct {
+	// Name of the Provider, shown in the title and as alt text of the logo.
+	Name    string
+	LogoURL string
+	// PrimaryColor is the CSS color of the buttons, defaults to #1a73e8.
+	PrimaryColor string
+	// StylesheetURL is included after the embedded styles, to override them.
+	StylesheetURL string
+}
+
+// Translator returns the message of the key in the language,
+// or an empty string to fall back to the English default.
+// The keys are listed in [DefaultMessages]. Scopes are translated
+// by the keys scope.<name>, falling back to the name of the scope.
+type Translator func(lang language.Tag, key string) string
+
+// DefaultMessages are the English messages of the embedded templates.
+// Messages with a %s verb are passed the client ID.
+var DefaultMessages = map[string]string{
+	"login.title":         "Sign in",
+	"login.username":      "Username",
+	"login.password":      "Password",
+	"login.submit":        "Sign in",
+	"login.pending":       "Follow the link we sent you to continue.",
+	"webauthn.submit":     "Sign in with a security key or passkey",
+	"webauthn.error":      "The security key could not be used.",
+	"accounts.title":      "Choose an account",
+	"accounts.other":      "Use another account",
+	"consent.title":       "Authorize access",
+	"consent.text":        "%s requests access to:",
+	"consent.allow":       "Allow",
+	"consent.deny":        "Deny",
+	"device.title":        "Connect a device",
+	"device.user_code":    "Enter the code displayed on your device",
+	"device.submit":       "Continue",
+	"device.allowed":      "The device is connected. You can return to your device.",
+	"device.denied":       "The device was denied access.",
+	"logout.title":        "Sign out",
+	"logout.text":         "Do you want to sign out?",
+	"logout.submit":       "Sign out",
+	"logged_out.title":    "Signed out",
+	"logged_out.text":     "You are signed out.",
+	"error.title":         "Error",
+	"error.credentials":   "Invalid username or password.",
+	"error.user_code":     "Invalid or expired code.",
+	"error.csrf":          "The form has expired, please try again.",
+	"error.session":       "The session has expired, please start again.",
+	"error.server":        "An unexpected error occurred.",
+	"error.invalid_input": "The request is incomplete.",
+}
+
+// Page is the data passed to the templates. Data is specific to the template:
+// [LoginData], [AccountsData], [ConsentData], [DeviceData], [LogoutData], [MessageData] or [ErrorData].
+type Page struct {
+	Lang      language.Tag
+	Title     string
+	Theme     Theme
+	CSRFToken string
+	Error     string
+	Data      any
+
+	translate Translator
+	templates *template.Template
+}
+
+// T returns the translation of the key, formatted with the args, if any.
+func (p *Page) T(key string, args ...any) string {
+	message := ""
+	if p.translate != nil {
+		message = p.translate(p.Lang, key)
+	}
+	if message == "" {
+		message = DefaultMessages[key]
+	}
+	if message == "" {
+		message = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Scope returns the translation of the scope, or the scope itself.
+func (p *Page) Scope(scope string) string {
+	if p.translate != nil {
+		if message := p.translate(p.Lang, "scope."+scope); message != "" {
+			return message
+		}
+	}
+	return scope
+}
+
+// Method renders the template authenticator_<name> of the login method.
+func (p *Page) Method(method LoginMethod) (template.HTML, error) {
+	page := *p
+	page.Data = method
+	var b strings.Builder
+	if err := p.templates.ExecuteTemplate(&b, "authenticator_"+method.Name, &page); err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
+}
+
+// LoginData is the Data of the login template, with the Methods
+// of the Authenticators of the current step of the login.
+type LoginData struct {
+	Methods []LoginMethod
+}
+
+// LoginMethod is the Data of the template of an [Authenticator]. Either AuthRequestID
+// or UserCode is set, for the authorization or the device flow.
+type LoginMethod struct {
+	Name          string
+	Action        string
+	AuthRequestID string
+	UserCode      string
+	Username      string
+	// Challenge returned by the Authenticator.
+	Challenge any
+	// Pending is set, if the Authenticator returned [ErrPending].
+	Pending bool
+}
+
+// AccountsData is the Data of the accounts template, the account chooser
+// of auth requests with the select_account prompt, see [op.ActiveSessions].
+type AccountsData struct {
+	Action        string
+	AuthRequestID string
+	Accounts      []Account
+}
+
+// Account is an active session of the account chooser.
+type Account struct {
+	// ID is posted as account parameter to choose the session.
+	ID      string
+	Subject string
+	Name    string
+}
+
+// ConsentData is the Data of the consent template,
+// for auth requests and the confirmation of device authorizations.
+type ConsentData struct {
+	Action   string
+	ClientID string
+	Scopes   []string
+}
+
+// DeviceData is the Data of the device template.
+type DeviceData struct {
+	UserCode string
+}
+
+// LogoutData is the Data of the logout template, which posts the
+// Parameters of the end session request to the EndSessionURL.
+type LogoutData struct {
+	EndSessionURL string
+	Parameters    map[string]string
+}
+
+// MessageData is the Data of the message template.
+type MessageData struct {
+	Message string
+}
+
+// ErrorData is the Data of the error template.
+type ErrorData struct {
+	ErrorType   string
+	Description string
+}
+
+func parseTemplates(overrides fs.FS) (*template.Template, error) {
+	templates, err := defaultTemplates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if overrides == nil {
+		return templates, nil
+	}
+	if matches, _ := fs.Glob(overrides, "*.html"); len(matches) == 0 {
+		return templates, nil
+	}
+	return templates.ParseFS(overrides, "*.html")
+}
+
+// language returns the language of the request, matched from the ui_locales parameter
+// and the Accept-Language header, or the first of the supported languages.
+func (h *Handler) language(r *http.Request) language.Tag {
+	var tags []language.Tag
+	for _, locale := range strings.Fields(r.FormValue("ui_locales")) {
+		if tag, err := language.Parse(locale); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	accepted, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	tags = append(tags, accepted...)
+	_, index, _ := h.matcher.Match(tags...)
+	return h.locales[index]
+}
+
+// newPage returns the page with the title of the key, in the language of the request.
+func (h *Handler) newPage(r *http.Request, titleKey string, data any) *Page {
+	page := &Page{
+		Lang:      h.language(r),
+		Theme:     h.theme,
+		Data:      data,
+		translate: h.translate,
+		templates: h.templates,
+	}
+	page.Title = page.T(titleKey)
+	return page
+}
+
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, name string, statusCode int, page *Page) {
+	page.CSRFToken = h.csrfToken(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := h.templates.ExecuteTemplate(w, name, page); err != nil {
+		h.logger.ErrorContext(r.Context(), "ui: render template", "template", name, "error", err)
+	}
+}
@@ -0,0 +1,688 @@
+// Package ui provides optional HTML pages for the end-user interaction of an
+// [op.Provider]: login, account chooser, consent, device verification, logout
+// confirmation and error pages, so a minimal but complete Provider can run from
+// this module alone.
+//
+// The pages are rendered from embedded templates, which can be themed by a
+// [Theme], translated by a [Translator] and replaced by name, see [Config.Templates].
+// All forms are protected against cross-site request forgery by a token,
+// bound to a cookie (double submit).
+//
+// The login page offers the [Authenticator] methods of the [Config], by default
+// username and password, and [WebAuthn] as reference of passwordless methods.
+// Further steps, like a one-time password, are required by the [StepUp].
+//
+// The [Handler] serves the pages relative to where it is mounted, e.g. with
+// http.StripPrefix("/ui", handler). The clients must return the login page as
+// LoginURL, with the ID of the auth request in the authRequestID query parameter,
+// e.g. "/ui/login?authRequestID=" + id.
+package ui
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"golang.org/x/text/language"
+
+	"github.com/lmindwarel/oidc/v3/internal/security"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// Storage authenticates the end-user. The auth requests are completed with
+// [op.CompleteAuthentication], so the Storage of the Provider must implement
+// the [op.AuthenticationStorage].
+type Storage interface {
+	// CheckCredentials returns the subject of the end-user, if the password is correct.
+	CheckCredentials(ctx context.Context, username, password string) (subject string, err error)
+}
+
+// ConsentStorage is an optional extension of the [Storage].
+// If implemented, the end-user is asked for consent,
+// before auth requests are completed.
+type ConsentStorage interface {
+	// ConsentRequired reports whether the end-user has not yet consented
+	// to grant the scopes to the client.
+	ConsentRequired(ctx context.Context, subject, clientID string, scopes []string) (bool, error)
+	// GrantConsent records the consent of the end-user.
+	GrantConsent(ctx context.Context, subject, clientID string, scopes []string) error
+}
+
+// DeviceStorage is an optional extension of the [Storage].
+// If implemented, the device verification pages are served,
+// which must be configured as [op.DeviceAuthorizationConfig.UserFormPath],
+// e.g. "/ui/device".
+type DeviceStorage interface {
+	// GetDeviceAuthorizationByUserCode returns the current state
+	// of the device authorization of the user code.
+	GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (*op.DeviceAuthorizationState, error)
+	// CompleteDeviceAuthorization marks the device authorization as completed by the subject.
+	CompleteDeviceAuthorization(ctx context.Context, userCode, subject string) error
+	// DenyDeviceAuthorization marks the device authorization as denied.
+	DenyDeviceAuthorization(ctx context.Context, userCode string) error
+}
+
+// Config of the [Handler].
+type Config struct {
+	Provider *op.Provider
+	// Storage checks the passwords of the default [PasswordAuthenticator]
+	// and can implement the [ConsentStorage] and [DeviceStorage].
+	Storage Storage
+	// Authenticators are offered on the login page,
+	// defaults to the [PasswordAuthenticator] of the Storage.
+	Authenticators []Authenticator
+	// StepUp requires further steps of the login, if set.
+	StepUp StepUp
+	Theme  Theme
+	// Templates replace the embedded templates of the same name, parsed from
+	// the *.html files at the root. The embedded templates are login, consent,
+	// device, logout, message and error, which share the header, footer and style
+	// templates, and the authenticator_<name> templates of the Authenticators.
+	// They are executed with a [Page].
+	Templates fs.FS
+	// Locales are the supported languages, the first being the default.
+	// Defaults to English.
+	Locales   []language.Tag
+	Translate Translator
+	// Cookies stores the CSRF token and the state between the pages.
+	// Defaults to secure cookies with random keys, which do not survive
+	// restarts and are not shared by multiple instances.
+	// Use [httphelper.WithUnsecure] for development without TLS.
+	Cookies *httphelper.CookieHandler
+}
+
+const (
+	csrfCookie  = "csrf_token"
+	stateCookie = "ui_state"
+
+	queryAuthRequestID = "authRequestID"
+)
+
+var (
+	ErrCSRF         = errors.New("invalid CSRF token")
+	ErrInvalidState = errors.New("invalid state of the pages")
+)
+
+// Handler serves the pages, see [New].
+type Handler struct {
+	provider       *op.Provider
+	storage        Storage
+	authenticators []Authenticator
+	stepUp         StepUp
+	theme          Theme
+	templates      *template.Template
+	locales        []language.Tag
+	matcher        language.Matcher
+	translate      Translator
+	cookies        *httphelper.CookieHandler
+	logger         *slog.Logger
+	mux            *http.ServeMux
+}
+
+// New returns the Handler of the pages. Its RenderError method
+// can be passed to [op.WithAuthErrorRenderer].
+func New(config Config) (*Handler, error) {
+	if config.Provider == nil {
+		return nil, errors.New("ui: provider is required")
+	}
+	if len(config.Authenticators) == 0 {
+		if config.Storage == nil {
+			return nil, errors.New("ui: storage or authenticators are required")
+		}
+		config.Authenticators = []Authenticator{PasswordAuthenticator(config.Storage)}
+	}
+	templates, err := parseTemplates(config.Templates)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		provider:       config.Provider,
+		storage:        config.Storage,
+		authenticators: config.Authenticators,
+		stepUp:         config.StepUp,
+		theme:          config.Theme,
+		templates:      templates,
+		locales:        config.Locales,
+		translate:      config.Translate,
+		cookies:        config.Cookies,
+		logger:         config.Provider.Logger(),
+	}
+	if h.theme.PrimaryColor == "" {
+		h.theme.PrimaryColor = "#1a73e8"
+	}
+	if len(h.locales) == 0 {
+		h.locales = []language.Tag{language.English}
+	}
+	h.matcher = language.NewMatcher(h.locales)
+	if h.cookies == nil {
+		h.cookies = httphelper.NewCookieHandler(securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32))
+	}
+
+	interceptor := op.NewIssuerInterceptor(config.Provider.IssuerFromRequest)
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("GET /login", h.loginPage)
+	h.mux.Handle("POST /login", interceptor.HandlerFunc(h.login))
+	h.mux.Handle("GET /login/verify", interceptor.HandlerFunc(h.login))
+	h.mux.Handle("POST /select-account", interceptor.HandlerFunc(h.selectAccount))
+	h.mux.Handle("GET /logout", interceptor.HandlerFunc(h.logoutPage))
+	h.mux.HandleFunc("GET /logged-out", h.loggedOutPage)
+	if _, ok := config.Storage.(ConsentStorage); ok {
+		h.mux.Handle("POST /consent", interceptor.HandlerFunc(h.consent))
+	}
+	if _, ok := config.Storage.(DeviceStorage); ok {
+		h.mux.HandleFunc("GET /device", h.devicePage)
+		h.mux.HandleFunc("POST /device", h.device)
+		h.mux.HandleFunc("POST /device-login", h.login)
+		h.mux.HandleFunc("POST /device-confirm", h.deviceConfirm)
+	}
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// RenderError renders the error page, see [op.AuthErrorRenderer].
+func (h *Handler) RenderError(w http.ResponseWriter, r *http.Request, err *oidc.Error, statusCode int) {
+	page := h.newPage(r, "error.title", &ErrorData{
+		ErrorType:   string(err.ErrorType),
+		Description: err.Description,
+	})
+	h.render(w, r, "error", statusCode, page)
+}
+
+// renderErrorMessage renders the error page with the message of the key.
+func (h *Handler) renderErrorMessage(w http.ResponseWriter, r *http.Request, errorType, errKey string, statusCode int) {
+	data := &ErrorData{ErrorType: errorType}
+	page := h.newPage(r, "error.title", data)
+	data.Description = page.T(errKey)
+	h.render(w, r, "error", statusCode, page)
+}
+
+func (h *Handler) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.ErrorContext(r.Context(), "ui", "error", err)
+	h.renderErrorMessage(w, r, string(oidc.ServerError), "error.server", http.StatusInternalServerError)
+}
+
+// csrfToken returns the CSRF token of the cookie, or sets a new one.
+func (h *Handler) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if token, err := h.cookies.CheckCookie(r, csrfCookie); err == nil && token != "" {
+		return token
+	}
+	token := base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	if err := h.cookies.SetCookie(w, csrfCookie, token); err != nil {
+		h.logger.ErrorContext(r.Context(), "ui: set CSRF cookie", "error", err)
+	}
+	return token
+}
+
+// checkCSRF compares the CSRF token of the form with the one of the cookie.
+func (h *Handler) checkCSRF(r *http.Request) error {
+	token, err := h.cookies.CheckCookie(r, csrfCookie)
+	if err != nil || token == "" {
+		return ErrCSRF
+	}
+	if !security.Equal(token, r.PostFormValue(csrfCookie)) {
+		return ErrCSRF
+	}
+	return nil
+}
+
+// state of the login is kept in a cookie between the pages.
+type state struct {
+	AuthRequestID string   `json:"auth_request_id,omitempty"`
+	UserCode      string   `json:"user_code,omitempty"`
+	Subject       string   `json:"sub,omitempty"`
+	Methods       []string `json:"amr,omitempty"`
+	ACR           string   `json:"acr,omitempty"`
+	// Time and SessionID of a session chosen in the account chooser.
+	Time      time.Time `json:"auth_time,omitempty"`
+	SessionID string    `json:"sid,omitempty"`
+	// StepUp is set while the Subject must complete a step of the StepUp.
+	StepUp bool `json:"step_up,omitempty"`
+	// Authenticated is set after all steps were completed.
+	Authenticated bool `json:"authenticated,omitempty"`
+	// Challenges are the states of the Authenticators by name.
+	Challenges map[string]string `json:"challenges,omitempty"`
+	// Pending is the name of the Authenticator, which returned ErrPending.
+	Pending string `json:"pending,omitempty"`
+}
+
+func (s *state) login() *Login {
+	return &Login{
+		AuthRequestID: s.AuthRequestID,
+		UserCode:      s.UserCode,
+		Subject:       s.Subject,
+		Methods:       s.Methods,
+	}
+}
+
+func (h *Handler) setState(w http.ResponseWriter, s *state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return h.cookies.SetCookie(w, stateCookie, string(data))
+}
+
+func (h *Handler) readState(r *http.Request) (*state, error) {
+	value, err := h.cookies.CheckCookie(r, stateCookie)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	s := new(state)
+	if err = json.Unmarshal([]byte(value), s); err != nil {
+		return nil, ErrInvalidState
+	}
+	return s, nil
+}
+
+// popState returns and deletes the state of an authenticated end-user.
+func (h *Handler) popState(w http.ResponseWriter, r *http.Request) (*state, error) {
+	s, err := h.readState(r)
+	if err != nil {
+		return nil, err
+	}
+	h.cookies.DeleteCookie(w, stateCookie)
+	if !s.Authenticated || s.Subject == "" {
+		return nil, ErrInvalidState
+	}
+	return s, nil
+}
+
+// loginState returns the state of the cookie, if it belongs to the login
+// of the request, or a new state.
+func (h *Handler) loginState(r *http.Request) *state {
+	id, userCode := r.FormValue(queryAuthRequestID), r.FormValue("user_code")
+	if s, err := h.readState(r); err == nil && !s.Authenticated && s.AuthRequestID == id && s.UserCode == userCode {
+		return s
+	}
+	return &state{AuthRequestID: id, UserCode: userCode}
+}
+
+// formError renders the page again with the error message of the key
+// and the status code.
+func (h *Handler) formError(w http.ResponseWriter, r *http.Request, name, titleKey string, data any, errKey string, statusCode int) {
+	page := h.newPage(r, titleKey, data)
+	page.Error = page.T(errKey)
+	h.render(w, r, name, statusCode, page)
+}
+
+// loginAuthenticators returns the Authenticators of the current step of the login.
+func (h *Handler) loginAuthenticators(ctx context.Context, s *state) ([]Authenticator, error) {
+	if !s.StepUp {
+		return h.authenticators, nil
+	}
+	authenticator, err := h.stepUp(ctx, s.login())
+	if err != nil {
+		return nil, err
+	}
+	if authenticator == nil {
+		return nil, ErrInvalidState
+	}
+	return []Authenticator{authenticator}, nil
+}
+
+// renderLogin challenges the Authenticators of the current step
+// and renders the login page with the message of the errKey, if any.
+func (h *Handler) renderLogin(w http.ResponseWriter, r *http.Request, s *state, errKey string, statusCode int) {
+	authenticators, err := h.loginAuthenticators(r.Context(), s)
+	if err != nil {
+		h.serverError(w, r, err)
+		return
+	}
+	action := "login"
+	if s.UserCode != "" {
+		action = "device-login"
+	}
+	challenges := make(map[string]string, len(authenticators))
+	data := &LoginData{Methods: make([]LoginMethod, len(authenticators))}
+	for i, authenticator := range authenticators {
+		name := authenticator.Name()
+		method := LoginMethod{
+			Name:          name,
+			Action:        action,
+			AuthRequestID: s.AuthRequestID,
+			UserCode:      s.UserCode,
+			Username:      r.PostFormValue("username"),
+			Pending:       name == s.Pending,
+		}
+		if method.Pending {
+			challenges[name] = s.Challenges[name]
+		} else {
+			method.Challenge, challenges[name], err = authenticator.Challenge(r.Context(), s.login())
+			if err != nil {
+				h.serverError(w, r, err)
+				return
+			}
+		}
+		data.Methods[i] = method
+	}
+	s.Challenges = challenges
+	if err = h.setState(w, s); err != nil {
+		h.serverError(w, r, err)
+		return
+	}
+	page := h.newPage(r, "login.title", data)
+	if errKey != "" {
+		page.Error = page.T(errKey)
+	}
+	h.render(w, r, "login", statusCode, page)
+}
+
+func (h *Handler) loginPage(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue(queryAuthRequestID)
+	if id == "" {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.invalid_input", http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("account") != "new" {
+		sessions, err := h.activeSessions(r, id)
+		if err != nil {
+			h.serverError(w, r, err)
+			return
+		}
+		if len(sessions) > 0 {
+			data := &AccountsData{Action: "select-account", AuthRequestID: id}
+			for _, session := range sessions {
+				data.Accounts = append(data.Accounts, Account{ID: accountID(session), Subject: session.Subject, Name: session.Name})
+			}
+			h.render(w, r, "accounts", http.StatusOK, h.newPage(r, "accounts.title", data))
+			return
+		}
+	}
+	h.renderLogin(w, r, &state{AuthRequestID: id}, "", http.StatusOK)
+}
+
+// activeSessions returns the sessions of the account chooser,
+// if the auth request has the select_account prompt.
+func (h *Handler) activeSessions(r *http.Request, authRequestID string) ([]*op.Session, error) {
+	authReq, err := h.provider.Storage().AuthRequestByID(r.Context(), authRequestID)
+	if err != nil {
+		return nil, nil
+	}
+	prompt, ok := authReq.(op.AuthRequestPrompt)
+	if !ok || !slices.Contains(prompt.GetPrompt(), oidc.PromptSelectAccount) {
+		return nil, nil
+	}
+	return op.ActiveSessions(r.Context(), h.provider, authReq, r.Header)
+}
+
+// accountID identifies the session in the account chooser.
+func accountID(session *op.Session) string {
+	if session.SessionID != "" {
+		return session.SessionID
+	}
+	return session.Subject
+}
+
+// selectAccount continues the login with the session chosen in the account chooser.
+func (h *Handler) selectAccount(w http.ResponseWriter, r *http.Request) {
+	if err := h.checkCSRF(r); err != nil {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.csrf", http.StatusForbidden)
+		return
+	}
+	id := r.PostFormValue(queryAuthRequestID)
+	sessions, err := h.activeSessions(r, id)
+	if err != nil {
+		h.serverError(w, r, err)
+		return
+	}
+	i := slices.IndexFunc(sessions, func(session *op.Session) bool {
+		return accountID(session) == r.PostFormValue("account")
+	})
+	if i < 0 {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.session", http.StatusBadRequest)
+		return
+	}
+	session := sessions[i]
+	h.authenticated(w, r, &state{
+		AuthRequestID: id,
+		Subject:       session.Subject,
+		Methods:       session.Methods,
+		ACR:           session.ACR,
+		Time:          session.Time,
+		SessionID:     session.SessionID,
+		Authenticated: true,
+	})
+}
+
+// login verifies the method of the login form or of a link sent out of band.
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	s := h.loginState(r)
+	if s.AuthRequestID == "" && s.UserCode == "" {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.invalid_input", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPost {
+		if err := h.checkCSRF(r); err != nil {
+			h.renderLogin(w, r, s, "error.csrf", http.StatusForbidden)
+			return
+		}
+	}
+	authenticators, err := h.loginAuthenticators(r.Context(), s)
+	if err != nil {
+		h.serverError(w, r, err)
+		return
+	}
+	name := r.FormValue("method")
+	if name == "" {
+		name = authenticators[0].Name()
+	}
+	i := slices.IndexFunc(authenticators, func(a Authenticator) bool { return a.Name() == name })
+	if i < 0 {
+		h.renderLogin(w, r, s, "error.invalid_input", http.StatusBadRequest)
+		return
+	}
+	result, err := authenticators[i].Verify(r.Context(), r, s.login(), s.Challenges[name])
+	if errors.Is(err, ErrPending) {
+		s.Pending = name
+		h.renderLogin(w, r, s, "login.pending", http.StatusOK)
+		return
+	}
+	s.Pending = ""
+	if err == nil && s.Subject != "" && result.Subject != s.Subject {
+		err = ErrAuthenticationFailed
+	}
+	if err != nil {
+		h.logger.DebugContext(r.Context(), "ui: authentication failed", "method", name, "error", err)
+		h.renderLogin(w, r, s, "error.credentials", http.StatusUnauthorized)
+		return
+	}
+	s.Subject = result.Subject
+	s.Methods = mergeMethods(s.Methods, result.Methods)
+	if result.ACR != "" {
+		s.ACR = result.ACR
+	}
+	s.StepUp = false
+	if h.stepUp != nil {
+		next, err := h.stepUp(r.Context(), s.login())
+		if err != nil {
+			h.serverError(w, r, err)
+			return
+		}
+		if next != nil {
+			s.StepUp = true
+			h.renderLogin(w, r, s, "", http.StatusOK)
+			return
+		}
+	}
+	s.Authenticated = true
+	s.Challenges = nil
+	if s.UserCode != "" {
+		h.confirmDevice(w, r, s)
+		return
+	}
+	h.authenticated(w, r, s)
+}
+
+// authenticated asks the end-user for consent, if required, or completes the auth request.
+func (h *Handler) authenticated(w http.ResponseWriter, r *http.Request, s *state) {
+	authReq, err := h.provider.Storage().AuthRequestByID(r.Context(), s.AuthRequestID)
+	if err != nil {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.session", http.StatusBadRequest)
+		return
+	}
+	if consents, ok := h.storage.(ConsentStorage); ok {
+		required, err := consents.ConsentRequired(r.Context(), s.Subject, authReq.GetClientID(), authReq.GetScopes())
+		if err != nil {
+			h.serverError(w, r, err)
+			return
+		}
+		if required {
+			if err = h.setState(w, s); err != nil {
+				h.serverError(w, r, err)
+				return
+			}
+			h.render(w, r, "consent", http.StatusOK, h.newPage(r, "consent.title", &ConsentData{
+				Action:   "consent",
+				ClientID: authReq.GetClientID(),
+				Scopes:   authReq.GetScopes(),
+			}))
+			return
+		}
+	}
+	h.complete(w, r, s)
+}
+
+func (h *Handler) consent(w http.ResponseWriter, r *http.Request) {
+	if err := h.checkCSRF(r); err != nil {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.csrf", http.StatusForbidden)
+		return
+	}
+	s, err := h.popState(w, r)
+	if err != nil || s.AuthRequestID == "" {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.session", http.StatusBadRequest)
+		return
+	}
+	authReq, err := h.provider.Storage().AuthRequestByID(r.Context(), s.AuthRequestID)
+	if err != nil {
+		h.renderErrorMessage(w, r, string(oidc.InvalidRequest), "error.session", http.StatusBadRequest)
+		return
+	}
+	if r.PostFormValue("action") != "allow" {
+		op.AuthRequestError(w, r, authReq, oidc.ErrAccessDenied(), h.provider)
+		return
+	}
+	if err = h.storage.(ConsentStorage).GrantConsent(r.Context(), s.Subject, authReq.GetClientID(), authReq.GetScopes()); err != nil {
+		h.serverError(w, r, err)
+		return
+	}
+	h.complete(w, r, s)
+}
+
+// complete completes the auth request and redirects to the callback of the Provider.
+func (h *Handler) complete(w http.ResponseWriter, r *http.Request, s *state) {
+	err := op.CompleteAuthentication(r.Context(), h.provider.Storage(), s.AuthRequestID, op.AuthenticationResult{
+		Subject:   s.Subject,
+		Methods:   s.Methods,
+		Time:      s.Time,
+		ACR:       s.ACR,
+		SessionID: s.SessionID,
+	})
+	if err != nil {
+		h.serverError(w, r, err)
+		return
+	}
+	h.cookies.DeleteCookie(w, stateCookie)
+	http.Redirect(w, r, op.AuthCallbackURL(h.provider)(r.Context(), s.AuthRequestID), http.StatusFound)
+}
+
+// endSessionParameters are passed from the logout page to the end_session endpoint.
+var endSessionParameters = []string{"id_token_hint", "logout_hint", "client_id", "post_logout_redirect_uri", "state", "ui_locales"}
+
+func (h *Handler) logoutPage(w http.ResponseWriter, r *http.Request) {
+	endpoint := h.provider.EndSessionEndpoint()
+	if endpoint == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data := &LogoutData{
+		EndSessionURL: endpoint.Absolute(op.IssuerFromContext(r.Context())),
+		Parameters:    make(map[string]string),
+	}
+	for _, name := range endSessionParameters {
+		if value := r.FormValue(name); value != "" {
+			data.Parameters[name] = value
+		}
+	}
+	h.render(w, r, "logout", http.StatusOK, h.newPage(r, "logout.title", data))
+}
+
+func (h *Handler) loggedOutPage(w http.ResponseWriter, r *http.Request) {
+	page := h.newPage(r, "logged_out.title", new(MessageData))
+	page.Data.(*MessageData).Message = page.T("logged_out.text")
+	h.render(w, r, "message", http.StatusOK, page)
+}
+
+func (h *Handler) devicePage(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "device", http.StatusOK, h.newPage(r, "device.title", &DeviceData{UserCode: r.FormValue("user_code")}))
+}
+
+func (h *Handler) device(w http.ResponseWriter, r *http.Request) {
+	data := &DeviceData{UserCode: r.PostFormValue("user_code")}
+	if err := h.checkCSRF(r); err != nil {
+		h.formError(w, r, "device", "device.title", data, "error.csrf", http.StatusForbidden)
+		return
+	}
+	if _, err := h.storage.(DeviceStorage).GetDeviceAuthorizationByUserCode(r.Context(), data.UserCode); err != nil {
+		h.formError(w, r, "device", "device.title", data, "error.user_code", http.StatusBadRequest)
+		return
+	}
+	h.renderLogin(w, r, &state{UserCode: data.UserCode}, "", http.StatusOK)
+}
+
+// confirmDevice asks the authenticated end-user to confirm the device authorization.
+func (h *Handler) confirmDevice(w http.ResponseWriter, r *http.Request, s *state) {
+	authorization, err := h.storage.(DeviceStorage).GetDeviceAuthorizationByUserCode(r.Context(), s.UserCode)
+	if err != nil {
+		h.formError(w, r, "device", "device.title", &DeviceData{}, "error.user_code", http.StatusBadRequest)
+		return
+	}
+	if err = h.setState(w, s); err != nil {
+		h.serverError(w, r, err)
+		return
+	}
+	h.render(w, r, "consent", http.StatusOK, h.newPage(r, "device.title", &ConsentData{
+		Action:   "device-confirm",
+		ClientID: authorization.ClientID,
+		Scopes:   authorization.Scopes,
+	}))
+}
+
+func (h *Handler) deviceConfirm(w http.ResponseWriter, r *http.Request) {
+	if err := h.checkCSRF(r); err != nil {
+		h.formError(w, r, "device", "device.title", &DeviceData{}, "error.csrf", http.StatusForbidden)
+		return
+	}
+	s, err := h.popState(w, r)
+	if err != nil || s.UserCode == "" {
+		h.formError(w, r, "device", "device.title", &DeviceData{}, "error.session", http.StatusBadRequest)
+		return
+	}
+	storage := h.storage.(DeviceStorage)
+	messageKey := "device.denied"
+	if r.PostFormValue("action") == "allow" {
+		messageKey = "device.allowed"
+		err = storage.CompleteDeviceAuthorization(r.Context(), s.UserCode, s.Subject)
+	} else {
+		err = storage.DenyDeviceAuthorization(r.Context(), s.UserCode)
+	}
+	if err != nil {
+		h.formError(w, r, "device", "device.title", &DeviceData{}, "error.user_code", http.StatusBadRequest)
+		return
+	}
+	page := h.newPage(r, "device.title", new(MessageData))
+	page.Data.(*MessageData).Message = page.T(messageKey)
+	h.render(w, r, "message", http.StatusOK, page)
+}
@@ -0,0 +1,358 @@
+package ui_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+
+	"github.com/lmindwarel/oidc/v3/example/server/storage"
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+	"github.com/lmindwarel/oidc/v3/pkg/op/ui"
+)
+
+const (
+	testIssuer   = "http://localhost:9998/"
+	testUsername = "test-user2"
+	testPassword = "verysecure"
+)
+
+type consentStorage struct {
+	*storage.Storage
+	granted map[string]bool
+}
+
+func (s *consentStorage) ConsentRequired(_ context.Context, subject, clientID string, _ []string) (bool, error) {
+	return !s.granted[subject+clientID], nil
+}
+
+func (s *consentStorage) GrantConsent(_ context.Context, subject, clientID string, _ []string) error {
+	s.granted[subject+clientID] = true
+	return nil
+}
+
+type multiSessionStorage struct {
+	*storage.Storage
+	sessions []*op.Session
+}
+
+func (s *multiSessionStorage) SessionFromRequest(context.Context, http.Header) (*op.Session, error) {
+	return s.sessions[0], nil
+}
+
+func (s *multiSessionStorage) SessionsFromRequest(context.Context, http.Header) ([]*op.Session, error) {
+	return s.sessions, nil
+}
+
+type testUI struct {
+	storage *storage.Storage
+	server  *httptest.Server
+	client  *http.Client
+}
+
+func newTestUI(t *testing.T, withConsent bool, config ui.Config) *testUI {
+	t.Helper()
+	return newTestUIWithSessions(t, withConsent, config, nil)
+}
+
+// newTestUIWithSessions returns the test UI of a Provider,
+// whose storage returns the sessions as the sessions of the browser.
+func newTestUIWithSessions(t *testing.T, withConsent bool, config ui.Config, sessions []*op.Session) *testUI {
+	t.Helper()
+	storage.RegisterClients(
+		storage.WebClient("web", "secret", "https://client.example.com/callback"),
+		storage.DeviceClient("device", "secret"),
+	)
+	s := storage.NewStorage(storage.NewUserStore(testIssuer))
+	var providerStorage op.Storage = s
+	if sessions != nil {
+		providerStorage = &multiSessionStorage{Storage: s, sessions: sessions}
+	}
+	provider, err := op.NewOpenIDProvider(testIssuer, &op.Config{CryptoKey: [32]byte{1}}, providerStorage, op.WithAllowInsecure())
+	require.NoError(t, err)
+
+	config.Provider = provider
+	config.Storage = s
+	if withConsent {
+		config.Storage = &consentStorage{Storage: s, granted: make(map[string]bool)}
+	}
+	key := make([]byte, 32)
+	config.Cookies = httphelper.NewCookieHandler(key, key, httphelper.WithUnsecure())
+	handler, err := ui.New(config)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return &testUI{
+		storage: s,
+		server:  server,
+		client: &http.Client{
+			Jar: jar,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (u *testUI) createAuthRequest(t *testing.T, prompt ...string) string {
+	t.Helper()
+	authReq, err := u.storage.CreateAuthRequest(context.Background(), &oidc.AuthRequest{
+		ClientID:     "web",
+		RedirectURI:  "https://client.example.com/callback",
+		ResponseType: oidc.ResponseTypeCode,
+		Scopes:       oidc.SpaceDelimitedArray{oidc.ScopeOpenID, oidc.ScopeEmail},
+		State:        "state",
+		Prompt:       prompt,
+	}, "")
+	require.NoError(t, err)
+	return authReq.GetID()
+}
+
+func (u *testUI) get(t *testing.T, path string, header ...string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, u.server.URL+path, nil)
+	require.NoError(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	return u.do(t, req)
+}
+
+func (u *testUI) post(t *testing.T, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, u.server.URL+path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return u.do(t, req)
+}
+
+func (u *testUI) do(t *testing.T, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := u.client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+var csrfTokenRegexp = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func csrfToken(t *testing.T, body string) string {
+	t.Helper()
+	match := csrfTokenRegexp.FindStringSubmatch(body)
+	require.Len(t, match, 2, "CSRF token in %s", body)
+	return match[1]
+}
+
+func TestHandler_login(t *testing.T) {
+	u := newTestUI(t, false, ui.Config{})
+	id := u.createAuthRequest(t)
+
+	resp, body := u.get(t, "/login?authRequestID="+id)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	token := csrfToken(t, body)
+
+	form := url.Values{"authRequestID": {id}, "username": {testUsername}, "password": {testPassword}}
+	resp, body = u.post(t, "/login", form)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "missing CSRF token")
+	assert.Contains(t, body, "The form has expired")
+
+	form.Set("csrf_token", token)
+	form.Set("password", "wrong")
+	resp, body = u.post(t, "/login", form)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, body, "Invalid username or password.")
+	assert.Contains(t, body, `value="`+testUsername+`"`)
+
+	form.Set("password", testPassword)
+	resp, _ = u.post(t, "/login", form)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, testIssuer+"authorize/callback?id="+id, resp.Header.Get("Location"))
+	authReq, err := u.storage.AuthRequestByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.True(t, authReq.Done())
+	assert.Equal(t, []string{oidc.AMRPassword}, authReq.GetAMR())
+}
+
+func TestHandler_accounts(t *testing.T) {
+	authTime := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	u := newTestUIWithSessions(t, false, ui.Config{}, []*op.Session{
+		{AuthenticationResult: op.AuthenticationResult{Subject: "id1", Time: authTime, SessionID: "sid1"}, Name: "Alice"},
+		{AuthenticationResult: op.AuthenticationResult{Subject: "id2", Methods: []string{oidc.AMRPassword}, Time: authTime, SessionID: "sid2"}, Name: "Bob"},
+		{AuthenticationResult: op.AuthenticationResult{Subject: "id3", Time: authTime.Add(-24 * time.Hour), SessionID: "sid3"}, Name: "Expired"},
+	})
+
+	_, body := u.get(t, "/login?authRequestID="+u.createAuthRequest(t))
+	assert.NotContains(t, body, "Choose an account", "without select_account prompt")
+
+	id := u.createAuthRequest(t, oidc.PromptSelectAccount)
+	resp, body := u.get(t, "/login?authRequestID="+id)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "Choose an account")
+	assert.Contains(t, body, "Alice")
+	assert.Contains(t, body, "Bob")
+	assert.NotContains(t, body, "Expired")
+	token := csrfToken(t, body)
+
+	_, body = u.get(t, "/login?account=new&authRequestID="+id)
+	assert.Contains(t, body, `name="password"`)
+
+	resp, _ = u.post(t, "/select-account", url.Values{"csrf_token": {token}, "authRequestID": {id}, "account": {"sid3"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "expired session")
+
+	resp, _ = u.post(t, "/select-account", url.Values{"csrf_token": {token}, "authRequestID": {id}, "account": {"sid2"}})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	authReq, err := u.storage.AuthRequestByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.True(t, authReq.Done())
+	assert.Equal(t, "id2", authReq.GetSubject())
+	assert.Equal(t, authTime, authReq.GetAuthTime())
+	assert.Equal(t, "sid2", authReq.(op.SessionIDRequest).GetSessionID())
+}
+
+func TestHandler_consent(t *testing.T) {
+	u := newTestUI(t, true, ui.Config{})
+
+	login := func(id string) string {
+		_, body := u.get(t, "/login?authRequestID="+id)
+		resp, body := u.post(t, "/login", url.Values{
+			"csrf_token": {csrfToken(t, body)}, "authRequestID": {id},
+			"username": {testUsername}, "password": {testPassword},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, body, "web requests access to:")
+		assert.Contains(t, body, "<li>email</li>")
+		return csrfToken(t, body)
+	}
+
+	id := u.createAuthRequest(t)
+	resp, _ := u.post(t, "/consent", url.Values{"csrf_token": {login(id)}, "action": {"deny"}})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "https://client.example.com/callback?error=access_denied&error_description=The+authorization+request+was+denied.&state=state", resp.Header.Get("Location"))
+
+	id = u.createAuthRequest(t)
+	token := login(id)
+	resp, _ = u.post(t, "/consent", url.Values{"csrf_token": {token}, "action": {"allow"}})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, testIssuer+"authorize/callback?id="+id, resp.Header.Get("Location"))
+
+	resp, body := u.post(t, "/consent", url.Values{"csrf_token": {token}, "action": {"allow"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "state is consumed")
+	assert.Contains(t, body, "The session has expired")
+
+	id = u.createAuthRequest(t)
+	_, body = u.get(t, "/login?authRequestID="+id)
+	resp, _ = u.post(t, "/login", url.Values{
+		"csrf_token": {csrfToken(t, body)}, "authRequestID": {id},
+		"username": {testUsername}, "password": {testPassword},
+	})
+	assert.Equal(t, http.StatusFound, resp.StatusCode, "consent was granted before")
+}
+
+func TestHandler_device(t *testing.T) {
+	u := newTestUI(t, false, ui.Config{})
+	ctx := context.Background()
+	require.NoError(t, u.storage.StoreDeviceAuthorization(ctx, "device", "device-code", "USER-CODE", time.Now().Add(time.Minute), []string{oidc.ScopeOpenID}))
+
+	resp, body := u.get(t, "/device?user_code=USER-CODE")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `value="USER-CODE"`)
+	token := csrfToken(t, body)
+
+	resp, body = u.post(t, "/device", url.Values{"csrf_token": {token}, "user_code": {"UNKNOWN"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "Invalid or expired code.")
+
+	resp, body = u.post(t, "/device", url.Values{"csrf_token": {token}, "user_code": {"USER-CODE"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `action="device-login"`)
+
+	resp, body = u.post(t, "/device-login", url.Values{
+		"csrf_token": {token}, "user_code": {"USER-CODE"},
+		"username": {testUsername}, "password": {testPassword},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "device requests access to:")
+	assert.Contains(t, body, `action="device-confirm"`)
+
+	resp, body = u.post(t, "/device-confirm", url.Values{"csrf_token": {token}, "action": {"allow"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "The device is connected.")
+	state, err := u.storage.GetDeviceAuthorizatonState(ctx, "device", "device-code")
+	require.NoError(t, err)
+	assert.True(t, state.Done)
+	assert.Equal(t, "id2", state.Subject)
+}
+
+func TestHandler_logout(t *testing.T) {
+	u := newTestUI(t, false, ui.Config{})
+
+	resp, body := u.get(t, "/logout?id_token_hint=hint&post_logout_redirect_uri=https%3A%2F%2Fclient.example.com&unknown=x")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `action="`+testIssuer+`end_session"`)
+	assert.Contains(t, body, `name="id_token_hint" value="hint"`)
+	assert.Contains(t, body, `name="post_logout_redirect_uri" value="https://client.example.com"`)
+	assert.NotContains(t, body, "unknown")
+
+	_, body = u.get(t, "/logged-out")
+	assert.Contains(t, body, "You are signed out.")
+}
+
+func TestHandler_theme(t *testing.T) {
+	u := newTestUI(t, false, ui.Config{
+		Theme: ui.Theme{Name: "Example", LogoURL: "/logo.svg", PrimaryColor: "#ff0000"},
+		Templates: fstest.MapFS{
+			"message.html": {Data: []byte(`{{ define "message" }}<p class="custom">{{ .Data.Message }}</p>{{ end }}`)},
+		},
+		Locales: []language.Tag{language.English, language.German},
+		Translate: func(lang language.Tag, key string) string {
+			if lang == language.German && key == "logged_out.text" {
+				return "Sie sind abgemeldet."
+			}
+			return ""
+		},
+	})
+
+	_, body := u.get(t, "/logged-out", "Accept-Language", "de-CH, en;q=0.5")
+	assert.Equal(t, `<p class="custom">Sie sind abgemeldet.</p>`, body)
+	_, body = u.get(t, "/logged-out?ui_locales=en", "Accept-Language", "de")
+	assert.Equal(t, `<p class="custom">You are signed out.</p>`, body)
+
+	_, body = u.get(t, "/logout")
+	assert.Contains(t, body, `<img class="logo" src="/logo.svg" alt="Example">`)
+	assert.Contains(t, body, "<title>Sign out - Example</title>")
+	assert.Contains(t, body, "background: #ff0000")
+}
+
+func TestHandler_RenderError(t *testing.T) {
+	provider, err := op.NewOpenIDProvider(testIssuer, &op.Config{CryptoKey: [32]byte{1}},
+		storage.NewStorage(storage.NewUserStore(testIssuer)), op.WithAllowInsecure())
+	require.NoError(t, err)
+	handler, err := ui.New(ui.Config{Provider: provider, Storage: storage.NewStorage(storage.NewUserStore(testIssuer))})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.RenderError(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), oidc.ErrInvalidRequest().WithDescription("<redirect_uri> is invalid"), http.StatusBadRequest)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "&lt;redirect_uri&gt; is invalid")
+	assert.Contains(t, w.Body.String(), "<code>invalid_request</code>")
+}
@@ -0,0 +1,424 @@
+package ui
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	zcrypto "github.com/lmindwarel/oidc/v3/pkg/crypto"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+// COSE algorithms supported by [WebAuthn].
+const (
+	coseES256 = -7
+	coseES384 = -35
+	coseES512 = -36
+	coseEdDSA = -8
+	coseRS256 = -257
+)
+
+// webAuthnCurves are the curves required by the ECDSA algorithms (RFC 9053, section 2.1).
+var webAuthnCurves = map[int]elliptic.Curve{
+	coseES256: elliptic.P256(),
+	coseES384: elliptic.P384(),
+	coseES512: elliptic.P521(),
+}
+
+// flags of the authenticator data.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// WebAuthnCredential is a public key credential registered by an end-user.
+type WebAuthnCredential struct {
+	ID      []byte
+	Subject string
+	// PublicKey in the SubjectPublicKeyInfo (PKIX) form
+	// returned by getPublicKey() of the browser.
+	PublicKey []byte
+	// Algorithm is the COSE algorithm of the PublicKey.
+	Algorithm int
+	SignCount uint32
+}
+
+// WebAuthnStorage stores the credentials of [WebAuthn].
+type WebAuthnStorage interface {
+	// WebAuthnCredentials returns the credentials of the subject.
+	WebAuthnCredentials(ctx context.Context, subject string) ([]WebAuthnCredential, error)
+	WebAuthnCredentialByID(ctx context.Context, id []byte) (*WebAuthnCredential, error)
+	UpdateWebAuthnSignCount(ctx context.Context, id []byte, signCount uint32) error
+}
+
+// WebAuthn is the reference [Authenticator] of passkeys and security keys,
+// by the Web Authentication API. Credentials are registered by
+// [WebAuthn.BeginRegistration] and [WebAuthn.FinishRegistration],
+// only "none" attestation is supported.
+//
+// As first step of the login, it authenticates end-users by discoverable credentials.
+// As step-up, it authenticates the Subject of the Login by its credentials.
+// It returns the methods hwk and user, and mfa if the user was verified.
+type WebAuthn struct {
+	// RPID is the relying party ID, the domain of the Provider.
+	RPID string
+	// RPName is displayed by the browser on registration, defaults to the RPID.
+	RPName string
+	// Origins allowed for the ceremonies, defaults to https://<RPID>.
+	Origins []string
+	// UserVerification requires the user to be verified, by a PIN or biometrics.
+	UserVerification bool
+	// Timeout of the ceremonies, defaults to 5 minutes.
+	Timeout time.Duration
+	Storage WebAuthnStorage
+}
+
+// WebAuthnCredentialDescriptor identifies a credential in the options of the ceremonies.
+type WebAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	// ID encoded as base64url.
+	ID string `json:"id"`
+}
+
+// WebAuthnRequestOptions are the options of navigator.credentials.get(),
+// with the binary values encoded as base64url. They are the Challenge
+// of the [LoginMethod] of the webauthn template.
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	Timeout          int64                          `json:"timeout,omitempty"`
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnCreationOptions are the options of navigator.credentials.create(),
+// with the binary values encoded as base64url.
+type WebAuthnCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams []struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout,omitempty"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// WebAuthnAttestation is the response of navigator.credentials.create(),
+// returned by response.getAuthenticatorData(), response.getPublicKey()
+// and response.getPublicKeyAlgorithm().
+type WebAuthnAttestation struct {
+	ClientDataJSON    []byte `json:"clientDataJSON"`
+	AuthenticatorData []byte `json:"authenticatorData"`
+	PublicKey         []byte `json:"publicKey"`
+	Algorithm         int    `json:"publicKeyAlgorithm"`
+}
+
+func (a *WebAuthn) Name() string {
+	return "webauthn"
+}
+
+// Challenge returns the [WebAuthnRequestOptions] and the challenge as state.
+func (a *WebAuthn) Challenge(ctx context.Context, login *Login) (any, string, error) {
+	challenge, err := newWebAuthnChallenge(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	options := &WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          a.timeout().Milliseconds(),
+		RPID:             a.RPID,
+		UserVerification: a.userVerification(),
+	}
+	if login.Subject != "" {
+		options.AllowCredentials, err = a.descriptors(ctx, login.Subject)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return options, challenge, nil
+}
+
+// Verify verifies the assertion posted by the webauthn template as the form parameters
+// credential_id, client_data, authenticator_data, signature and user_handle, encoded as base64url.
+func (a *WebAuthn) Verify(ctx context.Context, r *http.Request, login *Login, state string) (*op.AuthenticationResult, error) {
+	var id, clientData, authData, signature, userHandle []byte
+	for name, value := range map[string]*[]byte{
+		"credential_id":      &id,
+		"client_data":        &clientData,
+		"authenticator_data": &authData,
+		"signature":          &signature,
+		"user_handle":        &userHandle,
+	} {
+		var err error
+		if *value, err = base64.RawURLEncoding.DecodeString(r.PostFormValue(name)); err != nil {
+			return nil, fmt.Errorf("%w: invalid %s", ErrAuthenticationFailed, name)
+		}
+	}
+	credential, err := a.Storage.WebAuthnCredentialByID(ctx, id)
+	if err != nil {
+		return nil, errors.Join(ErrAuthenticationFailed, err)
+	}
+	if login.Subject != "" && credential.Subject != login.Subject {
+		return nil, fmt.Errorf("%w: credential of another subject", ErrAuthenticationFailed)
+	}
+	if len(userHandle) > 0 && string(userHandle) != credential.Subject {
+		return nil, fmt.Errorf("%w: user handle mismatch", ErrAuthenticationFailed)
+	}
+	if err = a.verifyClientData(clientData, "webauthn.get", state); err != nil {
+		return nil, err
+	}
+	flags, signCount, err := a.verifyAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(clientData)
+	if err = verifyWebAuthnSignature(credential.Algorithm, credential.PublicKey, append(slices.Clip(authData), hash[:]...), signature); err != nil {
+		return nil, err
+	}
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return nil, fmt.Errorf("%w: sign count did not increase, the authenticator may be cloned", ErrAuthenticationFailed)
+	}
+	if err = a.Storage.UpdateWebAuthnSignCount(ctx, credential.ID, signCount); err != nil {
+		return nil, err
+	}
+	methods := []string{oidc.AMRHardwareKey, oidc.AMRUserPresence}
+	if flags&flagUserVerified != 0 {
+		methods = append(methods, oidc.AMRMultipleFactor)
+	}
+	return &op.AuthenticationResult{
+		Subject: credential.Subject,
+		Methods: methods,
+	}, nil
+}
+
+// BeginRegistration returns the options to register a credential of the subject,
+// excluding its registered credentials, and the state passed to FinishRegistration.
+// The subject is the user handle of the credential and must not exceed 64 bytes.
+func (a *WebAuthn) BeginRegistration(ctx context.Context, subject, name string) (*WebAuthnCreationOptions, string, error) {
+	challenge, err := newWebAuthnChallenge(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	exclude, err := a.descriptors(ctx, subject)
+	if err != nil {
+		return nil, "", err
+	}
+	options := &WebAuthnCreationOptions{
+		Challenge:          challenge,
+		Timeout:            a.timeout().Milliseconds(),
+		ExcludeCredentials: exclude,
+		Attestation:        "none",
+	}
+	options.RP.ID = a.RPID
+	options.RP.Name = a.RPName
+	if options.RP.Name == "" {
+		options.RP.Name = a.RPID
+	}
+	options.User.ID = base64.RawURLEncoding.EncodeToString([]byte(subject))
+	options.User.Name = name
+	options.User.DisplayName = name
+	for _, alg := range []int{coseES256, coseEdDSA, coseES384, coseES512, coseRS256} {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, struct {
+			Type string `json:"type"`
+			Alg  int    `json:"alg"`
+		}{Type: "public-key", Alg: alg})
+	}
+	options.AuthenticatorSelection.ResidentKey = "preferred"
+	options.AuthenticatorSelection.UserVerification = a.userVerification()
+	return options, challenge, nil
+}
+
+// FinishRegistration verifies the attestation with the state of BeginRegistration
+// and returns the credential of the subject, to be stored by the caller.
+func (a *WebAuthn) FinishRegistration(subject, state string, attestation *WebAuthnAttestation) (*WebAuthnCredential, error) {
+	if err := a.verifyClientData(attestation.ClientDataJSON, "webauthn.create", state); err != nil {
+		return nil, err
+	}
+	flags, signCount, err := a.verifyAuthenticatorData(attestation.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	// attested credential data: aaguid (16), credential ID length (2), credential ID
+	data := attestation.AuthenticatorData[37:]
+	if flags&flagAttestedData == 0 || len(data) < 18 {
+		return nil, fmt.Errorf("%w: missing attested credential data", ErrAuthenticationFailed)
+	}
+	length := int(binary.BigEndian.Uint16(data[16:18]))
+	if len(data) < 18+length {
+		return nil, fmt.Errorf("%w: invalid attested credential data", ErrAuthenticationFailed)
+	}
+	if _, err = webAuthnPublicKey(attestation.Algorithm, attestation.PublicKey); err != nil {
+		return nil, err
+	}
+	return &WebAuthnCredential{
+		ID:        slices.Clone(data[18 : 18+length]),
+		Subject:   subject,
+		PublicKey: attestation.PublicKey,
+		Algorithm: attestation.Algorithm,
+		SignCount: signCount,
+	}, nil
+}
+
+func (a *WebAuthn) timeout() time.Duration {
+	if a.Timeout == 0 {
+		return 5 * time.Minute
+	}
+	return a.Timeout
+}
+
+func (a *WebAuthn) userVerification() string {
+	if a.UserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+func (a *WebAuthn) descriptors(ctx context.Context, subject string) ([]WebAuthnCredentialDescriptor, error) {
+	credentials, err := a.Storage.WebAuthnCredentials(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := make([]WebAuthnCredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		descriptors[i] = WebAuthnCredentialDescriptor{
+			Type: "public-key",
+			ID:   base64.RawURLEncoding.EncodeToString(credential.ID),
+		}
+	}
+	return descriptors, nil
+}
+
+func (a *WebAuthn) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return fmt.Errorf("%w: invalid client data", ErrAuthenticationFailed)
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("%w: client data type %q", ErrAuthenticationFailed, clientData.Type)
+	}
+	if challenge == "" || subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrAuthenticationFailed)
+	}
+	origins := a.Origins
+	if len(origins) == 0 {
+		origins = []string{"https://" + a.RPID}
+	}
+	if !slices.Contains(origins, clientData.Origin) {
+		return fmt.Errorf("%w: origin %q not allowed", ErrAuthenticationFailed, clientData.Origin)
+	}
+	return nil
+}
+
+// verifyAuthenticatorData verifies the RP ID hash and the flags
+// and returns the flags and the sign count.
+func (a *WebAuthn) verifyAuthenticatorData(authData []byte) (byte, uint32, error) {
+	if len(authData) < 37 {
+		return 0, 0, fmt.Errorf("%w: invalid authenticator data", ErrAuthenticationFailed)
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	if subtle.ConstantTimeCompare(authData[:32], rpIDHash[:]) != 1 {
+		return 0, 0, fmt.Errorf("%w: RP ID mismatch", ErrAuthenticationFailed)
+	}
+	flags := authData[32]
+	if flags&flagUserPresent == 0 {
+		return 0, 0, fmt.Errorf("%w: user not present", ErrAuthenticationFailed)
+	}
+	if a.UserVerification && flags&flagUserVerified == 0 {
+		return 0, 0, fmt.Errorf("%w: user not verified", ErrAuthenticationFailed)
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+func webAuthnPublicKey(algorithm int, der []byte) (crypto.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid public key: %w", ErrAuthenticationFailed, err)
+	}
+	var ok bool
+	switch algorithm {
+	case coseES256, coseES384, coseES512:
+		var ecKey *ecdsa.PublicKey
+		if ecKey, ok = key.(*ecdsa.PublicKey); ok && ecKey.Curve != webAuthnCurves[algorithm] {
+			return nil, fmt.Errorf("%w: curve %s for algorithm %d", ErrAuthenticationFailed, ecKey.Curve.Params().Name, algorithm)
+		}
+	case coseRS256:
+		_, ok = key.(*rsa.PublicKey)
+	case coseEdDSA:
+		_, ok = key.(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %d", ErrAuthenticationFailed, algorithm)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: public key of type %T for algorithm %d", ErrAuthenticationFailed, key, algorithm)
+	}
+	return key, nil
+}
+
+func verifyWebAuthnSignature(algorithm int, der, data, signature []byte) error {
+	key, err := webAuthnPublicKey(algorithm, der)
+	if err != nil {
+		return err
+	}
+	var valid bool
+	switch algorithm {
+	case coseES256:
+		hash := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), hash[:], signature)
+	case coseES384:
+		hash := sha512.Sum384(data)
+		valid = ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), hash[:], signature)
+	case coseES512:
+		hash := sha512.Sum512(data)
+		valid = ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), hash[:], signature)
+	case coseRS256:
+		hash := sha256.Sum256(data)
+		valid = rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, hash[:], signature) == nil
+	case coseEdDSA:
+		valid = ed25519.Verify(key.(ed25519.PublicKey), data, signature)
+	}
+	if !valid {
+		return fmt.Errorf("%w: invalid signature", ErrAuthenticationFailed)
+	}
+	return nil
+}
+
+// newWebAuthnChallenge returns 32 bytes of the random source of the request,
+// see [op.RandomFromContext].
+func newWebAuthnChallenge(ctx context.Context) (string, error) {
+	return zcrypto.RandomString(op.RandomFromContext(ctx), 32)
+}
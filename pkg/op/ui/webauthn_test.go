@@ -0,0 +1,183 @@
+package ui_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op/ui"
+)
+
+type webAuthnStorage map[string]*ui.WebAuthnCredential
+
+func (s webAuthnStorage) WebAuthnCredentials(_ context.Context, subject string) ([]ui.WebAuthnCredential, error) {
+	var credentials []ui.WebAuthnCredential
+	for _, credential := range s {
+		if credential.Subject == subject {
+			credentials = append(credentials, *credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (s webAuthnStorage) WebAuthnCredentialByID(_ context.Context, id []byte) (*ui.WebAuthnCredential, error) {
+	credential, ok := s[string(id)]
+	if !ok {
+		return nil, errors.New("credential not found")
+	}
+	return credential, nil
+}
+
+func (s webAuthnStorage) UpdateWebAuthnSignCount(_ context.Context, id []byte, signCount uint32) error {
+	s[string(id)].SignCount = signCount
+	return nil
+}
+
+// testAuthenticator simulates a security key with a single credential.
+type testAuthenticator struct {
+	id  []byte
+	key *ecdsa.PrivateKey
+}
+
+func authenticatorData(rpID string, flags byte, signCount uint32) []byte {
+	hash := sha256.Sum256([]byte(rpID))
+	data := append(hash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+func clientData(t *testing.T, ceremony, challenge, origin string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": origin})
+	require.NoError(t, err)
+	return data
+}
+
+func (a *testAuthenticator) register(t *testing.T, w *ui.WebAuthn, subject string) *ui.WebAuthnCredential {
+	t.Helper()
+	options, state, err := w.BeginRegistration(context.Background(), subject, "Test User")
+	require.NoError(t, err)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString([]byte(subject)), options.User.ID)
+
+	authData := authenticatorData(w.RPID, 0x45, 0)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	publicKey, err := x509.MarshalPKIXPublicKey(&a.key.PublicKey)
+	require.NoError(t, err)
+	credential, err := w.FinishRegistration(subject, state, &ui.WebAuthnAttestation{
+		ClientDataJSON:    clientData(t, "webauthn.create", options.Challenge, "https://example.com"),
+		AuthenticatorData: authData,
+		PublicKey:         publicKey,
+		Algorithm:         -7,
+	})
+	require.NoError(t, err)
+	return credential
+}
+
+func (a *testAuthenticator) assert(t *testing.T, rpID, challenge, origin string, flags byte, signCount uint32) *http.Request {
+	t.Helper()
+	clientDataJSON := clientData(t, "webauthn.get", challenge, origin)
+	authData := authenticatorData(rpID, flags, signCount)
+	hash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(bytes.Clone(authData), hash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+
+	form := url.Values{
+		"credential_id":      {base64.RawURLEncoding.EncodeToString(a.id)},
+		"client_data":        {base64.RawURLEncoding.EncodeToString(clientDataJSON)},
+		"authenticator_data": {base64.RawURLEncoding.EncodeToString(authData)},
+		"signature":          {base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestWebAuthn(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	device := &testAuthenticator{id: []byte("credential-1"), key: key}
+	storage := make(webAuthnStorage)
+	w := &ui.WebAuthn{RPID: "example.com", Storage: storage}
+
+	credential := device.register(t, w, "id2")
+	assert.Equal(t, device.id, credential.ID)
+	storage[string(credential.ID)] = credential
+
+	ctx := context.Background()
+	verify := func(login *ui.Login, origin string, flags byte, signCount uint32) error {
+		challenge, state, err := w.Challenge(ctx, login)
+		require.NoError(t, err)
+		options := challenge.(*ui.WebAuthnRequestOptions)
+		_, err = w.Verify(ctx, device.assert(t, "example.com", options.Challenge, origin, flags, signCount), login, state)
+		return err
+	}
+
+	challenge, state, err := w.Challenge(ctx, &ui.Login{Subject: "id2"})
+	require.NoError(t, err)
+	options := challenge.(*ui.WebAuthnRequestOptions)
+	require.Len(t, options.AllowCredentials, 1)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(device.id), options.AllowCredentials[0].ID)
+
+	result, err := w.Verify(ctx, device.assert(t, "example.com", options.Challenge, "https://example.com", 0x05, 1), &ui.Login{}, state)
+	require.NoError(t, err)
+	assert.Equal(t, "id2", result.Subject)
+	assert.Equal(t, []string{oidc.AMRHardwareKey, oidc.AMRUserPresence, oidc.AMRMultipleFactor}, result.Methods)
+	assert.Equal(t, uint32(1), storage[string(device.id)].SignCount)
+
+	_, err = w.Verify(ctx, device.assert(t, "example.com", options.Challenge, "https://example.com", 0x05, 2), &ui.Login{}, "other")
+	assert.ErrorIs(t, err, ui.ErrAuthenticationFailed, "challenge")
+	assert.ErrorIs(t, verify(&ui.Login{}, "https://evil.example", 0x05, 2), ui.ErrAuthenticationFailed, "origin")
+	assert.ErrorIs(t, verify(&ui.Login{}, "https://example.com", 0x04, 2), ui.ErrAuthenticationFailed, "user presence")
+	assert.ErrorIs(t, verify(&ui.Login{}, "https://example.com", 0x05, 1), ui.ErrAuthenticationFailed, "sign count")
+	assert.ErrorIs(t, verify(&ui.Login{Subject: "id1"}, "https://example.com", 0x05, 2), ui.ErrAuthenticationFailed, "subject")
+
+	w.UserVerification = true
+	assert.ErrorIs(t, verify(&ui.Login{}, "https://example.com", 0x01, 2), ui.ErrAuthenticationFailed, "user verification")
+	assert.NoError(t, verify(&ui.Login{Subject: "id2"}, "https://example.com", 0x05, 2))
+}
+
+func TestWebAuthn_curveMismatch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	device := &testAuthenticator{id: []byte("credential-1"), key: key}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	storage := webAuthnStorage{string(device.id): {ID: device.id, Subject: "id2", PublicKey: publicKey, Algorithm: -7}}
+	w := &ui.WebAuthn{RPID: "example.com", Storage: storage}
+
+	ctx := context.Background()
+	options, state, err := w.BeginRegistration(ctx, "id2", "Test User")
+	require.NoError(t, err)
+	_, err = w.FinishRegistration("id2", state, &ui.WebAuthnAttestation{
+		ClientDataJSON:    clientData(t, "webauthn.create", options.Challenge, "https://example.com"),
+		AuthenticatorData: append(authenticatorData(w.RPID, 0x45, 0), make([]byte, 18)...),
+		PublicKey:         publicKey,
+		Algorithm:         -7,
+	})
+	assert.ErrorIs(t, err, ui.ErrAuthenticationFailed, "registration")
+
+	challenge, state, err := w.Challenge(ctx, &ui.Login{Subject: "id2"})
+	require.NoError(t, err)
+	r := device.assert(t, "example.com", challenge.(*ui.WebAuthnRequestOptions).Challenge, "https://example.com", 0x05, 1)
+	_, err = w.Verify(ctx, r, &ui.Login{}, state)
+	assert.ErrorIs(t, err, ui.ErrAuthenticationFailed, "assertion")
+}
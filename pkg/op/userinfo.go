@@ -23,28 +23,79 @@ func userinfoHandler(userinfoProvider UserinfoProvider) func(http.ResponseWriter
 	}
 }
 
+// UserinfoStorage is an optional alternative to the SetUserinfoFromToken method of [Storage],
+// which returns the claims as custom type C, for example a struct with the claim schema of the provider.
+// It can be set to a [Provider] using [WithUserinfoStorage], or used directly with [UserinfoClaims].
+type UserinfoStorage[C any] interface {
+	UserinfoFromToken(ctx context.Context, tokenID, subject, origin string) (C, error)
+}
+
+type userinfoFunc func(ctx context.Context, tokenID, subject, origin string) (any, error)
+
+func newUserinfoFunc[C any](storage UserinfoStorage[C]) userinfoFunc {
+	return func(ctx context.Context, tokenID, subject, origin string) (any, error) {
+		return storage.UserinfoFromToken(ctx, tokenID, subject, origin)
+	}
+}
+
+type userinfoFuncGetter interface {
+	userinfoFunc() userinfoFunc
+}
+
+// getUserinfoFunc returns the function set by [WithUserinfoStorage],
+// or defaults to the SetUserinfoFromToken method of the Storage.
+func getUserinfoFunc(userinfoProvider UserinfoProvider) userinfoFunc {
+	if g, ok := userinfoProvider.(userinfoFuncGetter); ok {
+		if f := g.userinfoFunc(); f != nil {
+			return f
+		}
+	}
+	return func(ctx context.Context, tokenID, subject, origin string) (any, error) {
+		info := new(oidc.UserInfo)
+		err := userinfoProvider.Storage().SetUserinfoFromToken(ctx, info, tokenID, subject, origin)
+		return info, err
+	}
+}
+
 func Userinfo(w http.ResponseWriter, r *http.Request, userinfoProvider UserinfoProvider) {
 	ctx, span := tracer.Start(r.Context(), "Userinfo")
 	r = r.WithContext(ctx)
 	defer span.End()
 
+	userinfo(w, r, userinfoProvider, getUserinfoFunc(userinfoProvider))
+}
+
+// UserinfoClaims handles the userinfo request like [Userinfo],
+// but obtains the claims of type C from the passed storage.
+func UserinfoClaims[C any](w http.ResponseWriter, r *http.Request, userinfoProvider UserinfoProvider, storage UserinfoStorage[C]) {
+	ctx, span := tracer.Start(r.Context(), "UserinfoClaims")
+	r = r.WithContext(ctx)
+	defer span.End()
+
+	userinfo(w, r, userinfoProvider, newUserinfoFunc(storage))
+}
+
+func userinfo(w http.ResponseWriter, r *http.Request, userinfoProvider UserinfoProvider, fromToken userinfoFunc) {
 	accessToken, err := ParseUserinfoRequest(r, userinfoProvider.Decoder())
 	if err != nil {
-		http.Error(w, "access token missing", http.StatusUnauthorized)
+		WriteText(w, "access token missing", http.StatusUnauthorized)
 		return
 	}
-	tokenID, subject, ok := getTokenIDAndSubject(r.Context(), userinfoProvider, accessToken)
+	tokenID, subject, clientID, ok := getTokenIDAndSubject(r.Context(), userinfoProvider, accessToken)
 	if !ok {
-		http.Error(w, "access token invalid", http.StatusUnauthorized)
+		WriteText(w, "access token invalid", http.StatusUnauthorized)
 		return
 	}
-	info := new(oidc.UserInfo)
-	err = userinfoProvider.Storage().SetUserinfoFromToken(r.Context(), info, tokenID, subject, r.Header.Get("origin"))
+	ctx := oidc.ContextWithSubject(r.Context(), subject)
+	info, err := fromToken(ctx, tokenID, subject, r.Header.Get("origin"))
+	if err == nil {
+		err = setUserinfoSubject(ctx, userinfoProvider.Storage(), info, tokenID, clientID)
+	}
 	if err != nil {
-		httphelper.MarshalJSONWithStatus(w, err, http.StatusForbidden)
+		WriteJSON(w, err, http.StatusForbidden)
 		return
 	}
-	httphelper.MarshalJSON(w, info)
+	WriteJSON(w, info, http.StatusOK)
 }
 
 func ParseUserinfoRequest(r *http.Request, decoder httphelper.Decoder) (string, error) {
@@ -84,7 +135,9 @@ func getAccessToken(r *http.Request) (string, error) {
 	return parts[1], nil
 }
 
-func getTokenIDAndSubject(ctx context.Context, userinfoProvider UserinfoProvider, accessToken string) (string, string, bool) {
+// getTokenIDAndSubject returns the token id and the internal subject of an access token.
+// The client ID is only returned for JWT access tokens and empty for opaque tokens.
+func getTokenIDAndSubject(ctx context.Context, userinfoProvider UserinfoProvider, accessToken string) (tokenID, subject, clientID string, ok bool) {
 	ctx, span := tracer.Start(ctx, "getTokenIDAndSubject")
 	defer span.End()
 
@@ -92,13 +145,35 @@ func getTokenIDAndSubject(ctx context.Context, userinfoProvider UserinfoProvider
 	if err == nil {
 		splitToken := strings.Split(tokenIDSubject, ":")
 		if len(splitToken) != 2 {
-			return "", "", false
+			return "", "", "", false
 		}
-		return splitToken[0], splitToken[1], true
+		return splitToken[0], splitToken[1], "", true
 	}
 	accessTokenClaims, err := VerifyAccessToken[*oidc.AccessTokenClaims](ctx, accessToken, userinfoProvider.AccessTokenVerifier(ctx))
 	if err != nil {
-		return "", "", false
+		return "", "", "", false
+	}
+	subject, err = internalSubject(ctx, userinfoProvider.Storage(), accessTokenClaims.Subject, accessTokenClaims.ClientID)
+	if err != nil {
+		return "", "", "", false
+	}
+	return accessTokenClaims.JWTID, subject, accessTokenClaims.ClientID, true
+}
+
+// setUserinfoSubject translates the subject of the default
+// userinfo response, if the storage implements [SubjectMapper].
+// The clientID of opaque access tokens is resolved by the tokenID,
+// see [CanGetTokenClientID].
+func setUserinfoSubject(ctx context.Context, storage Storage, info any, tokenID, clientID string) (err error) {
+	userinfo, ok := info.(*oidc.UserInfo)
+	if !ok || userinfo.Subject == "" {
+		return nil
+	}
+	if clientID == "" {
+		if clientID, err = tokenClientID(ctx, storage, tokenID); err != nil {
+			return err
+		}
 	}
-	return accessTokenClaims.JWTID, accessTokenClaims.Subject, true
+	userinfo.Subject, err = externalSubject(ctx, storage, userinfo.Subject, clientID)
+	return err
 }
@@ -0,0 +1,167 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httphelper "github.com/lmindwarel/oidc/v3/pkg/http"
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zitadel/schema"
+)
+
+type testClaimsProvider struct {
+	crypto Crypto
+}
+
+func (p testClaimsProvider) Decoder() httphelper.Decoder                              { return schema.NewDecoder() }
+func (p testClaimsProvider) Crypto() Crypto                                           { return p.crypto }
+func (p testClaimsProvider) Storage() Storage                                         { return nil }
+func (p testClaimsProvider) AccessTokenVerifier(context.Context) *AccessTokenVerifier { return nil }
+
+type testUserClaims struct {
+	Subject    string `json:"sub"`
+	Department string `json:"department"`
+}
+
+type testClaimsStorage struct{}
+
+func (testClaimsStorage) UserinfoFromToken(_ context.Context, tokenID, subject, _ string) (*testUserClaims, error) {
+	if tokenID != "tokenID" {
+		return nil, errors.New("unknown token")
+	}
+	return &testUserClaims{Subject: subject, Department: "engineering"}, nil
+}
+
+type testIntrospectionClaims struct {
+	Active     bool   `json:"active"`
+	Subject    string `json:"sub"`
+	Department string `json:"department"`
+}
+
+func (c *testIntrospectionClaims) SetActive(active bool) {
+	c.Active = active
+}
+
+func (testClaimsStorage) IntrospectionFromToken(_ context.Context, tokenID, subject, _ string) (*testIntrospectionClaims, error) {
+	if tokenID != "tokenID" {
+		return nil, errors.New("unknown token")
+	}
+	return &testIntrospectionClaims{Subject: subject, Department: "engineering"}, nil
+}
+
+func TestUserinfoClaims(t *testing.T) {
+	provider := testClaimsProvider{crypto: NewAESCrypto([32]byte{1})}
+	validToken, err := CreateBearerToken("tokenID", "sub1", provider.crypto)
+	require.NoError(t, err)
+	unknownToken, err := CreateBearerToken("other", "sub1", provider.crypto)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "unknown token",
+			token:      unknownToken,
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{}`,
+		},
+		{
+			name:       "success",
+			token:      validToken,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"sub":"sub1","department":"engineering"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			r.Header.Set("authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			UserinfoClaims[*testUserClaims](w, r, provider, testClaimsStorage{})
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
+
+func Test_introspectToken(t *testing.T) {
+	provider := testClaimsProvider{crypto: NewAESCrypto([32]byte{1})}
+	validToken, err := CreateBearerToken("tokenID", "sub1", provider.crypto)
+	require.NoError(t, err)
+	unknownToken, err := CreateBearerToken("other", "sub1", provider.crypto)
+	require.NoError(t, err)
+	fromToken := newIntrospectionFunc[*testIntrospectionClaims](testClaimsStorage{})
+
+	_, ok := introspectToken(context.Background(), provider, fromToken, unknownToken, "client")
+	assert.False(t, ok)
+
+	got, ok := introspectToken(context.Background(), provider, fromToken, validToken, "client")
+	require.True(t, ok)
+	assert.Equal(t, &testIntrospectionClaims{Active: true, Subject: "sub1", Department: "engineering"}, got)
+}
+
+type scopeArrayProvider struct {
+	testClaimsProvider
+}
+
+func (scopeArrayProvider) ScopeArrayClaim() bool { return true }
+
+func Test_introspectToken_scopeArray(t *testing.T) {
+	provider := scopeArrayProvider{testClaimsProvider{crypto: NewAESCrypto([32]byte{1})}}
+	token, err := CreateBearerToken("tokenID", "sub1", provider.crypto)
+	require.NoError(t, err)
+	fromToken := func(_ context.Context, _, subject, _ string) (any, error) {
+		return &oidc.IntrospectionResponse{Active: true, Subject: subject, Scope: oidc.SpaceDelimitedArray{"openid", "api"}}, nil
+	}
+
+	got, ok := introspectToken(context.Background(), provider, fromToken, token, "client")
+	require.True(t, ok)
+	body, err := json.Marshal(got)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"active":true,"sub":"sub1","scope":"openid api","scp":["openid","api"]}`, string(body))
+}
+
+type testSubjectMapperStorage struct {
+	Storage
+	*PairwiseSubjectMapper
+}
+
+type testTokenClientStorage struct {
+	testSubjectMapperStorage
+}
+
+func (testTokenClientStorage) GetTokenClientID(_ context.Context, tokenID string) (string, error) {
+	if tokenID != "tokenID" {
+		return "", errors.New("unknown token")
+	}
+	return "client1", nil
+}
+
+func Test_setUserinfoSubject(t *testing.T) {
+	ctx := context.Background()
+	mapper := &PairwiseSubjectMapper{Key: []byte("secret")}
+	want, err := mapper.ExternalSubject(ctx, "user1", "client1")
+	require.NoError(t, err)
+	storage := testSubjectMapperStorage{PairwiseSubjectMapper: mapper}
+
+	info := &oidc.UserInfo{Subject: "user1"}
+	require.NoError(t, setUserinfoSubject(ctx, storage, info, "tokenID", "client1"))
+	assert.Equal(t, want, info.Subject, "client of the JWT access token")
+
+	info = &oidc.UserInfo{Subject: "user1"}
+	require.NoError(t, setUserinfoSubject(ctx, testTokenClientStorage{storage}, info, "tokenID", ""))
+	assert.Equal(t, want, info.Subject, "client of the opaque access token resolved by the storage")
+
+	info = &oidc.UserInfo{Subject: "user1"}
+	err = setUserinfoSubject(ctx, storage, info, "tokenID", "")
+	assert.ErrorIs(t, err, ErrUnknownTokenClient)
+}
@@ -34,17 +34,20 @@ func VerifyAccessToken[C oidc.Claims](ctx context.Context, token string, v *Acce
 	defer span.End()
 
 	var nilClaims C
+	explanation := oidc.ExplanationFromContext(ctx)
+	defer func() { explanation.Decide(err) }()
 
 	decrypted, err := oidc.DecryptToken(token)
 	if err != nil {
 		return nilClaims, err
 	}
 	payload, err := oidc.ParseToken(decrypted, &claims)
-	if err != nil {
+	if err = explanation.Check("parse", err); err != nil {
 		return nilClaims, err
 	}
 
-	if err := oidc.CheckIssuer(claims, v.Issuer); err != nil {
+	if err := explanation.Check("iss", oidc.CheckIssuerMatch(claims, v.Issuer, v.AllowInsecure),
+		"expected", v.Issuer, "actual", claims.GetIssuer()); err != nil {
 		return nilClaims, err
 	}
 
@@ -52,7 +55,8 @@ func VerifyAccessToken[C oidc.Claims](ctx context.Context, token string, v *Acce
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckExpiration(claims, v.Offset); err != nil {
+	if err = explanation.Check("exp", oidc.CheckExpiration(claims, v.Offset),
+		"actual", claims.GetExpiration(), "offset", v.Offset); err != nil {
 		return nilClaims, err
 	}
 
@@ -50,17 +50,20 @@ func VerifyIDTokenHint[C oidc.Claims](ctx context.Context, token string, v *IDTo
 	defer span.End()
 
 	var nilClaims C
+	explanation := oidc.ExplanationFromContext(ctx)
+	defer func() { explanation.Decide(err) }()
 
 	decrypted, err := oidc.DecryptToken(token)
 	if err != nil {
 		return nilClaims, err
 	}
 	payload, err := oidc.ParseToken(decrypted, &claims)
-	if err != nil {
+	if err = explanation.Check("parse", err); err != nil {
 		return nilClaims, err
 	}
 
-	if err := oidc.CheckIssuer(claims, v.Issuer); err != nil {
+	if err := explanation.Check("iss", oidc.CheckIssuerMatch(claims, v.Issuer, v.AllowInsecure),
+		"expected", v.Issuer, "actual", claims.GetIssuer()); err != nil {
 		return nilClaims, err
 	}
 
@@ -68,19 +71,23 @@ func VerifyIDTokenHint[C oidc.Claims](ctx context.Context, token string, v *IDTo
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckAuthorizationContextClassReference(claims, v.ACR); err != nil {
+	if err = explanation.Check("acr", oidc.CheckAuthorizationContextClassReference(claims, v.ACR),
+		"actual", claims.GetAuthenticationContextClassReference()); err != nil {
 		return nilClaims, err
 	}
 
-	if err = oidc.CheckExpiration(claims, v.Offset); err != nil {
+	if err = explanation.Check("exp", oidc.CheckExpiration(claims, v.Offset),
+		"actual", claims.GetExpiration(), "offset", v.Offset); err != nil {
 		return claims, IDTokenHintExpiredError{err}
 	}
 
-	if err = oidc.CheckIssuedAt(claims, v.MaxAgeIAT, v.Offset); err != nil {
+	if err = explanation.Check("iat", oidc.CheckIssuedAt(claims, v.MaxAgeIAT, v.Offset),
+		"actual", claims.GetIssuedAt(), "max_age", v.MaxAgeIAT, "offset", v.Offset); err != nil {
 		return claims, IDTokenHintExpiredError{err}
 	}
 
-	if err = oidc.CheckAuthTime(claims, v.MaxAge); err != nil {
+	if err = explanation.Check("auth_time", oidc.CheckAuthTime(claims, v.MaxAge),
+		"actual", claims.GetAuthTime(), "max_age", v.MaxAge); err != nil {
 		return claims, IDTokenHintExpiredError{err}
 	}
 	return claims, nil
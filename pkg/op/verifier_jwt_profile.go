@@ -18,6 +18,7 @@ type JWTProfileVerifier struct {
 	oidc.Verifier
 	Storage      JWTProfileKeyStorage
 	keySet       oidc.KeySet
+	clientJWKS   *ClientJWKSCache
 	CheckSubject func(request *oidc.JWTTokenRequest) error
 }
 
@@ -60,38 +61,56 @@ func SubjectCheck(check func(request *oidc.JWTTokenRequest) error) JWTProfileVer
 	}
 }
 
+// ClientJWKS verifies the assertions of clients implementing [HasJWKSURI]
+// with the keys of their jwks_uri, fetched by the cache.
+// It requires the storage of the verifier to implement GetClientByClientID.
+func ClientJWKS(cache *ClientJWKSCache) JWTProfileVerifierOption {
+	return func(verifier *JWTProfileVerifier) {
+		verifier.clientJWKS = cache
+	}
+}
+
 // VerifyJWTAssertion verifies the assertion string from JWT Profile (authorization grant and client authentication)
 //
 // checks audience, exp, iat, signature and that issuer and sub are the same
-func VerifyJWTAssertion(ctx context.Context, assertion string, v *JWTProfileVerifier) (*oidc.JWTTokenRequest, error) {
+func VerifyJWTAssertion(ctx context.Context, assertion string, v *JWTProfileVerifier) (_ *oidc.JWTTokenRequest, err error) {
 	ctx, span := tracer.Start(ctx, "VerifyJWTAssertion")
 	defer span.End()
 
+	explanation := oidc.ExplanationFromContext(ctx)
+	defer func() { explanation.Decide(err) }()
+
 	request := new(oidc.JWTTokenRequest)
 	payload, err := oidc.ParseToken(assertion, request)
-	if err != nil {
+	if err = explanation.Check("parse", err); err != nil {
 		return nil, err
 	}
 
-	if err = oidc.CheckAudience(request, v.Issuer); err != nil {
+	if !oidc.ContainsIssuer(request.GetAudience(), v.Issuer, v.AllowInsecure) {
+		err = fmt.Errorf("%w: Audience must contain the issuer %q", oidc.ErrAudience, v.Issuer)
+	}
+	if err = explanation.Check("aud", err, "expected", v.Issuer, "actual", request.GetAudience()); err != nil {
 		return nil, err
 	}
 
-	if err = oidc.CheckExpiration(request, v.Offset); err != nil {
+	if err = explanation.Check("exp", oidc.CheckExpiration(request, v.Offset),
+		"actual", request.GetExpiration(), "offset", v.Offset); err != nil {
 		return nil, err
 	}
 
-	if err = oidc.CheckIssuedAt(request, v.MaxAgeIAT, v.Offset); err != nil {
+	if err = explanation.Check("iat", oidc.CheckIssuedAt(request, v.MaxAgeIAT, v.Offset),
+		"actual", request.GetIssuedAt(), "max_age", v.MaxAgeIAT, "offset", v.Offset); err != nil {
 		return nil, err
 	}
 
-	if err = v.CheckSubject(request); err != nil {
+	if err = explanation.Check("sub", v.CheckSubject(request),
+		"iss", request.Issuer, "actual", request.Subject); err != nil {
 		return nil, err
 	}
 
 	keySet := v.keySet
 	if keySet == nil {
-		keySet = &jwtProfileKeySet{storage: v.Storage, clientID: request.Issuer}
+		keySet = &jwtProfileKeySet{storage: v.Storage, clientID: request.Issuer, clientJWKS: v.clientJWKS}
 	}
 	if err = oidc.CheckSignature(ctx, assertion, payload, request, nil, keySet); err != nil {
 		return nil, err
@@ -112,19 +131,31 @@ func SubjectIsIssuer(request *oidc.JWTTokenRequest) error {
 }
 
 type jwtProfileKeySet struct {
-	storage  JWTProfileKeyStorage
-	clientID string
+	storage    JWTProfileKeyStorage
+	clientID   string
+	clientJWKS *ClientJWKSCache
 }
 
-// VerifySignature implements oidc.KeySet by getting the public key from Storage implementation
+// VerifySignature implements oidc.KeySet by getting the public key from Storage implementation,
+// or from the jwks_uri of clients implementing [HasJWKSURI].
 func (k *jwtProfileKeySet) VerifySignature(ctx context.Context, jws *jose.JSONWebSignature) (payload []byte, err error) {
 	ctx, span := tracer.Start(ctx, "VerifySignature")
 	defer span.End()
 
 	keyID, _ := oidc.GetKeyIDAndAlg(jws)
+	if k.clientJWKS != nil {
+		if jwksURI := clientJWKSURI(ctx, k.storage, k.clientID); jwksURI != "" {
+			payload, err = k.clientJWKS.VerifySignature(ctx, jwksURI, jws)
+			oidc.ExplanationFromContext(ctx).Check("key", err, "kid", keyID, "client_id", k.clientID, "jwks_uri", jwksURI)
+			return payload, err
+		}
+	}
 	key, err := k.storage.GetKeyByIDAndClientID(ctx, keyID, k.clientID)
 	if err != nil {
+		oidc.ExplanationFromContext(ctx).Check("key", err, "kid", keyID, "client_id", k.clientID)
 		return nil, fmt.Errorf("error fetching keys: %w", err)
 	}
-	return jws.Verify(key)
+	payload, err = jws.Verify(key)
+	oidc.ExplanationFromContext(ctx).Check("key", err, "kid", keyID, "client_id", k.clientID)
+	return payload, err
 }
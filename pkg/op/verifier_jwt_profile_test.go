@@ -2,9 +2,15 @@ package op_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	jose "github.com/go-jose/go-jose/v4"
+
 	tu "github.com/lmindwarel/oidc/v3/internal/testutil"
 	"github.com/lmindwarel/oidc/v3/pkg/oidc"
 	"github.com/lmindwarel/oidc/v3/pkg/op"
@@ -115,3 +121,45 @@ func TestVerifyJWTAssertion(t *testing.T) {
 		})
 	}
 }
+
+// jwksURIClient publishes its keys at a jwks_uri.
+type jwksURIClient struct {
+	op.Client
+	jwksURI string
+}
+
+func (c jwksURIClient) JWKSURI() string {
+	return c.jwksURI
+}
+
+// jwksURIStorage has no keys of the clients.
+type jwksURIStorage struct {
+	jwksURI string
+}
+
+func (jwksURIStorage) GetKeyByIDAndClientID(context.Context, string, string) (*jose.JSONWebKey, error) {
+	return nil, errors.New("keys are not stored")
+}
+
+func (s jwksURIStorage) GetClientByClientID(context.Context, string) (op.Client, error) {
+	return jwksURIClient{jwksURI: s.jwksURI}, nil
+}
+
+func TestVerifyJWTAssertion_clientJWKS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{tu.WebKey.Public()}})
+	}))
+	defer server.Close()
+	storage := jwksURIStorage{jwksURI: server.URL}
+	assertion, want := tu.ValidJWTProfileAssertion()
+
+	verifier := op.NewJWTProfileVerifier(storage, tu.ValidIssuer, time.Minute, 0, op.ClientJWKS(op.NewClientJWKSCache(server.Client())))
+	got, err := op.VerifyJWTAssertion(context.Background(), assertion, verifier)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	verifier = op.NewJWTProfileVerifier(storage, tu.ValidIssuer, time.Minute, 0)
+	_, err = op.VerifyJWTAssertion(context.Background(), assertion, verifier)
+	assert.Error(t, err, "keys of the storage")
+}
@@ -0,0 +1,185 @@
+// Package persist provides versioned (de)serialization of the structures
+// persisted by Storage implementations, such as auth requests, token claims
+// and device authorization states.
+//
+// The data is wrapped in an envelope carrying the kind and version of the
+// structure. When a library upgrade changes the shape of a structure, its
+// version is incremented and a migration is registered, which upgrades data
+// persisted by previous versions on read, so outstanding sessions stay valid.
+// Data persisted as plain JSON, before using this package, is read as version 0.
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+var (
+	ErrKindMismatch       = errors.New("persist: kind mismatch")
+	ErrUnsupportedVersion = errors.New("persist: unsupported version")
+)
+
+// Envelope is the serialized form of a versioned structure.
+type Envelope struct {
+	Kind    string          `json:"kind"`
+	Version int             `json:"v"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Migration upgrades the decoded JSON object of a structure
+// from one version to the next, e.g. by renaming a field.
+type Migration func(data map[string]any) error
+
+// MigrateHook is called after data of an older version was migrated,
+// e.g. to persist the migrated data or for metrics.
+type MigrateHook func(kind string, from, to int)
+
+// Codec serializes values of type T with the kind and the current version.
+// Migrations and hooks must be registered before the Codec is used,
+// typically at init.
+type Codec[T any] struct {
+	kind       string
+	version    int
+	migrations map[int]Migration
+	hooks      []MigrateHook
+}
+
+// NewCodec returns a Codec for the kind in the version.
+// Version 0 is reserved for data persisted without envelope.
+func NewCodec[T any](kind string, version int) *Codec[T] {
+	return &Codec[T]{
+		kind:       kind,
+		version:    version,
+		migrations: make(map[int]Migration),
+	}
+}
+
+func (c *Codec[T]) Kind() string {
+	return c.kind
+}
+
+func (c *Codec[T]) Version() int {
+	return c.version
+}
+
+// Register adds the migration upgrading data of version from to version from+1.
+// Without migration, data of an older version is decoded as is, which is
+// sufficient for added fields.
+func (c *Codec[T]) Register(from int, migration Migration) *Codec[T] {
+	c.migrations[from] = migration
+	return c
+}
+
+// OnMigrate adds a hook, called after data of an older version was migrated.
+func (c *Codec[T]) OnMigrate(hook MigrateHook) *Codec[T] {
+	c.hooks = append(c.hooks, hook)
+	return c
+}
+
+// Marshal returns the JSON envelope of the value in the current version.
+func (c *Codec[T]) Marshal(v T) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&Envelope{
+		Kind:    c.kind,
+		Version: c.version,
+		Data:    data,
+	})
+}
+
+// Unmarshal decodes the data, written by Marshal of the current
+// or an older version, or as plain JSON (version 0).
+func (c *Codec[T]) Unmarshal(data []byte) (T, error) {
+	v, _, err := c.UnmarshalMigrated(data)
+	return v, err
+}
+
+// UnmarshalMigrated is like Unmarshal and reports whether the data was of an
+// older version, so the caller can persist it again in the current version.
+func (c *Codec[T]) UnmarshalMigrated(data []byte) (v T, migrated bool, err error) {
+	version, payload, err := c.open(data)
+	if err != nil {
+		return v, false, err
+	}
+	if version > c.version {
+		return v, false, fmt.Errorf("%w: %s version %d, supported up to %d", ErrUnsupportedVersion, c.kind, version, c.version)
+	}
+	if version < c.version {
+		if payload, err = c.migrate(version, payload); err != nil {
+			return v, false, err
+		}
+		for _, hook := range c.hooks {
+			hook(c.kind, version, c.version)
+		}
+	}
+	if err = json.Unmarshal(payload, &v); err != nil {
+		return v, false, fmt.Errorf("persist: %s: %w", c.kind, err)
+	}
+	return v, version < c.version, nil
+}
+
+// open returns the version and payload of the envelope,
+// or version 0 and the data, if it is not enveloped.
+func (c *Codec[T]) open(data []byte) (int, json.RawMessage, error) {
+	var envelope struct {
+		Kind    *string         `json:"kind"`
+		Version *int            `json:"v"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Kind == nil || envelope.Version == nil || envelope.Data == nil {
+		return 0, data, nil
+	}
+	if *envelope.Kind != c.kind {
+		return 0, nil, fmt.Errorf("%w: %q, expected %q", ErrKindMismatch, *envelope.Kind, c.kind)
+	}
+	return *envelope.Version, envelope.Data, nil
+}
+
+func (c *Codec[T]) migrate(version int, payload json.RawMessage) (json.RawMessage, error) {
+	if !c.hasMigrations(version) {
+		return payload, nil
+	}
+	var object map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("persist: %s version %d: %w", c.kind, version, err)
+	}
+	for ; version < c.version; version++ {
+		migration, ok := c.migrations[version]
+		if !ok {
+			continue
+		}
+		if err := migration(object); err != nil {
+			return nil, fmt.Errorf("persist: migrate %s from version %d: %w", c.kind, version, err)
+		}
+	}
+	return json.Marshal(object)
+}
+
+func (c *Codec[T]) hasMigrations(from int) bool {
+	for version := from; version < c.version; version++ {
+		if _, ok := c.migrations[version]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Codecs of the persisted structures of this library.
+// The versions are incremented and migrations registered,
+// when a release changes the shape of a structure.
+var (
+	AuthRequest              = NewCodec[*oidc.AuthRequest]("oidc.AuthRequest", 1)
+	IDTokenClaims            = NewCodec[*oidc.IDTokenClaims]("oidc.IDTokenClaims", 1)
+	AccessTokenClaims        = NewCodec[*oidc.AccessTokenClaims]("oidc.AccessTokenClaims", 1)
+	IntrospectionResponse    = NewCodec[*oidc.IntrospectionResponse]("oidc.IntrospectionResponse", 1)
+	DeviceAuthorizationState = NewCodec[*op.DeviceAuthorizationState]("op.DeviceAuthorizationState", 1)
+)
@@ -0,0 +1,133 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lmindwarel/oidc/v3/pkg/oidc"
+	"github.com/lmindwarel/oidc/v3/pkg/op"
+)
+
+func TestCodec_roundTrip(t *testing.T) {
+	authReq := &oidc.AuthRequest{
+		Scopes:       oidc.SpaceDelimitedArray{"openid", "profile"},
+		ResponseType: oidc.ResponseTypeCode,
+		ClientID:     "web",
+		RedirectURI:  "https://example.com/callback",
+		State:        "state",
+		Prompt:       oidc.SpaceDelimitedArray{oidc.PromptLogin},
+		ACRValues:    oidc.SpaceDelimitedArray{"urn:mace:incommon:iap:silver"},
+	}
+	data, err := AuthRequest.Marshal(authReq)
+	require.NoError(t, err)
+
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal(data, &envelope))
+	assert.Equal(t, "oidc.AuthRequest", envelope.Kind)
+	assert.Equal(t, 1, envelope.Version)
+
+	got, migrated, err := AuthRequest.UnmarshalMigrated(data)
+	require.NoError(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, authReq, got)
+}
+
+func TestCodec_plainJSON(t *testing.T) {
+	expires := time.Unix(1700000000, 0)
+	data := []byte(`{"ClientID":"device","Scopes":["openid"],"Expires":"2023-11-14T22:13:20Z","Done":true,"Subject":"tim"}`)
+
+	var migrations []int
+	codec := NewCodec[*op.DeviceAuthorizationState]("op.DeviceAuthorizationState", 1).
+		OnMigrate(func(kind string, from, to int) {
+			assert.Equal(t, "op.DeviceAuthorizationState", kind)
+			migrations = append(migrations, from, to)
+		})
+	got, migrated, err := codec.UnmarshalMigrated(data)
+	require.NoError(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, []int{0, 1}, migrations)
+	assert.Equal(t, "device", got.ClientID)
+	assert.True(t, got.Done)
+	assert.True(t, expires.Equal(got.Expires))
+}
+
+type sessionV3 struct {
+	Subject  string   `json:"subject"`
+	Audience []string `json:"audience"`
+	Expiry   int64    `json:"expiry"`
+}
+
+func TestCodec_migrations(t *testing.T) {
+	codec := NewCodec[*sessionV3]("session", 3).
+		// version 1 renamed "user_id" to "subject"
+		Register(0, func(data map[string]any) error {
+			data["subject"] = data["user_id"]
+			delete(data, "user_id")
+			return nil
+		}).
+		// version 3 changed the audience from a string to a list
+		Register(2, func(data map[string]any) error {
+			if aud, ok := data["audience"].(string); ok {
+				data["audience"] = []string{aud}
+			}
+			return nil
+		})
+
+	tests := []struct {
+		name         string
+		data         string
+		want         *sessionV3
+		wantMigrated bool
+		wantErr      error
+	}{
+		{
+			name:         "plain",
+			data:         `{"user_id":"tim","audience":"api","expiry":1700000000}`,
+			want:         &sessionV3{Subject: "tim", Audience: []string{"api"}, Expiry: 1700000000},
+			wantMigrated: true,
+		},
+		{
+			name:         "version 2",
+			data:         `{"kind":"session","v":2,"data":{"subject":"tim","audience":"api","expiry":1700000000}}`,
+			want:         &sessionV3{Subject: "tim", Audience: []string{"api"}, Expiry: 1700000000},
+			wantMigrated: true,
+		},
+		{
+			name: "current",
+			data: `{"kind":"session","v":3,"data":{"subject":"tim","audience":["api"],"expiry":1700000000}}`,
+			want: &sessionV3{Subject: "tim", Audience: []string{"api"}, Expiry: 1700000000},
+		},
+		{
+			name:    "newer version",
+			data:    `{"kind":"session","v":4,"data":{}}`,
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "other kind",
+			data:    `{"kind":"other","v":3,"data":{}}`,
+			wantErr: ErrKindMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, migrated, err := codec.UnmarshalMigrated([]byte(tt.data))
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantMigrated, migrated)
+		})
+	}
+}
+
+func TestCodec_migrationError(t *testing.T) {
+	errMigration := errors.New("migration failed")
+	codec := NewCodec[map[string]any]("session", 1).Register(0, func(map[string]any) error {
+		return errMigration
+	})
+	_, err := codec.Unmarshal([]byte(`{"subject":"tim"}`))
+	assert.ErrorIs(t, err, errMigration)
+}
@@ -0,0 +1,559 @@
+// Package qrcode encodes short texts, such as the verification_uri_complete
+// of the device authorization flow, as QR code, without external dependencies.
+//
+// Only the byte mode and the versions 1 to 10 (up to 271 bytes at level L)
+// are supported, which is sufficient for URLs.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// Level is the error correction level of a QR code.
+type Level int
+
+const (
+	// Low recovers 7% of the data.
+	Low Level = iota
+	// Medium recovers 15% of the data.
+	Medium
+	// Quartile recovers 25% of the data.
+	Quartile
+	// High recovers 30% of the data.
+	High
+)
+
+// formatBits of the level, as defined by ISO/IEC 18004, table 12.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+// MaxVersion is the largest supported version.
+const MaxVersion = 10
+
+// ErrTooLong is returned if the text exceeds the capacity of MaxVersion.
+var ErrTooLong = errors.New("qrcode: text too long")
+
+// ErrInvalidLevel is returned for an error correction level other than
+// [Low], [Medium], [Quartile] and [High].
+var ErrInvalidLevel = errors.New("qrcode: invalid error correction level")
+
+// blocks are the error correction parameters of a version and level:
+// number of error correction codewords per block, and the number of
+// blocks and data codewords per block of the two groups.
+type blocks struct {
+	ecc                            int
+	blocks1, data1, blocks2, data2 int
+}
+
+func (b blocks) dataCodewords() int {
+	return b.blocks1*b.data1 + b.blocks2*b.data2
+}
+
+// ecBlocks by version (index 1-10) and level, ISO/IEC 18004, table 9.
+var ecBlocks = [MaxVersion + 1][4]blocks{
+	1:  {{7, 1, 19, 0, 0}, {10, 1, 16, 0, 0}, {13, 1, 13, 0, 0}, {17, 1, 9, 0, 0}},
+	2:  {{10, 1, 34, 0, 0}, {16, 1, 28, 0, 0}, {22, 1, 22, 0, 0}, {28, 1, 16, 0, 0}},
+	3:  {{15, 1, 55, 0, 0}, {26, 1, 44, 0, 0}, {18, 2, 17, 0, 0}, {22, 2, 13, 0, 0}},
+	4:  {{20, 1, 80, 0, 0}, {18, 2, 32, 0, 0}, {26, 2, 24, 0, 0}, {16, 4, 9, 0, 0}},
+	5:  {{26, 1, 108, 0, 0}, {24, 2, 43, 0, 0}, {18, 2, 15, 2, 16}, {22, 2, 11, 2, 12}},
+	6:  {{18, 2, 68, 0, 0}, {16, 4, 27, 0, 0}, {24, 4, 19, 0, 0}, {28, 4, 15, 0, 0}},
+	7:  {{20, 2, 78, 0, 0}, {18, 4, 31, 0, 0}, {18, 2, 14, 4, 15}, {26, 4, 13, 1, 14}},
+	8:  {{24, 2, 97, 0, 0}, {22, 2, 38, 2, 39}, {22, 4, 18, 2, 19}, {26, 4, 14, 2, 15}},
+	9:  {{30, 2, 116, 0, 0}, {22, 3, 36, 2, 37}, {20, 4, 16, 4, 17}, {24, 4, 12, 4, 13}},
+	10: {{18, 2, 68, 2, 69}, {26, 4, 43, 1, 44}, {24, 6, 19, 2, 20}, {28, 6, 15, 2, 16}},
+}
+
+// alignmentPositions by version (index 1-10).
+var alignmentPositions = [MaxVersion + 1][]int{
+	2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30}, 6: {6, 34},
+	7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50},
+}
+
+// QRCode is an encoded QR code.
+type QRCode struct {
+	Version int
+	Level   Level
+	// Size is the number of modules per side, without quiet zone.
+	Size int
+
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// Encode encodes the text as QR code in byte mode, using the smallest
+// version with sufficient capacity for the error correction level.
+func Encode(text string, level Level) (*QRCode, error) {
+	if level < Low || level > High {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidLevel, level)
+	}
+	data := []byte(text)
+	for version := 1; version <= MaxVersion; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		capacity := ecBlocks[version][level].dataCodewords() * 8
+		if 4+countBits+len(data)*8 <= capacity {
+			return encode(data, version, level, countBits), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d bytes", ErrTooLong, len(data))
+}
+
+func encode(data []byte, version int, level Level, countBits int) *QRCode {
+	bb := new(bitBuffer)
+	bb.append(0b0100, 4) // byte mode
+	bb.append(len(data), countBits)
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	capacity := ecBlocks[version][level].dataCodewords() * 8
+	bb.append(0, min(4, capacity-bb.len()))
+	bb.append(0, (8-bb.len()%8)%8)
+	for pad := 0xEC; bb.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	size := version*4 + 17
+	q := &QRCode{
+		Version:    version,
+		Level:      level,
+		Size:       size,
+		modules:    newGrid(size),
+		isFunction: newGrid(size),
+	}
+	q.drawFunctionPatterns()
+	q.drawCodewords(interleave(bb.bytes(), ecBlocks[version][level]))
+
+	mask, minPenalty := 0, -1
+	for m := 0; m < 8; m++ {
+		q.applyMask(m)
+		q.drawFormatBits(m)
+		if penalty := q.penalty(); minPenalty < 0 || penalty < minPenalty {
+			mask, minPenalty = m, penalty
+		}
+		q.applyMask(m) // undo
+	}
+	q.applyMask(mask)
+	q.drawFormatBits(mask)
+	return q
+}
+
+func newGrid(size int) [][]bool {
+	grid := make([][]bool, size)
+	for i := range grid {
+		grid[i] = make([]bool, size)
+	}
+	return grid
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (q *QRCode) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < q.Size && y < q.Size && q.modules[y][x]
+}
+
+func (q *QRCode) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunction[y][x] = true
+}
+
+func (q *QRCode) drawFunctionPatterns() {
+	for i := 0; i < q.Size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+	q.drawFinderPattern(3, 3)
+	q.drawFinderPattern(q.Size-4, 3)
+	q.drawFinderPattern(3, q.Size-4)
+
+	positions := alignmentPositions[q.Version]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // finder patterns
+			}
+			q.drawAlignmentPattern(x, y)
+		}
+	}
+	q.drawFormatBits(0) // reserve the area, drawn after masking
+	q.drawVersion()
+}
+
+func (q *QRCode) drawFinderPattern(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (q *QRCode) drawAlignmentPattern(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the 15 bit format information,
+// BCH(15,5) encoded and masked as defined by ISO/IEC 18004, section 7.9.
+func (q *QRCode) drawFormatBits(mask int) {
+	data := q.Level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.Size-15+i, bit(i))
+	}
+	q.setFunction(8, q.Size-8, true) // dark module
+}
+
+// drawVersion draws the BCH(18,6) encoded version information of versions 7 and up.
+func (q *QRCode) drawVersion() {
+	if q.Version < 7 {
+		return
+	}
+	bits := versionBits(q.Version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 != 0
+		a, b := q.Size-11+i%3, i/3
+		q.setFunction(a, b, dark)
+		q.setFunction(b, a, dark)
+	}
+}
+
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawCodewords places the codewords in the zigzag pattern
+// of ISO/IEC 18004, section 7.7.3.
+func (q *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.Size; vert++ {
+			y := vert
+			if upward {
+				y = q.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if q.isFunction[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				q.modules[y][x] = codewords[i>>3]>>(7-i&7)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			q.modules[y][x] = q.modules[y][x] != invert
+		}
+	}
+}
+
+// penalty scores the symbol by the rules of ISO/IEC 18004, section 7.8.3.
+func (q *QRCode) penalty() int {
+	var penalty, dark int
+	line := make([]bool, q.Size)
+	for _, horizontal := range []bool{true, false} {
+		for i := 0; i < q.Size; i++ {
+			for j := 0; j < q.Size; j++ {
+				if horizontal {
+					line[j] = q.modules[i][j]
+				} else {
+					line[j] = q.modules[j][i]
+				}
+			}
+			penalty += linePenalty(line)
+		}
+	}
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < q.Size && y+1 < q.Size &&
+				c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				penalty += 3
+			}
+		}
+	}
+	total := q.Size * q.Size
+	k := (abs(dark*20-total*10) + total - 1) / total
+	return penalty + max(k-1, 0)*10
+}
+
+var finderLike = []bool{true, false, true, true, true, false, true}
+
+func linePenalty(line []bool) int {
+	var penalty int
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += 3 + run - 5
+		}
+		run = 1
+	}
+	for i := 0; i+7 <= len(line); i++ {
+		if !equal(line[i:i+7], finderLike) {
+			continue
+		}
+		if lightRun(line, i-4, i) || lightRun(line, i+7, i+11) {
+			penalty += 40
+		}
+	}
+	return penalty
+}
+
+// lightRun reports whether the modules from start to end are light,
+// treating modules outside of the symbol as light.
+func lightRun(line []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equal(a, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// QuietZone is the number of light modules around the symbol.
+const QuietZone = 4
+
+// Image returns the QR code as image, with scale pixels per module
+// and the quiet zone.
+func (q *QRCode) Image(scale int) image.Image {
+	scale = max(scale, 1)
+	size := (q.Size + 2*QuietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := color.Gray{Y: 0xff}
+			if q.Dark(x/scale-QuietZone, y/scale-QuietZone) {
+				c.Y = 0
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	return img
+}
+
+// PNG returns the QR code as PNG image, with scale pixels per module.
+func (q *QRCode) PNG(scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, q.Image(scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ASCII returns the QR code for terminals, drawing two rows of modules
+// per line with Unicode half blocks, including a quiet zone of 2 modules.
+// The blocks represent the dark modules, unless inverse is set.
+// Inverse should be used on terminals with a dark background,
+// so the blocks represent the light modules.
+func (q *QRCode) ASCII(inverse bool) string {
+	const quiet = 2
+	dark := func(x, y int) bool {
+		return q.Dark(x-quiet, y-quiet) != inverse
+	}
+	var b strings.Builder
+	size := q.Size + 2*quiet
+	for y := 0; y < size; y += 2 {
+		for x := 0; x < size; x++ {
+			top, bottom := dark(x, y), y+1 < size && dark(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		b.bits = append(b.bits, value>>i&1 != 0)
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	data := make([]byte, (len(b.bits)+7)/8)
+	for i, bit := range b.bits {
+		if bit {
+			data[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return data
+}
+
+// interleave splits the data into the error correction blocks,
+// appends the Reed-Solomon codewords and interleaves the blocks.
+func interleave(data []byte, b blocks) []byte {
+	divisor := rsGenerator(b.ecc)
+	var dataBlocks, eccBlocks [][]byte
+	for i := 0; i < b.blocks1+b.blocks2; i++ {
+		n := b.data1
+		if i >= b.blocks1 {
+			n = b.data2
+		}
+		block := data[:n]
+		data = data[n:]
+		dataBlocks = append(dataBlocks, block)
+		eccBlocks = append(eccBlocks, rsRemainder(block, divisor))
+	}
+	var result []byte
+	for i := 0; i < max(b.data1, b.data2); i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecc; i++ {
+		for _, block := range eccBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// rsGenerator returns the coefficients of the Reed-Solomon generator polynomial
+// of the degree, highest to lowest power, excluding the leading 1.
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
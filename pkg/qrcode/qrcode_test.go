@@ -0,0 +1,171 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode_version(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		level   Level
+		version int
+	}{
+		{"empty", "", Low, 1},
+		{"version 1 L full", strings.Repeat("a", 17), Low, 1},
+		{"version 2 L", strings.Repeat("a", 18), Low, 2},
+		{"version 1 H full", strings.Repeat("a", 7), High, 1},
+		{"verification uri", "https://auth.example.com/device?user_code=WDJB-MJHT", Medium, 4},
+		{"version 10 L", strings.Repeat("a", 271), Low, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Encode(tt.text, tt.level)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, q.Version)
+			assert.Equal(t, tt.version*4+17, q.Size)
+			assert.Equal(t, tt.level, q.Level)
+		})
+	}
+}
+
+func TestEncode_tooLong(t *testing.T) {
+	_, err := Encode(strings.Repeat("a", 272), Low)
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestEncode_invalidLevel(t *testing.T) {
+	for _, level := range []Level{-1, High + 1} {
+		_, err := Encode("text", level)
+		assert.ErrorIs(t, err, ErrInvalidLevel)
+	}
+}
+
+func TestEncode_finderPatterns(t *testing.T) {
+	q, err := Encode("https://example.com", Medium)
+	require.NoError(t, err)
+	finder := []string{
+		"#######",
+		"#.....#",
+		"#.###.#",
+		"#.###.#",
+		"#.###.#",
+		"#.....#",
+		"#######",
+	}
+	for _, corner := range [][2]int{{0, 0}, {q.Size - 7, 0}, {0, q.Size - 7}} {
+		for y, row := range finder {
+			for x, c := range row {
+				assert.Equal(t, c == '#', q.Dark(corner[0]+x, corner[1]+y), "corner %v at %d,%d", corner, x, y)
+			}
+		}
+	}
+	assert.True(t, q.Dark(8, q.Size-8), "dark module")
+	assert.False(t, q.Dark(-1, 0))
+	assert.False(t, q.Dark(0, q.Size))
+}
+
+func TestEncode_formatBits(t *testing.T) {
+	q, err := Encode("https://example.com", Quartile)
+	require.NoError(t, err)
+
+	// read both copies of the format information
+	var first, second int
+	for i := 0; i <= 5; i++ {
+		first |= bit(q.Dark(8, i)) << i
+	}
+	first |= bit(q.Dark(8, 7))<<6 | bit(q.Dark(8, 8))<<7 | bit(q.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		first |= bit(q.Dark(14-i, 8)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= bit(q.Dark(q.Size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(q.Dark(8, q.Size-15+i)) << i
+	}
+	assert.Equal(t, first, second)
+	assert.Equal(t, Quartile.formatBits(), (first^0x5412)>>13)
+}
+
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func Test_drawFormatBits(t *testing.T) {
+	// ISO/IEC 18004, annex C: level L and mask 4 is 110011000101111
+	q := &QRCode{Version: 1, Level: Low, Size: 21, modules: newGrid(21), isFunction: newGrid(21)}
+	q.drawFormatBits(4)
+	var bits int
+	for i := 0; i < 8; i++ {
+		bits |= bit(q.Dark(q.Size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		bits |= bit(q.Dark(8, q.Size-15+i)) << i
+	}
+	assert.Equal(t, 0b110011000101111, bits)
+}
+
+func Test_versionBits(t *testing.T) {
+	assert.Equal(t, 0x07C94, versionBits(7))
+	assert.Equal(t, 0x0A4D3, versionBits(10))
+}
+
+func Test_rsRemainder(t *testing.T) {
+	// HELLO WORLD at version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, want, rsRemainder(data, rsGenerator(10)))
+}
+
+func Test_interleave(t *testing.T) {
+	b := ecBlocks[5][Quartile]
+	data := make([]byte, b.dataCodewords())
+	for i := range data {
+		data[i] = byte(i)
+	}
+	result := interleave(data, b)
+	require.Len(t, result, b.dataCodewords()+b.ecc*(b.blocks1+b.blocks2))
+	// first codeword of each block, then the second...
+	assert.Equal(t, []byte{0, 15, 30, 46, 1, 16, 31, 47}, result[:8])
+	// the last codewords only exist in the second group
+	assert.Equal(t, []byte{45, 61}, result[b.dataCodewords()-2:b.dataCodewords()])
+}
+
+func TestQRCode_PNG(t *testing.T) {
+	q, err := Encode("https://example.com", Low)
+	require.NoError(t, err)
+	data, err := q.PNG(4)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, (q.Size+2*QuietZone)*4, img.Bounds().Dx())
+
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r, "quiet zone is light")
+	r, _, _, _ = img.At(QuietZone*4, QuietZone*4).RGBA()
+	assert.Equal(t, uint32(0), r, "finder pattern is dark")
+}
+
+func TestQRCode_ASCII(t *testing.T) {
+	q, err := Encode("https://example.com", Low)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(q.ASCII(false), "\n"), "\n")
+	assert.Len(t, lines, (q.Size+4+1)/2)
+	for _, line := range lines {
+		assert.Equal(t, q.Size+4, len([]rune(line)))
+	}
+	assert.Equal(t, "  █▀▀▀▀▀█", string([]rune(lines[1])[:9]))
+
+	inverse := strings.Split(q.ASCII(true), "\n")
+	assert.Equal(t, "██ ▄▄▄▄▄ ", string([]rune(inverse[1])[:9]))
+}